@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/loadtest"
+)
+
+// loadgen drives invoice creation and listing against a running server and
+// reports latency percentiles. With -mock it also serves an in-memory
+// QuickBooks the server can be pointed at, so throttling and pooling are
+// exercised without a sandbox company.
+//
+//	loadgen -mock :9090                                  # serve the mock only
+//	loadgen -target http://localhost:8080 -user u1       # load a server
+//	loadgen -mock :9090 -target http://localhost:8080 -user u1 -concurrency 50 -duration 1m
+func main() {
+	var (
+		target      = flag.String("target", "", "base URL of the server to load, e.g. http://localhost:8080")
+		user        = flag.String("user", "", "user ID sent as X-User-ID")
+		tenant      = flag.String("tenant", "", "tenant ID sent as X-Tenant-ID")
+		apiKey      = flag.String("api-key", "", "tenant API key, instead of -user")
+		noCache     = flag.Bool("no-cache", false, "bypass the server's micro-cache so every read reaches QuickBooks")
+		concurrency = flag.Int("concurrency", 10, "concurrent workers")
+		duration    = flag.Duration("duration", 30*time.Second, "how long to run")
+		requests    = flag.Int("requests", 0, "stop after this many requests (0 runs for -duration)")
+		rate        = flag.Float64("rate", 0, "requests per second across workers (0 is unthrottled)")
+		mix         = flag.String("mix", "create=1,list=3,get=6", "operation weights")
+		listLimit   = flag.Int("list-limit", 20, "page size of list requests")
+		jsonOut     = flag.Bool("json", false, "print the report as JSON")
+
+		mockAddr        = flag.String("mock", "", "serve a mock QuickBooks on this address, e.g. :9090")
+		mockLatency     = flag.Duration("mock-latency", 80*time.Millisecond, "mock response latency")
+		mockJitter      = flag.Duration("mock-jitter", 40*time.Millisecond, "extra random mock latency, up to")
+		mockRPM         = flag.Int("mock-rpm", loadtest.DefaultRequestsPerMinute, "mock requests per minute per company (0 is unlimited)")
+		mockConcurrency = flag.Int("mock-concurrency", loadtest.DefaultMaxConcurrent, "mock concurrent requests per company (0 is unlimited)")
+		mockThrottle    = flag.Float64("mock-throttle", 0, "fraction of mock requests answered 429 regardless of limits")
+		mockSeed        = flag.Int("mock-seed", 100, "invoices each mock company starts with")
+	)
+	flag.Parse()
+
+	if *target == "" && *mockAddr == "" {
+		fmt.Fprintln(os.Stderr, "loadgen: -target or -mock is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var mock *loadtest.Mock
+	if *mockAddr != "" {
+		mock = loadtest.NewMock()
+		mock.Latency = *mockLatency
+		mock.Jitter = *mockJitter
+		mock.RequestsPerMinute = *mockRPM
+		mock.MaxConcurrent = *mockConcurrency
+		mock.ThrottleRate = *mockThrottle
+		mock.SeedInvoices = *mockSeed
+
+		listener, err := net.Listen("tcp", *mockAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen for the mock: %v", err)
+		}
+		server := &http.Server{Handler: mock.Handler()}
+		go server.Serve(listener)
+		defer server.Close()
+		log.Printf("Mock QuickBooks on http://%[1]s; token URL http://%[1]s/oauth2/v1/tokens/bearer", listener.Addr())
+	}
+
+	if *target == "" {
+		// Serve the mock until interrupted
+		<-ctx.Done()
+		printMockStats(mock, *jsonOut)
+		return
+	}
+
+	if *user == "" && *apiKey == "" {
+		log.Fatal("-user or -api-key is required with -target")
+	}
+	weights, err := loadtest.ParseMix(*mix)
+	if err != nil {
+		log.Fatalf("Invalid -mix: %v", err)
+	}
+	runner, err := loadtest.NewRunner(&loadtest.Target{
+		BaseURL:  *target,
+		UserID:   *user,
+		TenantID: *tenant,
+		APIKey:   *apiKey,
+		NoCache:  *noCache,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConns:        *concurrency,
+				MaxIdleConnsPerHost: *concurrency,
+			},
+		},
+	}, loadtest.Config{
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+		Rate:        *rate,
+		Mix:         weights,
+		ListLimit:   *listLimit,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create runner: %v", err)
+	}
+
+	log.Printf("Loading %s with %d workers", *target, *concurrency)
+	report := runner.Run(ctx)
+
+	if *jsonOut {
+		out := map[string]interface{}{"report": report}
+		if mock != nil {
+			out["mock"] = mock.Stats()
+		}
+		json.NewEncoder(os.Stdout).Encode(out)
+		return
+	}
+	report.WriteText(os.Stdout)
+	printMockStats(mock, false)
+}
+
+// printMockStats prints what the mock served, when one ran
+func printMockStats(mock *loadtest.Mock, asJSON bool) {
+	if mock == nil {
+		return
+	}
+	stats := mock.Stats()
+	if asJSON {
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"mock": stats})
+		return
+	}
+	fmt.Printf("\nmock: %d requests, %d throttled, %d creates, %d queries, %d reads, %d token calls, peak %d concurrent per company\n",
+		stats.Requests, stats.Throttled, stats.Creates, stats.Queries, stats.Reads, stats.Tokens, stats.PeakConcurrent)
+}
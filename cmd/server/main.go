@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,8 +10,13 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	_ "github.com/lib/pq" // Postgres driver for the pgvector search backend
 	"github.com/eGGnogSC/qbserver/config"
 	"github.com/eGGnogSC/qbserver/infrastructure"
+	"github.com/eGGnogSC/qbserver/internal/compliance"
+	"github.com/eGGnogSC/qbserver/internal/i18n"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/tracing"
 	"github.com/eGGnogSC/qbserver/routes"
 )
 
@@ -19,7 +24,8 @@ func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
 	}
 	
 	// Create context that can be cancelled
@@ -29,29 +35,132 @@ func main() {
 	// Create dependency container
 	container, err := infrastructure.NewContainer(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize dependencies: %v", err)
+		slog.Error("failed to initialize dependencies", "error", err)
+		os.Exit(1)
 	}
 	defer container.Shutdown()
+	logger := container.Logger
 	
 	// Create router
 	router := mux.NewRouter()
 	
 	// Set up routes
-	routes.SetupRoutes(
-		router,
-		container.AuthHandler,
-		container.AuthService,
-		container.InvoiceHandler,
-		container.CustomerHandler,
-		container.ItemHandler,
-		container.PaymentHandler,
-		container.AgentHandler,
-	)
+	routes.SetupRoutes(router, routes.Deps{
+		AuthHandler:            container.AuthHandler,
+		AuthService:            container.AuthService,
+		InvoiceHandler:         container.InvoiceHandler,
+		CustomerHandler:        container.CustomerHandler,
+		ItemHandler:            container.ItemHandler,
+		PaymentHandler:         container.PaymentHandler,
+		VendorHandler:          container.VendorHandler,
+		AccountHandler:         container.AccountHandler,
+		BillHandler:            container.BillHandler,
+		EstimateHandler:        container.EstimateHandler,
+		CreditMemoHandler:      container.CreditMemoHandler,
+		RefundReceiptHandler:   container.RefundReceiptHandler,
+		CDCHandler:             container.CDCHandler,
+		SyncConflictHandler:    container.SyncConflictHandler,
+		InvoiceStateHandler:    container.InvoiceStateHandler,
+		CommissionHandler:      container.CommissionHandler,
+		AgentHandler:           container.AgentHandler,
+		UsageTracker:           container.UsageTracker,
+		ToolRegistry:           container.ToolRegistry,
+		UsageHandler:           container.UsageHandler,
+		ToolPolicyHandler:      container.ToolPolicyHandler,
+		ScheduledTaskHandler:   container.ScheduledTaskHandler,
+		TranscriptHandler:      container.TranscriptHandler,
+		SearchHandler:          container.SearchHandler,
+		KnowledgeHandler:       container.KnowledgeHandler,
+		InsightsHandler:        container.InsightsHandler,
+		ClosingHandler:         container.ClosingHandler,
+		BudgetHandler:          container.BudgetHandler,
+		ProjectHandler:         container.ProjectHandler,
+		InventoryHandler:       container.InventoryHandler,
+		ExpenseHandler:         container.ExpenseHandler,
+		IntakeHandler:          container.IntakeHandler,
+		EmailReceiver:          container.EmailReceiver,
+		BillPayHandler:         container.BillPayHandler,
+		ExchangeRateHandler:    container.ExchangeRateHandler,
+		PayrollHandler:         container.PayrollHandler,
+		StripeHandler:          container.StripeHandler,
+		OrdersHandler:          container.OrdersHandler,
+		EInvoiceHandler:        container.EInvoiceHandler,
+		PackingSlipHandler:     container.PackingSlipHandler,
+		BrandingHandler:        container.BrandingHandler,
+		InvoicePDFHandler:      container.InvoicePDFHandler,
+		PaymentQRHandler:       container.PaymentQRHandler,
+		BankExportHandler:      container.BankExportHandler,
+		MigrationHandler:       container.MigrationHandler,
+		WarehouseHandler:       container.WarehouseHandler,
+		TenantConfigHandler:    container.TenantConfigHandler,
+		BundleHandler:          container.BundleHandler,
+		SandboxHandler:         container.SandboxHandler,
+		RealmCopyHandler:       container.RealmCopyHandler,
+		ChaosHandler:           container.ChaosHandler,
+		TransformHandler:       container.TransformHandler,
+		ScriptHandler:          container.ScriptHandler,
+		ReplayHandler:          container.ReplayHandler,
+		WebhookReceiver:        container.WebhookReceiver,
+		InvoiceWatchHandler:    container.InvoiceWatchHandler,
+		RealtimeHandler:        container.RealtimeHandler,
+		Display:                container.Display,
+		Shaper:                 container.Shaper,
+		ShapingHandler:         container.ShapingHandler,
+		TimeZoneService:        container.TimeZoneService,
+		TimeZoneHandler:        container.TimeZoneHandler,
+		PeriodLockHandler:      container.PeriodLockHandler,
+		RoundingService:        container.RoundingService,
+		RoundingHandler:        container.RoundingHandler,
+		UpsertHandler:          container.UpsertHandler,
+		RefHandler:             container.RefHandler,
+		ExternalIDs:            container.ExternalIDs,
+		ExternalIDHandler:      container.ExternalIDHandler,
+		OperationsHandler:      container.OperationsHandler,
+		AdminOperationsHandler: container.AdminOperationsHandler,
+		Meter:                  container.Meter,
+		MeteringHandler:        container.MeteringHandler,
+		Tenants:                container.Tenants,
+		TenantHandler:          container.TenantHandler,
+		ReadOnly:               container.ReadOnly,
+		ReadOnlyHandler:        container.ReadOnlyHandler,
+		RetryQueue:             container.RetryQueue,
+		RetryQueueHandler:      container.RetryQueueHandler,
+		VersionHandler:         container.VersionHandler,
+		TrashHandler:           container.TrashHandler,
+		QBHealthHandler:        container.QBHealthHandler,
+		ConnStatsHandler:       container.ConnStatsHandler,
+		ComplianceHandler:      container.ComplianceHandler,
+		Coalescer:              container.Coalescer,
+		APIRoutes:              container.APIRoutes,
+		Deprecations:           container.Deprecations,
+		DeprecationHandler:     container.DeprecationHandler,
+	}, cfg.Admin.APIKey)
+	// Probes report dependencies warming up, e.g. Redis started in retry mode
+	routes.RegisterHealthRoutes(router, container.ReadinessHandler)
+	if cfg.Debug.Enabled {
+		// Profiling and runtime debug endpoints for diagnosing production
+		routes.RegisterDebugRoutes(router, cfg.Admin.APIKey)
+	}
+	// Routed requests are traced, down to their QuickBooks calls
+	router.Use(tracing.Middleware)
+	router.Use(i18n.Middleware)
+	if cfg.Compliance.Enabled {
+		// Error responses in the JSON format Intuit expects of App Store apps
+		router.Use(compliance.ErrorMiddleware)
+	}
+	if container.ChaosInjector.Enabled() {
+		router.Use(container.ChaosInjector.Middleware)
+	}
+	
+	// Replay writes queued during read-only mode once it is turned off
+	container.ReadOnly.StartDrain(ctx, router, 5*time.Second)
+	container.RetryQueue.StartWorker(ctx, router, 10*time.Second)
 	
-	// Create HTTP server
+	// Create HTTP server; every request gets an ID and an access log line
 	server := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
-		Handler:      router,
+		Handler:      logging.Middleware(logger)(router),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
 		ReadTimeout:  time.Duration(cfg.Server.Timeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.Server.Timeout) * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -59,9 +168,10 @@ func main() {
 	
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server starting on port %s", cfg.Server.Port)
+		logger.Info("server starting", "port", cfg.Server.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
+			logger.Error("server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 	
@@ -71,15 +181,16 @@ func main() {
 	<-quit
 	
 	// Shutdown gracefully
-	log.Println("Shutting down server...")
+	logger.Info("shutting down server")
 	
 	// Create shutdown context with timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 	
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Server shutdown failed: %v", err)
+		logger.Error("server shutdown failed", "error", err)
+		os.Exit(1)
 	}
 	
-	log.Println("Server gracefully stopped")
+	logger.Info("server gracefully stopped")
 }
@@ -3,28 +3,113 @@ package infrastructure
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/eGGnogSC/qbserver/config"
+	infraredis "github.com/eGGnogSC/qbserver/infrastructure/redis"
+	"github.com/eGGnogSC/qbserver/internal/account"
 	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/bankexport"
+	"github.com/eGGnogSC/qbserver/internal/bill"
+	"github.com/eGGnogSC/qbserver/internal/billpay"
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/budget"
+	"github.com/eGGnogSC/qbserver/internal/bundle"
+	"github.com/eGGnogSC/qbserver/internal/cdc"
+	"github.com/eGGnogSC/qbserver/internal/chaos"
+	"github.com/eGGnogSC/qbserver/internal/closing"
+	"github.com/eGGnogSC/qbserver/internal/coalesce"
+	"github.com/eGGnogSC/qbserver/internal/commission"
+	"github.com/eGGnogSC/qbserver/internal/compliance"
+	"github.com/eGGnogSC/qbserver/internal/connstats"
+	"github.com/eGGnogSC/qbserver/internal/creditmemo"
 	"github.com/eGGnogSC/qbserver/internal/customer"
+	"github.com/eGGnogSC/qbserver/internal/deprecation"
+	"github.com/eGGnogSC/qbserver/internal/einvoice"
+	"github.com/eGGnogSC/qbserver/internal/estimate"
+	"github.com/eGGnogSC/qbserver/internal/expense"
+	"github.com/eGGnogSC/qbserver/internal/extid"
+	"github.com/eGGnogSC/qbserver/internal/fxrate"
+	"github.com/eGGnogSC/qbserver/internal/i18n"
+	"github.com/eGGnogSC/qbserver/internal/insights"
+	"github.com/eGGnogSC/qbserver/internal/intake"
+	"github.com/eGGnogSC/qbserver/internal/inventory"
 	"github.com/eGGnogSC/qbserver/internal/invoice"
+	"github.com/eGGnogSC/qbserver/internal/invoicepdf"
+	"github.com/eGGnogSC/qbserver/internal/invoicestate"
+	"github.com/eGGnogSC/qbserver/internal/invoicewatch"
 	"github.com/eGGnogSC/qbserver/internal/item"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/eGGnogSC/qbserver/internal/knowledge"
+	"github.com/eGGnogSC/qbserver/internal/metering"
+	"github.com/eGGnogSC/qbserver/internal/migration"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/notify"
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/eGGnogSC/qbserver/internal/orders"
+	"github.com/eGGnogSC/qbserver/internal/outbox"
+	"github.com/eGGnogSC/qbserver/internal/packingslip"
 	"github.com/eGGnogSC/qbserver/internal/payment"
+	"github.com/eGGnogSC/qbserver/internal/payqr"
+	"github.com/eGGnogSC/qbserver/internal/payroll"
+	"github.com/eGGnogSC/qbserver/internal/pdf"
+	"github.com/eGGnogSC/qbserver/internal/periodlock"
+	"github.com/eGGnogSC/qbserver/internal/project"
+	"github.com/eGGnogSC/qbserver/internal/qbhealth"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+	"github.com/eGGnogSC/qbserver/internal/readiness"
+	"github.com/eGGnogSC/qbserver/internal/readonly"
+	"github.com/eGGnogSC/qbserver/internal/realmcopy"
+	"github.com/eGGnogSC/qbserver/internal/realtime"
+	"github.com/eGGnogSC/qbserver/internal/refs"
+	"github.com/eGGnogSC/qbserver/internal/refundreceipt"
+	"github.com/eGGnogSC/qbserver/internal/retryqueue"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/sandbox"
+	"github.com/eGGnogSC/qbserver/internal/scripting"
+	"github.com/eGGnogSC/qbserver/internal/search"
+	"github.com/eGGnogSC/qbserver/internal/shaping"
+	"github.com/eGGnogSC/qbserver/internal/stripe"
+	"github.com/eGGnogSC/qbserver/internal/syncconflict"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/internal/tenants"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/internal/totals"
+	"github.com/eGGnogSC/qbserver/internal/tracing"
+	"github.com/eGGnogSC/qbserver/internal/transform"
+	"github.com/eGGnogSC/qbserver/internal/trash"
+	"github.com/eGGnogSC/qbserver/internal/upsert"
+	"github.com/eGGnogSC/qbserver/internal/vendor"
+	"github.com/eGGnogSC/qbserver/internal/versions"
+	"github.com/eGGnogSC/qbserver/internal/warehouse"
+	"github.com/eGGnogSC/qbserver/internal/writelock"
 	"github.com/eGGnogSC/qbserver/nlp"
 	"github.com/eGGnogSC/qbserver/pkg/qbclient"
 )
 
 // Container provides application dependencies
 type Container struct {
+	// Structured logger, handed to requests through their context
+	Logger *slog.Logger
+	// shutdownTracing flushes buffered trace spans
+	shutdownTracing func(context.Context) error
+	
 	// Services
 	AuthService     *auth.Service
 	InvoiceService  *invoice.Service
 	CustomerService *customer.Service
 	ItemService     *item.Service
 	PaymentService  *payment.Service
+	VendorService   vendor.Servicer
+	AccountService  account.Servicer
 	
 	// Handlers
 	AuthHandler     *auth.Handler
@@ -32,19 +117,264 @@ type Container struct {
 	CustomerHandler *customer.Handler
 	ItemHandler     *item.Handler
 	PaymentHandler  *payment.Handler
+	VendorHandler   *vendor.Handler
+	AccountHandler  *account.Handler
+	BillHandler     *bill.Handler
+	EstimateHandler *estimate.Handler
+	CDCHandler      *cdc.Handler
 	AgentHandler    *nlp.AgentHandler
 	
+	// Credit memos and refund receipts
+	CreditMemoHandler    *creditmemo.Handler
+	RefundReceiptHandler *refundreceipt.Handler
+	
+	// Agent usage and tools
+	UsageTracker      *nlp.UsageTracker
+	UsageHandler      *nlp.UsageHandler
+	ToolRegistry      *nlp.ToolRegistry
+	ToolPolicyHandler *nlp.ToolPolicyHandler
+	
+	// Agent scheduled tasks
+	LLMProvider          nlp.LLMProvider
+	ScheduledTaskService *nlp.ScheduledTaskService
+	ScheduledTaskHandler *nlp.ScheduledTaskHandler
+	
+	// Agent transcripts
+	TranscriptStore   *nlp.TranscriptStore
+	TranscriptHandler *nlp.TranscriptHandler
+	
+	// Semantic search
+	SearchService search.Servicer
+	SearchHandler *search.Handler
+	
+	// Tenant reference documents
+	KnowledgeService knowledge.Servicer
+	KnowledgeHandler *knowledge.Handler
+	
+	// Transaction insights
+	InsightsService insights.Servicer
+	InsightsHandler *insights.Handler
+	CustomerMetrics *insights.MetricsService
+	
+	// Month-end close
+	ClosingHandler *closing.Handler
+	
+	// Budgets
+	BudgetHandler *budget.Handler
+	
+	// Project profitability
+	ProjectHandler *project.Handler
+	
+	// Inventory reorder alerts
+	InventoryService inventory.Servicer
+	InventoryHandler *inventory.Handler
+	
+	// Mileage and expense claims
+	ExpenseHandler *expense.Handler
+	
+	// Receipt and bill intake, scanned or emailed in
+	IntakeHandler *intake.Handler
+	EmailReceiver *intake.EmailReceiver
+	
+	// Vendor bill payment queue
+	BillPayHandler *billpay.Handler
+	
+	// Exchange rates and foreign currency revaluation
+	ExchangeRateHandler *fxrate.Handler
+	
+	// Payroll journal import
+	PayrollHandler *payroll.Handler
+	
+	// Stripe payout reconciliation
+	StripeHandler *stripe.Handler
+	
+	// Storefront order imports
+	OrdersHandler *orders.Handler
+	
+	// UBL/PEPPOL e-invoicing export
+	EInvoiceHandler *einvoice.Handler
+	
+	// Branded packing slips and delivery notes
+	PackingSlipHandler *packingslip.Handler
+	BrandingHandler    *branding.Handler
+	
+	// Branded invoice PDFs and payment QR codes
+	InvoicePDFHandler *invoicepdf.Handler
+	PaymentQRHandler  *payqr.Handler
+	
+	// OFX/QBO bank file export
+	BankExportHandler *bankexport.Handler
+	
+	// QuickBooks Desktop IIF/QBXML list import
+	MigrationHandler *migration.Handler
+	
+	// Data warehouse export
+	WarehouseHandler *warehouse.Handler
+	
+	// Declarative tenant configuration
+	TenantConfigHandler *tenantconfig.Handler
+	
+	// Local drafts pushed to QuickBooks under the tenants' conflict policies
+	SyncConflictHandler *syncconflict.Handler
+	
+	// Invoice lifecycle statuses under the tenants' state machines
+	InvoiceStateHandler *invoicestate.Handler
+	
+	// Sales rep tags and commission reports under the tenants' rules
+	CommissionHandler *commission.Handler
+	
+	// Tenant configuration bundles
+	BundleHandler *bundle.Handler
+	
+	// Sandbox demo data seeding
+	SandboxHandler *sandbox.Handler
+	
+	// Copying data between connected companies
+	RealmCopyHandler *realmcopy.Handler
+	
+	// Fault injection for resilience testing
+	ChaosHandler *chaos.Handler
+	
+	// Payload transformation rules
+	TransformHandler *transform.Handler
+	
+	// Tenant scripts run at QuickBooks hook points
+	ScriptHandler *scripting.Handler
+	
+	// Name and external ID references in write payloads
+	RefHandler *refs.Handler
+	
+	// Other systems' IDs mapped to QuickBooks IDs
+	ExternalIDs       *extid.Store
+	ExternalIDHandler *extid.Handler
+	
+	// Keeps writes out of closed accounting periods
+	PeriodLockHandler *periodlock.Handler
+	
+	// Background jobs and notifications
+	JobScheduler *jobs.Scheduler
+	Notifier     *notify.Dispatcher
+	
+	// Reliable event publishing
+	Outbox      *outbox.Store
+	OutboxRelay *outbox.Relay
+	
+	// Read-only mode and maintenance windows, with optional write queueing
+	ReadOnly        *readonly.Switch
+	ReadOnlyHandler *readonly.Handler
+	
+	// Writes queued for retry after transient QuickBooks failures
+	RetryQueue        *retryqueue.Queue
+	RetryQueueHandler *retryqueue.Handler
+	
+	// Entity version history and restore
+	Versions       *versions.Store
+	VersionHandler *versions.Handler
+	
+	// Deletes and voids through a restorable trash
+	TrashHandler *trash.Handler
+	
+	// Self-serve tenant onboarding and tenant API keys
+	Tenants       *tenants.Service
+	TenantHandler *tenants.Handler
+	
+	// QuickBooks API call statistics, deprecation notices and per-realm diagnostics
+	QBHealthHandler *qbhealth.Handler
+	
+	// QuickBooks connection and token analytics
+	ConnStatsHandler *connstats.Handler
+	
+	// Self-check against Intuit's App Store checklist
+	ComplianceHandler *compliance.Handler
+	
+	// Coalescing of identical concurrent reads and their micro-cache
+	Coalescer *coalesce.Coalescer
+	
+	// Declared API routes and their metadata
+	APIRoutes *routing.Registry
+	
+	// Deprecation headers and usage of deprecated routes and parameters
+	Deprecations       *deprecation.Tracker
+	DeprecationHandler *deprecation.Handler
+	
+	// Dependency readiness for liveness and readiness probes
+	Readiness        *readiness.Tracker
+	ReadinessHandler *readiness.Handler
+	
+	// Per-tenant usage metering and plan quotas
+	Meter           *metering.Meter
+	MeteringHandler *metering.Handler
+	
+	// Bulk imports, backfills and exports run in the background
+	Operations             *operations.Manager
+	OperationsHandler      *operations.Handler
+	AdminOperationsHandler *operations.Handler
+	
+	// Deduplicated, per-entity ordered QuickBooks webhook processing
+	WebhookIngester *qbwebhook.Ingester
+	WebhookReceiver *qbwebhook.Receiver
+	ReplayHandler   *qbwebhook.ReplayHandler
+	
+	// Live invoice payment status
+	InvoiceWatchHub     *invoicewatch.Hub
+	InvoiceWatchHandler *invoicewatch.Handler
+	
+	// Realtime entity updates over WebSocket
+	RealtimeHub     *realtime.Hub
+	RealtimeHandler *realtime.Handler
+	
+	// Locale-formatted display fields
+	Display *i18n.Display
+	
+	// Client-selected response profiles: field masks, expansions and page sizes
+	Shaper         *shaping.Shaper
+	ShapingHandler *shaping.Handler
+	
+	// Realm-local time zones
+	TimeZoneService timezone.Servicer
+	TimeZoneHandler *timezone.Handler
+	
+	// Per-realm rounding of computed amounts
+	RoundingService money.Servicer
+	RoundingHandler *money.Handler
+	
+	// Create-or-get upserts of customers and items
+	UpsertHandler *upsert.Handler
+	
 	// Infrastructure
 	RedisClient     redis.UniversalClient
-	RedisHealth     *redis.HealthChecker
+	RedisHealth     *infraredis.HealthChecker
 	TokenStore      auth.TokenStore
 	QBClient        *qbclient.Client
+	ChaosInjector   *chaos.Injector
 }
 
 // NewContainer creates and initializes the dependency container
 func NewContainer(ctx context.Context, cfg config.Config) (*Container, error) {
 	container := &Container{}
 	
+	// Log in structure; the default logger serves background work and the
+	// standard log package
+	container.Logger = logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(container.Logger)
+	
+	// Trace requests through to QuickBooks and the agent's model, exported
+	// over OTLP when enabled
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Enabled:     cfg.Tracing.Enabled,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Headers:     cfg.Tracing.Headers,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return nil, err
+	}
+	container.shutdownTracing = shutdownTracing
+	
+	// Size in-memory caches before any is created
+	lru.Configure(cfg.Cache.Capacities)
+	
 	// Initialize Redis client based on configuration
 	var redisClient redis.UniversalClient
 
@@ -65,23 +395,99 @@ func NewContainer(ctx context.Context, cfg config.Config) (*Container, error) {
 		})
 	}
 
+	// Fault injection for resilience testing; refused in production
+	container.ChaosInjector = chaos.NewInjector(cfg.Chaos.Enabled)
+	container.ChaosHandler = chaos.NewHandler(container.ChaosInjector)
+	if cfg.Chaos.Enabled {
+		if err := chaos.CheckEnvironment(cfg.QuickBooks.APIBaseURL); err != nil {
+			return nil, fmt.Errorf("failed to enable fault injection: %w", err)
+		}
+		container.Logger.Warn("fault injection is enabled; this is for development only")
+		redisClient.AddHook(container.ChaosInjector.RedisHook())
+	}
+
 	// Create health checker
-	redisHealth := redis.NewHealthChecker(redisClient, 30*time.Second)
+	redisHealth := infraredis.NewHealthChecker(redisClient, 30*time.Second)
+	container.RedisClient = redisClient
+	container.RedisHealth = redisHealth
 
-	// Create token store with Redis
-	tokenStore := auth.NewRedisTokenStore(redisClient, config.Redis.KeyPrefix)
+	// Connect to Redis as configured for when it is unreachable, reporting
+	// readiness while it warms up
+	container.Readiness = readiness.NewTracker()
+	container.ReadinessHandler = readiness.NewHandler(container.Readiness)
+	startupMode, err := infraredis.ParseStartupMode(cfg.Redis.StartupMode)
+	if err != nil {
+		return nil, err
+	}
+	err = infraredis.Startup{
+		Mode:            startupMode,
+		Timeout:         cfg.Redis.StartupTimeout,
+		WarmConnections: cfg.Redis.WarmConnections,
+		Tracker:         container.Readiness,
+		OnReady: func() {
+			checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			redisHealth.Check(checkCtx)
+		},
+	}.Connect(ctx, redisClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	// Create token store with Redis; when starting degraded, tokens are also
+	// kept in memory so connected users keep working while Redis is down
+	if startupMode == infraredis.StartupDegrade {
+		container.TokenStore = auth.NewFallbackTokenStore(redisClient, cfg.Redis.KeyPrefix, redisHealth.IsHealthy)
+	} else {
+		container.TokenStore = auth.NewRedisTokenStore(redisClient, cfg.Redis.KeyPrefix)
+	}
 
 	// Initialize services
 	container.AuthService = auth.NewService(auth.OAuthConfig{
 		ClientID:     cfg.QuickBooks.ClientID,
 		ClientSecret: cfg.QuickBooks.ClientSecret,
 		RedirectURI:  cfg.QuickBooks.RedirectURI,
+		RedirectURIs: cfg.QuickBooks.RedirectURIs,
 		Scopes:       cfg.QuickBooks.Scopes,
 		AuthURL:      cfg.QuickBooks.AuthURL,
 		TokenURL:     cfg.QuickBooks.TokenURL,
 		APIBaseURL:   cfg.QuickBooks.APIBaseURL,
 	}, container.TokenStore)
 	
+	// Onboarded tenants may bring their own Intuit app; connect links are
+	// served from the same origin as the OAuth callback
+	publicURL := cfg.QuickBooks.RedirectURI
+	if u, err := url.Parse(publicURL); err == nil && u.Host != "" {
+		publicURL = u.Scheme + "://" + u.Host
+	}
+	container.Tenants = tenants.NewService(redisClient, cfg.Redis.KeyPrefix, publicURL)
+	
+	// Count connections made, refreshed and lost for analytics
+	connStats := connstats.NewStats(redisClient, cfg.Redis.KeyPrefix)
+	container.AuthService = container.AuthService.
+		WithCredentialSource(container.Tenants).
+		WithScopeSource(container.Tenants).
+		WithConnectionStates(container.Tenants).
+		WithConnectionEvents(connStats)
+	
+	// Behaviors Intuit requires of apps published on its App Store
+	if cfg.Compliance.Enabled {
+		container.AuthService = container.AuthService.WithCompliance(compliance.NewOpenID(compliance.IntuitIssuer, compliance.IntuitJWKSURL))
+	}
+	container.ComplianceHandler = compliance.NewHandler(compliance.NewChecker(compliance.Settings{
+		Enabled:       cfg.Compliance.Enabled,
+		RedirectURIs:  append([]string{cfg.QuickBooks.RedirectURI}, cfg.QuickBooks.RedirectURIs...),
+		Scopes:        cfg.QuickBooks.Scopes,
+		APIBaseURL:    cfg.QuickBooks.APIBaseURL,
+		WebhookURL:    cfg.Compliance.WebhookURL,
+		DisconnectURL: cfg.Compliance.DisconnectURL,
+	}))
+	container.TenantHandler = tenants.NewHandler(container.Tenants, container.AuthService)
+	
+	// Per-tenant usage metering and plan quotas
+	container.Meter = metering.NewMeter(redisClient, cfg.Redis.KeyPrefix)
+	container.MeteringHandler = metering.NewHandler(container.Meter)
+	
 	// Initialize QuickBooks client
 	container.QBClient = qbclient.NewClient(
 		cfg.QuickBooks.APIBaseURL,
@@ -89,6 +495,176 @@ func NewContainer(ctx context.Context, cfg config.Config) (*Container, error) {
 		cfg.QuickBooks.ClientSecret,
 		container.AuthService,
 	)
+	if cfg.QuickBooks.Retry.MaxAttempts > 0 {
+		// Retry transient QuickBooks failures as configured instead of by default
+		container.QBClient = container.QBClient.WithRetryPolicy(qbclient.RetryPolicy{
+			MaxAttempts:   cfg.QuickBooks.Retry.MaxAttempts,
+			BaseDelay:     cfg.QuickBooks.Retry.BaseDelay,
+			MaxDelay:      cfg.QuickBooks.Retry.MaxDelay,
+			Jitter:        cfg.QuickBooks.Retry.Jitter,
+			MaxRetryAfter: cfg.QuickBooks.Retry.MaxRetryAfter,
+		})
+	}
+	// Queue requests per company under QuickBooks' throttle limits instead
+	// of running into 429s
+	rateLimit := qbclient.DefaultRateLimit
+	if cfg.QuickBooks.RateLimit.PerMinute > 0 {
+		rateLimit = qbclient.RateLimit{
+			PerMinute:     cfg.QuickBooks.RateLimit.PerMinute,
+			Burst:         cfg.QuickBooks.RateLimit.Burst,
+			MaxConcurrent: cfg.QuickBooks.RateLimit.MaxConcurrent,
+			MaxWait:       cfg.QuickBooks.RateLimit.MaxWait,
+		}
+	}
+	container.QBClient = container.QBClient.WithRateLimiter(qbclient.NewRateLimiter(rateLimit))
+	// Watch real QuickBooks responses for deprecation notices, latency and throttling
+	qbHealth := qbhealth.NewMonitor(redisClient, cfg.Redis.KeyPrefix)
+	container.QBHealthHandler = qbhealth.NewHandler(qbHealth)
+	container.ConnStatsHandler = connstats.NewHandler(connstats.NewService(connStats, container.QBClient))
+	
+	// Collapse bursts of identical reads into one QuickBooks call, with
+	// per-route micro-cache times overridable in configuration
+	container.Coalescer = coalesce.NewCoalescer(coalesce.DefaultRoutes)
+	for template, ttl := range cfg.Coalesce.Routes {
+		container.Coalescer.SetRoute(template, ttl)
+	}
+	
+	// API routes are declared with the metadata middlewares look up, and
+	// served per API version; retiring versions announce their sunset
+	container.APIRoutes = routing.NewRegistry()
+	for version, sunset := range cfg.API.Sunsets {
+		if err := container.APIRoutes.Deprecate(version, sunset); err != nil {
+			return nil, fmt.Errorf("failed to deprecate API version: %w", err)
+		}
+	}
+	container.Deprecations = deprecation.NewTracker(redisClient, cfg.Redis.KeyPrefix)
+	container.DeprecationHandler = deprecation.NewHandler(container.Deprecations)
+	if cfg.Chaos.Enabled {
+		// Injected faults never reach QuickBooks, so they are not metered
+		container.QBClient = container.QBClient.WithTransport(container.ChaosInjector.Transport(container.Meter.Transport(qbHealth.Transport(nil))))
+	} else {
+		container.QBClient = container.QBClient.WithTransport(container.Meter.Transport(qbHealth.Transport(nil)))
+	}
+	if cfg.WriteLock.Enabled {
+		// Serialize concurrent writes to the same QuickBooks entity
+		container.QBClient = container.QBClient.WithLocker(writelock.NewLocker(
+			redisClient,
+			cfg.Redis.KeyPrefix,
+			30*time.Second,
+			10*time.Second,
+		))
+	}
+	
+	// Block writes in read-only mode before any other transformer runs
+	container.ReadOnly = readonly.NewSwitch(redisClient, cfg.Redis.KeyPrefix, cfg.ReadOnly.Enabled, cfg.ReadOnly.Message)
+	container.QBClient = container.QBClient.WithTransformer(container.ReadOnly)
+	container.ReadOnlyHandler = readonly.NewHandler(container.ReadOnly)
+	
+	// Initialize the retry queue for writes that fail on QuickBooks outages
+	container.RetryQueue = retryqueue.NewQueue(redisClient, cfg.Redis.KeyPrefix)
+	container.RetryQueueHandler = retryqueue.NewHandler(container.RetryQueue)
+	
+	// Verify sales totals first, while responses are as QuickBooks returned them
+	container.QBClient = container.QBClient.WithTransformer(totals.NewVerifier())
+	
+	// Snapshot entities as QuickBooks stored them, before rules rewrite responses
+	container.Versions = versions.NewStore(redisClient, cfg.Redis.KeyPrefix)
+	container.QBClient = container.QBClient.WithTransformer(versions.NewRecorder(container.Versions))
+	
+	// Apply tenants' payload transformation rules to QuickBooks writes and reads
+	transformService := transform.NewService(redisClient, cfg.Redis.KeyPrefix)
+	container.QBClient = container.QBClient.WithTransformer(transformService)
+	container.TransformHandler = transform.NewHandler(transformService)
+	
+	// Tenant scripts run after the declarative rules, so they see their result
+	scriptService := scripting.NewService(redisClient, cfg.Redis.KeyPrefix)
+	container.QBClient = container.QBClient.WithTransformer(scriptService)
+	container.ScriptHandler = scripting.NewHandler(scriptService)
+	
+	// Translate other systems' IDs and record new mappings, then resolve
+	// references by name into QuickBooks IDs before the write is checked
+	container.ExternalIDs = extid.NewStore(redisClient, cfg.Redis.KeyPrefix)
+	container.QBClient = container.QBClient.WithTransformer(container.ExternalIDs)
+	container.ExternalIDHandler = extid.NewHandler(container.ExternalIDs)
+	refResolver := refs.NewResolver(redisClient, cfg.Redis.KeyPrefix, container.QBClient, container.ExternalIDs)
+	container.QBClient = container.QBClient.WithTransformer(refResolver)
+	container.RefHandler = refs.NewHandler(refResolver)
+	
+	// Check the closing date after rules and scripts have set the final TxnDate
+	periodLockGuard := periodlock.NewGuard(redisClient, cfg.Redis.KeyPrefix, container.QBClient)
+	container.QBClient = container.QBClient.WithTransformer(periodLockGuard)
+	container.PeriodLockHandler = periodlock.NewHandler(periodLockGuard)
+	
+	// Keep a copy of entities about to be deleted or voided, once the guards allowed it
+	trashStore := trash.NewStore(redisClient, cfg.Redis.KeyPrefix, 30*24*time.Hour)
+	container.QBClient = container.QBClient.WithTransformer(trash.NewRecorder(trashStore))
+	
+	// Record an outbox event for every write last, once all hooks have run
+	container.Outbox = outbox.NewStore(redisClient, cfg.Redis.KeyPrefix)
+	container.QBClient = container.QBClient.WithTransformer(outbox.NewRecorder(container.Outbox))
+	
+	// Forward writes made through this server to WebSocket clients
+	container.RealtimeHub = realtime.NewHub(redisClient, cfg.Redis.KeyPrefix)
+	container.QBClient = container.QBClient.WithTransformer(container.RealtimeHub)
+	
+	// Background operations, reported on by any instance
+	container.Operations = operations.NewManager(operations.NewStore(redisClient, cfg.Redis.KeyPrefix))
+	container.OperationsHandler = operations.NewHandler(container.Operations)
+	container.AdminOperationsHandler = operations.NewAdminHandler(container.Operations)
+	
+	// Remember processed webhook changes for three days, beyond Intuit's redelivery period
+	webhookStore := qbwebhook.NewStore(redisClient, cfg.Redis.KeyPrefix, 72*time.Hour)
+	container.WebhookIngester = qbwebhook.NewIngester(
+		webhookStore,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 5*time.Minute, 30*time.Second),
+	)
+	// QuickBooks posts change notifications signed with the app's verifier
+	// token; handlers act as the user who connected the company
+	container.WebhookReceiver = qbwebhook.NewReceiver(container.WebhookIngester, cfg.QuickBooks.WebhookVerifierToken, connStats)
+	container.ReplayHandler = qbwebhook.NewReplayHandler(qbwebhook.NewReplayer(
+		container.WebhookIngester,
+		webhookStore,
+		container.QBClient,
+		container.AuthService,
+	), container.Operations)
+	
+	// Push invoice status changes from webhooks to watching clients
+	container.InvoiceWatchHub = invoicewatch.NewHub(redisClient, cfg.Redis.KeyPrefix)
+	for _, entity := range []string{"Invoice", "Payment", "Deposit"} {
+		container.WebhookIngester.Register(entity, container.InvoiceWatchHub)
+	}
+	container.InvoiceWatchHandler = invoicewatch.NewHandler(container.InvoiceWatchHub, container.QBClient)
+	
+	// Forward webhook changes to WebSocket clients as well
+	container.WebhookIngester.Register("*", container.RealtimeHub)
+	
+	// Snapshot entities changed in QuickBooks that the server has history for
+	container.WebhookIngester.Register("*", versions.NewChangeHandler(container.Versions, container.QBClient))
+	container.VersionHandler = versions.NewHandler(versions.NewService(container.Versions, container.QBClient))
+	container.TrashHandler = trash.NewHandler(trash.NewService(trashStore, container.QBClient))
+	container.RealtimeHandler = realtime.NewHandler(container.RealtimeHub)
+	
+	// Format amounts and dates in API responses for the request locale
+	container.Display = i18n.NewDisplay(container.QBClient)
+	
+	// Trim responses to the fields constrained clients need
+	responseProfiles := shaping.NewProfiles(redisClient, cfg.Redis.KeyPrefix)
+	container.Shaper = shaping.NewShaper(responseProfiles, container.QBClient)
+	container.ShapingHandler = shaping.NewHandler(responseProfiles)
+	
+	// Resolve each realm's time zone from CompanyInfo for local dates and schedules
+	container.TimeZoneService = timezone.NewService(redisClient, cfg.Redis.KeyPrefix, container.QBClient)
+	container.TimeZoneHandler = timezone.NewHandler(container.TimeZoneService)
+	
+	// Round computed amounts with each realm's policy
+	container.RoundingService = money.NewService(redisClient, cfg.Redis.KeyPrefix)
+	container.RoundingHandler = money.NewHandler(container.RoundingService)
+	
+	// Serialize upserts per match key so retries and races never create duplicates
+	container.UpsertHandler = upsert.NewHandler(upsert.NewService(
+		container.QBClient,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 30*time.Second, 10*time.Second),
+	))
 	
 	// Initialize domain services
 	container.CustomerService = customer.NewService(container.QBClient)
@@ -99,6 +675,8 @@ func NewContainer(ctx context.Context, cfg config.Config) (*Container, error) {
 		container.ItemService,
 	)
 	container.PaymentService = payment.NewService(container.QBClient)
+	container.VendorService = vendor.NewService(container.QBClient)
+	container.AccountService = account.NewService(container.QBClient)
 	
 	// Initialize handlers
 	container.AuthHandler = auth.NewHandler(container.AuthService)
@@ -106,6 +684,23 @@ func NewContainer(ctx context.Context, cfg config.Config) (*Container, error) {
 	container.ItemHandler = item.NewHandler(container.ItemService)
 	container.InvoiceHandler = invoice.NewHandler(container.InvoiceService)
 	container.PaymentHandler = payment.NewHandler(container.PaymentService)
+	container.VendorHandler = vendor.NewHandler(container.VendorService)
+	container.AccountHandler = account.NewHandler(container.AccountService)
+	container.BillHandler = bill.NewHandler(bill.NewService(
+		container.QBClient,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 30*time.Second, 10*time.Second),
+	))
+	container.EstimateHandler = estimate.NewHandler(estimate.NewService(
+		container.QBClient,
+		container.InvoiceService,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 30*time.Second, 10*time.Second),
+	))
+	container.CreditMemoHandler = creditmemo.NewHandler(creditmemo.NewService(
+		container.QBClient,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 30*time.Second, 10*time.Second),
+	))
+	container.RefundReceiptHandler = refundreceipt.NewHandler(refundreceipt.NewService(container.QBClient))
+	container.CDCHandler = cdc.NewHandler(container.QBClient)
 	
 	// Initialize NLP processors
 	invoiceProcessor := nlp.NewInvoiceProcessor(
@@ -117,6 +712,309 @@ func NewContainer(ctx context.Context, cfg config.Config) (*Container, error) {
 	// Initialize Agent handler
 	container.AgentHandler = nlp.NewAgentHandler(invoiceProcessor)
 	
+	// Initialize agent usage tracking
+	usageConfig := nlp.DefaultUsageConfig()
+	usageConfig.SoftDailyLimit = cfg.NLP.SoftDailyBudget
+	usageConfig.HardDailyLimit = cfg.NLP.HardDailyBudget
+	container.UsageTracker = nlp.NewUsageTracker(
+		nlp.NewRedisUsageStore(redisClient, cfg.Redis.KeyPrefix, usageConfig.Retention),
+		usageConfig,
+		container.Meter,
+	)
+	container.UsageHandler = nlp.NewUsageHandler(container.UsageTracker)
+	
+	// Initialize agent tool registry with per-tenant and per-role permissions
+	toolPolicyStore := nlp.NewRedisToolPolicyStore(redisClient, cfg.Redis.KeyPrefix)
+	container.ToolRegistry = nlp.NewToolRegistry(
+		nlp.NewToolPermissions(toolPolicyStore, nlp.DefaultRolePolicies()),
+	)
+	container.ToolPolicyHandler = nlp.NewToolPolicyHandler(toolPolicyStore)
+	
+	// Initialize background jobs and notification channels
+	container.JobScheduler = jobs.NewScheduler(jobs.NewRedisStore(redisClient, cfg.Redis.KeyPrefix), 30*time.Second)
+	container.Notifier = notify.NewDispatcher()
+	container.Notifier.Register("email", notify.NewEmailChannel(notify.EmailConfig{
+		Host:     cfg.SMTP.Host,
+		Port:     cfg.SMTP.Port,
+		Username: cfg.SMTP.Username,
+		Password: cfg.SMTP.Password,
+		From:     cfg.SMTP.From,
+	}))
+	container.Notifier.Register("slack", notify.NewSlackChannel())
+	
+	// Initialize scheduled agent tasks
+	var llmProvider nlp.LLMProvider = nlp.NewHTTPProvider(cfg.NLP.APIBaseURL, cfg.NLP.APIKey, cfg.NLP.Model)
+	switch {
+	case cfg.NLP.FixtureDir != "":
+		// Test mode: answer from fixture files instead of calling the model
+		fixtureProvider, err := nlp.LoadFixtureProvider(cfg.NLP.FixtureDir)
+		if err != nil {
+			return nil, err
+		}
+		llmProvider = fixtureProvider
+		container.Logger.Info("agent LLM calls are served from fixtures", "dir", cfg.NLP.FixtureDir)
+	case cfg.NLP.RecordDir != "":
+		llmProvider = nlp.NewRecordingProvider(llmProvider, cfg.NLP.RecordDir)
+		container.Logger.Info("recording agent LLM replies as fixtures", "dir", cfg.NLP.RecordDir)
+	}
+	container.LLMProvider = nlp.NewMeteredProvider(nlp.NewTracedProvider(llmProvider), container.UsageTracker)
+	container.ScheduledTaskService = nlp.NewScheduledTaskService(
+		container.JobScheduler,
+		nlp.NewPlanner(container.LLMProvider, container.ToolRegistry),
+		container.ToolRegistry,
+		container.Notifier,
+	)
+	container.ScheduledTaskHandler = nlp.NewScheduledTaskHandler(container.ScheduledTaskService)
+	
+	// Initialize agent transcripts with PII redaction
+	container.TranscriptStore = nlp.NewTranscriptStore(redisClient, cfg.Redis.KeyPrefix, nlp.NewRedactor())
+	container.TranscriptHandler = nlp.NewTranscriptHandler(container.TranscriptStore)
+	
+	// Initialize semantic search over QuickBooks entities
+	var vectorStore search.VectorStore
+	if cfg.Search.Backend == "pgvector" {
+		db, err := sql.Open("postgres", cfg.Search.PostgresDSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open search database: %w", err)
+		}
+		pgStore := search.NewPgVectorStore(db, cfg.Search.Dimension)
+		if err := pgStore.EnsureSchema(ctx); err != nil {
+			return nil, err
+		}
+		vectorStore = pgStore
+	} else {
+		redisStore := search.NewRedisVectorStore(redisClient, cfg.Redis.KeyPrefix, cfg.Search.Dimension)
+		if err := redisStore.EnsureIndex(ctx); err != nil {
+			container.Logger.Warn("failed to create search index", "error", err)
+		}
+		vectorStore = redisStore
+	}
+	container.SearchService = search.NewService(
+		search.NewHTTPEmbedder(cfg.NLP.APIBaseURL, cfg.NLP.APIKey, cfg.Search.EmbeddingModel),
+		vectorStore,
+	)
+	container.SearchHandler = search.NewHandler(
+		container.SearchService,
+		search.NewIndexer(container.SearchService, container.QBClient),
+	)
+	container.ToolRegistry.Register(nlp.NewSearchTool(container.SearchService))
+	container.ToolRegistry.Register(nlp.NewOverdueInvoicesTool(container.QBClient))
+	
+	// Initialize tenant reference documents and feed them into agent prompts
+	container.KnowledgeService = knowledge.NewService(redisClient, cfg.Redis.KeyPrefix, container.SearchService)
+	container.KnowledgeHandler = knowledge.NewHandler(container.KnowledgeService)
+	container.LLMProvider = nlp.NewRetrievalProvider(container.LLMProvider, container.KnowledgeService, 4)
+	
+	// Initialize transaction anomaly detection
+	container.InsightsService = insights.NewService(
+		insights.NewAnalyzer(container.QBClient, insights.DefaultAnalyzerConfig()),
+		insights.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		container.JobScheduler,
+		container.Notifier,
+	)
+	behaviorAnalyzer := insights.NewBehaviorAnalyzer(container.QBClient)
+	invoiceStates := invoicestate.NewStore(redisClient, cfg.Redis.KeyPrefix)
+	container.CustomerMetrics = insights.NewMetricsService(
+		behaviorAnalyzer,
+		insights.NewMetricsStore(redisClient, cfg.Redis.KeyPrefix),
+		container.JobScheduler,
+	)
+	container.InsightsHandler = insights.NewHandler(
+		container.InsightsService,
+		insights.NewForecaster(container.QBClient, behaviorAnalyzer),
+		container.CustomerMetrics,
+		insights.NewAuditor(container.QBClient),
+		insights.NewFunnelAnalyzer(container.QBClient, invoiceStates),
+	)
+	
+	// Initialize month-end close workflow
+	container.ClosingHandler = closing.NewHandler(closing.NewService(container.QBClient))
+	
+	// Initialize budgets and budget vs actuals reporting
+	container.BudgetHandler = budget.NewHandler(
+		budget.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		budget.NewComparer(container.QBClient),
+	)
+	
+	// Initialize project profitability
+	container.ProjectHandler = project.NewHandler(project.NewService(container.QBClient))
+	
+	// Initialize inventory reorder alerts
+	container.InventoryService = inventory.NewService(
+		container.QBClient,
+		inventory.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		container.JobScheduler,
+		container.Notifier,
+	)
+	container.InventoryHandler = inventory.NewHandler(container.InventoryService)
+	
+	// Initialize mileage and reimbursable expense claims
+	container.ExpenseHandler = expense.NewHandler(expense.NewService(
+		expense.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		container.QBClient,
+		cfg.Expenses.MileageRate,
+	))
+	
+	// Initialize receipt and bill intake; scanned documents need an OCR
+	// service and emailed ones an inbound email domain
+	var ocrProvider intake.Provider
+	if cfg.OCR.URL != "" {
+		ocrProvider = intake.NewHTTPProvider(cfg.OCR.URL, cfg.OCR.APIKey)
+	}
+	intakeService := intake.NewService(
+		intake.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		ocrProvider,
+		container.QBClient,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 30*time.Second, 10*time.Second),
+		cfg.InboundEmail.Domain,
+	)
+	container.IntakeHandler = intake.NewHandler(intakeService)
+	container.EmailReceiver = intake.NewEmailReceiver(intakeService, cfg.InboundEmail.Secret, connStats)
+	container.ToolRegistry.Register(nlp.NewBillDraftTool(intakeService))
+	
+	// Initialize the bill payment queue
+	container.BillPayHandler = billpay.NewHandler(billpay.NewService(
+		billpay.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		container.QBClient,
+		container.JobScheduler,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 5*time.Minute, 30*time.Second),
+	))
+	
+	// Initialize exchange rates, cached per realm and date
+	container.ExchangeRateHandler = fxrate.NewHandler(fxrate.NewService(container.QBClient, fxrate.NewStore(redisClient, cfg.Redis.KeyPrefix)))
+	
+	// Initialize payroll journal import
+	payrollService := payroll.NewService(redisClient, cfg.Redis.KeyPrefix, container.QBClient)
+	container.PayrollHandler = payroll.NewHandler(payrollService)
+	
+	// Initialize Stripe payout reconciliation
+	stripeService := stripe.NewService(
+		redisClient,
+		cfg.Redis.KeyPrefix,
+		container.QBClient,
+		container.JobScheduler,
+	)
+	container.StripeHandler = stripe.NewHandler(stripeService)
+	
+	// Initialize storefront order imports
+	ordersService := orders.NewService(
+		redisClient,
+		cfg.Redis.KeyPrefix,
+		orders.NewMappingStore(redisClient, cfg.Redis.KeyPrefix),
+		container.QBClient,
+		container.JobScheduler,
+	)
+	container.OrdersHandler = orders.NewHandler(ordersService)
+	
+	// Initialize UBL/PEPPOL e-invoicing export
+	container.EInvoiceHandler = einvoice.NewHandler(einvoice.NewService(
+		container.QBClient,
+		einvoice.NewPartyStore(redisClient, cfg.Redis.KeyPrefix),
+	))
+	
+	// Initialize branded packing slips and delivery notes
+	brandingStore := branding.NewStore(redisClient, cfg.Redis.KeyPrefix)
+	container.BrandingHandler = branding.NewHandler(brandingStore)
+	container.PackingSlipHandler = packingslip.NewHandler(packingslip.NewService(container.QBClient, brandingStore))
+	
+	// Initialize payment QR codes, also embedded in branded invoices
+	paymentQRStore := payqr.NewStore(redisClient, cfg.Redis.KeyPrefix)
+	paymentQRService := payqr.NewService(container.QBClient, paymentQRStore)
+	container.PaymentQRHandler = payqr.NewHandler(paymentQRService, paymentQRStore)
+	
+	// Render branded invoices from HTML templates when a converter is configured
+	var invoiceConverter invoicepdf.Converter
+	if cfg.PDF.ConverterURL != "" {
+		invoiceConverter = pdf.NewHTMLConverter(cfg.PDF.ConverterURL)
+	}
+	container.InvoicePDFHandler = invoicepdf.NewHandler(invoicepdf.NewService(container.QBClient, brandingStore, paymentQRService, invoiceConverter))
+	
+	// Initialize OFX/QBO bank file export
+	container.BankExportHandler = bankexport.NewHandler(bankexport.NewService(container.QBClient), container.Operations, container.Meter)
+	
+	// Initialize QuickBooks Desktop list import
+	container.MigrationHandler = migration.NewHandler(migration.NewImporter(container.QBClient), container.Operations)
+	
+	// Initialize data warehouse export
+	warehouseService := warehouse.NewService(
+		redisClient,
+		cfg.Redis.KeyPrefix,
+		container.QBClient,
+		container.JobScheduler,
+		container.Meter,
+	)
+	container.WarehouseHandler = warehouse.NewHandler(warehouseService)
+	
+	// Initialize declarative tenant configuration
+	tenantConfigService := tenantconfig.NewService(
+		redisClient,
+		cfg.Redis.KeyPrefix,
+		container.Notifier,
+	)
+	container.TenantConfigHandler = tenantconfig.NewHandler(tenantConfigService)
+	container.SyncConflictHandler = syncconflict.NewHandler(syncconflict.NewService(
+		syncconflict.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		container.Versions,
+		tenantConfigService,
+		container.QBClient,
+	))
+	
+	// Move invoices through their tenant's lifecycle, following payments
+	// and sends made in QuickBooks
+	invoiceStateService := invoicestate.NewService(
+		invoiceStates,
+		tenantConfigService,
+		container.Outbox,
+		container.QBClient,
+		writelock.NewLocker(redisClient, cfg.Redis.KeyPrefix, 30*time.Second, 10*time.Second),
+	)
+	container.WebhookIngester.Register("Invoice", invoiceStateService)
+	container.WebhookIngester.Register("Payment", invoiceStateService)
+	container.InvoiceStateHandler = invoicestate.NewHandler(invoiceStateService)
+	
+	// Credit invoices to sales reps and report their commissions
+	container.CommissionHandler = commission.NewHandler(commission.NewService(
+		commission.NewStore(redisClient, cfg.Redis.KeyPrefix),
+		tenantConfigService,
+		container.QBClient,
+	))
+	
+	// Publish outbox events to the tenants' configured webhooks
+	container.OutboxRelay = outbox.NewRelay(
+		container.Outbox,
+		outbox.NewWebhookPublisher(tenantConfigService),
+		container.QBClient,
+		5*time.Second,
+	)
+	
+	// Initialize configuration bundle export/import
+	bundleService := bundle.NewService()
+	bundleService.Register("tenant_config", bundle.NewTenantConfigSection(tenantConfigService))
+	bundleService.Register("tool_policy", bundle.NewToolPolicySection(toolPolicyStore))
+	bundleService.Register("agent_schedules", bundle.NewAgentScheduleSection(container.ScheduledTaskService))
+	bundleService.Register("stripe", bundle.NewStripeSection(stripeService))
+	bundleService.Register("orders", bundle.NewOrderRulesSection(ordersService))
+	bundleService.Register("payroll", bundle.NewPayrollSection(payrollService))
+	bundleService.Register("warehouse", bundle.NewWarehouseSection(warehouseService))
+	container.BundleHandler = bundle.NewHandler(bundleService)
+	
+	// Initialize sandbox demo data seeding
+	container.SandboxHandler = sandbox.NewHandler(sandbox.NewService(
+		container.QBClient,
+		container.AuthService,
+		cfg.QuickBooks.APIBaseURL,
+	))
+	
+	// Initialize copying between connected companies
+	container.RealmCopyHandler = realmcopy.NewHandler(realmcopy.NewCopier(container.QBClient, container.AuthService), container.Operations)
+	
+	// Start background workers
+	container.JobScheduler.Start(ctx)
+	container.TranscriptStore.StartRetentionRoutine(ctx)
+	container.OutboxRelay.Start(ctx)
+	container.InvoiceWatchHub.Start(ctx)
+	container.RealtimeHub.Start(ctx)
+	
 	return container, nil
 }
 
@@ -124,7 +1022,14 @@ func NewContainer(ctx context.Context, cfg config.Config) (*Container, error) {
 func (c *Container) Shutdown() {
 	if c.RedisClient != nil {
 		if err := c.RedisClient.Close(); err != nil {
-			log.Printf("Error closing Redis connection: %v", err)
+			c.Logger.Error("failed to close Redis connection", "error", err)
+		}
+	}
+	if c.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.shutdownTracing(ctx); err != nil {
+			c.Logger.Error("failed to flush traces", "error", err)
 		}
 	}
 }
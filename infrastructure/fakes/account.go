@@ -0,0 +1,148 @@
+// infrastructure/fakes/account.go
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eGGnogSC/qbserver/internal/account"
+)
+
+// AccountService is an in-memory account.Servicer. Accounts are stored as
+// given; type and subtype validation is left to the QuickBooks-backed
+// service.
+type AccountService struct {
+	mu       sync.Mutex
+	accounts map[string]account.Account
+	nextID   int
+}
+
+// NewAccountService creates a fake account service holding accounts
+func NewAccountService(accounts ...account.Account) *AccountService {
+	s := &AccountService{accounts: make(map[string]account.Account)}
+	for _, a := range accounts {
+		s.add(a)
+	}
+	return s
+}
+
+// add stores an account, assigning it an ID and qualified name
+func (s *AccountService) add(a account.Account) account.Account {
+	s.nextID++
+	if a.ID == "" {
+		a.ID = strconv.Itoa(s.nextID)
+	}
+	a.SyncToken = "0"
+	a.FullyQualifiedName = a.Name
+	if a.ParentRef != nil && a.ParentRef.Value != "" {
+		if parent, ok := s.accounts[a.ParentRef.Value]; ok {
+			a.FullyQualifiedName = parent.FullyQualifiedName + ":" + a.Name
+		}
+		a.SubAccount = true
+	}
+	if a.Active == nil {
+		a.Active = boolPtr(true)
+	}
+	s.accounts[a.ID] = a
+	return a
+}
+
+// List returns a page of accounts by fully qualified name
+func (s *AccountService) List(ctx context.Context, opts account.ListOptions) ([]account.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToLower(strings.TrimSpace(opts.Name))
+	var accounts []account.Account
+	for _, a := range s.accounts {
+		switch {
+		case !opts.IncludeInactive && !isActive(a.Active):
+		case name != "" && !strings.Contains(strings.ToLower(a.Name), name):
+		case len(opts.Types) > 0 && !contains(opts.Types, a.AccountType):
+		case opts.Classification != "" && a.Classification != opts.Classification:
+		default:
+			accounts = append(accounts, a)
+		}
+	}
+	sortAccounts(accounts)
+	return page(accounts, opts.Offset, opts.Limit), nil
+}
+
+// DepositAccounts returns the active bank and other current asset accounts
+func (s *AccountService) DepositAccounts(ctx context.Context) ([]account.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var accounts []account.Account
+	for _, a := range s.accounts {
+		if isActive(a.Active) && (a.AccountType == account.TypeBank || a.AccountType == account.TypeOtherCurrentAsset) {
+			accounts = append(accounts, a)
+		}
+	}
+	sortAccounts(accounts)
+	return accounts, nil
+}
+
+// Get returns an account by ID
+func (s *AccountService) Get(ctx context.Context, id string) (*account.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.accounts[id]
+	if !ok {
+		return nil, account.ErrNotFound
+	}
+	return &a, nil
+}
+
+// Create creates an account with a name unique under its parent
+func (s *AccountService) Create(ctx context.Context, a *account.Account) (*account.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" || strings.ContainsAny(a.Name, ":\"") {
+		return nil, account.ErrInvalidAccount
+	}
+	if a.AccountType == "" {
+		return nil, fmt.Errorf("%w: %q", account.ErrUnknownType, a.AccountType)
+	}
+	parent := ""
+	if a.ParentRef != nil {
+		parent = a.ParentRef.Value
+	}
+	for _, existing := range s.accounts {
+		existingParent := ""
+		if existing.ParentRef != nil {
+			existingParent = existing.ParentRef.Value
+		}
+		if existing.Name == a.Name && existingParent == parent {
+			return nil, fmt.Errorf("%w: Id %s", account.ErrDuplicateName, existing.ID)
+		}
+	}
+	a.ID, a.CurrentBalance, a.MetaData = "", 0, nil
+
+	created := s.add(*a)
+	return &created, nil
+}
+
+// sortAccounts orders accounts by fully qualified name
+func sortAccounts(accounts []account.Account) {
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].FullyQualifiedName < accounts[j].FullyQualifiedName
+	})
+}
+
+// contains reports whether list includes value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+// infrastructure/fakes/search.go
+package fakes
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eGGnogSC/qbserver/internal/search"
+)
+
+// embeddingSize is the length of the fake embedder's vectors
+const embeddingSize = 64
+
+// Embedder is a search.Embedder that hashes words into a fixed-size vector,
+// so texts sharing words are close without calling an embedding API
+type Embedder struct{}
+
+// NewEmbedder creates a fake embedder
+func NewEmbedder() *Embedder {
+	return &Embedder{}
+}
+
+// Embed returns a normalized bag-of-words vector per text
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, embeddingSize)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,;:!?\"'()")))
+			vector[h.Sum32()%embeddingSize]++
+		}
+		vectors[i] = normalize(vector)
+	}
+	return vectors, nil
+}
+
+// VectorStore is an in-memory search.VectorStore
+type VectorStore struct {
+	mu   sync.RWMutex
+	docs map[string]search.Document
+}
+
+// NewVectorStore creates an empty fake vector store
+func NewVectorStore() *VectorStore {
+	return &VectorStore{docs: make(map[string]search.Document)}
+}
+
+// docKey identifies a document within the store
+func docKey(realmID, entityType, entityID string) string {
+	return realmID + ":" + entityType + ":" + entityID
+}
+
+// Upsert stores documents, replacing earlier versions
+func (s *VectorStore) Upsert(ctx context.Context, docs []search.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, doc := range docs {
+		s.docs[docKey(doc.RealmID, doc.EntityType, doc.EntityID)] = doc
+	}
+	return nil
+}
+
+// Search returns the realm's documents closest to vector
+func (s *VectorStore) Search(ctx context.Context, realmID string, vector []float32, limit int, filter search.Filter) ([]search.Result, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []search.Result
+	for _, doc := range s.docs {
+		switch {
+		case doc.RealmID != realmID:
+		case len(filter.EntityTypes) > 0 && !contains(filter.EntityTypes, doc.EntityType):
+		case filter.From != nil && (doc.TxnDate == nil || doc.TxnDate.Before(*filter.From)):
+		case filter.To != nil && (doc.TxnDate == nil || doc.TxnDate.After(*filter.To)):
+		default:
+			results = append(results, search.Result{Document: doc, Score: dot(vector, doc.Vector)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Delete removes a document
+func (s *VectorStore) Delete(ctx context.Context, realmID, entityType, entityID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.docs, docKey(realmID, entityType, entityID))
+	return nil
+}
+
+// Querier answers every QuickBooks query with no rows, for services that
+// page through QuickBooks but are exercised without it
+type Querier struct{}
+
+// Query leaves result empty
+func (Querier) Query(ctx context.Context, query string, result interface{}) error {
+	return nil
+}
+
+// normalize scales a vector to unit length
+func normalize(vector []float32) []float32 {
+	norm := math.Sqrt(dot(vector, vector))
+	if norm == 0 {
+		return vector
+	}
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// dot returns the dot product of two vectors, the cosine similarity of
+// unit vectors
+func dot(a, b []float32) float64 {
+	sum := 0.0
+	for i := range a {
+		if i < len(b) {
+			sum += float64(a[i]) * float64(b[i])
+		}
+	}
+	return sum
+}
@@ -0,0 +1,197 @@
+// infrastructure/fakes/vendor.go
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eGGnogSC/qbserver/internal/vendor"
+)
+
+// VendorService is an in-memory vendor.Servicer. It enforces the same
+// display name rules as the QuickBooks-backed service.
+type VendorService struct {
+	mu      sync.Mutex
+	vendors map[string]vendor.Vendor
+	nextID  int
+}
+
+// NewVendorService creates an empty fake vendor service
+func NewVendorService(vendors ...vendor.Vendor) *VendorService {
+	s := &VendorService{vendors: make(map[string]vendor.Vendor)}
+	for _, v := range vendors {
+		s.add(v)
+	}
+	return s
+}
+
+// add stores a vendor, assigning it an ID and making it active by default
+func (s *VendorService) add(v vendor.Vendor) vendor.Vendor {
+	s.nextID++
+	if v.ID == "" {
+		v.ID = strconv.Itoa(s.nextID)
+	}
+	v.SyncToken = "0"
+	if v.Active == nil {
+		v.Active = boolPtr(true)
+	}
+	s.vendors[v.ID] = v
+	return v
+}
+
+// List returns a page of vendors by display name
+func (s *VendorService) List(ctx context.Context, opts vendor.ListOptions) ([]vendor.Vendor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToLower(strings.TrimSpace(opts.Name))
+	var vendors []vendor.Vendor
+	for _, v := range s.vendors {
+		if !opts.IncludeInactive && !isActive(v.Active) {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(v.DisplayName), name) {
+			continue
+		}
+		vendors = append(vendors, v)
+	}
+	sort.Slice(vendors, func(i, j int) bool {
+		return vendors[i].DisplayName < vendors[j].DisplayName
+	})
+	return page(vendors, opts.Offset, opts.Limit), nil
+}
+
+// Get returns a vendor by ID
+func (s *VendorService) Get(ctx context.Context, id string) (*vendor.Vendor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.vendors[id]
+	if !ok {
+		return nil, vendor.ErrNotFound
+	}
+	return &v, nil
+}
+
+// Create creates a vendor with a unique display name
+func (s *VendorService) Create(ctx context.Context, v *vendor.Vendor) (*vendor.Vendor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v.DisplayName = strings.TrimSpace(v.DisplayName)
+	if v.DisplayName == "" {
+		return nil, vendor.ErrInvalidVendor
+	}
+	if existing := s.findByName(v.DisplayName); existing != nil {
+		return nil, fmt.Errorf("%w: Id %s", vendor.ErrDuplicateName, existing.ID)
+	}
+	v.ID, v.Balance, v.MetaData = "", 0, nil
+
+	created := s.add(*v)
+	return &created, nil
+}
+
+// Update applies a sparse update to a vendor
+func (s *VendorService) Update(ctx context.Context, id string, fields map[string]interface{}) (*vendor.Vendor, error) {
+	for name := range fields {
+		switch name {
+		case "Id", "SyncToken", "Balance", "MetaData", "sparse":
+			return nil, fmt.Errorf("%w: %s", vendor.ErrReadOnlyField, name)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name, ok := fields["DisplayName"]; ok {
+		displayName, _ := name.(string)
+		displayName = strings.TrimSpace(displayName)
+		if displayName == "" {
+			return nil, vendor.ErrInvalidVendor
+		}
+		if existing := s.findByName(displayName); existing != nil && existing.ID != id {
+			return nil, fmt.Errorf("%w: Id %s", vendor.ErrDuplicateName, existing.ID)
+		}
+		fields["DisplayName"] = displayName
+	}
+	return s.modify(id, fields)
+}
+
+// Deactivate marks a vendor inactive
+func (s *VendorService) Deactivate(ctx context.Context, id string) (*vendor.Vendor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modify(id, map[string]interface{}{"Active": false})
+}
+
+// Reactivate marks a vendor active again
+func (s *VendorService) Reactivate(ctx context.Context, id string) (*vendor.Vendor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modify(id, map[string]interface{}{"Active": true})
+}
+
+// modify merges fields into a stored vendor and bumps its sync token
+func (s *VendorService) modify(id string, fields map[string]interface{}) (*vendor.Vendor, error) {
+	v, ok := s.vendors[id]
+	if !ok {
+		return nil, vendor.ErrNotFound
+	}
+	if err := merge(&v, fields); err != nil {
+		return nil, err
+	}
+	token, _ := strconv.Atoi(v.SyncToken)
+	v.SyncToken = strconv.Itoa(token + 1)
+	s.vendors[id] = v
+	return &v, nil
+}
+
+// findByName returns the vendor with a display name, active or not
+func (s *VendorService) findByName(name string) *vendor.Vendor {
+	for _, v := range s.vendors {
+		if v.DisplayName == name {
+			return &v
+		}
+	}
+	return nil
+}
+
+// merge applies fields to v as QuickBooks applies a sparse update
+func merge(v interface{}, fields map[string]interface{}) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to apply update: %w", err)
+	}
+	return nil
+}
+
+// page returns the items from offset, at most limit of them, defaulting to
+// the services' page size
+func page[T any](items []T, offset, limit int) []T {
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 || offset >= len(items) {
+		return []T{}
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
+
+// isActive reports whether an entity is active; QuickBooks omits the flag
+// for active entities
+func isActive(active *bool) bool {
+	return active == nil || *active
+}
+
+// boolPtr returns a pointer to b
+func boolPtr(b bool) *bool {
+	return &b
+}
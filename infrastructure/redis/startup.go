@@ -0,0 +1,177 @@
+// infrastructure/redis/startup.go
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/readiness"
+	"github.com/go-redis/redis/v8"
+)
+
+// StartupMode decides what the server does when Redis is unreachable at
+// startup
+type StartupMode string
+
+const (
+	// StartupFailFast fails startup, leaving restarts to the orchestrator
+	StartupFailFast StartupMode = "fail-fast"
+	// StartupDegrade starts without Redis, keeping tokens in memory, and
+	// reconnects in the background
+	StartupDegrade StartupMode = "degrade"
+	// StartupRetry starts unready and reconnects with backoff, giving up
+	// after the startup timeout
+	StartupRetry StartupMode = "retry"
+)
+
+// DependencyName is the name Redis is reported under by readiness probes
+const DependencyName = "redis"
+
+// ErrInvalidStartupMode is returned for startup modes other than fail-fast,
+// degrade and retry
+var ErrInvalidStartupMode = errors.New("invalid Redis startup mode, expected fail-fast, degrade or retry")
+
+// ParseStartupMode parses a configured startup mode; empty is fail-fast
+func ParseStartupMode(value string) (StartupMode, error) {
+	switch mode := StartupMode(value); mode {
+	case "":
+		return StartupFailFast, nil
+	case StartupFailFast, StartupDegrade, StartupRetry:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidStartupMode, value)
+	}
+}
+
+// Startup connects to Redis at startup according to a mode, reporting its
+// progress to readiness probes
+type Startup struct {
+	Mode StartupMode
+	// Timeout is how long retry mode keeps trying before reporting Redis
+	// failed; zero keeps trying
+	Timeout     time.Duration
+	PingTimeout time.Duration
+	// InitialBackoff doubles after each failed attempt, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// WarmConnections are opened once connected, so the first requests do
+	// not pay for dialing
+	WarmConnections int
+	Tracker         *readiness.Tracker
+	// OnReady is called once Redis is connected and warmed up
+	OnReady func()
+}
+
+// withDefaults fills unset timings
+func (s Startup) withDefaults() Startup {
+	if s.PingTimeout <= 0 {
+		s.PingTimeout = 2 * time.Second
+	}
+	if s.InitialBackoff <= 0 {
+		s.InitialBackoff = 500 * time.Millisecond
+	}
+	if s.MaxBackoff <= 0 {
+		s.MaxBackoff = 30 * time.Second
+	}
+	if s.Tracker == nil {
+		s.Tracker = readiness.NewTracker()
+	}
+	return s
+}
+
+// Connect pings Redis once. In fail-fast mode a failure is returned; in
+// degrade and retry modes Connect returns at once and reconnects in the
+// background until ctx is cancelled.
+func (s Startup) Connect(ctx context.Context, client redis.UniversalClient) error {
+	s = s.withDefaults()
+	s.Tracker.Register(DependencyName, true)
+
+	err := s.ping(ctx, client)
+	if err == nil {
+		s.ready(ctx, client)
+		return nil
+	}
+
+	switch s.Mode {
+	case StartupDegrade:
+		logging.FromContext(ctx).Warn("Redis is unavailable, starting degraded with in-memory tokens", "error", err)
+		s.Tracker.Set(DependencyName, readiness.StateDegraded, err)
+		go s.reconnect(ctx, client, readiness.StateDegraded)
+		return nil
+	case StartupRetry:
+		logging.FromContext(ctx).Warn("Redis is unavailable, retrying in the background", "error", err)
+		s.Tracker.Set(DependencyName, readiness.StateStarting, err)
+		go s.reconnect(ctx, client, readiness.StateStarting)
+		return nil
+	default:
+		s.Tracker.Set(DependencyName, readiness.StateFailed, err)
+		return fmt.Errorf("redis unavailable: %w", err)
+	}
+}
+
+// ping makes one connection attempt
+func (s Startup) ping(ctx context.Context, client redis.UniversalClient) error {
+	s.Tracker.Attempt(DependencyName)
+	ctx, cancel := context.WithTimeout(ctx, s.PingTimeout)
+	defer cancel()
+	return client.Ping(ctx).Err()
+}
+
+// reconnect retries with exponential backoff, reporting state while Redis
+// is unavailable
+func (s Startup) reconnect(ctx context.Context, client redis.UniversalClient, state string) {
+	started := time.Now()
+	backoff := s.InitialBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		err := s.ping(ctx, client)
+		if err == nil {
+			logging.FromContext(ctx).Info("Redis connected", "after", time.Since(started).Round(time.Millisecond))
+			s.ready(ctx, client)
+			return
+		}
+		if s.Mode == StartupRetry && s.Timeout > 0 && time.Since(started) >= s.Timeout {
+			logging.FromContext(ctx).Error("giving up on Redis", "after", s.Timeout, "error", err)
+			s.Tracker.Set(DependencyName, readiness.StateFailed, err)
+			return
+		}
+		s.Tracker.Set(DependencyName, state, err)
+
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// ready warms the connection pool and reports Redis ready
+func (s Startup) ready(ctx context.Context, client redis.UniversalClient) {
+	if s.WarmConnections > 1 {
+		// Concurrent pings each hold a connection, so the pool grows to
+		// the number of pings
+		var wg sync.WaitGroup
+		for i := 0; i < s.WarmConnections; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				warmCtx, cancel := context.WithTimeout(ctx, s.PingTimeout)
+				defer cancel()
+				client.Ping(warmCtx)
+			}()
+		}
+		wg.Wait()
+	}
+	s.Tracker.Set(DependencyName, readiness.StateReady, nil)
+	if s.OnReady != nil {
+		s.OnReady()
+	}
+}
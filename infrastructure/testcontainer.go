@@ -0,0 +1,79 @@
+// infrastructure/testcontainer.go
+package infrastructure
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/eGGnogSC/qbserver/infrastructure/fakes"
+	"github.com/eGGnogSC/qbserver/internal/account"
+	"github.com/eGGnogSC/qbserver/internal/search"
+	"github.com/eGGnogSC/qbserver/internal/vendor"
+)
+
+// TestContainerBuilder builds a Container whose services run in memory, so
+// handlers can be exercised without QuickBooks, Redis or an embedding API.
+// Services default to the fakes package; tests replace them as needed.
+type TestContainerBuilder struct {
+	vendorService  vendor.Servicer
+	accountService account.Servicer
+	searchService  search.Servicer
+	reindexer      search.Reindexer
+}
+
+// NewTestContainerBuilder creates a builder with fake services
+func NewTestContainerBuilder() *TestContainerBuilder {
+	return &TestContainerBuilder{
+		vendorService:  fakes.NewVendorService(),
+		accountService: fakes.NewAccountService(),
+		searchService:  search.NewService(fakes.NewEmbedder(), fakes.NewVectorStore()),
+	}
+}
+
+// WithVendorService replaces the vendor service
+func (b *TestContainerBuilder) WithVendorService(service vendor.Servicer) *TestContainerBuilder {
+	b.vendorService = service
+	return b
+}
+
+// WithAccountService replaces the account service
+func (b *TestContainerBuilder) WithAccountService(service account.Servicer) *TestContainerBuilder {
+	b.accountService = service
+	return b
+}
+
+// WithSearchService replaces the search service
+func (b *TestContainerBuilder) WithSearchService(service search.Servicer) *TestContainerBuilder {
+	b.searchService = service
+	return b
+}
+
+// WithReindexer replaces the reindexer behind the search handler. By
+// default it indexes the search service from a QuickBooks with no entities.
+func (b *TestContainerBuilder) WithReindexer(reindexer search.Reindexer) *TestContainerBuilder {
+	b.reindexer = reindexer
+	return b
+}
+
+// Build creates the container and its handlers. Services the builder does
+// not cover are left nil.
+func (b *TestContainerBuilder) Build() *Container {
+	container := &Container{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	container.VendorService = b.vendorService
+	container.AccountService = b.accountService
+	container.SearchService = b.searchService
+
+	reindexer := b.reindexer
+	if reindexer == nil {
+		reindexer = search.NewIndexer(b.searchService, fakes.Querier{})
+	}
+
+	container.VendorHandler = vendor.NewHandler(container.VendorService)
+	container.AccountHandler = account.NewHandler(container.AccountService)
+	container.SearchHandler = search.NewHandler(container.SearchService, reindexer)
+
+	return container
+}
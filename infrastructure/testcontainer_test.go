@@ -0,0 +1,102 @@
+// infrastructure/testcontainer_test.go
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eGGnogSC/qbserver/infrastructure/fakes"
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/search"
+	"github.com/eGGnogSC/qbserver/internal/vendor"
+	"github.com/gorilla/mux"
+)
+
+// serve sends a request for realm-1 to a handler
+func serve(handler http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), auth.CompanyIDKey, "realm-1"))
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestContainerVendorHandler(t *testing.T) {
+	container := NewTestContainerBuilder().
+		WithVendorService(fakes.NewVendorService(vendor.Vendor{DisplayName: "Acme Supply"})).
+		Build()
+	h := container.VendorHandler
+
+	rec := serve(h.CreateVendor, http.MethodPost, "/api/vendors", `{"DisplayName":"Harbor Paper"}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body)
+	}
+	var created vendor.Vendor
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("create body: %v", err)
+	}
+	if created.ID == "" || created.DisplayName != "Harbor Paper" {
+		t.Fatalf("created = %+v", created)
+	}
+
+	rec = serve(h.CreateVendor, http.MethodPost, "/api/vendors", `{"DisplayName":" Acme Supply "}`, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate create status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = serve(h.GetVendor, http.MethodGet, "/api/vendors/"+created.ID, "", map[string]string{"id": created.ID})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(h.GetVendor, http.MethodGet, "/api/vendors/999", "", map[string]string{"id": "999"})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get missing status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = serve(h.ListVendors, http.MethodGet, "/api/vendors", "", nil)
+	var vendors []vendor.Vendor
+	if err := json.Unmarshal(rec.Body.Bytes(), &vendors); err != nil {
+		t.Fatalf("list body: %v", err)
+	}
+	if len(vendors) != 2 {
+		t.Errorf("listed %d vendors, want 2", len(vendors))
+	}
+}
+
+func TestContainerSearchHandler(t *testing.T) {
+	container := NewTestContainerBuilder().Build()
+	err := container.SearchService.Index(context.Background(), []search.Document{
+		{RealmID: "realm-1", EntityType: search.EntityCustomer, EntityID: "1", Text: "Green Acres landscaping"},
+		{RealmID: "realm-2", EntityType: search.EntityCustomer, EntityID: "2", Text: "Other company landscaping"},
+	})
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	rec := serve(container.SearchHandler.Search, http.MethodGet, "/api/search?q=landscaping", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search status = %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Results []search.Result `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("search body: %v", err)
+	}
+	if len(body.Results) != 1 || body.Results[0].EntityID != "1" {
+		t.Errorf("results = %+v, want only realm-1's customer", body.Results)
+	}
+
+	rec = serve(container.SearchHandler.Search, http.MethodGet, "/api/search", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("search without q status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
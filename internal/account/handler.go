@@ -0,0 +1,174 @@
+// account/handler.go
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Servicer is the account service the handler serves. *Service implements
+// it; tests can substitute a fake.
+type Servicer interface {
+	List(ctx context.Context, opts ListOptions) ([]Account, error)
+	DepositAccounts(ctx context.Context) ([]Account, error)
+	Get(ctx context.Context, id string) (*Account, error)
+	Create(ctx context.Context, account *Account) (*Account, error)
+}
+
+// Handler provides HTTP handlers for the chart of accounts
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new account handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDuplicateName):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidAccount), errors.Is(err, ErrUnknownType), errors.Is(err, ErrUnknownSubType),
+		errors.Is(err, ErrUnknownClassification), errors.Is(err, ErrNotDepositAccount):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// listOptions parses the list query: ?name= matches part of the name,
+// ?type= takes comma-separated account types, ?classification= one
+// classification, ?include_inactive=true adds deactivated accounts, and
+// ?limit= and ?offset= page the list
+func listOptions(r *http.Request) (ListOptions, error) {
+	query := r.URL.Query()
+	opts := ListOptions{Name: query.Get("name"), Classification: query.Get("classification"), Limit: defaultLimit}
+	for _, accountType := range strings.Split(query.Get("type"), ",") {
+		if accountType = strings.TrimSpace(accountType); accountType != "" {
+			opts.Types = append(opts.Types, accountType)
+		}
+	}
+	var err error
+	if v := query.Get("include_inactive"); v != "" {
+		if opts.IncludeInactive, err = strconv.ParseBool(v); err != nil {
+			return opts, errors.New("include_inactive must be true or false")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil || opts.Limit < 1 {
+			return opts, errors.New("limit must be a positive number")
+		}
+		if opts.Limit > maxLimit {
+			opts.Limit = maxLimit
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil || opts.Offset < 0 {
+			return opts, errors.New("offset must not be negative")
+		}
+	}
+	return opts, nil
+}
+
+// ListAccounts lists accounts by fully qualified name as a bare array
+func (h *Handler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	accounts, err := h.service.List(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list accounts: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(accounts)
+}
+
+// ListAccountPage lists accounts by fully qualified name in the pagination
+// envelope of API v2
+func (h *Handler) ListAccountPage(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	accounts, err := h.service.List(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list accounts: "+err.Error(), status(err))
+		return
+	}
+	page := Page{Data: accounts, Limit: opts.Limit, Offset: opts.Offset}
+	if len(accounts) == opts.Limit {
+		next := opts.Offset + opts.Limit
+		page.NextOffset = &next
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// ListTypes returns the account types with their classifications and
+// subtypes
+func (h *Handler) ListTypes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Types)
+}
+
+// ListDepositAccounts lists the accounts payments can be deposited to
+func (h *Handler) ListDepositAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.service.DepositAccounts(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list deposit accounts: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(accounts)
+}
+
+// GetAccount returns an account
+func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get account: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(account)
+}
+
+// CreateAccount creates an account
+func (h *Handler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	var account Account
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &account)
+	if err != nil {
+		http.Error(w, "Failed to create account: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
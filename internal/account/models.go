@@ -0,0 +1,217 @@
+// account/models.go
+package account
+
+// Ref references another QuickBooks entity
+type Ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+// MetaData holds QuickBooks record timestamps
+type MetaData struct {
+	CreateTime      string `json:"CreateTime,omitempty"`
+	LastUpdatedTime string `json:"LastUpdatedTime,omitempty"`
+}
+
+// Account is a QuickBooks chart of accounts entry, in QuickBooks' field
+// names
+type Account struct {
+	ID                 string    `json:"Id,omitempty"`
+	SyncToken          string    `json:"SyncToken,omitempty"`
+	Name               string    `json:"Name"`
+	FullyQualifiedName string    `json:"FullyQualifiedName,omitempty"`
+	AcctNum            string    `json:"AcctNum,omitempty"`
+	Description        string    `json:"Description,omitempty"`
+	Classification     string    `json:"Classification,omitempty"`
+	AccountType        string    `json:"AccountType"`
+	AccountSubType     string    `json:"AccountSubType,omitempty"`
+	SubAccount         bool      `json:"SubAccount,omitempty"`
+	ParentRef          *Ref      `json:"ParentRef,omitempty"`
+	CurrencyRef        *Ref      `json:"CurrencyRef,omitempty"`
+	CurrentBalance     float64   `json:"CurrentBalance,omitempty"`
+	Active             *bool     `json:"Active,omitempty"`
+	MetaData           *MetaData `json:"MetaData,omitempty"`
+}
+
+// Classifications, the financial statement sections account types fall in
+const (
+	ClassificationAsset     = "Asset"
+	ClassificationLiability = "Liability"
+	ClassificationEquity    = "Equity"
+	ClassificationRevenue   = "Revenue"
+	ClassificationExpense   = "Expense"
+)
+
+// Account types, as QuickBooks names them
+const (
+	TypeBank                  = "Bank"
+	TypeOtherCurrentAsset     = "Other Current Asset"
+	TypeFixedAsset            = "Fixed Asset"
+	TypeOtherAsset            = "Other Asset"
+	TypeAccountsReceivable    = "Accounts Receivable"
+	TypeEquity                = "Equity"
+	TypeExpense               = "Expense"
+	TypeOtherExpense          = "Other Expense"
+	TypeCostOfGoodsSold       = "Cost of Goods Sold"
+	TypeAccountsPayable       = "Accounts Payable"
+	TypeCreditCard            = "Credit Card"
+	TypeLongTermLiability     = "Long Term Liability"
+	TypeOtherCurrentLiability = "Other Current Liability"
+	TypeIncome                = "Income"
+	TypeOtherIncome           = "Other Income"
+)
+
+// Account subtypes the server refers to by name
+const (
+	SubTypeChecking                = "Checking"
+	SubTypeSavings                 = "Savings"
+	SubTypeUndepositedFunds        = "UndepositedFunds"
+	SubTypeAccountsReceivable      = "AccountsReceivable"
+	SubTypeAccountsPayable         = "AccountsPayable"
+	SubTypeSalesOfProductIncome    = "SalesOfProductIncome"
+	SubTypeServiceFeeIncome        = "ServiceFeeIncome"
+	SubTypeSuppliesMaterialsCogs   = "SuppliesMaterialsCogs"
+	SubTypeOtherMiscServiceCost    = "OtherMiscServiceCost"
+	SubTypeBankCharges             = "BankCharges"
+	SubTypeCommissionsAndFees      = "CommissionsAndFees"
+	SubTypeOtherBusinessExpenses   = "OtherBusinessExpenses"
+	SubTypeOpeningBalanceEquity    = "OpeningBalanceEquity"
+	SubTypeInventory               = "Inventory"
+	SubTypeCreditCard              = "CreditCard"
+	SubTypeOtherCurrentLiabilities = "OtherCurrentLiabilities"
+)
+
+// TypeInfo describes an account type: its classification, the subtypes
+// QuickBooks accepts for it and the one it uses when none is given
+type TypeInfo struct {
+	Type           string   `json:"type"`
+	Classification string   `json:"classification"`
+	DefaultSubType string   `json:"default_sub_type"`
+	SubTypes       []string `json:"sub_types"`
+}
+
+// Types lists the account types in chart of accounts order. QuickBooks
+// rejects subtypes that do not belong to an account's type.
+var Types = []TypeInfo{
+	{TypeBank, ClassificationAsset, SubTypeChecking, []string{
+		"CashOnHand", SubTypeChecking, "MoneyMarket", "RentsHeldInTrust", SubTypeSavings, "TrustAccounts",
+	}},
+	{TypeAccountsReceivable, ClassificationAsset, SubTypeAccountsReceivable, []string{
+		SubTypeAccountsReceivable,
+	}},
+	{TypeOtherCurrentAsset, ClassificationAsset, "OtherCurrentAssets", []string{
+		"AllowanceForBadDebts", "DevelopmentCosts", "EmployeeCashAdvances", SubTypeInventory,
+		"Investment_MortgageRealEstateLoans", "Investment_Other", "Investment_TaxExemptSecurities",
+		"Investment_USGovernmentObligations", "LoansToOfficers", "LoansToOthers", "LoansToStockholders",
+		"OtherCurrentAssets", "PrepaidExpenses", "Retainage", SubTypeUndepositedFunds,
+	}},
+	{TypeFixedAsset, ClassificationAsset, "OtherFixedAssets", []string{
+		"AccumulatedAmortization", "AccumulatedDepletion", "AccumulatedDepreciation", "Buildings",
+		"DepletableAssets", "FixedAssetComputers", "FixedAssetOfficeEquipment", "FurnitureAndFixtures",
+		"IntangibleAssets", "Land", "LeaseholdImprovements", "MachineryAndEquipment", "OtherFixedAssets",
+		"Vehicles",
+	}},
+	{TypeOtherAsset, ClassificationAsset, "OtherLongTermAssets", []string{
+		"AccumulatedAmortizationOfOtherAssets", "Goodwill", "LeaseBuyout", "Licenses",
+		"OrganizationalCosts", "OtherLongTermAssets", "SecurityDeposits",
+	}},
+	{TypeAccountsPayable, ClassificationLiability, SubTypeAccountsPayable, []string{
+		SubTypeAccountsPayable,
+	}},
+	{TypeCreditCard, ClassificationLiability, SubTypeCreditCard, []string{
+		SubTypeCreditCard,
+	}},
+	{TypeOtherCurrentLiability, ClassificationLiability, SubTypeOtherCurrentLiabilities, []string{
+		"DirectDepositPayable", "FederalIncomeTaxPayable", "InsurancePayable", "LineOfCredit",
+		"LoanPayable", SubTypeOtherCurrentLiabilities, "PayrollClearing", "PayrollTaxPayable",
+		"PrepaidExpensesPayable", "RentsInTrustLiability", "SalesTaxPayable", "StateLocalIncomeTaxPayable",
+		"TrustAccountsLiabilities", "UndistributedTips",
+	}},
+	{TypeLongTermLiability, ClassificationLiability, "OtherLongTermLiabilities", []string{
+		"NotesPayable", "OtherLongTermLiabilities", "ShareholderNotesPayable",
+	}},
+	{TypeEquity, ClassificationEquity, SubTypeOpeningBalanceEquity, []string{
+		"AccumulatedAdjustment", "CommonStock", "EstimatedTaxes", "Healthcare", "OpeningBalanceEquity",
+		"OwnersEquity", "PaidInCapitalOrSurplus", "PartnerContributions", "PartnerDistributions",
+		"PartnersEquity", "PersonalExpense", "PersonalIncome", "PreferredStock", "RetainedEarnings",
+		"TreasuryStock",
+	}},
+	{TypeIncome, ClassificationRevenue, SubTypeServiceFeeIncome, []string{
+		"DiscountsRefundsGiven", "NonProfitIncome", "OtherPrimaryIncome", SubTypeSalesOfProductIncome,
+		SubTypeServiceFeeIncome, "UnappliedCashPaymentIncome",
+	}},
+	{TypeOtherIncome, ClassificationRevenue, "OtherMiscellaneousIncome", []string{
+		"DividendIncome", "InterestEarned", "OtherInvestmentIncome", "OtherMiscellaneousIncome",
+		"TaxExemptInterest",
+	}},
+	{TypeCostOfGoodsSold, ClassificationExpense, SubTypeSuppliesMaterialsCogs, []string{
+		"CostOfLaborCos", "EquipmentRentalCos", "OtherCostsOfServiceCos", "ShippingFreightDeliveryCos",
+		SubTypeSuppliesMaterialsCogs,
+	}},
+	{TypeExpense, ClassificationExpense, SubTypeOtherBusinessExpenses, []string{
+		"AdvertisingPromotional", "Auto", SubTypeBankCharges, "BadDebts", "CharitableContributions",
+		SubTypeCommissionsAndFees, "CostOfLabor", "DuesSubscriptions", "EntertainmentMeals",
+		"EquipmentRental", "FinanceCosts", "Insurance", "InterestPaid", "LegalProfessionalFees",
+		"OfficeGeneralAdministrativeExpenses", SubTypeOtherBusinessExpenses, SubTypeOtherMiscServiceCost,
+		"PayrollExpenses", "PromotionalMeals", "RentOrLeaseOfBuildings", "RepairMaintenance",
+		"ShippingFreightDelivery", "SuppliesMaterials", "TaxesPaid", "Travel", "TravelMeals",
+		"UnappliedCashBillPaymentExpense", "Utilities",
+	}},
+	{TypeOtherExpense, ClassificationExpense, "OtherMiscellaneousExpense", []string{
+		"Amortization", "Depreciation", "ExchangeGainOrLoss", "GasAndFuel", "HomeOffice",
+		"HomeOwnerRentalInsurance", "MortgageInterest", "OtherHomeOfficeExpenses",
+		"OtherMiscellaneousExpense", "OtherVehicleExpenses", "ParkingAndTolls", "PenaltiesSettlements",
+		"RentAndLease", "RepairsAndMaintenance", "Utilities", "Vehicle", "VehicleInsurance",
+		"VehicleLease", "VehicleLoan", "VehicleLoanInterest", "VehicleRegistration", "VehicleRepairs",
+		"WashAndRoadServices",
+	}},
+}
+
+// typeInfo returns the description of an account type, or nil if
+// QuickBooks does not know it
+func typeInfo(accountType string) *TypeInfo {
+	for i := range Types {
+		if Types[i].Type == accountType {
+			return &Types[i]
+		}
+	}
+	return nil
+}
+
+// allowsSubType reports whether a subtype belongs to the type
+func (t *TypeInfo) allowsSubType(subType string) bool {
+	for _, s := range t.SubTypes {
+		if s == subType {
+			return true
+		}
+	}
+	return false
+}
+
+// depositTypes are the account types QuickBooks accepts in
+// DepositToAccountRef: bank accounts, and other current assets such as
+// Undeposited Funds
+var depositTypes = []interface{}{TypeBank, TypeOtherCurrentAsset}
+
+// ListOptions filters and pages account lists
+type ListOptions struct {
+	// Name matches accounts whose name contains it
+	Name string
+	// Types limits the list to these account types
+	Types []string
+	// Classification limits the list to one classification
+	Classification string
+	// IncludeInactive lists deactivated accounts too
+	IncludeInactive bool
+	Limit           int
+	Offset          int
+}
+
+// Page is a page of accounts in the v2 API's pagination envelope
+type Page struct {
+	Data   []Account `json:"data"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+	// NextOffset is set while more accounts may follow
+	NextOffset *int `json:"next_offset,omitempty"`
+}
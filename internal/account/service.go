@@ -0,0 +1,231 @@
+// account/service.go
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+var (
+	// ErrNotFound is returned for account IDs QuickBooks does not know
+	ErrNotFound = errors.New("account not found")
+	// ErrInvalidAccount is returned for accounts without a usable name
+	ErrInvalidAccount = errors.New("account requires a Name without colons or double quotes")
+	// ErrUnknownType is returned for account types QuickBooks does not know
+	ErrUnknownType = errors.New("unknown account type")
+	// ErrUnknownSubType is returned for subtypes not belonging to the
+	// account's type
+	ErrUnknownSubType = errors.New("account subtype does not belong to the account type")
+	// ErrUnknownClassification is returned for unknown classifications
+	ErrUnknownClassification = errors.New("unknown account classification")
+	// ErrDuplicateName is returned when another account under the same
+	// parent has the name, which QuickBooks requires to be unique
+	ErrDuplicateName = errors.New("an account with this Name already exists")
+	// ErrNotDepositAccount is returned for accounts payments cannot be
+	// deposited to
+	ErrNotDepositAccount = errors.New("account cannot receive deposits")
+)
+
+// defaultLimit and maxLimit bound account list pages
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// QuickBooks is the subset of the QuickBooks client used for accounts
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// Service manages the QuickBooks chart of accounts
+type Service struct {
+	qb QuickBooks
+}
+
+// NewService creates a new account service
+func NewService(qb QuickBooks) *Service {
+	return &Service{qb: qb}
+}
+
+// List returns a page of active accounts by fully qualified name, filtered
+// by name, types and classification
+func (s *Service) List(ctx context.Context, opts ListOptions) ([]Account, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	q := query.Select("Account")
+	if name := strings.TrimSpace(opts.Name); name != "" {
+		q.Where("Name", "LIKE", "%"+name+"%")
+	}
+	if len(opts.Types) > 0 {
+		types := make([]interface{}, len(opts.Types))
+		for i, accountType := range opts.Types {
+			if typeInfo(accountType) == nil {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownType, accountType)
+			}
+			types[i] = accountType
+		}
+		q.WhereIn("AccountType", types...)
+	}
+	if opts.Classification != "" {
+		if !knownClassification(opts.Classification) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownClassification, opts.Classification)
+		}
+		q.Where("Classification", "=", opts.Classification)
+	}
+	if opts.IncludeInactive {
+		q.WhereIn("Active", true, false)
+	}
+	q.OrderBy("FullyQualifiedName").Offset(opts.Offset).Limit(opts.Limit)
+
+	accounts, err := query.List[Account](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// DepositAccounts returns the active accounts invoice payments and sales
+// receipts can be deposited to, so callers pick one instead of configuring
+// its ID by hand
+func (s *Service) DepositAccounts(ctx context.Context) ([]Account, error) {
+	q := query.Select("Account").WhereIn("AccountType", depositTypes...).OrderBy("FullyQualifiedName").Limit(maxLimit)
+	accounts, err := query.List[Account](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deposit accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// DepositAccount returns an account by ID, checking payments can be
+// deposited to it
+func (s *Service) DepositAccount(ctx context.Context, id string) (*Account, error) {
+	account, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !isDepositType(account.AccountType) || (account.Active != nil && !*account.Active) {
+		return nil, fmt.Errorf("%w: %s is an inactive or %s account", ErrNotDepositAccount, account.Name, account.AccountType)
+	}
+	return account, nil
+}
+
+// Get returns an account by ID
+func (s *Service) Get(ctx context.Context, id string) (*Account, error) {
+	fields, err := s.qb.Read(ctx, "Account", id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var account Account
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to decode account: %w", err)
+	}
+	return &account, nil
+}
+
+// Create creates an account. The type must be one QuickBooks knows and the
+// subtype, defaulted from the type when empty, must belong to it. Names are
+// unique under a parent, so a taken name is rejected up front rather than
+// by QuickBooks.
+func (s *Service) Create(ctx context.Context, account *Account) (*Account, error) {
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" || strings.ContainsAny(account.Name, ":\"") {
+		return nil, ErrInvalidAccount
+	}
+	info := typeInfo(account.AccountType)
+	if info == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, account.AccountType)
+	}
+	if account.AccountSubType == "" {
+		account.AccountSubType = info.DefaultSubType
+	}
+	if !info.allowsSubType(account.AccountSubType) {
+		return nil, fmt.Errorf("%w: %s is not a %s subtype", ErrUnknownSubType, account.AccountSubType, account.AccountType)
+	}
+	account.ID, account.SyncToken, account.FullyQualifiedName, account.MetaData = "", "", "", nil
+	account.Classification, account.CurrentBalance = "", 0
+	account.SubAccount = account.ParentRef != nil && account.ParentRef.Value != ""
+
+	existing, err := s.findByName(ctx, account.Name, account.ParentRef)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w: Id %s", ErrDuplicateName, existing.ID)
+	}
+
+	var created struct {
+		Account Account `json:"Account"`
+	}
+	if err := s.qb.Create(ctx, "Account", account, &created); err != nil {
+		return nil, err
+	}
+	return &created.Account, nil
+}
+
+// findByName returns the account with a name under a parent, or at the top
+// level when parent is nil, active or not
+func (s *Service) findByName(ctx context.Context, name string, parent *Ref) (*Account, error) {
+	accounts, err := query.List[Account](ctx, s.qb, query.Select("Account").Where("Name", "=", name).WhereIn("Active", true, false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find account: %w", err)
+	}
+	for i, account := range accounts {
+		switch {
+		case parent == nil || parent.Value == "":
+			if account.ParentRef == nil {
+				return &accounts[i], nil
+			}
+		case account.ParentRef != nil && account.ParentRef.Value == parent.Value:
+			return &accounts[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// isDepositType reports whether accounts of a type can receive deposits
+func isDepositType(accountType string) bool {
+	for _, t := range depositTypes {
+		if t == accountType {
+			return true
+		}
+	}
+	return false
+}
+
+// knownClassification reports whether QuickBooks knows a classification
+func knownClassification(classification string) bool {
+	for _, t := range Types {
+		if t.Classification == classification {
+			return true
+		}
+	}
+	return false
+}
+
+// notFound maps QuickBooks' "Object Not Found" fault (code 610) to
+// ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
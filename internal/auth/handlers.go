@@ -5,8 +5,13 @@ import (
     "crypto/rand"
     "encoding/base64"
     "encoding/json"
+    "errors"
+    "html/template"
     "net/http"
+    "strconv"
     "time"
+    
+    "github.com/gorilla/mux"
 )
 
 // Handler provides HTTP handlers for auth flows
@@ -56,19 +61,16 @@ func (h *Handler) ConnectHandler(w http.ResponseWriter, r *http.Request) {
     }
     
     // Redirect to QuickBooks authorization page
-    authURL := h.service.GetAuthorizationURL(state)
+    authURL, err := h.service.GetAuthorizationURL(r.Context(), state, r.Host)
+    if err != nil {
+        http.Error(w, "Failed to build authorization URL: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
     http.Redirect(w, r, authURL, http.StatusFound)
 }
 
 // CallbackHandler handles the OAuth callback from QuickBooks
 func (h *Handler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
-    // Get user ID from session or auth
-    userID := GetUserID(r.Context())
-    if userID == "" {
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-    
     // Get query parameters
     query := r.URL.Query()
     code := query.Get("code")
@@ -80,6 +82,27 @@ func (h *Handler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
     
+    // States issued by connect links carry their own identity
+    if h.service.states != nil {
+        userID, tenantID, ok, err := h.service.states.TakeState(r.Context(), state)
+        if err != nil {
+            http.Error(w, "Failed to verify state: "+err.Error(), http.StatusInternalServerError)
+            return
+        }
+        if ok {
+            ctx := WithIdentity(r.Context(), userID, tenantID, "")
+            h.completeCallback(w, r.WithContext(ctx), code, state, realmID, userID)
+            return
+        }
+    }
+    
+    // Get user ID from session or auth
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+    
     // Verify state parameter
     session := GetSession(r)
     savedState, ok := session.Values["qb_state"].(string)
@@ -103,22 +126,29 @@ func (h *Handler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
     
+    h.completeCallback(w, r, code, state, realmID, userID)
+}
+
+// completeCallback exchanges a verified authorization code for tokens
+func (h *Handler) completeCallback(w http.ResponseWriter, r *http.Request, code, state, realmID, userID string) {
     // Exchange code for token
-    token, err := h.service.HandleCallback(r.Context(), code, state, userID)
+    token, err := h.service.HandleCallback(r.Context(), code, state, realmID, userID, r.Host)
+    if errors.Is(err, ErrUnregisteredRedirect) {
+        http.Error(w, "Invalid callback domain", http.StatusBadRequest)
+        return
+    }
+    if errors.Is(err, ErrInvalidIDToken) {
+        http.Error(w, "Failed to verify your Intuit identity: "+err.Error(), http.StatusUnauthorized)
+        return
+    }
     if err != nil {
         http.Error(w, "Failed to exchange code for token: "+err.Error(), http.StatusInternalServerError)
         return
     }
     
-    // Set realm ID from callback
-    if realmID != "" {
-        token.RealmID = realmID
-        // Save updated token
-        if err := h.service.tokenStore.SaveToken(userID, token); err != nil {
-            http.Error(w, "Failed to save token with realm ID", http.StatusInternalServerError)
-            return
-        }
-    }
+    h.service.notify(r.Context(), func(events ConnectionEvents) error {
+        return events.Connected(r.Context(), userID, token)
+    })
     
     // Return success response
     w.Header().Set("Content-Type", "application/json")
@@ -138,8 +168,8 @@ func (h *Handler) DisconnectHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    // Disconnect from QuickBooks
-    if err := h.service.Disconnect(r.Context(), userID); err != nil {
+    // Disconnect the selected or active company from QuickBooks
+    if err := h.service.Disconnect(withSelectedRealm(r), userID); err != nil {
         http.Error(w, "Failed to disconnect: "+err.Error(), http.StatusInternalServerError)
         return
     }
@@ -152,6 +182,81 @@ func (h *Handler) DisconnectHandler(w http.ResponseWriter, r *http.Request) {
     })
 }
 
+// maxMinValidity bounds how long a refreshed token may be asked to last;
+// QuickBooks access tokens last an hour
+const maxMinValidity = time.Hour
+
+// RefreshHandler refreshes the QuickBooks access token ahead of a long
+// operation. With ?min_validity=N, the token is only refreshed when it
+// expires within N seconds; otherwise it is always refreshed.
+func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+    
+    minValidity := maxMinValidity
+    if value := r.URL.Query().Get("min_validity"); value != "" {
+        seconds, err := strconv.Atoi(value)
+        if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxMinValidity {
+            http.Error(w, "min_validity must be between 0 and 3600 seconds", http.StatusBadRequest)
+            return
+        }
+        minValidity = time.Duration(seconds) * time.Second
+    }
+    ctx := withSelectedRealm(r)
+    if _, err := h.service.getToken(ctx, userID); err != nil {
+        http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+        return
+    }
+    
+    token, refreshed, err := h.service.EnsureValidFor(ctx, userID, minValidity)
+    if errors.Is(err, ErrInvalidGrant) {
+        http.Error(w, "QuickBooks connection was revoked; reconnect to continue", http.StatusUnauthorized)
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to refresh token: "+err.Error(), http.StatusBadGateway)
+        return
+    }
+    
+    SetTokenExpiry(w, token)
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "realm_id":   token.RealmID,
+        "expires_at": token.ExpiresAt,
+        "expires_in": int64(time.Until(token.ExpiresAt).Seconds()),
+        "refreshed":  refreshed,
+    })
+}
+
+// disconnectedPage is shown to users who disconnected the app from within
+// QuickBooks
+var disconnectedPage = template.Must(template.New("disconnected").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Disconnected from QuickBooks</title>
+</head>
+<body>
+<h1>Your QuickBooks company has been disconnected</h1>
+<p>This app no longer has access to {{if .RealmID}}QuickBooks company {{.RealmID}}{{else}}your QuickBooks company{{end}}. Data already synced is kept until you delete it.</p>
+<p><a href="/auth/connect">Reconnect to QuickBooks</a></p>
+</body>
+</html>
+`))
+
+// DisconnectedHandler renders the disconnect landing page Intuit sends users
+// to after they disconnect the app from within QuickBooks. Their tokens
+// are forgotten when QuickBooks next rejects them.
+func (h *Handler) DisconnectedHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(http.StatusOK)
+    disconnectedPage.Execute(w, struct{ RealmID string }{r.URL.Query().Get("realmId")})
+}
+
 // StatusHandler returns the connection status
 func (h *Handler) StatusHandler(w http.ResponseWriter, r *http.Request) {
     // Get user ID from session or auth
@@ -161,8 +266,8 @@ func (h *Handler) StatusHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    // Check if user has a token
-    token, err := h.service.tokenStore.GetToken(userID)
+    // Check if user has a token for the selected or active company
+    token, err := h.service.getToken(withSelectedRealm(r), userID)
     if err != nil {
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusOK)
@@ -172,12 +277,71 @@ func (h *Handler) StatusHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
     
+    // Scopes the tenant now requests that the connection lacks
+    missing, err := h.service.MissingScopes(r.Context(), token)
+    if err != nil {
+        http.Error(w, "Failed to check scopes: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    
     // Return connection status
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]interface{}{
+    status := map[string]interface{}{
         "connected": true,
         "realm_id":  token.RealmID,
         "expires_at": token.ExpiresAt,
+        "scopes":    h.service.GrantedScopes(token),
+    }
+    if len(missing) > 0 {
+        status["missing_scopes"] = missing
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(status)
+}
+
+// CompaniesHandler lists the QuickBooks companies the user connected
+func (h *Handler) CompaniesHandler(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+    
+    companies, err := h.service.Companies(r.Context(), userID)
+    if err != nil {
+        http.Error(w, "Failed to list companies: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "companies": companies,
+    })
+}
+
+// ActivateCompanyHandler makes a connected company the one the user's
+// requests go to when they do not send the X-QB-Realm-ID header
+func (h *Handler) ActivateCompanyHandler(w http.ResponseWriter, r *http.Request) {
+    userID := GetUserID(r.Context())
+    if userID == "" {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+    
+    realmID := mux.Vars(r)["realmId"]
+    err := h.service.ActivateCompany(r.Context(), userID, realmID)
+    if errors.Is(err, ErrCompanyNotConnected) {
+        http.Error(w, "QuickBooks company "+realmID+" not connected", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to activate company: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(map[string]string{
+        "status":   "success",
+        "realm_id": realmID,
     })
 }
@@ -3,10 +3,33 @@ package auth
 
 import (
     "context"
+    "crypto/subtle"
     "errors"
     "net/http"
+    "strconv"
+    "time"
+    
+    "github.com/eGGnogSC/qbserver/internal/logging"
 )
 
+// TokenExpiresInHeader tells clients how many seconds the QuickBooks access
+// token serving the request remains valid, so long operations can refresh
+// it first
+const TokenExpiresInHeader = "X-QB-Token-Expires-In"
+
+// RealmHeader selects which of the user's connected companies a request
+// goes to; without it requests go to the active company
+const RealmHeader = "X-QB-Realm-ID"
+
+// SetTokenExpiry sets the token expiry header for token
+func SetTokenExpiry(w http.ResponseWriter, token *OAuthToken) {
+    seconds := int64(time.Until(token.ExpiresAt).Seconds())
+    if seconds < 0 {
+        seconds = 0
+    }
+    w.Header().Set(TokenExpiresInHeader, strconv.FormatInt(seconds, 10))
+}
+
 // contextKey is a custom type for context keys
 type contextKey string
 
@@ -15,8 +38,17 @@ const (
     UserIDKey   contextKey = "user_id"
     TokenKey    contextKey = "token"
     CompanyIDKey contextKey = "company_id"
+    TenantIDKey contextKey = "tenant_id"
+    RoleKey     contextKey = "role"
 )
 
+// UserRole may read and change a tenant's data
+const UserRole = "user"
+
+// DefaultRole is the least privileged role, held by callers no credential
+// or membership grants a role
+const DefaultRole = "viewer"
+
 // GetUserID extracts user ID from context
 func GetUserID(ctx context.Context) string {
     userID, _ := ctx.Value(UserIDKey).(string)
@@ -38,10 +70,66 @@ func GetCompanyID(ctx context.Context) (string, error) {
     return companyID, nil
 }
 
-// UserMiddleware sets user ID in the request context
+// ErrNoTenant is returned when neither an API key nor a membership placed
+// the caller in a tenant
+var ErrNoTenant = errors.New("tenant not found in context")
+
+// GetTenantID extracts tenant ID from context, falling back to the user ID
+func GetTenantID(ctx context.Context) string {
+    if tenantID, ok := ctx.Value(TenantIDKey).(string); ok && tenantID != "" {
+        return tenantID
+    }
+    return GetUserID(ctx)
+}
+
+// GetVerifiedTenantID extracts the tenant an API key or membership placed
+// the caller in, without falling back to the user ID. Anything billed or
+// limited per tenant uses it.
+func GetVerifiedTenantID(ctx context.Context) (string, error) {
+    tenantID, ok := ctx.Value(TenantIDKey).(string)
+    if !ok || tenantID == "" {
+        return "", ErrNoTenant
+    }
+    return tenantID, nil
+}
+
+// GetRole extracts the user's role from context
+func GetRole(ctx context.Context) string {
+    if role, ok := ctx.Value(RoleKey).(string); ok && role != "" {
+        return role
+    }
+    return DefaultRole
+}
+
+// WithIdentity returns a context carrying a user's identity and company,
+// for background work that runs outside an HTTP request
+func WithIdentity(ctx context.Context, userID, tenantID, realmID string) context.Context {
+    ctx = context.WithValue(ctx, UserIDKey, userID)
+    if tenantID != "" {
+        ctx = context.WithValue(ctx, TenantIDKey, tenantID)
+    }
+    if realmID != "" {
+        ctx = context.WithValue(ctx, CompanyIDKey, realmID)
+    }
+    args := []any{"user_id", userID, "tenant_id", GetTenantID(ctx)}
+    if realmID != "" {
+        args = append(args, "realm_id", realmID)
+    }
+    return logging.With(ctx, args...)
+}
+
+// UserMiddleware sets user ID in the request context. Tenants and roles are
+// never taken from the request as is; they come from an API key or a
+// verified tenant membership.
 // Replace this with your actual user authentication logic
 func UserMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // Requests already authenticated upstream, e.g. by API key
+        if GetUserID(r.Context()) != "" {
+            next.ServeHTTP(w, r.WithContext(withUserLogger(r.Context())))
+            return
+        }
+        
         // Example: Get user ID from Authorization header or session
         // In a real app, you'd validate JWT, session token, etc.
         userID := r.Header.Get("X-User-ID")
@@ -52,10 +140,57 @@ func UserMiddleware(next http.Handler) http.Handler {
         
         // Set user ID in context
         ctx := context.WithValue(r.Context(), UserIDKey, userID)
-        next.ServeHTTP(w, r.WithContext(ctx))
+        
+        next.ServeHTTP(w, r.WithContext(withUserLogger(ctx)))
     })
 }
 
+// withSelectedRealm returns the request's context with the company
+// selected by RealmHeader, if any
+func withSelectedRealm(r *http.Request) context.Context {
+    if realmID := r.Header.Get(RealmHeader); realmID != "" {
+        return context.WithValue(r.Context(), CompanyIDKey, realmID)
+    }
+    return r.Context()
+}
+
+// withUserLogger returns a context whose logger identifies the user
+func withUserLogger(ctx context.Context) context.Context {
+    return logging.With(ctx, "user_id", GetUserID(ctx), "tenant_id", GetTenantID(ctx))
+}
+
+// AdminMiddleware restricts access to operators holding the admin API key
+func AdminMiddleware(apiKey string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            provided := r.Header.Get("X-Admin-Key")
+            if apiKey == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) != 1 {
+                http.Error(w, "Forbidden", http.StatusForbidden)
+                return
+            }
+            
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// RequireScope ensures the QuickBooks connection in the request context was
+// granted scope, so modules are only exposed to connections that may use
+// them. It runs after QBAuthMiddleware.
+func (s *Service) RequireScope(scope string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            token := GetToken(r.Context())
+            if token == nil || !HasScope(s.GrantedScopes(token), scope) {
+                http.Error(w, "QuickBooks connection was not granted the "+scope+" scope; reconnect to grant it", http.StatusForbidden)
+                return
+            }
+            
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
 // QBAuthMiddleware ensures the request has a valid QuickBooks token
 func QBAuthMiddleware(service *Service) func(http.Handler) http.Handler {
     return func(next http.Handler) http.Handler {
@@ -67,8 +202,13 @@ func QBAuthMiddleware(service *Service) func(http.Handler) http.Handler {
                 return
             }
             
-            // Get and validate token
-            token, err := service.GetValidToken(r.Context(), userID)
+            // Get and validate the token of the selected or active company
+            selected := r.Header.Get(RealmHeader)
+            token, err := service.GetValidToken(withSelectedRealm(r), userID)
+            if err != nil && selected != "" {
+                http.Error(w, "QuickBooks company "+selected+" not connected", http.StatusUnauthorized)
+                return
+            }
             if err != nil {
                 http.Error(w, "QuickBooks authentication required", http.StatusUnauthorized)
                 return
@@ -80,9 +220,12 @@ func QBAuthMiddleware(service *Service) func(http.Handler) http.Handler {
                 return
             }
             
+            SetTokenExpiry(w, token)
+            
             // Set token and company ID in context
             ctx := context.WithValue(r.Context(), TokenKey, token)
             ctx = context.WithValue(ctx, CompanyIDKey, token.RealmID)
+            ctx = logging.With(ctx, "realm_id", token.RealmID)
             
             next.ServeHTTP(w, r.WithContext(ctx))
         })
@@ -2,6 +2,7 @@
 package auth
 
 import (
+    "context"
     "time"
 )
 
@@ -13,13 +14,79 @@ type OAuthToken struct {
     ExpiresIn    int       `json:"expires_in"`
     ExpiresAt    time.Time `json:"expires_at"`
     RealmID      string    `json:"realm_id"` // Company ID in QuickBooks
+    IDToken      string    `json:"id_token,omitempty"` // OpenID Connect identity, when requested
+    // Scopes are those the user granted; empty for tokens saved before
+    // scopes were recorded, which were granted the default scopes
+    Scopes []string `json:"scopes,omitempty"`
 }
 
-// TokenStore interface for different token storage implementations
+// QuickBooks scopes
+const (
+    ScopeAccounting = "com.intuit.quickbooks.accounting"
+    ScopePayments   = "com.intuit.quickbooks.payment"
+    ScopeOpenID     = "openid"
+    ScopeProfile    = "profile"
+    ScopeEmail      = "email"
+    ScopePhone      = "phone"
+    ScopeAddress    = "address"
+)
+
+// KnownScopes are the scopes a tenant may request
+var KnownScopes = []string{ScopeAccounting, ScopePayments, ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone, ScopeAddress}
+
+// HasScope reports whether scopes include scope
+func HasScope(scopes []string, scope string) bool {
+    for _, s := range scopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// OpenIDScopes are the scopes requesting the connecting user's identity
+var OpenIDScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// IDClaims are the verified claims of an OpenID Connect ID token
+type IDClaims struct {
+    Issuer        string    `json:"iss"`
+    Subject       string    `json:"sub"`
+    Email         string    `json:"email,omitempty"`
+    EmailVerified bool      `json:"email_verified,omitempty"`
+    RealmID       string    `json:"realmid,omitempty"`
+    ExpiresAt     time.Time `json:"-"`
+}
+
+// IDTokenVerifier verifies the signature, issuer, audience and expiry of
+// ID tokens
+type IDTokenVerifier interface {
+    VerifyIDToken(ctx context.Context, idToken, clientID string) (*IDClaims, error)
+}
+
+// TokenStore interface for different token storage implementations. A
+// user may connect several companies, each with its own token; one of them
+// is active and serves requests that do not select a company.
 type TokenStore interface {
+    // SaveToken stores the token for the company in token.RealmID
     SaveToken(userID string, token *OAuthToken) error
-    GetToken(userID string) (*OAuthToken, error)
-    DeleteToken(userID string) error
+    // GetToken returns the token for a company, the active one when
+    // realmID is empty
+    GetToken(userID, realmID string) (*OAuthToken, error)
+    // ListTokens returns the tokens of every company the user connected
+    ListTokens(userID string) ([]*OAuthToken, error)
+    // DeleteToken removes the token for a company
+    DeleteToken(userID, realmID string) error
+    // ActiveRealm and SetActiveRealm get and set the active company
+    ActiveRealm(userID string) (string, error)
+    SetActiveRealm(userID, realmID string) error
+}
+
+// Company is a QuickBooks company a user connected
+type Company struct {
+    RealmID   string    `json:"realm_id"`
+    Active    bool      `json:"active"`
+    ExpiresAt time.Time `json:"expires_at"`
+    Scopes    []string  `json:"scopes"`
 }
 
 // OAuthConfig holds OAuth 2.0 configuration
@@ -27,8 +94,62 @@ type OAuthConfig struct {
     ClientID     string
     ClientSecret string
     RedirectURI  string
+    // RedirectURIs are further redirect URIs registered with the app, such
+    // as those of white-label domains
+    RedirectURIs []string
     Scopes       []string
     AuthURL      string
     TokenURL     string
     APIBaseURL   string
 }
+
+// AppCredentials identify the Intuit app a tenant connects through
+type AppCredentials struct {
+    ClientID     string `json:"client_id"`
+    ClientSecret string `json:"client_secret,omitempty"`
+    RedirectURI  string `json:"redirect_uri,omitempty"` // defaults to the shared app's
+    // RedirectURIs are further redirect URIs registered with the app
+    RedirectURIs []string `json:"redirect_uris,omitempty"`
+}
+
+// CredentialSource looks up the Intuit app credentials of tenants that
+// bring their own app. It returns nil for tenants using the shared app.
+type CredentialSource interface {
+    AppCredentials(ctx context.Context, tenantID string) (*AppCredentials, error)
+}
+
+// Reasons a connection was lost
+const (
+    // LostDisconnected is a user disconnecting the company
+    LostDisconnected = "disconnected"
+    // LostRevoked is QuickBooks rejecting the refresh token, e.g. because it
+    // expired or the app was disconnected from within QuickBooks
+    LostRevoked = "revoked"
+)
+
+// ConnectionEvents is told of QuickBooks connections being made, refreshed
+// and lost, e.g. to keep analytics of integration health. Its failures
+// never fail the operation it is told of.
+type ConnectionEvents interface {
+    // Connected is told of a company connected by a user
+    Connected(ctx context.Context, userID string, token *OAuthToken) error
+    // Refreshed is told of a refresh of the token, and its error if it
+    // failed
+    Refreshed(ctx context.Context, userID string, token *OAuthToken, err error) error
+    // Disconnected is told of a company's connection being lost
+    Disconnected(ctx context.Context, userID, realmID, reason string) error
+}
+
+// ScopeSource looks up the scopes a tenant requests. It returns nil for
+// tenants using the default scopes.
+type ScopeSource interface {
+    TenantScopes(ctx context.Context, tenantID string) ([]string, error)
+}
+
+// ConnectionStates resolves OAuth states issued outside a browser session,
+// such as those of connect links handed out when onboarding a tenant
+type ConnectionStates interface {
+    // TakeState returns the user and tenant a state was issued for and
+    // invalidates it; ok is false for states it did not issue
+    TakeState(ctx context.Context, state string) (userID, tenantID string, ok bool, err error)
+}
@@ -4,18 +4,46 @@ package auth
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "io/ioutil"
     "net/http"
     "net/url"
     "strings"
     "time"
+    
+    "github.com/eGGnogSC/qbserver/internal/logging"
+    "github.com/eGGnogSC/qbserver/internal/tracing"
+    "go.opentelemetry.io/otel/trace"
 )
 
+// ErrUnregisteredRedirect is returned for callbacks received on a domain
+// that is not one of the app's registered redirect URIs
+var ErrUnregisteredRedirect = errors.New("callback domain is not a registered redirect URI")
+
+// ErrInvalidGrant is returned when QuickBooks rejects a refresh token or
+// authorization code as invalid, expired or revoked
+var ErrInvalidGrant = errors.New("invalid grant")
+
+// ErrCompanyNotConnected is returned for companies the user has not
+// connected
+var ErrCompanyNotConnected = errors.New("QuickBooks company not connected")
+
+// ErrInvalidIDToken is returned in compliance mode for callbacks whose
+// OpenID Connect ID token is missing or fails verification
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
 // Service handles OAuth 2.0 operations
 type Service struct {
-    config     OAuthConfig
-    tokenStore TokenStore
+    config      OAuthConfig
+    tokenStore  TokenStore
+    credentials CredentialSource
+    scopeSource ScopeSource
+    states      ConnectionStates
+    events      ConnectionEvents
+    // compliance enables the behaviors Intuit requires of App Store apps
+    compliance bool
+    idTokens   IDTokenVerifier
 }
 
 // NewService creates a new auth service
@@ -26,31 +54,192 @@ func NewService(config OAuthConfig, tokenStore TokenStore) *Service {
     }
 }
 
-// GetAuthorizationURL generates the QuickBooks authorization URL
-func (s *Service) GetAuthorizationURL(state string) string {
-    u, _ := url.Parse(s.config.AuthURL)
+// WithCredentialSource lets tenants connect through their own Intuit apps
+func (s *Service) WithCredentialSource(source CredentialSource) *Service {
+    service := *s
+    service.credentials = source
+    return &service
+}
+
+// WithScopeSource lets tenants choose the scopes they request
+func (s *Service) WithScopeSource(source ScopeSource) *Service {
+    service := *s
+    service.scopeSource = source
+    return &service
+}
+
+// WithConnectionStates accepts callbacks for states issued by states
+func (s *Service) WithConnectionStates(states ConnectionStates) *Service {
+    service := *s
+    service.states = states
+    return &service
+}
+
+// WithConnectionEvents tells events of connections made, refreshed and lost
+func (s *Service) WithConnectionEvents(events ConnectionEvents) *Service {
+    service := *s
+    service.events = events
+    return &service
+}
+
+// WithCompliance enables the behaviors Intuit requires of apps published on
+// its App Store: the user's identity is requested with OpenID Connect and
+// its ID token verified, and tokens QuickBooks revoked are forgotten so the
+// user is asked to reconnect
+func (s *Service) WithCompliance(idTokens IDTokenVerifier) *Service {
+    service := *s
+    service.compliance = true
+    service.idTokens = idTokens
+    return &service
+}
+
+// scopes returns the scopes to request, adding the OpenID scopes in
+// compliance mode
+func (s *Service) scopes(config OAuthConfig) []string {
+    scopes := append([]string(nil), config.Scopes...)
+    if !s.compliance {
+        return scopes
+    }
+    for _, scope := range OpenIDScopes {
+        if !HasScope(scopes, scope) {
+            scopes = append(scopes, scope)
+        }
+    }
+    return scopes
+}
+
+// notify tells the connection events, if any, of an event. Failures are
+// only logged.
+func (s *Service) notify(ctx context.Context, fn func(events ConnectionEvents) error) {
+    if s.events == nil {
+        return
+    }
+    if err := fn(s.events); err != nil {
+        logging.FromContext(ctx).Warn("failed to record connection event", "error", err)
+    }
+}
+
+// getToken reads a user's token for the company in ctx, or the active
+// company, from the token store, in a span
+func (s *Service) getToken(ctx context.Context, userID string) (*OAuthToken, error) {
+    realmID, _ := GetCompanyID(ctx)
+    _, span := tracing.Start(ctx, "auth.token_store.get")
+    token, err := s.tokenStore.GetToken(userID, realmID)
+    tracing.End(span, err)
+    return token, err
+}
+
+// saveToken writes a user's token to the token store, in a span
+func (s *Service) saveToken(ctx context.Context, userID string, token *OAuthToken) error {
+    _, span := tracing.Start(ctx, "auth.token_store.save")
+    err := s.tokenStore.SaveToken(userID, token)
+    tracing.End(span, err)
+    return err
+}
+
+// deleteToken removes a user's token for a company from the token store,
+// in a span
+func (s *Service) deleteToken(ctx context.Context, userID, realmID string) error {
+    _, span := tracing.Start(ctx, "auth.token_store.delete")
+    err := s.tokenStore.DeleteToken(userID, realmID)
+    tracing.End(span, err)
+    return err
+}
+
+// oauthConfig returns the OAuth settings for the tenant in ctx
+func (s *Service) oauthConfig(ctx context.Context) (OAuthConfig, error) {
+    config := s.config
+    if s.scopeSource != nil {
+        scopes, err := s.scopeSource.TenantScopes(ctx, GetTenantID(ctx))
+        if err != nil {
+            return config, fmt.Errorf("failed to get tenant scopes: %w", err)
+        }
+        if len(scopes) > 0 {
+            config.Scopes = scopes
+        }
+    }
+    if s.credentials == nil {
+        return config, nil
+    }
+    
+    creds, err := s.credentials.AppCredentials(ctx, GetTenantID(ctx))
+    if err != nil {
+        return config, fmt.Errorf("failed to get app credentials: %w", err)
+    }
+    if creds != nil {
+        config.ClientID = creds.ClientID
+        config.ClientSecret = creds.ClientSecret
+        if creds.RedirectURI != "" {
+            config.RedirectURI = creds.RedirectURI
+            config.RedirectURIs = creds.RedirectURIs
+        }
+    }
+    return config, nil
+}
+
+// redirectURI returns the registered redirect URI on host. With a single
+// registered URI, or no host, the default redirect URI is always used.
+func (c OAuthConfig) redirectURI(host string) (string, bool) {
+    if host == "" || len(c.RedirectURIs) == 0 {
+        return c.RedirectURI, true
+    }
+    
+    for _, uri := range append([]string{c.RedirectURI}, c.RedirectURIs...) {
+        u, err := url.Parse(uri)
+        if err == nil && strings.EqualFold(u.Host, host) {
+            return uri, true
+        }
+    }
+    return c.RedirectURI, false
+}
+
+// GetAuthorizationURL generates the QuickBooks authorization URL for the
+// tenant in ctx, redirecting back to the registered URI on host when there
+// is one
+func (s *Service) GetAuthorizationURL(ctx context.Context, state, host string) (string, error) {
+    config, err := s.oauthConfig(ctx)
+    if err != nil {
+        return "", err
+    }
+    redirectURI, _ := config.redirectURI(host)
+    
+    u, err := url.Parse(config.AuthURL)
+    if err != nil {
+        return "", fmt.Errorf("invalid authorization URL: %w", err)
+    }
     q := u.Query()
     
-    q.Set("client_id", s.config.ClientID)
+    q.Set("client_id", config.ClientID)
     q.Set("response_type", "code")
-    q.Set("scope", strings.Join(s.config.Scopes, " "))
-    q.Set("redirect_uri", s.config.RedirectURI)
+    q.Set("scope", strings.Join(s.scopes(config), " "))
+    q.Set("redirect_uri", redirectURI)
     q.Set("state", state)
     
     u.RawQuery = q.Encode()
-    return u.String()
+    return u.String(), nil
 }
 
-// HandleCallback processes the OAuth callback and exchanges the code for tokens
-func (s *Service) HandleCallback(ctx context.Context, code, state, userID string) (*OAuthToken, error) {
+// HandleCallback processes the OAuth callback received on host and
+// exchanges the code for tokens for the company realmID. The company is
+// added to the user's connections and becomes the active one.
+func (s *Service) HandleCallback(ctx context.Context, code, state, realmID, userID, host string) (*OAuthToken, error) {
+    config, err := s.oauthConfig(ctx)
+    if err != nil {
+        return nil, err
+    }
+    redirectURI, ok := config.redirectURI(host)
+    if !ok {
+        return nil, ErrUnregisteredRedirect
+    }
+    
     // Prepare token exchange request
     data := url.Values{}
     data.Set("grant_type", "authorization_code")
     data.Set("code", code)
-    data.Set("redirect_uri", s.config.RedirectURI)
+    data.Set("redirect_uri", redirectURI)
     
     // Execute token exchange
-    token, err := s.executeTokenRequest(ctx, data)
+    token, err := s.executeTokenRequest(ctx, config, data)
     if err != nil {
         return nil, err
     }
@@ -58,10 +247,34 @@ func (s *Service) HandleCallback(ctx context.Context, code, state, userID string
     // Set expiry time
     token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
     
+    // The token response does not name the company; the callback does
+    if realmID != "" {
+        token.RealmID = realmID
+    }
+    if token.RealmID == "" {
+        return nil, fmt.Errorf("no company was selected")
+    }
+    
+    // The user grants every scope requested or none
+    token.Scopes = s.scopes(config)
+    
+    // Verify the connecting user's identity
+    if s.compliance && s.idTokens != nil {
+        if token.IDToken == "" {
+            return nil, fmt.Errorf("%w: no ID token was returned", ErrInvalidIDToken)
+        }
+        if _, err := s.idTokens.VerifyIDToken(ctx, token.IDToken, config.ClientID); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+        }
+    }
+    
     // Save token
-    if err := s.tokenStore.SaveToken(userID, token); err != nil {
+    if err := s.saveToken(ctx, userID, token); err != nil {
         return nil, fmt.Errorf("failed to save token: %w", err)
     }
+    if err := s.tokenStore.SetActiveRealm(userID, token.RealmID); err != nil {
+        return nil, err
+    }
     
     return token, nil
 }
@@ -69,25 +282,44 @@ func (s *Service) HandleCallback(ctx context.Context, code, state, userID string
 // RefreshToken refreshes an expired access token
 func (s *Service) RefreshToken(ctx context.Context, userID string) (*OAuthToken, error) {
     // Get current token
-    token, err := s.tokenStore.GetToken(userID)
+    token, err := s.getToken(ctx, userID)
     if err != nil {
         return nil, fmt.Errorf("failed to get token for refresh: %w", err)
     }
     
+    config, err := s.oauthConfig(ctx)
+    if err != nil {
+        return nil, err
+    }
+    
     // Prepare refresh request
     data := url.Values{}
     data.Set("grant_type", "refresh_token")
     data.Set("refresh_token", token.RefreshToken)
     
     // Execute refresh
-    newToken, err := s.executeTokenRequest(ctx, data)
+    newToken, err := s.executeTokenRequest(ctx, config, data)
     if err != nil {
+        s.notify(ctx, func(events ConnectionEvents) error {
+            return events.Refreshed(ctx, userID, token, err)
+        })
+        if errors.Is(err, ErrInvalidGrant) {
+            if s.compliance {
+                if err := s.deleteToken(ctx, userID, token.RealmID); err != nil {
+                    logging.FromContext(ctx).Warn("failed to delete revoked token", "error", err)
+                }
+            }
+            s.notify(ctx, func(events ConnectionEvents) error {
+                return events.Disconnected(ctx, userID, token.RealmID, LostRevoked)
+            })
+        }
         return nil, err
     }
     
     // Update token fields
     newToken.ExpiresAt = time.Now().Add(time.Duration(newToken.ExpiresIn) * time.Second)
     newToken.RealmID = token.RealmID // Preserve realm ID
+    newToken.Scopes = token.Scopes
     
     // If the refresh token was not returned, reuse the existing one
     if newToken.RefreshToken == "" {
@@ -95,23 +327,35 @@ func (s *Service) RefreshToken(ctx context.Context, userID string) (*OAuthToken,
     }
     
     // Save updated token
-    if err := s.tokenStore.SaveToken(userID, newToken); err != nil {
+    if err := s.saveToken(ctx, userID, newToken); err != nil {
         return nil, fmt.Errorf("failed to save refreshed token: %w", err)
     }
+    s.notify(ctx, func(events ConnectionEvents) error {
+        return events.Refreshed(ctx, userID, newToken, nil)
+    })
     
     return newToken, nil
 }
 
-// executeTokenRequest performs the actual token request to QuickBooks
-func (s *Service) executeTokenRequest(ctx context.Context, data url.Values) (*OAuthToken, error) {
-    req, err := http.NewRequestWithContext(ctx, "POST", s.config.TokenURL, strings.NewReader(data.Encode()))
+// executeTokenRequest performs the actual token request to QuickBooks, in a
+// client span
+func (s *Service) executeTokenRequest(ctx context.Context, config OAuthConfig, data url.Values) (*OAuthToken, error) {
+    ctx, span := tracing.Start(ctx, "QuickBooks OAuth "+data.Get("grant_type"), trace.WithSpanKind(trace.SpanKindClient))
+    token, err := s.requestToken(ctx, config, data)
+    tracing.End(span, err)
+    return token, err
+}
+
+// requestToken makes a token request
+func (s *Service) requestToken(ctx context.Context, config OAuthConfig, data url.Values) (*OAuthToken, error) {
+    req, err := http.NewRequestWithContext(ctx, "POST", config.TokenURL, strings.NewReader(data.Encode()))
     if err != nil {
         return nil, fmt.Errorf("failed to create token request: %w", err)
     }
     
     req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
     req.Header.Add("Accept", "application/json")
-    req.SetBasicAuth(s.config.ClientID, s.config.ClientSecret)
+    req.SetBasicAuth(config.ClientID, config.ClientSecret)
     
     client := &http.Client{Timeout: 10 * time.Second}
     resp, err := client.Do(req)
@@ -122,6 +366,9 @@ func (s *Service) executeTokenRequest(ctx context.Context, data url.Values) (*OA
     
     if resp.StatusCode != http.StatusOK {
         body, _ := ioutil.ReadAll(resp.Body)
+        if resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "invalid_grant") {
+            return nil, fmt.Errorf("token request failed with status %d: %s: %w", resp.StatusCode, body, ErrInvalidGrant)
+        }
         return nil, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, body)
     }
     
@@ -138,28 +385,62 @@ func (s *Service) executeTokenRequest(ctx context.Context, data url.Values) (*OA
     return &token, nil
 }
 
+// GrantedScopes returns the scopes a token was granted
+func (s *Service) GrantedScopes(token *OAuthToken) []string {
+    if len(token.Scopes) > 0 {
+        return token.Scopes
+    }
+    return s.config.Scopes
+}
+
+// MissingScopes returns the scopes the tenant in ctx now requests that a
+// token was not granted; the user must reconnect to grant them
+func (s *Service) MissingScopes(ctx context.Context, token *OAuthToken) ([]string, error) {
+    config, err := s.oauthConfig(ctx)
+    if err != nil {
+        return nil, err
+    }
+    granted := s.GrantedScopes(token)
+    var missing []string
+    for _, scope := range s.scopes(config) {
+        if !HasScope(granted, scope) {
+            missing = append(missing, scope)
+        }
+    }
+    return missing, nil
+}
+
 // GetValidToken returns a valid token, refreshing it if necessary
 func (s *Service) GetValidToken(ctx context.Context, userID string) (*OAuthToken, error) {
-    token, err := s.tokenStore.GetToken(userID)
+    // Refresh tokens that are expired or about to expire (within 5 minutes)
+    token, _, err := s.EnsureValidFor(ctx, userID, 5*time.Minute)
+    return token, err
+}
+
+// EnsureValidFor returns a token valid for at least d, refreshing it if it
+// expires sooner, and whether it was refreshed
+func (s *Service) EnsureValidFor(ctx context.Context, userID string, d time.Duration) (*OAuthToken, bool, error) {
+    token, err := s.getToken(ctx, userID)
     if err != nil {
-        return nil, fmt.Errorf("failed to get token: %w", err)
+        return nil, false, fmt.Errorf("failed to get token: %w", err)
     }
-    
-    // Check if token is expired or about to expire (within 5 minutes)
-    if time.Until(token.ExpiresAt) < 5*time.Minute {
-        token, err = s.RefreshToken(ctx, userID)
-        if err != nil {
-            return nil, fmt.Errorf("failed to refresh token: %w", err)
-        }
+    if time.Until(token.ExpiresAt) >= d {
+        return token, false, nil
     }
     
-    return token, nil
+    token, err = s.RefreshToken(ctx, userID)
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to refresh token: %w", err)
+    }
+    return token, true, nil
 }
 
-// Disconnect revokes tokens and removes from storage
+// Disconnect revokes the tokens for the company in ctx, or the active
+// company, and removes them from storage. Another connected company, if
+// any, becomes active.
 func (s *Service) Disconnect(ctx context.Context, userID string) error {
     // Get token
-    token, err := s.tokenStore.GetToken(userID)
+    token, err := s.getToken(ctx, userID)
     if err != nil {
         return fmt.Errorf("failed to get token for revocation: %w", err)
     }
@@ -175,21 +456,66 @@ func (s *Service) Disconnect(ctx context.Context, userID string) error {
     }
     
     // Remove from storage
-    return s.tokenStore.DeleteToken(userID)
+    if err := s.deleteToken(ctx, userID, token.RealmID); err != nil {
+        return err
+    }
+    s.notify(ctx, func(events ConnectionEvents) error {
+        return events.Disconnected(ctx, userID, token.RealmID, LostDisconnected)
+    })
+    return nil
+}
+
+// Companies returns the companies a user connected
+func (s *Service) Companies(ctx context.Context, userID string) ([]Company, error) {
+    _, span := tracing.Start(ctx, "auth.token_store.list")
+    tokens, err := s.tokenStore.ListTokens(userID)
+    tracing.End(span, err)
+    if err != nil {
+        return nil, err
+    }
+    active, _ := s.tokenStore.ActiveRealm(userID)
+    
+    companies := make([]Company, 0, len(tokens))
+    for _, token := range tokens {
+        companies = append(companies, Company{
+            RealmID:   token.RealmID,
+            Active:    token.RealmID == active,
+            ExpiresAt: token.ExpiresAt,
+            Scopes:    s.GrantedScopes(token),
+        })
+    }
+    return companies, nil
+}
+
+// ActivateCompany makes one of a user's connected companies the one their
+// requests go to when they do not select a company
+func (s *Service) ActivateCompany(ctx context.Context, userID, realmID string) error {
+    if realmID == "" {
+        return ErrCompanyNotConnected
+    }
+    if _, err := s.tokenStore.GetToken(userID, realmID); err != nil {
+        return ErrCompanyNotConnected
+    }
+    return s.tokenStore.SetActiveRealm(userID, realmID)
 }
 
 // revokeToken revokes a token with QuickBooks
 func (s *Service) revokeToken(ctx context.Context, token string) error {
+    config, err := s.oauthConfig(ctx)
+    if err != nil {
+        return err
+    }
+    
     data := url.Values{}
     data.Set("token", token)
     
-    req, err := http.NewRequestWithContext(ctx, "POST", s.config.APIBaseURL+"/oauth2/v1/tokens/revoke", strings.NewReader(data.Encode()))
+    req, err := http.NewRequestWithContext(ctx, "POST", config.APIBaseURL+"/oauth2/v1/tokens/revoke", strings.NewReader(data.Encode()))
     if err != nil {
         return fmt.Errorf("failed to create revoke request: %w", err)
     }
     
     req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-    req.SetBasicAuth(s.config.ClientID, s.config.ClientSecret)
+    req.SetBasicAuth(config.ClientID, config.ClientSecret)
     
     client := &http.Client{Timeout: 10 * time.Second}
     resp, err := client.Do(req)
@@ -5,12 +5,14 @@ import (
     "context"
     "encoding/json"
     "fmt"
+    "sort"
     "time"
     
     "github.com/go-redis/redis/v8"
 )
 
-// RedisTokenStore implements TokenStore using Redis
+// RedisTokenStore implements TokenStore using Redis. Each company a user
+// connected has its own token, listed in the user's set of realms.
 type RedisTokenStore struct {
     client *redis.Client
     prefix string
@@ -24,13 +26,41 @@ func NewRedisTokenStore(client *redis.Client, prefix string) *RedisTokenStore {
     }
 }
 
-// key generates the Redis key for a user's token
-func (s *RedisTokenStore) key(userID string) string {
+// key generates the Redis key for a user's token for a company
+func (s *RedisTokenStore) key(userID, realmID string) string {
+    return fmt.Sprintf("%s:token:%s:%s", s.prefix, userID, realmID)
+}
+
+// legacyKey is the key of the single token users had before they could
+// connect several companies
+func (s *RedisTokenStore) legacyKey(userID string) string {
     return fmt.Sprintf("%s:token:%s", s.prefix, userID)
 }
 
-// SaveToken stores a token for a user
+// realmsKey is the set of companies a user connected
+func (s *RedisTokenStore) realmsKey(userID string) string {
+    return fmt.Sprintf("%s:token_realms:%s", s.prefix, userID)
+}
+
+// activeKey holds the company a user's requests go to by default
+func (s *RedisTokenStore) activeKey(userID string) string {
+    return fmt.Sprintf("%s:token_active:%s", s.prefix, userID)
+}
+
+// SaveToken stores a token for the user's company in token.RealmID. The
+// first company a user connects becomes the active one.
 func (s *RedisTokenStore) SaveToken(userID string, token *OAuthToken) error {
+    if _, err := s.migrate(userID); err != nil {
+        return err
+    }
+    return s.save(userID, token)
+}
+
+// save stores a token without looking for a token to migrate
+func (s *RedisTokenStore) save(userID string, token *OAuthToken) error {
+    if token.RealmID == "" {
+        return fmt.Errorf("failed to save token: no realm ID")
+    }
     data, err := json.Marshal(token)
     if err != nil {
         return fmt.Errorf("failed to marshal token: %w", err)
@@ -39,21 +69,37 @@ func (s *RedisTokenStore) SaveToken(userID string, token *OAuthToken) error {
     // Calculate TTL based on token expiry plus a buffer
     ttl := time.Until(token.ExpiresAt) + (24 * time.Hour)
     
-    err = s.client.Set(context.Background(), s.key(userID), data, ttl).Err()
-    if err != nil {
+    ctx := context.Background()
+    pipe := s.client.TxPipeline()
+    pipe.Set(ctx, s.key(userID, token.RealmID), data, ttl)
+    pipe.SAdd(ctx, s.realmsKey(userID), token.RealmID)
+    pipe.SetNX(ctx, s.activeKey(userID), token.RealmID, 0)
+    if _, err := pipe.Exec(ctx); err != nil {
         return fmt.Errorf("failed to save token: %w", err)
     }
     
     return nil
 }
 
-// GetToken retrieves a token for a user
-func (s *RedisTokenStore) GetToken(userID string) (*OAuthToken, error) {
-    data, err := s.client.Get(context.Background(), s.key(userID)).Bytes()
-    if err != nil {
-        if err == redis.Nil {
-            return nil, fmt.Errorf("no token found for user")
+// GetToken retrieves a user's token for a company, or for the active
+// company when realmID is empty
+func (s *RedisTokenStore) GetToken(userID, realmID string) (*OAuthToken, error) {
+    if realmID == "" {
+        active, err := s.ActiveRealm(userID)
+        if err != nil {
+            return nil, err
         }
+        realmID = active
+    }
+    
+    data, err := s.client.Get(context.Background(), s.key(userID, realmID)).Bytes()
+    if err == redis.Nil {
+        if legacy, err := s.migrate(userID); err == nil && legacy != nil && legacy.RealmID == realmID {
+            return legacy, nil
+        }
+        return nil, fmt.Errorf("no token found for user")
+    }
+    if err != nil {
         return nil, fmt.Errorf("failed to get token: %w", err)
     }
     
@@ -65,12 +111,134 @@ func (s *RedisTokenStore) GetToken(userID string) (*OAuthToken, error) {
     return &token, nil
 }
 
-// DeleteToken removes a user's token
-func (s *RedisTokenStore) DeleteToken(userID string) error {
-    err := s.client.Del(context.Background(), s.key(userID)).Err()
+// ListTokens returns the tokens of every company a user connected, by
+// realm ID. Companies whose tokens expired from the store are forgotten.
+func (s *RedisTokenStore) ListTokens(userID string) ([]*OAuthToken, error) {
+    ctx := context.Background()
+    if _, err := s.migrate(userID); err != nil {
+        return nil, err
+    }
+    
+    realmIDs, err := s.client.SMembers(ctx, s.realmsKey(userID)).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list tokens: %w", err)
+    }
+    sort.Strings(realmIDs)
+    tokens := make([]*OAuthToken, 0, len(realmIDs))
+    if len(realmIDs) == 0 {
+        return tokens, nil
+    }
+    
+    keys := make([]string, len(realmIDs))
+    for i, realmID := range realmIDs {
+        keys[i] = s.key(userID, realmID)
+    }
+    values, err := s.client.MGet(ctx, keys...).Result()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list tokens: %w", err)
+    }
+    for i, value := range values {
+        data, ok := value.(string)
+        if !ok {
+            s.forget(userID, realmIDs[i])
+            continue
+        }
+        var token OAuthToken
+        if err := json.Unmarshal([]byte(data), &token); err != nil {
+            continue
+        }
+        tokens = append(tokens, &token)
+    }
+    
+    return tokens, nil
+}
+
+// ActiveRealm returns the company a user's requests go to by default
+func (s *RedisTokenStore) ActiveRealm(userID string) (string, error) {
+    realmID, err := s.client.Get(context.Background(), s.activeKey(userID)).Result()
+    if err == redis.Nil {
+        legacy, err := s.migrate(userID)
+        if err != nil {
+            return "", err
+        }
+        if legacy == nil {
+            return "", fmt.Errorf("no token found for user")
+        }
+        return legacy.RealmID, nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to get active company: %w", err)
+    }
+    
+    return realmID, nil
+}
+
+// SetActiveRealm makes one of a user's companies the active one
+func (s *RedisTokenStore) SetActiveRealm(userID, realmID string) error {
+    err := s.client.Set(context.Background(), s.activeKey(userID), realmID, 0).Err()
     if err != nil {
+        return fmt.Errorf("failed to set active company: %w", err)
+    }
+    
+    return nil
+}
+
+// DeleteToken removes a user's token for a company. If it was the active
+// company, another connected company, if any, becomes active.
+func (s *RedisTokenStore) DeleteToken(userID, realmID string) error {
+    ctx := context.Background()
+    pipe := s.client.TxPipeline()
+    pipe.Del(ctx, s.key(userID, realmID))
+    pipe.SRem(ctx, s.realmsKey(userID), realmID)
+    if _, err := pipe.Exec(ctx); err != nil {
         return fmt.Errorf("failed to delete token: %w", err)
     }
+    s.forget(userID, realmID)
     
     return nil
 }
+
+// forget drops a company from a user's set and moves the active company to
+// a remaining one when it was the active one
+func (s *RedisTokenStore) forget(userID, realmID string) {
+    ctx := context.Background()
+    s.client.SRem(ctx, s.realmsKey(userID), realmID)
+    active, err := s.client.Get(ctx, s.activeKey(userID)).Result()
+    if err != nil || active != realmID {
+        return
+    }
+    if next, err := s.client.SRandMember(ctx, s.realmsKey(userID)).Result(); err == nil {
+        s.client.Set(ctx, s.activeKey(userID), next, 0)
+        return
+    }
+    s.client.Del(ctx, s.activeKey(userID))
+}
+
+// migrate moves a user's token from before multi-company support to its
+// company's key and makes it the active company. It returns the token, or
+// nil when there was none.
+func (s *RedisTokenStore) migrate(userID string) (*OAuthToken, error) {
+    ctx := context.Background()
+    data, err := s.client.Get(ctx, s.legacyKey(userID)).Bytes()
+    if err == redis.Nil {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get token: %w", err)
+    }
+    
+    var token OAuthToken
+    if err := json.Unmarshal(data, &token); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal token: %w", err)
+    }
+    if token.RealmID == "" {
+        // Connected without a company; nothing to migrate to
+        return nil, nil
+    }
+    if err := s.save(userID, &token); err != nil {
+        return nil, err
+    }
+    s.client.Del(ctx, s.legacyKey(userID))
+    
+    return &token, nil
+}
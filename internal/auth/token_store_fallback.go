@@ -4,41 +4,59 @@ package auth
 import (
 	"context"
 	"fmt"
-	"log"
-	"sync"
+	"log/slog"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/eGGnogSC/qbserver/internal/lru"
 	"github.com/go-redis/redis/v8"
 )
 
+// defaultLocalTokens is how many tokens the local cache holds unless
+// configured otherwise
+const defaultLocalTokens = 10000
+
+// localKey keys a user's token for a company in the local cache. Realm IDs
+// are numeric, so the key splits back at the first slash.
+func localKey(userID, realmID string) string {
+	return realmID + "/" + userID
+}
+
 // FallbackTokenStore provides a resilient token store with local cache
 type FallbackTokenStore struct {
-	redisStore  *RedisTokenStore
-	localCache  map[string]*OAuthToken
-	cacheMutex  sync.RWMutex
-	healthCheck func() bool
+	redisStore   *RedisTokenStore
+	localCache   *lru.Cache[string, *OAuthToken]
+	activeRealms *lru.Cache[string, string]
+	healthCheck  func() bool
 }
 
 // NewFallbackTokenStore creates a token store with Redis and local fallback
 func NewFallbackTokenStore(redisClient redis.UniversalClient, prefix string, healthCheck func() bool) *FallbackTokenStore {
 	return &FallbackTokenStore{
-		redisStore:  NewRedisTokenStore(redisClient, prefix),
-		localCache:  make(map[string]*OAuthToken),
-		healthCheck: healthCheck,
+		redisStore:   NewRedisTokenStore(redisClient, prefix),
+		localCache:   lru.New[string, *OAuthToken]("auth.local_tokens", defaultLocalTokens),
+		activeRealms: lru.New[string, string]("auth.local_active_realms", defaultLocalTokens),
+		healthCheck:  healthCheck,
 	}
 }
 
 // SaveToken stores a token in Redis and local cache
 func (s *FallbackTokenStore) SaveToken(userID string, token *OAuthToken) error {
-	// Update local cache
-	s.cacheMutex.Lock()
-	s.localCache[userID] = token
-	s.cacheMutex.Unlock()
+	if token.RealmID == "" {
+		return fmt.Errorf("failed to save token: no realm ID")
+	}
+	
+	// Update local cache; the first company connected becomes active
+	s.localCache.Add(localKey(userID, token.RealmID), token)
+	if _, ok := s.activeRealms.Get(userID); !ok {
+		s.activeRealms.Add(userID, token.RealmID)
+	}
 	
 	// If Redis is healthy, update it too
 	if s.healthCheck() {
 		if err := s.redisStore.SaveToken(userID, token); err != nil {
-			log.Printf("Warning: Failed to save token to Redis: %v", err)
+			slog.Warn("failed to save token to Redis", "error", err)
 			// Continue with just local cache
 		}
 	}
@@ -47,25 +65,31 @@ func (s *FallbackTokenStore) SaveToken(userID string, token *OAuthToken) error {
 }
 
 // GetToken retrieves a token, trying Redis first, falling back to local cache
-func (s *FallbackTokenStore) GetToken(userID string) (*OAuthToken, error) {
+func (s *FallbackTokenStore) GetToken(userID, realmID string) (*OAuthToken, error) {
 	// Try Redis first if healthy
 	if s.healthCheck() {
-		token, err := s.redisStore.GetToken(userID)
+		token, err := s.redisStore.GetToken(userID, realmID)
 		if err == nil {
 			// Update local cache
-			s.cacheMutex.Lock()
-			s.localCache[userID] = token
-			s.cacheMutex.Unlock()
+			s.localCache.Add(localKey(userID, token.RealmID), token)
+			if realmID == "" {
+				s.activeRealms.Add(userID, token.RealmID)
+			}
 			return token, nil
 		}
 		// Redis failed, log and fall back to cache
-		log.Printf("Warning: Failed to get token from Redis: %v", err)
+		slog.Warn("failed to get token from Redis", "error", err)
 	}
 	
 	// Try local cache
-	s.cacheMutex.RLock()
-	token, exists := s.localCache[userID]
-	s.cacheMutex.RUnlock()
+	if realmID == "" {
+		active, ok := s.activeRealms.Get(userID)
+		if !ok {
+			return nil, fmt.Errorf("token not found for user")
+		}
+		realmID = active
+	}
+	token, exists := s.localCache.Get(localKey(userID, realmID))
 	
 	if exists {
 		return token, nil
@@ -74,17 +98,76 @@ func (s *FallbackTokenStore) GetToken(userID string) (*OAuthToken, error) {
 	return nil, fmt.Errorf("token not found for user")
 }
 
+// ListTokens returns a user's tokens from Redis, or from the local cache
+// while Redis is unavailable
+func (s *FallbackTokenStore) ListTokens(userID string) ([]*OAuthToken, error) {
+	if s.healthCheck() {
+		tokens, err := s.redisStore.ListTokens(userID)
+		if err == nil {
+			return tokens, nil
+		}
+		slog.Warn("failed to list tokens from Redis", "error", err)
+	}
+	
+	var tokens []*OAuthToken
+	for key, token := range s.localCache.Snapshot() {
+		if _, user, _ := strings.Cut(key, "/"); user == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].RealmID < tokens[j].RealmID })
+	return tokens, nil
+}
+
+// ActiveRealm returns the user's active company from Redis, or from the
+// local cache while Redis is unavailable
+func (s *FallbackTokenStore) ActiveRealm(userID string) (string, error) {
+	if s.healthCheck() {
+		realmID, err := s.redisStore.ActiveRealm(userID)
+		if err == nil {
+			s.activeRealms.Add(userID, realmID)
+			return realmID, nil
+		}
+		slog.Warn("failed to get active company from Redis", "error", err)
+	}
+	
+	if realmID, ok := s.activeRealms.Get(userID); ok {
+		return realmID, nil
+	}
+	return "", fmt.Errorf("token not found for user")
+}
+
+// SetActiveRealm sets the user's active company in Redis and local cache
+func (s *FallbackTokenStore) SetActiveRealm(userID, realmID string) error {
+	s.activeRealms.Add(userID, realmID)
+	
+	if s.healthCheck() {
+		if err := s.redisStore.SetActiveRealm(userID, realmID); err != nil {
+			slog.Warn("failed to set active company in Redis", "error", err)
+		}
+	}
+	
+	return nil
+}
+
 // DeleteToken removes a token from both stores
-func (s *FallbackTokenStore) DeleteToken(userID string) error {
-	// Remove from local cache
-	s.cacheMutex.Lock()
-	delete(s.localCache, userID)
-	s.cacheMutex.Unlock()
+func (s *FallbackTokenStore) DeleteToken(userID, realmID string) error {
+	// Remove from local cache, moving the active company to a remaining one
+	s.localCache.Remove(localKey(userID, realmID))
+	if active, ok := s.activeRealms.Get(userID); ok && active == realmID {
+		s.activeRealms.Remove(userID)
+		for key := range s.localCache.Snapshot() {
+			if realm, user, _ := strings.Cut(key, "/"); user == userID {
+				s.activeRealms.Add(userID, realm)
+				break
+			}
+		}
+	}
 	
 	// If Redis is healthy, remove from there too
 	if s.healthCheck() {
-		if err := s.redisStore.DeleteToken(userID); err != nil {
-			log.Printf("Warning: Failed to delete token from Redis: %v", err)
+		if err := s.redisStore.DeleteToken(userID, realmID); err != nil {
+			slog.Warn("failed to delete token from Redis", "error", err)
 			// Continue with just local removal
 		}
 	}
@@ -108,17 +191,13 @@ func (s *FallbackTokenStore) StartReplicationRoutine(ctx context.Context) {
 				}
 				
 				// Copy tokens that need replication
-				s.cacheMutex.RLock()
-				tokensToReplicate := make(map[string]*OAuthToken)
-				for id, token := range s.localCache {
-					tokensToReplicate[id] = token
-				}
-				s.cacheMutex.RUnlock()
+				tokensToReplicate := s.localCache.Snapshot()
 				
 				// Replicate to Redis
-				for id, token := range tokensToReplicate {
+				for key, token := range tokensToReplicate {
+					_, id, _ := strings.Cut(key, "/")
 					if err := s.redisStore.SaveToken(id, token); err != nil {
-						log.Printf("Replication error for user %s: %v", id, err)
+						slog.Warn("failed to replicate token to Redis", "user_id", id, "error", err)
 					}
 				}
 			}
@@ -0,0 +1,133 @@
+// bankexport/handler.go
+package bankexport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/metering"
+	"github.com/eGGnogSC/qbserver/internal/operations"
+)
+
+// Handler provides HTTP handlers for bank file exports
+type Handler struct {
+	service    *Service
+	operations *operations.Manager
+	meter      *metering.Meter
+}
+
+// NewHandler creates a new bank export handler
+func NewHandler(service *Service, operations *operations.Manager, meter *metering.Meter) *Handler {
+	return &Handler{
+		service:    service,
+		operations: operations,
+		meter:      meter,
+	}
+}
+
+// render writes a statement and counts its size toward the tenant's export volume
+func (h *Handler) render(ctx context.Context, stmt *Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := stmt.Write(&buf); err != nil {
+		return nil, err
+	}
+	if err := h.meter.Add(ctx, auth.GetTenantID(ctx), metering.ExportBytes, int64(buf.Len())); err != nil {
+		logging.FromContext(ctx).Warn("failed to meter export size", "error", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportOFX returns payments (or ?source=deposits) dated between ?from= and
+// ?to= as an OFX file, or a QuickBooks Web Connect file with ?format=qbo.
+// ?account_id= limits the export to one deposit account. Asynchronous
+// requests are answered with an operation whose result is the file.
+func (h *Handler) ExportOFX(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := Request{
+		Source:       q.Get("source"),
+		Format:       q.Get("format"),
+		AccountID:    q.Get("account_id"),
+		BankID:       q.Get("bank_id"),
+		IntuitBankID: q.Get("intu_bid"),
+	}
+
+	switch req.Source {
+	case "":
+		req.Source = SourcePayments
+	case SourcePayments, SourceDeposits:
+	default:
+		http.Error(w, "Invalid source, expected payments or deposits", http.StatusBadRequest)
+		return
+	}
+	switch req.Format {
+	case "":
+		req.Format = FormatOFX
+	case FormatOFX, FormatQBO:
+	default:
+		http.Error(w, "Invalid format, expected ofx or qbo", http.StatusBadRequest)
+		return
+	}
+	if req.BankID == "" {
+		req.BankID = "000000000"
+	}
+
+	var err error
+	if req.From, err = time.Parse("2006-01-02", q.Get("from")); err != nil {
+		http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if req.To, err = time.Parse("2006-01-02", q.Get("to")); err != nil {
+		http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if req.To.Before(req.From) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	if operations.Async(r) {
+		op, err := h.operations.Start(r.Context(), auth.GetTenantID(r.Context()), "bank_export", func(ctx context.Context) (interface{}, error) {
+			stmt, err := h.service.Statement(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			data, err := h.render(ctx, stmt)
+			if err != nil {
+				return nil, err
+			}
+			return &operations.File{ContentType: stmt.ContentType(), Filename: stmt.Filename(), Data: data}, nil
+		})
+		if err != nil {
+			http.Error(w, "Failed to start export: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		operations.Accepted(w, r, op)
+		return
+	}
+
+	stmt, err := h.service.Statement(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrMixedCurrencies) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to export transactions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.render(r.Context(), stmt)
+	if err != nil {
+		http.Error(w, "Failed to export transactions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", stmt.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+stmt.Filename()+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
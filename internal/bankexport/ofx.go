@@ -0,0 +1,157 @@
+// bankexport/ofx.go
+package bankexport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Output formats
+const (
+	FormatOFX = "ofx" // OFX 1.02 SGML
+	FormatQBO = "qbo" // OFX 1.02 with the Intuit bank ID used by Web Connect
+)
+
+// defaultIntuitBankID is the Web Connect bank ID written to .qbo files
+const defaultIntuitBankID = "3000"
+
+// Transaction is a single statement line
+type Transaction struct {
+	ID     string    `json:"id"` // unique and stable across exports (OFX FITID)
+	Type   string    `json:"type"`
+	Posted time.Time `json:"posted"`
+	Amount float64   `json:"amount"`
+	Name   string    `json:"name"`
+	Memo   string    `json:"memo,omitempty"`
+	RefNum string    `json:"ref_num,omitempty"`
+
+	currency string
+}
+
+// Statement is a bank statement covering a date range
+type Statement struct {
+	Format        string
+	Currency      string
+	BankID        string
+	AccountID     string
+	AccountType   string // CHECKING or SAVINGS
+	IntuitBankID  string // only written for FormatQBO
+	Start         time.Time
+	End           time.Time
+	Transactions  []Transaction
+	GeneratedAt   time.Time
+	LedgerBalance float64
+}
+
+// Filename returns a download name for the statement
+func (s *Statement) Filename() string {
+	return fmt.Sprintf("payments-%s-%s.%s", s.Start.Format("20060102"), s.End.Format("20060102"), s.Format)
+}
+
+// ContentType returns the MIME type for the statement format
+func (s *Statement) ContentType() string {
+	if s.Format == FormatQBO {
+		return "application/vnd.intu.qbo"
+	}
+	return "application/x-ofx"
+}
+
+// Write renders the statement as OFX 1.02 SGML
+func (s *Statement) Write(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+	}
+
+	line("OFXHEADER:100")
+	line("DATA:OFXSGML")
+	line("VERSION:102")
+	line("SECURITY:NONE")
+	line("ENCODING:USASCII")
+	line("CHARSET:1252")
+	line("COMPRESSION:NONE")
+	line("OLDFILEUID:NONE")
+	line("NEWFILEUID:NONE")
+	line("")
+
+	line("<OFX>")
+	line("<SIGNONMSGSRSV1>")
+	line("<SONRS>")
+	line("<STATUS><CODE>0<SEVERITY>INFO</STATUS>")
+	line("<DTSERVER>%s", ofxTime(s.GeneratedAt))
+	line("<LANGUAGE>ENG")
+	if s.Format == FormatQBO {
+		bankID := s.IntuitBankID
+		if bankID == "" {
+			bankID = defaultIntuitBankID
+		}
+		line("<FI><ORG>qbserver<FID>%s</FI>", sgml(bankID))
+		line("<INTU.BID>%s", sgml(bankID))
+	}
+	line("</SONRS>")
+	line("</SIGNONMSGSRSV1>")
+
+	line("<BANKMSGSRSV1>")
+	line("<STMTTRNRS>")
+	line("<TRNUID>1")
+	line("<STATUS><CODE>0<SEVERITY>INFO</STATUS>")
+	line("<STMTRS>")
+	line("<CURDEF>%s", sgml(s.Currency))
+	line("<BANKACCTFROM>")
+	line("<BANKID>%s", sgml(s.BankID))
+	line("<ACCTID>%s", sgml(s.AccountID))
+	line("<ACCTTYPE>%s", sgml(s.AccountType))
+	line("</BANKACCTFROM>")
+	line("<BANKTRANLIST>")
+	line("<DTSTART>%s", ofxTime(s.Start))
+	line("<DTEND>%s", ofxTime(s.End))
+	for _, t := range s.Transactions {
+		line("<STMTTRN>")
+		line("<TRNTYPE>%s", t.Type)
+		line("<DTPOSTED>%s", ofxTime(t.Posted))
+		line("<TRNAMT>%.2f", t.Amount)
+		line("<FITID>%s", sgml(t.ID))
+		if t.RefNum != "" {
+			line("<CHECKNUM>%s", sgml(truncate(t.RefNum, 12)))
+		}
+		// NAME is limited to 32 characters in OFX 1.02
+		line("<NAME>%s", sgml(truncate(t.Name, 32)))
+		if t.Memo != "" {
+			line("<MEMO>%s", sgml(truncate(t.Memo, 255)))
+		}
+		line("</STMTTRN>")
+	}
+	line("</BANKTRANLIST>")
+	line("<LEDGERBAL><BALAMT>%.2f<DTASOF>%s</LEDGERBAL>", s.LedgerBalance, ofxTime(s.End))
+	line("</STMTRS>")
+	line("</STMTTRNRS>")
+	line("</BANKMSGSRSV1>")
+	line("</OFX>")
+
+	return w.Flush()
+}
+
+// ofxTime formats a time as an OFX datetime in UTC
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "[0:GMT]"
+}
+
+// sgmlReplacer escapes SGML markup and strips line breaks from element values
+var sgmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", " ", "\n", " ")
+
+// sgml escapes a value for an OFX element
+func sgml(s string) string {
+	return sgmlReplacer.Replace(s)
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+// bankexport/service.go
+package bankexport
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Sources of exported transactions
+const (
+	SourcePayments = "payments" // customer payments received
+	SourceDeposits = "deposits" // bank deposits, which may bundle several payments
+)
+
+// ErrMixedCurrencies is returned when the selected transactions span currencies
+var ErrMixedCurrencies = errors.New("transactions are in more than one currency; filter by account_id")
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// Request describes an export
+type Request struct {
+	Source       string
+	Format       string
+	From         time.Time
+	To           time.Time
+	AccountID    string // limit to transactions deposited to this QuickBooks account
+	BankID       string // routing number written to BANKACCTFROM
+	IntuitBankID string
+}
+
+// ref is a QuickBooks entity reference
+type ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+// payment is the subset of a QuickBooks payment used for export
+type payment struct {
+	ID                  string  `json:"Id"`
+	TxnDate             string  `json:"TxnDate"`
+	TotalAmt            float64 `json:"TotalAmt"`
+	PaymentRefNum       string  `json:"PaymentRefNum"`
+	PrivateNote         string  `json:"PrivateNote"`
+	CustomerRef         ref     `json:"CustomerRef"`
+	CurrencyRef         ref     `json:"CurrencyRef"`
+	DepositToAccountRef ref     `json:"DepositToAccountRef"`
+}
+
+// deposit is the subset of a QuickBooks deposit used for export
+type deposit struct {
+	ID                  string  `json:"Id"`
+	TxnDate             string  `json:"TxnDate"`
+	TotalAmt            float64 `json:"TotalAmt"`
+	PrivateNote         string  `json:"PrivateNote"`
+	CurrencyRef         ref     `json:"CurrencyRef"`
+	DepositToAccountRef ref     `json:"DepositToAccountRef"`
+}
+
+// Service builds bank statement files from QuickBooks transactions
+type Service struct {
+	querier Querier
+}
+
+// NewService creates a new bank export service
+func NewService(querier Querier) *Service {
+	return &Service{
+		querier: querier,
+	}
+}
+
+// Statement collects the requested transactions into a statement
+func (s *Service) Statement(ctx context.Context, req Request) (*Statement, error) {
+	inPeriod := func(entity string) *query.Builder {
+		return query.Select(entity).
+			Where("TxnDate", ">=", req.From.Format("2006-01-02")).
+			Where("TxnDate", "<=", req.To.Format("2006-01-02"))
+	}
+
+	stmt := &Statement{
+		Format:       req.Format,
+		BankID:       req.BankID,
+		AccountID:    req.AccountID,
+		AccountType:  "CHECKING",
+		IntuitBankID: req.IntuitBankID,
+		Start:        req.From,
+		End:          req.To.Add(24*time.Hour - time.Second),
+		GeneratedAt:  time.Now(),
+	}
+	if stmt.AccountID == "" {
+		stmt.AccountID = "QUICKBOOKS"
+	}
+
+	switch req.Source {
+	case SourceDeposits:
+		deposits, err := query.All[deposit](ctx, s.querier, inPeriod("Deposit"))
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deposits {
+			if req.AccountID != "" && d.DepositToAccountRef.Value != req.AccountID {
+				continue
+			}
+			stmt.add(Transaction{
+				currency: d.CurrencyRef.Value,
+				ID:       "DEP-" + d.ID,
+				Type:     "DEP",
+				Posted:   parseDate(d.TxnDate),
+				Amount:   d.TotalAmt,
+				Name:     nonEmpty(d.DepositToAccountRef.Name, "Deposit"),
+				Memo:     d.PrivateNote,
+			})
+		}
+	default:
+		payments, err := query.All[payment](ctx, s.querier, inPeriod("Payment"))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range payments {
+			if req.AccountID != "" && p.DepositToAccountRef.Value != req.AccountID {
+				continue
+			}
+			stmt.add(Transaction{
+				currency: p.CurrencyRef.Value,
+				ID:       "PMT-" + p.ID,
+				Type:     "CREDIT",
+				Posted:   parseDate(p.TxnDate),
+				Amount:   p.TotalAmt,
+				Name:     nonEmpty(p.CustomerRef.Name, "Customer payment"),
+				Memo:     p.PrivateNote,
+				RefNum:   p.PaymentRefNum,
+			})
+		}
+	}
+
+	for _, t := range stmt.Transactions {
+		if t.currency != "" && t.currency != stmt.Currency {
+			return nil, ErrMixedCurrencies
+		}
+	}
+
+	sort.SliceStable(stmt.Transactions, func(i, j int) bool {
+		return stmt.Transactions[i].Posted.Before(stmt.Transactions[j].Posted)
+	})
+	if stmt.Currency == "" {
+		stmt.Currency = "USD"
+	}
+	return stmt, nil
+}
+
+// add appends a transaction, taking the statement currency from the first one
+func (s *Statement) add(t Transaction) {
+	if s.Currency == "" {
+		s.Currency = t.currency
+	}
+	s.Transactions = append(s.Transactions, t)
+	s.LedgerBalance += t.Amount
+}
+
+// parseDate parses a QuickBooks transaction date
+func parseDate(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+// nonEmpty returns s, or fallback when s is empty
+func nonEmpty(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
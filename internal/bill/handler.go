@@ -0,0 +1,185 @@
+// bill/handler.go
+package bill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+	"github.com/gorilla/mux"
+)
+
+// Servicer is the bill service the handler serves. *Service implements
+// it; tests can substitute a fake.
+type Servicer interface {
+	List(ctx context.Context, opts ListOptions) ([]Bill, error)
+	Get(ctx context.Context, id string) (*Bill, error)
+	Create(ctx context.Context, bill *Bill) (*Bill, error)
+	Unpaid(ctx context.Context, vendorID string) ([]VendorBills, error)
+	Pay(ctx context.Context, req *PaymentRequest) (*Payment, error)
+	ListPayments(ctx context.Context, opts ListOptions) ([]BillPayment, error)
+	GetPayment(ctx context.Context, id string) (*BillPayment, error)
+}
+
+// Handler provides HTTP handlers for bills and bill payments
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new bill handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, writelock.ErrTimeout):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidBill), errors.Is(err, ErrInvalidPayment):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// listOptions parses the list query: ?vendor_id= filters the list, and
+// ?limit= and ?offset= page it
+func listOptions(r *http.Request) (ListOptions, error) {
+	query := r.URL.Query()
+	opts := ListOptions{VendorID: query.Get("vendor_id"), Limit: defaultLimit}
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil || opts.Limit < 1 {
+			return opts, errors.New("limit must be a positive number")
+		}
+		if opts.Limit > maxLimit {
+			opts.Limit = maxLimit
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil || opts.Offset < 0 {
+			return opts, errors.New("offset must not be negative")
+		}
+	}
+	return opts, nil
+}
+
+// ListBills lists bills, newest first
+func (h *Handler) ListBills(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bills, err := h.service.List(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list bills: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(bills)
+}
+
+// ListUnpaid lists unpaid bills per vendor, optionally only the
+// ?vendor_id= vendor's
+func (h *Handler) ListUnpaid(w http.ResponseWriter, r *http.Request) {
+	vendors, err := h.service.Unpaid(r.Context(), r.URL.Query().Get("vendor_id"))
+	if err != nil {
+		http.Error(w, "Failed to list unpaid bills: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"vendors": vendors,
+	})
+}
+
+// GetBill returns a bill
+func (h *Handler) GetBill(w http.ResponseWriter, r *http.Request) {
+	bill, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get bill: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(bill)
+}
+
+// CreateBill records a vendor bill
+func (h *Handler) CreateBill(w http.ResponseWriter, r *http.Request) {
+	var bill Bill
+	if err := json.NewDecoder(r.Body).Decode(&bill); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &bill)
+	if err != nil {
+		http.Error(w, "Failed to create bill: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// ListPayments lists bill payments, newest first
+func (h *Handler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payments, err := h.service.ListPayments(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list bill payments: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payments)
+}
+
+// GetPayment returns a bill payment
+func (h *Handler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.service.GetPayment(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get bill payment: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// PayBills records a payment of a vendor's bills. The body may list the
+// bills and amounts; without them the vendor's unpaid bills are paid in
+// full.
+func (h *Handler) PayBills(w http.ResponseWriter, r *http.Request) {
+	var req PaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.service.Pay(r.Context(), &req)
+	if err != nil {
+		http.Error(w, "Failed to pay bills: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payment)
+}
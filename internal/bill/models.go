@@ -0,0 +1,186 @@
+// bill/models.go
+package bill
+
+import "github.com/eGGnogSC/qbserver/internal/money"
+
+// Ref references another QuickBooks entity
+type Ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+// MetaData holds QuickBooks record timestamps
+type MetaData struct {
+	CreateTime      string `json:"CreateTime,omitempty"`
+	LastUpdatedTime string `json:"LastUpdatedTime,omitempty"`
+}
+
+// Line detail types
+const (
+	DetailAccount = "AccountBasedExpenseLineDetail" // an expense booked to an account
+	DetailItem    = "ItemBasedExpenseLineDetail"    // a purchase of an item
+)
+
+// AccountBasedExpenseLineDetail books a line to an expense account
+type AccountBasedExpenseLineDetail struct {
+	AccountRef     *Ref   `json:"AccountRef,omitempty"`
+	TaxCodeRef     *Ref   `json:"TaxCodeRef,omitempty"`
+	ClassRef       *Ref   `json:"ClassRef,omitempty"`
+	CustomerRef    *Ref   `json:"CustomerRef,omitempty"` // customer the expense is billable to
+	BillableStatus string `json:"BillableStatus,omitempty"`
+}
+
+// ItemBasedExpenseLineDetail is the item, quantity and cost of a line
+type ItemBasedExpenseLineDetail struct {
+	ItemRef        *Ref          `json:"ItemRef,omitempty"`
+	Qty            *money.Amount `json:"Qty,omitempty"`
+	UnitPrice      *money.Amount `json:"UnitPrice,omitempty"`
+	TaxCodeRef     *Ref          `json:"TaxCodeRef,omitempty"`
+	ClassRef       *Ref          `json:"ClassRef,omitempty"`
+	CustomerRef    *Ref          `json:"CustomerRef,omitempty"`
+	BillableStatus string        `json:"BillableStatus,omitempty"`
+}
+
+// Line is a bill line; DetailType names which detail is set
+type Line struct {
+	ID                            string                         `json:"Id,omitempty"`
+	LineNum                       int                            `json:"LineNum,omitempty"`
+	Description                   string                         `json:"Description,omitempty"`
+	Amount                        money.Amount                   `json:"Amount"`
+	DetailType                    string                         `json:"DetailType"`
+	AccountBasedExpenseLineDetail *AccountBasedExpenseLineDetail `json:"AccountBasedExpenseLineDetail,omitempty"`
+	ItemBasedExpenseLineDetail    *ItemBasedExpenseLineDetail    `json:"ItemBasedExpenseLineDetail,omitempty"`
+}
+
+// LinkedTxn links a transaction to another, e.g. a bill to its payments
+type LinkedTxn struct {
+	TxnID   string `json:"TxnId"`
+	TxnType string `json:"TxnType"`
+}
+
+// TxnTaxDetail is the tax code and computed tax of a transaction
+type TxnTaxDetail struct {
+	TxnTaxCodeRef *Ref          `json:"TxnTaxCodeRef,omitempty"`
+	TotalTax      *money.Amount `json:"TotalTax,omitempty"`
+}
+
+// Bill is a QuickBooks vendor bill, in QuickBooks' field names. Balance is
+// what is left to pay.
+type Bill struct {
+	ID           string        `json:"Id,omitempty"`
+	SyncToken    string        `json:"SyncToken,omitempty"`
+	DocNumber    string        `json:"DocNumber,omitempty"`
+	TxnDate      string        `json:"TxnDate,omitempty"`
+	DueDate      string        `json:"DueDate,omitempty"`
+	VendorRef    *Ref          `json:"VendorRef,omitempty"`
+	APAccountRef *Ref          `json:"APAccountRef,omitempty"`
+	SalesTermRef *Ref          `json:"SalesTermRef,omitempty"`
+	PrivateNote  string        `json:"PrivateNote,omitempty"`
+	Line         []Line        `json:"Line"`
+	TxnTaxDetail *TxnTaxDetail `json:"TxnTaxDetail,omitempty"`
+	CurrencyRef  *Ref          `json:"CurrencyRef,omitempty"`
+	LinkedTxn    []LinkedTxn   `json:"LinkedTxn,omitempty"`
+	TotalAmt     money.Amount  `json:"TotalAmt,omitempty"`
+	Balance      money.Amount  `json:"Balance,omitempty"`
+	MetaData     *MetaData     `json:"MetaData,omitempty"`
+}
+
+// currency returns the bill's currency code, "" for the home currency
+func (b *Bill) currency() string {
+	if b.CurrencyRef == nil {
+		return ""
+	}
+	return b.CurrencyRef.Value
+}
+
+// Bill payment types
+const (
+	PayTypeCheck      = "Check"      // paid from a bank account
+	PayTypeCreditCard = "CreditCard" // charged to a credit card account
+)
+
+// CheckPayment is the bank account a check bill payment is paid from
+type CheckPayment struct {
+	BankAccountRef *Ref   `json:"BankAccountRef,omitempty"`
+	PrintStatus    string `json:"PrintStatus,omitempty"`
+}
+
+// CreditCardPayment is the credit card account a bill payment is charged to
+type CreditCardPayment struct {
+	CCAccountRef *Ref `json:"CCAccountRef,omitempty"`
+}
+
+// PaymentLine is the amount of a bill payment applied to one bill
+type PaymentLine struct {
+	Amount    money.Amount `json:"Amount"`
+	LinkedTxn []LinkedTxn  `json:"LinkedTxn"`
+}
+
+// BillPayment is a QuickBooks bill payment, in QuickBooks' field names
+type BillPayment struct {
+	ID                string             `json:"Id,omitempty"`
+	SyncToken         string             `json:"SyncToken,omitempty"`
+	DocNumber         string             `json:"DocNumber,omitempty"`
+	TxnDate           string             `json:"TxnDate,omitempty"`
+	VendorRef         *Ref               `json:"VendorRef,omitempty"`
+	PayType           string             `json:"PayType"`
+	CheckPayment      *CheckPayment      `json:"CheckPayment,omitempty"`
+	CreditCardPayment *CreditCardPayment `json:"CreditCardPayment,omitempty"`
+	APAccountRef      *Ref               `json:"APAccountRef,omitempty"`
+	PrivateNote       string             `json:"PrivateNote,omitempty"`
+	CurrencyRef       *Ref               `json:"CurrencyRef,omitempty"`
+	Line              []PaymentLine      `json:"Line"`
+	TotalAmt          money.Amount       `json:"TotalAmt"`
+	MetaData          *MetaData          `json:"MetaData,omitempty"`
+}
+
+// ListOptions filters and pages bill and bill payment lists
+type ListOptions struct {
+	VendorID string
+	Limit    int
+	Offset   int
+}
+
+// VendorBills is a vendor's unpaid bills in one currency
+type VendorBills struct {
+	VendorID   string       `json:"vendor_id"`
+	VendorName string       `json:"vendor_name,omitempty"`
+	Currency   string       `json:"currency,omitempty"`
+	Balance    money.Amount `json:"balance"`
+	Bills      []Bill       `json:"bills"`
+}
+
+// Allocation is the part of a payment applied to one bill
+type Allocation struct {
+	BillID string `json:"bill_id"`
+	// Amount defaults to the bill's balance
+	Amount money.Amount `json:"amount"`
+	// DocNumber and Balance describe the bill once paid; Balance is what
+	// was open before
+	DocNumber string       `json:"doc_number,omitempty"`
+	Balance   money.Amount `json:"balance,omitempty"`
+}
+
+// PaymentRequest pays bills of one vendor. Without bills, the vendor's
+// unpaid bills are paid in full, oldest due first.
+type PaymentRequest struct {
+	VendorID string       `json:"vendor_id"`
+	Bills    []Allocation `json:"bills,omitempty"`
+	// PayType is Check, the default, or CreditCard
+	PayType string `json:"pay_type,omitempty"`
+	// AccountID is the bank account checks are paid from, or the credit
+	// card account card payments are charged to
+	AccountID string `json:"account_id"`
+	// PrintCheck queues a check payment for printing in QuickBooks
+	PrintCheck bool   `json:"print_check,omitempty"`
+	DocNumber  string `json:"doc_number,omitempty"`
+	TxnDate    string `json:"txn_date,omitempty"`
+	Memo       string `json:"memo,omitempty"`
+}
+
+// Payment is a recorded bill payment and the bills it paid
+type Payment struct {
+	BillPayment *BillPayment `json:"bill_payment"`
+	Paid        []Allocation `json:"paid"`
+	Total       money.Amount `json:"total"`
+}
@@ -0,0 +1,455 @@
+// bill/service.go
+package bill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+var (
+	// ErrNotFound is returned for bill and bill payment IDs QuickBooks does
+	// not know
+	ErrNotFound = errors.New("bill not found")
+	// ErrInvalidBill is returned for bills without a vendor or valid lines
+	ErrInvalidBill = errors.New("invalid bill")
+	// ErrInvalidPayment is returned for payments of bills they cannot pay,
+	// or of more than is open
+	ErrInvalidPayment = errors.New("invalid bill payment")
+)
+
+// defaultLimit and maxLimit bound bill list pages
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// readOnlyFields are computed by QuickBooks and left out of new bills
+var readOnlyFields = []string{"Id", "SyncToken", "TotalAmt", "Balance", "LinkedTxn", "MetaData"}
+
+// QuickBooks is the subset of the QuickBooks client used for bills
+type QuickBooks interface {
+	query.Querier
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// Locker serializes payments of the same vendor's bills across instances
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// Service records vendor bills and bill payments in QuickBooks
+type Service struct {
+	qb     QuickBooks
+	locker Locker
+}
+
+// NewService creates a new bill service
+func NewService(qb QuickBooks, locker Locker) *Service {
+	return &Service{
+		qb:     qb,
+		locker: locker,
+	}
+}
+
+// List returns a page of bills, newest first, optionally only a vendor's
+func (s *Service) List(ctx context.Context, opts ListOptions) ([]Bill, error) {
+	q, ok := listQuery("Bill", opts)
+	if !ok {
+		return []Bill{}, nil
+	}
+	bills, err := query.List[Bill](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bills: %w", err)
+	}
+	return bills, nil
+}
+
+// ListPayments returns a page of bill payments, newest first, optionally
+// only a vendor's
+func (s *Service) ListPayments(ctx context.Context, opts ListOptions) ([]BillPayment, error) {
+	q, ok := listQuery("BillPayment", opts)
+	if !ok {
+		return []BillPayment{}, nil
+	}
+	payments, err := query.List[BillPayment](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bill payments: %w", err)
+	}
+	return payments, nil
+}
+
+// listQuery builds a bill or bill payment list query; it reports false for
+// vendor IDs that cannot match
+func listQuery(entity string, opts ListOptions) (*query.Builder, bool) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	q := query.Select(entity)
+	if opts.VendorID != "" {
+		if !query.IsID(opts.VendorID) {
+			return nil, false
+		}
+		q.Where("VendorRef", "=", opts.VendorID)
+	}
+	return q.OrderByDesc("TxnDate").Offset(opts.Offset).Limit(opts.Limit), true
+}
+
+// Get returns a bill by ID
+func (s *Service) Get(ctx context.Context, id string) (*Bill, error) {
+	var bill Bill
+	if err := s.read(ctx, "Bill", id, &bill); err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+// GetPayment returns a bill payment by ID
+func (s *Service) GetPayment(ctx context.Context, id string) (*BillPayment, error) {
+	var payment BillPayment
+	if err := s.read(ctx, "BillPayment", id, &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// read reads an entity by ID into v
+func (s *Service) read(ctx context.Context, entity, id string, v interface{}) error {
+	fields, err := s.qb.Read(ctx, entity, id)
+	if err != nil {
+		return notFound(err)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", entity, err)
+	}
+	return nil
+}
+
+// Create records a bill from a vendor. Lines book an expense to an account
+// or buy an item; item lines without an amount cost their quantity times
+// their unit price.
+func (s *Service) Create(ctx context.Context, bill *Bill) (*Bill, error) {
+	if bill.VendorRef == nil || !query.IsID(bill.VendorRef.Value) {
+		return nil, fmt.Errorf("%w: a VendorRef is required", ErrInvalidBill)
+	}
+	if len(bill.Line) == 0 {
+		return nil, fmt.Errorf("%w: at least one line is required", ErrInvalidBill)
+	}
+	for _, date := range []string{bill.TxnDate, bill.DueDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("%w: dates must be YYYY-MM-DD", ErrInvalidBill)
+		}
+	}
+	for i := range bill.Line {
+		if err := prepareLine(&bill.Line[i]); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidBill, i+1, err)
+		}
+	}
+
+	data, err := json.Marshal(bill)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	for _, name := range readOnlyFields {
+		delete(payload, name)
+	}
+
+	var created struct {
+		Bill Bill `json:"Bill"`
+	}
+	if err := s.qb.Create(ctx, "Bill", payload, &created); err != nil {
+		return nil, err
+	}
+	return &created.Bill, nil
+}
+
+// prepareLine checks a bill line has the detail its type names, filling
+// item line amounts from their quantity and unit price
+func prepareLine(line *Line) error {
+	line.ID = ""
+	switch line.DetailType {
+	case DetailAccount:
+		detail := line.AccountBasedExpenseLineDetail
+		if detail == nil || detail.AccountRef == nil || detail.AccountRef.Value == "" {
+			return errors.New("an AccountRef is required")
+		}
+		line.ItemBasedExpenseLineDetail = nil
+	case DetailItem:
+		detail := line.ItemBasedExpenseLineDetail
+		if detail == nil || detail.ItemRef == nil || detail.ItemRef.Value == "" {
+			return errors.New("an ItemRef is required")
+		}
+		if line.Amount.IsZero() && detail.Qty != nil && detail.UnitPrice != nil {
+			line.Amount = money.NewAmount(detail.Qty.Mul(detail.UnitPrice.Decimal).Round(2))
+		}
+		line.AccountBasedExpenseLineDetail = nil
+	default:
+		return fmt.Errorf("DetailType must be %s or %s", DetailAccount, DetailItem)
+	}
+	if !line.Amount.IsPositive() {
+		return errors.New("amount must be positive")
+	}
+	return nil
+}
+
+// openBills returns bills with a balance: those given, or else the
+// vendor's, oldest due first
+func (s *Service) openBills(ctx context.Context, vendorID string, ids []string) ([]Bill, error) {
+	q := query.Select("Bill")
+	if len(ids) > 0 {
+		values := make([]interface{}, len(ids))
+		for i, id := range ids {
+			if !query.IsID(id) {
+				return nil, fmt.Errorf("%w: bill %q not found", ErrInvalidPayment, id)
+			}
+			values[i] = id
+		}
+		q.WhereIn("Id", values...)
+	} else {
+		q.Where("VendorRef", "=", vendorID).Where("Balance", ">", "0")
+	}
+
+	bills, err := query.All[Bill](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bills: %w", err)
+	}
+	sort.SliceStable(bills, func(i, j int) bool {
+		a, b := bills[i], bills[j]
+		if a.DueDate != b.DueDate {
+			return a.DueDate < b.DueDate
+		}
+		return a.TxnDate < b.TxnDate
+	})
+	return bills, nil
+}
+
+// Unpaid returns the bills with a balance, grouped by vendor and currency,
+// optionally only a vendor's
+func (s *Service) Unpaid(ctx context.Context, vendorID string) ([]VendorBills, error) {
+	q := query.Select("Bill").Where("Balance", ">", "0")
+	if vendorID != "" {
+		if !query.IsID(vendorID) {
+			return []VendorBills{}, nil
+		}
+		q.Where("VendorRef", "=", vendorID)
+	}
+	bills, err := query.All[Bill](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unpaid bills: %w", err)
+	}
+
+	vendors := make(map[string]*VendorBills)
+	var keys []string
+	for _, bill := range bills {
+		if !bill.Balance.IsPositive() || bill.VendorRef == nil {
+			continue
+		}
+		key := bill.VendorRef.Value + ":" + bill.currency()
+		unpaid, ok := vendors[key]
+		if !ok {
+			unpaid = &VendorBills{
+				VendorID:   bill.VendorRef.Value,
+				VendorName: bill.VendorRef.Name,
+				Currency:   bill.currency(),
+				Bills:      []Bill{},
+			}
+			vendors[key] = unpaid
+			keys = append(keys, key)
+		}
+		unpaid.Balance = money.NewAmount(unpaid.Balance.Add(bill.Balance.Decimal))
+		unpaid.Bills = append(unpaid.Bills, bill)
+	}
+
+	result := make([]VendorBills, 0, len(keys))
+	for _, key := range keys {
+		unpaid := vendors[key]
+		sort.SliceStable(unpaid.Bills, func(i, j int) bool {
+			a, b := unpaid.Bills[i], unpaid.Bills[j]
+			if a.DueDate != b.DueDate {
+				return a.DueDate < b.DueDate
+			}
+			return a.TxnDate < b.TxnDate
+		})
+		result = append(result, *unpaid)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].VendorName != result[j].VendorName {
+			return result[i].VendorName < result[j].VendorName
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	return result, nil
+}
+
+// Pay records a bill payment of a vendor's bills, in full or of the amounts
+// given, from a bank account by check or charged to a credit card.
+// Payments of the same vendor are serialized, so retries cannot pay a bill
+// twice.
+func (s *Service) Pay(ctx context.Context, req *PaymentRequest) (*Payment, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !query.IsID(req.VendorID) {
+		return nil, fmt.Errorf("%w: vendor_id is required", ErrInvalidPayment)
+	}
+	if !query.IsID(req.AccountID) {
+		return nil, fmt.Errorf("%w: account_id is required", ErrInvalidPayment)
+	}
+	if req.PayType == "" {
+		req.PayType = PayTypeCheck
+	}
+	if req.PayType != PayTypeCheck && req.PayType != PayTypeCreditCard {
+		return nil, fmt.Errorf("%w: pay_type must be %s or %s", ErrInvalidPayment, PayTypeCheck, PayTypeCreditCard)
+	}
+	if req.TxnDate != "" {
+		if _, err := time.Parse("2006-01-02", req.TxnDate); err != nil {
+			return nil, fmt.Errorf("%w: txn_date must be YYYY-MM-DD", ErrInvalidPayment)
+		}
+	}
+
+	unlock, err := s.locker.Lock(ctx, fmt.Sprintf("%s:bill:pay:%s", realmID, req.VendorID))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	requested := make(map[string]money.Amount, len(req.Bills))
+	ids := make([]string, 0, len(req.Bills))
+	for _, allocation := range req.Bills {
+		if _, ok := requested[allocation.BillID]; ok {
+			return nil, fmt.Errorf("%w: bill %s is listed twice", ErrInvalidPayment, allocation.BillID)
+		}
+		if allocation.Amount.IsNegative() {
+			return nil, fmt.Errorf("%w: amount for bill %s is negative", ErrInvalidPayment, allocation.BillID)
+		}
+		requested[allocation.BillID] = allocation.Amount
+		ids = append(ids, allocation.BillID)
+	}
+	bills, err := s.openBills(ctx, req.VendorID, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		// Pay in the order given
+		found := make(map[string]Bill, len(bills))
+		for _, bill := range bills {
+			found[bill.ID] = bill
+		}
+		bills = bills[:0]
+		for _, id := range ids {
+			bill, ok := found[id]
+			if !ok {
+				return nil, fmt.Errorf("%w: bill %s not found", ErrInvalidPayment, id)
+			}
+			bills = append(bills, bill)
+		}
+	}
+	if len(bills) == 0 {
+		return nil, fmt.Errorf("%w: the vendor has no unpaid bills", ErrInvalidPayment)
+	}
+
+	payment := &Payment{Paid: []Allocation{}}
+	currency := bills[0].currency()
+	var lines []PaymentLine
+	for _, bill := range bills {
+		if bill.VendorRef == nil || bill.VendorRef.Value != req.VendorID {
+			return nil, fmt.Errorf("%w: bill %s belongs to another vendor", ErrInvalidPayment, bill.ID)
+		}
+		if bill.currency() != currency {
+			return nil, fmt.Errorf("%w: bill %s is in another currency", ErrInvalidPayment, bill.ID)
+		}
+		if !bill.Balance.IsPositive() {
+			return nil, fmt.Errorf("%w: bill %s is paid", ErrInvalidPayment, bill.ID)
+		}
+		amount := requested[bill.ID]
+		if amount.IsZero() {
+			amount = bill.Balance
+		}
+		if amount.GreaterThan(bill.Balance.Decimal) {
+			return nil, fmt.Errorf("%w: %s exceeds the balance of bill %s", ErrInvalidPayment, amount, bill.ID)
+		}
+
+		payment.Total = money.NewAmount(payment.Total.Add(amount.Decimal))
+		payment.Paid = append(payment.Paid, Allocation{
+			BillID:    bill.ID,
+			Amount:    amount,
+			DocNumber: bill.DocNumber,
+			Balance:   bill.Balance,
+		})
+		lines = append(lines, PaymentLine{
+			Amount:    amount,
+			LinkedTxn: []LinkedTxn{{TxnID: bill.ID, TxnType: "Bill"}},
+		})
+	}
+
+	txnDate := req.TxnDate
+	if txnDate == "" {
+		txnDate = timezone.Today(ctx).Format("2006-01-02")
+	}
+	record := &BillPayment{
+		DocNumber:   req.DocNumber,
+		TxnDate:     txnDate,
+		VendorRef:   &Ref{Value: req.VendorID},
+		PayType:     req.PayType,
+		PrivateNote: strings.TrimSpace(req.Memo),
+		CurrencyRef: bills[0].CurrencyRef,
+		Line:        lines,
+		TotalAmt:    payment.Total,
+	}
+	if req.PayType == PayTypeCheck {
+		printStatus := "NotSet"
+		if req.PrintCheck {
+			printStatus = "NeedToPrint"
+		}
+		record.CheckPayment = &CheckPayment{BankAccountRef: &Ref{Value: req.AccountID}, PrintStatus: printStatus}
+	} else {
+		record.CreditCardPayment = &CreditCardPayment{CCAccountRef: &Ref{Value: req.AccountID}}
+	}
+
+	var created struct {
+		BillPayment BillPayment `json:"BillPayment"`
+	}
+	if err := s.qb.Create(ctx, "BillPayment", record, &created); err != nil {
+		return nil, fmt.Errorf("failed to record bill payment: %w", err)
+	}
+	payment.BillPayment = &created.BillPayment
+	return payment, nil
+}
+
+// notFound maps QuickBooks' "Object Not Found" fault (code 610) to
+// ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,236 @@
+// billpay/checkpdf.go
+package billpay
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/pdf"
+)
+
+// Check stock layouts, all on US Letter
+const (
+	LayoutVoucherTop    = "voucher-top"    // check above two stubs
+	LayoutVoucherMiddle = "voucher-middle" // check between two stubs
+	LayoutVoucherBottom = "voucher-bottom" // check below two stubs
+	LayoutStandard      = "standard"       // three checks a page, no stubs
+)
+
+// Layouts lists the supported check stock layouts
+var Layouts = []string{LayoutVoucherTop, LayoutVoucherMiddle, LayoutVoucherBottom, LayoutStandard}
+
+// Check stock geometry in points. Checks are 3.5" tall; voucher pages are
+// split into thirds of 3 2/3".
+const (
+	checkHeight   = 252
+	voucherPart   = 264
+	stubMargin    = 36
+	stubRows      = 12
+	stubRowHeight = 12
+)
+
+// Field positions on the face of a check, from its top-left corner, where
+// common check stock leaves room for them
+const (
+	dateX       = 460
+	dateY       = 62
+	payeeX      = 72
+	payeeY      = 100
+	amountRight = 576
+	wordsX      = 30
+	wordsY      = 124
+	wordsWidth  = 440
+	addressY    = 148
+	memoX       = 48
+	memoY       = 214
+)
+
+// PrintedCheck is one check laid out on check stock
+type PrintedCheck struct {
+	Number  int64
+	Date    string // MM/DD/YYYY
+	Payee   string
+	Address []string
+	Amount  float64
+	Memo    string
+	// Stubs lists the bills paid, shown on voucher stubs
+	Stubs []StubLine
+}
+
+// StubLine is a bill paid by a check
+type StubLine struct {
+	Reference string
+	Amount    float64
+}
+
+// validLayout reports whether layout is a supported check stock layout
+func validLayout(layout string) bool {
+	for _, supported := range Layouts {
+		if layout == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// renderChecks lays checks out on check stock, shifted by offset
+func renderChecks(title, layout string, offset Offset, checks []PrintedCheck) ([]byte, error) {
+	if !validLayout(layout) {
+		return nil, fmt.Errorf("unsupported check layout %q", layout)
+	}
+	doc := pdf.New(title)
+
+	if layout == LayoutStandard {
+		var page *pdf.Page
+		for i, check := range checks {
+			if i%3 == 0 {
+				page = doc.AddPage(pdf.Letter)
+			}
+			drawCheck(page, offset.X, offset.Y+float64(i%3)*checkHeight, check)
+		}
+		return doc.Bytes()
+	}
+
+	// The check takes one third of a voucher page and the stubs the others
+	checkPart := map[string]int{LayoutVoucherTop: 0, LayoutVoucherMiddle: 1, LayoutVoucherBottom: 2}[layout]
+	for _, check := range checks {
+		page := doc.AddPage(pdf.Letter)
+		for part := 0; part < 3; part++ {
+			top := offset.Y + float64(part)*voucherPart
+			if part == checkPart {
+				drawCheck(page, offset.X, top, check)
+			} else {
+				drawStub(page, offset.X, top, check)
+			}
+		}
+	}
+	return doc.Bytes()
+}
+
+// drawCheck fills in the face of a check whose top-left corner is at x, y.
+// The stock carries the bank details, MICR line and signature line.
+func drawCheck(page *pdf.Page, x, y float64, check PrintedCheck) {
+	page.Text(x+dateX, y+dateY, pdf.Helvetica, 10, pdf.Black, check.Date)
+	page.Text(x+payeeX, y+payeeY, pdf.Helvetica, 10, pdf.Black, check.Payee)
+	page.TextRight(x+amountRight, y+payeeY, pdf.Helvetica, 10, pdf.Black, "**"+formatAmount(check.Amount))
+
+	// Words are padded with asterisks so nothing can be written after them
+	words := amountWords(check.Amount)
+	for pdf.TextWidth(pdf.Helvetica, 10, words+"*") <= wordsWidth {
+		words += "*"
+	}
+	page.Text(x+wordsX, y+wordsY, pdf.Helvetica, 10, pdf.Black, words)
+
+	lineY := y + addressY
+	page.Text(x+payeeX, lineY, pdf.Helvetica, 9, pdf.Black, check.Payee)
+	for _, line := range check.Address {
+		lineY += 11
+		page.Text(x+payeeX, lineY, pdf.Helvetica, 9, pdf.Black, line)
+	}
+	if check.Memo != "" {
+		page.Text(x+memoX, y+memoY, pdf.Helvetica, 9, pdf.Black, check.Memo)
+	}
+}
+
+// drawStub draws a voucher stub listing the bills a check pays
+func drawStub(page *pdf.Page, x, y float64, check PrintedCheck) {
+	left := x + stubMargin
+	right := x + pdf.Letter.Width - stubMargin
+	top := y + stubMargin
+
+	page.Text(left, top, pdf.HelveticaBold, 10, pdf.Black, check.Payee)
+	page.TextRight(right, top, pdf.HelveticaBold, 10, pdf.Black, "Check "+strconv.FormatInt(check.Number, 10))
+	page.TextRight(right-110, top, pdf.Helvetica, 10, pdf.Black, check.Date)
+
+	rowY := top + 22
+	page.Text(left, rowY, pdf.HelveticaBold, 8, pdf.Gray, "BILL")
+	page.TextRight(right, rowY, pdf.HelveticaBold, 8, pdf.Gray, "AMOUNT PAID")
+	page.Line(left, rowY+4, right, rowY+4, 0.5, pdf.Gray)
+
+	lines := check.Stubs
+	if len(lines) > stubRows {
+		// The rest of a long remittance is summed on the last row
+		var rest float64
+		for _, line := range lines[stubRows-1:] {
+			rest += line.Amount
+		}
+		lines = append(append([]StubLine(nil), lines[:stubRows-1]...), StubLine{
+			Reference: fmt.Sprintf("%d more bills", len(check.Stubs)-stubRows+1),
+			Amount:    rest,
+		})
+	}
+	for _, line := range lines {
+		rowY += stubRowHeight
+		page.Text(left, rowY, pdf.Helvetica, 9, pdf.Black, line.Reference)
+		page.TextRight(right, rowY, pdf.Helvetica, 9, pdf.Black, formatAmount(line.Amount))
+	}
+
+	rowY += stubRowHeight + 4
+	page.Line(left, rowY-stubRowHeight+2, right, rowY-stubRowHeight+2, 0.5, pdf.Gray)
+	page.TextRight(right-110, rowY, pdf.HelveticaBold, 9, pdf.Black, "Total")
+	page.TextRight(right, rowY, pdf.HelveticaBold, 9, pdf.Black, formatAmount(check.Amount))
+	if check.Memo != "" {
+		page.Text(left, rowY, pdf.Helvetica, 9, pdf.Gray, check.Memo)
+	}
+}
+
+// formatAmount formats an amount with thousands separators, e.g. 1,234.56
+func formatAmount(amount float64) string {
+	c := cents(amount)
+	digits := strconv.FormatInt(c/100, 10)
+	var grouped string
+	for len(digits) > 3 {
+		grouped = "," + digits[len(digits)-3:] + grouped
+		digits = digits[:len(digits)-3]
+	}
+	return fmt.Sprintf("%s%s.%02d", digits, grouped, c%100)
+}
+
+var (
+	ones = []string{"Zero", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten",
+		"Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen"}
+	tens   = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+	scales = []string{"", "Thousand", "Million", "Billion", "Trillion"}
+)
+
+// amountWords spells out an amount the way checks do, e.g. "One Thousand
+// Two Hundred Thirty-Four and 56/100"
+func amountWords(amount float64) string {
+	whole := cents(amount) / 100
+	fraction := fmt.Sprintf(" and %02d/100", cents(amount)%100)
+	if whole == 0 {
+		return ones[0] + fraction
+	}
+
+	var groups []string
+	for scale := 0; whole > 0; scale++ {
+		if group := whole % 1000; group > 0 {
+			words := hundreds(group)
+			if scales[scale] != "" {
+				words += " " + scales[scale]
+			}
+			groups = append([]string{words}, groups...)
+		}
+		whole /= 1000
+	}
+	return strings.Join(groups, " ") + fraction
+}
+
+// hundreds spells out a number from 1 to 999
+func hundreds(n int64) string {
+	var words []string
+	if n >= 100 {
+		words = append(words, ones[n/100], "Hundred")
+		n %= 100
+	}
+	switch {
+	case n >= 20 && n%10 != 0:
+		words = append(words, tens[n/10]+"-"+ones[n%10])
+	case n >= 20:
+		words = append(words, tens[n/10])
+	case n > 0:
+		words = append(words, ones[n])
+	}
+	return strings.Join(words, " ")
+}
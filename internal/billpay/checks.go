@@ -0,0 +1,381 @@
+// billpay/checks.go
+package billpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+)
+
+// defaultFirstCheck is the first check number of an account whose next
+// number was never set
+const defaultFirstCheck = 1001
+
+// ErrNoChecks is returned when there are no checks to print
+var ErrNoChecks = errors.New("no checks to print")
+
+// PrintRequest selects the checks to print
+type PrintRequest struct {
+	// BatchID limits printing to one payment run
+	BatchID string `json:"batch_id,omitempty"`
+	// BankAccountID limits printing to one account's checks
+	BankAccountID string `json:"bank_account_id,omitempty"`
+	// Reprint prints a batch's checks again under the numbers they were
+	// given, e.g. after a paper jam; it requires BatchID
+	Reprint bool `json:"reprint,omitempty"`
+	// Layout overrides the company's check stock layout
+	Layout string `json:"layout,omitempty"`
+}
+
+// PrintResult is a rendered print run
+type PrintResult struct {
+	PDF []byte
+	// Numbers maps each bank account to the first and last check numbers
+	// printed from it
+	Numbers map[string][2]int64
+	// Warnings lists QuickBooks updates that failed; the checks were
+	// numbered regardless
+	Warnings []string
+}
+
+// checkNumberKey holds the last check number used on a bank account
+func (s *Store) checkNumberKey(realmID, bankAccountID string) string {
+	return fmt.Sprintf("%s:billpay:checkno:%s:%s", s.prefix, realmID, bankAccountID)
+}
+
+// NextCheckNumber returns the number the next check from a bank account
+// will be given
+func (s *Store) NextCheckNumber(ctx context.Context, realmID, bankAccountID string) (int64, error) {
+	last, err := s.client.Get(ctx, s.checkNumberKey(realmID, bankAccountID)).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return defaultFirstCheck, nil
+		}
+		return 0, fmt.Errorf("failed to get check number: %w", err)
+	}
+	return last + 1, nil
+}
+
+// SetNextCheckNumber sets the number of the next check from a bank
+// account, e.g. to the first number of a new box of check stock
+func (s *Store) SetNextCheckNumber(ctx context.Context, realmID, bankAccountID string, next int64) error {
+	if next < 1 {
+		return fmt.Errorf("check numbers must be positive")
+	}
+	if err := s.client.Set(ctx, s.checkNumberKey(realmID, bankAccountID), next-1, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set check number: %w", err)
+	}
+	return nil
+}
+
+// ReserveCheckNumbers takes the next n check numbers of a bank account
+// and returns the first. Numbers are never handed out twice, even when
+// printing fails afterwards.
+func (s *Store) ReserveCheckNumbers(ctx context.Context, realmID, bankAccountID string, n int) (int64, error) {
+	key := s.checkNumberKey(realmID, bankAccountID)
+	if err := s.client.SetNX(ctx, key, defaultFirstCheck-1, 0).Err(); err != nil {
+		return 0, fmt.Errorf("failed to reserve check numbers: %w", err)
+	}
+	last, err := s.client.IncrBy(ctx, key, int64(n)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve check numbers: %w", err)
+	}
+	return last - int64(n) + 1, nil
+}
+
+// queuedCheck is a check payment of a batch awaiting printing
+type queuedCheck struct {
+	batch *Batch
+	index int
+}
+
+// payment returns the queued check's bill payment
+func (q queuedCheck) payment() *BillPayment {
+	return &q.batch.BillPayments[q.index]
+}
+
+// PrintChecks renders the check payments not yet printed onto check
+// stock, numbering them in sequence per bank account. Printed checks are
+// marked as such in QuickBooks and given their numbers there.
+func (s *Service) PrintChecks(ctx context.Context, realmID string, req PrintRequest) (*PrintResult, error) {
+	if req.Reprint && req.BatchID == "" {
+		return nil, fmt.Errorf("reprinting requires a batch_id")
+	}
+	settings, err := s.store.GetSettings(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	layout := req.Layout
+	if layout == "" {
+		layout = settings.CheckLayout
+	}
+	if layout == "" {
+		layout = LayoutVoucherTop
+	}
+	if !validLayout(layout) {
+		return nil, fmt.Errorf("layout must be one of %s", strings.Join(Layouts, ", "))
+	}
+
+	unlock, err := s.locker.Lock(ctx, "billpay:"+realmID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var batches []*Batch
+	if req.BatchID != "" {
+		batch, err := s.store.GetBatch(ctx, realmID, req.BatchID)
+		if err != nil {
+			return nil, err
+		}
+		batches = []*Batch{batch}
+	} else if batches, err = s.store.ListBatches(ctx, realmID); err != nil {
+		return nil, err
+	}
+
+	var queue []queuedCheck
+	for _, batch := range batches {
+		for i, recorded := range batch.BillPayments {
+			if recorded.Method != MethodCheck || (req.BankAccountID != "" && recorded.BankAccountID != req.BankAccountID) {
+				continue
+			}
+			if (recorded.CheckNumber == 0) != req.Reprint {
+				queue = append(queue, queuedCheck{batch, i})
+			}
+		}
+	}
+	if len(queue) == 0 {
+		return nil, ErrNoChecks
+	}
+	// Checks are numbered oldest run first, then by payee
+	sort.SliceStable(queue, func(i, j int) bool {
+		a, b := queue[i], queue[j]
+		if a.batch.PayDate != b.batch.PayDate {
+			return a.batch.PayDate < b.batch.PayDate
+		}
+		if a.payment().BankAccountID != b.payment().BankAccountID {
+			return a.payment().BankAccountID < b.payment().BankAccountID
+		}
+		return strings.ToLower(a.payment().VendorName) < strings.ToLower(b.payment().VendorName)
+	})
+
+	result := &PrintResult{Numbers: make(map[string][2]int64)}
+	if !req.Reprint {
+		if err := s.numberChecks(ctx, realmID, queue, result); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, q := range queue {
+			p := q.payment()
+			numbers, ok := result.Numbers[p.BankAccountID]
+			if !ok || p.CheckNumber < numbers[0] {
+				numbers[0] = p.CheckNumber
+			}
+			if p.CheckNumber > numbers[1] {
+				numbers[1] = p.CheckNumber
+			}
+			result.Numbers[p.BankAccountID] = numbers
+		}
+	}
+
+	checks, err := s.printedChecks(ctx, realmID, queue)
+	if err != nil {
+		return nil, err
+	}
+	result.PDF, err = renderChecks("Checks", layout, settings.CheckOffset, checks)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Reprint {
+		changed := make(map[*Batch]bool)
+		for _, q := range queue {
+			if err := s.markPrinted(ctx, q.payment()); err != nil {
+				logging.FromContext(ctx).Warn("failed to mark bill payment printed", "bill_payment_id", q.payment().ID, "realm_id", realmID, "error", err)
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Check %d (bill payment %s) was not updated in QuickBooks: %v", q.payment().CheckNumber, q.payment().ID, err))
+				continue
+			}
+			changed[q.batch] = true
+		}
+		for batch := range changed {
+			if err := s.store.SaveBatch(ctx, batch); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// numberChecks reserves check numbers for the queued checks per bank
+// account and saves them to their batches before anything is printed
+func (s *Service) numberChecks(ctx context.Context, realmID string, queue []queuedCheck, result *PrintResult) error {
+	perAccount := make(map[string]int)
+	for _, q := range queue {
+		perAccount[q.payment().BankAccountID]++
+	}
+	next := make(map[string]int64)
+	for account, n := range perAccount {
+		first, err := s.store.ReserveCheckNumbers(ctx, realmID, account, n)
+		if err != nil {
+			return err
+		}
+		next[account] = first
+		result.Numbers[account] = [2]int64{first, first + int64(n) - 1}
+	}
+
+	now := time.Now()
+	changed := make(map[*Batch]bool)
+	for _, q := range queue {
+		p := q.payment()
+		p.CheckNumber = next[p.BankAccountID]
+		p.PrintedAt = &now
+		next[p.BankAccountID]++
+		changed[q.batch] = true
+	}
+	for batch := range changed {
+		if err := s.store.SaveBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vendorAddress is the subset of vendor fields printed on checks
+type vendorAddress struct {
+	ID               string `json:"Id"`
+	DisplayName      string `json:"DisplayName"`
+	PrintOnCheckName string `json:"PrintOnCheckName"`
+	BillAddr         *struct {
+		Line1                  string `json:"Line1"`
+		Line2                  string `json:"Line2"`
+		Line3                  string `json:"Line3"`
+		City                   string `json:"City"`
+		CountrySubDivisionCode string `json:"CountrySubDivisionCode"`
+		PostalCode             string `json:"PostalCode"`
+	} `json:"BillAddr"`
+}
+
+// printedChecks lays out the queued checks with their payees' names and
+// addresses and the bills they pay
+func (s *Service) printedChecks(ctx context.Context, realmID string, queue []queuedCheck) ([]PrintedCheck, error) {
+	var ids []interface{}
+	seen := make(map[string]bool)
+	for _, q := range queue {
+		if id := q.payment().VendorID; query.IsID(id) && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	found, err := query.All[vendorAddress](ctx, s.qb, query.Select("Vendor").WhereIn("Id", ids...).WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	vendors := make(map[string]vendorAddress, len(found))
+	for _, v := range found {
+		vendors[v.ID] = v
+	}
+
+	payments, err := s.store.ListPayments(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Payment, len(payments))
+	for _, p := range payments {
+		byID[p.ID] = p
+	}
+
+	checks := make([]PrintedCheck, 0, len(queue))
+	for _, q := range queue {
+		recorded := q.payment()
+		check := PrintedCheck{
+			Number: recorded.CheckNumber,
+			Payee:  recorded.VendorName,
+			Amount: recorded.Amount,
+		}
+		if date, err := time.Parse("2006-01-02", q.batch.PayDate); err == nil {
+			check.Date = date.Format("01/02/2006")
+		}
+		if vendor, ok := vendors[recorded.VendorID]; ok {
+			if vendor.PrintOnCheckName != "" {
+				check.Payee = vendor.PrintOnCheckName
+			}
+			if addr := vendor.BillAddr; addr != nil {
+				for _, line := range []string{addr.Line1, addr.Line2, addr.Line3} {
+					if line != "" {
+						check.Address = append(check.Address, line)
+					}
+				}
+				var place []string
+				for _, part := range []string{addr.City, addr.CountrySubDivisionCode} {
+					if part != "" {
+						place = append(place, part)
+					}
+				}
+				if cityLine := strings.TrimSpace(strings.Join(place, ", ") + " " + addr.PostalCode); cityLine != "" {
+					check.Address = append(check.Address, cityLine)
+				}
+			}
+		}
+
+		var memos []string
+		for _, id := range recorded.PaymentIDs {
+			p, ok := byID[id]
+			if !ok {
+				continue
+			}
+			reference := p.DocNumber
+			if reference == "" {
+				reference = "Bill " + p.BillID
+			}
+			check.Stubs = append(check.Stubs, StubLine{Reference: reference, Amount: p.Amount})
+			if p.Memo != "" {
+				memos = append(memos, p.Memo)
+			}
+		}
+		check.Memo = strings.Join(memos, "; ")
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// markPrinted gives a printed check's bill payment its number in QuickBooks
+func (s *Service) markPrinted(ctx context.Context, recorded *BillPayment) error {
+	if !query.IsID(recorded.ID) {
+		return fmt.Errorf("invalid bill payment ID %q", recorded.ID)
+	}
+	var updated struct {
+		BillPayment struct {
+			DocNumber string `json:"DocNumber"`
+		} `json:"BillPayment"`
+	}
+	err := s.qb.Modify(ctx, "BillPayment", recorded.ID, func(current map[string]json.RawMessage) (map[string]interface{}, error) {
+		var checkPayment map[string]interface{}
+		if err := json.Unmarshal(current["CheckPayment"], &checkPayment); err != nil || checkPayment == nil {
+			return nil, fmt.Errorf("bill payment %s is not a check", recorded.ID)
+		}
+		checkPayment["PrintStatus"] = "PrintComplete"
+		return map[string]interface{}{
+			"sparse":       true,
+			"DocNumber":    strconv.FormatInt(recorded.CheckNumber, 10),
+			"PayType":      "Check",
+			"VendorRef":    current["VendorRef"],
+			"TotalAmt":     current["TotalAmt"],
+			"CheckPayment": checkPayment,
+		}, nil
+	}, &updated)
+	if err != nil {
+		return err
+	}
+	recorded.DocNumber = updated.BillPayment.DocNumber
+	return nil
+}
@@ -0,0 +1,146 @@
+// billpay/export.go
+package billpay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// ErrNoOriginator is returned when exporting before the company's bank
+// details are set up
+var ErrNoOriginator = errors.New("the company's bank details are not set up for bill pay exports")
+
+// originator returns the company's bank details
+func (s *Service) originator(ctx context.Context, realmID string) (*Originator, error) {
+	settings, err := s.store.GetSettings(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Originator == nil {
+		return nil, ErrNoOriginator
+	}
+	return settings.Originator, nil
+}
+
+// NACHA returns a NACHA file crediting the vendors of a batch's ACH
+// payments. Vendors' bank details are read at export time.
+func (s *Service) NACHA(ctx context.Context, realmID, batchID string) ([]byte, error) {
+	batch, err := s.store.GetBatch(ctx, realmID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	originator, err := s.originator(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, recorded := range batch.BillPayments {
+		if recorded.Method != MethodACH {
+			continue
+		}
+		if recorded.Currency != "" && recorded.Currency != "USD" {
+			return nil, fmt.Errorf("payment %s to %s is in %s; ACH files carry US dollars only", recorded.ID, recorded.VendorName, recorded.Currency)
+		}
+		payee, err := s.store.GetPayee(ctx, realmID, recorded.VendorID)
+		if err != nil {
+			return nil, fmt.Errorf("vendor %s: %w", recorded.VendorName, err)
+		}
+		name := payee.Name
+		if name == "" {
+			name = recorded.VendorName
+		}
+		entries = append(entries, Entry{
+			Payee:     *payee,
+			Name:      name,
+			Amount:    recorded.Amount,
+			Reference: recorded.ID,
+		})
+	}
+
+	effective, err := time.Parse("2006-01-02", batch.PayDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch pay date: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteNACHA(&buf, originator, effective, entries, time.Now()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PositivePay returns a positive pay file of a batch's checks. Check
+// numbers are read back from QuickBooks, since checks may also be printed
+// there; unprinted checks are left out.
+func (s *Service) PositivePay(ctx context.Context, realmID, batchID string) ([]byte, error) {
+	batch, err := s.store.GetBatch(ctx, realmID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	originator, err := s.originator(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []interface{}
+	numbers := make(map[string]int64)
+	for _, recorded := range batch.BillPayments {
+		if recorded.Method == MethodCheck && query.IsID(recorded.ID) {
+			ids = append(ids, recorded.ID)
+			numbers[recorded.ID] = recorded.CheckNumber
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no check payments to export")
+	}
+
+	payments, err := query.All[struct {
+		ID        string  `json:"Id"`
+		DocNumber string  `json:"DocNumber"`
+		TxnDate   string  `json:"TxnDate"`
+		TotalAmt  float64 `json:"TotalAmt"`
+		VendorRef struct {
+			Name string `json:"name"`
+		} `json:"VendorRef"`
+	}](ctx, s.qb, query.Select("BillPayment").WhereIn("Id", ids...))
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []Check
+	for _, payment := range payments {
+		if payment.DocNumber == "" || strings.EqualFold(payment.DocNumber, "To Print") {
+			if numbers[payment.ID] == 0 {
+				continue
+			}
+			// Printed here, though QuickBooks missed the number
+			payment.DocNumber = strconv.FormatInt(numbers[payment.ID], 10)
+		}
+		date, err := time.Parse("2006-01-02", payment.TxnDate)
+		if err != nil {
+			date, _ = time.Parse("2006-01-02", batch.PayDate)
+		}
+		checks = append(checks, Check{
+			Number: payment.DocNumber,
+			Date:   date,
+			Amount: payment.TotalAmt,
+			Payee:  payment.VendorRef.Name,
+		})
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("none of the batch's checks have been printed yet")
+	}
+
+	var buf bytes.Buffer
+	if err := WritePositivePay(&buf, originator, checks); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
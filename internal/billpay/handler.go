@@ -0,0 +1,439 @@
+// billpay/handler.go
+package billpay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for the bill payment queue
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new bill pay handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// writeError maps bill pay errors to HTTP responses
+func writeError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, ErrPaymentNotFound), errors.Is(err, ErrBatchNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNotScheduled), errors.Is(err, ErrNoChecks):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrNoOriginator), errors.Is(err, ErrPayeeNotFound):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, "Failed to "+action+": "+err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ListPayments returns the company's scheduled and past payments,
+// optionally filtered by ?status=
+func (h *Handler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	payments, err := h.service.Store().ListPayments(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list payments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	list := make([]*Payment, 0, len(payments))
+	for _, p := range payments {
+		if status == "" || p.Status == status {
+			list = append(list, p)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"payments": list,
+	})
+}
+
+// SchedulePayment marks a bill for payment on a date
+func (h *Handler) SchedulePayment(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.service.Schedule(r.Context(), realmID, auth.GetUserID(r.Context()), req)
+	if err != nil {
+		writeError(w, "schedule payment", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// CancelPayment takes a scheduled payment out of the queue
+func (h *Handler) CancelPayment(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	payment, err := h.service.Cancel(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "cancel payment", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// RunPayments records the payments due today now rather than at the
+// company's scheduled hour
+func (h *Handler) RunPayments(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	batch, err := h.service.Run(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to run payments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if batch == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// ListBatches returns the company's payment runs, newest first
+func (h *Handler) ListBatches(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	batches, err := h.service.Store().ListBatches(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list batches: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batches": batches,
+	})
+}
+
+// GetBatch returns a payment run
+func (h *Handler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	batch, err := h.service.Store().GetBatch(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "get batch", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// ExportNACHA downloads a NACHA file of a batch's ACH payments for upload
+// to the company's bank
+func (h *Handler) ExportNACHA(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	file, err := h.service.NACHA(r.Context(), realmID, id)
+	if err != nil {
+		writeError(w, "export NACHA file", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=us-ascii")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="billpay-%s.ach"`, id))
+	w.WriteHeader(http.StatusOK)
+	w.Write(file)
+}
+
+// ExportPositivePay downloads a positive pay file of a batch's printed checks
+func (h *Handler) ExportPositivePay(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	file, err := h.service.PositivePay(r.Context(), realmID, id)
+	if err != nil {
+		writeError(w, "export positive pay file", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="positive-pay-%s.csv"`, id))
+	w.WriteHeader(http.StatusOK)
+	w.Write(file)
+}
+
+// GetSettings returns the company's bill pay settings with its bank
+// account number masked
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := h.service.Store().GetSettings(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to get bill pay settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings.Masked())
+}
+
+// SaveSettings replaces the company's bill pay settings
+func (h *Handler) SaveSettings(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	settings := Settings{Hour: defaultHour}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SaveSettings(r.Context(), realmID, auth.GetUserID(r.Context()), &settings); err != nil {
+		writeError(w, "save bill pay settings", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings.Masked())
+}
+
+// GetPayee returns a vendor's ACH bank details with the account number masked
+func (h *Handler) GetPayee(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	payee, err := h.service.Store().GetPayee(r.Context(), realmID, mux.Vars(r)["vendorId"])
+	if err != nil {
+		if errors.Is(err, ErrPayeeNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get payee: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payee.Masked())
+}
+
+// SavePayee stores a vendor's bank details for ACH payments
+func (h *Handler) SavePayee(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var payee Payee
+	if err := json.NewDecoder(r.Body).Decode(&payee); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	payee.VendorID = mux.Vars(r)["vendorId"]
+	if !query.IsID(payee.VendorID) {
+		http.Error(w, "Invalid vendor ID", http.StatusBadRequest)
+		return
+	}
+	if err := payee.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Store().SavePayee(r.Context(), realmID, &payee); err != nil {
+		http.Error(w, "Failed to save payee: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payee.Masked())
+}
+
+// DeletePayee removes a vendor's bank details
+func (h *Handler) DeletePayee(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Store().DeletePayee(r.Context(), realmID, mux.Vars(r)["vendorId"]); err != nil {
+		if errors.Is(err, ErrPayeeNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete payee: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PrintChecks renders the check payments not yet printed as a PDF for
+// check stock and numbers them. The numbers used per bank account are
+// returned in the X-Check-Numbers header.
+func (h *Handler) PrintChecks(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req PrintRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.service.PrintChecks(r.Context(), realmID, req)
+	if err != nil {
+		writeError(w, "print checks", err)
+		return
+	}
+
+	var numbers []string
+	for account, span := range result.Numbers {
+		numbers = append(numbers, fmt.Sprintf("%s:%d-%d", account, span[0], span[1]))
+	}
+	sort.Strings(numbers)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="checks.pdf"`)
+	w.Header().Set("X-Check-Numbers", strings.Join(numbers, ","))
+	if len(result.Warnings) > 0 {
+		w.Header().Set("X-Print-Warnings", strings.ReplaceAll(strings.Join(result.Warnings, "; "), "\n", " "))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(result.PDF)
+}
+
+// GetCheckNumber returns the number the next check from a bank account
+// will be given
+func (h *Handler) GetCheckNumber(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	account := mux.Vars(r)["accountId"]
+	next, err := h.service.Store().NextCheckNumber(r.Context(), realmID, account)
+	if err != nil {
+		http.Error(w, "Failed to get check number: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bank_account_id": account,
+		"next":            next,
+	})
+}
+
+// SetCheckNumber sets the number the next check from a bank account will
+// be given, e.g. when loading a new box of check stock
+func (h *Handler) SetCheckNumber(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	account := mux.Vars(r)["accountId"]
+	if !query.IsID(account) {
+		http.Error(w, "Invalid bank account ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Next int64 `json:"next"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Store().SetNextCheckNumber(r.Context(), realmID, account, req.Next); err != nil {
+		http.Error(w, "Failed to set check number: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bank_account_id": account,
+		"next":            req.Next,
+	})
+}
@@ -0,0 +1,197 @@
+// billpay/nacha.go
+package billpay
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NACHA record layout
+const (
+	recordSize     = 94
+	blockingFactor = 10
+	// serviceClassCredits marks batches of credits only; vendor payments
+	// are not offset by a debit to the company's account, which the bank
+	// posts itself
+	serviceClassCredits = "220"
+	secCorporate        = "CCD"
+	entryDescription    = "VENDOR PAY"
+)
+
+// Entry is an ACH credit to a payee
+type Entry struct {
+	Payee  Payee
+	Name   string
+	Amount float64
+	// Reference identifies the payment to the payee, e.g. the bill payment
+	// number
+	Reference string
+}
+
+// cents converts an amount to whole cents
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// alpha formats an alphanumeric field: upper case, left justified and
+// space padded, with characters outside printable ASCII dropped
+func alpha(s string, width int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(s))
+	if len(s) > width {
+		s = s[:width]
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+// numeric formats a numeric field, right justified and zero padded
+func numeric(n int64, width int) string {
+	s := strconv.FormatInt(n, 10)
+	if len(s) > width {
+		s = s[len(s)-width:]
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// WriteNACHA writes a NACHA file of CCD credits paying the entries from
+// the originator's account on the effective date
+func WriteNACHA(out io.Writer, originator *Originator, effective time.Time, entries []Entry, created time.Time) error {
+	if err := originator.Validate(); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no ACH payments to export")
+	}
+	origin := originator.ImmediateOrigin
+	if origin == "" {
+		origin = originator.CompanyID
+	}
+	odfi := originator.BankRoutingNumber[:8]
+
+	var records []string
+	records = append(records, "1"+"01"+
+		" "+originator.BankRoutingNumber+
+		fmt.Sprintf("%10s", origin)+
+		created.Format("060102")+created.Format("1504")+
+		"A"+"094"+"10"+"1"+
+		alpha(originator.BankName, 23)+
+		alpha(originator.CompanyName, 23)+
+		alpha("", 8))
+
+	records = append(records, "5"+serviceClassCredits+
+		alpha(originator.CompanyName, 16)+
+		alpha("", 20)+
+		alpha(originator.CompanyID, 10)+
+		secCorporate+
+		alpha(entryDescription, 10)+
+		effective.Format("060102")+
+		effective.Format("060102")+
+		"   "+"1"+
+		odfi+
+		numeric(1, 7))
+
+	var hash, credits int64
+	for i, entry := range entries {
+		if err := entry.Payee.Validate(); err != nil {
+			return fmt.Errorf("payee %s: %w", entry.Name, err)
+		}
+		amount := cents(entry.Amount)
+		if amount <= 0 {
+			return fmt.Errorf("payment to %s must be positive", entry.Name)
+		}
+		transactionCode := "22"
+		if entry.Payee.AccountType == AccountSavings {
+			transactionCode = "32"
+		}
+		rdfi, _ := strconv.ParseInt(entry.Payee.RoutingNumber[:8], 10, 64)
+		hash += rdfi
+		credits += amount
+
+		records = append(records, "6"+transactionCode+
+			entry.Payee.RoutingNumber+
+			alpha(entry.Payee.AccountNumber, 17)+
+			numeric(amount, 10)+
+			alpha(entry.Reference, 15)+
+			alpha(entry.Name, 22)+
+			"  "+"0"+
+			odfi+numeric(int64(i+1), 7))
+	}
+
+	records = append(records, "8"+serviceClassCredits+
+		numeric(int64(len(entries)), 6)+
+		numeric(hash, 10)+
+		numeric(0, 12)+
+		numeric(credits, 12)+
+		alpha(originator.CompanyID, 10)+
+		alpha("", 19)+
+		alpha("", 6)+
+		odfi+
+		numeric(1, 7))
+
+	// The file is padded with all-9 records to whole blocks of ten
+	blocks := (len(records) + 1 + blockingFactor - 1) / blockingFactor
+	records = append(records, "9"+
+		numeric(1, 6)+
+		numeric(int64(blocks), 6)+
+		numeric(int64(len(entries)), 8)+
+		numeric(hash, 10)+
+		numeric(0, 12)+
+		numeric(credits, 12)+
+		alpha("", 39))
+	for len(records)%blockingFactor != 0 {
+		records = append(records, strings.Repeat("9", recordSize))
+	}
+
+	w := bufio.NewWriter(out)
+	for _, record := range records {
+		if len(record) != recordSize {
+			return fmt.Errorf("malformed NACHA record %q", record)
+		}
+		w.WriteString(record + "\n")
+	}
+	return w.Flush()
+}
+
+// Check is an issued check reported to the bank for positive pay
+type Check struct {
+	Number string
+	Date   time.Time
+	Amount float64
+	Payee  string
+}
+
+// WritePositivePay writes the checks issued on the originator's account
+// as a positive pay CSV, the layout most banks accept for upload
+func WritePositivePay(out io.Writer, originator *Originator, checks []Check) error {
+	if originator.AccountNumber == "" {
+		return fmt.Errorf("the originator's account_number is required for positive pay")
+	}
+	if len(checks) == 0 {
+		return fmt.Errorf("no check payments to export")
+	}
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"Account Number", "Check Number", "Issue Date", "Amount", "Payee", "Void"})
+	for _, check := range checks {
+		w.Write([]string{
+			originator.AccountNumber,
+			check.Number,
+			check.Date.Format("01/02/2006"),
+			strconv.FormatFloat(float64(cents(check.Amount))/100, 'f', 2, 64),
+			check.Payee,
+			"N",
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,227 @@
+// billpay/payment.go
+package billpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Payment methods
+const (
+	MethodCheck = "check" // printed and mailed by the company
+	MethodACH   = "ach"   // sent through the company's bank in a NACHA file
+)
+
+// Scheduled payment statuses
+const (
+	StatusScheduled  = "scheduled"
+	StatusProcessing = "processing" // being recorded; left here if recording was interrupted
+	StatusPaid       = "paid"
+	StatusFailed     = "failed"
+	StatusCancelled  = "cancelled"
+)
+
+var (
+	// ErrPaymentNotFound is returned when a scheduled payment does not exist
+	ErrPaymentNotFound = errors.New("scheduled payment not found")
+	// ErrBatchNotFound is returned when a payment batch does not exist
+	ErrBatchNotFound = errors.New("payment batch not found")
+	// ErrNotScheduled is returned when changing a payment that already ran
+	ErrNotScheduled = errors.New("payment is no longer scheduled")
+)
+
+// Payment is a bill marked to be paid on a date
+type Payment struct {
+	ID         string  `json:"id"`
+	RealmID    string  `json:"realm_id"`
+	BillID     string  `json:"bill_id"`
+	DocNumber  string  `json:"doc_number,omitempty"`
+	VendorID   string  `json:"vendor_id"`
+	VendorName string  `json:"vendor_name"`
+	Amount     float64 `json:"amount"`
+	Currency   string  `json:"currency,omitempty"`
+	// PayDate is the date (YYYY-MM-DD) the payment is made on, in the
+	// company's time zone
+	PayDate       string `json:"pay_date"`
+	Method        string `json:"method"`
+	BankAccountID string `json:"bank_account_id"`
+	Memo          string `json:"memo,omitempty"`
+	Status        string `json:"status"`
+	// BatchID and BillPaymentID are set once the payment is recorded
+	BatchID       string     `json:"batch_id,omitempty"`
+	BillPaymentID string     `json:"bill_payment_id,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+}
+
+// BillPayment is a QuickBooks BillPayment recorded by a batch, paying one
+// vendor's bills due the same day from the same account by the same method
+type BillPayment struct {
+	ID            string   `json:"id"`
+	DocNumber     string   `json:"doc_number,omitempty"`
+	VendorID      string   `json:"vendor_id"`
+	VendorName    string   `json:"vendor_name"`
+	BankAccountID string   `json:"bank_account_id"`
+	Method        string   `json:"method"`
+	Amount        float64  `json:"amount"`
+	Currency      string   `json:"currency,omitempty"`
+	PaymentIDs    []string `json:"payment_ids"`
+	BillIDs       []string `json:"bill_ids"`
+	// CheckNumber and PrintedAt are set once a check payment is printed
+	CheckNumber int64      `json:"check_number,omitempty"`
+	PrintedAt   *time.Time `json:"printed_at,omitempty"`
+}
+
+// Batch is one run of the payment queue
+type Batch struct {
+	ID           string        `json:"id"`
+	RealmID      string        `json:"realm_id"`
+	PayDate      string        `json:"pay_date"`
+	BillPayments []BillPayment `json:"bill_payments"`
+	// Failed lists the payments that could not be recorded
+	Failed    []string  `json:"failed,omitempty"`
+	Total     float64   `json:"total"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store persists scheduled payments, batches and bank details per realm
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new bill pay store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// paymentsKey is the hash of payment ID to scheduled payment for a realm
+func (s *Store) paymentsKey(realmID string) string {
+	return fmt.Sprintf("%s:billpay:payments:%s", s.prefix, realmID)
+}
+
+// batchesKey is the hash of batch ID to batch for a realm
+func (s *Store) batchesKey(realmID string) string {
+	return fmt.Sprintf("%s:billpay:batches:%s", s.prefix, realmID)
+}
+
+// SavePayments stores scheduled payments
+func (s *Store) SavePayments(ctx context.Context, realmID string, payments ...*Payment) error {
+	values := make([]interface{}, 0, len(payments)*2)
+	for _, p := range payments {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payment: %w", err)
+		}
+		values = append(values, p.ID, data)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if err := s.client.HSet(ctx, s.paymentsKey(realmID), values...).Err(); err != nil {
+		return fmt.Errorf("failed to save payments: %w", err)
+	}
+	return nil
+}
+
+// GetPayment retrieves a scheduled payment
+func (s *Store) GetPayment(ctx context.Context, realmID, id string) (*Payment, error) {
+	data, err := s.client.HGet(ctx, s.paymentsKey(realmID), id).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	var p Payment
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payment: %w", err)
+	}
+	return &p, nil
+}
+
+// ListPayments returns a realm's payments by pay date
+func (s *Store) ListPayments(ctx context.Context, realmID string) ([]*Payment, error) {
+	values, err := s.client.HGetAll(ctx, s.paymentsKey(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	payments := make([]*Payment, 0, len(values))
+	for _, v := range values {
+		var p Payment
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			continue
+		}
+		payments = append(payments, &p)
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if payments[i].PayDate != payments[j].PayDate {
+			return payments[i].PayDate < payments[j].PayDate
+		}
+		return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+	})
+	return payments, nil
+}
+
+// SaveBatch stores a payment batch
+func (s *Store) SaveBatch(ctx context.Context, batch *Batch) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.batchesKey(batch.RealmID), batch.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+	return nil
+}
+
+// GetBatch retrieves a payment batch
+func (s *Store) GetBatch(ctx context.Context, realmID, id string) (*Batch, error) {
+	data, err := s.client.HGet(ctx, s.batchesKey(realmID), id).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrBatchNotFound
+		}
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	var batch Batch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// ListBatches returns a realm's payment batches, newest first
+func (s *Store) ListBatches(ctx context.Context, realmID string) ([]*Batch, error) {
+	values, err := s.client.HGetAll(ctx, s.batchesKey(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batches: %w", err)
+	}
+
+	batches := make([]*Batch, 0, len(values))
+	for _, v := range values {
+		var batch Batch
+		if err := json.Unmarshal([]byte(v), &batch); err != nil {
+			continue
+		}
+		batches = append(batches, &batch)
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.After(batches[j].CreatedAt)
+	})
+	return batches, nil
+}
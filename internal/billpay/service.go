@@ -0,0 +1,489 @@
+// billpay/service.go
+package billpay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// BatchJobType identifies a company's daily bill payment run in the jobs subsystem
+const BatchJobType = "billpay_batch"
+
+// defaultHour is the local hour due payments are recorded at
+const defaultHour = 6
+
+// QuickBooks is the subset of the QuickBooks client bill pay uses
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error
+}
+
+// Locker serializes payment runs of the same company across instances
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// batchPayload is the job payload of a company's payment run
+type batchPayload struct {
+	RealmID string `json:"realm_id"`
+}
+
+// bill is the subset of bill fields used to schedule and pay it
+type bill struct {
+	ID        string  `json:"Id"`
+	DocNumber string  `json:"DocNumber"`
+	Balance   float64 `json:"Balance"`
+	VendorRef struct {
+		Value string `json:"value"`
+		Name  string `json:"name"`
+	} `json:"VendorRef"`
+	CurrencyRef struct {
+		Value string `json:"value"`
+	} `json:"CurrencyRef"`
+}
+
+// ScheduleRequest marks a bill for payment
+type ScheduleRequest struct {
+	BillID  string `json:"bill_id"`
+	PayDate string `json:"pay_date"`
+	// Amount defaults to the bill's open balance
+	Amount float64 `json:"amount,omitempty"`
+	// Method defaults to check
+	Method string `json:"method,omitempty"`
+	// BankAccountID defaults to the company's bill pay account
+	BankAccountID string `json:"bank_account_id,omitempty"`
+	Memo          string `json:"memo,omitempty"`
+}
+
+// Service schedules bill payments and records them in QuickBooks when due
+type Service struct {
+	store     *Store
+	qb        QuickBooks
+	scheduler *jobs.Scheduler
+	locker    Locker
+}
+
+// NewService creates a new bill pay service and registers its job runner
+func NewService(store *Store, qb QuickBooks, scheduler *jobs.Scheduler, locker Locker) *Service {
+	service := &Service{
+		store:     store,
+		qb:        qb,
+		scheduler: scheduler,
+		locker:    locker,
+	}
+	scheduler.RegisterRunner(BatchJobType, jobs.RunnerFunc(service.runBatch))
+	return service
+}
+
+// Store returns the bill pay store
+func (s *Service) Store() *Store {
+	return s.store
+}
+
+// bills fetches bills by ID
+func (s *Service) bills(ctx context.Context, ids []string) (map[string]bill, error) {
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if !query.IsID(id) {
+			return nil, fmt.Errorf("invalid bill ID %q", id)
+		}
+		values = append(values, id)
+	}
+
+	found, err := query.All[bill](ctx, s.qb, query.Select("Bill").WhereIn("Id", values...))
+	if err != nil {
+		return nil, err
+	}
+	bills := make(map[string]bill, len(found))
+	for _, b := range found {
+		bills[b.ID] = b
+	}
+	return bills, nil
+}
+
+// Schedule marks a bill to be paid on a date. The amounts scheduled for a
+// bill may not exceed its open balance.
+func (s *Service) Schedule(ctx context.Context, realmID, userID string, req ScheduleRequest) (*Payment, error) {
+	payDate, err := time.Parse("2006-01-02", req.PayDate)
+	if err != nil {
+		return nil, fmt.Errorf("pay_date must be a YYYY-MM-DD date")
+	}
+	if payDate.Before(timezone.Today(ctx)) {
+		return nil, fmt.Errorf("pay_date must not be in the past")
+	}
+	if req.Method == "" {
+		req.Method = MethodCheck
+	}
+	if req.Method != MethodCheck && req.Method != MethodACH {
+		return nil, fmt.Errorf("method must be %q or %q", MethodCheck, MethodACH)
+	}
+	if req.Amount < 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	settings, err := s.store.GetSettings(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	if req.BankAccountID == "" {
+		req.BankAccountID = settings.BankAccountID
+	}
+	if !query.IsID(req.BankAccountID) {
+		return nil, fmt.Errorf("bank_account_id is required unless the company has a default")
+	}
+
+	unlock, err := s.locker.Lock(ctx, "billpay:"+realmID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	bills, err := s.bills(ctx, []string{req.BillID})
+	if err != nil {
+		return nil, err
+	}
+	b, ok := bills[req.BillID]
+	if !ok {
+		return nil, fmt.Errorf("bill %s not found", req.BillID)
+	}
+	if req.Method == MethodACH {
+		if _, err := s.store.GetPayee(ctx, realmID, b.VendorRef.Value); err != nil {
+			return nil, fmt.Errorf("vendor %s: %w", b.VendorRef.Name, err)
+		}
+	}
+
+	existing, err := s.store.ListPayments(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	open := b.Balance
+	for _, p := range existing {
+		if p.BillID == b.ID && (p.Status == StatusScheduled || p.Status == StatusProcessing) {
+			open -= p.Amount
+		}
+	}
+	if req.Amount == 0 {
+		req.Amount = open
+	}
+	if req.Amount <= 0 || cents(req.Amount) > cents(open) {
+		return nil, fmt.Errorf("bill %s has %.2f left to schedule", b.ID, math.Max(open, 0))
+	}
+
+	payment := &Payment{
+		ID:            jobs.NewID(),
+		RealmID:       realmID,
+		BillID:        b.ID,
+		DocNumber:     b.DocNumber,
+		VendorID:      b.VendorRef.Value,
+		VendorName:    b.VendorRef.Name,
+		Amount:        req.Amount,
+		Currency:      b.CurrencyRef.Value,
+		PayDate:       req.PayDate,
+		Method:        req.Method,
+		BankAccountID: req.BankAccountID,
+		Memo:          req.Memo,
+		Status:        StatusScheduled,
+		CreatedBy:     userID,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.store.SavePayments(ctx, realmID, payment); err != nil {
+		return nil, err
+	}
+	if err := s.ensureJob(ctx, realmID, settings.Hour, false); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// Cancel takes a payment out of the queue
+func (s *Service) Cancel(ctx context.Context, realmID, id string) (*Payment, error) {
+	unlock, err := s.locker.Lock(ctx, "billpay:"+realmID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := s.store.GetPayment(ctx, realmID, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != StatusScheduled {
+		return nil, ErrNotScheduled
+	}
+	payment.Status = StatusCancelled
+	if err := s.store.SavePayments(ctx, realmID, payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// SaveSettings validates and stores a company's bill pay settings,
+// moving its daily run to the new hour
+func (s *Service) SaveSettings(ctx context.Context, realmID, userID string, settings *Settings) error {
+	if settings.BankAccountID != "" && !query.IsID(settings.BankAccountID) {
+		return fmt.Errorf("invalid bank_account_id")
+	}
+	if settings.Hour < 0 || settings.Hour > 23 {
+		return fmt.Errorf("hour must be between 0 and 23")
+	}
+	if settings.Originator != nil {
+		if err := settings.Originator.Validate(); err != nil {
+			return err
+		}
+	}
+	if settings.CheckLayout != "" && !validLayout(settings.CheckLayout) {
+		return fmt.Errorf("check_layout must be one of %s", strings.Join(Layouts, ", "))
+	}
+	if math.Abs(settings.CheckOffset.X) > 72 || math.Abs(settings.CheckOffset.Y) > 72 {
+		return fmt.Errorf("check_offset may move checks at most 72 points (an inch) each way")
+	}
+	settings.UpdatedBy = userID
+	settings.UpdatedAt = time.Now()
+	if err := s.store.SaveSettings(ctx, realmID, settings); err != nil {
+		return err
+	}
+	return s.ensureJob(ctx, realmID, settings.Hour, true)
+}
+
+// ensureJob makes sure the company's daily payment run is scheduled,
+// rescheduling it at the given hour if reschedule is set
+func (s *Service) ensureJob(ctx context.Context, realmID string, hour int, reschedule bool) error {
+	tenantID := auth.GetTenantID(ctx)
+	existing, err := s.scheduler.Store().ListByTenant(ctx, tenantID, BatchJobType)
+	if err != nil {
+		return err
+	}
+	for _, job := range existing {
+		var payload batchPayload
+		if json.Unmarshal(job.Payload, &payload) != nil || payload.RealmID != realmID || job.Status != jobs.StatusActive {
+			continue
+		}
+		if !reschedule {
+			return nil
+		}
+		if _, err := s.scheduler.Cancel(ctx, job.ID); err != nil {
+			return err
+		}
+	}
+
+	job, err := jobs.NewJob(BatchJobType, tenantID, auth.GetUserID(ctx), batchPayload{RealmID: realmID})
+	if err != nil {
+		return fmt.Errorf("failed to create bill payment job: %w", err)
+	}
+	job.Schedule = (&jobs.Schedule{Frequency: jobs.FrequencyDaily, Hour: hour}).Localize(ctx)
+	job.NextRunAt = job.Schedule.Next(time.Now())
+	return s.scheduler.Store().Save(ctx, job)
+}
+
+// runBatch is the job runner for daily payment runs
+func (s *Service) runBatch(ctx context.Context, job *jobs.Job) error {
+	var payload batchPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode bill payment job: %w", err)
+	}
+
+	ctx = auth.WithIdentity(ctx, job.UserID, job.TenantID, payload.RealmID)
+	batch, err := s.Run(ctx, payload.RealmID)
+	if err != nil {
+		return err
+	}
+	if batch != nil && len(batch.Failed) > 0 {
+		return fmt.Errorf("%d of the payments due could not be recorded", len(batch.Failed))
+	}
+	return nil
+}
+
+// batchKey groups the payments recorded as one BillPayment
+type batchKey struct {
+	vendorID, bankAccountID, method, currency string
+}
+
+// Run records the company's payments due today or earlier as BillPayments,
+// one per vendor, account and method, and returns the batch, or nil if
+// none were due. Payments whose bills no longer have the balance to pay
+// fail rather than overpay.
+func (s *Service) Run(ctx context.Context, realmID string) (*Batch, error) {
+	unlock, err := s.locker.Lock(ctx, "billpay:"+realmID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payments, err := s.store.ListPayments(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	today := timezone.Today(ctx).Format("2006-01-02")
+	var due []*Payment
+	var billIDs []string
+	for _, p := range payments {
+		if p.Status == StatusScheduled && p.PayDate <= today {
+			due = append(due, p)
+			billIDs = append(billIDs, p.BillID)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	bills, err := s.bills(ctx, billIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{
+		ID:        jobs.NewID(),
+		RealmID:   realmID,
+		PayDate:   today,
+		CreatedAt: time.Now(),
+	}
+	fail := func(p *Payment, reason string) {
+		p.Status = StatusFailed
+		p.Error = reason
+		p.BatchID = batch.ID
+		batch.Failed = append(batch.Failed, p.ID)
+	}
+
+	groups := make(map[batchKey][]*Payment)
+	var order []batchKey
+	balances := make(map[string]float64)
+	for id, b := range bills {
+		balances[id] = b.Balance
+	}
+	for _, p := range due {
+		if _, ok := bills[p.BillID]; !ok {
+			fail(p, "bill no longer exists")
+			continue
+		}
+		if cents(balances[p.BillID]) < cents(p.Amount) {
+			fail(p, fmt.Sprintf("bill balance %.2f is less than the scheduled amount", balances[p.BillID]))
+			continue
+		}
+		balances[p.BillID] -= p.Amount
+
+		key := batchKey{p.VendorID, p.BankAccountID, p.Method, p.Currency}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], p)
+	}
+
+	// Payments are marked processing before they are recorded, so one
+	// interrupted between the two is never paid twice
+	for _, p := range due {
+		if p.Status == StatusScheduled {
+			p.Status = StatusProcessing
+			p.BatchID = batch.ID
+		}
+	}
+	if err := s.store.SavePayments(ctx, realmID, due...); err != nil {
+		return nil, err
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		recorded, err := s.record(ctx, today, group)
+		now := time.Now()
+		for _, p := range group {
+			if err != nil {
+				fail(p, err.Error())
+				continue
+			}
+			p.Status = StatusPaid
+			p.BillPaymentID = recorded.ID
+			p.PaidAt = &now
+		}
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to pay vendor bills", "vendor_id", key.vendorID, "realm_id", realmID, "error", err)
+			continue
+		}
+		batch.BillPayments = append(batch.BillPayments, *recorded)
+		batch.Total += recorded.Amount
+	}
+	batch.Total = float64(cents(batch.Total)) / 100
+
+	if err := s.store.SavePayments(ctx, realmID, due...); err != nil {
+		return nil, err
+	}
+	if err := s.store.SaveBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// record creates the BillPayment paying a vendor's due payments
+func (s *Service) record(ctx context.Context, payDate string, group []*Payment) (*BillPayment, error) {
+	first := group[0]
+	recorded := &BillPayment{
+		VendorID:      first.VendorID,
+		VendorName:    first.VendorName,
+		BankAccountID: first.BankAccountID,
+		Method:        first.Method,
+		Currency:      first.Currency,
+	}
+	var lines []map[string]interface{}
+	var memos []string
+	var total int64
+	for _, p := range group {
+		lines = append(lines, map[string]interface{}{
+			"Amount": p.Amount,
+			"LinkedTxn": []map[string]string{
+				{"TxnId": p.BillID, "TxnType": "Bill"},
+			},
+		})
+		if p.Memo != "" {
+			memos = append(memos, p.Memo)
+		}
+		total += cents(p.Amount)
+		recorded.PaymentIDs = append(recorded.PaymentIDs, p.ID)
+		recorded.BillIDs = append(recorded.BillIDs, p.BillID)
+	}
+	recorded.Amount = float64(total) / 100
+
+	// ACH payments are bank transfers QuickBooks records as checks that
+	// are never printed
+	printStatus := "NeedToPrint"
+	if first.Method == MethodACH {
+		printStatus = "NotSet"
+	}
+	payload := map[string]interface{}{
+		"VendorRef": map[string]string{"value": first.VendorID},
+		"PayType":   "Check",
+		"CheckPayment": map[string]interface{}{
+			"BankAccountRef": map[string]string{"value": first.BankAccountID},
+			"PrintStatus":    printStatus,
+		},
+		"TotalAmt": recorded.Amount,
+		"TxnDate":  payDate,
+		"Line":     lines,
+	}
+	if first.Method == MethodACH {
+		payload["DocNumber"] = "ACH"
+	}
+	if len(memos) > 0 {
+		payload["PrivateNote"] = strings.Join(memos, "; ")
+	}
+
+	var result struct {
+		BillPayment struct {
+			ID        string `json:"Id"`
+			DocNumber string `json:"DocNumber"`
+		} `json:"BillPayment"`
+	}
+	if err := s.qb.Create(ctx, "BillPayment", payload, &result); err != nil {
+		return nil, err
+	}
+	recorded.ID = result.BillPayment.ID
+	recorded.DocNumber = result.BillPayment.DocNumber
+	return recorded, nil
+}
@@ -0,0 +1,235 @@
+// billpay/settings.go
+package billpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrPayeeNotFound is returned when a vendor has no bank details for ACH
+var ErrPayeeNotFound = errors.New("vendor bank details not found")
+
+// Bank account types of ACH payees
+const (
+	AccountChecking = "checking"
+	AccountSavings  = "savings"
+)
+
+var (
+	routingPattern = regexp.MustCompile(`^\d{9}$`)
+	accountPattern = regexp.MustCompile(`^[0-9A-Za-z-]{1,17}$`)
+)
+
+// Originator is the company's bank relationship written to NACHA and
+// positive pay files. Banks issue the company ID and tell originators
+// which immediate origin to use.
+type Originator struct {
+	// BankRoutingNumber is the routing number of the originating bank
+	BankRoutingNumber string `json:"bank_routing_number"`
+	BankName          string `json:"bank_name"`
+	// AccountNumber is the company account payments are drawn on, used in
+	// positive pay files
+	AccountNumber string `json:"account_number"`
+	CompanyName   string `json:"company_name"`
+	// CompanyID identifies the company to the bank, usually "1" and its EIN
+	CompanyID string `json:"company_id"`
+	// ImmediateOrigin defaults to the company ID
+	ImmediateOrigin string `json:"immediate_origin,omitempty"`
+}
+
+// Validate checks the originator can be written to a NACHA file
+func (o *Originator) Validate() error {
+	if !validRouting(o.BankRoutingNumber) {
+		return fmt.Errorf("invalid bank_routing_number")
+	}
+	if o.AccountNumber != "" && !accountPattern.MatchString(o.AccountNumber) {
+		return fmt.Errorf("invalid account_number")
+	}
+	if strings.TrimSpace(o.CompanyName) == "" {
+		return fmt.Errorf("company_name is required")
+	}
+	if o.CompanyID == "" || len(o.CompanyID) > 10 {
+		return fmt.Errorf("company_id must be 1 to 10 characters")
+	}
+	if len(o.ImmediateOrigin) > 10 {
+		return fmt.Errorf("immediate_origin must be at most 10 characters")
+	}
+	return nil
+}
+
+// Settings are a company's bill pay defaults
+type Settings struct {
+	// BankAccountID is the QuickBooks bank account payments are made from
+	// unless a payment names another
+	BankAccountID string `json:"bank_account_id"`
+	// Hour is the local hour the day's payments are recorded at
+	Hour       int         `json:"hour"`
+	Originator *Originator `json:"originator,omitempty"`
+	// CheckLayout is the check stock printed on, LayoutVoucherTop unless set
+	CheckLayout string `json:"check_layout,omitempty"`
+	// CheckOffset shifts printed checks to line up with the stock in the
+	// company's printer
+	CheckOffset Offset    `json:"check_offset"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Offset is a print alignment adjustment in points, 72 to the inch;
+// positive values move right and down
+type Offset struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Payee is a vendor's bank account for ACH payments
+type Payee struct {
+	VendorID      string `json:"vendor_id"`
+	RoutingNumber string `json:"routing_number"`
+	AccountNumber string `json:"account_number"`
+	AccountType   string `json:"account_type"`
+	// Name is the account holder written to NACHA entries, defaulting to
+	// the vendor's display name
+	Name string `json:"name,omitempty"`
+}
+
+// Validate checks the payee's bank details
+func (p *Payee) Validate() error {
+	if !validRouting(p.RoutingNumber) {
+		return fmt.Errorf("invalid routing_number")
+	}
+	if !accountPattern.MatchString(p.AccountNumber) {
+		return fmt.Errorf("invalid account_number")
+	}
+	if p.AccountType == "" {
+		p.AccountType = AccountChecking
+	}
+	if p.AccountType != AccountChecking && p.AccountType != AccountSavings {
+		return fmt.Errorf("account_type must be %q or %q", AccountChecking, AccountSavings)
+	}
+	return nil
+}
+
+// Masked returns the payee with all but the last four digits of its
+// account number hidden, for responses
+func (p Payee) Masked() Payee {
+	p.AccountNumber = mask(p.AccountNumber)
+	return p
+}
+
+// mask hides all but the last four characters of an account number
+func mask(account string) string {
+	if n := len(account); n > 4 {
+		return strings.Repeat("*", n-4) + account[n-4:]
+	}
+	return account
+}
+
+// Masked returns the settings with the originator's account number masked
+func (s Settings) Masked() Settings {
+	if s.Originator != nil {
+		originator := *s.Originator
+		originator.AccountNumber = mask(originator.AccountNumber)
+		s.Originator = &originator
+	}
+	return s
+}
+
+// validRouting checks an ABA routing number's length and check digit
+func validRouting(routing string) bool {
+	if !routingPattern.MatchString(routing) {
+		return false
+	}
+	weights := []int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i, c := range routing {
+		sum += int(c-'0') * weights[i]
+	}
+	return sum%10 == 0
+}
+
+// settingsKey holds a realm's bill pay settings
+func (s *Store) settingsKey(realmID string) string {
+	return fmt.Sprintf("%s:billpay:settings:%s", s.prefix, realmID)
+}
+
+// payeesKey is the hash of vendor ID to bank details for a realm
+func (s *Store) payeesKey(realmID string) string {
+	return fmt.Sprintf("%s:billpay:payees:%s", s.prefix, realmID)
+}
+
+// GetSettings retrieves a realm's settings, or defaults if none are saved
+func (s *Store) GetSettings(ctx context.Context, realmID string) (*Settings, error) {
+	data, err := s.client.Get(ctx, s.settingsKey(realmID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return &Settings{Hour: defaultHour}, nil
+		}
+		return nil, fmt.Errorf("failed to get bill pay settings: %w", err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bill pay settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SaveSettings stores a realm's settings
+func (s *Store) SaveSettings(ctx context.Context, realmID string, settings *Settings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bill pay settings: %w", err)
+	}
+	if err := s.client.Set(ctx, s.settingsKey(realmID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save bill pay settings: %w", err)
+	}
+	return nil
+}
+
+// SavePayee stores a vendor's bank details
+func (s *Store) SavePayee(ctx context.Context, realmID string, payee *Payee) error {
+	data, err := json.Marshal(payee)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payee: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.payeesKey(realmID), payee.VendorID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save payee: %w", err)
+	}
+	return nil
+}
+
+// GetPayee retrieves a vendor's bank details
+func (s *Store) GetPayee(ctx context.Context, realmID, vendorID string) (*Payee, error) {
+	data, err := s.client.HGet(ctx, s.payeesKey(realmID), vendorID).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrPayeeNotFound
+		}
+		return nil, fmt.Errorf("failed to get payee: %w", err)
+	}
+
+	var payee Payee
+	if err := json.Unmarshal(data, &payee); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payee: %w", err)
+	}
+	return &payee, nil
+}
+
+// DeletePayee removes a vendor's bank details
+func (s *Store) DeletePayee(ctx context.Context, realmID, vendorID string) error {
+	removed, err := s.client.HDel(ctx, s.payeesKey(realmID), vendorID).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete payee: %w", err)
+	}
+	if removed == 0 {
+		return ErrPayeeNotFound
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// branding/branding.go
+package branding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/jpeg" // logo formats
+	_ "image/png"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eGGnogSC/qbserver/internal/pdf"
+	"github.com/go-redis/redis/v8"
+)
+
+// Limits on branding settings
+const (
+	maxLogoSize       = 512 << 10
+	maxLogoDimension  = 2000
+	maxFooterLength   = 500
+	maxCompanyNameLen = 100
+	maxTemplateSize   = 64 << 10
+)
+
+// DefaultAccentColor is used by tenants without an accent color
+const DefaultAccentColor = "#2e3b4e"
+
+// Branding is how a tenant's locally rendered documents look
+type Branding struct {
+	// CompanyName replaces the QuickBooks company name on documents
+	CompanyName string `json:"company_name,omitempty"`
+	// Logo is a PNG or JPEG image, base64-encoded in JSON
+	Logo []byte `json:"logo,omitempty"`
+	// AccentColor colors headings and rules, as "#rrggbb"
+	AccentColor string `json:"accent_color,omitempty"`
+	Footer      string `json:"footer,omitempty"`
+	// InvoiceTemplate replaces the built-in HTML template of locally
+	// rendered invoices; it is an html/template over the invoice's view
+	InvoiceTemplate string    `json:"invoice_template,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
+}
+
+// Validate checks the branding settings
+func (b *Branding) Validate() error {
+	if utf8.RuneCountInString(b.CompanyName) > maxCompanyNameLen {
+		return fmt.Errorf("company_name must be at most %d characters", maxCompanyNameLen)
+	}
+	if len(b.Logo) > 0 {
+		if len(b.Logo) > maxLogoSize {
+			return fmt.Errorf("logo must be at most %d KB", maxLogoSize>>10)
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(b.Logo))
+		if err != nil || (format != "png" && format != "jpeg") {
+			return fmt.Errorf("logo must be a PNG or JPEG image")
+		}
+		if config.Width > maxLogoDimension || config.Height > maxLogoDimension {
+			return fmt.Errorf("logo must be at most %dx%d pixels", maxLogoDimension, maxLogoDimension)
+		}
+	}
+	if b.AccentColor != "" {
+		if _, err := pdf.ParseColor(b.AccentColor); err != nil {
+			return fmt.Errorf("accent_color: %w", err)
+		}
+	}
+	if utf8.RuneCountInString(b.Footer) > maxFooterLength {
+		return fmt.Errorf("footer must be at most %d characters", maxFooterLength)
+	}
+	if b.InvoiceTemplate != "" {
+		if len(b.InvoiceTemplate) > maxTemplateSize {
+			return fmt.Errorf("invoice_template must be at most %d KB", maxTemplateSize>>10)
+		}
+		if _, err := template.New("invoice").Parse(b.InvoiceTemplate); err != nil {
+			return fmt.Errorf("invoice_template: %w", err)
+		}
+	}
+	return nil
+}
+
+// Accent returns the accent color, or the default for tenants without one
+func (b *Branding) Accent() pdf.Color {
+	accent, err := pdf.ParseColor(b.AccentColor)
+	if err != nil {
+		accent, _ = pdf.ParseColor(DefaultAccentColor)
+	}
+	return accent
+}
+
+// LogoImage decodes the logo, or returns nil for tenants without one
+func (b *Branding) LogoImage() (image.Image, error) {
+	if len(b.Logo) == 0 {
+		return nil, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(b.Logo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode logo: %w", err)
+	}
+	return img, nil
+}
+
+// Store persists tenants' branding
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new branding store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key holds a tenant's branding
+func (s *Store) key(tenantID string) string {
+	return fmt.Sprintf("%s:branding:%s", s.prefix, tenantID)
+}
+
+// Get returns a tenant's branding; tenants that have not set any get the
+// default look
+func (s *Store) Get(ctx context.Context, tenantID string) (*Branding, error) {
+	data, err := s.client.Get(ctx, s.key(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return &Branding{}, nil
+		}
+		return nil, fmt.Errorf("failed to get branding: %w", err)
+	}
+
+	var branding Branding
+	if err := json.Unmarshal(data, &branding); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal branding: %w", err)
+	}
+	return &branding, nil
+}
+
+// Save stores a tenant's branding
+func (s *Store) Save(ctx context.Context, tenantID string, branding *Branding) error {
+	if err := branding.Validate(); err != nil {
+		return err
+	}
+	branding.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(branding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal branding: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save branding: %w", err)
+	}
+	return nil
+}
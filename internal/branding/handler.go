@@ -0,0 +1,55 @@
+// branding/handler.go
+package branding
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// maxRequestSize bounds branding bodies, whose logo is base64-encoded
+const maxRequestSize = 1 << 20
+
+// Handler provides HTTP handlers for tenant branding
+type Handler struct {
+	store *Store
+}
+
+// NewHandler creates a new branding handler
+func NewHandler(store *Store) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+// GetBranding returns the tenant's branding
+func (h *Handler) GetBranding(w http.ResponseWriter, r *http.Request) {
+	branding, err := h.store.Get(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to get branding: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(branding)
+}
+
+// SaveBranding replaces the tenant's branding
+func (h *Handler) SaveBranding(w http.ResponseWriter, r *http.Request) {
+	var branding Branding
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&branding); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Save(r.Context(), auth.GetTenantID(r.Context()), &branding); err != nil {
+		http.Error(w, "Failed to save branding: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(branding)
+}
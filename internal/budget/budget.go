@@ -0,0 +1,244 @@
+// budget/budget.go
+package budget
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrBudgetNotFound is returned when a budget does not exist
+var ErrBudgetNotFound = errors.New("budget not found")
+
+// Line is the budgeted amount for an account, optionally scoped to a class
+type Line struct {
+	AccountID   string  `json:"account_id"`
+	AccountName string  `json:"account_name,omitempty"`
+	ClassID     string  `json:"class_id,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// Budget is a set of budgeted amounts over a period
+type Budget struct {
+	ID        string    `json:"id"`
+	RealmID   string    `json:"realm_id"`
+	Name      string    `json:"name"`
+	StartDate string    `json:"start_date"`
+	EndDate   string    `json:"end_date"`
+	Lines     []Line    `json:"lines"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the period and lines of a budget
+func (b *Budget) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	start, err := time.Parse("2006-01-02", b.StartDate)
+	if err != nil {
+		return fmt.Errorf("invalid start_date, expected YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", b.EndDate)
+	if err != nil {
+		return fmt.Errorf("invalid end_date, expected YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("end_date must not be before start_date")
+	}
+	if len(b.Lines) == 0 {
+		return fmt.Errorf("budget has no lines")
+	}
+
+	seen := map[string]bool{}
+	for i, line := range b.Lines {
+		if line.AccountID == "" {
+			return fmt.Errorf("line %d: account_id is required", i+1)
+		}
+		key := line.AccountID + "|" + line.ClassID
+		if seen[key] {
+			return fmt.Errorf("line %d: duplicate account and class", i+1)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// ParseCSV reads budget lines from CSV with an account_id, class_id
+// (optional), account_name (optional) and amount header
+func ParseCSV(r io.Reader) ([]Line, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["account_id"]; !ok {
+		return nil, fmt.Errorf("CSV is missing the account_id column")
+	}
+	if _, ok := columns["amount"]; !ok {
+		return nil, fmt.Errorf("CSV is missing the amount column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var lines []Line
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row %d: %w", row, err)
+		}
+
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(field(record, "amount"), ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid amount", row)
+		}
+		lines = append(lines, Line{
+			AccountID:   field(record, "account_id"),
+			AccountName: field(record, "account_name"),
+			ClassID:     field(record, "class_id"),
+			Amount:      amount,
+		})
+	}
+
+	return lines, nil
+}
+
+// Store persists budgets per realm
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new budget store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key generates the Redis key for a realm's budgets
+func (s *Store) key(realmID string) string {
+	return fmt.Sprintf("%s:budgets:%s", s.prefix, realmID)
+}
+
+// Create validates and stores a new budget
+func (s *Store) Create(ctx context.Context, b *Budget) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	b.ID = jobs.NewID()
+	b.CreatedAt = time.Now()
+	b.UpdatedAt = b.CreatedAt
+
+	return s.save(ctx, b)
+}
+
+// Update validates and replaces an existing budget's name, period and lines
+func (s *Store) Update(ctx context.Context, b *Budget) error {
+	existing, err := s.Get(ctx, b.RealmID, b.ID)
+	if err != nil {
+		return err
+	}
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	b.CreatedBy = existing.CreatedBy
+	b.CreatedAt = existing.CreatedAt
+	b.UpdatedAt = time.Now()
+
+	return s.save(ctx, b)
+}
+
+// save writes a budget
+func (s *Store) save(ctx context.Context, b *Budget) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal budget: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.key(b.RealmID), b.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save budget: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a budget
+func (s *Store) Get(ctx context.Context, realmID, id string) (*Budget, error) {
+	data, err := s.client.HGet(ctx, s.key(realmID), id).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrBudgetNotFound
+		}
+		return nil, fmt.Errorf("failed to get budget: %w", err)
+	}
+
+	var b Budget
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal budget: %w", err)
+	}
+
+	return &b, nil
+}
+
+// List returns a realm's budgets, latest period first
+func (s *Store) List(ctx context.Context, realmID string) ([]*Budget, error) {
+	values, err := s.client.HGetAll(ctx, s.key(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+
+	budgets := make([]*Budget, 0, len(values))
+	for _, v := range values {
+		var b Budget
+		if err := json.Unmarshal([]byte(v), &b); err != nil {
+			continue
+		}
+		budgets = append(budgets, &b)
+	}
+
+	sort.Slice(budgets, func(i, j int) bool {
+		return budgets[i].StartDate > budgets[j].StartDate
+	})
+
+	return budgets, nil
+}
+
+// Delete removes a budget
+func (s *Store) Delete(ctx context.Context, realmID, id string) error {
+	removed, err := s.client.HDel(ctx, s.key(realmID), id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if removed == 0 {
+		return ErrBudgetNotFound
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+// budget/handler.go
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// maxImportSize caps uploaded budget CSVs
+const maxImportSize = 2 << 20
+
+// Handler provides HTTP handlers for budgets
+type Handler struct {
+	store    *Store
+	comparer *Comparer
+}
+
+// NewHandler creates a new budget handler
+func NewHandler(store *Store, comparer *Comparer) *Handler {
+	return &Handler{
+		store:    store,
+		comparer: comparer,
+	}
+}
+
+// writeStoreError maps budget store errors to HTTP responses
+func writeStoreError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, ErrBudgetNotFound) {
+		http.Error(w, "Budget not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Failed to "+action+" budget: "+err.Error(), http.StatusBadRequest)
+}
+
+// ListBudgets returns the current company's budgets
+func (h *Handler) ListBudgets(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	budgets, err := h.store.List(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list budgets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"budgets": budgets,
+	})
+}
+
+// CreateBudget defines a budget from a JSON body
+func (h *Handler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var b Budget
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	b.RealmID = realmID
+	b.CreatedBy = auth.GetUserID(r.Context())
+
+	if err := h.store.Create(r.Context(), &b); err != nil {
+		writeStoreError(w, "create", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// ImportBudget creates a budget from a CSV body; name, start_date and
+// end_date are given as query parameters
+func (h *Handler) ImportBudget(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	lines, err := ParseCSV(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, "Invalid budget CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	b := Budget{
+		RealmID:   realmID,
+		Name:      query.Get("name"),
+		StartDate: query.Get("start_date"),
+		EndDate:   query.Get("end_date"),
+		Lines:     lines,
+		CreatedBy: auth.GetUserID(r.Context()),
+	}
+	if err := h.store.Create(r.Context(), &b); err != nil {
+		writeStoreError(w, "import", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// GetBudget returns a budget
+func (h *Handler) GetBudget(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	b, err := h.store.Get(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		writeStoreError(w, "get", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(b)
+}
+
+// UpdateBudget replaces a budget's definition
+func (h *Handler) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var b Budget
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	b.ID = mux.Vars(r)["id"]
+	b.RealmID = realmID
+
+	if err := h.store.Update(r.Context(), &b); err != nil {
+		writeStoreError(w, "update", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(b)
+}
+
+// DeleteBudget removes a budget
+func (h *Handler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.store.Delete(r.Context(), realmID, mux.Vars(r)["id"]); err != nil {
+		writeStoreError(w, "delete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetVariance compares a budget with actuals, as JSON or CSV (?format=csv)
+func (h *Handler) GetVariance(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	b, err := h.store.Get(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		writeStoreError(w, "get", err)
+		return
+	}
+
+	report, err := h.comparer.Compare(r.Context(), b)
+	if err != nil {
+		http.Error(w, "Failed to compare budget with actuals: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+report.Filename()+`"`)
+		w.WriteHeader(http.StatusOK)
+		report.WriteCSV(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
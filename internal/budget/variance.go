@@ -0,0 +1,197 @@
+// budget/variance.go
+package budget
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// Reporter runs QuickBooks reports
+type Reporter interface {
+	Report(ctx context.Context, name string, params url.Values) (*qbclient.Report, error)
+}
+
+// VarianceLine compares a budget line with actual activity
+type VarianceLine struct {
+	AccountID       string   `json:"account_id"`
+	AccountName     string   `json:"account_name"`
+	ClassID         string   `json:"class_id,omitempty"`
+	Budget          float64  `json:"budget"`
+	Actual          float64  `json:"actual"`
+	Variance        float64  `json:"variance"`                   // actual - budget
+	VariancePercent *float64 `json:"variance_percent,omitempty"` // nil when nothing was budgeted
+}
+
+// VarianceReport compares a budget with actuals over its period
+type VarianceReport struct {
+	BudgetID      string         `json:"budget_id"`
+	Name          string         `json:"name"`
+	StartDate     string         `json:"start_date"`
+	EndDate       string         `json:"end_date"`
+	Lines         []VarianceLine `json:"lines"`
+	TotalBudget   float64        `json:"total_budget"`
+	TotalActual   float64        `json:"total_actual"`
+	TotalVariance float64        `json:"total_variance"`
+}
+
+// Comparer computes budget vs actuals from the Profit and Loss report
+type Comparer struct {
+	reporter Reporter
+}
+
+// NewComparer creates a new budget vs actuals comparer
+func NewComparer(reporter Reporter) *Comparer {
+	return &Comparer{
+		reporter: reporter,
+	}
+}
+
+// Compare builds the variance report for a budget. Class-scoped lines are
+// compared with the Profit and Loss report filtered to that class.
+func (c *Comparer) Compare(ctx context.Context, b *Budget) (*VarianceReport, error) {
+	actuals := map[string]map[string]accountActual{}
+	for _, line := range b.Lines {
+		if _, ok := actuals[line.ClassID]; ok {
+			continue
+		}
+
+		params := url.Values{}
+		params.Set("start_date", b.StartDate)
+		params.Set("end_date", b.EndDate)
+		params.Set("accounting_method", "Accrual")
+		if line.ClassID != "" {
+			params.Set("class", line.ClassID)
+		}
+
+		report, err := c.reporter.Report(ctx, "ProfitAndLoss", params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get actuals: %w", err)
+		}
+
+		byAccount := map[string]accountActual{}
+		collectActuals(report.Rows.Row, byAccount)
+		actuals[line.ClassID] = byAccount
+	}
+
+	result := &VarianceReport{
+		BudgetID:  b.ID,
+		Name:      b.Name,
+		StartDate: b.StartDate,
+		EndDate:   b.EndDate,
+	}
+	for _, line := range b.Lines {
+		actual := actuals[line.ClassID][line.AccountID]
+		name := line.AccountName
+		if name == "" {
+			name = actual.name
+		}
+
+		v := VarianceLine{
+			AccountID:   line.AccountID,
+			AccountName: name,
+			ClassID:     line.ClassID,
+			Budget:      roundCents(line.Amount),
+			Actual:      roundCents(actual.amount),
+			Variance:    roundCents(actual.amount - line.Amount),
+		}
+		if line.Amount != 0 {
+			pct := math.Round(v.Variance/math.Abs(line.Amount)*10000) / 100
+			v.VariancePercent = &pct
+		}
+
+		result.Lines = append(result.Lines, v)
+		result.TotalBudget += v.Budget
+		result.TotalActual += v.Actual
+	}
+	result.TotalBudget = roundCents(result.TotalBudget)
+	result.TotalActual = roundCents(result.TotalActual)
+	result.TotalVariance = roundCents(result.TotalActual - result.TotalBudget)
+
+	return result, nil
+}
+
+// accountActual is an account's total from a report
+type accountActual struct {
+	name   string
+	amount float64
+}
+
+// collectActuals walks report rows, summing data rows by account ID
+func collectActuals(rows []qbclient.ReportRow, into map[string]accountActual) {
+	for _, row := range rows {
+		if row.Rows != nil {
+			collectActuals(row.Rows.Row, into)
+		}
+		if len(row.ColData) < 2 || row.ColData[0].ID == "" {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(row.ColData[len(row.ColData)-1].Value, 64)
+		if err != nil {
+			continue
+		}
+		actual := into[row.ColData[0].ID]
+		actual.name = row.ColData[0].Value
+		actual.amount += amount
+		into[row.ColData[0].ID] = actual
+	}
+}
+
+// WriteCSV writes a variance report as CSV
+func (r *VarianceReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"account_id", "account_name", "class_id", "budget", "actual", "variance", "variance_percent"}); err != nil {
+		return err
+	}
+
+	money := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	for _, line := range r.Lines {
+		pct := ""
+		if line.VariancePercent != nil {
+			pct = strconv.FormatFloat(*line.VariancePercent, 'f', 2, 64)
+		}
+		if err := writer.Write([]string{
+			line.AccountID,
+			line.AccountName,
+			line.ClassID,
+			money(line.Budget),
+			money(line.Actual),
+			money(line.Variance),
+			pct,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := writer.Write([]string{"", "Total", "", money(r.TotalBudget), money(r.TotalActual), money(r.TotalVariance), ""}); err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Filename returns a CSV download name for the report
+func (r *VarianceReport) Filename() string {
+	name := strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' {
+			return c
+		}
+		return '_'
+	}, r.Name)
+	return fmt.Sprintf("budget-vs-actuals-%s-%s.csv", name, r.EndDate)
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,164 @@
+// bundle/bundle.go
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Format identifies the bundle layout; imports reject other formats
+const Format = "qbserver-config/1"
+
+// Errors returned for bundles that cannot be applied at all
+var (
+	ErrUnknownSection    = errors.New("unknown config section")
+	ErrUnsupportedFormat = errors.New("unsupported bundle format")
+)
+
+// Import actions reported per section
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionUnchanged = "unchanged"
+	ActionSkipped   = "skipped"
+)
+
+// Bundle is a portable snapshot of a tenant's server-side configuration.
+// Credentials are never included; the target environment keeps its own.
+type Bundle struct {
+	Format     string                     `json:"format"`
+	ExportedAt time.Time                  `json:"exported_at"`
+	Sections   map[string]json.RawMessage `json:"sections"`
+}
+
+// SectionResult reports how one section was (or, in a dry run, would be) imported
+type SectionResult struct {
+	Section  string      `json:"section"`
+	Action   string      `json:"action,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+	Warnings []string    `json:"warnings,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Section exports and imports one area of tenant configuration. Import
+// runs with the importing user's identity and current company in ctx.
+type Section interface {
+	// Export returns the section's portable form, or nil if the tenant has
+	// nothing configured
+	Export(ctx context.Context, tenantID string) (interface{}, error)
+	// Import applies the section, or only reports what would change when dryRun is set
+	Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error)
+}
+
+// Service assembles and applies configuration bundles
+type Service struct {
+	names    []string
+	sections map[string]Section
+}
+
+// NewService creates a new bundle service
+func NewService() *Service {
+	return &Service{
+		sections: make(map[string]Section),
+	}
+}
+
+// Register adds a section. Sections are exported and imported in
+// registration order, so register dependencies first.
+func (s *Service) Register(name string, section Section) {
+	if _, exists := s.sections[name]; !exists {
+		s.names = append(s.names, name)
+	}
+	s.sections[name] = section
+}
+
+// Sections lists the registered section names in order
+func (s *Service) Sections() []string {
+	return append([]string(nil), s.names...)
+}
+
+// Export builds a bundle of the named sections, or all sections if none are named
+func (s *Service) Export(ctx context.Context, tenantID string, only []string) (*Bundle, error) {
+	names := s.names
+	if len(only) > 0 {
+		for _, name := range only {
+			if _, ok := s.sections[name]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownSection, name)
+			}
+		}
+		names = only
+	}
+
+	bundle := &Bundle{
+		Format:     Format,
+		ExportedAt: time.Now().UTC(),
+		Sections:   make(map[string]json.RawMessage),
+	}
+	for _, name := range names {
+		value, err := s.sections[name].Export(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", name, err)
+		}
+		if value == nil {
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		bundle.Sections[name] = data
+	}
+	return bundle, nil
+}
+
+// Import applies a bundle's sections in registration order. The bundle is
+// checked up front; after that a failing section is reported in its result
+// and the remaining sections still run.
+func (s *Service) Import(ctx context.Context, tenantID string, bundle *Bundle, dryRun bool) ([]SectionResult, error) {
+	if bundle.Format != Format {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, bundle.Format)
+	}
+	for name := range bundle.Sections {
+		if _, ok := s.sections[name]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownSection, name)
+		}
+	}
+
+	var results []SectionResult
+	for _, name := range s.names {
+		data, ok := bundle.Sections[name]
+		if !ok {
+			continue
+		}
+		result, err := s.sections[name].Import(ctx, tenantID, data, dryRun)
+		if err != nil {
+			results = append(results, SectionResult{Section: name, Error: err.Error()})
+			continue
+		}
+		result.Section = name
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// compare returns the action that replacing before with after amounts to
+func compare(before, after interface{}) (string, error) {
+	if before == nil {
+		return ActionCreated, nil
+	}
+	a, err := json.Marshal(before)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(after)
+	if err != nil {
+		return "", err
+	}
+	if string(a) == string(b) {
+		return ActionUnchanged, nil
+	}
+	return ActionUpdated, nil
+}
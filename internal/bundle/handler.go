@@ -0,0 +1,88 @@
+// bundle/handler.go
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// maxBundleSize bounds uploaded bundles
+const maxBundleSize = 5 << 20
+
+// Handler provides HTTP handlers for configuration bundles
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new bundle handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ExportBundle downloads the tenant's configuration. ?sections=a,b limits
+// the bundle to the named sections.
+func (h *Handler) ExportBundle(w http.ResponseWriter, r *http.Request) {
+	var only []string
+	if v := r.URL.Query().Get("sections"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				only = append(only, name)
+			}
+		}
+	}
+
+	tenantID := auth.GetTenantID(r.Context())
+	bundle, err := h.service.Export(r.Context(), tenantID, only)
+	if err != nil {
+		if errors.Is(err, ErrUnknownSection) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to export config bundle: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("qbserver-config-%s-%s.json", tenantID, bundle.ExportedAt.Format("20060102"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// ImportBundle applies an exported bundle to the tenant. ?dry_run=true
+// reports what each section would do without saving.
+func (h *Handler) ImportBundle(w http.ResponseWriter, r *http.Request) {
+	var bundle Bundle
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBundleSize)).Decode(&bundle); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	results, err := h.service.Import(r.Context(), auth.GetTenantID(r.Context()), &bundle, dryRun)
+	if err != nil {
+		if errors.Is(err, ErrUnknownSection) || errors.Is(err, ErrUnsupportedFormat) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to import config bundle: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []SectionResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dry_run":  dryRun,
+		"sections": results,
+	})
+}
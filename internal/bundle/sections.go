@@ -0,0 +1,462 @@
+// bundle/sections.go
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/orders"
+	"github.com/eGGnogSC/qbserver/internal/payroll"
+	"github.com/eGGnogSC/qbserver/internal/stripe"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/internal/warehouse"
+	"github.com/eGGnogSC/qbserver/nlp"
+)
+
+// idWarning accompanies sections that carry QuickBooks entity IDs
+const idWarning = "QuickBooks account and item IDs are company-specific; confirm they exist in the target company"
+
+// decode unmarshals section data, rejecting unknown fields
+func decode(data json.RawMessage, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid section data: %w", err)
+	}
+	return nil
+}
+
+// save stores a config, or in a dry run only validates it so the plan
+// reports what the real import would reject
+func save[C interface{ Validate() error }](ctx context.Context, tenantID string, config C, dryRun bool, store func(context.Context, string, C) error) error {
+	if dryRun {
+		return config.Validate()
+	}
+	return store(ctx, tenantID, config)
+}
+
+// TenantConfigSection carries webhooks, notification channels, the dunning
+// policy, feature flags, sync conflict policies, the invoice lifecycle and
+// commission rules.
+// Webhook secrets are not exported; existing secrets in the target are kept.
+type TenantConfigSection struct {
+	service *tenantconfig.Service
+}
+
+// NewTenantConfigSection creates the tenant configuration section
+func NewTenantConfigSection(service *tenantconfig.Service) *TenantConfigSection {
+	return &TenantConfigSection{service: service}
+}
+
+// Export returns the redacted document
+func (s *TenantConfigSection) Export(ctx context.Context, tenantID string) (interface{}, error) {
+	stored, err := s.service.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Version == 0 {
+		return nil, nil
+	}
+	return stored.Document.Redacted(), nil
+}
+
+// Import applies the document and reports its diff
+func (s *TenantConfigSection) Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error) {
+	var doc tenantconfig.Document
+	if err := decode(data, &doc); err != nil {
+		return nil, err
+	}
+	current, err := s.service.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.service.Apply(ctx, tenantID, &doc, 0, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	result := &SectionResult{Action: ActionUnchanged, Details: applied.Changes}
+	switch {
+	case !applied.Changed:
+	case current.Version == 0:
+		result.Action = ActionCreated
+	default:
+		result.Action = ActionUpdated
+	}
+	return result, nil
+}
+
+// ToolPolicySection carries the tenant's agent tool restrictions
+type ToolPolicySection struct {
+	store nlp.ToolPolicyStore
+}
+
+// NewToolPolicySection creates the agent tool policy section
+func NewToolPolicySection(store nlp.ToolPolicyStore) *ToolPolicySection {
+	return &ToolPolicySection{store: store}
+}
+
+// Export returns the tenant's tool policy, if any restrictions are set
+func (s *ToolPolicySection) Export(ctx context.Context, tenantID string) (interface{}, error) {
+	policy, err := s.store.GetPolicy(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if len(policy.DeniedTools) == 0 && !policy.ReadOnly && len(policy.Roles) == 0 {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// Import replaces the tenant's tool policy
+func (s *ToolPolicySection) Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error) {
+	var policy nlp.TenantToolPolicy
+	if err := decode(data, &policy); err != nil {
+		return nil, err
+	}
+	existing, err := s.store.GetPolicy(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := compare(existing, &policy)
+	if err != nil {
+		return nil, err
+	}
+	if action != ActionUnchanged && !dryRun {
+		if err := s.store.SavePolicy(ctx, tenantID, &policy); err != nil {
+			return nil, err
+		}
+	}
+	return &SectionResult{Action: action}, nil
+}
+
+// agentSchedule is the portable form of a scheduled agent task
+type agentSchedule struct {
+	Instruction string         `json:"instruction"`
+	Plan        nlp.Plan       `json:"plan"`
+	Delivery    nlp.Delivery   `json:"delivery"`
+	Schedule    *jobs.Schedule `json:"schedule"`
+}
+
+// key identifies equivalent schedules so re-imports do not duplicate them
+func (a agentSchedule) key() string {
+	schedule, _ := json.Marshal(a.Schedule)
+	return a.Instruction + "\x00" + string(schedule)
+}
+
+// AgentScheduleSection carries the tenant's active scheduled agent tasks
+type AgentScheduleSection struct {
+	service *nlp.ScheduledTaskService
+}
+
+// NewAgentScheduleSection creates the scheduled agent task section
+func NewAgentScheduleSection(service *nlp.ScheduledTaskService) *AgentScheduleSection {
+	return &AgentScheduleSection{service: service}
+}
+
+// active returns the tenant's active tasks in portable form
+func (s *AgentScheduleSection) active(ctx context.Context, tenantID string) ([]agentSchedule, error) {
+	list, err := s.service.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var schedules []agentSchedule
+	for _, job := range list {
+		if job.Status != jobs.StatusActive {
+			continue
+		}
+		var task nlp.ScheduledTask
+		if err := json.Unmarshal(job.Payload, &task); err != nil {
+			continue
+		}
+		schedules = append(schedules, agentSchedule{
+			Instruction: task.Instruction,
+			Plan:        task.Plan,
+			Delivery:    task.Delivery,
+			Schedule:    job.Schedule,
+		})
+	}
+	return schedules, nil
+}
+
+// Export returns the active scheduled tasks
+func (s *AgentScheduleSection) Export(ctx context.Context, tenantID string) (interface{}, error) {
+	schedules, err := s.active(ctx, tenantID)
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// Import creates tasks that do not already exist; existing tasks are left alone
+func (s *AgentScheduleSection) Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error) {
+	var incoming []agentSchedule
+	if err := decode(data, &incoming); err != nil {
+		return nil, err
+	}
+	existing, err := s.active(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(existing))
+	for _, schedule := range existing {
+		present[schedule.key()] = true
+	}
+
+	created := 0
+	for _, schedule := range incoming {
+		if present[schedule.key()] {
+			continue
+		}
+		present[schedule.key()] = true
+		if !dryRun {
+			task := nlp.ScheduledTask{Instruction: schedule.Instruction, Plan: schedule.Plan, Delivery: schedule.Delivery}
+			if _, err := s.service.Import(ctx, task, schedule.Schedule); err != nil {
+				return nil, fmt.Errorf("failed to import %q: %w", schedule.Instruction, err)
+			}
+		}
+		created++
+	}
+
+	result := &SectionResult{
+		Action:  ActionUnchanged,
+		Details: map[string]int{"created": created, "existing": len(incoming) - created},
+	}
+	if created > 0 {
+		result.Action = ActionCreated
+	}
+	return result, nil
+}
+
+// stripeSettings is the portable part of a Stripe connection
+type stripeSettings struct {
+	Accounts           stripe.Accounts `json:"accounts"`
+	InvoiceMetadataKey string          `json:"invoice_metadata_key,omitempty"`
+}
+
+// StripeSection carries the Stripe account mapping. The target must already
+// be connected to Stripe; its API key is kept.
+type StripeSection struct {
+	service *stripe.Service
+}
+
+// NewStripeSection creates the Stripe section
+func NewStripeSection(service *stripe.Service) *StripeSection {
+	return &StripeSection{service: service}
+}
+
+// Export returns the account mapping
+func (s *StripeSection) Export(ctx context.Context, tenantID string) (interface{}, error) {
+	config, err := s.service.GetConfig(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, stripe.ErrNotConfigured) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return stripeSettings{Accounts: config.Accounts, InvoiceMetadataKey: config.InvoiceMetadataKey}, nil
+}
+
+// Import updates the account mapping of the existing connection
+func (s *StripeSection) Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error) {
+	var settings stripeSettings
+	if err := decode(data, &settings); err != nil {
+		return nil, err
+	}
+	config, err := s.service.GetConfig(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, stripe.ErrNotConfigured) {
+			return nil, fmt.Errorf("connect Stripe in this environment before importing its settings")
+		}
+		return nil, err
+	}
+
+	action, err := compare(stripeSettings{Accounts: config.Accounts, InvoiceMetadataKey: config.InvoiceMetadataKey}, settings)
+	if err != nil {
+		return nil, err
+	}
+	if action != ActionUnchanged {
+		config.Accounts = settings.Accounts
+		config.InvoiceMetadataKey = settings.InvoiceMetadataKey
+		if err := save(ctx, tenantID, config, dryRun, s.service.SaveConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &SectionResult{Action: action, Warnings: []string{idWarning}}, nil
+}
+
+// OrderRulesSection carries storefront order conversion rules. The store
+// connection itself is environment-specific and is not exported.
+type OrderRulesSection struct {
+	service *orders.Service
+}
+
+// NewOrderRulesSection creates the order rules section
+func NewOrderRulesSection(service *orders.Service) *OrderRulesSection {
+	return &OrderRulesSection{service: service}
+}
+
+// Export returns the conversion rules
+func (s *OrderRulesSection) Export(ctx context.Context, tenantID string) (interface{}, error) {
+	config, err := s.service.GetConfig(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, orders.ErrNotConfigured) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return config.Rules, nil
+}
+
+// Import replaces the rules of the existing order source
+func (s *OrderRulesSection) Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error) {
+	var rules orders.Rules
+	if err := decode(data, &rules); err != nil {
+		return nil, err
+	}
+	config, err := s.service.GetConfig(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, orders.ErrNotConfigured) {
+			return nil, fmt.Errorf("connect an order source in this environment before importing its rules")
+		}
+		return nil, err
+	}
+
+	action, err := compare(config.Rules, rules)
+	if err != nil {
+		return nil, err
+	}
+	if action != ActionUnchanged {
+		config.Rules = rules
+		if err := save(ctx, tenantID, config, dryRun, s.service.SaveConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &SectionResult{Action: action, Warnings: []string{idWarning}}, nil
+}
+
+// PayrollSection carries the payroll journal account mapping
+type PayrollSection struct {
+	service *payroll.Service
+}
+
+// NewPayrollSection creates the payroll section
+func NewPayrollSection(service *payroll.Service) *PayrollSection {
+	return &PayrollSection{service: service}
+}
+
+// Export returns the account mapping
+func (s *PayrollSection) Export(ctx context.Context, tenantID string) (interface{}, error) {
+	config, err := s.service.GetConfig(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, payroll.ErrNotConfigured) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return config.Accounts, nil
+}
+
+// Import updates the account mapping, creating the payroll configuration
+// for the current company if there is none
+func (s *PayrollSection) Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error) {
+	var accounts payroll.AccountMapping
+	if err := decode(data, &accounts); err != nil {
+		return nil, err
+	}
+	warnings := []string{idWarning}
+
+	config, err := s.service.GetConfig(ctx, tenantID)
+	var action string
+	switch {
+	case errors.Is(err, payroll.ErrNotConfigured):
+		realmID, err := auth.GetCompanyID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("QuickBooks company not connected")
+		}
+		config = &payroll.Config{
+			RealmID:       realmID,
+			UserID:        auth.GetUserID(ctx),
+			WebhookSecret: jobs.NewID(),
+		}
+		action = ActionCreated
+		warnings = append(warnings, "a new webhook secret was generated; register it with the payroll provider")
+	case err != nil:
+		return nil, err
+	default:
+		if action, err = compare(config.Accounts, accounts); err != nil {
+			return nil, err
+		}
+	}
+
+	if action != ActionUnchanged {
+		config.Accounts = accounts
+		if err := save(ctx, tenantID, config, dryRun, s.service.SaveConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &SectionResult{Action: action, Warnings: warnings}, nil
+}
+
+// warehouseSettings is the portable part of a warehouse export
+type warehouseSettings struct {
+	Entities  []string `json:"entities"`
+	Frequency string   `json:"frequency"`
+}
+
+// WarehouseSection carries which entities are exported and how often. The
+// destination and its credentials are environment-specific and not exported.
+type WarehouseSection struct {
+	service *warehouse.Service
+}
+
+// NewWarehouseSection creates the warehouse export section
+func NewWarehouseSection(service *warehouse.Service) *WarehouseSection {
+	return &WarehouseSection{service: service}
+}
+
+// Export returns the entity list and frequency
+func (s *WarehouseSection) Export(ctx context.Context, tenantID string) (interface{}, error) {
+	config, err := s.service.GetConfig(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, warehouse.ErrNotConfigured) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return warehouseSettings{Entities: config.Entities, Frequency: config.Frequency}, nil
+}
+
+// Import updates the existing warehouse export
+func (s *WarehouseSection) Import(ctx context.Context, tenantID string, data json.RawMessage, dryRun bool) (*SectionResult, error) {
+	var settings warehouseSettings
+	if err := decode(data, &settings); err != nil {
+		return nil, err
+	}
+	config, err := s.service.GetConfig(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, warehouse.ErrNotConfigured) {
+			return nil, fmt.Errorf("configure a warehouse sink in this environment before importing export settings")
+		}
+		return nil, err
+	}
+
+	action, err := compare(warehouseSettings{Entities: config.Entities, Frequency: config.Frequency}, settings)
+	if err != nil {
+		return nil, err
+	}
+	if action != ActionUnchanged {
+		config.Entities = settings.Entities
+		config.Frequency = settings.Frequency
+		if err := save(ctx, tenantID, config, dryRun, s.service.SaveConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &SectionResult{Action: action}, nil
+}
@@ -0,0 +1,68 @@
+// cdc/handler.go
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// ChangeSource runs QuickBooks change data capture requests
+type ChangeSource interface {
+	ChangeDataCapture(ctx context.Context, entities []string, since time.Time) (*qbclient.ChangeSet, error)
+}
+
+// Handler provides the incremental sync endpoint
+type Handler struct {
+	qb ChangeSource
+}
+
+// NewHandler creates a new change data capture handler
+func NewHandler(qb ChangeSource) *Handler {
+	return &Handler{
+		qb: qb,
+	}
+}
+
+// status returns the HTTP status for a change data capture error
+func status(err error) int {
+	switch {
+	case errors.Is(err, qbclient.ErrCDCNoEntities), errors.Is(err, qbclient.ErrCDCTooOld):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// GetChanges returns the entities of the types in ?entities= (e.g.
+// "Invoice,Customer") changed or deleted since ?since=, an RFC 3339 time
+// within the last 30 days. Clients pass the response's time as the next
+// since; a truncated response means the window should be narrowed.
+func (h *Handler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var entities []string
+	for _, entity := range strings.Split(query.Get("entities"), ",") {
+		if entity = strings.TrimSpace(entity); entity != "" {
+			entities = append(entities, entity)
+		}
+	}
+	since, err := time.Parse(time.RFC3339, query.Get("since"))
+	if err != nil {
+		http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := h.qb.ChangeDataCapture(r.Context(), entities, since)
+	if err != nil {
+		http.Error(w, "Failed to get changes: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(changes)
+}
@@ -0,0 +1,124 @@
+// chaos/faults.go
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// qbFaults are QuickBooks-style fault bodies for injected responses
+var qbFaults = map[int]string{
+	http.StatusTooManyRequests: `{"Fault":{"Error":[{"Message":"message=ThrottleExceeded; errorCode=003001; statusCode=429","Detail":"The request limit was reached (injected fault)","code":"3001"}],"type":"SERVICE"}}`,
+}
+
+// defaultQBFault is returned for injected 5xx responses
+const defaultQBFault = `{"Fault":{"Error":[{"Message":"An application error has occurred while processing your request","Detail":"System Failure Error: injected fault","code":"10000"}],"type":"SystemFault"}}`
+
+// Transport wraps an HTTP transport so QuickBooks API calls made while
+// handling a matching request are delayed or answered with an error
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{injector: i, base: base}
+}
+
+// transport injects QuickBooks faults
+type transport struct {
+	injector *Injector
+	base     http.RoundTripper
+}
+
+// RoundTrip applies the first firing QuickBooks rule, then forwards the request
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule := t.injector.pick(req.Context(), TargetQuickBooks)
+	if rule == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	if rule.DelayMS > 0 {
+		if err := sleep(req.Context(), rule.delay()); err != nil {
+			return nil, err
+		}
+	}
+	if rule.Status == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	body, ok := qbFaults[rule.Status]
+	if !ok {
+		body = defaultQBFault
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	if rule.Status == http.StatusTooManyRequests {
+		header.Set("Retry-After", "1")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+		StatusCode:    rule.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// timeoutError mimics a network timeout so callers take their timeout paths
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout (injected fault)" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// RedisHook returns a hook that times out Redis commands issued while
+// handling a matching request. Commands issued without a request context,
+// such as health checks, only match rules without a route.
+func (i *Injector) RedisHook() redis.Hook {
+	return &redisHook{injector: i}
+}
+
+// redisHook injects Redis timeouts
+type redisHook struct {
+	injector *Injector
+}
+
+// fault waits out the rule's delay and returns the timeout, if a rule fires
+func (h *redisHook) fault(ctx context.Context) error {
+	rule := h.injector.pick(ctx, TargetRedis)
+	if rule == nil {
+		return nil
+	}
+	if err := sleep(ctx, rule.delay()); err != nil {
+		return err
+	}
+	return timeoutError{}
+}
+
+// BeforeProcess fails a command with an injected timeout
+func (h *redisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, h.fault(ctx)
+}
+
+// AfterProcess does nothing
+func (h *redisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+// BeforeProcessPipeline fails a pipeline with an injected timeout
+func (h *redisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, h.fault(ctx)
+}
+
+// AfterProcessPipeline does nothing
+func (h *redisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
@@ -0,0 +1,59 @@
+// chaos/handler.go
+package chaos
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler provides operator endpoints for managing fault rules
+type Handler struct {
+	injector *Injector
+}
+
+// NewHandler creates a new fault injection handler
+func NewHandler(injector *Injector) *Handler {
+	return &Handler{
+		injector: injector,
+	}
+}
+
+// GetRules returns the active fault rules
+func (h *Handler) GetRules(w http.ResponseWriter, r *http.Request) {
+	if !h.injector.Enabled() {
+		http.Error(w, "Fault injection is disabled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": h.injector.Rules(),
+	})
+}
+
+// SetRules replaces the active fault rules; an empty list stops all faults
+func (h *Handler) SetRules(w http.ResponseWriter, r *http.Request) {
+	if !h.injector.Enabled() {
+		http.Error(w, "Fault injection is disabled", http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.injector.SetRules(body.Rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": h.injector.Rules(),
+	})
+}
@@ -0,0 +1,181 @@
+// chaos/injector.go
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault targets
+const (
+	TargetQuickBooks = "quickbooks" // fail or slow down calls to the QuickBooks API
+	TargetRedis      = "redis"      // time out Redis commands
+	TargetLatency    = "latency"    // delay the inbound request before it is handled
+)
+
+// Rule injects one kind of fault into a share of requests
+type Rule struct {
+	// Route is a request path prefix such as /api/invoices. Empty matches
+	// every request and also background work that runs outside a request.
+	Route       string  `json:"route,omitempty"`
+	Target      string  `json:"target"`
+	Probability float64 `json:"probability"`        // 0 to 1
+	Status      int     `json:"status,omitempty"`   // quickbooks: 429 or 5xx response to return
+	DelayMS     int     `json:"delay_ms,omitempty"` // latency and quickbooks: added delay; redis: wait before timing out
+}
+
+// validate checks a rule and applies defaults
+func (r *Rule) validate() error {
+	if r.Probability <= 0 || r.Probability > 1 {
+		return fmt.Errorf("probability must be greater than 0 and at most 1")
+	}
+	if r.DelayMS < 0 || r.DelayMS > 60000 {
+		return fmt.Errorf("delay_ms must be between 0 and 60000")
+	}
+
+	switch r.Target {
+	case TargetQuickBooks:
+		if r.Status == 0 && r.DelayMS == 0 {
+			return fmt.Errorf("quickbooks faults need a status or a delay_ms")
+		}
+		if r.Status != 0 && r.Status != http.StatusTooManyRequests && (r.Status < 500 || r.Status > 599) {
+			return fmt.Errorf("quickbooks status must be 429 or 5xx")
+		}
+	case TargetRedis:
+		if r.DelayMS == 0 {
+			r.DelayMS = 100
+		}
+	case TargetLatency:
+		if r.DelayMS == 0 {
+			return fmt.Errorf("latency faults need a delay_ms")
+		}
+	default:
+		return fmt.Errorf("target must be %s, %s or %s", TargetQuickBooks, TargetRedis, TargetLatency)
+	}
+	return nil
+}
+
+// delay returns the rule's delay as a duration
+func (r *Rule) delay() time.Duration {
+	return time.Duration(r.DelayMS) * time.Millisecond
+}
+
+// ErrProduction is returned when fault injection is enabled for a server
+// connected to production QuickBooks
+var ErrProduction = errors.New("fault injection is only allowed against the QuickBooks sandbox")
+
+// CheckEnvironment refuses fault injection unless apiBaseURL is the
+// QuickBooks sandbox API, so a configuration flag alone cannot inject
+// faults into a production server
+func CheckEnvironment(apiBaseURL string) error {
+	if !strings.Contains(apiBaseURL, "sandbox") {
+		return ErrProduction
+	}
+	return nil
+}
+
+// contextKey carries the inbound request path to outbound calls
+type contextKey struct{}
+
+// Injector holds the active fault rules. It is a development tool: faults
+// are only injected when it was created enabled, and rules live in memory
+// on each instance.
+type Injector struct {
+	enabled bool
+
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// NewInjector creates a fault injector with no rules
+func NewInjector(enabled bool) *Injector {
+	return &Injector{
+		enabled: enabled,
+	}
+}
+
+// Enabled reports whether fault injection is switched on
+func (i *Injector) Enabled() bool {
+	return i.enabled
+}
+
+// Rules returns the active rules
+func (i *Injector) Rules() []Rule {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]Rule{}, i.rules...)
+}
+
+// SetRules validates and replaces the active rules
+func (i *Injector) SetRules(rules []Rule) error {
+	for n := range rules {
+		if err := rules[n].validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", n+1, err)
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = append([]Rule(nil), rules...)
+	return nil
+}
+
+// pick returns the first rule for target that matches the path in ctx and
+// fires on this call, or nil
+func (i *Injector) pick(ctx context.Context, target string) *Rule {
+	if !i.enabled {
+		return nil
+	}
+	path, _ := ctx.Value(contextKey{}).(string)
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, rule := range i.rules {
+		if rule.Target != target || !strings.HasPrefix(path, rule.Route) {
+			continue
+		}
+		if rand.Float64() < rule.Probability {
+			return &rule
+		}
+	}
+	return nil
+}
+
+// Middleware records the request path for outbound fault rules and applies
+// latency rules. Operator routes are never affected, so faults can always
+// be switched off again.
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !i.enabled || strings.HasPrefix(r.URL.Path, "/admin") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, r.URL.Path)
+		if rule := i.pick(ctx, TargetLatency); rule != nil {
+			if err := sleep(ctx, rule.delay()); err != nil {
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
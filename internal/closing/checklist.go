@@ -0,0 +1,231 @@
+// closing/checklist.go
+package closing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Check statuses
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// maxCheckItems caps how many offending entities a check lists
+const maxCheckItems = 50
+
+// CheckItem is an entity that needs attention before closing
+type CheckItem struct {
+	EntityType string  `json:"entity_type"`
+	EntityID   string  `json:"entity_id"`
+	Reference  string  `json:"reference,omitempty"`
+	Amount     float64 `json:"amount,omitempty"`
+	Detail     string  `json:"detail"`
+}
+
+// CheckResult is the outcome of one checklist step
+type CheckResult struct {
+	ID     string      `json:"id"`
+	Title  string      `json:"title"`
+	Status string      `json:"status"`
+	Count  int         `json:"count"`
+	Detail string      `json:"detail"`
+	Items  []CheckItem `json:"items,omitempty"`
+}
+
+// add records an offending entity, keeping the item list bounded
+func (r *CheckResult) add(item CheckItem) {
+	r.Count++
+	if len(r.Items) < maxCheckItems {
+		r.Items = append(r.Items, item)
+	}
+}
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// check is one step of the close checklist
+type check func(ctx context.Context, q Querier, periodStart, periodEnd time.Time) (CheckResult, error)
+
+// checklist runs in order for every close report
+var checklist = []check{
+	unappliedPayments,
+	undepositedFunds,
+	negativeInventory,
+	missingInvoiceNumbers,
+}
+
+// unappliedPayments finds customer payments on or before period end that
+// were never applied to an invoice
+func unappliedPayments(ctx context.Context, q Querier, _, periodEnd time.Time) (CheckResult, error) {
+	result := CheckResult{ID: "unapplied_payments", Title: "Unapplied customer payments", Status: StatusPass}
+
+	payments, err := query.All[struct {
+		ID           string  `json:"Id"`
+		TxnDate      string  `json:"TxnDate"`
+		UnappliedAmt float64 `json:"UnappliedAmt"`
+		CustomerRef  struct {
+			Name string `json:"name"`
+		} `json:"CustomerRef"`
+	}](ctx, q, query.Select("Payment").Where("TxnDate", "<=", periodEnd.Format("2006-01-02")))
+	if err != nil {
+		return result, err
+	}
+
+	for _, p := range payments {
+		if p.UnappliedAmt > 0 {
+			result.add(CheckItem{
+				EntityType: "Payment",
+				EntityID:   p.ID,
+				Reference:  p.CustomerRef.Name,
+				Amount:     p.UnappliedAmt,
+				Detail:     "Payment received " + p.TxnDate + " is not fully applied",
+			})
+		}
+	}
+
+	if result.Count > 0 {
+		result.Status = StatusWarn
+		result.Detail = fmt.Sprintf("%d payments have unapplied amounts", result.Count)
+	} else {
+		result.Detail = "All payments are applied"
+	}
+	return result, nil
+}
+
+// undepositedFunds finds money still sitting in Undeposited Funds
+func undepositedFunds(ctx context.Context, q Querier, _, _ time.Time) (CheckResult, error) {
+	result := CheckResult{ID: "undeposited_funds", Title: "Undeposited funds", Status: StatusPass}
+
+	accounts, err := query.All[struct {
+		ID             string  `json:"Id"`
+		Name           string  `json:"Name"`
+		AccountSubType string  `json:"AccountSubType"`
+		CurrentBalance float64 `json:"CurrentBalance"`
+	}](ctx, q, query.Select("Account").Where("AccountType", "=", "Other Current Asset"))
+	if err != nil {
+		return result, err
+	}
+
+	for _, a := range accounts {
+		if a.AccountSubType == "UndepositedFunds" && a.CurrentBalance != 0 {
+			result.add(CheckItem{
+				EntityType: "Account",
+				EntityID:   a.ID,
+				Reference:  a.Name,
+				Amount:     a.CurrentBalance,
+				Detail:     "Funds received but not yet deposited",
+			})
+		}
+	}
+
+	if result.Count > 0 {
+		result.Status = StatusWarn
+		result.Detail = "Undeposited funds should be deposited or cleared before closing"
+	} else {
+		result.Detail = "No undeposited funds"
+	}
+	return result, nil
+}
+
+// negativeInventory finds inventory items with negative quantity on hand
+func negativeInventory(ctx context.Context, q Querier, _, _ time.Time) (CheckResult, error) {
+	result := CheckResult{ID: "negative_inventory", Title: "Negative inventory", Status: StatusPass}
+
+	items, err := query.All[struct {
+		ID        string  `json:"Id"`
+		Name      string  `json:"Name"`
+		QtyOnHand float64 `json:"QtyOnHand"`
+	}](ctx, q, query.Select("Item").Where("Type", "=", "Inventory"))
+	if err != nil {
+		return result, err
+	}
+
+	for _, item := range items {
+		if item.QtyOnHand < 0 {
+			result.add(CheckItem{
+				EntityType: "Item",
+				EntityID:   item.ID,
+				Reference:  item.Name,
+				Detail:     "Quantity on hand is " + strconv.FormatFloat(item.QtyOnHand, 'f', -1, 64),
+			})
+		}
+	}
+
+	// Negative stock misstates cost of goods sold, so it blocks the close
+	if result.Count > 0 {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("%d inventory items have negative quantity on hand", result.Count)
+	} else {
+		result.Detail = "No negative inventory"
+	}
+	return result, nil
+}
+
+// missingInvoiceNumbers finds invoices in the period without a number and
+// gaps in the numeric invoice sequence
+func missingInvoiceNumbers(ctx context.Context, q Querier, periodStart, periodEnd time.Time) (CheckResult, error) {
+	result := CheckResult{ID: "missing_invoice_numbers", Title: "Missing invoice numbers", Status: StatusPass}
+
+	invoices, err := query.All[struct {
+		ID        string  `json:"Id"`
+		DocNumber string  `json:"DocNumber"`
+		TxnDate   string  `json:"TxnDate"`
+		TotalAmt  float64 `json:"TotalAmt"`
+	}](ctx, q, query.Select("Invoice").
+		Where("TxnDate", ">=", periodStart.Format("2006-01-02")).
+		Where("TxnDate", "<=", periodEnd.Format("2006-01-02")))
+	if err != nil {
+		return result, err
+	}
+
+	var numbers []int
+	for _, inv := range invoices {
+		if inv.DocNumber == "" {
+			result.add(CheckItem{
+				EntityType: "Invoice",
+				EntityID:   inv.ID,
+				Amount:     inv.TotalAmt,
+				Detail:     "Invoice dated " + inv.TxnDate + " has no number",
+			})
+			continue
+		}
+		if n, err := strconv.Atoi(inv.DocNumber); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+
+	sort.Ints(numbers)
+	for i := 1; i < len(numbers); i++ {
+		first, last := numbers[i-1]+1, numbers[i]-1
+		if first > last {
+			continue
+		}
+		reference := strconv.Itoa(first)
+		if last > first {
+			reference += "-" + strconv.Itoa(last)
+		}
+		result.add(CheckItem{
+			EntityType: "Invoice",
+			Reference:  reference,
+			Detail:     "Invoice numbers " + reference + " are missing from the sequence",
+		})
+	}
+
+	if result.Count > 0 {
+		result.Status = StatusWarn
+		result.Detail = fmt.Sprintf("%d invoice numbers are missing or blank", result.Count)
+	} else {
+		result.Detail = "Invoice numbering is complete"
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+// closing/handler.go
+package closing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+)
+
+// Handler provides HTTP handlers for the month-end close workflow
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new close workflow handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// periodEnd reads the period_end parameter, defaulting to the end of last
+// month in the realm's time zone
+func periodEnd(ctx context.Context, value string) (time.Time, error) {
+	if value == "" {
+		return LastMonthEnd(timezone.Today(ctx)), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// GetChecklist runs the close checklist for a period
+func (h *Handler) GetChecklist(w http.ResponseWriter, r *http.Request) {
+	end, err := periodEnd(r.Context(), r.URL.Query().Get("period_end"))
+	if err != nil {
+		http.Error(w, "Invalid period_end, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Checklist(r.Context(), end)
+	if err != nil {
+		http.Error(w, "Failed to run close checklist: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// ClosePeriod sets the QuickBooks closing date once the checklist passes
+func (h *Handler) ClosePeriod(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PeriodEnd string `json:"period_end"`
+		Force     bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	end, err := periodEnd(r.Context(), req.PeriodEnd)
+	if err != nil {
+		http.Error(w, "Invalid period_end, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Close(r.Context(), end, req.Force)
+	if err != nil {
+		if errors.Is(err, ErrNotReady) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		http.Error(w, "Failed to close period: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
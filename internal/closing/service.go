@@ -0,0 +1,105 @@
+// closing/service.go
+package closing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// ErrNotReady is returned when closing a period whose checklist has failures
+var ErrNotReady = errors.New("close checklist has failing checks")
+
+// QuickBooks is the subset of the QuickBooks client used by the close workflow
+type QuickBooks interface {
+	Querier
+	GetPreferences(ctx context.Context) (*qbclient.Preferences, error)
+	SetBookCloseDate(ctx context.Context, date string) (*qbclient.Preferences, error)
+}
+
+// Report is the month-end close report for a period
+type Report struct {
+	PeriodStart   string        `json:"period_start"`
+	PeriodEnd     string        `json:"period_end"`
+	BookCloseDate string        `json:"book_close_date,omitempty"`
+	Locked        bool          `json:"locked"` // period is on or before the closing date
+	ReadyToClose  bool          `json:"ready_to_close"`
+	Checks        []CheckResult `json:"checks"`
+	GeneratedAt   time.Time     `json:"generated_at"`
+}
+
+// Service runs the month-end close checklist and manages the closing date
+type Service struct {
+	qb QuickBooks
+}
+
+// NewService creates a new close workflow service
+func NewService(qb QuickBooks) *Service {
+	return &Service{
+		qb: qb,
+	}
+}
+
+// Checklist runs every close check for the month ending at periodEnd
+func (s *Service) Checklist(ctx context.Context, periodEnd time.Time) (*Report, error) {
+	periodStart := time.Date(periodEnd.Year(), periodEnd.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	prefs, err := s.qb.GetPreferences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get closing date: %w", err)
+	}
+
+	report := &Report{
+		PeriodStart:   periodStart.Format("2006-01-02"),
+		PeriodEnd:     periodEnd.Format("2006-01-02"),
+		BookCloseDate: prefs.AccountingInfoPrefs.BookCloseDate,
+		ReadyToClose:  true,
+		GeneratedAt:   time.Now().UTC(),
+	}
+	if closeDate, err := time.Parse("2006-01-02", report.BookCloseDate); err == nil {
+		report.Locked = !closeDate.Before(periodEnd)
+	}
+
+	for _, run := range checklist {
+		result, err := run(ctx, s.qb, periodStart, periodEnd)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status == StatusFail {
+			report.ReadyToClose = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report, nil
+}
+
+// Close sets the QuickBooks closing date to periodEnd after running the
+// checklist. Failing checks block the close unless force is set.
+func (s *Service) Close(ctx context.Context, periodEnd time.Time, force bool) (*Report, error) {
+	report, err := s.Checklist(ctx, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	if !report.ReadyToClose && !force {
+		return report, ErrNotReady
+	}
+
+	prefs, err := s.qb.SetBookCloseDate(ctx, report.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	report.BookCloseDate = prefs.AccountingInfoPrefs.BookCloseDate
+	report.Locked = true
+
+	return report, nil
+}
+
+// LastMonthEnd returns the last day of the month before now
+func LastMonthEnd(now time.Time) time.Time {
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return firstOfMonth.AddDate(0, 0, -1)
+}
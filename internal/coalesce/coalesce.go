@@ -0,0 +1,240 @@
+// coalesce/coalesce.go
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/gorilla/mux"
+)
+
+// Header tells clients a response was shared with an identical concurrent
+// request ("shared") or served from the micro-cache ("cached")
+const Header = "X-Coalesced"
+
+// defaultEntries bounds the micro-cache unless configured otherwise
+const defaultEntries = 1000
+
+// DefaultRoutes are the hot read routes coalesced by default, by
+// unversioned route template, with how long their responses are
+// micro-cached. Zero only coalesces concurrent requests.
+var DefaultRoutes = map[string]time.Duration{
+	"/api/invoices":                      2 * time.Second,
+	"/api/invoices/{id}":                 2 * time.Second,
+	"/api/customers":                     2 * time.Second,
+	"/api/customers/{id}":                2 * time.Second,
+	"/api/items":                         2 * time.Second,
+	"/api/items/{id}":                    2 * time.Second,
+	"/api/insights":                      10 * time.Second,
+	"/api/insights/cashflow-forecast":    10 * time.Second,
+	"/api/customers/{id}/metrics":        10 * time.Second,
+	"/api/budgets/{id}/variance":         10 * time.Second,
+	"/api/projects/{id}/profitability":   10 * time.Second,
+	"/api/inventory/low-stock":           10 * time.Second,
+	"/api/inventory/reorder-suggestions": 10 * time.Second,
+	"/api/close/checklist":               5 * time.Second,
+}
+
+// response is a recorded response
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// entry is a micro-cached response
+type entry struct {
+	realm   string
+	resp    *response
+	expires time.Time
+}
+
+// Coalescer collapses identical concurrent GET requests of a user into one
+// call of the handler, and so one QuickBooks call, and micro-caches the
+// response for a short, per-route time
+type Coalescer struct {
+	group group
+
+	mu     sync.Mutex
+	routes map[string]time.Duration
+	cache  *lru.Cache[string, *entry]
+}
+
+// NewCoalescer creates a new coalescer for routes
+func NewCoalescer(routes map[string]time.Duration) *Coalescer {
+	c := &Coalescer{
+		routes: make(map[string]time.Duration, len(routes)),
+		cache:  lru.New[string, *entry]("coalesce.responses", defaultEntries),
+	}
+	for template, ttl := range routes {
+		c.routes[template] = ttl
+	}
+	return c
+}
+
+// SetRoute coalesces requests of a route template, micro-caching their
+// responses for ttl; a negative ttl stops coalescing the route
+func (c *Coalescer) SetRoute(template string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ttl < 0 {
+		delete(c.routes, template)
+		return
+	}
+	c.routes[template] = ttl
+}
+
+// route returns the micro-cache time of the request's route, and whether it
+// is coalesced
+func (c *Coalescer) route(r *http.Request) (time.Duration, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return 0, false
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ttl, ok := c.routes[routing.StripVersion(template)]
+	return ttl, ok
+}
+
+// key identifies identical requests: same user, tenant, company, URL and
+// negotiated representation
+func key(r *http.Request) string {
+	realmID, _ := auth.GetCompanyID(r.Context())
+	parts := []string{
+		auth.GetUserID(r.Context()),
+		auth.GetTenantID(r.Context()),
+		realmID,
+		auth.GetRole(r.Context()),
+		r.URL.Path,
+		r.URL.Query().Encode(),
+		r.Header.Get("Accept"),
+		r.Header.Get("Accept-Language"),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Middleware coalesces GET requests of configured routes. Writes drop the
+// company's micro-cached responses so they are not read back stale.
+func (c *Coalescer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realmID, _ := auth.GetCompanyID(r.Context())
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			if r.Method != http.MethodHead && r.Method != http.MethodOptions {
+				c.purge(realmID)
+			}
+			return
+		}
+		ttl, ok := c.route(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		k := key(r)
+		noCache := strings.Contains(r.Header.Get("Cache-Control"), "no-cache")
+		if !noCache {
+			if resp := c.cached(k); resp != nil {
+				write(w, resp, "cached")
+				return
+			}
+		}
+
+		resp, shared := c.group.do(k, func() *response {
+			// The call serves every waiter, so one client going away must
+			// not cancel it
+			recorder := &recorder{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(context.WithoutCancel(r.Context())))
+			resp := &response{status: recorder.status, header: recorder.header, body: recorder.body.Bytes()}
+			if ttl > 0 && resp.status == http.StatusOK {
+				c.store(k, realmID, resp, ttl)
+			}
+			return resp
+		})
+		if shared && resp == nil {
+			// The call panicked; try again alone
+			next.ServeHTTP(w, r)
+			return
+		}
+		if shared {
+			write(w, resp, "shared")
+			return
+		}
+		write(w, resp, "")
+	})
+}
+
+// cached returns an unexpired micro-cached response
+func (c *Coalescer) cached(k string) *response {
+	e, ok := c.cache.Get(k)
+	if !ok {
+		return nil
+	}
+	if time.Now().After(e.expires) {
+		c.cache.Remove(k)
+		return nil
+	}
+	return e.resp
+}
+
+// store micro-caches a response; the least recently used are evicted when
+// the cache is full
+func (c *Coalescer) store(k, realmID string, resp *response, ttl time.Duration) {
+	c.cache.Add(k, &entry{realm: realmID, resp: resp, expires: time.Now().Add(ttl)})
+}
+
+// purge drops a company's micro-cached responses
+func (c *Coalescer) purge(realmID string) {
+	c.cache.RemoveFunc(func(_ string, e *entry) bool {
+		return e.realm == realmID
+	})
+}
+
+// write sends a recorded response, marking how it was served
+func write(w http.ResponseWriter, resp *response, how string) {
+	for key, values := range resp.header {
+		w.Header()[key] = values
+	}
+	if how != "" {
+		w.Header().Set(Header, how)
+	}
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// recorder captures a handler's response
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the captured headers
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader captures the status
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+// Write captures the body
+func (r *recorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
@@ -0,0 +1,44 @@
+// coalesce/group.go
+package coalesce
+
+import "sync"
+
+// call is a handler call in flight
+type call struct {
+	done chan struct{}
+	resp *response
+}
+
+// group runs one call per key at a time; callers arriving while it runs
+// share its response
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do runs fn for key unless a call for key is in flight, in which case it
+// waits for that call. shared reports whether the response came from
+// another caller's call.
+func (g *group) do(key string, fn func() *response) (resp *response, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.resp, true
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.resp = fn()
+	return c.resp, false
+}
@@ -0,0 +1,102 @@
+// commission/handler.go
+package commission
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for sales reps and commissions
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new commission handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidRep), errors.Is(err, ErrInvalidPeriod):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// GetRep returns the sales rep an invoice is credited to
+func (h *Handler) GetRep(w http.ResponseWriter, r *http.Request) {
+	assignment, err := h.service.Rep(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get sales rep: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(assignment)
+}
+
+// AssignRep tags an invoice with a sales rep, e.g. {"rep": "Jane Doe"}
+func (h *Handler) AssignRep(w http.ResponseWriter, r *http.Request) {
+	var req AssignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	assignment, err := h.service.AssignRep(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		http.Error(w, "Failed to assign sales rep: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(assignment)
+}
+
+// ClearRep removes an invoice's sales rep tag and returns the rep it is
+// credited to without it
+func (h *Handler) ClearRep(w http.ResponseWriter, r *http.Request) {
+	assignment, err := h.service.ClearRep(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to clear sales rep: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(assignment)
+}
+
+// GetReport returns the commission per sales rep for ?from= to ?to=,
+// optionally for one ?rep=
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := ReportOptions{Rep: query.Get("rep")}
+	var err error
+	if opts.From, err = time.Parse("2006-01-02", query.Get("from")); err != nil {
+		http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if opts.To, err = time.Parse("2006-01-02", query.Get("to")); err != nil {
+		http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Report(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to compute commissions: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
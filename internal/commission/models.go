@@ -0,0 +1,68 @@
+// commission/models.go
+package commission
+
+import "time"
+
+// Sources of an invoice's sales rep
+const (
+	SourceLocal       = "local"        // tagged through the API
+	SourceCustomField = "custom_field" // read from the invoice's rep custom field
+)
+
+// Assignment is the sales rep an invoice is credited to
+type Assignment struct {
+	InvoiceID string `json:"invoice_id"`
+	Rep       string `json:"rep,omitempty"`
+	Source    string `json:"source,omitempty"` // empty when the invoice has no rep
+}
+
+// AssignRequest tags an invoice with a sales rep, e.g. {"rep": "Jane Doe"}
+type AssignRequest struct {
+	Rep string `json:"rep"`
+}
+
+// ReportOptions selects the period and sales rep a report covers
+type ReportOptions struct {
+	From time.Time
+	To   time.Time
+	// Rep limits the reps reported to one; unassigned sales are reported
+	// regardless
+	Rep string
+}
+
+// Entry is the commission earned on one invoice, or on one payment of it
+// when commissions are earned on payment
+type Entry struct {
+	InvoiceID  string  `json:"invoice_id"`
+	DocNumber  string  `json:"doc_number,omitempty"`
+	Customer   string  `json:"customer,omitempty"`
+	PaymentID  string  `json:"payment_id,omitempty"`
+	Date       string  `json:"date"`
+	Amount     float64 `json:"amount"` // pre-tax amount commission is earned on
+	Commission float64 `json:"commission"`
+}
+
+// RepCommission is the commission a sales rep earned in the period
+type RepCommission struct {
+	Rep        string  `json:"rep"`
+	Rate       float64 `json:"rate"` // percent
+	Sales      float64 `json:"sales"`
+	Commission float64 `json:"commission"`
+	Entries    []Entry `json:"entries"`
+}
+
+// Unassigned sums the sales in the period no sales rep is credited with
+type Unassigned struct {
+	Sales      float64  `json:"sales"`
+	InvoiceIDs []string `json:"invoice_ids"`
+}
+
+// Report is the commission earned per sales rep in a period
+type Report struct {
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	Basis      string          `json:"basis"`
+	Reps       []RepCommission `json:"reps"`
+	Commission float64         `json:"commission"`
+	Unassigned Unassigned      `json:"unassigned"`
+}
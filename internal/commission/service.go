@@ -0,0 +1,398 @@
+// commission/service.go
+package commission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+var (
+	// ErrNotFound is returned for invoice IDs QuickBooks does not know
+	ErrNotFound = errors.New("invoice not found")
+	// ErrInvalidRep is returned for empty or overlong sales rep names
+	ErrInvalidRep = errors.New("sales rep must be 1 to 100 characters")
+	// ErrInvalidPeriod is returned for reports without a period or ending
+	// before they start
+	ErrInvalidPeriod = errors.New("from and to are required and to must not be before from")
+)
+
+// maxRepLength bounds sales rep names
+const maxRepLength = 100
+
+// idBatch bounds the invoice IDs looked up in one query
+const idBatch = 100
+
+// QuickBooks is the subset of the QuickBooks client used for commissions
+type QuickBooks interface {
+	query.Querier
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// invoiceFields are the invoice fields commissions are computed from
+type invoiceFields struct {
+	ID           string  `json:"Id"`
+	DocNumber    string  `json:"DocNumber"`
+	TxnDate      string  `json:"TxnDate"`
+	TotalAmt     float64 `json:"TotalAmt"`
+	TxnTaxDetail *struct {
+		TotalTax float64 `json:"TotalTax"`
+	} `json:"TxnTaxDetail"`
+	CustomerRef *struct {
+		Value string `json:"value"`
+		Name  string `json:"name"`
+	} `json:"CustomerRef"`
+	CustomField []struct {
+		Name        string `json:"Name"`
+		StringValue string `json:"StringValue"`
+	} `json:"CustomField"`
+}
+
+// preTax returns the invoice's amount before tax
+func (i *invoiceFields) preTax() float64 {
+	if i.TxnTaxDetail == nil {
+		return i.TotalAmt
+	}
+	return i.TotalAmt - i.TxnTaxDetail.TotalTax
+}
+
+// customer returns the invoice's customer name
+func (i *invoiceFields) customer() string {
+	if i.CustomerRef == nil {
+		return ""
+	}
+	return i.CustomerRef.Name
+}
+
+// customRep returns the sales rep in the named custom field
+func (i *invoiceFields) customRep(field string) string {
+	if field == "" {
+		return ""
+	}
+	for _, custom := range i.CustomField {
+		if strings.EqualFold(custom.Name, field) {
+			return strings.TrimSpace(custom.StringValue)
+		}
+	}
+	return ""
+}
+
+// paymentFields are the payment fields naming the invoices it pays
+type paymentFields struct {
+	ID      string `json:"Id"`
+	TxnDate string `json:"TxnDate"`
+	Line    []struct {
+		Amount    float64 `json:"Amount"`
+		LinkedTxn []struct {
+			TxnID   string `json:"TxnId"`
+			TxnType string `json:"TxnType"`
+		} `json:"LinkedTxn"`
+	} `json:"Line"`
+}
+
+// Service credits invoices to sales reps and reports the commission they
+// earn. An invoice's rep is the one tagged locally, or else the one in the
+// tenant's rep custom field.
+type Service struct {
+	store  *Store
+	config *tenantconfig.Service
+	qb     QuickBooks
+}
+
+// NewService creates a new commission service
+func NewService(store *Store, config *tenantconfig.Service, qb QuickBooks) *Service {
+	return &Service{
+		store:  store,
+		config: config,
+		qb:     qb,
+	}
+}
+
+// Rep returns the sales rep an invoice is credited to
+func (s *Service) Rep(ctx context.Context, invoiceID string) (*Assignment, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.config.Commissions(ctx, auth.GetTenantID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.readInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	reps, err := s.store.Reps(ctx, realmID, []string{invoice.ID})
+	if err != nil {
+		return nil, err
+	}
+	assignment := &Assignment{InvoiceID: invoice.ID}
+	if rep, ok := reps[invoice.ID]; ok {
+		assignment.Rep, assignment.Source = rep, SourceLocal
+	} else if rep := invoice.customRep(policy.RepField); rep != "" {
+		assignment.Rep, assignment.Source = rep, SourceCustomField
+	}
+	return assignment, nil
+}
+
+// AssignRep tags an invoice with a sales rep, taking precedence over its
+// rep custom field
+func (s *Service) AssignRep(ctx context.Context, invoiceID string, req *AssignRequest) (*Assignment, error) {
+	rep := strings.TrimSpace(req.Rep)
+	if rep == "" || len(rep) > maxRepLength {
+		return nil, ErrInvalidRep
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.readInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetRep(ctx, realmID, invoice.ID, rep); err != nil {
+		return nil, err
+	}
+	return &Assignment{InvoiceID: invoice.ID, Rep: rep, Source: SourceLocal}, nil
+}
+
+// ClearRep removes an invoice's local sales rep tag; its rep custom field,
+// if any, applies again
+func (s *Service) ClearRep(ctx context.Context, invoiceID string) (*Assignment, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ClearRep(ctx, realmID, invoiceID); err != nil {
+		return nil, err
+	}
+	return s.Rep(ctx, invoiceID)
+}
+
+// Report computes the commission each sales rep earned in a period under
+// the tenant's commission policy: on invoices dated in it, or on payments
+// received in it
+func (s *Service) Report(ctx context.Context, opts ReportOptions) (*Report, error) {
+	if opts.From.IsZero() || opts.To.IsZero() || opts.To.Before(opts.From) {
+		return nil, ErrInvalidPeriod
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.config.Commissions(ctx, auth.GetTenantID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// sales are the pre-tax amounts commission is earned on, each for an
+	// invoice and, on payment, the payment that paid it
+	type sale struct {
+		invoice *invoiceFields
+		payment string
+		date    string
+		amount  float64
+	}
+	var sales []sale
+	var invoices map[string]*invoiceFields
+	from, to := opts.From.Format("2006-01-02"), opts.To.Format("2006-01-02")
+
+	switch policy.BasisOrDefault() {
+	case tenantconfig.CommissionOnPayment:
+		payments, err := query.All[paymentFields](ctx, s.qb, query.Select("Payment").
+			Where("TxnDate", ">=", from).Where("TxnDate", "<=", to).OrderBy("TxnDate"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list payments: %w", err)
+		}
+		var ids []string
+		seen := make(map[string]bool)
+		for _, payment := range payments {
+			for _, line := range payment.Line {
+				for _, txn := range line.LinkedTxn {
+					if txn.TxnType == "Invoice" && !seen[txn.TxnID] {
+						seen[txn.TxnID] = true
+						ids = append(ids, txn.TxnID)
+					}
+				}
+			}
+		}
+		if invoices, err = s.invoicesByID(ctx, ids); err != nil {
+			return nil, err
+		}
+		for _, payment := range payments {
+			for _, line := range payment.Line {
+				for _, txn := range line.LinkedTxn {
+					invoice, ok := invoices[txn.TxnID]
+					if txn.TxnType != "Invoice" || !ok || invoice.TotalAmt == 0 {
+						continue
+					}
+					// Payments cover tax too; commission is earned on the
+					// pre-tax share of what was paid
+					amount := line.Amount * invoice.preTax() / invoice.TotalAmt
+					sales = append(sales, sale{invoice: invoice, payment: payment.ID, date: payment.TxnDate, amount: amount})
+				}
+			}
+		}
+	default:
+		list, err := query.All[invoiceFields](ctx, s.qb, query.Select("Invoice").
+			Where("TxnDate", ">=", from).Where("TxnDate", "<=", to).OrderBy("TxnDate"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list invoices: %w", err)
+		}
+		invoices = make(map[string]*invoiceFields, len(list))
+		for i := range list {
+			invoice := &list[i]
+			invoices[invoice.ID] = invoice
+			sales = append(sales, sale{invoice: invoice, date: invoice.TxnDate, amount: invoice.preTax()})
+		}
+	}
+
+	ids := make([]string, 0, len(invoices))
+	for id := range invoices {
+		ids = append(ids, id)
+	}
+	local, err := s.store.Reps(ctx, realmID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		From:       from,
+		To:         to,
+		Basis:      policy.BasisOrDefault(),
+		Reps:       []RepCommission{},
+		Unassigned: Unassigned{InvoiceIDs: []string{}},
+	}
+	byRep := make(map[string]*RepCommission)
+	unassigned := make(map[string]bool)
+	for _, sale := range sales {
+		rep, ok := local[sale.invoice.ID]
+		if !ok {
+			rep = sale.invoice.customRep(policy.RepField)
+		}
+		if rep == "" {
+			report.Unassigned.Sales += sale.amount
+			if !unassigned[sale.invoice.ID] {
+				unassigned[sale.invoice.ID] = true
+				report.Unassigned.InvoiceIDs = append(report.Unassigned.InvoiceIDs, sale.invoice.ID)
+			}
+			continue
+		}
+		if opts.Rep != "" && rep != opts.Rep {
+			continue
+		}
+
+		totals, ok := byRep[rep]
+		if !ok {
+			totals = &RepCommission{Rep: rep, Rate: policy.Rate(rep), Entries: []Entry{}}
+			byRep[rep] = totals
+		}
+		commission := sale.amount * totals.Rate / 100
+		totals.Sales += sale.amount
+		totals.Commission += commission
+		totals.Entries = append(totals.Entries, Entry{
+			InvoiceID:  sale.invoice.ID,
+			DocNumber:  sale.invoice.DocNumber,
+			Customer:   sale.invoice.customer(),
+			PaymentID:  sale.payment,
+			Date:       sale.date,
+			Amount:     roundCents(sale.amount),
+			Commission: roundCents(commission),
+		})
+	}
+
+	for _, totals := range byRep {
+		report.Commission += totals.Commission
+		totals.Sales = roundCents(totals.Sales)
+		totals.Commission = roundCents(totals.Commission)
+		report.Reps = append(report.Reps, *totals)
+	}
+	sort.Slice(report.Reps, func(i, j int) bool { return report.Reps[i].Rep < report.Reps[j].Rep })
+	report.Commission = roundCents(report.Commission)
+	report.Unassigned.Sales = roundCents(report.Unassigned.Sales)
+	return report, nil
+}
+
+// readInvoice reads an invoice's commission fields
+func (s *Service) readInvoice(ctx context.Context, invoiceID string) (*invoiceFields, error) {
+	raw, err := s.qb.Read(ctx, "Invoice", invoiceID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	var invoice invoiceFields
+	if err := decode(raw, &invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// invoicesByID reads invoices by ID, idBatch at a time; IDs QuickBooks does
+// not know are left out
+func (s *Service) invoicesByID(ctx context.Context, ids []string) (map[string]*invoiceFields, error) {
+	invoices := make(map[string]*invoiceFields, len(ids))
+	var batch []interface{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		list, err := query.All[invoiceFields](ctx, s.qb, query.Select("Invoice").WhereIn("Id", batch...))
+		if err != nil {
+			return fmt.Errorf("failed to read paid invoices: %w", err)
+		}
+		for i := range list {
+			invoices[list[i].ID] = &list[i]
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for _, id := range ids {
+		if !query.IsID(id) {
+			continue
+		}
+		batch = append(batch, id)
+		if len(batch) == idBatch {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// decode converts a record read from QuickBooks into fields
+func decode(raw map[string]json.RawMessage, fields interface{}) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, fields); err != nil {
+		return fmt.Errorf("failed to decode invoice: %w", err)
+	}
+	return nil
+}
+
+// roundCents rounds an amount to cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// notFound maps QuickBooks' "Object Not Found" fault (code 610) to
+// ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+// commission/store.go
+package commission
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Store keeps the sales reps invoices are tagged with locally, per company
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new sales rep store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// repsKey is the hash of a company's invoice IDs to their sales reps
+func (s *Store) repsKey(realmID string) string {
+	return fmt.Sprintf("%s:commission:%s:reps", s.prefix, realmID)
+}
+
+// SetRep tags an invoice with a sales rep
+func (s *Store) SetRep(ctx context.Context, realmID, invoiceID, rep string) error {
+	if err := s.client.HSet(ctx, s.repsKey(realmID), invoiceID, rep).Err(); err != nil {
+		return fmt.Errorf("failed to save sales rep: %w", err)
+	}
+	return nil
+}
+
+// ClearRep removes an invoice's sales rep tag
+func (s *Store) ClearRep(ctx context.Context, realmID, invoiceID string) error {
+	if err := s.client.HDel(ctx, s.repsKey(realmID), invoiceID).Err(); err != nil {
+		return fmt.Errorf("failed to clear sales rep: %w", err)
+	}
+	return nil
+}
+
+// Reps returns the sales reps of several invoices by ID; untagged invoices
+// are left out
+func (s *Store) Reps(ctx context.Context, realmID string, invoiceIDs []string) (map[string]string, error) {
+	reps := make(map[string]string, len(invoiceIDs))
+	if len(invoiceIDs) == 0 {
+		return reps, nil
+	}
+
+	values, err := s.client.HMGet(ctx, s.repsKey(realmID), invoiceIDs...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sales reps: %w", err)
+	}
+	for i, value := range values {
+		if rep, ok := value.(string); ok && rep != "" {
+			reps[invoiceIDs[i]] = rep
+		}
+	}
+	return reps, nil
+}
@@ -0,0 +1,165 @@
+// compliance/check.go
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// Check outcomes
+const (
+	Pass = "pass"
+	Warn = "warn" // allowed, but reviewers will ask about it
+	Fail = "fail"
+)
+
+// Settings are the parts of the configuration Intuit's App Store checklist
+// covers
+type Settings struct {
+	Enabled      bool
+	RedirectURIs []string
+	Scopes       []string
+	APIBaseURL   string
+	// WebhookURL is the webhook endpoint registered with the app
+	WebhookURL string
+	// DisconnectURL is the disconnect landing page registered with the app
+	DisconnectURL string
+}
+
+// Result is the outcome of one checklist item
+type Result struct {
+	Name        string `json:"name"`
+	Requirement string `json:"requirement"`
+	Status      string `json:"status"`
+	Detail      string `json:"detail,omitempty"`
+}
+
+// Report is the outcome of the whole checklist
+type Report struct {
+	Passed    bool      `json:"passed"` // no item failed
+	CheckedAt time.Time `json:"checked_at"`
+	Results   []Result  `json:"results"`
+}
+
+// Checker verifies the configuration against Intuit's App Store checklist
+type Checker struct {
+	settings Settings
+	client   *http.Client
+}
+
+// NewChecker creates a new checklist verifier
+func NewChecker(settings Settings) *Checker {
+	return &Checker{
+		settings: settings,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Check runs every checklist item
+func (c *Checker) Check(ctx context.Context) *Report {
+	report := &Report{Passed: true, CheckedAt: time.Now().UTC()}
+	add := func(name, requirement, status, detail string) {
+		report.Results = append(report.Results, Result{Name: name, Requirement: requirement, Status: status, Detail: detail})
+		if status == Fail {
+			report.Passed = false
+		}
+	}
+
+	if c.settings.Enabled {
+		add("compliance_mode", "App Store behaviors are enabled", Pass, "")
+	} else {
+		add("compliance_mode", "App Store behaviors are enabled", Fail, "compliance mode is off")
+	}
+
+	status, detail := c.checkRedirectURIs()
+	add("redirect_uris", "Redirect URIs use HTTPS on a public host", status, detail)
+
+	if auth.HasScope(c.settings.Scopes, auth.ScopeAccounting) {
+		add("accounting_scope", "The accounting scope is requested", Pass, "")
+	} else {
+		add("accounting_scope", "The accounting scope is requested", Fail, auth.ScopeAccounting+" is not in the configured scopes")
+	}
+	if auth.HasScope(c.settings.Scopes, auth.ScopeOpenID) || c.settings.Enabled {
+		add("openid", "The user's identity is requested with OpenID Connect and verified", Pass, "")
+	} else {
+		add("openid", "The user's identity is requested with OpenID Connect and verified", Fail, "openid is not requested; compliance mode adds it")
+	}
+
+	if strings.Contains(c.settings.APIBaseURL, "sandbox") {
+		add("production_api", "The production QuickBooks API is used", Warn, c.settings.APIBaseURL+" is a sandbox")
+	} else {
+		add("production_api", "The production QuickBooks API is used", Pass, "")
+	}
+
+	status, detail = c.checkEndpoint(ctx, c.settings.DisconnectURL, http.MethodGet)
+	add("disconnect_url", "A disconnect landing page is reachable", status, detail)
+	status, detail = c.checkEndpoint(ctx, c.settings.WebhookURL, http.MethodPost)
+	add("webhook_endpoint", "The webhook endpoint is reachable over HTTPS", status, detail)
+	return report
+}
+
+// checkRedirectURIs requires every redirect URI to use HTTPS on a host
+// other than localhost or an IP address
+func (c *Checker) checkRedirectURIs() (string, string) {
+	if len(c.settings.RedirectURIs) == 0 {
+		return Fail, "no redirect URI is configured"
+	}
+	var problems []string
+	for _, uri := range c.settings.RedirectURIs {
+		u, err := url.Parse(uri)
+		switch {
+		case err != nil || u.Host == "":
+			problems = append(problems, uri+" is not a valid URL")
+		case u.Scheme != "https":
+			problems = append(problems, uri+" does not use HTTPS")
+		case u.Hostname() == "localhost" || net.ParseIP(u.Hostname()) != nil:
+			problems = append(problems, uri+" is not a public host name")
+		}
+	}
+	if len(problems) > 0 {
+		return Fail, strings.Join(problems, "; ")
+	}
+	return Pass, ""
+}
+
+// checkEndpoint requires an HTTPS URL that answers without a server error.
+// Client errors pass: the webhook endpoint rightly rejects unsigned calls.
+func (c *Checker) checkEndpoint(ctx context.Context, endpoint, method string) (string, string) {
+	if endpoint == "" {
+		return Fail, "not configured"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return Fail, endpoint + " is not a valid URL"
+	}
+	if u.Scheme != "https" {
+		return Fail, endpoint + " does not use HTTPS"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, strings.NewReader("{}"))
+	if err != nil {
+		return Fail, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Fail, fmt.Sprintf("%s is unreachable: %v", endpoint, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return Fail, fmt.Sprintf("%s answered with status %d", endpoint, resp.StatusCode)
+	}
+	return Pass, fmt.Sprintf("status %d", resp.StatusCode)
+}
@@ -0,0 +1,132 @@
+// compliance/errors.go
+package compliance
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// tidPattern finds the intuit_tid QuickBooks errors carry in their message
+var tidPattern = regexp.MustCompile(`\s*\(intuit_tid ([^)\s]+)\)`)
+
+// ErrorBody is the JSON form of every error response in compliance mode
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes one error
+type ErrorDetail struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// IntuitTID identifies the failed QuickBooks call for Intuit support
+	IntuitTID string `json:"intuit_tid,omitempty"`
+}
+
+// errorCode returns the machine-readable code of an HTTP status, e.g.
+// "not_found"
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// ErrorMiddleware rewrites plain-text error responses, such as those of
+// http.Error, as JSON with a stable code, a readable message and the
+// intuit_tid of the failed QuickBooks call, the error format Intuit expects
+// of App Store apps. Other responses pass through unbuffered.
+func ErrorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &errorWriter{ResponseWriter: w}
+		next.ServeHTTP(writer, r)
+		writer.finish()
+	})
+}
+
+// errorWriter captures plain-text error bodies and passes everything else
+// through
+type errorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int
+	capturing   bool
+	body        bytes.Buffer
+}
+
+// WriteHeader starts capturing plain-text errors
+func (e *errorWriter) WriteHeader(status int) {
+	if e.wroteHeader {
+		return
+	}
+	e.wroteHeader = true
+	e.status = status
+	contentType := e.Header().Get("Content-Type")
+	if status >= 400 && (contentType == "" || strings.HasPrefix(contentType, "text/plain")) {
+		e.capturing = true
+		return
+	}
+	e.ResponseWriter.WriteHeader(status)
+}
+
+// Write captures or passes through the body
+func (e *errorWriter) Write(p []byte) (int, error) {
+	if !e.wroteHeader {
+		e.WriteHeader(http.StatusOK)
+	}
+	if e.capturing {
+		return e.body.Write(p)
+	}
+	return e.ResponseWriter.Write(p)
+}
+
+// Flush passes through to streaming responses
+func (e *errorWriter) Flush() {
+	if e.capturing {
+		return
+	}
+	if flusher, ok := e.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack passes through to WebSocket upgrades
+func (e *errorWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := e.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// finish writes a captured error as JSON
+func (e *errorWriter) finish() {
+	if !e.capturing {
+		return
+	}
+
+	message := strings.TrimSpace(e.body.String())
+	detail := ErrorDetail{Status: e.status, Code: errorCode(e.status)}
+	if match := tidPattern.FindStringSubmatch(message); match != nil {
+		detail.IntuitTID = match[1]
+		message = strings.TrimSpace(tidPattern.ReplaceAllString(message, ""))
+	}
+	if message == "" {
+		message = http.StatusText(e.status)
+	}
+	detail.Message = message
+
+	header := e.ResponseWriter.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	e.ResponseWriter.WriteHeader(e.status)
+	json.NewEncoder(e.ResponseWriter).Encode(ErrorBody{Error: detail})
+}
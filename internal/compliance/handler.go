@@ -0,0 +1,29 @@
+// compliance/handler.go
+package compliance
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler provides HTTP handlers for the App Store self-check
+type Handler struct {
+	checker *Checker
+}
+
+// NewHandler creates a new compliance handler
+func NewHandler(checker *Checker) *Handler {
+	return &Handler{
+		checker: checker,
+	}
+}
+
+// SelfCheck verifies the configuration against Intuit's App Store
+// checklist. The report is returned whether or not it passed.
+func (h *Handler) SelfCheck(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Check(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
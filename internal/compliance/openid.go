@@ -0,0 +1,201 @@
+// compliance/openid.go
+package compliance
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+const (
+	// IntuitIssuer is the issuer of Intuit's ID tokens
+	IntuitIssuer = "https://oauth.platform.intuit.com/op/v1"
+	// IntuitJWKSURL serves the keys Intuit signs ID tokens with
+	IntuitJWKSURL = "https://oauth.platform.intuit.com/op/v1/jwks"
+	// keysTTL is how long signing keys are cached before being fetched again
+	keysTTL = 24 * time.Hour
+	// clockSkew is how far the token's expiry may be behind our clock
+	clockSkew = 5 * time.Minute
+)
+
+// OpenID verifies Intuit ID tokens against Intuit's published signing keys
+type OpenID struct {
+	issuer  string
+	jwksURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewOpenID creates a verifier of ID tokens from issuer, signed with the
+// keys served at jwksURL
+func NewOpenID(issuer, jwksURL string) *OpenID {
+	return &OpenID{
+		issuer:  issuer,
+		jwksURL: jwksURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		keys:    make(map[string]*rsa.PublicKey),
+	}
+}
+
+// audience is an ID token's aud claim, which may be a string or a list
+type audience []string
+
+// UnmarshalJSON accepts either form
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// VerifyIDToken verifies an ID token's RS256 signature, issuer, audience
+// and expiry and returns its claims
+func (o *OpenID) VerifyIDToken(ctx context.Context, idToken, clientID string) (*auth.IDClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed ID token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unexpected ID token algorithm %q", header.Alg)
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("ID token signature is invalid")
+	}
+
+	var claims struct {
+		auth.IDClaims
+		Audience audience `json:"aud"`
+		Expiry   int64    `json:"exp"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	if claims.Issuer != o.issuer {
+		return nil, fmt.Errorf("ID token issued by %q", claims.Issuer)
+	}
+	found := false
+	for _, aud := range claims.Audience {
+		if aud == clientID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.New("ID token was issued to another app")
+	}
+	claims.ExpiresAt = time.Unix(claims.Expiry, 0).UTC()
+	if time.Now().After(claims.ExpiresAt.Add(clockSkew)) {
+		return nil, errors.New("ID token has expired")
+	}
+	return &claims.IDClaims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the signing key with ID kid, fetching the keys when they are
+// stale or kid is unknown, e.g. after Intuit rotated them
+func (o *OpenID) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if key, ok := o.keys[kid]; ok && time.Since(o.fetched) < keysTTL {
+		return key, nil
+	}
+	if err := o.fetch(ctx); err != nil {
+		return nil, err
+	}
+	key, ok := o.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown ID token signing key %q", kid)
+	}
+	return key, nil
+}
+
+// fetch replaces the cached signing keys with those currently published
+func (o *OpenID) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create signing keys request: %w", err)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ID token signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch ID token signing keys: status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("failed to parse ID token signing keys: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	o.keys = keys
+	o.fetched = time.Now()
+	return nil
+}
@@ -0,0 +1,48 @@
+// connstats/handler.go
+package connstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// maxReportDays bounds the period of a report to what is kept
+const maxReportDays = 90
+
+// Handler provides HTTP handlers for connection analytics
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new connection analytics handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetAnalytics returns connections added and lost per day, the refresh
+// success rate, average token age and connected realms by QuickBooks
+// edition, for the last 30 days or ?days=N up to 90
+func (h *Handler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxReportDays {
+			http.Error(w, "days must be between 1 and 90", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	report, err := h.service.Report(r.Context(), days)
+	if err != nil {
+		http.Error(w, "Failed to get connection analytics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
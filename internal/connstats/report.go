@@ -0,0 +1,207 @@
+// connstats/report.go
+package connstats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+const (
+	// editionTTL is how long a company's edition is trusted before it is
+	// read again, e.g. after an upgrade
+	editionTTL = 30 * 24 * time.Hour
+	// maxEditionReads bounds the editions read from QuickBooks per report;
+	// the rest are read by later reports
+	maxEditionReads = 25
+	// unknownEdition counts companies whose edition has not been read
+	unknownEdition = "unknown"
+)
+
+// CompanyInfoSource reads the current company's information
+type CompanyInfoSource interface {
+	GetCompanyInfo(ctx context.Context) (*qbclient.CompanyInfo, error)
+}
+
+// Day is the connection activity of one day
+type Day struct {
+	Date            string           `json:"date"`
+	Added           int64            `json:"added"`
+	Lost            int64            `json:"lost"`
+	LostByReason    map[string]int64 `json:"lost_by_reason,omitempty"`
+	Refreshes       int64            `json:"refreshes"`
+	RefreshFailures int64            `json:"refresh_failures"`
+}
+
+// Report is the health of QuickBooks connections across every tenant
+type Report struct {
+	From               string  `json:"from"`
+	To                 string  `json:"to"`
+	Days               []Day   `json:"days"`
+	Added              int64   `json:"added"`
+	Lost               int64   `json:"lost"`
+	Refreshes          int64   `json:"refreshes"`
+	RefreshFailures    int64   `json:"refresh_failures"`
+	RefreshSuccessRate float64 `json:"refresh_success_rate"` // 1 when nothing was refreshed
+	Connected          int     `json:"connected"`
+	// AvgConnectionAgeDays is the mean time since connected companies were
+	// connected
+	AvgConnectionAgeDays float64 `json:"avg_connection_age_days"`
+	// AvgTokenAgeHours is the mean age of connected companies' current
+	// refresh tokens
+	AvgTokenAgeHours float64          `json:"avg_token_age_hours"`
+	Editions         map[string]int64 `json:"realms_by_edition"`
+}
+
+// Service reports connection statistics, reading each company's edition
+// from QuickBooks as needed
+type Service struct {
+	stats   *Stats
+	company CompanyInfoSource
+}
+
+// NewService creates a new connection statistics service
+func NewService(stats *Stats, company CompanyInfoSource) *Service {
+	return &Service{
+		stats:   stats,
+		company: company,
+	}
+}
+
+// Report returns connection activity over the days up to today and the
+// state of the companies connected now
+func (s *Service) Report(ctx context.Context, days int) (*Report, error) {
+	now := time.Now().UTC()
+	report := &Report{
+		From:     now.AddDate(0, 0, -(days - 1)).Format(dayLayout),
+		To:       now.Format(dayLayout),
+		Days:     make([]Day, 0, days),
+		Editions: make(map[string]int64),
+	}
+
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i)
+		fields, err := s.stats.client.HGetAll(ctx, s.stats.dayKey(date)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get connection counts: %w", err)
+		}
+		day := Day{Date: date.Format(dayLayout)}
+		for field, value := range fields {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			switch {
+			case field == "added":
+				day.Added = n
+			case field == "lost":
+				day.Lost = n
+			case field == "refresh_ok":
+				day.Refreshes += n
+			case field == "refresh_failed":
+				day.Refreshes += n
+				day.RefreshFailures = n
+			case strings.HasPrefix(field, "lost:"):
+				if day.LostByReason == nil {
+					day.LostByReason = make(map[string]int64)
+				}
+				day.LostByReason[strings.TrimPrefix(field, "lost:")] = n
+			}
+		}
+		report.Added += day.Added
+		report.Lost += day.Lost
+		report.Refreshes += day.Refreshes
+		report.RefreshFailures += day.RefreshFailures
+		report.Days = append(report.Days, day)
+	}
+	report.RefreshSuccessRate = 1
+	if report.Refreshes > 0 {
+		report.RefreshSuccessRate = float64(report.Refreshes-report.RefreshFailures) / float64(report.Refreshes)
+	}
+
+	realms, err := s.connected(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.resolveEditions(ctx, realms)
+
+	var connectionAge, tokenAge time.Duration
+	var connectionAged, tokenAged int
+	for _, realm := range realms {
+		if !realm.ConnectedAt.IsZero() {
+			connectionAge += now.Sub(realm.ConnectedAt)
+			connectionAged++
+		}
+		if !realm.RefreshIssuedAt.IsZero() {
+			tokenAge += now.Sub(realm.RefreshIssuedAt)
+			tokenAged++
+		}
+		edition := realm.Edition
+		if edition == "" {
+			edition = unknownEdition
+		}
+		report.Editions[edition]++
+	}
+	report.Connected = len(realms)
+	if connectionAged > 0 {
+		report.AvgConnectionAgeDays = connectionAge.Hours() / 24 / float64(connectionAged)
+	}
+	if tokenAged > 0 {
+		report.AvgTokenAgeHours = tokenAge.Hours() / float64(tokenAged)
+	}
+	return report, nil
+}
+
+// connected returns the companies connected now, longest connected first
+func (s *Service) connected(ctx context.Context) ([]*Realm, error) {
+	all, err := s.stats.client.HGetAll(ctx, s.stats.realmsKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list realm connections: %w", err)
+	}
+	realms := make([]*Realm, 0, len(all))
+	for _, data := range all {
+		var realm Realm
+		if err := json.Unmarshal([]byte(data), &realm); err != nil || realm.LostAt != nil {
+			continue
+		}
+		realms = append(realms, &realm)
+	}
+	sort.Slice(realms, func(i, j int) bool {
+		return realms[i].ConnectedAt.Before(realms[j].ConnectedAt)
+	})
+	return realms, nil
+}
+
+// resolveEditions reads from QuickBooks the editions of companies whose
+// edition is unknown or stale, as the user who connected them. Failures
+// leave the edition as it was.
+func (s *Service) resolveEditions(ctx context.Context, realms []*Realm) {
+	reads := 0
+	for _, realm := range realms {
+		if reads == maxEditionReads {
+			return
+		}
+		if realm.EditionAt != nil && time.Since(*realm.EditionAt) < editionTTL {
+			continue
+		}
+		reads++
+
+		realmCtx := auth.WithIdentity(ctx, realm.UserID, realm.TenantID, realm.RealmID)
+		info, err := s.company.GetCompanyInfo(realmCtx)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to read company edition", "realm_id", realm.RealmID, "error", err)
+			continue
+		}
+		now := time.Now().UTC()
+		realm.Edition = info.Edition()
+		realm.EditionAt = &now
+		if err := s.stats.save(ctx, realm); err != nil {
+			logging.FromContext(ctx).Warn("failed to save company edition", "realm_id", realm.RealmID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// connstats/stats.go
+package connstats
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/go-redis/redis/v8"
+)
+
+// dailyRetention is how long daily connection counts are kept
+const dailyRetention = 90 * 24 * time.Hour
+
+// dayLayout formats the days connection counts are kept in
+const dayLayout = "2006-01-02"
+
+// Realm is what is known of one company's connection
+type Realm struct {
+	RealmID  string `json:"realm_id"`
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id,omitempty"`
+	// ConnectedAt is when the company was last connected
+	ConnectedAt time.Time `json:"connected_at"`
+	// RefreshIssuedAt is when QuickBooks last issued a new refresh token,
+	// which it rotates, so the token's age is measured from here
+	RefreshIssuedAt time.Time `json:"refresh_issued_at"`
+	// Fingerprint identifies the current refresh token without keeping it
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	LastRefresh *time.Time `json:"last_refresh_at,omitempty"`
+	Edition     string     `json:"edition,omitempty"`
+	EditionAt   *time.Time `json:"edition_at,omitempty"`
+	LostAt      *time.Time `json:"lost_at,omitempty"`
+	LostReason  string     `json:"lost_reason,omitempty"`
+}
+
+// Stats records QuickBooks connections added and lost, token refreshes and
+// the age of each company's refresh token. It implements
+// auth.ConnectionEvents.
+type Stats struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStats creates new connection statistics
+func NewStats(client redis.UniversalClient, prefix string) *Stats {
+	return &Stats{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// dayKey returns the hash of the connection counts of a day
+func (s *Stats) dayKey(day time.Time) string {
+	return fmt.Sprintf("%s:connstats:day:%s", s.prefix, day.UTC().Format(dayLayout))
+}
+
+// realmsKey returns the hash of every company's connection by realm
+func (s *Stats) realmsKey() string {
+	return fmt.Sprintf("%s:connstats:realms", s.prefix)
+}
+
+// fingerprint identifies a refresh token
+func fingerprint(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:8])
+}
+
+// realm returns a company's connection, or nil if none is recorded
+func (s *Stats) realm(ctx context.Context, realmID string) (*Realm, error) {
+	data, err := s.client.HGet(ctx, s.realmsKey(), realmID).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get realm connection: %w", err)
+	}
+	var realm Realm
+	if err := json.Unmarshal(data, &realm); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal realm connection: %w", err)
+	}
+	return &realm, nil
+}
+
+// Connection returns the user and tenant a company is connected through,
+// or an empty user ID if it is not connected. It implements
+// qbwebhook.Connections.
+func (s *Stats) Connection(ctx context.Context, realmID string) (userID, tenantID string, err error) {
+	realm, err := s.realm(ctx, realmID)
+	if err != nil || realm == nil || realm.LostAt != nil {
+		return "", "", err
+	}
+	return realm.UserID, realm.TenantID, nil
+}
+
+// save stores a company's connection and counts fields of today's counts
+func (s *Stats) save(ctx context.Context, realm *Realm, counts ...string) error {
+	data, err := json.Marshal(realm)
+	if err != nil {
+		return fmt.Errorf("failed to marshal realm connection: %w", err)
+	}
+	key := s.dayKey(time.Now())
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.realmsKey(), realm.RealmID, data)
+		for _, field := range counts {
+			pipe.HIncrBy(ctx, key, field, 1)
+		}
+		if len(counts) > 0 {
+			pipe.Expire(ctx, key, dailyRetention)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record connection event: %w", err)
+	}
+	return nil
+}
+
+// Connected counts a company connected, unless it already was
+func (s *Stats) Connected(ctx context.Context, userID string, token *auth.OAuthToken) error {
+	if token.RealmID == "" {
+		return nil
+	}
+	realm, err := s.realm(ctx, token.RealmID)
+	if err != nil {
+		return err
+	}
+	var counts []string
+	if realm == nil || realm.LostAt != nil {
+		realm = &Realm{RealmID: token.RealmID}
+		counts = append(counts, "added")
+	}
+
+	now := time.Now().UTC()
+	realm.UserID = userID
+	realm.TenantID = auth.GetTenantID(ctx)
+	realm.ConnectedAt = now
+	realm.RefreshIssuedAt = now
+	realm.Fingerprint = fingerprint(token.RefreshToken)
+	return s.save(ctx, realm, counts...)
+}
+
+// Refreshed counts a token refresh and notes a newly issued refresh token
+func (s *Stats) Refreshed(ctx context.Context, userID string, token *auth.OAuthToken, refreshErr error) error {
+	if token.RealmID == "" {
+		return nil
+	}
+	realm, err := s.realm(ctx, token.RealmID)
+	if err != nil {
+		return err
+	}
+	if realm == nil {
+		// Connected before statistics were kept, so when is unknown
+		realm = &Realm{RealmID: token.RealmID, UserID: userID, TenantID: auth.GetTenantID(ctx)}
+	}
+
+	if refreshErr != nil {
+		return s.save(ctx, realm, "refresh_failed")
+	}
+	now := time.Now().UTC()
+	realm.LastRefresh = &now
+	if id := fingerprint(token.RefreshToken); id != realm.Fingerprint {
+		realm.Fingerprint = id
+		realm.RefreshIssuedAt = now
+	}
+	return s.save(ctx, realm, "refresh_ok")
+}
+
+// Disconnected counts a company's connection lost, unless it already was
+func (s *Stats) Disconnected(ctx context.Context, userID, realmID, reason string) error {
+	if realmID == "" {
+		return nil
+	}
+	realm, err := s.realm(ctx, realmID)
+	if err != nil {
+		return err
+	}
+	if realm == nil {
+		realm = &Realm{RealmID: realmID, UserID: userID}
+	} else if realm.LostAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	realm.LostAt = &now
+	realm.LostReason = reason
+	realm.Fingerprint = ""
+	return s.save(ctx, realm, "lost", "lost:"+reason)
+}
@@ -0,0 +1,154 @@
+// creditmemo/handler.go
+package creditmemo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+	"github.com/gorilla/mux"
+)
+
+// Servicer is the credit memo service the handler serves. *Service implements
+// it; tests can substitute a fake.
+type Servicer interface {
+	List(ctx context.Context, opts ListOptions) ([]CreditMemo, error)
+	Get(ctx context.Context, id string) (*CreditMemo, error)
+	Create(ctx context.Context, memo *CreditMemo) (*CreditMemo, error)
+	Unapplied(ctx context.Context, customerID string) ([]CustomerCredit, error)
+	Apply(ctx context.Context, id string, opts ApplyOptions) (*Application, error)
+}
+
+// Handler provides HTTP handlers for credit memos
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new credit memo handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNoCredit), errors.Is(err, writelock.ErrTimeout):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidCreditMemo), errors.Is(err, ErrInvalidApplication):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// listOptions parses the list query: ?customer_id= filters the list, and
+// ?limit= and ?offset= page it
+func listOptions(r *http.Request) (ListOptions, error) {
+	query := r.URL.Query()
+	opts := ListOptions{CustomerID: query.Get("customer_id"), Limit: defaultLimit}
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil || opts.Limit < 1 {
+			return opts, errors.New("limit must be a positive number")
+		}
+		if opts.Limit > maxLimit {
+			opts.Limit = maxLimit
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil || opts.Offset < 0 {
+			return opts, errors.New("offset must not be negative")
+		}
+	}
+	return opts, nil
+}
+
+// ListCreditMemos lists credit memos, newest first
+func (h *Handler) ListCreditMemos(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	memos, err := h.service.List(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list credit memos: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(memos)
+}
+
+// GetCreditMemo returns a credit memo
+func (h *Handler) GetCreditMemo(w http.ResponseWriter, r *http.Request) {
+	memo, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get credit memo: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(memo)
+}
+
+// CreateCreditMemo creates a credit memo
+func (h *Handler) CreateCreditMemo(w http.ResponseWriter, r *http.Request) {
+	var memo CreditMemo
+	if err := json.NewDecoder(r.Body).Decode(&memo); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &memo)
+	if err != nil {
+		http.Error(w, "Failed to create credit memo: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// ApplyCreditMemo applies a credit memo's remaining credit to open invoices.
+// The body may list the invoices and amounts; without it the credit goes to
+// the customer's oldest open invoices.
+func (h *Handler) ApplyCreditMemo(w http.ResponseWriter, r *http.Request) {
+	var opts ApplyOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	application, err := h.service.Apply(r.Context(), mux.Vars(r)["id"], opts)
+	if err != nil {
+		http.Error(w, "Failed to apply credit memo: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(application)
+}
+
+// ListUnapplied lists credit left per customer, optionally only the
+// ?customer_id= customer's
+func (h *Handler) ListUnapplied(w http.ResponseWriter, r *http.Request) {
+	credits, err := h.service.Unapplied(r.Context(), r.URL.Query().Get("customer_id"))
+	if err != nil {
+		http.Error(w, "Failed to list unapplied credits: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"customers": credits,
+	})
+}
@@ -0,0 +1,125 @@
+// creditmemo/models.go
+package creditmemo
+
+import "github.com/eGGnogSC/qbserver/internal/money"
+
+// Ref references another QuickBooks entity
+type Ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+// EmailAddress is a QuickBooks email address
+type EmailAddress struct {
+	Address string `json:"Address,omitempty"`
+}
+
+// Memo is a QuickBooks message field
+type Memo struct {
+	Value string `json:"value"`
+}
+
+// MetaData holds QuickBooks record timestamps
+type MetaData struct {
+	CreateTime      string `json:"CreateTime,omitempty"`
+	LastUpdatedTime string `json:"LastUpdatedTime,omitempty"`
+}
+
+// SalesItemLineDetail is the item, quantity and price of a line
+type SalesItemLineDetail struct {
+	ItemRef     *Ref          `json:"ItemRef,omitempty"`
+	Qty         *money.Amount `json:"Qty,omitempty"`
+	UnitPrice   *money.Amount `json:"UnitPrice,omitempty"`
+	TaxCodeRef  *Ref          `json:"TaxCodeRef,omitempty"`
+	ClassRef    *Ref          `json:"ClassRef,omitempty"`
+	ServiceDate string        `json:"ServiceDate,omitempty"`
+}
+
+// Line is a credit memo line; DetailType names which detail is set
+type Line struct {
+	ID                  string               `json:"Id,omitempty"`
+	LineNum             int                  `json:"LineNum,omitempty"`
+	Description         string               `json:"Description,omitempty"`
+	Amount              money.Amount         `json:"Amount"`
+	DetailType          string               `json:"DetailType"`
+	SalesItemLineDetail *SalesItemLineDetail `json:"SalesItemLineDetail,omitempty"`
+	SubTotalLineDetail  *struct{}            `json:"SubTotalLineDetail,omitempty"`
+}
+
+// TxnTaxDetail is the tax code and computed tax of a transaction
+type TxnTaxDetail struct {
+	TxnTaxCodeRef *Ref          `json:"TxnTaxCodeRef,omitempty"`
+	TotalTax      *money.Amount `json:"TotalTax,omitempty"`
+}
+
+// CreditMemo is a QuickBooks credit memo, in QuickBooks' field names. The
+// credit it leaves is RemainingCredit until it is applied to invoices.
+type CreditMemo struct {
+	ID              string        `json:"Id,omitempty"`
+	SyncToken       string        `json:"SyncToken,omitempty"`
+	DocNumber       string        `json:"DocNumber,omitempty"`
+	TxnDate         string        `json:"TxnDate,omitempty"`
+	CustomerRef     *Ref          `json:"CustomerRef,omitempty"`
+	BillEmail       *EmailAddress `json:"BillEmail,omitempty"`
+	CustomerMemo    *Memo         `json:"CustomerMemo,omitempty"`
+	PrivateNote     string        `json:"PrivateNote,omitempty"`
+	Line            []Line        `json:"Line"`
+	TxnTaxDetail    *TxnTaxDetail `json:"TxnTaxDetail,omitempty"`
+	CurrencyRef     *Ref          `json:"CurrencyRef,omitempty"`
+	ClassRef        *Ref          `json:"ClassRef,omitempty"`
+	TotalAmt        money.Amount  `json:"TotalAmt,omitempty"`
+	RemainingCredit money.Amount  `json:"RemainingCredit,omitempty"`
+	MetaData        *MetaData     `json:"MetaData,omitempty"`
+}
+
+// currency returns the memo's currency code, "" for the home currency
+func (m *CreditMemo) currency() string {
+	if m.CurrencyRef == nil {
+		return ""
+	}
+	return m.CurrencyRef.Value
+}
+
+// ListOptions filters and pages credit memo lists
+type ListOptions struct {
+	CustomerID string
+	Limit      int
+	Offset     int
+}
+
+// Allocation is the part of a credit applied to one invoice
+type Allocation struct {
+	InvoiceID string `json:"invoice_id"`
+	// Amount defaults to the smaller of the invoice's balance and the
+	// credit left
+	Amount money.Amount `json:"amount"`
+	// DocNumber and Balance describe the invoice once applied; Balance is
+	// what was open before
+	DocNumber string       `json:"doc_number,omitempty"`
+	Balance   money.Amount `json:"balance,omitempty"`
+}
+
+// ApplyOptions picks the invoices a credit memo is applied to. Without
+// invoices, the credit goes to the customer's open invoices, oldest first.
+type ApplyOptions struct {
+	Invoices []Allocation `json:"invoices,omitempty"`
+	TxnDate  string       `json:"txn_date,omitempty"`
+}
+
+// Application is the result of applying a credit memo: the QuickBooks
+// payment linking it to the invoices and the credit memo afterwards
+type Application struct {
+	CreditMemo *CreditMemo  `json:"credit_memo"`
+	PaymentID  string       `json:"payment_id"`
+	Applied    []Allocation `json:"applied"`
+	Total      money.Amount `json:"total"`
+}
+
+// CustomerCredit is a customer's credit memos with credit left
+type CustomerCredit struct {
+	CustomerID   string       `json:"customer_id"`
+	CustomerName string       `json:"customer_name,omitempty"`
+	Currency     string       `json:"currency,omitempty"`
+	Remaining    money.Amount `json:"remaining"`
+	CreditMemos  []CreditMemo `json:"credit_memos"`
+}
@@ -0,0 +1,413 @@
+// creditmemo/service.go
+package creditmemo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrNotFound is returned for credit memo IDs QuickBooks does not know
+	ErrNotFound = errors.New("credit memo not found")
+	// ErrInvalidCreditMemo is returned for credit memos without a customer or lines
+	ErrInvalidCreditMemo = errors.New("credit memo requires a CustomerRef and at least one line")
+	// ErrNoCredit is returned when applying a credit memo with no credit left
+	ErrNoCredit = errors.New("credit memo has no credit left")
+	// ErrInvalidApplication is returned for applications to invoices the
+	// credit cannot go to, or of more than is left
+	ErrInvalidApplication = errors.New("invalid credit application")
+)
+
+// defaultLimit and maxLimit bound credit memo list pages
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// readOnlyFields are computed by QuickBooks and left out of new credit memos
+var readOnlyFields = []string{"Id", "SyncToken", "TotalAmt", "RemainingCredit", "MetaData"}
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// QuickBooks is the subset of the QuickBooks client used for credit memos
+type QuickBooks interface {
+	Querier
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// Locker serializes applications of the same credit memo across instances
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// Service manages QuickBooks credit memos and applies their credit to
+// open invoices
+type Service struct {
+	qb     QuickBooks
+	locker Locker
+}
+
+// NewService creates a new credit memo service
+func NewService(qb QuickBooks, locker Locker) *Service {
+	return &Service{
+		qb:     qb,
+		locker: locker,
+	}
+}
+
+// List returns a page of credit memos, newest first, optionally only a
+// customer's
+func (s *Service) List(ctx context.Context, opts ListOptions) ([]CreditMemo, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	q := query.Select("CreditMemo")
+	if opts.CustomerID != "" {
+		if !query.IsID(opts.CustomerID) {
+			return []CreditMemo{}, nil
+		}
+		q.Where("CustomerRef", "=", opts.CustomerID)
+	}
+	q.OrderByDesc("TxnDate").Offset(opts.Offset).Limit(opts.Limit)
+
+	memos, err := query.List[CreditMemo](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credit memos: %w", err)
+	}
+	return memos, nil
+}
+
+// Get returns a credit memo by ID
+func (s *Service) Get(ctx context.Context, id string) (*CreditMemo, error) {
+	fields, err := s.qb.Read(ctx, "CreditMemo", id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var memo CreditMemo
+	if err := json.Unmarshal(data, &memo); err != nil {
+		return nil, fmt.Errorf("failed to decode credit memo: %w", err)
+	}
+	return &memo, nil
+}
+
+// Create creates a credit memo for a customer. Its whole amount is credit
+// until it is applied.
+func (s *Service) Create(ctx context.Context, memo *CreditMemo) (*CreditMemo, error) {
+	if memo.CustomerRef == nil || memo.CustomerRef.Value == "" || len(memo.Line) == 0 {
+		return nil, ErrInvalidCreditMemo
+	}
+
+	data, err := json.Marshal(memo)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	for _, name := range readOnlyFields {
+		delete(payload, name)
+	}
+
+	var created struct {
+		CreditMemo CreditMemo `json:"CreditMemo"`
+	}
+	if err := s.qb.Create(ctx, "CreditMemo", payload, &created); err != nil {
+		return nil, err
+	}
+	return &created.CreditMemo, nil
+}
+
+// openInvoice is the part of an invoice a credit application needs
+type openInvoice struct {
+	ID          string       `json:"Id"`
+	DocNumber   string       `json:"DocNumber"`
+	TxnDate     string       `json:"TxnDate"`
+	DueDate     string       `json:"DueDate"`
+	Balance     money.Amount `json:"Balance"`
+	CustomerRef Ref          `json:"CustomerRef"`
+	CurrencyRef *Ref         `json:"CurrencyRef"`
+}
+
+// openInvoices returns invoices with a balance: those given, or else the
+// customer's, oldest due first
+func (s *Service) openInvoices(ctx context.Context, customerID string, ids []string) ([]openInvoice, error) {
+	q := query.Select("Invoice", "Id", "DocNumber", "TxnDate", "DueDate", "Balance", "CustomerRef", "CurrencyRef")
+	if len(ids) > 0 {
+		values := make([]interface{}, len(ids))
+		for i, id := range ids {
+			if !query.IsID(id) {
+				return nil, fmt.Errorf("%w: invoice %q not found", ErrInvalidApplication, id)
+			}
+			values[i] = id
+		}
+		q.WhereIn("Id", values...)
+	} else {
+		q.Where("CustomerRef", "=", customerID).Where("Balance", ">", "0")
+	}
+
+	invoices, err := query.All[openInvoice](ctx, s.qb, q)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(invoices, func(i, j int) bool {
+		a, b := invoices[i], invoices[j]
+		if a.DueDate != b.DueDate {
+			return a.DueDate < b.DueDate
+		}
+		return a.TxnDate < b.TxnDate
+	})
+	return invoices, nil
+}
+
+// Apply applies a credit memo's remaining credit to open invoices of its
+// customer in the same currency, through a QuickBooks payment of zero that
+// links the credit memo to them. Applications of the same credit memo are
+// serialized, so retries cannot apply its credit twice.
+func (s *Service) Apply(ctx context.Context, id string, opts ApplyOptions) (*Application, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TxnDate != "" {
+		if _, err := time.Parse("2006-01-02", opts.TxnDate); err != nil {
+			return nil, fmt.Errorf("%w: txn_date must be YYYY-MM-DD", ErrInvalidApplication)
+		}
+	}
+	unlock, err := s.locker.Lock(ctx, fmt.Sprintf("%s:creditmemo:apply:%s", realmID, id))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	memo, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !memo.RemainingCredit.IsPositive() {
+		return nil, ErrNoCredit
+	}
+	if memo.CustomerRef == nil || !query.IsID(memo.CustomerRef.Value) {
+		return nil, ErrInvalidCreditMemo
+	}
+
+	requested := make(map[string]money.Amount, len(opts.Invoices))
+	ids := make([]string, 0, len(opts.Invoices))
+	for _, allocation := range opts.Invoices {
+		if _, ok := requested[allocation.InvoiceID]; ok {
+			return nil, fmt.Errorf("%w: invoice %s is listed twice", ErrInvalidApplication, allocation.InvoiceID)
+		}
+		if allocation.Amount.IsNegative() {
+			return nil, fmt.Errorf("%w: amount for invoice %s is negative", ErrInvalidApplication, allocation.InvoiceID)
+		}
+		requested[allocation.InvoiceID] = allocation.Amount
+		ids = append(ids, allocation.InvoiceID)
+	}
+	invoices, err := s.openInvoices(ctx, memo.CustomerRef.Value, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		// Apply in the order given
+		found := make(map[string]openInvoice, len(invoices))
+		for _, invoice := range invoices {
+			found[invoice.ID] = invoice
+		}
+		invoices = invoices[:0]
+		for _, id := range ids {
+			invoice, ok := found[id]
+			if !ok {
+				return nil, fmt.Errorf("%w: invoice %s not found", ErrInvalidApplication, id)
+			}
+			invoices = append(invoices, invoice)
+		}
+	}
+
+	application := &Application{Applied: []Allocation{}}
+	left := memo.RemainingCredit
+	var lines []map[string]interface{}
+	for _, invoice := range invoices {
+		if invoice.CustomerRef.Value != memo.CustomerRef.Value {
+			return nil, fmt.Errorf("%w: invoice %s belongs to another customer", ErrInvalidApplication, invoice.ID)
+		}
+		currency := ""
+		if invoice.CurrencyRef != nil {
+			currency = invoice.CurrencyRef.Value
+		}
+		if currency != memo.currency() {
+			if len(ids) > 0 {
+				return nil, fmt.Errorf("%w: invoice %s is in another currency", ErrInvalidApplication, invoice.ID)
+			}
+			continue
+		}
+		if !invoice.Balance.IsPositive() {
+			if len(ids) > 0 {
+				return nil, fmt.Errorf("%w: invoice %s is paid", ErrInvalidApplication, invoice.ID)
+			}
+			continue
+		}
+
+		amount := requested[invoice.ID]
+		if amount.IsZero() {
+			amount = money.NewAmount(decimal.Min(invoice.Balance.Decimal, left.Decimal))
+		}
+		if amount.GreaterThan(invoice.Balance.Decimal) {
+			return nil, fmt.Errorf("%w: %s exceeds the balance of invoice %s", ErrInvalidApplication, amount, invoice.ID)
+		}
+		if amount.GreaterThan(left.Decimal) {
+			return nil, fmt.Errorf("%w: %s exceeds the %s of credit left", ErrInvalidApplication, amount, left)
+		}
+		if amount.IsZero() {
+			break
+		}
+
+		left = money.NewAmount(left.Sub(amount.Decimal))
+		application.Total = money.NewAmount(application.Total.Add(amount.Decimal))
+		application.Applied = append(application.Applied, Allocation{
+			InvoiceID: invoice.ID,
+			Amount:    amount,
+			DocNumber: invoice.DocNumber,
+			Balance:   invoice.Balance,
+		})
+		lines = append(lines, map[string]interface{}{
+			"Amount":    amount,
+			"LinkedTxn": []map[string]string{{"TxnId": invoice.ID, "TxnType": "Invoice"}},
+		})
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: the customer has no open invoices in the credit memo's currency", ErrInvalidApplication)
+	}
+	lines = append(lines, map[string]interface{}{
+		"Amount":    application.Total,
+		"LinkedTxn": []map[string]string{{"TxnId": memo.ID, "TxnType": "CreditMemo"}},
+	})
+
+	txnDate := opts.TxnDate
+	if txnDate == "" {
+		txnDate = timezone.Today(ctx).Format("2006-01-02")
+	}
+	payment := map[string]interface{}{
+		"CustomerRef": map[string]string{"value": memo.CustomerRef.Value},
+		"TotalAmt":    0,
+		"TxnDate":     txnDate,
+		"PrivateNote": "Credit memo " + docNumber(memo) + " applied",
+		"Line":        lines,
+	}
+	if memo.CurrencyRef != nil {
+		payment["CurrencyRef"] = memo.CurrencyRef
+	}
+	var created struct {
+		Payment struct {
+			ID string `json:"Id"`
+		} `json:"Payment"`
+	}
+	if err := s.qb.Create(ctx, "Payment", payment, &created); err != nil {
+		return nil, fmt.Errorf("failed to apply credit memo: %w", err)
+	}
+	application.PaymentID = created.Payment.ID
+
+	application.CreditMemo, err = s.Get(ctx, id)
+	if err != nil {
+		// The credit was applied; report the credit memo as it was read
+		memo.RemainingCredit = left
+		application.CreditMemo = memo
+	}
+	return application, nil
+}
+
+// docNumber returns a credit memo's number, or its ID without one
+func docNumber(memo *CreditMemo) string {
+	if memo.DocNumber != "" {
+		return memo.DocNumber
+	}
+	return memo.ID
+}
+
+// Unapplied returns the credit memos with credit left, grouped by customer
+// and currency, optionally only a customer's
+func (s *Service) Unapplied(ctx context.Context, customerID string) ([]CustomerCredit, error) {
+	q := query.Select("CreditMemo")
+	if customerID != "" {
+		if !query.IsID(customerID) {
+			return []CustomerCredit{}, nil
+		}
+		q.Where("CustomerRef", "=", customerID)
+	}
+	memos, err := query.All[CreditMemo](ctx, s.qb, q)
+	if err != nil {
+		return nil, err
+	}
+
+	credits := make(map[string]*CustomerCredit)
+	var keys []string
+	for _, memo := range memos {
+		if !memo.RemainingCredit.IsPositive() || memo.CustomerRef == nil {
+			continue
+		}
+		key := memo.CustomerRef.Value + ":" + memo.currency()
+		credit, ok := credits[key]
+		if !ok {
+			credit = &CustomerCredit{
+				CustomerID:   memo.CustomerRef.Value,
+				CustomerName: memo.CustomerRef.Name,
+				Currency:     memo.currency(),
+				CreditMemos:  []CreditMemo{},
+			}
+			credits[key] = credit
+			keys = append(keys, key)
+		}
+		credit.Remaining = money.NewAmount(credit.Remaining.Add(memo.RemainingCredit.Decimal))
+		credit.CreditMemos = append(credit.CreditMemos, memo)
+	}
+
+	result := make([]CustomerCredit, 0, len(keys))
+	for _, key := range keys {
+		credit := credits[key]
+		sort.Slice(credit.CreditMemos, func(i, j int) bool {
+			return credit.CreditMemos[i].TxnDate < credit.CreditMemos[j].TxnDate
+		})
+		result = append(result, *credit)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CustomerName != result[j].CustomerName {
+			return result[i].CustomerName < result[j].CustomerName
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	return result, nil
+}
+
+// notFound maps QuickBooks' "Object Not Found" fault (code 610) to
+// ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+// deprecation/handler.go
+package deprecation
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler provides HTTP handlers for deprecated API usage
+type Handler struct {
+	tracker *Tracker
+}
+
+// NewHandler creates a new deprecated API usage handler
+func NewHandler(tracker *Tracker) *Handler {
+	return &Handler{
+		tracker: tracker,
+	}
+}
+
+// GetReport returns which clients still call deprecated routes and
+// parameters
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.tracker.Report(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get deprecated API usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// ResetReport clears the recorded usage
+func (h *Handler) ResetReport(w http.ResponseWriter, r *http.Request) {
+	if err := h.tracker.Reset(r.Context()); err != nil {
+		http.Error(w, "Failed to reset deprecated API usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,196 @@
+// deprecation/tracker.go
+package deprecation
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/go-redis/redis/v8"
+)
+
+// calls counts uses of deprecated routes and parameters by feature, for
+// metrics scrapers of /admin/debug/vars
+var calls = expvar.NewMap("api_deprecated_calls")
+
+// stats are the statistics kept by feature, or by feature and client
+var stats = []string{"calls", "sunset", "clients", "last_seen", "agent"}
+
+// recordTimeout bounds recording a use, which runs alongside the request
+const recordTimeout = 2 * time.Second
+
+// Tracker attaches Deprecation and Sunset headers to responses of
+// deprecated routes and parameters, and records which clients still use
+// them so they can be removed safely
+type Tracker struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewTracker creates a new deprecation tracker
+func NewTracker(client redis.UniversalClient, prefix string) *Tracker {
+	return &Tracker{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key returns the Redis hash of one statistic
+func (t *Tracker) key(stat string) string {
+	return fmt.Sprintf("%s:deprecation:%s", t.prefix, stat)
+}
+
+// Middleware is a route middleware marking responses of deprecated routes,
+// and of requests with deprecated query parameters, and recording the use.
+// Routes with nothing deprecated are served as they are.
+func (t *Tracker) Middleware(route routing.Route, next http.Handler) http.Handler {
+	if route.Deprecation == nil && len(route.DeprecatedParams) == 0 {
+		return next
+	}
+	feature := route.Method + " " + route.Path
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route.Deprecation != nil {
+			route.Deprecation.SetHeaders(w.Header())
+			t.record(r, feature, *route.Deprecation)
+		}
+		query := r.URL.Query()
+		for param, deprecation := range route.DeprecatedParams {
+			if _, ok := query[param]; !ok {
+				continue
+			}
+			deprecation.SetHeaders(w.Header())
+			w.Header().Add("Warning", fmt.Sprintf(`299 - "The %s parameter is deprecated"`, param))
+			t.record(r, feature+"?"+param, deprecation)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// client identifies the caller of a request: its tenant, or its user for
+// session callers
+func client(r *http.Request) string {
+	if tenantID := auth.GetTenantID(r.Context()); tenantID != "" {
+		return "tenant:" + tenantID
+	}
+	if userID := auth.GetUserID(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+	return "anonymous"
+}
+
+// record counts a use of a deprecated feature by the request's client.
+// Telemetry failures never fail the request.
+func (t *Tracker) record(r *http.Request, feature string, deprecation routing.Deprecation) {
+	calls.Add(feature, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	field := feature + "\t" + client(r)
+	_, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, t.key("calls"), feature, 1)
+		if !deprecation.Sunset.IsZero() {
+			pipe.HSet(ctx, t.key("sunset"), feature, deprecation.Sunset.UTC().Format(time.RFC3339))
+		}
+		pipe.HIncrBy(ctx, t.key("clients"), field, 1)
+		pipe.HSet(ctx, t.key("last_seen"), field, time.Now().UTC().Format(time.RFC3339))
+		if agent := r.UserAgent(); agent != "" {
+			pipe.HSet(ctx, t.key("agent"), field, agent)
+		}
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("failed to record use of deprecated feature", "feature", feature, "error", err)
+	}
+}
+
+// ClientUsage is one client's use of a deprecated feature
+type ClientUsage struct {
+	Client    string    `json:"client"`
+	Calls     int64     `json:"calls"`
+	LastSeen  time.Time `json:"last_seen"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// FeatureUsage is the use of a deprecated route ("GET /api/v1/vendors") or
+// parameter ("GET /api/v1/vendors?limit")
+type FeatureUsage struct {
+	Feature string        `json:"feature"`
+	Sunset  *time.Time    `json:"sunset,omitempty"`
+	Calls   int64         `json:"calls"`
+	Clients []ClientUsage `json:"clients"`
+}
+
+// Report returns the deprecated features still in use, soonest sunset
+// first, each with its clients, most recently seen first
+func (t *Tracker) Report(ctx context.Context) ([]FeatureUsage, error) {
+	values := make(map[string]map[string]string, len(stats))
+	for _, stat := range stats {
+		all, err := t.client.HGetAll(ctx, t.key(stat)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deprecated API %s: %w", stat, err)
+		}
+		values[stat] = all
+	}
+
+	byFeature := make(map[string]*FeatureUsage, len(values["calls"]))
+	report := make([]FeatureUsage, 0, len(values["calls"]))
+	for feature, count := range values["calls"] {
+		usage := FeatureUsage{Feature: feature, Clients: []ClientUsage{}}
+		usage.Calls, _ = strconv.ParseInt(count, 10, 64)
+		if sunset, err := time.Parse(time.RFC3339, values["sunset"][feature]); err == nil {
+			usage.Sunset = &sunset
+		}
+		report = append(report, usage)
+	}
+	for i := range report {
+		byFeature[report[i].Feature] = &report[i]
+	}
+	for field, count := range values["clients"] {
+		parts := strings.SplitN(field, "\t", 2)
+		usage, ok := byFeature[parts[0]]
+		if !ok || len(parts) != 2 {
+			continue
+		}
+		c := ClientUsage{Client: parts[1], UserAgent: values["agent"][field]}
+		c.Calls, _ = strconv.ParseInt(count, 10, 64)
+		c.LastSeen, _ = time.Parse(time.RFC3339, values["last_seen"][field])
+		usage.Clients = append(usage.Clients, c)
+	}
+
+	for _, usage := range report {
+		sort.Slice(usage.Clients, func(i, j int) bool {
+			return usage.Clients[i].LastSeen.After(usage.Clients[j].LastSeen)
+		})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if (a.Sunset == nil) != (b.Sunset == nil) {
+			return a.Sunset != nil
+		}
+		if a.Sunset != nil && !a.Sunset.Equal(*b.Sunset) {
+			return a.Sunset.Before(*b.Sunset)
+		}
+		return a.Feature < b.Feature
+	})
+	return report, nil
+}
+
+// Reset clears the recorded uses
+func (t *Tracker) Reset(ctx context.Context) error {
+	keys := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		keys = append(keys, t.key(stat))
+	}
+	if err := t.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to reset deprecated API usage: %w", err)
+	}
+	return nil
+}
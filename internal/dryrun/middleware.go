@@ -0,0 +1,197 @@
+// dryrun/middleware.go
+package dryrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/sparse"
+	"github.com/eGGnogSC/qbserver/internal/totals"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+const (
+	// Param turns on a dry run, e.g. POST /api/invoices?dry_run=true
+	Param = "dry_run"
+	// PreviewParam turns on a dry run that also reports the field-level
+	// changes each update would make, e.g. PUT /api/invoices/42?preview=true
+	PreviewParam = "preview"
+)
+
+// collections are the entity routes that support dry runs. Their handlers
+// only write to QuickBooks, so collecting those writes leaves nothing
+// changed.
+var collections = map[string]bool{
+	"customers": true,
+	"items":     true,
+	"invoices":  true,
+	"payments":  true,
+	"bills":     true,
+}
+
+// Write is a write that would have been sent, with the totals computed for
+// sales transactions and, in previews, the changes updates would make
+type Write struct {
+	qbclient.DryRunWrite
+	Totals  *totals.Totals  `json:"totals,omitempty"`
+	Changes []sparse.Change `json:"changes,omitempty"`
+}
+
+// Response answers a dry run that reached QuickBooks. Headers the handler
+// set, such as warnings, are kept.
+type Response struct {
+	DryRun bool    `json:"dry_run"`
+	Writes []Write `json:"writes"`
+}
+
+// Requested reports whether a request asks for a dry run or a preview
+func Requested(r *http.Request) bool {
+	return r.URL.Query().Get(Param) == "true" || previewed(r)
+}
+
+// previewed reports whether a request asks for a preview of its changes
+func previewed(r *http.Request) bool {
+	return r.URL.Query().Get(PreviewParam) == "true"
+}
+
+// supported reports whether a request path is under a dry-run collection
+func supported(path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	return len(segments) >= 2 && segments[0] == "api" && collections[segments[1]]
+}
+
+// Middleware runs create and update requests with ?dry_run=true through
+// validation, reference resolution and the other before-write steps, then
+// answers with the payloads that would have been sent to QuickBooks instead
+// of sending them. With ?preview=true, updates also report their
+// field-level changes against the stored entity so callers can review them
+// before committing. Requests that fail before reaching QuickBooks are
+// answered as usual.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Requested(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch {
+			http.Error(w, "Dry runs are only supported on creates and updates", http.StatusBadRequest)
+			return
+		}
+		if !supported(r.URL.Path) {
+			http.Error(w, "Dry runs are not supported on this endpoint", http.StatusBadRequest)
+			return
+		}
+
+		ctx, run := qbclient.WithDryRun(r.Context())
+		run.ReadCurrent = previewed(r)
+		recorder := &recorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		writes := run.Writes()
+		if len(writes) == 0 {
+			recorder.flush(w)
+			return
+		}
+
+		resp := Response{DryRun: true, Writes: make([]Write, 0, len(writes))}
+		policy := money.PolicyFromContext(ctx)
+		for _, write := range writes {
+			result := Write{
+				DryRunWrite: write,
+				Totals:      computeTotals(write, policy),
+			}
+			if write.Current != nil {
+				result.Changes = changes(write)
+				if result.Changes == nil {
+					result.Changes = []sparse.Change{}
+				}
+			}
+			resp.Writes = append(resp.Writes, result)
+		}
+
+		for name, values := range recorder.header {
+			switch name {
+			case "Content-Type", "Content-Length", "Location":
+				continue
+			}
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// computeTotals works out a sales transaction's totals, nil for others
+func computeTotals(write qbclient.DryRunWrite, policy money.Policy) *totals.Totals {
+	if !totals.IsSalesTransaction(write.Entity) {
+		return nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(write.Payload, &payload); err != nil {
+		return nil
+	}
+	computed, err := totals.Compute(payload, policy)
+	if err != nil {
+		slog.Warn("failed to compute totals for dry run", "entity", write.Entity, "error", err)
+		return nil
+	}
+	return computed
+}
+
+// changes returns the field-level changes an update makes to the stored
+// entity
+func changes(write qbclient.DryRunWrite) []sparse.Change {
+	current := make(map[string]interface{}, len(write.Current))
+	for name, raw := range write.Current {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err == nil {
+			current[name] = value
+		}
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(write.Payload, &payload); err != nil {
+		return nil
+	}
+	return sparse.Diff(current, payload)
+}
+
+// recorder holds a handler's response until it is known whether the
+// request reached QuickBooks
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// Header returns the response headers
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader records the status code
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+}
+
+// Write records the body
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
+
+// flush sends the recorded response as is
+func (r *recorder) flush(w http.ResponseWriter) {
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
@@ -0,0 +1,141 @@
+// einvoice/handler.go
+package einvoice
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for e-invoice export
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new e-invoicing handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ExportUBL returns an invoice as UBL 2.1 XML for PEPPOL delivery. Invoices
+// failing validation are rejected with the list of issues.
+func (h *Handler) ExportUBL(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	data, doc, err := h.service.ExportUBL(r.Context(), auth.GetTenantID(r.Context()), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		var validationErr *ValidationError
+		switch {
+		case errors.Is(err, ErrInvoiceNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrSellerNotConfigured):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.As(err, &validationErr):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":  err.Error(),
+				"issues": validationErr.Issues,
+			})
+		default:
+			http.Error(w, "Failed to export invoice: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition", `attachment; filename="invoice-`+doc.ID+`.xml"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// GetSeller returns the tenant's seller identity
+func (h *Handler) GetSeller(w http.ResponseWriter, r *http.Request) {
+	seller, err := h.service.Parties().GetSeller(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		if errors.Is(err, ErrSellerNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get seller: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(seller)
+}
+
+// SaveSeller sets the tenant's seller identity and tax category mapping
+func (h *Handler) SaveSeller(w http.ResponseWriter, r *http.Request) {
+	var seller Seller
+	if err := json.NewDecoder(r.Body).Decode(&seller); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Parties().SaveSeller(r.Context(), auth.GetTenantID(r.Context()), &seller); err != nil {
+		http.Error(w, "Failed to save seller: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(seller)
+}
+
+// GetBuyer returns the PEPPOL identity stored for a customer
+func (h *Handler) GetBuyer(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	buyer, err := h.service.Parties().GetBuyer(r.Context(), realmID, mux.Vars(r)["customerId"])
+	if err != nil {
+		http.Error(w, "Failed to get buyer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if buyer == nil {
+		http.Error(w, "No e-invoicing identity for this customer", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(buyer)
+}
+
+// SaveBuyer stores the PEPPOL identity for a customer
+func (h *Handler) SaveBuyer(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var buyer Party
+	if err := json.NewDecoder(r.Body).Decode(&buyer); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Parties().SaveBuyer(r.Context(), realmID, mux.Vars(r)["customerId"], &buyer); err != nil {
+		http.Error(w, "Failed to save buyer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(buyer)
+}
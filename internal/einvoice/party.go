@@ -0,0 +1,148 @@
+// einvoice/party.go
+package einvoice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrSellerNotConfigured is returned when a tenant has no seller identity
+var ErrSellerNotConfigured = errors.New("e-invoicing seller identity is not configured")
+
+// Address is a postal address in UBL terms
+type Address struct {
+	Street     string `json:"street,omitempty"`
+	City       string `json:"city,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	Region     string `json:"region,omitempty"`
+	Country    string `json:"country"` // ISO 3166-1 alpha-2
+}
+
+// Party identifies a seller or buyer on the PEPPOL network
+type Party struct {
+	Name           string  `json:"name"`
+	EndpointID     string  `json:"endpoint_id"`     // electronic address, e.g. GLN or organization number
+	EndpointScheme string  `json:"endpoint_scheme"` // EAS code, e.g. 0088 or 0192
+	VATID          string  `json:"vat_id,omitempty"`
+	LegalID        string  `json:"legal_id,omitempty"` // company registration number
+	Email          string  `json:"email,omitempty"`
+	Reference      string  `json:"reference,omitempty"` // buyer reference expected on invoices
+	Address        Address `json:"address"`
+}
+
+// Seller is a tenant's supplier identity and tax setup
+type Seller struct {
+	Party
+	IBAN          string                 `json:"iban,omitempty"` // payee account for credit transfers
+	TaxCategories map[string]TaxCategory `json:"tax_categories,omitempty"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+// TaxCategory is the UNCL5305 category and rate a QuickBooks tax code maps to
+type TaxCategory struct {
+	ID              string  `json:"id"` // S, Z, E, AE, K, G or O
+	Percent         float64 `json:"percent"`
+	ExemptionReason string  `json:"exemption_reason,omitempty"`
+}
+
+// PartyStore persists seller and buyer identities
+type PartyStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewPartyStore creates a new party store
+func NewPartyStore(client redis.UniversalClient, prefix string) *PartyStore {
+	return &PartyStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// sellerKey holds a tenant's seller identity
+func (s *PartyStore) sellerKey(tenantID string) string {
+	return fmt.Sprintf("%s:einvoice:seller:%s", s.prefix, tenantID)
+}
+
+// buyersKey is the hash of customer ID to buyer identity for a realm
+func (s *PartyStore) buyersKey(realmID string) string {
+	return fmt.Sprintf("%s:einvoice:buyers:%s", s.prefix, realmID)
+}
+
+// GetSeller returns a tenant's seller identity
+func (s *PartyStore) GetSeller(ctx context.Context, tenantID string) (*Seller, error) {
+	data, err := s.client.Get(ctx, s.sellerKey(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrSellerNotConfigured
+		}
+		return nil, fmt.Errorf("failed to get seller: %w", err)
+	}
+
+	var seller Seller
+	if err := json.Unmarshal(data, &seller); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal seller: %w", err)
+	}
+	return &seller, nil
+}
+
+// SaveSeller stores a tenant's seller identity
+func (s *PartyStore) SaveSeller(ctx context.Context, tenantID string, seller *Seller) error {
+	if issues := validateParty("seller", &seller.Party); len(issues) > 0 {
+		return fmt.Errorf("%s", issues[0])
+	}
+	for code, category := range seller.TaxCategories {
+		if issue := validateTaxCategory(category); issue != "" {
+			return fmt.Errorf("tax_categories.%s: %s", code, issue)
+		}
+	}
+	seller.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(seller)
+	if err != nil {
+		return fmt.Errorf("failed to marshal seller: %w", err)
+	}
+	if err := s.client.Set(ctx, s.sellerKey(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save seller: %w", err)
+	}
+	return nil
+}
+
+// GetBuyer returns the stored identity for a customer, or nil if none
+func (s *PartyStore) GetBuyer(ctx context.Context, realmID, customerID string) (*Party, error) {
+	data, err := s.client.HGet(ctx, s.buyersKey(realmID), customerID).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get buyer: %w", err)
+	}
+
+	var party Party
+	if err := json.Unmarshal(data, &party); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal buyer: %w", err)
+	}
+	return &party, nil
+}
+
+// SaveBuyer stores the PEPPOL identity for a customer. Name and address may
+// be left blank to use the QuickBooks customer record.
+func (s *PartyStore) SaveBuyer(ctx context.Context, realmID, customerID string, party *Party) error {
+	if party.EndpointID == "" || !validScheme(party.EndpointScheme) {
+		return fmt.Errorf("endpoint_id and a valid endpoint_scheme are required")
+	}
+
+	data, err := json.Marshal(party)
+	if err != nil {
+		return fmt.Errorf("failed to marshal buyer: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.buyersKey(realmID), customerID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save buyer: %w", err)
+	}
+	return nil
+}
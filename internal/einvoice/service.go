@@ -0,0 +1,112 @@
+// einvoice/service.go
+package einvoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// ErrInvoiceNotFound is returned when the invoice does not exist
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// ValidationError lists the reasons an invoice cannot be exported
+type ValidationError struct {
+	Issues []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invoice is not valid for PEPPOL: %d issue(s)", len(e.Issues))
+}
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// Service exports QuickBooks invoices as UBL e-invoices
+type Service struct {
+	querier Querier
+	parties *PartyStore
+}
+
+// NewService creates a new e-invoicing service
+func NewService(querier Querier, parties *PartyStore) *Service {
+	return &Service{
+		querier: querier,
+		parties: parties,
+	}
+}
+
+// Parties returns the seller and buyer identity store
+func (s *Service) Parties() *PartyStore {
+	return s.parties
+}
+
+// ExportUBL renders an invoice as PEPPOL BIS 3.0 UBL XML. Invoices failing
+// validation return a *ValidationError.
+func (s *Service) ExportUBL(ctx context.Context, tenantID, realmID, invoiceID string) ([]byte, *Invoice, error) {
+	if !query.IsID(invoiceID) {
+		return nil, nil, ErrInvoiceNotFound
+	}
+
+	seller, err := s.parties.GetSeller(ctx, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	invoices, err := query.List[qbInvoice](ctx, s.querier, query.Select("Invoice").Where("Id", "=", invoiceID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(invoices) == 0 {
+		return nil, nil, ErrInvoiceNotFound
+	}
+	invoice := invoices[0]
+
+	var customer qbCustomer
+	if query.IsID(invoice.CustomerRef.Value) {
+		customers, err := query.List[qbCustomer](ctx, s.querier, query.Select("Customer").Where("Id", "=", invoice.CustomerRef.Value))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(customers) > 0 {
+			customer = customers[0]
+		}
+	}
+
+	stored, err := s.parties.GetBuyer(ctx, realmID, invoice.CustomerRef.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	buyer := buyerParty(stored, customer)
+
+	doc, issues := build(seller, buyer, invoice, money.PolicyFromContext(ctx))
+	issues = append(issues, validateParty("buyer", &buyer)...)
+	issues = append(issues, Validate(doc)...)
+	if len(issues) > 0 {
+		return nil, doc, &ValidationError{Issues: dedupe(issues)}
+	}
+
+	data, err := doc.Marshal()
+	if err != nil {
+		return nil, doc, err
+	}
+	return data, doc, nil
+}
+
+// dedupe drops repeated issues while keeping their order
+func dedupe(issues []string) []string {
+	seen := make(map[string]bool, len(issues))
+	unique := issues[:0]
+	for _, issue := range issues {
+		if !seen[issue] {
+			seen[issue] = true
+			unique = append(unique, issue)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,525 @@
+// einvoice/ubl.go
+package einvoice
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/shopspring/decimal"
+)
+
+// PEPPOL BIS Billing 3.0 identifiers
+const (
+	customizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	profileID       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+	invoiceTypeCode = "380" // commercial invoice
+	unitCodeEach    = "C62" // UN/ECE rec 20 "one"
+)
+
+// UBL 2.1 namespaces
+const (
+	nsInvoice = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	nsCAC     = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	nsCBC     = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+)
+
+// Amount is a monetary value with its currency attribute
+type Amount struct {
+	Currency string `xml:"currencyID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// Identifier is an ID with an optional scheme attribute
+type Identifier struct {
+	Scheme string `xml:"schemeID,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+// Quantity is a quantity with its unit code attribute
+type Quantity struct {
+	Unit  string `xml:"unitCode,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Invoice is a UBL 2.1 invoice. Element order follows the schema sequence,
+// which encoding/xml preserves from field order.
+type Invoice struct {
+	XMLName              xml.Name          `xml:"Invoice"`
+	XMLNS                string            `xml:"xmlns,attr"`
+	XMLNSCAC             string            `xml:"xmlns:cac,attr"`
+	XMLNSCBC             string            `xml:"xmlns:cbc,attr"`
+	CustomizationID      string            `xml:"cbc:CustomizationID"`
+	ProfileID            string            `xml:"cbc:ProfileID"`
+	ID                   string            `xml:"cbc:ID"`
+	IssueDate            string            `xml:"cbc:IssueDate"`
+	DueDate              string            `xml:"cbc:DueDate,omitempty"`
+	InvoiceTypeCode      string            `xml:"cbc:InvoiceTypeCode"`
+	Note                 string            `xml:"cbc:Note,omitempty"`
+	DocumentCurrencyCode string            `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference       string            `xml:"cbc:BuyerReference,omitempty"`
+	Supplier             PartyWrapper      `xml:"cac:AccountingSupplierParty"`
+	Customer             PartyWrapper      `xml:"cac:AccountingCustomerParty"`
+	PaymentMeans         *PaymentMeans     `xml:"cac:PaymentMeans,omitempty"`
+	AllowanceCharges     []AllowanceCharge `xml:"cac:AllowanceCharge"`
+	TaxTotal             TaxTotal          `xml:"cac:TaxTotal"`
+	MonetaryTotal        MonetaryTotal     `xml:"cac:LegalMonetaryTotal"`
+	Lines                []InvoiceLine     `xml:"cac:InvoiceLine"`
+}
+
+// PartyWrapper wraps a party as AccountingSupplierParty or AccountingCustomerParty
+type PartyWrapper struct {
+	Party UBLParty `xml:"cac:Party"`
+}
+
+// UBLParty is a UBL party
+type UBLParty struct {
+	EndpointID  Identifier       `xml:"cbc:EndpointID"`
+	PartyName   *PartyName       `xml:"cac:PartyName,omitempty"`
+	Address     PostalAddress    `xml:"cac:PostalAddress"`
+	TaxScheme   *PartyTaxScheme  `xml:"cac:PartyTaxScheme,omitempty"`
+	LegalEntity PartyLegalEntity `xml:"cac:PartyLegalEntity"`
+	Contact     *Contact         `xml:"cac:Contact,omitempty"`
+}
+
+// PartyName is a party's trading name
+type PartyName struct {
+	Name string `xml:"cbc:Name"`
+}
+
+// PostalAddress is a UBL postal address
+type PostalAddress struct {
+	StreetName  string  `xml:"cbc:StreetName,omitempty"`
+	CityName    string  `xml:"cbc:CityName,omitempty"`
+	PostalZone  string  `xml:"cbc:PostalZone,omitempty"`
+	Subentity   string  `xml:"cbc:CountrySubentity,omitempty"`
+	CountryCode Country `xml:"cac:Country"`
+}
+
+// Country holds an ISO 3166-1 alpha-2 code
+type Country struct {
+	IdentificationCode string `xml:"cbc:IdentificationCode"`
+}
+
+// PartyTaxScheme holds a party's VAT identifier
+type PartyTaxScheme struct {
+	CompanyID string    `xml:"cbc:CompanyID"`
+	TaxScheme TaxScheme `xml:"cac:TaxScheme"`
+}
+
+// TaxScheme is always VAT for PEPPOL
+type TaxScheme struct {
+	ID string `xml:"cbc:ID"`
+}
+
+// PartyLegalEntity holds a party's registered name and number
+type PartyLegalEntity struct {
+	RegistrationName string `xml:"cbc:RegistrationName"`
+	CompanyID        string `xml:"cbc:CompanyID,omitempty"`
+}
+
+// Contact is a party's electronic mail contact
+type Contact struct {
+	Email string `xml:"cbc:ElectronicMail"`
+}
+
+// PaymentMeans describes how the invoice should be paid
+type PaymentMeans struct {
+	Code      string            `xml:"cbc:PaymentMeansCode"`
+	PaymentID string            `xml:"cbc:PaymentID,omitempty"`
+	Account   *FinancialAccount `xml:"cac:PayeeFinancialAccount,omitempty"`
+}
+
+// FinancialAccount is the payee's bank account
+type FinancialAccount struct {
+	ID string `xml:"cbc:ID"`
+}
+
+// AllowanceCharge is a document-level discount or charge
+type AllowanceCharge struct {
+	ChargeIndicator bool           `xml:"cbc:ChargeIndicator"`
+	Reason          string         `xml:"cbc:AllowanceChargeReason,omitempty"`
+	Amount          Amount         `xml:"cbc:Amount"`
+	TaxCategory     UBLTaxCategory `xml:"cac:TaxCategory"`
+}
+
+// TaxTotal is the document tax amount and its breakdown
+type TaxTotal struct {
+	TaxAmount Amount        `xml:"cbc:TaxAmount"`
+	Subtotals []TaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+// TaxSubtotal is the tax for one category and rate
+type TaxSubtotal struct {
+	TaxableAmount Amount         `xml:"cbc:TaxableAmount"`
+	TaxAmount     Amount         `xml:"cbc:TaxAmount"`
+	Category      UBLTaxCategory `xml:"cac:TaxCategory"`
+}
+
+// UBLTaxCategory is a tax category with its rate
+type UBLTaxCategory struct {
+	ID              string    `xml:"cbc:ID"`
+	Percent         *string   `xml:"cbc:Percent,omitempty"`
+	ExemptionReason string    `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme       TaxScheme `xml:"cac:TaxScheme"`
+}
+
+// MonetaryTotal holds the document totals
+type MonetaryTotal struct {
+	LineExtensionAmount  Amount  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount   Amount  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount   Amount  `xml:"cbc:TaxInclusiveAmount"`
+	AllowanceTotalAmount *Amount `xml:"cbc:AllowanceTotalAmount,omitempty"`
+	PrepaidAmount        *Amount `xml:"cbc:PrepaidAmount,omitempty"`
+	PayableAmount        Amount  `xml:"cbc:PayableAmount"`
+}
+
+// InvoiceLine is a single invoice line
+type InvoiceLine struct {
+	ID                  string   `xml:"cbc:ID"`
+	Quantity            Quantity `xml:"cbc:InvoicedQuantity"`
+	LineExtensionAmount Amount   `xml:"cbc:LineExtensionAmount"`
+	Item                LineItem `xml:"cac:Item"`
+	Price               Price    `xml:"cac:Price"`
+}
+
+// LineItem describes what was sold on a line
+type LineItem struct {
+	Description string         `xml:"cbc:Description,omitempty"`
+	Name        string         `xml:"cbc:Name"`
+	TaxCategory UBLTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+}
+
+// Price is the net unit price of a line
+type Price struct {
+	Amount Amount `xml:"cbc:PriceAmount"`
+}
+
+// qbInvoice is the subset of the QuickBooks invoice used for export
+type qbInvoice struct {
+	ID           string `json:"Id"`
+	DocNumber    string `json:"DocNumber"`
+	TxnDate      string `json:"TxnDate"`
+	DueDate      string `json:"DueDate"`
+	CurrencyRef  qbRef  `json:"CurrencyRef"`
+	CustomerRef  qbRef  `json:"CustomerRef"`
+	CustomerMemo struct {
+		Value string `json:"value"`
+	} `json:"CustomerMemo"`
+	GlobalTaxCalculation string `json:"GlobalTaxCalculation"`
+	Line                 []struct {
+		ID                  string          `json:"Id"`
+		Description         string          `json:"Description"`
+		Amount              decimal.Decimal `json:"Amount"`
+		DetailType          string          `json:"DetailType"`
+		SalesItemLineDetail struct {
+			ItemRef    qbRef           `json:"ItemRef"`
+			Qty        decimal.Decimal `json:"Qty"`
+			UnitPrice  decimal.Decimal `json:"UnitPrice"`
+			TaxCodeRef qbRef           `json:"TaxCodeRef"`
+		} `json:"SalesItemLineDetail"`
+	} `json:"Line"`
+	TxnTaxDetail struct {
+		TotalTax decimal.Decimal `json:"TotalTax"`
+		TaxLine  []struct {
+			Amount        decimal.Decimal `json:"Amount"`
+			TaxLineDetail struct {
+				TaxPercent       float64         `json:"TaxPercent"`
+				NetAmountTaxable decimal.Decimal `json:"NetAmountTaxable"`
+			} `json:"TaxLineDetail"`
+		} `json:"TaxLine"`
+	} `json:"TxnTaxDetail"`
+	TotalAmt decimal.Decimal `json:"TotalAmt"`
+	Balance  decimal.Decimal `json:"Balance"`
+}
+
+// qbRef is a QuickBooks entity reference
+type qbRef struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+// qbCustomer is the subset of the QuickBooks customer used for the buyer party
+type qbCustomer struct {
+	DisplayName      string `json:"DisplayName"`
+	CompanyName      string `json:"CompanyName"`
+	PrimaryEmailAddr struct {
+		Address string `json:"Address"`
+	} `json:"PrimaryEmailAddr"`
+	BillAddr struct {
+		Line1                  string `json:"Line1"`
+		City                   string `json:"City"`
+		CountrySubDivisionCode string `json:"CountrySubDivisionCode"`
+		PostalCode             string `json:"PostalCode"`
+		Country                string `json:"Country"`
+	} `json:"BillAddr"`
+}
+
+// buyerParty fills blanks in a stored buyer identity from the customer record
+func buyerParty(stored *Party, customer qbCustomer) Party {
+	var party Party
+	if stored != nil {
+		party = *stored
+	}
+	if party.Name == "" {
+		party.Name = customer.CompanyName
+		if party.Name == "" {
+			party.Name = customer.DisplayName
+		}
+	}
+	if party.Email == "" {
+		party.Email = customer.PrimaryEmailAddr.Address
+	}
+	if party.Address.Street == "" && party.Address.City == "" {
+		party.Address.Street = customer.BillAddr.Line1
+		party.Address.City = customer.BillAddr.City
+		party.Address.PostalCode = customer.BillAddr.PostalCode
+		party.Address.Region = customer.BillAddr.CountrySubDivisionCode
+	}
+	if party.Address.Country == "" && len(customer.BillAddr.Country) == 2 {
+		party.Address.Country = strings.ToUpper(customer.BillAddr.Country)
+	}
+	return party
+}
+
+// build renders a QuickBooks invoice as a PEPPOL BIS 3.0 UBL invoice. Lines
+// whose tax category cannot be determined are reported as issues; the
+// document is still returned so callers can inspect it. Computed tax is
+// rounded with the realm's policy.
+func build(seller *Seller, buyer Party, invoice qbInvoice, policy money.Policy) (*Invoice, []string) {
+	var issues []string
+	// UBL amounts carry at most two decimals
+	if policy.Places > 2 {
+		policy.Places = 2
+	}
+	currency := invoice.CurrencyRef.Value
+	amount := func(v decimal.Decimal) Amount {
+		return Amount{Currency: currency, Value: policy.Format(v)}
+	}
+
+	if invoice.GlobalTaxCalculation == "TaxInclusive" {
+		issues = append(issues, "tax-inclusive invoices are not supported; amounts must be entered exclusive of tax")
+	}
+
+	defaultCategory, hasDefault := singleRate(invoice)
+
+	doc := &Invoice{
+		XMLNS:                nsInvoice,
+		XMLNSCAC:             nsCAC,
+		XMLNSCBC:             nsCBC,
+		CustomizationID:      customizationID,
+		ProfileID:            profileID,
+		ID:                   invoice.DocNumber,
+		IssueDate:            invoice.TxnDate,
+		DueDate:              invoice.DueDate,
+		InvoiceTypeCode:      invoiceTypeCode,
+		Note:                 invoice.CustomerMemo.Value,
+		DocumentCurrencyCode: currency,
+		BuyerReference:       buyer.Reference,
+		Supplier:             PartyWrapper{Party: ublParty(seller.Party)},
+		Customer:             PartyWrapper{Party: ublParty(buyer)},
+	}
+	if doc.ID == "" {
+		doc.ID = invoice.ID
+	}
+	if doc.BuyerReference == "" {
+		// PEPPOL requires a buyer or order reference
+		doc.BuyerReference = doc.ID
+	}
+	if seller.IBAN != "" {
+		doc.PaymentMeans = &PaymentMeans{
+			Code:      "58", // SEPA credit transfer
+			PaymentID: doc.ID,
+			Account:   &FinancialAccount{ID: strings.ReplaceAll(seller.IBAN, " ", "")},
+		}
+	}
+
+	type subtotal struct {
+		category TaxCategory
+		taxable  decimal.Decimal
+	}
+	subtotals := make(map[string]*subtotal)
+	addTaxable := func(category TaxCategory, v decimal.Decimal) {
+		key := category.ID + "/" + strconv.FormatFloat(category.Percent, 'f', 2, 64)
+		if subtotals[key] == nil {
+			subtotals[key] = &subtotal{category: category}
+		}
+		subtotals[key].taxable = subtotals[key].taxable.Add(v)
+	}
+
+	var lineTotal, discounts decimal.Decimal
+	var lastCategory *TaxCategory
+	for _, line := range invoice.Line {
+		switch line.DetailType {
+		case "SalesItemLineDetail":
+		case "DiscountLineDetail":
+			discounts = discounts.Add(line.Amount)
+			continue
+		default:
+			continue
+		}
+
+		detail := line.SalesItemLineDetail
+		category, ok := seller.TaxCategories[detail.TaxCodeRef.Value]
+		if !ok && hasDefault && detail.TaxCodeRef.Value != "NON" {
+			category, ok = defaultCategory, true
+		}
+		if !ok {
+			issues = append(issues, fmt.Sprintf("line %s: no tax category for tax code %q; map it in the seller's tax_categories", line.ID, detail.TaxCodeRef.Value))
+		}
+		lastCategory = &category
+
+		qty, price := detail.Qty, detail.UnitPrice
+		if qty.IsZero() {
+			qty, price = decimal.NewFromInt(1), line.Amount
+		}
+		name := detail.ItemRef.Name
+		if name == "" {
+			name = line.Description
+		}
+
+		doc.Lines = append(doc.Lines, InvoiceLine{
+			ID:                  strconv.Itoa(len(doc.Lines) + 1),
+			Quantity:            Quantity{Unit: unitCodeEach, Value: qty.String()},
+			LineExtensionAmount: amount(line.Amount),
+			Item: LineItem{
+				Description: line.Description,
+				Name:        name,
+				TaxCategory: ublTaxCategory(category),
+			},
+			Price: Price{Amount: amount(price)},
+		})
+		lineTotal = lineTotal.Add(line.Amount)
+		addTaxable(category, line.Amount)
+	}
+
+	if discounts.IsPositive() {
+		if len(subtotals) != 1 || lastCategory == nil {
+			issues = append(issues, "discounts are only supported on invoices with a single tax category")
+		} else {
+			doc.AllowanceCharges = append(doc.AllowanceCharges, AllowanceCharge{
+				ChargeIndicator: false,
+				Reason:          "Discount",
+				Amount:          amount(discounts),
+				TaxCategory:     ublTaxCategory(*lastCategory),
+			})
+			addTaxable(*lastCategory, discounts.Neg())
+		}
+	}
+
+	// Tax is recomputed per category as BIS 3.0 requires and checked
+	// against the QuickBooks total below
+	keys := make([]string, 0, len(subtotals))
+	for key := range subtotals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var taxTotal decimal.Decimal
+	for _, key := range keys {
+		st := subtotals[key]
+		tax := policy.Round(st.taxable.Mul(decimal.NewFromFloat(st.category.Percent)).Div(decimal.NewFromInt(100)))
+		taxTotal = taxTotal.Add(tax)
+		doc.TaxTotal.Subtotals = append(doc.TaxTotal.Subtotals, TaxSubtotal{
+			TaxableAmount: amount(st.taxable),
+			TaxAmount:     amount(tax),
+			Category:      ublTaxCategory(st.category),
+		})
+	}
+	doc.TaxTotal.TaxAmount = amount(taxTotal)
+	// QuickBooks may round per line, so allow a cent per category
+	tolerance := decimal.New(int64(len(keys)), -policy.Places)
+	if taxTotal.Sub(invoice.TxnTaxDetail.TotalTax).Abs().GreaterThan(tolerance) {
+		issues = append(issues, fmt.Sprintf("computed tax %s does not match QuickBooks tax %s", policy.Format(taxTotal), policy.Format(invoice.TxnTaxDetail.TotalTax)))
+	}
+
+	taxExclusive := lineTotal.Sub(discounts)
+	taxInclusive := taxExclusive.Add(taxTotal)
+	doc.MonetaryTotal = MonetaryTotal{
+		LineExtensionAmount: amount(lineTotal),
+		TaxExclusiveAmount:  amount(taxExclusive),
+		TaxInclusiveAmount:  amount(taxInclusive),
+		PayableAmount:       amount(invoice.Balance),
+	}
+	if discounts.IsPositive() {
+		total := amount(discounts)
+		doc.MonetaryTotal.AllowanceTotalAmount = &total
+	}
+	if prepaid := policy.Round(taxInclusive.Sub(invoice.Balance)); !prepaid.IsZero() {
+		paid := amount(prepaid)
+		doc.MonetaryTotal.PrepaidAmount = &paid
+	}
+
+	return doc, issues
+}
+
+// singleRate returns the standard-rated category when QuickBooks applied
+// exactly one non-zero tax rate to the invoice
+func singleRate(invoice qbInvoice) (TaxCategory, bool) {
+	var rate float64
+	for _, line := range invoice.TxnTaxDetail.TaxLine {
+		p := line.TaxLineDetail.TaxPercent
+		if p == 0 {
+			continue
+		}
+		if rate != 0 && rate != p {
+			return TaxCategory{}, false
+		}
+		rate = p
+	}
+	if rate == 0 {
+		return TaxCategory{}, false
+	}
+	return TaxCategory{ID: "S", Percent: rate}, true
+}
+
+// ublParty converts a stored party to its UBL form
+func ublParty(p Party) UBLParty {
+	party := UBLParty{
+		EndpointID: Identifier{Scheme: p.EndpointScheme, Value: p.EndpointID},
+		Address: PostalAddress{
+			StreetName:  p.Address.Street,
+			CityName:    p.Address.City,
+			PostalZone:  p.Address.PostalCode,
+			Subentity:   p.Address.Region,
+			CountryCode: Country{IdentificationCode: p.Address.Country},
+		},
+		LegalEntity: PartyLegalEntity{RegistrationName: p.Name, CompanyID: p.LegalID},
+	}
+	if p.Name != "" {
+		party.PartyName = &PartyName{Name: p.Name}
+	}
+	if p.VATID != "" {
+		party.TaxScheme = &PartyTaxScheme{CompanyID: p.VATID, TaxScheme: TaxScheme{ID: "VAT"}}
+	}
+	if p.Email != "" {
+		party.Contact = &Contact{Email: p.Email}
+	}
+	return party
+}
+
+// ublTaxCategory converts a tax category to its UBL form; category O carries no rate
+func ublTaxCategory(c TaxCategory) UBLTaxCategory {
+	category := UBLTaxCategory{
+		ID:              c.ID,
+		ExemptionReason: c.ExemptionReason,
+		TaxScheme:       TaxScheme{ID: "VAT"},
+	}
+	if c.ID != "O" {
+		percent := strconv.FormatFloat(c.Percent, 'f', -1, 64)
+		category.Percent = &percent
+	}
+	return category
+}
+
+// Marshal renders the invoice as indented XML with a declaration
+func (i *Invoice) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal UBL invoice: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,173 @@
+// einvoice/validate.go
+package einvoice
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/shopspring/decimal"
+)
+
+// easCodes are the PEPPOL electronic address scheme identifiers
+var easCodes = map[string]bool{
+	"0002": true, "0007": true, "0009": true, "0037": true, "0060": true,
+	"0088": true, "0096": true, "0097": true, "0106": true, "0130": true,
+	"0135": true, "0142": true, "0151": true, "0183": true, "0184": true,
+	"0188": true, "0190": true, "0191": true, "0192": true, "0193": true,
+	"0195": true, "0196": true, "0198": true, "0199": true, "0200": true,
+	"0201": true, "0202": true, "0204": true, "0208": true, "0209": true,
+	"0210": true, "0211": true, "0212": true, "0213": true, "0215": true,
+	"0216": true, "0221": true, "0230": true, "9901": true, "9910": true,
+	"9913": true, "9914": true, "9915": true, "9918": true, "9919": true,
+	"9920": true, "9922": true, "9923": true, "9924": true, "9925": true,
+	"9926": true, "9927": true, "9928": true, "9929": true, "9930": true,
+	"9931": true, "9932": true, "9933": true, "9934": true, "9935": true,
+	"9936": true, "9937": true, "9938": true, "9939": true, "9940": true,
+	"9941": true, "9942": true, "9943": true, "9944": true, "9945": true,
+	"9946": true, "9947": true, "9948": true, "9949": true, "9950": true,
+	"9951": true, "9952": true, "9953": true, "9957": true, "9959": true,
+	"EM": true,
+}
+
+// taxCategoryCodes are the UNCL5305 codes allowed by BIS 3.0
+var taxCategoryCodes = map[string]bool{
+	"S": true, "Z": true, "E": true, "AE": true, "K": true, "G": true, "O": true, "L": true, "M": true,
+}
+
+var (
+	countryPattern  = regexp.MustCompile(`^[A-Z]{2}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	datePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// validScheme reports whether scheme is a PEPPOL EAS code
+func validScheme(scheme string) bool {
+	return easCodes[scheme]
+}
+
+// validateParty checks the identity fields PEPPOL requires of a party
+func validateParty(role string, p *Party) []string {
+	var issues []string
+	if p.Name == "" {
+		issues = append(issues, role+": name is required")
+	}
+	if p.EndpointID == "" {
+		issues = append(issues, role+": endpoint_id is required")
+	}
+	if !validScheme(p.EndpointScheme) {
+		issues = append(issues, fmt.Sprintf("%s: endpoint_scheme %q is not a PEPPOL EAS code", role, p.EndpointScheme))
+	}
+	if !countryPattern.MatchString(p.Address.Country) {
+		issues = append(issues, role+": address.country must be an ISO 3166-1 alpha-2 code")
+	}
+	if role == "seller" && p.VATID == "" && p.LegalID == "" {
+		issues = append(issues, "seller: vat_id or legal_id is required")
+	}
+	return issues
+}
+
+// validateTaxCategory checks a category's code, rate and exemption reason
+func validateTaxCategory(c TaxCategory) string {
+	switch {
+	case !taxCategoryCodes[c.ID]:
+		return fmt.Sprintf("unknown tax category %q", c.ID)
+	case c.ID == "S" && c.Percent <= 0:
+		return "standard rated category S needs a percent above zero"
+	case (c.ID == "Z" || c.ID == "E" || c.ID == "AE" || c.ID == "K" || c.ID == "G") && c.Percent != 0:
+		return fmt.Sprintf("category %s must have a zero percent", c.ID)
+	case (c.ID == "E" || c.ID == "AE" || c.ID == "K" || c.ID == "G" || c.ID == "O") && c.ExemptionReason == "":
+		return fmt.Sprintf("category %s needs an exemption_reason", c.ID)
+	}
+	return ""
+}
+
+// Validate checks a UBL invoice against the PEPPOL BIS Billing 3.0 rules
+// that apply to the elements this exporter produces: mandatory fields,
+// code lists and the arithmetic between lines, tax subtotals and totals.
+func Validate(doc *Invoice) []string {
+	var issues []string
+	require := func(ok bool, msg string) {
+		if !ok {
+			issues = append(issues, msg)
+		}
+	}
+
+	require(doc.ID != "", "invoice number is required")
+	require(datePattern.MatchString(doc.IssueDate), "issue date must be YYYY-MM-DD")
+	require(doc.DueDate == "" || datePattern.MatchString(doc.DueDate), "due date must be YYYY-MM-DD")
+	require(currencyPattern.MatchString(doc.DocumentCurrencyCode), "document currency must be an ISO 4217 code")
+	require(doc.BuyerReference != "", "buyer reference is required")
+	require(len(doc.Lines) > 0, "at least one invoice line is required")
+
+	for _, p := range []struct {
+		role  string
+		party UBLParty
+	}{{"seller", doc.Supplier.Party}, {"buyer", doc.Customer.Party}} {
+		role, party := p.role, p.party
+		require(party.EndpointID.Value != "" && validScheme(party.EndpointID.Scheme), role+": a PEPPOL endpoint is required")
+		require(party.LegalEntity.RegistrationName != "", role+": registered name is required")
+		require(countryPattern.MatchString(party.Address.CountryCode.IdentificationCode), role+": country code is required")
+	}
+
+	// Totals are sums of two-decimal amounts and must match exactly; only
+	// products of quantity and price, and tax at a rate, may be a cent off
+	cent := decimal.New(1, -2)
+
+	var lineTotal decimal.Decimal
+	for _, line := range doc.Lines {
+		require(line.Item.Name != "", "line "+line.ID+": item name is required")
+		if issue := validateTaxCategory(fromUBL(line.Item.TaxCategory)); issue != "" {
+			issues = append(issues, "line "+line.ID+": "+issue)
+		}
+		net := money.Parse(line.LineExtensionAmount.Value)
+		qty := money.Parse(line.Quantity.Value)
+		price := money.Parse(line.Price.Amount.Value)
+		require(!price.IsNegative(), "line "+line.ID+": price must not be negative")
+		require(qty.Mul(price).Sub(net).Abs().LessThanOrEqual(cent.Mul(decimal.NewFromInt(2))), "line "+line.ID+": net amount does not equal quantity times price")
+		lineTotal = lineTotal.Add(net)
+	}
+
+	var allowances decimal.Decimal
+	for _, ac := range doc.AllowanceCharges {
+		allowances = allowances.Add(money.Parse(ac.Amount.Value))
+	}
+
+	var taxTotal decimal.Decimal
+	for _, st := range doc.TaxTotal.Subtotals {
+		category := fromUBL(st.Category)
+		if issue := validateTaxCategory(category); issue != "" {
+			issues = append(issues, "tax subtotal: "+issue)
+		}
+		tax := money.Parse(st.TaxAmount.Value)
+		expected := money.Parse(st.TaxableAmount.Value).Mul(decimal.NewFromFloat(category.Percent)).Div(decimal.NewFromInt(100))
+		require(tax.Sub(expected).Abs().LessThanOrEqual(cent), fmt.Sprintf("tax subtotal %s: tax amount does not match rate", category.ID))
+		taxTotal = taxTotal.Add(tax)
+	}
+
+	totals := doc.MonetaryTotal
+	taxExclusive := money.Parse(totals.TaxExclusiveAmount.Value)
+	taxInclusive := money.Parse(totals.TaxInclusiveAmount.Value)
+	require(money.Parse(totals.LineExtensionAmount.Value).Equal(lineTotal), "line extension total does not equal the sum of lines")
+	require(money.Parse(doc.TaxTotal.TaxAmount.Value).Equal(taxTotal), "tax total does not equal the sum of tax subtotals")
+	require(taxExclusive.Equal(lineTotal.Sub(allowances)), "tax exclusive total does not equal lines less allowances")
+	require(taxInclusive.Equal(taxExclusive.Add(taxTotal)), "tax inclusive total does not equal tax exclusive total plus tax")
+
+	var prepaid decimal.Decimal
+	if totals.PrepaidAmount != nil {
+		prepaid = money.Parse(totals.PrepaidAmount.Value)
+	}
+	require(money.Parse(totals.PayableAmount.Value).Equal(taxInclusive.Sub(prepaid)), "payable amount does not equal tax inclusive total less prepaid amount")
+
+	return issues
+}
+
+// fromUBL converts a UBL tax category back for validation
+func fromUBL(c UBLTaxCategory) TaxCategory {
+	category := TaxCategory{ID: c.ID, ExemptionReason: c.ExemptionReason}
+	if c.Percent != nil {
+		category.Percent, _ = strconv.ParseFloat(*c.Percent, 64)
+	}
+	return category
+}
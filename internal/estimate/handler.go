@@ -0,0 +1,179 @@
+// estimate/handler.go
+package estimate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+	"github.com/gorilla/mux"
+)
+
+// Servicer is the estimate service the handler serves. *Service implements
+// it; tests can substitute a fake.
+type Servicer interface {
+	List(ctx context.Context, opts ListOptions) ([]Estimate, error)
+	Get(ctx context.Context, id string) (*Estimate, error)
+	Create(ctx context.Context, estimate *Estimate) (*Estimate, error)
+	Update(ctx context.Context, id string, fields map[string]interface{}) (*Estimate, error)
+	Send(ctx context.Context, id, email string) (*Estimate, error)
+	Convert(ctx context.Context, id string, opts ConvertOptions) (*Conversion, error)
+}
+
+// Handler provides HTTP handlers for estimates
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new estimate handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNotAccepted), errors.Is(err, ErrAlreadyConverted), errors.Is(err, writelock.ErrTimeout):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidEstimate), errors.Is(err, ErrInvalidStatus), errors.Is(err, ErrReadOnlyField), errors.Is(err, ErrInvalidEmail):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// listOptions parses the list query: ?customer_id= and ?status= filter the
+// list, and ?limit= and ?offset= page it
+func listOptions(r *http.Request) (ListOptions, error) {
+	query := r.URL.Query()
+	opts := ListOptions{CustomerID: query.Get("customer_id"), Status: query.Get("status"), Limit: defaultLimit}
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil || opts.Limit < 1 {
+			return opts, errors.New("limit must be a positive number")
+		}
+		if opts.Limit > maxLimit {
+			opts.Limit = maxLimit
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil || opts.Offset < 0 {
+			return opts, errors.New("offset must not be negative")
+		}
+	}
+	return opts, nil
+}
+
+// ListEstimates lists estimates, newest first
+func (h *Handler) ListEstimates(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	estimates, err := h.service.List(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list estimates: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(estimates)
+}
+
+// GetEstimate returns an estimate
+func (h *Handler) GetEstimate(w http.ResponseWriter, r *http.Request) {
+	estimate, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get estimate: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(estimate)
+}
+
+// CreateEstimate creates an estimate
+func (h *Handler) CreateEstimate(w http.ResponseWriter, r *http.Request) {
+	var estimate Estimate
+	if err := json.NewDecoder(r.Body).Decode(&estimate); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &estimate)
+	if err != nil {
+		http.Error(w, "Failed to create estimate: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// UpdateEstimate changes the fields given in the body, in QuickBooks' field
+// names, and leaves the others as they are
+func (h *Handler) UpdateEstimate(w http.ResponseWriter, r *http.Request) {
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil || len(fields) == 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := h.service.Update(r.Context(), mux.Vars(r)["id"], fields)
+	if err != nil {
+		http.Error(w, "Failed to update estimate: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(estimate)
+}
+
+// SendEstimate emails an estimate to the customer, or to the "email" given
+// in the body
+func (h *Handler) SendEstimate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := h.service.Send(r.Context(), mux.Vars(r)["id"], req.Email)
+	if err != nil {
+		http.Error(w, "Failed to send estimate: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(estimate)
+}
+
+// ConvertEstimate turns an accepted estimate into an invoice. The body may
+// set the invoice's TxnDate, DueDate and DocNumber.
+func (h *Handler) ConvertEstimate(w http.ResponseWriter, r *http.Request) {
+	var opts ConvertOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	conversion, err := h.service.Convert(r.Context(), mux.Vars(r)["id"], opts)
+	if err != nil {
+		http.Error(w, "Failed to convert estimate: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(conversion)
+}
@@ -0,0 +1,155 @@
+// estimate/models.go
+package estimate
+
+// Estimate statuses. QuickBooks closes an estimate itself once an invoice
+// links to it.
+const (
+	StatusPending  = "Pending"
+	StatusAccepted = "Accepted"
+	StatusRejected = "Rejected"
+	StatusClosed   = "Closed"
+)
+
+// Ref references another QuickBooks entity
+type Ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+// EmailAddress is a QuickBooks email address
+type EmailAddress struct {
+	Address string `json:"Address,omitempty"`
+}
+
+// Address is a QuickBooks physical address
+type Address struct {
+	Line1                  string `json:"Line1,omitempty"`
+	Line2                  string `json:"Line2,omitempty"`
+	City                   string `json:"City,omitempty"`
+	CountrySubDivisionCode string `json:"CountrySubDivisionCode,omitempty"`
+	PostalCode             string `json:"PostalCode,omitempty"`
+	Country                string `json:"Country,omitempty"`
+}
+
+// Memo is a QuickBooks message field
+type Memo struct {
+	Value string `json:"value"`
+}
+
+// MetaData holds QuickBooks record timestamps
+type MetaData struct {
+	CreateTime      string `json:"CreateTime,omitempty"`
+	LastUpdatedTime string `json:"LastUpdatedTime,omitempty"`
+}
+
+// LinkedTxn links a transaction to another, e.g. an estimate to the
+// invoice it was converted to
+type LinkedTxn struct {
+	TxnID   string `json:"TxnId"`
+	TxnType string `json:"TxnType"`
+}
+
+// SalesItemLineDetail is the item, quantity and price of a line
+type SalesItemLineDetail struct {
+	ItemRef     *Ref    `json:"ItemRef,omitempty"`
+	Qty         float64 `json:"Qty,omitempty"`
+	UnitPrice   float64 `json:"UnitPrice,omitempty"`
+	TaxCodeRef  *Ref    `json:"TaxCodeRef,omitempty"`
+	ClassRef    *Ref    `json:"ClassRef,omitempty"`
+	ServiceDate string  `json:"ServiceDate,omitempty"`
+}
+
+// DiscountLineDetail is a discount applied to the lines above it
+type DiscountLineDetail struct {
+	PercentBased       bool    `json:"PercentBased,omitempty"`
+	DiscountPercent    float64 `json:"DiscountPercent,omitempty"`
+	DiscountAccountRef *Ref    `json:"DiscountAccountRef,omitempty"`
+}
+
+// GroupLineDetail is a bundle and the lines it expands to
+type GroupLineDetail struct {
+	GroupItemRef *Ref    `json:"GroupItemRef,omitempty"`
+	Quantity     float64 `json:"Quantity,omitempty"`
+	Line         []Line  `json:"Line,omitempty"`
+}
+
+// Line is an estimate line; DetailType names which detail is set
+type Line struct {
+	ID                  string               `json:"Id,omitempty"`
+	LineNum             int                  `json:"LineNum,omitempty"`
+	Description         string               `json:"Description,omitempty"`
+	Amount              float64              `json:"Amount"`
+	DetailType          string               `json:"DetailType"`
+	SalesItemLineDetail *SalesItemLineDetail `json:"SalesItemLineDetail,omitempty"`
+	DiscountLineDetail  *DiscountLineDetail  `json:"DiscountLineDetail,omitempty"`
+	GroupLineDetail     *GroupLineDetail     `json:"GroupLineDetail,omitempty"`
+	SubTotalLineDetail  *struct{}            `json:"SubTotalLineDetail,omitempty"`
+}
+
+// TxnTaxDetail is the tax code and computed tax of a transaction
+type TxnTaxDetail struct {
+	TxnTaxCodeRef *Ref    `json:"TxnTaxCodeRef,omitempty"`
+	TotalTax      float64 `json:"TotalTax,omitempty"`
+}
+
+// Estimate is a QuickBooks estimate (a quote), in QuickBooks' field names
+type Estimate struct {
+	ID                   string        `json:"Id,omitempty"`
+	SyncToken            string        `json:"SyncToken,omitempty"`
+	DocNumber            string        `json:"DocNumber,omitempty"`
+	TxnDate              string        `json:"TxnDate,omitempty"`
+	ExpirationDate       string        `json:"ExpirationDate,omitempty"`
+	TxnStatus            string        `json:"TxnStatus,omitempty"`
+	AcceptedBy           string        `json:"AcceptedBy,omitempty"`
+	AcceptedDate         string        `json:"AcceptedDate,omitempty"`
+	CustomerRef          *Ref          `json:"CustomerRef,omitempty"`
+	BillEmail            *EmailAddress `json:"BillEmail,omitempty"`
+	EmailStatus          string        `json:"EmailStatus,omitempty"`
+	BillAddr             *Address      `json:"BillAddr,omitempty"`
+	ShipAddr             *Address      `json:"ShipAddr,omitempty"`
+	CustomerMemo         *Memo         `json:"CustomerMemo,omitempty"`
+	PrivateNote          string        `json:"PrivateNote,omitempty"`
+	Line                 []Line        `json:"Line"`
+	TxnTaxDetail         *TxnTaxDetail `json:"TxnTaxDetail,omitempty"`
+	CurrencyRef          *Ref          `json:"CurrencyRef,omitempty"`
+	ClassRef             *Ref          `json:"ClassRef,omitempty"`
+	SalesTermRef         *Ref          `json:"SalesTermRef,omitempty"`
+	GlobalTaxCalculation string        `json:"GlobalTaxCalculation,omitempty"`
+	TotalAmt             float64       `json:"TotalAmt,omitempty"`
+	LinkedTxn            []LinkedTxn   `json:"LinkedTxn,omitempty"`
+	MetaData             *MetaData     `json:"MetaData,omitempty"`
+}
+
+// InvoiceID returns the ID of the invoice the estimate was converted to,
+// or "" if it has not been
+func (e *Estimate) InvoiceID() string {
+	for _, linked := range e.LinkedTxn {
+		if linked.TxnType == "Invoice" {
+			return linked.TxnID
+		}
+	}
+	return ""
+}
+
+// ListOptions filters and pages estimate lists
+type ListOptions struct {
+	CustomerID string
+	// Status is one of the estimate statuses
+	Status string
+	Limit  int
+	Offset int
+}
+
+// ConvertOptions overrides fields of the invoice an estimate converts to;
+// QuickBooks defaults them otherwise
+type ConvertOptions struct {
+	TxnDate   string `json:"TxnDate,omitempty"`
+	DueDate   string `json:"DueDate,omitempty"`
+	DocNumber string `json:"DocNumber,omitempty"`
+}
+
+// Conversion is the result of converting an estimate to an invoice
+type Conversion struct {
+	Estimate *Estimate              `json:"estimate"`
+	Invoice  map[string]interface{} `json:"invoice"`
+}
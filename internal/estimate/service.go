@@ -0,0 +1,342 @@
+// estimate/service.go
+package estimate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+var (
+	// ErrNotFound is returned for estimate IDs QuickBooks does not know
+	ErrNotFound = errors.New("estimate not found")
+	// ErrInvalidEstimate is returned for estimates without a customer or lines
+	ErrInvalidEstimate = errors.New("estimate requires a CustomerRef and at least one line")
+	// ErrInvalidStatus is returned for statuses other than Pending, Accepted
+	// or Rejected
+	ErrInvalidStatus = errors.New("TxnStatus must be Pending, Accepted or Rejected")
+	// ErrReadOnlyField is returned for updates of fields QuickBooks manages
+	ErrReadOnlyField = errors.New("field cannot be updated")
+	// ErrInvalidEmail is returned for malformed recipient addresses
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrNotAccepted is returned when converting an estimate the customer has
+	// not accepted
+	ErrNotAccepted = errors.New("only accepted estimates can be converted")
+	// ErrAlreadyConverted is returned when converting an estimate an invoice
+	// already links to
+	ErrAlreadyConverted = errors.New("estimate was already converted")
+)
+
+// defaultLimit and maxLimit bound estimate list pages
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// readOnlyFields are managed by QuickBooks or by the service
+var readOnlyFields = map[string]bool{
+	"Id":          true,
+	"SyncToken":   true,
+	"TotalAmt":    true,
+	"EmailStatus": true,
+	"LinkedTxn":   true,
+	"MetaData":    true,
+	"sparse":      true,
+}
+
+// updatableStatuses are the statuses clients may set; Closed is set by
+// QuickBooks when an invoice links to the estimate
+var updatableStatuses = map[string]bool{
+	StatusPending:  true,
+	StatusAccepted: true,
+	StatusRejected: true,
+}
+
+// invoiceFields are the estimate fields carried over to its invoice
+var invoiceFields = []string{
+	"CustomerRef", "BillEmail", "BillAddr", "ShipAddr", "CustomerMemo", "CurrencyRef", "ExchangeRate",
+	"ClassRef", "DepartmentRef", "SalesTermRef", "ShipMethodRef", "ShipDate", "GlobalTaxCalculation",
+	"ApplyTaxAfterDiscount", "ProjectRef",
+}
+
+// emailPattern is a loose check of recipient addresses before QuickBooks
+// sees them
+var emailPattern = regexp.MustCompile(`^[^@\s,;]+@[^@\s,;]+\.[^@\s,;]+$`)
+
+// QuickBooks is the subset of the QuickBooks client used for estimates
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+	Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error
+	Send(ctx context.Context, entity, id, sendTo string, result interface{}) error
+}
+
+// Invoicer creates invoices. Conversions go through the invoice service so
+// they get the same customer and item checks as any new invoice.
+type Invoicer interface {
+	CreateInvoice(ctx context.Context, invoice map[string]interface{}) (map[string]interface{}, error)
+}
+
+// Locker serializes conversions of the same estimate across instances
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// Service manages QuickBooks estimates, the quotes sent before invoicing
+type Service struct {
+	qb       QuickBooks
+	invoices Invoicer
+	locker   Locker
+}
+
+// NewService creates a new estimate service
+func NewService(qb QuickBooks, invoices Invoicer, locker Locker) *Service {
+	return &Service{
+		qb:       qb,
+		invoices: invoices,
+		locker:   locker,
+	}
+}
+
+// List returns a page of estimates, newest first, optionally only those of
+// a customer or in a status
+func (s *Service) List(ctx context.Context, opts ListOptions) ([]Estimate, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	q := query.Select("Estimate")
+	if opts.CustomerID != "" {
+		if !query.IsID(opts.CustomerID) {
+			return []Estimate{}, nil
+		}
+		q.Where("CustomerRef", "=", opts.CustomerID)
+	}
+	if opts.Status != "" {
+		if !updatableStatuses[opts.Status] && opts.Status != StatusClosed {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidStatus, opts.Status)
+		}
+		q.Where("TxnStatus", "=", opts.Status)
+	}
+	q.OrderByDesc("TxnDate").Offset(opts.Offset).Limit(opts.Limit)
+
+	estimates, err := query.List[Estimate](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list estimates: %w", err)
+	}
+	return estimates, nil
+}
+
+// Get returns an estimate by ID
+func (s *Service) Get(ctx context.Context, id string) (*Estimate, error) {
+	fields, err := s.qb.Read(ctx, "Estimate", id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return decode(fields)
+}
+
+// decode converts an estimate as read from QuickBooks
+func decode(fields map[string]json.RawMessage) (*Estimate, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var estimate Estimate
+	if err := json.Unmarshal(data, &estimate); err != nil {
+		return nil, fmt.Errorf("failed to decode estimate: %w", err)
+	}
+	return &estimate, nil
+}
+
+// Create creates a pending estimate for a customer
+func (s *Service) Create(ctx context.Context, estimate *Estimate) (*Estimate, error) {
+	if estimate.CustomerRef == nil || estimate.CustomerRef.Value == "" || len(estimate.Line) == 0 {
+		return nil, ErrInvalidEstimate
+	}
+	if estimate.TxnStatus != "" && !updatableStatuses[estimate.TxnStatus] {
+		return nil, ErrInvalidStatus
+	}
+	estimate.ID, estimate.SyncToken, estimate.EmailStatus, estimate.LinkedTxn, estimate.MetaData = "", "", "", nil, nil
+	estimate.TotalAmt = 0
+
+	var created struct {
+		Estimate Estimate `json:"Estimate"`
+	}
+	if err := s.qb.Create(ctx, "Estimate", estimate, &created); err != nil {
+		return nil, err
+	}
+	return &created.Estimate, nil
+}
+
+// Update applies the given fields to an estimate as a sparse update; fields
+// not given are left as they are. Setting TxnStatus to Accepted records the
+// customer's acceptance.
+func (s *Service) Update(ctx context.Context, id string, fields map[string]interface{}) (*Estimate, error) {
+	for name := range fields {
+		if readOnlyFields[name] {
+			return nil, fmt.Errorf("%w: %s", ErrReadOnlyField, name)
+		}
+	}
+	if value, ok := fields["TxnStatus"]; ok {
+		status, _ := value.(string)
+		if !updatableStatuses[status] {
+			return nil, ErrInvalidStatus
+		}
+	}
+	if lines, ok := fields["Line"]; ok {
+		if list, _ := lines.([]interface{}); len(list) == 0 {
+			return nil, ErrInvalidEstimate
+		}
+	}
+
+	var updated struct {
+		Estimate Estimate `json:"Estimate"`
+	}
+	err := s.qb.Modify(ctx, "Estimate", id, func(map[string]json.RawMessage) (map[string]interface{}, error) {
+		update := map[string]interface{}{"sparse": true}
+		for name, value := range fields {
+			update[name] = value
+		}
+		return update, nil
+	}, &updated)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &updated.Estimate, nil
+}
+
+// Send emails an estimate from QuickBooks to its BillEmail, or to email
+// when given
+func (s *Service) Send(ctx context.Context, id, email string) (*Estimate, error) {
+	email = strings.TrimSpace(email)
+	if email != "" && !emailPattern.MatchString(email) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+
+	var sent struct {
+		Estimate Estimate `json:"Estimate"`
+	}
+	if err := s.qb.Send(ctx, "Estimate", id, email, &sent); err != nil {
+		return nil, notFound(err)
+	}
+	return &sent.Estimate, nil
+}
+
+// Convert creates an invoice from an accepted estimate, linked to it so
+// QuickBooks closes the estimate. Conversions of the same estimate are
+// serialized, so retries cannot invoice it twice.
+func (s *Service) Convert(ctx context.Context, id string, opts ConvertOptions) (*Conversion, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := s.locker.Lock(ctx, fmt.Sprintf("%s:estimate:convert:%s", realmID, id))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	fields, err := s.qb.Read(ctx, "Estimate", id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	estimate, err := decode(fields)
+	if err != nil {
+		return nil, err
+	}
+	if invoiceID := estimate.InvoiceID(); invoiceID != "" {
+		return nil, fmt.Errorf("%w to invoice %s", ErrAlreadyConverted, invoiceID)
+	}
+	if estimate.TxnStatus != StatusAccepted {
+		return nil, fmt.Errorf("%w: estimate is %s", ErrNotAccepted, estimate.TxnStatus)
+	}
+
+	draft, err := invoiceDraft(fields, opts)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.invoices.CreateInvoice(ctx, draft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	converted, err := s.Get(ctx, id)
+	if err != nil {
+		// The invoice exists; report it with the estimate as it was read
+		converted = estimate
+	}
+	return &Conversion{Estimate: converted, Invoice: invoice}, nil
+}
+
+// invoiceDraft builds the invoice for an estimate from its stored fields.
+// Lines keep their details but not their IDs, and subtotal lines, which
+// QuickBooks computes, are left out.
+func invoiceDraft(fields map[string]json.RawMessage, opts ConvertOptions) (map[string]interface{}, error) {
+	draft := make(map[string]interface{})
+	for _, name := range invoiceFields {
+		if value, ok := fields[name]; ok {
+			draft[name] = value
+		}
+	}
+	var tax struct {
+		TxnTaxCodeRef json.RawMessage `json:"TxnTaxCodeRef"`
+	}
+	if raw, ok := fields["TxnTaxDetail"]; ok && json.Unmarshal(raw, &tax) == nil && tax.TxnTaxCodeRef != nil {
+		draft["TxnTaxDetail"] = map[string]interface{}{"TxnTaxCodeRef": tax.TxnTaxCodeRef}
+	}
+
+	var lines []map[string]json.RawMessage
+	if err := json.Unmarshal(fields["Line"], &lines); err != nil {
+		return nil, fmt.Errorf("failed to decode estimate lines: %w", err)
+	}
+	invoiceLines := make([]map[string]json.RawMessage, 0, len(lines))
+	for _, line := range lines {
+		var detailType string
+		json.Unmarshal(line["DetailType"], &detailType)
+		if detailType == "SubTotalLineDetail" {
+			continue
+		}
+		delete(line, "Id")
+		delete(line, "LineNum")
+		delete(line, "LinkedTxn")
+		invoiceLines = append(invoiceLines, line)
+	}
+	draft["Line"] = invoiceLines
+
+	var estimateID string
+	json.Unmarshal(fields["Id"], &estimateID)
+	draft["LinkedTxn"] = []LinkedTxn{{TxnID: estimateID, TxnType: "Estimate"}}
+	if opts.TxnDate != "" {
+		draft["TxnDate"] = opts.TxnDate
+	}
+	if opts.DueDate != "" {
+		draft["DueDate"] = opts.DueDate
+	}
+	if opts.DocNumber != "" {
+		draft["DocNumber"] = opts.DocNumber
+	}
+	return draft, nil
+}
+
+// notFound maps QuickBooks' "Object Not Found" fault (code 610) to
+// ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
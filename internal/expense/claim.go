@@ -0,0 +1,196 @@
+// expense/claim.go
+package expense
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Claim types
+const (
+	TypeMileage = "mileage"
+	TypeExpense = "expense"
+)
+
+// Claim statuses
+const (
+	StatusSubmitted = "submitted"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusConverted = "converted"
+)
+
+var (
+	// ErrClaimNotFound is returned when a claim does not exist
+	ErrClaimNotFound = errors.New("claim not found")
+	// ErrReceiptNotFound is returned when a receipt does not exist
+	ErrReceiptNotFound = errors.New("receipt not found")
+)
+
+// Receipt describes an uploaded receipt; the file itself is stored separately
+type Receipt struct {
+	ID          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// Conversion records the QuickBooks transaction a claim became
+type Conversion struct {
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+}
+
+// Claim is a mileage or reimbursable expense submitted by an employee
+type Claim struct {
+	ID          string      `json:"id"`
+	RealmID     string      `json:"realm_id"`
+	Type        string      `json:"type"`
+	Date        string      `json:"date"`
+	Description string      `json:"description"`
+	Miles       float64     `json:"miles,omitempty"`
+	Rate        float64     `json:"rate,omitempty"` // per mile
+	Amount      float64     `json:"amount"`
+	CustomerID  string      `json:"customer_id,omitempty"` // customer the cost is billable to
+	Billable    bool        `json:"billable"`
+	Receipts    []Receipt   `json:"receipts"`
+	Status      string      `json:"status"`
+	SubmittedBy string      `json:"submitted_by"`
+	ReviewedBy  string      `json:"reviewed_by,omitempty"`
+	ReviewNote  string      `json:"review_note,omitempty"`
+	ConvertedTo *Conversion `json:"converted_to,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Validate checks a newly submitted claim
+func (c *Claim) Validate() error {
+	if _, err := time.Parse("2006-01-02", c.Date); err != nil {
+		return fmt.Errorf("invalid date, expected YYYY-MM-DD")
+	}
+	if c.Description == "" {
+		return fmt.Errorf("description is required")
+	}
+	if c.Billable && c.CustomerID == "" {
+		return fmt.Errorf("customer_id is required for billable claims")
+	}
+
+	switch c.Type {
+	case TypeMileage:
+		if c.Miles <= 0 {
+			return fmt.Errorf("miles must be positive")
+		}
+	case TypeExpense:
+		if c.Amount <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+	default:
+		return fmt.Errorf("type must be %q or %q", TypeMileage, TypeExpense)
+	}
+
+	return nil
+}
+
+// Store persists claims and receipts per realm
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new claim store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// claimsKey is the hash of claim ID to claim for a realm
+func (s *Store) claimsKey(realmID string) string {
+	return fmt.Sprintf("%s:expenses:%s", s.prefix, realmID)
+}
+
+// receiptKey holds a receipt file
+func (s *Store) receiptKey(realmID, receiptID string) string {
+	return fmt.Sprintf("%s:expenses:receipt:%s:%s", s.prefix, realmID, receiptID)
+}
+
+// Save writes a claim
+func (s *Store) Save(ctx context.Context, c *Claim) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal claim: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.claimsKey(c.RealmID), c.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save claim: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a claim
+func (s *Store) Get(ctx context.Context, realmID, id string) (*Claim, error) {
+	data, err := s.client.HGet(ctx, s.claimsKey(realmID), id).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrClaimNotFound
+		}
+		return nil, fmt.Errorf("failed to get claim: %w", err)
+	}
+
+	var c Claim
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal claim: %w", err)
+	}
+
+	return &c, nil
+}
+
+// List returns a realm's claims, newest first
+func (s *Store) List(ctx context.Context, realmID string) ([]*Claim, error) {
+	values, err := s.client.HGetAll(ctx, s.claimsKey(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claims: %w", err)
+	}
+
+	claims := make([]*Claim, 0, len(values))
+	for _, v := range values {
+		var c Claim
+		if err := json.Unmarshal([]byte(v), &c); err != nil {
+			continue
+		}
+		claims = append(claims, &c)
+	}
+
+	sort.Slice(claims, func(i, j int) bool {
+		return claims[i].CreatedAt.After(claims[j].CreatedAt)
+	})
+
+	return claims, nil
+}
+
+// SaveReceipt stores a receipt file
+func (s *Store) SaveReceipt(ctx context.Context, realmID, receiptID string, content []byte) error {
+	if err := s.client.Set(ctx, s.receiptKey(realmID, receiptID), content, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save receipt: %w", err)
+	}
+	return nil
+}
+
+// GetReceipt retrieves a receipt file
+func (s *Store) GetReceipt(ctx context.Context, realmID, receiptID string) ([]byte, error) {
+	content, err := s.client.Get(ctx, s.receiptKey(realmID, receiptID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrReceiptNotFound
+		}
+		return nil, fmt.Errorf("failed to get receipt: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,273 @@
+// expense/handler.go
+package expense
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// maxReceiptSize limits uploaded receipts to 5 MB
+const maxReceiptSize = 5 << 20
+
+// receiptTypes are the accepted receipt content types
+var receiptTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/heic":      true,
+	"application/pdf": true,
+}
+
+// Handler provides HTTP handlers for mileage and expense claims
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new expense claim handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// writeError maps claim errors to HTTP responses
+func writeError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, ErrClaimNotFound), errors.Is(err, ErrReceiptNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrInvalidState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to "+action+": "+err.Error(), http.StatusBadRequest)
+	}
+}
+
+// SubmitClaim submits a mileage or reimbursable expense claim
+func (h *Handler) SubmitClaim(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var claim Claim
+	if err := json.NewDecoder(r.Body).Decode(&claim); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Submit(r.Context(), realmID, auth.GetUserID(r.Context()), &claim); err != nil {
+		writeError(w, "submit claim", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(claim)
+}
+
+// ListClaims returns the caller's claims, or every claim for reviewers,
+// optionally filtered by ?status=
+func (h *Handler) ListClaims(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := h.service.Store().List(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list claims: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	reviewer := CanReview(auth.GetRole(r.Context()))
+	status := r.URL.Query().Get("status")
+
+	filtered := make([]*Claim, 0, len(claims))
+	for _, c := range claims {
+		if (reviewer || c.SubmittedBy == userID) && (status == "" || c.Status == status) {
+			filtered = append(filtered, c)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"claims": filtered,
+	})
+}
+
+// GetClaim returns a claim visible to the caller
+func (h *Handler) GetClaim(w http.ResponseWriter, r *http.Request) {
+	claim, ok := h.visibleClaim(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(claim)
+}
+
+// visibleClaim loads the claim in the path if the caller submitted it or may review it
+func (h *Handler) visibleClaim(w http.ResponseWriter, r *http.Request) (*Claim, bool) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	claim, err := h.service.Store().Get(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "get claim", err)
+		return nil, false
+	}
+	if claim.SubmittedBy != auth.GetUserID(r.Context()) && !CanReview(auth.GetRole(r.Context())) {
+		writeError(w, "get claim", ErrClaimNotFound)
+		return nil, false
+	}
+
+	return claim, true
+}
+
+// UploadReceipt attaches a receipt image or PDF to the caller's claim
+func (h *Handler) UploadReceipt(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxReceiptSize)
+	if err := r.ParseMultipartForm(maxReceiptSize); err != nil {
+		http.Error(w, "Invalid upload", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+
+	contentType := http.DetectContentType(content)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !receiptTypes[contentType] {
+		contentType = header.Header.Get("Content-Type")
+	}
+	if !receiptTypes[contentType] {
+		http.Error(w, "Receipts must be JPEG, PNG, HEIC or PDF files", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	receipt, err := h.service.AddReceipt(r.Context(), realmID, mux.Vars(r)["id"], auth.GetUserID(r.Context()), header.Filename, contentType, content)
+	if err != nil {
+		writeError(w, "upload receipt", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// DownloadReceipt returns a receipt file from a claim visible to the caller
+func (h *Handler) DownloadReceipt(w http.ResponseWriter, r *http.Request) {
+	claim, ok := h.visibleClaim(w, r)
+	if !ok {
+		return
+	}
+
+	receiptID := mux.Vars(r)["receiptId"]
+	for _, receipt := range claim.Receipts {
+		if receipt.ID != receiptID {
+			continue
+		}
+
+		content, err := h.service.Store().GetReceipt(r.Context(), claim.RealmID, receipt.ID)
+		if err != nil {
+			writeError(w, "get receipt", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", receipt.ContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(receipt.Filename, `"`, "")+`"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+		return
+	}
+
+	writeError(w, "get receipt", ErrReceiptNotFound)
+}
+
+// ReviewClaim approves or rejects a submitted claim
+func (h *Handler) ReviewClaim(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Approve bool   `json:"approve"`
+		Note    string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claim, err := h.service.Review(r.Context(), realmID, mux.Vars(r)["id"],
+		auth.GetUserID(r.Context()), req.Approve, req.Note)
+	if err != nil {
+		writeError(w, "review claim", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(claim)
+}
+
+// ConvertClaim posts an approved claim to QuickBooks
+func (h *Handler) ConvertClaim(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req ConvertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claim, err := h.service.Convert(r.Context(), realmID, mux.Vars(r)["id"], req)
+	if err != nil {
+		writeError(w, "convert claim", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(claim)
+}
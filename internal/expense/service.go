@@ -0,0 +1,328 @@
+// expense/service.go
+package expense
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+)
+
+// DefaultMileageRate is the reimbursement rate per mile when none is configured
+const DefaultMileageRate = 0.70
+
+// Conversion targets
+const (
+	TargetExpense     = "expense"
+	TargetInvoiceLine = "invoice_line"
+)
+
+var (
+	// ErrForbidden is returned when the caller may not act on a claim
+	ErrForbidden = errors.New("not allowed to review claims")
+	// ErrInvalidState is returned when a claim is not in the right status for an action
+	ErrInvalidState = errors.New("claim is not in a valid state for this action")
+)
+
+// ReviewerRoles may approve, reject and convert claims, and see every claim
+var ReviewerRoles = []string{"admin", "manager"}
+
+// CanReview reports whether a role may approve and convert claims
+func CanReview(role string) bool {
+	for _, reviewer := range ReviewerRoles {
+		if role == reviewer {
+			return true
+		}
+	}
+	return false
+}
+
+// QuickBooks is the subset of the QuickBooks client used to post claims
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error
+}
+
+// ConvertRequest describes how to post an approved claim to QuickBooks
+type ConvertRequest struct {
+	Target           string `json:"target"`                       // expense or invoice_line
+	PaymentAccountID string `json:"payment_account_id,omitempty"` // expense: account the reimbursement is paid from
+	ExpenseAccountID string `json:"expense_account_id,omitempty"` // expense: account the cost is booked to
+	ItemID           string `json:"item_id,omitempty"`            // invoice_line: item to bill
+	InvoiceID        string `json:"invoice_id,omitempty"`         // invoice_line: append to this invoice instead of creating one
+}
+
+// Service handles claim submission, review and conversion
+type Service struct {
+	store       *Store
+	qb          QuickBooks
+	mileageRate float64
+}
+
+// NewService creates a new expense claim service
+func NewService(store *Store, qb QuickBooks, mileageRate float64) *Service {
+	if mileageRate <= 0 {
+		mileageRate = DefaultMileageRate
+	}
+	return &Service{
+		store:       store,
+		qb:          qb,
+		mileageRate: mileageRate,
+	}
+}
+
+// Store returns the claim store
+func (s *Service) Store() *Store {
+	return s.store
+}
+
+// Submit validates and stores a new claim; mileage is priced at the configured rate
+func (s *Service) Submit(ctx context.Context, realmID, userID string, c *Claim) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	c.ID = jobs.NewID()
+	c.RealmID = realmID
+	c.SubmittedBy = userID
+	c.Status = StatusSubmitted
+	c.Receipts = []Receipt{}
+	c.ReviewedBy, c.ReviewNote, c.ConvertedTo = "", "", nil
+	if c.Type == TypeMileage {
+		c.Rate = s.mileageRate
+		c.Amount = math.Round(c.Miles*c.Rate*100) / 100
+	} else {
+		c.Miles, c.Rate = 0, 0
+	}
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = c.CreatedAt
+
+	return s.store.Save(ctx, c)
+}
+
+// AddReceipt attaches a receipt to a claim that has not been converted yet
+func (s *Service) AddReceipt(ctx context.Context, realmID, claimID, userID, filename, contentType string, content []byte) (*Receipt, error) {
+	c, err := s.store.Get(ctx, realmID, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if c.SubmittedBy != userID {
+		return nil, ErrForbidden
+	}
+	if c.Status == StatusConverted || c.Status == StatusRejected {
+		return nil, ErrInvalidState
+	}
+
+	receipt := Receipt{
+		ID:          jobs.NewID(),
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        len(content),
+		UploadedAt:  time.Now(),
+	}
+	if err := s.store.SaveReceipt(ctx, realmID, receipt.ID, content); err != nil {
+		return nil, err
+	}
+
+	c.Receipts = append(c.Receipts, receipt)
+	c.UpdatedAt = time.Now()
+	if err := s.store.Save(ctx, c); err != nil {
+		return nil, err
+	}
+
+	return &receipt, nil
+}
+
+// Review approves or rejects a submitted claim. Only ReviewerRoles may
+// review; the route enforces it.
+func (s *Service) Review(ctx context.Context, realmID, claimID, reviewer string, approve bool, note string) (*Claim, error) {
+	c, err := s.store.Get(ctx, realmID, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != StatusSubmitted {
+		return nil, ErrInvalidState
+	}
+	if c.SubmittedBy == reviewer {
+		return nil, fmt.Errorf("%w: claims cannot be approved by their submitter", ErrForbidden)
+	}
+
+	c.Status = StatusRejected
+	if approve {
+		c.Status = StatusApproved
+	}
+	c.ReviewedBy = reviewer
+	c.ReviewNote = note
+	c.UpdatedAt = time.Now()
+
+	if err := s.store.Save(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Convert posts an approved claim to QuickBooks as an expense transaction or
+// a billable invoice line. Only ReviewerRoles may convert; the route
+// enforces it.
+func (s *Service) Convert(ctx context.Context, realmID, claimID string, req ConvertRequest) (*Claim, error) {
+	c, err := s.store.Get(ctx, realmID, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != StatusApproved {
+		return nil, ErrInvalidState
+	}
+
+	var conversion *Conversion
+	switch req.Target {
+	case TargetExpense:
+		conversion, err = s.createExpense(ctx, c, req)
+	case TargetInvoiceLine:
+		conversion, err = s.addInvoiceLine(ctx, c, req)
+	default:
+		return nil, fmt.Errorf("target must be %q or %q", TargetExpense, TargetInvoiceLine)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.Status = StatusConverted
+	c.ConvertedTo = conversion
+	c.UpdatedAt = time.Now()
+	if err := s.store.Save(ctx, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// memo describes a claim on the QuickBooks transaction
+func memo(c *Claim) string {
+	if c.Type == TypeMileage {
+		return fmt.Sprintf("Mileage %s: %g mi @ %.2f - %s", c.Date, c.Miles, c.Rate, c.Description)
+	}
+	return fmt.Sprintf("Reimbursable expense %s - %s", c.Date, c.Description)
+}
+
+// createExpense posts the claim as a cash Purchase
+func (s *Service) createExpense(ctx context.Context, c *Claim, req ConvertRequest) (*Conversion, error) {
+	if req.PaymentAccountID == "" || req.ExpenseAccountID == "" {
+		return nil, fmt.Errorf("payment_account_id and expense_account_id are required")
+	}
+
+	detail := map[string]interface{}{
+		"AccountRef": map[string]string{"value": req.ExpenseAccountID},
+	}
+	if c.CustomerID != "" {
+		detail["CustomerRef"] = map[string]string{"value": c.CustomerID}
+		if c.Billable {
+			detail["BillableStatus"] = "Billable"
+		}
+	}
+
+	purchase := map[string]interface{}{
+		"PaymentType": "Cash",
+		"AccountRef":  map[string]string{"value": req.PaymentAccountID},
+		"TxnDate":     c.Date,
+		"PrivateNote": "Expense claim " + c.ID,
+		"Line": []map[string]interface{}{{
+			"Amount":                        c.Amount,
+			"Description":                   memo(c),
+			"DetailType":                    "AccountBasedExpenseLineDetail",
+			"AccountBasedExpenseLineDetail": detail,
+		}},
+	}
+
+	var result struct {
+		Purchase struct {
+			ID string `json:"Id"`
+		} `json:"Purchase"`
+	}
+	if err := s.qb.Create(ctx, "Purchase", purchase, &result); err != nil {
+		return nil, err
+	}
+
+	return &Conversion{EntityType: "Purchase", EntityID: result.Purchase.ID}, nil
+}
+
+// addInvoiceLine bills the claim to its customer, on a new invoice or appended to an open one
+func (s *Service) addInvoiceLine(ctx context.Context, c *Claim, req ConvertRequest) (*Conversion, error) {
+	if c.CustomerID == "" {
+		return nil, fmt.Errorf("claim has no customer to bill")
+	}
+	if req.ItemID == "" {
+		return nil, fmt.Errorf("item_id is required")
+	}
+
+	qty, unitPrice := 1.0, c.Amount
+	if c.Type == TypeMileage {
+		qty, unitPrice = c.Miles, c.Rate
+	}
+	line := map[string]interface{}{
+		"Amount":      c.Amount,
+		"Description": memo(c),
+		"DetailType":  "SalesItemLineDetail",
+		"SalesItemLineDetail": map[string]interface{}{
+			"ItemRef":     map[string]string{"value": req.ItemID},
+			"Qty":         qty,
+			"UnitPrice":   unitPrice,
+			"ServiceDate": c.Date,
+		},
+	}
+
+	var result struct {
+		Invoice struct {
+			ID string `json:"Id"`
+		} `json:"Invoice"`
+	}
+
+	if req.InvoiceID == "" {
+		invoice := map[string]interface{}{
+			"CustomerRef": map[string]string{"value": c.CustomerID},
+			"TxnDate":     timezone.Now(ctx).Format("2006-01-02"),
+			"Line":        []interface{}{line},
+		}
+		if err := s.qb.Create(ctx, "Invoice", invoice, &result); err != nil {
+			return nil, err
+		}
+		return &Conversion{EntityType: "Invoice", EntityID: result.Invoice.ID}, nil
+	}
+
+	if strings.ContainsAny(req.InvoiceID, "'\\") {
+		return nil, fmt.Errorf("invalid invoice_id")
+	}
+
+	// Read and update under the invoice's write lock so a concurrent edit
+	// cannot make the SyncToken stale in between
+	err := s.qb.Modify(ctx, "Invoice", req.InvoiceID, func(invoice map[string]json.RawMessage) (map[string]interface{}, error) {
+		var customer struct {
+			Value string `json:"value"`
+		}
+		json.Unmarshal(invoice["CustomerRef"], &customer)
+		if customer.Value != c.CustomerID {
+			return nil, fmt.Errorf("invoice %s belongs to a different customer", req.InvoiceID)
+		}
+
+		// A sparse update replaces the whole line list, so resend the existing lines
+		var lines []interface{}
+		if err := json.Unmarshal(invoice["Line"], &lines); err != nil {
+			return nil, fmt.Errorf("failed to decode invoice lines: %w", err)
+		}
+		return map[string]interface{}{
+			"sparse": true,
+			"Line":   append(lines, line),
+		}, nil
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conversion{EntityType: "Invoice", EntityID: req.InvoiceID}, nil
+}
@@ -0,0 +1,196 @@
+// extid/context.go
+package extid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// Header names the external system whose IDs a request uses in place of
+// QuickBooks IDs, in the URL path and in references of write payloads.
+// Without it, an ID can name its system with a prefix, as in "salesforce:0015".
+const Header = "X-External-IDs"
+
+// pathEntities maps the collections whose {id} route variable is a
+// QuickBooks ID to the entity it identifies
+var pathEntities = map[string]string{
+	"customers": "Customer",
+	"items":     "Item",
+	"invoices":  "Invoice",
+	"payments":  "Payment",
+	"bills":     "Bill",
+}
+
+// varEntities maps route variables named after an entity to that entity
+var varEntities = map[string]string{
+	"customerId": "Customer",
+	"itemId":     "Item",
+}
+
+// contextKey is the type for external ID context keys
+type contextKey string
+
+const (
+	systemKey  contextKey = "extid_system"
+	pendingKey contextKey = "extid_pending"
+)
+
+// WithSystem returns a context whose IDs are those of an external system
+func WithSystem(ctx context.Context, system string) context.Context {
+	return context.WithValue(ctx, systemKey, system)
+}
+
+// SystemFromContext returns the external system of the request in ctx, or ""
+func SystemFromContext(ctx context.Context) string {
+	system, _ := ctx.Value(systemKey).(string)
+	return system
+}
+
+// externalRef is an ID in a named external system
+type externalRef struct {
+	system string
+	id     string
+}
+
+// pending carries the external IDs given in write payloads from the
+// before stage to the after stage, where the QuickBooks ID is known
+type pending struct {
+	mu   sync.Mutex
+	refs map[string]externalRef
+}
+
+// put remembers the external ID of the entity being written; an empty ref
+// forgets a previous one
+func (p *pending) put(entity string, ref externalRef) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ref.id == "" {
+		delete(p.refs, entity)
+		return
+	}
+	if p.refs == nil {
+		p.refs = make(map[string]externalRef)
+	}
+	p.refs[entity] = ref
+}
+
+// take returns and forgets the external ID of the entity just written
+func (p *pending) take(entity string) (externalRef, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ref, ok := p.refs[entity]
+	delete(p.refs, entity)
+	return ref, ok
+}
+
+// split separates an ID into its system and the ID within it. IDs with a
+// known system prefix name their own system; others belong to
+// defaultSystem. An empty system means a QuickBooks ID.
+func split(value, defaultSystem string) externalRef {
+	if i := strings.Index(value, ":"); i > 0 {
+		system := strings.ToLower(value[:i])
+		if system == NativeSystem {
+			return externalRef{id: value[i+1:]}
+		}
+		if systemPattern.MatchString(system) {
+			return externalRef{system: system, id: value[i+1:]}
+		}
+	}
+	return externalRef{system: defaultSystem, id: value}
+}
+
+// translate returns the QuickBooks ID an ID in the request in ctx stands for
+func (s *Store) translate(ctx context.Context, entity, value string) (string, error) {
+	ref := split(value, SystemFromContext(ctx))
+	if ref.system == "" {
+		return ref.id, nil
+	}
+	id, err := s.QuickBooksID(ctx, ref.system, entity, ref.id)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("%w: no %s mapped to %s ID %q", ErrNotFound, entity, ref.system, ref.id)
+	}
+	return id, nil
+}
+
+// pathEntity returns the entity a route variable identifies
+func pathEntity(r *http.Request, name string) (string, bool) {
+	if entity, ok := varEntities[name]; ok {
+		return entity, true
+	}
+	if name != "id" {
+		return "", false
+	}
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+	segments := strings.Split(template, "/")
+	for i := 1; i < len(segments); i++ {
+		if segments[i] == "{id}" {
+			entity, ok := pathEntities[segments[i-1]]
+			return entity, ok
+		}
+	}
+	return "", false
+}
+
+// Middleware applies the X-External-IDs header and translates external
+// IDs in the URL path into QuickBooks IDs. It must run after the
+// QuickBooks auth middleware, which identifies the company.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if value := r.Header.Get(Header); value != "" {
+			system, err := normalizeSystem(value)
+			if err != nil {
+				http.Error(w, "Invalid "+Header+" header: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			ctx = WithSystem(ctx, system)
+		}
+		ctx = context.WithValue(ctx, pendingKey, &pending{})
+
+		vars := mux.Vars(r)
+		translated := make(map[string]string, len(vars))
+		changed := false
+		for name, value := range vars {
+			translated[name] = value
+			entity, ok := pathEntity(r, name)
+			if !ok {
+				continue
+			}
+			id, err := s.translate(ctx, entity, value)
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to translate external ID: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if id != value {
+				translated[name] = id
+				changed = true
+			}
+		}
+
+		r = r.WithContext(ctx)
+		if changed {
+			r = mux.SetURLVars(r, translated)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,123 @@
+// extid/handler.go
+package extid
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for external ID mappings
+type Handler struct {
+	store *Store
+}
+
+// NewHandler creates a new external ID mapping handler
+func NewHandler(store *Store) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+// status returns the HTTP status for a store error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidSystem), errors.Is(err, ErrInvalidEntity):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ListMappings returns the mappings of one system and entity
+func (h *Handler) ListMappings(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	mappings, err := h.store.List(r.Context(), auth.GetTenantID(r.Context()), realmID, vars["system"], vars["entity"])
+	if err != nil {
+		http.Error(w, "Failed to list external ID mappings: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(mappings)
+}
+
+// GetMapping returns the mapping of one external ID
+func (h *Handler) GetMapping(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	mapping, err := h.store.Get(r.Context(), auth.GetTenantID(r.Context()), realmID, vars["system"], vars["entity"], vars["externalId"])
+	if err != nil {
+		http.Error(w, "Failed to get external ID mapping: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(mapping)
+}
+
+// SaveMapping maps an external ID to the QuickBooks ID in the body
+func (h *Handler) SaveMapping(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		QuickBooksID string `json:"quickbooks_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.QuickBooksID == "" {
+		http.Error(w, "Invalid request body: quickbooks_id is required", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	mapping := &Mapping{
+		System:       vars["system"],
+		Entity:       vars["entity"],
+		ExternalID:   vars["externalId"],
+		QuickBooksID: body.QuickBooksID,
+	}
+	if err := h.store.Save(r.Context(), auth.GetTenantID(r.Context()), realmID, mapping); err != nil {
+		http.Error(w, "Failed to save external ID mapping: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(mapping)
+}
+
+// DeleteMapping removes the mapping of one external ID
+func (h *Handler) DeleteMapping(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.store.Delete(r.Context(), auth.GetTenantID(r.Context()), realmID, vars["system"], vars["entity"], vars["externalId"]); err != nil {
+		http.Error(w, "Failed to delete external ID mapping: "+err.Error(), status(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
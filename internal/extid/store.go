@@ -0,0 +1,224 @@
+// extid/store.go
+package extid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/go-redis/redis/v8"
+)
+
+// NativeSystem addresses QuickBooks IDs explicitly, e.g. "qbo:42" while
+// the X-External-IDs header is set. It cannot be used for mappings.
+const NativeSystem = "qbo"
+
+var (
+	// ErrInvalidSystem is returned for external system names that are not
+	// lowercase letters, digits, dashes and underscores
+	ErrInvalidSystem = errors.New("invalid external system name")
+	// ErrInvalidEntity is returned for names that are not QuickBooks entities
+	ErrInvalidEntity = errors.New("invalid entity name")
+	// ErrNotFound is returned when an external ID has no mapping
+	ErrNotFound = errors.New("external ID not mapped")
+)
+
+var (
+	systemPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+	entityPattern = regexp.MustCompile(`^[A-Z][A-Za-z]{1,63}$`)
+)
+
+// Mapping links an entity's ID in another system to its QuickBooks ID
+type Mapping struct {
+	System       string    `json:"system"`
+	Entity       string    `json:"entity"`
+	ExternalID   string    `json:"external_id"`
+	QuickBooksID string    `json:"quickbooks_id"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Store persists external ID mappings per tenant and QuickBooks company.
+// Each external ID maps to one QuickBooks ID and each QuickBooks ID to at
+// most one external ID per system.
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new external ID mapping store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// idsKey returns the hash of mappings by external ID
+func (s *Store) idsKey(tenantID, realmID, system, entity string) string {
+	return fmt.Sprintf("%s:extid:ids:%s:%s:%s:%s", s.prefix, tenantID, realmID, system, entity)
+}
+
+// qboKey returns the hash of external IDs by QuickBooks ID
+func (s *Store) qboKey(tenantID, realmID, system, entity string) string {
+	return fmt.Sprintf("%s:extid:qbo:%s:%s:%s:%s", s.prefix, tenantID, realmID, system, entity)
+}
+
+// normalizeSystem validates and normalizes an external system name
+func normalizeSystem(system string) (string, error) {
+	system = strings.ToLower(strings.TrimSpace(system))
+	if !systemPattern.MatchString(system) || system == NativeSystem {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSystem, system)
+	}
+	return system, nil
+}
+
+// normalize validates and normalizes a system and entity name
+func normalize(system, entity string) (string, error) {
+	system, err := normalizeSystem(system)
+	if err != nil {
+		return "", err
+	}
+	if !entityPattern.MatchString(entity) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEntity, entity)
+	}
+	return system, nil
+}
+
+// Get returns the mapping of an external ID, or ErrNotFound
+func (s *Store) Get(ctx context.Context, tenantID, realmID, system, entity, externalID string) (*Mapping, error) {
+	system, err := normalize(system, entity)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.client.HGet(ctx, s.idsKey(tenantID, realmID, system, entity), externalID).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: no %s mapped to %s ID %q", ErrNotFound, entity, system, externalID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get external ID mapping: %w", err)
+	}
+
+	var m Mapping
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal external ID mapping: %w", err)
+	}
+	return &m, nil
+}
+
+// Save creates or replaces a mapping. A previous mapping of either ID in
+// the same system is removed.
+func (s *Store) Save(ctx context.Context, tenantID, realmID string, m *Mapping) error {
+	system, err := normalize(m.System, m.Entity)
+	if err != nil {
+		return err
+	}
+	if m.ExternalID == "" || m.QuickBooksID == "" {
+		return fmt.Errorf("external_id and quickbooks_id are required")
+	}
+	m.System = system
+	m.UpdatedAt = time.Now()
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal external ID mapping: %w", err)
+	}
+
+	idsKey := s.idsKey(tenantID, realmID, system, m.Entity)
+	qboKey := s.qboKey(tenantID, realmID, system, m.Entity)
+
+	// Look up what either ID was mapped to before, to unlink it
+	var staleQBO, staleExternal string
+	if old, err := s.Get(ctx, tenantID, realmID, system, m.Entity, m.ExternalID); err == nil {
+		staleQBO = old.QuickBooksID
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if old, err := s.client.HGet(ctx, qboKey, m.QuickBooksID).Result(); err == nil {
+		staleExternal = old
+	} else if err != redis.Nil {
+		return fmt.Errorf("failed to get external ID mapping: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if staleQBO != "" && staleQBO != m.QuickBooksID {
+			pipe.HDel(ctx, qboKey, staleQBO)
+		}
+		if staleExternal != "" && staleExternal != m.ExternalID {
+			pipe.HDel(ctx, idsKey, staleExternal)
+		}
+		pipe.HSet(ctx, idsKey, m.ExternalID, data)
+		pipe.HSet(ctx, qboKey, m.QuickBooksID, m.ExternalID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save external ID mapping: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the mapping of an external ID
+func (s *Store) Delete(ctx context.Context, tenantID, realmID, system, entity, externalID string) error {
+	m, err := s.Get(ctx, tenantID, realmID, system, entity, externalID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, s.idsKey(tenantID, realmID, m.System, entity), externalID)
+		pipe.HDel(ctx, s.qboKey(tenantID, realmID, m.System, entity), m.QuickBooksID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete external ID mapping: %w", err)
+	}
+	return nil
+}
+
+// List returns all mappings of a system and entity ordered by external ID
+func (s *Store) List(ctx context.Context, tenantID, realmID, system, entity string) ([]Mapping, error) {
+	system, err := normalize(system, entity)
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.client.HGetAll(ctx, s.idsKey(tenantID, realmID, system, entity)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list external ID mappings: %w", err)
+	}
+
+	mappings := make([]Mapping, 0, len(values))
+	for _, v := range values {
+		var m Mapping
+		if err := json.Unmarshal([]byte(v), &m); err != nil {
+			continue
+		}
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].ExternalID < mappings[j].ExternalID })
+	return mappings, nil
+}
+
+// QuickBooksID returns the QuickBooks ID mapped to an external ID for the
+// tenant and company in ctx, or "" if it has none. An empty system means
+// the one named by the request's X-External-IDs header.
+func (s *Store) QuickBooksID(ctx context.Context, system, entity, externalID string) (string, error) {
+	if system == "" {
+		system = SystemFromContext(ctx)
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return "", err
+	}
+	m, err := s.Get(ctx, auth.GetTenantID(ctx), realmID, system, entity, externalID)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return m.QuickBooksID, nil
+}
@@ -0,0 +1,129 @@
+// extid/transform.go
+package extid
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/refs"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// ExternalIDField is the payload field that gives the external ID of the
+// record being written. It may carry a system prefix.
+const ExternalIDField = "external_id"
+
+// Transform translates external IDs in payloads about to be written and
+// records the mapping of the payload's own external ID once QuickBooks has
+// assigned or confirmed the record's ID. Reference values and the Id of
+// updates are translated when they carry a system prefix or the request
+// has an X-External-IDs header.
+func (s *Store) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	switch stage {
+	case qbclient.StageBeforeCreate, qbclient.StageBeforeUpdate:
+		if stage == qbclient.StageBeforeUpdate {
+			if id, _ := data["Id"].(string); id != "" {
+				translated, err := s.translate(ctx, entity, id)
+				if err != nil {
+					return nil, fmt.Errorf("Id: %w", err)
+				}
+				data["Id"] = translated
+			}
+		}
+		if err := s.walk(ctx, data); err != nil {
+			return nil, err
+		}
+		if err := s.hold(ctx, entity, data); err != nil {
+			return nil, err
+		}
+	case qbclient.StageAfterCreate, qbclient.StageAfterUpdate:
+		if err := s.record(ctx, entity, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// walk translates the value of every reference in a payload in place
+func (s *Store) walk(ctx context.Context, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if target, ok := refs.RefEntity(key); ok {
+				if ref, ok := field.(map[string]interface{}); ok {
+					if id, _ := ref["value"].(string); id != "" {
+						translated, err := s.translate(ctx, target, id)
+						if err != nil {
+							return fmt.Errorf("%s: %w", key, err)
+						}
+						ref["value"] = translated
+					}
+					continue
+				}
+			}
+			if err := s.walk(ctx, field); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			if err := s.walk(ctx, elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hold takes the payload's external ID out of the payload and keeps it
+// for the after stage of the same write
+func (s *Store) hold(ctx context.Context, entity string, data map[string]interface{}) error {
+	p, ok := ctx.Value(pendingKey).(*pending)
+	value, _ := data[ExternalIDField].(string)
+	delete(data, ExternalIDField)
+	if !ok {
+		if value != "" {
+			return fmt.Errorf("%s can only be given on API requests", ExternalIDField)
+		}
+		return nil
+	}
+
+	var ref externalRef
+	if value != "" {
+		ref = split(value, SystemFromContext(ctx))
+		if ref.system == "" {
+			return fmt.Errorf("%s: no external system given; prefix the ID or set the %s header", ExternalIDField, Header)
+		}
+	}
+	p.put(entity, ref)
+	return nil
+}
+
+// record saves the mapping held for a record that was just written
+func (s *Store) record(ctx context.Context, entity string, data map[string]interface{}) error {
+	p, ok := ctx.Value(pendingKey).(*pending)
+	if !ok {
+		return nil
+	}
+	ref, ok := p.take(entity)
+	if !ok {
+		return nil
+	}
+	id, _ := data["Id"].(string)
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m := &Mapping{
+		System:       ref.system,
+		Entity:       entity,
+		ExternalID:   ref.id,
+		QuickBooksID: id,
+	}
+	if err := s.Save(ctx, auth.GetTenantID(ctx), realmID, m); err != nil {
+		return fmt.Errorf("%s %s was saved to QuickBooks but its %s ID was not recorded: %w", entity, id, ref.system, err)
+	}
+	return nil
+}
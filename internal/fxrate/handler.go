@@ -0,0 +1,178 @@
+// fxrate/handler.go
+package fxrate
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// Handler provides HTTP handlers for exchange rates and revaluation
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new exchange rate handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrMultiCurrencyDisabled):
+		return http.StatusConflict
+	case errors.Is(err, ErrRateNotFound), errors.Is(err, ErrOverrideNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+// date parses an optional date query parameter, defaulting to today in the
+// realm's time zone
+func date(r *http.Request, param string) (time.Time, error) {
+	v := r.URL.Query().Get(param)
+	if v == "" {
+		return timezone.Today(r.Context()), nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// GetRate returns the rate for ?currency= on ?date=, today by default
+func (h *Handler) GetRate(w http.ResponseWriter, r *http.Request) {
+	if _, err := auth.GetCompanyID(r.Context()); err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	currency, err := NormalizeCurrency(r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	day, err := date(r, "date")
+	if err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	rate, err := h.service.Rate(r.Context(), currency, day)
+	if err != nil {
+		http.Error(w, "Failed to get exchange rate: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rate)
+}
+
+// ListOverrides returns the company's manual rates
+func (h *Handler) ListOverrides(w http.ResponseWriter, r *http.Request) {
+	if _, err := auth.GetCompanyID(r.Context()); err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	overrides, err := h.service.ListOverrides(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list exchange rate overrides: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"overrides": overrides,
+	})
+}
+
+// SetOverride sets the rate for a currency on a date, e.g.
+// PUT /exchange-rates/overrides/EUR/2024-06-30 {"rate": 1.0712}
+func (h *Handler) SetOverride(w http.ResponseWriter, r *http.Request) {
+	if _, err := auth.GetCompanyID(r.Context()); err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Rate decimal.Decimal `json:"rate"`
+		Note string          `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	override := &Override{
+		Currency: vars["currency"],
+		Date:     vars["date"],
+		Rate:     money.NewAmount(req.Rate),
+		Note:     req.Note,
+	}
+	if err := h.service.SetOverride(r.Context(), override); err != nil {
+		if errors.Is(err, ErrMultiCurrencyDisabled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(override)
+}
+
+// DeleteOverride removes the manual rate for a currency on a date
+func (h *Handler) DeleteOverride(w http.ResponseWriter, r *http.Request) {
+	if _, err := auth.GetCompanyID(r.Context()); err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.service.DeleteOverride(r.Context(), vars["currency"], vars["date"]); err != nil {
+		if errors.Is(err, ErrOverrideNotFound) {
+			http.Error(w, "Exchange rate override not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete exchange rate override: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Revaluation reports unrealized gains and losses on open foreign-currency
+// invoices at the rates for ?as_of=, today by default
+func (h *Handler) Revaluation(w http.ResponseWriter, r *http.Request) {
+	if _, err := auth.GetCompanyID(r.Context()); err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	asOf, err := date(r, "as_of")
+	if err != nil {
+		http.Error(w, "Invalid as_of date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Revaluation(r.Context(), asOf)
+	if err != nil {
+		http.Error(w, "Failed to run revaluation report: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,165 @@
+// fxrate/revaluation.go
+package fxrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/shopspring/decimal"
+)
+
+// RevaluedInvoice is an open foreign-currency invoice valued at its own
+// rate and at the revaluation rate
+type RevaluedInvoice struct {
+	ID           string       `json:"id"`
+	DocNumber    string       `json:"doc_number,omitempty"`
+	TxnDate      string       `json:"txn_date"`
+	CustomerID   string       `json:"customer_id"`
+	CustomerName string       `json:"customer_name,omitempty"`
+	Currency     string       `json:"currency"`
+	Balance      money.Amount `json:"balance"` // open balance in the invoice's currency
+	BookedRate   money.Amount `json:"booked_rate"`
+	Booked       money.Amount `json:"booked"` // home currency at the booked rate
+	Rate         money.Amount `json:"rate"`
+	Revalued     money.Amount `json:"revalued"` // home currency at the revaluation rate
+	GainLoss     money.Amount `json:"gain_loss"`
+}
+
+// CurrencyRevaluation totals the revaluation of one currency's invoices
+type CurrencyRevaluation struct {
+	Currency string       `json:"currency"`
+	Rate     *Rate        `json:"rate"`
+	Invoices int          `json:"invoices"`
+	Balance  money.Amount `json:"balance"`
+	Booked   money.Amount `json:"booked"`
+	Revalued money.Amount `json:"revalued"`
+	GainLoss money.Amount `json:"gain_loss"`
+}
+
+// RevaluationReport lists unrealized gains and losses on open foreign-currency
+// invoices. Positive amounts are gains.
+type RevaluationReport struct {
+	AsOf         string                `json:"as_of"`
+	HomeCurrency string                `json:"home_currency"`
+	Currencies   []CurrencyRevaluation `json:"currencies"`
+	Invoices     []RevaluedInvoice     `json:"invoices"`
+	GainLoss     money.Amount          `json:"gain_loss"`
+	Errors       []string              `json:"errors,omitempty"` // currencies and invoices left out for want of a rate
+}
+
+// openInvoice is the part of an invoice a revaluation needs
+type openInvoice struct {
+	ID           string          `json:"Id"`
+	DocNumber    string          `json:"DocNumber"`
+	TxnDate      string          `json:"TxnDate"`
+	Balance      decimal.Decimal `json:"Balance"`
+	ExchangeRate decimal.Decimal `json:"ExchangeRate"`
+	CustomerRef  struct {
+		Value string `json:"value"`
+		Name  string `json:"name"`
+	} `json:"CustomerRef"`
+	CurrencyRef struct {
+		Value string `json:"value"`
+	} `json:"CurrencyRef"`
+}
+
+// Revaluation values the current company's open foreign-currency invoices
+// dated on or before asOf at the rate for asOf, against the rate each was
+// booked at. Balances are those open now, so a past asOf restates today's
+// receivables at that date's rates.
+func (s *Service) Revaluation(ctx context.Context, asOf time.Time) (*RevaluationReport, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	home, err := s.homeCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invoices, err := query.All[openInvoice](ctx, s.qb, query.Select("Invoice",
+		"Id", "DocNumber", "TxnDate", "Balance", "ExchangeRate", "CustomerRef", "CurrencyRef").
+		Where("Balance", ">", "0"))
+	if err != nil {
+		return nil, err
+	}
+
+	policy := money.PolicyFromContext(ctx)
+	day := asOf.Format("2006-01-02")
+	report := &RevaluationReport{
+		AsOf:         day,
+		HomeCurrency: home,
+		Currencies:   []CurrencyRevaluation{},
+		Invoices:     []RevaluedInvoice{},
+	}
+	totals := make(map[string]*CurrencyRevaluation)
+	failed := make(map[string]bool)
+	for _, inv := range invoices {
+		currency := inv.CurrencyRef.Value
+		if currency == "" || currency == home || inv.TxnDate > day || failed[currency] {
+			continue
+		}
+
+		if !inv.ExchangeRate.IsPositive() {
+			report.Errors = append(report.Errors, fmt.Sprintf("invoice %s: no booked exchange rate", inv.ID))
+			continue
+		}
+
+		total, ok := totals[currency]
+		if !ok {
+			rate, err := s.rate(ctx, realmID, home, currency, asOf)
+			if err != nil {
+				failed[currency] = true
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", currency, err))
+				continue
+			}
+			total = &CurrencyRevaluation{Currency: currency, Rate: rate}
+			totals[currency] = total
+		}
+
+		booked := policy.Round(inv.Balance.Mul(inv.ExchangeRate))
+		revalued := policy.Round(inv.Balance.Mul(total.Rate.Rate.Decimal))
+		line := RevaluedInvoice{
+			ID:           inv.ID,
+			DocNumber:    inv.DocNumber,
+			TxnDate:      inv.TxnDate,
+			CustomerID:   inv.CustomerRef.Value,
+			CustomerName: inv.CustomerRef.Name,
+			Currency:     currency,
+			Balance:      money.NewAmount(inv.Balance),
+			BookedRate:   money.NewAmount(inv.ExchangeRate),
+			Booked:       money.NewAmount(booked),
+			Rate:         total.Rate.Rate,
+			Revalued:     money.NewAmount(revalued),
+			GainLoss:     money.NewAmount(revalued.Sub(booked)),
+		}
+		report.Invoices = append(report.Invoices, line)
+
+		total.Invoices++
+		total.Balance = money.NewAmount(total.Balance.Add(line.Balance.Decimal))
+		total.Booked = money.NewAmount(total.Booked.Add(line.Booked.Decimal))
+		total.Revalued = money.NewAmount(total.Revalued.Add(line.Revalued.Decimal))
+		total.GainLoss = money.NewAmount(total.GainLoss.Add(line.GainLoss.Decimal))
+		report.GainLoss = money.NewAmount(report.GainLoss.Add(line.GainLoss.Decimal))
+	}
+
+	for _, total := range totals {
+		report.Currencies = append(report.Currencies, *total)
+	}
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		return report.Currencies[i].Currency < report.Currencies[j].Currency
+	})
+	sort.SliceStable(report.Invoices, func(i, j int) bool {
+		a, b := report.Invoices[i], report.Invoices[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		return a.TxnDate < b.TxnDate
+	})
+	return report, nil
+}
@@ -0,0 +1,222 @@
+// fxrate/service.go
+package fxrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrMultiCurrencyDisabled is returned for companies that only use their home currency
+	ErrMultiCurrencyDisabled = errors.New("multicurrency is not enabled for this company")
+	// ErrRateNotFound is returned when QuickBooks has no rate for a currency
+	ErrRateNotFound = errors.New("exchange rate not found")
+)
+
+// Rate sources
+const (
+	SourceHome       = "home" // the home currency, always 1
+	SourceOverride   = "override"
+	SourceQuickBooks = "quickbooks"
+)
+
+// How long QuickBooks rates are cached. Rates for past dates rarely change;
+// today's may still be updated during the day.
+const (
+	historicalRateTTL = 7 * 24 * time.Hour
+	currentRateTTL    = time.Hour
+)
+
+// currencyPattern matches ISO 4217 currency codes
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// QuickBooks is the subset of the QuickBooks client used for exchange rates
+type QuickBooks interface {
+	Querier
+	GetPreferences(ctx context.Context) (*qbclient.Preferences, error)
+	GetExchangeRate(ctx context.Context, currency, date string) (*qbclient.ExchangeRate, error)
+}
+
+// Rate converts one unit of a currency to the home currency on a date
+type Rate struct {
+	Currency     string       `json:"currency"`
+	HomeCurrency string       `json:"home_currency"`
+	Date         string       `json:"date"`
+	AsOfDate     string       `json:"as_of_date"` // date of the QuickBooks rate, the closest on or before date
+	Rate         money.Amount `json:"rate"`
+	Source       string       `json:"source"`
+	Note         string       `json:"note,omitempty"`
+}
+
+// Service looks up exchange rates per realm and date, preferring overrides
+// to QuickBooks' rates and caching those
+type Service struct {
+	qb    QuickBooks
+	store *Store
+}
+
+// NewService creates a new exchange rate service
+func NewService(qb QuickBooks, store *Store) *Service {
+	return &Service{
+		qb:    qb,
+		store: store,
+	}
+}
+
+// NormalizeCurrency upper-cases a currency code and checks it is well formed
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !currencyPattern.MatchString(currency) {
+		return "", fmt.Errorf("invalid currency code %q", currency)
+	}
+	return currency, nil
+}
+
+// homeCurrency returns the current company's home currency, refusing
+// companies without multicurrency
+func (s *Service) homeCurrency(ctx context.Context) (string, error) {
+	prefs, err := s.qb.GetPreferences(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !prefs.CurrencyPrefs.MultiCurrencyEnabled {
+		return "", ErrMultiCurrencyDisabled
+	}
+	if home := prefs.CurrencyPrefs.HomeCurrency.Value; home != "" {
+		return home, nil
+	}
+	return "USD", nil
+}
+
+// Rate returns the current company's rate for a currency on a date
+func (s *Service) Rate(ctx context.Context, currency string, date time.Time) (*Rate, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currency, err = NormalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	home, err := s.homeCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.rate(ctx, realmID, home, currency, date)
+}
+
+// rate looks up a rate: the home currency converts at 1, then an override
+// for the date applies, then QuickBooks' rate, cached
+func (s *Service) rate(ctx context.Context, realmID, home, currency string, date time.Time) (*Rate, error) {
+	day := date.Format("2006-01-02")
+	if currency == home {
+		return &Rate{Currency: currency, HomeCurrency: home, Date: day, AsOfDate: day, Rate: money.NewAmount(decimal.NewFromInt(1)), Source: SourceHome}, nil
+	}
+
+	override, err := s.store.GetOverride(ctx, realmID, currency, day)
+	switch {
+	case err == nil:
+		return &Rate{Currency: currency, HomeCurrency: home, Date: day, AsOfDate: day, Rate: override.Rate, Source: SourceOverride, Note: override.Note}, nil
+	case !errors.Is(err, ErrOverrideNotFound):
+		return nil, err
+	}
+
+	cached, err := s.store.GetRate(ctx, realmID, currency, day)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to read cached exchange rate", "currency", currency, "date", day, "error", err)
+	}
+	if cached != nil && cached.HomeCurrency == home {
+		return cached, nil
+	}
+
+	found, err := s.qb.GetExchangeRate(ctx, currency, day)
+	if err != nil {
+		return nil, err
+	}
+	if found.Rate <= 0 {
+		return nil, fmt.Errorf("%w: %s on %s", ErrRateNotFound, currency, day)
+	}
+	rate := &Rate{
+		Currency:     currency,
+		HomeCurrency: home,
+		Date:         day,
+		AsOfDate:     found.AsOfDate,
+		Rate:         money.NewAmount(decimal.NewFromFloat(found.Rate)),
+		Source:       SourceQuickBooks,
+	}
+
+	ttl := historicalRateTTL
+	if !date.Before(timezone.Today(ctx)) {
+		ttl = currentRateTTL
+	}
+	if err := s.store.SaveRate(ctx, realmID, rate, ttl); err != nil {
+		logging.FromContext(ctx).Warn("failed to cache exchange rate", "currency", currency, "date", day, "error", err)
+	}
+	return rate, nil
+}
+
+// SetOverride validates and stores an override for the current company
+func (s *Service) SetOverride(ctx context.Context, override *Override) error {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return err
+	}
+	if override.Currency, err = NormalizeCurrency(override.Currency); err != nil {
+		return err
+	}
+	if _, err := time.Parse("2006-01-02", override.Date); err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", override.Date)
+	}
+	if !override.Rate.IsPositive() {
+		return fmt.Errorf("rate must be positive")
+	}
+	home, err := s.homeCurrency(ctx)
+	if err != nil {
+		return err
+	}
+	if override.Currency == home {
+		return fmt.Errorf("%s is the home currency and always converts at 1", home)
+	}
+
+	override.UpdatedBy = auth.GetUserID(ctx)
+	override.UpdatedAt = time.Now()
+	return s.store.SaveOverride(ctx, realmID, override)
+}
+
+// ListOverrides returns the current company's overrides
+func (s *Service) ListOverrides(ctx context.Context) ([]Override, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.ListOverrides(ctx, realmID)
+}
+
+// DeleteOverride removes one of the current company's overrides, so
+// QuickBooks' rate applies again
+func (s *Service) DeleteOverride(ctx context.Context, currency, date string) error {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return err
+	}
+	if currency, err = NormalizeCurrency(currency); err != nil {
+		return err
+	}
+	return s.store.DeleteOverride(ctx, realmID, currency, date)
+}
@@ -0,0 +1,151 @@
+// fxrate/store.go
+package fxrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrOverrideNotFound is returned when a currency has no override on a date
+var ErrOverrideNotFound = errors.New("exchange rate override not found")
+
+// Override is a rate entered by hand for a currency on a date, used instead
+// of QuickBooks' rate, e.g. the rate a bank actually applied
+type Override struct {
+	Currency  string       `json:"currency"`
+	Date      string       `json:"date"`
+	Rate      money.Amount `json:"rate"`
+	Note      string       `json:"note,omitempty"`
+	UpdatedBy string       `json:"updated_by,omitempty"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Store caches QuickBooks exchange rates and keeps overrides per realm
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new exchange rate store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// rateKey holds a realm's cached QuickBooks rate for a currency on a date
+func (s *Store) rateKey(realmID, currency, date string) string {
+	return fmt.Sprintf("%s:fxrate:rates:%s:%s:%s", s.prefix, realmID, currency, date)
+}
+
+// overridesKey is the hash of currency and date to override for a realm
+func (s *Store) overridesKey(realmID string) string {
+	return fmt.Sprintf("%s:fxrate:overrides:%s", s.prefix, realmID)
+}
+
+// overrideField is an override's field in the realm's hash
+func overrideField(currency, date string) string {
+	return currency + ":" + date
+}
+
+// GetRate retrieves a cached rate, or nil if none is cached
+func (s *Store) GetRate(ctx context.Context, realmID, currency, date string) (*Rate, error) {
+	data, err := s.client.Get(ctx, s.rateKey(realmID, currency, date)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cached exchange rate: %w", err)
+	}
+
+	var rate Rate
+	if err := json.Unmarshal(data, &rate); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached exchange rate: %w", err)
+	}
+	return &rate, nil
+}
+
+// SaveRate caches a rate for ttl
+func (s *Store) SaveRate(ctx context.Context, realmID string, rate *Rate, ttl time.Duration) error {
+	data, err := json.Marshal(rate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exchange rate: %w", err)
+	}
+	if err := s.client.Set(ctx, s.rateKey(realmID, rate.Currency, rate.Date), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache exchange rate: %w", err)
+	}
+	return nil
+}
+
+// SaveOverride stores an override, replacing any for the same currency and date
+func (s *Store) SaveOverride(ctx context.Context, realmID string, override *Override) error {
+	data, err := json.Marshal(override)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exchange rate override: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.overridesKey(realmID), overrideField(override.Currency, override.Date), data).Err(); err != nil {
+		return fmt.Errorf("failed to save exchange rate override: %w", err)
+	}
+	return nil
+}
+
+// GetOverride retrieves the override for a currency on a date
+func (s *Store) GetOverride(ctx context.Context, realmID, currency, date string) (*Override, error) {
+	data, err := s.client.HGet(ctx, s.overridesKey(realmID), overrideField(currency, date)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrOverrideNotFound
+		}
+		return nil, fmt.Errorf("failed to get exchange rate override: %w", err)
+	}
+
+	var override Override
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal exchange rate override: %w", err)
+	}
+	return &override, nil
+}
+
+// ListOverrides returns a realm's overrides by currency and date
+func (s *Store) ListOverrides(ctx context.Context, realmID string) ([]Override, error) {
+	values, err := s.client.HGetAll(ctx, s.overridesKey(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exchange rate overrides: %w", err)
+	}
+
+	overrides := make([]Override, 0, len(values))
+	for _, data := range values {
+		var override Override
+		if err := json.Unmarshal([]byte(data), &override); err != nil {
+			continue
+		}
+		overrides = append(overrides, override)
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		if overrides[i].Currency != overrides[j].Currency {
+			return overrides[i].Currency < overrides[j].Currency
+		}
+		return overrides[i].Date < overrides[j].Date
+	})
+	return overrides, nil
+}
+
+// DeleteOverride removes the override for a currency on a date
+func (s *Store) DeleteOverride(ctx context.Context, realmID, currency, date string) error {
+	removed, err := s.client.HDel(ctx, s.overridesKey(realmID), overrideField(currency, date)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete exchange rate override: %w", err)
+	}
+	if removed == 0 {
+		return ErrOverrideNotFound
+	}
+	return nil
+}
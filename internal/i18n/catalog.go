@@ -0,0 +1,57 @@
+// i18n/catalog.go
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalogs holds each locale's messages by key. Messages are fmt formats
+// whose verbs must match the English message.
+var catalogs = loadCatalogs()
+
+// loadCatalogs reads the embedded message catalogs
+func loadCatalogs() map[string]map[string]string {
+	entries, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: failed to read catalogs: %v", err))
+	}
+
+	loaded := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := catalogFiles.ReadFile(path.Join("catalogs", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: failed to read catalog %s: %v", entry.Name(), err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog %s: %v", entry.Name(), err))
+		}
+		loaded[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+	return loaded
+}
+
+// Translate formats a message in locale, falling back to English and then
+// to the key itself when a translation is missing
+func Translate(locale, key string, args ...interface{}) string {
+	format, ok := catalogs[locale][key]
+	if !ok {
+		if format, ok = catalogs[DefaultLocale][key]; !ok {
+			format = key
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// T formats a message in the context's locale
+func T(ctx context.Context, key string, args ...interface{}) string {
+	return Translate(FromContext(ctx), key, args...)
+}
@@ -0,0 +1,204 @@
+// i18n/display.go
+package i18n
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// amountFields are QuickBooks fields holding money
+var amountFields = map[string]bool{
+	"Amount":       true,
+	"Balance":      true,
+	"TotalAmt":     true,
+	"HomeTotalAmt": true,
+	"HomeBalance":  true,
+	"UnitPrice":    true,
+	"TotalTax":     true,
+	"UnappliedAmt": true,
+	"Deposit":      true,
+}
+
+// dateFields are QuickBooks fields holding calendar dates
+var dateFields = map[string]bool{
+	"TxnDate":  true,
+	"DueDate":  true,
+	"ShipDate": true,
+}
+
+// homeCurrencyTTL is how long a company's home currency is cached
+const homeCurrencyTTL = time.Hour
+
+// PreferencesSource reads the current company's preferences
+type PreferencesSource interface {
+	GetPreferences(ctx context.Context) (*qbclient.Preferences, error)
+}
+
+// cachedCurrency is a company's home currency and when it was read
+type cachedCurrency struct {
+	code    string
+	fetched time.Time
+}
+
+// Display adds locale-formatted "display" objects to JSON responses. Every
+// object with amount or date fields gets a sibling "display" object holding
+// the same fields formatted for the request locale, leaving raw values
+// untouched. Amounts use the object's CurrencyRef, else the company's home
+// currency.
+type Display struct {
+	prefs PreferencesSource
+
+	currencies *lru.Cache[string, cachedCurrency] // by realm
+}
+
+// NewDisplay creates a new display formatter
+func NewDisplay(prefs PreferencesSource) *Display {
+	return &Display{
+		prefs:      prefs,
+		currencies: lru.New[string, cachedCurrency]("i18n.home_currencies", 10000),
+	}
+}
+
+// homeCurrency returns the current company's home currency, defaulting to USD
+func (d *Display) homeCurrency(ctx context.Context) string {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return "USD"
+	}
+
+	cached, ok := d.currencies.Get(realmID)
+	if ok && time.Since(cached.fetched) < homeCurrencyTTL {
+		return cached.code
+	}
+
+	code := "USD"
+	if prefs, err := d.prefs.GetPreferences(ctx); err == nil && prefs.CurrencyPrefs.HomeCurrency.Value != "" {
+		code = prefs.CurrencyPrefs.HomeCurrency.Value
+	}
+	d.currencies.Add(realmID, cachedCurrency{code: code, fetched: time.Now()})
+	return code
+}
+
+// Middleware adds display fields to successful JSON responses of requests
+// that ask for them with ?display=true. Other responses pass through
+// unbuffered, so streams are unaffected.
+func (d *Display) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enabled, _ := strconv.ParseBool(r.URL.Query().Get("display")); !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		body := recorder.body.Bytes()
+		if recorder.status == http.StatusOK && strings.HasPrefix(recorder.header.Get("Content-Type"), "application/json") {
+			if formatted, ok := d.format(r.Context(), body); ok {
+				body = formatted
+			}
+		}
+
+		for key, values := range recorder.header {
+			w.Header()[key] = values
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(recorder.status)
+		w.Write(body)
+	})
+}
+
+// format decodes a JSON body and adds display objects throughout
+func (d *Display) format(ctx context.Context, body []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	locale := FromContext(ctx)
+	var home string
+	currency := func() string {
+		if home == "" {
+			home = d.homeCurrency(ctx)
+		}
+		return home
+	}
+	d.walk(value, locale, currency)
+
+	formatted, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return append(formatted, '\n'), true
+}
+
+// walk adds display objects to every object within value. currency returns
+// the currency of the enclosing object, so invoice lines use the invoice's.
+func (d *Display) walk(value interface{}, locale string, currency func() string) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			d.walk(element, locale, currency)
+		}
+	case map[string]interface{}:
+		if ref, ok := v["CurrencyRef"].(map[string]interface{}); ok {
+			if code, ok := ref["value"].(string); ok && code != "" {
+				currency = func() string { return code }
+			}
+		}
+
+		display := make(map[string]interface{})
+		for key, field := range v {
+			switch f := field.(type) {
+			case json.Number:
+				if amount, err := f.Float64(); err == nil && amountFields[key] {
+					display[key] = FormatAmount(locale, amount, currency())
+				}
+			case string:
+				if date, err := time.Parse("2006-01-02", f); err == nil && dateFields[key] {
+					display[key] = FormatDate(locale, date)
+				}
+			default:
+				d.walk(field, locale, currency)
+			}
+		}
+		if len(display) > 0 {
+			if _, taken := v["display"]; !taken {
+				v["display"] = display
+			}
+		}
+	}
+}
+
+// bufferedResponse captures a handler's response for rewriting
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the captured headers
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the status code
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// Write captures the body
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
@@ -0,0 +1,129 @@
+// i18n/format.go
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// numberFormat describes how a locale writes numbers and money
+type numberFormat struct {
+	group        string
+	decimal      string
+	symbolSuffix bool // "1 234,56 €" rather than "€1,234.56"
+}
+
+// numberFormats by locale; French separates groups with narrow no-break spaces
+var numberFormats = map[string]numberFormat{
+	English: {group: ",", decimal: "."},
+	Spanish: {group: ".", decimal: ",", symbolSuffix: true},
+	French:  {group: "\u202f", decimal: ",", symbolSuffix: true},
+}
+
+// currencySymbols are the symbols of common QuickBooks currencies; other
+// currencies are written with their ISO code
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"INR": "₹",
+	"CAD": "CA$",
+	"AUD": "A$",
+	"NZD": "NZ$",
+	"MXN": "MX$",
+}
+
+// zeroDecimalCurrencies have no minor unit
+var zeroDecimalCurrencies = map[string]bool{
+	"JPY": true,
+	"KRW": true,
+}
+
+// monthNames by locale
+var monthNames = map[string][12]string{
+	Spanish: {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+	French:  {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+}
+
+// FormatNumber writes n with the locale's separators and places decimals
+func FormatNumber(locale string, n float64, places int) string {
+	format, ok := numberFormats[locale]
+	if !ok {
+		format = numberFormats[DefaultLocale]
+	}
+
+	s := fmt.Sprintf("%.*f", places, math.Abs(n))
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(format.group)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	out := grouped.String()
+	if fraction != "" {
+		out += format.decimal + fraction
+	}
+	if n < 0 && strings.Trim(s, "0.") != "" {
+		out = "-" + out
+	}
+	return out
+}
+
+// FormatAmount writes an amount of currency (an ISO code) for the locale
+func FormatAmount(locale string, amount float64, currency string) string {
+	format, ok := numberFormats[locale]
+	if !ok {
+		format = numberFormats[DefaultLocale]
+	}
+	currency = strings.ToUpper(currency)
+
+	places := 2
+	if zeroDecimalCurrencies[currency] {
+		places = 0
+	}
+	number := FormatNumber(locale, math.Abs(amount), places)
+
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+
+	var out string
+	switch {
+	case format.symbolSuffix:
+		out = number + "\u00a0" + symbol
+	case len(symbol) == 3 && symbol == currency:
+		out = symbol + "\u00a0" + number
+	default:
+		out = symbol + number
+	}
+	if amount < 0 && number != FormatNumber(locale, 0, places) {
+		out = "-" + out
+	}
+	return out
+}
+
+// FormatDate writes a calendar date in the locale's long form
+func FormatDate(locale string, date time.Time) string {
+	switch locale {
+	case Spanish:
+		return fmt.Sprintf("%d de %s de %d", date.Day(), monthNames[Spanish][date.Month()-1], date.Year())
+	case French:
+		day := fmt.Sprint(date.Day())
+		if date.Day() == 1 {
+			day = "1er"
+		}
+		return fmt.Sprintf("%s %s %d", day, monthNames[French][date.Month()-1], date.Year())
+	}
+	return date.Format("January 2, 2006")
+}
@@ -0,0 +1,105 @@
+// i18n/locale.go
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported locales
+const (
+	English = "en"
+	Spanish = "es"
+	French  = "fr"
+)
+
+// DefaultLocale is used when a request names no supported locale
+const DefaultLocale = English
+
+// supported lists the locales with message catalogs and formatting rules
+var supported = map[string]bool{
+	English: true,
+	Spanish: true,
+	French:  true,
+}
+
+// contextKey carries the request locale
+type contextKey struct{}
+
+// WithLocale returns a context carrying a locale
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// FromContext returns the context's locale, or the default locale
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(contextKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// Normalize maps a language tag such as "fr-CA" to a supported locale, or
+// returns "" when the language is not supported
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if supported[tag] {
+		return tag
+	}
+	return ""
+}
+
+// negotiate picks the first supported locale from ?locale=, the X-Locale
+// header, then Accept-Language by preference
+func negotiate(r *http.Request) string {
+	for _, explicit := range []string{r.URL.Query().Get("locale"), r.Header.Get("X-Locale")} {
+		if locale := Normalize(explicit); locale != "" {
+			return locale
+		}
+	}
+
+	type candidate struct {
+		locale string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		locale := Normalize(fields[0])
+		if locale == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value := strings.TrimPrefix(strings.TrimSpace(param), "q="); value != strings.TrimSpace(param) {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{locale: locale, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) > 0 {
+		return candidates[0].locale
+	}
+	return DefaultLocale
+}
+
+// Middleware stores the negotiated locale in the request context and
+// reports it in Content-Language
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := negotiate(r)
+		w.Header().Set("Content-Language", locale)
+		next.ServeHTTP(w, r.WithContext(WithLocale(r.Context(), locale)))
+	})
+}
@@ -0,0 +1,270 @@
+// insights/anomaly.go
+package insights
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// AnalyzerConfig holds anomaly detection thresholds
+type AnalyzerConfig struct {
+	Lookback           time.Duration // transactions considered per scan
+	DuplicateWindow    time.Duration // max date gap between duplicate-looking invoices
+	SpikeWeeks         int           // weeks of history for the credit memo baseline
+	SpikeStdDevs       float64
+	SpikeMinCount      int
+	UnmatchedMinAmount float64
+}
+
+// DefaultAnalyzerConfig returns sensible detection thresholds
+func DefaultAnalyzerConfig() AnalyzerConfig {
+	return AnalyzerConfig{
+		Lookback:           90 * 24 * time.Hour,
+		DuplicateWindow:    7 * 24 * time.Hour,
+		SpikeWeeks:         12,
+		SpikeStdDevs:       3,
+		SpikeMinCount:      3,
+		UnmatchedMinAmount: 0.01,
+	}
+}
+
+// Analyzer detects unusual transaction patterns in a realm
+type Analyzer struct {
+	querier Querier
+	config  AnalyzerConfig
+}
+
+// NewAnalyzer creates a new anomaly analyzer
+func NewAnalyzer(querier Querier, config AnalyzerConfig) *Analyzer {
+	return &Analyzer{
+		querier: querier,
+		config:  config,
+	}
+}
+
+// ref is a QuickBooks entity reference
+type ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+// anomalyInvoice is the subset of invoice fields the duplicate check uses
+type anomalyInvoice struct {
+	ID          string  `json:"Id"`
+	DocNumber   string  `json:"DocNumber"`
+	TxnDate     string  `json:"TxnDate"`
+	TotalAmt    float64 `json:"TotalAmt"`
+	CustomerRef ref     `json:"CustomerRef"`
+}
+
+// anomalyCreditMemo is the credit memo field the spike check uses
+type anomalyCreditMemo struct {
+	TxnDate string `json:"TxnDate"`
+}
+
+// anomalyPayment is the subset of payment fields the unmatched check uses
+type anomalyPayment struct {
+	ID           string  `json:"Id"`
+	TxnDate      string  `json:"TxnDate"`
+	TotalAmt     float64 `json:"TotalAmt"`
+	UnappliedAmt float64 `json:"UnappliedAmt"`
+	CustomerRef  ref     `json:"CustomerRef"`
+	Line         []struct {
+		LinkedTxn []struct {
+			TxnType string `json:"TxnType"`
+		} `json:"LinkedTxn"`
+	} `json:"Line"`
+}
+
+// Analyze runs every check and returns the combined findings
+func (a *Analyzer) Analyze(ctx context.Context, realmID string) ([]Finding, error) {
+	var findings []Finding
+
+	for _, check := range []func(context.Context, string) ([]Finding, error){
+		a.duplicateInvoices,
+		a.creditMemoSpike,
+		a.unmatchedPayments,
+	} {
+		result, err := check(ctx, realmID)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, result...)
+	}
+
+	return findings, nil
+}
+
+// duplicateInvoices flags invoices to the same customer for the same amount
+// within a few days of each other
+func (a *Analyzer) duplicateInvoices(ctx context.Context, realmID string) ([]Finding, error) {
+	since := timezone.Now(ctx).Add(-a.config.Lookback).Format("2006-01-02")
+	invoices, err := query.All[anomalyInvoice](ctx, a.querier, query.Select("Invoice").
+		Where("TxnDate", ">=", since).
+		OrderBy("TxnDate"))
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		customer string
+		cents    int64
+	}
+	groups := map[key][]int{}
+	for i, inv := range invoices {
+		k := key{inv.CustomerRef.Value, int64(math.Round(inv.TotalAmt * 100))}
+		groups[k] = append(groups[k], i)
+	}
+
+	var findings []Finding
+	for k, indexes := range groups {
+		if len(indexes) < 2 || k.cents == 0 {
+			continue
+		}
+		for n := 1; n < len(indexes); n++ {
+			prev, cur := invoices[indexes[n-1]], invoices[indexes[n]]
+			prevDate, err1 := time.Parse("2006-01-02", prev.TxnDate)
+			curDate, err2 := time.Parse("2006-01-02", cur.TxnDate)
+			if err1 != nil || err2 != nil || curDate.Sub(prevDate) > a.config.DuplicateWindow {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				ID:         findingID(KindDuplicateInvoice, prev.ID, cur.ID),
+				RealmID:    realmID,
+				Kind:       KindDuplicateInvoice,
+				Severity:   SeverityWarning,
+				Summary:    fmt.Sprintf("Invoices %s and %s to %s both total %.2f and are dated %s and %s", prev.DocNumber, cur.DocNumber, cur.CustomerRef.Name, cur.TotalAmt, prev.TxnDate, cur.TxnDate),
+				EntityType: "Invoice",
+				EntityIDs:  []string{prev.ID, cur.ID},
+				DetectedAt: time.Now(),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// creditMemoSpike flags a week with far more credit memos than usual
+func (a *Analyzer) creditMemoSpike(ctx context.Context, realmID string) ([]Finding, error) {
+	now := timezone.Today(ctx)
+	since := now.AddDate(0, 0, -7*(a.config.SpikeWeeks+1)).Format("2006-01-02")
+	memos, err := query.All[anomalyCreditMemo](ctx, a.querier, query.Select("CreditMemo", "TxnDate").
+		Where("TxnDate", ">=", since))
+	if err != nil {
+		return nil, err
+	}
+
+	// Bucket 0 is the most recent week
+	counts := make([]float64, a.config.SpikeWeeks+1)
+	for _, memo := range memos {
+		date, err := time.Parse("2006-01-02", memo.TxnDate)
+		if err != nil {
+			continue
+		}
+		week := int(now.Sub(date).Hours() / (24 * 7))
+		if week >= 0 && week < len(counts) {
+			counts[week]++
+		}
+	}
+
+	current := counts[0]
+	mean, stddev := meanStdDev(counts[1:])
+	threshold := mean + a.config.SpikeStdDevs*math.Max(stddev, 1)
+	if current < float64(a.config.SpikeMinCount) || current <= threshold {
+		return nil, nil
+	}
+
+	weekStart := now.AddDate(0, 0, -7).Format("2006-01-02")
+	return []Finding{{
+		ID:       findingID(KindCreditMemoSpike, realmID, weekStart),
+		RealmID:  realmID,
+		Kind:     KindCreditMemoSpike,
+		Severity: SeverityHigh,
+		Summary:  fmt.Sprintf("%d credit memos in the last 7 days against a weekly average of %.1f", int(current), mean),
+		Details: map[string]interface{}{
+			"current_week": current,
+			"weekly_mean":  mean,
+			"weekly_std":   stddev,
+		},
+		DetectedAt: now,
+	}}, nil
+}
+
+// unmatchedPayments flags payments that are not applied to any invoice
+func (a *Analyzer) unmatchedPayments(ctx context.Context, realmID string) ([]Finding, error) {
+	since := timezone.Now(ctx).Add(-a.config.Lookback).Format("2006-01-02")
+	payments, err := query.All[anomalyPayment](ctx, a.querier, query.Select("Payment").
+		Where("TxnDate", ">=", since))
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, p := range payments {
+		linked := false
+		for _, line := range p.Line {
+			for _, txn := range line.LinkedTxn {
+				if txn.TxnType == "Invoice" {
+					linked = true
+				}
+			}
+		}
+		if linked && p.UnappliedAmt < a.config.UnmatchedMinAmount {
+			continue
+		}
+
+		summary := fmt.Sprintf("Payment of %.2f from %s on %s is not applied to any invoice", p.TotalAmt, p.CustomerRef.Name, p.TxnDate)
+		if linked {
+			summary = fmt.Sprintf("Payment from %s on %s has %.2f unapplied", p.CustomerRef.Name, p.TxnDate, p.UnappliedAmt)
+		}
+
+		findings = append(findings, Finding{
+			ID:         findingID(KindUnmatchedPayment, p.ID),
+			RealmID:    realmID,
+			Kind:       KindUnmatchedPayment,
+			Severity:   SeverityInfo,
+			Summary:    summary,
+			EntityType: "Payment",
+			EntityIDs:  []string{p.ID},
+			DetectedAt: time.Now(),
+		})
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Summary < findings[j].Summary
+	})
+
+	return findings, nil
+}
+
+// meanStdDev returns the mean and population standard deviation
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,299 @@
+// insights/audit.go
+package insights
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Reasons an audit item is flagged
+const (
+	AuditBenfordDigit     = "benford_digit"
+	AuditRoundAmount      = "repeated_round_amount"
+	AuditVendorRecurrence = "vendor_amount_date_recurrence"
+)
+
+// Screening thresholds
+const (
+	benfordMinAmount        = 10.0
+	benfordMinSample        = 100
+	benfordDigitDeviation   = 0.04 // excess share over the expected frequency
+	benfordDigitMinObserved = 10
+	roundAmountMinimum      = 100.0
+	roundAmountMinRepeats   = 3
+)
+
+// DigitFrequency compares observed and expected first-digit frequencies
+type DigitFrequency struct {
+	Digit    int     `json:"digit"`
+	Count    int     `json:"count"`
+	Observed float64 `json:"observed"`
+	Expected float64 `json:"expected"`
+}
+
+// BenfordResult summarizes how closely amounts follow Benford's law
+type BenfordResult struct {
+	SampleSize  int              `json:"sample_size"`
+	Digits      []DigitFrequency `json:"digits"`
+	MAD         float64          `json:"mean_absolute_deviation"`
+	Conformity  string           `json:"conformity"`
+	Significant bool             `json:"significant"` // enough samples to draw conclusions
+}
+
+// AuditItem is a transaction flagged for review
+type AuditItem struct {
+	Reason     string  `json:"reason"`
+	EntityType string  `json:"entity_type"`
+	EntityID   string  `json:"entity_id"`
+	DocNumber  string  `json:"doc_number,omitempty"`
+	TxnDate    string  `json:"txn_date"`
+	Party      string  `json:"party,omitempty"`
+	Amount     float64 `json:"amount"`
+	Detail     string  `json:"detail"`
+}
+
+// AuditReport is the result of a fraud screening run over a period
+type AuditReport struct {
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	Transactions int           `json:"transactions"`
+	Benford      BenfordResult `json:"benford"`
+	Flagged      []AuditItem   `json:"flagged"`
+}
+
+// auditTxn is the subset of transaction fields used for fraud screening
+type auditTxn struct {
+	ID          string  `json:"Id"`
+	DocNumber   string  `json:"DocNumber"`
+	TxnDate     string  `json:"TxnDate"`
+	TotalAmt    float64 `json:"TotalAmt"`
+	VendorRef   ref     `json:"VendorRef"`
+	EntityRef   ref     `json:"EntityRef"`
+	CustomerRef ref     `json:"CustomerRef"`
+
+	entityType string
+}
+
+// party returns the vendor, payee or customer the transaction is with
+func (t auditTxn) party() ref {
+	switch {
+	case t.VendorRef.Value != "":
+		return t.VendorRef
+	case t.EntityRef.Value != "":
+		return t.EntityRef
+	default:
+		return t.CustomerRef
+	}
+}
+
+// auditEntities are the transaction types screened by the audit report
+var auditEntities = []string{"Bill", "Purchase", "Invoice"}
+
+// Auditor runs statistical fraud screening over a period's transactions
+type Auditor struct {
+	querier Querier
+}
+
+// NewAuditor creates a new fraud screening auditor
+func NewAuditor(querier Querier) *Auditor {
+	return &Auditor{
+		querier: querier,
+	}
+}
+
+// Audit screens transactions dated between from and to inclusive
+func (a *Auditor) Audit(ctx context.Context, from, to time.Time) (*AuditReport, error) {
+	var txns []auditTxn
+	for _, entity := range auditEntities {
+		result, err := query.All[auditTxn](ctx, a.querier, query.Select(entity).
+			Where("TxnDate", ">=", from.Format("2006-01-02")).
+			Where("TxnDate", "<=", to.Format("2006-01-02")))
+		if err != nil {
+			return nil, err
+		}
+		for i := range result {
+			result[i].entityType = entity
+		}
+		txns = append(txns, result...)
+	}
+
+	report := &AuditReport{
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Transactions: len(txns),
+		Flagged:      []AuditItem{},
+	}
+
+	var benfordFlags []AuditItem
+	report.Benford, benfordFlags = benford(txns)
+	report.Flagged = append(report.Flagged, benfordFlags...)
+	report.Flagged = append(report.Flagged, repeatedRoundAmounts(txns)...)
+	report.Flagged = append(report.Flagged, vendorRecurrences(txns)...)
+
+	return report, nil
+}
+
+// newAuditItem flags a transaction for a reason
+func newAuditItem(txn auditTxn, reason, detail string) AuditItem {
+	return AuditItem{
+		Reason:     reason,
+		EntityType: txn.entityType,
+		EntityID:   txn.ID,
+		DocNumber:  txn.DocNumber,
+		TxnDate:    txn.TxnDate,
+		Party:      txn.party().Name,
+		Amount:     txn.TotalAmt,
+		Detail:     detail,
+	}
+}
+
+// firstDigit returns the leading non-zero digit of an amount
+func firstDigit(amount float64) int {
+	amount = math.Abs(amount)
+	for amount >= 10 {
+		amount /= 10
+	}
+	for amount > 0 && amount < 1 {
+		amount *= 10
+	}
+	return int(amount)
+}
+
+// benford compares first-digit frequencies against Benford's law and flags
+// transactions whose leading digit is markedly over-represented
+func benford(txns []auditTxn) (BenfordResult, []AuditItem) {
+	var counts [10]int
+	var sample []auditTxn
+	for _, txn := range txns {
+		// Small amounts are dominated by pricing conventions rather than natural spread
+		if math.Abs(txn.TotalAmt) < benfordMinAmount {
+			continue
+		}
+		counts[firstDigit(txn.TotalAmt)]++
+		sample = append(sample, txn)
+	}
+
+	result := BenfordResult{
+		SampleSize:  len(sample),
+		Significant: len(sample) >= benfordMinSample,
+	}
+	if len(sample) == 0 {
+		result.Conformity = "insufficient_data"
+		return result, nil
+	}
+
+	overRepresented := map[int]bool{}
+	total := float64(len(sample))
+	deviation := 0.0
+	for d := 1; d <= 9; d++ {
+		expected := math.Log10(1 + 1/float64(d))
+		observed := float64(counts[d]) / total
+		deviation += math.Abs(observed - expected)
+		result.Digits = append(result.Digits, DigitFrequency{
+			Digit:    d,
+			Count:    counts[d],
+			Observed: math.Round(observed*10000) / 10000,
+			Expected: math.Round(expected*10000) / 10000,
+		})
+		if observed-expected > benfordDigitDeviation && counts[d] >= benfordDigitMinObserved {
+			overRepresented[d] = true
+		}
+	}
+	result.MAD = math.Round(deviation/9*100000) / 100000
+
+	// Nigrini's first-digit conformity thresholds
+	switch {
+	case !result.Significant:
+		result.Conformity = "insufficient_data"
+	case result.MAD < 0.006:
+		result.Conformity = "close"
+	case result.MAD < 0.012:
+		result.Conformity = "acceptable"
+	case result.MAD < 0.015:
+		result.Conformity = "marginal"
+	default:
+		result.Conformity = "nonconforming"
+	}
+
+	if !result.Significant || result.Conformity == "close" || result.Conformity == "acceptable" {
+		return result, nil
+	}
+
+	var flagged []AuditItem
+	for _, txn := range sample {
+		d := firstDigit(txn.TotalAmt)
+		if overRepresented[d] {
+			flagged = append(flagged, newAuditItem(txn, AuditBenfordDigit,
+				"Leading digit "+strconv.Itoa(d)+" appears far more often than Benford's law predicts"))
+		}
+	}
+	return result, flagged
+}
+
+// repeatedRoundAmounts flags round amounts paid to or billed by the same
+// party several times in the period
+func repeatedRoundAmounts(txns []auditTxn) []AuditItem {
+	groups := map[string][]auditTxn{}
+	var keys []string
+	for _, txn := range txns {
+		if txn.TotalAmt < roundAmountMinimum || math.Mod(txn.TotalAmt, 100) != 0 {
+			continue
+		}
+		key := txn.party().Value + "|" + strconv.FormatFloat(txn.TotalAmt, 'f', 2, 64)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], txn)
+	}
+	sort.Strings(keys)
+
+	var flagged []AuditItem
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < roundAmountMinRepeats {
+			continue
+		}
+		for _, txn := range group {
+			flagged = append(flagged, newAuditItem(txn, AuditRoundAmount,
+				fmt.Sprintf("Round amount %.2f recorded %d times for the same party", txn.TotalAmt, len(group))))
+		}
+	}
+	return flagged
+}
+
+// vendorRecurrences flags bills and expenses to the same vendor for the same
+// amount on the same date
+func vendorRecurrences(txns []auditTxn) []AuditItem {
+	groups := map[string][]auditTxn{}
+	var keys []string
+	for _, txn := range txns {
+		if txn.entityType == "Invoice" || txn.party().Value == "" {
+			continue
+		}
+		key := txn.party().Value + "|" + txn.TxnDate + "|" + strconv.FormatFloat(txn.TotalAmt, 'f', 2, 64)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], txn)
+	}
+	sort.Strings(keys)
+
+	var flagged []AuditItem
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		for _, txn := range group {
+			flagged = append(flagged, newAuditItem(txn, AuditVendorRecurrence,
+				fmt.Sprintf("%d transactions to the same vendor for %.2f on %s", len(group), txn.TotalAmt, txn.TxnDate)))
+		}
+	}
+	return flagged
+}
@@ -0,0 +1,146 @@
+// insights/behavior.go
+package insights
+
+import (
+	"context"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// CustomerBehavior summarizes how a customer has paid past invoices
+type CustomerBehavior struct {
+	CustomerID     string  `json:"customer_id"`
+	Name           string  `json:"name"`
+	PaidInvoices   int     `json:"paid_invoices"`
+	OpenInvoices   int     `json:"open_invoices"`
+	AvgDaysToPay   float64 `json:"avg_days_to_pay"`
+	AvgDaysLate    float64 `json:"avg_days_late"`
+	OnTimeRate     float64 `json:"on_time_rate"` // 0-1
+	TotalInvoiced  float64 `json:"total_invoiced"`
+	OpenBalance    float64 `json:"open_balance"`
+	OverdueBalance float64 `json:"overdue_balance"`
+}
+
+// behaviorInvoice is the subset of invoice fields used for payment behavior
+type behaviorInvoice struct {
+	ID          string  `json:"Id"`
+	TxnDate     string  `json:"TxnDate"`
+	DueDate     string  `json:"DueDate"`
+	TotalAmt    float64 `json:"TotalAmt"`
+	Balance     float64 `json:"Balance"`
+	CustomerRef ref     `json:"CustomerRef"`
+}
+
+// behaviorPayment is the subset of payment fields used for payment behavior
+type behaviorPayment struct {
+	TxnDate string `json:"TxnDate"`
+	Line    []struct {
+		LinkedTxn []struct {
+			TxnID   string `json:"TxnId"`
+			TxnType string `json:"TxnType"`
+		} `json:"LinkedTxn"`
+	} `json:"Line"`
+}
+
+// BehaviorAnalyzer computes customer payment behavior from invoice and payment history
+type BehaviorAnalyzer struct {
+	querier Querier
+}
+
+// NewBehaviorAnalyzer creates a new payment behavior analyzer
+func NewBehaviorAnalyzer(querier Querier) *BehaviorAnalyzer {
+	return &BehaviorAnalyzer{
+		querier: querier,
+	}
+}
+
+// Compute returns payment behavior per customer ID for invoices dated on or
+// after since; a zero since covers the customer's whole history
+func (b *BehaviorAnalyzer) Compute(ctx context.Context, since time.Time) (map[string]*CustomerBehavior, error) {
+	dated := func(entity string) *query.Builder {
+		q := query.Select(entity)
+		if !since.IsZero() {
+			q.Where("TxnDate", ">=", since.Format("2006-01-02"))
+		}
+		return q
+	}
+
+	invoices, err := query.All[behaviorInvoice](ctx, b.querier, dated("Invoice"))
+	if err != nil {
+		return nil, err
+	}
+	payments, err := query.All[behaviorPayment](ctx, b.querier, dated("Payment"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The latest payment applied to each invoice marks when it was paid off
+	paidOn := map[string]time.Time{}
+	for _, p := range payments {
+		date, err := time.Parse("2006-01-02", p.TxnDate)
+		if err != nil {
+			continue
+		}
+		for _, line := range p.Line {
+			for _, txn := range line.LinkedTxn {
+				if txn.TxnType == "Invoice" && date.After(paidOn[txn.TxnID]) {
+					paidOn[txn.TxnID] = date
+				}
+			}
+		}
+	}
+
+	today := timezone.Today(ctx)
+	behaviors := map[string]*CustomerBehavior{}
+	onTime := map[string]int{}
+	for _, inv := range invoices {
+		behavior, ok := behaviors[inv.CustomerRef.Value]
+		if !ok {
+			behavior = &CustomerBehavior{CustomerID: inv.CustomerRef.Value, Name: inv.CustomerRef.Name}
+			behaviors[inv.CustomerRef.Value] = behavior
+		}
+
+		txnDate, err1 := time.Parse("2006-01-02", inv.TxnDate)
+		dueDate, err2 := time.Parse("2006-01-02", inv.DueDate)
+		if err2 != nil {
+			dueDate = txnDate
+		}
+
+		behavior.TotalInvoiced += inv.TotalAmt
+		if inv.Balance > 0 {
+			behavior.OpenInvoices++
+			behavior.OpenBalance += inv.Balance
+			if err1 == nil && dueDate.Before(today) {
+				behavior.OverdueBalance += inv.Balance
+			}
+			continue
+		}
+
+		paid, ok := paidOn[inv.ID]
+		if !ok || err1 != nil {
+			continue
+		}
+
+		daysToPay := paid.Sub(txnDate).Hours() / 24
+		daysLate := paid.Sub(dueDate).Hours() / 24
+
+		// Running averages
+		n := float64(behavior.PaidInvoices)
+		behavior.AvgDaysToPay = (behavior.AvgDaysToPay*n + daysToPay) / (n + 1)
+		behavior.AvgDaysLate = (behavior.AvgDaysLate*n + daysLate) / (n + 1)
+		behavior.PaidInvoices++
+		if daysLate <= 0 {
+			onTime[inv.CustomerRef.Value]++
+		}
+	}
+
+	for id, behavior := range behaviors {
+		if behavior.PaidInvoices > 0 {
+			behavior.OnTimeRate = float64(onTime[id]) / float64(behavior.PaidInvoices)
+		}
+	}
+
+	return behaviors, nil
+}
@@ -0,0 +1,175 @@
+// insights/cashflow.go
+package insights
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// defaultCollectionRate is assumed for customers with no payment history
+const defaultCollectionRate = 0.9
+
+// ForecastBucket is the expected cash movement in a window of days from today
+type ForecastBucket struct {
+	Label            string  `json:"label"`
+	Start            string  `json:"start"`
+	End              string  `json:"end"`
+	ExpectedInflows  float64 `json:"expected_inflows"`
+	ExpectedOutflows float64 `json:"expected_outflows"`
+	Net              float64 `json:"net"`
+	InvoiceCount     int     `json:"invoice_count"`
+	BillCount        int     `json:"bill_count"`
+}
+
+// CashflowForecast projects inflows and outflows over the coming days
+type CashflowForecast struct {
+	AsOf     string           `json:"as_of"`
+	Buckets  []ForecastBucket `json:"buckets"`
+	TotalIn  float64          `json:"total_inflows"`
+	TotalOut float64          `json:"total_outflows"`
+	Net      float64          `json:"net"`
+	OpenAR   float64          `json:"open_receivables"`
+	OpenAP   float64          `json:"open_payables"`
+}
+
+// openBill is the subset of bill fields used for forecasting
+type openBill struct {
+	DueDate string  `json:"DueDate"`
+	TxnDate string  `json:"TxnDate"`
+	Balance float64 `json:"Balance"`
+}
+
+// Forecaster projects cash flow from open invoices and bills
+type Forecaster struct {
+	querier  Querier
+	behavior *BehaviorAnalyzer
+}
+
+// NewForecaster creates a new cash flow forecaster
+func NewForecaster(querier Querier, behavior *BehaviorAnalyzer) *Forecaster {
+	return &Forecaster{
+		querier:  querier,
+		behavior: behavior,
+	}
+}
+
+// Forecast buckets expected cash movement into 30-day windows up to horizonDays
+func (f *Forecaster) Forecast(ctx context.Context, horizonDays int) (*CashflowForecast, error) {
+	today := timezone.Today(ctx)
+
+	behaviors, err := f.behavior.Compute(ctx, today.AddDate(-1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	invoices, err := query.All[behaviorInvoice](ctx, f.querier, query.Select("Invoice").Where("Balance", ">", "0"))
+	if err != nil {
+		return nil, err
+	}
+	bills, err := query.All[openBill](ctx, f.querier, query.Select("Bill").Where("Balance", ">", "0"))
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := &CashflowForecast{AsOf: today.Format("2006-01-02")}
+	for start := 0; start < horizonDays; start += 30 {
+		end := start + 30
+		if end > horizonDays {
+			end = horizonDays
+		}
+		forecast.Buckets = append(forecast.Buckets, ForecastBucket{
+			Label: bucketLabel(start, end),
+			Start: today.AddDate(0, 0, start).Format("2006-01-02"),
+			End:   today.AddDate(0, 0, end-1).Format("2006-01-02"),
+		})
+	}
+
+	// bucketFor returns the bucket for an expected date; past-due amounts land in the first
+	bucketFor := func(expected time.Time) *ForecastBucket {
+		days := int(expected.Sub(today).Hours() / 24)
+		if days < 0 {
+			days = 0
+		}
+		index := days / 30
+		if index >= len(forecast.Buckets) {
+			return nil
+		}
+		return &forecast.Buckets[index]
+	}
+
+	for _, inv := range invoices {
+		forecast.OpenAR += inv.Balance
+
+		due, err := time.Parse("2006-01-02", inv.DueDate)
+		if err != nil {
+			due = today
+		}
+
+		// Shift the due date by the customer's typical lateness and weight by reliability
+		rate := defaultCollectionRate
+		if b, ok := behaviors[inv.CustomerRef.Value]; ok && b.PaidInvoices > 0 {
+			due = due.AddDate(0, 0, int(math.Round(math.Max(b.AvgDaysLate, 0))))
+			rate = collectionRate(b)
+		}
+
+		if bucket := bucketFor(due); bucket != nil {
+			bucket.ExpectedInflows += inv.Balance * rate
+			bucket.InvoiceCount++
+		}
+	}
+
+	for _, bill := range bills {
+		forecast.OpenAP += bill.Balance
+
+		due, err := time.Parse("2006-01-02", bill.DueDate)
+		if err != nil {
+			due = today
+		}
+
+		if bucket := bucketFor(due); bucket != nil {
+			bucket.ExpectedOutflows += bill.Balance
+			bucket.BillCount++
+		}
+	}
+
+	for i := range forecast.Buckets {
+		b := &forecast.Buckets[i]
+		b.ExpectedInflows = roundCents(b.ExpectedInflows)
+		b.ExpectedOutflows = roundCents(b.ExpectedOutflows)
+		b.Net = roundCents(b.ExpectedInflows - b.ExpectedOutflows)
+		forecast.TotalIn += b.ExpectedInflows
+		forecast.TotalOut += b.ExpectedOutflows
+	}
+	forecast.TotalIn = roundCents(forecast.TotalIn)
+	forecast.TotalOut = roundCents(forecast.TotalOut)
+	forecast.Net = roundCents(forecast.TotalIn - forecast.TotalOut)
+	forecast.OpenAR = roundCents(forecast.OpenAR)
+	forecast.OpenAP = roundCents(forecast.OpenAP)
+
+	return forecast, nil
+}
+
+// collectionRate estimates the share of an open balance that will be collected
+// in the forecast window, discounting customers who often pay late
+func collectionRate(b *CustomerBehavior) float64 {
+	rate := 0.6 + 0.4*b.OnTimeRate
+	if b.AvgDaysLate > 60 {
+		rate *= 0.5
+	}
+	return rate
+}
+
+// bucketLabel describes a window of days
+func bucketLabel(start, end int) string {
+	return strconv.Itoa(start) + "-" + strconv.Itoa(end) + " days"
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
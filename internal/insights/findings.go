@@ -0,0 +1,113 @@
+// insights/findings.go
+package insights
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Finding kinds
+const (
+	KindDuplicateInvoice = "duplicate_invoice"
+	KindCreditMemoSpike  = "credit_memo_spike"
+	KindUnmatchedPayment = "unmatched_payment"
+)
+
+// Severity levels
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityHigh    = "high"
+)
+
+// Finding is an unusual pattern detected in a realm's transactions
+type Finding struct {
+	ID         string                 `json:"id"`
+	RealmID    string                 `json:"realm_id"`
+	Kind       string                 `json:"kind"`
+	Severity   string                 `json:"severity"`
+	Summary    string                 `json:"summary"`
+	EntityType string                 `json:"entity_type,omitempty"`
+	EntityIDs  []string               `json:"entity_ids,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	DetectedAt time.Time              `json:"detected_at"`
+}
+
+// findingID derives a stable ID so repeated scans recognise the same finding
+func findingID(kind string, parts ...string) string {
+	sum := sha1.Sum([]byte(kind + ":" + strings.Join(parts, ",")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Store persists the latest findings per realm
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new findings store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// findingsKey holds the realm's findings from the latest scan
+func (s *Store) findingsKey(realmID string) string {
+	return fmt.Sprintf("%s:insights:findings:%s", s.prefix, realmID)
+}
+
+// seenKey is the set of finding IDs already notified for a realm
+func (s *Store) seenKey(realmID string) string {
+	return fmt.Sprintf("%s:insights:seen:%s", s.prefix, realmID)
+}
+
+// Replace stores a scan's findings and returns the ones not seen before
+func (s *Store) Replace(ctx context.Context, realmID string, findings []Finding) ([]Finding, error) {
+	data, err := json.Marshal(findings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal findings: %w", err)
+	}
+	if err := s.client.Set(ctx, s.findingsKey(realmID), data, 0).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save findings: %w", err)
+	}
+
+	var fresh []Finding
+	for _, f := range findings {
+		added, err := s.client.SAdd(ctx, s.seenKey(realmID), f.ID).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to mark finding seen: %w", err)
+		}
+		if added == 1 {
+			fresh = append(fresh, f)
+		}
+	}
+
+	return fresh, nil
+}
+
+// List returns the realm's findings from the latest scan
+func (s *Store) List(ctx context.Context, realmID string) ([]Finding, error) {
+	data, err := s.client.Get(ctx, s.findingsKey(realmID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return []Finding{}, nil
+		}
+		return nil, fmt.Errorf("failed to get findings: %w", err)
+	}
+
+	var findings []Finding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal findings: %w", err)
+	}
+
+	return findings, nil
+}
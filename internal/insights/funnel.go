@@ -0,0 +1,311 @@
+// insights/funnel.go
+package insights
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/invoicestate"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// funnelBatchSize bounds the IDs looked up per linked transaction query
+const funnelBatchSize = 100
+
+// Quote-to-cash funnel stages
+const (
+	StageEstimated = "estimated"
+	StageInvoiced  = "invoiced"
+	StagePaid      = "paid"
+)
+
+// FunnelStage is how many estimates reached a stage and what they were worth.
+// Rates and drop-off compare the stage to the one before it.
+type FunnelStage struct {
+	Stage          string  `json:"stage"`
+	Count          int     `json:"count"`
+	Value          float64 `json:"value"`
+	ConversionRate float64 `json:"conversion_rate"`       // 0-1, by count
+	ValueRate      float64 `json:"value_conversion_rate"` // 0-1, by value
+	AvgDays        float64 `json:"avg_days_from_previous"`
+	DropOffCount   int     `json:"drop_off_count"`
+	DropOffValue   float64 `json:"drop_off_value"`
+}
+
+// QuoteToCash is the estimate to invoice to payment funnel of the estimates
+// dated in a period. An estimate is invoiced once an invoice links to it and
+// paid once every such invoice is; the paid value is what was collected.
+type QuoteToCash struct {
+	From           string         `json:"from"`
+	To             string         `json:"to"`
+	Stages         []FunnelStage  `json:"stages"`
+	ConversionRate float64        `json:"conversion_rate"`       // estimates paid, 0-1
+	ValueRate      float64        `json:"value_conversion_rate"` // value collected, 0-1
+	AvgDaysToCash  float64        `json:"avg_days_to_cash"`
+	NotInvoiced    map[string]int `json:"not_invoiced"` // by estimate status
+}
+
+// linkedTxn links a transaction to another, e.g. an estimate to its invoice
+type linkedTxn struct {
+	TxnID   string `json:"TxnId"`
+	TxnType string `json:"TxnType"`
+}
+
+// metaData holds QuickBooks record timestamps
+type metaData struct {
+	CreateTime string `json:"CreateTime"`
+}
+
+// funnelTxn is the subset of estimate, invoice and payment fields the
+// funnel follows
+type funnelTxn struct {
+	ID        string      `json:"Id"`
+	TxnDate   string      `json:"TxnDate"`
+	TxnStatus string      `json:"TxnStatus"`
+	TotalAmt  float64     `json:"TotalAmt"`
+	Balance   float64     `json:"Balance"`
+	LinkedTxn []linkedTxn `json:"LinkedTxn"`
+	MetaData  metaData    `json:"MetaData"`
+}
+
+// createdAt returns when the transaction was entered in QuickBooks, or its
+// transaction date when QuickBooks did not say
+func (t *funnelTxn) createdAt(loc *time.Location) (time.Time, bool) {
+	if at, err := time.Parse(time.RFC3339, t.MetaData.CreateTime); err == nil {
+		return at, true
+	}
+	if at, err := time.ParseInLocation("2006-01-02", t.TxnDate, loc); err == nil {
+		return at, true
+	}
+	return time.Time{}, false
+}
+
+// linked returns the IDs of the transactions of a type linked to this one
+func (t *funnelTxn) linked(txnType string) []string {
+	var ids []string
+	for _, txn := range t.LinkedTxn {
+		if txn.TxnType == txnType {
+			ids = append(ids, txn.TxnID)
+		}
+	}
+	return ids
+}
+
+// FunnelAnalyzer follows estimates through to invoices and payments
+type FunnelAnalyzer struct {
+	querier Querier
+	states  *invoicestate.Store
+}
+
+// NewFunnelAnalyzer creates a new quote-to-cash analyzer. Invoices the
+// server moved to paid are timed by that transition, others by their
+// latest payment.
+func NewFunnelAnalyzer(querier Querier, states *invoicestate.Store) *FunnelAnalyzer {
+	return &FunnelAnalyzer{
+		querier: querier,
+		states:  states,
+	}
+}
+
+// Analyze builds the funnel of the estimates dated from from to to
+func (f *FunnelAnalyzer) Analyze(ctx context.Context, realmID string, from, to time.Time) (*QuoteToCash, error) {
+	loc := timezone.FromContext(ctx)
+	estimates, err := query.All[funnelTxn](ctx, f.querier, query.Select("Estimate").
+		Where("TxnDate", ">=", from.Format("2006-01-02")).
+		Where("TxnDate", "<=", to.Format("2006-01-02")))
+	if err != nil {
+		return nil, err
+	}
+
+	var invoiceIDs []string
+	for i := range estimates {
+		invoiceIDs = append(invoiceIDs, estimates[i].linked("Invoice")...)
+	}
+	invoices, err := f.byID(ctx, "Invoice", invoiceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var paymentIDs []string
+	for _, inv := range invoices {
+		paymentIDs = append(paymentIDs, inv.linked("Payment")...)
+	}
+	payments, err := f.byID(ctx, "Payment", paymentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]*invoicestate.State{}
+	if f.states != nil && len(invoices) > 0 {
+		ids := make([]string, 0, len(invoices))
+		for id := range invoices {
+			ids = append(ids, id)
+		}
+		if states, err = f.states.GetMany(ctx, realmID, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	estimated := FunnelStage{Stage: StageEstimated}
+	invoiced := FunnelStage{Stage: StageInvoiced}
+	paid := FunnelStage{Stage: StagePaid}
+	var toInvoice, toPay, toCash []float64
+	notInvoiced := map[string]int{}
+
+	for i := range estimates {
+		est := &estimates[i]
+		estimated.Count++
+		estimated.Value += est.TotalAmt
+
+		var linked []*funnelTxn
+		for _, id := range est.linked("Invoice") {
+			if inv, ok := invoices[id]; ok {
+				linked = append(linked, inv)
+			}
+		}
+		if len(linked) == 0 {
+			status := est.TxnStatus
+			if status == "" {
+				status = "Pending"
+			}
+			notInvoiced[status]++
+			continue
+		}
+
+		// The first invoice converts the estimate; the last payment settles it
+		estimatedAt, estimateOK := est.createdAt(loc)
+		var invoicedAt, paidAt time.Time
+		settled := true
+		collected := 0.0
+		for _, inv := range linked {
+			invoiced.Value += inv.TotalAmt
+			collected += inv.TotalAmt - inv.Balance
+			if at, ok := inv.createdAt(loc); ok && (invoicedAt.IsZero() || at.Before(invoicedAt)) {
+				invoicedAt = at
+			}
+			if inv.Balance > 0 {
+				settled = false
+				continue
+			}
+			if at, ok := paidOn(inv, states[inv.ID], payments, loc); ok && at.After(paidAt) {
+				paidAt = at
+			}
+		}
+		invoiced.Count++
+		paid.Value += collected
+		if estimateOK && !invoicedAt.IsZero() {
+			toInvoice = append(toInvoice, daysBetween(estimatedAt, invoicedAt))
+		}
+		if !settled {
+			continue
+		}
+
+		paid.Count++
+		if !invoicedAt.IsZero() && !paidAt.IsZero() {
+			toPay = append(toPay, daysBetween(invoicedAt, paidAt))
+		}
+		if estimateOK && !paidAt.IsZero() {
+			toCash = append(toCash, daysBetween(estimatedAt, paidAt))
+		}
+	}
+
+	invoiced.AvgDays = mean(toInvoice)
+	paid.AvgDays = mean(toPay)
+	stages := []FunnelStage{estimated, invoiced, paid}
+	for i := range stages {
+		stages[i].Value = roundCents(stages[i].Value)
+		if i == 0 {
+			continue
+		}
+		prev := stages[i-1]
+		stages[i].ConversionRate = ratio(float64(stages[i].Count), float64(prev.Count))
+		stages[i].ValueRate = ratio(stages[i].Value, prev.Value)
+		stages[i].DropOffCount = prev.Count - stages[i].Count
+		stages[i].DropOffValue = roundCents(prev.Value - stages[i].Value)
+	}
+
+	return &QuoteToCash{
+		From:           from.Format("2006-01-02"),
+		To:             to.Format("2006-01-02"),
+		Stages:         stages,
+		ConversionRate: ratio(float64(paid.Count), float64(estimated.Count)),
+		ValueRate:      ratio(stages[2].Value, stages[0].Value),
+		AvgDaysToCash:  mean(toCash),
+		NotInvoiced:    notInvoiced,
+	}, nil
+}
+
+// byID fetches transactions of an entity by ID, keyed by ID
+func (f *FunnelAnalyzer) byID(ctx context.Context, entity string, ids []string) (map[string]*funnelTxn, error) {
+	seen := map[string]bool{}
+	var unique []interface{}
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	txns := make(map[string]*funnelTxn, len(unique))
+	for start := 0; start < len(unique); start += funnelBatchSize {
+		end := min(start+funnelBatchSize, len(unique))
+		batch, err := query.All[funnelTxn](ctx, f.querier, query.Select(entity).WhereIn("Id", unique[start:end]...))
+		if err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			txns[batch[i].ID] = &batch[i]
+		}
+	}
+	return txns, nil
+}
+
+// paidOn returns when a paid invoice was paid: when the server last moved it
+// to paid, or else when its latest payment was entered
+func paidOn(inv *funnelTxn, state *invoicestate.State, payments map[string]*funnelTxn, loc *time.Location) (time.Time, bool) {
+	if state != nil {
+		for i := len(state.History) - 1; i >= 0; i-- {
+			if state.History[i].To == tenantconfig.InvoicePaid {
+				return state.History[i].At, true
+			}
+		}
+	}
+
+	var latest time.Time
+	for _, id := range inv.linked("Payment") {
+		if payment, ok := payments[id]; ok {
+			if at, ok := payment.createdAt(loc); ok && at.After(latest) {
+				latest = at
+			}
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+// daysBetween returns the days between two times, never negative
+func daysBetween(from, to time.Time) float64 {
+	return math.Max(to.Sub(from).Hours()/24, 0)
+}
+
+// mean returns the mean of values rounded to two decimals, 0 if none
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return roundCents(sum / float64(len(values)))
+}
+
+// ratio returns part over whole to four decimals, 0 when whole is 0
+func ratio(part, whole float64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Round(part/whole*10000) / 10000
+}
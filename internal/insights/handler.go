@@ -0,0 +1,267 @@
+// insights/handler.go
+package insights
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/notify"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/gorilla/mux"
+)
+
+// Servicer scans a company's transactions for anomalies. *Service
+// implements it; tests can substitute a fake.
+type Servicer interface {
+	Scan(ctx context.Context, realmID string) ([]Finding, []Finding, error)
+	Findings(ctx context.Context, realmID string) ([]Finding, error)
+	Monitor(ctx context.Context, realmID string, destinations []notify.Destination) (*jobs.Job, error)
+}
+
+// Handler provides HTTP handlers for transaction insights
+type Handler struct {
+	service    Servicer
+	forecaster *Forecaster
+	metrics    *MetricsService
+	auditor    *Auditor
+	funnel     *FunnelAnalyzer
+}
+
+// NewHandler creates a new insights handler
+func NewHandler(service Servicer, forecaster *Forecaster, metrics *MetricsService, auditor *Auditor, funnel *FunnelAnalyzer) *Handler {
+	return &Handler{
+		service:    service,
+		forecaster: forecaster,
+		metrics:    metrics,
+		auditor:    auditor,
+		funnel:     funnel,
+	}
+}
+
+// ListFindings returns the current company's findings, optionally filtered by kind
+func (h *Handler) ListFindings(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	findings, err := h.service.Findings(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to get findings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		filtered := make([]Finding, 0, len(findings))
+		for _, f := range findings {
+			if f.Kind == kind {
+				filtered = append(filtered, f)
+			}
+		}
+		findings = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"findings": findings,
+	})
+}
+
+// RunScan analyzes the current company immediately
+func (h *Handler) RunScan(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	findings, fresh, err := h.service.Scan(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to scan transactions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"findings":  findings,
+		"new_count": len(fresh),
+	})
+}
+
+// Monitor enables daily scans with notifications for the current company
+func (h *Handler) Monitor(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Notifications []notify.Destination `json:"notifications"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.service.Monitor(r.Context(), realmID, req.Notifications)
+	if err != nil {
+		http.Error(w, "Failed to enable monitoring: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+// CashflowForecast projects the current company's cash flow over 30/60/90 days
+func (h *Handler) CashflowForecast(w http.ResponseWriter, r *http.Request) {
+	horizon := 90
+	if v := r.URL.Query().Get("horizon"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 365 {
+			http.Error(w, "horizon must be between 1 and 365 days", http.StatusBadRequest)
+			return
+		}
+		horizon = parsed
+	}
+
+	forecast, err := h.forecaster.Forecast(r.Context(), horizon)
+	if err != nil {
+		http.Error(w, "Failed to forecast cash flow: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(forecast)
+}
+
+// CustomerMetrics returns a customer's payment metrics and risk score
+func (h *Handler) CustomerMetrics(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	metrics, err := h.metrics.CustomerMetrics(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, ErrMetricsNotFound) {
+			http.Error(w, "No invoice history for customer", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get customer metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(metrics)
+}
+
+// RefreshCustomerMetrics recomputes every customer's metrics for the current company
+func (h *Handler) RefreshCustomerMetrics(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.metrics.Refresh(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to refresh customer metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"customers": count,
+	})
+}
+
+// AuditReport screens a period's transactions for signs of fraud or error.
+// The period defaults to the last 90 days in the realm's time zone.
+func (h *Handler) AuditReport(w http.ResponseWriter, r *http.Request) {
+	to := timezone.Today(r.Context())
+	from := to.AddDate(0, 0, -90)
+
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.auditor.Audit(r.Context(), from, to)
+	if err != nil {
+		http.Error(w, "Failed to run audit report: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// QuoteToCash reports how the estimates of a period converted to invoices
+// and payments. The period defaults to the last 365 days in the realm's
+// time zone.
+func (h *Handler) QuoteToCash(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	to := timezone.Today(r.Context())
+	from := to.AddDate(0, 0, -365)
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.funnel.Analyze(r.Context(), realmID, from, to)
+	if err != nil {
+		http.Error(w, "Failed to analyze quote-to-cash funnel: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
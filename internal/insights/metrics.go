@@ -0,0 +1,266 @@
+// insights/metrics.go
+package insights
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+// CustomerMetricsJobType identifies recurring customer metric refreshes in the jobs subsystem
+const CustomerMetricsJobType = "insights_customer_metrics"
+
+// Risk levels derived from a customer's risk score
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// ErrMetricsNotFound is returned when a customer has no computed metrics
+var ErrMetricsNotFound = errors.New("customer metrics not found")
+
+// CustomerMetrics is a customer's payment profile over their whole history
+type CustomerMetrics struct {
+	CustomerID      string    `json:"customer_id"`
+	Name            string    `json:"name"`
+	PaidInvoices    int       `json:"paid_invoices"`
+	OpenInvoices    int       `json:"open_invoices"`
+	AvgDaysToPay    float64   `json:"avg_days_to_pay"`
+	AvgDaysLate     float64   `json:"avg_days_late"`
+	OnTimePercent   float64   `json:"on_time_percent"`
+	LifetimeRevenue float64   `json:"lifetime_revenue"`
+	OpenBalance     float64   `json:"open_balance"`
+	OverdueBalance  float64   `json:"overdue_balance"`
+	RiskScore       int       `json:"risk_score"` // 0 (safe) - 100 (risky)
+	RiskLevel       string    `json:"risk_level"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// newCustomerMetrics scores a customer's lifetime payment behavior
+func newCustomerMetrics(b *CustomerBehavior, now time.Time) CustomerMetrics {
+	score := riskScore(b)
+	return CustomerMetrics{
+		CustomerID:      b.CustomerID,
+		Name:            b.Name,
+		PaidInvoices:    b.PaidInvoices,
+		OpenInvoices:    b.OpenInvoices,
+		AvgDaysToPay:    math.Round(b.AvgDaysToPay*10) / 10,
+		AvgDaysLate:     math.Round(b.AvgDaysLate*10) / 10,
+		OnTimePercent:   math.Round(b.OnTimeRate*1000) / 10,
+		LifetimeRevenue: roundCents(b.TotalInvoiced),
+		OpenBalance:     roundCents(b.OpenBalance),
+		OverdueBalance:  roundCents(b.OverdueBalance),
+		RiskScore:       score,
+		RiskLevel:       riskLevel(score),
+		UpdatedAt:       now,
+	}
+}
+
+// riskScore weighs typical lateness, reliability and current overdue exposure.
+// Customers without payment history get a neutral reliability component.
+func riskScore(b *CustomerBehavior) int {
+	lateness := math.Min(math.Max(b.AvgDaysLate, 0)/60, 1) * 40
+
+	unreliability := 15.0
+	if b.PaidInvoices > 0 {
+		unreliability = (1 - b.OnTimeRate) * 30
+	}
+
+	exposure := 0.0
+	if b.OpenBalance > 0 {
+		exposure = b.OverdueBalance / b.OpenBalance * 30
+	}
+
+	return int(math.Round(lateness + unreliability + exposure))
+}
+
+// riskLevel buckets a risk score
+func riskLevel(score int) string {
+	switch {
+	case score >= 65:
+		return RiskHigh
+	case score >= 35:
+		return RiskMedium
+	default:
+		return RiskLow
+	}
+}
+
+// MetricsStore persists the latest customer metrics per realm
+type MetricsStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewMetricsStore creates a new customer metrics store
+func NewMetricsStore(client redis.UniversalClient, prefix string) *MetricsStore {
+	return &MetricsStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// metricsKey is the hash of customer ID to metrics for a realm
+func (s *MetricsStore) metricsKey(realmID string) string {
+	return fmt.Sprintf("%s:insights:customer-metrics:%s", s.prefix, realmID)
+}
+
+// Replace stores a full refresh of a realm's customer metrics
+func (s *MetricsStore) Replace(ctx context.Context, realmID string, metrics []CustomerMetrics) error {
+	key := s.metricsKey(realmID)
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	for _, m := range metrics {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal customer metrics: %w", err)
+		}
+		pipe.HSet(ctx, key, m.CustomerID, data)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save customer metrics: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns a customer's metrics
+func (s *MetricsStore) Get(ctx context.Context, realmID, customerID string) (*CustomerMetrics, error) {
+	data, err := s.client.HGet(ctx, s.metricsKey(realmID), customerID).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrMetricsNotFound
+		}
+		return nil, fmt.Errorf("failed to get customer metrics: %w", err)
+	}
+
+	var metrics CustomerMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal customer metrics: %w", err)
+	}
+
+	return &metrics, nil
+}
+
+// Populated reports whether the realm has been refreshed at least once
+func (s *MetricsStore) Populated(ctx context.Context, realmID string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.metricsKey(realmID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check customer metrics: %w", err)
+	}
+	return n > 0, nil
+}
+
+// metricsPayload is the job payload for a realm's metrics refresh
+type metricsPayload struct {
+	RealmID string `json:"realm_id"`
+}
+
+// MetricsService keeps per-customer payment metrics fresh. There is no
+// QuickBooks sync engine, so each realm is refreshed by a daily job that is
+// scheduled the first time its metrics are requested.
+type MetricsService struct {
+	behavior  *BehaviorAnalyzer
+	store     *MetricsStore
+	scheduler *jobs.Scheduler
+}
+
+// NewMetricsService creates a new customer metrics service and registers its job runner
+func NewMetricsService(behavior *BehaviorAnalyzer, store *MetricsStore, scheduler *jobs.Scheduler) *MetricsService {
+	service := &MetricsService{
+		behavior:  behavior,
+		store:     store,
+		scheduler: scheduler,
+	}
+	scheduler.RegisterRunner(CustomerMetricsJobType, jobs.RunnerFunc(service.runRefresh))
+	return service
+}
+
+// Refresh recomputes metrics for every customer in the realm and returns how many were scored
+func (s *MetricsService) Refresh(ctx context.Context, realmID string) (int, error) {
+	behaviors, err := s.behavior.Compute(ctx, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	metrics := make([]CustomerMetrics, 0, len(behaviors))
+	for _, b := range behaviors {
+		if b.CustomerID == "" {
+			continue
+		}
+		metrics = append(metrics, newCustomerMetrics(b, now))
+	}
+
+	if err := s.store.Replace(ctx, realmID, metrics); err != nil {
+		return 0, err
+	}
+
+	return len(metrics), nil
+}
+
+// CustomerMetrics returns a customer's metrics, computing the realm's metrics
+// and scheduling daily refreshes on first use. Dunning policies use the
+// risk score and level to decide how firmly to follow up.
+func (s *MetricsService) CustomerMetrics(ctx context.Context, realmID, customerID string) (*CustomerMetrics, error) {
+	populated, err := s.store.Populated(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	if !populated {
+		if _, err := s.Refresh(ctx, realmID); err != nil {
+			return nil, err
+		}
+		if err := s.ensureScheduled(ctx, realmID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.store.Get(ctx, realmID, customerID)
+}
+
+// ensureScheduled creates the realm's daily refresh job unless one is active
+func (s *MetricsService) ensureScheduled(ctx context.Context, realmID string) error {
+	tenantID := auth.GetTenantID(ctx)
+	existing, err := s.scheduler.Store().ListByTenant(ctx, tenantID, CustomerMetricsJobType)
+	if err != nil {
+		return err
+	}
+	for _, job := range existing {
+		var payload metricsPayload
+		if json.Unmarshal(job.Payload, &payload) == nil && payload.RealmID == realmID && job.Status == jobs.StatusActive {
+			return nil
+		}
+	}
+
+	job, err := jobs.NewJob(CustomerMetricsJobType, tenantID, auth.GetUserID(ctx), metricsPayload{RealmID: realmID})
+	if err != nil {
+		return fmt.Errorf("failed to create metrics job: %w", err)
+	}
+	job.Schedule = (&jobs.Schedule{Frequency: jobs.FrequencyDaily, Hour: 5}).Localize(ctx)
+	job.NextRunAt = job.Schedule.Next(time.Now())
+
+	return s.scheduler.Store().Save(ctx, job)
+}
+
+// runRefresh is the job runner for scheduled metric refreshes
+func (s *MetricsService) runRefresh(ctx context.Context, job *jobs.Job) error {
+	var payload metricsPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode metrics job: %w", err)
+	}
+
+	ctx = auth.WithIdentity(ctx, job.UserID, job.TenantID, payload.RealmID)
+
+	_, err := s.Refresh(ctx, payload.RealmID)
+	return err
+}
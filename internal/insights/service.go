@@ -0,0 +1,141 @@
+// insights/service.go
+package insights
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/notify"
+)
+
+// AnomalyScanJobType identifies recurring anomaly scans in the jobs subsystem
+const AnomalyScanJobType = "insights_anomaly_scan"
+
+// scanPayload is the job payload for a realm's anomaly scan
+type scanPayload struct {
+	RealmID       string               `json:"realm_id"`
+	Notifications []notify.Destination `json:"notifications,omitempty"`
+}
+
+// Service runs anomaly scans and notifies about new findings
+type Service struct {
+	analyzer  *Analyzer
+	store     *Store
+	scheduler *jobs.Scheduler
+	notifier  *notify.Dispatcher
+}
+
+// NewService creates a new insights service and registers its job runner
+func NewService(analyzer *Analyzer, store *Store, scheduler *jobs.Scheduler, notifier *notify.Dispatcher) *Service {
+	service := &Service{
+		analyzer:  analyzer,
+		store:     store,
+		scheduler: scheduler,
+		notifier:  notifier,
+	}
+	scheduler.RegisterRunner(AnomalyScanJobType, jobs.RunnerFunc(service.runScan))
+	return service
+}
+
+// Scan analyzes a realm, stores the findings, and returns the new ones
+func (s *Service) Scan(ctx context.Context, realmID string) ([]Finding, []Finding, error) {
+	findings, err := s.analyzer.Analyze(ctx, realmID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fresh, err := s.store.Replace(ctx, realmID, findings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return findings, fresh, nil
+}
+
+// Findings returns the realm's findings from the latest scan
+func (s *Service) Findings(ctx context.Context, realmID string) ([]Finding, error) {
+	return s.store.List(ctx, realmID)
+}
+
+// Monitor schedules a daily anomaly scan for the realm, replacing any existing one
+func (s *Service) Monitor(ctx context.Context, realmID string, destinations []notify.Destination) (*jobs.Job, error) {
+	for _, dest := range destinations {
+		if err := s.notifier.Validate(dest); err != nil {
+			return nil, err
+		}
+	}
+
+	tenantID := auth.GetTenantID(ctx)
+	existing, err := s.scheduler.Store().ListByTenant(ctx, tenantID, AnomalyScanJobType)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range existing {
+		var payload scanPayload
+		if json.Unmarshal(job.Payload, &payload) == nil && payload.RealmID == realmID && job.Status == jobs.StatusActive {
+			if _, err := s.scheduler.Cancel(ctx, job.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	job, err := jobs.NewJob(AnomalyScanJobType, tenantID, auth.GetUserID(ctx), scanPayload{
+		RealmID:       realmID,
+		Notifications: destinations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan job: %w", err)
+	}
+	job.Schedule = (&jobs.Schedule{Frequency: jobs.FrequencyDaily, Hour: 6}).Localize(ctx)
+	job.NextRunAt = job.Schedule.Next(time.Now())
+
+	if err := s.scheduler.Store().Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// runScan is the job runner for scheduled anomaly scans
+func (s *Service) runScan(ctx context.Context, job *jobs.Job) error {
+	var payload scanPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode scan job: %w", err)
+	}
+
+	ctx = auth.WithIdentity(ctx, job.UserID, job.TenantID, payload.RealmID)
+
+	_, fresh, err := s.Scan(ctx, payload.RealmID)
+	if err != nil {
+		return err
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	for _, f := range fresh {
+		fmt.Fprintf(&body, "[%s] %s\n", strings.ToUpper(f.Severity), f.Summary)
+	}
+	msg := notify.Message{
+		Subject: fmt.Sprintf("%d new transaction anomalies detected", len(fresh)),
+		Body:    body.String(),
+	}
+
+	var failed []string
+	for _, dest := range payload.Notifications {
+		if err := s.notifier.Send(ctx, dest.Channel, dest.Target, msg); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to deliver anomaly notifications: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
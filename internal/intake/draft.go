@@ -0,0 +1,233 @@
+// intake/draft.go
+package intake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DraftTTL is how long unconfirmed drafts and their documents are kept
+const DraftTTL = 30 * 24 * time.Hour
+
+// Draft statuses
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+)
+
+// Draft kinds: what a document becomes in QuickBooks once confirmed
+const (
+	KindBill    = "bill"
+	KindExpense = "expense" // a Purchase paid from a bank or credit card account
+)
+
+// Draft sources
+const (
+	SourceUpload = "upload"
+	SourceEmail  = "email"
+)
+
+var (
+	// ErrDraftNotFound is returned when a draft does not exist or expired
+	ErrDraftNotFound = errors.New("draft not found")
+	// ErrDocumentNotFound is returned when a draft's document expired
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+// BillLine is a line of a bill draft, booked to an expense account
+type BillLine struct {
+	Description string  `json:"description,omitempty"`
+	Amount      float64 `json:"amount"`
+	// AccountID is the expense account; empty uses the bill's
+	AccountID string `json:"account_id,omitempty"`
+}
+
+// BillDraft is the bill or expense a document is expected to become, for
+// the user to check and complete before it is created in QuickBooks
+type BillDraft struct {
+	VendorID   string `json:"vendor_id,omitempty"`
+	VendorName string `json:"vendor_name,omitempty"`
+	// AccountID is the expense account of lines without their own
+	AccountID string `json:"account_id,omitempty"`
+	// PaymentAccountID is the bank or credit card account an expense was
+	// paid from; bills do not use it
+	PaymentAccountID string     `json:"payment_account_id,omitempty"`
+	TxnDate          string     `json:"txn_date,omitempty"`
+	DueDate          string     `json:"due_date,omitempty"`
+	DocNumber        string     `json:"doc_number,omitempty"`
+	Currency         string     `json:"currency,omitempty"`
+	Memo             string     `json:"memo,omitempty"`
+	Lines            []BillLine `json:"lines"`
+}
+
+// Draft is a scanned document awaiting confirmation as a bill or expense
+type Draft struct {
+	ID      string `json:"id"`
+	RealmID string `json:"realm_id"`
+	// Kind is empty for drafts taken in before expenses were drafted,
+	// which are bills
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Source string `json:"source"`
+	// Sender is the address an emailed document came from
+	Sender      string `json:"sender,omitempty"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	// Text is the document's recognized text
+	Text       string      `json:"text"`
+	Extraction *Extraction `json:"extraction"`
+	Bill       BillDraft   `json:"bill"`
+	// Warnings name what the user should check before confirming
+	Warnings  []string  `json:"warnings"`
+	BillID    string    `json:"bill_id,omitempty"`
+	ExpenseID string    `json:"expense_id,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsExpense reports whether a draft becomes an expense rather than a bill
+func (d *Draft) IsExpense() bool {
+	return d.Kind == KindExpense
+}
+
+// Store persists drafts and their documents per realm until they expire
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new draft store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// indexKey is the sorted set of a realm's draft IDs by creation time
+func (s *Store) indexKey(realmID string) string {
+	return fmt.Sprintf("%s:intake:%s", s.prefix, realmID)
+}
+
+// draftKey holds a draft
+func (s *Store) draftKey(realmID, id string) string {
+	return fmt.Sprintf("%s:intake:draft:%s:%s", s.prefix, realmID, id)
+}
+
+// documentKey holds a draft's document
+func (s *Store) documentKey(realmID, id string) string {
+	return fmt.Sprintf("%s:intake:document:%s:%s", s.prefix, realmID, id)
+}
+
+// Save writes a draft, keeping it for DraftTTL from its creation
+func (s *Store) Save(ctx context.Context, draft *Draft) error {
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return fmt.Errorf("failed to marshal draft: %w", err)
+	}
+	ttl := time.Until(draft.CreatedAt.Add(DraftTTL))
+	if ttl <= 0 {
+		return ErrDraftNotFound
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.draftKey(draft.RealmID, draft.ID), data, ttl)
+	pipe.ZAdd(ctx, s.indexKey(draft.RealmID), &redis.Z{Score: float64(draft.CreatedAt.Unix()), Member: draft.ID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save draft: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a draft
+func (s *Store) Get(ctx context.Context, realmID, id string) (*Draft, error) {
+	data, err := s.client.Get(ctx, s.draftKey(realmID, id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrDraftNotFound
+		}
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+
+	var draft Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal draft: %w", err)
+	}
+	return &draft, nil
+}
+
+// List returns a realm's drafts, newest first, dropping expired ones from
+// the index
+func (s *Store) List(ctx context.Context, realmID string) ([]*Draft, error) {
+	index := s.indexKey(realmID)
+	cutoff := time.Now().Add(-DraftTTL).Unix()
+	s.client.ZRemRangeByScore(ctx, index, "-inf", fmt.Sprintf("(%d", cutoff))
+
+	ids, err := s.client.ZRevRange(ctx, index, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %w", err)
+	}
+	drafts := make([]*Draft, 0, len(ids))
+	if len(ids) == 0 {
+		return drafts, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.draftKey(realmID, id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %w", err)
+	}
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var draft Draft
+		if err := json.Unmarshal([]byte(data), &draft); err != nil {
+			continue
+		}
+		drafts = append(drafts, &draft)
+	}
+	return drafts, nil
+}
+
+// Delete removes a draft and its document
+func (s *Store) Delete(ctx context.Context, realmID, id string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, s.draftKey(realmID, id), s.documentKey(realmID, id))
+	pipe.ZRem(ctx, s.indexKey(realmID), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+	return nil
+}
+
+// SaveDocument stores a draft's document for as long as the draft
+func (s *Store) SaveDocument(ctx context.Context, realmID, id string, content []byte) error {
+	if err := s.client.Set(ctx, s.documentKey(realmID, id), content, DraftTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save document: %w", err)
+	}
+	return nil
+}
+
+// GetDocument retrieves a draft's document
+func (s *Store) GetDocument(ctx context.Context, realmID, id string) ([]byte, error) {
+	content, err := s.client.Get(ctx, s.documentKey(realmID, id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrDocumentNotFound
+		}
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,379 @@
+// intake/email.go
+package intake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"path"
+	"strings"
+)
+
+// MaxEmailSize limits inbound emails, attachments included, to 25 MB
+const MaxEmailSize = 25 << 20
+
+// maxPartDepth bounds how deeply nested multiparts and forwarded messages
+// are searched for attachments
+const maxPartDepth = 8
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+	// Inline attachments are shown in the body, like logos in signatures
+	Inline bool
+}
+
+// Email is an inbound email as delivered by a provider
+type Email struct {
+	MessageID string
+	// From is the sender's address
+	From string
+	// Recipients are the addresses the email was delivered to, including
+	// blind copies when the provider reports its envelope
+	Recipients  []string
+	Subject     string
+	Text        string
+	Attachments []Attachment
+}
+
+// Documents returns the attachments that can be taken in. An email without
+// any, such as a forwarded e-mail receipt, yields its body as a plain text
+// document.
+func (e *Email) Documents() []Attachment {
+	var documents []Attachment
+	for _, attachment := range e.Attachments {
+		if !attachment.Inline && DocumentTypes[attachment.ContentType] && attachment.ContentType != "text/plain" {
+			if attachment.FileName == "" {
+				attachment.FileName = "attachment"
+				if extensions, _ := mime.ExtensionsByType(attachment.ContentType); len(extensions) > 0 {
+					attachment.FileName += extensions[0]
+				}
+			}
+			documents = append(documents, attachment)
+		}
+	}
+	if len(documents) == 0 && strings.TrimSpace(e.Text) != "" {
+		name := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+				return '_'
+			}
+			return r
+		}, strings.TrimSpace(e.Subject))
+		if name == "" {
+			name = "email"
+		}
+		documents = append(documents, Attachment{
+			FileName:    name + ".txt",
+			ContentType: "text/plain",
+			Content:     []byte(e.Text),
+		})
+	}
+	return documents
+}
+
+// ParseMIME reads a raw RFC 5322 message
+func ParseMIME(r io.Reader) (*Email, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email: %w", err)
+	}
+
+	email := &Email{
+		MessageID: strings.Trim(msg.Header.Get("Message-Id"), "<> "),
+		Subject:   decodeHeader(msg.Header.Get("Subject")),
+	}
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		email.From = strings.ToLower(from.Address)
+	}
+	for _, field := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		if list, err := mail.ParseAddressList(msg.Header.Get(field)); err == nil {
+			for _, address := range list {
+				email.Recipients = append(email.Recipients, strings.ToLower(address.Address))
+			}
+		}
+	}
+
+	if err := email.walk(msg.Header.Get, msg.Body, 0); err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+// walk collects the body text and attachments of a MIME entity
+func (e *Email) walk(header func(string) string, body io.Reader, depth int) error {
+	if depth > maxPartDepth {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(header("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	switch encoding := strings.ToLower(strings.TrimSpace(header("Content-Transfer-Encoding"))); encoding {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid email part: %w", err)
+			}
+			if err := e.walk(part.Header.Get, part, depth+1); err != nil {
+				return err
+			}
+		}
+	case mediaType == "message/rfc822":
+		// A forwarded message's attachments are the documents forwarded
+		forwarded, err := mail.ReadMessage(body)
+		if err != nil {
+			return nil
+		}
+		return e.walk(forwarded.Header.Get, forwarded.Body, depth+1)
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header("Content-Disposition"))
+	fileName := decodeHeader(dispositionParams["filename"])
+	if fileName == "" {
+		fileName = decodeHeader(params["name"])
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read email part: %w", err)
+	}
+
+	if mediaType == "text/plain" && disposition != "attachment" && fileName == "" {
+		if e.Text == "" {
+			e.Text = string(content)
+		}
+		return nil
+	}
+	if fileName == "" && !strings.HasPrefix(mediaType, "image/") && mediaType != "application/pdf" {
+		return nil
+	}
+	e.Attachments = append(e.Attachments, Attachment{
+		FileName:    baseName(fileName),
+		ContentType: attachmentType(mediaType, fileName, content),
+		Content:     content,
+		Inline:      disposition == "inline" || (disposition == "" && header("Content-Id") != ""),
+	})
+	return nil
+}
+
+// attachmentType returns an attachment's content type, looking past the
+// generic types some mail clients label every attachment with
+func attachmentType(declared, fileName string, content []byte) string {
+	if DocumentTypes[declared] {
+		return declared
+	}
+	detected := http.DetectContentType(content)
+	if i := strings.Index(detected, ";"); i >= 0 {
+		detected = detected[:i]
+	}
+	if DocumentTypes[detected] && detected != "text/plain" {
+		return detected
+	}
+	if byExtension, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(path.Ext(fileName)))); err == nil && DocumentTypes[byExtension] {
+		return byExtension
+	}
+	if strings.HasSuffix(strings.ToLower(fileName), ".heic") {
+		return "image/heic"
+	}
+	return declared
+}
+
+// baseName drops any directories a sender put in an attachment's name
+func baseName(fileName string) string {
+	if fileName = path.Base(strings.ReplaceAll(fileName, `\`, "/")); fileName == "." || fileName == "/" {
+		return ""
+	}
+	return fileName
+}
+
+// decodeHeader decodes RFC 2047 encoded words
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// ParseSendGrid reads a SendGrid Inbound Parse webhook, either with the
+// raw message in its "email" field or with parsed fields and attachments
+func ParseSendGrid(r *http.Request) (*Email, error) {
+	if err := r.ParseMultipartForm(MaxEmailSize); err != nil {
+		return nil, fmt.Errorf("invalid inbound parse request: %w", err)
+	}
+	if raw := r.FormValue("email"); raw != "" {
+		email, err := ParseMIME(strings.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		email.Recipients = append(email.Recipients, sendGridEnvelope(r.FormValue("envelope"))...)
+		return email, nil
+	}
+
+	email := &Email{
+		Subject: r.FormValue("subject"),
+		Text:    r.FormValue("text"),
+	}
+	if headers, err := mail.ReadMessage(strings.NewReader(r.FormValue("headers") + "\r\n\r\n")); err == nil {
+		email.MessageID = strings.Trim(headers.Header.Get("Message-Id"), "<> ")
+	}
+	if from, err := mail.ParseAddress(r.FormValue("from")); err == nil {
+		email.From = strings.ToLower(from.Address)
+	}
+	for _, field := range []string{"to", "cc"} {
+		if list, err := mail.ParseAddressList(r.FormValue(field)); err == nil {
+			for _, address := range list {
+				email.Recipients = append(email.Recipients, strings.ToLower(address.Address))
+			}
+		}
+	}
+	email.Recipients = append(email.Recipients, sendGridEnvelope(r.FormValue("envelope"))...)
+
+	// attachment-info describes each file, including the content ID of
+	// those shown inline
+	var info map[string]struct {
+		FileName  string `json:"filename"`
+		Type      string `json:"type"`
+		ContentID string `json:"content-id"`
+	}
+	json.Unmarshal([]byte(r.FormValue("attachment-info")), &info)
+	if r.MultipartForm != nil {
+		for field, files := range r.MultipartForm.File {
+			for _, file := range files {
+				content, err := readFormFile(file)
+				if err != nil {
+					return nil, err
+				}
+				fileName := file.Filename
+				if fileName == "" {
+					fileName = info[field].FileName
+				}
+				email.Attachments = append(email.Attachments, Attachment{
+					FileName:    baseName(fileName),
+					ContentType: attachmentType(file.Header.Get("Content-Type"), fileName, content),
+					Content:     content,
+					Inline:      info[field].ContentID != "",
+				})
+			}
+		}
+	}
+	return email, nil
+}
+
+// sendGridEnvelope returns the recipients of a SendGrid envelope field
+func sendGridEnvelope(value string) []string {
+	var envelope struct {
+		To []string `json:"to"`
+	}
+	json.Unmarshal([]byte(value), &envelope)
+	recipients := make([]string, 0, len(envelope.To))
+	for _, to := range envelope.To {
+		recipients = append(recipients, strings.ToLower(to))
+	}
+	return recipients
+}
+
+// readFormFile reads an uploaded file of a multipart form
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// snsMessage is an Amazon SNS HTTP delivery
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// sesNotification is an SES receipt notification published through SNS
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	Mail             struct {
+		MessageID   string   `json:"messageId"`
+		Destination []string `json:"destination"`
+	} `json:"mail"`
+	Receipt struct {
+		Recipients []string `json:"recipients"`
+		Action     struct {
+			Encoding string `json:"encoding"`
+		} `json:"action"`
+	} `json:"receipt"`
+	Content string `json:"content"`
+}
+
+// errSubscription is returned for SNS subscription confirmations, which
+// carry no email
+var errSubscription = errors.New("subscription confirmation")
+
+// ParseSES reads an SES receipt notification delivered by an SNS topic of
+// an SES "SNS" receipt rule action. SNS only carries messages of up to
+// 150 KB; larger ones are dropped by SES with that action. Subscription
+// confirmations return errSubscription and the URL to confirm with.
+func ParseSES(body []byte) (*Email, string, error) {
+	var message snsMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, "", fmt.Errorf("invalid SNS message: %w", err)
+	}
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return nil, message.SubscribeURL, errSubscription
+	case "Notification":
+	default:
+		return nil, "", fmt.Errorf("unsupported SNS message type %q", message.Type)
+	}
+
+	var notification sesNotification
+	if err := json.Unmarshal([]byte(message.Message), &notification); err != nil {
+		return nil, "", fmt.Errorf("invalid SES notification: %w", err)
+	}
+	if notification.NotificationType != "Received" || notification.Content == "" {
+		return nil, "", fmt.Errorf("SES notification carries no email")
+	}
+
+	raw := []byte(notification.Content)
+	if strings.EqualFold(notification.Receipt.Action.Encoding, "BASE64") {
+		decoded, err := base64.StdEncoding.DecodeString(notification.Content)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid SES content: %w", err)
+		}
+		raw = decoded
+	}
+	email, err := ParseMIME(bytes.NewReader(raw))
+	if err != nil {
+		return nil, "", err
+	}
+	if email.MessageID == "" {
+		email.MessageID = notification.Mail.MessageID
+	}
+	for _, recipient := range notification.Receipt.Recipients {
+		email.Recipients = append(email.Recipients, strings.ToLower(recipient))
+	}
+	return email, "", nil
+}
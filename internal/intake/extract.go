@@ -0,0 +1,310 @@
+// intake/extract.go
+package intake
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// vendorLines is how many lines from the top of a document are searched
+// for the vendor's name
+const vendorLines = 6
+
+// LineCandidate is a printed line that looks like a purchased item
+type LineCandidate struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	UnitPrice   float64 `json:"unit_price,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// Extraction is what was recognized on a receipt or bill. Fields that
+// were not found are empty.
+type Extraction struct {
+	Vendor string `json:"vendor,omitempty"`
+	// Date and DueDate are YYYY-MM-DD
+	Date    string `json:"date,omitempty"`
+	DueDate string `json:"due_date,omitempty"`
+	// Reference is the vendor's invoice or receipt number
+	Reference string          `json:"reference,omitempty"`
+	Currency  string          `json:"currency,omitempty"`
+	Subtotal  float64         `json:"subtotal,omitempty"`
+	Tax       float64         `json:"tax,omitempty"`
+	Total     float64         `json:"total"`
+	Lines     []LineCandidate `json:"lines"`
+}
+
+var (
+	isoDate      = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	numericDate  = regexp.MustCompile(`\b(\d{1,2})([/.-])(\d{1,2})[/.-](\d{4}|\d{2})\b`)
+	dayMonthDate = regexp.MustCompile(`(?i)\b(\d{1,2})\.?\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?,?\s+(\d{4})\b`)
+	monthDayDate = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`)
+	percentage   = regexp.MustCompile(`\d+(?:[.,]\d+)?\s?%`)
+	// amountPattern requires cents, which tells amounts from other numbers
+	amountPattern    = regexp.MustCompile(`(-)?([$€£]\s?)?(\d{1,3}(?:[,.']\d{3})+|\d+)[.,](\d{2})\b`)
+	quantityPrefix   = regexp.MustCompile(`^(\d{1,4})\s*(?:x|@|pcs|ea)?\s+`)
+	quantitySuffix   = regexp.MustCompile(`\s(\d{1,4})\s*(?:x|@|pcs|ea)$`)
+	referencePattern = regexp.MustCompile(`(?i)\b(?:invoice|receipt|bill|order|inv|ref(?:erence)?)\s*(?:no\.?|number|num|#)\s*[:.]?\s*([A-Z0-9][A-Z0-9/-]{0,30})`)
+	currencyCode     = regexp.MustCompile(`\b(USD|EUR|GBP|CAD|AUD|NZD|CHF|SEK|NOK|DKK|JPY|INR|MXN)\b`)
+
+	subtotalWord = regexp.MustCompile(`\bsub\s?-?total\b`)
+	totalWord    = regexp.MustCompile(`\b(total|amount due|balance due|amount payable)\b`)
+	inclusive    = regexp.MustCompile(`\b(incl|including|inc)\b`)
+	taxWord      = regexp.MustCompile(`\b(tax|vat|gst|hst|pst|iva|tva|mwst)\b`)
+	paymentWord  = regexp.MustCompile(`\b(change|cash|tendered|card|visa|mastercard|amex|debit|credit|payment|paid|tip|gratuity|balance|rounding|discount|savings|saved|auth|approval)\b`)
+	dueWord      = regexp.MustCompile(`\b(due|payable by)\b`)
+	dateWord     = regexp.MustCompile(`\b(date|dated|issued)\b`)
+	headerWord   = regexp.MustCompile(`\b(receipt|invoice|bill|statement|order|tel|phone|fax|www|http|https|email|date|page|customer|cashier|welcome)\b`)
+)
+
+// months maps month abbreviations to their number
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// Extract finds the vendor, dates, amounts and item lines in the text of a
+// receipt or bill. Numeric dates with slashes are read month first unless
+// dayFirst is set or the first number cannot be a month.
+func Extract(text string, dayFirst bool) *Extraction {
+	ex := &Extraction{Lines: []LineCandidate{}}
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	var labeledDate, firstDate string
+	var totals, all []float64
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+
+		dates, rest := findDates(line, dayFirst)
+		if len(dates) > 0 {
+			switch {
+			case dueWord.MatchString(lower):
+				if ex.DueDate == "" {
+					ex.DueDate = dates[0]
+				}
+			case dateWord.MatchString(lower):
+				if labeledDate == "" {
+					labeledDate = dates[0]
+				}
+			default:
+				if firstDate == "" {
+					firstDate = dates[0]
+				}
+			}
+		}
+		if ex.Reference == "" {
+			if m := referencePattern.FindStringSubmatch(line); m != nil && strings.ContainsAny(m[1], "0123456789") {
+				ex.Reference = m[1]
+			}
+		}
+
+		amounts, description := findAmounts(percentage.ReplaceAllString(rest, " "))
+		if len(amounts) == 0 {
+			continue
+		}
+		all = append(all, amounts...)
+		last := amounts[len(amounts)-1]
+		switch {
+		case subtotalWord.MatchString(lower):
+			ex.Subtotal = last
+		case taxWord.MatchString(lower) && !(totalWord.MatchString(lower) && inclusive.MatchString(lower)):
+			ex.Tax += last
+		case totalWord.MatchString(lower):
+			totals = append(totals, last)
+		case paymentWord.MatchString(lower):
+		default:
+			if candidate, ok := lineCandidate(description, amounts); ok {
+				ex.Lines = append(ex.Lines, candidate)
+			}
+		}
+	}
+
+	ex.Date = labeledDate
+	if ex.Date == "" {
+		ex.Date = firstDate
+	}
+	switch {
+	case len(totals) > 0:
+		ex.Total = maxOf(totals)
+	case ex.Subtotal > 0:
+		ex.Total = round(ex.Subtotal + ex.Tax)
+	case len(all) > 0:
+		ex.Total = maxOf(all)
+	}
+	ex.Vendor = vendor(lines)
+	ex.Currency = currency(text)
+	return ex
+}
+
+// findDates returns the dates in a line as YYYY-MM-DD and the line without
+// them, so their digits are not taken for amounts
+func findDates(line string, dayFirst bool) ([]string, string) {
+	var dates []string
+	add := func(year, month, day int) {
+		if year < 100 {
+			year += 2000
+		}
+		date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if year >= 2000 && year <= 2100 && date.Day() == day && date.Month() == time.Month(month) {
+			dates = append(dates, date.Format("2006-01-02"))
+		}
+	}
+
+	line = isoDate.ReplaceAllStringFunc(line, func(match string) string {
+		m := isoDate.FindStringSubmatch(match)
+		add(atoi(m[1]), atoi(m[2]), atoi(m[3]))
+		return " "
+	})
+	line = numericDate.ReplaceAllStringFunc(line, func(match string) string {
+		m := numericDate.FindStringSubmatch(match)
+		month, day := atoi(m[1]), atoi(m[3])
+		// Dotted dates are day first everywhere they are used
+		if (dayFirst || m[2] == "." || month > 12) && day <= 12 {
+			month, day = day, month
+		}
+		add(atoi(m[4]), month, day)
+		return " "
+	})
+	line = dayMonthDate.ReplaceAllStringFunc(line, func(match string) string {
+		m := dayMonthDate.FindStringSubmatch(match)
+		add(atoi(m[3]), int(months[strings.ToLower(m[2])]), atoi(m[1]))
+		return " "
+	})
+	line = monthDayDate.ReplaceAllStringFunc(line, func(match string) string {
+		m := monthDayDate.FindStringSubmatch(match)
+		add(atoi(m[3]), int(months[strings.ToLower(m[1])]), atoi(m[2]))
+		return " "
+	})
+	return dates, line
+}
+
+// findAmounts returns the amounts in a line, in order, and the line without
+// them
+func findAmounts(line string) ([]float64, string) {
+	var amounts []float64
+	rest := amountPattern.ReplaceAllStringFunc(line, func(match string) string {
+		m := amountPattern.FindStringSubmatch(match)
+		whole := strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, m[3])
+		value, err := strconv.ParseFloat(whole+"."+m[4], 64)
+		if err != nil {
+			return match
+		}
+		if m[1] != "" {
+			value = -value
+		}
+		amounts = append(amounts, value)
+		return " "
+	})
+	return amounts, rest
+}
+
+// lineCandidate reads an item line: a description, an optional quantity,
+// and the unit price and amount or just the amount
+func lineCandidate(text string, amounts []float64) (LineCandidate, bool) {
+	candidate := LineCandidate{Amount: amounts[len(amounts)-1]}
+	text = strings.TrimSpace(text)
+	if m := quantityPrefix.FindStringSubmatch(text); m != nil {
+		candidate.Quantity = float64(atoi(m[1]))
+		text = text[len(m[0]):]
+	} else if m := quantitySuffix.FindStringSubmatch(text); m != nil {
+		candidate.Quantity = float64(atoi(m[1]))
+		text = text[:len(text)-len(m[0])]
+	}
+	if len(amounts) >= 2 {
+		price := amounts[len(amounts)-2]
+		switch {
+		case candidate.Quantity > 0 && math.Abs(candidate.Quantity*price-candidate.Amount) < 0.01:
+			candidate.UnitPrice = price
+		case candidate.Quantity == 0 && price > 0:
+			if quantity := math.Round(candidate.Amount / price); quantity >= 1 && math.Abs(quantity*price-candidate.Amount) < 0.01 {
+				candidate.Quantity, candidate.UnitPrice = quantity, price
+			}
+		}
+	}
+	if candidate.UnitPrice == 0 {
+		candidate.Quantity = 0
+	}
+
+	candidate.Description = strings.Trim(strings.Join(strings.Fields(text), " "), " .:-*#$€£")
+	letters := 0
+	for _, r := range candidate.Description {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return candidate, letters >= 2 && candidate.Amount != 0
+}
+
+// vendor returns the first line near the top that reads like a business
+// name rather than a heading, address or contact detail
+func vendor(lines []string) string {
+	for i, line := range lines {
+		if i == vendorLines {
+			break
+		}
+		letters, digits := 0, 0
+		for _, r := range line {
+			switch {
+			case unicode.IsLetter(r):
+				letters++
+			case unicode.IsDigit(r):
+				digits++
+			}
+		}
+		if letters < 3 || digits*3 > letters || headerWord.MatchString(strings.ToLower(line)) {
+			continue
+		}
+		return strings.Trim(line, " .:-*#")
+	}
+	return ""
+}
+
+// currency returns the ISO code named or implied by the symbols in the
+// text; "$" is ambiguous and left to the company's home currency
+func currency(text string) string {
+	if m := currencyCode.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	switch {
+	case strings.Contains(text, "€"):
+		return "EUR"
+	case strings.Contains(text, "£"):
+		return "GBP"
+	}
+	return ""
+}
+
+// atoi parses digits matched by a pattern
+func atoi(value string) int {
+	n, _ := strconv.Atoi(value)
+	return n
+}
+
+// maxOf returns the largest value
+func maxOf(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Max(result, v)
+	}
+	return result
+}
+
+// round rounds an amount to cents
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
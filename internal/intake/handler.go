@@ -0,0 +1,280 @@
+// intake/handler.go
+package intake
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for document intake and bill drafts
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new document intake handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// writeError maps intake errors to HTTP responses
+func writeError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, ErrDraftNotFound), errors.Is(err, ErrDocumentNotFound), errors.Is(err, ErrMailboxNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyConfirmed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrUnsupportedType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, ErrNoText):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, ErrUnavailable), errors.Is(err, ErrEmailDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		http.Error(w, "Failed to "+action+": "+err.Error(), http.StatusBadRequest)
+	}
+}
+
+// Intake recognizes an uploaded receipt or bill and returns its draft for
+// confirmation. The form's kind field drafts a bill (the default) or an
+// expense, whose payment account payment_account_id prefills.
+func (h *Handler) Intake(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxDocumentSize)
+	if err := r.ParseMultipartForm(MaxDocumentSize); err != nil {
+		http.Error(w, "Invalid upload", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+
+	contentType := http.DetectContentType(content)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !DocumentTypes[contentType] {
+		contentType = header.Header.Get("Content-Type")
+	}
+
+	draft, err := h.service.Intake(r.Context(), realmID, auth.GetUserID(r.Context()), Document{
+		FileName:         header.Filename,
+		ContentType:      contentType,
+		Content:          content,
+		Kind:             r.FormValue("kind"),
+		PaymentAccountID: r.FormValue("payment_account_id"),
+		Source:           SourceUpload,
+	})
+	if err != nil {
+		writeError(w, "take in document", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// ListDrafts returns the company's drafts, optionally filtered by ?status=,
+// ?kind= and ?source=
+func (h *Handler) ListDrafts(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	drafts, err := h.service.Store().List(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list drafts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	status, kind, source := query.Get("status"), query.Get("kind"), query.Get("source")
+	filtered := make([]*Draft, 0, len(drafts))
+	for _, draft := range drafts {
+		draftKind := draft.Kind
+		if draftKind == "" {
+			draftKind = KindBill
+		}
+		if (status == "" || draft.Status == status) && (kind == "" || draftKind == kind) && (source == "" || draft.Source == source) {
+			filtered = append(filtered, draft)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"drafts": filtered,
+	})
+}
+
+// GetDraft returns a draft
+func (h *Handler) GetDraft(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	draft, err := h.service.Store().Get(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "get draft", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// GetDocument returns a draft's scanned document
+func (h *Handler) GetDocument(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	draft, err := h.service.Store().Get(r.Context(), realmID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "get document", err)
+		return
+	}
+	content, err := h.service.Store().GetDocument(r.Context(), realmID, draft.ID)
+	if err != nil {
+		writeError(w, "get document", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", draft.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="`+strings.ReplaceAll(draft.FileName, `"`, "")+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+// ConfirmDraft creates the bill or expense of a draft. The body optionally
+// replaces the draft's bill with the user's corrections.
+func (h *Handler) ConfirmDraft(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	var bill *BillDraft
+	var corrected BillDraft
+	switch err := json.NewDecoder(r.Body).Decode(&corrected); {
+	case err == io.EOF:
+	case err != nil:
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	default:
+		bill = &corrected
+	}
+
+	draft, err := h.service.Confirm(r.Context(), realmID, mux.Vars(r)["id"], bill)
+	if err != nil {
+		writeError(w, "confirm draft", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// DiscardDraft deletes a draft and its document
+func (h *Handler) DiscardDraft(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	if err := h.service.Discard(r.Context(), realmID, mux.Vars(r)["id"]); err != nil {
+		writeError(w, "discard draft", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMailbox returns the company's address for emailing in documents
+func (h *Handler) GetMailbox(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	mailbox, err := h.service.Store().GetMailbox(r.Context(), realmID)
+	if err != nil {
+		writeError(w, "get mailbox", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(mailbox)
+}
+
+// OpenMailbox gives the company a new address for emailing in documents,
+// retiring its previous one. The body sets the kind of draft emailed
+// documents become, the payment account of expenses and the allowed
+// senders.
+func (h *Handler) OpenMailbox(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	var settings Mailbox
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	mailbox, err := h.service.OpenMailbox(r.Context(), realmID, auth.GetUserID(r.Context()), settings)
+	if err != nil {
+		writeError(w, "open mailbox", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(mailbox)
+}
+
+// CloseMailbox removes the company's mailbox; mail to it is dropped
+func (h *Handler) CloseMailbox(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	if err := h.service.Store().DeleteMailbox(r.Context(), realmID); err != nil {
+		writeError(w, "close mailbox", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,225 @@
+// intake/inbound.go
+package intake
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/gorilla/mux"
+)
+
+// Inbound email providers
+const (
+	ProviderSendGrid = "sendgrid"
+	ProviderSES      = "ses"
+)
+
+// deliverTimeout bounds taking in one email after it is acknowledged
+const deliverTimeout = 5 * time.Minute
+
+// snsHost matches the hosts SNS subscriptions are confirmed at, so a
+// forged confirmation cannot make the server fetch other URLs
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// Connections resolves the user and tenant a company is connected through,
+// so emailed documents can be taken in for it. It returns an empty user ID
+// for companies no longer connected.
+type Connections interface {
+	Connection(ctx context.Context, realmID string) (userID, tenantID string, err error)
+}
+
+// EmailReceiver is the endpoint inbound email providers post forwarded
+// receipts and bills to. Providers are authenticated by a shared secret,
+// given as the token query parameter or the basic auth password of the
+// configured webhook URL. Emails are acknowledged once parsed and taken in
+// in the background; providers redeliver unacknowledged emails, and
+// redeliveries are recognized by their message ID.
+type EmailReceiver struct {
+	service     *Service
+	secret      string
+	connections Connections
+	httpClient  *http.Client
+}
+
+// NewEmailReceiver creates a new inbound email receiver
+func NewEmailReceiver(service *Service, secret string, connections Connections) *EmailReceiver {
+	return &EmailReceiver{
+		service:     service,
+		secret:      secret,
+		connections: connections,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// authorized checks the request's shared secret
+func (e *EmailReceiver) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+	return e.secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(e.secret)) == 1
+}
+
+// Receive parses an inbound email posted by the provider named in the path
+// and takes in its documents for the companies it was addressed to
+func (e *EmailReceiver) Receive(w http.ResponseWriter, r *http.Request) {
+	if !e.authorized(r) {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxEmailSize)
+
+	var email *Email
+	var err error
+	switch mux.Vars(r)["provider"] {
+	case ProviderSendGrid:
+		email, err = ParseSendGrid(r)
+	case ProviderSES:
+		var body []byte
+		if body, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+		var subscribeURL string
+		email, subscribeURL, err = ParseSES(body)
+		if errors.Is(err, errSubscription) {
+			if err := e.confirmSubscription(r.Context(), subscribeURL); err != nil {
+				http.Error(w, "Failed to confirm subscription: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	default:
+		http.Error(w, "Unsupported email provider", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	go e.deliver(email)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int{"documents": len(email.Documents())})
+}
+
+// confirmSubscription confirms an SNS topic subscription to this endpoint
+func (e *EmailReceiver) confirmSubscription(ctx context.Context, subscribeURL string) error {
+	parsed, err := url.Parse(subscribeURL)
+	if err != nil || parsed.Scheme != "https" || !snsHost.MatchString(parsed.Hostname()) {
+		return fmt.Errorf("subscribe URL is not an SNS endpoint")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", parsed.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SNS returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deliver takes in an email's documents for each company mailbox it was
+// addressed to
+func (e *EmailReceiver) deliver(email *Email) {
+	ctx, cancel := context.WithTimeout(context.Background(), deliverTimeout)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	for _, recipient := range email.Recipients {
+		mailbox, err := e.mailbox(ctx, recipient)
+		if err != nil {
+			if !errors.Is(err, ErrMailboxNotFound) {
+				logging.FromContext(ctx).Warn("failed to look up mailbox", "recipient", recipient, "error", err)
+			}
+			continue
+		}
+		if seen[mailbox.RealmID] {
+			continue
+		}
+		seen[mailbox.RealmID] = true
+
+		if err := e.deliverTo(ctx, mailbox, email); err != nil {
+			logging.FromContext(ctx).Warn("failed to take in email", "message_id", email.MessageID, "realm_id", mailbox.RealmID, "error", err)
+		}
+	}
+}
+
+// mailbox finds the mailbox of a recipient address on the configured
+// domain, also accepting the token as a plus address tag
+func (e *EmailReceiver) mailbox(ctx context.Context, recipient string) (*Mailbox, error) {
+	local, domain, ok := strings.Cut(strings.ToLower(recipient), "@")
+	if !ok || domain != strings.ToLower(e.service.emailDomain) {
+		return nil, ErrMailboxNotFound
+	}
+	mailbox, err := e.service.store.LookupMailbox(ctx, local)
+	if errors.Is(err, ErrMailboxNotFound) {
+		if _, tag, ok := strings.Cut(local, "+"); ok {
+			return e.service.store.LookupMailbox(ctx, tag)
+		}
+	}
+	return mailbox, err
+}
+
+// deliverTo drafts an email's documents in a mailbox's company under the
+// identity of the user who connected it
+func (e *EmailReceiver) deliverTo(ctx context.Context, mailbox *Mailbox, email *Email) error {
+	if !mailbox.Accepts(email.From) {
+		logging.FromContext(ctx).Info("dropped email from a sender not allowed", "message_id", email.MessageID, "from", email.From, "realm_id", mailbox.RealmID)
+		return nil
+	}
+	if email.MessageID != "" {
+		first, err := e.service.store.MarkMessage(ctx, mailbox.RealmID, email.MessageID)
+		if err != nil {
+			return err
+		}
+		if !first {
+			return nil
+		}
+	}
+
+	userID, tenantID, err := e.connections.Connection(ctx, mailbox.RealmID)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return fmt.Errorf("company is no longer connected")
+	}
+	ctx = auth.WithIdentity(ctx, userID, tenantID, mailbox.RealmID)
+
+	for _, document := range email.Documents() {
+		draft, err := e.service.Intake(ctx, mailbox.RealmID, userID, Document{
+			FileName:         document.FileName,
+			ContentType:      document.ContentType,
+			Content:          document.Content,
+			Kind:             mailbox.Kind,
+			PaymentAccountID: mailbox.PaymentAccountID,
+			Source:           SourceEmail,
+			Sender:           email.From,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to take in emailed document", "file", document.FileName, "message_id", email.MessageID, "error", err)
+			continue
+		}
+		logging.FromContext(ctx).Info("drafted emailed document", "kind", draft.Kind, "draft_id", draft.ID, "file", document.FileName, "from", email.From)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// intake/mailbox.go
+package intake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/go-redis/redis/v8"
+)
+
+// messageTTL is how long an emailed message's ID is remembered, so a
+// redelivered message is not taken in twice
+const messageTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrEmailDisabled is returned when no inbound email domain is configured
+	ErrEmailDisabled = errors.New("emailing in documents is not enabled")
+	// ErrMailboxNotFound is returned when a company has no mailbox
+	ErrMailboxNotFound = errors.New("mailbox not found")
+)
+
+// Mailbox is a company's address for forwarding receipts and bills to.
+// The address's local part is a random token, so it cannot be guessed.
+type Mailbox struct {
+	Address string `json:"address"`
+	RealmID string `json:"realm_id"`
+	// Kind is what emailed documents are drafted as
+	Kind string `json:"kind"`
+	// PaymentAccountID prefills the account emailed expenses were paid
+	// from, e.g. the company card
+	PaymentAccountID string `json:"payment_account_id,omitempty"`
+	// AllowedSenders restricts the senders taken in, by address or by
+	// "@domain"; empty takes in mail from anyone who knows the address
+	AllowedSenders []string  `json:"allowed_senders"`
+	CreatedBy      string    `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// token returns the mailbox's local part
+func (m *Mailbox) token() string {
+	return strings.ToLower(strings.SplitN(m.Address, "@", 2)[0])
+}
+
+// Accepts reports whether mail from sender is taken in
+func (m *Mailbox) Accepts(sender string) bool {
+	if len(m.AllowedSenders) == 0 {
+		return true
+	}
+	sender = strings.ToLower(sender)
+	for _, allowed := range m.AllowedSenders {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if sender == allowed || (strings.HasPrefix(allowed, "@") && strings.HasSuffix(sender, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mailboxKey holds a mailbox by its token
+func (s *Store) mailboxKey(token string) string {
+	return fmt.Sprintf("%s:intake:mailbox:%s", s.prefix, token)
+}
+
+// realmMailboxKey holds the token of a realm's mailbox
+func (s *Store) realmMailboxKey(realmID string) string {
+	return fmt.Sprintf("%s:intake:mailbox:realm:%s", s.prefix, realmID)
+}
+
+// messageKey marks an emailed message as taken in
+func (s *Store) messageKey(realmID, messageID string) string {
+	return fmt.Sprintf("%s:intake:message:%s:%s", s.prefix, realmID, messageID)
+}
+
+// SaveMailbox stores a realm's mailbox, retiring its previous address
+func (s *Store) SaveMailbox(ctx context.Context, mailbox *Mailbox) error {
+	data, err := json.Marshal(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mailbox: %w", err)
+	}
+	previous, err := s.client.Get(ctx, s.realmMailboxKey(mailbox.RealmID)).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get mailbox: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	if previous != "" && previous != mailbox.token() {
+		pipe.Del(ctx, s.mailboxKey(previous))
+	}
+	pipe.Set(ctx, s.mailboxKey(mailbox.token()), data, 0)
+	pipe.Set(ctx, s.realmMailboxKey(mailbox.RealmID), mailbox.token(), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save mailbox: %w", err)
+	}
+	return nil
+}
+
+// GetMailbox retrieves a realm's mailbox
+func (s *Store) GetMailbox(ctx context.Context, realmID string) (*Mailbox, error) {
+	token, err := s.client.Get(ctx, s.realmMailboxKey(realmID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrMailboxNotFound
+		}
+		return nil, fmt.Errorf("failed to get mailbox: %w", err)
+	}
+	return s.LookupMailbox(ctx, token)
+}
+
+// LookupMailbox retrieves the mailbox an address's local part belongs to
+func (s *Store) LookupMailbox(ctx context.Context, token string) (*Mailbox, error) {
+	data, err := s.client.Get(ctx, s.mailboxKey(strings.ToLower(token))).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrMailboxNotFound
+		}
+		return nil, fmt.Errorf("failed to get mailbox: %w", err)
+	}
+
+	var mailbox Mailbox
+	if err := json.Unmarshal(data, &mailbox); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mailbox: %w", err)
+	}
+	return &mailbox, nil
+}
+
+// DeleteMailbox removes a realm's mailbox; mail to its address is dropped
+func (s *Store) DeleteMailbox(ctx context.Context, realmID string) error {
+	mailbox, err := s.GetMailbox(ctx, realmID)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Del(ctx, s.mailboxKey(mailbox.token()), s.realmMailboxKey(realmID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete mailbox: %w", err)
+	}
+	return nil
+}
+
+// MarkMessage records an emailed message as taken in for a realm. It
+// returns false if the message was already taken in.
+func (s *Store) MarkMessage(ctx context.Context, realmID, messageID string) (bool, error) {
+	ok, err := s.client.SetNX(ctx, s.messageKey(realmID, messageID), 1, messageTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark message: %w", err)
+	}
+	return ok, nil
+}
+
+// OpenMailbox gives a realm a new mailbox address with the given settings,
+// replacing its previous address
+func (s *Service) OpenMailbox(ctx context.Context, realmID, userID string, settings Mailbox) (*Mailbox, error) {
+	if s.emailDomain == "" {
+		return nil, ErrEmailDisabled
+	}
+	if settings.Kind == "" {
+		settings.Kind = KindExpense
+	}
+	if settings.Kind != KindBill && settings.Kind != KindExpense {
+		return nil, fmt.Errorf("kind must be %q or %q", KindBill, KindExpense)
+	}
+	if settings.PaymentAccountID != "" && !query.IsID(settings.PaymentAccountID) {
+		return nil, fmt.Errorf("invalid payment_account_id")
+	}
+	senders := []string{}
+	for _, sender := range settings.AllowedSenders {
+		if sender = strings.ToLower(strings.TrimSpace(sender)); sender != "" {
+			if !strings.Contains(sender, "@") {
+				return nil, fmt.Errorf("allowed sender %q must be an address or @domain", sender)
+			}
+			senders = append(senders, sender)
+		}
+	}
+
+	mailbox := &Mailbox{
+		Address:          "receipts-" + jobs.NewID()[:16] + "@" + s.emailDomain,
+		RealmID:          realmID,
+		Kind:             settings.Kind,
+		PaymentAccountID: settings.PaymentAccountID,
+		AllowedSenders:   senders,
+		CreatedBy:        userID,
+		CreatedAt:        time.Now(),
+	}
+	if err := s.store.SaveMailbox(ctx, mailbox); err != nil {
+		return nil, err
+	}
+	return mailbox, nil
+}
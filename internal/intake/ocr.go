@@ -0,0 +1,70 @@
+// intake/ocr.go
+package intake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Provider recognizes the text of scanned documents
+type Provider interface {
+	// Recognize returns the text of an image or PDF, one printed line per
+	// line
+	Recognize(ctx context.Context, content []byte, contentType string) (string, error)
+}
+
+// HTTPProvider calls an OCR service that accepts the document as the
+// request body and answers {"text": "..."}, such as a Tesseract server or
+// an adapter in front of a cloud OCR API
+type HTTPProvider struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewHTTPProvider creates a new OCR client; the API key is optional
+func NewHTTPProvider(url, apiKey string) *HTTPProvider {
+	return &HTTPProvider{
+		url:        strings.TrimRight(url, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Recognize sends a document to the OCR service
+func (p *HTTPProvider) Recognize(ctx context.Context, content []byte, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCR request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OCR request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("OCR request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse OCR response: %w", err)
+	}
+	return result.Text, nil
+}
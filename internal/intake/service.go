@@ -0,0 +1,526 @@
+// intake/service.go
+package intake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// MaxDocumentSize limits scanned documents to 10 MB
+const MaxDocumentSize = 10 << 20
+
+// maxVendors bounds the vendors a recognized name is matched against
+const maxVendors = 1000
+
+// DocumentTypes are the accepted document content types. Plain text, such
+// as a receipt in an email's body, is read as it is rather than recognized.
+var DocumentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/tiff":      true,
+	"image/heic":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+var (
+	// ErrUnavailable is returned when no OCR provider is configured
+	ErrUnavailable = errors.New("document intake is not enabled")
+	// ErrUnsupportedType is returned for documents of other content types
+	ErrUnsupportedType = errors.New("documents must be JPEG, PNG, TIFF, HEIC, PDF or text files")
+	// ErrNoText is returned when OCR found no text in a document
+	ErrNoText = errors.New("no text was recognized in the document")
+	// ErrAlreadyConfirmed is returned when confirming a confirmed draft
+	ErrAlreadyConfirmed = errors.New("draft is already confirmed")
+)
+
+// companySuffixes are dropped when matching vendor names
+var companySuffixes = map[string]bool{
+	"the": true, "inc": true, "llc": true, "ltd": true, "limited": true, "co": true, "corp": true,
+	"corporation": true, "company": true, "gmbh": true, "ag": true, "sa": true, "sarl": true, "bv": true, "plc": true,
+}
+
+// QuickBooks is the subset of the QuickBooks client used to draft and
+// create bills and expenses
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	GetCompanyInfo(ctx context.Context) (*qbclient.CompanyInfo, error)
+	Upload(ctx context.Context, attachable *qbclient.Attachable, content []byte) (*qbclient.Attachable, error)
+}
+
+// Locker serializes confirmations of the same draft across instances
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// Document is a receipt or bill to take in
+type Document struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+	// Kind is what the document is drafted as; empty drafts a bill
+	Kind string
+	// PaymentAccountID prefills the account an expense was paid from
+	PaymentAccountID string
+	// Source is how the document arrived, and Sender who emailed it
+	Source string
+	Sender string
+}
+
+// Service turns scanned receipts and bills into bill and expense drafts
+// and creates them in QuickBooks once confirmed
+type Service struct {
+	store       *Store
+	ocr         Provider
+	qb          QuickBooks
+	locker      Locker
+	emailDomain string
+}
+
+// NewService creates a new document intake service. Without an OCR
+// provider only plain text documents can be taken in, and without an
+// email domain companies get no mailbox.
+func NewService(store *Store, ocr Provider, qb QuickBooks, locker Locker, emailDomain string) *Service {
+	return &Service{
+		store:       store,
+		ocr:         ocr,
+		qb:          qb,
+		locker:      locker,
+		emailDomain: emailDomain,
+	}
+}
+
+// Store returns the draft store
+func (s *Service) Store() *Store {
+	return s.store
+}
+
+// Intake recognizes a document and stores it with a bill or expense draft
+// prefilled from what was found on it
+func (s *Service) Intake(ctx context.Context, realmID, userID string, doc Document) (*Draft, error) {
+	if doc.Kind == "" {
+		doc.Kind = KindBill
+	}
+	if doc.Kind != KindBill && doc.Kind != KindExpense {
+		return nil, fmt.Errorf("kind must be %q or %q", KindBill, KindExpense)
+	}
+	if doc.Source == "" {
+		doc.Source = SourceUpload
+	}
+	if !DocumentTypes[doc.ContentType] {
+		return nil, ErrUnsupportedType
+	}
+	if s.ocr == nil && doc.ContentType != "text/plain" {
+		return nil, ErrUnavailable
+	}
+
+	company, err := s.qb.GetCompanyInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch company info: %w", err)
+	}
+	text := string(doc.Content)
+	if doc.ContentType != "text/plain" {
+		if text, err = s.ocr.Recognize(ctx, doc.Content, doc.ContentType); err != nil {
+			return nil, fmt.Errorf("failed to recognize document: %w", err)
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrNoText
+	}
+
+	// Only US companies write dates month first
+	extraction := Extract(text, company.Country != "" && company.Country != "US")
+	draft := &Draft{
+		ID:          jobs.NewID(),
+		RealmID:     realmID,
+		Kind:        doc.Kind,
+		Status:      StatusPending,
+		Source:      doc.Source,
+		Sender:      doc.Sender,
+		FileName:    doc.FileName,
+		ContentType: doc.ContentType,
+		Size:        len(doc.Content),
+		Text:        text,
+		Extraction:  extraction,
+		CreatedBy:   userID,
+		CreatedAt:   time.Now(),
+	}
+	draft.UpdatedAt = draft.CreatedAt
+	draft.Bill, draft.Warnings = s.prefill(ctx, draft.Kind, extraction, doc.FileName)
+	if draft.IsExpense() {
+		draft.Bill.DueDate = ""
+		draft.Bill.PaymentAccountID = doc.PaymentAccountID
+		if draft.Bill.PaymentAccountID == "" {
+			draft.Warnings = append(draft.Warnings, "Choose the bank or credit card account the expense was paid from")
+		}
+	}
+
+	if err := s.store.SaveDocument(ctx, realmID, draft.ID, doc.Content); err != nil {
+		return nil, err
+	}
+	if err := s.store.Save(ctx, draft); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// prefill drafts a bill or expense from an extraction, with warnings for
+// what the user has to check or complete
+func (s *Service) prefill(ctx context.Context, kind string, ex *Extraction, fileName string) (BillDraft, []string) {
+	bill := BillDraft{
+		VendorName: ex.Vendor,
+		TxnDate:    ex.Date,
+		DueDate:    ex.DueDate,
+		DocNumber:  ex.Reference,
+		Currency:   ex.Currency,
+		Memo:       "Captured from " + fileName,
+		Lines:      []BillLine{},
+	}
+	warnings := []string{}
+
+	if ex.Vendor == "" {
+		warnings = append(warnings, "No vendor was recognized")
+	} else if id, name, err := s.matchVendor(ctx, ex.Vendor); err != nil {
+		warnings = append(warnings, "Vendors could not be matched: "+err.Error())
+	} else if id == "" {
+		warnings = append(warnings, fmt.Sprintf("%q does not match a QuickBooks vendor", ex.Vendor))
+	} else {
+		bill.VendorID, bill.VendorName = id, name
+		bill.AccountID = s.lastAccount(ctx, id)
+	}
+	if bill.TxnDate == "" {
+		warnings = append(warnings, "No date was recognized")
+	}
+	if bill.Currency != "" {
+		warnings = append(warnings, fmt.Sprintf("The document is in %s; check the vendor uses that currency", bill.Currency))
+	}
+
+	var sum float64
+	for _, line := range ex.Lines {
+		sum += line.Amount
+	}
+	itemized := len(ex.Lines) > 0 && (math.Abs(sum-ex.Total) < 0.02 || math.Abs(sum+ex.Tax-ex.Total) < 0.02)
+	switch {
+	case itemized:
+		for _, line := range ex.Lines {
+			description := line.Description
+			if line.Quantity > 0 {
+				description = fmt.Sprintf("%s (%g @ %.2f)", description, line.Quantity, line.UnitPrice)
+			}
+			bill.Lines = append(bill.Lines, BillLine{Description: description, Amount: line.Amount})
+		}
+		if math.Abs(sum-ex.Total) >= 0.02 {
+			bill.Lines = append(bill.Lines, BillLine{Description: "Tax", Amount: ex.Tax})
+		}
+	case ex.Total > 0:
+		description := ex.Vendor
+		if description == "" {
+			description = "Purchase"
+		}
+		bill.Lines = append(bill.Lines, BillLine{Description: description, Amount: ex.Total})
+		if len(ex.Lines) > 0 {
+			warnings = append(warnings, fmt.Sprintf("The recognized lines do not add up to the total, so the %s has a single line", kind))
+		}
+	default:
+		warnings = append(warnings, "No total was recognized")
+	}
+	if bill.AccountID == "" {
+		warnings = append(warnings, fmt.Sprintf("Choose the expense account to book the %s to", kind))
+	}
+	return bill, warnings
+}
+
+// normalizeName reduces a company name to its distinctive lower-case words
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, word := range words {
+		if !companySuffixes[word] {
+			kept = append(kept, word)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// matchVendor finds the active vendor a recognized name refers to: one
+// whose name is the same, or else the longest contained in the other.
+// It returns an empty ID when none matches.
+func (s *Service) matchVendor(ctx context.Context, recognized string) (string, string, error) {
+	vendors, err := query.List[struct {
+		ID          string `json:"Id"`
+		DisplayName string `json:"DisplayName"`
+		CompanyName string `json:"CompanyName"`
+	}](ctx, s.qb, query.Select("Vendor", "Id", "DisplayName", "CompanyName").Where("Active", "=", true).Limit(maxVendors))
+	if err != nil {
+		return "", "", err
+	}
+
+	target := normalizeName(recognized)
+	if len(target) < 3 {
+		return "", "", nil
+	}
+	var bestID, bestName string
+	bestLen := 0
+	for _, vendor := range vendors {
+		for _, name := range []string{vendor.DisplayName, vendor.CompanyName} {
+			candidate := normalizeName(name)
+			if len(candidate) < 3 {
+				continue
+			}
+			if candidate == target {
+				return vendor.ID, vendor.DisplayName, nil
+			}
+			if (strings.Contains(target, candidate) || strings.Contains(candidate, target)) && len(candidate) > bestLen {
+				bestID, bestName, bestLen = vendor.ID, vendor.DisplayName, len(candidate)
+			}
+		}
+	}
+	return bestID, bestName, nil
+}
+
+// lastAccount returns the expense account of the vendor's latest bill, or
+// "" if it has none
+func (s *Service) lastAccount(ctx context.Context, vendorID string) string {
+	if !query.IsID(vendorID) {
+		return ""
+	}
+	bills, err := query.List[struct {
+		Line []struct {
+			AccountBasedExpenseLineDetail struct {
+				AccountRef struct {
+					Value string `json:"value"`
+				} `json:"AccountRef"`
+			} `json:"AccountBasedExpenseLineDetail"`
+		} `json:"Line"`
+	}](ctx, s.qb, query.Select("Bill").Where("VendorRef", "=", vendorID).OrderByDesc("TxnDate").Limit(1))
+	if err != nil || len(bills) == 0 {
+		return ""
+	}
+	for _, line := range bills[0].Line {
+		if account := line.AccountBasedExpenseLineDetail.AccountRef.Value; account != "" {
+			return account
+		}
+	}
+	return ""
+}
+
+// Confirm creates the bill or expense of a pending draft, replacing the
+// draft's bill with the given one if set, and attaches the document to it
+func (s *Service) Confirm(ctx context.Context, realmID, id string, bill *BillDraft) (*Draft, error) {
+	unlock, err := s.locker.Lock(ctx, fmt.Sprintf("%s:intake:confirm:%s", realmID, id))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	draft, err := s.store.Get(ctx, realmID, id)
+	if err != nil {
+		return nil, err
+	}
+	if draft.Status != StatusPending {
+		return nil, ErrAlreadyConfirmed
+	}
+	if bill != nil {
+		draft.Bill = *bill
+	}
+	entity := "Bill"
+	var payload map[string]interface{}
+	if draft.IsExpense() {
+		entity = "Purchase"
+		payload, err = s.expensePayload(ctx, &draft.Bill)
+	} else {
+		payload, err = billPayload(&draft.Bill)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]struct {
+		ID string `json:"Id"`
+	}
+	if err := s.qb.Create(ctx, entity, payload, &result); err != nil {
+		return nil, err
+	}
+	draft.Status = StatusConfirmed
+	if draft.IsExpense() {
+		draft.ExpenseID = result[entity].ID
+	} else {
+		draft.BillID = result[entity].ID
+	}
+	draft.UpdatedAt = time.Now()
+
+	if err := s.attach(ctx, draft, entity, result[entity].ID); err != nil {
+		logging.FromContext(ctx).Warn("failed to attach draft document", "draft_id", draft.ID, "entity", entity, "id", result[entity].ID, "error", err)
+		draft.Warnings = append(draft.Warnings, "The document could not be attached to the "+draft.Kind)
+	}
+	if err := s.store.Save(ctx, draft); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// attach uploads a confirmed draft's document to the entity it became
+func (s *Service) attach(ctx context.Context, draft *Draft, entity, entityID string) error {
+	content, err := s.store.GetDocument(ctx, draft.RealmID, draft.ID)
+	if err != nil {
+		return err
+	}
+	_, err = s.qb.Upload(ctx, &qbclient.Attachable{
+		FileName:    draft.FileName,
+		ContentType: draft.ContentType,
+		AttachableRef: []qbclient.AttachableRef{{
+			EntityRef: qbclient.EntityRef{Type: entity, Value: entityID},
+		}},
+	}, content)
+	return err
+}
+
+// Discard deletes a draft and its document
+func (s *Service) Discard(ctx context.Context, realmID, id string) error {
+	if _, err := s.store.Get(ctx, realmID, id); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, realmID, id)
+}
+
+// linesPayload validates a draft's lines and builds its QuickBooks lines
+func linesPayload(bill *BillDraft) ([]map[string]interface{}, error) {
+	if len(bill.Lines) == 0 {
+		return nil, fmt.Errorf("at least one line is required")
+	}
+
+	lines := make([]map[string]interface{}, 0, len(bill.Lines))
+	for i, line := range bill.Lines {
+		account := line.AccountID
+		if account == "" {
+			account = bill.AccountID
+		}
+		if account == "" {
+			return nil, fmt.Errorf("line %d: account_id is required", i+1)
+		}
+		if line.Amount <= 0 {
+			return nil, fmt.Errorf("line %d: amount must be positive", i+1)
+		}
+		lines = append(lines, map[string]interface{}{
+			"Amount":      math.Round(line.Amount*100) / 100,
+			"Description": line.Description,
+			"DetailType":  "AccountBasedExpenseLineDetail",
+			"AccountBasedExpenseLineDetail": map[string]interface{}{
+				"AccountRef": map[string]string{"value": account},
+			},
+		})
+	}
+	return lines, nil
+}
+
+// commonFields sets the optional fields bills and expenses share
+func commonFields(payload map[string]interface{}, bill *BillDraft) {
+	optional := map[string]string{
+		"TxnDate":     bill.TxnDate,
+		"DocNumber":   bill.DocNumber,
+		"PrivateNote": bill.Memo,
+	}
+	for field, value := range optional {
+		if value != "" {
+			payload[field] = value
+		}
+	}
+	if bill.Currency != "" {
+		payload["CurrencyRef"] = map[string]string{"value": bill.Currency}
+	}
+}
+
+// validDates checks a draft's dates are YYYY-MM-DD
+func validDates(dates ...string) error {
+	for _, date := range dates {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+	return nil
+}
+
+// billPayload validates a bill draft and builds the QuickBooks bill
+func billPayload(bill *BillDraft) (map[string]interface{}, error) {
+	if !query.IsID(bill.VendorID) {
+		return nil, fmt.Errorf("vendor_id is required")
+	}
+	if err := validDates(bill.TxnDate, bill.DueDate); err != nil {
+		return nil, err
+	}
+	lines, err := linesPayload(bill)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"VendorRef": map[string]string{"value": bill.VendorID},
+		"Line":      lines,
+	}
+	if bill.DueDate != "" {
+		payload["DueDate"] = bill.DueDate
+	}
+	commonFields(payload, bill)
+	return payload, nil
+}
+
+// expensePayload validates an expense draft and builds the QuickBooks
+// purchase, paid by card or in cash depending on the payment account
+func (s *Service) expensePayload(ctx context.Context, bill *BillDraft) (map[string]interface{}, error) {
+	if !query.IsID(bill.PaymentAccountID) {
+		return nil, fmt.Errorf("payment_account_id is required")
+	}
+	if bill.VendorID != "" && !query.IsID(bill.VendorID) {
+		return nil, fmt.Errorf("invalid vendor_id")
+	}
+	if err := validDates(bill.TxnDate); err != nil {
+		return nil, err
+	}
+	lines, err := linesPayload(bill)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := query.List[struct {
+		AccountType string `json:"AccountType"`
+	}](ctx, s.qb, query.Select("Account", "AccountType").Where("Id", "=", bill.PaymentAccountID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up payment account: %w", err)
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("payment account %s not found", bill.PaymentAccountID)
+	}
+	var paymentType string
+	switch accounts[0].AccountType {
+	case "Bank":
+		paymentType = "Cash"
+	case "Credit Card":
+		paymentType = "CreditCard"
+	default:
+		return nil, fmt.Errorf("payment account must be a bank or credit card account")
+	}
+
+	payload := map[string]interface{}{
+		"PaymentType": paymentType,
+		"AccountRef":  map[string]string{"value": bill.PaymentAccountID},
+		"Line":        lines,
+	}
+	if bill.VendorID != "" {
+		payload["EntityRef"] = map[string]string{"value": bill.VendorID, "type": "Vendor"}
+	}
+	commonFields(payload, bill)
+	return payload, nil
+}
@@ -0,0 +1,213 @@
+// inventory/handler.go
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/notify"
+	"github.com/gorilla/mux"
+)
+
+// Servicer checks stock against reorder points. *Service implements it;
+// tests can substitute a fake.
+type Servicer interface {
+	Store() *Store
+	GetBill(ctx context.Context, id string) (*Bill, error)
+	LowStock(ctx context.Context, realmID string) ([]LowStockItem, error)
+	Suggestions(ctx context.Context, realmID string) ([]VendorSuggestion, error)
+	Monitor(ctx context.Context, realmID string, destinations []notify.Destination) (*jobs.Job, error)
+}
+
+// Handler provides HTTP handlers for inventory management
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new inventory handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListReorderPoints returns the current company's local reorder points
+func (h *Handler) ListReorderPoints(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	points, err := h.service.Store().List(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list reorder points: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]ReorderPoint, 0, len(points))
+	for _, p := range points {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ItemID < list[j].ItemID
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reorder_points": list,
+	})
+}
+
+// SaveReorderPoints creates or replaces reorder points for the given items
+func (h *Handler) SaveReorderPoints(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ReorderPoints []ReorderPoint `json:"reorder_points"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Store().Save(r.Context(), realmID, req.ReorderPoints); err != nil {
+		http.Error(w, "Failed to save reorder points: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"saved": len(req.ReorderPoints),
+	})
+}
+
+// DeleteReorderPoint removes an item's local reorder point
+func (h *Handler) DeleteReorderPoint(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Store().Delete(r.Context(), realmID, mux.Vars(r)["itemId"]); err != nil {
+		if errors.Is(err, ErrReorderPointNotFound) {
+			http.Error(w, "Reorder point not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete reorder point: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LowStockReport lists items at or below their reorder point
+func (h *Handler) LowStockReport(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	low, err := h.service.LowStock(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to check stock levels: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": low,
+	})
+}
+
+// ReorderSuggestions groups low-stock items by preferred vendor
+func (h *Handler) ReorderSuggestions(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	suggestions, err := h.service.Suggestions(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to build reorder suggestions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"vendors": suggestions,
+	})
+}
+
+// MonitorStock enables daily low-stock alerts for the current company
+func (h *Handler) MonitorStock(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Notifications []notify.Destination `json:"notifications"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.service.Monitor(r.Context(), realmID, req.Notifications)
+	if err != nil {
+		http.Error(w, "Failed to enable low-stock alerts: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+// AllocateLandedCost spreads a bill's freight and duty lines across its item
+// lines and returns the adjusted bill for review
+func (h *Handler) AllocateLandedCost(w http.ResponseWriter, r *http.Request) {
+	var req LandedCostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	bill, err := h.service.GetBill(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, ErrBillNotFound) {
+			http.Error(w, "Bill not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get bill: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := AllocateLandedCost(*bill, req)
+	if err != nil {
+		http.Error(w, "Failed to allocate landed cost: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,274 @@
+// inventory/landed_cost.go
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Landed cost allocation methods
+const (
+	AllocateByValue    = "value"
+	AllocateByQuantity = "quantity"
+	AllocateByWeight   = "weight"
+)
+
+// ErrBillNotFound is returned when the bill to allocate does not exist
+var ErrBillNotFound = errors.New("bill not found")
+
+// Ref is a QuickBooks entity reference
+type Ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+// ItemLineDetail is the detail of an item-based bill line
+type ItemLineDetail struct {
+	ItemRef        Ref     `json:"ItemRef"`
+	Qty            float64 `json:"Qty,omitempty"`
+	UnitPrice      float64 `json:"UnitPrice,omitempty"`
+	BillableStatus string  `json:"BillableStatus,omitempty"`
+	CustomerRef    *Ref    `json:"CustomerRef,omitempty"`
+	ClassRef       *Ref    `json:"ClassRef,omitempty"`
+}
+
+// AccountLineDetail is the detail of an account-based bill line
+type AccountLineDetail struct {
+	AccountRef     Ref    `json:"AccountRef"`
+	BillableStatus string `json:"BillableStatus,omitempty"`
+	CustomerRef    *Ref   `json:"CustomerRef,omitempty"`
+	ClassRef       *Ref   `json:"ClassRef,omitempty"`
+}
+
+// BillLine is a line of a QuickBooks bill
+type BillLine struct {
+	ID                            string             `json:"Id,omitempty"`
+	LineNum                       int                `json:"LineNum,omitempty"`
+	Description                   string             `json:"Description,omitempty"`
+	Amount                        float64            `json:"Amount"`
+	DetailType                    string             `json:"DetailType"`
+	ItemBasedExpenseLineDetail    *ItemLineDetail    `json:"ItemBasedExpenseLineDetail,omitempty"`
+	AccountBasedExpenseLineDetail *AccountLineDetail `json:"AccountBasedExpenseLineDetail,omitempty"`
+}
+
+// Bill is the subset of QuickBooks bill fields carried through allocation
+type Bill struct {
+	ID           string     `json:"Id"`
+	SyncToken    string     `json:"SyncToken"`
+	VendorRef    Ref        `json:"VendorRef"`
+	APAccountRef *Ref       `json:"APAccountRef,omitempty"`
+	DocNumber    string     `json:"DocNumber,omitempty"`
+	TxnDate      string     `json:"TxnDate,omitempty"`
+	DueDate      string     `json:"DueDate,omitempty"`
+	PrivateNote  string     `json:"PrivateNote,omitempty"`
+	TotalAmt     float64    `json:"TotalAmt"`
+	Line         []BillLine `json:"Line"`
+}
+
+// LandedCostRequest describes how to allocate a bill's freight and duty lines
+type LandedCostRequest struct {
+	Method             string             `json:"method"`                    // value, quantity or weight
+	CostAccountIDs     []string           `json:"cost_account_ids"`          // accounts of lines to allocate, e.g. freight and duty
+	CostLineIDs        []string           `json:"cost_line_ids,omitempty"`   // individual lines to allocate
+	Weights            map[string]float64 `json:"weights,omitempty"`         // unit weight per item ID, for the weight method
+	InventoryEntry     bool               `json:"inventory_entry,omitempty"` // also produce an inventory adjustment entry
+	InventoryAccountID string             `json:"inventory_account_id,omitempty"`
+}
+
+// Allocation is the landed cost added to one item line
+type Allocation struct {
+	LineID        string  `json:"line_id,omitempty"`
+	ItemID        string  `json:"item_id"`
+	ItemName      string  `json:"item_name,omitempty"`
+	Basis         float64 `json:"basis"`
+	Share         float64 `json:"share"`
+	LandedCost    float64 `json:"landed_cost"`
+	OriginalCost  float64 `json:"original_amount"`
+	AdjustedCost  float64 `json:"adjusted_amount"`
+	AdjustedPrice float64 `json:"adjusted_unit_price,omitempty"`
+}
+
+// JournalLine is one side of a proposed adjustment entry
+type JournalLine struct {
+	AccountID   string  `json:"account_id"`
+	Posting     string  `json:"posting"` // Debit or Credit
+	Amount      float64 `json:"amount"`
+	Description string  `json:"description"`
+}
+
+// LandedCostResult is the adjusted bill and supporting detail. The bill is
+// returned for review and is not written back to QuickBooks.
+type LandedCostResult struct {
+	Method         string        `json:"method"`
+	AllocatedTotal float64       `json:"allocated_total"`
+	Allocations    []Allocation  `json:"allocations"`
+	AdjustedBill   Bill          `json:"adjusted_bill"`
+	InventoryEntry []JournalLine `json:"inventory_entry,omitempty"`
+}
+
+// GetBill retrieves a bill by ID
+func (s *Service) GetBill(ctx context.Context, id string) (*Bill, error) {
+	if !query.IsID(id) {
+		return nil, ErrBillNotFound
+	}
+
+	bills, err := query.List[Bill](ctx, s.querier, query.Select("Bill").Where("Id", "=", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(bills) == 0 {
+		return nil, ErrBillNotFound
+	}
+	return &bills[0], nil
+}
+
+// AllocateLandedCost spreads a bill's cost lines across its item lines
+func AllocateLandedCost(bill Bill, req LandedCostRequest) (*LandedCostResult, error) {
+	if req.Method == "" {
+		req.Method = AllocateByValue
+	}
+	if req.Method != AllocateByValue && req.Method != AllocateByQuantity && req.Method != AllocateByWeight {
+		return nil, fmt.Errorf("unsupported allocation method %q", req.Method)
+	}
+	if len(req.CostAccountIDs) == 0 && len(req.CostLineIDs) == 0 {
+		return nil, fmt.Errorf("cost_account_ids or cost_line_ids is required")
+	}
+	if req.InventoryEntry && req.InventoryAccountID == "" {
+		return nil, fmt.Errorf("inventory_account_id is required for an inventory entry")
+	}
+
+	isCostLine := func(line BillLine) bool {
+		for _, id := range req.CostLineIDs {
+			if line.ID == id {
+				return true
+			}
+		}
+		if line.AccountBasedExpenseLineDetail == nil {
+			return false
+		}
+		for _, id := range req.CostAccountIDs {
+			if line.AccountBasedExpenseLineDetail.AccountRef.Value == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	var costLines []BillLine
+	var itemIdx []int
+	adjusted := bill
+	adjusted.Line = nil
+	for _, line := range bill.Line {
+		if line.DetailType == "AccountBasedExpenseLineDetail" && isCostLine(line) {
+			costLines = append(costLines, line)
+			continue
+		}
+		if line.ItemBasedExpenseLineDetail != nil {
+			itemIdx = append(itemIdx, len(adjusted.Line))
+		}
+		adjusted.Line = append(adjusted.Line, line)
+	}
+	if len(costLines) == 0 {
+		return nil, fmt.Errorf("bill has no matching cost lines")
+	}
+	if len(itemIdx) == 0 {
+		return nil, fmt.Errorf("bill has no item lines to allocate to")
+	}
+
+	total := 0.0
+	for _, line := range costLines {
+		total += line.Amount
+	}
+
+	// Bases per item line under the chosen method
+	bases := make([]float64, len(itemIdx))
+	basisTotal := 0.0
+	for i, idx := range itemIdx {
+		line := adjusted.Line[idx]
+		detail := line.ItemBasedExpenseLineDetail
+		switch req.Method {
+		case AllocateByValue:
+			bases[i] = line.Amount
+		case AllocateByQuantity:
+			bases[i] = detail.Qty
+		case AllocateByWeight:
+			weight, ok := req.Weights[detail.ItemRef.Value]
+			if !ok {
+				return nil, fmt.Errorf("no weight given for item %s", detail.ItemRef.Value)
+			}
+			bases[i] = detail.Qty * weight
+		}
+		basisTotal += bases[i]
+	}
+	if basisTotal <= 0 {
+		return nil, fmt.Errorf("item lines have no %s to allocate by", req.Method)
+	}
+
+	// Allocate in cents, giving the rounding remainder to the largest line
+	totalCents := int64(math.Round(total * 100))
+	shares := make([]int64, len(itemIdx))
+	allocated, largest := int64(0), 0
+	for i := range itemIdx {
+		shares[i] = int64(math.Floor(float64(totalCents) * bases[i] / basisTotal))
+		allocated += shares[i]
+		if bases[i] > bases[largest] {
+			largest = i
+		}
+	}
+	shares[largest] += totalCents - allocated
+
+	result := &LandedCostResult{
+		Method:         req.Method,
+		AllocatedTotal: float64(totalCents) / 100,
+	}
+	for i, idx := range itemIdx {
+		line := &adjusted.Line[idx]
+		detail := *line.ItemBasedExpenseLineDetail
+		landed := float64(shares[i]) / 100
+
+		allocation := Allocation{
+			LineID:       line.ID,
+			ItemID:       detail.ItemRef.Value,
+			ItemName:     detail.ItemRef.Name,
+			Basis:        bases[i],
+			Share:        math.Round(bases[i]/basisTotal*10000) / 10000,
+			LandedCost:   landed,
+			OriginalCost: line.Amount,
+			AdjustedCost: math.Round((line.Amount+landed)*100) / 100,
+		}
+
+		line.Amount = allocation.AdjustedCost
+		if detail.Qty > 0 {
+			detail.UnitPrice = math.Round(line.Amount/detail.Qty*1e6) / 1e6
+			allocation.AdjustedPrice = detail.UnitPrice
+		}
+		line.ItemBasedExpenseLineDetail = &detail
+
+		result.Allocations = append(result.Allocations, allocation)
+	}
+	result.AdjustedBill = adjusted
+
+	// Moving cost from the freight and duty accounts into inventory
+	if req.InventoryEntry {
+		result.InventoryEntry = append(result.InventoryEntry, JournalLine{
+			AccountID:   req.InventoryAccountID,
+			Posting:     "Debit",
+			Amount:      result.AllocatedTotal,
+			Description: "Landed cost capitalized from bill " + bill.DocNumber,
+		})
+		for _, line := range costLines {
+			result.InventoryEntry = append(result.InventoryEntry, JournalLine{
+				AccountID:   line.AccountBasedExpenseLineDetail.AccountRef.Value,
+				Posting:     "Credit",
+				Amount:      line.Amount,
+				Description: "Landed cost reclassified to inventory",
+			})
+		}
+	}
+
+	return result, nil
+}
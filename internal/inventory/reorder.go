@@ -0,0 +1,267 @@
+// inventory/reorder.go
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrReorderPointNotFound is returned when an item has no local reorder point
+var ErrReorderPointNotFound = errors.New("reorder point not found")
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// ReorderPoint is a locally configured stock threshold for an inventory item
+type ReorderPoint struct {
+	ItemID       string  `json:"item_id"`
+	ReorderPoint float64 `json:"reorder_point"`
+	ReorderQty   float64 `json:"reorder_qty,omitempty"` // defaults to restoring twice the reorder point
+	VendorID     string  `json:"vendor_id,omitempty"`   // overrides the item's preferred vendor
+}
+
+// LowStockItem is an inventory item at or below its reorder point
+type LowStockItem struct {
+	ItemID       string  `json:"item_id"`
+	Name         string  `json:"name"`
+	SKU          string  `json:"sku,omitempty"`
+	QtyOnHand    float64 `json:"qty_on_hand"`
+	ReorderPoint float64 `json:"reorder_point"`
+	SuggestedQty float64 `json:"suggested_qty"`
+	UnitCost     float64 `json:"unit_cost"`
+	VendorID     string  `json:"vendor_id,omitempty"`
+	VendorName   string  `json:"vendor_name,omitempty"`
+}
+
+// VendorSuggestion groups items to reorder from one vendor
+type VendorSuggestion struct {
+	VendorID      string         `json:"vendor_id,omitempty"`
+	VendorName    string         `json:"vendor_name"`
+	Items         []LowStockItem `json:"items"`
+	EstimatedCost float64        `json:"estimated_cost"`
+}
+
+// inventoryItem is the subset of item fields used for reorder checks
+type inventoryItem struct {
+	ID            string  `json:"Id"`
+	Name          string  `json:"Name"`
+	Sku           string  `json:"Sku"`
+	Active        bool    `json:"Active"`
+	QtyOnHand     float64 `json:"QtyOnHand"`
+	ReorderPoint  float64 `json:"ReorderPoint"`
+	PurchaseCost  float64 `json:"PurchaseCost"`
+	PrefVendorRef struct {
+		Value string `json:"value"`
+		Name  string `json:"name"`
+	} `json:"PrefVendorRef"`
+}
+
+// Store persists reorder points and alert state per realm
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new reorder point store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// pointsKey is the hash of item ID to reorder point for a realm
+func (s *Store) pointsKey(realmID string) string {
+	return fmt.Sprintf("%s:inventory:reorder:%s", s.prefix, realmID)
+}
+
+// alertedKey is the set of item IDs already alerted as low for a realm
+func (s *Store) alertedKey(realmID string) string {
+	return fmt.Sprintf("%s:inventory:alerted:%s", s.prefix, realmID)
+}
+
+// Save stores reorder points, replacing any existing ones for the same items
+func (s *Store) Save(ctx context.Context, realmID string, points []ReorderPoint) error {
+	values := make([]interface{}, 0, len(points)*2)
+	for _, p := range points {
+		if p.ItemID == "" {
+			return fmt.Errorf("item_id is required")
+		}
+		if p.ReorderPoint < 0 || p.ReorderQty < 0 {
+			return fmt.Errorf("item %s: quantities must not be negative", p.ItemID)
+		}
+		data, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("failed to marshal reorder point: %w", err)
+		}
+		values = append(values, p.ItemID, data)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	if err := s.client.HSet(ctx, s.pointsKey(realmID), values...).Err(); err != nil {
+		return fmt.Errorf("failed to save reorder points: %w", err)
+	}
+	return nil
+}
+
+// List returns a realm's reorder points keyed by item ID
+func (s *Store) List(ctx context.Context, realmID string) (map[string]ReorderPoint, error) {
+	values, err := s.client.HGetAll(ctx, s.pointsKey(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reorder points: %w", err)
+	}
+
+	points := make(map[string]ReorderPoint, len(values))
+	for id, v := range values {
+		var p ReorderPoint
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			continue
+		}
+		points[id] = p
+	}
+
+	return points, nil
+}
+
+// Delete removes an item's reorder point
+func (s *Store) Delete(ctx context.Context, realmID, itemID string) error {
+	removed, err := s.client.HDel(ctx, s.pointsKey(realmID), itemID).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete reorder point: %w", err)
+	}
+	if removed == 0 {
+		return ErrReorderPointNotFound
+	}
+	return nil
+}
+
+// MarkAlerted records the realm's currently low items and returns the ones
+// that were not low at the last check
+func (s *Store) MarkAlerted(ctx context.Context, realmID string, low []LowStockItem) ([]LowStockItem, error) {
+	key := s.alertedKey(realmID)
+	previous, err := s.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alerted items: %w", err)
+	}
+	alerted := make(map[string]bool, len(previous))
+	for _, id := range previous {
+		alerted[id] = true
+	}
+
+	var fresh []LowStockItem
+	members := make([]interface{}, 0, len(low))
+	for _, item := range low {
+		if !alerted[item.ItemID] {
+			fresh = append(fresh, item)
+		}
+		members = append(members, item.ItemID)
+	}
+
+	// Restocked items drop out of the set so they alert again next time they run low
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(members) > 0 {
+		pipe.SAdd(ctx, key, members...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to save alerted items: %w", err)
+	}
+
+	return fresh, nil
+}
+
+// LowStock returns active inventory items at or below their reorder point.
+// Local reorder points take precedence over the item's QuickBooks ReorderPoint.
+func LowStock(ctx context.Context, querier Querier, points map[string]ReorderPoint) ([]LowStockItem, error) {
+	items, err := query.All[inventoryItem](ctx, querier, query.Select("Item").Where("Type", "=", "Inventory"))
+	if err != nil {
+		return nil, err
+	}
+
+	var low []LowStockItem
+	for _, item := range items {
+		if !item.Active {
+			continue
+		}
+
+		threshold := item.ReorderPoint
+		reorderQty := 0.0
+		vendorID, vendorName := item.PrefVendorRef.Value, item.PrefVendorRef.Name
+		point, configured := points[item.ID]
+		if configured {
+			threshold = point.ReorderPoint
+			reorderQty = point.ReorderQty
+			if point.VendorID != "" && point.VendorID != vendorID {
+				vendorID, vendorName = point.VendorID, ""
+			}
+		}
+		if (!configured && threshold <= 0) || item.QtyOnHand > threshold {
+			continue
+		}
+
+		suggested := reorderQty
+		if suggested == 0 {
+			suggested = math.Max(threshold*2-item.QtyOnHand, 1)
+		}
+
+		low = append(low, LowStockItem{
+			ItemID:       item.ID,
+			Name:         item.Name,
+			SKU:          item.Sku,
+			QtyOnHand:    item.QtyOnHand,
+			ReorderPoint: threshold,
+			SuggestedQty: math.Ceil(suggested),
+			UnitCost:     item.PurchaseCost,
+			VendorID:     vendorID,
+			VendorName:   vendorName,
+		})
+	}
+
+	return low, nil
+}
+
+// GroupByVendor groups low stock items into per-vendor reorder suggestions
+func GroupByVendor(low []LowStockItem) []VendorSuggestion {
+	groups := map[string]*VendorSuggestion{}
+	var order []string
+	for _, item := range low {
+		group, ok := groups[item.VendorID]
+		if !ok {
+			name := item.VendorName
+			if item.VendorID == "" {
+				name = "No preferred vendor"
+			}
+			group = &VendorSuggestion{VendorID: item.VendorID, VendorName: name}
+			groups[item.VendorID] = group
+			order = append(order, item.VendorID)
+		}
+		if group.VendorName == "" {
+			group.VendorName = item.VendorName
+		}
+		group.Items = append(group.Items, item)
+		group.EstimatedCost += item.SuggestedQty * item.UnitCost
+	}
+
+	suggestions := make([]VendorSuggestion, 0, len(order))
+	for _, id := range order {
+		group := groups[id]
+		group.EstimatedCost = math.Round(group.EstimatedCost*100) / 100
+		suggestions = append(suggestions, *group)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].EstimatedCost > suggestions[j].EstimatedCost
+	})
+
+	return suggestions
+}
@@ -0,0 +1,155 @@
+// inventory/service.go
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/notify"
+)
+
+// ReorderCheckJobType identifies recurring low-stock checks in the jobs subsystem
+const ReorderCheckJobType = "inventory_reorder_check"
+
+// checkPayload is the job payload for a realm's low-stock check
+type checkPayload struct {
+	RealmID       string               `json:"realm_id"`
+	Notifications []notify.Destination `json:"notifications,omitempty"`
+}
+
+// Service manages reorder points and low-stock alerts
+type Service struct {
+	querier   Querier
+	store     *Store
+	scheduler *jobs.Scheduler
+	notifier  *notify.Dispatcher
+}
+
+// NewService creates a new inventory service and registers its job runner
+func NewService(querier Querier, store *Store, scheduler *jobs.Scheduler, notifier *notify.Dispatcher) *Service {
+	service := &Service{
+		querier:   querier,
+		store:     store,
+		scheduler: scheduler,
+		notifier:  notifier,
+	}
+	scheduler.RegisterRunner(ReorderCheckJobType, jobs.RunnerFunc(service.runCheck))
+	return service
+}
+
+// Store returns the reorder point store
+func (s *Service) Store() *Store {
+	return s.store
+}
+
+// LowStock returns the realm's items at or below their reorder point
+func (s *Service) LowStock(ctx context.Context, realmID string) ([]LowStockItem, error) {
+	points, err := s.store.List(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	return LowStock(ctx, s.querier, points)
+}
+
+// Suggestions returns reorder suggestions grouped by preferred vendor
+func (s *Service) Suggestions(ctx context.Context, realmID string) ([]VendorSuggestion, error) {
+	low, err := s.LowStock(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	return GroupByVendor(low), nil
+}
+
+// Monitor schedules a daily low-stock check for the realm, replacing any existing one
+func (s *Service) Monitor(ctx context.Context, realmID string, destinations []notify.Destination) (*jobs.Job, error) {
+	if len(destinations) == 0 {
+		return nil, fmt.Errorf("at least one notification destination is required")
+	}
+	for _, dest := range destinations {
+		if err := s.notifier.Validate(dest); err != nil {
+			return nil, err
+		}
+	}
+
+	tenantID := auth.GetTenantID(ctx)
+	existing, err := s.scheduler.Store().ListByTenant(ctx, tenantID, ReorderCheckJobType)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range existing {
+		var payload checkPayload
+		if json.Unmarshal(job.Payload, &payload) == nil && payload.RealmID == realmID && job.Status == jobs.StatusActive {
+			if _, err := s.scheduler.Cancel(ctx, job.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	job, err := jobs.NewJob(ReorderCheckJobType, tenantID, auth.GetUserID(ctx), checkPayload{
+		RealmID:       realmID,
+		Notifications: destinations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reorder check job: %w", err)
+	}
+	job.Schedule = (&jobs.Schedule{Frequency: jobs.FrequencyDaily, Hour: 7}).Localize(ctx)
+	job.NextRunAt = job.Schedule.Next(time.Now())
+
+	if err := s.scheduler.Store().Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// runCheck is the job runner for scheduled low-stock checks
+func (s *Service) runCheck(ctx context.Context, job *jobs.Job) error {
+	var payload checkPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode reorder check job: %w", err)
+	}
+
+	ctx = auth.WithIdentity(ctx, job.UserID, job.TenantID, payload.RealmID)
+
+	low, err := s.LowStock(ctx, payload.RealmID)
+	if err != nil {
+		return err
+	}
+	fresh, err := s.store.MarkAlerted(ctx, payload.RealmID, low)
+	if err != nil {
+		return err
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	for _, item := range fresh {
+		fmt.Fprintf(&body, "%s: %g on hand (reorder point %g), suggest ordering %g", item.Name, item.QtyOnHand, item.ReorderPoint, item.SuggestedQty)
+		if item.VendorName != "" {
+			fmt.Fprintf(&body, " from %s", item.VendorName)
+		}
+		body.WriteString("\n")
+	}
+	msg := notify.Message{
+		Subject: fmt.Sprintf("%d inventory items are low on stock", len(fresh)),
+		Body:    body.String(),
+	}
+
+	var failed []string
+	for _, dest := range payload.Notifications {
+		if err := s.notifier.Send(ctx, dest.Channel, dest.Target, msg); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to deliver low-stock alerts: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
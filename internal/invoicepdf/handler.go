@@ -0,0 +1,50 @@
+// invoicepdf/handler.go
+package invoicepdf
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for invoice PDFs
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new invoice PDF handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a rendering error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrInvoiceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnknownTemplate):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrRendererUnavailable):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// GetPDF returns an invoice as PDF: QuickBooks' own rendering by default,
+// or with ?template=custom the tenant's branded template
+func (h *Handler) GetPDF(w http.ResponseWriter, r *http.Request) {
+	data, fileName, err := h.service.Render(r.Context(), auth.GetTenantID(r.Context()), mux.Vars(r)["id"], r.URL.Query().Get("template"))
+	if err != nil {
+		http.Error(w, "Failed to render invoice: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="`+fileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
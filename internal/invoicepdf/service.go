@@ -0,0 +1,171 @@
+// invoicepdf/service.go
+package invoicepdf
+
+import (
+	"bytes"
+	"context"
+	_ "embed" // built-in template
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/payqr"
+	"github.com/eGGnogSC/qbserver/internal/pdf"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Templates
+const (
+	// TemplateQuickBooks is QuickBooks' own rendering
+	TemplateQuickBooks = "quickbooks"
+	// TemplateCustom renders the tenant's template, or the built-in one,
+	// with the tenant's branding
+	TemplateCustom = "custom"
+)
+
+var (
+	// ErrInvoiceNotFound is returned when the invoice does not exist
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrUnknownTemplate is returned for templates other than quickbooks or
+	// custom
+	ErrUnknownTemplate = errors.New("template must be quickbooks or custom")
+	// ErrRendererUnavailable is returned for custom renderings when no HTML
+	// converter is configured
+	ErrRendererUnavailable = errors.New("custom invoice PDFs are not enabled")
+)
+
+// paymentCodeScale is the pixels per module of payment QR codes on
+// invoices, enough to stay sharp when printed
+const paymentCodeScale = 6
+
+//go:embed templates/invoice.html
+var builtinSource string
+
+// builtin is the template of tenants without their own
+var builtin = template.Must(template.New("invoice").Parse(builtinSource))
+
+// QuickBooks reads invoices, the company and QuickBooks' own PDFs
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	GetCompanyInfo(ctx context.Context) (*qbclient.CompanyInfo, error)
+	PDF(ctx context.Context, entity, id string) ([]byte, error)
+}
+
+// Converter renders HTML as PDF
+type Converter interface {
+	Convert(ctx context.Context, html []byte, size pdf.Size) ([]byte, error)
+}
+
+// Service renders invoice PDFs
+type Service struct {
+	qb        QuickBooks
+	branding  *branding.Store
+	payments  *payqr.Service
+	converter Converter
+}
+
+// NewService creates a new invoice PDF service. Without a converter only
+// QuickBooks' own PDFs are available.
+func NewService(qb QuickBooks, branding *branding.Store, payments *payqr.Service, converter Converter) *Service {
+	return &Service{
+		qb:        qb,
+		branding:  branding,
+		payments:  payments,
+		converter: converter,
+	}
+}
+
+// Render returns an invoice as PDF with the given template, and the file
+// name to serve it under
+func (s *Service) Render(ctx context.Context, tenantID, invoiceID, name string) ([]byte, string, error) {
+	if !query.IsID(invoiceID) {
+		return nil, "", ErrInvoiceNotFound
+	}
+	switch name {
+	case "", TemplateQuickBooks:
+		data, err := s.qb.PDF(ctx, "Invoice", invoiceID)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, "invoice-" + invoiceID + ".pdf", nil
+	case TemplateCustom:
+		if s.converter == nil {
+			return nil, "", ErrRendererUnavailable
+		}
+	default:
+		return nil, "", ErrUnknownTemplate
+	}
+
+	invoices, err := query.List[qbInvoice](ctx, s.qb, query.Select("Invoice").Where("Id", "=", invoiceID))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(invoices) == 0 {
+		return nil, "", ErrInvoiceNotFound
+	}
+	invoice := &invoices[0]
+	company, err := s.qb.GetCompanyInfo(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch company info: %w", err)
+	}
+	brand, err := s.branding.Get(ctx, tenantID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	view := buildView(ctx, invoice, company, brand)
+	if view.PaymentCode, err = s.paymentCode(ctx, tenantID, invoice); err != nil {
+		return nil, "", err
+	}
+	html, err := s.html(view, brand)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := s.converter.Convert(ctx, html, pdf.SizeFor(company.Country))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to render invoice: %w", err)
+	}
+	return data, "invoice-" + invoice.number() + ".pdf", nil
+}
+
+// paymentCode returns the invoice's payment QR code as a data URI, or ""
+// for tenants without payment QR codes and invoices they do not apply to
+func (s *Service) paymentCode(ctx context.Context, tenantID string, invoice *qbInvoice) (template.URL, error) {
+	currency := invoice.CurrencyRef.Value
+	if currency == "" {
+		currency = "USD"
+	}
+	code, err := s.payments.Code(ctx, tenantID, &payqr.Invoice{
+		ID:         invoice.ID,
+		DocNumber:  invoice.DocNumber,
+		CustomerID: invoice.CustomerRef.Value,
+		Currency:   currency,
+		Balance:    invoice.Balance,
+	}, paymentCodeScale)
+	if errors.Is(err, payqr.ErrDisabled) || errors.Is(err, payqr.ErrNothingDue) || errors.Is(err, payqr.ErrUnsupportedCurrency) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to render payment QR code: %w", err)
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(code)), nil
+}
+
+// html executes the tenant's template, or the built-in one, over a view
+func (s *Service) html(view *View, brand *branding.Branding) ([]byte, error) {
+	tmpl := builtin
+	if brand.InvoiceTemplate != "" {
+		var err error
+		if tmpl, err = template.New("invoice").Parse(brand.InvoiceTemplate); err != nil {
+			return nil, fmt.Errorf("invalid invoice template: %w", err)
+		}
+	}
+	var html bytes.Buffer
+	if err := tmpl.Execute(&html, view); err != nil {
+		return nil, fmt.Errorf("failed to execute invoice template: %w", err)
+	}
+	return html.Bytes(), nil
+}
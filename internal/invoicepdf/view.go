@@ -0,0 +1,241 @@
+// invoicepdf/view.go
+package invoicepdf
+
+import (
+	"context"
+	"encoding/base64"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/i18n"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// labels are the catalog keys, under "invoice.", of the template's labels
+var labels = []string{
+	"title", "number", "date", "due_date", "terms", "bill_to", "ship_to", "item", "description",
+	"quantity", "rate", "amount", "subtotal", "discount", "tax", "total", "paid", "balance", "notes",
+	"scan_to_pay",
+}
+
+// ViewLine is a line of the invoice as printed
+type ViewLine struct {
+	Item        string
+	Description string
+	Quantity    string
+	Rate        string
+	Amount      string
+}
+
+// View is what invoice templates render: the invoice with its amounts and
+// dates formatted for the request's locale, the tenant's branding and the
+// labels in the locale's language. Optional amounts are "" when zero.
+type View struct {
+	Labels       map[string]string
+	Number       string
+	Date         string
+	DueDate      string
+	Terms        string
+	Company      string
+	CompanyLines []string
+	Logo         template.URL // data URI
+	Accent       template.CSS
+	Customer     string
+	BillTo       []string
+	ShipTo       []string
+	Lines        []ViewLine
+	Subtotal     string
+	Discount     string
+	Tax          string
+	Total        string
+	Paid         string
+	Balance      string
+	Memo         string
+	// PaymentCode is the data URI of the tenant's payment QR code, "" for
+	// tenants without one or settled invoices
+	PaymentCode template.URL
+	Footer      string
+}
+
+// qbRef is a QuickBooks entity reference
+type qbRef struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+// qbAddress is a QuickBooks physical address
+type qbAddress struct {
+	Line1                  string `json:"Line1"`
+	Line2                  string `json:"Line2"`
+	Line3                  string `json:"Line3"`
+	City                   string `json:"City"`
+	CountrySubDivisionCode string `json:"CountrySubDivisionCode"`
+	PostalCode             string `json:"PostalCode"`
+	Country                string `json:"Country"`
+}
+
+// lines returns the address as printed lines, skipping blank parts
+func (a qbAddress) lines() []string {
+	var lines []string
+	for _, line := range []string{a.Line1, a.Line2, a.Line3} {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	region := strings.TrimSpace(a.CountrySubDivisionCode + " " + a.PostalCode)
+	switch {
+	case a.City != "" && region != "":
+		lines = append(lines, a.City+", "+region)
+	case a.City != "" || region != "":
+		lines = append(lines, a.City+region)
+	}
+	if country := strings.TrimSpace(a.Country); country != "" {
+		lines = append(lines, country)
+	}
+	return lines
+}
+
+// qbLine is an invoice line; DetailType names which detail is set
+type qbLine struct {
+	Description         string  `json:"Description"`
+	Amount              float64 `json:"Amount"`
+	DetailType          string  `json:"DetailType"`
+	SalesItemLineDetail struct {
+		ItemRef   qbRef   `json:"ItemRef"`
+		Qty       float64 `json:"Qty"`
+		UnitPrice float64 `json:"UnitPrice"`
+	} `json:"SalesItemLineDetail"`
+	GroupLineDetail struct {
+		GroupItemRef qbRef   `json:"GroupItemRef"`
+		Quantity     float64 `json:"Quantity"`
+	} `json:"GroupLineDetail"`
+}
+
+// qbInvoice is the subset of the QuickBooks invoice that is printed
+type qbInvoice struct {
+	ID           string    `json:"Id"`
+	DocNumber    string    `json:"DocNumber"`
+	TxnDate      string    `json:"TxnDate"`
+	DueDate      string    `json:"DueDate"`
+	SalesTermRef qbRef     `json:"SalesTermRef"`
+	CurrencyRef  qbRef     `json:"CurrencyRef"`
+	CustomerRef  qbRef     `json:"CustomerRef"`
+	BillAddr     qbAddress `json:"BillAddr"`
+	ShipAddr     qbAddress `json:"ShipAddr"`
+	CustomerMemo struct {
+		Value string `json:"value"`
+	} `json:"CustomerMemo"`
+	Line         []qbLine `json:"Line"`
+	TxnTaxDetail struct {
+		TotalTax float64 `json:"TotalTax"`
+	} `json:"TxnTaxDetail"`
+	TotalAmt float64 `json:"TotalAmt"`
+	Balance  float64 `json:"Balance"`
+}
+
+// number returns the invoice's printed number
+func (i *qbInvoice) number() string {
+	if i.DocNumber != "" {
+		return i.DocNumber
+	}
+	return i.ID
+}
+
+// buildView formats an invoice for the context's locale with the tenant's
+// branding. Bundles print as their group line; subtotal lines are replaced
+// by the computed subtotal.
+func buildView(ctx context.Context, invoice *qbInvoice, company *qbclient.CompanyInfo, brand *branding.Branding) *View {
+	locale := i18n.FromContext(ctx)
+	currency := invoice.CurrencyRef.Value
+	if currency == "" {
+		currency = "USD"
+	}
+	amount := func(value float64) string {
+		return i18n.FormatAmount(locale, value, currency)
+	}
+	optional := func(value float64) string {
+		if math.Abs(value) < 0.005 {
+			return ""
+		}
+		return amount(value)
+	}
+	date := func(value string) string {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return value
+		}
+		return i18n.FormatDate(locale, parsed)
+	}
+	quantity := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	view := &View{
+		Labels:   make(map[string]string, len(labels)),
+		Number:   invoice.number(),
+		Date:     date(invoice.TxnDate),
+		DueDate:  date(invoice.DueDate),
+		Terms:    invoice.SalesTermRef.Name,
+		Company:  company.CompanyName,
+		Accent:   template.CSS(brand.Accent().Hex()),
+		Customer: invoice.CustomerRef.Name,
+		BillTo:   invoice.BillAddr.lines(),
+		ShipTo:   invoice.ShipAddr.lines(),
+		Lines:    []ViewLine{},
+		Tax:      optional(invoice.TxnTaxDetail.TotalTax),
+		Total:    amount(invoice.TotalAmt),
+		Paid:     optional(invoice.TotalAmt - invoice.Balance),
+		Balance:  amount(invoice.Balance),
+		Memo:     invoice.CustomerMemo.Value,
+		Footer:   brand.Footer,
+	}
+	for _, key := range labels {
+		view.Labels[key] = i18n.T(ctx, "invoice."+key)
+	}
+	if brand.CompanyName != "" {
+		view.Company = brand.CompanyName
+	}
+	address := company.CompanyAddr
+	view.CompanyLines = qbAddress{
+		Line1:                  address.Line1,
+		City:                   address.City,
+		CountrySubDivisionCode: address.CountrySubDivisionCode,
+		PostalCode:             address.PostalCode,
+	}.lines()
+	if len(brand.Logo) > 0 {
+		view.Logo = template.URL("data:" + http.DetectContentType(brand.Logo) + ";base64," + base64.StdEncoding.EncodeToString(brand.Logo))
+	}
+
+	var subtotal, discount float64
+	for _, line := range invoice.Line {
+		switch line.DetailType {
+		case "SalesItemLineDetail":
+			detail := line.SalesItemLineDetail
+			printed := ViewLine{Item: detail.ItemRef.Name, Description: line.Description, Amount: amount(line.Amount)}
+			if detail.Qty != 0 {
+				printed.Quantity = quantity(detail.Qty)
+				printed.Rate = amount(detail.UnitPrice)
+			}
+			view.Lines = append(view.Lines, printed)
+			subtotal += line.Amount
+		case "GroupLineDetail":
+			detail := line.GroupLineDetail
+			printed := ViewLine{Item: detail.GroupItemRef.Name, Description: line.Description, Amount: amount(line.Amount)}
+			if detail.Quantity != 0 {
+				printed.Quantity = quantity(detail.Quantity)
+			}
+			view.Lines = append(view.Lines, printed)
+			subtotal += line.Amount
+		case "DiscountLineDetail":
+			discount += line.Amount
+		}
+	}
+	view.Subtotal = amount(subtotal)
+	view.Discount = optional(discount)
+	return view
+}
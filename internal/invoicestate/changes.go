@@ -0,0 +1,61 @@
+// invoicestate/changes.go
+package invoicestate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+)
+
+// paymentFields are the payment fields naming the invoices it pays
+type paymentFields struct {
+	Line []struct {
+		LinkedTxn []struct {
+			TxnID   string `json:"TxnId"`
+			TxnType string `json:"TxnType"`
+		} `json:"LinkedTxn"`
+	} `json:"Line"`
+}
+
+// HandleChange implements qbwebhook.Handler for Invoice and Payment
+// changes, moving the invoices they affect to the status QuickBooks shows.
+// Deleted payments are not followed, since the invoices they paid can no
+// longer be read from them; the invoices' own changes move them back.
+func (s *Service) HandleChange(ctx context.Context, change qbwebhook.Change) error {
+	if strings.EqualFold(change.Operation, "Delete") {
+		return nil
+	}
+	if realmID, err := auth.GetCompanyID(ctx); err != nil || realmID != change.RealmID {
+		return nil
+	}
+
+	switch change.Entity {
+	case "Invoice":
+		return s.Follow(ctx, change.ID)
+	case "Payment":
+		raw, err := s.qb.Read(ctx, "Payment", change.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read payment %s: %w", change.ID, err)
+		}
+		var payment paymentFields
+		if err := decode(raw, &payment); err != nil {
+			return err
+		}
+		seen := make(map[string]bool)
+		for _, line := range payment.Line {
+			for _, txn := range line.LinkedTxn {
+				if txn.TxnType != "Invoice" || seen[txn.TxnID] {
+					continue
+				}
+				seen[txn.TxnID] = true
+				if err := s.Follow(ctx, txn.TxnID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
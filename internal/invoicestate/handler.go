@@ -0,0 +1,123 @@
+// invoicestate/handler.go
+package invoicestate
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for invoice lifecycles
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new invoice lifecycle handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnknownStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrFollowsQuickBooks), errors.Is(err, writelock.ErrTimeout):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// listOptions reads list filters from the query string, e.g.
+// ?status=approved,sent&customer_id=12&limit=50&offset=1000
+func listOptions(r *http.Request) (ListOptions, error) {
+	q := r.URL.Query()
+	opts := ListOptions{CustomerID: q.Get("customer_id")}
+	for _, status := range strings.Split(q.Get("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			opts.Statuses = append(opts.Statuses, status)
+		}
+	}
+	if value := q.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, errors.New("limit must be a number")
+		}
+		opts.Limit = limit
+	}
+	if value := q.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, errors.New("offset must be a number")
+		}
+		opts.Offset = offset
+	}
+	return opts, nil
+}
+
+// GetMachine returns the company's invoice statuses and transitions
+func (h *Handler) GetMachine(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.service.Machine(r.Context()))
+}
+
+// ListInvoices lists invoices with their lifecycle status, filtered by
+// ?status=. A page may hold fewer invoices than the limit while
+// next_offset is set; continue from it to scan further.
+func (h *Handler) ListInvoices(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.List(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list invoices: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetLifecycle returns an invoice's status, the statuses it may move to and
+// its transition history
+func (h *Handler) GetLifecycle(w http.ResponseWriter, r *http.Request) {
+	lifecycle, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get invoice lifecycle: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(lifecycle)
+}
+
+// Transition moves an invoice to a status, e.g. {"status": "approved"}
+func (h *Handler) Transition(w http.ResponseWriter, r *http.Request) {
+	var req TransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	lifecycle, err := h.service.Transition(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		http.Error(w, "Failed to change invoice status: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(lifecycle)
+}
@@ -0,0 +1,491 @@
+// invoicestate/service.go
+package invoicestate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/outbox"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+var (
+	// ErrNotFound is returned for invoice IDs QuickBooks does not know
+	ErrNotFound = errors.New("invoice not found")
+	// ErrUnknownStatus is returned for statuses not in the tenant's lifecycle
+	ErrUnknownStatus = errors.New("unknown invoice status")
+	// ErrInvalidTransition is returned for moves the lifecycle does not allow
+	ErrInvalidTransition = errors.New("transition not allowed")
+	// ErrFollowsQuickBooks is returned for moves to a payment status, which
+	// invoices reach when QuickBooks records their payments
+	ErrFollowsQuickBooks = errors.New("payment statuses follow the invoice's balance in QuickBooks")
+)
+
+// errUnchanged stops a write when QuickBooks already has the EmailStatus
+var errUnchanged = errors.New("invoice already has the email status")
+
+// defaultLimit and maxLimit bound list pages; maxScan bounds the invoices
+// read from QuickBooks to fill one
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+	maxScan      = 5 * query.MaxPageSize
+)
+
+// QuickBooks is the subset of the QuickBooks client used for invoice
+// lifecycles
+type QuickBooks interface {
+	query.Querier
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+	Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error
+}
+
+// Locker serializes transitions of the same invoice across instances
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// Lifecycle is an invoice's status and the statuses it may move to
+type Lifecycle struct {
+	InvoiceID    string  `json:"invoice_id"`
+	DocNumber    string  `json:"doc_number,omitempty"`
+	CustomerID   string  `json:"customer_id,omitempty"`
+	CustomerName string  `json:"customer_name,omitempty"`
+	TotalAmt     float64 `json:"total_amt"`
+	Balance      float64 `json:"balance"`
+	Status       string  `json:"status"`
+	// QuickBooksStatus is the built-in status the invoice's QuickBooks
+	// fields show
+	QuickBooksStatus string       `json:"quickbooks_status"`
+	Next             []string     `json:"next"`
+	History          []Transition `json:"history,omitempty"`
+}
+
+// Machine describes a tenant's invoice lifecycle
+type Machine struct {
+	Statuses    []string            `json:"statuses"`
+	Transitions map[string][]string `json:"transitions"`
+	// Custom is set when the tenant replaced the default transitions
+	Custom bool `json:"custom"`
+}
+
+// TransitionRequest moves an invoice to a status
+type TransitionRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note,omitempty"`
+}
+
+// ListOptions filters and pages lifecycle lists
+type ListOptions struct {
+	Statuses   []string
+	CustomerID string
+	Limit      int
+	// Offset is the position in QuickBooks' invoice list to scan from, as
+	// returned in the previous page's next_offset
+	Offset int
+}
+
+// Page is one page of invoices in the requested statuses
+type Page struct {
+	Invoices []*Lifecycle `json:"invoices"`
+	// NextOffset is where the next page's scan starts; it is absent once
+	// every invoice was scanned
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+// Service moves invoices through their tenant's lifecycle. Statuses
+// QuickBooks can hold are written to the invoice's EmailStatus; payment
+// statuses follow its balance. Every transition is published as an
+// "invoice_status.<status>" event to the tenant's webhooks.
+type Service struct {
+	store  *Store
+	config *tenantconfig.Service
+	events *outbox.Store
+	qb     QuickBooks
+	locker Locker
+}
+
+// NewService creates a new invoice lifecycle service
+func NewService(store *Store, config *tenantconfig.Service, events *outbox.Store, qb QuickBooks, locker Locker) *Service {
+	return &Service{
+		store:  store,
+		config: config,
+		events: events,
+		qb:     qb,
+		locker: locker,
+	}
+}
+
+// Machine returns the current tenant's lifecycle
+func (s *Service) Machine(ctx context.Context) *Machine {
+	lifecycle := s.config.InvoiceLifecycle(ctx, auth.GetTenantID(ctx))
+	statuses := append(append([]string{}, tenantconfig.InvoiceStatuses...), lifecycle.Statuses...)
+	machine := &Machine{
+		Statuses:    statuses,
+		Transitions: make(map[string][]string, len(statuses)),
+		Custom:      len(lifecycle.Transitions) > 0,
+	}
+	for _, status := range statuses {
+		machine.Transitions[status] = append([]string{}, lifecycle.Next(status)...)
+	}
+	return machine
+}
+
+// Get returns an invoice's lifecycle with its transition history
+func (s *Service) Get(ctx context.Context, invoiceID string) (*Lifecycle, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle := s.config.InvoiceLifecycle(ctx, auth.GetTenantID(ctx))
+
+	invoice, err := s.read(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.store.Get(ctx, realmID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	result := describe(lifecycle, invoice, state)
+	if state != nil {
+		result.History = state.History
+	}
+	return result, nil
+}
+
+// Transition moves an invoice to a status the lifecycle allows from its
+// current one. Moving to draft, approved or sent sets the invoice's
+// EmailStatus in QuickBooks. Payment statuses cannot be moved to; they
+// follow the invoice's balance.
+func (s *Service) Transition(ctx context.Context, invoiceID string, req *TransitionRequest) (*Lifecycle, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle := s.config.InvoiceLifecycle(ctx, auth.GetTenantID(ctx))
+	to := strings.TrimSpace(req.Status)
+	if !lifecycle.Known(to) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+	if followed[to] && emailStatuses[to] == "" {
+		return nil, ErrFollowsQuickBooks
+	}
+
+	unlock, err := s.locker.Lock(ctx, s.lockName(realmID, invoiceID))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	state, err := s.store.Get(ctx, realmID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// check validates the move against the invoice as QuickBooks has it
+	var invoice invoiceFields
+	var current string
+	check := func(raw map[string]json.RawMessage) error {
+		if err := decode(raw, &invoice); err != nil {
+			return err
+		}
+		current = resolve(lifecycle, state, derive(&invoice))
+		if current == to {
+			return fmt.Errorf("%w: invoice is already %s", ErrInvalidTransition, to)
+		}
+		if !lifecycle.Allows(current, to) {
+			return fmt.Errorf("%w: from %s to %s", ErrInvalidTransition, current, to)
+		}
+		return nil
+	}
+
+	if emailStatus, ok := emailStatuses[to]; ok {
+		var updated map[string]json.RawMessage
+		err = s.qb.Modify(ctx, "Invoice", invoiceID, func(raw map[string]json.RawMessage) (map[string]interface{}, error) {
+			if err := check(raw); err != nil {
+				return nil, err
+			}
+			if invoice.EmailStatus == emailStatus {
+				return nil, errUnchanged
+			}
+			return map[string]interface{}{"sparse": true, "EmailStatus": emailStatus}, nil
+		}, &updated)
+		if err != nil && !errors.Is(err, errUnchanged) {
+			return nil, notFound(err)
+		}
+		invoice.EmailStatus = emailStatus
+	} else {
+		raw, err := s.qb.Read(ctx, "Invoice", invoiceID)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		if err := check(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	// Record a move QuickBooks made since the state was saved first, so the
+	// history shows where the invoice came from
+	now := time.Now().UTC()
+	var moves []Transition
+	if state == nil {
+		state = &State{InvoiceID: invoiceID}
+		moves = append(moves, Transition{To: current, Source: SourceQuickBooks, At: now})
+	} else if current != state.Status {
+		moves = append(moves, Transition{From: state.Status, To: current, Source: SourceQuickBooks, At: now})
+	}
+	moves = append(moves, Transition{
+		From:   current,
+		To:     to,
+		Source: SourceUser,
+		By:     auth.GetUserID(ctx),
+		Note:   strings.TrimSpace(req.Note),
+		At:     now,
+	})
+	if err := s.save(ctx, realmID, &invoice, state, moves); err != nil {
+		return nil, err
+	}
+
+	result := describe(lifecycle, &invoice, state)
+	result.History = state.History
+	return result, nil
+}
+
+// Follow moves an invoice to the status QuickBooks shows, when its lifecycle
+// allows, and starts tracking invoices seen for the first time
+func (s *Service) Follow(ctx context.Context, invoiceID string) error {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return err
+	}
+	lifecycle := s.config.InvoiceLifecycle(ctx, auth.GetTenantID(ctx))
+
+	unlock, err := s.locker.Lock(ctx, s.lockName(realmID, invoiceID))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	invoice, err := s.read(ctx, invoiceID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state, err := s.store.Get(ctx, realmID, invoiceID)
+	if err != nil {
+		return err
+	}
+
+	derived := derive(invoice)
+	now := time.Now().UTC()
+	if state == nil {
+		state = &State{InvoiceID: invoiceID}
+		return s.save(ctx, realmID, invoice, state, []Transition{{To: derived, Source: SourceQuickBooks, At: now}})
+	}
+	if current := resolve(lifecycle, state, derived); current != state.Status {
+		return s.save(ctx, realmID, invoice, state, []Transition{{From: state.Status, To: current, Source: SourceQuickBooks, At: now}})
+	}
+	return nil
+}
+
+// List returns invoices in the requested statuses, or every invoice, newest
+// first. Statuses only the server knows are looked up by their stored
+// invoices; otherwise QuickBooks' invoices are scanned until the page is
+// full or maxScan invoices were read.
+func (s *Service) List(ctx context.Context, opts ListOptions) (*Page, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle := s.config.InvoiceLifecycle(ctx, auth.GetTenantID(ctx))
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	page := &Page{Invoices: []*Lifecycle{}}
+	wanted := make(map[string]bool, len(opts.Statuses))
+	local := len(opts.Statuses) > 0
+	for _, status := range opts.Statuses {
+		if !lifecycle.Known(status) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, status)
+		}
+		wanted[status] = true
+		if _, ok := emailStatuses[status]; ok || followed[status] {
+			local = false
+		}
+	}
+
+	q := query.Select("Invoice")
+	if opts.CustomerID != "" {
+		if !query.IsID(opts.CustomerID) {
+			return page, nil
+		}
+		q.Where("CustomerRef", "=", opts.CustomerID)
+	}
+	if local {
+		// Invoices only reach these statuses through the server, so their
+		// stored states are complete
+		var ids []interface{}
+		for status := range wanted {
+			stored, err := s.store.InStatus(ctx, realmID, status)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range stored {
+				if query.IsID(id) {
+					ids = append(ids, id)
+				}
+			}
+		}
+		if len(ids) == 0 {
+			return page, nil
+		}
+		q.WhereIn("Id", ids...)
+	}
+	q.OrderByDesc("TxnDate")
+
+	offset := opts.Offset
+	for scanned := 0; scanned < maxScan; {
+		invoices, err := query.List[invoiceFields](ctx, s.qb, q.Offset(offset).Limit(query.MaxPageSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list invoices: %w", err)
+		}
+		ids := make([]string, len(invoices))
+		for i, invoice := range invoices {
+			ids[i] = invoice.ID
+		}
+		states, err := s.store.GetMany(ctx, realmID, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range invoices {
+			if len(page.Invoices) == opts.Limit {
+				next := offset + i
+				page.NextOffset = &next
+				return page, nil
+			}
+			result := describe(lifecycle, &invoices[i], states[invoices[i].ID])
+			if len(wanted) == 0 || wanted[result.Status] {
+				page.Invoices = append(page.Invoices, result)
+			}
+		}
+		offset += len(invoices)
+		scanned += len(invoices)
+		if len(invoices) < query.MaxPageSize {
+			return page, nil
+		}
+	}
+	page.NextOffset = &offset
+	return page, nil
+}
+
+// save records transitions on a state, saves it and appends an event per
+// transition. If the events cannot be recorded the request fails, so a
+// client is never told an invoice moved when its webhooks were lost.
+func (s *Service) save(ctx context.Context, realmID string, invoice *invoiceFields, state *State, moves []Transition) error {
+	previous := state.Status
+	for _, move := range moves {
+		state.record(move)
+	}
+	if err := s.store.Save(ctx, realmID, previous, state); err != nil {
+		return err
+	}
+
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		return nil
+	}
+	events := make([]*outbox.Event, 0, len(moves))
+	for _, move := range moves {
+		event, err := outbox.NewEvent(tenantID, realmID, "invoice_status."+move.To, "Invoice", state.InvoiceID, map[string]interface{}{
+			"invoice_id": state.InvoiceID,
+			"doc_number": invoice.DocNumber,
+			"transition": move,
+		})
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	if err := s.events.Append(ctx, events...); err != nil {
+		return fmt.Errorf("invoice moved to %s but its event was not recorded: %w", state.Status, err)
+	}
+	return nil
+}
+
+// read fetches an invoice's lifecycle fields
+func (s *Service) read(ctx context.Context, invoiceID string) (*invoiceFields, error) {
+	raw, err := s.qb.Read(ctx, "Invoice", invoiceID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	var invoice invoiceFields
+	if err := decode(raw, &invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// lockName serializes work on one invoice's state
+func (s *Service) lockName(realmID, invoiceID string) string {
+	return "invoicestate:" + realmID + ":" + invoiceID
+}
+
+// describe builds an invoice's lifecycle from its fields and stored state
+func describe(lifecycle tenantconfig.InvoiceLifecycle, invoice *invoiceFields, state *State) *Lifecycle {
+	derived := derive(invoice)
+	status := resolve(lifecycle, state, derived)
+	next := append([]string{}, lifecycle.Next(status)...)
+	sort.Strings(next)
+	result := &Lifecycle{
+		InvoiceID:        invoice.ID,
+		DocNumber:        invoice.DocNumber,
+		TotalAmt:         invoice.TotalAmt,
+		Balance:          invoice.Balance,
+		Status:           status,
+		QuickBooksStatus: derived,
+		Next:             next,
+	}
+	if invoice.CustomerRef != nil {
+		result.CustomerID = invoice.CustomerRef.Value
+		result.CustomerName = invoice.CustomerRef.Name
+	}
+	return result
+}
+
+// decode reads the fields v declares from an entity's QuickBooks JSON
+func decode(raw map[string]json.RawMessage, v interface{}) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("failed to marshal entity: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode entity: %w", err)
+	}
+	return nil
+}
+
+// notFound maps QuickBooks' object-not-found fault to ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
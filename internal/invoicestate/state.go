@@ -0,0 +1,214 @@
+// invoicestate/state.go
+package invoicestate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/go-redis/redis/v8"
+)
+
+// maxHistory bounds the transitions kept per invoice
+const maxHistory = 50
+
+// Transition sources
+const (
+	SourceUser       = "user"       // moved through the API
+	SourceQuickBooks = "quickbooks" // followed a change made in QuickBooks
+)
+
+// QuickBooks EmailStatus values the send statuses map to
+const (
+	emailNotSet     = "NotSet"
+	emailNeedToSend = "NeedToSend"
+	emailSent       = "EmailSent"
+)
+
+// emailStatuses are the EmailStatus written for statuses QuickBooks can hold
+var emailStatuses = map[string]string{
+	tenantconfig.InvoiceDraft:    emailNotSet,
+	tenantconfig.InvoiceApproved: emailNeedToSend,
+	tenantconfig.InvoiceSent:     emailSent,
+}
+
+// followed are the statuses QuickBooks is authoritative for: an invoice
+// emailed or paid in QuickBooks moves to them when its lifecycle allows
+var followed = map[string]bool{
+	tenantconfig.InvoiceSent:          true,
+	tenantconfig.InvoicePartiallyPaid: true,
+	tenantconfig.InvoicePaid:          true,
+}
+
+// Transition is one move of an invoice between statuses. From is empty for
+// the status an invoice was first seen in.
+type Transition struct {
+	From   string    `json:"from,omitempty"`
+	To     string    `json:"to"`
+	Source string    `json:"source"`
+	By     string    `json:"by,omitempty"`
+	Note   string    `json:"note,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// State is the lifecycle status the server keeps for an invoice
+type State struct {
+	InvoiceID string       `json:"invoice_id"`
+	Status    string       `json:"status"`
+	History   []Transition `json:"history"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// record moves the state to a status, keeping the newest transitions
+func (s *State) record(t Transition) {
+	s.Status = t.To
+	s.UpdatedAt = t.At
+	s.History = append(s.History, t)
+	if len(s.History) > maxHistory {
+		s.History = s.History[len(s.History)-maxHistory:]
+	}
+}
+
+// invoiceFields are the invoice fields a status is derived from
+type invoiceFields struct {
+	ID          string  `json:"Id"`
+	DocNumber   string  `json:"DocNumber"`
+	TotalAmt    float64 `json:"TotalAmt"`
+	Balance     float64 `json:"Balance"`
+	EmailStatus string  `json:"EmailStatus"`
+	CustomerRef *struct {
+		Value string `json:"value"`
+		Name  string `json:"name"`
+	} `json:"CustomerRef"`
+}
+
+// derive maps an invoice's QuickBooks fields to the built-in status they
+// show: payments by its balance, sending by its EmailStatus
+func derive(invoice *invoiceFields) string {
+	switch {
+	case invoice.TotalAmt > 0 && invoice.Balance <= 0:
+		return tenantconfig.InvoicePaid
+	case invoice.Balance < invoice.TotalAmt:
+		return tenantconfig.InvoicePartiallyPaid
+	case invoice.EmailStatus == emailSent:
+		return tenantconfig.InvoiceSent
+	case invoice.EmailStatus == emailNeedToSend:
+		return tenantconfig.InvoiceApproved
+	}
+	return tenantconfig.InvoiceDraft
+}
+
+// resolve returns an invoice's effective status: the stored one, unless
+// QuickBooks shows a status it is authoritative for that the lifecycle
+// allows moving to. Invoices without a stored state take the derived one.
+func resolve(lifecycle tenantconfig.InvoiceLifecycle, state *State, derived string) string {
+	if state == nil {
+		return derived
+	}
+	if derived != state.Status && followed[derived] && lifecycle.Allows(state.Status, derived) {
+		return derived
+	}
+	return state.Status
+}
+
+// Store persists invoice states per company, with a set of invoice IDs per
+// status so invoices in statuses only the server knows can be listed
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new invoice state store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// stateKey holds an invoice's state
+func (s *Store) stateKey(realmID, invoiceID string) string {
+	return fmt.Sprintf("%s:invoicestate:%s:%s", s.prefix, realmID, invoiceID)
+}
+
+// statusKey is the set of a company's invoice IDs stored in a status
+func (s *Store) statusKey(realmID, status string) string {
+	return fmt.Sprintf("%s:invoicestate:%s:status:%s", s.prefix, realmID, status)
+}
+
+// Save writes a state and moves it from the previous status's set
+func (s *Store) Save(ctx context.Context, realmID, previous string, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invoice state: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.stateKey(realmID, state.InvoiceID), data, 0)
+	if previous != "" && previous != state.Status {
+		pipe.SRem(ctx, s.statusKey(realmID, previous), state.InvoiceID)
+	}
+	pipe.SAdd(ctx, s.statusKey(realmID, state.Status), state.InvoiceID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save invoice state: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves an invoice's state, or nil if it has none
+func (s *Store) Get(ctx context.Context, realmID, invoiceID string) (*State, error) {
+	data, err := s.client.Get(ctx, s.stateKey(realmID, invoiceID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get invoice state: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal invoice state: %w", err)
+	}
+	return &state, nil
+}
+
+// GetMany retrieves the states of several invoices by ID; invoices without
+// one are left out
+func (s *Store) GetMany(ctx context.Context, realmID string, invoiceIDs []string) (map[string]*State, error) {
+	states := make(map[string]*State, len(invoiceIDs))
+	if len(invoiceIDs) == 0 {
+		return states, nil
+	}
+
+	keys := make([]string, len(invoiceIDs))
+	for i, id := range invoiceIDs {
+		keys[i] = s.stateKey(realmID, id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice states: %w", err)
+	}
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var state State
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			continue
+		}
+		states[state.InvoiceID] = &state
+	}
+	return states, nil
+}
+
+// InStatus returns the IDs of a company's invoices stored in a status
+func (s *Store) InStatus(ctx context.Context, realmID, status string) ([]string, error) {
+	ids, err := s.client.SMembers(ctx, s.statusKey(realmID, status)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invoices in %s: %w", status, err)
+	}
+	return ids, nil
+}
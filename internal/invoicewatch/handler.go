@@ -0,0 +1,124 @@
+// invoicewatch/handler.go
+package invoicewatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/gorilla/mux"
+)
+
+// Stream timing
+const (
+	heartbeatInterval = 25 * time.Second
+	maxWatchDuration  = time.Hour // clients reconnect after this
+	refreshInterval   = time.Second
+)
+
+// Handler streams invoice payment status over server-sent events
+type Handler struct {
+	hub    *Hub
+	reader Reader
+}
+
+// NewHandler creates a new invoice watch handler
+func NewHandler(hub *Hub, reader Reader) *Handler {
+	return &Handler{
+		hub:    hub,
+		reader: reader,
+	}
+}
+
+// Watch sends the invoice's current status as a "status" event, then a new
+// one whenever a webhook changes it. Comment lines are sent as heartbeats
+// so proxies keep the connection open.
+func (h *Handler) Watch(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+	invoiceID := mux.Vars(r)["id"]
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before the first read so no change between the two is missed
+	sub := h.hub.subscribe(realmID, invoiceID)
+	defer h.hub.unsubscribe(sub)
+
+	current, err := fetchStatus(r.Context(), h.reader, invoiceID)
+	if err != nil {
+		http.Error(w, "Failed to get invoice status: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logging.FromContext(r.Context()).Warn("failed to clear write deadline for invoice watch", "error", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := writeEvent(w, "status", current); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(maxWatchDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-sub.changed:
+			status, err := fetchStatus(r.Context(), h.reader, invoiceID)
+			if err != nil {
+				if writeEvent(w, "error", map[string]string{"error": err.Error()}) != nil {
+					return
+				}
+			} else if !status.same(current) {
+				current = status
+				if writeEvent(w, "status", current) != nil {
+					return
+				}
+			}
+			flusher.Flush()
+
+			// Bursts of payment changes cost one refresh per interval
+			select {
+			case <-time.After(refreshInterval):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes one server-sent event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
@@ -0,0 +1,147 @@
+// invoicewatch/hub.go
+package invoicewatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+	"github.com/go-redis/redis/v8"
+)
+
+// notification tells watchers that an entity in a company changed
+type notification struct {
+	RealmID string `json:"realm_id"`
+	Entity  string `json:"entity"`
+	ID      string `json:"id"`
+}
+
+// subscriber is one watch connection
+type subscriber struct {
+	realmID string
+	// changed is signalled when something affecting the watched invoice may
+	// have changed; it holds at most one pending signal
+	changed chan struct{}
+	match   func(n notification) bool
+}
+
+// Hub fans webhook changes out to watch connections. Changes are published
+// through Redis so a watcher is notified whichever instance received the
+// webhook.
+type Hub struct {
+	client redis.UniversalClient
+	prefix string
+
+	mu          sync.Mutex
+	subscribers map[string]map[*subscriber]struct{} // by realm
+}
+
+// NewHub creates a new watch hub
+func NewHub(client redis.UniversalClient, prefix string) *Hub {
+	return &Hub{
+		client:      client,
+		prefix:      prefix,
+		subscribers: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// channel is the Redis channel for a company's changes
+func (h *Hub) channel(realmID string) string {
+	return fmt.Sprintf("%s:invoicewatch:%s", h.prefix, realmID)
+}
+
+// HandleChange publishes a webhook change; register it with the webhook
+// ingester for Invoice, Payment and Deposit
+func (h *Hub) HandleChange(ctx context.Context, change qbwebhook.Change) error {
+	data, err := json.Marshal(notification{RealmID: change.RealmID, Entity: change.Entity, ID: change.ID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal watch notification: %w", err)
+	}
+	if err := h.client.Publish(ctx, h.channel(change.RealmID), data).Err(); err != nil {
+		return fmt.Errorf("failed to publish watch notification: %w", err)
+	}
+	return nil
+}
+
+// Start relays published changes to this instance's watchers until the
+// context is cancelled
+func (h *Hub) Start(ctx context.Context) {
+	pubsub := h.client.PSubscribe(ctx, h.channel("*"))
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var n notification
+				if err := json.Unmarshal([]byte(msg.Payload), &n); err != nil {
+					logging.FromContext(ctx).Warn("invalid watch notification", "error", err)
+					continue
+				}
+				h.dispatch(n)
+			}
+		}
+	}()
+}
+
+// dispatch signals the watchers a change may affect. A watcher that has not
+// consumed its previous signal is skipped, since it will refresh anyway.
+func (h *Hub) dispatch(n notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers[n.RealmID] {
+		if !sub.match(n) {
+			continue
+		}
+		select {
+		case sub.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// subscribe registers a watcher of one invoice. Payment and deposit changes
+// are not tied to an invoice in the webhook, so any of them in the company
+// triggers a refresh.
+func (h *Hub) subscribe(realmID, invoiceID string) *subscriber {
+	sub := &subscriber{
+		realmID: realmID,
+		changed: make(chan struct{}, 1),
+		match: func(n notification) bool {
+			switch strings.ToLower(n.Entity) {
+			case "invoice":
+				return n.ID == invoiceID
+			case "payment", "deposit":
+				return true
+			}
+			return false
+		},
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[realmID] == nil {
+		h.subscribers[realmID] = make(map[*subscriber]struct{})
+	}
+	h.subscribers[realmID][sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes a watcher
+func (h *Hub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers[sub.realmID], sub)
+	if len(h.subscribers[sub.realmID]) == 0 {
+		delete(h.subscribers, sub.realmID)
+	}
+}
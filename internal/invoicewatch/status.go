@@ -0,0 +1,161 @@
+// invoicewatch/status.go
+package invoicewatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Invoice payment statuses, in the order an invoice normally moves through them
+const (
+	StatusOpen          = "open"
+	StatusSent          = "sent"
+	StatusViewed        = "viewed"
+	StatusPartiallyPaid = "partially_paid"
+	StatusPaid          = "paid"
+	StatusDeposited     = "deposited"
+	StatusVoided        = "voided"
+)
+
+// Reader fetches one QuickBooks entity by ID
+type Reader interface {
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// Status is an invoice's payment status
+type Status struct {
+	InvoiceID  string    `json:"invoice_id"`
+	Status     string    `json:"status"`
+	TotalAmt   float64   `json:"total_amt"`
+	Balance    float64   `json:"balance"`
+	PaymentIDs []string  `json:"payment_ids,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// same reports whether two statuses would look identical to a client
+func (s *Status) same(other *Status) bool {
+	return other != nil && s.Status == other.Status && s.Balance == other.Balance &&
+		strings.Join(s.PaymentIDs, ",") == strings.Join(other.PaymentIDs, ",")
+}
+
+// linkedTxn is a reference to a related transaction
+type linkedTxn struct {
+	TxnID   string `json:"TxnId"`
+	TxnType string `json:"TxnType"`
+}
+
+// invoiceFields are the invoice fields the status is derived from
+type invoiceFields struct {
+	ID             string      `json:"Id"`
+	TotalAmt       float64     `json:"TotalAmt"`
+	Balance        float64     `json:"Balance"`
+	EmailStatus    string      `json:"EmailStatus"`
+	EInvoiceStatus string      `json:"EInvoiceStatus"`
+	PrivateNote    string      `json:"PrivateNote"`
+	LinkedTxn      []linkedTxn `json:"LinkedTxn"`
+	MetaData       struct {
+		LastUpdatedTime time.Time `json:"LastUpdatedTime"`
+	} `json:"MetaData"`
+}
+
+// paymentFields are the payment fields that show whether it was deposited
+type paymentFields struct {
+	LinkedTxn           []linkedTxn `json:"LinkedTxn"`
+	DepositToAccountRef *struct {
+		Name string `json:"name"`
+	} `json:"DepositToAccountRef"`
+}
+
+// fetchStatus reads an invoice, and its payments once it is paid, and
+// derives its payment status
+func fetchStatus(ctx context.Context, reader Reader, invoiceID string) (*Status, error) {
+	fields, err := reader.Read(ctx, "Invoice", invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	var invoice invoiceFields
+	if err := decode(fields, &invoice); err != nil {
+		return nil, fmt.Errorf("failed to decode invoice: %w", err)
+	}
+
+	status := &Status{
+		InvoiceID: invoiceID,
+		Status:    StatusOpen,
+		TotalAmt:  invoice.TotalAmt,
+		Balance:   invoice.Balance,
+		UpdatedAt: invoice.MetaData.LastUpdatedTime,
+	}
+	for _, txn := range invoice.LinkedTxn {
+		if txn.TxnType == "Payment" {
+			status.PaymentIDs = append(status.PaymentIDs, txn.TxnID)
+		}
+	}
+
+	// QuickBooks zeroes voided invoices and marks them in the memo
+	if invoice.TotalAmt == 0 && strings.HasPrefix(invoice.PrivateNote, "Voided") {
+		status.Status = StatusVoided
+		return status, nil
+	}
+
+	switch {
+	case invoice.TotalAmt > 0 && invoice.Balance <= 0:
+		status.Status = StatusPaid
+	case invoice.Balance < invoice.TotalAmt:
+		status.Status = StatusPartiallyPaid
+	case strings.EqualFold(invoice.EInvoiceStatus, "Viewed"):
+		status.Status = StatusViewed
+	case invoice.EmailStatus == "EmailSent" || strings.EqualFold(invoice.EInvoiceStatus, "Sent"):
+		status.Status = StatusSent
+	}
+
+	if status.Status == StatusPaid && len(status.PaymentIDs) > 0 {
+		deposited, err := allDeposited(ctx, reader, status.PaymentIDs)
+		if err != nil {
+			return nil, err
+		}
+		if deposited {
+			status.Status = StatusDeposited
+		}
+	}
+	return status, nil
+}
+
+// allDeposited reports whether every payment has left Undeposited Funds,
+// either through a bank deposit or by being received straight into a bank
+// account
+func allDeposited(ctx context.Context, reader Reader, paymentIDs []string) (bool, error) {
+	for _, id := range paymentIDs {
+		fields, err := reader.Read(ctx, "Payment", id)
+		if err != nil {
+			return false, err
+		}
+		var payment paymentFields
+		if err := decode(fields, &payment); err != nil {
+			return false, fmt.Errorf("failed to decode payment: %w", err)
+		}
+
+		deposited := payment.DepositToAccountRef != nil && payment.DepositToAccountRef.Name != "" &&
+			payment.DepositToAccountRef.Name != "Undeposited Funds"
+		for _, txn := range payment.LinkedTxn {
+			if txn.TxnType == "Deposit" {
+				deposited = true
+			}
+		}
+		if !deposited {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// decode unmarshals raw entity fields into v
+func decode(fields map[string]json.RawMessage, v interface{}) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
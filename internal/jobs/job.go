@@ -0,0 +1,63 @@
+// jobs/job.go
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+// Job is a persisted unit of background work
+type Job struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	TenantID  string          `json:"tenant_id"`
+	UserID    string          `json:"user_id,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Schedule  *Schedule       `json:"schedule,omitempty"` // nil for one-off jobs
+	Status    string          `json:"status"`
+	NextRunAt time.Time       `json:"next_run_at"`
+	LastRunAt *time.Time      `json:"last_run_at,omitempty"`
+	LastError string          `json:"last_error,omitempty"`
+	RunCount  int             `json:"run_count"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NewJob creates an active job of the given type
+func NewJob(jobType, tenantID, userID string, payload interface{}) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Job{
+		ID:        NewID(),
+		Type:      jobType,
+		TenantID:  tenantID,
+		UserID:    userID,
+		Payload:   data,
+		Status:    StatusActive,
+		NextRunAt: now,
+		CreatedAt: now,
+	}, nil
+}
+
+// NewID generates a random identifier
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is unrecoverable
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,117 @@
+// jobs/schedule.go
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+)
+
+// Schedule frequencies
+const (
+	FrequencyHourly  = "hourly"
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+// Schedule describes when a recurring job runs
+type Schedule struct {
+	Frequency  string `json:"frequency"`
+	Weekday    int    `json:"weekday,omitempty"`      // 0 = Sunday, weekly only
+	DayOfMonth int    `json:"day_of_month,omitempty"` // 1-28, monthly only
+	Hour       int    `json:"hour"`
+	Minute     int    `json:"minute"`
+	Location   string `json:"location,omitempty"` // IANA time zone, defaults to UTC
+}
+
+// Localize defaults an unset location to the realm time zone in ctx, so
+// schedules run at the realm's local time of day rather than the server's
+func (s *Schedule) Localize(ctx context.Context) *Schedule {
+	if s.Location == "" {
+		s.Location = timezone.FromContext(ctx).String()
+	}
+	return s
+}
+
+// Validate checks the schedule is well formed
+func (s *Schedule) Validate() error {
+	switch s.Frequency {
+	case FrequencyHourly, FrequencyDaily:
+	case FrequencyWeekly:
+		if s.Weekday < 0 || s.Weekday > 6 {
+			return fmt.Errorf("weekday must be between 0 and 6")
+		}
+	case FrequencyMonthly:
+		if s.DayOfMonth < 1 || s.DayOfMonth > 28 {
+			return fmt.Errorf("day_of_month must be between 1 and 28")
+		}
+	default:
+		return fmt.Errorf("unsupported frequency: %q", s.Frequency)
+	}
+
+	if s.Hour < 0 || s.Hour > 23 || s.Minute < 0 || s.Minute > 59 {
+		return fmt.Errorf("invalid time of day %02d:%02d", s.Hour, s.Minute)
+	}
+
+	if _, err := s.location(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// location resolves the schedule's time zone
+func (s *Schedule) location() (*time.Location, error) {
+	if s.Location == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.Location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid location: %w", err)
+	}
+	return loc, nil
+}
+
+// Next returns the first run time strictly after the given time
+func (s *Schedule) Next(after time.Time) time.Time {
+	loc, err := s.location()
+	if err != nil {
+		loc = time.UTC
+	}
+	t := after.In(loc)
+
+	switch s.Frequency {
+	case FrequencyHourly:
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), s.Minute, 0, 0, loc)
+		if !next.After(t) {
+			next = next.Add(time.Hour)
+		}
+		return next
+
+	case FrequencyWeekly:
+		next := time.Date(t.Year(), t.Month(), t.Day(), s.Hour, s.Minute, 0, 0, loc)
+		days := (s.Weekday - int(next.Weekday()) + 7) % 7
+		next = next.AddDate(0, 0, days)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+
+	case FrequencyMonthly:
+		next := time.Date(t.Year(), t.Month(), s.DayOfMonth, s.Hour, s.Minute, 0, 0, loc)
+		if !next.After(t) {
+			next = next.AddDate(0, 1, 0)
+		}
+		return next
+
+	default: // daily
+		next := time.Date(t.Year(), t.Month(), t.Day(), s.Hour, s.Minute, 0, 0, loc)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	}
+}
@@ -0,0 +1,202 @@
+// jobs/scheduler.go
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/internal/tracing"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Runner executes jobs of a particular type
+type Runner interface {
+	Run(ctx context.Context, job *Job) error
+}
+
+// RunnerFunc adapts a function to the Runner interface
+type RunnerFunc func(ctx context.Context, job *Job) error
+
+// Run calls f(ctx, job)
+func (f RunnerFunc) Run(ctx context.Context, job *Job) error {
+	return f(ctx, job)
+}
+
+// Scheduler polls the store for due jobs and dispatches them to runners
+type Scheduler struct {
+	store        Store
+	runners      map[string]Runner
+	mu           sync.RWMutex
+	pollInterval time.Duration
+	jobTimeout   time.Duration
+	lease        time.Duration
+}
+
+// NewScheduler creates a new job scheduler
+func NewScheduler(store Store, pollInterval time.Duration) *Scheduler {
+	return &Scheduler{
+		store:        store,
+		runners:      make(map[string]Runner),
+		pollInterval: pollInterval,
+		jobTimeout:   5 * time.Minute,
+		// Outlasts the run timeout so only a dead worker's lease expires
+		lease: 6 * time.Minute,
+	}
+}
+
+// RegisterRunner sets the runner for a job type
+func (s *Scheduler) RegisterRunner(jobType string, runner Runner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runners[jobType] = runner
+}
+
+// Store returns the scheduler's job store
+func (s *Scheduler) Store() Store {
+	return s.store
+}
+
+// Start begins polling for due jobs until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runDue(ctx)
+			}
+		}
+	}()
+}
+
+// runDue claims and runs every job that is due, first requeueing jobs whose
+// worker stopped before saving them
+func (s *Scheduler) runDue(ctx context.Context) {
+	if requeued, err := s.store.RequeueExpired(ctx, time.Now()); err != nil {
+		logging.FromContext(ctx).Warn("failed to requeue expired jobs", "error", err)
+	} else if requeued > 0 {
+		logging.FromContext(ctx).Warn("requeued jobs whose lease expired", "count", requeued)
+	}
+
+	ids, err := s.store.Due(ctx, time.Now(), 100)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to poll due jobs", "error", err)
+		return
+	}
+
+	for _, id := range ids {
+		claimed, err := s.store.Claim(ctx, id, s.lease)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to claim job", "job_id", id, "error", err)
+			continue
+		}
+		if !claimed {
+			// Another instance got it first
+			continue
+		}
+
+		job, err := s.store.Get(ctx, id)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to load job", "job_id", id, "error", err)
+			continue
+		}
+
+		s.execute(ctx, job)
+	}
+}
+
+// execute runs a claimed job and reschedules or completes it. Saving the job
+// afterwards releases its lease.
+func (s *Scheduler) execute(ctx context.Context, job *Job) {
+	if job.Status != StatusActive {
+		if err := s.store.Save(ctx, job); err != nil {
+			logging.FromContext(ctx).Warn("failed to release job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	logger := logging.FromContext(ctx).With("job_id", job.ID, "job_type", job.Type, "tenant_id", job.TenantID)
+	s.mu.RLock()
+	runner, ok := s.runners[job.Type]
+	s.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no runner registered for job type %q", job.Type)
+	} else {
+		runCtx, cancel := context.WithTimeout(ctx, s.jobTimeout)
+		runCtx = logging.WithLogger(runCtx, logger)
+		// Each run is the root of its own trace
+		runCtx, span := tracing.Start(runCtx, "job "+job.Type, trace.WithNewRoot(),
+			trace.WithAttributes(attribute.String("job.id", job.ID), attribute.String("job.tenant_id", job.TenantID)))
+		// Background jobs wait out QuickBooks hiccups, within their timeout
+		runCtx = qbclient.WithRetry(runCtx, qbclient.BackgroundRetryPolicy)
+		// and yield the QuickBooks budget to interactive requests
+		runCtx = qbclient.WithPriority(runCtx, qbclient.PriorityBackground)
+		// Recurring jobs run in the time zone they are scheduled in
+		if job.Schedule != nil {
+			if loc, err := job.Schedule.location(); err == nil {
+				runCtx = timezone.WithLocation(runCtx, loc)
+			}
+		}
+		err = runner.Run(runCtx, job)
+		tracing.End(span, err)
+		cancel()
+	}
+
+	now := time.Now()
+	job.LastRunAt = &now
+	job.RunCount++
+	job.LastError = ""
+	if err != nil {
+		job.LastError = err.Error()
+		logger.Error("job failed", "error", err)
+	}
+
+	// Recurring jobs keep running after failures; one-off jobs finish
+	switch {
+	case s.cancelledDuringRun(ctx, job.ID):
+		job.Status = StatusCancelled
+	case job.Schedule != nil:
+		job.NextRunAt = job.Schedule.Next(now)
+	case err != nil:
+		job.Status = StatusFailed
+	default:
+		job.Status = StatusCompleted
+	}
+
+	if err := s.store.Save(ctx, job); err != nil {
+		logger.Warn("failed to save job", "error", err)
+	}
+}
+
+// cancelledDuringRun reports whether the job was cancelled while it ran
+func (s *Scheduler) cancelledDuringRun(ctx context.Context, id string) bool {
+	current, err := s.store.Get(ctx, id)
+	return err == nil && current.Status == StatusCancelled
+}
+
+// Cancel stops a job from running again
+func (s *Scheduler) Cancel(ctx context.Context, id string) (*Job, error) {
+	job, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Status = StatusCancelled
+	if err := s.store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
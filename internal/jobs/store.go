@@ -0,0 +1,185 @@
+// jobs/store.go
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrJobNotFound is returned when a job does not exist
+var ErrJobNotFound = errors.New("job not found")
+
+// Store persists jobs and tracks when they are due
+type Store interface {
+	Save(ctx context.Context, job *Job) error
+	Get(ctx context.Context, id string) (*Job, error)
+	ListByTenant(ctx context.Context, tenantID, jobType string) ([]*Job, error)
+	// Due returns IDs of active jobs scheduled at or before now
+	Due(ctx context.Context, now time.Time, limit int64) ([]string, error)
+	// Claim atomically takes a due job so only one worker runs it. The job
+	// is leased until the lease expires or the job is next saved.
+	Claim(ctx context.Context, id string, lease time.Duration) (bool, error)
+	// RequeueExpired makes jobs whose lease expired before now due again
+	RequeueExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// claim moves a job from the due set to the leased set, so a worker that
+// dies mid-run leaves it to be requeued rather than lost
+var claim = redis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 1 then
+	redis.call("ZADD", KEYS[2], ARGV[2], ARGV[1])
+	return 1
+end
+return 0`)
+
+// requeue moves jobs whose lease has expired back to the due set
+var requeue = redis.NewScript(`
+local ids = redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1])
+for _, id in ipairs(ids) do
+	redis.call("ZREM", KEYS[2], id)
+	redis.call("ZADD", KEYS[1], ARGV[1], id)
+end
+return #ids`)
+
+// RedisStore implements Store using Redis
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a new Redis-backed job store
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// jobKey generates the Redis key for a job
+func (s *RedisStore) jobKey(id string) string {
+	return fmt.Sprintf("%s:jobs:job:%s", s.prefix, id)
+}
+
+// dueKey is the sorted set of active jobs scored by next run time
+func (s *RedisStore) dueKey() string {
+	return fmt.Sprintf("%s:jobs:due", s.prefix)
+}
+
+// leasedKey is the sorted set of running jobs scored by lease expiry
+func (s *RedisStore) leasedKey() string {
+	return fmt.Sprintf("%s:jobs:leased", s.prefix)
+}
+
+// tenantKey is the set of job IDs owned by a tenant
+func (s *RedisStore) tenantKey(tenantID string) string {
+	return fmt.Sprintf("%s:jobs:tenant:%s", s.prefix, tenantID)
+}
+
+// Save stores a job, schedules it if active and releases any lease on it
+func (s *RedisStore) Save(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.jobKey(job.ID), data, 0)
+	pipe.SAdd(ctx, s.tenantKey(job.TenantID), job.ID)
+	if job.Status == StatusActive {
+		pipe.ZAdd(ctx, s.dueKey(), &redis.Z{Score: float64(job.NextRunAt.Unix()), Member: job.ID})
+	} else {
+		pipe.ZRem(ctx, s.dueKey(), job.ID)
+	}
+	pipe.ZRem(ctx, s.leasedKey(), job.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a job by ID
+func (s *RedisStore) Get(ctx context.Context, id string) (*Job, error) {
+	data, err := s.client.Get(ctx, s.jobKey(id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// ListByTenant returns a tenant's jobs, optionally filtered by type
+func (s *RedisStore) ListByTenant(ctx context.Context, tenantID, jobType string) ([]*Job, error) {
+	ids, err := s.client.SMembers(ctx, s.tenantKey(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	jobs := make([]*Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := s.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrJobNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if jobType != "" && job.Type != jobType {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Due returns IDs of jobs whose next run time has passed
+func (s *RedisStore) Due(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	ids, err := s.client.ZRangeByScore(ctx, s.dueKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due jobs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// Claim moves the job from the due set to the leased set, succeeding for
+// exactly one caller
+func (s *RedisStore) Claim(ctx context.Context, id string, lease time.Duration) (bool, error) {
+	expiry := time.Now().Add(lease).Unix()
+	claimed, err := claim.Run(ctx, s.client, []string{s.dueKey(), s.leasedKey()}, id, expiry).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	return claimed == 1, nil
+}
+
+// RequeueExpired makes jobs whose worker never saved them due again, returning
+// how many were requeued
+func (s *RedisStore) RequeueExpired(ctx context.Context, now time.Time) (int64, error) {
+	count, err := requeue.Run(ctx, s.client, []string{s.dueKey(), s.leasedKey()}, now.Unix()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue expired jobs: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,61 @@
+// knowledge/chunker.go
+package knowledge
+
+import (
+	"strings"
+)
+
+// Chunk sizes in characters
+const (
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 200
+)
+
+// chunkText splits text into overlapping chunks, preferring paragraph and
+// sentence boundaries so retrieved context reads naturally
+func chunkText(text string, size, overlap int) []string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+	if len(text) <= size {
+		return []string{text}
+	}
+
+	var chunks []string
+	start := 0
+	for start < len(text) {
+		end := start + size
+		if end >= len(text) {
+			chunks = append(chunks, strings.TrimSpace(text[start:]))
+			break
+		}
+
+		// Back off to the nearest boundary in the second half of the window
+		window := text[start:end]
+		cut := -1
+		for _, sep := range []string{"\n\n", "\n", ". "} {
+			if i := strings.LastIndex(window, sep); i > size/2 {
+				cut = i + len(sep)
+				break
+			}
+		}
+		if cut == -1 {
+			if i := strings.LastIndex(window, " "); i > size/2 {
+				cut = i + 1
+			} else {
+				cut = size
+			}
+		}
+
+		chunks = append(chunks, strings.TrimSpace(text[start:start+cut]))
+
+		next := start + cut - overlap
+		if next <= start {
+			next = start + cut
+		}
+		start = next
+	}
+
+	return chunks
+}
@@ -0,0 +1,127 @@
+// knowledge/handler.go
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// maxUploadSize limits uploaded documents to 5 MB
+const maxUploadSize = 5 << 20
+
+// Servicer stores and retrieves tenant reference documents. *Service
+// implements it; tests can substitute a fake.
+type Servicer interface {
+	Upload(ctx context.Context, tenantID, userID, title, content string) (*Document, error)
+	List(ctx context.Context, tenantID string) ([]*Document, error)
+	Delete(ctx context.Context, tenantID, id string) error
+	Retrieve(ctx context.Context, tenantID, query string, limit int) ([]Passage, error)
+}
+
+// Handler provides HTTP handlers for tenant reference documents
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new knowledge handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// UploadDocument accepts a plain text, markdown, or CSV document
+func (h *Handler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		http.Error(w, "Invalid upload", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if !supportedUpload(header.Filename, header.Header.Get("Content-Type")) {
+		http.Error(w, "Only text, markdown, and CSV documents are supported", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		title = header.Filename
+	}
+
+	doc, err := h.service.Upload(r.Context(), auth.GetTenantID(r.Context()), auth.GetUserID(r.Context()), title, string(content))
+	if err != nil {
+		http.Error(w, "Failed to upload document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// ListDocuments returns the tenant's documents
+func (h *Handler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	docs, err := h.service.List(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to list documents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"documents": docs,
+	})
+}
+
+// DeleteDocument removes a document
+func (h *Handler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := h.service.Delete(r.Context(), auth.GetTenantID(r.Context()), id); err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			http.Error(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// supportedUpload reports whether the file looks like a text document
+func supportedUpload(filename, contentType string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".txt", ".md", ".markdown", ".csv":
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/")
+}
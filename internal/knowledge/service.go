@@ -0,0 +1,211 @@
+// knowledge/service.go
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/search"
+	"github.com/go-redis/redis/v8"
+)
+
+// EntityChunk is the search entity type for document chunks. Chunks are
+// partitioned in the vector store by tenant ID rather than realm ID.
+const EntityChunk = "document_chunk"
+
+// ErrDocumentNotFound is returned when a document does not exist
+var ErrDocumentNotFound = errors.New("document not found")
+
+// Document is a reference document uploaded by a tenant
+type Document struct {
+	ID         string    `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	Title      string    `json:"title"`
+	Size       int       `json:"size"`
+	ChunkCount int       `json:"chunk_count"`
+	UploadedBy string    `json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Passage is a retrieved chunk of a document
+type Passage struct {
+	DocumentID string  `json:"document_id"`
+	Title      string  `json:"title"`
+	Text       string  `json:"text"`
+	Score      float64 `json:"score"`
+}
+
+// Service stores, indexes, and retrieves tenant documents
+type Service struct {
+	client   redis.UniversalClient
+	prefix   string
+	search   search.Servicer
+	minScore float64
+}
+
+// NewService creates a new knowledge service
+func NewService(client redis.UniversalClient, prefix string, searchService search.Servicer) *Service {
+	return &Service{
+		client:   client,
+		prefix:   prefix,
+		search:   searchService,
+		minScore: 0.3,
+	}
+}
+
+// key generates the Redis key for a tenant's document metadata
+func (s *Service) key(tenantID string) string {
+	return fmt.Sprintf("%s:knowledge:docs:%s", s.prefix, tenantID)
+}
+
+// Upload chunks, embeds, and stores a document
+func (s *Service) Upload(ctx context.Context, tenantID, userID, title, content string) (*Document, error) {
+	chunks := chunkText(content, defaultChunkSize, defaultChunkOverlap)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("document is empty")
+	}
+
+	doc := &Document{
+		ID:         jobs.NewID(),
+		TenantID:   tenantID,
+		Title:      title,
+		Size:       len(content),
+		ChunkCount: len(chunks),
+		UploadedBy: userID,
+		CreatedAt:  time.Now(),
+	}
+
+	searchDocs := make([]search.Document, len(chunks))
+	for i, chunk := range chunks {
+		searchDocs[i] = search.Document{
+			RealmID:    tenantID,
+			EntityType: EntityChunk,
+			EntityID:   chunkID(doc.ID, i),
+			// Prefix the title so chunks carry their document's context
+			Text: title + "\n" + chunk,
+		}
+	}
+	if err := s.search.Index(ctx, searchDocs); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.key(tenantID), doc.ID, data).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save document: %w", err)
+	}
+
+	return doc, nil
+}
+
+// List returns a tenant's documents, newest first
+func (s *Service) List(ctx context.Context, tenantID string) ([]*Document, error) {
+	values, err := s.client.HGetAll(ctx, s.key(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	docs := make([]*Document, 0, len(values))
+	for _, v := range values {
+		var doc Document
+		if err := json.Unmarshal([]byte(v), &doc); err != nil {
+			continue
+		}
+		docs = append(docs, &doc)
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].CreatedAt.After(docs[j].CreatedAt)
+	})
+
+	return docs, nil
+}
+
+// Delete removes a document and its chunks
+func (s *Service) Delete(ctx context.Context, tenantID, id string) error {
+	data, err := s.client.HGet(ctx, s.key(tenantID), id).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return ErrDocumentNotFound
+		}
+		return fmt.Errorf("failed to get document: %w", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to unmarshal document: %w", err)
+	}
+
+	for i := 0; i < doc.ChunkCount; i++ {
+		if err := s.search.Remove(ctx, tenantID, EntityChunk, chunkID(id, i)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.client.HDel(ctx, s.key(tenantID), id).Err(); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+
+	return nil
+}
+
+// Retrieve returns the passages most relevant to the query
+func (s *Service) Retrieve(ctx context.Context, tenantID, query string, limit int) ([]Passage, error) {
+	results, err := s.search.Search(ctx, tenantID, query, limit, search.Filter{
+		EntityTypes: []string{EntityChunk},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	titles := map[string]string{}
+	passages := make([]Passage, 0, len(results))
+	for _, result := range results {
+		if result.Score < s.minScore {
+			continue
+		}
+
+		docID := documentIDFromChunk(result.EntityID)
+		title, ok := titles[docID]
+		if !ok {
+			if data, err := s.client.HGet(ctx, s.key(tenantID), docID).Bytes(); err == nil {
+				var doc Document
+				if json.Unmarshal(data, &doc) == nil {
+					title = doc.Title
+				}
+			}
+			titles[docID] = title
+		}
+
+		passages = append(passages, Passage{
+			DocumentID: docID,
+			Title:      title,
+			Text:       result.Text,
+			Score:      result.Score,
+		})
+	}
+
+	return passages, nil
+}
+
+// chunkID identifies a chunk within a document
+func chunkID(docID string, index int) string {
+	return fmt.Sprintf("%s-%d", docID, index)
+}
+
+// documentIDFromChunk extracts the document ID from a chunk ID
+func documentIDFromChunk(id string) string {
+	for i := len(id) - 1; i >= 0; i-- {
+		if id[i] == '-' {
+			return id[:i]
+		}
+	}
+	return id
+}
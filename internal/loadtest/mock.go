@@ -0,0 +1,446 @@
+// loadtest/mock.go
+package loadtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// QuickBooks throttles each company to 500 requests a minute and 10
+// concurrent requests
+const (
+	DefaultRequestsPerMinute = 500
+	DefaultMaxConcurrent     = 10
+)
+
+// entityNames maps the lowercase entity of API paths and queries to the key
+// QuickBooks wraps it in
+var entityNames = map[string]string{
+	"invoice":     "Invoice",
+	"customer":    "Customer",
+	"item":        "Item",
+	"payment":     "Payment",
+	"companyinfo": "CompanyInfo",
+	"preferences": "Preferences",
+}
+
+var (
+	fromPattern  = regexp.MustCompile(`(?i)\bfrom\s+(\w+)`)
+	startPattern = regexp.MustCompile(`(?i)\bstartposition\s+(\d+)`)
+	maxPattern   = regexp.MustCompile(`(?i)\bmaxresults\s+(\d+)`)
+	countPattern = regexp.MustCompile(`(?i)^\s*select\s+count\(\*\)`)
+)
+
+// MockStats counts the requests a mock has served
+type MockStats struct {
+	Requests  int64 `json:"requests"`
+	Throttled int64 `json:"throttled"` // answered 429, by rate, concurrency or injection
+	Creates   int64 `json:"creates"`
+	Queries   int64 `json:"queries"`
+	Reads     int64 `json:"reads"`
+	Tokens    int64 `json:"tokens"`
+	// PeakConcurrent is the most requests a company had in flight at once
+	PeakConcurrent int64 `json:"peak_concurrent"`
+}
+
+// realm is a mock company's entities and throttling state
+type realm struct {
+	mu       sync.Mutex
+	entities map[string][]map[string]interface{} // by lowercase entity, in creation order
+	nextID   int
+	window   time.Time // start of the current rate window
+	count    int       // requests in the window
+	inFlight int
+}
+
+// Mock is an in-memory QuickBooks Online API serving the calls the server
+// makes, with QuickBooks' latency and throttling, so the server's pooling
+// and rate limiting can be load tested without a sandbox company. Point the
+// server's QuickBooks API base URL and token URL at it; any bearer token is
+// accepted.
+type Mock struct {
+	// Latency and Jitter delay every response by Latency plus up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// RequestsPerMinute and MaxConcurrent throttle each company as
+	// QuickBooks does; zero disables the limit
+	RequestsPerMinute int
+	MaxConcurrent     int
+	// ThrottleRate answers this fraction of requests 429 regardless
+	ThrottleRate float64
+	// SeedInvoices is the number of invoices a company starts with
+	SeedInvoices int
+
+	mu     sync.Mutex
+	realms map[string]*realm
+
+	requests, throttled, creates, queries, reads, tokens, peak int64
+}
+
+// NewMock creates a mock with QuickBooks' limits, a typical 80–120ms
+// latency and 100 invoices per company
+func NewMock() *Mock {
+	return &Mock{
+		Latency:           80 * time.Millisecond,
+		Jitter:            40 * time.Millisecond,
+		RequestsPerMinute: DefaultRequestsPerMinute,
+		MaxConcurrent:     DefaultMaxConcurrent,
+		SeedInvoices:      100,
+	}
+}
+
+// Handler returns the mock's API
+func (m *Mock) Handler() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/oauth2/v1/tokens/bearer", m.token).Methods("POST")
+
+	company := router.PathPrefix("/v3/company/{realmID}").Subrouter()
+	company.Use(m.throttle)
+	company.HandleFunc("/query", m.query).Methods("GET", "POST")
+	company.HandleFunc("/{entity}", m.singleton).Methods("GET")
+	company.HandleFunc("/{entity}", m.write).Methods("POST")
+	company.HandleFunc("/{entity}/{id}", m.read).Methods("GET")
+	return router
+}
+
+// Stats returns the mock's counters
+func (m *Mock) Stats() MockStats {
+	return MockStats{
+		Requests:       atomic.LoadInt64(&m.requests),
+		Throttled:      atomic.LoadInt64(&m.throttled),
+		Creates:        atomic.LoadInt64(&m.creates),
+		Queries:        atomic.LoadInt64(&m.queries),
+		Reads:          atomic.LoadInt64(&m.reads),
+		Tokens:         atomic.LoadInt64(&m.tokens),
+		PeakConcurrent: atomic.LoadInt64(&m.peak),
+	}
+}
+
+// realm returns a company's state, seeding it on first use
+func (m *Mock) realm(realmID string) *realm {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if re, ok := m.realms[realmID]; ok {
+		return re
+	}
+	if m.realms == nil {
+		m.realms = make(map[string]*realm)
+	}
+	re := &realm{entities: make(map[string][]map[string]interface{})}
+	rng := mathrand.New(mathrand.NewSource(int64(len(m.realms))))
+	for i := 0; i < m.SeedInvoices; i++ {
+		re.add("invoice", newInvoice(rng))
+	}
+	m.realms[realmID] = re
+	return re
+}
+
+// add stores a new entity, assigning its ID, sync token and metadata
+func (re *realm) add(entity string, data map[string]interface{}) map[string]interface{} {
+	re.nextID++
+	now := time.Now().UTC().Format(time.RFC3339)
+	data["Id"] = strconv.Itoa(re.nextID)
+	data["SyncToken"] = "0"
+	data["MetaData"] = map[string]string{"CreateTime": now, "LastUpdatedTime": now}
+	if entity == "invoice" {
+		data["DocNumber"] = strconv.Itoa(1000 + re.nextID)
+		data["TxnDate"] = now[:10]
+		var total float64
+		if lines, ok := data["Line"].([]interface{}); ok {
+			for _, line := range lines {
+				if l, ok := line.(map[string]interface{}); ok {
+					amount, _ := l["Amount"].(float64)
+					total += amount
+				}
+			}
+		} else if lines, ok := data["Line"].([]map[string]interface{}); ok {
+			for _, l := range lines {
+				amount, _ := l["Amount"].(float64)
+				total += amount
+			}
+		}
+		data["TotalAmt"] = total
+		data["Balance"] = total
+	}
+	re.entities[entity] = append(re.entities[entity], data)
+	return data
+}
+
+// find returns a stored entity by ID
+func (re *realm) find(entity, id string) (int, map[string]interface{}) {
+	for i, data := range re.entities[entity] {
+		if data["Id"] == id {
+			return i, data
+		}
+	}
+	return -1, nil
+}
+
+// throttle delays responses and answers 429 over the company's rate or
+// concurrency limit, as QuickBooks does
+func (m *Mock) throttle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&m.requests, 1)
+		w.Header().Set("intuit_tid", newTID())
+		authorization := r.Header.Get("Authorization")
+		if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "bearer ") {
+			fault(w, http.StatusUnauthorized, "3200", "message=AuthenticationFailed; errorCode=003200; statusCode=401")
+			return
+		}
+
+		re := m.realm(mux.Vars(r)["realmID"])
+		re.mu.Lock()
+		now := time.Now()
+		if now.Sub(re.window) >= time.Minute {
+			re.window, re.count = now, 0
+		}
+		re.count++
+		overRate := m.RequestsPerMinute > 0 && re.count > m.RequestsPerMinute
+		overConcurrency := m.MaxConcurrent > 0 && re.inFlight >= m.MaxConcurrent
+		injected := m.ThrottleRate > 0 && mathrand.Float64() < m.ThrottleRate
+		if overRate || overConcurrency || injected {
+			re.mu.Unlock()
+			atomic.AddInt64(&m.throttled, 1)
+			fault(w, http.StatusTooManyRequests, "3001", "message=ThrottleExceeded; errorCode=003001; statusCode=429")
+			return
+		}
+		re.inFlight++
+		inFlight := int64(re.inFlight)
+		re.mu.Unlock()
+		for {
+			peak := atomic.LoadInt64(&m.peak)
+			if inFlight <= peak || atomic.CompareAndSwapInt64(&m.peak, peak, inFlight) {
+				break
+			}
+		}
+		defer func() {
+			re.mu.Lock()
+			re.inFlight--
+			re.mu.Unlock()
+		}()
+
+		delay := m.Latency
+		if m.Jitter > 0 {
+			delay += time.Duration(mathrand.Int63n(int64(m.Jitter)))
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// token issues tokens for any authorization code or refresh token
+func (m *Mock) token(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&m.tokens, 1)
+	respond(w, http.StatusOK, map[string]interface{}{
+		"access_token":               "mock-" + newTID(),
+		"refresh_token":              "mock-refresh-" + newTID(),
+		"token_type":                 "bearer",
+		"expires_in":                 3600,
+		"x_refresh_token_expires_in": 8726400,
+	})
+}
+
+// query answers SELECT statements over the company's entities, honouring
+// COUNT(*), STARTPOSITION and MAXRESULTS; WHERE and ORDER BY are ignored
+func (m *Mock) query(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&m.queries, 1)
+	statement := r.URL.Query().Get("query")
+	if statement == "" {
+		statement = r.FormValue("query")
+	}
+	match := fromPattern.FindStringSubmatch(statement)
+	if match == nil {
+		fault(w, http.StatusBadRequest, "4000", "Error parsing query")
+		return
+	}
+	entity := strings.ToLower(match[1])
+
+	re := m.realm(mux.Vars(r)["realmID"])
+	re.mu.Lock()
+	all := re.entities[entity]
+	re.mu.Unlock()
+
+	if countPattern.MatchString(statement) {
+		respond(w, http.StatusOK, map[string]interface{}{
+			"QueryResponse": map[string]int{"totalCount": len(all)},
+			"time":          time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	start, max := 1, 100
+	if match := startPattern.FindStringSubmatch(statement); match != nil {
+		start, _ = strconv.Atoi(match[1])
+	}
+	if match := maxPattern.FindStringSubmatch(statement); match != nil {
+		max, _ = strconv.Atoi(match[1])
+	}
+	if start < 1 {
+		start = 1
+	}
+	if max > 1000 {
+		max = 1000
+	}
+
+	// Newest first, as lists are usually ordered
+	results := make([]map[string]interface{}, 0, max)
+	for i := len(all) - start; i >= 0 && len(results) < max; i-- {
+		results = append(results, all[i])
+	}
+	queryResponse := map[string]interface{}{"startPosition": start, "maxResults": len(results)}
+	if len(results) > 0 {
+		queryResponse[entityName(entity)] = results
+	}
+	respond(w, http.StatusOK, map[string]interface{}{
+		"QueryResponse": queryResponse,
+		"time":          time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// read returns one entity
+func (m *Mock) read(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&m.reads, 1)
+	vars := mux.Vars(r)
+	entity := strings.ToLower(vars["entity"])
+	if entity == "companyinfo" || entity == "preferences" {
+		m.singleton(w, r)
+		return
+	}
+
+	re := m.realm(vars["realmID"])
+	re.mu.Lock()
+	_, data := re.find(entity, vars["id"])
+	re.mu.Unlock()
+	if data == nil {
+		fault(w, http.StatusBadRequest, "610", "Object Not Found")
+		return
+	}
+	respond(w, http.StatusOK, map[string]interface{}{entityName(entity): data})
+}
+
+// singleton returns a company's CompanyInfo or Preferences
+func (m *Mock) singleton(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	switch strings.ToLower(vars["entity"]) {
+	case "companyinfo":
+		respond(w, http.StatusOK, map[string]interface{}{"CompanyInfo": map[string]interface{}{
+			"Id":          vars["realmID"],
+			"CompanyName": "Load Test Company " + vars["realmID"],
+			"Country":     "US",
+			"SyncToken":   "0",
+			"NameValue":   []map[string]string{{"Name": "OfferingSku", "Value": "QuickBooks Online Plus"}},
+		}})
+	case "preferences":
+		respond(w, http.StatusOK, map[string]interface{}{"Preferences": map[string]interface{}{
+			"Id":                  "1",
+			"SyncToken":           "0",
+			"AccountingInfoPrefs": map[string]interface{}{},
+			"CurrencyPrefs": map[string]interface{}{
+				"HomeCurrency": map[string]string{"value": "USD"},
+			},
+		}})
+	default:
+		fault(w, http.StatusBadRequest, "4000", "Unsupported operation")
+	}
+}
+
+// write creates or, given an Id, updates an entity, checking its sync token
+// as QuickBooks does
+func (m *Mock) write(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	entity := strings.ToLower(vars["entity"])
+	if entity == "batch" {
+		fault(w, http.StatusBadRequest, "4000", "Batch is not supported by the mock")
+		return
+	}
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		fault(w, http.StatusBadRequest, "2500", "Invalid Request: "+err.Error())
+		return
+	}
+
+	re := m.realm(vars["realmID"])
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	id, _ := data["Id"].(string)
+	if id == "" {
+		atomic.AddInt64(&m.creates, 1)
+		respond(w, http.StatusOK, map[string]interface{}{entityName(entity): re.add(entity, data)})
+		return
+	}
+
+	i, current := re.find(entity, id)
+	if current == nil {
+		fault(w, http.StatusBadRequest, "610", "Object Not Found")
+		return
+	}
+	if data["SyncToken"] != current["SyncToken"] {
+		fault(w, http.StatusBadRequest, "5010", "Stale Object Error")
+		return
+	}
+	if sparse, _ := data["sparse"].(bool); sparse {
+		for key, value := range data {
+			current[key] = value
+		}
+		data = current
+	}
+	syncToken, _ := strconv.Atoi(fmt.Sprint(current["SyncToken"]))
+	data["SyncToken"] = strconv.Itoa(syncToken + 1)
+	delete(data, "sparse")
+	re.entities[entity][i] = data
+	respond(w, http.StatusOK, map[string]interface{}{entityName(entity): data})
+}
+
+// entityName returns the key QuickBooks wraps an entity in
+func entityName(entity string) string {
+	if name, ok := entityNames[entity]; ok {
+		return name
+	}
+	if entity == "" {
+		return entity
+	}
+	return strings.ToUpper(entity[:1]) + entity[1:]
+}
+
+// respond writes a JSON response
+func respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// fault writes a QuickBooks error response
+func fault(w http.ResponseWriter, status int, code, message string) {
+	respond(w, status, map[string]interface{}{
+		"Fault": map[string]interface{}{
+			"Error": []map[string]string{{"Message": message, "code": code}},
+			"type":  "ValidationFault",
+		},
+		"time": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// newTID returns a random transaction ID
+func newTID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
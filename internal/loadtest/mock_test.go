@@ -0,0 +1,129 @@
+// loadtest/mock_test.go
+package loadtest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// invoicePayload is a typical invoice create request
+var invoicePayload = []byte(`{"CustomerRef":{"value":"1"},"Line":[{"Amount":100,"DetailType":"SalesItemLineDetail","SalesItemLineDetail":{"ItemRef":{"value":"1"}}}]}`)
+
+// newBenchMock serves a mock without latency or throttling, so benchmarks
+// measure the request path rather than the simulated QuickBooks delays
+func newBenchMock(b *testing.B) *httptest.Server {
+	b.Helper()
+	mock := NewMock()
+	mock.Latency, mock.Jitter = 0, 0
+	mock.RequestsPerMinute, mock.MaxConcurrent = 0, 0
+	server := httptest.NewServer(mock.Handler())
+	b.Cleanup(server.Close)
+	return server
+}
+
+// pooledClient keeps connections to the mock open across requests, as the
+// server's QuickBooks client does
+func pooledClient(concurrency int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = concurrency
+	return &http.Client{Transport: transport}
+}
+
+// unpooledClient opens a connection per request
+func unpooledClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = true
+	return &http.Client{Transport: transport}
+}
+
+// do sends a request to the mock and drains the response
+func do(b *testing.B, client *http.Client, method, target string, body []byte) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		b.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer bench")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		b.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b.Fatalf("%s %s: status %d", method, target, resp.StatusCode)
+	}
+}
+
+// benchCreate creates invoices in parallel through client
+func benchCreate(b *testing.B, client *http.Client) {
+	server := newBenchMock(b)
+	target := server.URL + "/v3/company/bench/invoice"
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			do(b, client, http.MethodPost, target, invoicePayload)
+		}
+	})
+}
+
+func BenchmarkCreateInvoice(b *testing.B) {
+	benchCreate(b, pooledClient(64))
+}
+
+func BenchmarkCreateInvoiceUnpooled(b *testing.B) {
+	benchCreate(b, unpooledClient())
+}
+
+// BenchmarkListInvoices reads the newest page of a company's invoices, as
+// the invoice list does
+func BenchmarkListInvoices(b *testing.B) {
+	server := newBenchMock(b)
+	client := pooledClient(64)
+	target := server.URL + "/v3/company/bench/query?query=" +
+		url.QueryEscape("SELECT * FROM Invoice ORDERBY TxnDate DESC STARTPOSITION 1 MAXRESULTS 20")
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			do(b, client, http.MethodGet, target, nil)
+		}
+	})
+}
+
+// BenchmarkMixedWorkload runs DefaultMix's share of creates and lists
+// against one company, whose lock every request takes
+func BenchmarkMixedWorkload(b *testing.B) {
+	server := newBenchMock(b)
+	client := pooledClient(64)
+	create := server.URL + "/v3/company/bench/invoice"
+	list := server.URL + "/v3/company/bench/query?query=" +
+		url.QueryEscape("SELECT * FROM Invoice STARTPOSITION 1 MAXRESULTS 20")
+	get := server.URL + "/v3/company/bench/invoice/1"
+
+	var ops []string
+	for _, name := range []string{OpCreate, OpList, OpGet} {
+		for i := 0; i < DefaultMix[name]; i++ {
+			ops = append(ops, name)
+		}
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			switch ops[i%len(ops)] {
+			case OpCreate:
+				do(b, client, http.MethodPost, create, invoicePayload)
+			case OpList:
+				do(b, client, http.MethodGet, list, nil)
+			default:
+				do(b, client, http.MethodGet, get, nil)
+			}
+		}
+	})
+}
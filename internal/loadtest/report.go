@@ -0,0 +1,157 @@
+// loadtest/report.go
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary is the outcome of one operation of a run
+type Summary struct {
+	Operation string      `json:"operation"`
+	Requests  int         `json:"requests"`
+	Errors    int         `json:"errors"` // transport failures and 4xx/5xx responses
+	Statuses  map[int]int `json:"statuses"`
+	Coalesced int         `json:"coalesced"` // responses shared or micro-cached by the server
+	RPS       float64     `json:"rps"`
+	P50       Millis      `json:"p50_ms"`
+	P95       Millis      `json:"p95_ms"`
+	P99       Millis      `json:"p99_ms"`
+	Max       Millis      `json:"max_ms"`
+}
+
+// Report is the outcome of a run, by operation
+type Report struct {
+	Duration   Millis    `json:"duration_ms"`
+	Operations []Summary `json:"operations"`
+	Total      Summary   `json:"total"`
+}
+
+// Millis is a duration reported in milliseconds
+type Millis time.Duration
+
+// MarshalJSON encodes the duration as fractional milliseconds
+func (m Millis) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%.2f", float64(m)/float64(time.Millisecond))), nil
+}
+
+// String formats the duration in milliseconds
+func (m Millis) String() string {
+	return fmt.Sprintf("%.1fms", float64(m)/float64(time.Millisecond))
+}
+
+// sample is one request's outcome
+type sample struct {
+	latency   time.Duration
+	status    int // zero when the request failed before a response
+	coalesced bool
+}
+
+// recorder collects samples by operation
+type recorder struct {
+	mu      sync.Mutex
+	samples map[string][]sample
+}
+
+// newRecorder creates an empty recorder
+func newRecorder() *recorder {
+	return &recorder{samples: make(map[string][]sample)}
+}
+
+// record adds a request's outcome
+func (r *recorder) record(operation string, s sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples[operation] = append(r.samples[operation], s)
+}
+
+// report summarizes the samples of a run that took elapsed
+func (r *recorder) report(elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Duration: Millis(elapsed)}
+	var all []sample
+	for operation, samples := range r.samples {
+		report.Operations = append(report.Operations, summarize(operation, samples, elapsed))
+		all = append(all, samples...)
+	}
+	sort.Slice(report.Operations, func(i, j int) bool {
+		return report.Operations[i].Operation < report.Operations[j].Operation
+	})
+	report.Total = summarize("total", all, elapsed)
+	return report
+}
+
+// summarize computes the counts and latency percentiles of samples
+func summarize(operation string, samples []sample, elapsed time.Duration) Summary {
+	summary := Summary{Operation: operation, Requests: len(samples), Statuses: make(map[int]int)}
+	if len(samples) == 0 {
+		return summary
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		latencies[i] = s.latency
+		summary.Statuses[s.status]++
+		if s.status == 0 || s.status >= 400 {
+			summary.Errors++
+		}
+		if s.coalesced {
+			summary.Coalesced++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	summary.P50 = Millis(percentile(latencies, 50))
+	summary.P95 = Millis(percentile(latencies, 95))
+	summary.P99 = Millis(percentile(latencies, 99))
+	summary.Max = Millis(latencies[len(latencies)-1])
+	if elapsed > 0 {
+		summary.RPS = float64(len(samples)) / elapsed.Seconds()
+	}
+	return summary
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// WriteText writes the report as a table
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "operation\trequests\terrors\tcoalesced\trps\tp50\tp95\tp99\tmax\tstatuses\t\n")
+	for _, s := range append(r.Operations, r.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t\n",
+			s.Operation, s.Requests, s.Errors, s.Coalesced, s.RPS, s.P50, s.P95, s.P99, s.Max, statuses(s.Statuses))
+	}
+	fmt.Fprintf(tw, "\nduration %s\n", time.Duration(r.Duration).Round(time.Millisecond))
+	return tw.Flush()
+}
+
+// statuses formats status counts in status order, e.g. "200:97 429:3";
+// status 0 counts transport failures
+func statuses(counts map[int]int) string {
+	codes := make([]int, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	out := ""
+	for i, code := range codes {
+		if i > 0 {
+			out += " "
+		}
+		out += fmt.Sprintf("%d:%d", code, counts[code])
+	}
+	return out
+}
@@ -0,0 +1,329 @@
+// loadtest/runner.go
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operations of the invoice workload
+const (
+	OpCreate = "create" // POST /api/invoices
+	OpList   = "list"   // GET /api/invoices
+	OpGet    = "get"    // GET /api/invoices/{id} of an invoice created or listed earlier
+)
+
+// DefaultMix is a read-heavy mix resembling dashboard traffic
+var DefaultMix = map[string]int{OpCreate: 1, OpList: 3, OpGet: 6}
+
+// ErrInvalidMix is returned for mixes without a known operation of positive
+// weight
+var ErrInvalidMix = errors.New("invalid mix, expected weights of create, list and get")
+
+// Target is a running server and the identity requests are made as
+type Target struct {
+	BaseURL  string
+	UserID   string // sent as X-User-ID
+	TenantID string // sent as X-Tenant-ID
+	APIKey   string // sent as X-API-Key instead of the user headers
+	// NoCache bypasses the server's micro-cache so every read reaches
+	// QuickBooks
+	NoCache bool
+	Client  *http.Client
+}
+
+// Config shapes a run
+type Config struct {
+	Concurrency int
+	Duration    time.Duration
+	Requests    int     // stops after this many requests; zero runs for Duration
+	Rate        float64 // requests per second across workers; zero is unthrottled
+	Mix         map[string]int
+	ListLimit   int
+}
+
+// Runner drives the invoice workload against a target
+type Runner struct {
+	target   *Target
+	config   Config
+	ops      []string // operation names, repeated by weight
+	recorder *recorder
+
+	mu  sync.Mutex
+	ids []string // known invoice IDs for get
+}
+
+// maxIDs bounds the invoice IDs kept for get
+const maxIDs = 1000
+
+// NewRunner creates a runner, defaulting unset configuration
+func NewRunner(target *Target, config Config) (*Runner, error) {
+	if target.Client == nil {
+		target.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	if config.Duration <= 0 && config.Requests <= 0 {
+		config.Duration = 30 * time.Second
+	}
+	if config.Mix == nil {
+		config.Mix = DefaultMix
+	}
+	if config.ListLimit < 1 {
+		config.ListLimit = 20
+	}
+
+	r := &Runner{target: target, config: config, recorder: newRecorder()}
+	names := make([]string, 0, len(config.Mix))
+	for name := range config.Mix {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != OpCreate && name != OpList && name != OpGet {
+			return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidMix, name)
+		}
+		for i := 0; i < config.Mix[name]; i++ {
+			r.ops = append(r.ops, name)
+		}
+	}
+	if len(r.ops) == 0 {
+		return nil, ErrInvalidMix
+	}
+	return r, nil
+}
+
+// ParseMix parses a mix such as "create=1,list=3,get=6"
+func ParseMix(value string) (map[string]int, error) {
+	mix := make(map[string]int)
+	for _, part := range strings.Split(value, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMix, part)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMix, part)
+		}
+		mix[name] = n
+	}
+	return mix, nil
+}
+
+// Run drives the workload until the duration elapses, the request count is
+// reached or ctx is cancelled, and reports latencies by operation
+func (r *Runner) Run(ctx context.Context) *Report {
+	if r.config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.Duration)
+		defer cancel()
+	}
+
+	// A shared ticker paces workers when a rate is set
+	var pace <-chan time.Time
+	if r.config.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.config.Rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	var issued int64
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < r.config.Concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for {
+				if pace != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-pace:
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				if r.config.Requests > 0 && atomic.AddInt64(&issued, 1) > int64(r.config.Requests) {
+					return
+				}
+				r.do(ctx, r.ops[rng.Intn(len(r.ops))], rng)
+			}
+		}(start.UnixNano() + int64(i))
+	}
+	wg.Wait()
+
+	return r.recorder.report(time.Since(start))
+}
+
+// do runs one operation and records its outcome. Requests cut short by the
+// end of the run are not recorded.
+func (r *Runner) do(ctx context.Context, operation string, rng *rand.Rand) {
+	var (
+		method = http.MethodGet
+		path   = "/api/invoices"
+		body   []byte
+	)
+	switch operation {
+	case OpCreate:
+		method = http.MethodPost
+		body, _ = json.Marshal(newInvoice(rng))
+	case OpList:
+		path += "?limit=" + strconv.Itoa(r.config.ListLimit)
+	case OpGet:
+		id := r.randomID(rng)
+		if id == "" {
+			// Nothing to read yet; list to learn some IDs
+			operation = OpList
+			path += "?limit=" + strconv.Itoa(r.config.ListLimit)
+			break
+		}
+		path += "/" + id
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(r.target.BaseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		r.recorder.record(operation, sample{})
+		return
+	}
+	r.authenticate(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	started := time.Now()
+	resp, err := r.target.Client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.recorder.record(operation, sample{latency: time.Since(started)})
+		}
+		return
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	r.recorder.record(operation, sample{
+		latency:   time.Since(started),
+		status:    resp.StatusCode,
+		coalesced: resp.Header.Get("X-Coalesced") != "",
+	})
+
+	if resp.StatusCode < 300 && operation != OpGet {
+		r.learnIDs(data)
+	}
+}
+
+// authenticate sets the target's identity headers
+func (r *Runner) authenticate(req *http.Request) {
+	if r.target.APIKey != "" {
+		req.Header.Set("X-API-Key", r.target.APIKey)
+	} else {
+		req.Header.Set("X-User-ID", r.target.UserID)
+		if r.target.TenantID != "" {
+			req.Header.Set("X-Tenant-ID", r.target.TenantID)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.target.NoCache {
+		req.Header.Set("Cache-Control", "no-cache")
+	}
+}
+
+// learnIDs keeps the invoice IDs of a create or list response for get
+func (r *Runner) learnIDs(data []byte) {
+	ids := invoiceIDs(data)
+	if len(ids) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, ids...)
+	if len(r.ids) > maxIDs {
+		r.ids = r.ids[len(r.ids)-maxIDs:]
+	}
+}
+
+// randomID returns a known invoice ID, or "" when none is known yet
+func (r *Runner) randomID(rng *rand.Rand) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ids) == 0 {
+		return ""
+	}
+	return r.ids[rng.Intn(len(r.ids))]
+}
+
+// invoiceIDs finds invoice IDs in a response, whether it is an invoice, a
+// list of invoices, or either wrapped in an object
+func invoiceIDs(data []byte) []string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	var ids []string
+	var walk func(v interface{}, depth int)
+	walk = func(v interface{}, depth int) {
+		if depth > 3 {
+			return
+		}
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				walk(item, depth+1)
+			}
+		case map[string]interface{}:
+			for _, key := range []string{"Id", "id"} {
+				if id, ok := v[key].(string); ok && id != "" {
+					ids = append(ids, id)
+					return
+				}
+			}
+			for _, key := range []string{"Invoice", "invoice", "invoices", "data", "items"} {
+				if inner, ok := v[key]; ok {
+					walk(inner, depth+1)
+				}
+			}
+		}
+	}
+	walk(value, 0)
+	return ids
+}
+
+// newInvoice returns a plausible one- to five-line invoice
+func newInvoice(rng *rand.Rand) map[string]interface{} {
+	lines := make([]map[string]interface{}, 1+rng.Intn(5))
+	for i := range lines {
+		qty := 1 + rng.Intn(10)
+		price := float64(500+rng.Intn(20000)) / 100
+		lines[i] = map[string]interface{}{
+			"Amount":     float64(qty) * price,
+			"DetailType": "SalesItemLineDetail",
+			"SalesItemLineDetail": map[string]interface{}{
+				"ItemRef":   map[string]string{"value": strconv.Itoa(1 + rng.Intn(20))},
+				"Qty":       qty,
+				"UnitPrice": price,
+			},
+		}
+	}
+	return map[string]interface{}{
+		"CustomerRef": map[string]string{"value": strconv.Itoa(1 + rng.Intn(50))},
+		"Line":        lines,
+	}
+}
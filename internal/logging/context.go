@@ -0,0 +1,72 @@
+// logging/context.go
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// contextKey is the type for logging context keys
+type contextKey string
+
+// Context keys
+const (
+	loggerKey    contextKey = "logger"
+	requestIDKey contextKey = "request_id"
+	requestKey   contextKey = "request_log"
+)
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the context's logger, defaulting to slog's default
+// logger for work started outside a request
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a context whose logger adds the given attributes, e.g. the
+// user and company once a request is authenticated. Attributes added while
+// serving a request also appear on its access log line.
+func With(ctx context.Context, args ...any) context.Context {
+	if entry, ok := ctx.Value(requestKey).(*requestEntry); ok {
+		entry.add(args)
+	}
+	return context.WithValue(ctx, loggerKey, FromContext(ctx).With(args...))
+}
+
+// RequestID returns the ID of the request being served, if any
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// requestEntry collects attributes for a request's access log line from
+// handlers further down the chain
+type requestEntry struct {
+	mu   sync.Mutex
+	args []any
+}
+
+// add records attributes
+func (e *requestEntry) add(args []any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.args = append(e.args, args...)
+}
+
+// attrs returns the recorded attributes
+func (e *requestEntry) attrs() []any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]any(nil), e.args...)
+}
@@ -0,0 +1,34 @@
+// logging/logger.go
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing to out at the given level ("debug", "info",
+// "warn" or "error", defaulting to info) in the given format, JSON for log
+// collectors or text for development
+func New(out io.Writer, level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: ParseLevel(level)}
+	if strings.EqualFold(format, FormatJSON) {
+		return slog.New(slog.NewJSONHandler(out, options))
+	}
+	return slog.New(slog.NewTextHandler(out, options))
+}
+
+// ParseLevel parses a level name, defaulting to info
+func ParseLevel(level string) slog.Level {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return parsed
+}
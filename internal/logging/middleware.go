@@ -0,0 +1,120 @@
+// logging/middleware.go
+package logging
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// RequestIDHeader carries the request ID, taken from the client or a proxy
+// when provided and echoed on every response
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern bounds the request IDs accepted from clients, so they
+// cannot inject arbitrary text into logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// Middleware assigns each request an ID, gives handlers a logger carrying
+// it, and logs the request's method, path, status and duration once served
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			requestID := r.Header.Get(RequestIDHeader)
+			if !requestIDPattern.MatchString(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			entry := &requestEntry{}
+			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+			ctx = context.WithValue(ctx, requestKey, entry)
+			ctx = WithLogger(ctx, logger.With("request_id", requestID))
+
+			writer := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(writer, r.WithContext(ctx))
+
+			status := writer.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			args := []any{
+				"request_id", requestID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"duration_ms", time.Since(started).Milliseconds(),
+				"bytes", writer.bytes,
+			}
+			logger.Log(ctx, level, "request served", append(args, entry.attrs()...)...)
+		})
+	}
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusWriter records the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body size
+func (s *statusWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += n
+	return n, err
+}
+
+// Flush passes through to streaming responses
+func (s *statusWriter) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack passes through to WebSocket upgrades
+func (s *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	if s.status == 0 {
+		s.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
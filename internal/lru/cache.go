@@ -0,0 +1,143 @@
+// lru/cache.go
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Stats are a cache's size and counters since it was created
+type Stats struct {
+	Name      string `json:"name"`
+	Capacity  int    `json:"capacity"`
+	Size      int    `json:"size"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"` // entries dropped to make room
+}
+
+// element is a cache entry in recency order
+type element[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// Cache is a size-limited, concurrency-safe map that evicts its least
+// recently used entry when full
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	name     string
+	capacity int
+	order    *list.List // most recently used first
+	items    map[K]*list.Element
+
+	hits, misses, evictions uint64
+}
+
+// New creates a cache holding up to the capacity configured for name, or
+// defaultCapacity when none is, and registers it for metrics
+func New[K comparable, V any](name string, defaultCapacity int) *Cache[K, V] {
+	c := &Cache[K, V]{
+		name:     name,
+		capacity: Capacity(name, defaultCapacity),
+		order:    list.New(),
+		items:    make(map[K]*list.Element),
+	}
+	register(c)
+	return c
+}
+
+// Get returns the value of key and marks it recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.hits++
+		c.order.MoveToFront(e)
+		return e.Value.(*element[K, V]).value, true
+	}
+	c.misses++
+	var zero V
+	return zero, false
+}
+
+// Add sets the value of key, evicting the least recently used entry when
+// the cache is full
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*element[K, V]).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&element[K, V]{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*element[K, V]).key)
+		c.evictions++
+	}
+}
+
+// Remove drops key
+func (c *Cache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// RemoveFunc drops every entry for which fn returns true
+func (c *Cache[K, V]) RemoveFunc(fn func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.items {
+		if fn(key, e.Value.(*element[K, V]).value) {
+			c.order.Remove(e)
+			delete(c.items, key)
+		}
+	}
+}
+
+// Purge drops every entry
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.items = make(map[K]*list.Element)
+}
+
+// Snapshot returns a copy of every entry, without marking them used
+func (c *Cache[K, V]) Snapshot() map[K]V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make(map[K]V, len(c.items))
+	for key, e := range c.items {
+		snapshot[key] = e.Value.(*element[K, V]).value
+	}
+	return snapshot
+}
+
+// Len returns the number of entries
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the cache's size and counters
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Name:      c.name,
+		Capacity:  c.capacity,
+		Size:      c.order.Len(),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
@@ -0,0 +1,77 @@
+// lru/registry.go
+package lru
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// statser is a cache reporting its stats
+type statser interface {
+	Stats() Stats
+}
+
+var (
+	mu         sync.Mutex
+	capacities = make(map[string]int)
+	caches     []statser
+)
+
+// Configure overrides the capacities of caches by name. It applies to
+// caches created afterwards, so it is called before the caches' owners are.
+func Configure(overrides map[string]int) {
+	mu.Lock()
+	defer mu.Unlock()
+	for name, capacity := range overrides {
+		if capacity > 0 {
+			capacities[name] = capacity
+		}
+	}
+}
+
+// Capacity returns the configured capacity of the named cache, or
+// defaultCapacity when none is
+func Capacity(name string, defaultCapacity int) int {
+	mu.Lock()
+	defer mu.Unlock()
+	if capacity, ok := capacities[name]; ok {
+		return capacity
+	}
+	if defaultCapacity < 1 {
+		return 1
+	}
+	return defaultCapacity
+}
+
+// register adds a cache to those reported
+func register(c statser) {
+	mu.Lock()
+	defer mu.Unlock()
+	caches = append(caches, c)
+}
+
+// All returns the stats of every cache, by name
+func All() []Stats {
+	mu.Lock()
+	registered := append([]statser(nil), caches...)
+	mu.Unlock()
+
+	stats := make([]Stats, 0, len(registered))
+	for _, c := range registered {
+		stats = append(stats, c.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// Handler returns the size, hit and eviction counts of every cache, so
+// operators can size capacities
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(All())
+}
@@ -0,0 +1,159 @@
+// metering/handler.go
+package metering
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for usage and plans
+type Handler struct {
+	meter *Meter
+}
+
+// NewHandler creates a new metering handler
+func NewHandler(meter *Meter) *Handler {
+	return &Handler{
+		meter: meter,
+	}
+}
+
+// UsageReport is a tenant's usage in a billing period against its plan
+type UsageReport struct {
+	TenantID  string           `json:"tenant_id"`
+	Period    string           `json:"period"`
+	Plan      string           `json:"plan,omitempty"`
+	Usage     map[string]int64 `json:"usage"`
+	Limits    map[string]int64 `json:"limits,omitempty"`
+	Remaining map[string]int64 `json:"remaining,omitempty"`
+	ResetsAt  time.Time        `json:"resets_at"`
+}
+
+// report builds a tenant's usage report for ?period=YYYY-MM, defaulting to
+// the current period
+func (h *Handler) report(w http.ResponseWriter, r *http.Request, tenantID string) {
+	period := r.URL.Query().Get("period")
+	start := time.Now().UTC()
+	if period != "" {
+		var err error
+		if start, err = time.Parse(periodLayout, period); err != nil {
+			http.Error(w, "Invalid period, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	}
+	period = Period(start)
+
+	usage, err := h.meter.Usage(r.Context(), tenantID, period)
+	if err != nil {
+		http.Error(w, "Failed to get usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	plan, err := h.meter.TenantPlan(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "Failed to get plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := UsageReport{
+		TenantID: tenantID,
+		Period:   period,
+		Usage:    usage,
+		ResetsAt: periodEnd(start),
+	}
+	if plan != nil {
+		report.Plan = plan.Name
+		report.Limits = make(map[string]int64)
+		report.Remaining = make(map[string]int64)
+		for metric, limit := range plan.Limits {
+			if limit <= 0 {
+				continue
+			}
+			report.Limits[metric] = limit
+			report.Remaining[metric] = max(limit-usage[metric], 0)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetUsage returns the usage counted for the caller
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	h.report(w, r, Subject(r.Context()))
+}
+
+// GetTenantUsage returns any tenant's usage, for billing
+func (h *Handler) GetTenantUsage(w http.ResponseWriter, r *http.Request) {
+	h.report(w, r, mux.Vars(r)["tenantID"])
+}
+
+// ListPlans returns every plan
+func (h *Handler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.meter.ListPlans(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list plans: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(plans)
+}
+
+// SavePlan creates or replaces the plan named in the path
+func (h *Handler) SavePlan(w http.ResponseWriter, r *http.Request) {
+	var plan Plan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	plan.Name = mux.Vars(r)["name"]
+	if err := plan.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.meter.SavePlan(r.Context(), &plan); err != nil {
+		http.Error(w, "Failed to save plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// DeletePlan removes the plan named in the path
+func (h *Handler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	if err := h.meter.DeletePlan(r.Context(), mux.Vars(r)["name"]); err != nil {
+		http.Error(w, "Failed to delete plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AssignPlan puts a tenant on the plan named in the body; an empty name
+// removes the tenant's plan
+func (h *Handler) AssignPlan(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Plan string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.meter.AssignPlan(r.Context(), mux.Vars(r)["tenantID"], body.Plan); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrPlanNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "Failed to assign plan: "+err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+// metering/meter.go
+package metering
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/go-redis/redis/v8"
+)
+
+// Metered quantities
+const (
+	APICalls        = "api_calls"        // requests to the /api routes
+	QuickBooksCalls = "quickbooks_calls" // requests made to the QuickBooks API
+	AgentTokens     = "agent_tokens"     // prompt and completion tokens
+	ExportBytes     = "export_bytes"     // size of exported files
+	ExportRows      = "export_rows"      // rows delivered to data warehouses
+)
+
+// Metrics lists every metered quantity
+var Metrics = []string{APICalls, QuickBooksCalls, AgentTokens, ExportBytes, ExportRows}
+
+// retention is how long monthly usage is kept for billing
+const retention = 400 * 24 * time.Hour
+
+// periodLayout formats the monthly billing periods usage is counted in
+const periodLayout = "2006-01"
+
+// Period returns the billing period containing t
+func Period(t time.Time) string {
+	return t.UTC().Format(periodLayout)
+}
+
+// periodEnd returns when the billing period containing t ends
+func periodEnd(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Meter counts each tenant's usage per monthly billing period and enforces
+// the quotas of the tenant's plan
+type Meter struct {
+	client redis.UniversalClient
+	prefix string
+
+	plans *lru.Cache[string, cachedPlan] // by tenant
+}
+
+// NewMeter creates a new usage meter
+func NewMeter(client redis.UniversalClient, prefix string) *Meter {
+	return &Meter{
+		client: client,
+		prefix: prefix,
+		plans:  lru.New[string, cachedPlan]("metering.plans", 10000),
+	}
+}
+
+// usageKey returns the hash of a tenant's usage in a period
+func (m *Meter) usageKey(tenantID, period string) string {
+	return fmt.Sprintf("%s:metering:usage:%s:%s", m.prefix, tenantID, period)
+}
+
+// Add counts n units of a metric for the tenant in the current period
+func (m *Meter) Add(ctx context.Context, tenantID, metric string, n int64) error {
+	if tenantID == "" || n == 0 {
+		return nil
+	}
+	key := m.usageKey(tenantID, Period(time.Now()))
+	_, err := m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, metric, n)
+		pipe.Expire(ctx, key, retention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record %s usage: %w", metric, err)
+	}
+	return nil
+}
+
+// Usage returns the tenant's usage of every metric in a period
+func (m *Meter) Usage(ctx context.Context, tenantID, period string) (map[string]int64, error) {
+	values, err := m.client.HGetAll(ctx, m.usageKey(tenantID, period)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	usage := make(map[string]int64, len(Metrics))
+	for _, metric := range Metrics {
+		usage[metric], _ = strconv.ParseInt(values[metric], 10, 64)
+	}
+	return usage, nil
+}
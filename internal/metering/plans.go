@@ -0,0 +1,195 @@
+// metering/plans.go
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// planTTL is how long a tenant's plan is cached in memory
+const planTTL = time.Minute
+
+// ErrPlanNotFound is returned for a plan that does not exist
+var ErrPlanNotFound = errors.New("plan not found")
+
+// UntenantedPlan names the plan of callers without a verified tenant, whose
+// usage is counted per user. Saving a plan under this name replaces its
+// built-in limits.
+const UntenantedPlan = "untenanted"
+
+// untenantedLimits are the built-in monthly limits of UntenantedPlan
+var untenantedLimits = map[string]int64{
+	APICalls:        1000,
+	QuickBooksCalls: 1000,
+	AgentTokens:     50000,
+	ExportBytes:     10 << 20,
+	ExportRows:      10000,
+}
+
+// Plan sets monthly quotas per metric. Metrics without a positive limit
+// are unlimited, as is every metric for tenants without a plan.
+type Plan struct {
+	Name   string           `json:"name"`
+	Limits map[string]int64 `json:"limits"`
+}
+
+// Validate checks a plan's limits
+func (p *Plan) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	known := make(map[string]bool, len(Metrics))
+	for _, metric := range Metrics {
+		known[metric] = true
+	}
+	for metric, limit := range p.Limits {
+		if !known[metric] {
+			return fmt.Errorf("unknown metric %q", metric)
+		}
+		if limit < 0 {
+			return fmt.Errorf("limit for %s must not be negative", metric)
+		}
+	}
+	return nil
+}
+
+// cachedPlan is a tenant's plan as last read
+type cachedPlan struct {
+	plan    *Plan
+	fetched time.Time
+}
+
+// plansKey returns the hash of plans by name
+func (m *Meter) plansKey() string {
+	return fmt.Sprintf("%s:metering:plans", m.prefix)
+}
+
+// assignmentsKey returns the hash of plan names by tenant
+func (m *Meter) assignmentsKey() string {
+	return fmt.Sprintf("%s:metering:tenant_plans", m.prefix)
+}
+
+// SavePlan creates or replaces a plan
+func (m *Meter) SavePlan(ctx context.Context, plan *Plan) error {
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	if err := m.client.HSet(ctx, m.plansKey(), plan.Name, data).Err(); err != nil {
+		return fmt.Errorf("failed to save plan: %w", err)
+	}
+	m.forget()
+	return nil
+}
+
+// GetPlan returns a plan by name
+func (m *Meter) GetPlan(ctx context.Context, name string) (*Plan, error) {
+	data, err := m.client.HGet(ctx, m.plansKey(), name).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %s", ErrPlanNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+
+	var plan Plan
+	if err := json.Unmarshal([]byte(data), &plan); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// ListPlans returns every plan ordered by name
+func (m *Meter) ListPlans(ctx context.Context) ([]Plan, error) {
+	values, err := m.client.HGetAll(ctx, m.plansKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+
+	plans := make([]Plan, 0, len(values))
+	for _, v := range values {
+		var plan Plan
+		if err := json.Unmarshal([]byte(v), &plan); err != nil {
+			continue
+		}
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Name < plans[j].Name })
+	return plans, nil
+}
+
+// DeletePlan removes a plan. Tenants still assigned to it become unlimited.
+func (m *Meter) DeletePlan(ctx context.Context, name string) error {
+	if err := m.client.HDel(ctx, m.plansKey(), name).Err(); err != nil {
+		return fmt.Errorf("failed to delete plan: %w", err)
+	}
+	m.forget()
+	return nil
+}
+
+// AssignPlan puts a tenant on a plan; an empty name removes its plan
+func (m *Meter) AssignPlan(ctx context.Context, tenantID, name string) error {
+	var err error
+	if name == "" {
+		err = m.client.HDel(ctx, m.assignmentsKey(), tenantID).Err()
+	} else {
+		if _, err := m.GetPlan(ctx, name); err != nil {
+			return err
+		}
+		err = m.client.HSet(ctx, m.assignmentsKey(), tenantID, name).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to assign plan: %w", err)
+	}
+	m.forget()
+	return nil
+}
+
+// TenantPlan returns a tenant's plan, or nil if it has none. Users metered
+// without a verified tenant have UntenantedPlan. Plans are cached briefly,
+// so changes reach other instances within planTTL.
+func (m *Meter) TenantPlan(ctx context.Context, tenantID string) (*Plan, error) {
+	cached, ok := m.plans.Get(tenantID)
+	if ok && time.Since(cached.fetched) < planTTL {
+		return cached.plan, nil
+	}
+
+	var plan *Plan
+	name, err := UntenantedPlan, error(nil)
+	if !strings.HasPrefix(tenantID, userSubject) {
+		name, err = m.client.HGet(ctx, m.assignmentsKey(), tenantID).Result()
+	}
+	switch {
+	case err == redis.Nil:
+	case err != nil:
+		return nil, fmt.Errorf("failed to get tenant plan: %w", err)
+	default:
+		plan, err = m.GetPlan(ctx, name)
+		switch {
+		case errors.Is(err, ErrPlanNotFound) && name == UntenantedPlan:
+			plan = &Plan{Name: UntenantedPlan, Limits: untenantedLimits}
+		case errors.Is(err, ErrPlanNotFound):
+			plan = nil
+		case err != nil:
+			return nil, err
+		}
+	}
+
+	m.plans.Add(tenantID, cachedPlan{plan: plan, fetched: time.Now()})
+	return plan, nil
+}
+
+// forget drops the cached plans after a change
+func (m *Meter) forget() {
+	m.plans.Purge()
+}
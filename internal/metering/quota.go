@@ -0,0 +1,170 @@
+// metering/quota.go
+package metering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrQuotaExceeded is returned when a tenant has used up a monthly quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// userSubject prefixes the usage of callers without a verified tenant
+const userSubject = "user:"
+
+// Subject returns whom a caller's usage is counted for: the tenant an API
+// key or membership verified, or else the user, under UntenantedPlan. It is
+// "" for anonymous calls, which are not counted.
+func Subject(ctx context.Context) string {
+	if tenantID, err := auth.GetVerifiedTenantID(ctx); err == nil {
+		return tenantID
+	}
+	if userID := auth.GetUserID(ctx); userID != "" {
+		return userSubject + userID
+	}
+	return ""
+}
+
+// consume counts usage of ARGV[1] by ARGV[2] units unless that would pass
+// a limit. ARGV[4] onwards pairs limited metrics with their limits: the
+// counted metric may not go past its limit, and the others must not have
+// reached theirs. It returns the first metric over its limit, or "".
+var consume = redis.NewScript(`
+for i = 4, #ARGV, 2 do
+	local used = tonumber(redis.call("HGET", KEYS[1], ARGV[i]) or "0")
+	local need = 1
+	if ARGV[i] == ARGV[1] then
+		need = tonumber(ARGV[2])
+	end
+	if used + need > tonumber(ARGV[i + 1]) then
+		return ARGV[i]
+	end
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], ARGV[2])
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+return ""`)
+
+// Consume counts n units of a metric for the tenant in the current period,
+// unless that would take it past its plan's limit for the metric or the
+// tenant has used up any of the other metrics given. Then it counts nothing
+// and returns ErrQuotaExceeded. Checking and counting are one step, so
+// concurrent requests cannot all pass the check before any is counted.
+func (m *Meter) Consume(ctx context.Context, tenantID, metric string, n int64, metrics ...string) error {
+	if tenantID == "" || n == 0 {
+		return nil
+	}
+	plan, err := m.TenantPlan(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{metric, n, int64(retention / time.Second)}
+	if plan != nil {
+		for _, limited := range append([]string{metric}, metrics...) {
+			if limit := plan.Limits[limited]; limit > 0 {
+				args = append(args, limited, limit)
+			}
+		}
+	}
+	key := m.usageKey(tenantID, Period(time.Now()))
+	exceeded, err := consume.Run(ctx, m.client, []string{key}, args...).Text()
+	if err != nil {
+		return fmt.Errorf("failed to record %s usage: %w", metric, err)
+	}
+	if exceeded != "" {
+		return fmt.Errorf("%w: %s plan allows %d %s per month", ErrQuotaExceeded, plan.Name, plan.Limits[exceeded], exceeded)
+	}
+	return nil
+}
+
+// Check returns ErrQuotaExceeded if the tenant has reached its plan's
+// limit for any of the metrics in the current period
+func (m *Meter) Check(ctx context.Context, tenantID string, metrics ...string) error {
+	plan, err := m.TenantPlan(ctx, tenantID)
+	if err != nil || plan == nil {
+		return err
+	}
+
+	var limited []string
+	for _, metric := range metrics {
+		if plan.Limits[metric] > 0 {
+			limited = append(limited, metric)
+		}
+	}
+	if len(limited) == 0 {
+		return nil
+	}
+
+	period := Period(time.Now())
+	values, err := m.client.HMGet(ctx, m.usageKey(tenantID, period), limited...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get usage: %w", err)
+	}
+	for i, metric := range limited {
+		str, _ := values[i].(string)
+		used, _ := strconv.ParseInt(str, 10, 64)
+		if limit := plan.Limits[metric]; used >= limit {
+			return fmt.Errorf("%w: %s plan allows %d %s per month", ErrQuotaExceeded, plan.Name, limit, metric)
+		}
+	}
+	return nil
+}
+
+// Middleware refuses API requests with 429 once the caller has used up its
+// API or QuickBooks call quota, and counts the requests it lets through.
+// Callers without a verified tenant are held to UntenantedPlan. The usage
+// report stays reachable so callers can see why.
+func (m *Meter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if routing.StripVersion(r.URL.Path) == "/api/usage" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := m.Consume(r.Context(), Subject(r.Context()), APICalls, 1, QuickBooksCalls)
+		if errors.Is(err, ErrQuotaExceeded) {
+			retry := time.Until(periodEnd(time.Now())) / time.Second
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(retry)+1, 10))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			// Metering outages should not take the API down
+			logging.FromContext(r.Context()).Warn("failed to meter API call", "error", err)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Transport wraps an HTTP transport so QuickBooks API calls are counted
+// for the caller they are made for
+func (m *Meter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{meter: m, base: base}
+}
+
+// transport counts QuickBooks calls
+type transport struct {
+	meter *Meter
+	base  http.RoundTripper
+}
+
+// RoundTrip counts the call, then forwards it
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.meter.Add(req.Context(), Subject(req.Context()), QuickBooksCalls, 1); err != nil {
+		logging.FromContext(req.Context()).Warn("failed to meter QuickBooks call", "error", err)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,68 @@
+// migration/handler.go
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/operations"
+)
+
+// maxImportSize limits uploaded IIF/QBXML files to 10 MB
+const maxImportSize = 10 << 20
+
+// Handler provides HTTP handlers for QuickBooks Desktop list imports
+type Handler struct {
+	importer   *Importer
+	operations *operations.Manager
+}
+
+// NewHandler creates a new migration handler
+func NewHandler(importer *Importer, operations *operations.Manager) *Handler {
+	return &Handler{
+		importer:   importer,
+		operations: operations,
+	}
+}
+
+// ImportLists imports customers and items from an IIF or QBXML file in the
+// request body. ?format= is iif or qbxml (detected when omitted) and
+// ?dry_run=true validates without creating anything. Asynchronous requests
+// are answered with an operation whose result is the report.
+func (h *Handler) ImportLists(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	list, err := Parse(http.MaxBytesReader(w, r.Body, maxImportSize), r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, "Invalid import file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(list.Customers) == 0 && len(list.Items) == 0 {
+		http.Error(w, "Import file contains no customers or items", http.StatusBadRequest)
+		return
+	}
+
+	if operations.Async(r) {
+		op, err := h.operations.Start(r.Context(), auth.GetTenantID(r.Context()), "list_import", func(ctx context.Context) (interface{}, error) {
+			return h.importer.Import(ctx, list, dryRun)
+		})
+		if err != nil {
+			http.Error(w, "Failed to start import: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		operations.Accepted(w, r, op)
+		return
+	}
+
+	report, err := h.importer.Import(r.Context(), list, dryRun)
+	if err != nil {
+		http.Error(w, "Failed to import lists: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,148 @@
+// migration/iif.go
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// iifItemTypes maps IIF INVITEMTYPE codes to QuickBooks Online item types
+var iifItemTypes = map[string]string{
+	"SERV":      ItemService,
+	"PART":      ItemNonInventory,
+	"OTHC":      ItemNonInventory,
+	"INVENTORY": ItemInventory,
+}
+
+// ParseIIF reads CUST and INVITEM records from an IIF file. Other record
+// types (transactions, accounts, vendors) are ignored.
+func ParseIIF(r io.Reader) (*List, error) {
+	list := &List{}
+	headers := make(map[string][]string)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		recordType := strings.ToUpper(strings.TrimSpace(fields[0]))
+
+		// Header rows ("!CUST") name the columns of the records that follow
+		if strings.HasPrefix(recordType, "!") {
+			columns := make([]string, len(fields))
+			for i, f := range fields {
+				columns[i] = strings.ToUpper(strings.TrimSpace(f))
+			}
+			headers[strings.TrimPrefix(recordType, "!")] = columns
+			continue
+		}
+
+		columns, ok := headers[recordType]
+		if !ok {
+			if recordType == "CUST" || recordType == "INVITEM" {
+				return nil, fmt.Errorf("line %d: %s record before its !%s header", lineNum, recordType, recordType)
+			}
+			continue
+		}
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if i < len(fields) {
+				row[column] = unquoteIIF(fields[i])
+			}
+		}
+
+		switch recordType {
+		case "CUST":
+			list.Customers = append(list.Customers, iifCustomer(row))
+		case "INVITEM":
+			list.Items = append(list.Items, iifItem(row))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read IIF file: %w", err)
+	}
+
+	return list, nil
+}
+
+// iifCustomer maps a CUST row
+func iifCustomer(row map[string]string) Customer {
+	customer := Customer{
+		Name:        row["NAME"],
+		CompanyName: row["COMPANYNAME"],
+		GivenName:   row["FIRSTNAME"],
+		FamilyName:  row["LASTNAME"],
+		Email:       row["EMAIL"],
+		Phone:       row["PHONE1"],
+		Fax:         row["FAXNUM"],
+		Notes:       row["NOTE"],
+		Taxable:     iifBool(row["TAXABLE"]),
+		Active:      row["HIDDEN"] != "Y",
+	}
+	for i := 1; i <= 5; i++ {
+		if v := row[fmt.Sprintf("BADDR%d", i)]; v != "" {
+			customer.BillAddr = append(customer.BillAddr, v)
+		}
+		if v := row[fmt.Sprintf("SADDR%d", i)]; v != "" {
+			customer.ShipAddr = append(customer.ShipAddr, v)
+		}
+	}
+	return customer
+}
+
+// iifItem maps an INVITEM row
+func iifItem(row map[string]string) Item {
+	sourceType := strings.ToUpper(row["INVITEMTYPE"])
+	return Item{
+		Name:           row["NAME"],
+		Type:           iifItemTypes[sourceType],
+		SourceType:     sourceType,
+		Description:    row["DESC"],
+		PurchaseDesc:   row["PURCHASEDESC"],
+		Price:          iifFloat(row["PRICE"]),
+		Cost:           iifFloat(row["COST"]),
+		IncomeAccount:  row["ACCNT"],
+		ExpenseAccount: row["COGSACCNT"],
+		AssetAccount:   row["ASSETACCNT"],
+		QtyOnHand:      iifFloat(row["QNTY"]),
+		Taxable:        iifBool(row["TAXABLE"]),
+		Active:         row["HIDDEN"] != "Y",
+	}
+}
+
+// unquoteIIF strips the quotes Desktop adds around fields with commas
+func unquoteIIF(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return s
+}
+
+// iifFloat parses an IIF amount, which may contain thousands separators
+func iifFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return v
+}
+
+// iifBool parses a Y/N flag; blanks are unset
+func iifBool(s string) *bool {
+	switch strings.ToUpper(s) {
+	case "Y":
+		v := true
+		return &v
+	case "N":
+		v := false
+		return &v
+	}
+	return nil
+}
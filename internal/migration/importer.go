@@ -0,0 +1,426 @@
+// migration/importer.go
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Import statuses
+const (
+	StatusValid   = "valid"   // dry run: would be created
+	StatusInvalid = "invalid" // failed validation
+	StatusExists  = "exists"  // already in QuickBooks Online; left unchanged
+	StatusSkipped = "skipped" // record type has no QuickBooks Online equivalent
+	StatusCreated = "created"
+	StatusError   = "error" // rejected by QuickBooks
+)
+
+// QuickBooks Online name limits
+const (
+	maxDisplayNameLength = 500
+	maxItemNameLength    = 100
+)
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// QuickBooks is the subset of the QuickBooks client used for imports
+type QuickBooks interface {
+	Querier
+	Batch(ctx context.Context, items []qbclient.BatchItem) (map[string]qbclient.BatchResult, error)
+}
+
+// Result is the outcome for one imported record
+type Result struct {
+	Name   string   `json:"name"`
+	Status string   `json:"status"`
+	ID     string   `json:"id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Report summarizes an import
+type Report struct {
+	DryRun    bool           `json:"dry_run"`
+	Customers []Result       `json:"customers"`
+	Items     []Result       `json:"items"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// named is a QuickBooks list entity with its hierarchical name
+type named struct {
+	ID                 string `json:"Id"`
+	Name               string `json:"Name"`
+	FullyQualifiedName string `json:"FullyQualifiedName"`
+}
+
+// Importer maps Desktop lists into QuickBooks Online entities
+type Importer struct {
+	qb QuickBooks
+}
+
+// NewImporter creates a new list importer
+func NewImporter(qb QuickBooks) *Importer {
+	return &Importer{
+		qb: qb,
+	}
+}
+
+// pending is a validated record awaiting creation
+type pending struct {
+	result  *Result
+	name    string // full name
+	entity  string
+	payload func(parentID string) map[string]interface{}
+}
+
+// Import validates every record and, unless dryRun, creates the valid ones
+// through the batch API. Parents are created before their children so jobs
+// and sub-items can reference them. Existing entities are never modified.
+func (i *Importer) Import(ctx context.Context, list *List, dryRun bool) (*Report, error) {
+	// Inactive entities still reserve their names
+	customers, err := query.All[named](ctx, i.qb, query.Select("Customer", "Id", "DisplayName", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	items, err := query.All[named](ctx, i.qb, query.Select("Item", "Id", "Name", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := query.All[named](ctx, i.qb, query.Select("Account", "Id", "Name", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+
+	customerIDs := index(customers)
+	itemIDs := index(items)
+	accountIDs := index(accounts)
+	for _, a := range accounts {
+		// Desktop exports may name sub-accounts by leaf or full name
+		if _, ok := accountIDs[key(a.Name)]; !ok {
+			accountIDs[key(a.Name)] = a.ID
+		}
+	}
+
+	report := &Report{
+		DryRun:    dryRun,
+		Customers: make([]Result, len(list.Customers)),
+		Items:     make([]Result, len(list.Items)),
+		Counts:    make(map[string]int),
+	}
+
+	var customerQueue, itemQueue []pending
+	seen := make(map[string]bool)
+	for n, c := range list.Customers {
+		result := &report.Customers[n]
+		result.Name = c.Name
+		if p, ok := i.checkCustomer(c, result, customerIDs, seen); ok {
+			customerQueue = append(customerQueue, p)
+		}
+	}
+	seen = make(map[string]bool)
+	for n, item := range list.Items {
+		result := &report.Items[n]
+		result.Name = item.Name
+		if p, ok := i.checkItem(item, result, itemIDs, accountIDs, seen); ok {
+			itemQueue = append(itemQueue, p)
+		}
+	}
+
+	if !dryRun {
+		operations.SetTotal(ctx, len(customerQueue)+len(itemQueue))
+		if err := i.create(ctx, customerQueue, customerIDs); err != nil {
+			return nil, err
+		}
+		if err := i.create(ctx, itemQueue, itemIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range report.Customers {
+		report.Counts[r.Status]++
+	}
+	for _, r := range report.Items {
+		report.Counts[r.Status]++
+	}
+	return report, nil
+}
+
+// checkCustomer validates a customer and prepares its payload
+func (i *Importer) checkCustomer(c Customer, result *Result, existing map[string]string, seen map[string]bool) (pending, bool) {
+	parent, name := splitName(c.Name)
+	switch {
+	case strings.TrimSpace(name) == "":
+		result.Errors = append(result.Errors, "name is required")
+	case len(name) > maxDisplayNameLength:
+		result.Errors = append(result.Errors, fmt.Sprintf("name exceeds %d characters", maxDisplayNameLength))
+	case seen[key(c.Name)]:
+		result.Errors = append(result.Errors, "duplicate name in import file")
+	}
+	seen[key(c.Name)] = true
+
+	if id, ok := existing[key(c.Name)]; ok && len(result.Errors) == 0 {
+		result.Status, result.ID = StatusExists, id
+		return pending{}, false
+	}
+	if parent != "" && !seen[key(parent)] && existing[key(parent)] == "" {
+		result.Errors = append(result.Errors, "parent customer "+parent+" is neither in QuickBooks nor earlier in the file")
+	}
+	if len(result.Errors) > 0 {
+		result.Status = StatusInvalid
+		return pending{}, false
+	}
+	result.Status = StatusValid
+
+	return pending{
+		result: result,
+		name:   c.Name,
+		entity: "Customer",
+		payload: func(parentID string) map[string]interface{} {
+			payload := map[string]interface{}{
+				"DisplayName": name,
+				"Active":      c.Active,
+			}
+			setIf(payload, "CompanyName", c.CompanyName)
+			setIf(payload, "GivenName", c.GivenName)
+			setIf(payload, "FamilyName", c.FamilyName)
+			setIf(payload, "Notes", c.Notes)
+			if c.Email != "" {
+				payload["PrimaryEmailAddr"] = map[string]string{"Address": c.Email}
+			}
+			if c.Phone != "" {
+				payload["PrimaryPhone"] = map[string]string{"FreeFormNumber": c.Phone}
+			}
+			if c.Fax != "" {
+				payload["Fax"] = map[string]string{"FreeFormNumber": c.Fax}
+			}
+			if addr := address(c.BillAddr); addr != nil {
+				payload["BillAddr"] = addr
+			}
+			if addr := address(c.ShipAddr); addr != nil {
+				payload["ShipAddr"] = addr
+			}
+			if c.Taxable != nil {
+				payload["Taxable"] = *c.Taxable
+			}
+			if parentID != "" {
+				payload["Job"] = true
+				payload["ParentRef"] = map[string]string{"value": parentID}
+			}
+			return payload
+		},
+	}, true
+}
+
+// checkItem validates an item, resolves its accounts and prepares its payload
+func (i *Importer) checkItem(item Item, result *Result, existing, accounts map[string]string, seen map[string]bool) (pending, bool) {
+	if item.Type == "" {
+		result.Status = StatusSkipped
+		result.Errors = []string{item.SourceType + " items are not supported by QuickBooks Online"}
+		return pending{}, false
+	}
+
+	parent, name := splitName(item.Name)
+	switch {
+	case strings.TrimSpace(name) == "":
+		result.Errors = append(result.Errors, "name is required")
+	case len(name) > maxItemNameLength:
+		result.Errors = append(result.Errors, fmt.Sprintf("name exceeds %d characters", maxItemNameLength))
+	case seen[key(item.Name)]:
+		result.Errors = append(result.Errors, "duplicate name in import file")
+	}
+	seen[key(item.Name)] = true
+
+	if id, ok := existing[key(item.Name)]; ok && len(result.Errors) == 0 {
+		result.Status, result.ID = StatusExists, id
+		return pending{}, false
+	}
+	if parent != "" && !seen[key(parent)] && existing[key(parent)] == "" {
+		result.Errors = append(result.Errors, "parent item "+parent+" is neither in QuickBooks nor earlier in the file")
+	}
+
+	resolve := func(label, account string, required bool) string {
+		if account == "" {
+			if required {
+				result.Errors = append(result.Errors, label+" is required")
+			}
+			return ""
+		}
+		id, ok := accounts[key(account)]
+		if !ok {
+			result.Errors = append(result.Errors, label+" "+account+" does not exist in QuickBooks")
+		}
+		return id
+	}
+	inventory := item.Type == ItemInventory
+	incomeID := resolve("income account", item.IncomeAccount, true)
+	expenseID := resolve("expense account", item.ExpenseAccount, inventory)
+	assetID := resolve("asset account", item.AssetAccount, inventory)
+
+	if len(result.Errors) > 0 {
+		result.Status = StatusInvalid
+		return pending{}, false
+	}
+	result.Status = StatusValid
+
+	return pending{
+		result: result,
+		name:   item.Name,
+		entity: "Item",
+		payload: func(parentID string) map[string]interface{} {
+			payload := map[string]interface{}{
+				"Name":             name,
+				"Type":             item.Type,
+				"Active":           item.Active,
+				"UnitPrice":        item.Price,
+				"IncomeAccountRef": map[string]string{"value": incomeID},
+			}
+			setIf(payload, "Description", item.Description)
+			setIf(payload, "PurchaseDesc", item.PurchaseDesc)
+			if item.Cost != 0 {
+				payload["PurchaseCost"] = item.Cost
+			}
+			if expenseID != "" {
+				payload["ExpenseAccountRef"] = map[string]string{"value": expenseID}
+			}
+			if inventory {
+				payload["TrackQtyOnHand"] = true
+				payload["QtyOnHand"] = item.QtyOnHand
+				payload["InvStartDate"] = time.Now().Format("2006-01-02")
+				payload["AssetAccountRef"] = map[string]string{"value": assetID}
+			}
+			if item.Taxable != nil {
+				payload["Taxable"] = *item.Taxable
+			}
+			if parentID != "" {
+				payload["SubItem"] = true
+				payload["ParentRef"] = map[string]string{"value": parentID}
+			}
+			return payload
+		},
+	}, true
+}
+
+// create submits queued records level by level in batches, recording each
+// created ID so children can reference their parents
+func (i *Importer) create(ctx context.Context, queue []pending, ids map[string]string) error {
+	sort.SliceStable(queue, func(a, b int) bool {
+		return depth(queue[a].name) < depth(queue[b].name)
+	})
+
+	for start := 0; start < len(queue); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// A batch never mixes levels, so every parent exists before its children
+		level := depth(queue[start].name)
+		end := start
+		for end < len(queue) && end-start < qbclient.MaxBatchSize && depth(queue[end].name) == level {
+			end++
+		}
+
+		var batch []qbclient.BatchItem
+		for n, p := range queue[start:end] {
+			parentID := ""
+			if parent, _ := splitName(p.name); parent != "" {
+				if parentID = ids[key(parent)]; parentID == "" {
+					p.result.Status = StatusError
+					p.result.Errors = append(p.result.Errors, "parent "+parent+" was not created")
+					operations.Error(ctx, p.name+": parent "+parent+" was not created")
+					continue
+				}
+			}
+			batch = append(batch, qbclient.BatchItem{
+				ID:        strconv.Itoa(start + n),
+				Operation: "create",
+				Entity:    p.entity,
+				Payload:   p.payload(parentID),
+			})
+		}
+
+		if len(batch) > 0 {
+			results, err := i.qb.Batch(ctx, batch)
+			if err != nil {
+				return err
+			}
+			for _, item := range batch {
+				n, _ := strconv.Atoi(item.ID)
+				p := queue[n]
+				result, ok := results[item.ID]
+				switch {
+				case !ok:
+					p.result.Status = StatusError
+					p.result.Errors = append(p.result.Errors, "no response from QuickBooks")
+					operations.Error(ctx, p.name+": no response from QuickBooks")
+				case len(result.Errors) > 0:
+					p.result.Status = StatusError
+					for _, e := range result.Errors {
+						p.result.Errors = append(p.result.Errors, strings.TrimSpace(e.Message+" "+e.Detail))
+					}
+					operations.Error(ctx, p.name+": "+strings.Join(p.result.Errors, "; "))
+				default:
+					var entity named
+					json.Unmarshal(result.Entity, &entity)
+					p.result.Status = StatusCreated
+					p.result.ID = entity.ID
+					ids[key(p.name)] = entity.ID
+				}
+			}
+		}
+		operations.Add(ctx, end-start)
+		start = end
+	}
+	return nil
+}
+
+// index maps lowercased fully qualified names to IDs
+func index(entities []named) map[string]string {
+	ids := make(map[string]string, len(entities))
+	for _, e := range entities {
+		name := e.FullyQualifiedName
+		if name == "" {
+			name = e.Name
+		}
+		ids[key(name)] = e.ID
+	}
+	return ids
+}
+
+// key normalizes names for case-insensitive matching as QuickBooks does
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// address maps up to five Desktop address lines
+func address(lines []string) map[string]string {
+	if len(lines) == 0 {
+		return nil
+	}
+	addr := make(map[string]string)
+	for n, line := range lines {
+		if n >= 5 {
+			break
+		}
+		addr[fmt.Sprintf("Line%d", n+1)] = line
+	}
+	return addr
+}
+
+// setIf sets a payload field when the value is not empty
+func setIf(payload map[string]interface{}, field, value string) {
+	if value != "" {
+		payload[field] = value
+	}
+}
@@ -0,0 +1,99 @@
+// migration/list.go
+package migration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Source formats
+const (
+	FormatIIF   = "iif"
+	FormatQBXML = "qbxml"
+)
+
+// Item types supported by QuickBooks Online
+const (
+	ItemService      = "Service"
+	ItemNonInventory = "NonInventory"
+	ItemInventory    = "Inventory"
+)
+
+// Customer is a customer or job from a QuickBooks Desktop list
+type Customer struct {
+	Name        string   `json:"name"` // full name; jobs are "Parent:Job"
+	CompanyName string   `json:"company_name,omitempty"`
+	GivenName   string   `json:"given_name,omitempty"`
+	FamilyName  string   `json:"family_name,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Phone       string   `json:"phone,omitempty"`
+	Fax         string   `json:"fax,omitempty"`
+	BillAddr    []string `json:"bill_addr,omitempty"`
+	ShipAddr    []string `json:"ship_addr,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	Taxable     *bool    `json:"taxable,omitempty"`
+	Active      bool     `json:"active"`
+}
+
+// Item is a product or service from a QuickBooks Desktop list
+type Item struct {
+	Name           string  `json:"name"` // full name; sub-items are "Parent:Child"
+	Type           string  `json:"type"` // Service, NonInventory or Inventory; empty if unsupported
+	SourceType     string  `json:"source_type"`
+	Description    string  `json:"description,omitempty"`
+	PurchaseDesc   string  `json:"purchase_desc,omitempty"`
+	Price          float64 `json:"price,omitempty"`
+	Cost           float64 `json:"cost,omitempty"`
+	IncomeAccount  string  `json:"income_account,omitempty"`
+	ExpenseAccount string  `json:"expense_account,omitempty"`
+	AssetAccount   string  `json:"asset_account,omitempty"`
+	QtyOnHand      float64 `json:"qty_on_hand,omitempty"`
+	Taxable        *bool   `json:"taxable,omitempty"`
+	Active         bool    `json:"active"`
+}
+
+// List is the set of records parsed from an export file
+type List struct {
+	Customers []Customer `json:"customers"`
+	Items     []Item     `json:"items"`
+}
+
+// Parse reads an IIF or QBXML export; an empty format is detected from the content
+func Parse(r io.Reader, format string) (*List, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import file: %w", err)
+	}
+
+	if format == "" {
+		format = FormatIIF
+		if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("<")) {
+			format = FormatQBXML
+		}
+	}
+
+	switch format {
+	case FormatIIF:
+		return ParseIIF(bytes.NewReader(data))
+	case FormatQBXML:
+		return ParseQBXML(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported import format: %q", format)
+	}
+}
+
+// splitName splits a Desktop full name into its parent path and leaf name
+func splitName(fullName string) (parent, name string) {
+	i := strings.LastIndex(fullName, ":")
+	if i < 0 {
+		return "", fullName
+	}
+	return fullName[:i], fullName[i+1:]
+}
+
+// depth returns how many levels a full name is nested
+func depth(fullName string) int {
+	return strings.Count(fullName, ":")
+}
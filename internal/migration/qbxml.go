@@ -0,0 +1,283 @@
+// migration/qbxml.go
+package migration
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// qbxmlRef is a Desktop list reference
+type qbxmlRef struct {
+	FullName string `xml:"FullName"`
+}
+
+// qbxmlAddress is a Desktop address block
+type qbxmlAddress struct {
+	Addr1      string `xml:"Addr1"`
+	Addr2      string `xml:"Addr2"`
+	Addr3      string `xml:"Addr3"`
+	Addr4      string `xml:"Addr4"`
+	Addr5      string `xml:"Addr5"`
+	City       string `xml:"City"`
+	State      string `xml:"State"`
+	PostalCode string `xml:"PostalCode"`
+	Country    string `xml:"Country"`
+}
+
+// lines flattens the address into non-empty lines
+func (a qbxmlAddress) lines() []string {
+	var lines []string
+	for _, v := range []string{a.Addr1, a.Addr2, a.Addr3, a.Addr4, a.Addr5} {
+		if v != "" {
+			lines = append(lines, v)
+		}
+	}
+	cityLine := strings.TrimSpace(strings.Join(nonBlank(a.City, a.State, a.PostalCode), " "))
+	if cityLine != "" {
+		lines = append(lines, cityLine)
+	}
+	if a.Country != "" {
+		lines = append(lines, a.Country)
+	}
+	return lines
+}
+
+// qbxmlCustomer is a CustomerRet or CustomerAdd element
+type qbxmlCustomer struct {
+	Name            string       `xml:"Name"`
+	FullName        string       `xml:"FullName"`
+	ParentRef       qbxmlRef     `xml:"ParentRef"`
+	IsActive        string       `xml:"IsActive"`
+	CompanyName     string       `xml:"CompanyName"`
+	FirstName       string       `xml:"FirstName"`
+	LastName        string       `xml:"LastName"`
+	Email           string       `xml:"Email"`
+	Phone           string       `xml:"Phone"`
+	Fax             string       `xml:"Fax"`
+	BillAddress     qbxmlAddress `xml:"BillAddress"`
+	ShipAddress     qbxmlAddress `xml:"ShipAddress"`
+	Notes           string       `xml:"Notes"`
+	SalesTaxCodeRef qbxmlRef     `xml:"SalesTaxCodeRef"`
+}
+
+// qbxmlSalesOrPurchase holds pricing for items sold or bought but not both
+type qbxmlSalesOrPurchase struct {
+	Desc       string   `xml:"Desc"`
+	Price      string   `xml:"Price"`
+	AccountRef qbxmlRef `xml:"AccountRef"`
+}
+
+// qbxmlSalesAndPurchase holds pricing for items both sold and bought
+type qbxmlSalesAndPurchase struct {
+	SalesDesc         string   `xml:"SalesDesc"`
+	SalesPrice        string   `xml:"SalesPrice"`
+	IncomeAccountRef  qbxmlRef `xml:"IncomeAccountRef"`
+	PurchaseDesc      string   `xml:"PurchaseDesc"`
+	PurchaseCost      string   `xml:"PurchaseCost"`
+	ExpenseAccountRef qbxmlRef `xml:"ExpenseAccountRef"`
+}
+
+// qbxmlItem is an ItemServiceRet, ItemNonInventoryRet, ItemOtherChargeRet or
+// ItemInventoryRet element (or the matching Add request)
+type qbxmlItem struct {
+	Name             string                 `xml:"Name"`
+	FullName         string                 `xml:"FullName"`
+	ParentRef        qbxmlRef               `xml:"ParentRef"`
+	IsActive         string                 `xml:"IsActive"`
+	SalesTaxCodeRef  qbxmlRef               `xml:"SalesTaxCodeRef"`
+	SalesOrPurchase  *qbxmlSalesOrPurchase  `xml:"SalesOrPurchase"`
+	SalesAndPurchase *qbxmlSalesAndPurchase `xml:"SalesAndPurchase"`
+
+	// Inventory items carry these at the top level
+	SalesDesc        string   `xml:"SalesDesc"`
+	SalesPrice       string   `xml:"SalesPrice"`
+	IncomeAccountRef qbxmlRef `xml:"IncomeAccountRef"`
+	PurchaseDesc     string   `xml:"PurchaseDesc"`
+	PurchaseCost     string   `xml:"PurchaseCost"`
+	COGSAccountRef   qbxmlRef `xml:"COGSAccountRef"`
+	AssetAccountRef  qbxmlRef `xml:"AssetAccountRef"`
+	QuantityOnHand   string   `xml:"QuantityOnHand"`
+}
+
+// qbxmlItemTypes maps QBXML item element prefixes to QuickBooks Online item types
+var qbxmlItemTypes = map[string]string{
+	"ItemService":      ItemService,
+	"ItemNonInventory": ItemNonInventory,
+	"ItemOtherCharge":  ItemNonInventory,
+	"ItemInventory":    ItemInventory,
+}
+
+// qbxmlOtherItems are Desktop item lists with no QuickBooks Online
+// equivalent; they are reported as skipped
+var qbxmlOtherItems = map[string]bool{
+	"ItemGroup":         true,
+	"ItemDiscount":      true,
+	"ItemPayment":       true,
+	"ItemSalesTax":      true,
+	"ItemSalesTaxGroup": true,
+	"ItemSubtotal":      true,
+	"ItemFixedAsset":    true,
+}
+
+// ParseQBXML reads customers and items from a QBXML response (…Ret) or
+// request (…Add) document. Other elements are ignored.
+func ParseQBXML(r io.Reader) (*List, error) {
+	list := &List{}
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.CharsetReader = latin1Reader
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse QBXML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		kind := strings.TrimSuffix(strings.TrimSuffix(start.Name.Local, "Ret"), "Add")
+		if kind == start.Name.Local {
+			continue
+		}
+
+		switch {
+		case kind == "Customer":
+			var c qbxmlCustomer
+			if err := decoder.DecodeElement(&c, &start); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", start.Name.Local, err)
+			}
+			list.Customers = append(list.Customers, c.customer())
+		case qbxmlItemTypes[kind] != "" || qbxmlOtherItems[kind]:
+			var i qbxmlItem
+			if err := decoder.DecodeElement(&i, &start); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", start.Name.Local, err)
+			}
+			list.Items = append(list.Items, i.item(kind))
+		}
+	}
+
+	return list, nil
+}
+
+// customer maps a QBXML customer
+func (c qbxmlCustomer) customer() Customer {
+	return Customer{
+		Name:        fullName(c.FullName, c.ParentRef.FullName, c.Name),
+		CompanyName: c.CompanyName,
+		GivenName:   c.FirstName,
+		FamilyName:  c.LastName,
+		Email:       c.Email,
+		Phone:       c.Phone,
+		Fax:         c.Fax,
+		BillAddr:    c.BillAddress.lines(),
+		ShipAddr:    c.ShipAddress.lines(),
+		Notes:       c.Notes,
+		Taxable:     taxCode(c.SalesTaxCodeRef.FullName),
+		Active:      c.IsActive != "false",
+	}
+}
+
+// item maps a QBXML item of the given element kind
+func (i qbxmlItem) item(kind string) Item {
+	item := Item{
+		Name:       fullName(i.FullName, i.ParentRef.FullName, i.Name),
+		Type:       qbxmlItemTypes[kind],
+		SourceType: kind,
+		Taxable:    taxCode(i.SalesTaxCodeRef.FullName),
+		Active:     i.IsActive != "false",
+	}
+
+	switch {
+	case i.SalesAndPurchase != nil:
+		sp := i.SalesAndPurchase
+		item.Description = sp.SalesDesc
+		item.Price = parseFloat(sp.SalesPrice)
+		item.IncomeAccount = sp.IncomeAccountRef.FullName
+		item.PurchaseDesc = sp.PurchaseDesc
+		item.Cost = parseFloat(sp.PurchaseCost)
+		item.ExpenseAccount = sp.ExpenseAccountRef.FullName
+	case i.SalesOrPurchase != nil:
+		sp := i.SalesOrPurchase
+		item.Description = sp.Desc
+		item.Price = parseFloat(sp.Price)
+		item.IncomeAccount = sp.AccountRef.FullName
+	default:
+		item.Description = i.SalesDesc
+		item.Price = parseFloat(i.SalesPrice)
+		item.IncomeAccount = i.IncomeAccountRef.FullName
+		item.PurchaseDesc = i.PurchaseDesc
+		item.Cost = parseFloat(i.PurchaseCost)
+		item.ExpenseAccount = i.COGSAccountRef.FullName
+		item.AssetAccount = i.AssetAccountRef.FullName
+		item.QtyOnHand = parseFloat(i.QuantityOnHand)
+	}
+	return item
+}
+
+// fullName prefers the Desktop full name, else builds it from the parent
+func fullName(full, parent, name string) string {
+	if full != "" {
+		return full
+	}
+	if parent != "" {
+		return parent + ":" + name
+	}
+	return name
+}
+
+// taxCode interprets Desktop's default Tax/Non sales tax codes
+func taxCode(code string) *bool {
+	switch strings.ToLower(code) {
+	case "tax":
+		v := true
+		return &v
+	case "non":
+		v := false
+		return &v
+	}
+	return nil
+}
+
+// latin1Reader decodes the single-byte encodings Desktop declares
+// (windows-1252, ISO-8859-1) by mapping each byte to its Latin-1 code point
+func latin1Reader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "windows-1252", "iso-8859-1", "latin1", "us-ascii":
+	default:
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return strings.NewReader(string(runes)), nil
+}
+
+// parseFloat parses a QBXML amount, treating blanks as zero
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
+
+// nonBlank returns the non-empty values
+func nonBlank(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
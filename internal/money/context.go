@@ -0,0 +1,24 @@
+// money/context.go
+package money
+
+import "context"
+
+// contextKey is the type for money context keys
+type contextKey string
+
+// policyKey holds the realm's rounding policy
+const policyKey contextKey = "money_policy"
+
+// WithPolicy returns a context carrying the realm's rounding policy
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey, policy)
+}
+
+// PolicyFromContext returns the realm's rounding policy, defaulting to
+// half up to cents
+func PolicyFromContext(ctx context.Context) Policy {
+	if policy, ok := ctx.Value(policyKey).(Policy); ok {
+		return policy
+	}
+	return DefaultPolicy
+}
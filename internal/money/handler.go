@@ -0,0 +1,74 @@
+// money/handler.go
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// Servicer keeps each realm's rounding policy and applies it to requests.
+// *Service implements it; tests can substitute a fake.
+type Servicer interface {
+	GetPolicy(ctx context.Context, realmID string) (Policy, error)
+	SavePolicy(ctx context.Context, realmID string, policy Policy) error
+	Context(ctx context.Context, realmID string) context.Context
+	Middleware(next http.Handler) http.Handler
+}
+
+// Handler provides HTTP handlers for rounding policies
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new rounding policy handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetPolicy returns the current realm's rounding policy
+func (h *Handler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get company ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.service.GetPolicy(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to get rounding policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// SavePolicy replaces the current realm's rounding policy
+func (h *Handler) SavePolicy(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get company ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var policy Policy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SavePolicy(r.Context(), realmID, policy); err != nil {
+		http.Error(w, "Failed to save rounding policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
@@ -0,0 +1,49 @@
+// money/money.go
+package money
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Zero is a zero amount
+var Zero = decimal.Zero
+
+// Amount is a decimal amount that marshals to a JSON number, as QuickBooks
+// expects; a bare decimal marshals to a quoted string. It decodes either.
+type Amount struct {
+	decimal.Decimal
+}
+
+// NewAmount wraps a decimal as an amount
+func NewAmount(d decimal.Decimal) Amount {
+	return Amount{Decimal: d}
+}
+
+// NewAmountPtr wraps a decimal as an amount for an optional field
+func NewAmountPtr(d decimal.Decimal) *Amount {
+	return &Amount{Decimal: d}
+}
+
+// MarshalJSON writes the amount as a JSON number
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// FromMinor converts an amount in minor units, such as cents, to a decimal
+func FromMinor(minor int64, places int32) decimal.Decimal {
+	return decimal.New(minor, -places)
+}
+
+// Parse parses a formatted amount, treating blanks and invalid values as zero
+func Parse(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+// Sum adds amounts exactly
+func Sum(amounts ...decimal.Decimal) decimal.Decimal {
+	return decimal.Sum(decimal.Zero, amounts...)
+}
@@ -0,0 +1,60 @@
+// money/rounding.go
+package money
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rounding modes
+const (
+	RoundHalfUp   = "half_up"   // halves away from zero, as QuickBooks rounds
+	RoundHalfEven = "half_even" // halves to the even digit (banker's rounding)
+	RoundUp       = "up"        // away from zero
+	RoundDown     = "down"      // toward zero
+)
+
+// maxPlaces is the most decimal places a policy may keep
+const maxPlaces = 4
+
+// Policy is a realm's rounding policy for computed amounts
+type Policy struct {
+	Mode   string `json:"mode"`
+	Places int32  `json:"places"` // decimal places kept, 2 for most currencies
+}
+
+// DefaultPolicy rounds half up to cents
+var DefaultPolicy = Policy{Mode: RoundHalfUp, Places: 2}
+
+// Validate checks the policy is well formed
+func (p *Policy) Validate() error {
+	switch p.Mode {
+	case RoundHalfUp, RoundHalfEven, RoundUp, RoundDown:
+	default:
+		return fmt.Errorf("unsupported rounding mode: %q", p.Mode)
+	}
+	if p.Places < 0 || p.Places > maxPlaces {
+		return fmt.Errorf("places must be between 0 and %d", maxPlaces)
+	}
+	return nil
+}
+
+// Round rounds an amount to the policy's places
+func (p Policy) Round(d decimal.Decimal) decimal.Decimal {
+	switch p.Mode {
+	case RoundHalfEven:
+		return d.RoundBank(p.Places)
+	case RoundUp:
+		return d.RoundUp(p.Places)
+	case RoundDown:
+		return d.RoundDown(p.Places)
+	default:
+		return d.Round(p.Places)
+	}
+}
+
+// Format rounds an amount and formats it with exactly the policy's places
+func (p Policy) Format(d decimal.Decimal) string {
+	return p.Round(d).StringFixed(p.Places)
+}
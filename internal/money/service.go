@@ -0,0 +1,88 @@
+// money/service.go
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/go-redis/redis/v8"
+)
+
+// Service stores each realm's rounding policy
+type Service struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewService creates a new rounding policy service
+func NewService(client redis.UniversalClient, prefix string) *Service {
+	return &Service{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key holds a realm's policy
+func (s *Service) key(realmID string) string {
+	return fmt.Sprintf("%s:money:rounding:%s", s.prefix, realmID)
+}
+
+// GetPolicy returns a realm's rounding policy; realms without one use the default
+func (s *Service) GetPolicy(ctx context.Context, realmID string) (Policy, error) {
+	data, err := s.client.Get(ctx, s.key(realmID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return DefaultPolicy, nil
+		}
+		return Policy{}, fmt.Errorf("failed to get rounding policy: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return Policy{}, fmt.Errorf("failed to unmarshal rounding policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SavePolicy validates and replaces a realm's rounding policy
+func (s *Service) SavePolicy(ctx context.Context, realmID string, policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rounding policy: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(realmID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save rounding policy: %w", err)
+	}
+	return nil
+}
+
+// Context returns ctx carrying a realm's rounding policy, falling back to
+// the default when it cannot be read
+func (s *Service) Context(ctx context.Context, realmID string) context.Context {
+	policy, err := s.GetPolicy(ctx, realmID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to load rounding policy", "realm_id", realmID, "error", err)
+		policy = DefaultPolicy
+	}
+	return WithPolicy(ctx, policy)
+}
+
+// Middleware puts the current realm's rounding policy in the request context
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := auth.GetCompanyID(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(s.Context(r.Context(), realmID)))
+	})
+}
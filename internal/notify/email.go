@@ -0,0 +1,62 @@
+// notify/email.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// EmailConfig holds SMTP settings
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// EmailChannel sends notifications over SMTP
+type EmailChannel struct {
+	config EmailConfig
+}
+
+// NewEmailChannel creates a new email channel
+func NewEmailChannel(config EmailConfig) *EmailChannel {
+	return &EmailChannel{
+		config: config,
+	}
+}
+
+// Send emails the message to the target address
+func (c *EmailChannel) Send(ctx context.Context, target string, msg Message) error {
+	if strings.ContainsAny(target, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header value")
+	}
+
+	addr := net.JoinHostPort(c.config.Host, fmt.Sprint(c.config.Port))
+
+	var auth smtp.Auth
+	if c.config.Username != "" {
+		auth = smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + c.config.From,
+		"To: " + target,
+		"Subject: " + msg.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		msg.Body,
+	}, "\r\n")
+
+	// net/smtp has no context support, so honour cancellation before dialing
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return smtp.SendMail(addr, auth, c.config.From, []string{target}, []byte(body))
+}
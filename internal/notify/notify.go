@@ -0,0 +1,81 @@
+// notify/notify.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Message is a notification to deliver to a user
+type Message struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Destination identifies where a notification is delivered
+type Destination struct {
+	Channel string `json:"channel"` // "email" or "slack"
+	Target  string `json:"target"`  // email address or Slack webhook URL
+}
+
+// Channel delivers messages to a target such as an email address or Slack webhook
+type Channel interface {
+	Send(ctx context.Context, target string, msg Message) error
+}
+
+// Dispatcher routes messages to named channels
+type Dispatcher struct {
+	channels map[string]Channel
+	mu       sync.RWMutex
+}
+
+// NewDispatcher creates a new notification dispatcher
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		channels: make(map[string]Channel),
+	}
+}
+
+// Register adds a channel under the given name
+func (d *Dispatcher) Register(name string, channel Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels[name] = channel
+}
+
+// Supports reports whether a channel is registered
+func (d *Dispatcher) Supports(name string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, ok := d.channels[name]
+	return ok
+}
+
+// Validate checks that a destination can be delivered to
+func (d *Dispatcher) Validate(dest Destination) error {
+	if !d.Supports(dest.Channel) {
+		return fmt.Errorf("unsupported notification channel: %s", dest.Channel)
+	}
+	if dest.Target == "" {
+		return fmt.Errorf("notification target is required")
+	}
+	return nil
+}
+
+// Send delivers a message through the named channel
+func (d *Dispatcher) Send(ctx context.Context, channelName, target string, msg Message) error {
+	d.mu.RLock()
+	channel, ok := d.channels[channelName]
+	d.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown notification channel: %s", channelName)
+	}
+
+	if err := channel.Send(ctx, target, msg); err != nil {
+		return fmt.Errorf("failed to send %s notification: %w", channelName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+// notify/slack.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SlackChannel posts notifications to Slack incoming webhooks
+type SlackChannel struct {
+	httpClient *http.Client
+}
+
+// NewSlackChannel creates a new Slack channel
+func NewSlackChannel() *SlackChannel {
+	return &SlackChannel{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts the message to the target webhook URL
+func (c *SlackChannel) Send(ctx context.Context, target string, msg Message) error {
+	if !strings.HasPrefix(target, "https://hooks.slack.com/") {
+		return fmt.Errorf("invalid Slack webhook URL")
+	}
+
+	text := msg.Body
+	if msg.Subject != "" {
+		text = "*" + msg.Subject + "*\n" + msg.Body
+	}
+
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Slack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Slack request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
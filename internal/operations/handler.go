@@ -0,0 +1,156 @@
+// operations/handler.go
+package operations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Async reports whether a request asks to run as an operation, with a
+// "Prefer: respond-async" header or ?async=true
+func Async(r *http.Request) bool {
+	if r.URL.Query().Get("async") == "true" {
+		return true
+	}
+	for _, value := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// location returns the path an operation is reported at, under the admin
+// routes for operations started through them
+func location(r *http.Request, id string) string {
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		return "/admin/operations/" + id
+	}
+	return "/api/operations/" + id
+}
+
+// Accepted answers a request that started an operation
+func Accepted(w http.ResponseWriter, r *http.Request, op *Operation) {
+	w.Header().Set("Location", location(r, op.ID))
+	respond(w, r, http.StatusAccepted, op)
+}
+
+// Handler provides HTTP handlers for operations
+type Handler struct {
+	manager *Manager
+	admin   bool
+}
+
+// NewHandler creates a handler for operations of the requesting tenant
+func NewHandler(manager *Manager) *Handler {
+	return &Handler{
+		manager: manager,
+	}
+}
+
+// NewAdminHandler creates a handler for operators, who see every tenant's
+// operations
+func NewAdminHandler(manager *Manager) *Handler {
+	return &Handler{
+		manager: manager,
+		admin:   true,
+	}
+}
+
+// visible reports whether the requester may see an operation; others are
+// answered as if it did not exist
+func (h *Handler) visible(r *http.Request, op *Operation) bool {
+	return h.admin || op.TenantID == auth.GetTenantID(r.Context())
+}
+
+// status returns the HTTP status for a manager error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrFinished):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// operationResponse adds where to fetch an operation's result
+type operationResponse struct {
+	*Operation
+	ResultURL string `json:"result_url,omitempty"`
+}
+
+// respond writes an operation
+func respond(w http.ResponseWriter, r *http.Request, code int, op *Operation) {
+	resp := operationResponse{Operation: op}
+	if op.HasResult {
+		resp.ResultURL = location(r, op.ID) + "/result"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetOperation reports an operation's status, progress and partial errors
+func (h *Handler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	op, err := h.manager.Get(r.Context(), mux.Vars(r)["id"])
+	if err == nil && !h.visible(r, op) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		http.Error(w, "Failed to get operation: "+err.Error(), status(err))
+		return
+	}
+	respond(w, r, http.StatusOK, op)
+}
+
+// GetResult returns the result of a finished operation
+func (h *Handler) GetResult(w http.ResponseWriter, r *http.Request) {
+	op, err := h.manager.Get(r.Context(), mux.Vars(r)["id"])
+	if err == nil && !h.visible(r, op) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		http.Error(w, "Failed to get operation: "+err.Error(), status(err))
+		return
+	}
+	if !op.HasResult {
+		http.Error(w, "Operation has no result", http.StatusNotFound)
+		return
+	}
+
+	file, err := h.manager.Result(r.Context(), op.ID)
+	if err != nil {
+		http.Error(w, "Failed to get operation result: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", file.ContentType)
+	if file.Filename != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+file.Filename+`"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(file.Data)
+}
+
+// CancelOperation asks a running operation to stop
+func (h *Handler) CancelOperation(w http.ResponseWriter, r *http.Request) {
+	op, err := h.manager.Get(r.Context(), mux.Vars(r)["id"])
+	if err == nil && !h.visible(r, op) {
+		err = ErrNotFound
+	}
+	if err == nil {
+		op, err = h.manager.Cancel(r.Context(), op.ID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to cancel operation: "+err.Error(), status(err))
+		return
+	}
+	respond(w, r, http.StatusAccepted, op)
+}
@@ -0,0 +1,253 @@
+// operations/manager.go
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+const (
+	// flushInterval is how often a running operation's progress is saved
+	// and its cancellation checked
+	flushInterval = 2 * time.Second
+	// staleAfter is how long a running operation may go unsaved before it
+	// is considered lost with the instance that ran it
+	staleAfter = time.Minute
+)
+
+// ErrFinished is returned when canceling an operation that has stopped
+var ErrFinished = errors.New("operation has already finished")
+
+// RunFunc does an operation's work. Its result is served as is if it is a
+// *File and as JSON otherwise; a nil result means the operation has none.
+type RunFunc func(ctx context.Context) (interface{}, error)
+
+// running is an operation in progress on this instance
+type running struct {
+	tracker *tracker
+	cancel  context.CancelFunc
+}
+
+// Manager runs operations in the background and reports on them. Any
+// instance can report on or cancel an operation; the one running it picks
+// up cancellations when it next saves progress.
+type Manager struct {
+	store   *Store
+	mu      sync.Mutex
+	running map[string]*running
+}
+
+// NewManager creates a new operation manager
+func NewManager(store *Store) *Manager {
+	return &Manager{
+		store:   store,
+		running: make(map[string]*running),
+	}
+}
+
+// Start runs fn in the background as an operation of the tenant and
+// returns it. fn runs as the requesting user with the request's time zone
+// and rounding policy, but outlives the request.
+func (m *Manager) Start(ctx context.Context, tenantID, kind string, fn RunFunc) (*Operation, error) {
+	now := time.Now()
+	realmID, _ := auth.GetCompanyID(ctx)
+	op := &Operation{
+		ID:        jobs.NewID(),
+		Kind:      kind,
+		TenantID:  tenantID,
+		RealmID:   realmID,
+		UserID:    auth.GetUserID(ctx),
+		Status:    StatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.store.Save(ctx, op); err != nil {
+		return nil, err
+	}
+
+	runCtx := auth.WithIdentity(context.Background(), op.UserID, op.TenantID, op.RealmID)
+	runCtx = context.WithValue(runCtx, auth.RoleKey, auth.GetRole(ctx))
+	runCtx = timezone.WithLocation(runCtx, timezone.FromContext(ctx))
+	runCtx = money.WithPolicy(runCtx, money.PolicyFromContext(ctx))
+	// Nobody is waiting on the response, so QuickBooks hiccups are waited out
+	runCtx = qbclient.WithRetry(runCtx, qbclient.BackgroundRetryPolicy)
+	runCtx = qbclient.WithPriority(runCtx, qbclient.PriorityBackground)
+	runCtx, cancel := context.WithCancel(runCtx)
+
+	snapshot := *op
+	t := &tracker{op: op}
+	m.mu.Lock()
+	m.running[op.ID] = &running{tracker: t, cancel: cancel}
+	m.mu.Unlock()
+
+	go m.run(context.WithValue(runCtx, trackerKey, t), cancel, t, fn)
+	return &snapshot, nil
+}
+
+// run does an operation's work and saves how it ended
+func (m *Manager) run(ctx context.Context, cancel context.CancelFunc, t *tracker, fn RunFunc) {
+	defer cancel()
+	id := t.op.ID
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, id)
+		m.mu.Unlock()
+	}()
+
+	stop := make(chan struct{})
+	go m.watch(id, cancel, t, stop)
+	result, err := call(ctx, fn)
+	close(stop)
+
+	// Store the result before the operation reports success
+	if err == nil && result != nil {
+		var file *File
+		if file, err = encode(result); err == nil {
+			if err = m.store.SaveResult(context.Background(), id, file); err == nil {
+				t.mu.Lock()
+				t.op.HasResult = true
+				t.mu.Unlock()
+			}
+		}
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	switch {
+	case err == nil:
+		t.op.Status = StatusSucceeded
+	case t.op.CancelRequested:
+		t.op.Status = StatusCanceled
+	default:
+		t.op.Status = StatusFailed
+		t.op.Error = err.Error()
+	}
+	t.op.FinishedAt = &now
+	t.mu.Unlock()
+
+	if err := m.flush(t); err != nil {
+		logging.FromContext(ctx).Warn("operation finished but was not saved", "operation_id", id, "error", err)
+	}
+}
+
+// call runs fn, turning a panic into an error
+func call(ctx context.Context, fn RunFunc) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("operation panicked: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// encode converts a result into the form it is served in
+func encode(result interface{}) (*File, error) {
+	if file, ok := result.(*File); ok {
+		return file, nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal operation result: %w", err)
+	}
+	return &File{ContentType: "application/json", Data: data}, nil
+}
+
+// watch saves progress and checks for cancellation until stop is closed
+func (m *Manager) watch(id string, cancel context.CancelFunc, t *tracker, stop <-chan struct{}) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		requested, err := m.store.CancelRequested(context.Background(), id)
+		if err != nil {
+			slog.Warn("failed to check operation cancellation", "operation_id", id, "error", err)
+		} else if requested {
+			t.mu.Lock()
+			t.op.CancelRequested = true
+			t.mu.Unlock()
+			cancel()
+		}
+		if err := m.flush(t); err != nil {
+			slog.Warn("failed to save operation progress", "operation_id", id, "error", err)
+		}
+	}
+}
+
+// flush saves a tracked operation. It is saved even without progress, so
+// its UpdatedAt shows that it is still running.
+func (m *Manager) flush(t *tracker) error {
+	t.mu.Lock()
+	t.op.UpdatedAt = time.Now()
+	snapshot := *t.op
+	snapshot.Errors = append([]string(nil), t.op.Errors...)
+	t.mu.Unlock()
+	return m.store.Save(context.Background(), &snapshot)
+}
+
+// Get returns an operation. A running operation that stopped saving
+// progress is reported as failed, since the instance running it is gone.
+func (m *Manager) Get(ctx context.Context, id string) (*Operation, error) {
+	op, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Status == StatusRunning && time.Since(op.UpdatedAt) > staleAfter {
+		now := time.Now()
+		op.Status = StatusFailed
+		op.Error = "operation was interrupted"
+		op.FinishedAt = &now
+		if err := m.store.Save(ctx, op); err != nil {
+			return nil, err
+		}
+	}
+	return op, nil
+}
+
+// Result returns the result of a finished operation
+func (m *Manager) Result(ctx context.Context, id string) (*File, error) {
+	return m.store.Result(ctx, id)
+}
+
+// Cancel asks a running operation to stop. It reports as canceled once
+// its work has returned.
+func (m *Manager) Cancel(ctx context.Context, id string) (*Operation, error) {
+	op, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Finished() {
+		return op, ErrFinished
+	}
+	if err := m.store.RequestCancel(ctx, id); err != nil {
+		return nil, err
+	}
+	op.CancelRequested = true
+
+	m.mu.Lock()
+	r, ok := m.running[id]
+	m.mu.Unlock()
+	if ok {
+		r.tracker.mu.Lock()
+		r.tracker.op.CancelRequested = true
+		r.tracker.mu.Unlock()
+		r.cancel()
+	}
+	return op, nil
+}
@@ -0,0 +1,95 @@
+// operations/operation.go
+package operations
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Operation statuses
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// maxErrors bounds the partial errors kept on an operation; ErrorCount
+// still counts every one
+const maxErrors = 100
+
+// Operation is a bulk import, backfill or export running in the background
+type Operation struct {
+	ID              string     `json:"id"`
+	Kind            string     `json:"kind"`
+	TenantID        string     `json:"tenant_id"`
+	RealmID         string     `json:"realm_id,omitempty"`
+	UserID          string     `json:"user_id,omitempty"`
+	Status          string     `json:"status"`
+	Done            int        `json:"done"`
+	Total           int        `json:"total,omitempty"`
+	Errors          []string   `json:"errors,omitempty"`
+	ErrorCount      int        `json:"error_count"`
+	Error           string     `json:"error,omitempty"` // why the operation failed
+	CancelRequested bool       `json:"cancel_requested,omitempty"`
+	HasResult       bool       `json:"has_result"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the operation has stopped
+func (o *Operation) Finished() bool {
+	return o.Status != StatusRunning
+}
+
+// File is an operation result served as is rather than as JSON
+type File struct {
+	ContentType string
+	Filename    string
+	Data        []byte
+}
+
+// tracker collects the progress of a running operation
+type tracker struct {
+	mu sync.Mutex
+	op *Operation
+}
+
+// contextKey is the type for operation context keys
+type contextKey string
+
+const trackerKey contextKey = "operations_tracker"
+
+// update changes the tracked operation under the lock
+func update(ctx context.Context, fn func(op *Operation)) {
+	t, ok := ctx.Value(trackerKey).(*tracker)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(t.op)
+}
+
+// SetTotal records how many units of work the operation in ctx has. It does
+// nothing outside an operation, so work can report progress unconditionally.
+func SetTotal(ctx context.Context, total int) {
+	update(ctx, func(op *Operation) { op.Total = total })
+}
+
+// Add records n more units of work done by the operation in ctx
+func Add(ctx context.Context, n int) {
+	update(ctx, func(op *Operation) { op.Done += n })
+}
+
+// Error records a partial error of the operation in ctx
+func Error(ctx context.Context, message string) {
+	update(ctx, func(op *Operation) {
+		op.ErrorCount++
+		if len(op.Errors) < maxErrors {
+			op.Errors = append(op.Errors, message)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+// operations/store.go
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// retention is how long operations and their results are kept
+const retention = 7 * 24 * time.Hour
+
+// ErrNotFound is returned when an operation does not exist or has expired
+var ErrNotFound = errors.New("operation not found")
+
+// Store persists operations and their results
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new operation store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key returns the key of an operation
+func (s *Store) key(id string) string {
+	return fmt.Sprintf("%s:operations:%s", s.prefix, id)
+}
+
+// resultKey returns the hash holding an operation's result
+func (s *Store) resultKey(id string) string {
+	return fmt.Sprintf("%s:operations:%s:result", s.prefix, id)
+}
+
+// cancelKey marks an operation to be canceled by whichever instance runs it
+func (s *Store) cancelKey(id string) string {
+	return fmt.Sprintf("%s:operations:%s:cancel", s.prefix, id)
+}
+
+// Save stores an operation
+func (s *Store) Save(ctx context.Context, op *Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(op.ID), data, retention).Err(); err != nil {
+		return fmt.Errorf("failed to save operation: %w", err)
+	}
+	return nil
+}
+
+// Get returns an operation
+func (s *Store) Get(ctx context.Context, id string) (*Operation, error) {
+	data, err := s.client.Get(ctx, s.key(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operation: %w", err)
+	}
+	return &op, nil
+}
+
+// SaveResult stores an operation's result
+func (s *Store) SaveResult(ctx context.Context, id string, file *File) error {
+	key := s.resultKey(id)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "content_type", file.ContentType, "filename", file.Filename, "data", file.Data)
+		pipe.Expire(ctx, key, retention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save operation result: %w", err)
+	}
+	return nil
+}
+
+// Result returns an operation's result
+func (s *Store) Result(ctx context.Context, id string) (*File, error) {
+	values, err := s.client.HGetAll(ctx, s.resultKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation result: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, ErrNotFound
+	}
+	return &File{
+		ContentType: values["content_type"],
+		Filename:    values["filename"],
+		Data:        []byte(values["data"]),
+	}, nil
+}
+
+// RequestCancel marks an operation to be canceled
+func (s *Store) RequestCancel(ctx context.Context, id string) error {
+	if err := s.client.Set(ctx, s.cancelKey(id), 1, retention).Err(); err != nil {
+		return fmt.Errorf("failed to cancel operation: %w", err)
+	}
+	return nil
+}
+
+// CancelRequested reports whether an operation was marked to be canceled
+func (s *Store) CancelRequested(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.cancelKey(id)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check operation cancellation: %w", err)
+	}
+	return n > 0, nil
+}
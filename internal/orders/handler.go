@@ -0,0 +1,173 @@
+// orders/handler.go
+package orders
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for order source imports
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new order source handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetConfig returns the tenant's order source without its access token,
+// along with the available source types
+func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	config, err := h.service.GetConfig(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil && !errors.Is(err, ErrNotConfigured) {
+		http.Error(w, "Failed to get order source: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if config != nil {
+		config.Source.AccessToken = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":  config,
+		"sources": SourceTypes(),
+	})
+}
+
+// SaveConfig connects an order source for the tenant and binds it to the
+// current company
+func (h *Handler) SaveConfig(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var config Config
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	config.RealmID = realmID
+	config.UserID = auth.GetUserID(r.Context())
+
+	if err := h.service.SaveConfig(r.Context(), auth.GetTenantID(r.Context()), &config); err != nil {
+		http.Error(w, "Failed to save order source: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	config.Source.AccessToken = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config)
+}
+
+// SyncOrders imports orders now; ?since=YYYY-MM-DD overrides the cursor
+func (h *Handler) SyncOrders(w http.ResponseWriter, r *http.Request) {
+	tenantID := auth.GetTenantID(r.Context())
+	config, err := h.service.GetConfig(r.Context(), tenantID)
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to get order source: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if realmID, err := auth.GetCompanyID(r.Context()); err != nil || realmID != config.RealmID {
+		http.Error(w, "Order source is connected to a different QuickBooks company", http.StatusConflict)
+		return
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		if since, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid since date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.service.Sync(r.Context(), tenantID, config, since)
+	if err != nil {
+		http.Error(w, "Failed to import orders: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+	})
+}
+
+// ListMappings returns the current company's SKU mappings
+func (h *Handler) ListMappings(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	mappings, err := h.service.Mappings().List(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list SKU mappings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mappings": mappings,
+	})
+}
+
+// SaveMappings creates or replaces SKU mappings from a JSON array
+func (h *Handler) SaveMappings(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var mappings []SKUMapping
+	if err := json.NewDecoder(r.Body).Decode(&mappings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Mappings().Save(r.Context(), realmID, mappings); err != nil {
+		http.Error(w, "Failed to save SKU mappings: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"saved": len(mappings),
+	})
+}
+
+// DeleteMapping removes the mapping for a SKU
+func (h *Handler) DeleteMapping(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Mappings().Delete(r.Context(), realmID, mux.Vars(r)["sku"]); err != nil {
+		http.Error(w, "Failed to delete SKU mapping: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+// orders/mapping.go
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// SKUMapping maps a storefront SKU to a QuickBooks item
+type SKUMapping struct {
+	SKU       string    `json:"sku"`
+	ItemID    string    `json:"item_id"`
+	ItemName  string    `json:"item_name,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MappingStore persists SKU mappings per QuickBooks company
+type MappingStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewMappingStore creates a new SKU mapping store
+func NewMappingStore(client redis.UniversalClient, prefix string) *MappingStore {
+	return &MappingStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key returns the hash of SKU mappings for a realm
+func (s *MappingStore) key(realmID string) string {
+	return fmt.Sprintf("%s:orders:skus:%s", s.prefix, realmID)
+}
+
+// normalizeSKU makes SKU lookups case- and whitespace-insensitive
+func normalizeSKU(sku string) string {
+	return strings.ToUpper(strings.TrimSpace(sku))
+}
+
+// Save creates or replaces mappings
+func (s *MappingStore) Save(ctx context.Context, realmID string, mappings []SKUMapping) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	values := make(map[string]interface{}, len(mappings))
+	now := time.Now()
+	for _, m := range mappings {
+		sku := normalizeSKU(m.SKU)
+		if sku == "" || m.ItemID == "" {
+			return fmt.Errorf("sku and item_id are required")
+		}
+		m.SKU = sku
+		m.UpdatedAt = now
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal sku mapping: %w", err)
+		}
+		values[sku] = data
+	}
+
+	if err := s.client.HSet(ctx, s.key(realmID), values).Err(); err != nil {
+		return fmt.Errorf("failed to save sku mappings: %w", err)
+	}
+	return nil
+}
+
+// List returns all mappings for a realm ordered by SKU
+func (s *MappingStore) List(ctx context.Context, realmID string) ([]SKUMapping, error) {
+	values, err := s.client.HGetAll(ctx, s.key(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sku mappings: %w", err)
+	}
+
+	mappings := make([]SKUMapping, 0, len(values))
+	for _, v := range values {
+		var m SKUMapping
+		if err := json.Unmarshal([]byte(v), &m); err != nil {
+			continue
+		}
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].SKU < mappings[j].SKU })
+	return mappings, nil
+}
+
+// Lookup returns the item IDs for the given SKUs, keyed by normalized SKU.
+// SKUs without a mapping are absent from the result.
+func (s *MappingStore) Lookup(ctx context.Context, realmID string, skus []string) (map[string]string, error) {
+	items := make(map[string]string)
+	if len(skus) == 0 {
+		return items, nil
+	}
+
+	fields := make([]string, len(skus))
+	for i, sku := range skus {
+		fields[i] = normalizeSKU(sku)
+	}
+	values, err := s.client.HMGet(ctx, s.key(realmID), fields...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up sku mappings: %w", err)
+	}
+
+	for i, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var m SKUMapping
+		if json.Unmarshal([]byte(str), &m) == nil {
+			items[fields[i]] = m.ItemID
+		}
+	}
+	return items, nil
+}
+
+// Delete removes a mapping
+func (s *MappingStore) Delete(ctx context.Context, realmID, sku string) error {
+	if err := s.client.HDel(ctx, s.key(realmID), normalizeSKU(sku)).Err(); err != nil {
+		return fmt.Errorf("failed to delete sku mapping: %w", err)
+	}
+	return nil
+}
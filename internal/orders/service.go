@@ -0,0 +1,450 @@
+// orders/service.go
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/go-redis/redis/v8"
+)
+
+// SyncJobType identifies recurring order imports in the jobs subsystem
+const SyncJobType = "orders_sync"
+
+// Document rules for how orders are recorded in QuickBooks
+const (
+	DocumentAuto         = "auto"          // paid orders become sales receipts, others invoices
+	DocumentSalesReceipt = "sales_receipt" // always record a sales receipt
+	DocumentInvoice      = "invoice"       // always record an invoice
+)
+
+// initialLookback bounds the first sync for a newly connected source
+const initialLookback = 7 * 24 * time.Hour
+
+// ErrNotConfigured is returned when a tenant has not connected an order source
+var ErrNotConfigured = errors.New("order source is not configured")
+
+// Rules control how a tenant's orders are converted
+type Rules struct {
+	Document          string `json:"document"`                      // auto, sales_receipt or invoice
+	SkipUnpaid        bool   `json:"skip_unpaid"`                   // ignore orders not yet paid
+	DefaultCustomerID string `json:"default_customer_id,omitempty"` // used when no customer matches
+	CreateCustomers   bool   `json:"create_customers"`              // create missing customers by name
+	DepositAccountID  string `json:"deposit_account_id,omitempty"`  // sales receipt deposit account
+	ShippingItemID    string `json:"shipping_item_id,omitempty"`    // item used for shipping charges
+	FallbackItemID    string `json:"fallback_item_id,omitempty"`    // item used for unmapped SKUs
+	DocNumberPrefix   string `json:"doc_number_prefix,omitempty"`   // prepended to the order number
+}
+
+// Config is a tenant's order source connection and conversion rules
+type Config struct {
+	Source    SourceConfig `json:"source"`
+	RealmID   string       `json:"realm_id"`
+	UserID    string       `json:"user_id"`
+	Rules     Rules        `json:"rules"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Validate checks the source and rules
+func (c *Config) Validate() error {
+	if _, err := NewSource(c.Source); err != nil {
+		return err
+	}
+	switch c.Rules.Document {
+	case "":
+		c.Rules.Document = DocumentAuto
+	case DocumentAuto, DocumentSalesReceipt, DocumentInvoice:
+	default:
+		return fmt.Errorf("unsupported document rule: %q", c.Rules.Document)
+	}
+	if c.Rules.Document != DocumentSalesReceipt && c.Rules.DefaultCustomerID == "" && !c.Rules.CreateCustomers {
+		return fmt.Errorf("invoices need a customer: set default_customer_id or create_customers")
+	}
+	return nil
+}
+
+// QuickBooks is the subset of the QuickBooks client used to record orders
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+}
+
+// SyncResult reports what happened to one order
+type SyncResult struct {
+	OrderID      string   `json:"order_id"`
+	Number       string   `json:"number"`
+	Status       string   `json:"status"` // created, duplicate, skipped or error
+	Entity       string   `json:"entity,omitempty"`
+	EntityID     string   `json:"entity_id,omitempty"`
+	UnmappedSKUs []string `json:"unmapped_skus,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// Service imports storefront orders into QuickBooks
+type Service struct {
+	client    redis.UniversalClient
+	prefix    string
+	mappings  *MappingStore
+	qb        QuickBooks
+	scheduler *jobs.Scheduler
+}
+
+// NewService creates a new order import service and registers its job runner
+func NewService(client redis.UniversalClient, prefix string, mappings *MappingStore, qb QuickBooks, scheduler *jobs.Scheduler) *Service {
+	service := &Service{
+		client:    client,
+		prefix:    prefix,
+		mappings:  mappings,
+		qb:        qb,
+		scheduler: scheduler,
+	}
+	scheduler.RegisterRunner(SyncJobType, jobs.RunnerFunc(service.runSync))
+	return service
+}
+
+// Mappings returns the SKU mapping store
+func (s *Service) Mappings() *MappingStore {
+	return s.mappings
+}
+
+// configKey holds a tenant's order source configuration
+func (s *Service) configKey(tenantID string) string {
+	return fmt.Sprintf("%s:orders:config:%s", s.prefix, tenantID)
+}
+
+// cursorKey holds the time of a tenant's last successful sync
+func (s *Service) cursorKey(tenantID string) string {
+	return fmt.Sprintf("%s:orders:cursor:%s", s.prefix, tenantID)
+}
+
+// syncedKey is the hash of source order ID to QuickBooks transaction for a realm
+func (s *Service) syncedKey(realmID, source string) string {
+	return fmt.Sprintf("%s:orders:synced:%s:%s", s.prefix, realmID, source)
+}
+
+// GetConfig returns a tenant's order source configuration
+func (s *Service) GetConfig(ctx context.Context, tenantID string) (*Config, error) {
+	data, err := s.client.Get(ctx, s.configKey(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrNotConfigured
+		}
+		return nil, fmt.Errorf("failed to get order source config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order source config: %w", err)
+	}
+	return &config, nil
+}
+
+// SaveConfig stores a tenant's order source and schedules an hourly sync.
+// An empty access token keeps the stored one.
+func (s *Service) SaveConfig(ctx context.Context, tenantID string, config *Config) error {
+	if config.Source.AccessToken == "" {
+		if existing, err := s.GetConfig(ctx, tenantID); err == nil && existing.Source.Type == config.Source.Type {
+			config.Source.AccessToken = existing.Source.AccessToken
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	config.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order source config: %w", err)
+	}
+	if err := s.client.Set(ctx, s.configKey(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save order source config: %w", err)
+	}
+
+	return s.ensureScheduled(ctx, tenantID, config)
+}
+
+// ensureScheduled creates the tenant's hourly order sync unless one is active
+func (s *Service) ensureScheduled(ctx context.Context, tenantID string, config *Config) error {
+	existing, err := s.scheduler.Store().ListByTenant(ctx, tenantID, SyncJobType)
+	if err != nil {
+		return err
+	}
+	for _, job := range existing {
+		if job.Status == jobs.StatusActive {
+			return nil
+		}
+	}
+
+	job, err := jobs.NewJob(SyncJobType, tenantID, config.UserID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create order sync job: %w", err)
+	}
+	job.Schedule = &jobs.Schedule{Frequency: jobs.FrequencyHourly}
+	job.NextRunAt = job.Schedule.Next(time.Now())
+
+	return s.scheduler.Store().Save(ctx, job)
+}
+
+// Sync imports orders updated since the given time, or since the last fully
+// successful sync when since is zero. Orders already imported are reported as
+// duplicates. The context must carry the tenant's QuickBooks identity.
+func (s *Service) Sync(ctx context.Context, tenantID string, config *Config, since time.Time) ([]SyncResult, error) {
+	source, err := NewSource(config.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if since.IsZero() {
+		since = time.Now().Add(-initialLookback)
+		if cursor, err := s.client.Get(ctx, s.cursorKey(tenantID)).Time(); err == nil {
+			since = cursor
+		}
+	}
+	started := time.Now()
+
+	orders, err := source.Orders(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SyncResult, 0, len(orders))
+	failed := false
+	for _, order := range orders {
+		result := s.importOrder(ctx, config, order)
+		failed = failed || result.Status == "error"
+		results = append(results, result)
+	}
+
+	// Keep the cursor in place after failures so fixed mappings are picked
+	// up on the next run; imported orders are skipped as duplicates
+	if failed {
+		return results, nil
+	}
+	if err := s.client.Set(ctx, s.cursorKey(tenantID), started, 0).Err(); err != nil {
+		return results, fmt.Errorf("failed to record sync cursor: %w", err)
+	}
+	return results, nil
+}
+
+// importOrder records a single order once per realm
+func (s *Service) importOrder(ctx context.Context, config *Config, order Order) SyncResult {
+	result := SyncResult{OrderID: order.ID, Number: order.Number}
+	key := s.syncedKey(config.RealmID, config.Source.Type)
+
+	if existing, err := s.client.HGet(ctx, key, order.ID).Result(); err == nil {
+		result.Status = "duplicate"
+		if entity, id, ok := strings.Cut(existing, ":"); ok {
+			result.Entity, result.EntityID = entity, id
+		}
+		return result
+	}
+	if order.Cancelled || (config.Rules.SkipUnpaid && !order.Paid) || len(order.Lines) == 0 {
+		result.Status = "skipped"
+		return result
+	}
+
+	entity := "Invoice"
+	switch config.Rules.Document {
+	case DocumentSalesReceipt:
+		entity = "SalesReceipt"
+	case DocumentAuto:
+		if order.Paid {
+			entity = "SalesReceipt"
+		}
+	}
+	result.Entity = entity
+
+	payload, unmapped, err := s.buildTransaction(ctx, config, order, entity)
+	if err != nil {
+		result.Status = "error"
+		result.UnmappedSKUs = unmapped
+		result.Error = err.Error()
+		return result
+	}
+
+	// Claim the order before posting so overlapping syncs cannot double-post
+	claimed, err := s.client.HSetNX(ctx, key, order.ID, "").Result()
+	if err != nil {
+		result.Status = "error"
+		result.Error = "failed to record order: " + err.Error()
+		return result
+	}
+	if !claimed {
+		result.Status = "duplicate"
+		return result
+	}
+
+	var created map[string]struct {
+		ID string `json:"Id"`
+	}
+	if err := s.qb.Create(ctx, entity, payload, &created); err != nil {
+		s.client.HDel(ctx, key, order.ID)
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = "created"
+	result.EntityID = created[entity].ID
+	if err := s.client.HSet(ctx, key, order.ID, entity+":"+result.EntityID).Err(); err != nil {
+		result.Error = "created but failed to record order: " + err.Error()
+	}
+	return result
+}
+
+// buildTransaction maps an order to a QuickBooks sales receipt or invoice
+// payload, returning any SKUs that could not be mapped
+func (s *Service) buildTransaction(ctx context.Context, config *Config, order Order, entity string) (map[string]interface{}, []string, error) {
+	rules := config.Rules
+
+	skus := make([]string, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		if line.SKU != "" {
+			skus = append(skus, line.SKU)
+		}
+	}
+	items, err := s.mappings.Lookup(ctx, config.RealmID, skus)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var lines []map[string]interface{}
+	var unmapped []string
+	addItemLine := func(itemID, description string, qty, unitPrice float64) {
+		lines = append(lines, map[string]interface{}{
+			"Amount":      math.Round(qty*unitPrice*100) / 100,
+			"Description": description,
+			"DetailType":  "SalesItemLineDetail",
+			"SalesItemLineDetail": map[string]interface{}{
+				"ItemRef":   map[string]string{"value": itemID},
+				"Qty":       qty,
+				"UnitPrice": unitPrice,
+			},
+		})
+	}
+
+	for _, line := range order.Lines {
+		itemID := items[normalizeSKU(line.SKU)]
+		if itemID == "" {
+			if rules.FallbackItemID == "" {
+				unmapped = append(unmapped, line.SKU)
+				continue
+			}
+			itemID = rules.FallbackItemID
+		}
+		addItemLine(itemID, line.Title, line.Quantity, line.UnitPrice)
+	}
+	if len(unmapped) > 0 {
+		return nil, unmapped, fmt.Errorf("order %s has unmapped SKUs", order.Number)
+	}
+
+	if order.Shipping > 0 {
+		shippingItem := rules.ShippingItemID
+		if shippingItem == "" {
+			// QuickBooks' built-in shipping line when shipping is enabled
+			shippingItem = "SHIPPING_ITEM_ID"
+		}
+		addItemLine(shippingItem, "Shipping", 1, order.Shipping)
+	}
+	if order.Discount > 0 {
+		lines = append(lines, map[string]interface{}{
+			"Amount":     order.Discount,
+			"DetailType": "DiscountLineDetail",
+			"DiscountLineDetail": map[string]interface{}{
+				"PercentBased": false,
+			},
+		})
+	}
+
+	docNumber := rules.DocNumberPrefix + order.Number
+	if len(docNumber) > 21 {
+		docNumber = docNumber[:21]
+	}
+	payload := map[string]interface{}{
+		"DocNumber":   docNumber,
+		"TxnDate":     order.CreatedAt.Format("2006-01-02"),
+		"PrivateNote": fmt.Sprintf("%s order %s", config.Source.Type, order.ID),
+		"Line":        lines,
+	}
+	if order.CustomerEmail != "" {
+		payload["BillEmail"] = map[string]string{"Address": order.CustomerEmail}
+	}
+	if entity == "SalesReceipt" && rules.DepositAccountID != "" {
+		payload["DepositToAccountRef"] = map[string]string{"value": rules.DepositAccountID}
+	}
+
+	customerID, err := s.resolveCustomer(ctx, rules, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	if customerID != "" {
+		payload["CustomerRef"] = map[string]string{"value": customerID}
+	} else if entity == "Invoice" {
+		return nil, nil, fmt.Errorf("no customer for order %s", order.Number)
+	}
+
+	return payload, nil, nil
+}
+
+// resolveCustomer finds the order's customer by display name, creating it
+// when the rules allow, and otherwise falls back to the default customer
+func (s *Service) resolveCustomer(ctx context.Context, rules Rules, order Order) (string, error) {
+	name := strings.TrimSpace(order.CustomerName)
+	if name == "" {
+		name = strings.TrimSpace(order.CustomerEmail)
+	}
+	if name == "" {
+		logging.FromContext(ctx).Warn("order has no customer, using the default customer", "order", order.Number)
+		return rules.DefaultCustomerID, nil
+	}
+
+	q := query.Select("Customer", "Id").Where("DisplayName", "=", name)
+	found, err := query.List[struct {
+		ID string `json:"Id"`
+	}](ctx, s.qb, q)
+	if err != nil {
+		return "", fmt.Errorf("failed to find customer: %w", err)
+	}
+	if len(found) > 0 {
+		return found[0].ID, nil
+	}
+	if !rules.CreateCustomers {
+		logging.FromContext(ctx).Warn("order customer not found, using the default customer", "order", order.Number, "customer", name)
+		return rules.DefaultCustomerID, nil
+	}
+
+	customer := map[string]interface{}{"DisplayName": name}
+	if order.CustomerEmail != "" {
+		customer["PrimaryEmailAddr"] = map[string]string{"Address": order.CustomerEmail}
+	}
+	var created struct {
+		Customer struct {
+			ID string `json:"Id"`
+		} `json:"Customer"`
+	}
+	if err := s.qb.Create(ctx, "Customer", customer, &created); err != nil {
+		return "", fmt.Errorf("failed to create customer: %w", err)
+	}
+	return created.Customer.ID, nil
+}
+
+// runSync is the job runner for scheduled order imports
+func (s *Service) runSync(ctx context.Context, job *jobs.Job) error {
+	config, err := s.GetConfig(ctx, job.TenantID)
+	if err != nil {
+		return err
+	}
+
+	ctx = auth.WithIdentity(ctx, config.UserID, job.TenantID, config.RealmID)
+	_, err = s.Sync(ctx, job.TenantID, config, time.Time{})
+	return err
+}
@@ -0,0 +1,174 @@
+// orders/shopify.go
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceShopify is the registered name of the Shopify adapter
+const SourceShopify = "shopify"
+
+// shopifyAPIVersion is the Shopify Admin REST API version used
+const shopifyAPIVersion = "2024-01"
+
+// shopDomainPattern restricts shop names to myshopify.com subdomains
+var shopDomainPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*\.myshopify\.com$`)
+
+// nextLinkPattern extracts the next page URL from a Link header
+var nextLinkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+func init() {
+	RegisterSource(SourceShopify, func(config SourceConfig) (Source, error) {
+		return NewShopify(config.Shop, config.AccessToken)
+	})
+}
+
+// Shopify pulls orders from a Shopify store via the Admin API
+type Shopify struct {
+	shop        string
+	accessToken string
+	httpClient  *http.Client
+}
+
+// NewShopify creates a Shopify source for a store such as example.myshopify.com
+func NewShopify(shop, accessToken string) (*Shopify, error) {
+	shop = strings.ToLower(strings.TrimSpace(shop))
+	if !strings.Contains(shop, ".") {
+		shop += ".myshopify.com"
+	}
+	if !shopDomainPattern.MatchString(shop) {
+		return nil, fmt.Errorf("invalid Shopify shop domain: %q", shop)
+	}
+	if accessToken == "" {
+		return nil, fmt.Errorf("shopify access token is required")
+	}
+	return &Shopify{
+		shop:        shop,
+		accessToken: accessToken,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// shopifyOrder is the subset of the Shopify order resource that is mapped
+type shopifyOrder struct {
+	ID                    int64   `json:"id"`
+	Name                  string  `json:"name"`
+	CreatedAt             string  `json:"created_at"`
+	Currency              string  `json:"currency"`
+	FinancialStatus       string  `json:"financial_status"`
+	CancelledAt           *string `json:"cancelled_at"`
+	Email                 string  `json:"email"`
+	TotalPrice            string  `json:"total_price"`
+	TotalTax              string  `json:"total_tax"`
+	TotalDiscounts        string  `json:"total_discounts"`
+	TotalShippingPriceSet struct {
+		ShopMoney struct {
+			Amount string `json:"amount"`
+		} `json:"shop_money"`
+	} `json:"total_shipping_price_set"`
+	Customer *struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	} `json:"customer"`
+	LineItems []struct {
+		SKU      string `json:"sku"`
+		Title    string `json:"title"`
+		Quantity int    `json:"quantity"`
+		Price    string `json:"price"`
+	} `json:"line_items"`
+}
+
+// Orders returns orders updated at or after since
+func (s *Shopify) Orders(ctx context.Context, since time.Time) ([]Order, error) {
+	params := url.Values{}
+	params.Set("status", "any")
+	params.Set("limit", "250")
+	params.Set("order", "updated_at asc")
+	params.Set("updated_at_min", since.UTC().Format(time.RFC3339))
+	endpoint := fmt.Sprintf("https://%s/admin/api/%s/orders.json?%s", s.shop, shopifyAPIVersion, params.Encode())
+
+	var orders []Order
+	for endpoint != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("X-Shopify-Access-Token", s.accessToken)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("shopify request failed: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read shopify response: %w", err)
+		}
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("shopify API returned status %d: %s", resp.StatusCode, string(body))
+		}
+
+		var page struct {
+			Orders []shopifyOrder `json:"orders"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse shopify orders: %w", err)
+		}
+		for _, o := range page.Orders {
+			orders = append(orders, o.normalize())
+		}
+
+		// Cursor pagination: follow rel="next" until exhausted
+		endpoint = ""
+		if m := nextLinkPattern.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			endpoint = m[1]
+		}
+	}
+
+	return orders, nil
+}
+
+// normalize converts a Shopify order to the common order shape
+func (o shopifyOrder) normalize() Order {
+	order := Order{
+		ID:            strconv.FormatInt(o.ID, 10),
+		Number:        strings.TrimPrefix(o.Name, "#"),
+		Currency:      o.Currency,
+		Paid:          o.FinancialStatus == "paid",
+		Cancelled:     o.CancelledAt != nil,
+		CustomerEmail: o.Email,
+		Shipping:      parseAmount(o.TotalShippingPriceSet.ShopMoney.Amount),
+		Discount:      parseAmount(o.TotalDiscounts),
+		Tax:           parseAmount(o.TotalTax),
+		Total:         parseAmount(o.TotalPrice),
+	}
+	order.CreatedAt, _ = time.Parse(time.RFC3339, o.CreatedAt)
+	if o.Customer != nil {
+		order.CustomerName = strings.TrimSpace(o.Customer.FirstName + " " + o.Customer.LastName)
+	}
+	for _, line := range o.LineItems {
+		order.Lines = append(order.Lines, OrderLine{
+			SKU:       line.SKU,
+			Title:     line.Title,
+			Quantity:  float64(line.Quantity),
+			UnitPrice: parseAmount(line.Price),
+		})
+	}
+	return order
+}
+
+// parseAmount parses a Shopify decimal string, treating blanks as zero
+func parseAmount(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
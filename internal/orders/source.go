@@ -0,0 +1,88 @@
+// orders/source.go
+package orders
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Order is a storefront order normalized across sources
+type Order struct {
+	ID            string      `json:"id"`
+	Number        string      `json:"number"`
+	CreatedAt     time.Time   `json:"created_at"`
+	Currency      string      `json:"currency"`
+	Paid          bool        `json:"paid"`
+	Cancelled     bool        `json:"cancelled"`
+	CustomerName  string      `json:"customer_name"`
+	CustomerEmail string      `json:"customer_email"`
+	Lines         []OrderLine `json:"lines"`
+	Shipping      float64     `json:"shipping"`
+	Discount      float64     `json:"discount"`
+	Tax           float64     `json:"tax"`
+	Total         float64     `json:"total"`
+}
+
+// OrderLine is a single product line on an order
+type OrderLine struct {
+	SKU       string  `json:"sku"`
+	Title     string  `json:"title"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+}
+
+// Source pulls orders from an external sales channel
+type Source interface {
+	// Orders returns orders updated at or after since, oldest first
+	Orders(ctx context.Context, since time.Time) ([]Order, error)
+}
+
+// SourceConfig holds the connection settings for a tenant's order source
+type SourceConfig struct {
+	Type        string `json:"type"`                   // registered source name, e.g. "shopify"
+	Shop        string `json:"shop"`                   // store domain or account identifier
+	AccessToken string `json:"access_token,omitempty"` // API credential, never returned to clients
+}
+
+// Factory builds a Source from a tenant's settings
+type Factory func(config SourceConfig) (Source, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// RegisterSource makes an order source available by name. Adapters
+// register themselves from init.
+func RegisterSource(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// NewSource builds the source named by config.Type
+func NewSource(config SourceConfig) (Source, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[config.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported order source: %q", config.Type)
+	}
+	return factory(config)
+}
+
+// SourceTypes lists the registered source names
+func SourceTypes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,300 @@
+// outbox/aws.go
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+)
+
+// assumeRoleDuration is how long assumed role credentials last
+const assumeRoleDuration = time.Hour
+
+// awsCredentials sign AWS requests; sessionToken is set for assumed roles
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	expiresAt       time.Time
+}
+
+// awsSessions caches the credentials of assumed roles by access key, role
+// and external ID, so each role is assumed once an hour rather than once
+// per event
+type awsSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*awsCredentials
+}
+
+// credentials returns the credentials to sign a webhook's requests with:
+// its access key, or the role it assumes with that key
+func (a *awsSessions) credentials(ctx context.Context, httpClient *http.Client, config *tenantconfig.AWSCredentials, region string) (*awsCredentials, error) {
+	static := &awsCredentials{accessKeyID: config.AccessKeyID, secretAccessKey: config.SecretAccessKey}
+	if config.RoleARN == "" {
+		return static, nil
+	}
+
+	cacheKey := strings.Join([]string{config.AccessKeyID, config.RoleARN, config.ExternalID}, "|")
+	a.mu.Lock()
+	cached := a.sessions[cacheKey]
+	a.mu.Unlock()
+	if cached != nil && time.Until(cached.expiresAt) > 5*time.Minute {
+		return cached, nil
+	}
+
+	form := url.Values{}
+	form.Set("Action", "AssumeRole")
+	form.Set("Version", "2011-06-15")
+	form.Set("RoleArn", config.RoleARN)
+	form.Set("RoleSessionName", "qbserver-webhooks")
+	form.Set("DurationSeconds", strconv.Itoa(int(assumeRoleDuration.Seconds())))
+	if config.ExternalID != "" {
+		form.Set("ExternalId", config.ExternalID)
+	}
+	body, err := awsPost(ctx, httpClient, static, region, "sts", awsEndpoint("sts", region, "/"), form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assume role %s: %w", config.RoleARN, err)
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse assume role response: %w", err)
+	}
+	session := &awsCredentials{
+		accessKeyID:     result.Credentials.AccessKeyID,
+		secretAccessKey: result.Credentials.SecretAccessKey,
+		sessionToken:    result.Credentials.SessionToken,
+		expiresAt:       result.Credentials.Expiration,
+	}
+
+	a.mu.Lock()
+	if a.sessions == nil {
+		a.sessions = make(map[string]*awsCredentials)
+	}
+	a.sessions[cacheKey] = session
+	a.mu.Unlock()
+	return session, nil
+}
+
+// awsEndpoint returns the URL of a regional AWS service endpoint
+func awsEndpoint(service, region, path string) string {
+	host := fmt.Sprintf("%s.%s.amazonaws.com", service, region)
+	if strings.HasPrefix(region, "cn-") {
+		host += ".cn"
+	}
+	return "https://" + host + path
+}
+
+// awsAttributes adds message attributes to an SNS or SQS request in the
+// query protocol's numbered form, e.g. MessageAttributes.entry.1.Name
+func awsAttributes(form url.Values, prefix string, attributes map[string]string) {
+	n := 0
+	for _, name := range []string{"event_id", "event_type", "signature"} {
+		value, ok := attributes[name]
+		if !ok {
+			continue
+		}
+		n++
+		entry := fmt.Sprintf("%s.%d.", prefix, n)
+		form.Set(entry+"Name", name)
+		form.Set(entry+"Value.DataType", "String")
+		form.Set(entry+"Value.StringValue", value)
+	}
+}
+
+// publishSNS publishes the body to a webhook's SNS topic. FIFO topics group
+// messages by entity, so each entity's events stay in order.
+func (p *WebhookPublisher) publishSNS(ctx context.Context, webhook tenantconfig.Webhook, event *Event, attributes map[string]string, body []byte) error {
+	if webhook.AWS == nil {
+		return fmt.Errorf("aws credentials are missing")
+	}
+	// arn:<partition>:sns:<region>:<account>:<topic>
+	region := webhook.AWS.Region
+	if parts := strings.Split(webhook.Topic, ":"); region == "" && len(parts) == 6 {
+		region = parts[3]
+	}
+
+	form := url.Values{}
+	form.Set("Action", "Publish")
+	form.Set("Version", "2010-03-31")
+	form.Set("TopicArn", webhook.Topic)
+	form.Set("Message", string(body))
+	awsAttributes(form, "MessageAttributes.entry", attributes)
+	if strings.HasSuffix(webhook.Topic, ".fifo") {
+		form.Set("MessageGroupId", event.queue())
+		form.Set("MessageDeduplicationId", event.ID)
+	}
+
+	creds, err := p.aws.credentials(ctx, p.httpClient, webhook.AWS, region)
+	if err != nil {
+		return err
+	}
+	_, err = awsPost(ctx, p.httpClient, creds, region, "sns", awsEndpoint("sns", region, "/"), form)
+	return err
+}
+
+// publishSQS sends the body to a webhook's SQS queue. FIFO queues group
+// messages by entity, so each entity's events stay in order.
+func (p *WebhookPublisher) publishSQS(ctx context.Context, webhook tenantconfig.Webhook, event *Event, attributes map[string]string, body []byte) error {
+	if webhook.AWS == nil {
+		return fmt.Errorf("aws credentials are missing")
+	}
+	queueURL, err := url.Parse(webhook.URL)
+	if err != nil {
+		return fmt.Errorf("invalid queue url: %w", err)
+	}
+	// sqs.<region>.amazonaws.com
+	region := webhook.AWS.Region
+	if parts := strings.Split(queueURL.Host, "."); region == "" && len(parts) >= 4 {
+		region = parts[1]
+	}
+
+	form := url.Values{}
+	form.Set("Action", "SendMessage")
+	form.Set("Version", "2012-11-05")
+	form.Set("MessageBody", string(body))
+	awsAttributes(form, "MessageAttribute", attributes)
+	if strings.HasSuffix(queueURL.Path, ".fifo") {
+		form.Set("MessageGroupId", event.queue())
+		form.Set("MessageDeduplicationId", event.ID)
+	}
+
+	creds, err := p.aws.credentials(ctx, p.httpClient, webhook.AWS, region)
+	if err != nil {
+		return err
+	}
+	_, err = awsPost(ctx, p.httpClient, creds, region, "sqs", webhook.URL, form)
+	return err
+}
+
+// awsPost sends a SigV4-signed query protocol request and returns the
+// response body
+func awsPost(ctx context.Context, httpClient *http.Client, creds *awsCredentials, region, service, endpoint string, form url.Values) ([]byte, error) {
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signAWS(req, creds, region, service, body, time.Now())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", service, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var awsErr struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		if xml.Unmarshal(respBody, &awsErr) == nil && awsErr.Code != "" {
+			return nil, fmt.Errorf("%s status %d: %s: %s", service, resp.StatusCode, awsErr.Code, awsErr.Message)
+		}
+		return nil, fmt.Errorf("%s status %d: %s", service, resp.StatusCode, truncate(respBody, 512))
+	}
+	return respBody, nil
+}
+
+// truncate shortens an error response for messages
+func truncate(body []byte, n int) string {
+	if len(body) > n {
+		body = body[:n]
+	}
+	return string(body)
+}
+
+// signAWS adds AWS Signature Version 4 headers for a service
+func signAWS(req *http.Request, creds *awsCredentials, region, service string, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	signedHeaders := "content-type;host;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + creds.sessionToken + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+// awsEscapePath percent-encodes everything but unreserved characters and
+// slashes, as SigV4 canonical URIs require
+func awsEscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,159 @@
+// outbox/pubsub.go
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+)
+
+// pubSubBaseURL is the Pub/Sub REST API root
+const pubSubBaseURL = "https://pubsub.googleapis.com/v1"
+
+// pubSubScope is the OAuth scope requested for service accounts
+const pubSubScope = "https://www.googleapis.com/auth/pubsub"
+
+// googleToken is a cached OAuth access token of a service account
+type googleToken struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// googleTokens caches access tokens by service account key, so each
+// tenant's key is exchanged once an hour rather than once per event
+type googleTokens struct {
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]*googleToken
+}
+
+// token returns an access token for a service account key, exchanging a
+// signed JWT for a new one when the cached one is about to expire
+func (g *googleTokens) token(ctx context.Context, httpClient *http.Client, credentialsJSON string) (string, error) {
+	cacheKey := sha256.Sum256([]byte(credentialsJSON))
+	g.mu.Lock()
+	cached := g.tokens[cacheKey]
+	g.mu.Unlock()
+	if cached != nil && time.Until(cached.expiresAt) > time.Minute {
+		return cached.accessToken, nil
+	}
+
+	var account struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal([]byte(credentialsJSON), &account); err != nil {
+		return "", fmt.Errorf("invalid gcp credentials: %w", err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	key, err := parsePrivateKey(account.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(key, map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": pubSubScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, "POST", account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("google token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+
+	g.mu.Lock()
+	if g.tokens == nil {
+		g.tokens = make(map[[sha256.Size]byte]*googleToken)
+	}
+	g.tokens[cacheKey] = &googleToken{
+		accessToken: token.AccessToken,
+		expiresAt:   now.Add(time.Duration(token.ExpiresIn) * time.Second),
+	}
+	g.mu.Unlock()
+	return token.AccessToken, nil
+}
+
+// publishPubSub publishes the body to a webhook's Pub/Sub topic, with the
+// event's ID, type and signature as message attributes
+func (p *WebhookPublisher) publishPubSub(ctx context.Context, webhook tenantconfig.Webhook, attributes map[string]string, body []byte) error {
+	if webhook.GCP == nil {
+		return fmt.Errorf("gcp credentials are missing")
+	}
+	token, err := p.google.token(ctx, p.httpClient, webhook.GCP.CredentialsJSON)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"messages": []map[string]interface{}{{
+			"data":       base64.StdEncoding.EncodeToString(body),
+			"attributes": attributes,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal pubsub message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", pubSubBaseURL+"/"+webhook.Topic+":publish", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pubsub status %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// outbox/recorder.go
+package outbox
+
+import (
+	"context"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// eventSuffixes maps the write stages to event type suffixes
+var eventSuffixes = map[string]string{
+	qbclient.StageBeforeCreate: "created",
+	qbclient.StageBeforeUpdate: "updated",
+	qbclient.StageAfterCreate:  "created",
+	qbclient.StageAfterUpdate:  "updated",
+}
+
+// Recorder records an event for every QuickBooks create and update made on
+// behalf of a tenant. It runs as the last qbclient.Transformer, so events
+// carry the entity as QuickBooks returned it after every other hook.
+//
+// Before a write is sent its event is recorded pending under the write's
+// idempotency key; once the write succeeds the event is filled in and
+// queued. A write that fails leaves its event pending for a repeat, which
+// has the same key. Events still pending later, because the process died or
+// Redis failed between the write and its confirmation, are resolved by the
+// relay against QuickBooks.
+type Recorder struct {
+	store *Store
+}
+
+// NewRecorder creates a new outbox recorder
+func NewRecorder(store *Store) *Recorder {
+	return &Recorder{
+		store: store,
+	}
+}
+
+// Transform records the event and passes the entity through. A write whose
+// pending event cannot be recorded is not made. Once QuickBooks saved the
+// entity, failing to queue its event is logged rather than failing the
+// request.
+func (r *Recorder) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	suffix, ok := eventSuffixes[stage]
+	tenantID := auth.GetTenantID(ctx)
+	if !ok || tenantID == "" {
+		return data, nil
+	}
+	requestID := qbclient.WriteRequestID(ctx)
+	before := stage == qbclient.StageBeforeCreate || stage == qbclient.StageBeforeUpdate
+	if before && requestID == "" {
+		return data, nil
+	}
+
+	realmID, _ := auth.GetCompanyID(ctx)
+	entityID, _ := data["Id"].(string)
+	event, err := NewEvent(tenantID, realmID, strings.ToLower(entity)+"."+suffix, entity, entityID, data)
+	switch {
+	case before && err == nil:
+		err = r.store.AddPending(ctx, requestID, auth.GetUserID(ctx), event)
+	case err == nil:
+		err = r.store.Confirm(ctx, requestID, event)
+	}
+	if err != nil && before {
+		return nil, err
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to record outbox event",
+			"entity", entity, "entity_id", entityID, "request_id", requestID, "error", err)
+	}
+	return data, nil
+}
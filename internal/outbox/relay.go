@@ -0,0 +1,156 @@
+// outbox/relay.go
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+)
+
+// Publisher delivers an event. It may update event.Delivered to record
+// partial progress, which is saved when it returns an error so a retry
+// skips the destinations already reached.
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// Relay limits
+const (
+	maxAttempts  = 15
+	maxBackoff   = time.Hour
+	leaseTTL     = 2 * time.Minute
+	queueBatch   = 100
+	publishLimit = 30 * time.Second
+)
+
+// Relay publishes outbox events in the background. Delivery is at least
+// once: an event is removed only after it was published, so a crash between
+// the two publishes it again. Each entity's events are published strictly
+// in order; a failing event holds back the ones behind it until it succeeds
+// or exhausts its attempts. The relay also resolves events left pending by
+// writes that were never confirmed, by looking them up in QuickBooks.
+type Relay struct {
+	store        *Store
+	publisher    Publisher
+	qb           QuickBooks
+	pollInterval time.Duration
+}
+
+// NewRelay creates a new outbox relay
+func NewRelay(store *Store, publisher Publisher, qb QuickBooks, pollInterval time.Duration) *Relay {
+	return &Relay{
+		store:        store,
+		publisher:    publisher,
+		qb:           qb,
+		pollInterval: pollInterval,
+	}
+}
+
+// Start begins relaying events until the context is cancelled
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+
+		var swept time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Resolved events are queued before this poll relays them
+				if time.Since(swept) >= sweepInterval {
+					r.sweepPending(ctx)
+					swept = time.Now()
+				}
+				r.relayReady(ctx)
+			}
+		}
+	}()
+}
+
+// relayReady drains every queue that is due
+func (r *Relay) relayReady(ctx context.Context) {
+	queues, err := r.store.ready(ctx, time.Now(), queueBatch)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to poll outbox", "error", err)
+		return
+	}
+
+	for _, queue := range queues {
+		if ctx.Err() != nil {
+			return
+		}
+		acquired, err := r.store.lease(ctx, queue, leaseTTL)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to lease outbox queue", "queue", queue, "error", err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		r.drain(ctx, queue)
+		if err := r.store.release(ctx, queue); err != nil {
+			logging.FromContext(ctx).Warn("failed to release outbox queue", "queue", queue, "error", err)
+		}
+	}
+}
+
+// drain publishes a queue's events in order until it is empty or an event fails
+func (r *Relay) drain(ctx context.Context, queue string) {
+	logger := logging.FromContext(ctx).With("queue", queue)
+	started := time.Now()
+	for ctx.Err() == nil {
+		// Leave the rest for the next poll rather than outliving the lease
+		if time.Since(started) > leaseTTL/2 {
+			return
+		}
+
+		event, err := r.store.head(ctx, queue)
+		if err != nil {
+			logger.Warn("failed to read outbox queue", "error", err)
+			return
+		}
+		if event == nil {
+			return
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, publishLimit)
+		err = r.publisher.Publish(publishCtx, event)
+		cancel()
+		if err == nil {
+			if err := r.store.complete(ctx, event); err != nil {
+				logger.Warn("failed to complete outbox event", "event_id", event.ID, "error", err)
+				return
+			}
+			continue
+		}
+
+		event.Attempts++
+		event.LastError = err.Error()
+		if event.Attempts >= maxAttempts {
+			logger.Error("giving up on outbox event", "event_id", event.ID, "event_type", event.Type, "attempts", event.Attempts, "error", err)
+			if err := r.store.bury(ctx, event); err != nil {
+				logger.Warn("failed to bury outbox event", "event_id", event.ID, "error", err)
+				return
+			}
+			continue
+		}
+
+		if err := r.store.retry(ctx, event, time.Now().Add(backoff(event.Attempts))); err != nil {
+			logger.Warn("failed to reschedule outbox event", "event_id", event.ID, "error", err)
+		}
+		return
+	}
+}
+
+// backoff doubles the wait after each failed attempt, from 5 seconds up to an hour
+func backoff(attempts int) time.Duration {
+	wait := 5 * time.Second << uint(attempts-1)
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,364 @@
+// outbox/store.go
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+// Event is a domain event waiting to be published
+type Event struct {
+	ID        string          `json:"id"`
+	TenantID  string          `json:"tenant_id"`
+	RealmID   string          `json:"realm_id,omitempty"`
+	Type      string          `json:"type"` // e.g. "invoice.created"
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	Attempts  int      `json:"attempts,omitempty"`
+	LastError string   `json:"last_error,omitempty"`
+	Delivered []string `json:"delivered,omitempty"` // webhook IDs already delivered to
+}
+
+// NewEvent creates an event for an entity change
+func NewEvent(tenantID, realmID, eventType, entity, entityID string, data interface{}) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	return &Event{
+		ID:        jobs.NewID(),
+		TenantID:  tenantID,
+		RealmID:   realmID,
+		Type:      eventType,
+		Entity:    entity,
+		EntityID:  entityID,
+		Data:      raw,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// queue names the ordered queue an event belongs to. Events for the same
+// entity share a queue and are published in the order they were appended.
+func (e *Event) queue() string {
+	return strings.Join([]string{e.TenantID, strings.ToLower(e.Entity), e.EntityID}, ":")
+}
+
+// pendingTTL is how long the event of a write that never completed is kept
+// for the write's repeat; it outlasts the retry queue's replays
+const pendingTTL = 7 * 24 * time.Hour
+
+// pendingWrite is the event recorded for a write in flight, with the user
+// who made it so the relay can look the write up in QuickBooks
+type pendingWrite struct {
+	Event
+	UserID string `json:"user_id,omitempty"`
+}
+
+// removeIfEmpty drops a queue from the ready set once it has drained, without
+// racing an append that lands between the check and the removal
+var removeIfEmpty = redis.NewScript(`
+if redis.call("LLEN", KEYS[1]) == 0 then
+	return redis.call("ZREM", KEYS[2], ARGV[1])
+end
+return 0`)
+
+// Store keeps outbox events in Redis. Each entity has a list of event IDs;
+// a sorted set holds the queues with pending events, scored by when the
+// relay may next try them.
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new outbox store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// eventKey holds one event
+func (s *Store) eventKey(id string) string {
+	return fmt.Sprintf("%s:outbox:event:%s", s.prefix, id)
+}
+
+// queueKey holds an entity's pending event IDs in order
+func (s *Store) queueKey(queue string) string {
+	return fmt.Sprintf("%s:outbox:queue:%s", s.prefix, queue)
+}
+
+// readyKey holds the queues with pending events
+func (s *Store) readyKey() string {
+	return fmt.Sprintf("%s:outbox:ready", s.prefix)
+}
+
+// deadKey holds a tenant's events that exhausted their attempts
+func (s *Store) deadKey(tenantID string) string {
+	return fmt.Sprintf("%s:outbox:dead:%s", s.prefix, tenantID)
+}
+
+// pendingKey holds the event of a write in flight, by its idempotency key
+func (s *Store) pendingKey(requestID string) string {
+	return fmt.Sprintf("%s:outbox:pending:%s", s.prefix, requestID)
+}
+
+// inflightKey holds the idempotency keys of pending events, scored by when
+// they were recorded
+func (s *Store) inflightKey() string {
+	return fmt.Sprintf("%s:outbox:inflight", s.prefix)
+}
+
+// leaseKey marks a queue as being relayed by one instance
+func (s *Store) leaseKey(queue string) string {
+	return fmt.Sprintf("%s:outbox:lease:%s", s.prefix, queue)
+}
+
+// Append records events for publishing
+func (s *Store) Append(ctx context.Context, events ...*Event) error {
+	return s.AppendTx(ctx, nil, events...)
+}
+
+// AppendTx records events in the same Redis transaction as the writes fn
+// queues, so local state and its events are saved together or not at all
+func (s *Store) AppendTx(ctx context.Context, fn func(pipe redis.Pipeliner) error, events ...*Event) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if fn != nil {
+			if err := fn(pipe); err != nil {
+				return err
+			}
+		}
+		now := float64(time.Now().UnixNano() / int64(time.Millisecond))
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal event: %w", err)
+			}
+			pipe.Set(ctx, s.eventKey(event.ID), data, 0)
+			pipe.RPush(ctx, s.queueKey(event.queue()), event.ID)
+			// NX keeps the backoff of a queue that is already waiting to retry
+			pipe.ZAddNX(ctx, s.readyKey(), &redis.Z{Score: now, Member: event.queue()})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append outbox events: %w", err)
+	}
+	return nil
+}
+
+// AddPending records the event of a write userID is about to make. It is
+// not published until Confirm queues it once the write succeeded, or the
+// relay finds the write in QuickBooks.
+func (s *Store) AddPending(ctx context.Context, requestID, userID string, event *Event) error {
+	data, err := json.Marshal(pendingWrite{Event: *event, UserID: userID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.pendingKey(requestID), data, pendingTTL)
+		pipe.ZAdd(ctx, s.inflightKey(), &redis.Z{
+			Score:  float64(time.Now().UnixNano() / int64(time.Millisecond)),
+			Member: requestID,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record pending outbox event: %w", err)
+	}
+	return nil
+}
+
+// Confirm queues the event of a write that succeeded, in place of the event
+// recorded pending for it, whose ID it keeps. Without a pending event it
+// queues the event as is.
+func (s *Store) Confirm(ctx context.Context, requestID string, event *Event) error {
+	if requestID == "" {
+		return s.Append(ctx, event)
+	}
+
+	data, err := s.client.Get(ctx, s.pendingKey(requestID)).Bytes()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get pending outbox event: %w", err)
+	}
+	if err == nil {
+		var pending Event
+		if err := json.Unmarshal(data, &pending); err != nil {
+			return fmt.Errorf("failed to unmarshal pending outbox event: %w", err)
+		}
+		event.ID, event.CreatedAt = pending.ID, pending.CreatedAt
+	}
+
+	return s.AppendTx(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.pendingKey(requestID))
+		pipe.ZRem(ctx, s.inflightKey(), requestID)
+		return nil
+	}, event)
+}
+
+// stale returns up to limit idempotency keys of events pending since before
+// the given time
+func (s *Store) stale(ctx context.Context, before time.Time, limit int64) ([]string, error) {
+	ids, err := s.client.ZRangeByScore(ctx, s.inflightKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprint(before.UnixNano() / int64(time.Millisecond)),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending outbox events: %w", err)
+	}
+	return ids, nil
+}
+
+// pending returns the event pending under an idempotency key, or nil once
+// it was confirmed or expired
+func (s *Store) pending(ctx context.Context, requestID string) (*pendingWrite, error) {
+	data, err := s.client.Get(ctx, s.pendingKey(requestID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending outbox event: %w", err)
+	}
+
+	var write pendingWrite
+	if err := json.Unmarshal(data, &write); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending outbox event: %w", err)
+	}
+	return &write, nil
+}
+
+// dropPending forgets the event of a write that never reached QuickBooks
+func (s *Store) dropPending(ctx context.Context, requestID string) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.pendingKey(requestID))
+		pipe.ZRem(ctx, s.inflightKey(), requestID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drop pending outbox event: %w", err)
+	}
+	return nil
+}
+
+// ready returns up to limit queues due for relaying
+func (s *Store) ready(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	queues, err := s.client.ZRangeByScore(ctx, s.readyKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprint(now.UnixNano() / int64(time.Millisecond)),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ready outbox queues: %w", err)
+	}
+	return queues, nil
+}
+
+// lease claims a queue for relaying; it returns false when another instance holds it
+func (s *Store) lease(ctx context.Context, queue string, ttl time.Duration) (bool, error) {
+	acquired, err := s.client.SetNX(ctx, s.leaseKey(queue), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to lease outbox queue: %w", err)
+	}
+	return acquired, nil
+}
+
+// release gives up a queue lease
+func (s *Store) release(ctx context.Context, queue string) error {
+	return s.client.Del(ctx, s.leaseKey(queue)).Err()
+}
+
+// head returns the oldest pending event of a queue, or nil once it has
+// drained, in which case the queue leaves the ready set
+func (s *Store) head(ctx context.Context, queue string) (*Event, error) {
+	id, err := s.client.LIndex(ctx, s.queueKey(queue), 0).Result()
+	if err == redis.Nil {
+		if err := removeIfEmpty.Run(ctx, s.client, []string{s.queueKey(queue), s.readyKey()}, queue).Err(); err != nil {
+			return nil, fmt.Errorf("failed to remove drained outbox queue: %w", err)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox queue: %w", err)
+	}
+
+	data, err := s.client.Get(ctx, s.eventKey(id)).Bytes()
+	if err == redis.Nil {
+		// The ID outlived its event; drop it so the queue keeps moving
+		if err := s.client.LRem(ctx, s.queueKey(queue), 1, id).Err(); err != nil {
+			return nil, fmt.Errorf("failed to drop missing outbox event: %w", err)
+		}
+		return s.head(ctx, queue)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox event: %w", err)
+	}
+
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outbox event: %w", err)
+	}
+	return &event, nil
+}
+
+// complete removes a published event from the head of its queue
+func (s *Store) complete(ctx context.Context, event *Event) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, s.queueKey(event.queue()), 1, event.ID)
+		pipe.Del(ctx, s.eventKey(event.ID))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete outbox event: %w", err)
+	}
+	return nil
+}
+
+// retry saves a failed attempt and holds the event's queue until next
+func (s *Store) retry(ctx context.Context, event *Event, next time.Time) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.eventKey(event.ID), data, 0)
+		pipe.ZAdd(ctx, s.readyKey(), &redis.Z{
+			Score:  float64(next.UnixNano() / int64(time.Millisecond)),
+			Member: event.queue(),
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule outbox retry: %w", err)
+	}
+	return nil
+}
+
+// bury moves an event that exhausted its attempts to the tenant's dead
+// letters so later events for the entity are not held up forever
+func (s *Store) bury(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, s.deadKey(event.TenantID), data)
+		pipe.LTrim(ctx, s.deadKey(event.TenantID), 0, 999)
+		pipe.LRem(ctx, s.queueKey(event.queue()), 1, event.ID)
+		pipe.Del(ctx, s.eventKey(event.ID))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to bury outbox event: %w", err)
+	}
+	return nil
+}
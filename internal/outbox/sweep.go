@@ -0,0 +1,180 @@
+// outbox/sweep.go
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Sweep limits
+const (
+	// pendingAge is how long a write may take before its pending event is
+	// resolved against QuickBooks; writes and their retries settle well
+	// within it
+	pendingAge    = 10 * time.Minute
+	sweepInterval = time.Minute
+	// clockSkew allows for QuickBooks timestamps running behind ours
+	clockSkew = time.Minute
+	// sweepLease is the lease name held by the instance sweeping
+	sweepLease = "sweep"
+)
+
+// QuickBooks reads the entities of pending writes
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// sweepPending resolves events left pending by writes that were never
+// confirmed. Writes QuickBooks saved have their events queued with the
+// entity as it is now; the others are dropped. Events QuickBooks cannot be
+// asked about yet stay pending for the next sweep.
+func (r *Relay) sweepPending(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	acquired, err := r.store.lease(ctx, sweepLease, leaseTTL)
+	if err != nil {
+		logger.Warn("failed to lease outbox sweep", "error", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	defer func() {
+		if err := r.store.release(ctx, sweepLease); err != nil {
+			logger.Warn("failed to release outbox sweep", "error", err)
+		}
+	}()
+
+	ids, err := r.store.stale(ctx, time.Now().Add(-pendingAge), queueBatch)
+	if err != nil {
+		logger.Warn("failed to poll pending outbox events", "error", err)
+		return
+	}
+	for _, requestID := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := r.resolve(ctx, requestID); err != nil {
+			logger.Warn("failed to resolve pending outbox event", "request_id", requestID, "error", err)
+		}
+	}
+}
+
+// resolve confirms or drops one pending event
+func (r *Relay) resolve(ctx context.Context, requestID string) error {
+	write, err := r.store.pending(ctx, requestID)
+	if err != nil {
+		return err
+	}
+	if write == nil {
+		logging.FromContext(ctx).Error("pending outbox event expired unresolved", "request_id", requestID)
+		return r.store.dropPending(ctx, requestID)
+	}
+
+	qbCtx := auth.WithIdentity(ctx, write.UserID, write.TenantID, write.RealmID)
+	saved, err := r.find(qbCtx, &write.Event)
+	if err != nil {
+		return err
+	}
+	if saved == nil {
+		logging.FromContext(ctx).Info("dropped pending outbox event of a write QuickBooks did not save",
+			"request_id", requestID, "event_type", write.Type, "entity_id", write.EntityID)
+		return r.store.dropPending(ctx, requestID)
+	}
+
+	event := write.Event
+	event.EntityID, _ = saved["Id"].(string)
+	if event.Data, err = json.Marshal(saved); err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	return r.store.Confirm(ctx, requestID, &event)
+}
+
+// find returns the entity a pending write saved, or nil if QuickBooks has
+// no sign of it. An update is found when its entity changed after the event
+// was recorded; a create when an entity created since carries the values
+// it sent.
+func (r *Relay) find(ctx context.Context, event *Event) (map[string]interface{}, error) {
+	var sent map[string]interface{}
+	if err := json.Unmarshal(event.Data, &sent); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
+	}
+	since := event.CreatedAt.Add(-clockSkew)
+
+	if event.EntityID != "" {
+		q := query.Select(event.Entity).Where("Id", "=", event.EntityID)
+		entities, err := query.List[map[string]interface{}](ctx, r.qb, q)
+		if err != nil {
+			return nil, err
+		}
+		if len(entities) == 0 || !updatedSince(entities[0], since) {
+			return nil, nil
+		}
+		return entities[0], nil
+	}
+
+	q := query.Select(event.Entity).
+		Where("MetaData.CreateTime", ">=", since).
+		Where("MetaData.CreateTime", "<=", event.CreatedAt.Add(pendingAge+clockSkew))
+	entities, err := query.All[map[string]interface{}](ctx, r.qb, q)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		if carries(entity, sent) {
+			return entity, nil
+		}
+	}
+	return nil, nil
+}
+
+// updatedSince reports whether an entity was last updated at or after since
+func updatedSince(entity map[string]interface{}, since time.Time) bool {
+	meta, _ := entity["MetaData"].(map[string]interface{})
+	updated, _ := meta["LastUpdatedTime"].(string)
+	t, err := time.Parse(time.RFC3339, updated)
+	return err == nil && !t.Before(since)
+}
+
+// carries reports whether a saved entity has the scalar fields and
+// references a write sent. Fields QuickBooks leaves out of its response are
+// not compared.
+func carries(saved, sent map[string]interface{}) bool {
+	for field, value := range sent {
+		have, ok := saved[field]
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			ref, isRef := v["value"]
+			haveRef, _ := have.(map[string]interface{})
+			if isRef && (haveRef == nil || !sameValue(haveRef["value"], ref)) {
+				return false
+			}
+		case []interface{}:
+		default:
+			if !sameValue(have, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameValue compares JSON scalars, numbers by value so "10.00" matches 10
+func sameValue(a, b interface{}) bool {
+	x, y := fmt.Sprint(a), fmt.Sprint(b)
+	if x == y {
+		return true
+	}
+	fx, errX := strconv.ParseFloat(x, 64)
+	fy, errY := strconv.ParseFloat(y, 64)
+	return errX == nil && errY == nil && fx == fy
+}
@@ -0,0 +1,158 @@
+// outbox/webhook.go
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+)
+
+// WebhookPublisher delivers events to the webhooks in each tenant's
+// configuration that subscribe to them: HTTP endpoints, Pub/Sub and SNS
+// topics and SQS queues. Receivers should deduplicate on the event ID,
+// the X-Event-ID header or event_id attribute, since delivery is at least
+// once.
+type WebhookPublisher struct {
+	config     *tenantconfig.Service
+	httpClient *http.Client
+	google     googleTokens
+	aws        awsSessions
+}
+
+// NewWebhookPublisher creates a new webhook publisher
+func NewWebhookPublisher(config *tenantconfig.Service) *WebhookPublisher {
+	return &WebhookPublisher{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Publish posts the event to every subscribed webhook not yet delivered to
+func (p *WebhookPublisher) Publish(ctx context.Context, event *Event) error {
+	stored, err := p.config.Get(ctx, event.TenantID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         event.ID,
+		"type":       event.Type,
+		"realm_id":   event.RealmID,
+		"entity":     event.Entity,
+		"entity_id":  event.EntityID,
+		"created_at": event.CreatedAt,
+		"data":       event.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook body: %w", err)
+	}
+
+	var failures []string
+	for _, webhook := range stored.Document.Webhooks {
+		if webhook.Disabled || !subscribed(webhook.Events, event.Type) || contains(event.Delivered, webhook.ID) {
+			continue
+		}
+		if err := p.deliver(ctx, webhook, event, body); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", webhook.ID, err))
+			continue
+		}
+		event.Delivered = append(event.Delivered, webhook.ID)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("webhook delivery failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// deliver sends the body to one webhook by its type. Cloud targets carry
+// the event ID, type and signature as message attributes.
+func (p *WebhookPublisher) deliver(ctx context.Context, webhook tenantconfig.Webhook, event *Event, body []byte) error {
+	if webhook.Type == "" || webhook.Type == tenantconfig.WebhookHTTP {
+		return p.post(ctx, webhook, event, body)
+	}
+
+	attributes := map[string]string{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+	}
+	if webhook.Secret != "" {
+		attributes["signature"] = sign(webhook.Secret, body)
+	}
+	switch webhook.Type {
+	case tenantconfig.WebhookPubSub:
+		return p.publishPubSub(ctx, webhook, attributes, body)
+	case tenantconfig.WebhookSNS:
+		return p.publishSNS(ctx, webhook, event, attributes, body)
+	case tenantconfig.WebhookSQS:
+		return p.publishSQS(ctx, webhook, event, attributes, body)
+	}
+	return fmt.Errorf("unsupported webhook type %q", webhook.Type)
+}
+
+// sign returns the hex HMAC-SHA256 of the body with a webhook's secret
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// post delivers the body to an HTTP webhook, signed with its secret
+func (p *WebhookPublisher) post(ctx context.Context, webhook tenantconfig.Webhook, event *Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID)
+	req.Header.Set("X-Event-Type", event.Type)
+	if webhook.Secret != "" {
+		req.Header.Set("X-Signature", sign(webhook.Secret, body))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
+
+// subscribed reports whether any pattern matches the event type. Patterns
+// are "*", "invoice.*" or an exact type such as "invoice.created".
+func subscribed(patterns []string, eventType string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if strings.HasSuffix(pattern, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// packingslip/handler.go
+package packingslip
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for packing slips and delivery notes
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new packing slip handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a packing slip error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrInvoiceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnknownKind):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNothingToShip):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// GetSlip renders a packing slip for an invoice as PDF, or a delivery note
+// with ?kind=delivery
+func (h *Handler) GetSlip(w http.ResponseWriter, r *http.Request) {
+	data, slip, err := h.service.Render(r.Context(), auth.GetTenantID(r.Context()), mux.Vars(r)["id"], r.URL.Query().Get("kind"))
+	if err != nil {
+		http.Error(w, "Failed to render slip: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="`+slip.FileName()+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// AttachSlip renders a slip like GetSlip and attaches it to the invoice in
+// QuickBooks; ?include_on_send=true sends it along with the invoice
+func (h *Handler) AttachSlip(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	includeOnSend := false
+	if value := query.Get("include_on_send"); value != "" {
+		var err error
+		if includeOnSend, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "include_on_send must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	attachable, err := h.service.Attach(r.Context(), auth.GetTenantID(r.Context()), mux.Vars(r)["id"], query.Get("kind"), includeOnSend)
+	if err != nil {
+		http.Error(w, "Failed to attach slip: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachable)
+}
@@ -0,0 +1,277 @@
+// packingslip/render.go
+package packingslip
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/pdf"
+)
+
+// Layout in points
+const (
+	margin       = 48
+	footerHeight = 40
+	logoWidth    = 150
+	logoHeight   = 56
+	rowPadding   = 6
+	lineHeight   = 12
+	bodySize     = 9
+)
+
+// renderer lays a slip out over as many pages as its lines need
+type renderer struct {
+	doc    *pdf.Document
+	size   pdf.Size
+	accent pdf.Color
+	page   *pdf.Page
+	pages  []*pdf.Page
+	y      float64
+}
+
+// render lays out a slip as PDF with the tenant's logo, accent color and
+// footer
+func render(slip *Slip, brand *branding.Branding) ([]byte, error) {
+	r := &renderer{
+		doc:    pdf.New(slip.Title() + " " + slip.Number),
+		size:   pdf.SizeFor(slip.Country),
+		accent: brand.Accent(),
+	}
+	r.newPage()
+
+	logo, err := brand.LogoImage()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.header(slip, logo); err != nil {
+		return nil, err
+	}
+	r.addresses(slip)
+	r.items(slip)
+	r.notes(slip)
+	if slip.Kind == KindDeliveryNote {
+		r.signature()
+	}
+	r.footers(brand.Footer)
+
+	return r.doc.Bytes()
+}
+
+// newPage starts a page and moves to its top
+func (r *renderer) newPage() {
+	r.page = r.doc.AddPage(r.size)
+	r.pages = append(r.pages, r.page)
+	r.y = margin
+}
+
+// ensure starts a new page unless height fits above the footer
+func (r *renderer) ensure(height float64) bool {
+	if r.y+height <= r.size.Height-margin-footerHeight {
+		return false
+	}
+	r.newPage()
+	return true
+}
+
+// header draws the logo, company and the slip's title and references
+func (r *renderer) header(slip *Slip, logo image.Image) error {
+	left := float64(margin)
+	if logo != nil {
+		img, err := r.doc.AddImage(logo)
+		if err != nil {
+			return err
+		}
+		scale := math.Min(logoWidth/float64(img.Width), logoHeight/float64(img.Height))
+		height := float64(img.Height) * scale
+		r.page.Image(img, margin, margin, float64(img.Width)*scale, height)
+		left += height + 8
+	}
+	left += 11
+	r.page.Text(margin, left, pdf.HelveticaBold, 11, pdf.Black, slip.Company)
+	for _, line := range slip.CompanyLines {
+		left += lineHeight
+		r.page.Text(margin, left, pdf.Helvetica, bodySize, pdf.Gray, line)
+	}
+
+	right := float64(margin) + 20
+	edge := r.size.Width - margin
+	r.page.TextRight(edge, right, pdf.HelveticaBold, 20, r.accent, slip.Title())
+	right += 8
+	references := [][2]string{
+		{"Invoice no.", slip.Number},
+		{"Date", slip.Date},
+		{"Ship date", slip.ShipDate},
+		{"Ship via", slip.ShipVia},
+		{"Tracking no.", slip.TrackingNum},
+	}
+	for _, reference := range references {
+		if reference[1] == "" {
+			continue
+		}
+		right += lineHeight
+		r.page.TextRight(edge, right, pdf.Helvetica, bodySize, pdf.Black, reference[1])
+		r.page.TextRight(edge-110, right, pdf.Helvetica, bodySize, pdf.Gray, reference[0])
+	}
+
+	r.y = math.Max(left, right) + 16
+	r.page.Line(margin, r.y, edge, r.y, 1.5, r.accent)
+	r.y += 22
+	return nil
+}
+
+// addresses draws the ship-to and bill-to blocks side by side
+func (r *renderer) addresses(slip *Slip) {
+	shipLabel := "SHIP TO"
+	if slip.Kind == KindDeliveryNote {
+		shipLabel = "DELIVER TO"
+	}
+	column := (r.size.Width - 2*margin) / 2
+	bottom := r.y
+	for i, block := range []struct {
+		label string
+		lines []string
+	}{
+		{shipLabel, slip.ShipTo},
+		{"BILL TO", slip.BillTo},
+	} {
+		x := margin + float64(i)*column
+		y := r.y
+		r.page.Text(x, y, pdf.HelveticaBold, 8, r.accent, block.label)
+		y += lineHeight + 2
+		r.page.Text(x, y, pdf.HelveticaBold, 10, pdf.Black, slip.Customer)
+		for _, line := range block.lines {
+			for _, wrapped := range pdf.Wrap(pdf.Helvetica, bodySize, column-12, line) {
+				y += lineHeight
+				r.page.Text(x, y, pdf.Helvetica, bodySize, pdf.Black, wrapped)
+			}
+		}
+		bottom = math.Max(bottom, y)
+	}
+	r.y = bottom + 28
+}
+
+// columns are the left edges of the item table's columns; quantities are
+// right-aligned to the margin
+type columns struct {
+	item, sku, description, quantity float64
+}
+
+// tableColumns returns the item table's layout for the page width
+func (r *renderer) tableColumns() columns {
+	return columns{
+		item:        margin,
+		sku:         margin + 140,
+		description: margin + 230,
+		quantity:    r.size.Width - margin - 50,
+	}
+}
+
+// tableHeader draws the item table's heading band
+func (r *renderer) tableHeader() {
+	cols := r.tableColumns()
+	r.page.Rect(margin, r.y, r.size.Width-2*margin, 18, r.accent)
+	baseline := r.y + 12
+	r.page.Text(cols.item+4, baseline, pdf.HelveticaBold, bodySize, pdf.White, "Item")
+	r.page.Text(cols.sku, baseline, pdf.HelveticaBold, bodySize, pdf.White, "SKU")
+	r.page.Text(cols.description, baseline, pdf.HelveticaBold, bodySize, pdf.White, "Description")
+	r.page.TextRight(r.size.Width-margin-4, baseline, pdf.HelveticaBold, bodySize, pdf.White, "Qty")
+	r.y += 18
+}
+
+// items draws the item table, repeating its heading on each page
+func (r *renderer) items(slip *Slip) {
+	cols := r.tableColumns()
+	r.ensure(18 + lineHeight + 2*rowPadding)
+	r.tableHeader()
+	for _, line := range slip.Lines {
+		item := pdf.Wrap(pdf.Helvetica, bodySize, cols.sku-cols.item-12, line.Item)
+		sku := pdf.Wrap(pdf.Helvetica, bodySize, cols.description-cols.sku-8, line.SKU)
+		description := pdf.Wrap(pdf.Helvetica, bodySize, cols.quantity-cols.description-8, line.Description)
+		rows := len(item)
+		if len(sku) > rows {
+			rows = len(sku)
+		}
+		if len(description) > rows {
+			rows = len(description)
+		}
+		height := float64(rows)*lineHeight + 2*rowPadding
+		if r.ensure(height) {
+			r.tableHeader()
+		}
+
+		baseline := r.y + rowPadding + bodySize
+		for i, text := range item {
+			r.page.Text(cols.item+4, baseline+float64(i)*lineHeight, pdf.Helvetica, bodySize, pdf.Black, text)
+		}
+		for i, text := range sku {
+			r.page.Text(cols.sku, baseline+float64(i)*lineHeight, pdf.Helvetica, bodySize, pdf.Gray, text)
+		}
+		for i, text := range description {
+			r.page.Text(cols.description, baseline+float64(i)*lineHeight, pdf.Helvetica, bodySize, pdf.Black, text)
+		}
+		r.page.TextRight(r.size.Width-margin-4, baseline, pdf.HelveticaBold, bodySize, pdf.Black, line.Quantity.String())
+		r.y += height
+		r.page.Line(margin, r.y, r.size.Width-margin, r.y, 0.5, pdf.Gray)
+	}
+
+	r.ensure(lineHeight + 10)
+	r.y += lineHeight + 4
+	r.page.TextRight(r.size.Width-margin-60, r.y, pdf.Helvetica, bodySize, pdf.Gray, "Total quantity")
+	r.page.TextRight(r.size.Width-margin-4, r.y, pdf.HelveticaBold, bodySize, pdf.Black, slip.TotalQuantity.String())
+	r.y += 20
+}
+
+// notes draws the invoice's message to the customer
+func (r *renderer) notes(slip *Slip) {
+	if strings.TrimSpace(slip.Memo) == "" {
+		return
+	}
+	lines := pdf.Wrap(pdf.Helvetica, bodySize, r.size.Width-2*margin, slip.Memo)
+	r.ensure(float64(len(lines)+1)*lineHeight + 8)
+	r.y += 8
+	r.page.Text(margin, r.y, pdf.HelveticaBold, 8, r.accent, "NOTES")
+	for _, line := range lines {
+		r.y += lineHeight
+		r.page.Text(margin, r.y, pdf.Helvetica, bodySize, pdf.Black, line)
+	}
+	r.y += 16
+}
+
+// signature draws the fields the recipient fills in on delivery
+func (r *renderer) signature() {
+	r.ensure(100)
+	r.y += 12
+	r.page.Text(margin, r.y, pdf.HelveticaBold, 8, r.accent, "RECEIVED IN GOOD CONDITION")
+	r.y += 10
+	width := (r.size.Width - 2*margin - 40) / 3
+	for i, label := range []string{"Name", "Signature", "Date"} {
+		x := margin + float64(i)*(width+20)
+		r.page.Line(x, r.y+36, x+width, r.y+36, 0.75, pdf.Black)
+		r.page.Text(x, r.y+48, pdf.Helvetica, 8, pdf.Gray, label)
+	}
+	r.y += 60
+}
+
+// footers draws the tenant's footer text and page numbers on every page
+func (r *renderer) footers(text string) {
+	edge := r.size.Width - margin
+	lines := pdf.Wrap(pdf.Helvetica, 8, edge-margin-70, text)
+	if len(lines) > 2 {
+		lines = lines[:2]
+	}
+	for i, page := range r.pages {
+		top := r.size.Height - margin - footerHeight + 20
+		page.Line(margin, top, edge, top, 0.5, pdf.Gray)
+		y := top + 12
+		for _, line := range lines {
+			if line != "" {
+				page.Text(margin, y, pdf.Helvetica, 8, pdf.Gray, line)
+			}
+			y += 10
+		}
+		page.TextRight(edge, top+12, pdf.Helvetica, 8, pdf.Gray, fmt.Sprintf("Page %d of %d", i+1, len(r.pages)))
+	}
+}
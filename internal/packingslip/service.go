@@ -0,0 +1,190 @@
+// packingslip/service.go
+package packingslip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Slip kinds
+const (
+	KindPackingSlip  = "packing"
+	KindDeliveryNote = "delivery"
+)
+
+var (
+	// ErrInvoiceNotFound is returned when the invoice does not exist
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrUnknownKind is returned for a slip kind other than packing or delivery
+	ErrUnknownKind = errors.New("kind must be packing or delivery")
+	// ErrNothingToShip is returned for invoices without item lines
+	ErrNothingToShip = errors.New("invoice has no items to ship")
+)
+
+// QuickBooks reads invoices and uploads attachments
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	GetCompanyInfo(ctx context.Context) (*qbclient.CompanyInfo, error)
+	Upload(ctx context.Context, attachable *qbclient.Attachable, content []byte) (*qbclient.Attachable, error)
+}
+
+// Line is one item to pick and ship
+type Line struct {
+	Item        string       `json:"item"`
+	SKU         string       `json:"sku,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Quantity    money.Amount `json:"quantity"`
+
+	itemID string
+}
+
+// Slip is a packing slip or delivery note for an invoice. It lists what
+// ships without prices.
+type Slip struct {
+	Kind          string       `json:"kind"`
+	InvoiceID     string       `json:"invoice_id"`
+	Number        string       `json:"number"`
+	Date          string       `json:"date"`
+	ShipDate      string       `json:"ship_date,omitempty"`
+	ShipVia       string       `json:"ship_via,omitempty"`
+	TrackingNum   string       `json:"tracking_num,omitempty"`
+	Company       string       `json:"company"`
+	CompanyLines  []string     `json:"company_lines,omitempty"`
+	Country       string       `json:"country,omitempty"`
+	Customer      string       `json:"customer"`
+	ShipTo        []string     `json:"ship_to,omitempty"`
+	BillTo        []string     `json:"bill_to,omitempty"`
+	Memo          string       `json:"memo,omitempty"`
+	Lines         []Line       `json:"lines"`
+	TotalQuantity money.Amount `json:"total_quantity"`
+}
+
+// Title returns the heading of the slip
+func (s *Slip) Title() string {
+	if s.Kind == KindDeliveryNote {
+		return "Delivery Note"
+	}
+	return "Packing Slip"
+}
+
+// FileName returns the name the slip's PDF is served and attached under
+func (s *Slip) FileName() string {
+	return strings.ReplaceAll(strings.ToLower(s.Title()), " ", "-") + "-" + s.Number + ".pdf"
+}
+
+// Service renders packing slips and delivery notes from invoices
+type Service struct {
+	qb       QuickBooks
+	branding *branding.Store
+}
+
+// NewService creates a new packing slip service
+func NewService(qb QuickBooks, branding *branding.Store) *Service {
+	return &Service{
+		qb:       qb,
+		branding: branding,
+	}
+}
+
+// Render builds a slip of the given kind for an invoice and renders it as
+// PDF with the tenant's branding
+func (s *Service) Render(ctx context.Context, tenantID, invoiceID, kind string) ([]byte, *Slip, error) {
+	if kind == "" {
+		kind = KindPackingSlip
+	}
+	if kind != KindPackingSlip && kind != KindDeliveryNote {
+		return nil, nil, ErrUnknownKind
+	}
+	if !query.IsID(invoiceID) {
+		return nil, nil, ErrInvoiceNotFound
+	}
+
+	invoices, err := query.List[qbInvoice](ctx, s.qb, query.Select("Invoice").Where("Id", "=", invoiceID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(invoices) == 0 {
+		return nil, nil, ErrInvoiceNotFound
+	}
+	company, err := s.qb.GetCompanyInfo(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch company info: %w", err)
+	}
+	brand, err := s.branding.Get(ctx, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slip := build(kind, invoices[0], company, brand)
+	if len(slip.Lines) == 0 {
+		return nil, nil, ErrNothingToShip
+	}
+	skus, err := s.skus(ctx, slip.Lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range slip.Lines {
+		slip.Lines[i].SKU = skus[slip.Lines[i].itemID]
+	}
+
+	data, err := render(slip, brand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, slip, nil
+}
+
+// skus returns the SKU of each item the lines reference by item ID
+func (s *Service) skus(ctx context.Context, lines []Line) (map[string]string, error) {
+	seen := make(map[string]bool)
+	var ids []interface{}
+	for _, line := range lines {
+		if query.IsID(line.itemID) && !seen[line.itemID] {
+			seen[line.itemID] = true
+			ids = append(ids, line.itemID)
+		}
+	}
+	skus := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return skus, nil
+	}
+
+	items, err := query.All[struct {
+		ID  string `json:"Id"`
+		Sku string `json:"Sku"`
+	}](ctx, s.qb, query.Select("Item", "Id", "Sku").WhereIn("Id", ids...))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		skus[item.ID] = item.Sku
+	}
+	return skus, nil
+}
+
+// Attach renders a slip and attaches it to the invoice in QuickBooks. With
+// includeOnSend it goes out with the invoice's emails.
+func (s *Service) Attach(ctx context.Context, tenantID, invoiceID, kind string, includeOnSend bool) (*qbclient.Attachable, error) {
+	data, slip, err := s.Render(ctx, tenantID, invoiceID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	attachable := &qbclient.Attachable{
+		FileName:    slip.FileName(),
+		ContentType: "application/pdf",
+		Note:        slip.Title() + " for invoice " + slip.Number,
+		AttachableRef: []qbclient.AttachableRef{{
+			EntityRef:     qbclient.EntityRef{Type: "Invoice", Value: slip.InvoiceID},
+			IncludeOnSend: includeOnSend,
+		}},
+	}
+	return s.qb.Upload(ctx, attachable, data)
+}
@@ -0,0 +1,152 @@
+// packingslip/slip.go
+package packingslip
+
+import (
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/shopspring/decimal"
+)
+
+// qbRef is a QuickBooks entity reference
+type qbRef struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+// qbAddress is a QuickBooks physical address
+type qbAddress struct {
+	Line1                  string `json:"Line1"`
+	Line2                  string `json:"Line2"`
+	Line3                  string `json:"Line3"`
+	Line4                  string `json:"Line4"`
+	Line5                  string `json:"Line5"`
+	City                   string `json:"City"`
+	CountrySubDivisionCode string `json:"CountrySubDivisionCode"`
+	PostalCode             string `json:"PostalCode"`
+	Country                string `json:"Country"`
+}
+
+// lines returns the address as printed lines, skipping blank parts
+func (a qbAddress) lines() []string {
+	var lines []string
+	for _, line := range []string{a.Line1, a.Line2, a.Line3, a.Line4, a.Line5} {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	locality := strings.TrimSpace(strings.Join(nonEmpty(a.City, strings.TrimSpace(a.CountrySubDivisionCode+" "+a.PostalCode)), ", "))
+	if locality != "" {
+		lines = append(lines, locality)
+	}
+	if country := strings.TrimSpace(a.Country); country != "" {
+		lines = append(lines, country)
+	}
+	return lines
+}
+
+// nonEmpty returns the values that are not blank
+func nonEmpty(values ...string) []string {
+	var kept []string
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// qbLine is an invoice line; group lines hold the lines of a bundle
+type qbLine struct {
+	Description         string `json:"Description"`
+	DetailType          string `json:"DetailType"`
+	SalesItemLineDetail struct {
+		ItemRef qbRef           `json:"ItemRef"`
+		Qty     decimal.Decimal `json:"Qty"`
+	} `json:"SalesItemLineDetail"`
+	GroupLineDetail struct {
+		Line []qbLine `json:"Line"`
+	} `json:"GroupLineDetail"`
+}
+
+// qbInvoice is the subset of the QuickBooks invoice a slip is built from
+type qbInvoice struct {
+	ID            string    `json:"Id"`
+	DocNumber     string    `json:"DocNumber"`
+	TxnDate       string    `json:"TxnDate"`
+	ShipDate      string    `json:"ShipDate"`
+	ShipMethodRef qbRef     `json:"ShipMethodRef"`
+	TrackingNum   string    `json:"TrackingNum"`
+	CustomerRef   qbRef     `json:"CustomerRef"`
+	BillAddr      qbAddress `json:"BillAddr"`
+	ShipAddr      qbAddress `json:"ShipAddr"`
+	CustomerMemo  struct {
+		Value string `json:"value"`
+	} `json:"CustomerMemo"`
+	Line []qbLine `json:"Line"`
+}
+
+// build assembles a slip from an invoice. Bundles are listed by their
+// components, which are what gets picked; lines without a positive
+// quantity ship nothing and are left out.
+func build(kind string, invoice qbInvoice, company *qbclient.CompanyInfo, brand *branding.Branding) *Slip {
+	slip := &Slip{
+		Kind:        kind,
+		InvoiceID:   invoice.ID,
+		Number:      invoice.DocNumber,
+		Date:        invoice.TxnDate,
+		ShipDate:    invoice.ShipDate,
+		ShipVia:     invoice.ShipMethodRef.Name,
+		TrackingNum: invoice.TrackingNum,
+		Company:     company.CompanyName,
+		Country:     company.Country,
+		Customer:    invoice.CustomerRef.Name,
+		ShipTo:      invoice.ShipAddr.lines(),
+		BillTo:      invoice.BillAddr.lines(),
+		Memo:        invoice.CustomerMemo.Value,
+		Lines:       []Line{},
+	}
+	if slip.Number == "" {
+		slip.Number = invoice.ID
+	}
+	if brand.CompanyName != "" {
+		slip.Company = brand.CompanyName
+	}
+	address := company.CompanyAddr
+	slip.CompanyLines = qbAddress{
+		Line1:                  address.Line1,
+		City:                   address.City,
+		CountrySubDivisionCode: address.CountrySubDivisionCode,
+		PostalCode:             address.PostalCode,
+	}.lines()
+	if len(slip.ShipTo) == 0 {
+		slip.ShipTo = slip.BillTo
+	}
+
+	var add func(lines []qbLine)
+	add = func(lines []qbLine) {
+		for _, line := range lines {
+			switch line.DetailType {
+			case "SalesItemLineDetail":
+				detail := line.SalesItemLineDetail
+				quantity := detail.Qty
+				if detail.ItemRef.Value == "" || !quantity.IsPositive() {
+					continue
+				}
+				slip.Lines = append(slip.Lines, Line{
+					Item:        detail.ItemRef.Name,
+					Description: line.Description,
+					Quantity:    money.NewAmount(quantity),
+					itemID:      detail.ItemRef.Value,
+				})
+				slip.TotalQuantity = money.NewAmount(slip.TotalQuantity.Add(quantity))
+			case "GroupLineDetail":
+				add(line.GroupLineDetail.Line)
+			}
+		}
+	}
+	add(invoice.Line)
+	return slip
+}
@@ -0,0 +1,97 @@
+// payqr/handler.go
+package payqr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/qrcode"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for payment QR codes and their settings
+type Handler struct {
+	service *Service
+	store   *Store
+}
+
+// NewHandler creates a new payment QR code handler
+func NewHandler(service *Service, store *Store) *Handler {
+	return &Handler{
+		service: service,
+		store:   store,
+	}
+}
+
+// status returns the HTTP status for a payment QR code error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrInvoiceNotFound), errors.Is(err, ErrDisabled):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNothingDue):
+		return http.StatusConflict
+	case errors.Is(err, ErrUnsupportedCurrency), errors.Is(err, qrcode.ErrTooLong):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// GetQRCode returns an invoice's payment QR code as PNG; ?scale= sets the
+// pixels per module
+func (h *Handler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	scale := DefaultScale
+	if value := r.URL.Query().Get("scale"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > MaxScale {
+			http.Error(w, "Invalid query: scale must be between 1 and "+strconv.Itoa(MaxScale), http.StatusBadRequest)
+			return
+		}
+		scale = parsed
+	}
+
+	data, err := h.service.InvoiceCode(r.Context(), auth.GetTenantID(r.Context()), mux.Vars(r)["id"], scale)
+	if err != nil {
+		http.Error(w, "Failed to render payment QR code: "+err.Error(), status(err))
+		return
+	}
+
+	// The code follows the invoice's balance
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// GetSettings returns the tenant's payment QR settings
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.store.Get(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to get payment QR settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
+
+// SaveSettings replaces the tenant's payment QR settings
+func (h *Handler) SaveSettings(w http.ResponseWriter, r *http.Request) {
+	var settings Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Save(r.Context(), auth.GetTenantID(r.Context()), &settings); err != nil {
+		http.Error(w, "Failed to save payment QR settings: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
@@ -0,0 +1,104 @@
+// payqr/payload.go
+package payqr
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrDisabled is returned for tenants without payment QR codes
+	ErrDisabled = errors.New("payment QR codes are not enabled")
+	// ErrNothingDue is returned for invoices without an open balance
+	ErrNothingDue = errors.New("invoice has no balance due")
+	// ErrUnsupportedCurrency is returned for bank transfers of invoices in
+	// currencies other than EUR
+	ErrUnsupportedCurrency = errors.New("bank transfer QR codes are only available for EUR invoices")
+)
+
+// Invoice is what payment QR codes encode of an invoice
+type Invoice struct {
+	ID         string
+	DocNumber  string
+	CustomerID string
+	Currency   string
+	Balance    float64
+}
+
+// sample is the invoice templates are validated against
+var sample = Invoice{ID: "1", DocNumber: "1001", CustomerID: "1", Currency: "USD", Balance: 1}
+
+// amount formats the balance as payment systems expect it
+func (i *Invoice) amount() string {
+	return strconv.FormatFloat(i.Balance, 'f', 2, 64)
+}
+
+// expand replaces the placeholders of a template with the invoice's values,
+// escaped with escape
+func expand(template string, escape func(string) string, invoice *Invoice) string {
+	number := invoice.DocNumber
+	if number == "" {
+		number = invoice.ID
+	}
+	return strings.NewReplacer(
+		"{id}", escape(invoice.ID),
+		"{number}", escape(number),
+		"{amount}", escape(invoice.amount()),
+		"{currency}", escape(invoice.Currency),
+		"{customer_id}", escape(invoice.CustomerID),
+	).Replace(template)
+}
+
+// Payload returns the content of an invoice's payment QR code
+func Payload(settings *Settings, invoice *Invoice) (string, error) {
+	if settings.Mode == ModeOff {
+		return "", ErrDisabled
+	}
+	if invoice.Balance < 0.005 {
+		return "", ErrNothingDue
+	}
+
+	switch settings.Mode {
+	case ModeLink:
+		return expand(settings.LinkTemplate, url.QueryEscape, invoice), nil
+	case ModeBank:
+		if invoice.Currency != "EUR" {
+			return "", ErrUnsupportedCurrency
+		}
+		reference := settings.Reference
+		if reference == "" {
+			reference = DefaultReference
+		}
+		reference = truncate(oneLine(expand(reference, oneLine, invoice)), maxReferenceLen)
+		// EPC069-12 version 002: service tag, version, UTF-8, SEPA credit
+		// transfer, BIC, name, IBAN, amount, purpose, structured reference
+		// and unstructured remittance
+		return strings.Join([]string{
+			"BCD", "002", "1", "SCT",
+			settings.BIC,
+			truncate(oneLine(settings.Beneficiary), maxBeneficiaryLen),
+			settings.IBAN,
+			"EUR" + invoice.amount(),
+			"",
+			"",
+			reference,
+		}, "\n"), nil
+	}
+	return "", ErrDisabled
+}
+
+// oneLine replaces line breaks, which separate EPC fields, with spaces
+func oneLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// truncate cuts a value to at most n characters
+func truncate(value string, n int) string {
+	runes := []rune(value)
+	if len(runes) <= n {
+		return value
+	}
+	return string(runes[:n])
+}
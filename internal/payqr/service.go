@@ -0,0 +1,97 @@
+// payqr/service.go
+package payqr
+
+import (
+	"context"
+	"errors"
+
+	"github.com/eGGnogSC/qbserver/internal/qrcode"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Scales of QR code images, in pixels per module
+const (
+	DefaultScale = 8
+	MaxScale     = 20
+)
+
+// ErrInvoiceNotFound is returned when the invoice does not exist
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// Querier queries QuickBooks
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// qbInvoice is the subset of the QuickBooks invoice payment codes encode
+type qbInvoice struct {
+	ID          string `json:"Id"`
+	DocNumber   string `json:"DocNumber"`
+	CustomerRef struct {
+		Value string `json:"value"`
+	} `json:"CustomerRef"`
+	CurrencyRef struct {
+		Value string `json:"value"`
+	} `json:"CurrencyRef"`
+	Balance float64 `json:"Balance"`
+}
+
+// Service renders payment QR codes of invoices
+type Service struct {
+	qb    Querier
+	store *Store
+}
+
+// NewService creates a new payment QR code service
+func NewService(qb Querier, store *Store) *Service {
+	return &Service{
+		qb:    qb,
+		store: store,
+	}
+}
+
+// Code returns the payment QR code of an invoice as a PNG image with the
+// given pixels per module
+func (s *Service) Code(ctx context.Context, tenantID string, invoice *Invoice, scale int) ([]byte, error) {
+	settings, err := s.store.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := Payload(settings, invoice)
+	if err != nil {
+		return nil, err
+	}
+	// EPC QR codes require medium error correction; links use it too
+	code, err := qrcode.Encode([]byte(payload), qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(scale)
+}
+
+// InvoiceCode fetches an invoice and returns its payment QR code
+func (s *Service) InvoiceCode(ctx context.Context, tenantID, invoiceID string, scale int) ([]byte, error) {
+	if !query.IsID(invoiceID) {
+		return nil, ErrInvoiceNotFound
+	}
+	invoices, err := query.List[qbInvoice](ctx, s.qb, query.Select("Invoice").Where("Id", "=", invoiceID))
+	if err != nil {
+		return nil, err
+	}
+	if len(invoices) == 0 {
+		return nil, ErrInvoiceNotFound
+	}
+
+	found := &invoices[0]
+	invoice := &Invoice{
+		ID:         found.ID,
+		DocNumber:  found.DocNumber,
+		CustomerID: found.CustomerRef.Value,
+		Currency:   found.CurrencyRef.Value,
+		Balance:    found.Balance,
+	}
+	if invoice.Currency == "" {
+		invoice.Currency = "USD"
+	}
+	return s.Code(ctx, tenantID, invoice, scale)
+}
@@ -0,0 +1,172 @@
+// payqr/settings.go
+package payqr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Modes of payment QR codes
+const (
+	// ModeOff disables payment QR codes
+	ModeOff = ""
+	// ModeLink encodes a payment link
+	ModeLink = "link"
+	// ModeBank encodes a SEPA credit transfer as an EPC QR code, which
+	// European banking apps prefill transfers from; EUR invoices only
+	ModeBank = "bank"
+)
+
+// Limits of EPC QR codes
+const (
+	maxBeneficiaryLen = 70
+	maxReferenceLen   = 140
+	maxLinkLen        = 2000
+)
+
+// DefaultReference is the remittance text of bank transfers
+const DefaultReference = "Invoice {number}"
+
+var (
+	ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	bicPattern  = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+)
+
+// Settings configure a tenant's payment QR codes. LinkTemplate and
+// Reference may contain the placeholders {id}, {number}, {amount},
+// {currency} and {customer_id}, replaced with the invoice's values.
+type Settings struct {
+	Mode string `json:"mode"`
+	// LinkTemplate is the https URL customers pay at, e.g.
+	// "https://pay.example.com/invoices/{id}?amount={amount}"
+	LinkTemplate string `json:"link_template,omitempty"`
+	// Beneficiary, IBAN and BIC are the account transfers are made to; the
+	// BIC is optional within the EEA
+	Beneficiary string `json:"beneficiary,omitempty"`
+	IBAN        string `json:"iban,omitempty"`
+	BIC         string `json:"bic,omitempty"`
+	// Reference is the remittance text of transfers, DefaultReference if
+	// empty
+	Reference string    `json:"reference,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// normalize uppercases and strips spaces from the account identifiers
+func (s *Settings) normalize() {
+	s.IBAN = strings.ToUpper(strings.ReplaceAll(s.IBAN, " ", ""))
+	s.BIC = strings.ToUpper(strings.ReplaceAll(s.BIC, " ", ""))
+	s.Beneficiary = strings.TrimSpace(s.Beneficiary)
+}
+
+// Validate checks the settings of the selected mode
+func (s *Settings) Validate() error {
+	switch s.Mode {
+	case ModeOff:
+		return nil
+	case ModeLink:
+		if len(s.LinkTemplate) > maxLinkLen {
+			return fmt.Errorf("link_template must be at most %d characters", maxLinkLen)
+		}
+		link, err := url.Parse(expand(s.LinkTemplate, url.QueryEscape, &sample))
+		if err != nil || link.Scheme != "https" || link.Host == "" {
+			return fmt.Errorf("link_template must be an absolute https URL")
+		}
+	case ModeBank:
+		if s.Beneficiary == "" || utf8.RuneCountInString(s.Beneficiary) > maxBeneficiaryLen {
+			return fmt.Errorf("beneficiary is required and must be at most %d characters", maxBeneficiaryLen)
+		}
+		if !validIBAN(s.IBAN) {
+			return fmt.Errorf("iban is not a valid IBAN")
+		}
+		if s.BIC != "" && !bicPattern.MatchString(s.BIC) {
+			return fmt.Errorf("bic is not a valid BIC")
+		}
+		if utf8.RuneCountInString(s.Reference) > maxReferenceLen {
+			return fmt.Errorf("reference must be at most %d characters", maxReferenceLen)
+		}
+	default:
+		return fmt.Errorf("mode must be empty, link or bank")
+	}
+	return nil
+}
+
+// validIBAN checks an IBAN's format and ISO 7064 check digits
+func validIBAN(iban string) bool {
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	value, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(value, big.NewInt(97)).Int64() == 1
+}
+
+// Store persists tenants' payment QR settings
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new payment QR settings store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key returns the key of a tenant's settings
+func (s *Store) key(tenantID string) string {
+	return fmt.Sprintf("%s:payqr:%s", s.prefix, tenantID)
+}
+
+// Get returns a tenant's settings; tenants that have not set any have
+// payment QR codes off
+func (s *Store) Get(ctx context.Context, tenantID string) (*Settings, error) {
+	data, err := s.client.Get(ctx, s.key(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return &Settings{}, nil
+		}
+		return nil, fmt.Errorf("failed to get payment QR settings: %w", err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payment QR settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// Save stores a tenant's settings
+func (s *Store) Save(ctx context.Context, tenantID string, settings *Settings) error {
+	settings.normalize()
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	settings.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment QR settings: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save payment QR settings: %w", err)
+	}
+	return nil
+}
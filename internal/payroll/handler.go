@@ -0,0 +1,182 @@
+// payroll/handler.go
+package payroll
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/gorilla/mux"
+)
+
+// maxPayloadSize limits payroll uploads and webhook bodies to 2 MB
+const maxPayloadSize = 2 << 20
+
+// Handler provides HTTP handlers for payroll imports
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new payroll handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetConfig returns the tenant's payroll configuration without its webhook secret
+func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	config, err := h.service.GetConfig(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get payroll config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	config.WebhookSecret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config)
+}
+
+// SaveConfig sets the tenant's account mapping, binding it to the current
+// company and user. A webhook secret is generated if none is given and is
+// only returned in this response.
+func (h *Handler) SaveConfig(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var config Config
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	config.RealmID = realmID
+	config.UserID = auth.GetUserID(r.Context())
+	if config.WebhookSecret == "" {
+		config.WebhookSecret = jobs.NewID()
+	}
+
+	if err := h.service.SaveConfig(r.Context(), auth.GetTenantID(r.Context()), &config); err != nil {
+		http.Error(w, "Failed to save payroll config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config)
+}
+
+// ImportCSV posts journal entries for a payroll summary CSV; ?dry_run=true
+// previews the entries instead
+func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	config, err := h.service.GetConfig(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to get payroll config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if realmID, err := auth.GetCompanyID(r.Context()); err != nil || realmID != config.RealmID {
+		http.Error(w, "Payroll import is configured for a different QuickBooks company", http.StatusConflict)
+		return
+	}
+
+	summaries, err := ParseCSV(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "Invalid payroll CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := h.service.Import(r.Context(), config, summaries, r.URL.Query().Get("dry_run") == "true")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+	})
+}
+
+// Webhook receives a processed payroll from Gusto or ADP for a tenant. The
+// body must be signed with the tenant's webhook secret (hex HMAC-SHA256 in
+// X-Payroll-Signature, or Gusto's X-Gusto-Signature).
+func (h *Handler) Webhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	provider, tenantID := vars["provider"], vars["tenantID"]
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	config, err := h.service.GetConfig(r.Context(), tenantID)
+	if err != nil {
+		// Unknown tenants are indistinguishable from bad signatures
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	signature := r.Header.Get("X-Payroll-Signature")
+	if signature == "" {
+		signature = r.Header.Get("X-Gusto-Signature")
+	}
+	if !validSignature(config.WebhookSecret, body, signature) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var summary Summary
+	switch provider {
+	case ProviderGusto:
+		summary, err = ParseGusto(body)
+	case ProviderADP:
+		summary, err = ParseADP(body)
+	default:
+		http.Error(w, "Unsupported payroll provider", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := auth.WithIdentity(r.Context(), config.UserID, tenantID, config.RealmID)
+	result := h.service.Import(ctx, config, []Summary{summary}, false)[0]
+	if result.Status == "error" {
+		logging.FromContext(ctx).Warn("failed to import payroll", "provider", provider, "payroll_id", summary.PayrollID, "error", result.Error)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// validSignature checks a hex HMAC-SHA256 of body under secret
+func validSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,249 @@
+// payroll/service.go
+package payroll
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrNotConfigured is returned when a tenant has no payroll account mapping
+var ErrNotConfigured = errors.New("payroll import is not configured")
+
+// AccountMapping maps payroll components to QuickBooks account IDs
+type AccountMapping struct {
+	WagesExpense          string `json:"wages_expense"`
+	EmployerTaxExpense    string `json:"employer_tax_expense"`
+	BenefitsExpense       string `json:"benefits_expense"`
+	TaxLiability          string `json:"tax_liability"`
+	BenefitsLiability     string `json:"benefits_liability"`
+	ReimbursementsExpense string `json:"reimbursements_expense,omitempty"` // defaults to wages expense
+	NetPayClearing        string `json:"net_pay_clearing"`                 // bank or payroll clearing account
+	ClassID               string `json:"class_id,omitempty"`
+}
+
+// Config is a tenant's payroll import configuration
+type Config struct {
+	RealmID       string         `json:"realm_id"`
+	UserID        string         `json:"user_id"`                  // QuickBooks connection used for webhook imports
+	WebhookSecret string         `json:"webhook_secret,omitempty"` // shared secret for signed provider webhooks
+	Accounts      AccountMapping `json:"accounts"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// Validate checks that every required account is mapped
+func (c *Config) Validate() error {
+	a := c.Accounts
+	for name, value := range map[string]string{
+		"wages_expense":        a.WagesExpense,
+		"employer_tax_expense": a.EmployerTaxExpense,
+		"benefits_expense":     a.BenefitsExpense,
+		"tax_liability":        a.TaxLiability,
+		"benefits_liability":   a.BenefitsLiability,
+		"net_pay_clearing":     a.NetPayClearing,
+	} {
+		if value == "" {
+			return fmt.Errorf("accounts.%s is required", name)
+		}
+	}
+	return nil
+}
+
+// Creator creates QuickBooks entities
+type Creator interface {
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+}
+
+// ImportResult reports what happened to one payroll summary
+type ImportResult struct {
+	PayrollID      string                 `json:"payroll_id"`
+	Status         string                 `json:"status"` // posted, duplicate, preview or error
+	JournalEntryID string                 `json:"journal_entry_id,omitempty"`
+	Entry          map[string]interface{} `json:"entry,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+}
+
+// Service posts payroll summaries to QuickBooks as journal entries
+type Service struct {
+	client  redis.UniversalClient
+	prefix  string
+	creator Creator
+}
+
+// NewService creates a new payroll import service
+func NewService(client redis.UniversalClient, prefix string, creator Creator) *Service {
+	return &Service{
+		client:  client,
+		prefix:  prefix,
+		creator: creator,
+	}
+}
+
+// configKey holds a tenant's payroll configuration
+func (s *Service) configKey(tenantID string) string {
+	return fmt.Sprintf("%s:payroll:config:%s", s.prefix, tenantID)
+}
+
+// postedKey records the journal entry posted for a payroll run
+func (s *Service) postedKey(realmID, provider, payrollID string) string {
+	return fmt.Sprintf("%s:payroll:posted:%s:%s:%s", s.prefix, realmID, provider, payrollID)
+}
+
+// GetConfig returns a tenant's payroll configuration
+func (s *Service) GetConfig(ctx context.Context, tenantID string) (*Config, error) {
+	data, err := s.client.Get(ctx, s.configKey(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrNotConfigured
+		}
+		return nil, fmt.Errorf("failed to get payroll config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payroll config: %w", err)
+	}
+	return &config, nil
+}
+
+// SaveConfig validates and stores a tenant's payroll configuration
+func (s *Service) SaveConfig(ctx context.Context, tenantID string, config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	config.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payroll config: %w", err)
+	}
+	if err := s.client.Set(ctx, s.configKey(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save payroll config: %w", err)
+	}
+	return nil
+}
+
+// JournalEntry builds the QuickBooks journal entry for a payroll summary
+func JournalEntry(summary Summary, accounts AccountMapping) map[string]interface{} {
+	var lines []map[string]interface{}
+	add := func(posting, account string, amount float64, description string) {
+		amount = math.Round(amount*100) / 100
+		if amount == 0 {
+			return
+		}
+		detail := map[string]interface{}{
+			"PostingType": posting,
+			"AccountRef":  map[string]string{"value": account},
+		}
+		if accounts.ClassID != "" {
+			detail["ClassRef"] = map[string]string{"value": accounts.ClassID}
+		}
+		lines = append(lines, map[string]interface{}{
+			"Amount":                 amount,
+			"Description":            description,
+			"DetailType":             "JournalEntryLineDetail",
+			"JournalEntryLineDetail": detail,
+		})
+	}
+
+	reimbursements := accounts.ReimbursementsExpense
+	if reimbursements == "" {
+		reimbursements = accounts.WagesExpense
+	}
+
+	add("Debit", accounts.WagesExpense, summary.GrossWages, "Gross wages")
+	add("Debit", accounts.EmployerTaxExpense, summary.EmployerTaxes, "Employer payroll taxes")
+	add("Debit", accounts.BenefitsExpense, summary.EmployerBenefits, "Employer benefit contributions")
+	add("Debit", reimbursements, summary.Reimbursements, "Employee reimbursements")
+	add("Credit", accounts.TaxLiability, summary.EmployeeTaxes+summary.EmployerTaxes, "Payroll taxes payable")
+	add("Credit", accounts.BenefitsLiability, summary.EmployeeDeductions+summary.EmployerBenefits, "Benefits and deductions payable")
+	add("Credit", accounts.NetPayClearing, summary.NetPay, "Net pay")
+
+	note := fmt.Sprintf("%s payroll %s", summary.Provider, summary.PayrollID)
+	if summary.PeriodStart != "" && summary.PeriodEnd != "" {
+		note += fmt.Sprintf(" for %s to %s", summary.PeriodStart, summary.PeriodEnd)
+	}
+
+	// DocNumber is limited to 21 characters
+	docNumber := "PR-" + summary.PayrollID
+	if len(docNumber) > 21 {
+		docNumber = docNumber[:21]
+	}
+
+	return map[string]interface{}{
+		"TxnDate":     summary.CheckDate,
+		"DocNumber":   docNumber,
+		"PrivateNote": note,
+		"Line":        lines,
+	}
+}
+
+// Import posts each summary once per realm; previously posted runs are
+// reported as duplicates. With dryRun the entries are built but not posted.
+func (s *Service) Import(ctx context.Context, config *Config, summaries []Summary, dryRun bool) []ImportResult {
+	results := make([]ImportResult, 0, len(summaries))
+	for _, summary := range summaries {
+		results = append(results, s.importOne(ctx, config, summary, dryRun))
+	}
+	return results
+}
+
+// importOne validates and posts a single payroll summary
+func (s *Service) importOne(ctx context.Context, config *Config, summary Summary, dryRun bool) ImportResult {
+	result := ImportResult{PayrollID: summary.PayrollID}
+	if err := summary.Validate(); err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	entry := JournalEntry(summary, config.Accounts)
+	key := s.postedKey(config.RealmID, summary.Provider, summary.PayrollID)
+
+	if existing, err := s.client.Get(ctx, key).Result(); err == nil && existing != "" {
+		result.Status = "duplicate"
+		result.JournalEntryID = existing
+		return result
+	}
+	if dryRun {
+		result.Status = "preview"
+		result.Entry = entry
+		return result
+	}
+
+	// Claim the run before posting so concurrent deliveries cannot double-post
+	claimed, err := s.client.SetNX(ctx, key, "", 10*time.Minute).Result()
+	if err != nil {
+		result.Status = "error"
+		result.Error = "failed to record payroll run: " + err.Error()
+		return result
+	}
+	if !claimed {
+		result.Status = "duplicate"
+		return result
+	}
+
+	var created struct {
+		JournalEntry struct {
+			ID string `json:"Id"`
+		} `json:"JournalEntry"`
+	}
+	if err := s.creator.Create(ctx, "JournalEntry", entry, &created); err != nil {
+		s.client.Del(ctx, key)
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	if err := s.client.Set(ctx, key, created.JournalEntry.ID, 0).Err(); err != nil {
+		result.Error = "posted but failed to record payroll run: " + err.Error()
+	}
+	result.Status = "posted"
+	result.JournalEntryID = created.JournalEntry.ID
+	return result
+}
@@ -0,0 +1,251 @@
+// payroll/summary.go
+package payroll
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported payroll providers
+const (
+	ProviderCSV   = "csv"
+	ProviderGusto = "gusto"
+	ProviderADP   = "adp"
+)
+
+// Summary is a provider-neutral payroll run total
+type Summary struct {
+	Provider           string  `json:"provider"`
+	PayrollID          string  `json:"payroll_id"`
+	CheckDate          string  `json:"check_date"`
+	PeriodStart        string  `json:"period_start,omitempty"`
+	PeriodEnd          string  `json:"period_end,omitempty"`
+	GrossWages         float64 `json:"gross_wages"`
+	EmployeeTaxes      float64 `json:"employee_taxes"`
+	EmployerTaxes      float64 `json:"employer_taxes"`
+	EmployeeDeductions float64 `json:"employee_deductions"`
+	EmployerBenefits   float64 `json:"employer_benefits"`
+	Reimbursements     float64 `json:"reimbursements"`
+	NetPay             float64 `json:"net_pay"`
+}
+
+// Validate checks that the summary identifies a run and balances: gross wages
+// and reimbursements must equal withholdings, deductions and net pay
+func (s *Summary) Validate() error {
+	if s.PayrollID == "" {
+		return fmt.Errorf("payroll_id is required")
+	}
+	if _, err := time.Parse("2006-01-02", s.CheckDate); err != nil {
+		return fmt.Errorf("payroll %s: invalid check_date, expected YYYY-MM-DD", s.PayrollID)
+	}
+
+	paidOut := s.EmployeeTaxes + s.EmployeeDeductions + s.NetPay
+	if math.Abs(s.GrossWages+s.Reimbursements-paidOut) > 0.005 {
+		return fmt.Errorf("payroll %s does not balance: gross %.2f + reimbursements %.2f != taxes %.2f + deductions %.2f + net %.2f",
+			s.PayrollID, s.GrossWages, s.Reimbursements, s.EmployeeTaxes, s.EmployeeDeductions, s.NetPay)
+	}
+
+	return nil
+}
+
+// csvColumns are the accepted payroll CSV columns
+var csvColumns = []string{
+	"payroll_id", "check_date", "period_start", "period_end", "gross_wages", "employee_taxes",
+	"employer_taxes", "employee_deductions", "employer_benefits", "reimbursements", "net_pay",
+}
+
+// ParseCSV reads one payroll summary per row. The payroll_id, check_date,
+// gross_wages and net_pay columns are required.
+func ParseCSV(r io.Reader) ([]Summary, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"payroll_id", "check_date", "gross_wages", "net_pay"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %s column", required)
+		}
+	}
+
+	var summaries []Summary
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row %d: %w", row, err)
+		}
+
+		values := map[string]string{}
+		for _, name := range csvColumns {
+			if i, ok := columns[name]; ok && i < len(record) {
+				values[name] = strings.TrimSpace(record[i])
+			}
+		}
+
+		amount := func(name string) (float64, error) {
+			v := strings.ReplaceAll(values[name], ",", "")
+			if v == "" {
+				return 0, nil
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return 0, fmt.Errorf("row %d: invalid %s", row, name)
+			}
+			return f, nil
+		}
+
+		s := Summary{
+			Provider:    ProviderCSV,
+			PayrollID:   values["payroll_id"],
+			CheckDate:   values["check_date"],
+			PeriodStart: values["period_start"],
+			PeriodEnd:   values["period_end"],
+		}
+		for name, field := range map[string]*float64{
+			"gross_wages":         &s.GrossWages,
+			"employee_taxes":      &s.EmployeeTaxes,
+			"employer_taxes":      &s.EmployerTaxes,
+			"employee_deductions": &s.EmployeeDeductions,
+			"employer_benefits":   &s.EmployerBenefits,
+			"reimbursements":      &s.Reimbursements,
+			"net_pay":             &s.NetPay,
+		} {
+			if *field, err = amount(name); err != nil {
+				return nil, err
+			}
+		}
+
+		summaries = append(summaries, s)
+	}
+
+	return summaries, nil
+}
+
+// money decodes provider amounts that may be JSON strings or numbers
+type money float64
+
+// UnmarshalJSON accepts "123.45" as well as 123.45
+func (m *money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*m = 0
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	*m = money(f)
+	return nil
+}
+
+// gustoPayroll is the subset of a Gusto payroll object used for journal entries
+type gustoPayroll struct {
+	PayrollUUID string `json:"payroll_uuid"`
+	UUID        string `json:"uuid"`
+	CheckDate   string `json:"check_date"`
+	PayPeriod   struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+	} `json:"pay_period"`
+	Totals struct {
+		GrossPay                   money `json:"gross_pay"`
+		NetPay                     money `json:"net_pay"`
+		EmployerTaxes              money `json:"employer_taxes"`
+		EmployeeTaxes              money `json:"employee_taxes"`
+		Benefits                   money `json:"benefits"`
+		EmployeeBenefitsDeductions money `json:"employee_benefits_deductions"`
+		Reimbursements             money `json:"reimbursements"`
+		OtherDeductions            money `json:"other_deductions"`
+	} `json:"totals"`
+}
+
+// ParseGusto reads a processed Gusto payroll, either bare or wrapped in a
+// webhook body under "payroll"
+func ParseGusto(body []byte) (Summary, error) {
+	var envelope struct {
+		Payroll *gustoPayroll `json:"payroll"`
+	}
+	var p gustoPayroll
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Payroll != nil {
+		p = *envelope.Payroll
+	} else if err := json.Unmarshal(body, &p); err != nil {
+		return Summary{}, fmt.Errorf("invalid Gusto payroll: %w", err)
+	}
+
+	id := p.PayrollUUID
+	if id == "" {
+		id = p.UUID
+	}
+
+	return Summary{
+		Provider:           ProviderGusto,
+		PayrollID:          id,
+		CheckDate:          p.CheckDate,
+		PeriodStart:        p.PayPeriod.StartDate,
+		PeriodEnd:          p.PayPeriod.EndDate,
+		GrossWages:         float64(p.Totals.GrossPay),
+		EmployeeTaxes:      float64(p.Totals.EmployeeTaxes),
+		EmployerTaxes:      float64(p.Totals.EmployerTaxes),
+		EmployeeDeductions: float64(p.Totals.EmployeeBenefitsDeductions + p.Totals.OtherDeductions),
+		EmployerBenefits:   float64(p.Totals.Benefits),
+		Reimbursements:     float64(p.Totals.Reimbursements),
+		NetPay:             float64(p.Totals.NetPay),
+	}, nil
+}
+
+// adpPayroll is an ADP payroll summary as relayed by the tenant's ADP integration
+type adpPayroll struct {
+	PayrollSummary struct {
+		PayrollID             string `json:"payrollID"`
+		PayDate               string `json:"payDate"`
+		PeriodStartDate       string `json:"periodStartDate"`
+		PeriodEndDate         string `json:"periodEndDate"`
+		GrossPay              money  `json:"grossPay"`
+		NetPay                money  `json:"netPay"`
+		EmployeeTaxes         money  `json:"employeeTaxes"`
+		EmployerTaxes         money  `json:"employerTaxes"`
+		Deductions            money  `json:"deductions"`
+		EmployerContributions money  `json:"employerContributions"`
+		Reimbursements        money  `json:"reimbursements"`
+	} `json:"payrollSummary"`
+}
+
+// ParseADP reads an ADP payroll summary payload
+func ParseADP(body []byte) (Summary, error) {
+	var p adpPayroll
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Summary{}, fmt.Errorf("invalid ADP payroll: %w", err)
+	}
+	ps := p.PayrollSummary
+
+	return Summary{
+		Provider:           ProviderADP,
+		PayrollID:          ps.PayrollID,
+		CheckDate:          ps.PayDate,
+		PeriodStart:        ps.PeriodStartDate,
+		PeriodEnd:          ps.PeriodEndDate,
+		GrossWages:         float64(ps.GrossPay),
+		EmployeeTaxes:      float64(ps.EmployeeTaxes),
+		EmployerTaxes:      float64(ps.EmployerTaxes),
+		EmployeeDeductions: float64(ps.Deductions),
+		EmployerBenefits:   float64(ps.EmployerContributions),
+		Reimbursements:     float64(ps.Reimbursements),
+		NetPay:             float64(ps.NetPay),
+	}, nil
+}
@@ -0,0 +1,255 @@
+// pdf/document.go
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Page sizes in points
+var (
+	A4     = Size{Width: 595.28, Height: 841.89}
+	Letter = Size{Width: 612, Height: 792}
+)
+
+// Size is a page size in points
+type Size struct {
+	Width  float64
+	Height float64
+}
+
+// SizeFor returns the customary paper size of a company's country: US
+// Letter in North America and A4 elsewhere
+func SizeFor(country string) Size {
+	switch strings.ToUpper(strings.TrimSpace(country)) {
+	case "US", "CA", "USA", "CANADA", "UNITED STATES":
+		return Letter
+	}
+	return A4
+}
+
+// Color is an RGB color
+type Color struct {
+	R, G, B uint8
+}
+
+// Common colors
+var (
+	Black = Color{}
+	White = Color{R: 255, G: 255, B: 255}
+	Gray  = Color{R: 110, G: 110, B: 110}
+)
+
+// hexColor matches "#rrggbb" colors
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ParseColor parses a "#rrggbb" color
+func ParseColor(value string) (Color, error) {
+	if !hexColor.MatchString(value) {
+		return Color{}, fmt.Errorf("invalid color %q: must be #rrggbb", value)
+	}
+	n, _ := strconv.ParseUint(value[1:], 16, 32)
+	return Color{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n)}, nil
+}
+
+// Hex returns the color as "#rrggbb"
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// operands returns the color as PDF color operands
+func (c Color) operands() string {
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+}
+
+// Document is a PDF under construction. Pages are drawn with the standard
+// Helvetica fonts and images, in points from the top-left corner.
+type Document struct {
+	title  string
+	pages  []*Page
+	images []*Image
+}
+
+// New creates an empty document with the given title
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// Image is an image added to a document, drawable on any of its pages
+type Image struct {
+	Width  int
+	Height int
+
+	index  int
+	pixels []byte // compressed RGB samples
+}
+
+// AddImage adds an image to the document. Transparent pixels are blended
+// onto white.
+func (d *Document) AddImage(img image.Image) (*Image, error) {
+	bounds := img.Bounds()
+	var raw bytes.Buffer
+	compressor := zlib.NewWriter(&raw)
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			blend := func(v uint8) byte {
+				return byte((int(v)*int(c.A) + 255*(255-int(c.A))) / 255)
+			}
+			row = append(row, blend(c.R), blend(c.G), blend(c.B))
+		}
+		if _, err := compressor.Write(row); err != nil {
+			return nil, fmt.Errorf("failed to compress image: %w", err)
+		}
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress image: %w", err)
+	}
+
+	added := &Image{Width: bounds.Dx(), Height: bounds.Dy(), index: len(d.images), pixels: raw.Bytes()}
+	d.images = append(d.images, added)
+	return added, nil
+}
+
+// Page is one page of a document
+type Page struct {
+	size    Size
+	content bytes.Buffer
+}
+
+// AddPage appends a blank page of the given size
+func (d *Document) AddPage(size Size) *Page {
+	page := &Page{size: size}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Size returns the page's size
+func (p *Page) Size() Size {
+	return p.size
+}
+
+// Text draws text with its baseline at y
+func (p *Page) Text(x, y float64, font Font, size float64, c Color, text string) {
+	fmt.Fprintf(&p.content, "BT /F%d %.2f Tf %s rg %.2f %.2f Td %s Tj ET\n", font+1, size, c.operands(), x, p.size.Height-y, literal(text))
+}
+
+// TextRight draws text ending at x, e.g. for amounts in a column
+func (p *Page) TextRight(x, y float64, font Font, size float64, c Color, text string) {
+	p.Text(x-TextWidth(font, size, text), y, font, size, c, text)
+}
+
+// Line draws a straight line
+func (p *Page) Line(x1, y1, x2, y2, width float64, c Color) {
+	fmt.Fprintf(&p.content, "%s RG %.2f w %.2f %.2f m %.2f %.2f l S\n", c.operands(), width, x1, p.size.Height-y1, x2, p.size.Height-y2)
+}
+
+// Rect fills a rectangle whose top-left corner is at x, y
+func (p *Page) Rect(x, y, width, height float64, c Color) {
+	fmt.Fprintf(&p.content, "%s rg %.2f %.2f %.2f %.2f re f\n", c.operands(), x, p.size.Height-y-height, width, height)
+}
+
+// Image draws an image scaled to width and height with its top-left corner
+// at x, y
+func (p *Page) Image(img *Image, x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, x, p.size.Height-y-height, img.index+1)
+}
+
+// Bytes serializes the document
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the document as PDF 1.4. Objects are numbered: catalog,
+// page tree, info, fonts, images, then each page followed by its content.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< %s /Length %d >>\nstream\n", len(offsets), dict, len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	fontBase := 4
+	imageBase := fontBase + len(baseFonts)
+	pageBase := imageBase + len(d.images)
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	var kids bytes.Buffer
+	for i := range d.pages {
+		fmt.Fprintf(&kids, "%d 0 R ", pageBase+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(d.pages)))
+	object(fmt.Sprintf("<< /Title %s /Producer (qbserver) >>", literal(d.title)))
+
+	var resources bytes.Buffer
+	resources.WriteString("<< /Font <<")
+	for i, name := range baseFonts {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+		fmt.Fprintf(&resources, " /F%d %d 0 R", i+1, fontBase+i)
+	}
+	resources.WriteString(" >>")
+	if len(d.images) > 0 {
+		resources.WriteString(" /XObject <<")
+		for i, img := range d.images {
+			stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", img.Width, img.Height), img.pixels)
+			fmt.Fprintf(&resources, " /Im%d %d 0 R", i+1, imageBase+i)
+		}
+		resources.WriteString(" >>")
+	}
+	resources.WriteString(" >>")
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			page.size.Width, page.size.Height, resources.String(), pageBase+2*i+1))
+		var content bytes.Buffer
+		compressor := zlib.NewWriter(&content)
+		compressor.Write(page.content.Bytes())
+		if err := compressor.Close(); err != nil {
+			return 0, fmt.Errorf("failed to compress page: %w", err)
+		}
+		stream("/Filter /FlateDecode", content.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.WriteTo(w)
+}
+
+// literal returns text as a PDF string literal
+func literal(text string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('(')
+	for _, b := range encode(text) {
+		if b == '(' || b == ')' || b == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(b)
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
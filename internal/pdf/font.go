@@ -0,0 +1,130 @@
+// pdf/font.go
+package pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Font is one of the standard PDF fonts every reader provides, so documents
+// need not embed font files
+type Font int
+
+// Standard fonts
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+// baseFonts are the PostScript names of the fonts
+var baseFonts = [...]string{
+	Helvetica:     "Helvetica",
+	HelveticaBold: "Helvetica-Bold",
+}
+
+// widths are the Adobe metrics of printable ASCII (32 to 126) in 1/1000 em
+var widths = [...][95]int{
+	Helvetica: {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	HelveticaBold: {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+}
+
+// defaultWidth is used for characters outside printable ASCII
+const defaultWidth = 556
+
+// winAnsi maps the characters WinAnsiEncoding places outside Latin-1
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encode converts text to WinAnsiEncoding, replacing characters it cannot
+// represent with '?'
+func encode(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r >= 32 && r < 127, r >= 0xA0 && r <= 0xFF:
+			encoded = append(encoded, byte(r))
+		case winAnsi[r] != 0:
+			encoded = append(encoded, winAnsi[r])
+		case r == '\t':
+			encoded = append(encoded, ' ')
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+// TextWidth returns the width of text set in font at size points
+func TextWidth(font Font, size float64, text string) float64 {
+	total := 0
+	for _, r := range text {
+		if r >= 32 && r < 127 {
+			total += widths[font][r-32]
+		} else {
+			total += defaultWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap breaks text into lines no wider than width, at spaces where possible
+// and within words that are wider than a line. Newlines in text are kept.
+func Wrap(font Font, size, width float64, text string) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if TextWidth(font, size, candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			for TextWidth(font, size, word) > width && utf8.RuneCountInString(word) > 1 {
+				cut := fit(font, size, width, word)
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fit returns the byte length of the longest prefix of word, of at least one
+// character, that fits in width
+func fit(font Font, size, width float64, word string) int {
+	_, first := utf8.DecodeRuneInString(word)
+	cut := first
+	for i := range word {
+		if i > 0 && TextWidth(font, size, word[:i]) > width {
+			break
+		}
+		if i > 0 {
+			cut = i
+		}
+	}
+	return cut
+}
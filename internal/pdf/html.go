@@ -0,0 +1,86 @@
+// pdf/html.go
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxConvertedSize bounds the PDFs read back from the converter
+const maxConvertedSize = 20 << 20
+
+// HTMLConverter renders HTML documents as PDF with a headless Chromium
+// behind a Gotenberg-compatible API. Documents must be self-contained:
+// images are inlined as data URIs.
+type HTMLConverter struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTMLConverter creates a converter for the API at baseURL
+func NewHTMLConverter(baseURL string) *HTMLConverter {
+	return &HTMLConverter{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Convert renders an HTML document on pages of the given size. Margins are
+// left to the document's CSS and backgrounds are printed, so colored
+// headings and bands come out as designed.
+func (c *HTMLConverter) Convert(ctx context.Context, html []byte, size Size) ([]byte, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("files", "index.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build conversion request: %w", err)
+	}
+	file.Write(html)
+	fields := map[string]string{
+		"paperWidth":      strconv.FormatFloat(size.Width/72, 'f', 2, 64),
+		"paperHeight":     strconv.FormatFloat(size.Height/72, 'f', 2, 64),
+		"marginTop":       "0",
+		"marginBottom":    "0",
+		"marginLeft":      "0",
+		"marginRight":     "0",
+		"printBackground": "true",
+	}
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build conversion request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/forms/chromium/convert/html", &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversion request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("conversion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("conversion request failed with status %d: %s", resp.StatusCode, body)
+	}
+	data, err := io.ReadAll(&io.LimitedReader{R: resp.Body, N: maxConvertedSize + 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read converted PDF: %w", err)
+	}
+	if len(data) > maxConvertedSize {
+		return nil, fmt.Errorf("converted PDF exceeds %d MB", maxConvertedSize>>20)
+	}
+	return data, nil
+}
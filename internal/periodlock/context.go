@@ -0,0 +1,77 @@
+// periodlock/context.go
+package periodlock
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// OverrideHeader asks to save a mutation dated in a closed period
+const OverrideHeader = "X-Override-Period-Lock"
+
+// WarningHeader carries period lock warnings back to the client
+const WarningHeader = "X-Period-Lock-Warning"
+
+// overrideRoles may save mutations dated in a closed period
+var overrideRoles = map[string]bool{
+	"admin": true,
+}
+
+// CanOverride reports whether a role may override the closing date
+func CanOverride(role string) bool {
+	return overrideRoles[role]
+}
+
+// contextKey is the type for period lock context keys
+type contextKey string
+
+const (
+	overrideKey contextKey = "periodlock_override"
+	headerKey   contextKey = "periodlock_header"
+)
+
+// WithOverride returns a context whose mutations may be dated in a closed period
+func WithOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, overrideKey, true)
+}
+
+// overridden reports whether ctx carries an override
+func overridden(ctx context.Context) bool {
+	override, _ := ctx.Value(overrideKey).(bool)
+	return override
+}
+
+// warn adds a warning to the response of the request in ctx, if any
+func warn(ctx context.Context, message string) {
+	if header, ok := ctx.Value(headerKey).(http.Header); ok {
+		header.Add(WarningHeader, message)
+	}
+}
+
+// Middleware applies the override header for permitted roles and lets
+// warnings from QuickBooks writes reach the response headers
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), headerKey, w.Header())
+
+		if value := r.Header.Get(OverrideHeader); value != "" {
+			override, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "Invalid "+OverrideHeader+" header", http.StatusBadRequest)
+				return
+			}
+			if override {
+				if !CanOverride(auth.GetRole(ctx)) {
+					http.Error(w, "Not allowed to override the closing date", http.StatusForbidden)
+					return
+				}
+				ctx = WithOverride(ctx)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
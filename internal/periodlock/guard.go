@@ -0,0 +1,195 @@
+// periodlock/guard.go
+package periodlock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrPeriodLocked is returned for mutations dated on or before the closing date
+var ErrPeriodLocked = errors.New("accounting period is closed")
+
+// closeDateTTL is how long a company's closing date is cached
+const closeDateTTL = 5 * time.Minute
+
+// postingEntities are the transactions QuickBooks' closing date applies to
+var postingEntities = map[string]bool{
+	"Invoice":       true,
+	"SalesReceipt":  true,
+	"Payment":       true,
+	"CreditMemo":    true,
+	"RefundReceipt": true,
+	"Deposit":       true,
+	"Transfer":      true,
+	"JournalEntry":  true,
+	"Bill":          true,
+	"BillPayment":   true,
+	"VendorCredit":  true,
+	"Purchase":      true,
+}
+
+// QuickBooks is the subset of the QuickBooks client used by the guard
+type QuickBooks interface {
+	GetPreferences(ctx context.Context) (*qbclient.Preferences, error)
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// cachedCloseDate is a company's closing date and when it was read
+type cachedCloseDate struct {
+	date    time.Time // zero when the books are not closed
+	fetched time.Time
+}
+
+// Guard is a qbclient.Transformer that keeps writes out of closed
+// accounting periods. Creates and updates of posting transactions dated on
+// or before the company's closing date are blocked, or only warned about
+// when the tenant chooses. Updates are checked against the stored date as
+// well, so moving a transaction out of a closed period is caught too.
+// Permitted users may override the lock per request.
+type Guard struct {
+	client redis.UniversalClient
+	prefix string
+	qb     QuickBooks
+
+	closeDates *lru.Cache[string, cachedCloseDate] // by realm
+}
+
+// NewGuard creates a new period lock guard
+func NewGuard(client redis.UniversalClient, prefix string, qb QuickBooks) *Guard {
+	return &Guard{
+		client:     client,
+		prefix:     prefix,
+		qb:         qb,
+		closeDates: lru.New[string, cachedCloseDate]("periodlock.close_dates", 10000),
+	}
+}
+
+// CloseDate returns the current company's closing date, zero when the
+// books are not closed
+func (g *Guard) CloseDate(ctx context.Context, realmID string) (time.Time, error) {
+	cached, ok := g.closeDates.Get(realmID)
+	if ok && time.Since(cached.fetched) < closeDateTTL {
+		return cached.date, nil
+	}
+
+	prefs, err := g.qb.GetPreferences(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get closing date: %w", err)
+	}
+	var date time.Time
+	if value := prefs.AccountingInfoPrefs.BookCloseDate; value != "" {
+		if date, err = time.Parse("2006-01-02", value); err != nil {
+			return time.Time{}, fmt.Errorf("invalid closing date %q: %w", value, err)
+		}
+	}
+
+	g.closeDates.Add(realmID, cachedCloseDate{date: date, fetched: time.Now()})
+	return date, nil
+}
+
+// Forget drops a realm's cached closing date, e.g. after it was changed
+func (g *Guard) Forget(realmID string) {
+	g.closeDates.Remove(realmID)
+}
+
+// Transform checks creates, updates, deletes and voids of posting
+// transactions against the closing date. Lookup failures block the write
+// rather than risk silently re-opening a period.
+func (g *Guard) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	switch stage {
+	case qbclient.StageBeforeCreate, qbclient.StageBeforeUpdate, qbclient.StageBeforeDelete, qbclient.StageBeforeVoid:
+	default:
+		return data, nil
+	}
+	if !postingEntities[entity] {
+		return data, nil
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return data, nil
+	}
+
+	settings, err := g.GetSettings(ctx, auth.GetTenantID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if settings.Mode == ModeOff {
+		return data, nil
+	}
+
+	closeDate, err := g.CloseDate(ctx, realmID)
+	if err != nil {
+		return nil, err
+	}
+	if closeDate.IsZero() {
+		return data, nil
+	}
+
+	dates, err := g.dates(ctx, stage, entity, data)
+	if err != nil {
+		return nil, err
+	}
+	for _, date := range dates {
+		if date.After(closeDate) {
+			continue
+		}
+
+		message := fmt.Sprintf("%s dated %s is in a closed period (books closed through %s)",
+			entity, date.Format("2006-01-02"), closeDate.Format("2006-01-02"))
+		switch {
+		case overridden(ctx):
+			logging.FromContext(ctx).Warn("period lock overridden", "user_id", auth.GetUserID(ctx), "realm_id", realmID, "detail", message)
+			warn(ctx, message+"; saved with override")
+		case settings.Mode == ModeWarn:
+			warn(ctx, message)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrPeriodLocked, message)
+		}
+		return data, nil
+	}
+
+	return data, nil
+}
+
+// dates returns the transaction dates a write touches: the new date, which
+// QuickBooks sets to today when a create omits it, and for updates the
+// stored date
+func (g *Guard) dates(ctx context.Context, stage, entity string, data map[string]interface{}) ([]time.Time, error) {
+	var dates []time.Time
+	if value, ok := data["TxnDate"].(string); ok && value != "" {
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TxnDate %q", value)
+		}
+		dates = append(dates, date)
+	} else if stage == qbclient.StageBeforeCreate {
+		dates = append(dates, timezone.Today(ctx))
+	}
+
+	id, _ := data["Id"].(string)
+	if stage != qbclient.StageBeforeUpdate || id == "" {
+		return dates, nil
+	}
+
+	current, err := g.qb.Read(ctx, entity, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check stored date: %w", err)
+	}
+	var stored string
+	if raw, ok := current["TxnDate"]; ok && json.Unmarshal(raw, &stored) == nil && stored != "" {
+		if date, err := time.Parse("2006-01-02", stored); err == nil {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
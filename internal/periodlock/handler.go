@@ -0,0 +1,84 @@
+// periodlock/handler.go
+package periodlock
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// Handler provides HTTP handlers for period lock settings
+type Handler struct {
+	guard *Guard
+}
+
+// NewHandler creates a new period lock handler
+func NewHandler(guard *Guard) *Handler {
+	return &Handler{
+		guard: guard,
+	}
+}
+
+// Status is the current company's closing date and the tenant's lock mode
+type Status struct {
+	BookCloseDate string `json:"book_close_date,omitempty"`
+	Mode          string `json:"mode"`
+	CanOverride   bool   `json:"can_override"`
+}
+
+// GetStatus returns the closing date detected from the company's
+// preferences and how writes before it are treated
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get company ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Always read the current closing date rather than a cached one
+	h.guard.Forget(realmID)
+	closeDate, err := h.guard.CloseDate(ctx, realmID)
+	if err != nil {
+		http.Error(w, "Failed to get closing date: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	settings, err := h.guard.GetSettings(ctx, auth.GetTenantID(ctx))
+	if err != nil {
+		http.Error(w, "Failed to get period lock settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := Status{Mode: settings.Mode, CanOverride: CanOverride(auth.GetRole(ctx))}
+	if !closeDate.IsZero() {
+		status.BookCloseDate = closeDate.Format("2006-01-02")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(status)
+}
+
+// SaveSettings changes how the tenant's writes in closed periods are treated
+func (h *Handler) SaveSettings(w http.ResponseWriter, r *http.Request) {
+	if !CanOverride(auth.GetRole(r.Context())) {
+		http.Error(w, "Not allowed to change period lock settings", http.StatusForbidden)
+		return
+	}
+
+	var settings Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.guard.SaveSettings(r.Context(), auth.GetTenantID(r.Context()), &settings); err != nil {
+		http.Error(w, "Failed to save period lock settings: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
@@ -0,0 +1,69 @@
+// periodlock/settings.go
+package periodlock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Lock modes
+const (
+	ModeBlock = "block" // reject mutations in closed periods (default)
+	ModeWarn  = "warn"  // allow them with a warning header
+	ModeOff   = "off"   // do not check the closing date
+)
+
+// Settings is a tenant's period lock configuration
+type Settings struct {
+	Mode string `json:"mode"`
+}
+
+// Validate checks the settings are well formed
+func (s *Settings) Validate() error {
+	switch s.Mode {
+	case ModeBlock, ModeWarn, ModeOff:
+		return nil
+	}
+	return fmt.Errorf("unsupported mode: %q", s.Mode)
+}
+
+// settingsKey holds a tenant's settings
+func (g *Guard) settingsKey(tenantID string) string {
+	return fmt.Sprintf("%s:periodlock:%s", g.prefix, tenantID)
+}
+
+// GetSettings returns a tenant's settings; tenants without settings block
+func (g *Guard) GetSettings(ctx context.Context, tenantID string) (*Settings, error) {
+	data, err := g.client.Get(ctx, g.settingsKey(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return &Settings{Mode: ModeBlock}, nil
+		}
+		return nil, fmt.Errorf("failed to get period lock settings: %w", err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal period lock settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SaveSettings validates and replaces a tenant's settings
+func (g *Guard) SaveSettings(ctx context.Context, tenantID string, settings *Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal period lock settings: %w", err)
+	}
+	if err := g.client.Set(ctx, g.settingsKey(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save period lock settings: %w", err)
+	}
+	return nil
+}
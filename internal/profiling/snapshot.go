@@ -0,0 +1,111 @@
+// profiling/snapshot.go
+package profiling
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	httppprof "net/http/pprof"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/gorilla/mux"
+)
+
+// started is when the process started, for uptime
+var started = time.Now()
+
+func init() {
+	// Cache sizes, hits and evictions alongside the runtime's own variables
+	expvar.Publish("caches", expvar.Func(func() interface{} {
+		return lru.All()
+	}))
+}
+
+// Heap summarizes the heap
+type Heap struct {
+	AllocBytes   uint64 `json:"alloc_bytes"`
+	InuseBytes   uint64 `json:"inuse_bytes"`
+	SysBytes     uint64 `json:"sys_bytes"`
+	Objects      uint64 `json:"objects"`
+	NextGCBytes  uint64 `json:"next_gc_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	LastGC       string `json:"last_gc,omitempty"`
+	LastPauseNS  uint64 `json:"last_pause_ns"`
+	TotalPauseNS uint64 `json:"total_pause_ns"`
+}
+
+// Snapshot is the state of the process at one moment
+type Snapshot struct {
+	TakenAt    time.Time   `json:"taken_at"`
+	Uptime     string      `json:"uptime"`
+	GoVersion  string      `json:"go_version"`
+	CPUs       int         `json:"cpus"`
+	GOMAXPROCS int         `json:"gomaxprocs"`
+	Goroutines int         `json:"goroutines"`
+	Heap       Heap        `json:"heap"`
+	Caches     []lru.Stats `json:"caches"`
+	// Stacks are every goroutine's stack, when requested
+	Stacks string `json:"stacks,omitempty"`
+}
+
+// Take returns a snapshot of the process, with every goroutine's stack if
+// stacks is set
+func Take(stacks bool) *Snapshot {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	snapshot := &Snapshot{
+		TakenAt:    time.Now().UTC(),
+		Uptime:     time.Since(started).Round(time.Second).String(),
+		GoVersion:  runtime.Version(),
+		CPUs:       runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		Heap: Heap{
+			AllocBytes:   mem.HeapAlloc,
+			InuseBytes:   mem.HeapInuse,
+			SysBytes:     mem.Sys,
+			Objects:      mem.HeapObjects,
+			NextGCBytes:  mem.NextGC,
+			NumGC:        mem.NumGC,
+			LastPauseNS:  mem.PauseNs[(mem.NumGC+255)%256],
+			TotalPauseNS: mem.PauseTotalNs,
+		},
+		Caches: lru.All(),
+	}
+	if mem.LastGC > 0 {
+		snapshot.Heap.LastGC = time.Unix(0, int64(mem.LastGC)).UTC().Format(time.RFC3339)
+	}
+	if stacks {
+		var buf bytes.Buffer
+		pprof.Lookup("goroutine").WriteTo(&buf, 2)
+		snapshot.Stacks = buf.String()
+	}
+	return snapshot
+}
+
+// SnapshotHandler returns a goroutine and heap snapshot; ?stacks=true adds
+// every goroutine's stack
+func SnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	stacks, _ := strconv.ParseBool(r.URL.Query().Get("stacks"))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Take(stacks))
+}
+
+// ProfileHandler serves a named runtime profile, such as heap or goroutine,
+// which pprof.Index only serves under /debug/pprof/
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["profile"]
+	if pprof.Lookup(name) == nil {
+		http.Error(w, "Unknown profile", http.StatusNotFound)
+		return
+	}
+	httppprof.Handler(name).ServeHTTP(w, r)
+}
@@ -0,0 +1,62 @@
+// project/handler.go
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for project profitability
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new project handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetProfitability returns income, costs and margin for a customer, job or
+// project, optionally limited to ?from= and ?to= dates
+func (h *Handler) GetProfitability(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := validateID(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var period Period
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if period.From, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if period.To, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.service.Profitability(r.Context(), id, period)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to compute profitability: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
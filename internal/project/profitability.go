@@ -0,0 +1,221 @@
+// project/profitability.go
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// ErrProjectNotFound is returned when the customer, job or project does not exist
+var ErrProjectNotFound = errors.New("project not found")
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// Period limits profitability to transactions dated within it; zero values are open-ended
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// txns starts a query for an entity's transactions dated within the period
+func (p Period) txns(entity string) *query.Builder {
+	b := query.Select(entity)
+	if !p.From.IsZero() {
+		b.Where("TxnDate", ">=", p.From.Format("2006-01-02"))
+	}
+	if !p.To.IsZero() {
+		b.Where("TxnDate", "<=", p.To.Format("2006-01-02"))
+	}
+	return b
+}
+
+// Profitability summarizes income and costs for a customer, job or project
+type Profitability struct {
+	ProjectID     string  `json:"project_id"`
+	Name          string  `json:"name"`
+	IsProject     bool    `json:"is_project"`
+	From          string  `json:"from,omitempty"`
+	To            string  `json:"to,omitempty"`
+	Income        float64 `json:"income"`
+	BillCosts     float64 `json:"bill_costs"`
+	ExpenseCosts  float64 `json:"expense_costs"`
+	LaborCosts    float64 `json:"labor_costs"`
+	TotalCosts    float64 `json:"total_costs"`
+	GrossProfit   float64 `json:"gross_profit"`
+	MarginPercent float64 `json:"margin_percent"`
+	HoursLogged   float64 `json:"hours_logged"`
+	InvoiceCount  int     `json:"invoice_count"`
+}
+
+// ref is a QuickBooks entity reference
+type ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+// salesTxn is the subset of invoice and sales receipt fields used for income
+type salesTxn struct {
+	TotalAmt     float64 `json:"TotalAmt"`
+	TxnTaxDetail struct {
+		TotalTax float64 `json:"TotalTax"`
+	} `json:"TxnTaxDetail"`
+}
+
+// costTxn is the subset of bill and purchase fields used for costs
+type costTxn struct {
+	Line []struct {
+		Amount                        float64 `json:"Amount"`
+		AccountBasedExpenseLineDetail *struct {
+			CustomerRef ref `json:"CustomerRef"`
+		} `json:"AccountBasedExpenseLineDetail"`
+		ItemBasedExpenseLineDetail *struct {
+			CustomerRef ref `json:"CustomerRef"`
+		} `json:"ItemBasedExpenseLineDetail"`
+	} `json:"Line"`
+}
+
+// amountFor totals the lines allocated to a customer
+func (t costTxn) amountFor(customerID string) float64 {
+	total := 0.0
+	for _, line := range t.Line {
+		switch {
+		case line.AccountBasedExpenseLineDetail != nil && line.AccountBasedExpenseLineDetail.CustomerRef.Value == customerID:
+			total += line.Amount
+		case line.ItemBasedExpenseLineDetail != nil && line.ItemBasedExpenseLineDetail.CustomerRef.Value == customerID:
+			total += line.Amount
+		}
+	}
+	return total
+}
+
+// timeActivity is the subset of time activity fields used for labor cost
+type timeActivity struct {
+	CustomerRef ref     `json:"CustomerRef"`
+	Hours       int     `json:"Hours"`
+	Minutes     int     `json:"Minutes"`
+	CostRate    float64 `json:"CostRate"`
+}
+
+// Service computes project profitability from QuickBooks transactions
+type Service struct {
+	querier Querier
+}
+
+// NewService creates a new project profitability service
+func NewService(querier Querier) *Service {
+	return &Service{
+		querier: querier,
+	}
+}
+
+// Profitability aggregates income from invoices and sales receipts and costs
+// from bills, expenses and time for the customer, job or project. Income
+// excludes sales tax; labor is costed at each time entry's cost rate.
+func (s *Service) Profitability(ctx context.Context, projectID string, period Period) (*Profitability, error) {
+	customers, err := query.List[struct {
+		ID          string `json:"Id"`
+		DisplayName string `json:"DisplayName"`
+		IsProject   bool   `json:"IsProject"`
+	}](ctx, s.querier, query.Select("Customer").Where("Id", "=", projectID))
+	if err != nil {
+		return nil, err
+	}
+	if len(customers) == 0 {
+		return nil, ErrProjectNotFound
+	}
+
+	result := &Profitability{
+		ProjectID: projectID,
+		Name:      customers[0].DisplayName,
+		IsProject: customers[0].IsProject,
+	}
+	if !period.From.IsZero() {
+		result.From = period.From.Format("2006-01-02")
+	}
+	if !period.To.IsZero() {
+		result.To = period.To.Format("2006-01-02")
+	}
+
+	// Income
+	for _, entity := range []string{"Invoice", "SalesReceipt"} {
+		sales, err := query.All[salesTxn](ctx, s.querier, period.txns(entity).Where("CustomerRef", "=", projectID))
+		if err != nil {
+			return nil, err
+		}
+		for _, txn := range sales {
+			result.Income += txn.TotalAmt - txn.TxnTaxDetail.TotalTax
+		}
+		if entity == "Invoice" {
+			result.InvoiceCount = len(sales)
+		}
+	}
+
+	// Costs are allocated per line, so bills and expenses are scanned for the period
+	bills, err := query.All[costTxn](ctx, s.querier, period.txns("Bill"))
+	if err != nil {
+		return nil, err
+	}
+	for _, bill := range bills {
+		result.BillCosts += bill.amountFor(projectID)
+	}
+
+	purchases, err := query.All[costTxn](ctx, s.querier, period.txns("Purchase"))
+	if err != nil {
+		return nil, err
+	}
+	for _, purchase := range purchases {
+		result.ExpenseCosts += purchase.amountFor(projectID)
+	}
+
+	activities, err := query.All[timeActivity](ctx, s.querier, period.txns("TimeActivity"))
+	if err != nil {
+		return nil, err
+	}
+	for _, activity := range activities {
+		if activity.CustomerRef.Value != projectID {
+			continue
+		}
+		hours := float64(activity.Hours) + float64(activity.Minutes)/60
+		result.HoursLogged += hours
+		result.LaborCosts += hours * activity.CostRate
+	}
+
+	result.Income = roundCents(result.Income)
+	result.BillCosts = roundCents(result.BillCosts)
+	result.ExpenseCosts = roundCents(result.ExpenseCosts)
+	result.LaborCosts = roundCents(result.LaborCosts)
+	result.TotalCosts = roundCents(result.BillCosts + result.ExpenseCosts + result.LaborCosts)
+	result.GrossProfit = roundCents(result.Income - result.TotalCosts)
+	result.HoursLogged = math.Round(result.HoursLogged*100) / 100
+	if result.Income != 0 {
+		result.MarginPercent = math.Round(result.GrossProfit/result.Income*10000) / 100
+	}
+
+	return result, nil
+}
+
+// validateID checks that id looks like a QuickBooks entity ID
+func validateID(id string) error {
+	if id == "" {
+		return fmt.Errorf("project ID is required")
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("invalid project ID")
+		}
+	}
+	return nil
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,74 @@
+// qbhealth/handler.go
+package qbhealth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// maxDiagnosticHours bounds the period of realm diagnostics to what is kept
+const maxDiagnosticHours = 7 * 24
+
+// Handler provides HTTP handlers for QuickBooks API health
+type Handler struct {
+	monitor *Monitor
+}
+
+// NewHandler creates a new QuickBooks API health handler
+func NewHandler(monitor *Monitor) *Handler {
+	return &Handler{
+		monitor: monitor,
+	}
+}
+
+// GetHealth returns per-endpoint QuickBooks API statistics and deprecation
+// notices
+func (h *Handler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	report, err := h.monitor.Report(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get QuickBooks API health: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// ResetHealth clears the statistics
+func (h *Handler) ResetHealth(w http.ResponseWriter, r *http.Request) {
+	if err := h.monitor.Reset(r.Context()); err != nil {
+		http.Error(w, "Failed to reset QuickBooks API health: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RealmDiagnostics returns a company's QuickBooks API latency
+// percentiles, error codes and throttle events, for the last 24 hours or
+// ?hours=N up to a week
+func (h *Handler) RealmDiagnostics(w http.ResponseWriter, r *http.Request) {
+	hours := 24
+	if value := r.URL.Query().Get("hours"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxDiagnosticHours {
+			http.Error(w, "hours must be between 1 and 168", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+
+	diag, err := h.monitor.Diagnostics(r.Context(), mux.Vars(r)["realmID"], hours)
+	if err != nil {
+		http.Error(w, "Failed to get realm diagnostics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(diag)
+}
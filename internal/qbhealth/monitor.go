@@ -0,0 +1,183 @@
+// qbhealth/monitor.go
+package qbhealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+)
+
+// TIDHeader is the transaction ID Intuit support asks for about a response
+const TIDHeader = "intuit_tid"
+
+// idSegment matches path segments that are entity IDs
+var idSegment = regexp.MustCompile(`^[0-9]+$`)
+
+// Notice is a deprecation notice QuickBooks sent with a response
+type Notice struct {
+	Deprecation string            `json:"deprecation,omitempty"`
+	Sunset      string            `json:"sunset,omitempty"`
+	Warning     string            `json:"warning,omitempty"`
+	Link        string            `json:"link,omitempty"`
+	Other       map[string]string `json:"other,omitempty"` // other headers naming a deprecation
+	IntuitTID   string            `json:"intuit_tid,omitempty"`
+	SeenAt      time.Time         `json:"seen_at"`
+}
+
+// notice returns the deprecation notice in response headers, if any
+func notice(header http.Header) *Notice {
+	n := &Notice{
+		Deprecation: header.Get("Deprecation"),
+		Sunset:      header.Get("Sunset"),
+		Warning:     header.Get("Warning"),
+	}
+	for _, link := range header.Values("Link") {
+		if strings.Contains(link, `rel="deprecation"`) || strings.Contains(link, `rel="sunset"`) {
+			n.Link = link
+		}
+	}
+	for name, values := range header {
+		if name != "Deprecation" && strings.Contains(strings.ToLower(name), "deprecat") {
+			if n.Other == nil {
+				n.Other = make(map[string]string)
+			}
+			n.Other[name] = strings.Join(values, ", ")
+		}
+	}
+	if n.Deprecation == "" && n.Sunset == "" && n.Warning == "" && n.Link == "" && n.Other == nil {
+		return nil
+	}
+	n.IntuitTID = header.Get(TIDHeader)
+	n.SeenAt = time.Now().UTC()
+	return n
+}
+
+// Endpoint names a QuickBooks API call without its company and entity IDs,
+// e.g. "GET invoice/{id}" or "POST invoice?operation=void"
+func Endpoint(method string, u *url.URL) string {
+	path := u.Path
+	if i := strings.Index(path, "/company/"); i >= 0 {
+		path = path[i+len("/company/"):]
+		if j := strings.Index(path, "/"); j >= 0 {
+			path = path[j+1:]
+		} else {
+			path = ""
+		}
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	endpoint := method + " " + strings.Join(segments, "/")
+	if operation := u.Query().Get("operation"); operation != "" {
+		endpoint += "?operation=" + operation
+	}
+	return endpoint
+}
+
+// Monitor records, per QuickBooks endpoint, call and error counts, the
+// latest intuit_tid and any deprecation notices, so operators learn of
+// upcoming Intuit changes from the traffic itself. Per company, it records
+// latencies, error codes and throttling for diagnosing slow connections.
+type Monitor struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewMonitor creates a new QuickBooks API health monitor
+func NewMonitor(client redis.UniversalClient, prefix string) *Monitor {
+	return &Monitor{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key returns the Redis hash of one statistic by endpoint
+func (m *Monitor) key(stat string) string {
+	return fmt.Sprintf("%s:qbhealth:%s", m.prefix, stat)
+}
+
+// Observe records one QuickBooks response
+func (m *Monitor) Observe(ctx context.Context, endpoint string, resp *http.Response) error {
+	tid := resp.Header.Get(TIDHeader)
+	found := notice(resp.Header)
+	var encoded []byte
+	if found != nil {
+		var err error
+		if encoded, err = json.Marshal(found); err != nil {
+			return fmt.Errorf("failed to marshal deprecation notice: %w", err)
+		}
+	}
+
+	var firstWarned *redis.BoolCmd
+	_, err := m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, m.key("calls"), endpoint, 1)
+		if tid != "" {
+			pipe.HSet(ctx, m.key("tid"), endpoint, tid)
+		}
+		if resp.StatusCode >= 400 {
+			pipe.HIncrBy(ctx, m.key("errors"), endpoint, 1)
+			if tid != "" {
+				pipe.HSet(ctx, m.key("error_tid"), endpoint, tid)
+			}
+		}
+		if found != nil {
+			pipe.HIncrBy(ctx, m.key("warnings"), endpoint, 1)
+			pipe.HSet(ctx, m.key("notice"), endpoint, encoded)
+			firstWarned = pipe.HSetNX(ctx, m.key("first_warned"), endpoint, found.SeenAt.Format(time.RFC3339))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record QuickBooks response: %w", err)
+	}
+	if firstWarned != nil && firstWarned.Val() {
+		logging.FromContext(ctx).Warn("QuickBooks sent a deprecation notice", "endpoint", endpoint, "notice", encoded)
+	}
+	return nil
+}
+
+// Transport wraps an HTTP transport so every QuickBooks response is
+// observed
+func (m *Monitor) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{monitor: m, base: base}
+}
+
+// transport observes QuickBooks responses
+type transport struct {
+	monitor *Monitor
+	base    http.RoundTripper
+}
+
+// RoundTrip forwards the call, then observes its response. Telemetry
+// failures never fail the call.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	endpoint := Endpoint(req.Method, req.URL)
+	if err == nil {
+		if err := t.monitor.Observe(req.Context(), endpoint, resp); err != nil {
+			logging.FromContext(req.Context()).Warn("failed to observe QuickBooks response", "endpoint", endpoint, "error", err)
+		}
+	}
+	if err := t.monitor.ObserveRealm(req.Context(), RealmID(req.URL), endpoint, elapsed, resp); err != nil {
+		logging.FromContext(req.Context()).Warn("failed to observe QuickBooks latency", "endpoint", endpoint, "error", err)
+	}
+	return resp, err
+}
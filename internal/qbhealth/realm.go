@@ -0,0 +1,278 @@
+// qbhealth/realm.go
+package qbhealth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// realmRetention is how long per-realm statistics are kept
+	realmRetention = 7 * 24 * time.Hour
+	// maxThrottles is how many throttle events are kept per realm
+	maxThrottles = 100
+	// maxFaultBody bounds how much of an error response is read for its code
+	maxFaultBody = 64 << 10
+)
+
+// latencyBuckets are the upper bounds, in milliseconds, latencies are
+// counted in; slower calls fall in a final unbounded bucket
+var latencyBuckets = []int64{50, 100, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000}
+
+// hourLayout formats the hours per-realm statistics are kept in
+const hourLayout = "2006010215"
+
+// Throttle is a call QuickBooks refused for exceeding its rate limits
+type Throttle struct {
+	At         time.Time `json:"at"`
+	Endpoint   string    `json:"endpoint"`
+	RetryAfter string    `json:"retry_after,omitempty"`
+	IntuitTID  string    `json:"intuit_tid,omitempty"`
+}
+
+// EndpointLatency is the call count and mean latency of one endpoint
+type EndpointLatency struct {
+	Endpoint string  `json:"endpoint"`
+	Calls    int64   `json:"calls"`
+	MeanMS   float64 `json:"mean_ms"`
+}
+
+// Diagnostics summarizes one company's QuickBooks API calls over a period
+type Diagnostics struct {
+	RealmID   string            `json:"realm_id"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Calls     int64             `json:"calls"`
+	Errors    int64             `json:"errors"`
+	Throttled int64             `json:"throttled"`
+	P50MS     int64             `json:"p50_ms"`
+	P90MS     int64             `json:"p90_ms"`
+	P99MS     int64             `json:"p99_ms"`
+	ErrorCode map[string]int64  `json:"error_codes"` // by HTTP status and QuickBooks fault code, e.g. "400:6000"
+	Endpoints []EndpointLatency `json:"endpoints"`
+	// Throttles are the most recent throttle events, newest first
+	Throttles []Throttle `json:"throttles"`
+}
+
+// RealmID returns the company a QuickBooks API URL addresses
+func RealmID(u *url.URL) string {
+	path := u.Path
+	i := strings.Index(path, "/company/")
+	if i < 0 {
+		return ""
+	}
+	path = path[i+len("/company/"):]
+	if j := strings.Index(path, "/"); j >= 0 {
+		path = path[:j]
+	}
+	return path
+}
+
+// realmKey returns the Redis hash of a company's statistics for an hour
+func (m *Monitor) realmKey(realmID string, hour time.Time) string {
+	return fmt.Sprintf("%s:qbhealth:realm:%s:%s", m.prefix, realmID, hour.UTC().Format(hourLayout))
+}
+
+// throttlesKey returns the Redis list of a company's throttle events
+func (m *Monitor) throttlesKey(realmID string) string {
+	return fmt.Sprintf("%s:qbhealth:realm:%s:throttles", m.prefix, realmID)
+}
+
+// bucket returns the latency bucket field for a duration
+func bucket(elapsed time.Duration) string {
+	ms := elapsed.Milliseconds()
+	for _, bound := range latencyBuckets {
+		if ms <= bound {
+			return "lat:" + strconv.FormatInt(bound, 10)
+		}
+	}
+	return "lat:inf"
+}
+
+// faultCode returns the QuickBooks fault code of an error response,
+// leaving the body readable for the client
+func faultCode(resp *http.Response) string {
+	if resp.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFaultBody))
+	rest := resp.Body
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), rest), rest}
+	if err != nil {
+		return ""
+	}
+
+	var fault struct {
+		Fault struct {
+			Error []struct {
+				Code string `json:"code"`
+			} `json:"Error"`
+		} `json:"Fault"`
+	}
+	if json.Unmarshal(body, &fault) != nil || len(fault.Fault.Error) == 0 {
+		return ""
+	}
+	return fault.Fault.Error[0].Code
+}
+
+// ObserveRealm records one call's latency and outcome for its company. A
+// nil response is a call that never got one.
+func (m *Monitor) ObserveRealm(ctx context.Context, realmID, endpoint string, elapsed time.Duration, resp *http.Response) error {
+	if realmID == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	key := m.realmKey(realmID, now)
+
+	var code, tid string
+	if resp == nil {
+		code = "network"
+	} else if resp.StatusCode >= 400 {
+		tid = resp.Header.Get(TIDHeader)
+		code = strconv.Itoa(resp.StatusCode)
+		if fault := faultCode(resp); fault != "" {
+			code += ":" + fault
+		}
+	}
+	throttled := resp != nil && resp.StatusCode == http.StatusTooManyRequests
+	var throttle []byte
+	if throttled {
+		var err error
+		throttle, err = json.Marshal(Throttle{At: now, Endpoint: endpoint, RetryAfter: resp.Header.Get("Retry-After"), IntuitTID: tid})
+		if err != nil {
+			return fmt.Errorf("failed to marshal throttle event: %w", err)
+		}
+	}
+
+	_, err := m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "calls", 1)
+		pipe.HIncrBy(ctx, key, bucket(elapsed), 1)
+		pipe.HIncrBy(ctx, key, "ep:"+endpoint, 1)
+		pipe.HIncrBy(ctx, key, "ep_us:"+endpoint, elapsed.Microseconds())
+		if code != "" {
+			pipe.HIncrBy(ctx, key, "errors", 1)
+			pipe.HIncrBy(ctx, key, "code:"+code, 1)
+		}
+		pipe.Expire(ctx, key, realmRetention)
+		if throttled {
+			pipe.HIncrBy(ctx, key, "throttled", 1)
+			pipe.LPush(ctx, m.throttlesKey(realmID), throttle)
+			pipe.LTrim(ctx, m.throttlesKey(realmID), 0, maxThrottles-1)
+			pipe.Expire(ctx, m.throttlesKey(realmID), realmRetention)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record realm statistics: %w", err)
+	}
+	return nil
+}
+
+// Diagnostics returns a company's statistics for the hours up to now
+func (m *Monitor) Diagnostics(ctx context.Context, realmID string, hours int) (*Diagnostics, error) {
+	now := time.Now().UTC()
+	diag := &Diagnostics{
+		RealmID:   realmID,
+		From:      now.Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour),
+		To:        now,
+		ErrorCode: make(map[string]int64),
+		Endpoints: []EndpointLatency{},
+		Throttles: []Throttle{},
+	}
+
+	latencies := make(map[string]int64)
+	endpoints := make(map[string]*EndpointLatency)
+	endpointUS := make(map[string]int64)
+	for hour := diag.From; !hour.After(now); hour = hour.Add(time.Hour) {
+		fields, err := m.client.HGetAll(ctx, m.realmKey(realmID, hour)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get realm statistics: %w", err)
+		}
+		for field, value := range fields {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			switch {
+			case field == "calls":
+				diag.Calls += n
+			case field == "errors":
+				diag.Errors += n
+			case field == "throttled":
+				diag.Throttled += n
+			case strings.HasPrefix(field, "lat:"):
+				latencies[strings.TrimPrefix(field, "lat:")] += n
+			case strings.HasPrefix(field, "code:"):
+				diag.ErrorCode[strings.TrimPrefix(field, "code:")] += n
+			case strings.HasPrefix(field, "ep_us:"):
+				endpointUS[strings.TrimPrefix(field, "ep_us:")] += n
+			case strings.HasPrefix(field, "ep:"):
+				name := strings.TrimPrefix(field, "ep:")
+				if endpoints[name] == nil {
+					endpoints[name] = &EndpointLatency{Endpoint: name}
+				}
+				endpoints[name].Calls += n
+			}
+		}
+	}
+
+	diag.P50MS = percentile(latencies, 0.50)
+	diag.P90MS = percentile(latencies, 0.90)
+	diag.P99MS = percentile(latencies, 0.99)
+	for name, e := range endpoints {
+		if e.Calls > 0 {
+			e.MeanMS = float64(endpointUS[name]) / float64(e.Calls) / 1000
+		}
+		diag.Endpoints = append(diag.Endpoints, *e)
+	}
+	sort.Slice(diag.Endpoints, func(i, j int) bool {
+		return diag.Endpoints[i].MeanMS > diag.Endpoints[j].MeanMS
+	})
+
+	events, err := m.client.LRange(ctx, m.throttlesKey(realmID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get throttle events: %w", err)
+	}
+	for _, event := range events {
+		var t Throttle
+		if err := json.Unmarshal([]byte(event), &t); err == nil && !t.At.Before(diag.From) {
+			diag.Throttles = append(diag.Throttles, t)
+		}
+	}
+	return diag, nil
+}
+
+// percentile returns the upper bound of the latency bucket holding the
+// given fraction of calls; calls beyond the last bound report -1
+func percentile(latencies map[string]int64, fraction float64) int64 {
+	var total int64
+	for _, n := range latencies {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := int64(fraction*float64(total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, bound := range latencyBuckets {
+		seen += latencies[strconv.FormatInt(bound, 10)]
+		if seen >= rank {
+			return bound
+		}
+	}
+	return -1
+}
@@ -0,0 +1,98 @@
+// qbhealth/report.go
+package qbhealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// stats are the statistics kept by endpoint
+var stats = []string{"calls", "errors", "tid", "error_tid", "warnings", "notice", "first_warned"}
+
+// EndpointHealth summarizes the responses of one QuickBooks endpoint
+type EndpointHealth struct {
+	Endpoint     string     `json:"endpoint"`
+	Calls        int64      `json:"calls"`
+	Errors       int64      `json:"errors"`
+	LastTID      string     `json:"last_intuit_tid,omitempty"`
+	LastErrorTID string     `json:"last_error_intuit_tid,omitempty"`
+	Warnings     int64      `json:"warnings,omitempty"` // responses carrying a deprecation notice
+	FirstWarned  *time.Time `json:"first_warned_at,omitempty"`
+	Notice       *Notice    `json:"latest_notice,omitempty"`
+}
+
+// Report is the health of the QuickBooks API as seen by this server
+type Report struct {
+	MinorVersion string           `json:"minor_version"`
+	Deprecated   int              `json:"deprecated_endpoints"`
+	Endpoints    []EndpointHealth `json:"endpoints"`
+}
+
+// Report returns every observed endpoint, those with deprecation notices
+// first, then the busiest
+func (m *Monitor) Report(ctx context.Context) (*Report, error) {
+	values := make(map[string]map[string]string, len(stats))
+	for _, stat := range stats {
+		all, err := m.client.HGetAll(ctx, m.key(stat)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get QuickBooks API %s: %w", stat, err)
+		}
+		values[stat] = all
+	}
+
+	report := &Report{MinorVersion: qbclient.MinorVersion, Endpoints: []EndpointHealth{}}
+	for endpoint, calls := range values["calls"] {
+		health := EndpointHealth{
+			Endpoint:     endpoint,
+			LastTID:      values["tid"][endpoint],
+			LastErrorTID: values["error_tid"][endpoint],
+		}
+		health.Calls, _ = strconv.ParseInt(calls, 10, 64)
+		health.Errors, _ = strconv.ParseInt(values["errors"][endpoint], 10, 64)
+		health.Warnings, _ = strconv.ParseInt(values["warnings"][endpoint], 10, 64)
+		if first, err := time.Parse(time.RFC3339, values["first_warned"][endpoint]); err == nil {
+			health.FirstWarned = &first
+		}
+		if encoded := values["notice"][endpoint]; encoded != "" {
+			var n Notice
+			if err := json.Unmarshal([]byte(encoded), &n); err == nil {
+				health.Notice = &n
+			}
+		}
+		if health.Warnings > 0 {
+			report.Deprecated++
+		}
+		report.Endpoints = append(report.Endpoints, health)
+	}
+
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		a, b := report.Endpoints[i], report.Endpoints[j]
+		if (a.Warnings > 0) != (b.Warnings > 0) {
+			return a.Warnings > 0
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		return a.Endpoint < b.Endpoint
+	})
+	return report, nil
+}
+
+// Reset clears the statistics, e.g. once operators have acted on the
+// notices they showed
+func (m *Monitor) Reset(ctx context.Context) error {
+	keys := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		keys = append(keys, m.key(stat))
+	}
+	if err := m.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to reset QuickBooks API health: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// qbwebhook/change.go
+package qbwebhook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Change is one entity change reported by a QuickBooks webhook
+type Change struct {
+	// EventID identifies the notification when Intuit provides one. Without
+	// it, the change's own fields identify it.
+	EventID     string    `json:"event_id,omitempty"`
+	RealmID     string    `json:"realm_id"`
+	Entity      string    `json:"entity"` // e.g. "Invoice"
+	ID          string    `json:"id"`
+	Operation   string    `json:"operation"` // Create, Update, Delete, Merge or Void
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// key identifies the change for deduplication. Redeliveries of the same
+// change share a key even when Intuit sends them in separate notifications.
+func (c Change) key() string {
+	if c.EventID != "" {
+		return "id:" + c.EventID
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%d", c.RealmID, strings.ToLower(c.Entity), c.ID, strings.ToLower(c.Operation), c.LastUpdated.UnixNano())
+}
+
+// entityKey identifies the entity the change applies to
+func (c Change) entityKey() string {
+	return fmt.Sprintf("%s:%s:%s", c.RealmID, strings.ToLower(c.Entity), c.ID)
+}
+
+// Result statuses
+const (
+	StatusProcessed = "processed" // handlers ran and succeeded
+	StatusDuplicate = "duplicate" // already processed or being processed elsewhere
+	StatusStale     = "stale"     // older than a change already processed for the entity
+	StatusFailed    = "failed"    // a handler failed; a redelivery will be processed again
+	StatusReplayed  = "replayed"  // handlers ran again on an operator's request
+	StatusPending   = "pending"   // would be replayed; reported by dry runs
+)
+
+// Result is the outcome of ingesting one change
+type Result struct {
+	Change Change `json:"change"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
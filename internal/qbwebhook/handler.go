@@ -0,0 +1,82 @@
+// qbwebhook/handler.go
+package qbwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/gorilla/mux"
+)
+
+// ReplayHandler provides operator endpoints for reprocessing webhook history
+type ReplayHandler struct {
+	replayer   *Replayer
+	operations *operations.Manager
+}
+
+// NewReplayHandler creates a new replay handler
+func NewReplayHandler(replayer *Replayer, operations *operations.Manager) *ReplayHandler {
+	return &ReplayHandler{
+		replayer:   replayer,
+		operations: operations,
+	}
+}
+
+// Replay runs the handlers again for stored webhook changes in a range
+func (h *ReplayHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, "webhook_replay", h.replayer.Replay)
+}
+
+// Backfill runs the handlers for entities QuickBooks reports as changed in a range
+func (h *ReplayHandler) Backfill(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, "webhook_backfill", h.replayer.Backfill)
+}
+
+// serve decodes the options, runs fn for the tenant and writes its report.
+// Asynchronous requests are answered with an operation of the given kind
+// whose result is the report.
+func (h *ReplayHandler) serve(w http.ResponseWriter, r *http.Request, kind string, fn func(ctx context.Context, tenantID string, opts ReplayOptions) (*ReplayReport, error)) {
+	var opts ReplayOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := mux.Vars(r)["tenantID"]
+	if operations.Async(r) {
+		op, err := h.operations.Start(r.Context(), tenantID, kind, func(ctx context.Context) (interface{}, error) {
+			return fn(ctx, tenantID, opts)
+		})
+		if err != nil {
+			http.Error(w, "Failed to start operation: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		operations.Accepted(w, r, op)
+		return
+	}
+
+	report, err := fn(r.Context(), tenantID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTooManyChanges):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, ErrNoEntities):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to reprocess changes: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
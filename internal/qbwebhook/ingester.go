@@ -0,0 +1,212 @@
+// qbwebhook/ingester.go
+package qbwebhook
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// Handler reacts to an entity change, e.g. by syncing the entity downstream
+type Handler interface {
+	HandleChange(ctx context.Context, change Change) error
+}
+
+// HandlerFunc adapts a function to the Handler interface
+type HandlerFunc func(ctx context.Context, change Change) error
+
+// HandleChange calls f(ctx, change)
+func (f HandlerFunc) HandleChange(ctx context.Context, change Change) error {
+	return f(ctx, change)
+}
+
+// Locker serializes work on one entity across server instances
+type Locker interface {
+	Lock(ctx context.Context, key string) (func(), error)
+}
+
+// Ingester runs registered handlers for webhook changes at most once per
+// change within the dedup window. Changes to the same entity are handled
+// one at a time, oldest first, and a change older than one already handled
+// for the entity is skipped as stale.
+type Ingester struct {
+	store  *Store
+	locker Locker
+
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	names    map[string]string // registered entity names by lower-case name
+}
+
+// NewIngester creates a new webhook ingester
+func NewIngester(store *Store, locker Locker) *Ingester {
+	return &Ingester{
+		store:    store,
+		locker:   locker,
+		handlers: make(map[string][]Handler),
+		names:    make(map[string]string),
+	}
+}
+
+// Register adds a handler for an entity type, or for every type with "*"
+func (i *Ingester) Register(entity string, handler Handler) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	key := strings.ToLower(entity)
+	i.handlers[key] = append(i.handlers[key], handler)
+	i.names[key] = entity
+}
+
+// handlersFor returns the handlers for an entity type
+func (i *Ingester) handlersFor(entity string) []Handler {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append(append([]Handler(nil), i.handlers[strings.ToLower(entity)]...), i.handlers["*"]...)
+}
+
+// Ingest handles a batch of changes and reports the outcome of each, in the
+// order given
+func (i *Ingester) Ingest(ctx context.Context, changes []Change) []Result {
+	return i.run(ctx, changes, i.ingestChange)
+}
+
+// run groups changes by entity and applies fn to each under the entity's
+// lock, oldest first
+func (i *Ingester) run(ctx context.Context, changes []Change, fn func(ctx context.Context, change Change) Result) []Result {
+	// Nobody waits on the entities handlers read
+	ctx = qbclient.WithPriority(ctx, qbclient.PriorityBackground)
+
+	// Group by entity, keeping each group's changes oldest first
+	order := make([]int, len(changes))
+	for n := range order {
+		order[n] = n
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ca, cb := changes[order[a]], changes[order[b]]
+		if ca.entityKey() != cb.entityKey() {
+			return ca.entityKey() < cb.entityKey()
+		}
+		return ca.LastUpdated.Before(cb.LastUpdated)
+	})
+
+	results := make([]Result, len(changes))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && changes[order[end]].entityKey() == changes[order[start]].entityKey() {
+			end++
+		}
+		i.runEntity(ctx, changes, order[start:end], results, fn)
+		start = end
+	}
+	return results
+}
+
+// Replay runs the handlers for changes again, bypassing deduplication and
+// the stale check. Changes to the same entity still run one at a time,
+// oldest first. Progress is reported to the operation in ctx, if any.
+func (i *Ingester) Replay(ctx context.Context, changes []Change) []Result {
+	operations.SetTotal(ctx, len(changes))
+	return i.run(ctx, changes, func(ctx context.Context, change Change) Result {
+		result := i.replayChange(ctx, change)
+		if result.Status == StatusFailed {
+			operations.Error(ctx, change.Entity+" "+change.ID+": "+result.Error)
+		}
+		operations.Add(ctx, 1)
+		return result
+	})
+}
+
+// Entities returns the entity types with registered handlers
+func (i *Ingester) Entities() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	entities := make([]string, 0, len(i.names))
+	for key, entity := range i.names {
+		if key != "*" {
+			entities = append(entities, entity)
+		}
+	}
+	sort.Strings(entities)
+	return entities
+}
+
+// handle runs every handler for a change, stopping at the first failure
+func (i *Ingester) handle(ctx context.Context, change Change) error {
+	for _, handler := range i.handlersFor(change.Entity) {
+		if err := handler.HandleChange(ctx, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayChange handles one change again; the caller holds the entity's lock
+func (i *Ingester) replayChange(ctx context.Context, change Change) Result {
+	if err := i.handle(ctx, change); err != nil {
+		return Result{Change: change, Status: StatusFailed, Error: err.Error()}
+	}
+	return Result{Change: change, Status: StatusReplayed}
+}
+
+// runEntity applies fn to the changes of one entity under its lock
+func (i *Ingester) runEntity(ctx context.Context, changes []Change, indexes []int, results []Result, fn func(ctx context.Context, change Change) Result) {
+	first := changes[indexes[0]]
+	unlock, err := i.locker.Lock(ctx, "qbwebhook:"+first.entityKey())
+	if err != nil {
+		for _, n := range indexes {
+			results[n] = Result{Change: changes[n], Status: StatusFailed, Error: err.Error()}
+		}
+		return
+	}
+	defer unlock()
+
+	for _, n := range indexes {
+		results[n] = fn(ctx, changes[n])
+	}
+}
+
+// ingestChange handles one change; the caller holds the entity's lock
+func (i *Ingester) ingestChange(ctx context.Context, change Change) Result {
+	failed := func(err error) Result {
+		return Result{Change: change, Status: StatusFailed, Error: err.Error()}
+	}
+
+	cursor, err := i.store.Cursor(ctx, change)
+	if err != nil {
+		return failed(err)
+	}
+	if change.LastUpdated.Before(cursor) {
+		return Result{Change: change, Status: StatusStale}
+	}
+
+	claimed, err := i.store.Claim(ctx, change)
+	if err != nil {
+		return failed(err)
+	}
+	if !claimed {
+		return Result{Change: change, Status: StatusDuplicate}
+	}
+	if err := i.store.Record(ctx, change); err != nil {
+		// History only serves replays, so a failure must not block processing
+		logging.FromContext(ctx).Warn("failed to record webhook change", "entity", change.Entity, "id", change.ID, "error", err)
+	}
+
+	if err := i.handle(ctx, change); err != nil {
+		// Release the change so a redelivery runs every handler again
+		if abandonErr := i.store.Abandon(ctx, change); abandonErr != nil {
+			logging.FromContext(ctx).Warn("failed to abandon webhook change", "entity", change.Entity, "id", change.ID, "error", abandonErr)
+		}
+		return failed(err)
+	}
+
+	if err := i.store.Complete(ctx, change); err != nil {
+		// The handlers ran; the claim expires and a redelivery may run them again
+		logging.FromContext(ctx).Warn("failed to complete webhook change", "entity", change.Entity, "id", change.ID, "error", err)
+	}
+	return Result{Change: change, Status: StatusProcessed}
+}
@@ -0,0 +1,165 @@
+// qbwebhook/notification.go
+package qbwebhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidNotification is returned for bodies that are not a QuickBooks
+// event notification
+var ErrInvalidNotification = errors.New("invalid QuickBooks event notification")
+
+// notification is Intuit's original webhook payload
+type notification struct {
+	EventNotifications []struct {
+		RealmID         string `json:"realmId"`
+		DataChangeEvent struct {
+			Entities []struct {
+				Name        string `json:"name"`
+				ID          string `json:"id"`
+				Operation   string `json:"operation"`
+				LastUpdated string `json:"lastUpdated"`
+			} `json:"entities"`
+		} `json:"dataChangeEvent"`
+	} `json:"eventNotifications"`
+}
+
+// cloudEvent is one event of Intuit's CloudEvents payload, e.g. of type
+// "qbo.invoice.updated.v1"
+type cloudEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Time      string `json:"time"`
+	EntityID  string `json:"intuitentityid"`
+	AccountID string `json:"intuitaccountid"`
+}
+
+// cloudOperations maps CloudEvents verbs to QuickBooks operations
+var cloudOperations = map[string]string{
+	"created": "Create",
+	"updated": "Update",
+	"deleted": "Delete",
+	"merged":  "Merge",
+	"voided":  "Void",
+}
+
+// entityNames are QuickBooks entity names CloudEvents types spell in lower
+// case and that do not just capitalize
+var entityNames = map[string]string{
+	"billpayment":          "BillPayment",
+	"creditmemo":           "CreditMemo",
+	"journalcode":          "JournalCode",
+	"journalentry":         "JournalEntry",
+	"paymentmethod":        "PaymentMethod",
+	"purchaseorder":        "PurchaseOrder",
+	"recurringtransaction": "RecurringTransaction",
+	"refundreceipt":        "RefundReceipt",
+	"reimbursecharge":      "ReimburseCharge",
+	"salesreceipt":         "SalesReceipt",
+	"taxagency":            "TaxAgency",
+	"timeactivity":         "TimeActivity",
+	"vendorcredit":         "VendorCredit",
+}
+
+// lastUpdatedLayouts are the timestamp formats Intuit sends
+var lastUpdatedLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700", "2006-01-02T15:04:05Z0700"}
+
+// parseTime parses an Intuit timestamp
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range lastUpdatedLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: unreadable time %q", ErrInvalidNotification, value)
+}
+
+// ParseNotification returns the entity changes of a webhook body, in
+// either Intuit's original format or its CloudEvents format
+func ParseNotification(body []byte) ([]Change, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseCloudEvents(trimmed)
+	}
+
+	var n notification
+	if err := json.Unmarshal(trimmed, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	var changes []Change
+	for _, event := range n.EventNotifications {
+		if event.RealmID == "" {
+			return nil, fmt.Errorf("%w: missing realmId", ErrInvalidNotification)
+		}
+		for _, entity := range event.DataChangeEvent.Entities {
+			lastUpdated, err := parseTime(entity.LastUpdated)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, Change{
+				RealmID:     event.RealmID,
+				Entity:      entity.Name,
+				ID:          entity.ID,
+				Operation:   entity.Operation,
+				LastUpdated: lastUpdated,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// parseCloudEvents returns the entity changes of a CloudEvents batch
+func parseCloudEvents(body []byte) ([]Change, error) {
+	var events []cloudEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	changes := make([]Change, 0, len(events))
+	for _, event := range events {
+		parts := strings.Split(event.Type, ".")
+		if len(parts) < 3 || parts[0] != "qbo" {
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidNotification, event.Type)
+		}
+		if event.AccountID == "" || event.EntityID == "" {
+			return nil, fmt.Errorf("%w: event %s names no company or entity", ErrInvalidNotification, event.ID)
+		}
+		operation, ok := cloudOperations[parts[2]]
+		if !ok {
+			operation = capitalize(parts[2])
+		}
+		lastUpdated, err := parseTime(event.Time)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, Change{
+			EventID:     event.ID,
+			RealmID:     event.AccountID,
+			Entity:      entityName(parts[1]),
+			ID:          event.EntityID,
+			Operation:   operation,
+			LastUpdated: lastUpdated,
+		})
+	}
+	return changes, nil
+}
+
+// entityName returns the QuickBooks name of a lower-case entity name
+func entityName(name string) string {
+	if known, ok := entityNames[name]; ok {
+		return known
+	}
+	return capitalize(name)
+}
+
+// capitalize upper-cases the first letter of an ASCII word
+func capitalize(word string) string {
+	if word == "" {
+		return word
+	}
+	return strings.ToUpper(word[:1]) + word[1:]
+}
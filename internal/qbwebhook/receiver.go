@@ -0,0 +1,124 @@
+// qbwebhook/receiver.go
+package qbwebhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+)
+
+// SignatureHeader carries the base64 HMAC-SHA256 of the body under the
+// app's webhook verifier token
+const SignatureHeader = "intuit-signature"
+
+// maxNotificationSize bounds webhook bodies
+const maxNotificationSize = 1 << 20
+
+// ingestTimeout bounds handling one notification after it is acknowledged
+const ingestTimeout = 5 * time.Minute
+
+// Connections resolves the user and tenant a company is connected through,
+// so handlers can call QuickBooks for it. It returns an empty user ID for
+// companies no longer connected.
+type Connections interface {
+	Connection(ctx context.Context, realmID string) (userID, tenantID string, err error)
+}
+
+// Receiver is the endpoint QuickBooks Online posts change notifications to.
+// Intuit expects an answer within three seconds and redelivers otherwise,
+// so notifications are acknowledged once verified and ingested in the
+// background; failed changes stay in the history for an operator replay.
+type Receiver struct {
+	ingester      *Ingester
+	verifierToken string
+	connections   Connections
+}
+
+// NewReceiver creates a new webhook receiver verifying notifications with
+// the app's verifier token
+func NewReceiver(ingester *Ingester, verifierToken string, connections Connections) *Receiver {
+	return &Receiver{
+		ingester:      ingester,
+		verifierToken: verifierToken,
+		connections:   connections,
+	}
+}
+
+// validSignature checks the notification's signature against the verifier
+// token
+func (r *Receiver) validSignature(body []byte, signature string) bool {
+	if r.verifierToken == "" || signature == "" {
+		return false
+	}
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(r.verifierToken))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Receive verifies and acknowledges a change notification, then dispatches
+// its changes to the registered handlers
+func (r *Receiver) Receive(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxNotificationSize))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !r.validSignature(body, req.Header.Get(SignatureHeader)) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	changes, err := ParseNotification(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	go r.ingest(changes)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int{"received": len(changes)})
+}
+
+// ingest runs the handlers for each company's changes under the identity
+// of the user who connected it
+func (r *Receiver) ingest(changes []Change) {
+	ctx, cancel := context.WithTimeout(context.Background(), ingestTimeout)
+	defer cancel()
+
+	byRealm := make(map[string][]Change)
+	var realms []string
+	for _, change := range changes {
+		if _, ok := byRealm[change.RealmID]; !ok {
+			realms = append(realms, change.RealmID)
+		}
+		byRealm[change.RealmID] = append(byRealm[change.RealmID], change)
+	}
+
+	for _, realmID := range realms {
+		realmCtx := ctx
+		userID, tenantID, err := r.connections.Connection(ctx, realmID)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to resolve QuickBooks company of webhook", "realm_id", realmID, "error", err)
+		} else if userID != "" {
+			realmCtx = auth.WithIdentity(ctx, userID, tenantID, realmID)
+		}
+		for _, result := range r.ingester.Ingest(realmCtx, byRealm[realmID]) {
+			if result.Status == StatusFailed {
+				logging.FromContext(realmCtx).Warn("failed to handle webhook change", "entity", result.Change.Entity, "id", result.Change.ID, "realm_id", realmID, "error", result.Error)
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// qbwebhook/replay.go
+package qbwebhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// maxReplayChanges bounds the changes reprocessed by one request
+const maxReplayChanges = 5000
+
+// ErrTooManyChanges is returned when a range holds more changes than one
+// request may reprocess
+var ErrTooManyChanges = errors.New("range holds too many changes; narrow it")
+
+// ErrNoEntities is returned by a backfill that names no entities while no
+// webhook handlers are registered to default to
+var ErrNoEntities = errors.New("entities is required when no webhook handlers are registered")
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// TokenSource resolves a user's QuickBooks connection
+type TokenSource interface {
+	GetValidToken(ctx context.Context, userID string) (*auth.OAuthToken, error)
+}
+
+// ReplayOptions selects the changes to reprocess
+type ReplayOptions struct {
+	UserID   string    `json:"user_id"` // user whose QuickBooks connection handlers act through
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"` // defaults to now
+	Entities []string  `json:"entities,omitempty"`
+	DryRun   bool      `json:"dry_run,omitempty"` // list the changes without running handlers
+}
+
+// Validate applies defaults and checks the range
+func (o *ReplayOptions) Validate() error {
+	if o.UserID == "" {
+		return fmt.Errorf("user_id is required")
+	}
+	if o.From.IsZero() {
+		return fmt.Errorf("from is required")
+	}
+	if o.To.IsZero() {
+		o.To = time.Now()
+	}
+	if !o.To.After(o.From) {
+		return fmt.Errorf("to must be after from")
+	}
+	return nil
+}
+
+// ReplayReport summarizes a replay or backfill
+type ReplayReport struct {
+	RealmID string         `json:"realm_id"`
+	DryRun  bool           `json:"dry_run,omitempty"`
+	Counts  map[string]int `json:"counts"`
+	Results []Result       `json:"results"`
+}
+
+// Replayer reprocesses history for a realm, either from the stored webhook
+// changes or by asking QuickBooks which entities changed
+type Replayer struct {
+	ingester *Ingester
+	store    *Store
+	qb       Querier
+	tokens   TokenSource
+}
+
+// NewReplayer creates a new replayer
+func NewReplayer(ingester *Ingester, store *Store, qb Querier, tokens TokenSource) *Replayer {
+	return &Replayer{
+		ingester: ingester,
+		store:    store,
+		qb:       qb,
+		tokens:   tokens,
+	}
+}
+
+// connect resolves the user's company and adds the identity handlers act under
+func (r *Replayer) connect(ctx context.Context, tenantID, userID string) (context.Context, string, error) {
+	token, err := r.tokens.GetValidToken(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get QuickBooks connection: %w", err)
+	}
+	return auth.WithIdentity(ctx, userID, tenantID, token.RealmID), token.RealmID, nil
+}
+
+// Replay runs the handlers again for the webhook changes received for the
+// user's company in the range
+func (r *Replayer) Replay(ctx context.Context, tenantID string, opts ReplayOptions) (*ReplayReport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, realmID, err := r.connect(ctx, tenantID, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := r.store.History(ctx, realmID, opts.From, opts.To, maxReplayChanges+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) > maxReplayChanges {
+		return nil, ErrTooManyChanges
+	}
+
+	changes := history[:0]
+	for _, change := range history {
+		if len(opts.Entities) == 0 || containsFold(opts.Entities, change.Entity) {
+			changes = append(changes, change)
+		}
+	}
+	return r.run(ctx, realmID, changes, opts.DryRun), nil
+}
+
+// Backfill runs the handlers for every entity QuickBooks reports as changed
+// in the range, covering changes whose webhooks were never received.
+// Deleted entities cannot be queried, so deletions are not backfilled.
+func (r *Replayer) Backfill(ctx context.Context, tenantID string, opts ReplayOptions) (*ReplayReport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	entities := opts.Entities
+	if len(entities) == 0 {
+		entities = r.ingester.Entities()
+	}
+	if len(entities) == 0 {
+		return nil, ErrNoEntities
+	}
+
+	ctx, realmID, err := r.connect(ctx, tenantID, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, entity := range entities {
+		found, err := r.changed(ctx, realmID, entity, opts.From, opts.To)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, found...)
+		if len(changes) > maxReplayChanges {
+			return nil, ErrTooManyChanges
+		}
+	}
+	return r.run(ctx, realmID, changes, opts.DryRun), nil
+}
+
+// changedEntity is the part of an entity needed to describe its change
+type changedEntity struct {
+	ID       string `json:"Id"`
+	MetaData struct {
+		CreateTime      time.Time `json:"CreateTime"`
+		LastUpdatedTime time.Time `json:"LastUpdatedTime"`
+	} `json:"MetaData"`
+}
+
+// changed queries the entities of one type last updated in the range
+func (r *Replayer) changed(ctx context.Context, realmID, entity string, from, to time.Time) ([]Change, error) {
+	entities, err := query.All[changedEntity](ctx, r.qb, query.Select(entity, "Id", "MetaData").
+		Where("MetaData.LastUpdatedTime", ">=", from).
+		Where("MetaData.LastUpdatedTime", "<=", to))
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0, len(entities))
+	for _, e := range entities {
+		operation := "Update"
+		if e.MetaData.CreateTime.Equal(e.MetaData.LastUpdatedTime) {
+			operation = "Create"
+		}
+		changes = append(changes, Change{
+			RealmID:     realmID,
+			Entity:      entity,
+			ID:          e.ID,
+			Operation:   operation,
+			LastUpdated: e.MetaData.LastUpdatedTime,
+		})
+	}
+	return changes, nil
+}
+
+// run replays changes, or only lists them in a dry run
+func (r *Replayer) run(ctx context.Context, realmID string, changes []Change, dryRun bool) *ReplayReport {
+	report := &ReplayReport{RealmID: realmID, DryRun: dryRun, Counts: make(map[string]int)}
+	if dryRun {
+		report.Results = make([]Result, 0, len(changes))
+		for _, change := range changes {
+			report.Results = append(report.Results, Result{Change: change, Status: StatusPending})
+		}
+	} else {
+		report.Results = r.ingester.Replay(ctx, changes)
+	}
+
+	for _, result := range report.Results {
+		report.Counts[result.Status]++
+	}
+	return report
+}
+
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
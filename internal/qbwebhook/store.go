@@ -0,0 +1,150 @@
+// qbwebhook/store.go
+package qbwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Dedup markers
+const (
+	markerProcessing = "processing"
+	markerDone       = "done"
+)
+
+// claimTTL bounds how long a crashed instance's claim blocks a redelivery
+const claimTTL = 5 * time.Minute
+
+// historyRetention is how long received changes are kept for replay
+const historyRetention = 30 * 24 * time.Hour
+
+// Store remembers processed changes for the dedup window, the newest
+// change processed for each entity, and a history of received changes
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+	window time.Duration
+}
+
+// NewStore creates a new webhook dedup store. Changes are remembered for
+// window after they are processed.
+func NewStore(client redis.UniversalClient, prefix string, window time.Duration) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+		window: window,
+	}
+}
+
+// dedupKey marks a change as processing or done
+func (s *Store) dedupKey(key string) string {
+	return fmt.Sprintf("%s:qbwebhook:dedup:%s", s.prefix, key)
+}
+
+// cursorKey holds the last-updated time of the newest change processed for an entity
+func (s *Store) cursorKey(entityKey string) string {
+	return fmt.Sprintf("%s:qbwebhook:cursor:%s", s.prefix, entityKey)
+}
+
+// historyKey holds a realm's received changes scored by last-updated time
+func (s *Store) historyKey(realmID string) string {
+	return fmt.Sprintf("%s:qbwebhook:history:%s", s.prefix, realmID)
+}
+
+// Record adds a change to its realm's history and drops changes past retention
+func (s *Store) Record(ctx context.Context, change Change) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook change: %w", err)
+	}
+	key := s.historyKey(change.RealmID)
+	cutoff := time.Now().Add(-historyRetention)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, &redis.Z{Score: float64(unixMilli(change.LastUpdated)), Member: data})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%d", unixMilli(cutoff)))
+		pipe.Expire(ctx, key, historyRetention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record webhook change: %w", err)
+	}
+	return nil
+}
+
+// History returns up to limit of a realm's received changes last updated
+// between from and to, oldest first
+func (s *Store) History(ctx context.Context, realmID string, from, to time.Time, limit int64) ([]Change, error) {
+	members, err := s.client.ZRangeByScore(ctx, s.historyKey(realmID), &redis.ZRangeBy{
+		Min:   fmt.Sprint(unixMilli(from)),
+		Max:   fmt.Sprint(unixMilli(to)),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook history: %w", err)
+	}
+
+	changes := make([]Change, 0, len(members))
+	for _, member := range members {
+		var change Change
+		if err := json.Unmarshal([]byte(member), &change); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhook change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// unixMilli returns t in milliseconds since the epoch
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Claim marks a change as being processed; it returns false when the change
+// was already processed or is being processed by another instance
+func (s *Store) Claim(ctx context.Context, change Change) (bool, error) {
+	claimed, err := s.client.SetNX(ctx, s.dedupKey(change.key()), markerProcessing, claimTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook change: %w", err)
+	}
+	return claimed, nil
+}
+
+// Complete marks a claimed change as done and advances the entity's cursor
+func (s *Store) Complete(ctx context.Context, change Change) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.dedupKey(change.key()), markerDone, s.window)
+		if !change.LastUpdated.IsZero() {
+			pipe.Set(ctx, s.cursorKey(change.entityKey()), change.LastUpdated.UnixNano(), s.window)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete webhook change: %w", err)
+	}
+	return nil
+}
+
+// Abandon releases a claimed change that failed so a redelivery is processed
+func (s *Store) Abandon(ctx context.Context, change Change) error {
+	if err := s.client.Del(ctx, s.dedupKey(change.key())).Err(); err != nil {
+		return fmt.Errorf("failed to abandon webhook change: %w", err)
+	}
+	return nil
+}
+
+// Cursor returns the last-updated time of the newest change processed for
+// an entity, or the zero time
+func (s *Store) Cursor(ctx context.Context, change Change) (time.Time, error) {
+	nanos, err := s.client.Get(ctx, s.cursorKey(change.entityKey())).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get webhook cursor: %w", err)
+	}
+	return time.Unix(0, nanos), nil
+}
@@ -0,0 +1,479 @@
+// qrcode/qrcode.go
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Level is how much of a code can be damaged and still be read
+type Level int
+
+// Error correction levels
+const (
+	Low      Level = iota // 7% of codewords can be restored
+	Medium                // 15%
+	Quartile              // 25%
+	High                  // 30%
+)
+
+// QuietZone is the light border around codes, in modules
+const QuietZone = 4
+
+// ErrTooLong is returned for data that does not fit the largest code
+var ErrTooLong = errors.New("data is too long for a QR code")
+
+// formatBits are the levels' bits in the format information
+var formatBits = [4]int{1, 0, 3, 2}
+
+// eccPerBlock is the number of error correction codewords of each block,
+// by level then version
+var eccPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// eccBlocks is the number of error correction blocks, by level then
+// version
+var eccBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Code is an encoded QR code
+type Code struct {
+	// Size is the width and height in modules, excluding the quiet zone
+	Size     int
+	version  int
+	level    Level
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode encodes data in byte mode as the smallest code of the given level
+func Encode(data []byte, level Level) (*Code, error) {
+	version := 1
+	for ; version <= 40; version++ {
+		if 4+countBits(version)+8*len(data) <= dataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		return nil, ErrTooLong
+	}
+
+	var bits bitBuffer
+	bits.append(0x4, 4) // byte mode
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := dataCodewords(version, level) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	c := &Code{Size: version*4 + 17, version: version, level: level}
+	c.modules = grid(c.Size)
+	c.function = grid(c.Size)
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.interleave(bits.bytes()))
+
+	best, lowest := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penalty(); lowest < 0 || penalty < lowest {
+			best, lowest = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c, nil
+}
+
+// Dark reports whether the module at x, y is dark
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// Image renders the code with its quiet zone, each module scale pixels wide
+func (c *Code) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			top, left := (y+QuietZone)*scale, (x+QuietZone)*scale
+			for dy := 0; dy < scale; dy++ {
+				row := img.Pix[(top+dy)*img.Stride:]
+				for dx := 0; dx < scale; dx++ {
+					row[left+dx] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// PNG renders the code as a PNG image, each module scale pixels wide
+func (c *Code) PNG(scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.Image(scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// grid returns a size by size matrix
+func grid(size int) [][]bool {
+	rows := make([][]bool, size)
+	for i := range rows {
+		rows[i] = make([]bool, size)
+	}
+	return rows
+}
+
+// bitBuffer collects the data bits of a code
+type bitBuffer []bool
+
+// append appends the low length bits of value, most significant first
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+// bytes packs the bits into bytes
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, (len(b)+7)/8)
+	for i, bit := range b {
+		if bit {
+			result[i>>3] |= 0x80 >> uint(i&7)
+		}
+	}
+	return result
+}
+
+// countBits is the width of the byte mode character count
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// rawModules is the number of modules of a version available for data and
+// error correction, after the function patterns
+func rawModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		result -= (25*align-10)*align - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// dataCodewords is the number of data codewords of a version and level
+func dataCodewords(version int, level Level) int {
+	return rawModules(version)/8 - eccPerBlock[level][version]*eccBlocks[level][version]
+}
+
+// alignmentPositions returns the centre coordinates of the version's
+// alignment patterns along either axis
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*8 + count*3 + 5) / (count*4 - 4) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, version*4+10; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// set sets a function module
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFunctionPatterns draws the timing, finder, alignment and version
+// patterns and reserves the format information
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	for _, centre := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := centre[0]+dx, centre[1]+dy
+				if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+					continue
+				}
+				distance := max(abs(dx), abs(dy))
+				c.set(x, y, distance != 2 && distance != 4)
+			}
+		}
+	}
+
+	positions := alignmentPositions(c.version)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			// Alignment patterns would overlap the finders
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	c.drawFormatBits(0)
+	if c.version >= 7 {
+		remainder := c.version
+		for i := 0; i < 12; i++ {
+			remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+		}
+		bits := c.version<<12 | remainder
+		for i := 0; i < 18; i++ {
+			dark := (bits>>uint(i))&1 != 0
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the level and mask
+func (c *Code) drawFormatBits(mask int) {
+	data := formatBits[c.level]<<3 | mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool {
+		return (bits>>uint(i))&1 != 0
+	}
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// interleave splits data codewords into blocks, appends each block's error
+// correction and interleaves the blocks
+func (c *Code) interleave(data []byte) []byte {
+	blocks := eccBlocks[c.level][c.version]
+	eccLen := eccPerBlock[c.level][c.version]
+	raw := rawModules(c.version) / 8
+	short := blocks - raw%blocks
+	shortLen := raw / blocks
+	divisor := rsDivisor(eccLen)
+
+	split := make([][]byte, blocks)
+	for i, k := 0, 0; i < blocks; i++ {
+		length := shortLen - eccLen
+		if i >= short {
+			length++
+		}
+		block := append([]byte{}, data[k:k+length]...)
+		k += length
+		ecc := rsRemainder(block, divisor)
+		if i < short {
+			// Short blocks are padded to line up the error correction
+			block = append(block, 0)
+		}
+		split[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := 0; i < len(split[0]); i++ {
+		for j, block := range split {
+			if i != shortLen-eccLen || j >= short {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawCodewords places the codewords in the zigzag order, right to left
+// in two-module columns
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// The vertical timing pattern is skipped
+			right = 5
+		}
+		for vertical := 0; vertical < c.Size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vertical
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vertical
+				}
+				if !c.function[y][x] && i < len(data)*8 {
+					c.modules[y][x] = (data[i>>3]>>uint(7-i&7))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules the mask selects; applying it twice
+// undoes it
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.function[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// finderLike is the 1:1:3:1:1 pattern, with four light modules on one
+// side, that scanners could mistake for a finder
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores how hard the code is to scan; masks are chosen to
+// minimize it
+func (c *Code) penalty() int {
+	score := 0
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+		}
+	}
+
+	for _, transpose := range []bool{false, true} {
+		at := func(line, i int) bool {
+			if transpose {
+				return c.modules[i][line]
+			}
+			return c.modules[line][i]
+		}
+		for line := 0; line < c.Size; line++ {
+			run := 1
+			for i := 1; i <= c.Size; i++ {
+				if i < c.Size && at(line, i) == at(line, i-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+			for i := 0; i+11 <= c.Size; i++ {
+				for _, pattern := range finderLike {
+					matches := true
+					for k, want := range pattern {
+						if at(line, i+k) != want {
+							matches = false
+							break
+						}
+					}
+					if matches {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+
+	for y := 0; y < c.Size-1; y++ {
+		for x := 0; x < c.Size-1; x++ {
+			module := c.modules[y][x]
+			if module == c.modules[y][x+1] && module == c.modules[y+1][x] && module == c.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	score += ((abs(dark*20-total*10)+total-1)/total - 1) * 10
+	return score
+}
+
+// abs returns the absolute value of x
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
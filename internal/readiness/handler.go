@@ -0,0 +1,61 @@
+// readiness/handler.go
+package readiness
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler serves liveness and readiness probes
+type Handler struct {
+	tracker *Tracker
+}
+
+// NewHandler creates a new probe handler
+func NewHandler(tracker *Tracker) *Handler {
+	return &Handler{tracker: tracker}
+}
+
+// report is the body of both probes
+type report struct {
+	Status       string       `json:"status"`
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// Live answers 200 unless the server gave up on a critical dependency, in
+// which case restarting it is the remedy
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) {
+	if h.tracker.Failed() {
+		h.write(w, http.StatusServiceUnavailable, StateFailed)
+		return
+	}
+	h.write(w, http.StatusOK, "ok")
+}
+
+// Ready answers 200 once critical dependencies are connected or worked
+// around, and 503 while they warm up, so load balancers hold traffic back.
+// A worked-around dependency is reported as "degraded".
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case h.tracker.Failed():
+		h.write(w, http.StatusServiceUnavailable, StateFailed)
+	case !h.tracker.Ready():
+		h.write(w, http.StatusServiceUnavailable, StateStarting)
+	default:
+		state := StateReady
+		for _, dependency := range h.tracker.Dependencies() {
+			if dependency.State == StateDegraded {
+				state = StateDegraded
+			}
+		}
+		h.write(w, http.StatusOK, state)
+	}
+}
+
+// write sends a probe response with the state of every dependency
+func (h *Handler) write(w http.ResponseWriter, status int, state string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report{Status: state, Dependencies: h.tracker.Dependencies()})
+}
@@ -0,0 +1,123 @@
+// readiness/tracker.go
+package readiness
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// States of a dependency
+const (
+	// StateStarting is a dependency still connecting or warming up
+	StateStarting = "starting"
+	// StateReady is a connected dependency
+	StateReady = "ready"
+	// StateDegraded is an unavailable dependency the server works around,
+	// e.g. with a local fallback, while it keeps reconnecting
+	StateDegraded = "degraded"
+	// StateFailed is a dependency the server gave up connecting to
+	StateFailed = "failed"
+)
+
+// Dependency is the state of one dependency
+type Dependency struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	Since     time.Time `json:"since"`
+	Attempts  int       `json:"attempts,omitempty"` // connection attempts so far
+	Critical  bool      `json:"critical"`           // the server is not ready without it
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Tracker records the state of the server's dependencies as they connect,
+// so readiness can be reported while they warm up
+type Tracker struct {
+	mu           sync.RWMutex
+	dependencies map[string]*Dependency
+}
+
+// NewTracker creates a new tracker
+func NewTracker() *Tracker {
+	return &Tracker{dependencies: make(map[string]*Dependency)}
+}
+
+// Register adds a dependency in the starting state. The server is not ready
+// until critical dependencies are ready or degraded.
+func (t *Tracker) Register(name string, critical bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.dependencies[name] = &Dependency{Name: name, State: StateStarting, Critical: critical, Since: now, UpdatedAt: now}
+}
+
+// Set records a dependency's state and the error that caused it, if any
+func (t *Tracker) Set(name, state string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	dependency, ok := t.dependencies[name]
+	if !ok {
+		dependency = &Dependency{Name: name, Critical: true, Since: now}
+		t.dependencies[name] = dependency
+	}
+	if dependency.State != state {
+		dependency.State = state
+		dependency.Since = now
+	}
+	dependency.Error = ""
+	if err != nil {
+		dependency.Error = err.Error()
+	}
+	dependency.UpdatedAt = now
+}
+
+// Attempt counts a connection attempt of a dependency
+func (t *Tracker) Attempt(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if dependency, ok := t.dependencies[name]; ok {
+		dependency.Attempts++
+		dependency.UpdatedAt = time.Now()
+	}
+}
+
+// Dependencies returns the state of every dependency, by name
+func (t *Tracker) Dependencies() []Dependency {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	dependencies := make([]Dependency, 0, len(t.dependencies))
+	for _, dependency := range t.dependencies {
+		dependencies = append(dependencies, *dependency)
+	}
+	sort.Slice(dependencies, func(i, j int) bool {
+		return dependencies[i].Name < dependencies[j].Name
+	})
+	return dependencies
+}
+
+// Ready reports whether every critical dependency is ready or degraded
+func (t *Tracker) Ready() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, dependency := range t.dependencies {
+		if dependency.Critical && dependency.State != StateReady && dependency.State != StateDegraded {
+			return false
+		}
+	}
+	return true
+}
+
+// Failed reports whether the server gave up on a critical dependency, and
+// so should be restarted
+func (t *Tracker) Failed() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, dependency := range t.dependencies {
+		if dependency.Critical && dependency.State == StateFailed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+// readonly/drain.go
+package readonly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// drainLeaseTTL bounds how long one instance drains a tenant's queue
+	// before another may take over
+	drainLeaseTTL = 2 * time.Minute
+	// replayTimeout bounds each replayed write
+	replayTimeout = time.Minute
+)
+
+// drainLeaseKey is held by the instance draining a tenant's queue
+func (s *Switch) drainLeaseKey(tenantID string) string {
+	return fmt.Sprintf("%s:readonly:drain:%s", s.prefix, tenantID)
+}
+
+// StartDrain replays queued writes through handler, in order per tenant,
+// once read-only mode is off for the tenant. It runs until the context is
+// cancelled.
+func (s *Switch) StartDrain(ctx context.Context, handler http.Handler, pollInterval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.drainReady(ctx, handler)
+			}
+		}
+	}()
+}
+
+// drainReady drains the queues of every tenant no longer in read-only mode
+func (s *Switch) drainReady(ctx context.Context, handler http.Handler) {
+	tenants, err := s.client.SMembers(ctx, s.queuesKey()).Result()
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to list queued writes", "error", err)
+		return
+	}
+
+	for _, tenantID := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		acquired, err := s.client.SetNX(ctx, s.drainLeaseKey(tenantID), "1", drainLeaseTTL).Result()
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to lease write queue", "tenant_id", tenantID, "error", err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+		if err := s.drain(ctx, tenantID, handler); err != nil {
+			logging.FromContext(ctx).Warn("failed to drain write queue", "tenant_id", tenantID, "error", err)
+		}
+		s.client.Del(ctx, s.drainLeaseKey(tenantID))
+	}
+}
+
+// drain replays a tenant's queued writes until the queue is empty, the
+// mode is turned back on or the lease runs out
+func (s *Switch) drain(ctx context.Context, tenantID string, handler http.Handler) error {
+	deadline := time.Now().Add(drainLeaseTTL - replayTimeout)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		state, err := s.Status(ctx, tenantID)
+		if err != nil {
+			return err
+		}
+		if state.Enabled {
+			return nil
+		}
+
+		id, err := s.client.LIndex(ctx, s.queueKey(tenantID), 0).Result()
+		if err == redis.Nil {
+			return s.retireQueue(ctx, tenantID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read write queue: %w", err)
+		}
+
+		write, err := s.GetWrite(ctx, id)
+		if errors.Is(err, ErrQueuedWriteNotFound) {
+			s.client.LRem(ctx, s.queueKey(tenantID), 1, id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.replay(ctx, write, handler)
+		if err := s.saveWrite(ctx, write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retireQueue forgets an empty queue, unless a write arrived meanwhile
+func (s *Switch) retireQueue(ctx context.Context, tenantID string) error {
+	if err := s.client.SRem(ctx, s.queuesKey(), tenantID).Err(); err != nil {
+		return fmt.Errorf("failed to retire write queue: %w", err)
+	}
+	if n, err := s.client.LLen(ctx, s.queueKey(tenantID)).Result(); err == nil && n > 0 {
+		s.client.SAdd(ctx, s.queuesKey(), tenantID)
+	}
+	return nil
+}
+
+// replay serves a queued write as the identity it was queued with and
+// records the response
+func (s *Switch) replay(ctx context.Context, write *QueuedWrite, handler http.Handler) {
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+
+	ctx = auth.WithIdentity(ctx, write.UserID, write.TenantID, "")
+	ctx = context.WithValue(ctx, auth.RoleKey, write.Role)
+	recorder := &replayRecorder{header: make(http.Header), status: http.StatusOK}
+
+	req, err := http.NewRequestWithContext(ctx, write.Method, write.URI, bytes.NewReader(write.Body))
+	if err != nil {
+		recorder.status = http.StatusBadRequest
+		recorder.body.WriteString(err.Error())
+	} else {
+		req.Header = write.Header.Clone()
+		handler.ServeHTTP(recorder, req)
+	}
+
+	now := time.Now().UTC()
+	write.Status = StatusCompleted
+	write.CompletedAt = &now
+	write.ResponseStatus = recorder.status
+	write.Header, write.Body = nil, nil
+	if body := recorder.body.Bytes(); json.Valid(body) {
+		write.Response = body
+	} else {
+		write.ResponseText = string(body)
+	}
+	if write.ResponseStatus >= 400 {
+		logging.FromContext(ctx).Warn("queued write failed", "write_id", write.ID, "status", write.ResponseStatus)
+	}
+}
+
+// replayRecorder captures a replayed write's response
+type replayRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// Header returns the response headers
+func (r *replayRecorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader records the status code
+func (r *replayRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+}
+
+// Write records the body, up to maxResultBody
+func (r *replayRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	if room := maxResultBody - r.body.Len(); room > 0 {
+		r.body.Write(b[:min(len(b), room)])
+	}
+	return len(b), nil
+}
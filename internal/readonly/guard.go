@@ -0,0 +1,118 @@
+// readonly/guard.go
+package readonly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/dryrun"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// mutating reports whether a request method changes data
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// queuedResponse answers a queued write
+type queuedResponse struct {
+	*Receipt
+	Message string     `json:"message"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// QueuePath is where clients follow up on queued writes
+const QueuePath = "/api/read-only/queue/"
+
+// Middleware answers mutating requests with 503 while read-only mode is on
+// for the request's tenant, or queues them with 202 when the mode queues
+// writes. Reads and dry runs pass through.
+func (s *Switch) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mutating(r.Method) || dryrun.Requested(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		state, err := s.Status(r.Context(), auth.GetTenantID(r.Context()))
+		if err != nil {
+			http.Error(w, "Failed to check read-only mode: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !state.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !state.QueueWrites {
+			w.Header().Set("Retry-After", state.retryAfter())
+			http.Error(w, state.message(), http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxQueuedBody+1))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxQueuedBody {
+			w.Header().Set("Retry-After", state.retryAfter())
+			http.Error(w, state.message()+" (request too large to queue)", http.StatusServiceUnavailable)
+			return
+		}
+		write, err := s.Enqueue(r.Context(), r, body)
+		if err != nil {
+			http.Error(w, "Failed to queue write: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		receipt, err := s.Receipt(r.Context(), write)
+		if err != nil {
+			http.Error(w, "Failed to queue write: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Location", QueuePath+write.ID)
+		w.Header().Set("X-Queue-Position", strconv.FormatInt(receipt.Position, 10))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(queuedResponse{Receipt: receipt, Message: state.message(), Until: state.Until})
+	})
+}
+
+// writeStages are the transformer stages of writes
+var writeStages = map[string]bool{
+	qbclient.StageBeforeCreate: true,
+	qbclient.StageBeforeUpdate: true,
+	qbclient.StageBeforeDelete: true,
+	qbclient.StageBeforeVoid:   true,
+}
+
+// Transform is a qbclient.Transformer that blocks QuickBooks creates,
+// updates, deletes and voids while read-only mode is on, covering writes
+// made outside the API routes such as by the agent and background jobs
+func (s *Switch) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	if !writeStages[stage] {
+		return data, nil
+	}
+	if qbclient.IsDryRun(ctx) {
+		return data, nil
+	}
+
+	state, err := s.Status(ctx, auth.GetTenantID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if state.Enabled {
+		return nil, fmt.Errorf("%w: %s", ErrReadOnly, state.message())
+	}
+	return data, nil
+}
@@ -0,0 +1,179 @@
+// readonly/handler.go
+package readonly
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for read-only mode
+type Handler struct {
+	sw *Switch
+}
+
+// NewHandler creates a new read-only mode handler
+func NewHandler(sw *Switch) *Handler {
+	return &Handler{
+		sw: sw,
+	}
+}
+
+// writeState writes a read-only state as JSON
+func writeState(w http.ResponseWriter, state *State) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(state)
+}
+
+// GetStatus returns the mode in effect for the current tenant
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	state, err := h.sw.Status(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to get read-only mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeState(w, state)
+}
+
+// GetGlobal returns the global mode
+func (h *Handler) GetGlobal(w http.ResponseWriter, r *http.Request) {
+	state, err := h.sw.Global(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get read-only mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeState(w, state)
+}
+
+// SetGlobal turns the global mode on or off
+func (h *Handler) SetGlobal(w http.ResponseWriter, r *http.Request) {
+	var settings Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.sw.SetGlobal(r.Context(), settings)
+	if errors.Is(err, ErrForced) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set read-only mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeState(w, state)
+}
+
+// GetTenant returns a tenant's own mode
+func (h *Handler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	state, err := h.sw.Tenant(r.Context(), mux.Vars(r)["tenantID"])
+	if err != nil {
+		http.Error(w, "Failed to get read-only mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeState(w, state)
+}
+
+// SetTenant turns a tenant's mode on or off
+func (h *Handler) SetTenant(w http.ResponseWriter, r *http.Request) {
+	var settings Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.sw.SetTenant(r.Context(), mux.Vars(r)["tenantID"], settings)
+	if err != nil {
+		http.Error(w, "Failed to set read-only mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeState(w, state)
+}
+
+// GetQueuedWrite reports a write queued during read-only mode: its place in
+// the queue, or once replayed, its response
+func (h *Handler) GetQueuedWrite(w http.ResponseWriter, r *http.Request) {
+	write, err := h.sw.GetWrite(r.Context(), mux.Vars(r)["id"])
+	if err == nil && write.TenantID != auth.GetTenantID(r.Context()) {
+		err = ErrQueuedWriteNotFound
+	}
+	if errors.Is(err, ErrQueuedWriteNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get queued write: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	receipt, err := h.sw.Receipt(r.Context(), write)
+	if err != nil {
+		http.Error(w, "Failed to get queued write: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// ListWindows returns the maintenance windows that have not ended
+func (h *Handler) ListWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := h.sw.ListWindows(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list maintenance windows: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(windows)
+}
+
+// ScheduleWindow schedules a maintenance window
+func (h *Handler) ScheduleWindow(w http.ResponseWriter, r *http.Request) {
+	var window Window
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.sw.ScheduleWindow(r.Context(), &window)
+	if errors.Is(err, ErrInvalidWindow) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to schedule maintenance window: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(window)
+}
+
+// CancelWindow deletes a maintenance window, ending it if it is running
+func (h *Handler) CancelWindow(w http.ResponseWriter, r *http.Request) {
+	err := h.sw.CancelWindow(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, ErrWindowNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to cancel maintenance window: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,198 @@
+// readonly/queue.go
+package readonly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+// Queued write statuses
+const (
+	StatusQueued    = "queued"
+	StatusCompleted = "completed" // replayed; see the response status
+)
+
+const (
+	// maxQueuedBody is the largest request body that is queued
+	maxQueuedBody = 1 << 20
+	// maxResultBody is how much of a replayed response is kept
+	maxResultBody = 64 << 10
+	// completedTTL is how long replayed writes and their results are kept
+	completedTTL = 7 * 24 * time.Hour
+)
+
+// ErrQueuedWriteNotFound is returned for unknown queued writes
+var ErrQueuedWriteNotFound = errors.New("queued write not found")
+
+// secretHeaders are not persisted with queued writes; the write is replayed
+// as the identity it was queued with instead
+var secretHeaders = []string{"Authorization", "Cookie", "X-API-Key", "X-Admin-Key"}
+
+// QueuedWrite is a mutating request held during read-only mode and replayed,
+// in order per tenant, once the mode is off
+type QueuedWrite struct {
+	ID       string      `json:"id"`
+	TenantID string      `json:"tenant_id"`
+	UserID   string      `json:"user_id"`
+	Role     string      `json:"role"`
+	Seq      int64       `json:"seq"`
+	Method   string      `json:"method"`
+	URI      string      `json:"uri"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	Status   string      `json:"status"`
+	QueuedAt time.Time   `json:"queued_at"`
+
+	// Result of the replay
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	ResponseText   string          `json:"response_text,omitempty"` // non-JSON responses
+}
+
+// Receipt is what clients see of a queued write
+type Receipt struct {
+	ID       string    `json:"id"`
+	Status   string    `json:"status"`
+	Position int64     `json:"position,omitempty"` // 1 is next in line
+	QueuedAt time.Time `json:"queued_at"`
+
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	ResponseText   string          `json:"response_text,omitempty"`
+}
+
+// writeKey holds a queued write
+func (s *Switch) writeKey(id string) string {
+	return fmt.Sprintf("%s:readonly:write:%s", s.prefix, id)
+}
+
+// queueKey lists a tenant's queued write IDs in order
+func (s *Switch) queueKey(tenantID string) string {
+	return fmt.Sprintf("%s:readonly:queue:%s", s.prefix, tenantID)
+}
+
+// seqKey counts the writes ever queued for a tenant
+func (s *Switch) seqKey(tenantID string) string {
+	return fmt.Sprintf("%s:readonly:queue:%s:seq", s.prefix, tenantID)
+}
+
+// drainedKey holds the sequence number of a tenant's last replayed write
+func (s *Switch) drainedKey(tenantID string) string {
+	return fmt.Sprintf("%s:readonly:queue:%s:drained", s.prefix, tenantID)
+}
+
+// queuesKey is the set of tenants with queued writes
+func (s *Switch) queuesKey() string {
+	return fmt.Sprintf("%s:readonly:queues", s.prefix)
+}
+
+// Enqueue persists a request to replay once read-only mode is off
+func (s *Switch) Enqueue(ctx context.Context, r *http.Request, body []byte) (*QueuedWrite, error) {
+	tenantID := auth.GetTenantID(ctx)
+	seq, err := s.client.Incr(ctx, s.seqKey(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue write: %w", err)
+	}
+
+	header := r.Header.Clone()
+	for _, name := range secretHeaders {
+		header.Del(name)
+	}
+	write := &QueuedWrite{
+		ID:       jobs.NewID(),
+		TenantID: tenantID,
+		UserID:   auth.GetUserID(ctx),
+		Role:     auth.GetRole(ctx),
+		Seq:      seq,
+		Method:   r.Method,
+		URI:      r.URL.RequestURI(),
+		Header:   header,
+		Body:     body,
+		Status:   StatusQueued,
+		QueuedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(write)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal queued write: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.writeKey(write.ID), data, 0)
+		pipe.RPush(ctx, s.queueKey(tenantID), write.ID)
+		pipe.SAdd(ctx, s.queuesKey(), tenantID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue write: %w", err)
+	}
+	return write, nil
+}
+
+// GetWrite returns a queued write
+func (s *Switch) GetWrite(ctx context.Context, id string) (*QueuedWrite, error) {
+	data, err := s.client.Get(ctx, s.writeKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrQueuedWriteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queued write: %w", err)
+	}
+
+	var write QueuedWrite
+	if err := json.Unmarshal(data, &write); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queued write: %w", err)
+	}
+	return &write, nil
+}
+
+// saveWrite records a replayed write's result
+func (s *Switch) saveWrite(ctx context.Context, write *QueuedWrite) error {
+	data, err := json.Marshal(write)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued write: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.writeKey(write.ID), data, completedTTL)
+		pipe.LRem(ctx, s.queueKey(write.TenantID), 1, write.ID)
+		pipe.Set(ctx, s.drainedKey(write.TenantID), write.Seq, 0)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save queued write: %w", err)
+	}
+	return nil
+}
+
+// Receipt returns what a client sees of a queued write, including its
+// current place in the tenant's queue
+func (s *Switch) Receipt(ctx context.Context, write *QueuedWrite) (*Receipt, error) {
+	receipt := &Receipt{
+		ID:             write.ID,
+		Status:         write.Status,
+		QueuedAt:       write.QueuedAt,
+		CompletedAt:    write.CompletedAt,
+		ResponseStatus: write.ResponseStatus,
+		Response:       write.Response,
+		ResponseText:   write.ResponseText,
+	}
+	if write.Status != StatusQueued {
+		return receipt, nil
+	}
+
+	drained, err := s.client.Get(ctx, s.drainedKey(write.TenantID)).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get queue position: %w", err)
+	}
+	receipt.Position = max(write.Seq-drained, 1)
+	return receipt, nil
+}
@@ -0,0 +1,205 @@
+// readonly/switch.go
+package readonly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultMessage is returned for blocked writes when no message was given
+const DefaultMessage = "The service is in read-only mode for maintenance; please retry later"
+
+var (
+	// ErrReadOnly is returned for writes while read-only mode is on
+	ErrReadOnly = errors.New("read-only mode is on")
+	// ErrForced is returned when turning off a global mode forced on by
+	// configuration
+	ErrForced = errors.New("read-only mode is forced on by configuration")
+)
+
+// Settings turn read-only mode on or off
+type Settings struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// QueueWrites queues mutating requests to run once the mode is off
+	// instead of rejecting them
+	QueueWrites bool `json:"queue_writes,omitempty"`
+}
+
+// State is whether read-only mode is on, globally or for a tenant
+type State struct {
+	Enabled     bool      `json:"enabled"`
+	Message     string    `json:"message,omitempty"`
+	QueueWrites bool      `json:"queue_writes,omitempty"`
+	Since       time.Time `json:"since,omitempty"`
+	// Until is when a maintenance window ends
+	Until *time.Time `json:"until,omitempty"`
+	// Window is the maintenance window that turned the mode on
+	Window string `json:"window,omitempty"`
+	// Global is set when the mode comes from the global switch
+	Global bool `json:"global,omitempty"`
+}
+
+// message returns the message shown for blocked writes
+func (s *State) message() string {
+	if s.Message != "" {
+		return s.Message
+	}
+	return DefaultMessage
+}
+
+// retryAfter returns the Retry-After hint, in seconds, for blocked writes
+func (s *State) retryAfter() string {
+	seconds := 300
+	if s.Until != nil {
+		seconds = int(time.Until(*s.Until).Seconds()) + 1
+	}
+	return strconv.Itoa(max(seconds, 1))
+}
+
+// Switch turns read-only mode on and off, directly or in scheduled
+// maintenance windows. The global mode may also be forced on from
+// configuration, in which case it cannot be turned off at runtime.
+type Switch struct {
+	client redis.UniversalClient
+	prefix string
+	forced *State
+
+	mu      sync.Mutex
+	windows []*Window
+	loaded  time.Time
+}
+
+// NewSwitch creates a new read-only switch; enabled forces the global mode
+// on with message
+func NewSwitch(client redis.UniversalClient, prefix string, enabled bool, message string) *Switch {
+	s := &Switch{
+		client: client,
+		prefix: prefix,
+	}
+	if enabled {
+		s.forced = &State{Enabled: true, Message: message, Since: time.Now().UTC(), Global: true}
+	}
+	return s
+}
+
+// globalKey holds the global state
+func (s *Switch) globalKey() string {
+	return fmt.Sprintf("%s:readonly:global", s.prefix)
+}
+
+// tenantKey holds a tenant's state
+func (s *Switch) tenantKey(tenantID string) string {
+	return fmt.Sprintf("%s:readonly:tenant:%s", s.prefix, tenantID)
+}
+
+// Status returns the mode in effect for a tenant; the global mode and
+// global windows win
+func (s *Switch) Status(ctx context.Context, tenantID string) (*State, error) {
+	if s.forced != nil {
+		return s.forced, nil
+	}
+
+	values, err := s.client.MGet(ctx, s.globalKey(), s.tenantKey(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read-only mode: %w", err)
+	}
+	windows, err := s.activeWindows(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, scope := range []string{"", tenantID} {
+		if data, ok := values[i].(string); ok {
+			var state State
+			if err := json.Unmarshal([]byte(data), &state); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal read-only mode: %w", err)
+			}
+			state.Global = i == 0
+			return &state, nil
+		}
+		for _, window := range windows {
+			if window.TenantID == scope {
+				return window.state(), nil
+			}
+		}
+	}
+	return &State{}, nil
+}
+
+// Global returns the global mode
+func (s *Switch) Global(ctx context.Context) (*State, error) {
+	if s.forced != nil {
+		return s.forced, nil
+	}
+	return s.get(ctx, s.globalKey(), true)
+}
+
+// Tenant returns a tenant's own mode, regardless of the global mode
+func (s *Switch) Tenant(ctx context.Context, tenantID string) (*State, error) {
+	return s.get(ctx, s.tenantKey(tenantID), false)
+}
+
+// get reads a state, off when unset
+func (s *Switch) get(ctx context.Context, key string, global bool) (*State, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return &State{Global: global}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read-only mode: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal read-only mode: %w", err)
+	}
+	state.Global = global
+	return &state, nil
+}
+
+// SetGlobal turns the global mode on or off
+func (s *Switch) SetGlobal(ctx context.Context, settings Settings) (*State, error) {
+	if s.forced != nil {
+		return nil, ErrForced
+	}
+	return s.set(ctx, s.globalKey(), settings, true)
+}
+
+// SetTenant turns a tenant's mode on or off
+func (s *Switch) SetTenant(ctx context.Context, tenantID string, settings Settings) (*State, error) {
+	return s.set(ctx, s.tenantKey(tenantID), settings, false)
+}
+
+// set saves a state; turning the mode off deletes it
+func (s *Switch) set(ctx context.Context, key string, settings Settings, global bool) (*State, error) {
+	if !settings.Enabled {
+		if err := s.client.Del(ctx, key).Err(); err != nil {
+			return nil, fmt.Errorf("failed to turn off read-only mode: %w", err)
+		}
+		return &State{Global: global}, nil
+	}
+
+	state := &State{
+		Enabled:     true,
+		Message:     settings.Message,
+		QueueWrites: settings.QueueWrites,
+		Since:       time.Now().UTC(),
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal read-only mode: %w", err)
+	}
+	if err := s.client.Set(ctx, key, data, 0).Err(); err != nil {
+		return nil, fmt.Errorf("failed to turn on read-only mode: %w", err)
+	}
+	state.Global = global
+	return state, nil
+}
@@ -0,0 +1,169 @@
+// readonly/window.go
+package readonly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+)
+
+// windowCacheTTL is how long scheduled windows are cached between reads
+const windowCacheTTL = 10 * time.Second
+
+var (
+	// ErrInvalidWindow is returned for windows that do not end after they
+	// start or have already ended
+	ErrInvalidWindow = errors.New("maintenance window must end in the future and after it starts")
+	// ErrWindowNotFound is returned for unknown maintenance windows
+	ErrWindowNotFound = errors.New("maintenance window not found")
+)
+
+// Window is a scheduled maintenance window during which read-only mode is
+// on, for every tenant or for one
+type Window struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id,omitempty"` // empty for all tenants
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Message     string    `json:"message,omitempty"`
+	QueueWrites bool      `json:"queue_writes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate checks a window before it is scheduled
+func (w *Window) Validate() error {
+	if !w.End.After(w.Start) || !w.End.After(time.Now()) {
+		return ErrInvalidWindow
+	}
+	return nil
+}
+
+// active reports whether the window is in progress at now
+func (w *Window) active(now time.Time) bool {
+	return !now.Before(w.Start) && now.Before(w.End)
+}
+
+// state returns the read-only state the window puts in effect
+func (w *Window) state() *State {
+	end := w.End
+	return &State{
+		Enabled:     true,
+		Message:     w.Message,
+		QueueWrites: w.QueueWrites,
+		Since:       w.Start,
+		Until:       &end,
+		Window:      w.ID,
+		Global:      w.TenantID == "",
+	}
+}
+
+// windowsKey holds every scheduled window by ID
+func (s *Switch) windowsKey() string {
+	return fmt.Sprintf("%s:readonly:windows", s.prefix)
+}
+
+// ScheduleWindow schedules a maintenance window
+func (s *Switch) ScheduleWindow(ctx context.Context, window *Window) error {
+	if err := window.Validate(); err != nil {
+		return err
+	}
+	window.ID = jobs.NewID()
+	window.Start = window.Start.UTC()
+	window.End = window.End.UTC()
+	window.CreatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(window)
+	if err != nil {
+		return fmt.Errorf("failed to marshal maintenance window: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.windowsKey(), window.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save maintenance window: %w", err)
+	}
+	s.forgetWindows()
+	return nil
+}
+
+// CancelWindow deletes a scheduled or running maintenance window
+func (s *Switch) CancelWindow(ctx context.Context, id string) error {
+	deleted, err := s.client.HDel(ctx, s.windowsKey(), id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete maintenance window: %w", err)
+	}
+	if deleted == 0 {
+		return ErrWindowNotFound
+	}
+	s.forgetWindows()
+	return nil
+}
+
+// ListWindows returns the windows that have not ended, soonest first.
+// Ended windows are deleted.
+func (s *Switch) ListWindows(ctx context.Context) ([]*Window, error) {
+	values, err := s.client.HGetAll(ctx, s.windowsKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list maintenance windows: %w", err)
+	}
+
+	now := time.Now()
+	windows := make([]*Window, 0, len(values))
+	var ended []string
+	for id, data := range values {
+		var window Window
+		if err := json.Unmarshal([]byte(data), &window); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal maintenance window: %w", err)
+		}
+		if !now.Before(window.End) {
+			ended = append(ended, id)
+			continue
+		}
+		windows = append(windows, &window)
+	}
+	if len(ended) > 0 {
+		if err := s.client.HDel(ctx, s.windowsKey(), ended...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to delete ended maintenance windows: %w", err)
+		}
+	}
+
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Start.Before(windows[j].Start)
+	})
+	return windows, nil
+}
+
+// activeWindows returns the windows in progress, read through a short cache
+func (s *Switch) activeWindows(ctx context.Context) ([]*Window, error) {
+	s.mu.Lock()
+	windows, loaded := s.windows, s.loaded
+	s.mu.Unlock()
+
+	if time.Since(loaded) >= windowCacheTTL {
+		var err error
+		if windows, err = s.ListWindows(ctx); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.windows, s.loaded = windows, time.Now()
+		s.mu.Unlock()
+	}
+
+	now := time.Now()
+	active := make([]*Window, 0, len(windows))
+	for _, window := range windows {
+		if window.active(now) {
+			active = append(active, window)
+		}
+	}
+	return active, nil
+}
+
+// forgetWindows drops the cached windows after they were changed
+func (s *Switch) forgetWindows() {
+	s.mu.Lock()
+	s.loaded = time.Time{}
+	s.mu.Unlock()
+}
@@ -0,0 +1,665 @@
+// realmcopy/copier.go
+package realmcopy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// ErrSameRealm is returned when both users are connected to the same company
+var ErrSameRealm = errors.New("source and target are the same QuickBooks company")
+
+// Copy statuses
+const (
+	StatusValid   = "valid"   // dry run: would be created
+	StatusInvalid = "invalid" // references something missing from the target
+	StatusExists  = "exists"  // already in the target; left unchanged
+	StatusSkipped = "skipped" // record type is not copied
+	StatusCreated = "created"
+	StatusError   = "error" // rejected by QuickBooks
+)
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// QuickBooks is the subset of the QuickBooks client used for copying
+type QuickBooks interface {
+	Querier
+	Batch(ctx context.Context, items []qbclient.BatchItem) (map[string]qbclient.BatchResult, error)
+}
+
+// TokenSource resolves a user's QuickBooks connection
+type TokenSource interface {
+	GetValidToken(ctx context.Context, userID string) (*auth.OAuthToken, error)
+}
+
+// Options selects the companies to copy between and what to copy
+type Options struct {
+	SourceUserID string `json:"source_user_id"` // user whose company is copied from
+	TargetUserID string `json:"target_user_id"` // user whose company is copied into
+	Invoices     bool   `json:"invoices"`       // also copy invoices with a balance due
+	DryRun       bool   `json:"dry_run"`        // report the mapping without creating anything
+}
+
+// Validate checks the options
+func (o *Options) Validate() error {
+	switch {
+	case o.SourceUserID == "":
+		return fmt.Errorf("source_user_id is required")
+	case o.TargetUserID == "":
+		return fmt.Errorf("target_user_id is required")
+	}
+	return nil
+}
+
+// Result maps one source record to the target company
+type Result struct {
+	SourceID string   `json:"source_id"`
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	TargetID string   `json:"target_id,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"` // copied, but not exactly
+}
+
+// Report is the mapping from the source company to the target
+type Report struct {
+	SourceRealmID string                    `json:"source_realm_id"`
+	TargetRealmID string                    `json:"target_realm_id"`
+	DryRun        bool                      `json:"dry_run"`
+	Customers     []Result                  `json:"customers"`
+	Items         []Result                  `json:"items"`
+	Invoices      []Result                  `json:"invoices,omitempty"`
+	Counts        map[string]map[string]int `json:"counts"` // statuses per entity
+}
+
+// entity is a QuickBooks entity as returned by a query
+type entity map[string]interface{}
+
+// str returns a string field
+func (e entity) str(field string) string {
+	s, _ := e[field].(string)
+	return s
+}
+
+// fullName returns a list entity's hierarchical name
+func (e entity) fullName() string {
+	for _, field := range []string{"FullyQualifiedName", "DisplayName", "Name"} {
+		if name := e.str(field); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// ref returns the ID and name of a reference field
+func (e entity) ref(field string) (id, name string) {
+	ref, _ := e[field].(map[string]interface{})
+	id, _ = ref["value"].(string)
+	name, _ = ref["name"].(string)
+	return id, name
+}
+
+// named is a QuickBooks list entity with its hierarchical name
+type named struct {
+	ID                 string `json:"Id"`
+	Name               string `json:"Name"`
+	FullyQualifiedName string `json:"FullyQualifiedName"`
+}
+
+// numbered is a QuickBooks transaction with its document number
+type numbered struct {
+	ID        string `json:"Id"`
+	DocNumber string `json:"DocNumber"`
+}
+
+// Fields copied as they are. References to other records are remapped or
+// dropped, and read-only fields such as balances are left to the target.
+var (
+	customerFields = []string{
+		"Title", "GivenName", "MiddleName", "FamilyName", "Suffix", "DisplayName",
+		"CompanyName", "PrintOnCheckName", "Active", "PrimaryEmailAddr", "PrimaryPhone",
+		"Mobile", "AlternatePhone", "Fax", "WebAddr", "BillAddr", "ShipAddr", "Notes",
+		"Taxable", "Job", "BillWithParent", "PreferredDeliveryMethod", "ResaleNum", "CurrencyRef",
+	}
+	itemFields = []string{
+		"Name", "Sku", "Description", "Active", "Type", "UnitPrice", "PurchaseDesc",
+		"PurchaseCost", "Taxable", "SalesTaxIncluded", "PurchaseTaxIncluded",
+		"TrackQtyOnHand", "QtyOnHand", "SubItem",
+	}
+	invoiceFields = []string{
+		"DocNumber", "TxnDate", "DueDate", "CustomerMemo", "BillEmail", "BillAddr",
+		"ShipAddr", "ApplyTaxAfterDiscount", "CurrencyRef", "ExchangeRate",
+	}
+)
+
+// itemAccounts are the account references of items, with their labels
+var itemAccounts = []struct {
+	Field string
+	Label string
+}{
+	{"IncomeAccountRef", "income account"},
+	{"ExpenseAccountRef", "expense account"},
+	{"AssetAccountRef", "asset account"},
+}
+
+// Copier copies lists and open invoices between connected companies
+type Copier struct {
+	qb     QuickBooks
+	tokens TokenSource
+}
+
+// NewCopier creates a new realm copier
+func NewCopier(qb QuickBooks, tokens TokenSource) *Copier {
+	return &Copier{
+		qb:     qb,
+		tokens: tokens,
+	}
+}
+
+// mapping tracks where the source records of one entity are in the target
+type mapping struct {
+	ids     map[string]string // source ID to target ID
+	planned map[string]bool   // source IDs queued for creation
+}
+
+// newMapping creates an empty mapping
+func newMapping() *mapping {
+	return &mapping{
+		ids:     make(map[string]string),
+		planned: make(map[string]bool),
+	}
+}
+
+// known reports whether a source record is or will be in the target
+func (m *mapping) known(id string) (string, bool) {
+	return m.ids[id], m.ids[id] != "" || m.planned[id]
+}
+
+// target returns the target ID of a source record
+func (m *mapping) target(id string) (string, bool) {
+	return m.ids[id], m.ids[id] != ""
+}
+
+// pending is a source record awaiting creation in the target
+type pending struct {
+	result  *Result
+	depth   int
+	entity  string
+	payload func() (map[string]interface{}, error)
+}
+
+// Copy copies the source user's customers and items, and optionally their
+// invoices with a balance due, into the target user's company. Records are
+// matched by name (invoices by number) and existing ones are never
+// modified, so a copy can be repeated to pick up what failed or was added
+// since. Dry runs report the mapping without creating anything.
+func (c *Copier) Copy(ctx context.Context, tenantID string, opts Options) (*Report, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	sourceToken, err := c.tokens.GetValidToken(ctx, opts.SourceUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source QuickBooks connection: %w", err)
+	}
+	targetToken, err := c.tokens.GetValidToken(ctx, opts.TargetUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target QuickBooks connection: %w", err)
+	}
+	if sourceToken.RealmID == targetToken.RealmID {
+		return nil, ErrSameRealm
+	}
+	source := auth.WithIdentity(ctx, opts.SourceUserID, tenantID, sourceToken.RealmID)
+	target := auth.WithIdentity(ctx, opts.TargetUserID, tenantID, targetToken.RealmID)
+
+	// Inactive entities still reserve their names
+	sourceCustomers, err := query.All[entity](source, c.qb, query.Select("Customer").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	sourceItems, err := query.All[entity](source, c.qb, query.Select("Item").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	sourceAccounts, err := query.All[named](source, c.qb, query.Select("Account", "Id", "Name", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	targetCustomers, err := query.All[named](target, c.qb, query.Select("Customer", "Id", "DisplayName", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	targetItems, err := query.All[named](target, c.qb, query.Select("Item", "Id", "Name", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	targetAccounts, err := query.All[named](target, c.qb, query.Select("Account", "Id", "Name", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+
+	// Accounts are not copied; items use the target's account of the same name
+	accounts := make(map[string]string)
+	targetAccountIDs := index(targetAccounts)
+	for _, a := range sourceAccounts {
+		if id, ok := targetAccountIDs[key(fullName(a))]; ok {
+			accounts[a.ID] = id
+		}
+	}
+
+	report := &Report{
+		SourceRealmID: sourceToken.RealmID,
+		TargetRealmID: targetToken.RealmID,
+		DryRun:        opts.DryRun,
+		Counts:        make(map[string]map[string]int),
+	}
+
+	customers := newMapping()
+	report.Customers = make([]Result, len(sourceCustomers))
+	customerQueue := planList("Customer", sourceCustomers, report.Customers, index(targetCustomers), customers, customerPayload)
+
+	items := newMapping()
+	today := timezone.Today(target).Format("2006-01-02")
+	report.Items = make([]Result, len(sourceItems))
+	itemQueue := planList("Item", sourceItems, report.Items, index(targetItems), items, func(e entity, result *Result) map[string]interface{} {
+		return itemPayload(e, result, accounts, today)
+	})
+
+	var invoiceQueue []pending
+	if opts.Invoices {
+		sourceInvoices, err := query.All[entity](source, c.qb, query.Select("Invoice").Where("Balance", ">", "0"))
+		if err != nil {
+			return nil, err
+		}
+		targetInvoices, err := query.All[numbered](target, c.qb, query.Select("Invoice", "Id", "DocNumber"))
+		if err != nil {
+			return nil, err
+		}
+		numbers := make(map[string]string, len(targetInvoices))
+		for _, inv := range targetInvoices {
+			if inv.DocNumber != "" {
+				numbers[inv.DocNumber] = inv.ID
+			}
+		}
+		report.Invoices = make([]Result, len(sourceInvoices))
+		invoiceQueue = planInvoices(sourceInvoices, report.Invoices, numbers, sourceToken.RealmID, customers, items)
+	}
+
+	if !opts.DryRun {
+		operations.SetTotal(target, len(customerQueue)+len(itemQueue)+len(invoiceQueue))
+		for _, step := range []struct {
+			queue []pending
+			ids   map[string]string
+		}{
+			{customerQueue, customers.ids},
+			{itemQueue, items.ids},
+			{invoiceQueue, nil},
+		} {
+			if err := c.create(target, step.queue, step.ids); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for name, results := range map[string][]Result{"Customer": report.Customers, "Item": report.Items, "Invoice": report.Invoices} {
+		if len(results) == 0 {
+			continue
+		}
+		counts := make(map[string]int)
+		for _, r := range results {
+			counts[r.Status]++
+		}
+		report.Counts[name] = counts
+	}
+	return report, nil
+}
+
+// planList matches source list entities to the target by full name and
+// queues the rest for creation, parents before their children. build
+// returns the payload without its parent, or nil when the record is not
+// copied, recording why in the result.
+func planList(entityName string, sources []entity, results []Result, existing map[string]string, m *mapping, build func(e entity, result *Result) map[string]interface{}) []pending {
+	sort.SliceStable(sources, func(a, b int) bool {
+		return depth(sources[a].fullName()) < depth(sources[b].fullName())
+	})
+
+	var queue []pending
+	for n, e := range sources {
+		result := &results[n]
+		name := e.fullName()
+		result.SourceID, result.Name = e.str("Id"), name
+
+		if id, ok := existing[key(name)]; ok {
+			result.Status, result.TargetID = StatusExists, id
+			m.ids[result.SourceID] = id
+			continue
+		}
+		payload := build(e, result)
+		if result.Status == StatusSkipped {
+			continue
+		}
+		parentID, _ := e.ref("ParentRef")
+		parent, _ := splitName(name)
+		if _, ok := m.known(parentID); parentID != "" && !ok {
+			result.Errors = append(result.Errors, "parent "+parent+" is not copied")
+		}
+		if len(result.Errors) > 0 {
+			result.Status = StatusInvalid
+			continue
+		}
+		result.Status = StatusValid
+		m.planned[result.SourceID] = true
+
+		queue = append(queue, pending{
+			result: result,
+			depth:  depth(name),
+			entity: entityName,
+			payload: func() (map[string]interface{}, error) {
+				if parentID != "" {
+					id, ok := m.target(parentID)
+					if !ok {
+						return nil, fmt.Errorf("parent %s was not created", parent)
+					}
+					payload["ParentRef"] = map[string]string{"value": id}
+				}
+				return payload, nil
+			},
+		})
+	}
+	return queue
+}
+
+// customerPayload copies a customer. Terms, payment methods and tax codes
+// differ between companies and are left to the target's defaults.
+func customerPayload(e entity, result *Result) map[string]interface{} {
+	payload := pick(e, customerFields)
+	for _, field := range []string{"SalesTermRef", "PaymentMethodRef", "DefaultTaxCodeRef"} {
+		if _, name := e.ref(field); name != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s %s is not copied", strings.TrimSuffix(field, "Ref"), name))
+		}
+	}
+	return payload
+}
+
+// itemPayload copies an item, pointing it at the target's accounts of the
+// same name. Inventory items start with the source's quantity on hand as
+// of today.
+func itemPayload(e entity, result *Result, accounts map[string]string, today string) map[string]interface{} {
+	if e.str("Type") == "Group" {
+		result.Status = StatusSkipped
+		result.Errors = []string{"bundles are not copied"}
+		return nil
+	}
+
+	payload := pick(e, itemFields)
+	for _, account := range itemAccounts {
+		sourceID, name := e.ref(account.Field)
+		if sourceID == "" {
+			continue
+		}
+		id, ok := accounts[sourceID]
+		if !ok {
+			result.Errors = append(result.Errors, account.Label+" "+name+" does not exist in the target company")
+			continue
+		}
+		payload[account.Field] = map[string]string{"value": id}
+	}
+	if payload["TrackQtyOnHand"] == true {
+		payload["InvStartDate"] = today
+	}
+	return payload
+}
+
+// planInvoices matches source invoices to the target by number and queues
+// the rest for creation once their customers and items are copied
+func planInvoices(sources []entity, results []Result, existing map[string]string, sourceRealmID string, customers, items *mapping) []pending {
+	var queue []pending
+	for n, e := range sources {
+		result := &results[n]
+		number := e.str("DocNumber")
+		result.SourceID, result.Name = e.str("Id"), number
+
+		if id, ok := existing[number]; ok && number != "" {
+			result.Status, result.TargetID = StatusExists, id
+			continue
+		}
+		if _, errs := invoicePayload(e, sourceRealmID, customers.known, items.known); len(errs) > 0 {
+			result.Status, result.Errors = StatusInvalid, errs
+			continue
+		}
+		result.Status = StatusValid
+		if number == "" {
+			result.Warnings = append(result.Warnings, "invoice has no number, so a repeated copy creates it again")
+		}
+		total, _ := e["TotalAmt"].(float64)
+		if balance, _ := e["Balance"].(float64); balance < total {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("partially paid: copied for the full %.2f, of which %.2f was due", total, balance))
+		}
+
+		queue = append(queue, pending{
+			result: result,
+			entity: "Invoice",
+			payload: func() (map[string]interface{}, error) {
+				payload, errs := invoicePayload(e, sourceRealmID, customers.target, items.target)
+				if len(errs) > 0 {
+					return nil, errors.New(strings.Join(errs, "; "))
+				}
+				return payload, nil
+			},
+		})
+	}
+	return queue
+}
+
+// invoicePayload copies an invoice, looking up its customer and items in
+// the target. Subtotal lines are recalculated by QuickBooks and tax is
+// left to the target's tax settings.
+func invoicePayload(e entity, sourceRealmID string, customer, item func(id string) (string, bool)) (map[string]interface{}, []string) {
+	var errs []string
+	payload := pick(e, invoiceFields)
+
+	customerID, customerName := e.ref("CustomerRef")
+	if id, ok := customer(customerID); ok {
+		payload["CustomerRef"] = map[string]string{"value": id}
+	} else {
+		errs = append(errs, "customer "+customerName+" is not copied")
+	}
+
+	note := fmt.Sprintf("Copied from QuickBooks company %s, invoice %s", sourceRealmID, e.str("Id"))
+	if private := e.str("PrivateNote"); private != "" {
+		note = private + "\n" + note
+	}
+	payload["PrivateNote"] = note
+
+	raw, _ := e["Line"].([]interface{})
+	lines := make([]map[string]interface{}, 0, len(raw))
+	for _, l := range raw {
+		line := entity(asMap(l))
+		detailType := line.str("DetailType")
+		copied := map[string]interface{}{"DetailType": detailType}
+		for _, field := range []string{"Amount", "Description"} {
+			if value, ok := line[field]; ok {
+				copied[field] = value
+			}
+		}
+
+		switch detailType {
+		case "SubTotalLineDetail":
+			continue
+		case "DescriptionOnly":
+		case "SalesItemLineDetail":
+			source := entity(asMap(line[detailType]))
+			detail := pick(source, []string{"Qty", "UnitPrice", "ServiceDate"})
+			itemID, itemName := source.ref("ItemRef")
+			if id, ok := item(itemID); ok {
+				detail["ItemRef"] = map[string]string{"value": id}
+			} else {
+				errs = append(errs, "item "+itemName+" is not copied")
+			}
+			// TAX and NON are the same in every US company
+			if code, _ := source.ref("TaxCodeRef"); code == "TAX" || code == "NON" {
+				detail["TaxCodeRef"] = map[string]string{"value": code}
+			}
+			copied[detailType] = detail
+		case "DiscountLineDetail":
+			copied[detailType] = pick(entity(asMap(line[detailType])), []string{"PercentBased", "DiscountPercent"})
+		default:
+			errs = append(errs, detailType+" lines are not copied")
+			continue
+		}
+		lines = append(lines, copied)
+	}
+	payload["Line"] = lines
+	return payload, errs
+}
+
+// create submits queued records level by level in batches, recording each
+// created ID in ids so later records can reference it
+func (c *Copier) create(ctx context.Context, queue []pending, ids map[string]string) error {
+	for start := 0; start < len(queue); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// A batch never mixes levels, so every parent exists before its children
+		level := queue[start].depth
+		end := start
+		for end < len(queue) && end-start < qbclient.MaxBatchSize && queue[end].depth == level {
+			end++
+		}
+
+		var batch []qbclient.BatchItem
+		for n, p := range queue[start:end] {
+			payload, err := p.payload()
+			if err != nil {
+				fail(ctx, p, err.Error())
+				continue
+			}
+			batch = append(batch, qbclient.BatchItem{
+				ID:        strconv.Itoa(start + n),
+				Operation: "create",
+				Entity:    p.entity,
+				Payload:   payload,
+			})
+		}
+
+		if len(batch) > 0 {
+			results, err := c.qb.Batch(ctx, batch)
+			if err != nil {
+				return err
+			}
+			for _, item := range batch {
+				n, _ := strconv.Atoi(item.ID)
+				p := queue[n]
+				result, ok := results[item.ID]
+				switch {
+				case !ok:
+					fail(ctx, p, "no response from QuickBooks")
+				case len(result.Errors) > 0:
+					var messages []string
+					for _, e := range result.Errors {
+						messages = append(messages, strings.TrimSpace(e.Message+" "+e.Detail))
+					}
+					fail(ctx, p, strings.Join(messages, "; "))
+				default:
+					var created named
+					json.Unmarshal(result.Entity, &created)
+					p.result.Status = StatusCreated
+					p.result.TargetID = created.ID
+					if ids != nil {
+						ids[p.result.SourceID] = created.ID
+					}
+				}
+			}
+		}
+		operations.Add(ctx, end-start)
+		start = end
+	}
+	return nil
+}
+
+// fail records a record the target did not accept
+func fail(ctx context.Context, p pending, message string) {
+	p.result.Status = StatusError
+	p.result.Errors = append(p.result.Errors, message)
+	operations.Error(ctx, p.entity+" "+p.result.Name+": "+message)
+}
+
+// pick copies the given fields that are set, without the IDs QuickBooks
+// gives addresses and other nested records
+func pick(e entity, fields []string) map[string]interface{} {
+	payload := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, ok := e[field]
+		if !ok || value == nil {
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			copied := make(map[string]interface{}, len(nested))
+			for k, v := range nested {
+				if k != "Id" {
+					copied[k] = v
+				}
+			}
+			value = copied
+		}
+		payload[field] = value
+	}
+	return payload
+}
+
+// asMap returns a decoded JSON object, or nil
+func asMap(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
+
+// fullName returns a target list entity's hierarchical name
+func fullName(e named) string {
+	if e.FullyQualifiedName != "" {
+		return e.FullyQualifiedName
+	}
+	return e.Name
+}
+
+// index maps lowercased full names to IDs
+func index(entities []named) map[string]string {
+	ids := make(map[string]string, len(entities))
+	for _, e := range entities {
+		ids[key(fullName(e))] = e.ID
+	}
+	return ids
+}
+
+// key normalizes names for case-insensitive matching as QuickBooks does
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// splitName splits a full name into its parent's full name and its own
+func splitName(fullName string) (parent, name string) {
+	i := strings.LastIndex(fullName, ":")
+	if i < 0 {
+		return "", fullName
+	}
+	return fullName[:i], fullName[i+1:]
+}
+
+// depth returns how many levels a full name is nested
+func depth(fullName string) int {
+	return strings.Count(fullName, ":")
+}
@@ -0,0 +1,68 @@
+// realmcopy/handler.go
+package realmcopy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides operator endpoints for copying data between companies
+type Handler struct {
+	copier     *Copier
+	operations *operations.Manager
+}
+
+// NewHandler creates a new realm copy handler
+func NewHandler(copier *Copier, operations *operations.Manager) *Handler {
+	return &Handler{
+		copier:     copier,
+		operations: operations,
+	}
+}
+
+// Copy copies customers, items and optionally open invoices between two
+// users' connected companies and writes the mapping report. Asynchronous
+// requests are answered with an operation whose result is the report.
+func (h *Handler) Copy(w http.ResponseWriter, r *http.Request) {
+	var opts Options
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := mux.Vars(r)["tenantID"]
+	if operations.Async(r) {
+		op, err := h.operations.Start(r.Context(), tenantID, "realm_copy", func(ctx context.Context) (interface{}, error) {
+			return h.copier.Copy(ctx, tenantID, opts)
+		})
+		if err != nil {
+			http.Error(w, "Failed to start operation: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		operations.Accepted(w, r, op)
+		return
+	}
+
+	report, err := h.copier.Copy(r.Context(), tenantID, opts)
+	if err != nil {
+		if errors.Is(err, ErrSameRealm) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to copy company data: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
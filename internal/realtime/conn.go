@@ -0,0 +1,209 @@
+// realtime/conn.go
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Connection timing and limits
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingInterval   = 25 * time.Second // must be shorter than pongWait
+	maxMessageSize = 8 << 10
+	sendBuffer     = 64
+	// maxDropped closes a connection that has fallen this far behind, since
+	// its view of the data can no longer be trusted
+	maxDropped = 1000
+)
+
+// Client message types
+const (
+	msgSubscribe   = "subscribe"
+	msgUnsubscribe = "unsubscribe"
+	msgPing        = "ping"
+)
+
+// clientMessage is a message sent by the client
+type clientMessage struct {
+	Type         string        `json:"type"`
+	Subscription *Subscription `json:"subscription,omitempty"` // subscribe
+	ID           string        `json:"id,omitempty"`           // unsubscribe
+}
+
+// serverMessage is a message sent to the client
+type serverMessage struct {
+	Type          string   `json:"type"` // event, subscribed, unsubscribed, dropped, pong or error
+	Subscriptions []string `json:"subscriptions,omitempty"`
+	Event         *Event   `json:"event,omitempty"`
+	ID            string   `json:"id,omitempty"`
+	Count         int      `json:"count,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// conn is one client connection. Events are queued on a bounded buffer; a
+// client that reads too slowly loses events rather than slowing the hub,
+// and is told how many it missed.
+type conn struct {
+	ws      *websocket.Conn
+	realmID string
+	send    chan serverMessage
+	done    chan struct{}
+
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription
+	dropped       int
+	closing       bool
+}
+
+// newConn wraps an upgraded WebSocket
+func newConn(ws *websocket.Conn, realmID string) *conn {
+	return &conn{
+		ws:            ws,
+		realmID:       realmID,
+		send:          make(chan serverMessage, sendBuffer),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*Subscription),
+	}
+}
+
+// deliver queues an event for every subscription it matches
+func (c *conn) deliver(event *Event) {
+	c.mu.Lock()
+	var matched []string
+	for id, sub := range c.subscriptions {
+		if sub.matches(event) {
+			matched = append(matched, id)
+		}
+	}
+	c.mu.Unlock()
+	if len(matched) == 0 {
+		return
+	}
+	c.queue(serverMessage{Type: "event", Subscriptions: matched, Event: event})
+}
+
+// queue adds a message to the send buffer, counting it as dropped when full
+func (c *conn) queue(msg serverMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		c.dropped++
+		if c.dropped >= maxDropped {
+			c.closing = true
+			close(c.done)
+		}
+	}
+}
+
+// takeDropped returns and resets the count of dropped messages
+func (c *conn) takeDropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dropped := c.dropped
+	c.dropped = 0
+	return dropped
+}
+
+// readLoop handles client messages until the connection fails
+func (c *conn) readLoop() {
+	c.ws.SetReadLimit(maxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(pongWait))
+
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.queue(serverMessage{Type: "error", Error: "invalid message"})
+			continue
+		}
+		c.queue(c.handle(msg))
+	}
+}
+
+// handle applies a client message and returns the reply
+func (c *conn) handle(msg clientMessage) serverMessage {
+	switch msg.Type {
+	case msgSubscribe:
+		if msg.Subscription == nil {
+			return serverMessage{Type: "error", Error: "subscription is required"}
+		}
+		if err := msg.Subscription.validate(); err != nil {
+			return serverMessage{Type: "error", ID: msg.Subscription.ID, Error: err.Error()}
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, exists := c.subscriptions[msg.Subscription.ID]; !exists && len(c.subscriptions) >= maxSubscriptions {
+			return serverMessage{Type: "error", ID: msg.Subscription.ID, Error: "too many subscriptions"}
+		}
+		c.subscriptions[msg.Subscription.ID] = msg.Subscription
+		return serverMessage{Type: "subscribed", ID: msg.Subscription.ID}
+
+	case msgUnsubscribe:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subscriptions, msg.ID)
+		return serverMessage{Type: "unsubscribed", ID: msg.ID}
+
+	case msgPing:
+		return serverMessage{Type: "pong"}
+	}
+	return serverMessage{Type: "error", Error: "unknown message type"}
+}
+
+// writeLoop sends queued messages and pings until the connection fails,
+// the client falls too far behind, or closed is closed
+func (c *conn) writeLoop(closed <-chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-c.done:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			c.ws.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client is reading too slowly"))
+			return
+		case msg := <-c.send:
+			if dropped := c.takeDropped(); dropped > 0 {
+				if c.write(serverMessage{Type: "dropped", Count: dropped}) != nil {
+					return
+				}
+			}
+			if c.write(msg) != nil {
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// write sends one message
+func (c *conn) write(msg serverMessage) error {
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteJSON(msg)
+}
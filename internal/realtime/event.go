@@ -0,0 +1,111 @@
+// realtime/event.go
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event sources
+const (
+	SourceAPI        = "api"        // written through this server
+	SourceQuickBooks = "quickbooks" // changed in QuickBooks and reported by webhook
+)
+
+// Event is an entity change forwarded to connected clients
+type Event struct {
+	Type      string                 `json:"type"` // e.g. "invoice.updated"
+	RealmID   string                 `json:"realm_id"`
+	Entity    string                 `json:"entity"`
+	ID        string                 `json:"id"`
+	Operation string                 `json:"operation"` // created, updated, deleted, merged or voided
+	Source    string                 `json:"source"`
+	Data      map[string]interface{} `json:"data,omitempty"` // the entity, for changes written through this server
+	Time      time.Time              `json:"time"`
+}
+
+// Limits on client subscriptions
+const (
+	maxSubscriptions = 20
+	maxFilterValues  = 100
+)
+
+// Subscription selects the events a client receives. Empty lists match
+// everything. Fields filters compare dot-separated paths into the entity,
+// e.g. {"CustomerRef.value": "42"}; events without entity data, such as
+// webhook changes, never match a fields filter.
+type Subscription struct {
+	ID         string            `json:"id"`
+	Entities   []string          `json:"entities,omitempty"`
+	Operations []string          `json:"operations,omitempty"`
+	IDs        []string          `json:"ids,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+}
+
+// validate checks a subscription request
+func (s *Subscription) validate() error {
+	if s.ID == "" || len(s.ID) > 64 {
+		return fmt.Errorf("subscription id is required and at most 64 characters")
+	}
+	if len(s.Entities) > maxFilterValues || len(s.Operations) > maxFilterValues ||
+		len(s.IDs) > maxFilterValues || len(s.Fields) > maxFilterValues {
+		return fmt.Errorf("at most %d values per filter are allowed", maxFilterValues)
+	}
+	return nil
+}
+
+// matches reports whether the event passes every filter
+func (s *Subscription) matches(event *Event) bool {
+	if len(s.Entities) > 0 && !containsFold(s.Entities, event.Entity) {
+		return false
+	}
+	if len(s.Operations) > 0 && !containsFold(s.Operations, event.Operation) {
+		return false
+	}
+	if len(s.IDs) > 0 && !containsFold(s.IDs, event.ID) {
+		return false
+	}
+	for path, want := range s.Fields {
+		value, ok := lookup(event.Data, path)
+		if !ok || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup follows a dot-separated path into the entity
+func lookup(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, segment := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[segment]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// encode marshals an event for publishing
+func (e *Event) encode() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal realtime event: %w", err)
+	}
+	return data, nil
+}
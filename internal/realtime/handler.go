@@ -0,0 +1,59 @@
+// realtime/handler.go
+package realtime
+
+import (
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/gorilla/websocket"
+)
+
+// upgrader accepts WebSocket connections; the default origin check rejects
+// cross-site browser connections
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// Handler serves the realtime WebSocket endpoint
+type Handler struct {
+	hub *Hub
+}
+
+// NewHandler creates a new realtime handler
+func NewHandler(hub *Hub) *Handler {
+	return &Handler{
+		hub: hub,
+	}
+}
+
+// Connect upgrades the request to a WebSocket that receives the company's
+// entity changes. Clients send {"type":"subscribe","subscription":{...}} to
+// choose events, {"type":"unsubscribe","id":"..."} and {"type":"ping"}.
+func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		logging.FromContext(r.Context()).Warn("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer ws.Close()
+
+	c := newConn(ws, realmID)
+	h.hub.register(c)
+	defer h.hub.unregister(c)
+
+	closed := make(chan struct{})
+	go func() {
+		c.readLoop()
+		close(closed)
+	}()
+	c.writeLoop(closed)
+}
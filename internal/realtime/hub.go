@@ -0,0 +1,169 @@
+// realtime/hub.go
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/go-redis/redis/v8"
+)
+
+// webhookOperations maps QuickBooks webhook operations to event operations
+var webhookOperations = map[string]string{
+	"create": "created",
+	"update": "updated",
+	"delete": "deleted",
+	"merge":  "merged",
+	"void":   "voided",
+}
+
+// stageOperations maps QuickBooks client write stages to event operations
+var stageOperations = map[string]string{
+	qbclient.StageAfterCreate: "created",
+	qbclient.StageAfterUpdate: "updated",
+}
+
+// Hub forwards entity changes to connected clients. Changes are published
+// through Redis so every instance's clients see them, wherever the write
+// or webhook was handled.
+type Hub struct {
+	client redis.UniversalClient
+	prefix string
+
+	mu    sync.RWMutex
+	conns map[string]map[*conn]struct{} // by realm
+}
+
+// NewHub creates a new realtime hub
+func NewHub(client redis.UniversalClient, prefix string) *Hub {
+	return &Hub{
+		client: client,
+		prefix: prefix,
+		conns:  make(map[string]map[*conn]struct{}),
+	}
+}
+
+// channel is the Redis channel for a company's events
+func (h *Hub) channel(realmID string) string {
+	return fmt.Sprintf("%s:realtime:%s", h.prefix, realmID)
+}
+
+// Publish sends an event to the company's clients on every instance
+func (h *Hub) Publish(ctx context.Context, event *Event) error {
+	data, err := event.encode()
+	if err != nil {
+		return err
+	}
+	if err := h.client.Publish(ctx, h.channel(event.RealmID), data).Err(); err != nil {
+		return fmt.Errorf("failed to publish realtime event: %w", err)
+	}
+	return nil
+}
+
+// HandleChange publishes a QuickBooks webhook change; register it with the
+// webhook ingester for every entity
+func (h *Hub) HandleChange(ctx context.Context, change qbwebhook.Change) error {
+	operation := webhookOperations[strings.ToLower(change.Operation)]
+	if operation == "" {
+		operation = strings.ToLower(change.Operation)
+	}
+	return h.Publish(ctx, &Event{
+		Type:      strings.ToLower(change.Entity) + "." + operation,
+		RealmID:   change.RealmID,
+		Entity:    change.Entity,
+		ID:        change.ID,
+		Operation: operation,
+		Source:    SourceQuickBooks,
+		Time:      change.LastUpdated,
+	})
+}
+
+// Transform publishes creates and updates written through this server and
+// passes the entity through. Publishing is best effort: a client that
+// misses an event sees the change on its next read.
+func (h *Hub) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	operation, ok := stageOperations[stage]
+	realmID, err := auth.GetCompanyID(ctx)
+	if !ok || err != nil {
+		return data, nil
+	}
+
+	id, _ := data["Id"].(string)
+	event := &Event{
+		Type:      strings.ToLower(entity) + "." + operation,
+		RealmID:   realmID,
+		Entity:    entity,
+		ID:        id,
+		Operation: operation,
+		Source:    SourceAPI,
+		Data:      data,
+		Time:      time.Now().UTC(),
+	}
+	if err := h.Publish(ctx, event); err != nil {
+		logging.FromContext(ctx).Warn("failed to publish realtime event", "event", event.Type, "error", err)
+	}
+	return data, nil
+}
+
+// Start relays published events to this instance's clients until the
+// context is cancelled
+func (h *Hub) Start(ctx context.Context) {
+	pubsub := h.client.PSubscribe(ctx, h.channel("*"))
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event Event
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					logging.FromContext(ctx).Warn("invalid realtime event", "error", err)
+					continue
+				}
+				h.dispatch(&event)
+			}
+		}
+	}()
+}
+
+// dispatch hands an event to the company's connections
+func (h *Hub) dispatch(event *Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.conns[event.RealmID] {
+		c.deliver(event)
+	}
+}
+
+// register adds a connection
+func (h *Hub) register(c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[c.realmID] == nil {
+		h.conns[c.realmID] = make(map[*conn]struct{})
+	}
+	h.conns[c.realmID][c] = struct{}{}
+}
+
+// unregister removes a connection
+func (h *Hub) unregister(c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns[c.realmID], c)
+	if len(h.conns[c.realmID]) == 0 {
+		delete(h.conns, c.realmID)
+	}
+}
@@ -0,0 +1,69 @@
+// refs/handler.go
+package refs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Handler provides HTTP handlers for reference resolution
+type Handler struct {
+	resolver *Resolver
+}
+
+// NewHandler creates a new reference resolution handler
+func NewHandler(resolver *Resolver) *Handler {
+	return &Handler{
+		resolver: resolver,
+	}
+}
+
+// Resolve translates one reference by name or external ID into its
+// QuickBooks ID, the same way references in write payloads are resolved
+func (h *Handler) Resolve(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Entity     string `json:"entity"`
+		Name       string `json:"name"`
+		ExternalID string `json:"external_id"`
+		System     string `json:"system"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !Supported(body.Entity) {
+		http.Error(w, "Unsupported entity: "+body.Entity, http.StatusBadRequest)
+		return
+	}
+	if (body.Name == "") == (body.ExternalID == "") {
+		http.Error(w, "Exactly one of name or external_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var id string
+	var err error
+	if body.ExternalID != "" {
+		id, err = h.resolver.ResolveExternal(r.Context(), body.System, body.Entity, body.ExternalID)
+	} else {
+		id, err = h.resolver.ResolveName(r.Context(), body.Entity, body.Name)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrAmbiguous):
+			status = http.StatusConflict
+		}
+		http.Error(w, "Failed to resolve reference: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entity": body.Entity,
+		"id":     id,
+	})
+}
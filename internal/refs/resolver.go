@@ -0,0 +1,149 @@
+// refs/resolver.go
+package refs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrNotFound is returned when no record has the referenced name
+	ErrNotFound = errors.New("reference not found")
+	// ErrAmbiguous is returned when several records share the referenced name
+	ErrAmbiguous = errors.New("reference is ambiguous")
+)
+
+// cacheTTL is how long a resolved name is remembered
+const cacheTTL = 10 * time.Minute
+
+// nameFields are the fields each referenceable entity is named by
+var nameFields = map[string]string{
+	"Customer":      "DisplayName",
+	"Vendor":        "DisplayName",
+	"Employee":      "DisplayName",
+	"Item":          "Name",
+	"Account":       "Name",
+	"Class":         "Name",
+	"Department":    "Name",
+	"Term":          "Name",
+	"TaxCode":       "Name",
+	"PaymentMethod": "Name",
+}
+
+// Querier runs QuickBooks queries
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// ExternalIDs translates other systems' IDs into QuickBooks IDs. It returns
+// an empty ID when an external ID is not mapped.
+type ExternalIDs interface {
+	QuickBooksID(ctx context.Context, system, entity, externalID string) (string, error)
+}
+
+// Resolver translates references by name or external ID into QuickBooks
+// IDs. Resolved names are cached per realm.
+type Resolver struct {
+	client    redis.UniversalClient
+	prefix    string
+	querier   Querier
+	externals ExternalIDs
+}
+
+// NewResolver creates a new reference resolver. externals may be nil, in
+// which case references by external ID are rejected.
+func NewResolver(client redis.UniversalClient, prefix string, querier Querier, externals ExternalIDs) *Resolver {
+	return &Resolver{
+		client:    client,
+		prefix:    prefix,
+		querier:   querier,
+		externals: externals,
+	}
+}
+
+// key caches a resolved name
+func (r *Resolver) key(realmID, entity, name string) string {
+	return fmt.Sprintf("%s:refs:%s:%s:%s", r.prefix, realmID, strings.ToLower(entity), strings.ToLower(name))
+}
+
+// Supported reports whether an entity can be referenced by name
+func Supported(entity string) bool {
+	_, ok := nameFields[entity]
+	return ok
+}
+
+// ResolveName returns the ID of the entity with the given name. Items and
+// accounts may also be named by their full "Parent:Child" name, which tells
+// apart sub-items and sub-accounts sharing a name.
+func (r *Resolver) ResolveName(ctx context.Context, entity, name string) (string, error) {
+	field, ok := nameFields[entity]
+	if !ok {
+		return "", fmt.Errorf("%s cannot be referenced by name", entity)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: empty %s name", ErrNotFound, entity)
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	key := r.key(realmID, entity, name)
+	if id, err := r.client.Get(ctx, key).Result(); err == nil {
+		return id, nil
+	} else if err != redis.Nil {
+		return "", fmt.Errorf("failed to read reference cache: %w", err)
+	}
+
+	if strings.Contains(name, ":") && field == "Name" {
+		field = "FullyQualifiedName"
+	}
+	matches, err := query.List[struct {
+		ID                 string `json:"Id"`
+		FullyQualifiedName string `json:"FullyQualifiedName"`
+	}](ctx, r.querier, query.Select(entity).Where(field, "=", name).WhereIn("Active", true, false).Limit(10))
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s %q: %w", entity, name, err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: no %s named %q", ErrNotFound, entity, name)
+	case 1:
+	default:
+		names := make([]string, 0, len(matches))
+		for _, m := range matches {
+			names = append(names, m.FullyQualifiedName)
+		}
+		return "", fmt.Errorf("%w: %d %s records named %q (%s); use the full name", ErrAmbiguous, len(matches), entity, name, strings.Join(names, ", "))
+	}
+
+	id := matches[0].ID
+	if err := r.client.Set(ctx, key, id, cacheTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to cache reference: %w", err)
+	}
+	return id, nil
+}
+
+// ResolveExternal returns the QuickBooks ID mapped to another system's ID
+func (r *Resolver) ResolveExternal(ctx context.Context, system, entity, externalID string) (string, error) {
+	if r.externals == nil {
+		return "", fmt.Errorf("references by external ID are not available")
+	}
+	id, err := r.externals.QuickBooksID(ctx, system, entity, externalID)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("%w: no %s mapped to %s ID %q", ErrNotFound, entity, system, externalID)
+	}
+	return id, nil
+}
@@ -0,0 +1,103 @@
+// refs/transform.go
+package refs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// refEntities maps reference fields to the entity they point at
+var refEntities = map[string]string{
+	"CustomerRef":         "Customer",
+	"VendorRef":           "Vendor",
+	"EmployeeRef":         "Employee",
+	"ItemRef":             "Item",
+	"AccountRef":          "Account",
+	"ExpenseAccountRef":   "Account",
+	"IncomeAccountRef":    "Account",
+	"AssetAccountRef":     "Account",
+	"DepositToAccountRef": "Account",
+	"APAccountRef":        "Account",
+	"ARAccountRef":        "Account",
+	"FromAccountRef":      "Account",
+	"ToAccountRef":        "Account",
+	"ClassRef":            "Class",
+	"DepartmentRef":       "Department",
+	"SalesTermRef":        "Term",
+	"TaxCodeRef":          "TaxCode",
+	"PaymentMethodRef":    "PaymentMethod",
+}
+
+// RefEntity returns the entity a reference field points at
+func RefEntity(field string) (string, bool) {
+	entity, ok := refEntities[field]
+	return entity, ok
+}
+
+// Transform resolves references in payloads about to be written. A
+// reference without a value is resolved by its "name", or by
+// "external_id" and "system"; references with a value pass through.
+func (r *Resolver) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	if stage != qbclient.StageBeforeCreate && stage != qbclient.StageBeforeUpdate {
+		return data, nil
+	}
+	if err := r.walk(ctx, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// walk resolves every reference in a payload in place
+func (r *Resolver) walk(ctx context.Context, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if target, ok := refEntities[key]; ok {
+				if ref, ok := field.(map[string]interface{}); ok {
+					if err := r.resolveRef(ctx, key, target, ref); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if err := r.walk(ctx, field); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			if err := r.walk(ctx, elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveRef fills in a reference's value
+func (r *Resolver) resolveRef(ctx context.Context, field, entity string, ref map[string]interface{}) error {
+	if value, _ := ref["value"].(string); value != "" {
+		return nil
+	}
+
+	var id string
+	var err error
+	if externalID, _ := ref["external_id"].(string); externalID != "" {
+		system, _ := ref["system"].(string)
+		id, err = r.ResolveExternal(ctx, system, entity, externalID)
+		delete(ref, "external_id")
+		delete(ref, "system")
+	} else if name, _ := ref["name"].(string); strings.TrimSpace(name) != "" {
+		id, err = r.ResolveName(ctx, entity, name)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	ref["value"] = id
+	return nil
+}
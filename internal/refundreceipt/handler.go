@@ -0,0 +1,113 @@
+// refundreceipt/handler.go
+package refundreceipt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Servicer is the refund receipt service the handler serves. *Service implements
+// it; tests can substitute a fake.
+type Servicer interface {
+	List(ctx context.Context, opts ListOptions) ([]RefundReceipt, error)
+	Get(ctx context.Context, id string) (*RefundReceipt, error)
+	Create(ctx context.Context, receipt *RefundReceipt) (*RefundReceipt, error)
+}
+
+// Handler provides HTTP handlers for refund receipts
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new refund receipt handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidRefundReceipt):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// listOptions parses the list query: ?customer_id= filters the list, and
+// ?limit= and ?offset= page it
+func listOptions(r *http.Request) (ListOptions, error) {
+	query := r.URL.Query()
+	opts := ListOptions{CustomerID: query.Get("customer_id"), Limit: defaultLimit}
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil || opts.Limit < 1 {
+			return opts, errors.New("limit must be a positive number")
+		}
+		if opts.Limit > maxLimit {
+			opts.Limit = maxLimit
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil || opts.Offset < 0 {
+			return opts, errors.New("offset must not be negative")
+		}
+	}
+	return opts, nil
+}
+
+// ListRefundReceipts lists refund receipts, newest first
+func (h *Handler) ListRefundReceipts(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	receipts, err := h.service.List(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Failed to list refund receipts: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(receipts)
+}
+
+// GetRefundReceipt returns a refund receipt
+func (h *Handler) GetRefundReceipt(w http.ResponseWriter, r *http.Request) {
+	receipt, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get refund receipt: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// CreateRefundReceipt records a refund to a customer
+func (h *Handler) CreateRefundReceipt(w http.ResponseWriter, r *http.Request) {
+	var receipt RefundReceipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &receipt)
+	if err != nil {
+		http.Error(w, "Failed to create refund receipt: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
@@ -0,0 +1,82 @@
+// refundreceipt/models.go
+package refundreceipt
+
+import "github.com/eGGnogSC/qbserver/internal/money"
+
+// Ref references another QuickBooks entity
+type Ref struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+// EmailAddress is a QuickBooks email address
+type EmailAddress struct {
+	Address string `json:"Address,omitempty"`
+}
+
+// Memo is a QuickBooks message field
+type Memo struct {
+	Value string `json:"value"`
+}
+
+// MetaData holds QuickBooks record timestamps
+type MetaData struct {
+	CreateTime      string `json:"CreateTime,omitempty"`
+	LastUpdatedTime string `json:"LastUpdatedTime,omitempty"`
+}
+
+// SalesItemLineDetail is the item, quantity and price of a line
+type SalesItemLineDetail struct {
+	ItemRef     *Ref          `json:"ItemRef,omitempty"`
+	Qty         *money.Amount `json:"Qty,omitempty"`
+	UnitPrice   *money.Amount `json:"UnitPrice,omitempty"`
+	TaxCodeRef  *Ref          `json:"TaxCodeRef,omitempty"`
+	ClassRef    *Ref          `json:"ClassRef,omitempty"`
+	ServiceDate string        `json:"ServiceDate,omitempty"`
+}
+
+// Line is a refund receipt line; DetailType names which detail is set
+type Line struct {
+	ID                  string               `json:"Id,omitempty"`
+	LineNum             int                  `json:"LineNum,omitempty"`
+	Description         string               `json:"Description,omitempty"`
+	Amount              money.Amount         `json:"Amount"`
+	DetailType          string               `json:"DetailType"`
+	SalesItemLineDetail *SalesItemLineDetail `json:"SalesItemLineDetail,omitempty"`
+	SubTotalLineDetail  *struct{}            `json:"SubTotalLineDetail,omitempty"`
+}
+
+// TxnTaxDetail is the tax code and computed tax of a transaction
+type TxnTaxDetail struct {
+	TxnTaxCodeRef *Ref          `json:"TxnTaxCodeRef,omitempty"`
+	TotalTax      *money.Amount `json:"TotalTax,omitempty"`
+}
+
+// RefundReceipt is a QuickBooks refund receipt, money paid back to a
+// customer from DepositToAccountRef, in QuickBooks' field names
+type RefundReceipt struct {
+	ID                  string        `json:"Id,omitempty"`
+	SyncToken           string        `json:"SyncToken,omitempty"`
+	DocNumber           string        `json:"DocNumber,omitempty"`
+	TxnDate             string        `json:"TxnDate,omitempty"`
+	CustomerRef         *Ref          `json:"CustomerRef,omitempty"`
+	BillEmail           *EmailAddress `json:"BillEmail,omitempty"`
+	CustomerMemo        *Memo         `json:"CustomerMemo,omitempty"`
+	PrivateNote         string        `json:"PrivateNote,omitempty"`
+	Line                []Line        `json:"Line"`
+	TxnTaxDetail        *TxnTaxDetail `json:"TxnTaxDetail,omitempty"`
+	DepositToAccountRef *Ref          `json:"DepositToAccountRef,omitempty"` // the account the refund is paid from
+	PaymentMethodRef    *Ref          `json:"PaymentMethodRef,omitempty"`
+	PaymentRefNum       string        `json:"PaymentRefNum,omitempty"`
+	CurrencyRef         *Ref          `json:"CurrencyRef,omitempty"`
+	ClassRef            *Ref          `json:"ClassRef,omitempty"`
+	TotalAmt            money.Amount  `json:"TotalAmt,omitempty"`
+	MetaData            *MetaData     `json:"MetaData,omitempty"`
+}
+
+// ListOptions filters and pages refund receipt lists
+type ListOptions struct {
+	CustomerID string
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,131 @@
+// refundreceipt/service.go
+package refundreceipt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+var (
+	// ErrNotFound is returned for refund receipt IDs QuickBooks does not know
+	ErrNotFound = errors.New("refund receipt not found")
+	// ErrInvalidRefundReceipt is returned for refund receipts without a
+	// customer, an account to pay from or lines
+	ErrInvalidRefundReceipt = errors.New("refund receipt requires a CustomerRef, a DepositToAccountRef and at least one line")
+)
+
+// defaultLimit and maxLimit bound refund receipt list pages
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// readOnlyFields are computed by QuickBooks and left out of new refund receipts
+var readOnlyFields = []string{"Id", "SyncToken", "TotalAmt", "MetaData"}
+
+// QuickBooks is the subset of the QuickBooks client used for refund receipts
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// Service manages QuickBooks refund receipts
+type Service struct {
+	qb QuickBooks
+}
+
+// NewService creates a new refund receipt service
+func NewService(qb QuickBooks) *Service {
+	return &Service{
+		qb: qb,
+	}
+}
+
+// List returns a page of refund receipts, newest first, optionally only a
+// customer's
+func (s *Service) List(ctx context.Context, opts ListOptions) ([]RefundReceipt, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	q := query.Select("RefundReceipt")
+	if opts.CustomerID != "" {
+		if !query.IsID(opts.CustomerID) {
+			return []RefundReceipt{}, nil
+		}
+		q.Where("CustomerRef", "=", opts.CustomerID)
+	}
+	q.OrderByDesc("TxnDate").Offset(opts.Offset).Limit(opts.Limit)
+
+	receipts, err := query.List[RefundReceipt](ctx, s.qb, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refund receipts: %w", err)
+	}
+	return receipts, nil
+}
+
+// Get returns a refund receipt by ID
+func (s *Service) Get(ctx context.Context, id string) (*RefundReceipt, error) {
+	fields, err := s.qb.Read(ctx, "RefundReceipt", id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var receipt RefundReceipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return nil, fmt.Errorf("failed to decode refund receipt: %w", err)
+	}
+	return &receipt, nil
+}
+
+// Create records a refund paid to a customer
+func (s *Service) Create(ctx context.Context, receipt *RefundReceipt) (*RefundReceipt, error) {
+	if receipt.CustomerRef == nil || receipt.CustomerRef.Value == "" ||
+		receipt.DepositToAccountRef == nil || receipt.DepositToAccountRef.Value == "" || len(receipt.Line) == 0 {
+		return nil, ErrInvalidRefundReceipt
+	}
+
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	for _, name := range readOnlyFields {
+		delete(payload, name)
+	}
+
+	var created struct {
+		RefundReceipt RefundReceipt `json:"RefundReceipt"`
+	}
+	if err := s.qb.Create(ctx, "RefundReceipt", payload, &created); err != nil {
+		return nil, err
+	}
+	return &created.RefundReceipt, nil
+}
+
+// notFound maps QuickBooks' "Object Not Found" fault (code 610) to
+// ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
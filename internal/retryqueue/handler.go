@@ -0,0 +1,116 @@
+// retryqueue/handler.go
+package retryqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for queued writes
+type Handler struct {
+	queue *Queue
+}
+
+// NewHandler creates a new retry queue handler
+func NewHandler(queue *Queue) *Handler {
+	return &Handler{
+		queue: queue,
+	}
+}
+
+// writeReceipt writes a queued write's receipt as JSON
+func writeReceipt(w http.ResponseWriter, write *Write) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(write.Receipt())
+}
+
+// GetWrite reports one of the current tenant's queued writes: its attempts
+// so far, or once it got through, its response
+func (h *Handler) GetWrite(w http.ResponseWriter, r *http.Request) {
+	write, err := h.queue.Get(r.Context(), mux.Vars(r)["id"])
+	if err == nil && write.TenantID != auth.GetTenantID(r.Context()) {
+		err = ErrWriteNotFound
+	}
+	if errors.Is(err, ErrWriteNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get queued write: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeReceipt(w, write)
+}
+
+// ListWrites returns the writes still queued or left failed, optionally
+// only a ?tenant_id= or those with a ?status= of pending or failed
+func (h *Handler) ListWrites(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != StatusPending && status != StatusFailed {
+		http.Error(w, "Invalid status, expected pending or failed", http.StatusBadRequest)
+		return
+	}
+
+	receipts, err := h.queue.List(r.Context(), r.URL.Query().Get("tenant_id"), status)
+	if err != nil {
+		http.Error(w, "Failed to list queued writes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"writes": receipts,
+	})
+}
+
+// GetAnyWrite reports a queued write of any tenant
+func (h *Handler) GetAnyWrite(w http.ResponseWriter, r *http.Request) {
+	write, err := h.queue.Get(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, ErrWriteNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get queued write: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeReceipt(w, write)
+}
+
+// RetryWrite replays a pending or failed write at the next poll
+func (h *Handler) RetryWrite(w http.ResponseWriter, r *http.Request) {
+	write, err := h.queue.RetryNow(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, ErrWriteNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retry queued write: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	writeReceipt(w, write)
+}
+
+// DiscardWrite drops a pending or failed write without replaying it
+func (h *Handler) DiscardWrite(w http.ResponseWriter, r *http.Request) {
+	write, err := h.queue.Discard(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, ErrWriteNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to discard queued write: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	writeReceipt(w, write)
+}
@@ -0,0 +1,154 @@
+// retryqueue/middleware.go
+package retryqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/dryrun"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// QueuePath is where clients follow up on queued writes
+const QueuePath = "/api/retry-queue/"
+
+// contextKey is the type of the package's context keys
+type contextKey string
+
+// replayKey marks requests replayed from the queue
+const replayKey contextKey = "retryqueue_replay"
+
+// mutating reports whether a request method changes data
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// Requested reports whether the client opted into queueing with a
+// "Prefer: queue-on-failure" header or ?queue_on_failure=true
+func Requested(r *http.Request) bool {
+	if r.URL.Query().Get("queue_on_failure") == "true" {
+		return true
+	}
+	for _, value := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "queue-on-failure") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaying reports whether a request is a replay from the queue
+func replaying(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayKey).(bool)
+	return replay
+}
+
+// queuedResponse answers a queued write
+type queuedResponse struct {
+	*Receipt
+	Message string `json:"message"`
+}
+
+// Middleware queues writes that opted in and failed on a transient
+// QuickBooks error after the client's own retries, answering 202 instead of
+// the failure. Writes that failed otherwise, or after part of them reached
+// QuickBooks, are answered as they are, since repeating them is not safe.
+func (q *Queue) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mutating(r.Method) || dryrun.Requested(r) || replaying(r.Context()) || !Requested(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxQueuedBody+1))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxQueuedBody {
+			// Too large to queue; serve it without
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx, watch := qbclient.WithWriteWatch(r.Context(), "")
+		recorder := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		requestID, retryable := watch.Retryable()
+		if !retryable || recorder.status < http.StatusInternalServerError {
+			recorder.flush(w)
+			return
+		}
+		write, err := q.Enqueue(ctx, r, body, requestID, strings.TrimSpace(recorder.body.String()))
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to queue write for retry", "error", err)
+			recorder.flush(w)
+			return
+		}
+
+		w.Header().Set("Location", QueuePath+write.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(queuedResponse{
+			Receipt: write.Receipt(),
+			Message: "QuickBooks is unavailable; the write was queued and will be retried",
+		})
+	})
+}
+
+// bufferedResponse holds a response until it is known whether the write is
+// queued instead. limit caps the body kept, 0 keeps all of it.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int
+}
+
+// Header returns the response headers
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the status code
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status, b.wroteHeader = status, true
+	}
+}
+
+// Write records the body, up to the limit
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	if b.limit == 0 {
+		return b.body.Write(p)
+	}
+	if room := b.limit - b.body.Len(); room > 0 {
+		b.body.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// flush sends the buffered response
+func (b *bufferedResponse) flush(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
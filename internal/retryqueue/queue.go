@@ -0,0 +1,340 @@
+// retryqueue/queue.go
+package retryqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+// Queued write statuses
+const (
+	StatusPending   = "pending"   // waiting for its next attempt
+	StatusCompleted = "completed" // replayed; see the response status
+	StatusFailed    = "failed"    // still failing after the last attempt
+	StatusDiscarded = "discarded" // dropped by an operator
+)
+
+const (
+	// maxQueuedBody is the largest request body that is queued
+	maxQueuedBody = 1 << 20
+	// maxResultBody is how much of a replayed response is kept
+	maxResultBody = 64 << 10
+	// finishedTTL is how long completed and discarded writes are kept
+	finishedTTL = 7 * 24 * time.Hour
+)
+
+// ErrWriteNotFound is returned for unknown queued writes
+var ErrWriteNotFound = errors.New("queued write not found")
+
+// secretHeaders are not persisted with queued writes; the write is replayed
+// as the identity it was queued with instead
+var secretHeaders = []string{"Authorization", "Cookie", "X-API-Key", "X-Admin-Key"}
+
+// Write is a request that failed on a transient QuickBooks error and is
+// replayed in the background until it gets through
+type Write struct {
+	ID        string      `json:"id"`
+	TenantID  string      `json:"tenant_id"`
+	UserID    string      `json:"user_id"`
+	Role      string      `json:"role"`
+	Method    string      `json:"method"`
+	URI       string      `json:"uri"`
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"body,omitempty"`
+	RequestID string      `json:"request_id,omitempty"` // QuickBooks idempotency key of the failed write
+	Status    string      `json:"status"`
+	QueuedAt  time.Time   `json:"queued_at"`
+
+	Attempts      int        `json:"attempts"` // replays so far
+	LastError     string     `json:"last_error,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+
+	// Result of the replay that got through
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	ResponseText   string          `json:"response_text,omitempty"` // non-JSON responses
+}
+
+// Receipt is what clients and operators see of a queued write
+type Receipt struct {
+	ID       string    `json:"id"`
+	TenantID string    `json:"tenant_id"`
+	UserID   string    `json:"user_id"`
+	Method   string    `json:"method"`
+	URI      string    `json:"uri"`
+	Status   string    `json:"status"`
+	QueuedAt time.Time `json:"queued_at"`
+
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	ResponseText   string          `json:"response_text,omitempty"`
+}
+
+// Receipt returns what clients see of the write, without its request
+func (w *Write) Receipt() *Receipt {
+	return &Receipt{
+		ID:             w.ID,
+		TenantID:       w.TenantID,
+		UserID:         w.UserID,
+		Method:         w.Method,
+		URI:            w.URI,
+		Status:         w.Status,
+		QueuedAt:       w.QueuedAt,
+		Attempts:       w.Attempts,
+		LastError:      w.LastError,
+		LastAttemptAt:  w.LastAttemptAt,
+		NextAttemptAt:  w.NextAttemptAt,
+		CompletedAt:    w.CompletedAt,
+		ResponseStatus: w.ResponseStatus,
+		Response:       w.Response,
+		ResponseText:   w.ResponseText,
+	}
+}
+
+// Queue persists writes that failed transiently and replays them
+type Queue struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewQueue creates a new retry queue
+func NewQueue(client redis.UniversalClient, prefix string) *Queue {
+	return &Queue{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// writeKey holds a queued write
+func (q *Queue) writeKey(id string) string {
+	return fmt.Sprintf("%s:retryqueue:write:%s", q.prefix, id)
+}
+
+// dueKey orders pending writes by their next attempt, in milliseconds
+func (q *Queue) dueKey() string {
+	return fmt.Sprintf("%s:retryqueue:due", q.prefix)
+}
+
+// failedKey orders writes that exhausted their attempts by when they failed
+func (q *Queue) failedKey() string {
+	return fmt.Sprintf("%s:retryqueue:failed", q.prefix)
+}
+
+// leaseKey is held by the instance replaying a write
+func (q *Queue) leaseKey(id string) string {
+	return fmt.Sprintf("%s:retryqueue:lease:%s", q.prefix, id)
+}
+
+// millis scores a time in a sorted set
+func millis(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// Enqueue persists a request whose QuickBooks write failed transiently,
+// to be replayed after the first backoff
+func (q *Queue) Enqueue(ctx context.Context, r *http.Request, body []byte, requestID, lastError string) (*Write, error) {
+	header := r.Header.Clone()
+	for _, name := range secretHeaders {
+		header.Del(name)
+	}
+	now := time.Now().UTC()
+	next := now.Add(backoff(1))
+	write := &Write{
+		ID:            jobs.NewID(),
+		TenantID:      auth.GetTenantID(ctx),
+		UserID:        auth.GetUserID(ctx),
+		Role:          auth.GetRole(ctx),
+		Method:        r.Method,
+		URI:           r.URL.RequestURI(),
+		Header:        header,
+		Body:          body,
+		RequestID:     requestID,
+		Status:        StatusPending,
+		QueuedAt:      now,
+		LastError:     lastError,
+		LastAttemptAt: &now,
+		NextAttemptAt: &next,
+	}
+	if err := q.save(ctx, write); err != nil {
+		return nil, err
+	}
+	return write, nil
+}
+
+// Get returns a queued write
+func (q *Queue) Get(ctx context.Context, id string) (*Write, error) {
+	data, err := q.client.Get(ctx, q.writeKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrWriteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queued write: %w", err)
+	}
+
+	var write Write
+	if err := json.Unmarshal(data, &write); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queued write: %w", err)
+	}
+	return &write, nil
+}
+
+// List returns the pending and failed writes, oldest first, optionally only
+// a tenant's or those with a status
+func (q *Queue) List(ctx context.Context, tenantID, status string) ([]*Receipt, error) {
+	var ids []string
+	for _, set := range []struct {
+		key    string
+		status string
+	}{
+		{q.dueKey(), StatusPending},
+		{q.failedKey(), StatusFailed},
+	} {
+		if status != "" && status != set.status {
+			continue
+		}
+		members, err := q.client.ZRange(ctx, set.key, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list queued writes: %w", err)
+		}
+		ids = append(ids, members...)
+	}
+
+	receipts := make([]*Receipt, 0, len(ids))
+	for _, id := range ids {
+		write, err := q.Get(ctx, id)
+		if errors.Is(err, ErrWriteNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tenantID != "" && write.TenantID != tenantID {
+			continue
+		}
+		receipts = append(receipts, write.Receipt())
+	}
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].QueuedAt.Before(receipts[j].QueuedAt)
+	})
+	return receipts, nil
+}
+
+// save stores a write and files it by status: pending writes are scheduled
+// for their next attempt, failed ones listed for operators and finished ones
+// kept for a while for clients following up
+func (q *Queue) save(ctx context.Context, write *Write) error {
+	if write.Status != StatusPending {
+		write.Header, write.Body = nil, nil
+	}
+	data, err := json.Marshal(write)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued write: %w", err)
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		switch write.Status {
+		case StatusPending:
+			pipe.Set(ctx, q.writeKey(write.ID), data, 0)
+			pipe.ZAdd(ctx, q.dueKey(), &redis.Z{Score: millis(*write.NextAttemptAt), Member: write.ID})
+			pipe.ZRem(ctx, q.failedKey(), write.ID)
+		case StatusFailed:
+			pipe.Set(ctx, q.writeKey(write.ID), data, 0)
+			pipe.ZRem(ctx, q.dueKey(), write.ID)
+			pipe.ZAdd(ctx, q.failedKey(), &redis.Z{Score: millis(time.Now()), Member: write.ID})
+		default:
+			pipe.Set(ctx, q.writeKey(write.ID), data, finishedTTL)
+			pipe.ZRem(ctx, q.dueKey(), write.ID)
+			pipe.ZRem(ctx, q.failedKey(), write.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save queued write: %w", err)
+	}
+	return nil
+}
+
+// RetryNow schedules a pending or failed write for an immediate attempt.
+// A failed write gets one more attempt.
+func (q *Queue) RetryNow(ctx context.Context, id string) (*Write, error) {
+	write, err := q.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if write.Status != StatusPending && write.Status != StatusFailed {
+		return nil, fmt.Errorf("queued write is %s", write.Status)
+	}
+	if write.Status == StatusFailed {
+		// The request itself was kept for operators
+		write.Attempts = min(write.Attempts, maxAttempts-1)
+	}
+
+	now := time.Now().UTC()
+	write.Status, write.NextAttemptAt = StatusPending, &now
+	if err := q.save(ctx, write); err != nil {
+		return nil, err
+	}
+	return write, nil
+}
+
+// Discard drops a pending or failed write; it is never replayed
+func (q *Queue) Discard(ctx context.Context, id string) (*Write, error) {
+	write, err := q.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if write.Status != StatusPending && write.Status != StatusFailed {
+		return nil, fmt.Errorf("queued write is %s", write.Status)
+	}
+
+	write.Status, write.NextAttemptAt = StatusDiscarded, nil
+	if err := q.save(ctx, write); err != nil {
+		return nil, err
+	}
+	return write, nil
+}
+
+// due returns up to limit writes whose next attempt has come
+func (q *Queue) due(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	ids, err := q.client.ZRangeByScore(ctx, q.dueKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(int64(millis(now)), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due writes: %w", err)
+	}
+	return ids, nil
+}
+
+// lease claims a write for replaying; it returns false when another instance holds it
+func (q *Queue) lease(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	acquired, err := q.client.SetNX(ctx, q.leaseKey(id), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to lease queued write: %w", err)
+	}
+	return acquired, nil
+}
+
+// release gives up a write lease
+func (q *Queue) release(ctx context.Context, id string) error {
+	return q.client.Del(ctx, q.leaseKey(id)).Err()
+}
@@ -0,0 +1,158 @@
+// retryqueue/worker.go
+package retryqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+const (
+	// maxAttempts is how many times a write is replayed before it is left
+	// for operators
+	maxAttempts = 10
+	// baseBackoff is the wait before the first replay; it doubles per attempt
+	baseBackoff = 30 * time.Second
+	// maxBackoff caps the wait between replays
+	maxBackoff = time.Hour
+	// replayTimeout bounds each replayed write
+	replayTimeout = time.Minute
+	// leaseTTL bounds how long one instance holds a write it replays
+	leaseTTL = 2 * replayTimeout
+	// batchSize is how many due writes are replayed per poll
+	batchSize = 50
+)
+
+// backoff returns the wait before the given attempt
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff << (attempt - 1)
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// StartWorker replays due writes through handler until the context is
+// cancelled
+func (q *Queue) StartWorker(ctx context.Context, handler http.Handler, pollInterval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				q.replayDue(ctx, handler)
+			}
+		}
+	}()
+}
+
+// replayDue replays the writes whose next attempt has come
+func (q *Queue) replayDue(ctx context.Context, handler http.Handler) {
+	ids, err := q.due(ctx, time.Now(), batchSize)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to list due writes", "error", err)
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		acquired, err := q.lease(ctx, id, leaseTTL)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to lease queued write", "write_id", id, "error", err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+		if err := q.replayOne(ctx, id, handler); err != nil {
+			logging.FromContext(ctx).Warn("failed to replay queued write", "write_id", id, "error", err)
+		}
+		q.release(ctx, id)
+	}
+}
+
+// replayOne replays a write still pending and saves the outcome
+func (q *Queue) replayOne(ctx context.Context, id string, handler http.Handler) error {
+	write, err := q.Get(ctx, id)
+	if errors.Is(err, ErrWriteNotFound) {
+		q.client.ZRem(ctx, q.dueKey(), id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if write.Status != StatusPending || write.NextAttemptAt == nil || write.NextAttemptAt.After(time.Now()) {
+		// Discarded or rescheduled since it was listed
+		return nil
+	}
+
+	q.replay(ctx, write, handler)
+	return q.save(ctx, write)
+}
+
+// replay serves a write as the identity it was queued with, reusing the
+// failed write's idempotency key, and records the outcome: completed with
+// the response, or rescheduled while QuickBooks still fails transiently
+func (q *Queue) replay(ctx context.Context, write *Write, handler http.Handler) {
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+
+	ctx = auth.WithIdentity(ctx, write.UserID, write.TenantID, "")
+	ctx = context.WithValue(ctx, auth.RoleKey, write.Role)
+	ctx = context.WithValue(ctx, replayKey, true)
+	ctx = qbclient.WithPriority(ctx, qbclient.PriorityBackground)
+	ctx, watch := qbclient.WithWriteWatch(ctx, write.RequestID)
+	recorder := &bufferedResponse{header: make(http.Header), status: http.StatusOK, limit: maxResultBody}
+
+	req, err := http.NewRequestWithContext(ctx, write.Method, write.URI, bytes.NewReader(write.Body))
+	if err != nil {
+		recorder.status = http.StatusBadRequest
+		recorder.body.WriteString(err.Error())
+	} else {
+		req.Header = write.Header.Clone()
+		handler.ServeHTTP(recorder, req)
+	}
+
+	now := time.Now().UTC()
+	write.Attempts++
+	write.LastAttemptAt = &now
+	if requestID, retryable := watch.Retryable(); retryable && recorder.status >= http.StatusInternalServerError {
+		write.RequestID = requestID
+		write.LastError = strings.TrimSpace(recorder.body.String())
+		if write.Attempts >= maxAttempts {
+			write.Status, write.NextAttemptAt = StatusFailed, nil
+			logging.FromContext(ctx).Warn("queued write failed", "write_id", write.ID, "attempts", write.Attempts, "error", write.LastError)
+			return
+		}
+		next := now.Add(backoff(write.Attempts + 1))
+		write.NextAttemptAt = &next
+		return
+	}
+
+	write.Status = StatusCompleted
+	write.CompletedAt = &now
+	write.NextAttemptAt = nil
+	write.ResponseStatus = recorder.status
+	if body := recorder.body.Bytes(); json.Valid(body) {
+		write.Response = body
+	} else {
+		write.ResponseText = string(body)
+	}
+	if write.ResponseStatus >= 400 {
+		logging.FromContext(ctx).Warn("queued write was rejected", "write_id", write.ID, "status", write.ResponseStatus)
+	}
+}
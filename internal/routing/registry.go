@@ -0,0 +1,205 @@
+// routing/registry.go
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Registry holds routes declared with their metadata until they are mounted
+// on a router, and serves that metadata to middlewares and documentation
+type Registry struct {
+	mu       sync.RWMutex
+	pending  []Route
+	mounted  []Route
+	byRoute  map[string]Route // by method and full path template
+	versions []Version
+	routers  map[*mux.Router][]versionRouter
+}
+
+// versionRouter serves one API version under a parent router
+type versionRouter struct {
+	version string
+	router  *mux.Router
+}
+
+// NewRegistry creates an empty registry serving API versions v1 and v2
+func NewRegistry() *Registry {
+	return &Registry{
+		byRoute:  make(map[string]Route),
+		versions: []Version{{Name: V1}, {Name: V2}},
+		routers:  make(map[*mux.Router][]versionRouter),
+	}
+}
+
+// Deprecate marks an API version deprecated, to be removed at sunset (zero
+// if not yet scheduled). Its successor is the next version. It must be
+// called before routes are mounted.
+func (reg *Registry) Deprecate(name string, sunset time.Time) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for i := range reg.versions {
+		if reg.versions[i].Name != name {
+			continue
+		}
+		if i == len(reg.versions)-1 {
+			return fmt.Errorf("API version %s has no successor to deprecate it for", name)
+		}
+		reg.versions[i].Deprecated = true
+		reg.versions[i].Sunset = sunset
+		reg.versions[i].Successor = reg.versions[i+1].Name
+		return nil
+	}
+	return fmt.Errorf("unknown API version %q", name)
+}
+
+// Versions returns the API versions served
+func (reg *Registry) Versions() []Version {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return append([]Version(nil), reg.versions...)
+}
+
+// VersionRouters returns the routers serving each API version under router:
+// router itself for unprefixed paths, which serve DefaultVersion, then one
+// per version prefix. Routes that do not differ between versions and are
+// not declared in the registry are registered on each.
+func (reg *Registry) VersionRouters(router *mux.Router) []*mux.Router {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	var routers []*mux.Router
+	for _, vr := range reg.versionRouters(router) {
+		routers = append(routers, vr.router)
+	}
+	return routers
+}
+
+// versionRouters creates the version routers of router on first use. The
+// unprefixed one is a subrouter refusing versioned paths, so unprefixed
+// routes with a leading path variable cannot capture the version segment,
+// and its middleware does not apply to router's other routes.
+func (reg *Registry) versionRouters(router *mux.Router) []versionRouter {
+	if routers, ok := reg.routers[router]; ok {
+		return routers
+	}
+	unprefixed := router.NewRoute()
+	base, _ := unprefixed.GetPathTemplate()
+	unprefixed.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return StripVersion(r.URL.Path) == r.URL.Path
+	})
+	routers := []versionRouter{{version: DefaultVersion, router: unprefixed.Subrouter()}}
+	for _, version := range reg.versions {
+		routers = append(routers, versionRouter{version: version.Name, router: router.PathPrefix("/" + version.Name).Subrouter()})
+	}
+	for _, vr := range routers {
+		vr.router.Use(reg.version(vr.version).middleware(base))
+	}
+	reg.routers[router] = routers
+	return routers
+}
+
+// version returns the named API version
+func (reg *Registry) version(name string) Version {
+	for _, version := range reg.versions {
+		if version.Name == name {
+			return version
+		}
+	}
+	return Version{Name: name}
+}
+
+// Add declares routes; they are served once mounted
+func (reg *Registry) Add(routes ...Route) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, route := range routes {
+		reg.pending = append(reg.pending, route.withDefaults())
+	}
+}
+
+// Mount registers the routes added since the last mount on router's
+// version routers, in the order they were added, each wrapped in middleware
+// with the first outermost. Mounting in batches keeps fixed paths ahead of
+// routes of other routers that would otherwise shadow them.
+func (reg *Registry) Mount(router *mux.Router, middleware ...Middleware) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, vr := range reg.versionRouters(router) {
+		for _, route := range reg.pending {
+			handler := route.handler(vr.version)
+			if handler == nil {
+				continue
+			}
+			route.Version = vr.version
+			if route.Deprecation == nil {
+				route.Deprecation = reg.version(vr.version).deprecation()
+			}
+			muxRoute := vr.router.NewRoute().Path(route.Path).Methods(route.Method)
+			if template, err := muxRoute.GetPathTemplate(); err == nil {
+				route.Path = template
+			}
+
+			for i := len(middleware) - 1; i >= 0; i-- {
+				handler = middleware[i](route, handler)
+			}
+			muxRoute.Handler(handler)
+
+			reg.mounted = append(reg.mounted, route)
+			reg.byRoute[route.Method+" "+route.Path] = route
+		}
+	}
+	reg.pending = nil
+}
+
+// Lookup returns the metadata of the route a request matched. It works in
+// router middleware as well as in handlers.
+func (reg *Registry) Lookup(r *http.Request) (Route, bool) {
+	current := mux.CurrentRoute(r)
+	if current == nil {
+		return Route{}, false
+	}
+	template, err := current.GetPathTemplate()
+	if err != nil {
+		return Route{}, false
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	route, ok := reg.byRoute[r.Method+" "+template]
+	return route, ok
+}
+
+// Routes returns the mounted routes by unversioned path, method and version
+func (reg *Registry) Routes() []Route {
+	reg.mu.RLock()
+	routes := append([]Route(nil), reg.mounted...)
+	reg.mu.RUnlock()
+	sort.SliceStable(routes, func(i, j int) bool {
+		pi, pj := StripVersion(routes[i].Path), StripVersion(routes[j].Path)
+		if pi != pj {
+			return pi < pj
+		}
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		// Unprefixed paths first, then by version
+		if len(routes[i].Path) != len(routes[j].Path) {
+			return len(routes[i].Path) < len(routes[j].Path)
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// Handler lists the mounted routes and their metadata, the source for API
+// documentation
+func (reg *Registry) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reg.Routes())
+}
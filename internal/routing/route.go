@@ -0,0 +1,147 @@
+// routing/route.go
+package routing
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// Rate-limit classes of routes, from cheapest to most expensive
+const (
+	// ClassRead is a single QuickBooks read or list
+	ClassRead = "read"
+	// ClassWrite is a QuickBooks write
+	ClassWrite = "write"
+	// ClassReport is a report or an aggregate of several queries
+	ClassReport = "report"
+	// ClassBulk is an import, sync or export fanning out to many calls
+	ClassBulk = "bulk"
+)
+
+// Editors are the roles allowed to change data; viewers are read-only
+var Editors = []string{"admin", auth.UserRole}
+
+// Route describes an API route: how it is matched and served, and the
+// metadata middlewares, rate limiting and API documentation rely on
+type Route struct {
+	Method  string           `json:"method"`
+	Path    string           `json:"path"`
+	Summary string           `json:"summary,omitempty"`
+	Handler http.HandlerFunc `json:"-"`
+	// Versions overrides Handler for the API versions whose response
+	// shape differs; a nil handler leaves the route out of that version
+	Versions map[string]http.HandlerFunc `json:"-"`
+	// Version is the API version the mounted route serves
+	Version string `json:"version,omitempty"`
+	// Scopes are QuickBooks scopes required in addition to those of the
+	// router the route is mounted on
+	Scopes []string `json:"scopes,omitempty"`
+	// Roles are the roles allowed to call the route; empty allows any
+	Roles []string `json:"roles,omitempty"`
+	// Class is the rate-limit class; it defaults to read for GET and
+	// write otherwise. Bulk routes draw on the company's QuickBooks budget
+	// as background requests.
+	Class string `json:"class"`
+	// Deprecation announces the route's removal; routes of a deprecated
+	// API version inherit the version's
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
+	// DeprecatedParams announces the removal of query parameters, by name
+	DeprecatedParams map[string]Deprecation `json:"deprecated_params,omitempty"`
+}
+
+// Deprecation announces that a route or parameter is going away
+type Deprecation struct {
+	// Since is when it was deprecated; zero if unannounced
+	Since time.Time `json:"since,omitempty"`
+	// Sunset is when it will be removed; zero if not yet scheduled
+	Sunset time.Time `json:"sunset,omitempty"`
+	// Link documents the replacement
+	Link string `json:"link,omitempty"`
+}
+
+// SetHeaders marks a response as deprecated, per RFC 9745 and RFC 8594
+func (d Deprecation) SetHeaders(header http.Header) {
+	if d.Since.IsZero() {
+		header.Set("Deprecation", "true")
+	} else {
+		header.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		header.Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+	}
+}
+
+// withDefaults fills the class implied by the method
+func (r Route) withDefaults() Route {
+	if r.Class == "" {
+		r.Class = ClassWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			r.Class = ClassRead
+		}
+	}
+	return r
+}
+
+// handler returns the handler serving an API version, or nil if the route
+// is not part of it
+func (r Route) handler(version string) http.Handler {
+	handler := r.Handler
+	if override, ok := r.Versions[version]; ok {
+		handler = override
+	}
+	if handler == nil {
+		return nil
+	}
+	return handler
+}
+
+// allows reports whether a role may call the route
+func (r Route) allows(role string) bool {
+	if len(r.Roles) == 0 {
+		return true
+	}
+	for _, allowed := range r.Roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware wraps a route's handler, given the route's metadata
+type Middleware func(route Route, next http.Handler) http.Handler
+
+// RequireRoles rejects callers whose role the route does not allow
+func RequireRoles(route Route, next http.Handler) http.Handler {
+	if len(route.Roles) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role := auth.GetRole(r.Context()); !route.allows(role) {
+			http.Error(w, "Role "+role+" is not allowed to "+route.Method+" "+route.Path, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Prioritize runs bulk routes' QuickBooks requests as background ones, so
+// imports, syncs and exports wait out throttling and yield each company's
+// budget to the interactive requests of the other classes
+func Prioritize(route Route, next http.Handler) http.Handler {
+	if route.Class != ClassBulk {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := qbclient.WithRetry(r.Context(), qbclient.BackgroundRetryPolicy)
+		ctx = qbclient.WithPriority(ctx, qbclient.PriorityBackground)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
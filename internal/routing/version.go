@@ -0,0 +1,91 @@
+// routing/version.go
+package routing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API versions, served under /api/v1 and /api/v2. Unprefixed /api routes
+// serve DefaultVersion so existing clients keep working.
+const (
+	V1 = "v1"
+	V2 = "v2"
+
+	DefaultVersion = V1
+)
+
+// Version is an API version and its deprecation status
+type Version struct {
+	Name string `json:"name"`
+	// Deprecated versions answer with Deprecation and Sunset headers
+	// pointing at their successor
+	Deprecated bool      `json:"deprecated"`
+	Sunset     time.Time `json:"sunset,omitempty"`
+	Successor  string    `json:"successor,omitempty"`
+}
+
+// VersionHeader names the API version that served a response
+const VersionHeader = "API-Version"
+
+type versionKey struct{}
+
+// WithVersion returns a context carrying the API version of a request
+func WithVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// GetVersion returns the API version a request was routed to
+func GetVersion(ctx context.Context) string {
+	if version, ok := ctx.Value(versionKey{}).(string); ok {
+		return version
+	}
+	return DefaultVersion
+}
+
+// StripVersion removes the version segment from a path or path template,
+// so /api/v2/invoices/{id} becomes /api/invoices/{id}. Settings keyed by
+// unversioned path apply to every version that way.
+func StripVersion(path string) string {
+	for _, name := range []string{V1, V2} {
+		if i := strings.Index(path, "/"+name+"/"); i >= 0 {
+			return path[:i] + path[i+len(name)+1:]
+		}
+		if strings.HasSuffix(path, "/"+name) {
+			return strings.TrimSuffix(path, "/"+name)
+		}
+	}
+	return path
+}
+
+// deprecation returns the deprecation the version's routes inherit, if any
+func (v Version) deprecation() *Deprecation {
+	if !v.Deprecated {
+		return nil
+	}
+	return &Deprecation{Sunset: v.Sunset}
+}
+
+// middleware tags requests with the version and marks responses of
+// deprecated versions. base is the unversioned API path prefix.
+func (v Version) middleware(base string) func(http.Handler) http.Handler {
+	var link string
+	if v.Successor != "" {
+		link = fmt.Sprintf(`<%s/%s>; rel="successor-version"`, base, v.Successor)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(VersionHeader, v.Name)
+			if v.Deprecated {
+				v.deprecation().SetHeaders(w.Header())
+				if link != "" {
+					w.Header().Add("Link", link)
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(WithVersion(r.Context(), v.Name)))
+		})
+	}
+}
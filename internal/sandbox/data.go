@@ -0,0 +1,73 @@
+// sandbox/data.go
+package sandbox
+
+// Word lists for generated demo records. Combinations are plentiful enough
+// for the maximum volumes without repeating.
+
+var firstNames = []string{
+	"Amelia", "Ben", "Carmen", "Darius", "Elena", "Farid", "Grace", "Hiro",
+	"Isla", "Jonah", "Keiko", "Liam", "Maya", "Noah", "Olivia", "Priya",
+	"Quinn", "Rosa", "Samir", "Tessa", "Umar", "Vera", "Wes", "Yara",
+}
+
+var lastNames = []string{
+	"Alvarez", "Brooks", "Chen", "Dubois", "Evans", "Fischer", "Garcia", "Hughes",
+	"Ito", "Jensen", "Kowalski", "Larsen", "Moreau", "Nakamura", "O'Brien", "Patel",
+	"Rossi", "Schmidt", "Tanaka", "Ueda", "Varga", "Walsh", "Young", "Zimmerman",
+}
+
+var companyWords = []string{
+	"Alpine", "Beacon", "Cedar", "Summit", "Harbor", "Ironwood", "Juniper", "Keystone",
+	"Lakeside", "Meridian", "Northwind", "Oakridge", "Pinnacle", "Redwood", "Silverline", "Timber",
+}
+
+var companyTrades = []string{
+	"Bakery", "Builders", "Consulting", "Dental", "Design", "Electric", "Fitness", "Landscaping",
+	"Logistics", "Marketing", "Plumbing", "Realty", "Roofing", "Studios", "Supply", "Veterinary",
+}
+
+var companySuffixes = []string{"LLC", "Inc", "Co", "Group", "Partners"}
+
+var streets = []string{
+	"Main St", "Oak Ave", "Maple Dr", "Cedar Ln", "Park Blvd", "Elm St", "Lake Rd", "Hill St",
+}
+
+var cities = []struct {
+	City, State, Zip string
+}{
+	{"Austin", "TX", "78701"},
+	{"Denver", "CO", "80202"},
+	{"Portland", "OR", "97204"},
+	{"Raleigh", "NC", "27601"},
+	{"Madison", "WI", "53703"},
+	{"Phoenix", "AZ", "85004"},
+	{"Columbus", "OH", "43215"},
+	{"Sacramento", "CA", "95814"},
+}
+
+// itemTemplate is a demo product or service with a typical price range
+type itemTemplate struct {
+	Name     string
+	Type     string // Service or NonInventory
+	MinPrice float64
+	MaxPrice float64
+}
+
+var itemTemplates = []itemTemplate{
+	{"Consulting Hour", "Service", 90, 175},
+	{"Design Revision", "Service", 60, 120},
+	{"Installation", "Service", 150, 400},
+	{"Site Visit", "Service", 75, 150},
+	{"Monthly Retainer", "Service", 800, 2500},
+	{"Training Session", "Service", 200, 600},
+	{"Maintenance Plan", "Service", 120, 350},
+	{"Rush Delivery", "Service", 25, 60},
+	{"Office Chair", "NonInventory", 120, 320},
+	{"Standing Desk", "NonInventory", 350, 900},
+	{"Printer Paper Case", "NonInventory", 30, 55},
+	{"LED Panel", "NonInventory", 45, 140},
+	{"Copper Fitting Kit", "NonInventory", 18, 60},
+	{"Garden Mulch Bag", "NonInventory", 6, 15},
+	{"Safety Gloves", "NonInventory", 9, 25},
+	{"Paint Gallon", "NonInventory", 28, 70},
+}
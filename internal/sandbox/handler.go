@@ -0,0 +1,49 @@
+// sandbox/handler.go
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler provides operator endpoints for seeding sandbox companies
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new sandbox handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// Seed populates the given user's connected sandbox company with demo data
+func (h *Handler) Seed(w http.ResponseWriter, r *http.Request) {
+	var opts Options
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Seed(r.Context(), mux.Vars(r)["tenantID"], opts)
+	if err != nil {
+		if errors.Is(err, ErrNotSandbox) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to seed sandbox: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,466 @@
+// sandbox/seeder.go
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// ErrNotSandbox is returned when the server is connected to production QuickBooks
+var ErrNotSandbox = errors.New("seeding is only allowed against the QuickBooks sandbox")
+
+// seedNote marks every generated record so demo data is easy to find
+const seedNote = "Demo data generated by qbserver sandbox seeder"
+
+// Volume limits per run
+const (
+	maxCustomers = 500
+	maxItems     = 64
+	maxInvoices  = 1000
+	maxDays      = 365
+	maxErrors    = 50
+)
+
+// itemVariants extend the item templates when more items are requested
+var itemVariants = []string{"", " - Premium", " - Basic", " - Bulk"}
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// QuickBooks is the subset of the QuickBooks client used for seeding
+type QuickBooks interface {
+	Querier
+	Batch(ctx context.Context, items []qbclient.BatchItem) (map[string]qbclient.BatchResult, error)
+}
+
+// TokenSource resolves a user's QuickBooks connection
+type TokenSource interface {
+	GetValidToken(ctx context.Context, userID string) (*auth.OAuthToken, error)
+}
+
+// Options sets how much demo data to generate. When no volumes are given
+// the defaults apply; otherwise a zero volume generates none of that entity.
+type Options struct {
+	UserID    string `json:"user_id"` // user whose QuickBooks connection is seeded
+	Customers int    `json:"customers"`
+	Items     int    `json:"items"`
+	Invoices  int    `json:"invoices"`
+	Payments  int    `json:"payments"`       // invoices that receive a payment
+	Days      int    `json:"days"`           // invoice dates spread over this many past days
+	Seed      int64  `json:"seed,omitempty"` // fixes the generated data for repeatable demos
+}
+
+// Validate applies defaults and checks volumes
+func (o *Options) Validate() error {
+	if o.UserID == "" {
+		return fmt.Errorf("user_id is required")
+	}
+	if o.Customers == 0 && o.Items == 0 && o.Invoices == 0 && o.Payments == 0 {
+		o.Customers, o.Items, o.Invoices, o.Payments = 25, 10, 60, 40
+	}
+	if o.Days == 0 {
+		o.Days = 90
+	}
+
+	switch {
+	case o.Customers < 0 || o.Customers > maxCustomers:
+		return fmt.Errorf("customers must be between 0 and %d", maxCustomers)
+	case o.Items < 0 || o.Items > maxItems:
+		return fmt.Errorf("items must be between 0 and %d", maxItems)
+	case o.Invoices < 0 || o.Invoices > maxInvoices:
+		return fmt.Errorf("invoices must be between 0 and %d", maxInvoices)
+	case o.Payments < 0 || o.Payments > o.Invoices:
+		return fmt.Errorf("payments must be between 0 and the number of invoices")
+	case o.Days < 1 || o.Days > maxDays:
+		return fmt.Errorf("days must be between 1 and %d", maxDays)
+	case o.Invoices > 0 && (o.Customers == 0 || o.Items == 0):
+		return fmt.Errorf("invoices require at least one customer and one item")
+	}
+	if o.Seed == 0 {
+		o.Seed = time.Now().UnixNano()
+	}
+	return nil
+}
+
+// Report summarizes a seeding run
+type Report struct {
+	RealmID string         `json:"realm_id"`
+	Seed    int64          `json:"seed"`
+	Created map[string]int `json:"created"`
+	Errors  []string       `json:"errors,omitempty"`
+}
+
+// fail records a rejected record, keeping the report bounded
+func (r *Report) fail(message string) {
+	if len(r.Errors) < maxErrors {
+		r.Errors = append(r.Errors, message)
+	}
+}
+
+// created is the part of a created entity the seeder needs afterwards
+type created struct {
+	ID          string  `json:"Id"`
+	TotalAmt    float64 `json:"TotalAmt"`
+	TxnDate     string  `json:"TxnDate"`
+	CustomerRef struct {
+		Value string `json:"value"`
+	} `json:"CustomerRef"`
+}
+
+// named is a QuickBooks list entity with its name
+type named struct {
+	ID                 string `json:"Id"`
+	Name               string `json:"Name"`
+	FullyQualifiedName string `json:"FullyQualifiedName"`
+}
+
+// Service populates sandbox companies with demo data
+type Service struct {
+	qb      QuickBooks
+	tokens  TokenSource
+	sandbox bool
+}
+
+// NewService creates a new seeding service. Seeding is refused unless
+// apiBaseURL is the QuickBooks sandbox API.
+func NewService(qb QuickBooks, tokens TokenSource, apiBaseURL string) *Service {
+	return &Service{
+		qb:      qb,
+		tokens:  tokens,
+		sandbox: strings.Contains(apiBaseURL, "sandbox"),
+	}
+}
+
+// Seed generates customers, items, invoices and payments in the user's
+// connected company. Records rejected by QuickBooks are reported and
+// skipped; invoices only reference records created in the same run.
+func (s *Service) Seed(ctx context.Context, tenantID string, opts Options) (*Report, error) {
+	if !s.sandbox {
+		return nil, ErrNotSandbox
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokens.GetValidToken(ctx, opts.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get QuickBooks connection: %w", err)
+	}
+	ctx = auth.WithIdentity(ctx, opts.UserID, tenantID, token.RealmID)
+
+	report := &Report{RealmID: token.RealmID, Seed: opts.Seed, Created: make(map[string]int)}
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	customers, err := s.seedCustomers(ctx, rng, opts.Customers, report)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.seedItems(ctx, rng, opts.Items, report)
+	if err != nil {
+		return nil, err
+	}
+	invoices, err := s.seedInvoices(ctx, rng, opts, customers, items, report)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.seedPayments(ctx, rng, opts.Payments, invoices, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// seedCustomers creates people and businesses with unique display names
+func (s *Service) seedCustomers(ctx context.Context, rng *rand.Rand, count int, report *Report) ([]string, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	existing, err := query.All[named](ctx, s.qb, query.Select("Customer", "Id", "DisplayName", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	taken := names(existing)
+
+	payloads := make([]map[string]interface{}, 0, count)
+	for len(payloads) < count {
+		first := firstNames[rng.Intn(len(firstNames))]
+		last := lastNames[rng.Intn(len(lastNames))]
+		payload := map[string]interface{}{
+			"GivenName":  first,
+			"FamilyName": last,
+			"Notes":      seedNote,
+		}
+
+		displayName := first + " " + last
+		if rng.Intn(3) > 0 {
+			company := fmt.Sprintf("%s %s %s",
+				companyWords[rng.Intn(len(companyWords))],
+				companyTrades[rng.Intn(len(companyTrades))],
+				companySuffixes[rng.Intn(len(companySuffixes))])
+			payload["CompanyName"] = company
+			displayName = company
+		}
+		payload["DisplayName"] = unique(displayName, taken)
+
+		domain := strings.ToLower(strings.NewReplacer(" ", "", "'", "").Replace(last)) + ".example.com"
+		payload["PrimaryEmailAddr"] = map[string]string{"Address": strings.ToLower(first) + "@" + domain}
+		payload["PrimaryPhone"] = map[string]string{"FreeFormNumber": fmt.Sprintf("(555) %03d-%04d", rng.Intn(1000), rng.Intn(10000))}
+		city := cities[rng.Intn(len(cities))]
+		payload["BillAddr"] = map[string]string{
+			"Line1":                  fmt.Sprintf("%d %s", 100+rng.Intn(9900), streets[rng.Intn(len(streets))]),
+			"City":                   city.City,
+			"CountrySubDivisionCode": city.State,
+			"PostalCode":             city.Zip,
+			"Country":                "USA",
+		}
+		payloads = append(payloads, payload)
+	}
+
+	results, err := s.createAll(ctx, "Customer", payloads, report)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, c := range results {
+		if c != nil {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids, nil
+}
+
+// seedItem is a created item with its unit price
+type seedItem struct {
+	ID    string
+	Name  string
+	Price float64
+}
+
+// seedItems creates service and non-inventory items against an income account
+func (s *Service) seedItems(ctx context.Context, rng *rand.Rand, count int, report *Report) ([]seedItem, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	accounts, err := query.All[named](ctx, s.qb, query.Select("Account", "Id", "Name", "FullyQualifiedName").
+		Where("AccountType", "=", "Income").
+		Where("Active", "=", true))
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("company has no active income account for demo items")
+	}
+	incomeAccountID := accounts[0].ID
+
+	existing, err := query.All[named](ctx, s.qb, query.Select("Item", "Id", "Name", "FullyQualifiedName").WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	taken := names(existing)
+
+	payloads := make([]map[string]interface{}, 0, count)
+	prices := make([]float64, 0, count)
+	for n := 0; n < count; n++ {
+		template := itemTemplates[n%len(itemTemplates)]
+		price := round(template.MinPrice + rng.Float64()*(template.MaxPrice-template.MinPrice))
+		payloads = append(payloads, map[string]interface{}{
+			"Name":             unique(template.Name+itemVariants[n/len(itemTemplates)], taken),
+			"Type":             template.Type,
+			"UnitPrice":        price,
+			"Description":      seedNote,
+			"IncomeAccountRef": map[string]string{"value": incomeAccountID},
+		})
+		prices = append(prices, price)
+	}
+
+	results, err := s.createAll(ctx, "Item", payloads, report)
+	if err != nil {
+		return nil, err
+	}
+	var items []seedItem
+	for n, c := range results {
+		if c != nil {
+			items = append(items, seedItem{ID: c.ID, Name: payloads[n]["Name"].(string), Price: prices[n]})
+		}
+	}
+	return items, nil
+}
+
+// seedInvoices creates invoices dated across the requested window
+func (s *Service) seedInvoices(ctx context.Context, rng *rand.Rand, opts Options, customers []string, items []seedItem, report *Report) ([]*created, error) {
+	if opts.Invoices == 0 || len(customers) == 0 || len(items) == 0 {
+		return nil, nil
+	}
+	today := timezone.Today(ctx)
+
+	payloads := make([]map[string]interface{}, 0, opts.Invoices)
+	for n := 0; n < opts.Invoices; n++ {
+		txnDate := today.AddDate(0, 0, -rng.Intn(opts.Days))
+
+		var lines []map[string]interface{}
+		for l := 1 + rng.Intn(4); l > 0; l-- {
+			item := items[rng.Intn(len(items))]
+			qty := 1 + rng.Intn(10)
+			lines = append(lines, map[string]interface{}{
+				"DetailType":  "SalesItemLineDetail",
+				"Amount":      round(float64(qty) * item.Price),
+				"Description": item.Name,
+				"SalesItemLineDetail": map[string]interface{}{
+					"ItemRef":   map[string]string{"value": item.ID},
+					"Qty":       qty,
+					"UnitPrice": item.Price,
+				},
+			})
+		}
+
+		payloads = append(payloads, map[string]interface{}{
+			"CustomerRef": map[string]string{"value": customers[rng.Intn(len(customers))]},
+			"TxnDate":     txnDate.Format("2006-01-02"),
+			"DueDate":     txnDate.AddDate(0, 0, 30).Format("2006-01-02"),
+			"Line":        lines,
+			"PrivateNote": seedNote,
+		})
+	}
+
+	results, err := s.createAll(ctx, "Invoice", payloads, report)
+	if err != nil {
+		return nil, err
+	}
+	var invoices []*created
+	for _, c := range results {
+		if c != nil {
+			invoices = append(invoices, c)
+		}
+	}
+	return invoices, nil
+}
+
+// seedPayments pays randomly chosen invoices, most in full and some in part,
+// dated between the invoice and today
+func (s *Service) seedPayments(ctx context.Context, rng *rand.Rand, count int, invoices []*created, report *Report) error {
+	if count > len(invoices) {
+		count = len(invoices)
+	}
+	if count == 0 {
+		return nil
+	}
+	today := timezone.Today(ctx)
+
+	payloads := make([]map[string]interface{}, 0, count)
+	for _, n := range rng.Perm(len(invoices))[:count] {
+		invoice := invoices[n]
+		amount := invoice.TotalAmt
+		if rng.Intn(4) == 0 {
+			amount = round(amount / 2)
+		}
+
+		paidOn := today
+		if txnDate, err := time.Parse("2006-01-02", invoice.TxnDate); err == nil {
+			if candidate := txnDate.AddDate(0, 0, rng.Intn(45)); candidate.Before(today) {
+				paidOn = candidate
+			}
+		}
+
+		payloads = append(payloads, map[string]interface{}{
+			"CustomerRef": map[string]string{"value": invoice.CustomerRef.Value},
+			"TotalAmt":    amount,
+			"TxnDate":     paidOn.Format("2006-01-02"),
+			"PrivateNote": seedNote,
+			"Line": []map[string]interface{}{{
+				"Amount":    amount,
+				"LinkedTxn": []map[string]string{{"TxnId": invoice.ID, "TxnType": "Invoice"}},
+			}},
+		})
+	}
+
+	_, err := s.createAll(ctx, "Payment", payloads, report)
+	return err
+}
+
+// createAll submits payloads through the batch API. The result holds the
+// created entity at each payload's index, or nil where QuickBooks rejected it.
+func (s *Service) createAll(ctx context.Context, entity string, payloads []map[string]interface{}, report *Report) ([]*created, error) {
+	results := make([]*created, len(payloads))
+	for start := 0; start < len(payloads); start += qbclient.MaxBatchSize {
+		end := start + qbclient.MaxBatchSize
+		if end > len(payloads) {
+			end = len(payloads)
+		}
+
+		batch := make([]qbclient.BatchItem, 0, end-start)
+		for n := start; n < end; n++ {
+			batch = append(batch, qbclient.BatchItem{
+				ID:        strconv.Itoa(n),
+				Operation: "create",
+				Entity:    entity,
+				Payload:   payloads[n],
+			})
+		}
+
+		responses, err := s.qb.Batch(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s records: %w", entity, err)
+		}
+		for _, item := range batch {
+			n, _ := strconv.Atoi(item.ID)
+			response, ok := responses[item.ID]
+			switch {
+			case !ok:
+				report.fail(fmt.Sprintf("%s %d: no response from QuickBooks", entity, n+1))
+			case len(response.Errors) > 0:
+				e := response.Errors[0]
+				report.fail(fmt.Sprintf("%s %d: %s", entity, n+1, strings.TrimSpace(e.Message+" "+e.Detail)))
+			default:
+				var c created
+				if err := json.Unmarshal(response.Entity, &c); err != nil || c.ID == "" {
+					report.fail(fmt.Sprintf("%s %d: unreadable response from QuickBooks", entity, n+1))
+					continue
+				}
+				results[n] = &c
+				report.Created[entity]++
+			}
+		}
+	}
+	return results, nil
+}
+
+// names returns the lowercased names already used in the company
+func names(entities []named) map[string]bool {
+	taken := make(map[string]bool, len(entities))
+	for _, e := range entities {
+		name := e.FullyQualifiedName
+		if name == "" {
+			name = e.Name
+		}
+		taken[strings.ToLower(name)] = true
+	}
+	return taken
+}
+
+// unique returns name, numbered if it is already taken, and reserves it
+func unique(name string, taken map[string]bool) string {
+	candidate := name
+	for n := 2; taken[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s %d", name, n)
+	}
+	taken[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// round rounds an amount to cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
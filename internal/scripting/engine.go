@@ -0,0 +1,283 @@
+// scripting/engine.go
+package scripting
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Resource limits for one script run
+const (
+	maxSteps      = 200000
+	runTimeout    = 250 * time.Millisecond
+	maxOutput     = 20 // print() lines kept per run
+	maxValueDepth = 64
+)
+
+// entryPoint is the function every script must define
+const entryPoint = "run"
+
+// ErrRejected is returned when a script rejects a payload with reject()
+var ErrRejected = errors.New("rejected by script")
+
+// rejection carries a reject() message out of the interpreter
+type rejection struct {
+	message string
+}
+
+func (r *rejection) Error() string {
+	return r.message
+}
+
+// fileOptions keeps the default Starlark dialect: no top-level control
+// flow, recursion or while loops. Steps and time are bounded per run.
+var fileOptions = &syntax.FileOptions{}
+
+// predeclared names available to scripts besides the Starlark built-ins
+var predeclared = starlark.StringDict{
+	"reject": starlark.NewBuiltin("reject", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var message string
+		if err := starlark.UnpackPositionalArgs("reject", args, kwargs, 1, &message); err != nil {
+			return nil, err
+		}
+		return nil, &rejection{message: message}
+	}),
+}
+
+// programs caches compiled scripts by source hash
+var programs sync.Map
+
+// compile parses a script, reusing the cached program for known sources
+func compile(name, source string) (*starlark.Program, error) {
+	sum := sha256.Sum256([]byte(source))
+	if cached, ok := programs.Load(sum); ok {
+		return cached.(*starlark.Program), nil
+	}
+
+	file, program, err := starlark.SourceProgramOptions(fileOptions, name, source, predeclared.Has)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile script: %w", err)
+	}
+	if !defines(file, entryPoint) {
+		return nil, fmt.Errorf("script must define a %s(payload, meta) function", entryPoint)
+	}
+	programs.Store(sum, program)
+	return program, nil
+}
+
+// defines reports whether file has a top-level def of name
+func defines(file *syntax.File, name string) bool {
+	for _, stmt := range file.Stmts {
+		if def, ok := stmt.(*syntax.DefStmt); ok && def.Name.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Result is the outcome of one script run
+type Result struct {
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Output  []string               `json:"output,omitempty"`
+}
+
+// execute runs a script's entry point over payload within the resource
+// limits. meta is passed as the second argument.
+func execute(ctx context.Context, name, source string, payload, meta map[string]interface{}) (*Result, error) {
+	program, err := compile(name, source)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			if len(result.Output) < maxOutput {
+				result.Output = append(result.Output, msg)
+			}
+		},
+		// load() is not available to scripts
+		Load: func(*starlark.Thread, string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load is not supported")
+		},
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		thread.Cancel("script exceeded its time limit")
+	}()
+
+	globals, err := program.Init(thread, predeclared)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	globals.Freeze()
+	fn, ok := globals[entryPoint].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("script must define a %s(payload, meta) function", entryPoint)
+	}
+
+	arg, err := toStarlark(payload, 0)
+	if err != nil {
+		return nil, err
+	}
+	metaArg, err := toStarlark(meta, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := starlark.Call(thread, fn, starlark.Tuple{arg, metaArg}, nil)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+
+	// Returning None keeps the (possibly modified in place) payload
+	if value == starlark.None {
+		value = arg
+	}
+	converted, err := fromStarlark(value, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid return value: %w", err)
+	}
+	out, ok := converted.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must return a dict or None, got %s", entryPoint, value.Type())
+	}
+	result.Payload = out
+	return result, nil
+}
+
+// scriptError maps interpreter errors, surfacing reject() as ErrRejected
+func scriptError(err error) error {
+	var r *rejection
+	if errors.As(err, &r) {
+		return fmt.Errorf("%w: %s", ErrRejected, r.message)
+	}
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return fmt.Errorf("script error: %s", evalErr.Backtrace())
+	}
+	return fmt.Errorf("script error: %w", err)
+}
+
+// toStarlark converts generic JSON into Starlark values
+func toStarlark(v interface{}, depth int) (starlark.Value, error) {
+	if depth > maxValueDepth {
+		return nil, fmt.Errorf("value is nested too deeply")
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(x), nil
+	case float64:
+		// Whole numbers become ints so scripts can use them as indexes and counts
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+			return starlark.MakeInt64(int64(x)), nil
+		}
+		return starlark.Float(x), nil
+	case string:
+		return starlark.String(x), nil
+	case []interface{}:
+		elems := make([]starlark.Value, 0, len(x))
+		for _, e := range x {
+			converted, err := toStarlark(e, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, converted)
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		dict := starlark.NewDict(len(x))
+		for _, k := range keys {
+			converted, err := toStarlark(x[k], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			dict.SetKey(starlark.String(k), converted)
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %T", v)
+}
+
+// fromStarlark converts Starlark values back into generic JSON
+func fromStarlark(v starlark.Value, depth int) (interface{}, error) {
+	if depth > maxValueDepth {
+		return nil, fmt.Errorf("value is nested too deeply")
+	}
+
+	switch x := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(x), nil
+	case starlark.Int:
+		// Numbers go back as float64, the form encoding/json decodes them to
+		if n, ok := x.Int64(); ok && n < 1<<53 && n > -1<<53 {
+			return float64(n), nil
+		}
+		return nil, fmt.Errorf("integer %s is out of range", x)
+	case starlark.Float:
+		return float64(x), nil
+	case starlark.String:
+		return string(x), nil
+	case *starlark.List:
+		out := make([]interface{}, 0, x.Len())
+		for i := 0; i < x.Len(); i++ {
+			e, err := fromStarlark(x.Index(i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, e)
+		}
+		return out, nil
+	case starlark.Tuple:
+		out := make([]interface{}, 0, len(x))
+		for _, elem := range x {
+			e, err := fromStarlark(elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, e)
+		}
+		return out, nil
+	case *starlark.Dict:
+		out := make(map[string]interface{}, x.Len())
+		for _, item := range x.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+			e, err := fromStarlark(item[1], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out[string(key)] = e
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %s", strings.ToLower(v.Type()))
+}
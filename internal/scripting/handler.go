@@ -0,0 +1,126 @@
+// scripting/handler.go
+package scripting
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for tenant scripts
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new scripting handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListScripts returns the tenant's scripts
+func (h *Handler) ListScripts(w http.ResponseWriter, r *http.Request) {
+	scripts, err := h.service.ListScripts(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to get scripts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scripts": scripts,
+	})
+}
+
+// SaveScript creates or replaces the named script
+func (h *Handler) SaveScript(w http.ResponseWriter, r *http.Request) {
+	var script Script
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxSourceSize)).Decode(&script); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	script.Name = mux.Vars(r)["name"]
+
+	if err := h.service.SaveScript(r.Context(), auth.GetTenantID(r.Context()), script); err != nil {
+		http.Error(w, "Failed to save script: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(script)
+}
+
+// DeleteScript removes the named script
+func (h *Handler) DeleteScript(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteScript(r.Context(), auth.GetTenantID(r.Context()), mux.Vars(r)["name"]); err != nil {
+		if errors.Is(err, ErrScriptNotFound) {
+			http.Error(w, "Script not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete script: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestScript runs a script over a sample payload without calling
+// QuickBooks. The script may be given inline or by the name of a saved one.
+func (h *Handler) TestScript(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name    string                 `json:"name"`
+		Script  *Script                `json:"script"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Payload == nil {
+		http.Error(w, "payload is required", http.StatusBadRequest)
+		return
+	}
+
+	script := body.Script
+	if script == nil {
+		scripts, err := h.service.ListScripts(r.Context(), auth.GetTenantID(r.Context()))
+		if err != nil {
+			http.Error(w, "Failed to get scripts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for n := range scripts {
+			if scripts[n].Name == body.Name {
+				script = &scripts[n]
+			}
+		}
+		if script == nil {
+			http.Error(w, "Script not found", http.StatusNotFound)
+			return
+		}
+	} else if err := script.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Script failures are the result being tested, so they are reported in
+	// the response rather than as an error status
+	response := map[string]interface{}{}
+	result, err := h.service.Run(r.Context(), *script, body.Payload)
+	if err != nil {
+		response["error"] = err.Error()
+		response["rejected"] = errors.Is(err, ErrRejected)
+	} else {
+		response["payload"] = result.Payload
+		response["output"] = result.Output
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,207 @@
+// scripting/service.go
+package scripting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/go-redis/redis/v8"
+)
+
+// Limits on a tenant's scripts
+const (
+	maxScripts    = 20
+	maxSourceSize = 16 << 10
+)
+
+// ErrScriptNotFound is returned when a tenant has no script with the given name
+var ErrScriptNotFound = errors.New("script not found")
+
+// Script is a tenant's custom business rule. It runs at one hook point and
+// must define run(payload, meta), where meta holds the entity, stage,
+// tenant_id and realm_id. run may change payload in place or return a new
+// dict, and rejects the write by calling reject("reason"). Rejections at
+// after_* stages fail the request but cannot undo the QuickBooks write.
+type Script struct {
+	Name     string `json:"name"`
+	Entity   string `json:"entity"` // e.g. "Invoice", or "*" for every entity
+	Stage    string `json:"stage"`  // e.g. before_create
+	Source   string `json:"source"`
+	Disabled bool   `json:"disabled,omitempty"`
+	// FailOpen lets the write proceed when the script errors or exceeds its
+	// limits; reject() still blocks it
+	FailOpen bool `json:"fail_open,omitempty"`
+}
+
+// validStages are the hook points scripts may run at
+var validStages = map[string]bool{
+	qbclient.StageBeforeCreate: true,
+	qbclient.StageBeforeUpdate: true,
+	qbclient.StageAfterCreate:  true,
+	qbclient.StageAfterUpdate:  true,
+	qbclient.StageAfterRead:    true,
+}
+
+// Validate checks a script and compiles it
+func (s *Script) Validate() error {
+	if s.Name == "" || strings.ContainsAny(s.Name, " /:") {
+		return fmt.Errorf("name is required and may not contain spaces, slashes or colons")
+	}
+	if s.Entity == "" {
+		return fmt.Errorf("entity is required")
+	}
+	if !validStages[s.Stage] {
+		return fmt.Errorf("unknown stage %q", s.Stage)
+	}
+	if len(s.Source) > maxSourceSize {
+		return fmt.Errorf("source exceeds %d bytes", maxSourceSize)
+	}
+	if _, err := compile(s.Name, s.Source); err != nil {
+		return err
+	}
+	return nil
+}
+
+// matches reports whether the script runs for stage and entity
+func (s *Script) matches(stage, entity string) bool {
+	return !s.Disabled && s.Stage == stage && (s.Entity == "*" || strings.EqualFold(s.Entity, entity))
+}
+
+// Service stores tenants' scripts and runs them at QuickBooks hook points
+// as a qbclient.Transformer
+type Service struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewService creates a new scripting service
+func NewService(client redis.UniversalClient, prefix string) *Service {
+	return &Service{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key holds a tenant's scripts
+func (s *Service) key(tenantID string) string {
+	return fmt.Sprintf("%s:scripts:%s", s.prefix, tenantID)
+}
+
+// ListScripts returns a tenant's scripts in run order
+func (s *Service) ListScripts(ctx context.Context, tenantID string) ([]Script, error) {
+	data, err := s.client.Get(ctx, s.key(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return []Script{}, nil
+		}
+		return nil, fmt.Errorf("failed to get scripts: %w", err)
+	}
+
+	var scripts []Script
+	if err := json.Unmarshal(data, &scripts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scripts: %w", err)
+	}
+	return scripts, nil
+}
+
+// SaveScript validates a script and adds it, or replaces the script with
+// the same name in place
+func (s *Service) SaveScript(ctx context.Context, tenantID string, script Script) error {
+	if err := script.Validate(); err != nil {
+		return err
+	}
+
+	scripts, err := s.ListScripts(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for n := range scripts {
+		if scripts[n].Name == script.Name {
+			scripts[n] = script
+			replaced = true
+		}
+	}
+	if !replaced {
+		if len(scripts) >= maxScripts {
+			return fmt.Errorf("at most %d scripts are allowed", maxScripts)
+		}
+		scripts = append(scripts, script)
+	}
+	return s.save(ctx, tenantID, scripts)
+}
+
+// DeleteScript removes a tenant's script
+func (s *Service) DeleteScript(ctx context.Context, tenantID, name string) error {
+	scripts, err := s.ListScripts(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	for n := range scripts {
+		if scripts[n].Name == name {
+			return s.save(ctx, tenantID, append(scripts[:n], scripts[n+1:]...))
+		}
+	}
+	return ErrScriptNotFound
+}
+
+// save replaces a tenant's scripts
+func (s *Service) save(ctx context.Context, tenantID string, scripts []Script) error {
+	data, err := json.Marshal(scripts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scripts: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save scripts: %w", err)
+	}
+	return nil
+}
+
+// Run executes one script over payload without storing it
+func (s *Service) Run(ctx context.Context, script Script, payload map[string]interface{}) (*Result, error) {
+	realmID, _ := auth.GetCompanyID(ctx)
+	return execute(ctx, script.Name, script.Source, payload, map[string]interface{}{
+		"entity":    script.Entity,
+		"stage":     script.Stage,
+		"tenant_id": auth.GetTenantID(ctx),
+		"realm_id":  realmID,
+	})
+}
+
+// Transform runs the current tenant's scripts for the hook point in order.
+// Calls without a tenant, such as operator tooling, pass through unchanged.
+func (s *Service) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		return data, nil
+	}
+
+	scripts, err := s.ListScripts(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, script := range scripts {
+		if !script.matches(stage, entity) {
+			continue
+		}
+
+		// Scripts see the entity being written, not the request's
+		script.Entity = entity
+		result, err := s.Run(ctx, script, data)
+		if err != nil {
+			if script.FailOpen && !errors.Is(err, ErrRejected) {
+				logging.FromContext(ctx).Warn("script failed open", "script", script.Name, "tenant_id", tenantID, "error", err)
+				continue
+			}
+			return nil, fmt.Errorf("script %s: %w", script.Name, err)
+		}
+		data = result.Payload
+	}
+	return data, nil
+}
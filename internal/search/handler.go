@@ -0,0 +1,111 @@
+// search/handler.go
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+)
+
+// Reindexer rebuilds a realm's search documents. *Indexer implements it.
+type Reindexer interface {
+	Reindex(ctx context.Context, realmID string) (int, error)
+}
+
+// Handler provides HTTP handlers for semantic search
+type Handler struct {
+	service Servicer
+	indexer Reindexer
+}
+
+// NewHandler creates a new search handler
+func NewHandler(service Servicer, indexer Reindexer) *Handler {
+	return &Handler{
+		service: service,
+		indexer: indexer,
+	}
+}
+
+// Search finds entities matching a natural-language query
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	var filter Filter
+	if types := query.Get("types"); types != "" {
+		filter.EntityTypes = strings.Split(types, ",")
+	}
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		filter.From = &t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		filter.To = &t
+	}
+
+	results, err := h.service.Search(r.Context(), realmID, q, limit, filter)
+	if err != nil {
+		http.Error(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   q,
+		"results": results,
+	})
+}
+
+// Reindex rebuilds the search index for the current company in the background
+func (h *Handler) Reindex(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	// Keep the request's user and company but outlive the request
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		count, err := h.indexer.Reindex(ctx, realmID)
+		if err != nil {
+			logging.FromContext(ctx).Error("search reindex failed", "realm_id", realmID, "documents", count, "error", err)
+			return
+		}
+		logging.FromContext(ctx).Info("search reindex finished", "realm_id", realmID, "documents", count)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "reindexing",
+	})
+}
@@ -0,0 +1,176 @@
+// search/indexer.go
+package search
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// pageSize is the number of entities fetched per QuickBooks query
+const pageSize = 500
+
+// Querier runs QuickBooks query statements
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// Indexer builds search documents from QuickBooks entities
+type Indexer struct {
+	service Servicer
+	querier Querier
+}
+
+// NewIndexer creates a new indexer
+func NewIndexer(service Servicer, querier Querier) *Indexer {
+	return &Indexer{
+		service: service,
+		querier: querier,
+	}
+}
+
+// Reindex rebuilds the realm's documents for customers, items, and invoices
+func (i *Indexer) Reindex(ctx context.Context, realmID string) (int, error) {
+	ctx = qbclient.WithPriority(ctx, qbclient.PriorityBackground)
+	total := 0
+	for _, index := range []func(context.Context, string, int) (int, error){
+		i.indexCustomers,
+		i.indexItems,
+		i.indexInvoices,
+	} {
+		for start := 1; ; start += pageSize {
+			count, err := index(ctx, realmID, start)
+			if err != nil {
+				return total, err
+			}
+			total += count
+			if count < pageSize {
+				break
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// indexCustomers indexes one page of customers
+func (i *Indexer) indexCustomers(ctx context.Context, realmID string, start int) (int, error) {
+	type customer struct {
+		ID               string `json:"Id"`
+		DisplayName      string `json:"DisplayName"`
+		CompanyName      string `json:"CompanyName"`
+		Notes            string `json:"Notes"`
+		PrimaryEmailAddr struct {
+			Address string `json:"Address"`
+		} `json:"PrimaryEmailAddr"`
+	}
+	customers, err := query.List[customer](ctx, i.querier, i.page("Customer", start))
+	if err != nil {
+		return 0, err
+	}
+
+	docs := make([]Document, 0, len(customers))
+	for _, c := range customers {
+		docs = append(docs, Document{
+			RealmID:    realmID,
+			EntityType: EntityCustomer,
+			EntityID:   c.ID,
+			Text:       joinText("Customer", c.DisplayName, c.CompanyName, c.PrimaryEmailAddr.Address, c.Notes),
+		})
+	}
+
+	return len(customers), i.service.Index(ctx, docs)
+}
+
+// indexItems indexes one page of items
+func (i *Indexer) indexItems(ctx context.Context, realmID string, start int) (int, error) {
+	type entry struct {
+		ID          string `json:"Id"`
+		Name        string `json:"Name"`
+		Sku         string `json:"Sku"`
+		Description string `json:"Description"`
+	}
+	items, err := query.List[entry](ctx, i.querier, i.page("Item", start))
+	if err != nil {
+		return 0, err
+	}
+
+	docs := make([]Document, 0, len(items))
+	for _, item := range items {
+		docs = append(docs, Document{
+			RealmID:    realmID,
+			EntityType: EntityItem,
+			EntityID:   item.ID,
+			Text:       joinText("Item", item.Name, item.Sku, item.Description),
+		})
+	}
+
+	return len(items), i.service.Index(ctx, docs)
+}
+
+// indexInvoices indexes one page of invoices
+func (i *Indexer) indexInvoices(ctx context.Context, realmID string, start int) (int, error) {
+	type invoice struct {
+		ID           string `json:"Id"`
+		DocNumber    string `json:"DocNumber"`
+		TxnDate      string `json:"TxnDate"`
+		PrivateNote  string `json:"PrivateNote"`
+		CustomerMemo struct {
+			Value string `json:"value"`
+		} `json:"CustomerMemo"`
+		CustomerRef struct {
+			Name string `json:"name"`
+		} `json:"CustomerRef"`
+		Line []struct {
+			Description string `json:"Description"`
+		} `json:"Line"`
+	}
+	invoices, err := query.List[invoice](ctx, i.querier, i.page("Invoice", start))
+	if err != nil {
+		return 0, err
+	}
+
+	docs := make([]Document, 0, len(invoices))
+	for _, inv := range invoices {
+		parts := []string{"Invoice " + inv.DocNumber, inv.CustomerRef.Name, inv.CustomerMemo.Value, inv.PrivateNote}
+		for _, line := range inv.Line {
+			parts = append(parts, line.Description)
+		}
+
+		doc := Document{
+			RealmID:    realmID,
+			EntityType: EntityInvoice,
+			EntityID:   inv.ID,
+			Text:       joinText("", parts...),
+		}
+		if t, err := time.Parse("2006-01-02", inv.TxnDate); err == nil {
+			doc.TxnDate = &t
+			doc.Text += " (dated " + t.Format("January 2, 2006") + ")"
+		}
+		docs = append(docs, doc)
+	}
+
+	return len(invoices), i.service.Index(ctx, docs)
+}
+
+// page selects the page of an entity's records beginning at start
+func (i *Indexer) page(entity string, start int) *query.Builder {
+	return query.Select(entity).Limit(pageSize).Offset(start - 1)
+}
+
+// joinText builds document text from non-empty parts
+func joinText(label string, parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts)+1)
+	if label != "" {
+		nonEmpty = append(nonEmpty, label+":")
+	}
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
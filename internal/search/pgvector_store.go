@@ -0,0 +1,157 @@
+// search/pgvector_store.go
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PgVectorStore implements VectorStore with PostgreSQL and the pgvector extension.
+// The caller is responsible for opening db with a registered Postgres driver.
+type PgVectorStore struct {
+	db        *sql.DB
+	dimension int
+}
+
+// NewPgVectorStore creates a new pgvector-backed vector store
+func NewPgVectorStore(db *sql.DB, dimension int) *PgVectorStore {
+	return &PgVectorStore{
+		db:        db,
+		dimension: dimension,
+	}
+}
+
+// EnsureSchema creates the extension, table, and index if needed
+func (s *PgVectorStore) EnsureSchema(ctx context.Context) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS search_documents (
+			realm_id    TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id   TEXT NOT NULL,
+			text        TEXT NOT NULL,
+			txn_date    TIMESTAMPTZ,
+			embedding   vector(%d) NOT NULL,
+			PRIMARY KEY (realm_id, entity_type, entity_id)
+		)`, s.dimension),
+		`CREATE INDEX IF NOT EXISTS search_documents_embedding_idx
+			ON search_documents USING hnsw (embedding vector_cosine_ops)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to prepare search schema: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Upsert inserts or replaces documents
+func (s *PgVectorStore) Upsert(ctx context.Context, docs []Document) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, doc := range docs {
+		if len(doc.Vector) != s.dimension {
+			return fmt.Errorf("document %s/%s has dimension %d, expected %d",
+				doc.EntityType, doc.EntityID, len(doc.Vector), s.dimension)
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO search_documents (realm_id, entity_type, entity_id, text, txn_date, embedding)
+			VALUES ($1, $2, $3, $4, $5, $6::vector)
+			ON CONFLICT (realm_id, entity_type, entity_id)
+			DO UPDATE SET text = EXCLUDED.text, txn_date = EXCLUDED.txn_date, embedding = EXCLUDED.embedding`,
+			doc.RealmID, doc.EntityType, doc.EntityID, doc.Text, doc.TxnDate, vectorLiteral(doc.Vector),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store search document: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit search documents: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a document
+func (s *PgVectorStore) Delete(ctx context.Context, realmID, entityType, entityID string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM search_documents WHERE realm_id = $1 AND entity_type = $2 AND entity_id = $3`,
+		realmID, entityType, entityID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete search document: %w", err)
+	}
+	return nil
+}
+
+// Search returns the documents nearest to the vector by cosine distance
+func (s *PgVectorStore) Search(ctx context.Context, realmID string, vector []float32, limit int, filter Filter) ([]Result, error) {
+	query := `SELECT realm_id, entity_type, entity_id, text, txn_date, embedding <=> $1::vector AS distance
+		FROM search_documents WHERE realm_id = $2`
+	args := []interface{}{vectorLiteral(vector), realmID}
+
+	if len(filter.EntityTypes) > 0 {
+		placeholders := make([]string, len(filter.EntityTypes))
+		for i, t := range filter.EntityTypes {
+			args = append(args, t)
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		}
+		query += " AND entity_type IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += " AND txn_date >= $" + strconv.Itoa(len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += " AND txn_date <= $" + strconv.Itoa(len(args))
+	}
+
+	args = append(args, limit)
+	query += " ORDER BY distance LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search query failed: %w", err)
+	}
+	defer rows.Close()
+
+	results := []Result{}
+	for rows.Next() {
+		var result Result
+		var txnDate sql.NullTime
+		var distance float64
+		if err := rows.Scan(&result.RealmID, &result.EntityType, &result.EntityID, &result.Text, &txnDate, &distance); err != nil {
+			return nil, fmt.Errorf("failed to read search result: %w", err)
+		}
+		if txnDate.Valid {
+			t := txnDate.Time.In(time.UTC)
+			result.TxnDate = &t
+		}
+		result.Score = 1 - distance
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// vectorLiteral formats a vector in pgvector's text representation
+func vectorLiteral(vector []float32) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
@@ -0,0 +1,210 @@
+// search/redis_store.go
+package search
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisVectorStore implements VectorStore with RediSearch vector similarity
+type RedisVectorStore struct {
+	client    redis.UniversalClient
+	prefix    string
+	dimension int
+}
+
+// NewRedisVectorStore creates a new RediSearch-backed vector store
+func NewRedisVectorStore(client redis.UniversalClient, prefix string, dimension int) *RedisVectorStore {
+	return &RedisVectorStore{
+		client:    client,
+		prefix:    prefix,
+		dimension: dimension,
+	}
+}
+
+// indexName is the RediSearch index name
+func (s *RedisVectorStore) indexName() string {
+	return s.prefix + ":search:idx"
+}
+
+// docPrefix is the key prefix covered by the index
+func (s *RedisVectorStore) docPrefix() string {
+	return s.prefix + ":search:doc:"
+}
+
+// key generates the Redis key for a document
+func (s *RedisVectorStore) key(realmID, entityType, entityID string) string {
+	return fmt.Sprintf("%s%s:%s:%s", s.docPrefix(), realmID, entityType, entityID)
+}
+
+// EnsureIndex creates the RediSearch index if it does not exist
+func (s *RedisVectorStore) EnsureIndex(ctx context.Context) error {
+	err := s.client.Do(ctx, "FT.INFO", s.indexName()).Err()
+	if err == nil {
+		return nil
+	}
+
+	err = s.client.Do(ctx,
+		"FT.CREATE", s.indexName(), "ON", "HASH", "PREFIX", "1", s.docPrefix(),
+		"SCHEMA",
+		"realm_id", "TAG",
+		"entity_type", "TAG",
+		"entity_id", "TAG",
+		"text", "TEXT",
+		"txn_date", "NUMERIC",
+		"embedding", "VECTOR", "HNSW", "6",
+		"TYPE", "FLOAT32", "DIM", strconv.Itoa(s.dimension), "DISTANCE_METRIC", "COSINE",
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	return nil
+}
+
+// Upsert stores documents as hashes covered by the index
+func (s *RedisVectorStore) Upsert(ctx context.Context, docs []Document) error {
+	pipe := s.client.Pipeline()
+	for _, doc := range docs {
+		if len(doc.Vector) != s.dimension {
+			return fmt.Errorf("document %s/%s has dimension %d, expected %d",
+				doc.EntityType, doc.EntityID, len(doc.Vector), s.dimension)
+		}
+
+		fields := map[string]interface{}{
+			"realm_id":    doc.RealmID,
+			"entity_type": doc.EntityType,
+			"entity_id":   doc.EntityID,
+			"text":        doc.Text,
+			"embedding":   encodeVector(doc.Vector),
+		}
+		if doc.TxnDate != nil {
+			fields["txn_date"] = doc.TxnDate.Unix()
+		}
+		pipe.HSet(ctx, s.key(doc.RealmID, doc.EntityType, doc.EntityID), fields)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store search documents: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a document
+func (s *RedisVectorStore) Delete(ctx context.Context, realmID, entityType, entityID string) error {
+	if err := s.client.Del(ctx, s.key(realmID, entityType, entityID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete search document: %w", err)
+	}
+	return nil
+}
+
+// Search runs a KNN query restricted to the realm and filter
+func (s *RedisVectorStore) Search(ctx context.Context, realmID string, vector []float32, limit int, filter Filter) ([]Result, error) {
+	clauses := []string{fmt.Sprintf("@realm_id:{%s}", escapeTag(realmID))}
+	if len(filter.EntityTypes) > 0 {
+		types := make([]string, len(filter.EntityTypes))
+		for i, t := range filter.EntityTypes {
+			types[i] = escapeTag(t)
+		}
+		clauses = append(clauses, fmt.Sprintf("@entity_type:{%s}", strings.Join(types, "|")))
+	}
+	if filter.From != nil || filter.To != nil {
+		from, to := "-inf", "+inf"
+		if filter.From != nil {
+			from = strconv.FormatInt(filter.From.Unix(), 10)
+		}
+		if filter.To != nil {
+			to = strconv.FormatInt(filter.To.Unix(), 10)
+		}
+		clauses = append(clauses, fmt.Sprintf("@txn_date:[%s %s]", from, to))
+	}
+
+	query := fmt.Sprintf("(%s)=>[KNN %d @embedding $vec AS distance]", strings.Join(clauses, " "), limit)
+
+	raw, err := s.client.Do(ctx,
+		"FT.SEARCH", s.indexName(), query,
+		"PARAMS", "2", "vec", encodeVector(vector),
+		"SORTBY", "distance",
+		"RETURN", "6", "realm_id", "entity_type", "entity_id", "text", "txn_date", "distance",
+		"LIMIT", "0", strconv.Itoa(limit),
+		"DIALECT", "2",
+	).Result()
+	if err != nil {
+		return nil, fmt.Errorf("search query failed: %w", err)
+	}
+
+	return parseSearchReply(raw)
+}
+
+// parseSearchReply converts an FT.SEARCH reply into results
+func parseSearchReply(raw interface{}) ([]Result, error) {
+	reply, ok := raw.([]interface{})
+	if !ok || len(reply) == 0 {
+		return nil, fmt.Errorf("unexpected search reply")
+	}
+
+	results := []Result{}
+	// Reply is [total, key1, [field, value, ...], key2, [...], ...]
+	for i := 2; i < len(reply); i += 2 {
+		fields, ok := reply[i].([]interface{})
+		if !ok {
+			continue
+		}
+
+		values := make(map[string]string, len(fields)/2)
+		for j := 0; j+1 < len(fields); j += 2 {
+			name, _ := fields[j].(string)
+			value, _ := fields[j+1].(string)
+			values[name] = value
+		}
+
+		result := Result{
+			Document: Document{
+				RealmID:    values["realm_id"],
+				EntityType: values["entity_type"],
+				EntityID:   values["entity_id"],
+				Text:       values["text"],
+			},
+		}
+		if ts, err := strconv.ParseInt(values["txn_date"], 10, 64); err == nil {
+			t := time.Unix(ts, 0).UTC()
+			result.TxnDate = &t
+		}
+		if distance, err := strconv.ParseFloat(values["distance"], 64); err == nil {
+			result.Score = 1 - distance
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// encodeVector serializes a vector as little-endian float32 bytes
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// escapeTag escapes punctuation in a RediSearch tag value
+func escapeTag(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+// search/search.go
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Entity types covered by the index
+const (
+	EntityCustomer = "customer"
+	EntityItem     = "item"
+	EntityInvoice  = "invoice"
+)
+
+// Document is a searchable piece of text describing a QuickBooks entity
+type Document struct {
+	RealmID    string     `json:"realm_id"`
+	EntityType string     `json:"entity_type"`
+	EntityID   string     `json:"entity_id"`
+	Text       string     `json:"text"`
+	TxnDate    *time.Time `json:"txn_date,omitempty"`
+	Vector     []float32  `json:"-"`
+}
+
+// Result is a document matched by a search
+type Result struct {
+	Document
+	Score float64 `json:"score"` // cosine similarity, higher is closer
+}
+
+// Filter narrows a search
+type Filter struct {
+	EntityTypes []string
+	From        *time.Time
+	To          *time.Time
+}
+
+// VectorStore persists document embeddings and performs nearest-neighbour search
+type VectorStore interface {
+	Upsert(ctx context.Context, docs []Document) error
+	Search(ctx context.Context, realmID string, vector []float32, limit int, filter Filter) ([]Result, error)
+	Delete(ctx context.Context, realmID, entityType, entityID string) error
+}
+
+// Embedder turns text into embedding vectors
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Servicer indexes and searches entity documents. *Service implements it;
+// tests can substitute a fake.
+type Servicer interface {
+	Index(ctx context.Context, docs []Document) error
+	Remove(ctx context.Context, realmID, entityType, entityID string) error
+	Search(ctx context.Context, realmID, query string, limit int, filter Filter) ([]Result, error)
+}
+
+// Service indexes and searches entity documents
+type Service struct {
+	embedder Embedder
+	store    VectorStore
+}
+
+// NewService creates a new search service
+func NewService(embedder Embedder, store VectorStore) *Service {
+	return &Service{
+		embedder: embedder,
+		store:    store,
+	}
+}
+
+// Index embeds and stores documents
+func (s *Service) Index(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Text
+	}
+
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed documents: %w", err)
+	}
+	if len(vectors) != len(docs) {
+		return fmt.Errorf("embedder returned %d vectors for %d documents", len(vectors), len(docs))
+	}
+
+	for i := range docs {
+		docs[i].Vector = vectors[i]
+	}
+
+	return s.store.Upsert(ctx, docs)
+}
+
+// Remove deletes an entity from the index
+func (s *Service) Remove(ctx context.Context, realmID, entityType, entityID string) error {
+	return s.store.Delete(ctx, realmID, entityType, entityID)
+}
+
+// Search finds the documents closest in meaning to the query
+func (s *Service) Search(ctx context.Context, realmID, query string, limit int, filter Filter) ([]Result, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned no vector for query")
+	}
+
+	return s.store.Search(ctx, realmID, vectors[0], limit, filter)
+}
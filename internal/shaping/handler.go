@@ -0,0 +1,65 @@
+// shaping/handler.go
+package shaping
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler provides operator endpoints for response profiles
+type Handler struct {
+	profiles *Profiles
+}
+
+// NewHandler creates a new response profile handler
+func NewHandler(profiles *Profiles) *Handler {
+	return &Handler{
+		profiles: profiles,
+	}
+}
+
+// ListProfiles returns every response profile
+func (h *Handler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.profiles.List(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list response profiles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// SaveProfile creates or replaces the profile named in the path
+func (h *Handler) SaveProfile(w http.ResponseWriter, r *http.Request) {
+	var profile Profile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	profile.Name = mux.Vars(r)["name"]
+	if err := profile.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.profiles.Save(r.Context(), &profile); err != nil {
+		http.Error(w, "Failed to save response profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(profile)
+}
+
+// DeleteProfile removes the profile named in the path
+func (h *Handler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	if err := h.profiles.Delete(r.Context(), mux.Vars(r)["name"]); err != nil {
+		http.Error(w, "Failed to delete response profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,307 @@
+// shaping/middleware.go
+package shaping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Header selects a response profile by name
+const Header = "X-Response-Profile"
+
+// maxExpansions bounds the entities read to expand one response
+const maxExpansions = 50
+
+// refEntities are the entities of reference fields that can be expanded
+var refEntities = map[string]string{
+	"AccountRef":          "Account",
+	"APAccountRef":        "Account",
+	"ARAccountRef":        "Account",
+	"ClassRef":            "Class",
+	"CustomerRef":         "Customer",
+	"DepartmentRef":       "Department",
+	"DepositToAccountRef": "Account",
+	"EmployeeRef":         "Employee",
+	"ItemRef":             "Item",
+	"PaymentMethodRef":    "PaymentMethod",
+	"SalesTermRef":        "Term",
+	"TermRef":             "Term",
+	"VendorRef":           "Vendor",
+}
+
+// refEntity returns the entity a reference field points to
+func refEntity(field string) (string, bool) {
+	entity, ok := refEntities[field]
+	return entity, ok
+}
+
+// Reader reads QuickBooks entities to expand references
+type Reader interface {
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// Shaper trims and expands JSON responses for the profile a client selects
+// with the X-Response-Profile header, or for its ?fields= and ?expand=
+// parameters, which override the profile's
+type Shaper struct {
+	profiles *Profiles
+	qb       Reader
+}
+
+// NewShaper creates a new response shaper
+func NewShaper(profiles *Profiles, qb Reader) *Shaper {
+	return &Shaper{
+		profiles: profiles,
+		qb:       qb,
+	}
+}
+
+// splitList splits a comma-separated parameter
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Middleware applies the request's profile to GET requests: it defaults
+// ?limit= to the profile's page size, then masks and expands the records of
+// successful JSON responses. Other requests pass through unbuffered.
+func (s *Shaper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", Header)
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var profile Profile
+		if name := r.Header.Get(Header); name != "" {
+			selected, err := s.profiles.Get(r.Context(), name)
+			if errors.Is(err, ErrProfileNotFound) {
+				http.Error(w, "Unknown response profile "+strconv.Quote(name), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to get response profile: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			profile = *selected
+			w.Header().Set(Header, profile.Name)
+		}
+
+		query := r.URL.Query()
+		fields, expand := profile.Fields, profile.Expand
+		if value := query.Get("fields"); value != "" {
+			fields = splitList(value)
+		}
+		if value := query.Get("expand"); value != "" {
+			expand = splitList(value)
+			for _, field := range expand {
+				if _, ok := refEntity(field); !ok {
+					http.Error(w, field+" cannot be expanded", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		if profile.PageSize > 0 && !query.Has("limit") {
+			query.Set("limit", strconv.Itoa(profile.PageSize))
+			r.URL.RawQuery = query.Encode()
+		}
+		if len(fields) == 0 && len(expand) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		body := recorder.body.Bytes()
+		if recorder.status == http.StatusOK && strings.HasPrefix(recorder.header.Get("Content-Type"), "application/json") {
+			if shaped, ok := s.shape(r.Context(), body, fields, expand); ok {
+				body = shaped
+			}
+		}
+
+		for key, values := range recorder.header {
+			w.Header()[key] = values
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(recorder.status)
+		w.Write(body)
+	})
+}
+
+// shape decodes a JSON body, expands then masks its records
+func (s *Shaper) shape(ctx context.Context, body []byte, fields, expand []string) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	found := records(value)
+	if len(expand) > 0 {
+		s.expand(ctx, found, expand)
+	}
+	if len(fields) > 0 {
+		// Records stay identifiable whatever the mask
+		mask := newMask(append([]string{"Id"}, fields...))
+		for _, record := range found {
+			mask.apply(record)
+		}
+	}
+
+	shaped, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return append(shaped, '\n'), true
+}
+
+// records returns the records of a response: the elements of an array, of
+// a v2 envelope's data, or the response object itself
+func records(value interface{}) []map[string]interface{} {
+	var list []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		list = v
+	case map[string]interface{}:
+		data, ok := v["data"].([]interface{})
+		if !ok {
+			return []map[string]interface{}{v}
+		}
+		list = data
+	}
+	found := make([]map[string]interface{}, 0, len(list))
+	for _, element := range list {
+		if record, ok := element.(map[string]interface{}); ok {
+			found = append(found, record)
+		}
+	}
+	return found
+}
+
+// expand embeds the entity of each named reference as its "entity" field.
+// Each entity is read once per response, and references beyond
+// maxExpansions or whose entity cannot be read are left as they are.
+func (s *Shaper) expand(ctx context.Context, found []map[string]interface{}, fields []string) {
+	read := make(map[string]interface{})
+	for _, record := range found {
+		for _, field := range fields {
+			ref, ok := record[field].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := ref["value"].(string)
+			if id == "" {
+				continue
+			}
+			entity, _ := refEntity(field)
+			key := entity + ":" + id
+			expanded, seen := read[key]
+			if !seen {
+				if len(read) >= maxExpansions {
+					continue
+				}
+				expanded = s.readEntity(ctx, entity, id)
+				read[key] = expanded
+			}
+			if expanded != nil {
+				ref["entity"] = expanded
+			}
+		}
+	}
+}
+
+// readEntity reads an entity as generic JSON, or nil if it cannot be read
+func (s *Shaper) readEntity(ctx context.Context, entity, id string) interface{} {
+	fields, err := s.qb.Read(ctx, entity, id)
+	if err != nil {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	return value
+}
+
+// mask is a tree of the field paths to keep; a leaf keeps the whole field
+type mask map[string]mask
+
+// newMask builds a mask from dot-separated paths
+func newMask(paths []string) mask {
+	root := make(mask)
+	for _, path := range paths {
+		node := root
+		for _, name := range strings.Split(path, ".") {
+			child, ok := node[name]
+			if !ok {
+				child = make(mask)
+				node[name] = child
+			}
+			node = child
+		}
+	}
+	return root
+}
+
+// apply removes the fields the mask does not keep from objects in value,
+// including each object of a list
+func (m mask) apply(value interface{}) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			m.apply(element)
+		}
+	case map[string]interface{}:
+		for name, child := range v {
+			sub, ok := m[name]
+			if !ok {
+				delete(v, name)
+				continue
+			}
+			if len(sub) > 0 {
+				sub.apply(child)
+			}
+		}
+	}
+}
+
+// bufferedResponse captures a handler's response for rewriting
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the captured headers
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the status code
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// Write captures the body
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
@@ -0,0 +1,162 @@
+// shaping/profile.go
+package shaping
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/go-redis/redis/v8"
+)
+
+// profileTTL is how long profiles are cached in memory, so changes reach
+// other instances within it
+const profileTTL = time.Minute
+
+// maxPageSize bounds a profile's default page size
+const maxPageSize = 1000
+
+// ErrProfileNotFound is returned for a profile that does not exist
+var ErrProfileNotFound = errors.New("response profile not found")
+
+// profileName matches valid profile names, e.g. "mobile"
+var profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Profile shapes the responses of clients that select it, e.g. "mobile" or
+// "warehouse". Each setting is a default the request's own ?fields=,
+// ?expand= and ?limit= parameters override.
+type Profile struct {
+	Name string `json:"name"`
+	// Fields is the field mask applied to each returned record, as
+	// dot-separated paths such as "CustomerRef.name"; empty keeps every
+	// field
+	Fields []string `json:"fields,omitempty"`
+	// Expand names reference fields, such as "CustomerRef", whose entity is
+	// read and embedded in the reference
+	Expand []string `json:"expand,omitempty"`
+	// PageSize is the default ?limit= of list requests
+	PageSize int `json:"page_size,omitempty"`
+}
+
+// Validate checks a profile's settings
+func (p *Profile) Validate() error {
+	if !profileName.MatchString(p.Name) {
+		return fmt.Errorf("name must be lower-case letters, digits, '-' or '_'")
+	}
+	for _, path := range p.Fields {
+		if strings.TrimSpace(path) == "" || strings.Contains(path, "..") {
+			return fmt.Errorf("invalid field path %q", path)
+		}
+	}
+	for _, field := range p.Expand {
+		if _, ok := refEntity(field); !ok {
+			return fmt.Errorf("%s cannot be expanded", field)
+		}
+	}
+	if p.PageSize < 0 || p.PageSize > maxPageSize {
+		return fmt.Errorf("page_size must be between 0 and %d", maxPageSize)
+	}
+	return nil
+}
+
+// cachedProfile is a profile as last read; nil if it did not exist
+type cachedProfile struct {
+	profile *Profile
+	fetched time.Time
+}
+
+// Profiles stores response profiles
+type Profiles struct {
+	client redis.UniversalClient
+	prefix string
+
+	cache *lru.Cache[string, cachedProfile]
+}
+
+// NewProfiles creates a new response profile store
+func NewProfiles(client redis.UniversalClient, prefix string) *Profiles {
+	return &Profiles{
+		client: client,
+		prefix: prefix,
+		cache:  lru.New[string, cachedProfile]("shaping.profiles", 1000),
+	}
+}
+
+// key returns the hash of profiles by name
+func (p *Profiles) key() string {
+	return fmt.Sprintf("%s:shaping:profiles", p.prefix)
+}
+
+// Save creates or replaces a profile
+func (p *Profiles) Save(ctx context.Context, profile *Profile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response profile: %w", err)
+	}
+	if err := p.client.HSet(ctx, p.key(), profile.Name, data).Err(); err != nil {
+		return fmt.Errorf("failed to save response profile: %w", err)
+	}
+	p.cache.Purge()
+	return nil
+}
+
+// Get returns a profile by name. Profiles are cached briefly.
+func (p *Profiles) Get(ctx context.Context, name string) (*Profile, error) {
+	if cached, ok := p.cache.Get(name); ok && time.Since(cached.fetched) < profileTTL {
+		if cached.profile == nil {
+			return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+		}
+		return cached.profile, nil
+	}
+
+	data, err := p.client.HGet(ctx, p.key(), name).Result()
+	if err == redis.Nil {
+		p.cache.Add(name, cachedProfile{fetched: time.Now()})
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response profile: %w", err)
+	}
+	var profile Profile
+	if err := json.Unmarshal([]byte(data), &profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response profile: %w", err)
+	}
+	p.cache.Add(name, cachedProfile{profile: &profile, fetched: time.Now()})
+	return &profile, nil
+}
+
+// List returns every profile ordered by name
+func (p *Profiles) List(ctx context.Context) ([]Profile, error) {
+	values, err := p.client.HGetAll(ctx, p.key()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list response profiles: %w", err)
+	}
+	profiles := make([]Profile, 0, len(values))
+	for _, v := range values {
+		var profile Profile
+		if err := json.Unmarshal([]byte(v), &profile); err != nil {
+			continue
+		}
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// Delete removes a profile. Clients still selecting it are refused.
+func (p *Profiles) Delete(ctx context.Context, name string) error {
+	if err := p.client.HDel(ctx, p.key(), name).Err(); err != nil {
+		return fmt.Errorf("failed to delete response profile: %w", err)
+	}
+	p.cache.Purge()
+	return nil
+}
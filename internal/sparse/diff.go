@@ -0,0 +1,165 @@
+// sparse/diff.go
+package sparse
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change operations
+const (
+	OpAdd    = "add"
+	OpRemove = "remove"
+	OpUpdate = "update"
+)
+
+// Change is one field-level difference an update makes. Paths address
+// list entries by their Id, e.g. "Line[3].Amount"; entries without an Id
+// are new, e.g. "Line[new:2]".
+type Change struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ComputedFields are worked out by QuickBooks, so full updates leaving them
+// out do not clear them
+var ComputedFields = map[string]bool{
+	"TotalAmt":           true,
+	"Balance":            true,
+	"HomeTotalAmt":       true,
+	"HomeBalance":        true,
+	"FullyQualifiedName": true,
+	"Level":              true,
+}
+
+// Diff returns the changes an update payload makes to the current entity,
+// ordered by path. Sparse updates change only the fields they set, each
+// replacing the current value whole; full updates also clear the writable
+// fields they leave out.
+func Diff(current, payload map[string]interface{}) []Change {
+	a, _ := Generic(current).(map[string]interface{})
+	b, _ := Generic(payload).(map[string]interface{})
+	isSparse, _ := b["sparse"].(bool)
+
+	var changes []Change
+	for _, name := range unionKeys(a, b) {
+		if ReadOnlyFields[name] {
+			continue
+		}
+		before, inA := a[name]
+		after, inB := b[name]
+		switch {
+		case !inB && (isSparse || ComputedFields[name]):
+		case !inA:
+			changes = append(changes, Change{Op: OpAdd, Path: name, New: after})
+		case !inB:
+			changes = append(changes, Change{Op: OpRemove, Path: name, Old: before})
+		default:
+			diffValue(name, before, after, &changes)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// diffValue appends the differences between a and b at path
+func diffValue(path string, a, b interface{}, changes *[]Change) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range unionKeys(av, bv) {
+			childPath := path + "." + key
+			before, inA := av[key]
+			after, inB := bv[key]
+			switch {
+			case !inA:
+				*changes = append(*changes, Change{Op: OpAdd, Path: childPath, New: after})
+			case !inB:
+				*changes = append(*changes, Change{Op: OpRemove, Path: childPath, Old: before})
+			default:
+				diffValue(childPath, before, after, changes)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if ok && keyedByID(av) {
+			diffLines(path, av, bv, changes)
+			return
+		}
+	}
+
+	*changes = append(*changes, Change{Op: OpUpdate, Path: path, Old: a, New: b})
+}
+
+// keyedByID reports whether every entry of a list is an object with an Id,
+// as the lines of a stored transaction are
+func keyedByID(list []interface{}) bool {
+	for _, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := entry["Id"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// diffLines matches list entries by Id so that edits to one line are
+// reported against that line
+func diffLines(path string, a, b []interface{}, changes *[]Change) {
+	current := make(map[string]interface{}, len(a))
+	for _, item := range a {
+		current[item.(map[string]interface{})["Id"].(string)] = item
+	}
+
+	kept := make(map[string]bool)
+	for i, item := range b {
+		entry, _ := item.(map[string]interface{})
+		id, _ := entry["Id"].(string)
+		before, ok := current[id]
+		if id == "" || !ok {
+			key := id
+			if key == "" {
+				key = fmt.Sprintf("new:%d", i)
+			}
+			*changes = append(*changes, Change{Op: OpAdd, Path: fmt.Sprintf("%s[%s]", path, key), New: item})
+			continue
+		}
+		kept[id] = true
+		diffValue(fmt.Sprintf("%s[%s]", path, id), before, item, changes)
+	}
+	for _, item := range a {
+		id := item.(map[string]interface{})["Id"].(string)
+		if !kept[id] {
+			*changes = append(*changes, Change{Op: OpRemove, Path: fmt.Sprintf("%s[%s]", path, id), Old: item})
+		}
+	}
+}
+
+// unionKeys returns the keys of both maps in sorted order
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
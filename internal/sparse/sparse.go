@@ -0,0 +1,87 @@
+// sparse/sparse.go
+package sparse
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// ReadOnlyFields are never compared or sent when updating an entity
+var ReadOnlyFields = map[string]bool{
+	"Id":        true,
+	"SyncToken": true,
+	"sparse":    true,
+	"MetaData":  true,
+	"domain":    true,
+}
+
+// Changed lists the payload's top-level fields that differ from the
+// existing record. Nested objects only need the fields the payload sets.
+func Changed(existing, payload map[string]interface{}) []string {
+	var changed []string
+	for name, value := range payload {
+		if ReadOnlyFields[name] {
+			continue
+		}
+		if !Contains(Generic(existing[name]), Generic(value)) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Contains reports whether have holds want; objects match when every field
+// of want matches
+func Contains(have, want interface{}) bool {
+	wantMap, ok := want.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(have, want)
+	}
+	haveMap, ok := have.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, value := range wantMap {
+		if !Contains(haveMap[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge overlays a payload object onto the existing one, because sparse
+// updates replace nested objects such as addresses whole
+func Merge(existing, value interface{}) interface{} {
+	valueMap, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	existingMap, ok := Generic(existing).(map[string]interface{})
+	if !ok {
+		return value
+	}
+	merged := make(map[string]interface{}, len(existingMap)+len(valueMap))
+	for key, v := range existingMap {
+		merged[key] = v
+	}
+	for key, v := range valueMap {
+		merged[key] = Merge(existingMap[key], v)
+	}
+	return merged
+}
+
+// Generic round-trips a value through JSON so numbers and nested objects
+// compare equal whatever Go types they were decoded into
+func Generic(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
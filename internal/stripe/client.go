@@ -0,0 +1,181 @@
+// stripe/client.go
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// apiBaseURL is the Stripe REST API root
+const apiBaseURL = "https://api.stripe.com/v1"
+
+// Payout is a transfer from the Stripe balance to the tenant's bank
+type Payout struct {
+	ID          string `json:"id"`
+	Amount      int64  `json:"amount"` // minor units
+	Currency    string `json:"currency"`
+	ArrivalDate int64  `json:"arrival_date"`
+	Created     int64  `json:"created"`
+	Status      string `json:"status"`
+}
+
+// Charge is the subset of a Stripe charge used for invoice matching
+type Charge struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description"`
+	Metadata    map[string]string `json:"metadata"`
+	BillingName string            `json:"-"`
+}
+
+// BalanceTransaction is a movement of funds included in a payout
+type BalanceTransaction struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`   // charge, payment, refund, payment_refund, adjustment, payout, ...
+	Amount      int64           `json:"amount"` // gross, minor units
+	Fee         int64           `json:"fee"`
+	Net         int64           `json:"net"`
+	Currency    string          `json:"currency"`
+	Created     int64           `json:"created"`
+	Description string          `json:"description"`
+	Source      json.RawMessage `json:"source"` // expanded object or ID
+}
+
+// Charge returns the expanded source charge, if any
+func (t BalanceTransaction) Charge() *Charge {
+	var charge struct {
+		Object         string            `json:"object"`
+		ID             string            `json:"id"`
+		Description    string            `json:"description"`
+		Metadata       map[string]string `json:"metadata"`
+		BillingDetails struct {
+			Name string `json:"name"`
+		} `json:"billing_details"`
+	}
+	if json.Unmarshal(t.Source, &charge) != nil || charge.Object != "charge" {
+		return nil
+	}
+	return &Charge{
+		ID:          charge.ID,
+		Description: charge.Description,
+		Metadata:    charge.Metadata,
+		BillingName: charge.BillingDetails.Name,
+	}
+}
+
+// Client calls the Stripe API with a tenant's secret key
+type Client struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new Stripe API client
+func NewClient(apiKey string) *Client {
+	return &Client{
+		apiKey:     apiKey,
+		baseURL:    apiBaseURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// list pages through a Stripe list endpoint, calling fn for each page's raw data
+func (c *Client) list(ctx context.Context, path string, params url.Values, fn func(data json.RawMessage) (lastID string, err error)) error {
+	for {
+		endpoint := c.baseURL + path + "?" + params.Encode()
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.SetBasicAuth(c.apiKey, "")
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("stripe request failed: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read stripe response: %w", err)
+		}
+
+		if resp.StatusCode >= 400 {
+			var stripeErr struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if json.Unmarshal(body, &stripeErr) == nil && stripeErr.Error.Message != "" {
+				return fmt.Errorf("stripe API error: %s", stripeErr.Error.Message)
+			}
+			return fmt.Errorf("stripe API returned status %d", resp.StatusCode)
+		}
+
+		var page struct {
+			Data    json.RawMessage `json:"data"`
+			HasMore bool            `json:"has_more"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return fmt.Errorf("failed to parse stripe response: %w", err)
+		}
+
+		lastID, err := fn(page.Data)
+		if err != nil {
+			return err
+		}
+		if !page.HasMore || lastID == "" {
+			return nil
+		}
+		params.Set("starting_after", lastID)
+	}
+}
+
+// Payouts returns paid payouts created at or after since
+func (c *Client) Payouts(ctx context.Context, since time.Time) ([]Payout, error) {
+	params := url.Values{}
+	params.Set("limit", "100")
+	params.Set("status", "paid")
+	params.Set("created[gte]", strconv.FormatInt(since.Unix(), 10))
+
+	var payouts []Payout
+	err := c.list(ctx, "/payouts", params, func(data json.RawMessage) (string, error) {
+		var page []Payout
+		if err := json.Unmarshal(data, &page); err != nil {
+			return "", fmt.Errorf("failed to decode payouts: %w", err)
+		}
+		payouts = append(payouts, page...)
+		if len(page) == 0 {
+			return "", nil
+		}
+		return page[len(page)-1].ID, nil
+	})
+	return payouts, err
+}
+
+// PayoutTransactions returns the balance transactions settled by a payout,
+// with charge sources expanded
+func (c *Client) PayoutTransactions(ctx context.Context, payoutID string) ([]BalanceTransaction, error) {
+	params := url.Values{}
+	params.Set("limit", "100")
+	params.Set("payout", payoutID)
+	params.Add("expand[]", "data.source")
+
+	var txns []BalanceTransaction
+	err := c.list(ctx, "/balance_transactions", params, func(data json.RawMessage) (string, error) {
+		var page []BalanceTransaction
+		if err := json.Unmarshal(data, &page); err != nil {
+			return "", fmt.Errorf("failed to decode balance transactions: %w", err)
+		}
+		txns = append(txns, page...)
+		if len(page) == 0 {
+			return "", nil
+		}
+		return page[len(page)-1].ID, nil
+	})
+	return txns, err
+}
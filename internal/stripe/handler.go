@@ -0,0 +1,127 @@
+// stripe/handler.go
+package stripe
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// Handler provides HTTP handlers for Stripe payout reconciliation
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new Stripe handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetConfig returns the tenant's Stripe configuration without its API key
+func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	config, err := h.service.GetConfig(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get Stripe config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	config.APIKey = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config)
+}
+
+// SaveConfig connects Stripe for the tenant and binds it to the current
+// company; an omitted api_key keeps the stored one
+func (h *Handler) SaveConfig(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var config Config
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	config.RealmID = realmID
+	config.UserID = auth.GetUserID(r.Context())
+
+	if err := h.service.SaveConfig(r.Context(), auth.GetTenantID(r.Context()), &config); err != nil {
+		http.Error(w, "Failed to save Stripe config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	config.APIKey = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config)
+}
+
+// SyncPayouts reconciles payouts since ?since= (default 30 days ago)
+func (h *Handler) SyncPayouts(w http.ResponseWriter, r *http.Request) {
+	config, err := h.service.GetConfig(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to get Stripe config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if realmID, err := auth.GetCompanyID(r.Context()); err != nil || realmID != config.RealmID {
+		http.Error(w, "Stripe is connected to a different QuickBooks company", http.StatusConflict)
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -30)
+	if v := r.URL.Query().Get("since"); v != "" {
+		if since, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid since date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.service.Sync(r.Context(), config, since)
+	if err != nil {
+		http.Error(w, "Failed to reconcile payouts: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reconciled": results,
+	})
+}
+
+// ListPayouts returns the current company's reconciled payouts
+func (h *Handler) ListPayouts(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	payouts, err := h.service.Reconciled(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to list payouts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"payouts": payouts,
+	})
+}
@@ -0,0 +1,426 @@
+// stripe/reconcile.go
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/go-redis/redis/v8"
+)
+
+// PayoutSyncJobType identifies recurring payout reconciliation in the jobs subsystem
+const PayoutSyncJobType = "stripe_payout_sync"
+
+// defaultInvoiceMetadataKey is the charge metadata key holding the QuickBooks invoice
+const defaultInvoiceMetadataKey = "invoice_id"
+
+// ErrNotConfigured is returned when a tenant has not connected Stripe
+var ErrNotConfigured = errors.New("stripe is not configured")
+
+// Accounts maps Stripe activity to QuickBooks account IDs
+type Accounts struct {
+	Bank             string `json:"bank"`              // account payouts arrive in
+	UndepositedFunds string `json:"undeposited_funds"` // where matched invoice payments are received
+	Fees             string `json:"fees"`              // processing fee expense
+	Refunds          string `json:"refunds"`           // refunds and disputes
+	UnmatchedIncome  string `json:"unmatched_income"`  // charges without a matching invoice
+}
+
+// Config is a tenant's Stripe connection
+type Config struct {
+	APIKey             string    `json:"api_key,omitempty"`
+	RealmID            string    `json:"realm_id"`
+	UserID             string    `json:"user_id"`
+	Accounts           Accounts  `json:"accounts"`
+	InvoiceMetadataKey string    `json:"invoice_metadata_key,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// Validate checks the key and account mapping
+func (c *Config) Validate() error {
+	if !strings.HasPrefix(c.APIKey, "sk_") && !strings.HasPrefix(c.APIKey, "rk_") {
+		return fmt.Errorf("api_key must be a Stripe secret or restricted key")
+	}
+	a := c.Accounts
+	for name, value := range map[string]string{
+		"bank":              a.Bank,
+		"undeposited_funds": a.UndepositedFunds,
+		"fees":              a.Fees,
+		"refunds":           a.Refunds,
+		"unmatched_income":  a.UnmatchedIncome,
+	} {
+		if value == "" {
+			return fmt.Errorf("accounts.%s is required", name)
+		}
+	}
+	return nil
+}
+
+// QuickBooks is the subset of the QuickBooks client used to record payouts
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+}
+
+// PayoutResult is the QuickBooks record of a reconciled payout
+type PayoutResult struct {
+	PayoutID         string       `json:"payout_id"`
+	Amount           money.Amount `json:"amount"`
+	Currency         string       `json:"currency"`
+	ArrivalDate      string       `json:"arrival_date"`
+	DepositID        string       `json:"deposit_id"`
+	MatchedCharges   int          `json:"matched_charges"`
+	UnmatchedTotal   money.Amount `json:"unmatched_total"`
+	Fees             money.Amount `json:"fees"`
+	Refunds          money.Amount `json:"refunds"`
+	PaymentIDs       []string     `json:"payment_ids,omitempty"`
+	UnmatchedCharges []string     `json:"unmatched_charges,omitempty"`
+	ReconciledAt     time.Time    `json:"reconciled_at"`
+}
+
+// Service reconciles Stripe payouts into QuickBooks deposits
+type Service struct {
+	client    redis.UniversalClient
+	prefix    string
+	qb        QuickBooks
+	scheduler *jobs.Scheduler
+}
+
+// NewService creates a new Stripe reconciliation service and registers its job runner
+func NewService(client redis.UniversalClient, prefix string, qb QuickBooks, scheduler *jobs.Scheduler) *Service {
+	service := &Service{
+		client:    client,
+		prefix:    prefix,
+		qb:        qb,
+		scheduler: scheduler,
+	}
+	scheduler.RegisterRunner(PayoutSyncJobType, jobs.RunnerFunc(service.runSync))
+	return service
+}
+
+// configKey holds a tenant's Stripe configuration
+func (s *Service) configKey(tenantID string) string {
+	return fmt.Sprintf("%s:stripe:config:%s", s.prefix, tenantID)
+}
+
+// payoutsKey is the hash of payout ID to reconciliation result for a realm
+func (s *Service) payoutsKey(realmID string) string {
+	return fmt.Sprintf("%s:stripe:payouts:%s", s.prefix, realmID)
+}
+
+// paymentsKey is the hash of charge ID to QuickBooks payment ID for a realm
+func (s *Service) paymentsKey(realmID string) string {
+	return fmt.Sprintf("%s:stripe:payments:%s", s.prefix, realmID)
+}
+
+// GetConfig returns a tenant's Stripe configuration
+func (s *Service) GetConfig(ctx context.Context, tenantID string) (*Config, error) {
+	data, err := s.client.Get(ctx, s.configKey(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrNotConfigured
+		}
+		return nil, fmt.Errorf("failed to get stripe config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stripe config: %w", err)
+	}
+	return &config, nil
+}
+
+// SaveConfig stores a tenant's Stripe configuration and schedules a daily payout sync
+func (s *Service) SaveConfig(ctx context.Context, tenantID string, config *Config) error {
+	if config.APIKey == "" {
+		if existing, err := s.GetConfig(ctx, tenantID); err == nil {
+			config.APIKey = existing.APIKey
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.InvoiceMetadataKey == "" {
+		config.InvoiceMetadataKey = defaultInvoiceMetadataKey
+	}
+	config.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stripe config: %w", err)
+	}
+	if err := s.client.Set(ctx, s.configKey(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save stripe config: %w", err)
+	}
+
+	return s.ensureScheduled(ctx, tenantID, config)
+}
+
+// ensureScheduled creates the tenant's daily payout sync unless one is active
+func (s *Service) ensureScheduled(ctx context.Context, tenantID string, config *Config) error {
+	existing, err := s.scheduler.Store().ListByTenant(ctx, tenantID, PayoutSyncJobType)
+	if err != nil {
+		return err
+	}
+	for _, job := range existing {
+		if job.Status == jobs.StatusActive {
+			return nil
+		}
+	}
+
+	job, err := jobs.NewJob(PayoutSyncJobType, tenantID, config.UserID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create payout sync job: %w", err)
+	}
+	job.Schedule = (&jobs.Schedule{Frequency: jobs.FrequencyDaily, Hour: 4}).Localize(ctx)
+	job.NextRunAt = job.Schedule.Next(time.Now())
+
+	return s.scheduler.Store().Save(ctx, job)
+}
+
+// Reconciled returns a realm's reconciled payouts
+func (s *Service) Reconciled(ctx context.Context, realmID string) ([]PayoutResult, error) {
+	values, err := s.client.HGetAll(ctx, s.payoutsKey(realmID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciled payouts: %w", err)
+	}
+
+	results := make([]PayoutResult, 0, len(values))
+	for _, v := range values {
+		var result PayoutResult
+		if json.Unmarshal([]byte(v), &result) == nil {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Sync reconciles every paid payout since the given time that has not been
+// recorded yet. The context must carry the tenant's QuickBooks identity.
+func (s *Service) Sync(ctx context.Context, config *Config, since time.Time) ([]PayoutResult, error) {
+	client := NewClient(config.APIKey)
+	payouts, err := client.Payouts(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PayoutResult
+	for _, payout := range payouts {
+		done, err := s.client.HExists(ctx, s.payoutsKey(config.RealmID), payout.ID).Result()
+		if err != nil {
+			return results, fmt.Errorf("failed to check payout: %w", err)
+		}
+		if done {
+			continue
+		}
+
+		result, err := s.reconcile(ctx, client, config, payout)
+		if err != nil {
+			return results, fmt.Errorf("payout %s: %w", payout.ID, err)
+		}
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+// reconcile records one payout as invoice payments and a bank deposit
+func (s *Service) reconcile(ctx context.Context, client *Client, config *Config, payout Payout) (*PayoutResult, error) {
+	txns, err := client.PayoutTransactions(ctx, payout.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PayoutResult{
+		PayoutID:    payout.ID,
+		Amount:      toAmount(payout.Amount),
+		Currency:    strings.ToUpper(payout.Currency),
+		ArrivalDate: time.Unix(payout.ArrivalDate, 0).UTC().Format("2006-01-02"),
+	}
+
+	var lines []map[string]interface{}
+	addAccountLine := func(account string, amount money.Amount, description string) {
+		lines = append(lines, map[string]interface{}{
+			"Amount":      amount,
+			"Description": description,
+			"DetailType":  "DepositLineDetail",
+			"DepositLineDetail": map[string]interface{}{
+				"AccountRef": map[string]string{"value": account},
+			},
+		})
+	}
+
+	var fees int64
+	for _, txn := range txns {
+		if txn.Type == "payout" {
+			continue
+		}
+		fees += txn.Fee
+
+		switch txn.Type {
+		case "charge", "payment":
+			charge := txn.Charge()
+			paymentID := ""
+			if charge != nil {
+				paymentID, err = s.receivePayment(ctx, config, txn, charge)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if paymentID != "" {
+				result.MatchedCharges++
+				result.PaymentIDs = append(result.PaymentIDs, paymentID)
+				lines = append(lines, map[string]interface{}{
+					"Amount": toAmount(txn.Amount),
+					"LinkedTxn": []map[string]string{{
+						"TxnId":   paymentID,
+						"TxnType": "Payment",
+					}},
+				})
+				continue
+			}
+			result.UnmatchedCharges = append(result.UnmatchedCharges, txn.ID)
+			result.UnmatchedTotal = money.NewAmount(result.UnmatchedTotal.Add(toAmount(txn.Amount).Decimal))
+			addAccountLine(config.Accounts.UnmatchedIncome, toAmount(txn.Amount), "Stripe "+txn.ID+" "+txn.Description)
+		case "refund", "payment_refund", "adjustment", "payment_failure_refund":
+			result.Refunds = money.NewAmount(result.Refunds.Add(toAmount(txn.Amount).Decimal))
+			addAccountLine(config.Accounts.Refunds, toAmount(txn.Amount), "Stripe "+txn.Type+" "+txn.ID)
+		default:
+			addAccountLine(config.Accounts.UnmatchedIncome, toAmount(txn.Amount), "Stripe "+txn.Type+" "+txn.ID)
+		}
+	}
+	if fees != 0 {
+		result.Fees = toAmount(fees)
+		addAccountLine(config.Accounts.Fees, money.NewAmount(result.Fees.Neg()), "Stripe processing fees")
+	}
+
+	deposit := map[string]interface{}{
+		"TxnDate":             result.ArrivalDate,
+		"DepositToAccountRef": map[string]string{"value": config.Accounts.Bank},
+		"PrivateNote":         "Stripe payout " + payout.ID,
+		"Line":                lines,
+	}
+	var created struct {
+		Deposit struct {
+			ID       string       `json:"Id"`
+			TotalAmt money.Amount `json:"TotalAmt"`
+		} `json:"Deposit"`
+	}
+	if err := s.qb.Create(ctx, "Deposit", deposit, &created); err != nil {
+		return nil, err
+	}
+	result.DepositID = created.Deposit.ID
+	result.ReconciledAt = time.Now()
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payout result: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.payoutsKey(config.RealmID), payout.ID, data).Err(); err != nil {
+		return nil, fmt.Errorf("failed to record payout: %w", err)
+	}
+
+	return result, nil
+}
+
+// receivePayment records a charge as a payment against the invoice named in
+// its metadata, returning the payment ID or "" when no invoice matches.
+// Payments already recorded for the charge are reused.
+func (s *Service) receivePayment(ctx context.Context, config *Config, txn BalanceTransaction, charge *Charge) (string, error) {
+	if existing, err := s.client.HGet(ctx, s.paymentsKey(config.RealmID), charge.ID).Result(); err == nil {
+		return existing, nil
+	}
+
+	ref := charge.Metadata[config.InvoiceMetadataKey]
+	if ref == "" {
+		return "", nil
+	}
+
+	type match struct {
+		ID          string `json:"Id"`
+		CustomerRef struct {
+			Value string `json:"value"`
+		} `json:"CustomerRef"`
+	}
+	var found []match
+	for _, field := range []string{"Id", "DocNumber"} {
+		var err error
+		found, err = query.List[match](ctx, s.qb, query.Select("Invoice", "Id", "CustomerRef").Where(field, "=", ref))
+		if err != nil {
+			return "", fmt.Errorf("failed to find invoice %s: %w", ref, err)
+		}
+		if len(found) > 0 {
+			break
+		}
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	invoice := found[0]
+
+	amount := toAmount(txn.Amount)
+	payment := map[string]interface{}{
+		"CustomerRef":         map[string]string{"value": invoice.CustomerRef.Value},
+		"TotalAmt":            amount,
+		"TxnDate":             time.Unix(txn.Created, 0).UTC().Format("2006-01-02"),
+		"PaymentRefNum":       truncate(charge.ID, 21),
+		"DepositToAccountRef": map[string]string{"value": config.Accounts.UndepositedFunds},
+		"PrivateNote":         "Stripe charge " + charge.ID,
+		"Line": []map[string]interface{}{{
+			"Amount": amount,
+			"LinkedTxn": []map[string]string{{
+				"TxnId":   invoice.ID,
+				"TxnType": "Invoice",
+			}},
+		}},
+	}
+	var created struct {
+		Payment struct {
+			ID string `json:"Id"`
+		} `json:"Payment"`
+	}
+	if err := s.qb.Create(ctx, "Payment", payment, &created); err != nil {
+		return "", err
+	}
+
+	if err := s.client.HSet(ctx, s.paymentsKey(config.RealmID), charge.ID, created.Payment.ID).Err(); err != nil {
+		return "", fmt.Errorf("failed to record payment: %w", err)
+	}
+	return created.Payment.ID, nil
+}
+
+// runSync is the job runner for scheduled payout syncs
+func (s *Service) runSync(ctx context.Context, job *jobs.Job) error {
+	config, err := s.GetConfig(ctx, job.TenantID)
+	if err != nil {
+		return err
+	}
+
+	ctx = auth.WithIdentity(ctx, config.UserID, job.TenantID, config.RealmID)
+
+	// Look back far enough to pick up payouts that were pending at the last run
+	_, err = s.Sync(ctx, config, time.Now().AddDate(0, 0, -14))
+	return err
+}
+
+// toAmount converts Stripe minor units to an amount. Zero-decimal
+// currencies are not supported.
+func toAmount(minor int64) money.Amount {
+	return money.NewAmount(money.FromMinor(minor, 2))
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
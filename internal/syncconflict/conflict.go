@@ -0,0 +1,191 @@
+// syncconflict/conflict.go
+package syncconflict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// retention is how long resolved conflicts are kept
+const retention = 90 * 24 * time.Hour
+
+// Conflict statuses
+const (
+	StatusOpen      = "open"
+	StatusResolved  = "resolved"
+	StatusDismissed = "dismissed" // the draft was dropped without writing it
+)
+
+// Sides of a conflict a resolution keeps
+const (
+	SideQuickBooks = "quickbooks"
+	SideLocal      = "local"
+)
+
+// ErrConflictNotFound is returned for conflicts that do not exist in the
+// current company or have expired
+var ErrConflictNotFound = errors.New("conflict not found")
+
+// FieldConflict is a field that both QuickBooks and a draft changed since
+// the draft's base, to different values
+type FieldConflict struct {
+	Field string `json:"field"`
+	// Base is the value the draft started from; it is unknown when the
+	// server kept no snapshot of the base
+	Base       interface{} `json:"base,omitempty"`
+	QuickBooks interface{} `json:"quickbooks"`
+	Local      interface{} `json:"local"`
+}
+
+// Conflict is a draft held for review because it conflicts with changes
+// made in QuickBooks since it was started
+type Conflict struct {
+	ID       string `json:"id"`
+	RealmID  string `json:"realm_id"`
+	Entity   string `json:"entity"`
+	EntityID string `json:"entity_id"`
+	// BaseSyncToken is the version the draft started from, SyncToken the
+	// version QuickBooks had when the conflict was found
+	BaseSyncToken string          `json:"base_sync_token"`
+	SyncToken     string          `json:"sync_token"`
+	Fields        []FieldConflict `json:"fields"`
+	// Local is every change the draft makes, conflicting or not
+	Local     map[string]interface{} `json:"local"`
+	Status    string                 `json:"status"`
+	CreatedBy string                 `json:"created_by"`
+	CreatedAt time.Time              `json:"created_at"`
+	// Choices is the side kept per conflicting field once resolved
+	Choices    map[string]string `json:"choices,omitempty"`
+	ResolvedBy string            `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time        `json:"resolved_at,omitempty"`
+}
+
+// conflicting reports whether a field is one of the conflict's fields
+func (c *Conflict) conflicting(field string) bool {
+	for _, f := range c.Fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists conflicts per company. Open conflicts are kept until they
+// are resolved; resolved ones for the retention period.
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new conflict store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// conflictKey holds a conflict
+func (s *Store) conflictKey(realmID, id string) string {
+	return fmt.Sprintf("%s:syncconflict:%s:%s", s.prefix, realmID, id)
+}
+
+// indexKey is the sorted set of a company's conflict IDs in a status by
+// the time they were created or resolved
+func (s *Store) indexKey(realmID, status string) string {
+	return fmt.Sprintf("%s:syncconflict:%s:index:%s", s.prefix, realmID, status)
+}
+
+// Save writes a conflict and files it under its status
+func (s *Store) Save(ctx context.Context, conflict *Conflict) error {
+	data, err := json.Marshal(conflict)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conflict: %w", err)
+	}
+
+	key := s.conflictKey(conflict.RealmID, conflict.ID)
+	open := s.indexKey(conflict.RealmID, StatusOpen)
+	closed := s.indexKey(conflict.RealmID, StatusResolved)
+	pipe := s.client.TxPipeline()
+	if conflict.Status == StatusOpen {
+		pipe.Set(ctx, key, data, 0)
+		pipe.ZAdd(ctx, open, &redis.Z{Score: float64(conflict.CreatedAt.Unix()), Member: conflict.ID})
+	} else {
+		resolvedAt := time.Now()
+		if conflict.ResolvedAt != nil {
+			resolvedAt = *conflict.ResolvedAt
+		}
+		pipe.Set(ctx, key, data, retention)
+		pipe.ZRem(ctx, open, conflict.ID)
+		pipe.ZAdd(ctx, closed, &redis.Z{Score: float64(resolvedAt.Unix()), Member: conflict.ID})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save conflict: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a conflict
+func (s *Store) Get(ctx context.Context, realmID, id string) (*Conflict, error) {
+	data, err := s.client.Get(ctx, s.conflictKey(realmID, id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrConflictNotFound
+		}
+		return nil, fmt.Errorf("failed to get conflict: %w", err)
+	}
+
+	var conflict Conflict
+	if err := json.Unmarshal(data, &conflict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal conflict: %w", err)
+	}
+	return &conflict, nil
+}
+
+// List returns a company's open conflicts, oldest first, or with closed
+// set, its resolved and dismissed ones, newest first
+func (s *Store) List(ctx context.Context, realmID string, closed bool) ([]*Conflict, error) {
+	var ids []string
+	var err error
+	if closed {
+		index := s.indexKey(realmID, StatusResolved)
+		cutoff := time.Now().Add(-retention).Unix()
+		s.client.ZRemRangeByScore(ctx, index, "-inf", fmt.Sprintf("(%d", cutoff))
+		ids, err = s.client.ZRevRange(ctx, index, 0, -1).Result()
+	} else {
+		ids, err = s.client.ZRange(ctx, s.indexKey(realmID, StatusOpen), 0, -1).Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conflicts: %w", err)
+	}
+	conflicts := make([]*Conflict, 0, len(ids))
+	if len(ids) == 0 {
+		return conflicts, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.conflictKey(realmID, id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conflicts: %w", err)
+	}
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var conflict Conflict
+		if err := json.Unmarshal([]byte(data), &conflict); err != nil {
+			continue
+		}
+		conflicts = append(conflicts, &conflict)
+	}
+	return conflicts, nil
+}
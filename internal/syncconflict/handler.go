@@ -0,0 +1,139 @@
+// syncconflict/handler.go
+package syncconflict
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/versions"
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for pushing drafts and reviewing their
+// conflicts
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new sync conflict handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrConflictNotFound), errors.Is(err, ErrNotFound), errors.Is(err, versions.ErrUnknownEntity):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNotOpen), errors.Is(err, ErrStale), errors.Is(err, writelock.ErrTimeout):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidDraft), errors.Is(err, ErrInvalidResolution):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// PushDraft writes a local draft of a record, e.g. POST
+// /api/invoices/42/sync. Drafts held for review are answered with 409 and
+// the conflict's location.
+func (h *Handler) PushDraft(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	entity, ok := versions.EntityName(vars["entity"])
+	if !ok {
+		http.Error(w, "Failed to push draft: "+versions.ErrUnknownEntity.Error(), status(versions.ErrUnknownEntity))
+		return
+	}
+
+	var draft Draft
+	if err := json.NewDecoder(r.Body).Decode(&draft); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	outcome, err := h.service.Push(r.Context(), entity, vars["id"], &draft)
+	if err != nil {
+		http.Error(w, "Failed to push draft: "+err.Error(), status(err))
+		return
+	}
+
+	code := http.StatusOK
+	if outcome.Status == OutcomeHeld {
+		w.Header().Set("Location", "/api/sync/conflicts/"+outcome.Conflict.ID)
+		code = http.StatusConflict
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(outcome)
+}
+
+// ListConflicts lists the company's unresolved conflicts, oldest first.
+// ?status=closed lists resolved and dismissed ones instead.
+func (h *Handler) ListConflicts(w http.ResponseWriter, r *http.Request) {
+	closed := false
+	switch r.URL.Query().Get("status") {
+	case "", StatusOpen:
+	case "closed":
+		closed = true
+	default:
+		http.Error(w, "Invalid status, expected open or closed", http.StatusBadRequest)
+		return
+	}
+
+	conflicts, err := h.service.List(r.Context(), closed)
+	if err != nil {
+		http.Error(w, "Failed to list conflicts: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conflicts": conflicts,
+	})
+}
+
+// GetConflict returns a conflict
+func (h *Handler) GetConflict(w http.ResponseWriter, r *http.Request) {
+	conflict, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get conflict: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(conflict)
+}
+
+// ResolveConflict writes a held draft keeping the chosen side of each
+// conflicting field, e.g. {"keep": "quickbooks", "fields": {"DueDate": "local"}}
+func (h *Handler) ResolveConflict(w http.ResponseWriter, r *http.Request) {
+	var resolution Resolution
+	if err := json.NewDecoder(r.Body).Decode(&resolution); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	outcome, err := h.service.Resolve(r.Context(), mux.Vars(r)["id"], &resolution)
+	if err != nil {
+		http.Error(w, "Failed to resolve conflict: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(outcome)
+}
+
+// DismissConflict drops a held draft, leaving the record as QuickBooks has it
+func (h *Handler) DismissConflict(w http.ResponseWriter, r *http.Request) {
+	conflict, err := h.service.Dismiss(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to dismiss conflict: "+err.Error(), status(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(conflict)
+}
@@ -0,0 +1,412 @@
+// syncconflict/service.go
+package syncconflict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/sparse"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/internal/versions"
+)
+
+// Outcome statuses
+const (
+	OutcomeApplied   = "applied"   // the draft's changes were written
+	OutcomeUnchanged = "unchanged" // QuickBooks already had them
+	OutcomeHeld      = "held"      // the draft awaits review as a conflict
+)
+
+var (
+	// ErrInvalidDraft is returned for drafts without changes or base
+	ErrInvalidDraft = errors.New("invalid draft")
+	// ErrInvalidResolution is returned for resolutions that do not pick a
+	// side for every conflicting field
+	ErrInvalidResolution = errors.New("invalid resolution")
+	// ErrNotOpen is returned when resolving a conflict already closed
+	ErrNotOpen = errors.New("conflict is already closed")
+	// ErrStale is returned when resolving a conflict whose entity changed
+	// in QuickBooks again; push the draft again to compare afresh
+	ErrStale = errors.New("entity changed in QuickBooks since the conflict was found")
+	// ErrNotFound is returned for entities QuickBooks does not have
+	ErrNotFound = errors.New("entity not found")
+)
+
+// errHeld and errUnchanged stop a write that is held or has nothing to do
+var (
+	errHeld      = errors.New("draft held for review")
+	errUnchanged = errors.New("entity already matches the draft")
+)
+
+// QuickBooks is the subset of the QuickBooks client used to write drafts
+type QuickBooks interface {
+	Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error
+}
+
+// Draft is a local edit of a QuickBooks entity, made against the version
+// of the entity it was started from
+type Draft struct {
+	BaseSyncToken string `json:"base_sync_token"`
+	// Base is the entity the draft was started from, for entities the
+	// server has no snapshot of at BaseSyncToken
+	Base   map[string]interface{} `json:"base,omitempty"`
+	Fields map[string]interface{} `json:"fields"`
+	// Policy overrides the tenant's conflict policy for this draft
+	Policy string `json:"policy,omitempty"`
+}
+
+// Resolution picks the side kept for each field of a conflict
+type Resolution struct {
+	// Keep is the side kept for fields without their own choice
+	Keep   string            `json:"keep,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Outcome is the result of pushing a draft or resolving its conflict
+type Outcome struct {
+	Status string                 `json:"status"`
+	Policy string                 `json:"policy"`
+	Entity map[string]interface{} `json:"entity,omitempty"`
+	// Applied are the fields written; Kept the conflicting fields left
+	// as QuickBooks has them
+	Applied   []string        `json:"applied"`
+	Kept      []string        `json:"kept,omitempty"`
+	Conflicts []FieldConflict `json:"conflicts,omitempty"`
+	Conflict  *Conflict       `json:"conflict,omitempty"`
+}
+
+// Service writes local drafts to QuickBooks, resolving fields that were
+// changed on both sides by the tenant's conflict policy
+type Service struct {
+	store    *Store
+	versions *versions.Store
+	config   *tenantconfig.Service
+	qb       QuickBooks
+}
+
+// NewService creates a new sync conflict service. Drafts' bases are looked
+// up in the version history.
+func NewService(store *Store, versionStore *versions.Store, config *tenantconfig.Service, qb QuickBooks) *Service {
+	return &Service{
+		store:    store,
+		versions: versionStore,
+		config:   config,
+		qb:       qb,
+	}
+}
+
+// Push writes a draft's changes to an entity. Fields the draft changed
+// that QuickBooks left alone since the base are written. Fields both sides
+// changed go by the policy: QuickBooks wins keeps them as they are, local
+// wins overwrites them, and manual review holds the whole draft as a
+// conflict.
+func (s *Service) Push(ctx context.Context, entity, id string, draft *Draft) (*Outcome, error) {
+	if draft.BaseSyncToken == "" {
+		return nil, fmt.Errorf("%w: base_sync_token is required", ErrInvalidDraft)
+	}
+	if len(draft.Fields) == 0 {
+		return nil, fmt.Errorf("%w: no fields to change", ErrInvalidDraft)
+	}
+	for name := range draft.Fields {
+		if sparse.ReadOnlyFields[name] || sparse.ComputedFields[name] {
+			return nil, fmt.Errorf("%w: %s cannot be changed", ErrInvalidDraft, name)
+		}
+	}
+	policy := draft.Policy
+	if policy == "" {
+		policy = s.config.ConflictPolicy(ctx, auth.GetTenantID(ctx), entity)
+	}
+	if policy != tenantconfig.ConflictQuickBooksWins && policy != tenantconfig.ConflictLocalWins && policy != tenantconfig.ConflictManual {
+		return nil, fmt.Errorf("%w: unknown policy %q", ErrInvalidDraft, policy)
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &Outcome{Policy: policy, Applied: []string{}}
+	var held *Conflict
+	var updated map[string]map[string]interface{}
+	err = s.qb.Modify(ctx, entity, id, func(raw map[string]json.RawMessage) (map[string]interface{}, error) {
+		current, _ := sparse.Generic(raw).(map[string]interface{})
+		base := s.base(ctx, realmID, entity, id, draft, current)
+		clean, conflicts := analyze(base, current, draft.Fields)
+		outcome.Conflicts = conflicts
+
+		apply := clean
+		if len(conflicts) > 0 {
+			switch policy {
+			case tenantconfig.ConflictLocalWins:
+				for _, conflict := range conflicts {
+					apply = append(apply, conflict.Field)
+				}
+				sort.Strings(apply)
+			case tenantconfig.ConflictQuickBooksWins:
+				for _, conflict := range conflicts {
+					outcome.Kept = append(outcome.Kept, conflict.Field)
+				}
+			default:
+				syncToken, _ := current["SyncToken"].(string)
+				held = &Conflict{
+					ID:            jobs.NewID(),
+					RealmID:       realmID,
+					Entity:        entity,
+					EntityID:      id,
+					BaseSyncToken: draft.BaseSyncToken,
+					SyncToken:     syncToken,
+					Fields:        conflicts,
+					Local:         make(map[string]interface{}, len(clean)+len(conflicts)),
+					Status:        StatusOpen,
+					CreatedBy:     auth.GetUserID(ctx),
+					CreatedAt:     time.Now().UTC(),
+				}
+				for _, name := range clean {
+					held.Local[name] = draft.Fields[name]
+				}
+				for _, conflict := range conflicts {
+					held.Local[conflict.Field] = conflict.Local
+				}
+				return nil, errHeld
+			}
+		}
+		if len(apply) == 0 {
+			return nil, errUnchanged
+		}
+
+		update := map[string]interface{}{"sparse": true}
+		for _, name := range apply {
+			update[name] = sparse.Merge(current[name], draft.Fields[name])
+		}
+		outcome.Applied = apply
+		return update, nil
+	}, &updated)
+
+	switch {
+	case errors.Is(err, errHeld):
+		if err := s.store.Save(ctx, held); err != nil {
+			return nil, err
+		}
+		outcome.Status = OutcomeHeld
+		outcome.Conflict = held
+		return outcome, nil
+	case errors.Is(err, errUnchanged):
+		outcome.Status = OutcomeUnchanged
+		return outcome, nil
+	case err != nil:
+		return nil, notFound(err)
+	}
+	outcome.Status = OutcomeApplied
+	outcome.Entity = updated[entity]
+	return outcome, nil
+}
+
+// base returns the entity a draft was started from: the current entity if
+// QuickBooks has not changed it since, else the draft's own copy or the
+// server's snapshot. It returns nil when the base is unknown.
+func (s *Service) base(ctx context.Context, realmID, entity, id string, draft *Draft, current map[string]interface{}) map[string]interface{} {
+	if syncToken, _ := current["SyncToken"].(string); syncToken == draft.BaseSyncToken {
+		return current
+	}
+	if draft.Base != nil {
+		base, _ := sparse.Generic(draft.Base).(map[string]interface{})
+		return base
+	}
+	version, err := s.versions.ByToken(ctx, realmID, entity, id, draft.BaseSyncToken)
+	if err != nil {
+		return nil
+	}
+	base, _ := sparse.Generic(version.Entity).(map[string]interface{})
+	return base
+}
+
+// analyze compares a draft's fields with the current entity, by name.
+// Fields the draft leaves as they were in the base, or that already match
+// QuickBooks, are skipped. Of the rest, those QuickBooks changed since the
+// base conflict and the others are clean. Without a base every field that
+// differs from QuickBooks conflicts.
+func analyze(base, current, fields map[string]interface{}) ([]string, []FieldConflict) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clean := []string{}
+	var conflicts []FieldConflict
+	for _, name := range names {
+		local := sparse.Generic(fields[name])
+		if sparse.Contains(current[name], local) {
+			continue
+		}
+		if base != nil && sparse.Contains(base[name], local) {
+			continue
+		}
+		if base != nil && reflect.DeepEqual(base[name], current[name]) {
+			clean = append(clean, name)
+			continue
+		}
+		conflict := FieldConflict{Field: name, QuickBooks: current[name], Local: local}
+		if base != nil {
+			conflict.Base = base[name]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return clean, conflicts
+}
+
+// List returns the current company's open conflicts, or with closed set,
+// its resolved and dismissed ones
+func (s *Service) List(ctx context.Context, closed bool) ([]*Conflict, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.List(ctx, realmID, closed)
+}
+
+// Get returns one of the current company's conflicts
+func (s *Service) Get(ctx context.Context, id string) (*Conflict, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, realmID, id)
+}
+
+// Resolve writes a held draft with the chosen side of each conflicting
+// field. The draft's clean changes are written too. Conflicts whose entity
+// changed in QuickBooks again are stale unless nothing is written.
+func (s *Service) Resolve(ctx context.Context, id string, resolution *Resolution) (*Outcome, error) {
+	conflict, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if conflict.Status != StatusOpen {
+		return nil, ErrNotOpen
+	}
+	choices, err := choose(conflict, resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &Outcome{Status: OutcomeUnchanged, Policy: tenantconfig.ConflictManual, Applied: []string{}}
+	apply := []string{}
+	for name := range conflict.Local {
+		if side, ok := choices[name]; ok && side != SideLocal {
+			outcome.Kept = append(outcome.Kept, name)
+			continue
+		}
+		apply = append(apply, name)
+	}
+	sort.Strings(apply)
+	sort.Strings(outcome.Kept)
+
+	if len(apply) > 0 {
+		var updated map[string]map[string]interface{}
+		err = s.qb.Modify(ctx, conflict.Entity, conflict.EntityID, func(raw map[string]json.RawMessage) (map[string]interface{}, error) {
+			current, _ := sparse.Generic(raw).(map[string]interface{})
+			if syncToken, _ := current["SyncToken"].(string); syncToken != conflict.SyncToken {
+				return nil, ErrStale
+			}
+			update := map[string]interface{}{"sparse": true}
+			for _, name := range apply {
+				if !sparse.Contains(current[name], sparse.Generic(conflict.Local[name])) {
+					update[name] = sparse.Merge(current[name], conflict.Local[name])
+					outcome.Applied = append(outcome.Applied, name)
+				}
+			}
+			if len(outcome.Applied) == 0 {
+				return nil, errUnchanged
+			}
+			return update, nil
+		}, &updated)
+		switch {
+		case errors.Is(err, errUnchanged):
+		case err != nil:
+			return nil, notFound(err)
+		default:
+			outcome.Status = OutcomeApplied
+			outcome.Entity = updated[conflict.Entity]
+		}
+	}
+
+	now := time.Now().UTC()
+	conflict.Status = StatusResolved
+	conflict.Choices = choices
+	conflict.ResolvedBy = auth.GetUserID(ctx)
+	conflict.ResolvedAt = &now
+	if err := s.store.Save(ctx, conflict); err != nil {
+		return nil, err
+	}
+	outcome.Conflict = conflict
+	return outcome, nil
+}
+
+// choose returns the side a resolution keeps for each conflicting field
+func choose(conflict *Conflict, resolution *Resolution) (map[string]string, error) {
+	for name, side := range resolution.Fields {
+		if !conflict.conflicting(name) {
+			return nil, fmt.Errorf("%w: %s is not a conflicting field", ErrInvalidResolution, name)
+		}
+		if side != SideQuickBooks && side != SideLocal {
+			return nil, fmt.Errorf("%w: %s must keep %q or %q", ErrInvalidResolution, name, SideQuickBooks, SideLocal)
+		}
+	}
+	if resolution.Keep != "" && resolution.Keep != SideQuickBooks && resolution.Keep != SideLocal {
+		return nil, fmt.Errorf("%w: keep must be %q or %q", ErrInvalidResolution, SideQuickBooks, SideLocal)
+	}
+
+	choices := make(map[string]string, len(conflict.Fields))
+	var missing []string
+	for _, field := range conflict.Fields {
+		side := resolution.Fields[field.Field]
+		if side == "" {
+			side = resolution.Keep
+		}
+		if side == "" {
+			missing = append(missing, field.Field)
+			continue
+		}
+		choices[field.Field] = side
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: choose a side for %s", ErrInvalidResolution, strings.Join(missing, ", "))
+	}
+	return choices, nil
+}
+
+// Dismiss closes a conflict without writing any of its draft
+func (s *Service) Dismiss(ctx context.Context, id string) (*Conflict, error) {
+	conflict, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if conflict.Status != StatusOpen {
+		return nil, ErrNotOpen
+	}
+
+	now := time.Now().UTC()
+	conflict.Status = StatusDismissed
+	conflict.ResolvedBy = auth.GetUserID(ctx)
+	conflict.ResolvedAt = &now
+	if err := s.store.Save(ctx, conflict); err != nil {
+		return nil, err
+	}
+	return conflict, nil
+}
+
+// notFound maps QuickBooks' object-not-found fault to ErrNotFound
+func notFound(err error) error {
+	if strings.Contains(err.Error(), "(610)") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,213 @@
+// tenantconfig/diff.go
+package tenantconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change operations
+const (
+	OpAdd    = "add"
+	OpRemove = "remove"
+	OpUpdate = "update"
+)
+
+// sensitiveValue replaces secret values in diffs
+const sensitiveValue = "(sensitive)"
+
+// sensitiveFields are the fields whose values diffs never show
+var sensitiveFields = map[string]bool{
+	"secret":            true,
+	"credentials_json":  true,
+	"secret_access_key": true,
+}
+
+// Change is one difference between the stored and desired document. Paths
+// address list entries by their key, e.g. "webhooks[billing].url".
+type Change struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// listKeys names the field that identifies entries of keyed lists
+var listKeys = map[string]string{
+	"webhooks":              "id",
+	"notification_channels": "name",
+	"dunning.steps":         "days_overdue",
+}
+
+// Diff returns the changes needed to turn from into to, ordered by path
+func Diff(from, to *Document) ([]Change, error) {
+	a, err := toGeneric(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toGeneric(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	diffValue("", a, b, &changes)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	for i := range changes {
+		if sensitiveFields[changes[i].Path[strings.LastIndex(changes[i].Path, ".")+1:]] {
+			if changes[i].Old != nil {
+				changes[i].Old = sensitiveValue
+			}
+			if changes[i].New != nil {
+				changes[i].New = sensitiveValue
+			}
+		}
+	}
+	return changes, nil
+}
+
+// toGeneric round-trips a document through JSON so it can be walked
+func toGeneric(doc *Document) (interface{}, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tenant config: %w", err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tenant config: %w", err)
+	}
+	return v, nil
+}
+
+// diffValue appends the differences between a and b at path
+func diffValue(path string, a, b interface{}, changes *[]Change) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range unionKeys(av, bv) {
+			childPath := joinPath(path, key)
+			before, inA := av[key]
+			after, inB := bv[key]
+			switch {
+			case !inA:
+				addRemove(OpAdd, childPath, after, changes)
+			case !inB:
+				addRemove(OpRemove, childPath, before, changes)
+			default:
+				diffValue(childPath, before, after, changes)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		if keyField, keyed := listKeys[path]; keyed {
+			diffKeyedList(path, keyField, av, bv, changes)
+			return
+		}
+	}
+
+	*changes = append(*changes, Change{Op: OpUpdate, Path: path, Old: a, New: b})
+}
+
+// diffKeyedList matches list entries by key so that reordering is not a
+// change and edits to one entry are reported against that entry
+func diffKeyedList(path, keyField string, a, b []interface{}, changes *[]Change) {
+	index := func(list []interface{}) (map[string]interface{}, []string) {
+		entries := make(map[string]interface{}, len(list))
+		var keys []string
+		for _, item := range list {
+			key := fmt.Sprint(item.(map[string]interface{})[keyField])
+			entries[key] = item
+			keys = append(keys, key)
+		}
+		return entries, keys
+	}
+	aEntries, aKeys := index(a)
+	bEntries, bKeys := index(b)
+
+	seen := make(map[string]bool)
+	for _, key := range append(aKeys, bKeys...) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		entryPath := fmt.Sprintf("%s[%s]", path, key)
+		before, inA := aEntries[key]
+		after, inB := bEntries[key]
+		switch {
+		case !inA:
+			addRemove(OpAdd, entryPath, after, changes)
+		case !inB:
+			addRemove(OpRemove, entryPath, before, changes)
+		default:
+			diffValue(entryPath, before, after, changes)
+		}
+	}
+}
+
+// addRemove records an added or removed value, masking any secret it holds
+func addRemove(op, path string, value interface{}, changes *[]Change) {
+	value = mask(value)
+	change := Change{Op: op, Path: path}
+	if op == OpAdd {
+		change.New = value
+	} else {
+		change.Old = value
+	}
+	*changes = append(*changes, change)
+}
+
+// mask returns a copy of value with the sensitive fields of its objects,
+// at any depth, replaced
+func mask(value interface{}) interface{} {
+	entry, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	masked := make(map[string]interface{}, len(entry))
+	for k, v := range entry {
+		if sensitiveFields[k] {
+			masked[k] = sensitiveValue
+			continue
+		}
+		masked[k] = mask(v)
+	}
+	return masked
+}
+
+// unionKeys returns the keys of both maps in sorted order
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// joinPath appends a field name to a path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
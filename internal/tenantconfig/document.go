@@ -0,0 +1,535 @@
+// tenantconfig/document.go
+package tenantconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// namePattern restricts webhook IDs, channel names and feature flags to
+// identifiers that are stable in infrastructure-as-code definitions
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// pubSubTopicPattern matches full Pub/Sub topic names
+var pubSubTopicPattern = regexp.MustCompile(`^projects/[a-z][a-z0-9-]{4,28}[a-z0-9]/topics/[A-Za-z][A-Za-z0-9._~+%-]{2,254}$`)
+
+// snsTopicPattern matches SNS topic ARNs
+var snsTopicPattern = regexp.MustCompile(`^arn:aws[a-z-]*:sns:[a-z0-9-]+:[0-9]{12}:[A-Za-z0-9_-]{1,256}(\.fifo)?$`)
+
+// sqsHostPattern matches SQS queue URL hosts
+var sqsHostPattern = regexp.MustCompile(`^sqs\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// roleARNPattern matches IAM role ARNs
+var roleARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/[A-Za-z0-9+=,.@_/-]{1,512}$`)
+
+// entityPattern matches QuickBooks entity names such as "Invoice"
+var entityPattern = regexp.MustCompile(`^[A-Z][A-Za-z]{1,63}$`)
+
+// statusPattern matches invoice lifecycle status names, which become part
+// of event names
+var statusPattern = regexp.MustCompile(`^[a-z][a-z_]{0,31}$`)
+
+// eventPattern matches event names such as "invoice.created" or "invoice.*"
+var eventPattern = regexp.MustCompile(`^(\*|[a-z_]+\.(\*|[a-z_]+))$`)
+
+// Document is the complete declarative configuration of a tenant. A PUT
+// replaces the whole document; anything omitted is removed.
+type Document struct {
+	Webhooks             []Webhook             `json:"webhooks"`
+	NotificationChannels []NotificationChannel `json:"notification_channels"`
+	Dunning              DunningPolicy         `json:"dunning"`
+	FeatureFlags         map[string]bool       `json:"feature_flags"`
+	SyncConflicts        SyncConflictPolicy    `json:"sync_conflicts"`
+	InvoiceLifecycle     InvoiceLifecycle      `json:"invoice_lifecycle"`
+	Commissions          CommissionPolicy      `json:"commissions"`
+}
+
+// Webhook types
+const (
+	WebhookHTTP   = "http"   // POST to URL
+	WebhookPubSub = "pubsub" // publish to a Google Pub/Sub Topic
+	WebhookSNS    = "sns"    // publish to an AWS SNS Topic
+	WebhookSQS    = "sqs"    // send to the AWS SQS queue at URL
+)
+
+// Webhook is an outbound endpoint subscribed to tenant events: an HTTP URL
+// by default, or a cloud topic or queue
+type Webhook struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type,omitempty"` // one of the webhook types, "http" when empty
+	URL      string          `json:"url,omitempty"`
+	Topic    string          `json:"topic,omitempty"` // "projects/<project>/topics/<topic>" for Pub/Sub, the topic ARN for SNS
+	Events   []string        `json:"events"`
+	Secret   string          `json:"secret,omitempty"` // HMAC signing secret, never returned to clients
+	GCP      *GCPCredentials `json:"gcp,omitempty"`
+	AWS      *AWSCredentials `json:"aws,omitempty"`
+	Disabled bool            `json:"disabled,omitempty"`
+}
+
+// GCPCredentials authenticate Pub/Sub webhooks as a service account with
+// publish rights on the topic
+type GCPCredentials struct {
+	CredentialsJSON string `json:"credentials_json,omitempty"` // service account key, never returned to clients
+}
+
+// AWSCredentials authenticate SNS and SQS webhooks. With RoleARN the access
+// key only assumes that role, e.g. one in the tenant's own account that
+// trusts ours and requires ExternalID.
+type AWSCredentials struct {
+	Region          string `json:"region,omitempty"` // defaults to the topic's or queue's region
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"` // never returned to clients
+	RoleARN         string `json:"role_arn,omitempty"`
+	ExternalID      string `json:"external_id,omitempty"`
+}
+
+// NotificationChannel is a named notification destination
+type NotificationChannel struct {
+	Name    string `json:"name"`
+	Channel string `json:"channel"` // "email" or "slack"
+	Target  string `json:"target"`
+}
+
+// Sync conflict policies: which side wins a field that both QuickBooks and
+// a local draft changed
+const (
+	ConflictQuickBooksWins = "quickbooks_wins"
+	ConflictLocalWins      = "local_wins"
+	ConflictManual         = "manual" // hold the draft for review
+)
+
+// SyncConflictPolicy picks the conflict policy per QuickBooks entity
+type SyncConflictPolicy struct {
+	Default  string            `json:"default,omitempty"` // manual when empty
+	Entities map[string]string `json:"entities"`
+}
+
+// For returns the policy of an entity
+func (p SyncConflictPolicy) For(entity string) string {
+	if policy := p.Entities[entity]; policy != "" {
+		return policy
+	}
+	if p.Default != "" {
+		return p.Default
+	}
+	return ConflictManual
+}
+
+// Built-in invoice lifecycle statuses, in the order an invoice normally
+// moves through them
+const (
+	InvoiceDraft         = "draft"
+	InvoiceApproved      = "approved"
+	InvoiceSent          = "sent"
+	InvoicePartiallyPaid = "partially_paid"
+	InvoicePaid          = "paid"
+	InvoiceWrittenOff    = "written_off"
+)
+
+// InvoiceStatuses are the built-in invoice lifecycle statuses
+var InvoiceStatuses = []string{InvoiceDraft, InvoiceApproved, InvoiceSent, InvoicePartiallyPaid, InvoicePaid, InvoiceWrittenOff}
+
+// DefaultInvoiceTransitions are the statuses each built-in status may move
+// to unless a tenant declares its own. Paid invoices fall back when a
+// payment is removed in QuickBooks.
+var DefaultInvoiceTransitions = map[string][]string{
+	InvoiceDraft:         {InvoiceApproved},
+	InvoiceApproved:      {InvoiceDraft, InvoiceSent, InvoicePartiallyPaid, InvoicePaid},
+	InvoiceSent:          {InvoicePartiallyPaid, InvoicePaid, InvoiceWrittenOff},
+	InvoicePartiallyPaid: {InvoicePaid, InvoiceWrittenOff},
+	InvoicePaid:          {InvoiceSent, InvoicePartiallyPaid},
+	InvoiceWrittenOff:    {},
+}
+
+// reservedStatuses are event suffixes custom statuses cannot take
+var reservedStatuses = map[string]bool{"created": true, "updated": true, "deleted": true}
+
+// InvoiceLifecycle is the state machine invoices move through. Tenants may
+// add custom statuses and replace the transitions; without transitions the
+// default ones apply.
+type InvoiceLifecycle struct {
+	Statuses    []string            `json:"statuses"`    // custom statuses, in addition to the built-in ones
+	Transitions map[string][]string `json:"transitions"` // statuses each status may move to
+}
+
+// Known reports whether a status is built in or one of the tenant's
+func (l InvoiceLifecycle) Known(status string) bool {
+	for _, s := range InvoiceStatuses {
+		if s == status {
+			return true
+		}
+	}
+	for _, s := range l.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the statuses an invoice in a status may move to
+func (l InvoiceLifecycle) Next(status string) []string {
+	if len(l.Transitions) == 0 {
+		return DefaultInvoiceTransitions[status]
+	}
+	return l.Transitions[status]
+}
+
+// Allows reports whether an invoice may move between two statuses
+func (l InvoiceLifecycle) Allows(from, to string) bool {
+	for _, next := range l.Next(from) {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Commission bases: when a sale earns its sales rep commission
+const (
+	CommissionOnInvoice = "invoice" // on the invoice's pre-tax amount, in the period it is dated
+	CommissionOnPayment = "payment" // on the pre-tax share of each payment, in the period it is received
+)
+
+// CommissionPolicy sets how sales rep commissions are earned. Rates are
+// percentages; reps without a rate of their own earn the default rate.
+type CommissionPolicy struct {
+	Basis       string             `json:"basis,omitempty"`     // invoice when empty
+	DefaultRate float64            `json:"default_rate"`        // percent
+	Rates       map[string]float64 `json:"rates"`               // percent per sales rep
+	RepField    string             `json:"rep_field,omitempty"` // invoice custom field naming the sales rep
+}
+
+// BasisOrDefault returns the commission basis, invoice when not set
+func (p CommissionPolicy) BasisOrDefault() string {
+	if p.Basis == "" {
+		return CommissionOnInvoice
+	}
+	return p.Basis
+}
+
+// Rate returns a sales rep's commission rate in percent
+func (p CommissionPolicy) Rate(rep string) float64 {
+	if rate, ok := p.Rates[rep]; ok {
+		return rate
+	}
+	return p.DefaultRate
+}
+
+// DunningPolicy controls reminders for overdue invoices
+type DunningPolicy struct {
+	Enabled            bool          `json:"enabled"`
+	Steps              []DunningStep `json:"steps"`
+	ExcludeCustomerIDs []string      `json:"exclude_customer_ids"`
+}
+
+// DunningStep sends a reminder once an invoice is a number of days overdue
+type DunningStep struct {
+	DaysOverdue int    `json:"days_overdue"`
+	Channel     string `json:"channel"` // notification channel name
+	Subject     string `json:"subject"`
+	Message     string `json:"message"`
+}
+
+// normalize sorts lists and fills empty collections so that equivalent
+// documents serialize identically and re-applying one yields no diff
+func (d *Document) normalize() {
+	if d.Webhooks == nil {
+		d.Webhooks = []Webhook{}
+	}
+	for i := range d.Webhooks {
+		d.Webhooks[i].Events = sortedUnique(d.Webhooks[i].Events)
+		if d.Webhooks[i].Type == WebhookHTTP {
+			d.Webhooks[i].Type = ""
+		}
+	}
+	sort.Slice(d.Webhooks, func(i, j int) bool { return d.Webhooks[i].ID < d.Webhooks[j].ID })
+
+	if d.NotificationChannels == nil {
+		d.NotificationChannels = []NotificationChannel{}
+	}
+	sort.Slice(d.NotificationChannels, func(i, j int) bool {
+		return d.NotificationChannels[i].Name < d.NotificationChannels[j].Name
+	})
+
+	if d.Dunning.Steps == nil {
+		d.Dunning.Steps = []DunningStep{}
+	}
+	sort.SliceStable(d.Dunning.Steps, func(i, j int) bool {
+		return d.Dunning.Steps[i].DaysOverdue < d.Dunning.Steps[j].DaysOverdue
+	})
+	d.Dunning.ExcludeCustomerIDs = sortedUnique(d.Dunning.ExcludeCustomerIDs)
+
+	if d.FeatureFlags == nil {
+		d.FeatureFlags = map[string]bool{}
+	}
+
+	if d.SyncConflicts.Entities == nil {
+		d.SyncConflicts.Entities = map[string]string{}
+	}
+
+	d.InvoiceLifecycle.Statuses = sortedUnique(d.InvoiceLifecycle.Statuses)
+	if d.InvoiceLifecycle.Transitions == nil {
+		d.InvoiceLifecycle.Transitions = map[string][]string{}
+	}
+	for status, next := range d.InvoiceLifecycle.Transitions {
+		d.InvoiceLifecycle.Transitions[status] = sortedUnique(next)
+	}
+
+	if d.Commissions.Basis == CommissionOnInvoice {
+		d.Commissions.Basis = ""
+	}
+	d.Commissions.RepField = strings.TrimSpace(d.Commissions.RepField)
+	if d.Commissions.Rates == nil {
+		d.Commissions.Rates = map[string]float64{}
+	}
+}
+
+// validate checks the document; supports reports whether a notification
+// channel type is available
+func (d *Document) validate(supports func(channel string) bool) error {
+	webhookIDs := make(map[string]bool)
+	for _, w := range d.Webhooks {
+		if !namePattern.MatchString(w.ID) {
+			return fmt.Errorf("webhooks: invalid id %q", w.ID)
+		}
+		if webhookIDs[w.ID] {
+			return fmt.Errorf("webhooks: duplicate id %q", w.ID)
+		}
+		webhookIDs[w.ID] = true
+
+		if err := w.validateTarget(); err != nil {
+			return fmt.Errorf("webhooks[%s]: %w", w.ID, err)
+		}
+		if len(w.Events) == 0 {
+			return fmt.Errorf("webhooks[%s]: at least one event is required", w.ID)
+		}
+		for _, event := range w.Events {
+			if !eventPattern.MatchString(event) {
+				return fmt.Errorf("webhooks[%s]: invalid event %q", w.ID, event)
+			}
+		}
+	}
+
+	channels := make(map[string]bool)
+	for _, c := range d.NotificationChannels {
+		if !namePattern.MatchString(c.Name) {
+			return fmt.Errorf("notification_channels: invalid name %q", c.Name)
+		}
+		if channels[c.Name] {
+			return fmt.Errorf("notification_channels: duplicate name %q", c.Name)
+		}
+		channels[c.Name] = true
+
+		if !supports(c.Channel) {
+			return fmt.Errorf("notification_channels[%s]: unsupported channel %q", c.Name, c.Channel)
+		}
+		if strings.TrimSpace(c.Target) == "" {
+			return fmt.Errorf("notification_channels[%s]: target is required", c.Name)
+		}
+	}
+
+	if d.Dunning.Enabled && len(d.Dunning.Steps) == 0 {
+		return fmt.Errorf("dunning: at least one step is required when enabled")
+	}
+	seenDays := make(map[int]bool)
+	for _, step := range d.Dunning.Steps {
+		if step.DaysOverdue < 0 {
+			return fmt.Errorf("dunning: days_overdue must not be negative")
+		}
+		if seenDays[step.DaysOverdue] {
+			return fmt.Errorf("dunning: duplicate step for %d days overdue", step.DaysOverdue)
+		}
+		seenDays[step.DaysOverdue] = true
+		if !channels[step.Channel] {
+			return fmt.Errorf("dunning: step for %d days overdue references unknown channel %q", step.DaysOverdue, step.Channel)
+		}
+		if step.Subject == "" || step.Message == "" {
+			return fmt.Errorf("dunning: step for %d days overdue needs a subject and message", step.DaysOverdue)
+		}
+	}
+
+	for flag := range d.FeatureFlags {
+		if !namePattern.MatchString(flag) {
+			return fmt.Errorf("feature_flags: invalid flag name %q", flag)
+		}
+	}
+
+	if d.SyncConflicts.Default != "" && !validConflictPolicy(d.SyncConflicts.Default) {
+		return fmt.Errorf("sync_conflicts: invalid default policy %q", d.SyncConflicts.Default)
+	}
+	for entity, policy := range d.SyncConflicts.Entities {
+		if !entityPattern.MatchString(entity) {
+			return fmt.Errorf("sync_conflicts: invalid entity %q", entity)
+		}
+		if !validConflictPolicy(policy) {
+			return fmt.Errorf("sync_conflicts[%s]: invalid policy %q", entity, policy)
+		}
+	}
+
+	lifecycle := d.InvoiceLifecycle
+	if len(lifecycle.Statuses) > 0 && len(lifecycle.Transitions) == 0 {
+		return fmt.Errorf("invoice_lifecycle: custom statuses need transitions")
+	}
+	for _, status := range lifecycle.Statuses {
+		if !statusPattern.MatchString(status) || reservedStatuses[status] {
+			return fmt.Errorf("invoice_lifecycle: invalid status %q", status)
+		}
+		for _, builtIn := range InvoiceStatuses {
+			if status == builtIn {
+				return fmt.Errorf("invoice_lifecycle: %q is a built-in status", status)
+			}
+		}
+	}
+	for from, next := range lifecycle.Transitions {
+		if !lifecycle.Known(from) {
+			return fmt.Errorf("invoice_lifecycle: transitions from unknown status %q", from)
+		}
+		for _, to := range next {
+			if !lifecycle.Known(to) {
+				return fmt.Errorf("invoice_lifecycle[%s]: transition to unknown status %q", from, to)
+			}
+			if to == from {
+				return fmt.Errorf("invoice_lifecycle[%s]: transition to itself", from)
+			}
+		}
+	}
+
+	commissions := d.Commissions
+	if commissions.Basis != "" && commissions.Basis != CommissionOnInvoice && commissions.Basis != CommissionOnPayment {
+		return fmt.Errorf("commissions: invalid basis %q", commissions.Basis)
+	}
+	if commissions.DefaultRate < 0 || commissions.DefaultRate > 100 {
+		return fmt.Errorf("commissions: default_rate must be between 0 and 100")
+	}
+	for rep, rate := range commissions.Rates {
+		if rep == "" || rep != strings.TrimSpace(rep) || len(rep) > 100 {
+			return fmt.Errorf("commissions: invalid sales rep %q", rep)
+		}
+		if rate < 0 || rate > 100 {
+			return fmt.Errorf("commissions[%s]: rate must be between 0 and 100", rep)
+		}
+	}
+	if len(commissions.RepField) > 31 {
+		return fmt.Errorf("commissions: rep_field must be at most 31 characters")
+	}
+	return nil
+}
+
+// validConflictPolicy reports whether a sync conflict policy is known
+func validConflictPolicy(policy string) bool {
+	return policy == ConflictQuickBooksWins || policy == ConflictLocalWins || policy == ConflictManual
+}
+
+// validateTarget checks a webhook's destination and the credentials it needs
+func (w *Webhook) validateTarget() error {
+	switch w.Type {
+	case "", WebhookHTTP:
+		u, err := url.Parse(w.URL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("url must be an absolute https URL")
+		}
+	case WebhookPubSub:
+		if !pubSubTopicPattern.MatchString(w.Topic) {
+			return fmt.Errorf("topic must be projects/<project>/topics/<topic>")
+		}
+		if w.GCP == nil || w.GCP.CredentialsJSON == "" {
+			return fmt.Errorf("gcp.credentials_json is required")
+		}
+		var key struct {
+			ClientEmail string `json:"client_email"`
+			PrivateKey  string `json:"private_key"`
+		}
+		if err := json.Unmarshal([]byte(w.GCP.CredentialsJSON), &key); err != nil || key.ClientEmail == "" || key.PrivateKey == "" {
+			return fmt.Errorf("gcp.credentials_json must be a service account key")
+		}
+	case WebhookSNS:
+		if !snsTopicPattern.MatchString(w.Topic) {
+			return fmt.Errorf("topic must be an SNS topic ARN")
+		}
+		return w.AWS.validate()
+	case WebhookSQS:
+		u, err := url.Parse(w.URL)
+		if err != nil || u.Scheme != "https" || !sqsHostPattern.MatchString(u.Host) || strings.Count(strings.Trim(u.Path, "/"), "/") != 1 {
+			return fmt.Errorf("url must be an SQS queue URL")
+		}
+		return w.AWS.validate()
+	default:
+		return fmt.Errorf("unsupported type %q", w.Type)
+	}
+	return nil
+}
+
+// validate checks AWS credentials are complete
+func (c *AWSCredentials) validate() error {
+	if c == nil || c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return fmt.Errorf("aws.access_key_id and aws.secret_access_key are required")
+	}
+	if c.RoleARN != "" && !roleARNPattern.MatchString(c.RoleARN) {
+		return fmt.Errorf("aws.role_arn must be an IAM role ARN")
+	}
+	return nil
+}
+
+// keepSecrets carries stored webhook secrets and credentials into webhooks
+// that omit them
+func (d *Document) keepSecrets(existing *Document) {
+	stored := make(map[string]Webhook, len(existing.Webhooks))
+	for _, w := range existing.Webhooks {
+		stored[w.ID] = w
+	}
+	for i, w := range d.Webhooks {
+		old, ok := stored[w.ID]
+		if !ok {
+			continue
+		}
+		if w.Secret == "" {
+			d.Webhooks[i].Secret = old.Secret
+		}
+		if w.GCP != nil && w.GCP.CredentialsJSON == "" && old.GCP != nil {
+			d.Webhooks[i].GCP = &GCPCredentials{CredentialsJSON: old.GCP.CredentialsJSON}
+		}
+		if w.AWS != nil && w.AWS.SecretAccessKey == "" && old.AWS != nil && old.AWS.AccessKeyID == w.AWS.AccessKeyID {
+			aws := *w.AWS
+			aws.SecretAccessKey = old.AWS.SecretAccessKey
+			d.Webhooks[i].AWS = &aws
+		}
+	}
+}
+
+// Redacted returns a copy of the document with webhook secrets removed
+func (d Document) Redacted() Document {
+	webhooks := make([]Webhook, len(d.Webhooks))
+	for i, w := range d.Webhooks {
+		w.Secret = ""
+		if w.GCP != nil {
+			w.GCP = &GCPCredentials{}
+		}
+		if w.AWS != nil {
+			aws := *w.AWS
+			aws.SecretAccessKey = ""
+			w.AWS = &aws
+		}
+		webhooks[i] = w
+	}
+	d.Webhooks = webhooks
+	return d
+}
+
+// sortedUnique sorts values and drops duplicates, never returning nil
+func sortedUnique(values []string) []string {
+	out := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
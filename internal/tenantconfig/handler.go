@@ -0,0 +1,140 @@
+// tenantconfig/handler.go
+package tenantconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler provides operator endpoints for declarative tenant configuration
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new tenant configuration handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetConfig returns a tenant's document without secrets. The ETag carries
+// the version for use with If-Match.
+func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	stored, err := h.service.Get(r.Context(), mux.Vars(r)["tenantID"])
+	if err != nil {
+		http.Error(w, "Failed to get tenant config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(stored.Version)))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version":    stored.Version,
+		"updated_at": stored.UpdatedAt,
+		"document":   stored.Document.Redacted(),
+	})
+}
+
+// ApplyConfig replaces a tenant's whole document. ?dry_run=true returns the
+// planned changes without saving; If-Match rejects stale writes.
+func (h *Handler) ApplyConfig(w http.ResponseWriter, r *http.Request) {
+	var doc Document
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.apply(w, r, &doc)
+}
+
+// ApplySection returns a handler that replaces one top-level section of the
+// document, leaving the others as stored
+func (h *Handler) ApplySection(section string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stored, err := h.service.Get(r.Context(), mux.Vars(r)["tenantID"])
+		if err != nil {
+			http.Error(w, "Failed to get tenant config: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		doc := stored.Document
+
+		var target interface{}
+		switch section {
+		case "webhooks":
+			doc.Webhooks = nil
+			target = &doc.Webhooks
+		case "notification_channels":
+			doc.NotificationChannels = nil
+			target = &doc.NotificationChannels
+		case "dunning":
+			doc.Dunning = DunningPolicy{}
+			target = &doc.Dunning
+		case "feature_flags":
+			doc.FeatureFlags = nil
+			target = &doc.FeatureFlags
+		case "sync_conflicts":
+			doc.SyncConflicts = SyncConflictPolicy{}
+			target = &doc.SyncConflicts
+		case "invoice_lifecycle":
+			doc.InvoiceLifecycle = InvoiceLifecycle{}
+			target = &doc.InvoiceLifecycle
+		case "commissions":
+			doc.Commissions = CommissionPolicy{}
+			target = &doc.Commissions
+		default:
+			http.Error(w, "Unknown config section", http.StatusNotFound)
+			return
+		}
+
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(target); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Guard against another write landing between the read and the apply
+		if r.Header.Get("If-Match") == "" {
+			r.Header.Set("If-Match", strconv.Quote(strconv.Itoa(stored.Version)))
+		}
+		h.apply(w, r, &doc)
+	}
+}
+
+// apply saves or plans a document and writes the result
+func (h *Handler) apply(w http.ResponseWriter, r *http.Request, doc *Document) {
+	expectedVersion := 0
+	if v := r.Header.Get("If-Match"); v != "" {
+		version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(v, "W/"), `"`))
+		if err != nil {
+			http.Error(w, "Invalid If-Match header", http.StatusBadRequest)
+			return
+		}
+		expectedVersion = version
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	result, err := h.service.Apply(r.Context(), mux.Vars(r)["tenantID"], doc, expectedVersion, dryRun)
+	if err != nil {
+		if errors.Is(err, ErrVersionMismatch) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		http.Error(w, "Failed to apply tenant config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(result.Version)))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
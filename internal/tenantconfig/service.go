@@ -0,0 +1,161 @@
+// tenantconfig/service.go
+package tenantconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/notify"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrVersionMismatch is returned when the caller's expected version is stale
+var ErrVersionMismatch = errors.New("tenant config has changed since it was read")
+
+// Stored is a tenant's document with its revision metadata
+type Stored struct {
+	Document  Document  `json:"document"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// ApplyResult describes the outcome of applying a document
+type ApplyResult struct {
+	Version int      `json:"version"`
+	Changed bool     `json:"changed"`
+	DryRun  bool     `json:"dry_run,omitempty"`
+	Changes []Change `json:"changes"`
+}
+
+// Service stores declarative tenant configuration
+type Service struct {
+	client     redis.UniversalClient
+	prefix     string
+	dispatcher *notify.Dispatcher
+}
+
+// NewService creates a new tenant configuration service
+func NewService(client redis.UniversalClient, prefix string, dispatcher *notify.Dispatcher) *Service {
+	return &Service{
+		client:     client,
+		prefix:     prefix,
+		dispatcher: dispatcher,
+	}
+}
+
+// key holds a tenant's stored document
+func (s *Service) key(tenantID string) string {
+	return fmt.Sprintf("%s:tenantconfig:%s", s.prefix, tenantID)
+}
+
+// Get returns a tenant's document; tenants never configured get an empty
+// document at version 0
+func (s *Service) Get(ctx context.Context, tenantID string) (*Stored, error) {
+	data, err := s.client.Get(ctx, s.key(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			stored := &Stored{}
+			stored.Document.normalize()
+			return stored, nil
+		}
+		return nil, fmt.Errorf("failed to get tenant config: %w", err)
+	}
+
+	var stored Stored
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tenant config: %w", err)
+	}
+	stored.Document.normalize()
+	return &stored, nil
+}
+
+// FeatureEnabled reports whether a feature flag is on for a tenant. Unknown
+// flags and lookup failures count as off.
+func (s *Service) FeatureEnabled(ctx context.Context, tenantID, flag string) bool {
+	stored, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return false
+	}
+	return stored.Document.FeatureFlags[flag]
+}
+
+// ConflictPolicy returns a tenant's sync conflict policy for an entity.
+// Lookup failures hold drafts for review rather than overwrite either side.
+func (s *Service) ConflictPolicy(ctx context.Context, tenantID, entity string) string {
+	stored, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return ConflictManual
+	}
+	return stored.Document.SyncConflicts.For(entity)
+}
+
+// InvoiceLifecycle returns a tenant's invoice state machine. Lookup
+// failures fall back to the default lifecycle.
+func (s *Service) InvoiceLifecycle(ctx context.Context, tenantID string) InvoiceLifecycle {
+	stored, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return InvoiceLifecycle{}
+	}
+	return stored.Document.InvoiceLifecycle
+}
+
+// Commissions returns a tenant's commission policy
+func (s *Service) Commissions(ctx context.Context, tenantID string) (CommissionPolicy, error) {
+	stored, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return CommissionPolicy{}, err
+	}
+	return stored.Document.Commissions, nil
+}
+
+// Apply replaces a tenant's document and returns the changes. Applying an
+// identical document changes nothing and keeps the version, so tooling can
+// re-apply on every run. A non-zero expectedVersion must match the stored
+// version. With dryRun the changes are computed but not saved.
+func (s *Service) Apply(ctx context.Context, tenantID string, doc *Document, expectedVersion int, dryRun bool) (*ApplyResult, error) {
+	current, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && expectedVersion != current.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	doc.keepSecrets(&current.Document)
+	doc.normalize()
+	if err := doc.validate(s.dispatcher.Supports); err != nil {
+		return nil, err
+	}
+
+	changes, err := Diff(&current.Document, doc)
+	if err != nil {
+		return nil, err
+	}
+	result := &ApplyResult{
+		Version: current.Version,
+		Changed: len(changes) > 0,
+		DryRun:  dryRun,
+		Changes: changes,
+	}
+	if result.Changes == nil {
+		result.Changes = []Change{}
+	}
+	if !result.Changed || dryRun {
+		return result, nil
+	}
+
+	next := Stored{Document: *doc, Version: current.Version + 1, UpdatedAt: time.Now()}
+	data, err := json.Marshal(next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tenant config: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(tenantID), data, 0).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save tenant config: %w", err)
+	}
+
+	result.Version = next.Version
+	return result, nil
+}
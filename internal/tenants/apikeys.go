@@ -0,0 +1,197 @@
+// tenants/apikeys.go
+package tenants
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+// keyPrefix marks API keys issued by the server
+const keyPrefix = "qbs_"
+
+// APIKeyHeader carries a tenant API key
+const APIKeyHeader = "X-API-Key"
+
+// AdminRole is the role of a tenant's first API key
+const AdminRole = "admin"
+
+var roles = map[string]bool{AdminRole: true, auth.UserRole: true, auth.DefaultRole: true}
+
+// ErrInvalidRole is returned for API key and member roles other than admin,
+// user and viewer
+var ErrInvalidRole = errors.New("invalid role, expected admin, user or viewer")
+
+// APIKey authenticates a tenant's requests. Only a hash of the key is
+// stored; the key itself is returned once, when it is created.
+type APIKey struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Name      string    `json:"name,omitempty"`
+	Role      string    `json:"role"`
+	Hint      string    `json:"hint"`
+	Key       string    `json:"key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// hashKey returns the stored form of an API key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyKey returns the Redis key for an API key by its hash
+func (s *Service) apiKeyKey(hash string) string {
+	return fmt.Sprintf("%s:tenants:apikey:%s", s.prefix, hash)
+}
+
+// tenantKeysKey returns the Redis hash of a tenant's key IDs and hashes
+func (s *Service) tenantKeysKey(tenantID string) string {
+	return fmt.Sprintf("%s:tenants:apikeys:%s", s.prefix, tenantID)
+}
+
+// CreateKey issues an API key for a tenant
+func (s *Service) CreateKey(ctx context.Context, tenantID, name, role string) (*APIKey, error) {
+	if role == "" {
+		role = auth.DefaultRole
+	}
+	if !roles[role] {
+		return nil, ErrInvalidRole
+	}
+	if _, err := s.Get(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	secret := keyPrefix + jobs.NewID() + jobs.NewID()
+	key := &APIKey{
+		ID:        jobs.NewID()[:12],
+		TenantID:  tenantID,
+		Name:      name,
+		Role:      role,
+		Hint:      secret[len(secret)-4:],
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal API key: %w", err)
+	}
+
+	hash := hashKey(secret)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.apiKeyKey(hash), data, 0)
+		pipe.HSet(ctx, s.tenantKeysKey(tenantID), key.ID, hash)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	key.Key = secret
+	return key, nil
+}
+
+// ListKeys returns a tenant's API keys, oldest first
+func (s *Service) ListKeys(ctx context.Context, tenantID string) ([]*APIKey, error) {
+	hashes, err := s.client.HGetAll(ctx, s.tenantKeysKey(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	keys := make([]*APIKey, 0, len(hashes))
+	for _, hash := range hashes {
+		key, err := s.getKey(ctx, hash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// RevokeKey deletes one of a tenant's API keys
+func (s *Service) RevokeKey(ctx context.Context, tenantID, keyID string) error {
+	hash, err := s.client.HGet(ctx, s.tenantKeysKey(tenantID), keyID).Result()
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.apiKeyKey(hash))
+		pipe.HDel(ctx, s.tenantKeysKey(tenantID), keyID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return nil
+}
+
+// Authenticate returns the API key matching secret
+func (s *Service) Authenticate(ctx context.Context, secret string) (*APIKey, error) {
+	if len(secret) <= len(keyPrefix) || secret[:len(keyPrefix)] != keyPrefix {
+		return nil, ErrNotFound
+	}
+	return s.getKey(ctx, hashKey(secret))
+}
+
+// getKey returns an API key by its hash
+func (s *Service) getKey(ctx context.Context, hash string) (*APIKey, error) {
+	data, err := s.client.Get(ctx, s.apiKeyKey(hash)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key: %w", err)
+	}
+	return &key, nil
+}
+
+// APIKeyMiddleware authenticates requests carrying a tenant API key as the
+// tenant itself, with the key's role. Requests without a key pass through.
+func (s *Service) APIKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get(APIKeyHeader)
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := s.Authenticate(r.Context(), secret)
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to authenticate API key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx := auth.WithIdentity(r.Context(), key.TenantID, key.TenantID, "")
+		ctx = context.WithValue(ctx, auth.RoleKey, key.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,103 @@
+// tenants/connect.go
+package tenants
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// connectTTL is how long a connect link stays valid
+	connectTTL = 7 * 24 * time.Hour
+	// stateTTL is how long an authorization started from a connect link
+	// may take to complete
+	stateTTL = 10 * time.Minute
+)
+
+// ConnectPath is where connect links are served
+const ConnectPath = "/onboarding/connect/"
+
+// ConnectLink lets a tenant's user connect its QuickBooks company without
+// an account on the server
+type ConnectLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// connectKey returns the Redis key for a connect link token
+func (s *Service) connectKey(token string) string {
+	return fmt.Sprintf("%s:tenants:connect:%s", s.prefix, token)
+}
+
+// stateKey returns the Redis key for an OAuth state issued for a tenant
+func (s *Service) stateKey(state string) string {
+	return fmt.Sprintf("%s:tenants:state:%s", s.prefix, state)
+}
+
+// CreateConnectLink issues a connect link for a tenant. Links of tenants
+// with their own redirect URI are served from its domain, so white-label
+// deployments keep the whole flow on their domain.
+func (s *Service) CreateConnectLink(ctx context.Context, tenantID string) (*ConnectLink, error) {
+	tenant, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := s.baseURL
+	if tenant.Credentials != nil && tenant.Credentials.RedirectURI != "" {
+		baseURL = origin(tenant.Credentials.RedirectURI)
+	}
+
+	token := jobs.NewID()
+	if err := s.client.Set(ctx, s.connectKey(token), tenantID, connectTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save connect link: %w", err)
+	}
+	return &ConnectLink{
+		URL:       baseURL + ConnectPath + token,
+		ExpiresAt: time.Now().UTC().Add(connectTTL),
+	}, nil
+}
+
+// ConnectTenant returns the tenant a connect link token was issued for
+func (s *Service) ConnectTenant(ctx context.Context, token string) (string, error) {
+	tenantID, err := s.client.Get(ctx, s.connectKey(token)).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get connect link: %w", err)
+	}
+	return tenantID, nil
+}
+
+// IssueState returns an OAuth state that completes authorization as the
+// tenant when QuickBooks redirects back
+func (s *Service) IssueState(ctx context.Context, tenantID string) (string, error) {
+	state := jobs.NewID()
+	if err := s.client.Set(ctx, s.stateKey(state), tenantID, stateTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to save state: %w", err)
+	}
+	return state, nil
+}
+
+// TakeState implements auth.ConnectionStates. The tenant's QuickBooks
+// token is stored under the tenant ID, which API keys authenticate as.
+func (s *Service) TakeState(ctx context.Context, state string) (string, string, bool, error) {
+	var get *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, s.stateKey(state))
+		pipe.Del(ctx, s.stateKey(state))
+		return nil
+	})
+	if err == redis.Nil {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get state: %w", err)
+	}
+	tenantID := get.Val()
+	return tenantID, tenantID, true, nil
+}
@@ -0,0 +1,293 @@
+// tenants/handler.go
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// AuthorizationURLs builds the Intuit consent URLs tenants are sent to
+type AuthorizationURLs interface {
+	GetAuthorizationURL(ctx context.Context, state, host string) (string, error)
+}
+
+// Handler provides HTTP handlers for tenant onboarding
+type Handler struct {
+	service     *Service
+	authService AuthorizationURLs
+}
+
+// NewHandler creates a new tenant onboarding handler
+func NewHandler(service *Service, authService AuthorizationURLs) *Handler {
+	return &Handler{
+		service:     service,
+		authService: authService,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidCredentials),
+		errors.Is(err, ErrInvalidRedirectURI), errors.Is(err, ErrInvalidRole), errors.Is(err, ErrInvalidScopes):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// Onboarding is everything an ISV needs to hand a new tenant
+type Onboarding struct {
+	Tenant  *Tenant      `json:"tenant"`
+	APIKey  *APIKey      `json:"api_key"`
+	Connect *ConnectLink `json:"connect"`
+}
+
+// CreateTenant creates a tenant with its first admin API key and a link
+// to connect its QuickBooks company
+func (h *Handler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var tenant Tenant
+	if err := json.NewDecoder(r.Body).Decode(&tenant); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Create(r.Context(), &tenant); err != nil {
+		http.Error(w, "Failed to create tenant: "+err.Error(), status(err))
+		return
+	}
+	key, err := h.service.CreateKey(r.Context(), tenant.ID, "default", AdminRole)
+	if err != nil {
+		http.Error(w, "Failed to create API key: "+err.Error(), status(err))
+		return
+	}
+	link, err := h.service.CreateConnectLink(r.Context(), tenant.ID)
+	if err != nil {
+		http.Error(w, "Failed to create connect link: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(Onboarding{
+		Tenant:  tenant.Redacted(),
+		APIKey:  key,
+		Connect: link,
+	})
+}
+
+// GetTenant returns a tenant
+func (h *Handler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	tenant, err := h.service.Get(r.Context(), mux.Vars(r)["tenantID"])
+	if err != nil {
+		http.Error(w, "Failed to get tenant: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tenant.Redacted())
+}
+
+// SaveCredentials sets the Intuit app a tenant connects through
+func (h *Handler) SaveCredentials(w http.ResponseWriter, r *http.Request) {
+	var creds auth.AppCredentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := h.service.SaveCredentials(r.Context(), mux.Vars(r)["tenantID"], &creds)
+	if err != nil {
+		http.Error(w, "Failed to save credentials: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tenant.Redacted())
+}
+
+// DeleteCredentials switches a tenant to the shared Intuit app
+func (h *Handler) DeleteCredentials(w http.ResponseWriter, r *http.Request) {
+	tenant, err := h.service.SaveCredentials(r.Context(), mux.Vars(r)["tenantID"], nil)
+	if err != nil {
+		http.Error(w, "Failed to delete credentials: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tenant.Redacted())
+}
+
+// ScopesRequest sets the scopes a tenant requests
+type ScopesRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+// SaveScopes sets the QuickBooks scopes a tenant's users are asked to
+// grant, e.g. accounting only or accounting, payments and OpenID
+func (h *Handler) SaveScopes(w http.ResponseWriter, r *http.Request) {
+	var req ScopesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := h.service.SaveScopes(r.Context(), mux.Vars(r)["tenantID"], req.Scopes)
+	if err != nil {
+		http.Error(w, "Failed to save scopes: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tenant.Redacted())
+}
+
+// CreateKeyRequest names a new API key and its role
+type CreateKeyRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// CreateKey issues an API key for a tenant
+func (h *Handler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.service.CreateKey(r.Context(), mux.Vars(r)["tenantID"], req.Name, req.Role)
+	if err != nil {
+		http.Error(w, "Failed to create API key: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// ListKeys returns a tenant's API keys, without the keys themselves
+func (h *Handler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListKeys(r.Context(), mux.Vars(r)["tenantID"])
+	if err != nil {
+		http.Error(w, "Failed to list API keys: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeKey deletes one of a tenant's API keys
+func (h *Handler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.service.RevokeKey(r.Context(), vars["tenantID"], vars["keyID"]); err != nil {
+		http.Error(w, "Failed to revoke API key: "+err.Error(), status(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MemberRequest sets a member's role
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+// SaveMember adds a user to a tenant or changes their role
+func (h *Handler) SaveMember(w http.ResponseWriter, r *http.Request) {
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	member, err := h.service.SaveMember(r.Context(), vars["tenantID"], vars["userID"], req.Role)
+	if err != nil {
+		http.Error(w, "Failed to save member: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(member)
+}
+
+// ListMembers returns a tenant's members and their roles
+func (h *Handler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := h.service.ListMembers(r.Context(), mux.Vars(r)["tenantID"])
+	if err != nil {
+		http.Error(w, "Failed to list members: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
+// RemoveMember removes a user from a tenant
+func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.service.RemoveMember(r.Context(), vars["tenantID"], vars["userID"]); err != nil {
+		http.Error(w, "Failed to remove member: "+err.Error(), status(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateConnectLink issues a new connect link for a tenant
+func (h *Handler) CreateConnectLink(w http.ResponseWriter, r *http.Request) {
+	link, err := h.service.CreateConnectLink(r.Context(), mux.Vars(r)["tenantID"])
+	if err != nil {
+		http.Error(w, "Failed to create connect link: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+// Connect redirects a connect link's visitor to QuickBooks to authorize
+// the tenant's company
+func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := h.service.ConnectTenant(r.Context(), mux.Vars(r)["token"])
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Connect link is invalid or has expired", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get connect link: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	state, err := h.service.IssueState(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "Failed to generate state: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx := auth.WithIdentity(r.Context(), tenantID, tenantID, "")
+	authURL, err := h.authService.GetAuthorizationURL(ctx, state, r.Host)
+	if err != nil {
+		http.Error(w, "Failed to build authorization URL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
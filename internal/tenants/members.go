@@ -0,0 +1,147 @@
+// tenants/members.go
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/go-redis/redis/v8"
+)
+
+// TenantHeader names the tenant a user signed in without an API key acts
+// in; it is honoured only for the tenant's members
+const TenantHeader = "X-Tenant-ID"
+
+// Member grants a user a role in a tenant. Users signed in without an API
+// key act with the role of their membership.
+type Member struct {
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// membersKey returns the Redis hash of a tenant's members by user ID
+func (s *Service) membersKey(tenantID string) string {
+	return fmt.Sprintf("%s:tenants:members:%s", s.prefix, tenantID)
+}
+
+// SaveMember adds a user to a tenant or changes their role
+func (s *Service) SaveMember(ctx context.Context, tenantID, userID, role string) (*Member, error) {
+	if role == "" {
+		role = auth.DefaultRole
+	}
+	if !roles[role] {
+		return nil, ErrInvalidRole
+	}
+	if _, err := s.Get(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	member := &Member{
+		TenantID:  tenantID,
+		UserID:    userID,
+		Role:      role,
+		UpdatedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal member: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.membersKey(tenantID), userID, data).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save member: %w", err)
+	}
+	return member, nil
+}
+
+// GetMember returns a user's membership of a tenant
+func (s *Service) GetMember(ctx context.Context, tenantID, userID string) (*Member, error) {
+	data, err := s.client.HGet(ctx, s.membersKey(tenantID), userID).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	var member Member
+	if err := json.Unmarshal(data, &member); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal member: %w", err)
+	}
+	return &member, nil
+}
+
+// ListMembers returns a tenant's members by user ID
+func (s *Service) ListMembers(ctx context.Context, tenantID string) ([]*Member, error) {
+	entries, err := s.client.HGetAll(ctx, s.membersKey(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	members := make([]*Member, 0, len(entries))
+	for _, data := range entries {
+		var member Member
+		if err := json.Unmarshal([]byte(data), &member); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal member: %w", err)
+		}
+		members = append(members, &member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+// RemoveMember removes a user from a tenant
+func (s *Service) RemoveMember(ctx context.Context, tenantID, userID string) error {
+	removed, err := s.client.HDel(ctx, s.membersKey(tenantID), userID).Result()
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if removed == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MembershipMiddleware places users signed in without an API key in the
+// tenant named by TenantHeader, with the role of their membership, and
+// rejects users who are not members. API key requests keep their key's
+// tenant and role. Users naming no tenant stay outside any tenant with
+// auth.DefaultRole.
+func (s *Service) MembershipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if _, err := auth.GetVerifiedTenantID(ctx); err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenantID := r.Header.Get(TenantHeader)
+		if tenantID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		member, err := s.GetMember(ctx, tenantID, auth.GetUserID(ctx))
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "Not a member of tenant "+tenantID, http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to load membership: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx = context.WithValue(ctx, auth.TenantIDKey, member.TenantID)
+		ctx = context.WithValue(ctx, auth.RoleKey, member.Role)
+		ctx = logging.With(ctx, "tenant_id", member.TenantID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,243 @@
+// tenants/tenant.go
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrExists is returned when creating a tenant whose ID is taken
+	ErrExists = errors.New("tenant already exists")
+	// ErrNotFound is returned for unknown tenants, API keys and connect links
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidID is returned for tenant IDs that are not 3 to 64
+	// lowercase letters, digits, dashes and underscores
+	ErrInvalidID = errors.New("invalid tenant ID")
+	// ErrInvalidCredentials is returned for incomplete Intuit app credentials
+	ErrInvalidCredentials = errors.New("client_id and client_secret are required")
+	// ErrInvalidRedirectURI is returned for redirect URIs that are not
+	// absolute URLs
+	ErrInvalidRedirectURI = errors.New("invalid redirect URI")
+	// ErrInvalidScopes is returned for scopes that are unknown or omit the
+	// accounting scope
+	ErrInvalidScopes = errors.New("invalid scopes")
+)
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,63}$`)
+
+// Tenant is an ISV customer onboarded through the API
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Credentials of the tenant's own Intuit app; nil uses the shared app
+	Credentials *auth.AppCredentials `json:"credentials,omitempty"`
+	// Scopes the tenant's users are asked to grant; empty uses the defaults
+	Scopes    []string  `json:"scopes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Redacted returns a copy of the tenant without its client secret
+func (t *Tenant) Redacted() *Tenant {
+	tenant := *t
+	if t.Credentials != nil {
+		creds := *t.Credentials
+		creds.ClientSecret = ""
+		tenant.Credentials = &creds
+	}
+	return &tenant
+}
+
+// Service manages onboarded tenants, their API keys and connect links
+type Service struct {
+	client  redis.UniversalClient
+	prefix  string
+	baseURL string
+}
+
+// NewService creates a new tenant service. Connect links are served from
+// baseURL, the server's public origin.
+func NewService(client redis.UniversalClient, prefix, baseURL string) *Service {
+	return &Service{
+		client:  client,
+		prefix:  prefix,
+		baseURL: baseURL,
+	}
+}
+
+// tenantKey returns the Redis key for a tenant
+func (s *Service) tenantKey(tenantID string) string {
+	return fmt.Sprintf("%s:tenants:tenant:%s", s.prefix, tenantID)
+}
+
+// validateCredentials checks a tenant's own app credentials
+func validateCredentials(creds *auth.AppCredentials) error {
+	if creds == nil {
+		return nil
+	}
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return ErrInvalidCredentials
+	}
+	if creds.RedirectURI == "" && len(creds.RedirectURIs) > 0 {
+		return fmt.Errorf("%w: redirect_uri is required with redirect_uris", ErrInvalidRedirectURI)
+	}
+	for _, uri := range append([]string{creds.RedirectURI}, creds.RedirectURIs...) {
+		if uri != "" && origin(uri) == "" {
+			return fmt.Errorf("%w: %s", ErrInvalidRedirectURI, uri)
+		}
+	}
+	return nil
+}
+
+// validateScopes checks the scopes a tenant requests
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return nil
+	}
+	for _, scope := range scopes {
+		if !auth.HasScope(auth.KnownScopes, scope) {
+			return fmt.Errorf("%w: unknown scope %s", ErrInvalidScopes, scope)
+		}
+	}
+	if !auth.HasScope(scopes, auth.ScopeAccounting) {
+		return fmt.Errorf("%w: %s is required", ErrInvalidScopes, auth.ScopeAccounting)
+	}
+	return nil
+}
+
+// origin returns the scheme and host of an absolute URL, or "" for others
+func origin(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Create saves a new tenant, generating its ID when empty
+func (s *Service) Create(ctx context.Context, tenant *Tenant) error {
+	if tenant.ID == "" {
+		tenant.ID = jobs.NewID()
+	}
+	if !idPattern.MatchString(tenant.ID) {
+		return ErrInvalidID
+	}
+	if err := validateCredentials(tenant.Credentials); err != nil {
+		return err
+	}
+	if err := validateScopes(tenant.Scopes); err != nil {
+		return err
+	}
+	tenant.CreatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(tenant)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tenant: %w", err)
+	}
+	created, err := s.client.SetNX(ctx, s.tenantKey(tenant.ID), data, 0).Result()
+	if err != nil {
+		return fmt.Errorf("failed to save tenant: %w", err)
+	}
+	if !created {
+		return ErrExists
+	}
+	return nil
+}
+
+// Get returns a tenant
+func (s *Service) Get(ctx context.Context, tenantID string) (*Tenant, error) {
+	data, err := s.client.Get(ctx, s.tenantKey(tenantID)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	var tenant Tenant
+	if err := json.Unmarshal(data, &tenant); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tenant: %w", err)
+	}
+	return &tenant, nil
+}
+
+// SaveCredentials sets the Intuit app a tenant connects through; nil
+// switches it to the shared app
+func (s *Service) SaveCredentials(ctx context.Context, tenantID string, creds *auth.AppCredentials) (*Tenant, error) {
+	if err := validateCredentials(creds); err != nil {
+		return nil, err
+	}
+	tenant, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	tenant.Credentials = creds
+	return tenant, s.save(ctx, tenant)
+}
+
+// SaveScopes sets the scopes a tenant's users are asked to grant; empty
+// switches it to the defaults. Connections made before must be reconnected
+// to grant added scopes.
+func (s *Service) SaveScopes(ctx context.Context, tenantID string, scopes []string) (*Tenant, error) {
+	if err := validateScopes(scopes); err != nil {
+		return nil, err
+	}
+	tenant, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	tenant.Scopes = scopes
+	return tenant, s.save(ctx, tenant)
+}
+
+// save overwrites a tenant
+func (s *Service) save(ctx context.Context, tenant *Tenant) error {
+	data, err := json.Marshal(tenant)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tenant: %w", err)
+	}
+	if err := s.client.Set(ctx, s.tenantKey(tenant.ID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save tenant: %w", err)
+	}
+	return nil
+}
+
+// AppCredentials implements auth.CredentialSource. Tenants that were not
+// onboarded through the API use the shared app.
+func (s *Service) AppCredentials(ctx context.Context, tenantID string) (*auth.AppCredentials, error) {
+	if tenantID == "" {
+		return nil, nil
+	}
+	tenant, err := s.Get(ctx, tenantID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tenant.Credentials, nil
+}
+
+// TenantScopes implements auth.ScopeSource
+func (s *Service) TenantScopes(ctx context.Context, tenantID string) ([]string, error) {
+	if tenantID == "" {
+		return nil, nil
+	}
+	tenant, err := s.Get(ctx, tenantID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tenant.Scopes, nil
+}
@@ -0,0 +1,52 @@
+// timezone/context.go
+package timezone
+
+import (
+	"context"
+	"time"
+)
+
+// contextKey is the type for time zone context keys
+type contextKey string
+
+// locationKey holds the realm's *time.Location
+const locationKey contextKey = "timezone_location"
+
+// WithLocation returns a context carrying the realm's time zone
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	if loc == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, locationKey, loc)
+}
+
+// FromContext returns the realm's time zone, defaulting to UTC
+func FromContext(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(locationKey).(*time.Location); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// Now returns the current time in the realm's time zone
+func Now(ctx context.Context) time.Time {
+	return time.Now().In(FromContext(ctx))
+}
+
+// Today returns the realm-local calendar date as midnight UTC, the same form
+// time.Parse gives QuickBooks dates such as TxnDate and DueDate, so the two
+// compare directly
+func Today(ctx context.Context) time.Time {
+	return Date(Now(ctx))
+}
+
+// Date returns t's calendar date as midnight UTC
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// At returns the instant a realm-local calendar date reaches the given time
+// of day, e.g. when a reminder for a due date should go out
+func At(ctx context.Context, date time.Time, hour, minute int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, FromContext(ctx))
+}
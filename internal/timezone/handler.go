@@ -0,0 +1,84 @@
+// timezone/handler.go
+package timezone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// Servicer keeps each realm's time zone and applies it to requests.
+// *Service implements it; tests can substitute a fake.
+type Servicer interface {
+	Get(ctx context.Context, realmID string) (*Setting, error)
+	SetZone(ctx context.Context, realmID, zone string) (*Setting, error)
+	Location(ctx context.Context, realmID string) *time.Location
+	Middleware(next http.Handler) http.Handler
+}
+
+// Handler provides HTTP handlers for a realm's time zone
+type Handler struct {
+	service Servicer
+}
+
+// NewHandler creates a new time zone handler
+func NewHandler(service Servicer) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetTimeZone returns the current realm's time zone
+func (h *Handler) GetTimeZone(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get company ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.service.Get(r.Context(), realmID)
+	if err != nil {
+		http.Error(w, "Failed to get time zone: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(setting)
+}
+
+// SetTimeZone overrides the current realm's time zone. An empty zone goes
+// back to the zone derived from the company's address.
+func (h *Handler) SetTimeZone(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get company ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Zone string `json:"zone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.service.SetZone(r.Context(), realmID, body.Zone)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidZone) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "Failed to set time zone: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(setting)
+}
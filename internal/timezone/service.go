@@ -0,0 +1,186 @@
+// timezone/service.go
+package timezone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+	"github.com/go-redis/redis/v8"
+)
+
+// Time zone sources
+const (
+	SourceCompanyInfo = "company_info"
+	SourceOverride    = "override"
+	SourceDefault     = "default"
+)
+
+const (
+	// derivedTTL is how long a zone derived from CompanyInfo is kept before
+	// the company's address is read again
+	derivedTTL = 24 * time.Hour
+
+	// localTTL is how long a realm's zone is cached in memory
+	localTTL = 10 * time.Minute
+)
+
+// ErrInvalidZone is returned for names that are not IANA time zones
+var ErrInvalidZone = errors.New("invalid time zone")
+
+// CompanyInfoSource reads the current company's information
+type CompanyInfoSource interface {
+	GetCompanyInfo(ctx context.Context) (*qbclient.CompanyInfo, error)
+}
+
+// Setting is a realm's time zone and where it came from
+type Setting struct {
+	RealmID   string    `json:"realm_id"`
+	Zone      string    `json:"zone"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// cachedLocation is a realm's resolved zone and when it was resolved
+type cachedLocation struct {
+	loc     *time.Location
+	fetched time.Time
+}
+
+// Service stores each realm's time zone. Zones come from the company's
+// address in CompanyInfo unless a user overrides them.
+type Service struct {
+	client  redis.UniversalClient
+	prefix  string
+	company CompanyInfoSource
+
+	locations *lru.Cache[string, cachedLocation] // by realm
+}
+
+// NewService creates a new time zone service
+func NewService(client redis.UniversalClient, prefix string, company CompanyInfoSource) *Service {
+	return &Service{
+		client:    client,
+		prefix:    prefix,
+		company:   company,
+		locations: lru.New[string, cachedLocation]("timezone.locations", 10000),
+	}
+}
+
+// key holds a realm's setting
+func (s *Service) key(realmID string) string {
+	return fmt.Sprintf("%s:timezone:%s", s.prefix, realmID)
+}
+
+// Get returns a realm's time zone, deriving it from CompanyInfo when no
+// override or recent derivation is stored. ctx must carry the realm's
+// QuickBooks credentials for the derivation.
+func (s *Service) Get(ctx context.Context, realmID string) (*Setting, error) {
+	data, err := s.client.Get(ctx, s.key(realmID)).Bytes()
+	if err == nil {
+		var setting Setting
+		if err := json.Unmarshal(data, &setting); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal time zone: %w", err)
+		}
+		return &setting, nil
+	}
+	if err != redis.Nil {
+		return nil, fmt.Errorf("failed to get time zone: %w", err)
+	}
+
+	setting := &Setting{RealmID: realmID, Zone: "UTC", Source: SourceDefault, UpdatedAt: time.Now().UTC()}
+	info, err := s.company.GetCompanyInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company info: %w", err)
+	}
+	if zone := zoneFromCompanyInfo(info); zone != "" {
+		setting.Zone = zone
+		setting.Source = SourceCompanyInfo
+	}
+
+	if err := s.save(ctx, setting, derivedTTL); err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// SetZone overrides a realm's time zone with an IANA zone name. An empty
+// zone removes the override so the zone is derived from CompanyInfo again.
+func (s *Service) SetZone(ctx context.Context, realmID, zone string) (*Setting, error) {
+	defer s.forget(realmID)
+
+	if zone == "" {
+		if err := s.client.Del(ctx, s.key(realmID)).Err(); err != nil {
+			return nil, fmt.Errorf("failed to clear time zone: %w", err)
+		}
+		return s.Get(ctx, realmID)
+	}
+
+	if _, err := time.LoadLocation(zone); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidZone, zone)
+	}
+	setting := &Setting{RealmID: realmID, Zone: zone, Source: SourceOverride, UpdatedAt: time.Now().UTC()}
+	if err := s.save(ctx, setting, 0); err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// save stores a setting, expiring after ttl unless ttl is zero
+func (s *Service) save(ctx context.Context, setting *Setting, ttl time.Duration) error {
+	data, err := json.Marshal(setting)
+	if err != nil {
+		return fmt.Errorf("failed to marshal time zone: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(setting.RealmID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save time zone: %w", err)
+	}
+	return nil
+}
+
+// forget drops a realm's cached location
+func (s *Service) forget(realmID string) {
+	s.locations.Remove(realmID)
+}
+
+// Location returns a realm's time zone, falling back to UTC when it cannot
+// be resolved
+func (s *Service) Location(ctx context.Context, realmID string) *time.Location {
+	cached, ok := s.locations.Get(realmID)
+	if ok && time.Since(cached.fetched) < localTTL {
+		return cached.loc
+	}
+
+	loc := time.UTC
+	setting, err := s.Get(ctx, realmID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to resolve time zone", "realm_id", realmID, "error", err)
+		return loc
+	}
+	if l, err := time.LoadLocation(setting.Zone); err == nil {
+		loc = l
+	}
+
+	s.locations.Add(realmID, cachedLocation{loc: loc, fetched: time.Now()})
+	return loc
+}
+
+// Middleware puts the current realm's time zone in the request context
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := auth.GetCompanyID(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := WithLocation(r.Context(), s.Location(r.Context(), realmID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
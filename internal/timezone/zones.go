@@ -0,0 +1,117 @@
+// timezone/zones.go
+package timezone
+
+import (
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// countryAliases maps the country names QuickBooks stores to ISO codes
+var countryAliases = map[string]string{
+	"USA":            "US",
+	"UNITED STATES":  "US",
+	"CANADA":         "CA",
+	"UK":             "GB",
+	"UNITED KINGDOM": "GB",
+	"AUSTRALIA":      "AU",
+	"INDIA":          "IN",
+	"NEW ZEALAND":    "NZ",
+	"IRELAND":        "IE",
+	"FRANCE":         "FR",
+	"SPAIN":          "ES",
+	"MEXICO":         "MX",
+}
+
+// countryZones are the zones of countries with one main time zone, or the
+// fallback for countries whose subdivision is unknown
+var countryZones = map[string]string{
+	"US": "America/New_York",
+	"CA": "America/Toronto",
+	"AU": "Australia/Sydney",
+	"GB": "Europe/London",
+	"IE": "Europe/Dublin",
+	"FR": "Europe/Paris",
+	"ES": "Europe/Madrid",
+	"DE": "Europe/Berlin",
+	"IT": "Europe/Rome",
+	"NL": "Europe/Amsterdam",
+	"BE": "Europe/Brussels",
+	"MX": "America/Mexico_City",
+	"IN": "Asia/Kolkata",
+	"NZ": "Pacific/Auckland",
+	"SG": "Asia/Singapore",
+	"HK": "Asia/Hong_Kong",
+	"MY": "Asia/Kuala_Lumpur",
+	"PH": "Asia/Manila",
+	"AE": "Asia/Dubai",
+	"ZA": "Africa/Johannesburg",
+}
+
+// subdivisionZones are the zones of states and provinces in countries that
+// span several time zones
+var subdivisionZones = map[string]map[string]string{
+	"US": {
+		"CT": "America/New_York", "DE": "America/New_York", "DC": "America/New_York",
+		"FL": "America/New_York", "GA": "America/New_York", "ME": "America/New_York",
+		"MD": "America/New_York", "MA": "America/New_York", "NH": "America/New_York",
+		"NJ": "America/New_York", "NY": "America/New_York", "NC": "America/New_York",
+		"OH": "America/New_York", "PA": "America/New_York", "RI": "America/New_York",
+		"SC": "America/New_York", "VT": "America/New_York", "VA": "America/New_York",
+		"WV": "America/New_York", "MI": "America/Detroit", "IN": "America/Indiana/Indianapolis",
+		"KY": "America/New_York",
+		"AL": "America/Chicago", "AR": "America/Chicago", "IL": "America/Chicago",
+		"IA": "America/Chicago", "KS": "America/Chicago", "LA": "America/Chicago",
+		"MN": "America/Chicago", "MS": "America/Chicago", "MO": "America/Chicago",
+		"NE": "America/Chicago", "ND": "America/Chicago", "OK": "America/Chicago",
+		"SD": "America/Chicago", "TN": "America/Chicago", "TX": "America/Chicago",
+		"WI": "America/Chicago",
+		"CO": "America/Denver", "ID": "America/Boise", "MT": "America/Denver",
+		"NM": "America/Denver", "UT": "America/Denver", "WY": "America/Denver",
+		"AZ": "America/Phoenix",
+		"CA": "America/Los_Angeles", "NV": "America/Los_Angeles", "OR": "America/Los_Angeles",
+		"WA": "America/Los_Angeles",
+		"AK": "America/Anchorage", "HI": "Pacific/Honolulu", "PR": "America/Puerto_Rico",
+	},
+	"CA": {
+		"NL": "America/St_Johns", "NS": "America/Halifax", "NB": "America/Moncton",
+		"PE": "America/Halifax", "QC": "America/Toronto", "ON": "America/Toronto",
+		"MB": "America/Winnipeg", "SK": "America/Regina", "AB": "America/Edmonton",
+		"BC": "America/Vancouver", "YT": "America/Whitehorse", "NT": "America/Yellowknife",
+		"NU": "America/Iqaluit",
+	},
+	"AU": {
+		"NSW": "Australia/Sydney", "ACT": "Australia/Sydney", "VIC": "Australia/Melbourne",
+		"QLD": "Australia/Brisbane", "SA": "Australia/Adelaide", "WA": "Australia/Perth",
+		"TAS": "Australia/Hobart", "NT": "Australia/Darwin",
+	},
+}
+
+// zoneFromCompanyInfo derives a company's time zone from its address,
+// preferring the company address over the legal address. It returns an
+// empty string when the country is unknown.
+func zoneFromCompanyInfo(info *qbclient.CompanyInfo) string {
+	for _, addr := range []qbclient.CompanyAddress{info.CompanyAddr, info.LegalAddr} {
+		country := addr.Country
+		if country == "" {
+			country = info.Country
+		}
+		if zone := zoneFor(country, addr.CountrySubDivisionCode); zone != "" {
+			return zone
+		}
+	}
+	return zoneFor(info.Country, "")
+}
+
+// zoneFor returns the zone for a country and optional subdivision code
+func zoneFor(country, subdivision string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if code, ok := countryAliases[country]; ok {
+		country = code
+	}
+	subdivision = strings.ToUpper(strings.TrimSpace(subdivision))
+	if zone, ok := subdivisionZones[country][subdivision]; ok {
+		return zone
+	}
+	return countryZones[country]
+}
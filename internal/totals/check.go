@@ -0,0 +1,177 @@
+// totals/check.go
+package totals
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/shopspring/decimal"
+)
+
+// Discrepancy kinds
+const (
+	KindLine     = "line"     // line amount differs from quantity times price
+	KindSubtotal = "subtotal" // subtotal line differs from the sum of lines
+	KindTaxLine  = "tax_line" // tax differs from the taxable amount at the rate
+	KindTax      = "tax"      // total tax differs from the sum of tax lines
+	KindTotal    = "total"    // total differs from lines less discounts plus tax
+)
+
+// Discrepancy is a difference between a locally computed amount and the
+// amount QuickBooks returned
+type Discrepancy struct {
+	Kind     string       `json:"kind"`
+	LineID   string       `json:"line_id,omitempty"`
+	Expected money.Amount `json:"expected"`
+	Actual   money.Amount `json:"actual"`
+}
+
+// String describes the discrepancy for a response header or log line
+func (d Discrepancy) String() string {
+	subject := d.Kind
+	if d.LineID != "" {
+		subject += " " + d.LineID
+	}
+	return fmt.Sprintf("%s: computed %s, QuickBooks returned %s", subject, d.Expected, d.Actual)
+}
+
+// document is the subset of a sales transaction used to verify its totals
+type document struct {
+	GlobalTaxCalculation string `json:"GlobalTaxCalculation"`
+	Line                 []struct {
+		ID                  string          `json:"Id"`
+		Amount              decimal.Decimal `json:"Amount"`
+		DetailType          string          `json:"DetailType"`
+		SalesItemLineDetail *struct {
+			Qty       *decimal.Decimal `json:"Qty"`
+			UnitPrice *decimal.Decimal `json:"UnitPrice"`
+		} `json:"SalesItemLineDetail"`
+	} `json:"Line"`
+	TxnTaxDetail struct {
+		TotalTax decimal.Decimal `json:"TotalTax"`
+		TaxLine  []struct {
+			Amount        decimal.Decimal `json:"Amount"`
+			TaxLineDetail struct {
+				TaxPercent       decimal.Decimal `json:"TaxPercent"`
+				NetAmountTaxable decimal.Decimal `json:"NetAmountTaxable"`
+			} `json:"TaxLineDetail"`
+		} `json:"TaxLine"`
+	} `json:"TxnTaxDetail"`
+	TotalAmt decimal.Decimal `json:"TotalAmt"`
+}
+
+// decode reads the fields used to verify totals from an entity
+func decode(entity map[string]interface{}) (*document, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal entity: %w", err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode entity: %w", err)
+	}
+	return &doc, nil
+}
+
+// Check recomputes a sales transaction's totals from its lines and tax
+// detail and returns where they differ from the amounts QuickBooks computed.
+// Computed amounts are rounded with policy before comparing.
+func Check(entity map[string]interface{}, policy money.Policy) ([]Discrepancy, error) {
+	doc, err := decode(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Discrepancy
+	compare := func(kind, lineID string, expected, actual decimal.Decimal) {
+		expected = policy.Round(expected)
+		if !expected.Equal(actual) {
+			found = append(found, Discrepancy{Kind: kind, LineID: lineID, Expected: money.NewAmount(expected), Actual: money.NewAmount(actual)})
+		}
+	}
+
+	var lines, discounts decimal.Decimal
+	for _, line := range doc.Line {
+		switch line.DetailType {
+		case "SalesItemLineDetail", "GroupLineDetail":
+			lines = lines.Add(line.Amount)
+			detail := line.SalesItemLineDetail
+			if detail != nil && detail.Qty != nil && detail.UnitPrice != nil {
+				compare(KindLine, line.ID, detail.Qty.Mul(*detail.UnitPrice), line.Amount)
+			}
+		case "DiscountLineDetail":
+			discounts = discounts.Add(line.Amount)
+		}
+	}
+	for _, line := range doc.Line {
+		if line.DetailType == "SubTotalLineDetail" {
+			compare(KindSubtotal, line.ID, lines, line.Amount)
+		}
+	}
+
+	hundred := decimal.NewFromInt(100)
+	var tax decimal.Decimal
+	for _, line := range doc.TxnTaxDetail.TaxLine {
+		detail := line.TaxLineDetail
+		compare(KindTaxLine, "", detail.NetAmountTaxable.Mul(detail.TaxPercent).Div(hundred), line.Amount)
+		tax = tax.Add(line.Amount)
+	}
+	if len(doc.TxnTaxDetail.TaxLine) > 0 {
+		compare(KindTax, "", tax, doc.TxnTaxDetail.TotalTax)
+	}
+
+	// Tax-inclusive lines are entered gross and back-calculated, so only
+	// tax-exclusive totals add up exactly
+	if doc.GlobalTaxCalculation != "TaxInclusive" {
+		compare(KindTotal, "", lines.Sub(discounts).Add(doc.TxnTaxDetail.TotalTax), doc.TotalAmt)
+	}
+
+	return found, nil
+}
+
+// Totals are a sales transaction's amounts computed from its lines
+type Totals struct {
+	Subtotal money.Amount `json:"subtotal"`
+	Discount money.Amount `json:"discount"`
+	Tax      money.Amount `json:"tax"`
+	Total    money.Amount `json:"total"`
+}
+
+// IsSalesTransaction reports whether entity has totals Compute understands
+func IsSalesTransaction(entity string) bool {
+	return verifiedEntities[entity]
+}
+
+// Compute works out a sales transaction's totals from a payload about to
+// be sent, rounded with policy. Lines without an amount are priced at
+// quantity times unit price. Tax is taken from the payload's tax detail;
+// QuickBooks may compute it differently when automated sales tax applies.
+func Compute(entity map[string]interface{}, policy money.Policy) (*Totals, error) {
+	doc, err := decode(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var totals Totals
+	for _, line := range doc.Line {
+		switch line.DetailType {
+		case "SalesItemLineDetail", "GroupLineDetail":
+			amount := line.Amount
+			detail := line.SalesItemLineDetail
+			if amount.IsZero() && detail != nil && detail.Qty != nil && detail.UnitPrice != nil {
+				amount = detail.Qty.Mul(*detail.UnitPrice)
+			}
+			totals.Subtotal = money.NewAmount(totals.Subtotal.Add(policy.Round(amount)))
+		case "DiscountLineDetail":
+			totals.Discount = money.NewAmount(totals.Discount.Add(policy.Round(line.Amount)))
+		}
+	}
+	totals.Tax = money.NewAmount(policy.Round(doc.TxnTaxDetail.TotalTax))
+
+	totals.Total = money.NewAmount(totals.Subtotal.Sub(totals.Discount.Decimal))
+	if doc.GlobalTaxCalculation != "TaxInclusive" {
+		totals.Total = money.NewAmount(totals.Total.Add(totals.Tax.Decimal))
+	}
+	return &totals, nil
+}
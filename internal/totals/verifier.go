@@ -0,0 +1,81 @@
+// totals/verifier.go
+package totals
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// WarningHeader carries total discrepancies back to the client
+const WarningHeader = "X-Total-Warning"
+
+// verifiedEntities are the sales transactions whose totals are verified
+var verifiedEntities = map[string]bool{
+	"Invoice":       true,
+	"Estimate":      true,
+	"SalesReceipt":  true,
+	"CreditMemo":    true,
+	"RefundReceipt": true,
+}
+
+// discrepancies counts discrepancies found by entity and kind, e.g.
+// "Invoice.tax"
+var discrepancies = expvar.NewMap("qb_total_discrepancies")
+
+// contextKey is the type for total verification context keys
+type contextKey string
+
+// headerKey holds the response headers of the current request
+const headerKey contextKey = "totals_header"
+
+// Verifier is a qbclient.Transformer that recomputes the totals of sales
+// transactions QuickBooks returns from creates and updates. Discrepancies
+// never fail the write; they are logged, counted and returned as warning
+// headers so tax setup or rounding mismatches are noticed early.
+type Verifier struct{}
+
+// NewVerifier creates a new total verifier
+func NewVerifier() *Verifier {
+	return &Verifier{}
+}
+
+// Transform verifies entities returned by creates and updates
+func (v *Verifier) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	if stage != qbclient.StageAfterCreate && stage != qbclient.StageAfterUpdate {
+		return data, nil
+	}
+	if !verifiedEntities[entity] {
+		return data, nil
+	}
+
+	found, err := Check(data, money.PolicyFromContext(ctx))
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to verify totals", "entity", entity, "error", err)
+		return data, nil
+	}
+
+	id, _ := data["Id"].(string)
+	header, _ := ctx.Value(headerKey).(http.Header)
+	for _, d := range found {
+		discrepancies.Add(entity+"."+d.Kind, 1)
+		logging.FromContext(ctx).Warn("total mismatch", "entity", entity, "id", id, "discrepancy", d.String())
+		if header != nil {
+			header.Add(WarningHeader, d.String())
+		}
+	}
+	return data, nil
+}
+
+// Middleware lets discrepancies found during a request reach its response
+// headers
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), headerKey, w.Header())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,95 @@
+// tracing/tracing.go
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer spans are created with
+const instrumentationName = "github.com/eGGnogSC/qbserver"
+
+// DefaultServiceName identifies the server's traces unless configured
+const DefaultServiceName = "qbserver"
+
+// Config configures trace export over OTLP/HTTP
+type Config struct {
+	Enabled bool
+	// Endpoint is the collector's OTLP/HTTP traces URL, e.g.
+	// http://localhost:4318/v1/traces. When empty, the standard
+	// OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint string
+	// Headers are sent with every export, e.g. a vendor API key
+	Headers     map[string]string
+	ServiceName string
+	// SampleRatio is the fraction of new traces recorded, 0 meaning all.
+	// Requests carrying a sampled parent are always recorded.
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation. It returns a function flushing buffered spans on shutdown.
+// When tracing is disabled spans are not recorded, but trace context is
+// still propagated.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var options []otlptracehttp.Option
+	if config.Endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(config.Endpoint))
+	}
+	if len(config.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(config.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(config.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, options...)
+}
+
+// End ends a span, marking it failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,106 @@
+// transform/handler.go
+package transform
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// Handler provides HTTP handlers for payload transformation rules
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new transformation handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetRules returns the tenant's rules
+func (h *Handler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.service.GetRules(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to get transform rules: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": rules,
+	})
+}
+
+// SaveRules replaces the tenant's rules
+func (h *Handler) SaveRules(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Rules == nil {
+		body.Rules = []Rule{}
+	}
+
+	if err := h.service.SaveRules(r.Context(), auth.GetTenantID(r.Context()), body.Rules); err != nil {
+		http.Error(w, "Failed to save transform rules: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": body.Rules,
+	})
+}
+
+// Preview applies rules to a sample payload without calling QuickBooks.
+// Rules in the request are tried instead of the saved ones when given.
+func (h *Handler) Preview(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Entity  string                 `json:"entity"`
+		Stage   string                 `json:"stage"`
+		Payload map[string]interface{} `json:"payload"`
+		Rules   []Rule                 `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Entity == "" || body.Payload == nil {
+		http.Error(w, "entity and payload are required", http.StatusBadRequest)
+		return
+	}
+	if body.Stage == "" {
+		body.Stage = qbclient.StageBeforeCreate
+	}
+	if !validStages[body.Stage] {
+		http.Error(w, "Unknown stage", http.StatusBadRequest)
+		return
+	}
+
+	rules := body.Rules
+	if rules == nil {
+		var err error
+		if rules, err = h.service.GetRules(r.Context(), auth.GetTenantID(r.Context())); err != nil {
+			http.Error(w, "Failed to get transform rules: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if err := Validate(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"payload": Apply(rules, body.Stage, body.Entity, body.Payload),
+	})
+}
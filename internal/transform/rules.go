@@ -0,0 +1,322 @@
+// transform/rules.go
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// maxRules bounds a tenant's rule set
+const maxRules = 50
+
+// Condition tests a field of the entity. Paths are dot-separated and a
+// segment ending in [] applies to every element, e.g. "Line[].Amount";
+// the condition holds if any addressed value satisfies it.
+type Condition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"` // eq, ne, gt, lt, exists or missing
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Action changes a field of the entity
+type Action struct {
+	Op     string      `json:"op"` // set, default, append, prepend, round or remove
+	Field  string      `json:"field"`
+	Value  interface{} `json:"value,omitempty"`
+	Places *int        `json:"places,omitempty"` // round only; defaults to 2
+}
+
+// Rule applies actions to matching entities at the given stages
+type Rule struct {
+	Name     string      `json:"name"`
+	Entity   string      `json:"entity"`           // e.g. "Invoice", or "*" for every entity
+	Stages   []string    `json:"stages,omitempty"` // defaults to before_create and before_update
+	When     []Condition `json:"when,omitempty"`
+	Actions  []Action    `json:"actions"`
+	Disabled bool        `json:"disabled,omitempty"`
+}
+
+// validStages are the stages rules may run at
+var validStages = map[string]bool{
+	qbclient.StageBeforeCreate: true,
+	qbclient.StageBeforeUpdate: true,
+	qbclient.StageAfterCreate:  true,
+	qbclient.StageAfterUpdate:  true,
+	qbclient.StageAfterRead:    true,
+}
+
+// Validate checks a rule set and applies defaults
+func Validate(rules []Rule) error {
+	if len(rules) > maxRules {
+		return fmt.Errorf("at most %d rules are allowed", maxRules)
+	}
+	names := make(map[string]bool)
+	for n := range rules {
+		if err := rules[n].validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", n+1, err)
+		}
+		if names[rules[n].Name] {
+			return fmt.Errorf("rule %d: duplicate name %q", n+1, rules[n].Name)
+		}
+		names[rules[n].Name] = true
+	}
+	return nil
+}
+
+// validate checks one rule
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Entity == "" {
+		return fmt.Errorf("entity is required")
+	}
+	if len(r.Stages) == 0 {
+		r.Stages = []string{qbclient.StageBeforeCreate, qbclient.StageBeforeUpdate}
+	}
+	for _, stage := range r.Stages {
+		if !validStages[stage] {
+			return fmt.Errorf("unknown stage %q", stage)
+		}
+	}
+
+	for _, c := range r.When {
+		if err := checkPath(c.Field); err != nil {
+			return err
+		}
+		switch c.Op {
+		case "eq", "ne":
+		case "gt", "lt":
+			if _, ok := toFloat(c.Value); !ok {
+				return fmt.Errorf("%s condition on %s needs a numeric value", c.Op, c.Field)
+			}
+		case "exists", "missing":
+		default:
+			return fmt.Errorf("unknown condition op %q", c.Op)
+		}
+	}
+
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	for _, a := range r.Actions {
+		if err := checkPath(a.Field); err != nil {
+			return err
+		}
+		switch a.Op {
+		case "set", "default":
+			if a.Value == nil {
+				return fmt.Errorf("%s on %s needs a value", a.Op, a.Field)
+			}
+		case "append", "prepend":
+			if _, ok := a.Value.(string); !ok {
+				return fmt.Errorf("%s on %s needs a string value", a.Op, a.Field)
+			}
+		case "round":
+			if a.Places != nil && (*a.Places < 0 || *a.Places > 6) {
+				return fmt.Errorf("round places must be between 0 and 6")
+			}
+		case "remove":
+		default:
+			return fmt.Errorf("unknown action op %q", a.Op)
+		}
+	}
+	return nil
+}
+
+// checkPath validates a field path
+func checkPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("field is required")
+	}
+	for _, segment := range strings.Split(path, ".") {
+		name := strings.TrimSuffix(segment, "[]")
+		if name == "" || strings.ContainsAny(name, "[]") {
+			return fmt.Errorf("invalid field path %q", path)
+		}
+	}
+	if strings.HasSuffix(path, "[]") {
+		return fmt.Errorf("field path %q must name a field of the list elements", path)
+	}
+	return nil
+}
+
+// Apply runs the rules that match stage and entity over data, in order
+func Apply(rules []Rule, stage, entity string, data map[string]interface{}) map[string]interface{} {
+	for _, rule := range rules {
+		if rule.Disabled || (rule.Entity != "*" && !strings.EqualFold(rule.Entity, entity)) || !contains(rule.Stages, stage) {
+			continue
+		}
+
+		matched := true
+		for _, c := range rule.When {
+			if !c.holds(data) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		for _, a := range rule.Actions {
+			a.apply(data)
+		}
+	}
+	return data
+}
+
+// holds evaluates the condition against data
+func (c Condition) holds(data map[string]interface{}) bool {
+	var values []interface{}
+	visit(data, strings.Split(c.Field, "."), false, func(parent map[string]interface{}, key string) {
+		if v, ok := parent[key]; ok {
+			values = append(values, v)
+		}
+	})
+
+	switch c.Op {
+	case "exists":
+		return len(values) > 0
+	case "missing":
+		return len(values) == 0
+	}
+	for _, v := range values {
+		switch c.Op {
+		case "eq":
+			if equal(v, c.Value) {
+				return true
+			}
+		case "ne":
+			if !equal(v, c.Value) {
+				return true
+			}
+		case "gt", "lt":
+			a, ok := toFloat(v)
+			b, _ := toFloat(c.Value)
+			if ok && ((c.Op == "gt" && a > b) || (c.Op == "lt" && a < b)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apply performs the action on every addressed field
+func (a Action) apply(data map[string]interface{}) {
+	create := a.Op == "set" || a.Op == "default"
+	visit(data, strings.Split(a.Field, "."), create, func(parent map[string]interface{}, key string) {
+		current, exists := parent[key]
+		switch a.Op {
+		case "set":
+			parent[key] = clone(a.Value)
+		case "default":
+			if !exists {
+				parent[key] = clone(a.Value)
+			}
+		case "append":
+			// Skip values that already carry the suffix so updates stay idempotent
+			s, _ := current.(string)
+			if suffix := a.Value.(string); !strings.HasSuffix(s, suffix) {
+				parent[key] = s + suffix
+			}
+		case "prepend":
+			s, _ := current.(string)
+			if prefix := a.Value.(string); !strings.HasPrefix(s, prefix) {
+				parent[key] = prefix + s
+			}
+		case "round":
+			if f, ok := toFloat(current); ok && exists {
+				places := 2
+				if a.Places != nil {
+					places = *a.Places
+				}
+				scale := math.Pow(10, float64(places))
+				parent[key] = math.Round(f*scale) / scale
+			}
+		case "remove":
+			delete(parent, key)
+		}
+	})
+}
+
+// visit calls fn with the object and key each path addresses. With create,
+// missing intermediate objects are added; array segments only visit
+// existing elements.
+func visit(node map[string]interface{}, segments []string, create bool, fn func(parent map[string]interface{}, key string)) {
+	segment := segments[0]
+	name := strings.TrimSuffix(segment, "[]")
+	each := name != segment
+
+	if len(segments) == 1 && !each {
+		fn(node, name)
+		return
+	}
+
+	if each {
+		list, _ := node[name].([]interface{})
+		for _, element := range list {
+			if child, ok := element.(map[string]interface{}); ok {
+				visit(child, segments[1:], create, fn)
+			}
+		}
+		return
+	}
+
+	child, ok := node[name].(map[string]interface{})
+	if !ok {
+		if !create || node[name] != nil {
+			return
+		}
+		child = make(map[string]interface{})
+		node[name] = child
+	}
+	visit(child, segments[1:], create, fn)
+}
+
+// equal compares JSON values, treating numbers and their string forms alike
+// since QuickBooks sends IDs as strings
+func equal(a, b interface{}) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// toFloat converts a JSON number or numeric string
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// clone deep-copies a rule value so entities never share it
+func clone(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+// transform/service.go
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/go-redis/redis/v8"
+)
+
+// Service stores tenants' transformation rules and applies them to
+// QuickBooks payloads as a qbclient.Transformer
+type Service struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewService creates a new transformation service
+func NewService(client redis.UniversalClient, prefix string) *Service {
+	return &Service{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key holds a tenant's rules
+func (s *Service) key(tenantID string) string {
+	return fmt.Sprintf("%s:transform:rules:%s", s.prefix, tenantID)
+}
+
+// GetRules returns a tenant's rules; tenants without rules get none
+func (s *Service) GetRules(ctx context.Context, tenantID string) ([]Rule, error) {
+	data, err := s.client.Get(ctx, s.key(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return []Rule{}, nil
+		}
+		return nil, fmt.Errorf("failed to get transform rules: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transform rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SaveRules validates and replaces a tenant's rules
+func (s *Service) SaveRules(ctx context.Context, tenantID string, rules []Rule) error {
+	if err := Validate(rules); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transform rules: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save transform rules: %w", err)
+	}
+	return nil
+}
+
+// Transform applies the current tenant's rules. Calls without a tenant,
+// such as operator tooling, pass through unchanged.
+func (s *Service) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		return data, nil
+	}
+
+	rules, err := s.GetRules(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(rules, stage, entity, data), nil
+}
@@ -0,0 +1,119 @@
+// trash/handler.go
+package trash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/periodlock"
+	"github.com/eGGnogSC/qbserver/internal/versions"
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for deletes, voids and the trash
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new trash handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrRestored), errors.Is(err, ErrRestoring), errors.Is(err, writelock.ErrTimeout),
+		errors.Is(err, periodlock.ErrPeriodLocked):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// Delete deletes an entity, keeping a copy in the trash, e.g.
+// DELETE /api/invoices/42
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, "delete", h.service.Delete)
+}
+
+// Void voids a transaction, keeping a copy in the trash, e.g.
+// POST /api/invoices/42/void
+func (h *Handler) Void(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, "void", h.service.Void)
+}
+
+// remove runs a delete or void of the entity in the path
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request, operation string, fn func(ctx context.Context, entity, id string) (*Result, error)) {
+	vars := mux.Vars(r)
+	entity, ok := versions.EntityName(vars["entity"])
+	if !ok {
+		http.Error(w, "Unknown entity", http.StatusNotFound)
+		return
+	}
+
+	result, err := fn(r.Context(), entity, vars["id"])
+	if err != nil {
+		http.Error(w, "Failed to "+operation+" "+entity+": "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// List returns the company's trash, most recently trashed first
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	items, err := h.service.List(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list trash: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
+
+// Get returns a trash item with the copy it keeps
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	item, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get trash item: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(item)
+}
+
+// Restore re-creates a trashed entity
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	item, err := h.service.Restore(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to restore trash item: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(item)
+}
+
+// Purge deletes a trash item for good
+func (h *Handler) Purge(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Purge(r.Context(), mux.Vars(r)["id"]); err != nil {
+		http.Error(w, "Failed to purge trash item: "+err.Error(), status(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
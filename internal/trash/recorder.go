@@ -0,0 +1,79 @@
+// trash/recorder.go
+package trash
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// contextKey is the type for trash context keys
+type contextKey string
+
+// receiptKey holds the IDs of items trashed during a request
+const receiptKey contextKey = "trash_receipt"
+
+// stageOperations maps the transformer stages to trash operations
+var stageOperations = map[string]string{
+	qbclient.StageBeforeDelete: "delete",
+	qbclient.StageBeforeVoid:   "void",
+}
+
+// receipt collects the items trashed under a context
+type receipt struct {
+	ids []string
+}
+
+// withReceipt returns a context whose trashed items are collected
+func withReceipt(ctx context.Context) (context.Context, *receipt) {
+	r := &receipt{}
+	return context.WithValue(ctx, receiptKey, r), r
+}
+
+// Recorder is a qbclient.Transformer that keeps a copy of every entity
+// before it is deleted or voided. It runs after the guards, so blocked
+// operations leave nothing in the trash.
+type Recorder struct {
+	store *Store
+}
+
+// NewRecorder creates a new trash recorder
+func NewRecorder(store *Store) *Recorder {
+	return &Recorder{
+		store: store,
+	}
+}
+
+// Transform trashes a copy of the entity. If the copy cannot be kept the
+// operation fails, so nothing is lost without a way back.
+func (r *Recorder) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	operation, ok := stageOperations[stage]
+	if !ok || qbclient.IsDryRun(ctx) {
+		return data, nil
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := data["Id"].(string)
+	item := &Item{
+		ID:        jobs.NewID(),
+		RealmID:   realmID,
+		Entity:    entity,
+		EntityID:  id,
+		Operation: operation,
+		DeletedBy: auth.GetUserID(ctx),
+		Copy:      data,
+	}
+	if err := r.store.Add(ctx, item); err != nil {
+		return nil, fmt.Errorf("%s %s was not %sd because its copy could not be kept: %w", entity, id, operation, err)
+	}
+	if receipt, ok := ctx.Value(receiptKey).(*receipt); ok {
+		receipt.ids = append(receipt.ids, item.ID)
+	}
+	return data, nil
+}
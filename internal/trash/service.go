@@ -0,0 +1,160 @@
+// trash/service.go
+package trash
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/sparse"
+)
+
+// QuickBooks is the subset of the QuickBooks client used by the trash
+type QuickBooks interface {
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Delete(ctx context.Context, entity, id string) error
+	Void(ctx context.Context, entity, id string, result interface{}) error
+}
+
+// Result is the outcome of a delete or void, with the trash item that
+// can restore it
+type Result struct {
+	Entity    map[string]interface{} `json:"entity,omitempty"`
+	TrashItem string                 `json:"trash_item,omitempty"`
+}
+
+// Service deletes and voids entities through the trash and restores them
+type Service struct {
+	store *Store
+	qb    QuickBooks
+}
+
+// NewService creates a new trash service. qb must run the Recorder.
+func NewService(store *Store, qb QuickBooks) *Service {
+	return &Service{
+		store: store,
+		qb:    qb,
+	}
+}
+
+// Delete deletes an entity, keeping a copy in the trash
+func (s *Service) Delete(ctx context.Context, entity, id string) (*Result, error) {
+	ctx, receipt := withReceipt(ctx)
+	err := s.qb.Delete(ctx, entity, id)
+	return s.result(ctx, receipt, nil, err)
+}
+
+// Void voids a transaction, keeping a copy of it as it was in the trash
+func (s *Service) Void(ctx context.Context, entity, id string) (*Result, error) {
+	ctx, receipt := withReceipt(ctx)
+	var voided map[string]map[string]interface{}
+	err := s.qb.Void(ctx, entity, id, &voided)
+	return s.result(ctx, receipt, voided[entity], err)
+}
+
+// result reports a delete or void. Copies kept for an operation that then
+// failed are dropped, so the trash never offers to re-create an entity
+// that still exists.
+func (s *Service) result(ctx context.Context, receipt *receipt, entity map[string]interface{}, err error) (*Result, error) {
+	if err != nil {
+		realmID, _ := auth.GetCompanyID(ctx)
+		for _, id := range receipt.ids {
+			if purgeErr := s.store.Purge(ctx, realmID, id); purgeErr != nil {
+				logging.FromContext(ctx).Warn("failed to drop trash item", "trash_id", id, "error", purgeErr)
+			}
+		}
+		return nil, err
+	}
+	result := &Result{Entity: entity}
+	if len(receipt.ids) > 0 {
+		result.TrashItem = receipt.ids[len(receipt.ids)-1]
+	}
+	return result, nil
+}
+
+// List returns the company's trash, most recently trashed first
+func (s *Service) List(ctx context.Context) ([]*Item, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.List(ctx, realmID)
+}
+
+// Get returns a trash item with its copy
+func (s *Service) Get(ctx context.Context, id string) (*Item, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, realmID, id)
+}
+
+// Purge deletes a trash item for good
+func (s *Service) Purge(ctx context.Context, id string) error {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.store.Purge(ctx, realmID, id)
+}
+
+// Restore re-creates an entity from its trashed copy. QuickBooks cannot
+// undo deletes or voids, so the restored entity gets a new ID; voided
+// transactions are re-created as they were before the void.
+func (s *Service) Restore(ctx context.Context, id string) (*Item, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.store.Claim(ctx, realmID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	item, err := s.store.Get(ctx, realmID, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.RestoredID != "" {
+		return nil, ErrRestored
+	}
+
+	var created map[string]map[string]interface{}
+	if err := s.qb.Create(ctx, item.Entity, recreate(item.Copy), &created); err != nil {
+		return nil, fmt.Errorf("failed to restore %s %s: %w", item.Entity, item.EntityID, err)
+	}
+
+	now := time.Now().UTC()
+	item.RestoredID, _ = created[item.Entity]["Id"].(string)
+	item.RestoredAt = &now
+	if err := s.store.save(ctx, item); err != nil {
+		// The entity exists again; losing the marker only allows a duplicate restore
+		logging.FromContext(ctx).Warn("failed to mark trash item restored", "trash_id", item.ID, "error", err)
+	}
+	return item, nil
+}
+
+// recreate turns a trashed copy into a create payload, leaving out what
+// QuickBooks assigns or computes, including the Ids of lines
+func recreate(kept map[string]interface{}) map[string]interface{} {
+	payload := make(map[string]interface{}, len(kept))
+	for name, value := range kept {
+		if sparse.ReadOnlyFields[name] || sparse.ComputedFields[name] {
+			continue
+		}
+		if list, ok := sparse.Generic(value).([]interface{}); ok {
+			for _, entry := range list {
+				if line, ok := entry.(map[string]interface{}); ok {
+					delete(line, "Id")
+				}
+			}
+			value = list
+		}
+		payload[name] = value
+	}
+	return payload
+}
@@ -0,0 +1,177 @@
+// trash/store.go
+package trash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrNotFound is returned for items that were never trashed, have
+	// expired or were purged
+	ErrNotFound = errors.New("trash item not found")
+	// ErrRestored is returned when restoring an item a second time
+	ErrRestored = errors.New("trash item already restored")
+	// ErrRestoring is returned while another request restores the item
+	ErrRestoring = errors.New("trash item is being restored; try again")
+)
+
+// Item is a copy of an entity kept before it was deleted or voided
+type Item struct {
+	ID        string                 `json:"id"`
+	RealmID   string                 `json:"realm_id"`
+	Entity    string                 `json:"entity"`
+	EntityID  string                 `json:"entity_id"`
+	Operation string                 `json:"operation"` // delete or void
+	DeletedBy string                 `json:"deleted_by,omitempty"`
+	DeletedAt time.Time              `json:"deleted_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Copy      map[string]interface{} `json:"copy,omitempty"`
+	// RestoredID is the entity re-created from the copy, once restored
+	RestoredID string     `json:"restored_id,omitempty"`
+	RestoredAt *time.Time `json:"restored_at,omitempty"`
+}
+
+// Store keeps trashed entities for a retention period
+type Store struct {
+	client    redis.UniversalClient
+	prefix    string
+	retention time.Duration
+}
+
+// NewStore creates a new trash store. Items are kept for retention after
+// the entity was deleted or voided.
+func NewStore(client redis.UniversalClient, prefix string, retention time.Duration) *Store {
+	return &Store{
+		client:    client,
+		prefix:    prefix,
+		retention: retention,
+	}
+}
+
+// itemKey returns the Redis key of a trash item
+func (s *Store) itemKey(realmID, id string) string {
+	return fmt.Sprintf("%s:trash:%s:item:%s", s.prefix, realmID, id)
+}
+
+// indexKey returns the Redis sorted set of a realm's item IDs scored by
+// expiry
+func (s *Store) indexKey(realmID string) string {
+	return fmt.Sprintf("%s:trash:%s", s.prefix, realmID)
+}
+
+// Add keeps an item until its retention period ends
+func (s *Store) Add(ctx context.Context, item *Item) error {
+	item.DeletedAt = time.Now().UTC()
+	item.ExpiresAt = item.DeletedAt.Add(s.retention)
+	return s.save(ctx, item)
+}
+
+// save writes an item, keeping its expiry
+func (s *Store) save(ctx context.Context, item *Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trash item: %w", err)
+	}
+	ttl := time.Until(item.ExpiresAt)
+	if ttl <= 0 {
+		return ErrNotFound
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.itemKey(item.RealmID, item.ID), data, ttl)
+		pipe.ZAdd(ctx, s.indexKey(item.RealmID), &redis.Z{Score: float64(item.ExpiresAt.Unix()), Member: item.ID})
+		pipe.Expire(ctx, s.indexKey(item.RealmID), s.retention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save trash item: %w", err)
+	}
+	return nil
+}
+
+// Get returns a trash item
+func (s *Store) Get(ctx context.Context, realmID, id string) (*Item, error) {
+	data, err := s.client.Get(ctx, s.itemKey(realmID, id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash item: %w", err)
+	}
+
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trash item: %w", err)
+	}
+	return &item, nil
+}
+
+// List returns a realm's unexpired items, most recently trashed first,
+// without their copies
+func (s *Store) List(ctx context.Context, realmID string) ([]*Item, error) {
+	index := s.indexKey(realmID)
+	now := fmt.Sprintf("%d", time.Now().Unix())
+	if err := s.client.ZRemRangeByScore(ctx, index, "-inf", "("+now).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune trash: %w", err)
+	}
+	ids, err := s.client.ZRevRange(ctx, index, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	items := make([]*Item, 0, len(ids))
+	for _, id := range ids {
+		item, err := s.Get(ctx, realmID, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		item.Copy = nil
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Purge deletes an item before its retention period ends
+func (s *Store) Purge(ctx context.Context, realmID, id string) error {
+	var removed *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.Del(ctx, s.itemKey(realmID, id))
+		pipe.ZRem(ctx, s.indexKey(realmID), id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge trash item: %w", err)
+	}
+	if removed.Val() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// claimKey marks an item whose restore is in progress
+func (s *Store) claimKey(realmID, id string) string {
+	return s.itemKey(realmID, id) + ":restoring"
+}
+
+// Claim reserves an item for restoring so concurrent requests cannot
+// re-create it twice. The returned function releases the claim.
+func (s *Store) Claim(ctx context.Context, realmID, id string) (func(), error) {
+	key := s.claimKey(realmID, id)
+	claimed, err := s.client.SetNX(ctx, key, 1, time.Minute).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim trash item: %w", err)
+	}
+	if !claimed {
+		return nil, ErrRestoring
+	}
+	return func() { s.client.Del(context.Background(), key) }, nil
+}
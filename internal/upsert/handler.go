@@ -0,0 +1,75 @@
+// upsert/handler.go
+package upsert
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+)
+
+// Handler provides HTTP handlers for create-or-get upserts
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new upsert handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// UpsertCustomer creates a customer or returns the one with the same DisplayName
+func (h *Handler) UpsertCustomer(w http.ResponseWriter, r *http.Request) {
+	h.upsert(w, r, CustomerSpec)
+}
+
+// UpsertItem creates an item or returns the one with the same Sku, or Name
+// when no Sku is given
+func (h *Handler) UpsertItem(w http.ResponseWriter, r *http.Request) {
+	h.upsert(w, r, ItemSpec)
+}
+
+// upsert handles an upsert request. The body is the entity payload; with
+// ?update=true an existing match gets the payload's changed fields.
+// Created records are answered with 201, existing ones with 200.
+func (h *Handler) upsert(w http.ResponseWriter, r *http.Request, spec Spec) {
+	update := false
+	if v := r.URL.Query().Get("update"); v != "" {
+		var err error
+		if update, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid update parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var payload map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.Upsert(r.Context(), spec, payload, update)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrMissingKey):
+			status = http.StatusBadRequest
+		case errors.Is(err, writelock.ErrTimeout):
+			status = http.StatusConflict
+		}
+		http.Error(w, "Failed to upsert "+spec.Entity+": "+err.Error(), status)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
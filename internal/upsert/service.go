@@ -0,0 +1,151 @@
+// upsert/service.go
+package upsert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/sparse"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// ErrMissingKey is returned when a payload has none of the fields it is matched by
+var ErrMissingKey = errors.New("payload has no match field")
+
+// Spec describes how an entity is matched to existing records
+type Spec struct {
+	Entity string
+	Keys   []string // match fields in order of preference; the first one set is used
+}
+
+// Specs supported by the upsert endpoints
+var (
+	CustomerSpec = Spec{Entity: "Customer", Keys: []string{"DisplayName"}}
+	ItemSpec     = Spec{Entity: "Item", Keys: []string{"Sku", "Name"}}
+)
+
+// QuickBooks is the subset of the QuickBooks client used for upserts
+type QuickBooks interface {
+	Query(ctx context.Context, query string, result interface{}) error
+	Create(ctx context.Context, entity string, payload, result interface{}) error
+	Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error
+}
+
+// Locker serializes upserts of the same match key
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// Result is the outcome of an upsert
+type Result struct {
+	Entity        map[string]interface{} `json:"entity"`
+	MatchedBy     string                 `json:"matched_by"`
+	Created       bool                   `json:"created"`
+	Updated       bool                   `json:"updated"`
+	ChangedFields []string               `json:"changed_fields,omitempty"`
+}
+
+// Service creates or returns QuickBooks records matched by a natural key.
+// Upserts of the same key run one at a time, so concurrent or retried
+// requests never create duplicates.
+type Service struct {
+	qb     QuickBooks
+	locker Locker
+}
+
+// NewService creates a new upsert service
+func NewService(qb QuickBooks, locker Locker) *Service {
+	return &Service{
+		qb:     qb,
+		locker: locker,
+	}
+}
+
+// Upsert creates the entity unless one with the same match field exists.
+// An existing match is returned as is, or with update set, has the
+// payload's changed top-level fields applied as a sparse update.
+func (s *Service) Upsert(ctx context.Context, spec Spec, payload map[string]interface{}, update bool) (*Result, error) {
+	field, value := matchKey(spec, payload)
+	if field == "" {
+		return nil, fmt.Errorf("%w: set one of %s", ErrMissingKey, strings.Join(spec.Keys, ", "))
+	}
+
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := s.locker.Lock(ctx, fmt.Sprintf("%s:upsert:%s:%s", realmID, strings.ToLower(spec.Entity), strings.ToLower(value)))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	result := &Result{MatchedBy: field}
+	existing, err := s.find(ctx, spec.Entity, field, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		var created map[string]map[string]interface{}
+		if err := s.qb.Create(ctx, spec.Entity, payload, &created); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", spec.Entity, err)
+		}
+		result.Entity = created[spec.Entity]
+		result.Created = true
+		return result, nil
+	}
+
+	result.Entity = existing
+	if !update {
+		return result, nil
+	}
+
+	changed := sparse.Changed(existing, payload)
+	if len(changed) == 0 {
+		return result, nil
+	}
+	id, _ := existing["Id"].(string)
+	var updated map[string]map[string]interface{}
+	err = s.qb.Modify(ctx, spec.Entity, id, func(map[string]json.RawMessage) (map[string]interface{}, error) {
+		update := map[string]interface{}{"sparse": true}
+		for _, name := range changed {
+			update[name] = sparse.Merge(existing[name], payload[name])
+		}
+		return update, nil
+	}, &updated)
+	if err != nil {
+		return nil, err
+	}
+	result.Entity = updated[spec.Entity]
+	result.Updated = true
+	result.ChangedFields = changed
+	return result, nil
+}
+
+// find returns the record whose field equals value, including inactive
+// ones, which QuickBooks still counts for name uniqueness
+func (s *Service) find(ctx context.Context, entity, field, value string) (map[string]interface{}, error) {
+	found, err := query.List[map[string]interface{}](ctx, s.qb, query.Select(entity).Where(field, "=", value).WhereIn("Active", true, false))
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[0], nil
+}
+
+// matchKey returns the first match field set in the payload and its value
+func matchKey(spec Spec, payload map[string]interface{}) (string, string) {
+	for _, field := range spec.Keys {
+		if value, ok := payload[field].(string); ok && strings.TrimSpace(value) != "" {
+			return field, strings.TrimSpace(value)
+		}
+	}
+	return "", ""
+}
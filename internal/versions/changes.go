@@ -0,0 +1,111 @@
+// versions/changes.go
+package versions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/sparse"
+)
+
+// ErrUnknownEntity is returned for collections without a change feed
+var ErrUnknownEntity = errors.New("unknown entity")
+
+// collections maps API collection names to QuickBooks entity names
+var collections = map[string]string{
+	"invoices":       "Invoice",
+	"customers":      "Customer",
+	"items":          "Item",
+	"payments":       "Payment",
+	"bills":          "Bill",
+	"vendors":        "Vendor",
+	"estimates":      "Estimate",
+	"salesreceipts":  "SalesReceipt",
+	"creditmemos":    "CreditMemo",
+	"refundreceipts": "RefundReceipt",
+	"purchases":      "Purchase",
+	"journalentries": "JournalEntry",
+}
+
+// EntityName returns the QuickBooks entity of an API collection
+func EntityName(collection string) (string, bool) {
+	entity, ok := collections[strings.ToLower(collection)]
+	return entity, ok
+}
+
+// ChangeSet is what changed between two versions of an entity
+type ChangeSet struct {
+	Version   int64  `json:"version"`
+	SyncToken string `json:"sync_token"`
+	Source    string `json:"source"`
+	// ChangedBy is the server user who made the change or, for changes
+	// made in QuickBooks, the QuickBooks user when it reports one
+	ChangedBy string    `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+	// Initial marks the oldest kept version, which the feed starts from
+	Initial bool            `json:"initial,omitempty"`
+	Changes []sparse.Change `json:"changes"`
+}
+
+// Changes returns the field-level changes between an entity's kept
+// versions, oldest first. Changes made between two snapshots are reported
+// together under the later one.
+func (s *Service) Changes(ctx context.Context, entity, id string) ([]ChangeSet, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.store.List(ctx, realmID, entity, id)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := make([]ChangeSet, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		set := ChangeSet{
+			Version:   version.Version,
+			SyncToken: version.SyncToken,
+			Source:    version.Source,
+			ChangedBy: changedBy(version),
+			ChangedAt: changedAt(version),
+			Changes:   []sparse.Change{},
+		}
+		if i == len(versions)-1 {
+			set.Initial = true
+		} else if changes := sparse.Diff(versions[i+1].Entity, version.Entity); changes != nil {
+			set.Changes = changes
+		}
+		feed = append(feed, set)
+	}
+	return feed, nil
+}
+
+// changedBy returns who made a version
+func changedBy(version *Version) string {
+	if version.UserID != "" {
+		return version.UserID
+	}
+	meta, _ := version.Entity["MetaData"].(map[string]interface{})
+	ref, _ := meta["LastModifiedByRef"].(map[string]interface{})
+	if name, _ := ref["name"].(string); name != "" {
+		return name
+	}
+	value, _ := ref["value"].(string)
+	return value
+}
+
+// changedAt returns when a version was last updated in QuickBooks, or when
+// it was snapshotted if QuickBooks did not say
+func changedAt(version *Version) time.Time {
+	meta, _ := version.Entity["MetaData"].(map[string]interface{})
+	if value, _ := meta["LastUpdatedTime"].(string); value != "" {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return version.CapturedAt
+}
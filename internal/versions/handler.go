@@ -0,0 +1,123 @@
+// versions/handler.go
+package versions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/eGGnogSC/qbserver/internal/writelock"
+	"github.com/gorilla/mux"
+)
+
+// Handler provides HTTP handlers for entity versions
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new version handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// status returns the HTTP status for a service error
+func status(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrUnknownEntity):
+		return http.StatusNotFound
+	case errors.Is(err, writelock.ErrTimeout):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// ListInvoiceVersions returns an invoice's versions, newest first
+func (h *Handler) ListInvoiceVersions(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, "Invoice")
+}
+
+// GetInvoiceVersion returns one version of an invoice
+func (h *Handler) GetInvoiceVersion(w http.ResponseWriter, r *http.Request) {
+	h.get(w, r, "Invoice")
+}
+
+// RestoreInvoiceVersion re-applies a version of an invoice
+func (h *Handler) RestoreInvoiceVersion(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, "Invoice")
+}
+
+// Changes returns the field-level changes of any entity with history,
+// oldest first, e.g. GET /api/invoices/42/changes
+func (h *Handler) Changes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	entity, ok := EntityName(vars["entity"])
+	if !ok {
+		http.Error(w, "Failed to list changes: "+ErrUnknownEntity.Error(), status(ErrUnknownEntity))
+		return
+	}
+
+	feed, err := h.service.Changes(r.Context(), entity, vars["id"])
+	if err != nil {
+		http.Error(w, "Failed to list changes: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(feed)
+}
+
+// list answers with an entity's versions
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, entity string) {
+	versions, err := h.service.List(r.Context(), entity, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to list versions: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(versions)
+}
+
+// get answers with one version of an entity
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, entity string) {
+	number, err := strconv.ParseInt(mux.Vars(r)["version"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	version, err := h.service.Get(r.Context(), entity, mux.Vars(r)["id"], number)
+	if err != nil {
+		http.Error(w, "Failed to get version: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(version)
+}
+
+// restore re-applies a version of an entity. Use ?preview=true to review
+// the changes first.
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request, entity string) {
+	number, err := strconv.ParseInt(mux.Vars(r)["version"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.Restore(r.Context(), entity, mux.Vars(r)["id"], number)
+	if err != nil {
+		http.Error(w, "Failed to restore version: "+err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,117 @@
+// versions/recorder.go
+package versions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient"
+)
+
+// contextKey is the type for version context keys
+type contextKey string
+
+// sourceKey labels the snapshots taken while handling a webhook change
+const sourceKey contextKey = "versions_source"
+
+// stageSources maps the transformer stages to snapshot sources
+var stageSources = map[string]string{
+	qbclient.StageAfterCreate: SourceCreate,
+	qbclient.StageAfterUpdate: SourceUpdate,
+	qbclient.StageAfterRead:   SourceRead,
+}
+
+// Reader reads one entity from QuickBooks
+type Reader interface {
+	Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error)
+}
+
+// Recorder is a qbclient.Transformer that snapshots every entity the
+// server creates or updates. Reads and webhook changes of those entities
+// are snapshotted too, so edits made in QuickBooks itself show up in their
+// history; entities the server never wrote are left alone.
+type Recorder struct {
+	store *Store
+}
+
+// NewRecorder creates a new version recorder
+func NewRecorder(store *Store) *Recorder {
+	return &Recorder{
+		store: store,
+	}
+}
+
+// Transform snapshots the entity and passes it through. Snapshots only
+// serve history, so failing to take one never fails the request.
+func (r *Recorder) Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+	source, ok := stageSources[stage]
+	realmID, err := auth.GetCompanyID(ctx)
+	if !ok || err != nil {
+		return data, nil
+	}
+	if override, ok := ctx.Value(sourceKey).(string); ok && source == SourceRead {
+		source = override
+	}
+
+	userID := ""
+	if source == SourceRead || source == SourceWebhook {
+		id, _ := data["Id"].(string)
+		tracked, err := r.store.Tracked(ctx, realmID, entity, id)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to check version tracking", "entity", entity, "id", id, "error", err)
+			return data, nil
+		}
+		if !tracked {
+			return data, nil
+		}
+	} else {
+		userID = auth.GetUserID(ctx)
+	}
+	if err := r.store.Record(ctx, realmID, entity, source, userID, data); err != nil {
+		logging.FromContext(ctx).Warn("failed to snapshot entity", "entity", entity, "error", err)
+	}
+	return data, nil
+}
+
+// ChangeHandler snapshots entities changed in QuickBooks as webhooks report
+// them
+type ChangeHandler struct {
+	store *Store
+	qb    Reader
+}
+
+// NewChangeHandler creates a new webhook change handler. qb must run the
+// Recorder, which takes the snapshot when the entity is read.
+func NewChangeHandler(store *Store, qb Reader) *ChangeHandler {
+	return &ChangeHandler{
+		store: store,
+		qb:    qb,
+	}
+}
+
+// HandleChange implements qbwebhook.Handler by reading a changed entity the
+// server has history for. Changes can only be read with a QuickBooks
+// connection, as replays and backfills have.
+func (h *ChangeHandler) HandleChange(ctx context.Context, change qbwebhook.Change) error {
+	if strings.EqualFold(change.Operation, "Delete") {
+		return nil
+	}
+	if realmID, err := auth.GetCompanyID(ctx); err != nil || realmID != change.RealmID {
+		return nil
+	}
+	tracked, err := h.store.Tracked(ctx, change.RealmID, change.Entity, change.ID)
+	if err != nil || !tracked {
+		return err
+	}
+
+	ctx = context.WithValue(ctx, sourceKey, SourceWebhook)
+	if _, err := h.qb.Read(ctx, change.Entity, change.ID); err != nil {
+		return fmt.Errorf("failed to read %s %s: %w", change.Entity, change.ID, err)
+	}
+	return nil
+}
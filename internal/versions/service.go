@@ -0,0 +1,131 @@
+// versions/service.go
+package versions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/sparse"
+)
+
+// errUnchanged stops a restore whose version matches the current entity
+var errUnchanged = errors.New("entity already matches the version")
+
+// QuickBooks is the subset of the QuickBooks client used for restores
+type QuickBooks interface {
+	Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error
+}
+
+// Restore is the outcome of restoring a version
+type Restore struct {
+	Entity        map[string]interface{} `json:"entity,omitempty"`
+	Restored      int64                  `json:"restored"`
+	ChangedFields []string               `json:"changed_fields"`
+}
+
+// Service lists and restores entity versions
+type Service struct {
+	store *Store
+	qb    QuickBooks
+}
+
+// NewService creates a new version service
+func NewService(store *Store, qb QuickBooks) *Service {
+	return &Service{
+		store: store,
+		qb:    qb,
+	}
+}
+
+// List returns an entity's versions, newest first, without their contents
+func (s *Service) List(ctx context.Context, entity, id string) ([]*Version, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.store.List(ctx, realmID, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		version.Entity = nil
+	}
+	return versions, nil
+}
+
+// Get returns one of an entity's versions
+func (s *Service) Get(ctx context.Context, entity, id string, number int64) (*Version, error) {
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, realmID, entity, id, number)
+}
+
+// Restore re-applies a version as a sparse update of the fields that
+// differ from the current entity. Fields QuickBooks computes are left for
+// it to work out again, and fields added since the version are kept.
+func (s *Service) Restore(ctx context.Context, entity, id string, number int64) (*Restore, error) {
+	version, err := s.Get(ctx, entity, id, number)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Restore{Restored: version.Version}
+	var updated map[string]map[string]interface{}
+	err = s.qb.Modify(ctx, entity, id, func(raw map[string]json.RawMessage) (map[string]interface{}, error) {
+		current, _ := sparse.Generic(raw).(map[string]interface{})
+		update := map[string]interface{}{"sparse": true}
+		for _, name := range sparse.Changed(current, version.Entity) {
+			if sparse.ComputedFields[name] {
+				continue
+			}
+			update[name] = restorable(version.Entity[name], current[name])
+			result.ChangedFields = append(result.ChangedFields, name)
+		}
+		if len(result.ChangedFields) == 0 {
+			return nil, errUnchanged
+		}
+		return update, nil
+	}, &updated)
+	if errors.Is(err, errUnchanged) {
+		result.ChangedFields = []string{}
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore %s %s: %w", entity, id, err)
+	}
+	result.Entity = updated[entity]
+	return result, nil
+}
+
+// restorable prepares a version's value for the current entity. List
+// entries such as lines that have since been deleted lose their Id, so
+// QuickBooks adds them back instead of rejecting the update.
+func restorable(value, current interface{}) interface{} {
+	list, ok := sparse.Generic(value).([]interface{})
+	if !ok {
+		return value
+	}
+	existing := make(map[string]bool)
+	if currentList, ok := current.([]interface{}); ok {
+		for _, item := range currentList {
+			if entry, ok := item.(map[string]interface{}); ok {
+				if id, ok := entry["Id"].(string); ok {
+					existing[id] = true
+				}
+			}
+		}
+	}
+	for _, item := range list {
+		if entry, ok := item.(map[string]interface{}); ok {
+			if id, ok := entry["Id"].(string); ok && !existing[id] {
+				delete(entry, "Id")
+			}
+		}
+	}
+	return list
+}
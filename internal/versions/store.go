@@ -0,0 +1,175 @@
+// versions/store.go
+package versions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// maxVersions is how many snapshots are kept per entity
+	maxVersions = 50
+	// retention is how long an entity's snapshots are kept after the last one
+	retention = 90 * 24 * time.Hour
+)
+
+// Snapshot sources
+const (
+	SourceCreate  = "create"  // entity returned by a create
+	SourceUpdate  = "update"  // entity returned by an update
+	SourceRead    = "read"    // entity returned by a read
+	SourceWebhook = "webhook" // entity read after a webhook change
+)
+
+// ErrNotFound is returned for versions that were never kept or have been
+// pruned
+var ErrNotFound = errors.New("version not found")
+
+// Version is a snapshot of an entity as QuickBooks stored it
+type Version struct {
+	Version    int64                  `json:"version"`
+	SyncToken  string                 `json:"sync_token"`
+	Source     string                 `json:"source"`
+	UserID     string                 `json:"user_id,omitempty"` // user whose write through the server it was
+	CapturedAt time.Time              `json:"captured_at"`
+	Entity     map[string]interface{} `json:"entity,omitempty"`
+}
+
+// Store keeps the latest snapshots of each entity, one per SyncToken
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewStore creates a new version store
+func NewStore(client redis.UniversalClient, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// versionsKey returns the Redis list of an entity's snapshots, oldest first
+func (s *Store) versionsKey(realmID, entity, id string) string {
+	return fmt.Sprintf("%s:versions:%s:%s:%s", s.prefix, realmID, strings.ToLower(entity), id)
+}
+
+// seqKey returns the Redis counter numbering an entity's snapshots
+func (s *Store) seqKey(realmID, entity, id string) string {
+	return s.versionsKey(realmID, entity, id) + ":seq"
+}
+
+// tokenKey returns the Redis key of the SyncToken last snapshotted
+func (s *Store) tokenKey(realmID, entity, id string) string {
+	return s.versionsKey(realmID, entity, id) + ":token"
+}
+
+// Tracked reports whether an entity has snapshots
+func (s *Store) Tracked(ctx context.Context, realmID, entity, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.seqKey(realmID, entity, id)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check versions: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Record adds a snapshot of an entity unless its SyncToken was already
+// snapshotted, dropping the oldest beyond the limit
+func (s *Store) Record(ctx context.Context, realmID, entity, source, userID string, data map[string]interface{}) error {
+	id, _ := data["Id"].(string)
+	syncToken, _ := data["SyncToken"].(string)
+	if id == "" || syncToken == "" {
+		return nil
+	}
+
+	last, err := s.client.Get(ctx, s.tokenKey(realmID, entity, id)).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get last version: %w", err)
+	}
+	if last == syncToken {
+		return nil
+	}
+
+	seq, err := s.client.Incr(ctx, s.seqKey(realmID, entity, id)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to number version: %w", err)
+	}
+	version := Version{
+		Version:    seq,
+		SyncToken:  syncToken,
+		Source:     source,
+		UserID:     userID,
+		CapturedAt: time.Now().UTC(),
+		Entity:     data,
+	}
+	encoded, err := json.Marshal(version)
+	if err != nil {
+		return fmt.Errorf("failed to marshal version: %w", err)
+	}
+
+	key := s.versionsKey(realmID, entity, id)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, encoded)
+		pipe.LTrim(ctx, key, -maxVersions, -1)
+		pipe.Set(ctx, s.tokenKey(realmID, entity, id), syncToken, retention)
+		pipe.Expire(ctx, key, retention)
+		pipe.Expire(ctx, s.seqKey(realmID, entity, id), retention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save version: %w", err)
+	}
+	return nil
+}
+
+// List returns an entity's kept snapshots, newest first
+func (s *Store) List(ctx context.Context, realmID, entity, id string) ([]*Version, error) {
+	items, err := s.client.LRange(ctx, s.versionsKey(realmID, entity, id), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	versions := make([]*Version, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		var version Version
+		if err := json.Unmarshal([]byte(items[i]), &version); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal version: %w", err)
+		}
+		versions = append(versions, &version)
+	}
+	return versions, nil
+}
+
+// Get returns one of an entity's kept snapshots
+func (s *Store) Get(ctx context.Context, realmID, entity, id string, number int64) (*Version, error) {
+	versions, err := s.List(ctx, realmID, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		if version.Version == number {
+			return version, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ByToken returns the kept snapshot of an entity at a SyncToken
+func (s *Store) ByToken(ctx context.Context, realmID, entity, id, syncToken string) (*Version, error) {
+	versions, err := s.List(ctx, realmID, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		if version.SyncToken == syncToken {
+			return version, nil
+		}
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,314 @@
+// warehouse/bigquery.go
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SinkBigQuery is the registered name of the BigQuery sink
+const SinkBigQuery = "bigquery"
+
+// bigQueryBaseURL is the BigQuery REST API root
+const bigQueryBaseURL = "https://bigquery.googleapis.com/bigquery/v2"
+
+// bigQueryScope is the OAuth scope requested for the service account
+const bigQueryScope = "https://www.googleapis.com/auth/bigquery"
+
+// bigQueryIDPattern restricts project and dataset identifiers
+var bigQueryIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// errTableNotFound is returned by do when a GET finds no table
+var errTableNotFound = errors.New("table not found")
+
+func init() {
+	RegisterSink(SinkBigQuery, func(config SinkConfig) (Sink, error) {
+		if config.BigQuery == nil {
+			return nil, fmt.Errorf("bigquery settings are required")
+		}
+		return NewBigQuery(*config.BigQuery)
+	})
+}
+
+// BigQueryConfig identifies the destination dataset and service account
+type BigQueryConfig struct {
+	ProjectID       string `json:"project_id"`
+	Dataset         string `json:"dataset"`
+	CredentialsJSON string `json:"credentials_json,omitempty"` // service account key, never returned to clients
+}
+
+// serviceAccountKey is the subset of a Google service account key file used
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// BigQuery streams rows into BigQuery tables with the insertAll API
+type BigQuery struct {
+	config     BigQueryConfig
+	email      string
+	tokenURI   string
+	key        *rsa.PrivateKey
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewBigQuery creates a BigQuery sink from a service account key
+func NewBigQuery(config BigQueryConfig) (*BigQuery, error) {
+	if !bigQueryIDPattern.MatchString(config.ProjectID) || !bigQueryIDPattern.MatchString(config.Dataset) {
+		return nil, fmt.Errorf("bigquery project_id and dataset are required")
+	}
+
+	var account serviceAccountKey
+	if err := json.Unmarshal([]byte(config.CredentialsJSON), &account); err != nil {
+		return nil, fmt.Errorf("invalid bigquery credentials: %w", err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("bigquery credentials must be a service account key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	key, err := parsePrivateKey(account.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BigQuery{
+		config:     config,
+		email:      account.ClientEmail,
+		tokenURI:   account.TokenURI,
+		key:        key,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// token returns a cached OAuth access token, exchanging a signed JWT for a
+// new one when it is about to expire
+func (b *BigQuery) token(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.accessToken != "" && time.Until(b.expiresAt) > time.Minute {
+		return b.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(b.key, map[string]interface{}{
+		"iss":   b.email,
+		"scope": bigQueryScope,
+		"aud":   b.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, "POST", b.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("google token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	b.accessToken = token.AccessToken
+	b.expiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return b.accessToken, nil
+}
+
+// do sends an authenticated request and decodes the JSON response into out
+func (b *BigQuery) do(ctx context.Context, method, path string, payload, out interface{}) error {
+	token, err := b.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if payload != nil {
+		if data, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/%s/datasets/%s%s", bigQueryBaseURL, b.config.ProjectID, b.config.Dataset, path)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("bigquery request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read bigquery response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound && method == "GET" {
+		return errTableNotFound
+	}
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("bigquery API error: %s", apiErr.Error.Message)
+		}
+		return fmt.Errorf("bigquery API returned status %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse bigquery response: %w", err)
+		}
+	}
+	return nil
+}
+
+// bigQueryField is a column in a BigQuery table schema
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// bigQueryTableRef identifies a table
+type bigQueryTableRef struct {
+	ProjectID string `json:"projectId"`
+	DatasetID string `json:"datasetId"`
+	TableID   string `json:"tableId"`
+}
+
+// bigQueryTable is the subset of the table resource that is read or written
+type bigQueryTable struct {
+	TableReference *bigQueryTableRef `json:"tableReference,omitempty"`
+	Schema         struct {
+		Fields []bigQueryField `json:"fields"`
+	} `json:"schema"`
+}
+
+// EnsureSchema creates the table or patches in any missing columns.
+// BigQuery only allows appending NULLABLE columns, which is all that's needed.
+func (b *BigQuery) EnsureSchema(ctx context.Context, table string, columns []Column) error {
+	var existing bigQueryTable
+	err := b.do(ctx, "GET", "/tables/"+table, nil, &existing)
+	if errors.Is(err, errTableNotFound) {
+		var create bigQueryTable
+		create.TableReference = &bigQueryTableRef{
+			ProjectID: b.config.ProjectID,
+			DatasetID: b.config.Dataset,
+			TableID:   table,
+		}
+		for _, c := range columns {
+			create.Schema.Fields = append(create.Schema.Fields, bigQueryField{Name: c.Name, Type: c.Type, Mode: "NULLABLE"})
+		}
+		if err := b.do(ctx, "POST", "/tables", create, nil); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", table, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get table %s: %w", table, err)
+	}
+
+	present := make(map[string]bool, len(existing.Schema.Fields))
+	for _, f := range existing.Schema.Fields {
+		present[strings.ToLower(f.Name)] = true
+	}
+	fields := existing.Schema.Fields
+	for _, c := range columns {
+		if !present[strings.ToLower(c.Name)] {
+			fields = append(fields, bigQueryField{Name: c.Name, Type: c.Type, Mode: "NULLABLE"})
+		}
+	}
+	if len(fields) == len(existing.Schema.Fields) {
+		return nil
+	}
+
+	var patch bigQueryTable
+	patch.Schema.Fields = fields
+	if err := b.do(ctx, "PATCH", "/tables/"+table, patch, nil); err != nil {
+		return fmt.Errorf("failed to add columns to %s: %w", table, err)
+	}
+	return nil
+}
+
+// Write streams rows with insertAll. Each row's _row_id is used as the
+// insert ID so BigQuery drops retried duplicates on a best-effort basis.
+func (b *BigQuery) Write(ctx context.Context, table string, columns []Column, rows []Row) error {
+	type insertRow struct {
+		InsertID string `json:"insertId,omitempty"`
+		JSON     Row    `json:"json"`
+	}
+	request := struct {
+		Rows []insertRow `json:"rows"`
+	}{}
+	for _, row := range rows {
+		id, _ := row[ColumnRowID].(string)
+		request.Rows = append(request.Rows, insertRow{InsertID: id, JSON: row})
+	}
+
+	var response struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	if err := b.do(ctx, "POST", "/tables/"+table+"/insertAll", request, &response); err != nil {
+		return fmt.Errorf("failed to insert rows into %s: %w", table, err)
+	}
+	if len(response.InsertErrors) > 0 {
+		first := response.InsertErrors[0]
+		message := "unknown error"
+		if len(first.Errors) > 0 {
+			message = first.Errors[0].Message
+		}
+		return fmt.Errorf("bigquery rejected %d rows in %s (row %d: %s)", len(response.InsertErrors), table, first.Index, message)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// warehouse/handler.go
+package warehouse
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+)
+
+// Handler provides HTTP handlers for data warehouse exports
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates a new warehouse handler
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// GetConfig returns the tenant's warehouse configuration without credentials
+func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	config, err := h.service.GetConfig(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get warehouse config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config": config.Redacted(),
+		"sinks":  SinkTypes(),
+	})
+}
+
+// SaveConfig configures the tenant's warehouse sink and binds it to the
+// current company; omitted credentials keep the stored ones
+func (h *Handler) SaveConfig(w http.ResponseWriter, r *http.Request) {
+	realmID, err := auth.GetCompanyID(r.Context())
+	if err != nil {
+		http.Error(w, "QuickBooks company not connected", http.StatusUnauthorized)
+		return
+	}
+
+	var config Config
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	config.RealmID = realmID
+	config.UserID = auth.GetUserID(r.Context())
+
+	if err := h.service.SaveConfig(r.Context(), auth.GetTenantID(r.Context()), &config); err != nil {
+		http.Error(w, "Failed to save warehouse config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config.Redacted())
+}
+
+// RunExport exports everything changed since the last run
+func (h *Handler) RunExport(w http.ResponseWriter, r *http.Request) {
+	tenantID := auth.GetTenantID(r.Context())
+	config, err := h.service.GetConfig(r.Context(), tenantID)
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to get warehouse config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if realmID, err := auth.GetCompanyID(r.Context()); err != nil || realmID != config.RealmID {
+		http.Error(w, "Warehouse export is configured for a different QuickBooks company", http.StatusConflict)
+		return
+	}
+
+	results, err := h.service.Export(r.Context(), tenantID, config)
+	if err != nil {
+		http.Error(w, "Failed to export to warehouse: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entities": results,
+	})
+}
+
+// GetStatus returns the tenant's export watermarks and table schemas
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.service.Status(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to get warehouse status: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,298 @@
+// warehouse/parquet.go
+package warehouse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+)
+
+// Parquet physical types, repetition and encodings used by the writer
+const (
+	parquetBoolean   = 0
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUTF8         = 0 // converted type for strings
+	parquetDataPage     = 0
+	parquetUncompressed = 0
+)
+
+// parquetMagic opens and closes every Parquet file
+var parquetMagic = []byte("PAR1")
+
+// parquetTypes maps column types to Parquet physical types
+var parquetTypes = map[string]int32{
+	TypeString: parquetByteArray,
+	TypeFloat:  parquetDouble,
+	TypeBool:   parquetBoolean,
+}
+
+// encodeParquet writes rows as a Parquet file with a single row group and
+// one uncompressed, PLAIN-encoded data page per column. Every column is
+// OPTIONAL so missing values are stored as nulls. The file carries its own
+// schema, so files written before and after a column is added can be read
+// together by engines that merge schemas (Athena, Spark, DuckDB).
+func encodeParquet(columns []Column, rows []Row) []byte {
+	var file bytes.Buffer
+	file.Write(parquetMagic)
+
+	type chunk struct {
+		offset int64
+		size   int64
+	}
+	chunks := make([]chunk, len(columns))
+	for i, c := range columns {
+		page := parquetPage(c, rows)
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(len(page))}
+		file.Write(page)
+	}
+
+	// FileMetaData
+	var total int64
+	for _, c := range chunks {
+		total += c.size
+	}
+	t := &thriftWriter{}
+	t.structBegin()
+	t.fieldI32(1, 1) // version
+	t.fieldListBegin(2, thriftStruct, len(columns)+1)
+	// Root schema element
+	t.structBegin()
+	t.fieldBinary(4, []byte("schema"))
+	t.fieldI32(5, int32(len(columns)))
+	t.structEnd()
+	for _, c := range columns {
+		t.structBegin()
+		t.fieldI32(1, parquetTypes[c.Type])
+		t.fieldI32(3, parquetOptional)
+		t.fieldBinary(4, []byte(c.Name))
+		if c.Type == TypeString {
+			t.fieldI32(6, parquetUTF8)
+		}
+		t.structEnd()
+	}
+	t.fieldI64(3, int64(len(rows))) // num_rows
+	t.fieldListBegin(4, thriftStruct, 1)
+	// RowGroup
+	t.structBegin()
+	t.fieldListBegin(1, thriftStruct, len(columns))
+	for i, c := range columns {
+		// ColumnChunk
+		t.structBegin()
+		t.fieldI64(2, chunks[i].offset)
+		t.fieldStructBegin(3)
+		// ColumnMetaData
+		t.fieldI32(1, parquetTypes[c.Type])
+		t.fieldListBegin(2, thriftI32, 2)
+		t.writeI32(parquetPlain)
+		t.writeI32(parquetRLE)
+		t.fieldListBegin(3, thriftBinary, 1)
+		t.writeBinary([]byte(c.Name))
+		t.fieldI32(4, parquetUncompressed)
+		t.fieldI64(5, int64(len(rows)))
+		t.fieldI64(6, chunks[i].size)
+		t.fieldI64(7, chunks[i].size)
+		t.fieldI64(9, chunks[i].offset)
+		t.structEnd()
+		t.structEnd()
+	}
+	t.fieldI64(2, total)            // total_byte_size
+	t.fieldI64(3, int64(len(rows))) // num_rows
+	t.structEnd()
+	t.fieldBinary(6, []byte("qbserver"))
+	t.structEnd()
+
+	metadata := t.buf.Bytes()
+	file.Write(metadata)
+	binary.Write(&file, binary.LittleEndian, uint32(len(metadata)))
+	file.Write(parquetMagic)
+	return file.Bytes()
+}
+
+// parquetPage encodes one column as a page header followed by its data
+func parquetPage(c Column, rows []Row) []byte {
+	levels := make([]bool, len(rows))
+	var values bytes.Buffer
+	var bits []bool
+	for i, row := range rows {
+		v, ok := row[c.Name]
+		if !ok || v == nil || typeOf(v) != c.Type {
+			continue
+		}
+		levels[i] = true
+		switch c.Type {
+		case TypeString:
+			s := v.(string)
+			binary.Write(&values, binary.LittleEndian, uint32(len(s)))
+			values.WriteString(s)
+		case TypeFloat:
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(toFloat(v)))
+		case TypeBool:
+			bits = append(bits, v.(bool))
+		}
+	}
+	if c.Type == TypeBool {
+		packed := make([]byte, (len(bits)+7)/8)
+		for i, b := range bits {
+			if b {
+				packed[i/8] |= 1 << uint(i%8)
+			}
+		}
+		values.Write(packed)
+	}
+
+	// Definition levels: RLE runs of 0 (null) or 1 (present), prefixed
+	// with their byte length. Max repetition level is 0 so none are written.
+	var runs bytes.Buffer
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		writeUvarint(&runs, uint64(j-i)<<1)
+		if levels[i] {
+			runs.WriteByte(1)
+		} else {
+			runs.WriteByte(0)
+		}
+		i = j
+	}
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, uint32(runs.Len()))
+	data.Write(runs.Bytes())
+	data.Write(values.Bytes())
+
+	// PageHeader
+	t := &thriftWriter{}
+	t.structBegin()
+	t.fieldI32(1, parquetDataPage)
+	t.fieldI32(2, int32(data.Len()))
+	t.fieldI32(3, int32(data.Len()))
+	t.fieldStructBegin(5)
+	// DataPageHeader
+	t.fieldI32(1, int32(len(rows)))
+	t.fieldI32(2, parquetPlain)
+	t.fieldI32(3, parquetRLE)
+	t.fieldI32(4, parquetRLE)
+	t.structEnd()
+	t.structEnd()
+
+	return append(t.buf.Bytes(), data.Bytes()...)
+}
+
+// toFloat converts the numeric values typeOf reports as FLOAT
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return 0
+}
+
+// writeUvarint appends an unsigned LEB128 varint
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.Write(tmp[:n])
+}
+
+// Thrift compact protocol type codes
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol, which Parquet uses for
+// page headers and file metadata. Only the types Parquet needs are supported.
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField []int16
+}
+
+// structBegin starts a struct, either top-level or as a list element
+func (t *thriftWriter) structBegin() {
+	t.lastField = append(t.lastField, 0)
+}
+
+// structEnd writes the stop byte and restores the enclosing field ID
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+// fieldHeader writes a field header using the short delta form when possible
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &t.lastField[len(t.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		writeUvarint(&t.buf, uint64(uint16((id<<1)^(id>>15))))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) writeI32(v int32) {
+	writeUvarint(&t.buf, uint64(uint32((v<<1)^(v>>31))))
+}
+
+func (t *thriftWriter) writeI64(v int64) {
+	writeUvarint(&t.buf, uint64((v<<1)^(v>>63)))
+}
+
+func (t *thriftWriter) writeBinary(b []byte) {
+	writeUvarint(&t.buf, uint64(len(b)))
+	t.buf.Write(b)
+}
+
+func (t *thriftWriter) fieldI32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.writeI32(v)
+}
+
+func (t *thriftWriter) fieldI64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.writeI64(v)
+}
+
+func (t *thriftWriter) fieldBinary(id int16, b []byte) {
+	t.fieldHeader(id, thriftBinary)
+	t.writeBinary(b)
+}
+
+// fieldStructBegin starts a nested struct field; close it with structEnd
+func (t *thriftWriter) fieldStructBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+// fieldListBegin writes a list field header; the caller writes the elements
+func (t *thriftWriter) fieldListBegin(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		writeUvarint(&t.buf, uint64(size))
+	}
+}
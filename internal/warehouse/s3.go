@@ -0,0 +1,198 @@
+// warehouse/s3.go
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SinkS3 is the registered name of the S3 Parquet sink
+const SinkS3 = "s3"
+
+// s3BucketPattern matches valid S3 bucket names
+var s3BucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// s3RegionPattern matches AWS region names
+var s3RegionPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+func init() {
+	RegisterSink(SinkS3, func(config SinkConfig) (Sink, error) {
+		if config.S3 == nil {
+			return nil, fmt.Errorf("s3 settings are required")
+		}
+		return NewS3(*config.S3)
+	})
+}
+
+// S3Config identifies the destination bucket and access key
+type S3Config struct {
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	Prefix          string `json:"prefix,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"` // S3-compatible endpoint; path-style addressing is used when set
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"` // never returned to clients
+}
+
+// S3 writes each batch as a Parquet file under a Hive-style partitioned key:
+// <prefix>/<table>/dt=YYYY-MM-DD/<timestamp>.parquet
+type S3 struct {
+	config     S3Config
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3 creates an S3 sink
+func NewS3(config S3Config) (*S3, error) {
+	if !s3BucketPattern.MatchString(config.Bucket) {
+		return nil, fmt.Errorf("invalid s3 bucket: %q", config.Bucket)
+	}
+	if !s3RegionPattern.MatchString(config.Region) {
+		return nil, fmt.Errorf("invalid s3 region: %q", config.Region)
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3 access key is required")
+	}
+	if config.Endpoint != "" {
+		u, err := url.Parse(config.Endpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("invalid s3 endpoint: %q", config.Endpoint)
+		}
+	}
+	config.Prefix = strings.Trim(config.Prefix, "/")
+
+	return &S3{
+		config:     config,
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+		now:        time.Now,
+	}, nil
+}
+
+// EnsureSchema is a no-op: every Parquet file embeds its own schema, and
+// readers merge the schemas of older and newer files
+func (s *S3) EnsureSchema(ctx context.Context, table string, columns []Column) error {
+	return nil
+}
+
+// Write uploads rows as a new Parquet object
+func (s *S3) Write(ctx context.Context, table string, columns []Column, rows []Row) error {
+	now := s.now().UTC()
+	key := fmt.Sprintf("%s/dt=%s/%s.parquet", table, now.Format("2006-01-02"), now.Format("20060102T150405.000000000Z"))
+	if s.config.Prefix != "" {
+		key = s.config.Prefix + "/" + key
+	}
+
+	if err := s.put(ctx, key, encodeParquet(columns, rows)); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// put uploads an object with a SigV4-signed PUT
+func (s *S3) put(ctx context.Context, key string, body []byte) error {
+	var endpoint *url.URL
+	if s.config.Endpoint != "" {
+		endpoint, _ = url.Parse(s.config.Endpoint)
+		endpoint.Path = "/" + s.config.Bucket + "/" + key
+	} else {
+		endpoint = &url.URL{
+			Scheme: "https",
+			Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", s.config.Bucket, s.config.Region),
+			Path:   "/" + key,
+		}
+	}
+	canonicalURI := awsEscapePath(endpoint.Path)
+	endpoint.RawPath = canonicalURI
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.apache.parquet")
+	s.sign(req, canonicalURI, body)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("s3 returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// sign adds AWS Signature Version 4 headers for the s3 service
+func (s *S3) sign(req *http.Request, canonicalURI string, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"", // no query string
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsEscapePath percent-encodes everything but unreserved characters and
+// slashes, as SigV4 canonical URIs require
+func awsEscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
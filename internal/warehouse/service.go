@@ -0,0 +1,477 @@
+// warehouse/service.go
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/metering"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+	"github.com/go-redis/redis/v8"
+)
+
+// ExportJobType identifies recurring warehouse exports in the jobs subsystem
+const ExportJobType = "warehouse_export"
+
+// EventsTable receives one row per exported entity change
+const EventsTable = "events"
+
+// Metadata columns added to every exported row
+const (
+	ColumnRowID      = "_row_id" // entity, ID and LastUpdatedTime; stable across re-exports
+	ColumnRealmID    = "_realm_id"
+	ColumnExportedAt = "_exported_at"
+)
+
+// exportBatchSize is the number of rows written per sink call; it matches
+// the QuickBooks page size so each page is one write
+const exportBatchSize = 1000
+
+// defaultEntities are exported when a tenant does not choose any
+var defaultEntities = []string{"Customer", "Vendor", "Item", "Account", "Invoice", "Payment", "SalesReceipt", "Bill", "BillPayment", "Purchase", "Deposit", "JournalEntry"}
+
+// exportableEntities lists the QuickBooks entities that can be exported
+var exportableEntities = map[string]bool{
+	"Account": true, "Bill": true, "BillPayment": true, "Class": true,
+	"CreditMemo": true, "Customer": true, "Department": true, "Deposit": true,
+	"Employee": true, "Estimate": true, "Invoice": true, "Item": true,
+	"JournalEntry": true, "Payment": true, "PaymentMethod": true, "Purchase": true,
+	"PurchaseOrder": true, "RefundReceipt": true, "SalesReceipt": true, "TaxCode": true,
+	"Term": true, "TimeActivity": true, "Transfer": true, "Vendor": true, "VendorCredit": true,
+}
+
+// ErrNotConfigured is returned when a tenant has not configured a warehouse
+var ErrNotConfigured = errors.New("warehouse export is not configured")
+
+// Config is a tenant's warehouse export configuration
+type Config struct {
+	RealmID   string     `json:"realm_id"`
+	UserID    string     `json:"user_id"`
+	Sink      SinkConfig `json:"sink"`
+	Entities  []string   `json:"entities"`
+	Frequency string     `json:"frequency"` // hourly or daily
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Validate checks the entity list and schedule, and that the sink can be built
+func (c *Config) Validate() error {
+	if len(c.Entities) == 0 {
+		c.Entities = defaultEntities
+	}
+	for _, entity := range c.Entities {
+		if !exportableEntities[entity] {
+			return fmt.Errorf("unsupported entity: %q", entity)
+		}
+	}
+	switch c.Frequency {
+	case "":
+		c.Frequency = jobs.FrequencyDaily
+	case jobs.FrequencyHourly, jobs.FrequencyDaily:
+	default:
+		return fmt.Errorf("frequency must be %q or %q", jobs.FrequencyHourly, jobs.FrequencyDaily)
+	}
+	_, err := NewSink(c.Sink)
+	return err
+}
+
+// Redacted returns a copy of the config with credentials removed
+func (c Config) Redacted() Config {
+	if c.Sink.BigQuery != nil {
+		bq := *c.Sink.BigQuery
+		bq.CredentialsJSON = ""
+		c.Sink.BigQuery = &bq
+	}
+	if c.Sink.Snowflake != nil {
+		sf := *c.Sink.Snowflake
+		sf.PrivateKey = ""
+		c.Sink.Snowflake = &sf
+	}
+	if c.Sink.S3 != nil {
+		s3 := *c.Sink.S3
+		s3.SecretAccessKey = ""
+		c.Sink.S3 = &s3
+	}
+	return c
+}
+
+// destination identifies where a sink writes, ignoring credentials. A
+// change of destination restarts the export from scratch.
+func (c SinkConfig) destination() string {
+	switch {
+	case c.Type == SinkBigQuery && c.BigQuery != nil:
+		return strings.Join([]string{c.Type, c.BigQuery.ProjectID, c.BigQuery.Dataset}, "/")
+	case c.Type == SinkSnowflake && c.Snowflake != nil:
+		return strings.Join([]string{c.Type, c.Snowflake.Account, c.Snowflake.Database, c.Snowflake.Schema}, "/")
+	case c.Type == SinkS3 && c.S3 != nil:
+		return strings.Join([]string{c.Type, c.S3.Endpoint, c.S3.Bucket, c.S3.Prefix}, "/")
+	}
+	return c.Type
+}
+
+// keepSecrets copies stored credentials into a config that omits them
+func (c *SinkConfig) keepSecrets(existing SinkConfig) {
+	if c.Type != existing.Type {
+		return
+	}
+	if c.BigQuery != nil && existing.BigQuery != nil && c.BigQuery.CredentialsJSON == "" {
+		c.BigQuery.CredentialsJSON = existing.BigQuery.CredentialsJSON
+	}
+	if c.Snowflake != nil && existing.Snowflake != nil && c.Snowflake.PrivateKey == "" {
+		c.Snowflake.PrivateKey = existing.Snowflake.PrivateKey
+	}
+	if c.S3 != nil && existing.S3 != nil && c.S3.SecretAccessKey == "" {
+		c.S3.SecretAccessKey = existing.S3.SecretAccessKey
+	}
+}
+
+// Querier is the subset of the QuickBooks client used to read entities
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// EntityResult summarizes the export of one entity type
+type EntityResult struct {
+	Entity       string   `json:"entity"`
+	Rows         int      `json:"rows"`
+	Watermark    string   `json:"watermark,omitempty"`
+	AddedColumns []string `json:"added_columns,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// Status reports a tenant's export progress
+type Status struct {
+	Watermarks map[string]string   `json:"watermarks"`
+	Schemas    map[string][]Column `json:"schemas"`
+}
+
+// Service exports QuickBooks entities to a tenant's data warehouse
+type Service struct {
+	client    redis.UniversalClient
+	prefix    string
+	querier   Querier
+	scheduler *jobs.Scheduler
+	meter     *metering.Meter
+}
+
+// NewService creates a new warehouse export service and registers its job
+// runner. Exported rows count toward the tenant's export volume.
+func NewService(client redis.UniversalClient, prefix string, querier Querier, scheduler *jobs.Scheduler, meter *metering.Meter) *Service {
+	service := &Service{
+		client:    client,
+		prefix:    prefix,
+		querier:   querier,
+		scheduler: scheduler,
+		meter:     meter,
+	}
+	scheduler.RegisterRunner(ExportJobType, jobs.RunnerFunc(service.runExport))
+	return service
+}
+
+// configKey holds a tenant's warehouse configuration
+func (s *Service) configKey(tenantID string) string {
+	return fmt.Sprintf("%s:warehouse:config:%s", s.prefix, tenantID)
+}
+
+// watermarksKey is the hash of entity to last exported LastUpdatedTime
+func (s *Service) watermarksKey(tenantID string) string {
+	return fmt.Sprintf("%s:warehouse:watermarks:%s", s.prefix, tenantID)
+}
+
+// schemasKey is the hash of table to the columns known to exist in the sink
+func (s *Service) schemasKey(tenantID string) string {
+	return fmt.Sprintf("%s:warehouse:schemas:%s", s.prefix, tenantID)
+}
+
+// GetConfig returns a tenant's warehouse configuration
+func (s *Service) GetConfig(ctx context.Context, tenantID string) (*Config, error) {
+	data, err := s.client.Get(ctx, s.configKey(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrNotConfigured
+		}
+		return nil, fmt.Errorf("failed to get warehouse config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal warehouse config: %w", err)
+	}
+	return &config, nil
+}
+
+// SaveConfig stores a tenant's warehouse configuration and schedules the
+// export. Pointing the sink at a new destination resets watermarks and the
+// known schema so the new destination receives a full export.
+func (s *Service) SaveConfig(ctx context.Context, tenantID string, config *Config) error {
+	existing, err := s.GetConfig(ctx, tenantID)
+	if err != nil && !errors.Is(err, ErrNotConfigured) {
+		return err
+	}
+	if existing != nil {
+		config.Sink.keepSecrets(existing.Sink)
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	config.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal warehouse config: %w", err)
+	}
+	if err := s.client.Set(ctx, s.configKey(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save warehouse config: %w", err)
+	}
+
+	if existing != nil && (existing.Sink.destination() != config.Sink.destination() || existing.RealmID != config.RealmID) {
+		if err := s.client.Del(ctx, s.watermarksKey(tenantID), s.schemasKey(tenantID)).Err(); err != nil {
+			return fmt.Errorf("failed to reset export state: %w", err)
+		}
+	}
+
+	return s.ensureScheduled(ctx, tenantID, config)
+}
+
+// ensureScheduled creates the tenant's export job, or updates the active
+// one when the frequency has changed
+func (s *Service) ensureScheduled(ctx context.Context, tenantID string, config *Config) error {
+	schedule := (&jobs.Schedule{Frequency: config.Frequency, Hour: 3}).Localize(ctx)
+
+	existing, err := s.scheduler.Store().ListByTenant(ctx, tenantID, ExportJobType)
+	if err != nil {
+		return err
+	}
+	for _, job := range existing {
+		if job.Status != jobs.StatusActive {
+			continue
+		}
+		if job.Schedule == nil || job.Schedule.Frequency != schedule.Frequency {
+			job.Schedule = schedule
+			job.NextRunAt = schedule.Next(time.Now())
+			return s.scheduler.Store().Save(ctx, job)
+		}
+		return nil
+	}
+
+	job, err := jobs.NewJob(ExportJobType, tenantID, config.UserID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create warehouse export job: %w", err)
+	}
+	job.Schedule = schedule
+	job.NextRunAt = schedule.Next(time.Now())
+
+	return s.scheduler.Store().Save(ctx, job)
+}
+
+// Status returns the tenant's watermarks and known table schemas
+func (s *Service) Status(ctx context.Context, tenantID string) (*Status, error) {
+	watermarks, err := s.client.HGetAll(ctx, s.watermarksKey(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watermarks: %w", err)
+	}
+	schemas, err := s.schemas(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &Status{Watermarks: watermarks, Schemas: schemas}, nil
+}
+
+// schemas loads the known columns of every table
+func (s *Service) schemas(ctx context.Context, tenantID string) (map[string][]Column, error) {
+	values, err := s.client.HGetAll(ctx, s.schemasKey(tenantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schemas: %w", err)
+	}
+	schemas := make(map[string][]Column, len(values))
+	for table, v := range values {
+		var columns []Column
+		if json.Unmarshal([]byte(v), &columns) == nil {
+			schemas[table] = columns
+		}
+	}
+	return schemas, nil
+}
+
+// Export pushes every configured entity changed since its watermark to the
+// sink. Entities are exported independently: a failure is recorded in that
+// entity's result and the rest continue. The context must carry the
+// tenant's QuickBooks identity.
+//
+// Rows are appended, not merged, and each page re-reads rows updated at the
+// watermark itself, so delivery is at-least-once. Consumers deduplicate on
+// _row_id, or take the latest row per Id.
+func (s *Service) Export(ctx context.Context, tenantID string, config *Config) ([]EntityResult, error) {
+	sink, err := NewSink(config.Sink)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := s.schemas(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]EntityResult, 0, len(config.Entities))
+	rows := 0
+	for _, entity := range config.Entities {
+		result := EntityResult{Entity: entity}
+		if err := s.exportEntity(ctx, tenantID, config, sink, schemas, &result); err != nil {
+			result.Error = err.Error()
+		}
+		rows += result.Rows
+		results = append(results, result)
+	}
+	if err := s.meter.Add(ctx, tenantID, metering.ExportRows, int64(rows)); err != nil {
+		logging.FromContext(ctx).Warn("failed to meter exported rows", "tenant_id", tenantID, "error", err)
+	}
+	return results, nil
+}
+
+// exportEntity pages through one entity in LastUpdatedTime order, writing
+// each page and advancing the watermark only after the write succeeds
+func (s *Service) exportEntity(ctx context.Context, tenantID string, config *Config, sink Sink, schemas map[string][]Column, result *EntityResult) error {
+	watermark, err := s.client.HGet(ctx, s.watermarksKey(tenantID), result.Entity).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get watermark: %w", err)
+	}
+	result.Watermark = watermark
+
+	q := query.Select(result.Entity).OrderBy("MetaData.LastUpdatedTime")
+	if watermark != "" {
+		q.Where("MetaData.LastUpdatedTime", ">=", watermark)
+	}
+
+	for page := 1; ; page++ {
+		entities, err := query.List[map[string]interface{}](ctx, s.querier, q.Paginate(page, exportBatchSize))
+		if err != nil {
+			return err
+		}
+		if len(entities) == 0 {
+			return nil
+		}
+
+		rows, events, latest := s.toRows(config.RealmID, result.Entity, entities)
+		table := strings.ToLower(result.Entity)
+		if err := s.write(ctx, tenantID, sink, schemas, table, rows, result); err != nil {
+			return err
+		}
+		if err := s.write(ctx, tenantID, sink, schemas, EventsTable, events, nil); err != nil {
+			return err
+		}
+
+		if latest != "" {
+			if err := s.client.HSet(ctx, s.watermarksKey(tenantID), result.Entity, latest).Err(); err != nil {
+				return fmt.Errorf("failed to save watermark: %w", err)
+			}
+			result.Watermark = latest
+		}
+		result.Rows += len(rows)
+
+		if len(entities) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// toRows flattens a page of entities into table rows and change events,
+// returning the greatest LastUpdatedTime seen. Times are compared parsed
+// since a realm's UTC offset changes with daylight saving.
+func (s *Service) toRows(realmID, entityType string, entities []map[string]interface{}) ([]Row, []Row, string) {
+	exportedAt := time.Now().UTC().Format(time.RFC3339)
+	rows := make([]Row, 0, len(entities))
+	events := make([]Row, 0, len(entities))
+	latest := ""
+	var latestTime time.Time
+
+	for _, e := range entities {
+		id, _ := e["Id"].(string)
+		var created, updated string
+		if meta, ok := e["MetaData"].(map[string]interface{}); ok {
+			created, _ = meta["CreateTime"].(string)
+			updated, _ = meta["LastUpdatedTime"].(string)
+		}
+		if t, err := time.Parse(time.RFC3339, updated); err == nil && t.After(latestTime) {
+			latest, latestTime = updated, t
+		}
+		rowID := entityType + ":" + id + ":" + updated
+
+		row := flatten(e)
+		row[ColumnRowID] = rowID
+		row[ColumnRealmID] = realmID
+		row[ColumnExportedAt] = exportedAt
+		rows = append(rows, row)
+
+		operation := "update"
+		if created != "" && created == updated {
+			operation = "create"
+		}
+		events = append(events, Row{
+			ColumnRowID:      rowID,
+			ColumnRealmID:    realmID,
+			ColumnExportedAt: exportedAt,
+			"entity":         entityType,
+			"entity_id":      id,
+			"operation":      operation,
+			"occurred_at":    updated,
+		})
+	}
+	return rows, events, latest
+}
+
+// write evolves the table schema to cover the rows, then writes them
+func (s *Service) write(ctx context.Context, tenantID string, sink Sink, schemas map[string][]Column, table string, rows []Row, result *EntityResult) error {
+	known, seen := schemas[table]
+	columns, added := evolve(known, rows)
+	if added || !seen {
+		if err := sink.EnsureSchema(ctx, table, columns); err != nil {
+			return err
+		}
+		data, err := json.Marshal(columns)
+		if err != nil {
+			return fmt.Errorf("failed to marshal schema: %w", err)
+		}
+		if err := s.client.HSet(ctx, s.schemasKey(tenantID), table, data).Err(); err != nil {
+			return fmt.Errorf("failed to save schema: %w", err)
+		}
+		schemas[table] = columns
+		if result != nil {
+			for _, c := range columns[len(known):] {
+				result.AddedColumns = append(result.AddedColumns, c.Name)
+			}
+		}
+	}
+
+	conform(columns, rows)
+	return sink.Write(ctx, table, columns, rows)
+}
+
+// runExport is the job runner for scheduled exports
+func (s *Service) runExport(ctx context.Context, job *jobs.Job) error {
+	config, err := s.GetConfig(ctx, job.TenantID)
+	if err != nil {
+		return err
+	}
+
+	ctx = auth.WithIdentity(ctx, config.UserID, job.TenantID, config.RealmID)
+
+	results, err := s.Export(ctx, job.TenantID, config)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, result := range results {
+		if result.Error != "" {
+			failed = append(failed, result.Entity+": "+result.Error)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("warehouse export failed for %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// warehouse/sink.go
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Column types, kept to the subset every sink can represent
+const (
+	TypeString = "STRING"
+	TypeFloat  = "FLOAT"
+	TypeBool   = "BOOLEAN"
+)
+
+// Column is a named, typed, nullable column
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Row is one exported record keyed by column name; missing columns are null
+type Row map[string]interface{}
+
+// Sink writes rows to an external warehouse table
+type Sink interface {
+	// EnsureSchema creates the table or adds any missing columns. Columns
+	// are only ever added, so existing data stays readable.
+	EnsureSchema(ctx context.Context, table string, columns []Column) error
+	// Write appends rows to the table
+	Write(ctx context.Context, table string, columns []Column, rows []Row) error
+}
+
+// SinkConfig holds the connection settings for a warehouse destination.
+// Only the block matching Type is used.
+type SinkConfig struct {
+	Type      string           `json:"type"` // registered sink name, e.g. "bigquery"
+	BigQuery  *BigQueryConfig  `json:"bigquery,omitempty"`
+	Snowflake *SnowflakeConfig `json:"snowflake,omitempty"`
+	S3        *S3Config        `json:"s3,omitempty"`
+}
+
+// Factory builds a Sink from a tenant's settings
+type Factory func(config SinkConfig) (Sink, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// RegisterSink makes a sink available by name. Sinks register themselves
+// from init.
+func RegisterSink(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// NewSink builds the sink named by config.Type
+func NewSink(config SinkConfig) (Sink, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[config.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported warehouse sink: %q", config.Type)
+	}
+	return factory(config)
+}
+
+// SinkTypes lists the registered sink names
+func SinkTypes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// columnNamePattern matches characters not allowed in column names by all sinks
+var columnNamePattern = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// columnName sanitizes a field path for use as a column name
+func columnName(parts ...string) string {
+	return columnNamePattern.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+// flatten converts a QuickBooks entity into a row. Scalars become columns,
+// objects are flattened one level ("CustomerRef_value"), and anything deeper
+// or repeated (lines, custom fields) is stored as a JSON string.
+func flatten(entity map[string]interface{}) Row {
+	row := make(Row, len(entity))
+	for key, value := range entity {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for childKey, child := range v {
+				switch c := child.(type) {
+				case map[string]interface{}, []interface{}:
+					row[columnName(key, childKey)] = jsonString(c)
+				default:
+					row[columnName(key, childKey)] = c
+				}
+			}
+		case []interface{}:
+			row[columnName(key)] = jsonString(v)
+		default:
+			row[columnName(key)] = v
+		}
+	}
+	return row
+}
+
+// jsonString encodes a nested value as JSON text
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// typeOf infers the column type of a value; nil has no type
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return TypeBool
+	case float64, float32, int, int64, json.Number:
+		return TypeFloat
+	default:
+		return TypeString
+	}
+}
+
+// evolve merges the columns seen in rows into the known schema. Existing
+// columns keep their position and type; new columns are appended in name
+// order. It reports whether any column was added.
+func evolve(known []Column, rows []Row) ([]Column, bool) {
+	types := make(map[string]string, len(known))
+	for _, c := range known {
+		types[c.Name] = c.Type
+	}
+
+	added := make(map[string]string)
+	for _, row := range rows {
+		for name, value := range row {
+			if _, ok := types[name]; ok {
+				continue
+			}
+			t := typeOf(value)
+			if t == "" {
+				continue
+			}
+			if existing, ok := added[name]; ok && existing != t {
+				// Mixed types within one batch widen to string
+				t = TypeString
+			}
+			added[name] = t
+		}
+	}
+	if len(added) == 0 {
+		return known, false
+	}
+
+	names := make([]string, 0, len(added))
+	for name := range added {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := append([]Column(nil), known...)
+	for _, name := range names {
+		columns = append(columns, Column{Name: name, Type: added[name]})
+	}
+	return columns, true
+}
+
+// conform coerces row values to the schema: values of the wrong type are
+// stringified for string columns and dropped otherwise
+func conform(columns []Column, rows []Row) {
+	for _, row := range rows {
+		for _, c := range columns {
+			v, ok := row[c.Name]
+			if !ok || v == nil {
+				continue
+			}
+			if t := typeOf(v); t != c.Type {
+				if c.Type == TypeString {
+					row[c.Name] = fmt.Sprint(v)
+				} else {
+					delete(row, c.Name)
+				}
+			}
+		}
+	}
+}
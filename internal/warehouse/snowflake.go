@@ -0,0 +1,258 @@
+// warehouse/snowflake.go
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SinkSnowflake is the registered name of the Snowflake sink
+const SinkSnowflake = "snowflake"
+
+// snowflakeIdentPattern restricts account, database, schema, warehouse and
+// role names, which are interpolated into URLs and SQL
+var snowflakeIdentPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// snowflakeTypes maps column types to Snowflake column and binding types
+var snowflakeTypes = map[string]struct{ column, binding string }{
+	TypeString: {"VARCHAR", "TEXT"},
+	TypeFloat:  {"FLOAT", "REAL"},
+	TypeBool:   {"BOOLEAN", "BOOLEAN"},
+}
+
+func init() {
+	RegisterSink(SinkSnowflake, func(config SinkConfig) (Sink, error) {
+		if config.Snowflake == nil {
+			return nil, fmt.Errorf("snowflake settings are required")
+		}
+		return NewSnowflake(*config.Snowflake)
+	})
+}
+
+// SnowflakeConfig identifies the destination schema and key-pair user
+type SnowflakeConfig struct {
+	Account    string `json:"account"` // account identifier, e.g. "myorg-myaccount"
+	User       string `json:"user"`
+	Database   string `json:"database"`
+	Schema     string `json:"schema"`
+	Warehouse  string `json:"warehouse"`
+	Role       string `json:"role,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"` // PEM key registered as RSA_PUBLIC_KEY, never returned to clients
+}
+
+// Snowflake writes rows through the Snowflake SQL API using key-pair auth
+type Snowflake struct {
+	config      SnowflakeConfig
+	key         *rsa.PrivateKey
+	fingerprint string
+	httpClient  *http.Client
+}
+
+// NewSnowflake creates a Snowflake sink
+func NewSnowflake(config SnowflakeConfig) (*Snowflake, error) {
+	for name, value := range map[string]string{
+		"account":   config.Account,
+		"user":      config.User,
+		"database":  config.Database,
+		"schema":    config.Schema,
+		"warehouse": config.Warehouse,
+	} {
+		if !snowflakeIdentPattern.MatchString(value) {
+			return nil, fmt.Errorf("invalid snowflake %s: %q", name, value)
+		}
+	}
+	if config.Role != "" && !snowflakeIdentPattern.MatchString(config.Role) {
+		return nil, fmt.Errorf("invalid snowflake role: %q", config.Role)
+	}
+
+	key, err := parsePrivateKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	digest := sha256.Sum256(publicKey)
+
+	return &Snowflake{
+		config:      config,
+		key:         key,
+		fingerprint: "SHA256:" + base64.StdEncoding.EncodeToString(digest[:]),
+		httpClient:  &http.Client{Timeout: 120 * time.Second},
+	}, nil
+}
+
+// token builds a key-pair JWT. The issuer uses the account locator without
+// any region or cloud suffix, upper-cased as Snowflake expects.
+func (s *Snowflake) token() (string, error) {
+	account := strings.ToUpper(strings.SplitN(s.config.Account, ".", 2)[0])
+	user := strings.ToUpper(s.config.User)
+	now := time.Now()
+	return signJWT(s.key, map[string]interface{}{
+		"iss": account + "." + user + "." + s.fingerprint,
+		"sub": account + "." + user,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	})
+}
+
+// snowflakeBinding is a positional bind value; Value is a string or a
+// slice of strings for multi-row inserts
+type snowflakeBinding struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// execute runs a single SQL statement, polling until it completes
+func (s *Snowflake) execute(ctx context.Context, statement string, bindings map[string]snowflakeBinding) error {
+	request := map[string]interface{}{
+		"statement": statement,
+		"timeout":   110,
+		"database":  s.config.Database,
+		"schema":    s.config.Schema,
+		"warehouse": s.config.Warehouse,
+	}
+	if s.config.Role != "" {
+		request["role"] = s.config.Role
+	}
+	if len(bindings) > 0 {
+		request["bindings"] = bindings
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode statement: %w", err)
+	}
+
+	baseURL := fmt.Sprintf("https://%s.snowflakecomputing.com", s.config.Account)
+	method, endpoint := "POST", baseURL+"/api/v2/statements"
+	for {
+		token, err := s.token()
+		if err != nil {
+			return err
+		}
+		var body []byte
+		if method == "POST" {
+			body = payload
+		}
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("snowflake request failed: %w", err)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read snowflake response: %w", err)
+		}
+
+		var result struct {
+			Message            string `json:"message"`
+			StatementStatusURL string `json:"statementStatusUrl"`
+		}
+		json.Unmarshal(respBody, &result)
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return nil
+		case http.StatusAccepted:
+			// Still running; poll the status URL
+			if result.StatementStatusURL == "" {
+				return fmt.Errorf("snowflake statement accepted without a status URL")
+			}
+			method, endpoint = "GET", baseURL+result.StatementStatusURL
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+		default:
+			if result.Message != "" {
+				return fmt.Errorf("snowflake API error: %s", result.Message)
+			}
+			return fmt.Errorf("snowflake API returned status %d", resp.StatusCode)
+		}
+	}
+}
+
+// quoteIdent quotes an identifier, preserving its case
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// EnsureSchema creates the table and adds any missing columns. ADD COLUMN
+// IF NOT EXISTS makes this safe to repeat.
+func (s *Snowflake) EnsureSchema(ctx context.Context, table string, columns []Column) error {
+	definitions := make([]string, len(columns))
+	for i, c := range columns {
+		definitions[i] = quoteIdent(c.Name) + " " + snowflakeTypes[c.Type].column
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdent(table), strings.Join(definitions, ", "))
+	if err := s.execute(ctx, create, nil); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+
+	// An existing table may predate newer columns
+	alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", quoteIdent(table), strings.Join(definitions, ", "))
+	if err := s.execute(ctx, alter, nil); err != nil {
+		return fmt.Errorf("failed to add columns to %s: %w", table, err)
+	}
+	return nil
+}
+
+// Write inserts rows with a single array-bound INSERT
+func (s *Snowflake) Write(ctx context.Context, table string, columns []Column, rows []Row) error {
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	bindings := make(map[string]snowflakeBinding, len(columns))
+	for i, c := range columns {
+		names[i] = quoteIdent(c.Name)
+		placeholders[i] = "?"
+
+		values := make([]interface{}, len(rows))
+		for j, row := range rows {
+			values[j] = snowflakeValue(row[c.Name])
+		}
+		bindings[strconv.Itoa(i+1)] = snowflakeBinding{Type: snowflakeTypes[c.Type].binding, Value: values}
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(table), strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	if err := s.execute(ctx, insert, bindings); err != nil {
+		return fmt.Errorf("failed to insert rows into %s: %w", table, err)
+	}
+	return nil
+}
+
+// snowflakeValue formats a value as the string the SQL API binds; nil stays null
+func snowflakeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
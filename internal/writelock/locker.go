@@ -0,0 +1,82 @@
+// writelock/locker.go
+package writelock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrTimeout is returned when an entity stays locked for longer than the wait limit
+var ErrTimeout = errors.New("entity is being modified by another request; try again")
+
+// retryInterval is how often a waiting writer polls the lock
+const retryInterval = 50 * time.Millisecond
+
+// release deletes the lock only if this holder still owns it, so a holder
+// whose lock expired cannot release its successor's
+var release = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Locker implements per-entity write locks with Redis SETNX. The TTL bounds
+// how long a crashed holder can block writers.
+type Locker struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+	wait   time.Duration
+}
+
+// NewLocker creates a new write locker
+func NewLocker(client redis.UniversalClient, prefix string, ttl, wait time.Duration) *Locker {
+	return &Locker{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+		wait:   wait,
+	}
+}
+
+// key holds the lock for an entity
+func (l *Locker) key(name string) string {
+	return fmt.Sprintf("%s:writelock:%s", l.prefix, name)
+}
+
+// Lock waits up to the wait limit for the lock and returns its release function
+func (l *Locker) Lock(ctx context.Context, name string) (func(), error) {
+	key := l.key(name)
+	token := jobs.NewID()
+	deadline := time.Now().Add(l.wait)
+
+	for {
+		acquired, err := l.client.SetNX(ctx, key, token, l.ttl).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire write lock: %w", err)
+		}
+		if acquired {
+			return func() {
+				// Release even if the request context was cancelled mid-write
+				if err := release.Run(context.Background(), l.client, []string{key}, token).Err(); err != nil {
+					logging.FromContext(ctx).Warn("failed to release write lock", "lock", name, "error", err)
+				}
+			}, nil
+		}
+
+		if time.Now().Add(retryInterval).After(deadline) {
+			return nil, ErrTimeout
+		}
+		select {
+		case <-time.After(retryInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
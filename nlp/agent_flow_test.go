@@ -0,0 +1,167 @@
+// nlp/agent_flow_test.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eGGnogSC/qbserver/infrastructure/fakes"
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/search"
+)
+
+// agentFlow wires the planner and tools to the recorded fixtures and an
+// in-memory search index for one company
+type agentFlow struct {
+	ctx      context.Context
+	provider *FixtureProvider
+	planner  *Planner
+	registry *ToolRegistry
+}
+
+func newAgentFlow(t *testing.T) *agentFlow {
+	t.Helper()
+
+	provider, err := LoadFixtureProvider("testdata/fixtures")
+	if err != nil {
+		t.Fatalf("LoadFixtureProvider: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), auth.CompanyIDKey, "realm-1")
+	index := search.NewService(fakes.NewEmbedder(), fakes.NewVectorStore())
+	err = index.Index(ctx, []search.Document{
+		{RealmID: "realm-1", EntityType: search.EntityCustomer, EntityID: "1", Text: "Green Acres landscaping companies lawn care"},
+		{RealmID: "realm-1", EntityType: search.EntityCustomer, EntityID: "2", Text: "Harbor Dental clinic"},
+		{RealmID: "realm-1", EntityType: search.EntityInvoice, EntityID: "10", Text: "unpaid invoices for Green Acres"},
+		{RealmID: "realm-2", EntityType: search.EntityCustomer, EntityID: "3", Text: "Other company landscaping companies"},
+	})
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	registry := NewToolRegistry(nil)
+	registry.Register(NewSearchTool(index))
+
+	return &agentFlow{
+		ctx:      ctx,
+		provider: provider,
+		planner:  NewPlanner(provider, registry),
+		registry: registry,
+	}
+}
+
+// run compiles an instruction and executes the plan's tool calls in order
+func (f *agentFlow) run(instruction string) (*Plan, []*ToolResult, error) {
+	plan, err := f.planner.Compile(f.ctx, instruction)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results []*ToolResult
+	for _, step := range plan.Steps {
+		result, err := f.registry.Execute(f.ctx, step.Tool, step.Args)
+		if err != nil {
+			return plan, results, err
+		}
+		results = append(results, result)
+	}
+	return plan, results, nil
+}
+
+func TestAgentFlowSearchCustomers(t *testing.T) {
+	flow := newAgentFlow(t)
+
+	plan, results, err := flow.run("Find customers that are landscaping companies")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if len(plan.Steps) != 1 || plan.Steps[0].Tool != "search_entities" {
+		t.Fatalf("plan steps = %+v, want one search_entities call", plan.Steps)
+	}
+	var args struct {
+		Query string   `json:"query"`
+		Types []string `json:"types"`
+	}
+	if err := json.Unmarshal(plan.Steps[0].Args, &args); err != nil {
+		t.Fatalf("step args: %v", err)
+	}
+	if args.Query != "landscaping companies" || len(args.Types) != 1 || args.Types[0] != search.EntityCustomer {
+		t.Errorf("step args = %+v", args)
+	}
+	if plan.Schedule != nil {
+		t.Errorf("schedule = %+v, want none", plan.Schedule)
+	}
+
+	hits, ok := results[0].Output.([]search.Result)
+	if !ok {
+		t.Fatalf("output = %T, want []search.Result", results[0].Output)
+	}
+	if len(hits) == 0 || hits[0].EntityID != "1" {
+		t.Fatalf("hits = %+v, want customer 1 first", hits)
+	}
+	for _, hit := range hits {
+		if hit.RealmID != "realm-1" || hit.EntityType != search.EntityCustomer {
+			t.Errorf("hit %+v is outside the company's customers", hit)
+		}
+	}
+
+	// The model is only offered the registered tools
+	requests := flow.provider.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("provider requests = %d, want 1", len(requests))
+	}
+	if system := requests[0].Messages[0].Content; !strings.Contains(system, "- search_entities:") {
+		t.Errorf("system prompt does not offer search_entities:\n%s", system)
+	}
+}
+
+func TestAgentFlowWeeklyInvoiceSearch(t *testing.T) {
+	flow := newAgentFlow(t)
+
+	plan, results, err := flow.run("Every Monday morning, list unpaid invoices")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	want := jobs.Schedule{Frequency: jobs.FrequencyWeekly, Weekday: 1, Hour: 8}
+	if plan.Schedule == nil || *plan.Schedule != want {
+		t.Fatalf("schedule = %+v, want %+v", plan.Schedule, want)
+	}
+
+	hits, ok := results[0].Output.([]search.Result)
+	if !ok {
+		t.Fatalf("output = %T, want []search.Result", results[0].Output)
+	}
+	if len(hits) != 1 || hits[0].EntityID != "10" {
+		t.Errorf("hits = %+v, want invoice 10", hits)
+	}
+}
+
+func TestAgentFlowProviderError(t *testing.T) {
+	flow := newAgentFlow(t)
+
+	plan, results, err := flow.run("Please simulate provider outage")
+	if err == nil {
+		t.Fatalf("run succeeded with plan %+v, want provider error", plan)
+	}
+	if !strings.Contains(err.Error(), "upstream model unavailable") {
+		t.Errorf("error = %v, want the fixture's provider error", err)
+	}
+	if results != nil {
+		t.Errorf("tools ran after a provider error: %+v", results)
+	}
+}
+
+func TestAgentFlowUnmatchedInstruction(t *testing.T) {
+	flow := newAgentFlow(t)
+
+	_, _, err := flow.run("Reconcile the bank account")
+	if !errors.Is(err, ErrNoFixture) {
+		t.Errorf("error = %v, want ErrNoFixture", err)
+	}
+}
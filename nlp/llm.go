@@ -0,0 +1,42 @@
+// nlp/llm.go
+package nlp
+
+import (
+	"context"
+)
+
+// Message is a single chat message exchanged with the language model
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CompletionRequest describes a call to the language model
+type CompletionRequest struct {
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	MaxTokens int       `json:"max_tokens,omitempty"`
+}
+
+// TokenUsage records the tokens consumed by a single completion
+type TokenUsage struct {
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// TotalTokens returns the combined prompt and completion tokens
+func (u TokenUsage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// CompletionResponse is the language model's reply
+type CompletionResponse struct {
+	Content string     `json:"content"`
+	Usage   TokenUsage `json:"usage"`
+}
+
+// LLMProvider is implemented by language model backends used by the agent
+type LLMProvider interface {
+	Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error)
+}
@@ -0,0 +1,193 @@
+// nlp/llm_fixture.go
+package nlp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNoFixture is returned when no fixture matches a completion request
+var ErrNoFixture = errors.New("no fixture matches the request")
+
+// FixtureMatch selects the requests a fixture answers. Both conditions
+// apply to the latest user message; an empty match answers every request.
+type FixtureMatch struct {
+	User     string   `json:"user,omitempty"`     // exact message, as recorded
+	Contains []string `json:"contains,omitempty"` // case-insensitive substrings, all required
+}
+
+// matches reports whether the fixture applies to a user message
+func (m FixtureMatch) matches(message string) bool {
+	if m.User != "" && m.User != message {
+		return false
+	}
+	lower := strings.ToLower(message)
+	for _, s := range m.Contains {
+		if !strings.Contains(lower, strings.ToLower(s)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fixture is a canned reply to a completion request
+type Fixture struct {
+	Name     string             `json:"name,omitempty"`
+	Match    FixtureMatch       `json:"match"`
+	Response CompletionResponse `json:"response"`
+	Error    string             `json:"error,omitempty"` // returned instead of the response
+}
+
+// FixtureProvider is a deterministic LLMProvider that answers from fixtures
+// instead of calling a model, so agent flows can run in CI. Fixtures are
+// tried in order and the first match wins; unmatched requests fail with
+// ErrNoFixture rather than guessing.
+type FixtureProvider struct {
+	fixtures []Fixture
+
+	mu       sync.Mutex
+	requests []CompletionRequest
+}
+
+// NewFixtureProvider creates a provider answering from the given fixtures
+func NewFixtureProvider(fixtures []Fixture) *FixtureProvider {
+	return &FixtureProvider{
+		fixtures: fixtures,
+	}
+}
+
+// LoadFixtureProvider reads every .json file in dir, in file name order.
+// A file holds one fixture or an array of them.
+func LoadFixtureProvider(dir string) (*FixtureProvider, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	sort.Strings(paths)
+
+	var fixtures []Fixture
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+
+		var batch []Fixture
+		if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+			err = json.Unmarshal(data, &batch)
+		} else {
+			var fixture Fixture
+			err = json.Unmarshal(data, &fixture)
+			batch = []Fixture{fixture}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", filepath.Base(path), err)
+		}
+		for i := range batch {
+			if batch[i].Name == "" {
+				batch[i].Name = strings.TrimSuffix(filepath.Base(path), ".json")
+			}
+		}
+		fixtures = append(fixtures, batch...)
+	}
+
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+	return NewFixtureProvider(fixtures), nil
+}
+
+// Complete returns the first matching fixture's reply
+func (p *FixtureProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+
+	message := lastUserMessage(req.Messages)
+	for _, fixture := range p.fixtures {
+		if !fixture.Match.matches(message) {
+			continue
+		}
+		if fixture.Error != "" {
+			return nil, fmt.Errorf("fixture %s: %s", fixture.Name, fixture.Error)
+		}
+
+		resp := fixture.Response
+		if resp.Usage.Model == "" {
+			resp.Usage.Model = req.Model
+		}
+		if resp.Usage.PromptTokens == 0 && resp.Usage.CompletionTokens == 0 {
+			// Deterministic estimate so usage metering still has something to record
+			for _, m := range req.Messages {
+				resp.Usage.PromptTokens += len(m.Content) / 4
+			}
+			resp.Usage.CompletionTokens = len(resp.Content) / 4
+		}
+		return &resp, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrNoFixture, message)
+}
+
+// Requests returns the requests received so far, for assertions in tests
+func (p *FixtureProvider) Requests() []CompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]CompletionRequest(nil), p.requests...)
+}
+
+// RecordingProvider passes requests to a real provider and saves each reply
+// as a fixture, so a session against a live model can be replayed later by
+// a FixtureProvider
+type RecordingProvider struct {
+	provider LLMProvider
+	dir      string
+}
+
+// NewRecordingProvider creates a provider that records fixtures into dir
+func NewRecordingProvider(provider LLMProvider, dir string) *RecordingProvider {
+	return &RecordingProvider{
+		provider: provider,
+		dir:      dir,
+	}
+}
+
+// Complete forwards the request and records the reply. Recording failures
+// are returned so a recording session does not silently miss fixtures.
+func (p *RecordingProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	resp, err := p.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	message := lastUserMessage(req.Messages)
+	sum := sha256.Sum256([]byte(message))
+	name := hex.EncodeToString(sum[:])[:12]
+	fixture := Fixture{
+		Name:     name,
+		Match:    FixtureMatch{User: message},
+		Response: *resp,
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(p.dir, name+".json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,87 @@
+// nlp/llm_http.go
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPProvider calls an OpenAI-compatible chat completions API
+type HTTPProvider struct {
+	baseURL      string
+	apiKey       string
+	defaultModel string
+	httpClient   *http.Client
+}
+
+// NewHTTPProvider creates a new chat completions provider
+func NewHTTPProvider(baseURL, apiKey, defaultModel string) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		apiKey:       apiKey,
+		defaultModel: defaultModel,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Complete sends the messages to the model and returns its reply
+func (p *HTTPProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	if req.Model == "" {
+		req.Model = p.defaultModel
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal completion request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create completion request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("completion request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse completion response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("completion response contained no choices")
+	}
+
+	return &CompletionResponse{
+		Content: result.Choices[0].Message.Content,
+		Usage: TokenUsage{
+			Model:            result.Model,
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+		},
+	}, nil
+}
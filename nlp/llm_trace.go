@@ -0,0 +1,43 @@
+// nlp/llm_trace.go
+package nlp
+
+import (
+	"context"
+
+	"github.com/eGGnogSC/qbserver/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedProvider wraps an LLMProvider so every completion is a span under
+// the agent request or scheduled task that asked for it
+type TracedProvider struct {
+	provider LLMProvider
+}
+
+// NewTracedProvider creates a provider that traces completions
+func NewTracedProvider(provider LLMProvider) *TracedProvider {
+	return &TracedProvider{provider: provider}
+}
+
+// Complete forwards the request in a span recording the model and tokens
+// used. Message contents are not recorded.
+func (p *TracedProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	ctx, span := tracing.Start(ctx, "nlp.complete",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("llm.request.model", req.Model),
+			attribute.Int("llm.request.messages", len(req.Messages)),
+		),
+	)
+	resp, err := p.provider.Complete(ctx, req)
+	if err == nil {
+		span.SetAttributes(
+			attribute.String("llm.response.model", resp.Usage.Model),
+			attribute.Int("llm.usage.prompt_tokens", resp.Usage.PromptTokens),
+			attribute.Int("llm.usage.completion_tokens", resp.Usage.CompletionTokens),
+		)
+	}
+	tracing.End(span, err)
+	return resp, err
+}
@@ -0,0 +1,86 @@
+// nlp/planner.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+)
+
+// PlanStep is a single tool call in a compiled plan
+type PlanStep struct {
+	Tool string          `json:"tool"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// Plan is a natural-language instruction compiled into tool calls
+type Plan struct {
+	Steps []PlanStep `json:"steps"`
+	// Schedule is filled in when the instruction describes a recurrence
+	Schedule *jobs.Schedule `json:"schedule,omitempty"`
+}
+
+const plannerPrompt = `You compile accounting instructions into a JSON plan of tool calls.
+Respond with JSON only, in the form:
+{"steps":[{"tool":"<name>","args":{...}}],"schedule":{"frequency":"hourly|daily|weekly|monthly","weekday":0-6,"day_of_month":1-28,"hour":0-23,"minute":0-59}}
+Omit "schedule" if the instruction does not describe a recurrence. Only use these tools:
+`
+
+// Planner compiles instructions into plans using the language model
+type Planner struct {
+	provider LLMProvider
+	registry *ToolRegistry
+}
+
+// NewPlanner creates a new planner
+func NewPlanner(provider LLMProvider, registry *ToolRegistry) *Planner {
+	return &Planner{
+		provider: provider,
+		registry: registry,
+	}
+}
+
+// Compile turns an instruction into a validated plan
+func (p *Planner) Compile(ctx context.Context, instruction string) (*Plan, error) {
+	// Only offer tools the caller may use
+	var tools strings.Builder
+	for _, def := range p.registry.Definitions(ctx) {
+		fmt.Fprintf(&tools, "- %s: %s\n", def.Name, def.Description)
+	}
+
+	resp, err := p.provider.Complete(ctx, CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: plannerPrompt + tools.String()},
+			{Role: "user", Content: instruction},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile plan: %w", err)
+	}
+
+	var plan Plan
+	content := strings.TrimSpace(resp.Content)
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```json"), "```")
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	if len(plan.Steps) == 0 {
+		return nil, fmt.Errorf("instruction did not produce any steps")
+	}
+	for _, step := range plan.Steps {
+		if _, ok := p.registry.Get(step.Tool); !ok {
+			return nil, fmt.Errorf("plan references unknown tool: %s", step.Tool)
+		}
+	}
+	if plan.Schedule != nil {
+		if err := plan.Schedule.Validate(); err != nil {
+			return nil, fmt.Errorf("plan has invalid schedule: %w", err)
+		}
+	}
+
+	return &plan, nil
+}
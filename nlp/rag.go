@@ -0,0 +1,77 @@
+// nlp/rag.go
+package nlp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/knowledge"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+)
+
+// RetrievalProvider adds passages from the tenant's reference documents to
+// each completion so answers reflect company-specific policies
+type RetrievalProvider struct {
+	provider  LLMProvider
+	knowledge knowledge.Servicer
+	limit     int
+}
+
+// NewRetrievalProvider creates a provider that augments prompts with tenant documents
+func NewRetrievalProvider(provider LLMProvider, knowledgeService knowledge.Servicer, limit int) *RetrievalProvider {
+	return &RetrievalProvider{
+		provider:  provider,
+		knowledge: knowledgeService,
+		limit:     limit,
+	}
+}
+
+// Complete retrieves context for the latest user message and forwards the request
+func (p *RetrievalProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	query := lastUserMessage(req.Messages)
+	if query == "" {
+		return p.provider.Complete(ctx, req)
+	}
+
+	passages, err := p.knowledge.Retrieve(ctx, auth.GetTenantID(ctx), query, p.limit)
+	if err != nil {
+		// Answer without company context rather than failing the request
+		logging.FromContext(ctx).Warn("failed to retrieve agent context", "error", err)
+		return p.provider.Complete(ctx, req)
+	}
+	if len(passages) == 0 {
+		return p.provider.Complete(ctx, req)
+	}
+
+	var b strings.Builder
+	b.WriteString("Company reference material. Prefer it over general knowledge when relevant:\n")
+	for i, passage := range passages {
+		fmt.Fprintf(&b, "\n[%d] %s\n%s\n", i+1, passage.Title, passage.Text)
+	}
+
+	// Place the context after any existing system prompts
+	messages := make([]Message, 0, len(req.Messages)+1)
+	inserted := false
+	for _, m := range req.Messages {
+		if !inserted && m.Role != "system" {
+			messages = append(messages, Message{Role: "system", Content: b.String()})
+			inserted = true
+		}
+		messages = append(messages, m)
+	}
+	req.Messages = messages
+
+	return p.provider.Complete(ctx, req)
+}
+
+// lastUserMessage returns the content of the most recent user message
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+// nlp/redact.go
+package nlp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redactionRule replaces matches of a pattern with a placeholder
+type redactionRule struct {
+	name    string
+	pattern *regexp.Regexp
+	// validate optionally confirms a match, e.g. a Luhn check for card numbers
+	validate func(string) bool
+	// labeled patterns capture the label ahead of the value, such as
+	// "account number", in their first group; the label is kept
+	labeled bool
+}
+
+// Redactor masks personally identifiable information in free text
+type Redactor struct {
+	rules []redactionRule
+}
+
+// NewRedactor creates a redactor with the default PII rules
+func NewRedactor() *Redactor {
+	return &Redactor{
+		rules: []redactionRule{
+			{name: "EMAIL", pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+			{name: "CARD", pattern: regexp.MustCompile(`\b(?:\d[ \-]?){13,19}\b`), validate: luhnValid},
+			{name: "SSN", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+			// Bare digit runs are invoice numbers and amounts as often as
+			// accounts, so only those labeled as bank accounts are masked. They go
+			// ahead of phone numbers, which a 10-digit account would also match
+			{name: "ACCOUNT", pattern: regexp.MustCompile(`(?i)(\b(?:account|acct|a/c|routing|aba)\b[^\d\n]{0,20})\d{8,17}\b`), labeled: true},
+			{name: "PHONE", pattern: regexp.MustCompile(`(?:\+?1[ .\-]?)?\(?\b\d{3}\)?[ .\-]?\d{3}[ .\-]?\d{4}\b`)},
+		},
+	}
+}
+
+// Redact replaces PII in text with [REDACTED:<TYPE>] placeholders
+func (r *Redactor) Redact(text string) string {
+	for _, rule := range r.rules {
+		rule := rule
+		if rule.labeled {
+			text = rule.pattern.ReplaceAllString(text, "${1}[REDACTED:"+rule.name+"]")
+			continue
+		}
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.validate != nil && !rule.validate(match) {
+				return match
+			}
+			return "[REDACTED:" + rule.name + "]"
+		})
+	}
+	return text
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum
+func luhnValid(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+
+	if len(digits) < 13 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
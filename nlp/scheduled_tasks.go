@@ -0,0 +1,271 @@
+// nlp/scheduled_tasks.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/i18n"
+	"github.com/eGGnogSC/qbserver/internal/jobs"
+	"github.com/eGGnogSC/qbserver/internal/notify"
+	"github.com/gorilla/mux"
+)
+
+// ScheduledTaskJobType identifies agent tasks in the jobs subsystem
+const ScheduledTaskJobType = "agent_task"
+
+// Delivery describes where a scheduled task's results are sent
+type Delivery = notify.Destination
+
+// ScheduledTask is the job payload for a recurring agent instruction
+type ScheduledTask struct {
+	Instruction string   `json:"instruction"`
+	Plan        Plan     `json:"plan"`
+	Delivery    Delivery `json:"delivery"`
+	Role        string   `json:"role"`
+	RealmID     string   `json:"realm_id"`         // the company the task reads from
+	Locale      string   `json:"locale,omitempty"` // results are written in the creator's locale
+}
+
+// ScheduledTaskService creates and runs recurring agent tasks
+type ScheduledTaskService struct {
+	scheduler *jobs.Scheduler
+	planner   *Planner
+	registry  *ToolRegistry
+	notifier  *notify.Dispatcher
+}
+
+// NewScheduledTaskService creates a new scheduled task service and registers
+// it as the runner for agent tasks
+func NewScheduledTaskService(scheduler *jobs.Scheduler, planner *Planner, registry *ToolRegistry, notifier *notify.Dispatcher) *ScheduledTaskService {
+	service := &ScheduledTaskService{
+		scheduler: scheduler,
+		planner:   planner,
+		registry:  registry,
+		notifier:  notifier,
+	}
+	scheduler.RegisterRunner(ScheduledTaskJobType, service)
+	return service
+}
+
+// Create compiles an instruction and persists it as a recurring job
+func (s *ScheduledTaskService) Create(ctx context.Context, instruction string, schedule *jobs.Schedule, delivery Delivery) (*jobs.Job, error) {
+	if err := s.notifier.Validate(delivery); err != nil {
+		return nil, err
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.planner.Compile(ctx, instruction)
+	if err != nil {
+		return nil, err
+	}
+
+	// An explicit schedule wins over one inferred from the instruction
+	if schedule == nil {
+		schedule = plan.Schedule
+	}
+	if schedule == nil {
+		return nil, fmt.Errorf("could not determine a schedule from the instruction")
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	plan.Schedule = nil
+
+	task := ScheduledTask{
+		Instruction: instruction,
+		Plan:        *plan,
+		Delivery:    delivery,
+		Role:        auth.GetRole(ctx),
+		RealmID:     realmID,
+		Locale:      i18n.FromContext(ctx),
+	}
+
+	job, err := jobs.NewJob(ScheduledTaskJobType, auth.GetTenantID(ctx), auth.GetUserID(ctx), task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	job.Schedule = schedule.Localize(ctx)
+	job.NextRunAt = schedule.Next(time.Now())
+
+	if err := s.scheduler.Store().Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Import persists a task exported from another environment. The compiled
+// plan is reused rather than recompiled, and the task runs with the
+// importing user's role against the importing user's company.
+func (s *ScheduledTaskService) Import(ctx context.Context, task ScheduledTask, schedule *jobs.Schedule) (*jobs.Job, error) {
+	if err := s.notifier.Validate(task.Delivery); err != nil {
+		return nil, err
+	}
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, fmt.Errorf("schedule is required")
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	for _, step := range task.Plan.Steps {
+		if _, ok := s.registry.Get(step.Tool); !ok {
+			return nil, fmt.Errorf("unknown tool in plan: %s", step.Tool)
+		}
+	}
+	task.Plan.Schedule = nil
+	task.Role = auth.GetRole(ctx)
+	task.RealmID = realmID
+
+	job, err := jobs.NewJob(ScheduledTaskJobType, auth.GetTenantID(ctx), auth.GetUserID(ctx), task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	job.Schedule = schedule.Localize(ctx)
+	job.NextRunAt = schedule.Next(time.Now())
+
+	if err := s.scheduler.Store().Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// List returns the tenant's scheduled agent tasks
+func (s *ScheduledTaskService) List(ctx context.Context, tenantID string) ([]*jobs.Job, error) {
+	return s.scheduler.Store().ListByTenant(ctx, tenantID, ScheduledTaskJobType)
+}
+
+// Cancel stops a tenant's scheduled task
+func (s *ScheduledTaskService) Cancel(ctx context.Context, tenantID, id string) (*jobs.Job, error) {
+	job, err := s.scheduler.Store().Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.TenantID != tenantID || job.Type != ScheduledTaskJobType {
+		return nil, jobs.ErrJobNotFound
+	}
+
+	return s.scheduler.Cancel(ctx, id)
+}
+
+// Run executes a scheduled task's plan and delivers the results
+func (s *ScheduledTaskService) Run(ctx context.Context, job *jobs.Job) error {
+	var task ScheduledTask
+	if err := json.Unmarshal(job.Payload, &task); err != nil {
+		return fmt.Errorf("failed to decode scheduled task: %w", err)
+	}
+
+	// Run with the identity of the user who created the task
+	ctx = auth.WithIdentity(ctx, job.UserID, job.TenantID, task.RealmID)
+	ctx = context.WithValue(ctx, auth.RoleKey, task.Role)
+	ctx = i18n.WithLocale(ctx, task.Locale)
+	ctx = s.registry.WithPolicy(ctx)
+
+	var body strings.Builder
+	for _, step := range task.Plan.Steps {
+		result, err := s.registry.Execute(ctx, step.Tool, step.Args)
+		if err != nil {
+			fmt.Fprintf(&body, "%s\n\n", i18n.T(ctx, "task.step_failed", step.Tool, err))
+			continue
+		}
+		if result.Denied {
+			fmt.Fprintf(&body, "%s\n\n", result.Message)
+			continue
+		}
+
+		output, _ := json.MarshalIndent(result.Output, "", "  ")
+		fmt.Fprintf(&body, "%s:\n%s\n\n", step.Tool, output)
+	}
+
+	return s.notifier.Send(ctx, task.Delivery.Channel, task.Delivery.Target, notify.Message{
+		Subject: i18n.T(ctx, "task.subject", task.Instruction),
+		Body:    body.String(),
+	})
+}
+
+// ScheduledTaskHandler provides HTTP handlers for scheduled agent tasks
+type ScheduledTaskHandler struct {
+	service *ScheduledTaskService
+}
+
+// NewScheduledTaskHandler creates a new scheduled task handler
+func NewScheduledTaskHandler(service *ScheduledTaskService) *ScheduledTaskHandler {
+	return &ScheduledTaskHandler{
+		service: service,
+	}
+}
+
+// CreateTask schedules a recurring natural-language instruction
+func (h *ScheduledTaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Instruction string         `json:"instruction"`
+		Schedule    *jobs.Schedule `json:"schedule,omitempty"`
+		Delivery    Delivery       `json:"delivery"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Instruction) == "" {
+		http.Error(w, "instruction is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.service.Create(r.Context(), req.Instruction, req.Schedule, req.Delivery)
+	if err != nil {
+		http.Error(w, "Failed to schedule task: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ListTasks returns the tenant's scheduled tasks
+func (h *ScheduledTaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.service.List(r.Context(), auth.GetTenantID(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to list tasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tasks": tasks,
+	})
+}
+
+// CancelTask cancels a scheduled task
+func (h *ScheduledTaskHandler) CancelTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	job, err := h.service.Cancel(r.Context(), auth.GetTenantID(r.Context()), id)
+	if err != nil {
+		if errors.Is(err, jobs.ErrJobNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to cancel task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
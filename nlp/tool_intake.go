@@ -0,0 +1,81 @@
+// nlp/tool_intake.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/intake"
+)
+
+// BillDraftTool lets the agent create the bill or expense of a scanned
+// document's draft once the user has confirmed it, filling in what intake
+// could not
+type BillDraftTool struct {
+	service *intake.Service
+}
+
+// NewBillDraftTool creates a new bill draft confirmation tool
+func NewBillDraftTool(service *intake.Service) *BillDraftTool {
+	return &BillDraftTool{
+		service: service,
+	}
+}
+
+// Name returns the tool name
+func (t *BillDraftTool) Name() string {
+	return "confirm_bill_draft"
+}
+
+// Description describes the tool to the model
+func (t *BillDraftTool) Description() string {
+	return `Create the QuickBooks bill or expense of a scanned or emailed receipt or bill draft after the user confirmed it. Args: {"draft_id": string, "vendor_id": string, "account_id": string, "payment_account_id": string (expenses only), "txn_date": "YYYY-MM-DD"}; omitted fields keep the draft's values`
+}
+
+// Mutating reports that confirming a draft creates a bill or expense
+func (t *BillDraftTool) Mutating() bool {
+	return true
+}
+
+// Execute confirms the draft with the given corrections
+func (t *BillDraftTool) Execute(ctx context.Context, args json.RawMessage) (interface{}, error) {
+	var params struct {
+		DraftID          string `json:"draft_id"`
+		VendorID         string `json:"vendor_id"`
+		AccountID        string `json:"account_id"`
+		PaymentAccountID string `json:"payment_account_id"`
+		TxnDate          string `json:"txn_date"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid bill draft arguments: %w", err)
+	}
+	if params.DraftID == "" {
+		return nil, fmt.Errorf("draft_id is required")
+	}
+
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	draft, err := t.service.Store().Get(ctx, realmID, params.DraftID)
+	if err != nil {
+		return nil, err
+	}
+
+	bill := draft.Bill
+	if params.VendorID != "" {
+		bill.VendorID = params.VendorID
+	}
+	if params.AccountID != "" {
+		bill.AccountID = params.AccountID
+	}
+	if params.PaymentAccountID != "" {
+		bill.PaymentAccountID = params.PaymentAccountID
+	}
+	if params.TxnDate != "" {
+		bill.TxnDate = params.TxnDate
+	}
+	return t.service.Confirm(ctx, realmID, params.DraftID, &bill)
+}
@@ -0,0 +1,120 @@
+// nlp/tool_overdue.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/pkg/qbclient/query"
+)
+
+// Querier runs QuickBooks queries for the current company
+type Querier interface {
+	Query(ctx context.Context, query string, result interface{}) error
+}
+
+// OverdueInvoice is an unpaid invoice past its due date
+type OverdueInvoice struct {
+	ID          string  `json:"id"`
+	DocNumber   string  `json:"doc_number,omitempty"`
+	Customer    string  `json:"customer"`
+	DueDate     string  `json:"due_date"`
+	DaysOverdue int     `json:"days_overdue"`
+	Balance     float64 `json:"balance"`
+}
+
+// OverdueInvoicesTool lets the agent list invoices past their due date
+type OverdueInvoicesTool struct {
+	querier Querier
+}
+
+// NewOverdueInvoicesTool creates a new overdue invoices tool
+func NewOverdueInvoicesTool(querier Querier) *OverdueInvoicesTool {
+	return &OverdueInvoicesTool{
+		querier: querier,
+	}
+}
+
+// Name returns the tool name
+func (t *OverdueInvoicesTool) Name() string {
+	return "list_overdue_invoices"
+}
+
+// Description describes the tool to the model
+func (t *OverdueInvoicesTool) Description() string {
+	return `List unpaid invoices past their due date, most overdue first. Args: {"min_days_overdue": number, "customer_id": string}; both optional`
+}
+
+// Mutating reports that listing invoices is read-only
+func (t *OverdueInvoicesTool) Mutating() bool {
+	return false
+}
+
+// Execute lists the current company's overdue invoices
+func (t *OverdueInvoicesTool) Execute(ctx context.Context, args json.RawMessage) (interface{}, error) {
+	var params struct {
+		MinDaysOverdue int    `json:"min_days_overdue"`
+		CustomerID     string `json:"customer_id"`
+	}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, fmt.Errorf("invalid overdue invoice arguments: %w", err)
+		}
+	}
+	if params.MinDaysOverdue < 0 {
+		return nil, fmt.Errorf("min_days_overdue must not be negative")
+	}
+
+	if _, err := auth.GetCompanyID(ctx); err != nil {
+		return nil, err
+	}
+
+	// Due dates are calendar dates in the company's time zone
+	now := timezone.Now(ctx)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	cutoff := today.AddDate(0, 0, -params.MinDaysOverdue)
+	q := query.Select("Invoice", "Id", "DocNumber", "DueDate", "Balance", "CustomerRef").
+		Where("Balance", ">", "0").
+		Where("DueDate", "<", cutoff.Format("2006-01-02"))
+	if params.CustomerID != "" {
+		q.Where("CustomerRef", "=", params.CustomerID)
+	}
+	invoices, err := query.All[struct {
+		ID          string  `json:"Id"`
+		DocNumber   string  `json:"DocNumber"`
+		DueDate     string  `json:"DueDate"`
+		Balance     float64 `json:"Balance"`
+		CustomerRef struct {
+			Name string `json:"name"`
+		} `json:"CustomerRef"`
+	}](ctx, t.querier, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch overdue invoices: %w", err)
+	}
+
+	overdue := []OverdueInvoice{}
+	for _, inv := range invoices {
+		due, err := time.Parse("2006-01-02", inv.DueDate)
+		if err != nil {
+			continue
+		}
+		overdue = append(overdue, OverdueInvoice{
+			ID:          inv.ID,
+			DocNumber:   inv.DocNumber,
+			Customer:    inv.CustomerRef.Name,
+			DueDate:     inv.DueDate,
+			DaysOverdue: int(today.Sub(due).Hours() / 24),
+			Balance:     inv.Balance,
+		})
+	}
+
+	sort.SliceStable(overdue, func(i, j int) bool {
+		return overdue[i].DaysOverdue > overdue[j].DaysOverdue
+	})
+	return overdue, nil
+}
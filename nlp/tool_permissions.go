@@ -0,0 +1,242 @@
+// nlp/tool_permissions.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+)
+
+// RolePolicy restricts which tools a role may use
+type RolePolicy struct {
+	AllowedTools []string `json:"allowed_tools,omitempty"` // empty allows all tools
+	DeniedTools  []string `json:"denied_tools,omitempty"`
+	ReadOnly     bool     `json:"read_only"`
+}
+
+// TenantToolPolicy holds a tenant's tool restrictions
+type TenantToolPolicy struct {
+	DeniedTools []string              `json:"denied_tools,omitempty"`
+	ReadOnly    bool                  `json:"read_only"`
+	Roles       map[string]RolePolicy `json:"roles,omitempty"`
+}
+
+// DefaultRolePolicies returns the built-in role restrictions
+func DefaultRolePolicies() map[string]RolePolicy {
+	return map[string]RolePolicy{
+		"viewer": {ReadOnly: true},
+	}
+}
+
+// ToolPolicyStore persists per-tenant tool policies
+type ToolPolicyStore interface {
+	SavePolicy(ctx context.Context, tenantID string, policy *TenantToolPolicy) error
+	GetPolicy(ctx context.Context, tenantID string) (*TenantToolPolicy, error)
+}
+
+// RedisToolPolicyStore implements ToolPolicyStore using Redis
+type RedisToolPolicyStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisToolPolicyStore creates a new Redis-backed tool policy store
+func NewRedisToolPolicyStore(client redis.UniversalClient, prefix string) *RedisToolPolicyStore {
+	return &RedisToolPolicyStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// key generates the Redis key for a tenant's tool policy
+func (s *RedisToolPolicyStore) key(tenantID string) string {
+	return fmt.Sprintf("%s:nlp:tool_policy:%s", s.prefix, tenantID)
+}
+
+// SavePolicy stores a tenant's tool policy
+func (s *RedisToolPolicyStore) SavePolicy(ctx context.Context, tenantID string, policy *TenantToolPolicy) error {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tool policy: %w", err)
+	}
+
+	if err := s.client.Set(ctx, s.key(tenantID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save tool policy: %w", err)
+	}
+
+	return nil
+}
+
+// GetPolicy retrieves a tenant's tool policy, returning an empty policy if none is set
+func (s *RedisToolPolicyStore) GetPolicy(ctx context.Context, tenantID string) (*TenantToolPolicy, error) {
+	data, err := s.client.Get(ctx, s.key(tenantID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return &TenantToolPolicy{}, nil
+		}
+		return nil, fmt.Errorf("failed to get tool policy: %w", err)
+	}
+
+	var policy TenantToolPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tool policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// ToolPermissions decides whether the caller may use a tool
+type ToolPermissions struct {
+	store        ToolPolicyStore
+	rolePolicies map[string]RolePolicy
+}
+
+// NewToolPermissions creates a permission checker with the given role defaults
+func NewToolPermissions(store ToolPolicyStore, rolePolicies map[string]RolePolicy) *ToolPermissions {
+	return &ToolPermissions{
+		store:        store,
+		rolePolicies: rolePolicies,
+	}
+}
+
+// toolPolicyKey is the context key of a loaded tenant tool policy
+type toolPolicyKey struct{}
+
+// loadedPolicy is a tenant's tool policy, loaded on first use for one
+// request
+type loadedPolicy struct {
+	once     sync.Once
+	tenantID string
+	policy   *TenantToolPolicy
+	err      error
+}
+
+// WithPolicy returns a context in which the caller's tenant tool policy is
+// loaded once, so every check made while serving one request or task shares
+// a single read
+func (p *ToolPermissions) WithPolicy(ctx context.Context) context.Context {
+	tenantID := auth.GetTenantID(ctx)
+	if loaded, ok := ctx.Value(toolPolicyKey{}).(*loadedPolicy); ok && loaded.tenantID == tenantID {
+		return ctx
+	}
+	return context.WithValue(ctx, toolPolicyKey{}, &loadedPolicy{tenantID: tenantID})
+}
+
+// policy returns the caller's tenant tool policy, reusing the one loaded
+// for the request if there is one
+func (p *ToolPermissions) policy(ctx context.Context) (*TenantToolPolicy, error) {
+	tenantID := auth.GetTenantID(ctx)
+	loaded, ok := ctx.Value(toolPolicyKey{}).(*loadedPolicy)
+	if !ok || loaded.tenantID != tenantID {
+		return p.store.GetPolicy(ctx, tenantID)
+	}
+
+	loaded.once.Do(func() {
+		loaded.policy, loaded.err = p.store.GetPolicy(ctx, tenantID)
+	})
+	return loaded.policy, loaded.err
+}
+
+// Check returns an error describing why the caller may not use the tool
+func (p *ToolPermissions) Check(ctx context.Context, tool Tool) error {
+	role := auth.GetRole(ctx)
+
+	policy, err := p.policy(ctx)
+	if err != nil {
+		// Fail closed for tools that change data
+		logging.FromContext(ctx).Warn("failed to load tool policy", "error", err)
+		if tool.Mutating() {
+			return fmt.Errorf("%s is unavailable while permissions cannot be verified", tool.Name())
+		}
+		policy = &TenantToolPolicy{}
+	}
+
+	if contains(policy.DeniedTools, tool.Name()) {
+		return fmt.Errorf("%s is disabled for this organization", tool.Name())
+	}
+	if policy.ReadOnly && tool.Mutating() {
+		return fmt.Errorf("%s changes data and this organization's agent is read-only", tool.Name())
+	}
+
+	// Tenant role overrides take precedence over the built-in defaults
+	rolePolicy, ok := policy.Roles[role]
+	if !ok {
+		rolePolicy = p.rolePolicies[role]
+	}
+
+	if len(rolePolicy.AllowedTools) > 0 && !contains(rolePolicy.AllowedTools, tool.Name()) {
+		return fmt.Errorf("%s is not permitted for the %s role", tool.Name(), role)
+	}
+	if contains(rolePolicy.DeniedTools, tool.Name()) {
+		return fmt.Errorf("%s is not permitted for the %s role", tool.Name(), role)
+	}
+	if rolePolicy.ReadOnly && tool.Mutating() {
+		return fmt.Errorf("%s changes data and the %s role is read-only", tool.Name(), role)
+	}
+
+	return nil
+}
+
+// contains reports whether list includes value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolPolicyHandler manages tenant tool policies
+type ToolPolicyHandler struct {
+	store ToolPolicyStore
+}
+
+// NewToolPolicyHandler creates a new tool policy handler
+func NewToolPolicyHandler(store ToolPolicyStore) *ToolPolicyHandler {
+	return &ToolPolicyHandler{
+		store: store,
+	}
+}
+
+// GetPolicy returns a tenant's tool policy
+func (h *ToolPolicyHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["tenantID"]
+
+	policy, err := h.store.GetPolicy(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "Failed to get tool policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// SavePolicy replaces a tenant's tool policy
+func (h *ToolPolicyHandler) SavePolicy(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["tenantID"]
+
+	var policy TenantToolPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SavePolicy(r.Context(), tenantID, &policy); err != nil {
+		http.Error(w, "Failed to save tool policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
@@ -0,0 +1,71 @@
+// nlp/tool_search.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/search"
+)
+
+// SearchTool lets the agent find entities by meaning rather than exact name
+type SearchTool struct {
+	service search.Servicer
+}
+
+// NewSearchTool creates a new semantic search tool
+func NewSearchTool(service search.Servicer) *SearchTool {
+	return &SearchTool{
+		service: service,
+	}
+}
+
+// Name returns the tool name
+func (t *SearchTool) Name() string {
+	return "search_entities"
+}
+
+// Description describes the tool to the model
+func (t *SearchTool) Description() string {
+	return `Semantic search over customers, items, and invoices. Args: {"query": string, "types": ["customer"|"item"|"invoice"], "from": "YYYY-MM-DD", "to": "YYYY-MM-DD"}`
+}
+
+// Mutating reports that search is read-only
+func (t *SearchTool) Mutating() bool {
+	return false
+}
+
+// Execute runs the search for the current company
+func (t *SearchTool) Execute(ctx context.Context, args json.RawMessage) (interface{}, error) {
+	var params struct {
+		Query string   `json:"query"`
+		Types []string `json:"types"`
+		From  string   `json:"from"`
+		To    string   `json:"to"`
+		Limit int      `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid search arguments: %w", err)
+	}
+	if params.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+
+	realmID, err := auth.GetCompanyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := search.Filter{EntityTypes: params.Types}
+	if t, err := time.Parse("2006-01-02", params.From); err == nil {
+		filter.From = &t
+	}
+	if t, err := time.Parse("2006-01-02", params.To); err == nil {
+		filter.To = &t
+	}
+
+	return t.service.Search(ctx, realmID, params.Query, params.Limit, filter)
+}
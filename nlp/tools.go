@@ -0,0 +1,154 @@
+// nlp/tools.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/eGGnogSC/qbserver/internal/i18n"
+	"github.com/eGGnogSC/qbserver/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tool is an action the agent can invoke on behalf of a user
+type Tool interface {
+	Name() string
+	Description() string
+	// Mutating reports whether the tool changes data in QuickBooks
+	Mutating() bool
+	Execute(ctx context.Context, args json.RawMessage) (interface{}, error)
+}
+
+// ToolDefinition describes a tool to the language model
+type ToolDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Mutating    bool   `json:"mutating"`
+}
+
+// ToolResult is the outcome of a tool invocation
+type ToolResult struct {
+	Tool    string      `json:"tool"`
+	Output  interface{} `json:"output,omitempty"`
+	Denied  bool        `json:"denied,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// ToolRegistry holds the agent's tools and enforces tool permissions
+type ToolRegistry struct {
+	tools       map[string]Tool
+	mu          sync.RWMutex
+	permissions *ToolPermissions
+}
+
+// NewToolRegistry creates a new tool registry
+func NewToolRegistry(permissions *ToolPermissions) *ToolRegistry {
+	return &ToolRegistry{
+		tools:       make(map[string]Tool),
+		permissions: permissions,
+	}
+}
+
+// Register adds a tool to the registry
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name()] = tool
+}
+
+// Get returns a registered tool by name
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// WithPolicy returns a context carrying the caller's tool policy, loaded
+// once for all the tool calls made with it
+func (r *ToolRegistry) WithPolicy(ctx context.Context) context.Context {
+	if r.permissions == nil {
+		return ctx
+	}
+	return r.permissions.WithPolicy(ctx)
+}
+
+// PolicyMiddleware loads the caller's tool policy at most once per request
+func (r *ToolRegistry) PolicyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(r.WithPolicy(req.Context())))
+	})
+}
+
+// Definitions returns the tools the current tenant and role may use,
+// so the model is never offered tools it cannot call
+func (r *ToolRegistry) Definitions(ctx context.Context) []ToolDefinition {
+	ctx = r.WithPolicy(ctx)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		if r.permissions != nil && r.permissions.Check(ctx, tool) != nil {
+			continue
+		}
+		definitions = append(definitions, ToolDefinition{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			Mutating:    tool.Mutating(),
+		})
+	}
+
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+
+	return definitions
+}
+
+// Execute runs a tool after checking the caller is permitted to use it.
+// A denied call is reported in the result rather than as an error so the
+// agent can relay it to the user. Each call is a span.
+func (r *ToolRegistry) Execute(ctx context.Context, name string, args json.RawMessage) (*ToolResult, error) {
+	ctx, span := tracing.Start(ctx, "nlp.tool "+name, trace.WithAttributes(attribute.String("nlp.tool", name)))
+	result, err := r.execute(ctx, name, args)
+	if result != nil {
+		span.SetAttributes(attribute.Bool("nlp.tool.denied", result.Denied))
+	}
+	tracing.End(span, err)
+	return result, err
+}
+
+// execute runs a tool if permitted
+func (r *ToolRegistry) execute(ctx context.Context, name string, args json.RawMessage) (*ToolResult, error) {
+	tool, ok := r.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+
+	if r.permissions != nil {
+		if err := r.permissions.Check(ctx, tool); err != nil {
+			return &ToolResult{
+				Tool:    name,
+				Denied:  true,
+				Message: i18n.T(ctx, "tool.denied", err),
+			}, nil
+		}
+	}
+
+	output, err := tool.Execute(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s failed: %w", name, err)
+	}
+
+	return &ToolResult{
+		Tool:   name,
+		Output: output,
+	}, nil
+}
@@ -0,0 +1,363 @@
+// nlp/transcripts.go
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+)
+
+// DefaultTranscriptRetentionDays applies to tenants without a retention setting
+const DefaultTranscriptRetentionDays = 90
+
+// TranscriptEntry is a single message in a user's agent conversation
+type TranscriptEntry struct {
+	ID        string    `json:"id"`
+	Role      string    `json:"role"` // "user" or "agent"
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TranscriptStore persists redacted agent conversations in Redis
+type TranscriptStore struct {
+	client   redis.UniversalClient
+	prefix   string
+	redactor *Redactor
+}
+
+// NewTranscriptStore creates a new transcript store
+func NewTranscriptStore(client redis.UniversalClient, prefix string, redactor *Redactor) *TranscriptStore {
+	return &TranscriptStore{
+		client:   client,
+		prefix:   prefix,
+		redactor: redactor,
+	}
+}
+
+// transcriptKey is the sorted set of a user's messages scored by time in
+// milliseconds, which a float64 score holds exactly. Messages of the same
+// millisecond sort by their JSON, which leads with the nanosecond ID.
+func (s *TranscriptStore) transcriptKey(tenantID, userID string) string {
+	return fmt.Sprintf("%s:nlp:transcript:%s:%s", s.prefix, tenantID, userID)
+}
+
+// usersKey is the set of users with transcripts in a tenant
+func (s *TranscriptStore) usersKey(tenantID string) string {
+	return fmt.Sprintf("%s:nlp:transcript_users:%s", s.prefix, tenantID)
+}
+
+// tenantsKey is the set of tenants with transcripts
+func (s *TranscriptStore) tenantsKey() string {
+	return fmt.Sprintf("%s:nlp:transcript_tenants", s.prefix)
+}
+
+// retentionKey stores a tenant's retention period in days
+func (s *TranscriptStore) retentionKey(tenantID string) string {
+	return fmt.Sprintf("%s:nlp:transcript_retention:%s", s.prefix, tenantID)
+}
+
+// Append redacts and stores a message
+func (s *TranscriptStore) Append(ctx context.Context, tenantID, userID, role, content string) error {
+	now := time.Now()
+	entry := TranscriptEntry{
+		ID:        strconv.FormatInt(now.UnixNano(), 36),
+		Role:      role,
+		Content:   s.redactor.Redact(content),
+		CreatedAt: now,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript entry: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, s.transcriptKey(tenantID, userID), &redis.Z{Score: float64(now.UnixMilli()), Member: data})
+	pipe.SAdd(ctx, s.usersKey(tenantID), userID)
+	pipe.SAdd(ctx, s.tenantsKey(), tenantID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save transcript entry: %w", err)
+	}
+
+	return nil
+}
+
+// History returns a user's messages in chronological order
+func (s *TranscriptStore) History(ctx context.Context, tenantID, userID string) ([]TranscriptEntry, error) {
+	values, err := s.client.ZRange(ctx, s.transcriptKey(tenantID, userID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcript: %w", err)
+	}
+
+	entries := make([]TranscriptEntry, 0, len(values))
+	for _, v := range values {
+		var entry TranscriptEntry
+		if err := json.Unmarshal([]byte(v), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Delete removes a user's entire transcript
+func (s *TranscriptStore) Delete(ctx context.Context, tenantID, userID string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, s.transcriptKey(tenantID, userID))
+	pipe.SRem(ctx, s.usersKey(tenantID), userID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete transcript: %w", err)
+	}
+	return nil
+}
+
+// SetRetention sets the number of days a tenant's transcripts are kept
+func (s *TranscriptStore) SetRetention(ctx context.Context, tenantID string, days int) error {
+	if err := s.client.Set(ctx, s.retentionKey(tenantID), days, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save retention: %w", err)
+	}
+	return nil
+}
+
+// GetRetention returns a tenant's retention period in days
+func (s *TranscriptStore) GetRetention(ctx context.Context, tenantID string) (int, error) {
+	days, err := s.client.Get(ctx, s.retentionKey(tenantID)).Int()
+	if err != nil {
+		if err == redis.Nil {
+			return DefaultTranscriptRetentionDays, nil
+		}
+		return 0, fmt.Errorf("failed to get retention: %w", err)
+	}
+	return days, nil
+}
+
+// Purge removes transcript entries older than each tenant's retention period
+func (s *TranscriptStore) Purge(ctx context.Context) error {
+	tenants, err := s.client.SMembers(ctx, s.tenantsKey()).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list transcript tenants: %w", err)
+	}
+
+	for _, tenantID := range tenants {
+		days, err := s.GetRetention(ctx, tenantID)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to get transcript retention", "tenant_id", tenantID, "error", err)
+			continue
+		}
+		cutoff := time.Now().AddDate(0, 0, -days).UnixMilli()
+
+		users, err := s.client.SMembers(ctx, s.usersKey(tenantID)).Result()
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to list transcript users", "tenant_id", tenantID, "error", err)
+			continue
+		}
+
+		for _, userID := range users {
+			key := s.transcriptKey(tenantID, userID)
+			if err := s.client.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(cutoff, 10)).Err(); err != nil {
+				logging.FromContext(ctx).Warn("failed to purge transcript", "tenant_id", tenantID, "user_id", userID, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// StartRetentionRoutine purges expired transcripts in the background
+func (s *TranscriptStore) StartRetentionRoutine(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Purge(ctx); err != nil {
+					logging.FromContext(ctx).Error("transcript purge failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// responseRecorder captures the response body for transcript recording
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body as it is written
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// TranscriptMiddleware records agent requests and responses
+func (s *TranscriptStore) TranscriptMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status >= 400 {
+			return
+		}
+
+		tenantID := auth.GetTenantID(r.Context())
+		userID := auth.GetUserID(r.Context())
+		if err := s.Append(r.Context(), tenantID, userID, "user", extractMessage(body)); err != nil {
+			logging.FromContext(r.Context()).Warn("failed to record transcript", "error", err)
+			return
+		}
+		if err := s.Append(r.Context(), tenantID, userID, "agent", extractMessage(recorder.body.Bytes())); err != nil {
+			logging.FromContext(r.Context()).Warn("failed to record transcript", "error", err)
+		}
+	})
+}
+
+// extractMessage pulls the human-readable text out of an agent request or response body
+func extractMessage(body []byte) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err == nil {
+		for _, key := range []string{"query", "command", "message", "response", "text"} {
+			if v, ok := fields[key].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// TranscriptHandler provides transcript export and retention endpoints
+type TranscriptHandler struct {
+	store *TranscriptStore
+}
+
+// NewTranscriptHandler creates a new transcript handler
+func NewTranscriptHandler(store *TranscriptStore) *TranscriptHandler {
+	return &TranscriptHandler{
+		store: store,
+	}
+}
+
+// ExportTranscript returns the caller's conversation as JSON or markdown
+func (h *TranscriptHandler) ExportTranscript(w http.ResponseWriter, r *http.Request) {
+	tenantID := auth.GetTenantID(r.Context())
+	userID := auth.GetUserID(r.Context())
+
+	entries, err := h.store.History(r.Context(), tenantID, userID)
+	if err != nil {
+		http.Error(w, "Failed to export transcript: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "markdown", "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="agent-transcript.md"`)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "# Agent conversation for %s\n\n", userID)
+		for _, entry := range entries {
+			fmt.Fprintf(w, "**%s** (%s)\n\n%s\n\n", entry.Role, entry.CreatedAt.Format(time.RFC3339), entry.Content)
+		}
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="agent-transcript.json"`)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"user_id":  userID,
+			"messages": entries,
+		})
+	default:
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+	}
+}
+
+// DeleteTranscript erases the caller's conversation history
+func (h *TranscriptHandler) DeleteTranscript(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Delete(r.Context(), auth.GetTenantID(r.Context()), auth.GetUserID(r.Context())); err != nil {
+		http.Error(w, "Failed to delete transcript: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRetention returns a tenant's transcript retention period
+func (h *TranscriptHandler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["tenantID"]
+
+	days, err := h.store.GetRetention(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "Failed to get retention: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id":      tenantID,
+		"retention_days": days,
+	})
+}
+
+// SetRetention updates a tenant's transcript retention period
+func (h *TranscriptHandler) SetRetention(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["tenantID"]
+
+	var req struct {
+		RetentionDays int `json:"retention_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RetentionDays < 1 {
+		http.Error(w, "retention_days must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SetRetention(r.Context(), tenantID, req.RetentionDays); err != nil {
+		http.Error(w, "Failed to save retention: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id":      tenantID,
+		"retention_days": req.RetentionDays,
+	})
+}
+
+// RecordTranscript wraps an agent endpoint so its conversation is recorded
+func (h *TranscriptHandler) RecordTranscript(next http.HandlerFunc) http.Handler {
+	return h.store.TranscriptMiddleware(next)
+}
@@ -0,0 +1,348 @@
+// nlp/usage.go
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/logging"
+	"github.com/eGGnogSC/qbserver/internal/metering"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrBudgetExceeded is returned when a tenant has hit its hard daily budget
+var ErrBudgetExceeded = errors.New("daily agent budget exceeded")
+
+// ModelPricing holds USD prices per thousand tokens for a model
+type ModelPricing struct {
+	PromptPer1K     float64
+	CompletionPer1K float64
+}
+
+// UsageConfig holds pricing and budget settings for agent usage tracking
+type UsageConfig struct {
+	Pricing        map[string]ModelPricing
+	DefaultPricing ModelPricing
+	SoftDailyLimit float64 // USD; 0 disables the warning
+	HardDailyLimit float64 // USD; 0 disables the cutoff
+	Retention      time.Duration
+}
+
+// DefaultUsageConfig returns usage settings with conservative defaults
+func DefaultUsageConfig() UsageConfig {
+	return UsageConfig{
+		Pricing:        map[string]ModelPricing{},
+		DefaultPricing: ModelPricing{PromptPer1K: 0.003, CompletionPer1K: 0.015},
+		Retention:      90 * 24 * time.Hour,
+	}
+}
+
+// DailyUsage is the aggregated agent usage for a tenant on a single day
+type DailyUsage struct {
+	TenantID         string  `json:"tenant_id"`
+	Date             string  `json:"date"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// UsageStore persists per-tenant daily usage aggregates
+type UsageStore interface {
+	AddUsage(ctx context.Context, tenantID, date string, usage TokenUsage, cost float64) (*DailyUsage, error)
+	GetUsage(ctx context.Context, tenantID, date string) (*DailyUsage, error)
+}
+
+// RedisUsageStore implements UsageStore using Redis hashes
+type RedisUsageStore struct {
+	client    redis.UniversalClient
+	prefix    string
+	retention time.Duration
+}
+
+// NewRedisUsageStore creates a new Redis-backed usage store
+func NewRedisUsageStore(client redis.UniversalClient, prefix string, retention time.Duration) *RedisUsageStore {
+	return &RedisUsageStore{
+		client:    client,
+		prefix:    prefix,
+		retention: retention,
+	}
+}
+
+// key generates the Redis key for a tenant's usage on a given day
+func (s *RedisUsageStore) key(tenantID, date string) string {
+	return fmt.Sprintf("%s:nlp:usage:%s:%s", s.prefix, tenantID, date)
+}
+
+// AddUsage increments the tenant's aggregate for the day
+func (s *RedisUsageStore) AddUsage(ctx context.Context, tenantID, date string, usage TokenUsage, cost float64) (*DailyUsage, error) {
+	key := s.key(tenantID, date)
+
+	pipe := s.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "requests", 1)
+	pipe.HIncrBy(ctx, key, "prompt_tokens", int64(usage.PromptTokens))
+	pipe.HIncrBy(ctx, key, "completion_tokens", int64(usage.CompletionTokens))
+	pipe.HIncrByFloat(ctx, key, "cost", cost)
+	if s.retention > 0 {
+		pipe.Expire(ctx, key, s.retention)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to record usage: %w", err)
+	}
+
+	return s.GetUsage(ctx, tenantID, date)
+}
+
+// GetUsage retrieves the tenant's aggregate for the day
+func (s *RedisUsageStore) GetUsage(ctx context.Context, tenantID, date string) (*DailyUsage, error) {
+	values, err := s.client.HGetAll(ctx, s.key(tenantID, date)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	usage := &DailyUsage{TenantID: tenantID, Date: date}
+	usage.Requests, _ = strconv.ParseInt(values["requests"], 10, 64)
+	usage.PromptTokens, _ = strconv.ParseInt(values["prompt_tokens"], 10, 64)
+	usage.CompletionTokens, _ = strconv.ParseInt(values["completion_tokens"], 10, 64)
+	usage.Cost, _ = strconv.ParseFloat(values["cost"], 64)
+
+	return usage, nil
+}
+
+// UsageTracker records agent token usage and enforces tenant budgets
+type UsageTracker struct {
+	store  UsageStore
+	config UsageConfig
+	meter  *metering.Meter
+}
+
+// NewUsageTracker creates a new usage tracker. Tokens also count toward
+// the agent token quota of the tenant's plan unless meter is nil.
+func NewUsageTracker(store UsageStore, config UsageConfig, meter *metering.Meter) *UsageTracker {
+	return &UsageTracker{
+		store:  store,
+		config: config,
+		meter:  meter,
+	}
+}
+
+// today returns the aggregation bucket for the current day
+func today() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// EstimateCost calculates the USD cost of a completion
+func (t *UsageTracker) EstimateCost(usage TokenUsage) float64 {
+	pricing, ok := t.config.Pricing[usage.Model]
+	if !ok {
+		pricing = t.config.DefaultPricing
+	}
+
+	return float64(usage.PromptTokens)/1000*pricing.PromptPer1K +
+		float64(usage.CompletionTokens)/1000*pricing.CompletionPer1K
+}
+
+// CheckBudget returns ErrBudgetExceeded if the tenant is past its hard
+// limit, or metering.ErrQuotaExceeded once its plan's tokens are used up
+func (t *UsageTracker) CheckBudget(ctx context.Context, tenantID string) error {
+	if t.meter != nil {
+		if err := t.meter.Check(ctx, tenantID, metering.AgentTokens); err != nil {
+			return err
+		}
+	}
+	if t.config.HardDailyLimit <= 0 {
+		return nil
+	}
+
+	usage, err := t.store.GetUsage(ctx, tenantID, today())
+	if err != nil {
+		return err
+	}
+
+	if usage.Cost >= t.config.HardDailyLimit {
+		return ErrBudgetExceeded
+	}
+
+	return nil
+}
+
+// Record adds a completion's usage to the tenant's daily aggregate
+func (t *UsageTracker) Record(ctx context.Context, tenantID string, usage TokenUsage) (*DailyUsage, error) {
+	daily, err := t.store.AddUsage(ctx, tenantID, today(), usage, t.EstimateCost(usage))
+	if err != nil {
+		return nil, err
+	}
+	if t.meter != nil {
+		if err := t.meter.Add(ctx, tenantID, metering.AgentTokens, int64(usage.PromptTokens+usage.CompletionTokens)); err != nil {
+			logging.FromContext(ctx).Warn("failed to meter agent tokens", "tenant_id", tenantID, "error", err)
+		}
+	}
+
+	if t.overSoftLimit(daily) {
+		logging.FromContext(ctx).Warn("tenant is over its soft agent budget",
+			"tenant_id", tenantID, "cost", daily.Cost, "soft_limit", t.config.SoftDailyLimit)
+	}
+
+	return daily, nil
+}
+
+// overSoftLimit reports whether the aggregate has crossed the soft limit
+func (t *UsageTracker) overSoftLimit(daily *DailyUsage) bool {
+	return t.config.SoftDailyLimit > 0 && daily.Cost >= t.config.SoftDailyLimit
+}
+
+// BudgetMiddleware rejects agent requests made outside a tenant or once the
+// tenant's hard budget is spent, and flags requests made while over the soft
+// budget
+func (t *UsageTracker) BudgetMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, err := auth.GetVerifiedTenantID(r.Context())
+		if err != nil {
+			http.Error(w, "The agent requires a tenant API key or tenant membership", http.StatusForbidden)
+			return
+		}
+
+		if err := t.CheckBudget(r.Context(), tenantID); err != nil {
+			if errors.Is(err, ErrBudgetExceeded) {
+				http.Error(w, "Agent budget exceeded for today", http.StatusTooManyRequests)
+				return
+			}
+			if errors.Is(err, metering.ErrQuotaExceeded) {
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			}
+			// Budget lookups should not take the agent down
+			logging.FromContext(r.Context()).Warn("failed to check agent budget", "error", err)
+		}
+
+		if t.config.SoftDailyLimit > 0 {
+			if usage, err := t.store.GetUsage(r.Context(), tenantID, today()); err == nil && t.overSoftLimit(usage) {
+				w.Header().Set("X-Agent-Budget-Warning", "soft daily budget exceeded")
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MeteredProvider wraps an LLMProvider with budget checks and usage recording
+type MeteredProvider struct {
+	provider LLMProvider
+	tracker  *UsageTracker
+}
+
+// NewMeteredProvider creates a provider that tracks usage per tenant
+func NewMeteredProvider(provider LLMProvider, tracker *UsageTracker) *MeteredProvider {
+	return &MeteredProvider{
+		provider: provider,
+		tracker:  tracker,
+	}
+}
+
+// Complete enforces the tenant budget and records the completion's usage
+func (p *MeteredProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	tenantID, err := auth.GetVerifiedTenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.tracker.CheckBudget(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Usage.Model == "" {
+		resp.Usage.Model = req.Model
+	}
+
+	if _, err := p.tracker.Record(ctx, tenantID, resp.Usage); err != nil {
+		logging.FromContext(ctx).Warn("failed to record agent usage", "error", err)
+	}
+
+	return resp, nil
+}
+
+// UsageHandler exposes agent usage to operators
+type UsageHandler struct {
+	tracker *UsageTracker
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(tracker *UsageTracker) *UsageHandler {
+	return &UsageHandler{
+		tracker: tracker,
+	}
+}
+
+// GetUsage returns daily usage for a tenant over a date range
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tenantID := query.Get("tenant_id")
+	if tenantID == "" {
+		http.Error(w, "tenant_id is required", http.StatusBadRequest)
+		return
+	}
+
+	// Default to the last 30 days
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -29)
+
+	if v := query.Get("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if v := query.Get("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) || to.Sub(from) > 366*24*time.Hour {
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+
+	days := []DailyUsage{}
+	var totalCost float64
+	var totalTokens int64
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		usage, err := h.tracker.store.GetUsage(r.Context(), tenantID, d.Format("2006-01-02"))
+		if err != nil {
+			http.Error(w, "Failed to get usage: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if usage.Requests == 0 {
+			continue
+		}
+		days = append(days, *usage)
+		totalCost += usage.Cost
+		totalTokens += usage.PromptTokens + usage.CompletionTokens
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id":        tenantID,
+		"days":             days,
+		"total_tokens":     totalTokens,
+		"total_cost":       totalCost,
+		"soft_daily_limit": h.tracker.config.SoftDailyLimit,
+		"hard_daily_limit": h.tracker.config.HardDailyLimit,
+	})
+}
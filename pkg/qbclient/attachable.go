@@ -0,0 +1,111 @@
+// qbclient/attachable.go
+package qbclient
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "mime/multipart"
+    "net/textproto"
+)
+
+// EntityRef references a QuickBooks entity by type and ID
+type EntityRef struct {
+    Type  string `json:"type"` // e.g. "Invoice"
+    Value string `json:"value"`
+}
+
+// AttachableRef links an attachment to an entity
+type AttachableRef struct {
+    EntityRef EntityRef `json:"EntityRef"`
+    // IncludeOnSend attaches the file to the entity's emails, e.g. when an
+    // invoice is sent to the customer
+    IncludeOnSend bool `json:"IncludeOnSend"`
+}
+
+// Attachable is a file attached to QuickBooks entities
+type Attachable struct {
+    ID              string          `json:"Id,omitempty"`
+    SyncToken       string          `json:"SyncToken,omitempty"`
+    FileName        string          `json:"FileName"`
+    ContentType     string          `json:"ContentType"`
+    Size            float64         `json:"Size,omitempty"`
+    Note            string          `json:"Note,omitempty"`
+    TempDownloadURI string          `json:"TempDownloadUri,omitempty"`
+    AttachableRef   []AttachableRef `json:"AttachableRef,omitempty"`
+}
+
+// Upload uploads a file and attaches it to the referenced entities
+func (c *Client) Upload(ctx context.Context, attachable *Attachable, content []byte) (*Attachable, error) {
+    if err := c.dryRun(ctx, "upload", "Attachable", attachable); err != nil {
+        return nil, err
+    }
+    
+    endpoint, err := c.companyEndpoint(ctx, "upload")
+    if err != nil {
+        return nil, err
+    }
+    metadata, err := json.Marshal(attachable)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal attachable: %w", err)
+    }
+    
+    // QuickBooks pairs each file_content_NN part with file_metadata_NN
+    var body bytes.Buffer
+    form := multipart.NewWriter(&body)
+    part, err := form.CreatePart(textproto.MIMEHeader{
+        "Content-Disposition": {`form-data; name="file_metadata_01"; filename="attachment.json"`},
+        "Content-Type":        {"application/json"},
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to build upload: %w", err)
+    }
+    part.Write(metadata)
+    part, err = form.CreatePart(textproto.MIMEHeader{
+        "Content-Disposition": {fmt.Sprintf(`form-data; name="file_content_01"; filename=%q`, attachable.FileName)},
+        "Content-Type":        {attachable.ContentType},
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to build upload: %w", err)
+    }
+    part.Write(content)
+    if err := form.Close(); err != nil {
+        return nil, fmt.Errorf("failed to build upload: %w", err)
+    }
+    
+    resp, err := c.sendRequestAs(ctx, "POST", endpoint, form.FormDataContentType(), "application/json", body.Bytes())
+    if err != nil {
+        return nil, fmt.Errorf("failed to upload %s: %w", attachable.FileName, err)
+    }
+    defer resp.Body.Close()
+    
+    var result struct {
+        AttachableResponse []struct {
+            Attachable *Attachable `json:"Attachable"`
+            Fault      *struct {
+                Error []struct {
+                    Message string `json:"Message"`
+                    Detail  string `json:"Detail"`
+                    Code    string `json:"code"`
+                } `json:"Error"`
+            } `json:"Fault"`
+        } `json:"AttachableResponse"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return nil, fmt.Errorf("failed to parse upload response: %w", err)
+    }
+    if len(result.AttachableResponse) == 0 {
+        return nil, fmt.Errorf("failed to upload %s: empty response", attachable.FileName)
+    }
+    uploaded := result.AttachableResponse[0]
+    if uploaded.Fault != nil && len(uploaded.Fault.Error) > 0 {
+        fault := uploaded.Fault.Error[0]
+        return nil, fmt.Errorf("failed to upload %s: QuickBooks API error (%s): %s", attachable.FileName, fault.Code, fault.Message)
+    }
+    if uploaded.Attachable == nil {
+        return nil, fmt.Errorf("failed to upload %s: no attachable returned", attachable.FileName)
+    }
+    
+    return uploaded.Attachable, nil
+}
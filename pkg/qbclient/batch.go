@@ -0,0 +1,86 @@
+// qbclient/batch.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+)
+
+// MaxBatchSize is the QuickBooks limit on operations per batch request
+const MaxBatchSize = 30
+
+// BatchItem is one operation in a batch request
+type BatchItem struct {
+    ID        string      // bId, echoed in the response
+    Operation string      // create, update or delete
+    Entity    string      // e.g. "Customer"
+    Payload   interface{}
+}
+
+// BatchError is a fault reported for a batch operation
+type BatchError struct {
+    Message string `json:"Message"`
+    Detail  string `json:"Detail"`
+    Code    string `json:"code"`
+}
+
+// BatchResult is the outcome of one batch operation
+type BatchResult struct {
+    ID     string          // bId of the request item
+    Entity json.RawMessage // created or updated entity, when successful
+    Errors []BatchError    // faults, when the operation failed
+}
+
+// Batch submits up to MaxBatchSize operations in one request. Operations
+// succeed or fail independently; results are keyed by item ID.
+func (c *Client) Batch(ctx context.Context, items []BatchItem) (map[string]BatchResult, error) {
+    if len(items) > MaxBatchSize {
+        return nil, fmt.Errorf("batch of %d exceeds the limit of %d operations", len(items), MaxBatchSize)
+    }
+    
+    requests := make([]map[string]interface{}, 0, len(items))
+    for _, item := range items {
+        requests = append(requests, map[string]interface{}{
+            "bId":       item.ID,
+            "operation": item.Operation,
+            item.Entity: item.Payload,
+        })
+    }
+    
+    batch := map[string]interface{}{"BatchItemRequest": requests}
+    if err := c.dryRun(ctx, "batch", "Batch", batch); err != nil {
+        return nil, err
+    }
+    
+    var response struct {
+        BatchItemResponse []map[string]json.RawMessage `json:"BatchItemResponse"`
+    }
+    if err := c.post(ctx, "batch", batch, &response); err != nil {
+        return nil, fmt.Errorf("batch request failed: %w", err)
+    }
+    
+    results := make(map[string]BatchResult, len(response.BatchItemResponse))
+    for _, raw := range response.BatchItemResponse {
+        var result BatchResult
+        json.Unmarshal(raw["bId"], &result.ID)
+        for key, value := range raw {
+            switch key {
+            case "bId", "time":
+            case "Fault":
+                var fault struct {
+                    Error []BatchError `json:"Error"`
+                }
+                if err := json.Unmarshal(value, &fault); err != nil {
+                    return nil, fmt.Errorf("failed to parse batch fault: %w", err)
+                }
+                result.Errors = fault.Error
+            default:
+                result.Entity = value
+            }
+        }
+        results[result.ID] = result
+    }
+    
+    return results, nil
+}
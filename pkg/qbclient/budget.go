@@ -0,0 +1,199 @@
+// qbclient/budget.go
+package qbclient
+
+import (
+    "context"
+    "math"
+    "sync"
+    "time"
+)
+
+// Request priorities
+const (
+    // PriorityInteractive is for requests a user is waiting on
+    PriorityInteractive = "interactive"
+    // PriorityBackground is for jobs, syncs and backfills, which yield the
+    // company's budget to interactive requests
+    PriorityBackground = "background"
+)
+
+const (
+    // defaultLatencyTarget is the interactive latency background requests
+    // are throttled to keep
+    defaultLatencyTarget = 2 * time.Second
+    // latencyWindow is how long an interactive latency sample counts;
+    // without interactive traffic background requests get the full budget
+    latencyWindow = 30 * time.Second
+    // latencySmoothing is the weight of each new latency sample
+    latencySmoothing = 0.2
+    // minBackgroundShare keeps background requests moving however slow
+    // interactive ones are
+    minBackgroundShare = 0.1
+    // backgroundPoll is how often a background request waiting for a slot
+    // or for interactive requests checks again
+    backgroundPoll = 50 * time.Millisecond
+    // maxBackgroundPoll bounds how long a throttled background request
+    // sleeps before checking the budget again
+    maxBackgroundPoll = time.Second
+    // minBackgroundPoll keeps throttled requests from spinning
+    minBackgroundPoll = 10 * time.Millisecond
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose QuickBooks requests count as
+// interactive or background against the company's budget
+func WithPriority(ctx context.Context, priority string) context.Context {
+    return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// Priority returns the priority of requests under ctx, interactive unless
+// marked otherwise
+func Priority(ctx context.Context) string {
+    if priority, ok := ctx.Value(priorityKey{}).(string); ok && priority != "" {
+        return priority
+    }
+    return PriorityInteractive
+}
+
+// observe adds an interactive request's latency to the moving average.
+// The limiter's mu must be held.
+func (b *bucket) observe(latency time.Duration) {
+    now := time.Now()
+    if b.latency == 0 || now.Sub(b.lastInteractive) > latencyWindow {
+        b.latency = latency
+    } else {
+        b.latency = time.Duration(float64(b.latency)*(1-latencySmoothing) + float64(latency)*latencySmoothing)
+    }
+    b.lastInteractive = now
+}
+
+// backgroundShare returns the share of the budget background requests get:
+// all of it while interactive requests meet the latency target, shrinking
+// in proportion as they get slower. The limiter's mu must be held.
+func (l *RateLimiter) backgroundShare(b *bucket, now time.Time) float64 {
+    if b.latency <= l.limit.LatencyTarget || now.Sub(b.lastInteractive) > latencyWindow {
+        return 1
+    }
+    return math.Max(minBackgroundShare, float64(l.limit.LatencyTarget)/float64(b.latency))
+}
+
+// backgroundThreshold returns the tokens a bucket must hold for a
+// background request to take one. The reserve grows towards the whole
+// burst as the background share shrinks.
+func (l *RateLimiter) backgroundThreshold(share float64) float64 {
+    reserve := float64(l.limit.BackgroundReserve)
+    if spare := float64(l.limit.Burst-1) - reserve; spare > 0 {
+        reserve += spare * (1 - share)
+    }
+    return 1 + reserve
+}
+
+// backgroundSlots returns how many requests in flight may be background
+// ones, always leaving a slot for interactive requests; zero is unbounded
+func (l *RateLimiter) backgroundSlots(share float64) int {
+    if l.limit.MaxConcurrent <= 0 {
+        return 0
+    }
+    if l.limit.MaxConcurrent == 1 {
+        return 1
+    }
+    slots := int(float64(l.limit.MaxConcurrent-1) * share)
+    if slots < 1 {
+        slots = 1
+    }
+    return slots
+}
+
+// acquireBackground waits until a background request may go: no
+// interactive request is queued, the bucket holds more than the reserve,
+// fewer background requests than their share of the slots are in flight,
+// and while throttled, the previous one was at least its share of the
+// rate ago. Background requests are never failed with ErrRateLimited; they
+// wait as long as their context allows.
+func (l *RateLimiter) acquireBackground(ctx context.Context, realmID string) (func(), error) {
+    started := time.Now()
+    var b *bucket
+    waiting := false
+    for {
+        now := time.Now()
+        l.mu.Lock()
+        b = l.bucket(realmID, now)
+        l.refill(b, now)
+        share := l.backgroundShare(b, now)
+        threshold := l.backgroundThreshold(share)
+        slots := l.backgroundSlots(share)
+        paced := now.Before(b.nextBackground)
+        if b.interactiveQueued == 0 && b.tokens >= threshold && !paced && (slots == 0 || b.backgroundInFlight < slots) {
+            b.tokens--
+            b.backgroundInFlight++
+            b.inFlight++
+            b.nextBackground = time.Time{}
+            if share < 1 {
+                b.nextBackground = now.Add(time.Duration(float64(time.Second) / (l.rate * share)))
+            }
+            if waiting {
+                b.queued--
+            }
+            l.mu.Unlock()
+            break
+        }
+    
+        // Wait for the budget or the pace; slots and interactive requests
+        // are polled for
+        delay := backgroundPoll
+        if b.tokens < threshold {
+            delay = time.Duration((threshold - b.tokens) / l.rate * float64(time.Second))
+        }
+        if paced && b.nextBackground.Sub(now) > delay {
+            delay = b.nextBackground.Sub(now)
+        }
+        delay = time.Duration(math.Max(float64(minBackgroundPoll), math.Min(float64(maxBackgroundPoll), float64(delay))))
+        if !waiting {
+            waiting = true
+            b.queued++
+            b.throttled++
+        }
+        l.mu.Unlock()
+    
+        if err := wait(ctx, delay); err != nil {
+            l.mu.Lock()
+            b.queued--
+            l.mu.Unlock()
+            return nil, err
+        }
+    }
+    
+    // finish gives back the background slot, and the shared one if held
+    finish := func(slot bool) {
+        l.mu.Lock()
+        b.backgroundInFlight--
+        b.inFlight--
+        l.mu.Unlock()
+        if slot {
+            <-b.slots
+        }
+    }
+    if b.slots != nil {
+        select {
+        case b.slots <- struct{}{}:
+        case <-ctx.Done():
+            finish(false)
+            return nil, ctx.Err()
+        }
+    }
+    
+    if waited := time.Since(started); waited >= time.Millisecond {
+        l.mu.Lock()
+        b.waited++
+        l.waitTime += waited
+        l.mu.Unlock()
+    }
+    
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            finish(b.slots != nil)
+        })
+    }, nil
+}
@@ -0,0 +1,135 @@
+// qbclient/cdc.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/url"
+    "sort"
+    "strings"
+    "time"
+)
+
+// CDCMaxAge is how far back QuickBooks reports changes
+const CDCMaxAge = 30 * 24 * time.Hour
+
+// CDCMaxEntities is the most changed entities one CDC response holds;
+// fuller responses are truncated and the caller must narrow the window
+const CDCMaxEntities = 1000
+
+var (
+    // ErrCDCNoEntities is returned for CDC requests naming no entity types
+    ErrCDCNoEntities = errors.New("at least one entity type is required")
+    // ErrCDCTooOld is returned for CDC requests reaching back further than
+    // QuickBooks keeps changes
+    ErrCDCTooOld = errors.New("changes are only available for the last 30 days")
+)
+
+// ChangedEntity is one entity changed since the CDC cutoff. Deleted
+// entities only carry their ID and when they were deleted.
+type ChangedEntity struct {
+    Entity      string          `json:"entity"` // e.g. "Invoice"
+    ID          string          `json:"id"`
+    LastUpdated time.Time       `json:"last_updated"`
+    Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// ChangeSet is the result of a change data capture request
+type ChangeSet struct {
+    // Time is QuickBooks' time of the response, the cutoff of the next
+    // incremental sync
+    Time    time.Time       `json:"time"`
+    Changed []ChangedEntity `json:"changed"`
+    Deleted []ChangedEntity `json:"deleted"`
+    // Truncated is set when QuickBooks returned as many entities as one
+    // response holds, so more changes may be missing
+    Truncated bool `json:"truncated,omitempty"`
+}
+
+// cdcEntity holds the fields of a changed entity CDC decoding relies on
+type cdcEntity struct {
+    ID       string `json:"Id"`
+    Status   string `json:"status"`
+    MetaData struct {
+        LastUpdatedTime time.Time `json:"LastUpdatedTime"`
+    } `json:"MetaData"`
+}
+
+// ChangeDataCapture returns the entities of the given types created,
+// updated or deleted in the current company since the given time, which
+// must be within the last 30 days
+func (c *Client) ChangeDataCapture(ctx context.Context, entities []string, since time.Time) (*ChangeSet, error) {
+    if len(entities) == 0 {
+        return nil, ErrCDCNoEntities
+    }
+    if time.Since(since) > CDCMaxAge {
+        return nil, ErrCDCTooOld
+    }
+    
+    endpoint, err := c.companyEndpoint(ctx, "cdc")
+    if err != nil {
+        return nil, err
+    }
+    params := url.Values{}
+    params.Set("entities", strings.Join(entities, ","))
+    params.Set("changedSince", since.Format(time.RFC3339))
+    endpoint += "?" + params.Encode()
+    
+    resp, err := c.sendRequest(ctx, "GET", endpoint, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    
+    var envelope struct {
+        CDCResponse []struct {
+            QueryResponse []map[string]json.RawMessage `json:"QueryResponse"`
+        } `json:"CDCResponse"`
+        Time time.Time `json:"time"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+        return nil, fmt.Errorf("failed to parse CDC response: %w", err)
+    }
+    
+    set := &ChangeSet{Time: envelope.Time, Changed: []ChangedEntity{}, Deleted: []ChangedEntity{}}
+    total := 0
+    for _, cdc := range envelope.CDCResponse {
+        for _, query := range cdc.QueryResponse {
+            for entity, raw := range query {
+                if entity == "startPosition" || entity == "maxResults" || entity == "totalCount" {
+                    continue
+                }
+                var items []json.RawMessage
+                if err := json.Unmarshal(raw, &items); err != nil {
+                    return nil, fmt.Errorf("failed to decode changed %s: %w", entity, err)
+                }
+                for _, item := range items {
+                    var fields cdcEntity
+                    if err := json.Unmarshal(item, &fields); err != nil {
+                        return nil, fmt.Errorf("failed to decode changed %s: %w", entity, err)
+                    }
+                    change := ChangedEntity{Entity: entity, ID: fields.ID, LastUpdated: fields.MetaData.LastUpdatedTime}
+                    if fields.Status == "Deleted" {
+                        set.Deleted = append(set.Deleted, change)
+                    } else {
+                        change.Data = item
+                        set.Changed = append(set.Changed, change)
+                    }
+                    total++
+                }
+            }
+        }
+    }
+    set.Truncated = total >= CDCMaxEntities
+    byTime := func(list []ChangedEntity) func(i, j int) bool {
+        return func(i, j int) bool {
+            return list[i].LastUpdated.Before(list[j].LastUpdated)
+        }
+    }
+    sort.SliceStable(set.Changed, byTime(set.Changed))
+    sort.SliceStable(set.Deleted, byTime(set.Deleted))
+    
+    return set, nil
+}
@@ -2,9 +2,12 @@
 package qbclient
 
 import (
+    "bytes"
     "context"
     "encoding/json"
+    "errors"
     "fmt"
+    "io"
     "io/ioutil"
     "net/http"
     "net/url"
@@ -12,8 +15,16 @@ import (
     "time"
     
     "github.com/eGGnogSC/qbserver/auth"
+    "github.com/eGGnogSC/qbserver/internal/logging"
+    "github.com/eGGnogSC/qbserver/internal/tracing"
+    "go.opentelemetry.io/otel/attribute"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+    "go.opentelemetry.io/otel/trace"
 )
 
+// MinorVersion is the QuickBooks API minor version requests are made with
+const MinorVersion = "75"
+
 // Client is the main QuickBooks API client
 type Client struct {
     baseURL      string
@@ -23,6 +34,10 @@ type Client struct {
     userID       string
     realmID      string
     httpClient   *http.Client
+    locker       EntityLocker
+    transformers []Transformer
+    retry        RetryPolicy
+    limiter      *RateLimiter
 }
 
 // NewClient creates a new QuickBooks API client
@@ -33,6 +48,7 @@ func NewClient(baseURL, clientID, clientSecret string, authService *auth.Service
         clientSecret: clientSecret,
         authService:  authService,
         httpClient:   &http.Client{Timeout: 30 * time.Second},
+        retry:        DefaultRetryPolicy,
     }
 }
 
@@ -50,8 +66,37 @@ func (c *Client) WithRealmID(realmID string) *Client {
     return &client
 }
 
+// WithTransport routes the client's requests through transport, keeping its timeout
+func (c *Client) WithTransport(transport http.RoundTripper) *Client {
+    client := *c
+    client.httpClient = &http.Client{Timeout: c.httpClient.Timeout, Transport: transport}
+    return &client
+}
+
 // sendRequest makes an authenticated request to the QuickBooks API
 func (c *Client) sendRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Response, error) {
+    return c.sendRequestAs(ctx, method, endpoint, "application/json", "application/json", body)
+}
+
+// sendRequestAs makes an authenticated request whose body has the given
+// content type, accepting a response of the given media type, in a client
+// span under the caller's
+func (c *Client) sendRequestAs(ctx context.Context, method, endpoint, contentType, accept string, body []byte) (*http.Response, error) {
+    ctx, span := tracing.Start(ctx, "QuickBooks "+method+" "+resourceName(endpoint),
+        trace.WithSpanKind(trace.SpanKindClient),
+        trace.WithAttributes(
+            semconv.HTTPRequestMethodKey.String(method),
+            semconv.URLPath(endpointPath(endpoint)),
+        ),
+    )
+    resp, err := c.sendWithRetries(ctx, method, endpoint, contentType, accept, body)
+    tracing.End(span, err)
+    return resp, err
+}
+
+// sendWithRetries makes a request, retrying transient failures according
+// to the request's retry policy
+func (c *Client) sendWithRetries(ctx context.Context, method, endpoint, contentType, accept string, body []byte) (*http.Response, error) {
     // If userID is not set, try to get it from context
     userID := c.userID
     if userID == "" {
@@ -71,40 +116,60 @@ func (c *Client) sendRequest(ctx context.Context, method, endpoint string, body
         }
     }
     
-    // Get valid token
-    token, err := c.authService.GetValidToken(ctx, userID)
-    if err != nil {
-        return nil, fmt.Errorf("failed to get valid token: %w", err)
-    }
-    
-    // Create request
-    var reqBody *strings.Reader
-    if body != nil {
-        reqBody = strings.NewReader(string(body))
-    }
-    
-    req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
-    if err != nil {
-        return nil, fmt.Errorf("failed to create request: %w", err)
-    }
-    
-    // Set headers
-    req.Header.Set("Authorization", fmt.Sprintf("%s %s", token.TokenType, token.AccessToken))
-    req.Header.Set("Accept", "application/json")
-    
-    if method == "POST" || method == "PUT" {
-        req.Header.Set("Content-Type", "application/json")
+    policy := c.retryPolicy(ctx)
+    watch := writeWatch(ctx)
+    write := method == "POST"
+    var requestID string
+    if write && (policy.MaxAttempts > 1 || watch != nil || WriteRequestID(ctx) != "") {
+        requestID = watch.requestIDFor(ctx)
     }
     
-    // Add minor version
-    query := req.URL.Query()
-    query.Set("minorversion", "75") // Using the latest minor version
-    req.URL.RawQuery = query.Encode()
-    
-    // Send request
-    resp, err := c.httpClient.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("request failed: %w", err)
+    logger := logging.FromContext(ctx).With("realm_id", realmID, "method", method, "endpoint", endpointPath(endpoint))
+    span := trace.SpanFromContext(ctx)
+    span.SetAttributes(attribute.String("quickbooks.realm_id", realmID))
+    var resp *http.Response
+    for attempt := 1; ; attempt++ {
+        var err error
+        started := time.Now()
+        resp, err = c.send(ctx, userID, realmID, method, endpoint, contentType, accept, requestID, body)
+        if errors.Is(err, errNoToken) || errors.Is(err, ErrRateLimited) {
+            if write && errors.Is(err, ErrRateLimited) {
+                watch.fail(requestID)
+            }
+            return nil, err
+        }
+        if err == nil {
+            logger.Debug("QuickBooks request", "attempt", attempt, "status", resp.StatusCode,
+                "duration_ms", time.Since(started).Milliseconds(), "intuit_tid", resp.Header.Get("intuit_tid"))
+            span.SetAttributes(
+                semconv.HTTPResponseStatusCode(resp.StatusCode),
+                attribute.Int("quickbooks.attempts", attempt),
+                attribute.String("quickbooks.intuit_tid", resp.Header.Get("intuit_tid")),
+            )
+        }
+        
+        delay, retry := policy.next(ctx, attempt, resp, err)
+        if !retry {
+            // Writes that gave up on an outage may be repeated later, unless
+            // the caller went away
+            if write && ctx.Err() == nil && (err != nil || retryableStatus(resp.StatusCode)) {
+                watch.fail(requestID)
+            }
+            if err != nil {
+                return nil, fmt.Errorf("request failed: %w", err)
+            }
+            break
+        }
+        if err != nil {
+            logger.Warn("retrying QuickBooks request", "attempt", attempt, "delay", delay, "error", err)
+        } else {
+            logger.Warn("retrying QuickBooks request", "attempt", attempt, "delay", delay, "status", resp.StatusCode)
+        }
+        span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt), attribute.String("delay", delay.String())))
+        discard(resp)
+        if err := wait(ctx, delay); err != nil {
+            return nil, fmt.Errorf("request failed: %w", err)
+        }
     }
     
     // Check for error responses
@@ -121,14 +186,96 @@ func (c *Client) sendRequest(ctx context.Context, method, endpoint string, body
             } `json:"Fault"`
         }
         
+        // Intuit support identifies failed calls by their transaction ID
+        var tid string
+        if value := resp.Header.Get("intuit_tid"); value != "" {
+            tid = " (intuit_tid " + value + ")"
+        }
+        logger.Warn("QuickBooks request failed", "status", resp.StatusCode, "intuit_tid", resp.Header.Get("intuit_tid"))
+        
         if err := json.Unmarshal(body, &qbErr); err == nil && len(qbErr.Fault.Error) > 0 {
-            return nil, fmt.Errorf("QuickBooks API error (%s): %s", 
-                qbErr.Fault.Error[0].Code, qbErr.Fault.Error[0].Message)
+            return nil, fmt.Errorf("QuickBooks API error (%s): %s%s", 
+                qbErr.Fault.Error[0].Code, qbErr.Fault.Error[0].Message, tid)
         }
         
-        return nil, fmt.Errorf("QuickBooks API returned status %d: %s", 
-            resp.StatusCode, string(body))
+        return nil, fmt.Errorf("QuickBooks API returned status %d: %s%s", 
+            resp.StatusCode, string(body), tid)
     }
     
+    if write {
+        watch.succeed()
+    }
     return resp, nil
 }
+
+// endpointPath returns an endpoint without its query, which may carry
+// customer data, for logging
+func endpointPath(endpoint string) string {
+    if parsed, err := url.Parse(endpoint); err == nil {
+        return parsed.Path
+    }
+    return endpoint
+}
+
+// resourceName returns the QuickBooks resource an endpoint addresses, e.g.
+// "invoice" for /v3/company/{realmId}/invoice/42, naming spans without
+// company or entity IDs
+func resourceName(endpoint string) string {
+    path := strings.Trim(endpointPath(endpoint), "/")
+    parts := strings.Split(path, "/")
+    if len(parts) >= 4 && parts[1] == "company" {
+        return parts[3]
+    }
+    return path
+}
+
+// errNoToken marks a failure to get an access token, which retrying the
+// request would not fix
+var errNoToken = errors.New("failed to get valid token")
+
+// send makes one attempt at a request, within the company's rate limit
+func (c *Client) send(ctx context.Context, userID, realmID, method, endpoint, contentType, accept, requestID string, body []byte) (*http.Response, error) {
+    // Get valid token
+    token, err := c.authService.GetValidToken(ctx, userID)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %w", errNoToken, err)
+    }
+    
+    // Create request (a typed nil reader would be treated as a body)
+    var reqBody io.Reader
+    if body != nil {
+        reqBody = bytes.NewReader(body)
+    }
+    
+    req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create request: %w", err)
+    }
+    
+    // Set headers
+    req.Header.Set("Authorization", fmt.Sprintf("%s %s", token.TokenType, token.AccessToken))
+    req.Header.Set("Accept", accept)
+    
+    if method == "POST" || method == "PUT" {
+        req.Header.Set("Content-Type", contentType)
+    }
+    
+    // Add minor version, and the idempotency key of writes
+    query := req.URL.Query()
+    query.Set("minorversion", MinorVersion)
+    if requestID != "" {
+        query.Set("requestid", requestID)
+    }
+    req.URL.RawQuery = query.Encode()
+    
+    // The slot is held until the response headers arrive, which is when
+    // QuickBooks has done the request's work
+    if c.limiter != nil {
+        release, err := c.limiter.Acquire(ctx, realmID)
+        if err != nil {
+            return nil, err
+        }
+        defer release()
+    }
+    return c.httpClient.Do(req)
+}
@@ -0,0 +1,58 @@
+// qbclient/company.go
+package qbclient
+
+import (
+    "context"
+    "fmt"
+)
+
+// CompanyAddress is a company's physical or legal address
+type CompanyAddress struct {
+    Line1                  string `json:"Line1,omitempty"`
+    City                   string `json:"City,omitempty"`
+    Country                string `json:"Country,omitempty"`
+    CountrySubDivisionCode string `json:"CountrySubDivisionCode,omitempty"`
+    PostalCode             string `json:"PostalCode,omitempty"`
+}
+
+// CompanyInfo is the subset of company information used by the server
+type CompanyInfo struct {
+    ID          string         `json:"Id"`
+    CompanyName string         `json:"CompanyName"`
+    Country     string         `json:"Country,omitempty"`
+    CompanyAddr CompanyAddress `json:"CompanyAddr"`
+    LegalAddr   CompanyAddress `json:"LegalAddr"`
+    NameValue   []NameValue    `json:"NameValue,omitempty"`
+}
+
+// NameValue is a name/value pair of extended company information
+type NameValue struct {
+    Name  string `json:"Name"`
+    Value string `json:"Value"`
+}
+
+// Edition returns the company's QuickBooks Online subscription, e.g.
+// "QuickBooks Online Plus", or "" when QuickBooks did not say
+func (c *CompanyInfo) Edition() string {
+    for _, nv := range c.NameValue {
+        if nv.Name == "OfferingSku" {
+            return nv.Value
+        }
+    }
+    return ""
+}
+
+// GetCompanyInfo retrieves the current company's information
+func (c *Client) GetCompanyInfo(ctx context.Context) (*CompanyInfo, error) {
+    var result struct {
+        CompanyInfo []CompanyInfo `json:"CompanyInfo"`
+    }
+    if err := c.Query(ctx, "SELECT * FROM CompanyInfo", &result); err != nil {
+        return nil, err
+    }
+    if len(result.CompanyInfo) == 0 {
+        return nil, fmt.Errorf("company info not found")
+    }
+    
+    return &result.CompanyInfo[0], nil
+}
@@ -0,0 +1,84 @@
+// qbclient/delete.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strings"
+)
+
+// Stages at which entities about to be deleted or voided are passed to
+// transformers. data is the stored entity; returning an error stops the
+// operation.
+const (
+    StageBeforeDelete = "before_delete" // entity about to be deleted
+    StageBeforeVoid   = "before_void"   // entity about to be voided
+)
+
+// Delete deletes an entity, e.g. an invoice, by ID. The stored entity is
+// read first under the entity's write lock so transformers see what is
+// about to be lost.
+func (c *Client) Delete(ctx context.Context, entity, id string) error {
+    if err := c.remove(ctx, StageBeforeDelete, "delete", entity, id, nil); err != nil {
+        return fmt.Errorf("failed to delete %s: %w", entity, err)
+    }
+    return nil
+}
+
+// Void voids a transaction, e.g. an invoice or payment, keeping it with
+// zero amounts, and decodes the voided entity into result. The voided
+// entity passes through the after_update transformers like any update.
+func (c *Client) Void(ctx context.Context, entity, id string, result interface{}) error {
+    if err := c.remove(ctx, StageBeforeVoid, "void", entity, id, result); err != nil {
+        return fmt.Errorf("failed to void %s: %w", entity, err)
+    }
+    return nil
+}
+
+// remove runs a delete or void operation under the entity's write lock
+func (c *Client) remove(ctx context.Context, stage, operation, entity, id string, result interface{}) error {
+    unlock, err := c.lockEntity(ctx, entity, id)
+    if err != nil {
+        return fmt.Errorf("failed to lock %s %s: %w", entity, id, err)
+    }
+    defer unlock()
+    
+    current, err := c.Read(ctx, entity, id)
+    if err != nil {
+        return err
+    }
+    if len(c.transformers) > 0 {
+        generic, err := toGeneric(current)
+        if err != nil {
+            return err
+        }
+        if _, err := c.transform(ctx, stage, entity, generic); err != nil {
+            return err
+        }
+    }
+    
+    payload := map[string]interface{}{"Id": id, "SyncToken": current["SyncToken"]}
+    if err := c.dryRun(ctx, operation, entity, payload); err != nil {
+        return err
+    }
+    
+    var envelope map[string]json.RawMessage
+    if err := c.post(ctx, strings.ToLower(entity)+"?operation="+operation, payload, &envelope); err != nil {
+        return err
+    }
+    if result == nil {
+        return nil
+    }
+    if err := c.transformEnvelope(ctx, StageAfterUpdate, entity, envelope); err != nil {
+        return err
+    }
+    data, err := json.Marshal(envelope)
+    if err != nil {
+        return fmt.Errorf("failed to marshal response: %w", err)
+    }
+    if err := json.Unmarshal(data, result); err != nil {
+        return fmt.Errorf("failed to parse response: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,81 @@
+// qbclient/dryrun.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sync"
+)
+
+// ErrDryRun is returned instead of sending a write during a dry run
+var ErrDryRun = errors.New("dry run: not sent to QuickBooks")
+
+// DryRunWrite is a write that would have been sent to QuickBooks
+type DryRunWrite struct {
+    Entity    string          `json:"entity"`
+    Operation string          `json:"operation"` // create, update, batch, delete, void, upload, send
+    Payload   json.RawMessage `json:"payload"`
+    // Current is the stored entity an update applies to, when read
+    Current map[string]json.RawMessage `json:"current,omitempty"`
+}
+
+// DryRun collects the writes made under a dry-run context
+type DryRun struct {
+    // ReadCurrent reads the stored entity of each update, e.g. to preview
+    // what it changes
+    ReadCurrent bool
+    
+    mu     sync.Mutex
+    writes []DryRunWrite
+}
+
+// Writes returns the writes collected so far
+func (d *DryRun) Writes() []DryRunWrite {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return append([]DryRunWrite(nil), d.writes...)
+}
+
+// dryRunKey is the context key holding a dry run
+type dryRunKey struct{}
+
+// WithDryRun returns a context under which writes run their before-write
+// transformers and are then collected instead of being sent. Reads are
+// sent as usual.
+func WithDryRun(ctx context.Context) (context.Context, *DryRun) {
+    run := &DryRun{}
+    return context.WithValue(ctx, dryRunKey{}, run), run
+}
+
+// IsDryRun reports whether writes under ctx are collected instead of sent
+func IsDryRun(ctx context.Context) bool {
+    _, ok := ctx.Value(dryRunKey{}).(*DryRun)
+    return ok
+}
+
+// dryRun collects a write when ctx is a dry run and returns ErrDryRun;
+// otherwise it returns nil and the write goes ahead
+func (c *Client) dryRun(ctx context.Context, operation, entity string, payload interface{}) error {
+    run, ok := ctx.Value(dryRunKey{}).(*DryRun)
+    if !ok {
+        return nil
+    }
+    
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return fmt.Errorf("failed to marshal request: %w", err)
+    }
+    write := DryRunWrite{Entity: entity, Operation: operation, Payload: data}
+    if id := entityID(payload); run.ReadCurrent && operation == "update" && id != "" {
+        if write.Current, err = c.Read(ctx, entity, id); err != nil {
+            return err
+        }
+    }
+    
+    run.mu.Lock()
+    run.writes = append(run.writes, write)
+    run.mu.Unlock()
+    return ErrDryRun
+}
@@ -0,0 +1,32 @@
+// qbclient/entity.go
+package qbclient
+
+import (
+    "context"
+    "fmt"
+)
+
+// Create creates an entity (e.g. "Invoice") and decodes the response into result
+func (c *Client) Create(ctx context.Context, entity string, payload, result interface{}) error {
+    if err := c.writeEntity(ctx, StageBeforeCreate, StageAfterCreate, entity, payload, result); err != nil {
+        return fmt.Errorf("failed to create %s: %w", entity, err)
+    }
+    return nil
+}
+
+// Update writes a full or sparse ("sparse": true) update of an entity; payload
+// must carry Id and SyncToken. With a locker configured, concurrent updates
+// of the same entity are serialized; use Modify when the payload is built
+// from a prior read.
+func (c *Client) Update(ctx context.Context, entity string, payload, result interface{}) error {
+    unlock, err := c.lockEntity(ctx, entity, entityID(payload))
+    if err != nil {
+        return fmt.Errorf("failed to lock %s: %w", entity, err)
+    }
+    defer unlock()
+    
+    if err := c.writeEntity(ctx, StageBeforeUpdate, StageAfterUpdate, entity, payload, result); err != nil {
+        return fmt.Errorf("failed to update %s: %w", entity, err)
+    }
+    return nil
+}
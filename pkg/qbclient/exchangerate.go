@@ -0,0 +1,48 @@
+// qbclient/exchangerate.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+)
+
+// ExchangeRate is the rate QuickBooks uses to convert a foreign currency to
+// the company's home currency on a date
+type ExchangeRate struct {
+    SourceCurrencyCode string  `json:"SourceCurrencyCode"`
+    TargetCurrencyCode string  `json:"TargetCurrencyCode"`
+    Rate               float64 `json:"Rate"`
+    AsOfDate           string  `json:"AsOfDate"`
+}
+
+// GetExchangeRate retrieves the current company's rate for a currency on a
+// date (YYYY-MM-DD). QuickBooks answers with the closest earlier rate when
+// it has none for the date itself.
+func (c *Client) GetExchangeRate(ctx context.Context, currency, date string) (*ExchangeRate, error) {
+    endpoint, err := c.companyEndpoint(ctx, "exchangerate")
+    if err != nil {
+        return nil, err
+    }
+    params := url.Values{"sourcecurrencycode": {currency}}
+    if date != "" {
+        params.Set("asofdate", date)
+    }
+    endpoint += "?" + params.Encode()
+    
+    resp, err := c.sendRequest(ctx, "GET", endpoint, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get %s exchange rate: %w", currency, err)
+    }
+    defer resp.Body.Close()
+    
+    var result struct {
+        ExchangeRate ExchangeRate `json:"ExchangeRate"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return nil, fmt.Errorf("failed to parse exchange rate: %w", err)
+    }
+    
+    return &result.ExchangeRate, nil
+}
@@ -0,0 +1,121 @@
+// qbclient/lock.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+    
+    "github.com/eGGnogSC/qbserver/auth"
+)
+
+// EntityLocker serializes writes to one entity across server instances.
+// Lock blocks until the key is free and returns a function that releases it.
+type EntityLocker interface {
+    Lock(ctx context.Context, key string) (func(), error)
+}
+
+// WithLocker makes updates of the same entity wait for each other instead of
+// failing with stale SyncToken errors
+func (c *Client) WithLocker(locker EntityLocker) *Client {
+    client := *c
+    client.locker = locker
+    return &client
+}
+
+// lockEntity takes the write lock for an entity in the current company; it
+// is a no-op when no locker is configured
+func (c *Client) lockEntity(ctx context.Context, entity, id string) (func(), error) {
+    if c.locker == nil || id == "" {
+        return func() {}, nil
+    }
+    
+    realmID := c.realmID
+    if realmID == "" {
+        var err error
+        realmID, err = auth.GetCompanyID(ctx)
+        if err != nil {
+            return nil, fmt.Errorf("company ID not provided")
+        }
+    }
+    
+    return c.locker.Lock(ctx, fmt.Sprintf("%s:%s:%s", realmID, strings.ToLower(entity), id))
+}
+
+// Read fetches one entity by ID as raw fields
+func (c *Client) Read(ctx context.Context, entity, id string) (map[string]json.RawMessage, error) {
+    endpoint, err := c.companyEndpoint(ctx, strings.ToLower(entity)+"/"+url.PathEscape(id))
+    if err != nil {
+        return nil, err
+    }
+    
+    resp, err := c.sendRequest(ctx, "GET", endpoint, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %s: %w", entity, err)
+    }
+    defer resp.Body.Close()
+    
+    var envelope map[string]json.RawMessage
+    if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+        return nil, fmt.Errorf("failed to parse %s: %w", entity, err)
+    }
+    
+    if err := c.transformEnvelope(ctx, StageAfterRead, entity, envelope); err != nil {
+        return nil, err
+    }
+    
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(envelope[entity], &fields); err != nil || fields == nil {
+        return nil, fmt.Errorf("response did not contain %s %s", entity, id)
+    }
+    return fields, nil
+}
+
+// Modify performs a read-modify-write of one entity under its write lock.
+// apply receives the current entity and returns the update payload; Id and
+// SyncToken are filled in from the current entity, so concurrent callers
+// are applied one after another rather than racing on the SyncToken.
+func (c *Client) Modify(ctx context.Context, entity, id string, apply func(current map[string]json.RawMessage) (map[string]interface{}, error), result interface{}) error {
+    unlock, err := c.lockEntity(ctx, entity, id)
+    if err != nil {
+        return fmt.Errorf("failed to lock %s %s: %w", entity, id, err)
+    }
+    defer unlock()
+    
+    current, err := c.Read(ctx, entity, id)
+    if err != nil {
+        return err
+    }
+    
+    payload, err := apply(current)
+    if err != nil {
+        return err
+    }
+    payload["Id"] = id
+    payload["SyncToken"] = current["SyncToken"]
+    
+    if err := c.writeEntity(ctx, StageBeforeUpdate, StageAfterUpdate, entity, payload, result); err != nil {
+        return fmt.Errorf("failed to update %s: %w", entity, err)
+    }
+    return nil
+}
+
+// entityID returns the Id field of an update payload
+func entityID(payload interface{}) string {
+    if m, ok := payload.(map[string]interface{}); ok {
+        id, _ := m["Id"].(string)
+        return id
+    }
+    
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return ""
+    }
+    var fields struct {
+        ID string `json:"Id"`
+    }
+    json.Unmarshal(data, &fields)
+    return fields.ID
+}
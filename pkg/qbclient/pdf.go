@@ -0,0 +1,32 @@
+// qbclient/pdf.go
+package qbclient
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/url"
+    "strings"
+)
+
+// PDF returns QuickBooks' own rendering of a sales transaction such as an
+// invoice or estimate, as PDF
+func (c *Client) PDF(ctx context.Context, entity, id string) ([]byte, error) {
+    endpoint, err := c.companyEndpoint(ctx, strings.ToLower(entity)+"/"+url.PathEscape(id)+"/pdf")
+    if err != nil {
+        return nil, err
+    }
+    
+    resp, err := c.sendRequestAs(ctx, "GET", endpoint, "application/json", "application/pdf", nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get %s PDF: %w", entity, err)
+    }
+    defer resp.Body.Close()
+    
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %s PDF: %w", entity, err)
+    }
+    
+    return data, nil
+}
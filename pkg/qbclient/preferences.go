@@ -0,0 +1,100 @@
+// qbclient/preferences.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+)
+
+// AccountingInfoPrefs holds the company's accounting preferences
+type AccountingInfoPrefs struct {
+    BookCloseDate string `json:"BookCloseDate,omitempty"`
+}
+
+// CurrencyPrefs holds the company's currency preferences
+type CurrencyPrefs struct {
+    MultiCurrencyEnabled bool `json:"MultiCurrencyEnabled,omitempty"`
+    HomeCurrency         struct {
+        Value string `json:"value"`
+    } `json:"HomeCurrency"`
+}
+
+// Preferences is the subset of company preferences used by the server
+type Preferences struct {
+    ID                  string              `json:"Id"`
+    SyncToken           string              `json:"SyncToken"`
+    AccountingInfoPrefs AccountingInfoPrefs `json:"AccountingInfoPrefs"`
+    CurrencyPrefs       CurrencyPrefs       `json:"CurrencyPrefs"`
+}
+
+// GetPreferences retrieves the current company's preferences
+func (c *Client) GetPreferences(ctx context.Context) (*Preferences, error) {
+    var result struct {
+        Preferences []Preferences `json:"Preferences"`
+    }
+    if err := c.Query(ctx, "SELECT * FROM Preferences", &result); err != nil {
+        return nil, err
+    }
+    if len(result.Preferences) == 0 {
+        return nil, fmt.Errorf("company preferences not found")
+    }
+    
+    return &result.Preferences[0], nil
+}
+
+// SetBookCloseDate sets the company's closing date (YYYY-MM-DD); an empty
+// date clears it
+func (c *Client) SetBookCloseDate(ctx context.Context, date string) (*Preferences, error) {
+    current, err := c.GetPreferences(ctx)
+    if err != nil {
+        return nil, err
+    }
+    
+    update := map[string]interface{}{
+        "Id":        current.ID,
+        "SyncToken": current.SyncToken,
+        "sparse":    true,
+        "AccountingInfoPrefs": map[string]interface{}{
+            "BookCloseDate": date,
+        },
+    }
+    
+    if err := c.dryRun(ctx, "update", "Preferences", update); err != nil {
+        return nil, err
+    }
+    
+    var result struct {
+        Preferences Preferences `json:"Preferences"`
+    }
+    if err := c.post(ctx, "preferences", update, &result); err != nil {
+        return nil, fmt.Errorf("failed to update preferences: %w", err)
+    }
+    
+    return &result.Preferences, nil
+}
+
+// post sends a JSON payload to a company endpoint and decodes the response into result
+func (c *Client) post(ctx context.Context, path string, payload, result interface{}) error {
+    endpoint, err := c.companyEndpoint(ctx, path)
+    if err != nil {
+        return err
+    }
+    
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return fmt.Errorf("failed to marshal request: %w", err)
+    }
+    
+    resp, err := c.sendRequest(ctx, "POST", endpoint, body)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    
+    if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+        return fmt.Errorf("failed to parse response: %w", err)
+    }
+    
+    return nil
+}
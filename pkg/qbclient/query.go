@@ -0,0 +1,53 @@
+// qbclient/query.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    
+    "github.com/eGGnogSC/qbserver/auth"
+)
+
+// companyEndpoint builds a URL under the current company's API path
+func (c *Client) companyEndpoint(ctx context.Context, path string) (string, error) {
+    realmID := c.realmID
+    if realmID == "" {
+        var err error
+        realmID, err = auth.GetCompanyID(ctx)
+        if err != nil {
+            return "", fmt.Errorf("company ID not provided")
+        }
+    }
+    
+    return fmt.Sprintf("%s/v3/company/%s/%s", c.baseURL, url.PathEscape(realmID), path), nil
+}
+
+// Query runs a QuickBooks query statement and decodes the QueryResponse into result
+func (c *Client) Query(ctx context.Context, query string, result interface{}) error {
+    endpoint, err := c.companyEndpoint(ctx, "query")
+    if err != nil {
+        return err
+    }
+    endpoint += "?query=" + url.QueryEscape(query)
+    
+    resp, err := c.sendRequest(ctx, "GET", endpoint, nil)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    
+    var envelope struct {
+        QueryResponse json.RawMessage `json:"QueryResponse"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+        return fmt.Errorf("failed to parse query response: %w", err)
+    }
+    
+    if err := json.Unmarshal(envelope.QueryResponse, result); err != nil {
+        return fmt.Errorf("failed to decode query results: %w", err)
+    }
+    
+    return nil
+}
@@ -0,0 +1,321 @@
+// qbclient/query/query.go
+package query
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// MaxPageSize is the most entities QuickBooks returns per query
+const MaxPageSize = 1000
+
+// ErrInvalidQuery is returned for queries that cannot be built
+var ErrInvalidQuery = errors.New("invalid query")
+
+var (
+    // entityPattern matches entity names such as "Invoice"
+    entityPattern = regexp.MustCompile(`^[A-Za-z]+$`)
+    // fieldPattern matches field names such as "TotalAmt" or
+    // "MetaData.LastUpdatedTime"
+    fieldPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(\.[A-Za-z][A-Za-z0-9]*)*$`)
+    // idPattern matches QuickBooks entity IDs
+    idPattern = regexp.MustCompile(`^\d+$`)
+)
+
+// operators are the comparisons QuickBooks queries support
+var operators = map[string]bool{
+    "=":    true,
+    "<":    true,
+    ">":    true,
+    "<=":   true,
+    ">=":   true,
+    "IN":   true,
+    "LIKE": true,
+}
+
+// Querier runs a QuickBooks query statement
+type Querier interface {
+    Query(ctx context.Context, query string, result interface{}) error
+}
+
+// Builder builds a QuickBooks query statement. Methods record the first
+// error, which Build returns, so calls can be chained.
+type Builder struct {
+    entity     string
+    selection  string
+    conditions []string
+    orders     []string
+    start      int
+    max        int
+    err        error
+}
+
+// Select starts a query for an entity's fields, all of them when none are
+// named
+func Select(entity string, fields ...string) *Builder {
+    b := &Builder{entity: entity, selection: "*"}
+    if !entityPattern.MatchString(entity) {
+        b.fail("invalid entity %q", entity)
+    }
+    if len(fields) > 0 {
+        for _, field := range fields {
+            b.checkField(field)
+        }
+        b.selection = strings.Join(fields, ", ")
+    }
+    return b
+}
+
+// Count starts a query counting an entity's records
+func Count(entity string) *Builder {
+    b := Select(entity)
+    b.selection = "COUNT(*)"
+    return b
+}
+
+// fail records the builder's first error
+func (b *Builder) fail(format string, args ...interface{}) {
+    if b.err == nil {
+        b.err = fmt.Errorf("%w: %s", ErrInvalidQuery, fmt.Sprintf(format, args...))
+    }
+}
+
+// checkField fails the builder for field names that could alter the query
+func (b *Builder) checkField(field string) {
+    if !fieldPattern.MatchString(field) {
+        b.fail("invalid field %q", field)
+    }
+}
+
+// Where adds a condition; conditions are joined with AND, the only
+// conjunction QuickBooks supports. IN takes a slice of values.
+func (b *Builder) Where(field, op string, value interface{}) *Builder {
+    b.checkField(field)
+    op = strings.ToUpper(strings.TrimSpace(op))
+    if !operators[op] {
+        b.fail("unsupported operator %q", op)
+        return b
+    }
+
+    var literal string
+    var err error
+    if op == "IN" {
+        literal, err = list(value)
+    } else {
+        literal, err = Literal(value)
+    }
+    if err != nil {
+        b.fail("%s: %v", field, err)
+        return b
+    }
+    b.conditions = append(b.conditions, fmt.Sprintf("%s %s %s", field, op, literal))
+    return b
+}
+
+// WhereIn adds a condition matching any of the values
+func (b *Builder) WhereIn(field string, values ...interface{}) *Builder {
+    return b.Where(field, "IN", values)
+}
+
+// OrderBy sorts the results by a field, ascending
+func (b *Builder) OrderBy(field string) *Builder {
+    b.checkField(field)
+    b.orders = append(b.orders, field)
+    return b
+}
+
+// OrderByDesc sorts the results by a field, descending
+func (b *Builder) OrderByDesc(field string) *Builder {
+    b.checkField(field)
+    b.orders = append(b.orders, field+" DESC")
+    return b
+}
+
+// Paginate returns one page of results; pages are numbered from 1
+func (b *Builder) Paginate(page, size int) *Builder {
+    if page < 1 {
+        b.fail("page must be at least 1")
+        return b
+    }
+    b.Limit(size)
+    b.start = (page-1)*size + 1
+    return b
+}
+
+// Limit caps the results at n, at most MaxPageSize
+func (b *Builder) Limit(n int) *Builder {
+    if n < 1 || n > MaxPageSize {
+        b.fail("page size must be between 1 and %d", MaxPageSize)
+        return b
+    }
+    b.max = n
+    return b
+}
+
+// Offset skips the first n results
+func (b *Builder) Offset(n int) *Builder {
+    if n < 0 {
+        b.fail("offset must not be negative")
+        return b
+    }
+    b.start = n + 1
+    return b
+}
+
+// Build returns the query statement
+func (b *Builder) Build() (string, error) {
+    if b.err != nil {
+        return "", b.err
+    }
+
+    var sb strings.Builder
+    sb.WriteString("SELECT ")
+    sb.WriteString(b.selection)
+    sb.WriteString(" FROM ")
+    sb.WriteString(b.entity)
+    if len(b.conditions) > 0 {
+        sb.WriteString(" WHERE ")
+        sb.WriteString(strings.Join(b.conditions, " AND "))
+    }
+    if len(b.orders) > 0 {
+        sb.WriteString(" ORDERBY ")
+        sb.WriteString(strings.Join(b.orders, ", "))
+    }
+    if b.start > 0 {
+        fmt.Fprintf(&sb, " STARTPOSITION %d", b.start)
+    }
+    if b.max > 0 {
+        fmt.Fprintf(&sb, " MAXRESULTS %d", b.max)
+    }
+    return sb.String(), nil
+}
+
+// String returns the query statement, or "" if it cannot be built
+func (b *Builder) String() string {
+    query, _ := b.Build()
+    return query
+}
+
+// page returns a copy of the builder reading one page of MaxPageSize
+// results from start
+func (b *Builder) page(start int) *Builder {
+    page := *b
+    page.start, page.max = start, MaxPageSize
+    return &page
+}
+
+// Literal formats a value as a query literal. Strings are quoted with
+// backslashes escaping quotes, times are written as QuickBooks date-times
+// and numbers and booleans as they are.
+func Literal(value interface{}) (string, error) {
+    switch v := value.(type) {
+    case string:
+        return Quote(v), nil
+    case time.Time:
+        return Quote(v.Format("2006-01-02T15:04:05-07:00")), nil
+    case bool:
+        return strconv.FormatBool(v), nil
+    case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+        return fmt.Sprint(v), nil
+    case float32:
+        return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64), nil
+    case fmt.Stringer:
+        // Decimal amounts; anything else could alter the query
+        if _, err := strconv.ParseFloat(v.String(), 64); err == nil {
+            return v.String(), nil
+        }
+    }
+    return "", fmt.Errorf("unsupported value type %T", value)
+}
+
+// list formats a slice of values as a parenthesized IN list
+func list(value interface{}) (string, error) {
+    v := reflect.ValueOf(value)
+    if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+        return "", fmt.Errorf("IN needs a list of values, got %T", value)
+    }
+    if v.Len() == 0 {
+        return "", errors.New("IN needs at least one value")
+    }
+    literals := make([]string, v.Len())
+    for i := range literals {
+        literal, err := Literal(v.Index(i).Interface())
+        if err != nil {
+            return "", err
+        }
+        literals[i] = literal
+    }
+    return "(" + strings.Join(literals, ", ") + ")", nil
+}
+
+// IsID reports whether a value is a well-formed QuickBooks entity ID, so
+// callers can answer malformed IDs as not found without a query
+func IsID(value string) bool {
+    return idPattern.MatchString(value)
+}
+
+// Quote escapes a value as a query string literal
+func Quote(value string) string {
+    return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// List runs the query and decodes the entities it returns into T
+func List[T any](ctx context.Context, querier Querier, b *Builder) ([]T, error) {
+    query, err := b.Build()
+    if err != nil {
+        return nil, err
+    }
+
+    var page map[string]json.RawMessage
+    if err := querier.Query(ctx, query, &page); err != nil {
+        return nil, fmt.Errorf("failed to fetch %s: %w", b.entity, err)
+    }
+    items := []T{}
+    if raw, ok := page[b.entity]; ok {
+        if err := json.Unmarshal(raw, &items); err != nil {
+            return nil, fmt.Errorf("failed to decode %s: %w", b.entity, err)
+        }
+    }
+    return items, nil
+}
+
+// All pages through every result of the query, MaxPageSize at a time,
+// ignoring its own paging, and decodes them into T
+func All[T any](ctx context.Context, querier Querier, b *Builder) ([]T, error) {
+    all := []T{}
+    for start := 1; ; start += MaxPageSize {
+        items, err := List[T](ctx, querier, b.page(start))
+        if err != nil {
+            return nil, err
+        }
+        all = append(all, items...)
+        if len(items) < MaxPageSize {
+            return all, nil
+        }
+    }
+}
+
+// CountOf runs a Count query and returns the number of records
+func CountOf(ctx context.Context, querier Querier, b *Builder) (int, error) {
+    query, err := b.Build()
+    if err != nil {
+        return 0, err
+    }
+
+    var result struct {
+        TotalCount int `json:"totalCount"`
+    }
+    if err := querier.Query(ctx, query, &result); err != nil {
+        return 0, fmt.Errorf("failed to count %s: %w", b.entity, err)
+    }
+    return result.TotalCount, nil
+}
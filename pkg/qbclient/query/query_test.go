@@ -0,0 +1,116 @@
+// qbclient/query/query_test.go
+package query
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "regexp"
+    "strconv"
+    "testing"
+)
+
+// pagePattern finds the page a statement asks for
+var pagePattern = regexp.MustCompile(`STARTPOSITION (\d+) MAXRESULTS (\d+)`)
+
+// pagedQuerier answers queries from a fixed set of encoded invoices,
+// honouring the statement's page, as QuickBooks does
+type pagedQuerier struct {
+    invoices []json.RawMessage
+}
+
+func newPagedQuerier(n int) *pagedQuerier {
+    q := &pagedQuerier{invoices: make([]json.RawMessage, n)}
+    for i := range q.invoices {
+        q.invoices[i] = json.RawMessage(fmt.Sprintf(
+            `{"Id":"%d","DocNumber":"INV-%d","TxnDate":"2026-01-15","TotalAmt":125.5,"Balance":125.5,"CustomerRef":{"value":"%d","name":"Customer %d"}}`,
+            i+1, i+1, i%50+1, i%50+1))
+    }
+    return q
+}
+
+func (q *pagedQuerier) Query(ctx context.Context, statement string, result interface{}) error {
+    match := pagePattern.FindStringSubmatch(statement)
+    if match == nil {
+        return fmt.Errorf("unpaged query: %s", statement)
+    }
+    start, _ := strconv.Atoi(match[1])
+    max, _ := strconv.Atoi(match[2])
+    end := min(start-1+max, len(q.invoices))
+
+    page := map[string][]json.RawMessage{}
+    if start-1 < end {
+        page["Invoice"] = q.invoices[start-1 : end]
+    }
+    data, err := json.Marshal(page)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(data, result)
+}
+
+// benchInvoice is the part of an invoice a listing decodes
+type benchInvoice struct {
+    ID          string  `json:"Id"`
+    DocNumber   string  `json:"DocNumber"`
+    TxnDate     string  `json:"TxnDate"`
+    TotalAmt    float64 `json:"TotalAmt"`
+    Balance     float64 `json:"Balance"`
+    CustomerRef struct {
+        Value string `json:"value"`
+        Name  string `json:"name"`
+    } `json:"CustomerRef"`
+}
+
+func BenchmarkBuild(b *testing.B) {
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        _, err := Select("Invoice").
+            Where("CustomerRef", "=", "42").
+            Where("Balance", ">", "0").
+            Where("TxnDate", ">=", "2026-01-01").
+            OrderByDesc("TxnDate").
+            Paginate(3, 50).
+            Build()
+        if err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+// BenchmarkList decodes one page of invoices, as the invoice list does
+func BenchmarkList(b *testing.B) {
+    querier := newPagedQuerier(100)
+    q := Select("Invoice").OrderByDesc("TxnDate").Paginate(1, 100)
+    ctx := context.Background()
+
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        invoices, err := List[benchInvoice](ctx, querier, q)
+        if err != nil {
+            b.Fatal(err)
+        }
+        if len(invoices) != 100 {
+            b.Fatalf("listed %d invoices, want 100", len(invoices))
+        }
+    }
+}
+
+// BenchmarkAll pages through every invoice of a company, as reports and
+// exports do
+func BenchmarkAll(b *testing.B) {
+    querier := newPagedQuerier(5000)
+    q := Select("Invoice").Where("Balance", ">", "0")
+    ctx := context.Background()
+
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        invoices, err := All[benchInvoice](ctx, querier, q)
+        if err != nil {
+            b.Fatal(err)
+        }
+        if len(invoices) != 5000 {
+            b.Fatalf("paged %d invoices, want 5000", len(invoices))
+        }
+    }
+}
@@ -0,0 +1,328 @@
+// qbclient/ratelimit.go
+package qbclient
+
+import (
+    "context"
+    "errors"
+    "expvar"
+    "math"
+    "sort"
+    "sync"
+    "time"
+)
+
+// sweepInterval is how often idle companies' buckets are dropped
+const sweepInterval = time.Minute
+
+// ErrRateLimited is returned when a request would have to queue longer
+// than the rate limit allows or its context has time for
+var ErrRateLimited = errors.New("QuickBooks rate limit: request would wait too long")
+
+// RateLimit is the request budget of each QuickBooks company. QuickBooks
+// Online throttles about 500 requests a minute and 10 concurrent requests
+// per company with 429 responses; staying under those limits queues
+// requests here instead.
+type RateLimit struct {
+    // PerMinute is the steady request rate
+    PerMinute int
+    // Burst is how many requests may go at once after an idle spell
+    Burst int
+    // MaxConcurrent bounds the requests in flight; zero leaves them unbounded
+    MaxConcurrent int
+    // MaxWait fails requests that would queue longer; zero waits as long as
+    // the request's context allows. Background requests always wait.
+    MaxWait time.Duration
+    // BackgroundReserve is the budget held back for interactive requests:
+    // background requests wait while fewer tokens are left
+    BackgroundReserve int
+    // LatencyTarget is the interactive latency above which background
+    // requests are throttled further
+    LatencyTarget time.Duration
+}
+
+// DefaultRateLimit leaves headroom under QuickBooks Online's limits for
+// other apps connected to the same companies
+var DefaultRateLimit = RateLimit{
+    PerMinute:     450,
+    Burst:         20,
+    MaxConcurrent: 8,
+}
+
+// withDefaults fills unset limits
+func (l RateLimit) withDefaults() RateLimit {
+    if l.PerMinute <= 0 {
+        l.PerMinute = DefaultRateLimit.PerMinute
+    }
+    if l.Burst <= 0 {
+        l.Burst = 1
+    }
+    if l.BackgroundReserve <= 0 {
+        l.BackgroundReserve = l.Burst / 4
+    }
+    if l.BackgroundReserve > l.Burst-1 {
+        l.BackgroundReserve = l.Burst - 1
+    }
+    if l.LatencyTarget <= 0 {
+        l.LatencyTarget = defaultLatencyTarget
+    }
+    return l
+}
+
+// RateLimitStats is the state of one company's request budget
+type RateLimitStats struct {
+    RealmID  string `json:"realm_id"`
+    Queued   int    `json:"queued"`
+    InFlight int    `json:"in_flight"`
+    // Tokens is the budget left; it is negative while requests queue
+    Tokens float64 `json:"tokens"`
+    // Waited and Rejected count requests that queued and that were failed
+    // with ErrRateLimited
+    Waited   int64 `json:"waited"`
+    Rejected int64 `json:"rejected"`
+    // BackgroundInFlight is how many of the requests in flight are
+    // background ones, and Throttled how many background requests waited
+    BackgroundInFlight int   `json:"background_in_flight"`
+    Throttled          int64 `json:"throttled"`
+    // LatencyMS is the recent interactive latency and BackgroundShare the
+    // share of the budget background requests get because of it
+    LatencyMS       int64   `json:"latency_ms"`
+    BackgroundShare float64 `json:"background_share"`
+}
+
+// bucket is one company's token bucket and concurrency slots
+type bucket struct {
+    tokens   float64
+    updated  time.Time
+    queued   int
+    inFlight int
+    waited   int64
+    rejected int64
+    slots    chan struct{}
+    
+    // interactiveQueued are the queued requests background ones yield to
+    interactiveQueued  int
+    backgroundInFlight int
+    throttled          int64
+    // nextBackground paces background requests while they are throttled
+    nextBackground time.Time
+    // latency is the moving average of interactive requests' wait and
+    // response time, as of lastInteractive
+    latency         time.Duration
+    lastInteractive time.Time
+}
+
+// RateLimiter queues requests per QuickBooks company within a rate limit.
+// Buckets are kept per server instance, so the configured rate is divided
+// among instances.
+type RateLimiter struct {
+    limit RateLimit
+    // rate is the refill in tokens per second
+    rate float64
+    
+    mu        sync.Mutex
+    buckets   map[string]*bucket
+    lastSweep time.Time
+    waitTime  time.Duration
+}
+
+// limiters are the rate limiters published to expvar
+var (
+    limitersMu sync.Mutex
+    limiters   []*RateLimiter
+)
+
+func init() {
+    // Queue depths alongside the other metrics of /admin/debug/vars
+    expvar.Publish("qb_rate_limit", expvar.Func(func() interface{} {
+        limitersMu.Lock()
+        registered := append([]*RateLimiter(nil), limiters...)
+        limitersMu.Unlock()
+        
+        var summary struct {
+            Queued      int              `json:"queued"`
+            InFlight    int              `json:"in_flight"`
+            WaitSeconds float64          `json:"wait_seconds"`
+            Realms      []RateLimitStats `json:"realms"`
+        }
+        summary.Realms = []RateLimitStats{}
+        for _, limiter := range registered {
+            for _, stats := range limiter.Stats() {
+                summary.Queued += stats.Queued
+                summary.InFlight += stats.InFlight
+                if stats.Queued > 0 || stats.InFlight > 0 {
+                    summary.Realms = append(summary.Realms, stats)
+                }
+            }
+            limiter.mu.Lock()
+            summary.WaitSeconds += limiter.waitTime.Seconds()
+            limiter.mu.Unlock()
+        }
+        return summary
+    }))
+}
+
+// NewRateLimiter creates a new per-company rate limiter
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+    limit = limit.withDefaults()
+    l := &RateLimiter{
+        limit:     limit,
+        rate:      float64(limit.PerMinute) / 60,
+        buckets:   make(map[string]*bucket),
+        lastSweep: time.Now(),
+    }
+    limitersMu.Lock()
+    limiters = append(limiters, l)
+    limitersMu.Unlock()
+    return l
+}
+
+// WithRateLimiter queues the client's requests per company within the
+// limiter's budget
+func (c *Client) WithRateLimiter(limiter *RateLimiter) *Client {
+    client := *c
+    client.limiter = limiter
+    return &client
+}
+
+// refill adds the tokens accrued since the bucket was last updated
+func (l *RateLimiter) refill(b *bucket, now time.Time) {
+    b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+    b.updated = now
+}
+
+// bucket returns a company's bucket, dropping idle ones now and then.
+// l.mu must be held.
+func (l *RateLimiter) bucket(realmID string, now time.Time) *bucket {
+    if now.Sub(l.lastSweep) >= sweepInterval {
+        for id, b := range l.buckets {
+            l.refill(b, now)
+            if b.queued == 0 && b.inFlight == 0 && b.tokens >= float64(l.limit.Burst) {
+                delete(l.buckets, id)
+            }
+        }
+        l.lastSweep = now
+    }
+    
+    b, ok := l.buckets[realmID]
+    if !ok {
+        b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+        if l.limit.MaxConcurrent > 0 {
+            b.slots = make(chan struct{}, l.limit.MaxConcurrent)
+        }
+        l.buckets[realmID] = b
+    }
+    return b
+}
+
+// Acquire waits until a company's budget allows another request and
+// returns a function to call once it completes. Tokens are reserved in
+// the order requests arrive; requests that would wait longer than MaxWait
+// or past their context's deadline fail with ErrRateLimited at once.
+// Background requests wait behind interactive ones; see acquireBackground.
+func (l *RateLimiter) Acquire(ctx context.Context, realmID string) (func(), error) {
+    if Priority(ctx) == PriorityBackground {
+        return l.acquireBackground(ctx, realmID)
+    }
+    
+    now := time.Now()
+    l.mu.Lock()
+    b := l.bucket(realmID, now)
+    l.refill(b, now)
+    
+    // Take a token now, possibly borrowing against the refill, and wait
+    // until it would have been there
+    var delay time.Duration
+    if b.tokens < 1 {
+        delay = time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+    }
+    deadline, hasDeadline := ctx.Deadline()
+    if (l.limit.MaxWait > 0 && delay > l.limit.MaxWait) || (hasDeadline && now.Add(delay).After(deadline)) {
+        b.rejected++
+        l.mu.Unlock()
+        return nil, ErrRateLimited
+    }
+    b.tokens--
+    b.queued++
+    b.interactiveQueued++
+    l.mu.Unlock()
+    
+    // abandon gives back what was taken when the caller stops waiting
+    abandon := func(refund bool) {
+        l.mu.Lock()
+        b.queued--
+        b.interactiveQueued--
+        if refund {
+            b.tokens = math.Min(float64(l.limit.Burst), b.tokens+1)
+        }
+        l.mu.Unlock()
+    }
+    if delay > 0 {
+        if err := wait(ctx, delay); err != nil {
+            abandon(true)
+            return nil, err
+        }
+    }
+    if b.slots != nil {
+        select {
+        case b.slots <- struct{}{}:
+        case <-ctx.Done():
+            abandon(false)
+            return nil, ctx.Err()
+        }
+    }
+    
+    waited := time.Since(now)
+    l.mu.Lock()
+    b.queued--
+    b.interactiveQueued--
+    b.inFlight++
+    if waited >= time.Millisecond {
+        b.waited++
+        l.waitTime += waited
+    }
+    l.mu.Unlock()
+    
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            l.mu.Lock()
+            b.inFlight--
+            b.observe(time.Since(now))
+            l.mu.Unlock()
+            if b.slots != nil {
+                <-b.slots
+            }
+        })
+    }, nil
+}
+
+// Stats returns the budgets of the companies with a bucket, busiest first
+func (l *RateLimiter) Stats() []RateLimitStats {
+    now := time.Now()
+    l.mu.Lock()
+    stats := make([]RateLimitStats, 0, len(l.buckets))
+    for realmID, b := range l.buckets {
+        l.refill(b, now)
+        stats = append(stats, RateLimitStats{
+            RealmID:            realmID,
+            Queued:             b.queued,
+            InFlight:           b.inFlight,
+            Tokens:             math.Round(b.tokens*100) / 100,
+            Waited:             b.waited,
+            Rejected:           b.rejected,
+            BackgroundInFlight: b.backgroundInFlight,
+            Throttled:          b.throttled,
+            LatencyMS:          b.latency.Milliseconds(),
+            BackgroundShare:    math.Round(l.backgroundShare(b, now)*100) / 100,
+        })
+    }
+    l.mu.Unlock()
+    
+    sort.Slice(stats, func(i, j int) bool {
+        if stats[i].Queued != stats[j].Queued {
+            return stats[i].Queued > stats[j].Queued
+        }
+        return stats[i].RealmID < stats[j].RealmID
+    })
+    return stats
+}
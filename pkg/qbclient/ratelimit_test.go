@@ -0,0 +1,87 @@
+// qbclient/ratelimit_test.go
+package qbclient
+
+import (
+    "context"
+    "strconv"
+    "sync/atomic"
+    "testing"
+)
+
+// unthrottled is a budget no benchmark exhausts, so Acquire never waits
+// and the benchmarks measure the limiter's own bookkeeping
+var unthrottled = RateLimit{
+    PerMinute:     1 << 30,
+    Burst:         1 << 30,
+    MaxConcurrent: 1 << 16,
+}
+
+func BenchmarkRateLimiterAcquire(b *testing.B) {
+    limiter := NewRateLimiter(unthrottled)
+    ctx := context.Background()
+
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        release, err := limiter.Acquire(ctx, "realm")
+        if err != nil {
+            b.Fatal(err)
+        }
+        release()
+    }
+}
+
+// BenchmarkRateLimiterAcquireContended has every goroutine acquire for the
+// same company, as a busy tenant's requests do
+func BenchmarkRateLimiterAcquireContended(b *testing.B) {
+    limiter := NewRateLimiter(unthrottled)
+    ctx := context.Background()
+
+    b.ReportAllocs()
+    b.RunParallel(func(pb *testing.PB) {
+        for pb.Next() {
+            release, err := limiter.Acquire(ctx, "realm")
+            if err != nil {
+                b.Fatal(err)
+            }
+            release()
+        }
+    })
+}
+
+// BenchmarkRateLimiterAcquireRealms spreads acquisitions over many
+// companies, as a multi-tenant server's are
+func BenchmarkRateLimiterAcquireRealms(b *testing.B) {
+    limiter := NewRateLimiter(unthrottled)
+    ctx := context.Background()
+    realms := make([]string, 1000)
+    for i := range realms {
+        realms[i] = strconv.Itoa(i)
+    }
+    var next int64
+
+    b.ReportAllocs()
+    b.RunParallel(func(pb *testing.PB) {
+        for pb.Next() {
+            realm := realms[atomic.AddInt64(&next, 1)%int64(len(realms))]
+            release, err := limiter.Acquire(ctx, realm)
+            if err != nil {
+                b.Fatal(err)
+            }
+            release()
+        }
+    })
+}
+
+func BenchmarkRateLimiterAcquireBackground(b *testing.B) {
+    limiter := NewRateLimiter(unthrottled)
+    ctx := WithPriority(context.Background(), PriorityBackground)
+
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        release, err := limiter.Acquire(ctx, "realm")
+        if err != nil {
+            b.Fatal(err)
+        }
+        release()
+    }
+}
@@ -0,0 +1,74 @@
+// qbclient/reports.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+)
+
+// ReportColumn is a single cell in a report row
+type ReportColumn struct {
+    Value string `json:"value"`
+    ID    string `json:"id,omitempty"`
+}
+
+// ReportRow is a data, section, or summary row of a report
+type ReportRow struct {
+    Type    string         `json:"type,omitempty"`
+    Group   string         `json:"group,omitempty"`
+    ColData []ReportColumn `json:"ColData,omitempty"`
+    Header  *struct {
+        ColData []ReportColumn `json:"ColData"`
+    } `json:"Header,omitempty"`
+    Rows *struct {
+        Row []ReportRow `json:"Row"`
+    } `json:"Rows,omitempty"`
+    Summary *struct {
+        ColData []ReportColumn `json:"ColData"`
+    } `json:"Summary,omitempty"`
+}
+
+// Report is a QuickBooks Reports API response
+type Report struct {
+    Header struct {
+        ReportName  string `json:"ReportName"`
+        StartPeriod string `json:"StartPeriod"`
+        EndPeriod   string `json:"EndPeriod"`
+        Currency    string `json:"Currency"`
+    } `json:"Header"`
+    Columns struct {
+        Column []struct {
+            ColTitle string `json:"ColTitle"`
+            ColType  string `json:"ColType"`
+        } `json:"Column"`
+    } `json:"Columns"`
+    Rows struct {
+        Row []ReportRow `json:"Row"`
+    } `json:"Rows"`
+}
+
+// Report runs a named QuickBooks report (e.g. ProfitAndLoss) with the given parameters
+func (c *Client) Report(ctx context.Context, name string, params url.Values) (*Report, error) {
+    endpoint, err := c.companyEndpoint(ctx, "reports/"+url.PathEscape(name))
+    if err != nil {
+        return nil, err
+    }
+    if len(params) > 0 {
+        endpoint += "?" + params.Encode()
+    }
+    
+    resp, err := c.sendRequest(ctx, "GET", endpoint, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    
+    var report Report
+    if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+        return nil, fmt.Errorf("failed to parse %s report: %w", name, err)
+    }
+    
+    return &report, nil
+}
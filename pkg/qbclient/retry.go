@@ -0,0 +1,188 @@
+// qbclient/retry.go
+package qbclient
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "io"
+    "math"
+    mathrand "math/rand"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// RetryPolicy decides how often and how far apart requests that failed
+// transiently (network errors, 429 and 5xx gateway responses) are retried
+type RetryPolicy struct {
+    // MaxAttempts is the number of tries including the first; 1 disables
+    // retries
+    MaxAttempts int
+    // BaseDelay doubles after each failed attempt, up to MaxDelay
+    BaseDelay time.Duration
+    MaxDelay  time.Duration
+    // Jitter is the fraction of each delay that is randomized, from 0 to 1,
+    // so clients throttled together do not retry together
+    Jitter float64
+    // MaxRetryAfter is the longest Retry-After a response may ask for; a
+    // longer one fails the request instead of holding it
+    MaxRetryAfter time.Duration
+}
+
+var (
+    // DefaultRetryPolicy rides out brief QuickBooks hiccups without keeping
+    // interactive requests waiting long
+    DefaultRetryPolicy = RetryPolicy{
+        MaxAttempts:   3,
+        BaseDelay:     500 * time.Millisecond,
+        MaxDelay:      5 * time.Second,
+        Jitter:        0.5,
+        MaxRetryAfter: 10 * time.Second,
+    }
+    // BackgroundRetryPolicy keeps long-running syncs and imports going
+    // through throttling and short outages
+    BackgroundRetryPolicy = RetryPolicy{
+        MaxAttempts:   8,
+        BaseDelay:     time.Second,
+        MaxDelay:      time.Minute,
+        Jitter:        0.5,
+        MaxRetryAfter: 5 * time.Minute,
+    }
+    // NoRetry sends each request once
+    NoRetry = RetryPolicy{MaxAttempts: 1}
+)
+
+// withDefaults clamps a policy to usable values
+func (p RetryPolicy) withDefaults() RetryPolicy {
+    if p.MaxAttempts < 1 {
+        p.MaxAttempts = 1
+    }
+    if p.BaseDelay <= 0 {
+        p.BaseDelay = DefaultRetryPolicy.BaseDelay
+    }
+    if p.MaxDelay < p.BaseDelay {
+        p.MaxDelay = p.BaseDelay
+    }
+    p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+    return p
+}
+
+// WithRetryPolicy sets the retry policy of the client's requests
+func (c *Client) WithRetryPolicy(policy RetryPolicy) *Client {
+    client := *c
+    client.retry = policy.withDefaults()
+    return &client
+}
+
+// retryKey is the context key holding a per-request retry policy
+type retryKey struct{}
+
+// WithRetry returns a context whose QuickBooks requests use policy instead
+// of the client's, e.g. BackgroundRetryPolicy for a long-running sync or
+// NoRetry where the caller retries itself
+func WithRetry(ctx context.Context, policy RetryPolicy) context.Context {
+    return context.WithValue(ctx, retryKey{}, policy.withDefaults())
+}
+
+// retryPolicy returns the policy for a request under ctx
+func (c *Client) retryPolicy(ctx context.Context) RetryPolicy {
+    if policy, ok := ctx.Value(retryKey{}).(RetryPolicy); ok {
+        return policy
+    }
+    return c.retry
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(status int) bool {
+    switch status {
+    case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+        http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+        return true
+    }
+    return false
+}
+
+// next returns how long to wait before retrying a failed attempt, or false
+// when the request should not be retried
+func (p RetryPolicy) next(ctx context.Context, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+    if attempt >= p.MaxAttempts || ctx.Err() != nil {
+        return 0, false
+    }
+    if err == nil && !retryableStatus(resp.StatusCode) {
+        return 0, false
+    }
+    
+    delay := p.backoff(attempt)
+    if resp != nil {
+        if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+            if p.MaxRetryAfter > 0 && after > p.MaxRetryAfter {
+                return 0, false
+            }
+            delay = after
+        }
+    }
+    
+    // Waiting past the caller's deadline only delays the same failure
+    if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+        return 0, false
+    }
+    return delay, true
+}
+
+// backoff is the jittered exponential delay after the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+    delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+    delay = math.Min(delay, float64(p.MaxDelay))
+    delay -= delay * p.Jitter * mathrand.Float64()
+    return time.Duration(delay)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date
+func retryAfter(value string) (time.Duration, bool) {
+    if value == "" {
+        return 0, false
+    }
+    if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+        return time.Duration(seconds) * time.Second, true
+    }
+    if at, err := http.ParseTime(value); err == nil {
+        if delay := time.Until(at); delay > 0 {
+            return delay, true
+        }
+        return 0, true
+    }
+    return 0, false
+}
+
+// wait sleeps for delay unless ctx is done first
+func wait(ctx context.Context, delay time.Duration) error {
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
+
+// discard drains and closes the body of a response that is being retried,
+// so its connection can be reused
+func discard(resp *http.Response) {
+    if resp != nil {
+        io.Copy(io.Discard, resp.Body)
+        resp.Body.Close()
+    }
+}
+
+// newRequestID returns an idempotency key for a write. QuickBooks answers a
+// repeated requestid with the first request's result, so retrying a write
+// whose response was lost does not apply it twice.
+func newRequestID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return strconv.FormatInt(time.Now().UnixNano(), 36)
+    }
+    return hex.EncodeToString(b)
+}
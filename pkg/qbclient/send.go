@@ -0,0 +1,39 @@
+// qbclient/send.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+)
+
+// Send emails a sales transaction such as an invoice or estimate from
+// QuickBooks to its BillEmail, or to sendTo when given, and decodes the
+// response, which carries the updated EmailStatus, into result
+func (c *Client) Send(ctx context.Context, entity, id, sendTo string, result interface{}) error {
+    if err := c.dryRun(ctx, "send", entity, map[string]string{"Id": id, "sendTo": sendTo}); err != nil {
+        return err
+    }
+    
+    endpoint, err := c.companyEndpoint(ctx, strings.ToLower(entity)+"/"+url.PathEscape(id)+"/send")
+    if err != nil {
+        return err
+    }
+    if sendTo != "" {
+        endpoint += "?sendTo=" + url.QueryEscape(sendTo)
+    }
+    
+    resp, err := c.sendRequestAs(ctx, "POST", endpoint, "application/octet-stream", "application/json", nil)
+    if err != nil {
+        return fmt.Errorf("failed to send %s: %w", entity, err)
+    }
+    defer resp.Body.Close()
+    
+    if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+        return fmt.Errorf("failed to parse response: %w", err)
+    }
+    
+    return nil
+}
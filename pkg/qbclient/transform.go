@@ -0,0 +1,122 @@
+// qbclient/transform.go
+package qbclient
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strings"
+)
+
+// Stages at which entity payloads can be transformed
+const (
+    StageBeforeCreate = "before_create" // payload about to be sent for a create
+    StageBeforeUpdate = "before_update" // payload about to be sent for an update
+    StageAfterCreate  = "after_create"  // entity returned by a create
+    StageAfterUpdate  = "after_update"  // entity returned by an update
+    StageAfterRead    = "after_read"    // entity returned by a read
+)
+
+// Transformer rewrites entity payloads on their way to and from QuickBooks.
+// data is the entity as generic JSON; the returned map replaces it.
+type Transformer interface {
+    Transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error)
+}
+
+// WithTransformer adds a transformer; transformers run in the order added
+func (c *Client) WithTransformer(transformer Transformer) *Client {
+    client := *c
+    client.transformers = append(append([]Transformer(nil), c.transformers...), transformer)
+    return &client
+}
+
+// transform runs every transformer over an entity
+func (c *Client) transform(ctx context.Context, stage, entity string, data map[string]interface{}) (map[string]interface{}, error) {
+    for _, transformer := range c.transformers {
+        var err error
+        if data, err = transformer.Transform(ctx, stage, entity, data); err != nil {
+            return nil, fmt.Errorf("%s %s hook failed: %w", entity, stage, err)
+        }
+    }
+    return data, nil
+}
+
+// toGeneric converts a payload into a generic JSON object
+func toGeneric(payload interface{}) (map[string]interface{}, error) {
+    // Round-trip even maps so transformers never modify the caller's payload
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal request: %w", err)
+    }
+    var generic map[string]interface{}
+    if err := json.Unmarshal(data, &generic); err != nil {
+        return nil, fmt.Errorf("payload is not a JSON object: %w", err)
+    }
+    return generic, nil
+}
+
+// writeEntity sends a create or update through the transformers and decodes
+// the transformed response into result
+func (c *Client) writeEntity(ctx context.Context, before, after, entity string, payload, result interface{}) error {
+    operation := "create"
+    if before == StageBeforeUpdate {
+        operation = "update"
+    }
+    
+    if len(c.transformers) == 0 {
+        if err := c.dryRun(ctx, operation, entity, payload); err != nil {
+            return err
+        }
+        return c.post(ctx, strings.ToLower(entity), payload, result)
+    }
+    
+    generic, err := toGeneric(payload)
+    if err != nil {
+        return err
+    }
+    ctx = withWriteRequest(ctx)
+    if generic, err = c.transform(ctx, before, entity, generic); err != nil {
+        return err
+    }
+    if err := c.dryRun(ctx, operation, entity, generic); err != nil {
+        return err
+    }
+    
+    var envelope map[string]json.RawMessage
+    if err := c.post(ctx, strings.ToLower(entity), generic, &envelope); err != nil {
+        return err
+    }
+    if err := c.transformEnvelope(ctx, after, entity, envelope); err != nil {
+        return err
+    }
+    
+    data, err := json.Marshal(envelope)
+    if err != nil {
+        return fmt.Errorf("failed to marshal response: %w", err)
+    }
+    if err := json.Unmarshal(data, result); err != nil {
+        return fmt.Errorf("failed to parse response: %w", err)
+    }
+    return nil
+}
+
+// transformEnvelope transforms the entity inside a response envelope in place
+func (c *Client) transformEnvelope(ctx context.Context, stage, entity string, envelope map[string]json.RawMessage) error {
+    raw, ok := envelope[entity]
+    if !ok || len(c.transformers) == 0 {
+        return nil
+    }
+    
+    var generic map[string]interface{}
+    if err := json.Unmarshal(raw, &generic); err != nil {
+        return fmt.Errorf("failed to parse %s: %w", entity, err)
+    }
+    generic, err := c.transform(ctx, stage, entity, generic)
+    if err != nil {
+        return err
+    }
+    if envelope[entity], err = json.Marshal(generic); err != nil {
+        return fmt.Errorf("failed to marshal %s: %w", entity, err)
+    }
+    return nil
+}
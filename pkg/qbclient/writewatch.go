@@ -0,0 +1,114 @@
+// qbclient/writewatch.go
+package qbclient
+
+import (
+    "context"
+    "sync"
+    "sync/atomic"
+)
+
+// WriteWatch records the outcome of the QuickBooks writes made under a
+// context, so a caller can tell whether a failed request may be repeated
+// later: it gave up on a transient failure before anything was written.
+type WriteWatch struct {
+    mu        sync.Mutex
+    wrote     bool   // a write succeeded
+    failed    bool   // a write gave up on a transient failure
+    requestID string // idempotency key of the failed write
+    reuse     string // idempotency key for the next write, when repeating one
+}
+
+// writeWatchKey is the context key holding a WriteWatch
+type writeWatchKey struct{}
+
+// WithWriteWatch returns a context whose QuickBooks writes are recorded in
+// the returned watch. When requestID is set, the first write is sent with
+// it, so repeating a write that may have reached QuickBooks is answered
+// with the first attempt's result instead of applying it twice.
+func WithWriteWatch(ctx context.Context, requestID string) (context.Context, *WriteWatch) {
+    watch := &WriteWatch{reuse: requestID}
+    return context.WithValue(ctx, writeWatchKey{}, watch), watch
+}
+
+// writeRequest is the idempotency key reserved for a write before its
+// transformers run, taken by the write when it is sent
+type writeRequest struct {
+    id   string
+    sent atomic.Bool
+}
+
+// writeRequestKey is the context key holding a writeRequest
+type writeRequestKey struct{}
+
+// withWriteRequest reserves an idempotency key for the write made with the
+// returned context: the watch's next one, or a new one for unwatched writes
+func withWriteRequest(ctx context.Context) context.Context {
+    return context.WithValue(ctx, writeRequestKey{}, &writeRequest{id: writeWatch(ctx).nextRequestID()})
+}
+
+// WriteRequestID returns the idempotency key of the write a transformer
+// runs for, so its before and after stages, and those of a watched write's
+// repeats, can be matched up. It is "" outside writes.
+func WriteRequestID(ctx context.Context) string {
+    if req, ok := ctx.Value(writeRequestKey{}).(*writeRequest); ok {
+        return req.id
+    }
+    return ""
+}
+
+// requestIDFor returns the idempotency key for a write: the one reserved for
+// it, or the watch's next one. Writes made by transformers with the same
+// context get their own. The watch may be nil.
+func (w *WriteWatch) requestIDFor(ctx context.Context) string {
+    if req, ok := ctx.Value(writeRequestKey{}).(*writeRequest); ok && req.sent.CompareAndSwap(false, true) {
+        return req.id
+    }
+    return w.nextRequestID()
+}
+
+// writeWatch returns the context's watch, if any
+func writeWatch(ctx context.Context) *WriteWatch {
+    watch, _ := ctx.Value(writeWatchKey{}).(*WriteWatch)
+    return watch
+}
+
+// Retryable reports whether a write gave up on a transient failure while
+// no other write succeeded, returning the failed write's idempotency key
+func (w *WriteWatch) Retryable() (string, bool) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.requestID, w.failed && !w.wrote
+}
+
+// nextRequestID returns the idempotency key for a write
+func (w *WriteWatch) nextRequestID() string {
+    if w != nil {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        if id := w.reuse; id != "" {
+            w.reuse = ""
+            return id
+        }
+    }
+    return newRequestID()
+}
+
+// succeed records a write that succeeded
+func (w *WriteWatch) succeed() {
+    if w != nil {
+        w.mu.Lock()
+        w.wrote = true
+        w.mu.Unlock()
+    }
+}
+
+// fail records a write that gave up on a transient failure
+func (w *WriteWatch) fail(requestID string) {
+    if w != nil {
+        w.mu.Lock()
+        if !w.failed {
+            w.failed, w.requestID = true, requestID
+        }
+        w.mu.Unlock()
+    }
+}
@@ -0,0 +1,22 @@
+// routes/account.go
+package routes
+
+import (
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/account"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterAccountRoutes registers chart of accounts routes. The fixed paths
+// come before /accounts/{id} so they are not taken for account IDs.
+func RegisterAccountRoutes(registry *routing.Registry, accountHandler *account.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/accounts", Handler: accountHandler.ListAccounts, Summary: "List or search accounts by name, type and classification",
+			Versions: map[string]http.HandlerFunc{routing.V2: accountHandler.ListAccountPage}},
+		routing.Route{Method: "POST", Path: "/accounts", Handler: accountHandler.CreateAccount, Summary: "Create an account", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/accounts/types", Handler: accountHandler.ListTypes, Summary: "List account types and their subtypes"},
+		routing.Route{Method: "GET", Path: "/accounts/deposit", Handler: accountHandler.ListDepositAccounts, Summary: "List the accounts payments can be deposited to"},
+		routing.Route{Method: "GET", Path: "/accounts/{id}", Handler: accountHandler.GetAccount, Summary: "Get an account"},
+	)
+}
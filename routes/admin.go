@@ -0,0 +1,156 @@
+// routes/admin.go
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/chaos"
+	"github.com/eGGnogSC/qbserver/internal/compliance"
+	"github.com/eGGnogSC/qbserver/internal/connstats"
+	"github.com/eGGnogSC/qbserver/internal/deprecation"
+	"github.com/eGGnogSC/qbserver/internal/lru"
+	"github.com/eGGnogSC/qbserver/internal/metering"
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/eGGnogSC/qbserver/internal/qbhealth"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+	"github.com/eGGnogSC/qbserver/internal/readonly"
+	"github.com/eGGnogSC/qbserver/internal/realmcopy"
+	"github.com/eGGnogSC/qbserver/internal/retryqueue"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/sandbox"
+	"github.com/eGGnogSC/qbserver/internal/shaping"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/internal/tenants"
+	"github.com/eGGnogSC/qbserver/nlp"
+)
+
+// RegisterAdminRoutes registers operator-only routes
+func RegisterAdminRoutes(
+	router *mux.Router,
+	adminAPIKey string,
+	usageHandler *nlp.UsageHandler,
+	toolPolicyHandler *nlp.ToolPolicyHandler,
+	transcriptHandler *nlp.TranscriptHandler,
+	tenantConfigHandler *tenantconfig.Handler,
+	sandboxHandler *sandbox.Handler,
+	realmCopyHandler *realmcopy.Handler,
+	chaosHandler *chaos.Handler,
+	replayHandler *qbwebhook.ReplayHandler,
+	adminOperationsHandler *operations.Handler,
+	meteringHandler *metering.Handler,
+	tenantHandler *tenants.Handler,
+	readOnlyHandler *readonly.Handler,
+	retryQueueHandler *retryqueue.Handler,
+	qbHealthHandler *qbhealth.Handler,
+	connStatsHandler *connstats.Handler,
+	complianceHandler *compliance.Handler,
+	apiRoutes *routing.Registry,
+	deprecationHandler *deprecation.Handler,
+	shapingHandler *shaping.Handler,
+) {
+	adminRouter := router.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(auth.AdminMiddleware(adminAPIKey))
+	
+	// Agent usage
+	adminRouter.HandleFunc("/nlp/usage", usageHandler.GetUsage).Methods("GET")
+	
+	// Agent tool permissions
+	adminRouter.HandleFunc("/nlp/tool-policies/{tenantID}", toolPolicyHandler.GetPolicy).Methods("GET")
+	adminRouter.HandleFunc("/nlp/tool-policies/{tenantID}", toolPolicyHandler.SavePolicy).Methods("PUT")
+	
+	// Agent transcript retention
+	adminRouter.HandleFunc("/nlp/transcript-retention/{tenantID}", transcriptHandler.GetRetention).Methods("GET")
+	adminRouter.HandleFunc("/nlp/transcript-retention/{tenantID}", transcriptHandler.SetRetention).Methods("PUT")
+	
+	// Self-serve tenant onboarding
+	adminRouter.HandleFunc("/tenants", tenantHandler.CreateTenant).Methods("POST")
+	adminRouter.HandleFunc("/tenants/{tenantID}", tenantHandler.GetTenant).Methods("GET")
+	adminRouter.HandleFunc("/tenants/{tenantID}/credentials", tenantHandler.SaveCredentials).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/credentials", tenantHandler.DeleteCredentials).Methods("DELETE")
+	adminRouter.HandleFunc("/tenants/{tenantID}/scopes", tenantHandler.SaveScopes).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/api-keys", tenantHandler.ListKeys).Methods("GET")
+	adminRouter.HandleFunc("/tenants/{tenantID}/api-keys", tenantHandler.CreateKey).Methods("POST")
+	adminRouter.HandleFunc("/tenants/{tenantID}/api-keys/{keyID}", tenantHandler.RevokeKey).Methods("DELETE")
+	adminRouter.HandleFunc("/tenants/{tenantID}/connect-links", tenantHandler.CreateConnectLink).Methods("POST")
+	adminRouter.HandleFunc("/tenants/{tenantID}/members", tenantHandler.ListMembers).Methods("GET")
+	adminRouter.HandleFunc("/tenants/{tenantID}/members/{userID}", tenantHandler.SaveMember).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/members/{userID}", tenantHandler.RemoveMember).Methods("DELETE")
+	
+	// Declarative tenant configuration
+	adminRouter.HandleFunc("/tenants/{tenantID}/config", tenantConfigHandler.GetConfig).Methods("GET")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config", tenantConfigHandler.ApplyConfig).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config/webhooks", tenantConfigHandler.ApplySection("webhooks")).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config/notification-channels", tenantConfigHandler.ApplySection("notification_channels")).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config/dunning", tenantConfigHandler.ApplySection("dunning")).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config/feature-flags", tenantConfigHandler.ApplySection("feature_flags")).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config/sync-conflicts", tenantConfigHandler.ApplySection("sync_conflicts")).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config/invoice-lifecycle", tenantConfigHandler.ApplySection("invoice_lifecycle")).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/config/commissions", tenantConfigHandler.ApplySection("commissions")).Methods("PUT")
+	
+	// Sandbox demo data
+	adminRouter.HandleFunc("/tenants/{tenantID}/sandbox/seed", sandboxHandler.Seed).Methods("POST")
+	
+	// Copy customers, items and open invoices between connected companies
+	adminRouter.HandleFunc("/tenants/{tenantID}/realm-copy", realmCopyHandler.Copy).Methods("POST")
+	
+	// Webhook history replay and backfill
+	adminRouter.HandleFunc("/tenants/{tenantID}/webhooks/replay", replayHandler.Replay).Methods("POST")
+	adminRouter.HandleFunc("/tenants/{tenantID}/webhooks/backfill", replayHandler.Backfill).Methods("POST")
+	
+	// Background operations of every tenant
+	adminRouter.HandleFunc("/operations/{id}", adminOperationsHandler.GetOperation).Methods("GET")
+	adminRouter.HandleFunc("/operations/{id}/result", adminOperationsHandler.GetResult).Methods("GET")
+	adminRouter.HandleFunc("/operations/{id}", adminOperationsHandler.CancelOperation).Methods("DELETE")
+	
+	// Plans, quotas and usage for billing
+	adminRouter.HandleFunc("/plans", meteringHandler.ListPlans).Methods("GET")
+	adminRouter.HandleFunc("/plans/{name}", meteringHandler.SavePlan).Methods("PUT")
+	adminRouter.HandleFunc("/plans/{name}", meteringHandler.DeletePlan).Methods("DELETE")
+	adminRouter.HandleFunc("/tenants/{tenantID}/plan", meteringHandler.AssignPlan).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/usage", meteringHandler.GetTenantUsage).Methods("GET")
+	
+	// Read-only mode and maintenance windows, globally and per tenant
+	adminRouter.HandleFunc("/read-only", readOnlyHandler.GetGlobal).Methods("GET")
+	adminRouter.HandleFunc("/read-only", readOnlyHandler.SetGlobal).Methods("PUT")
+	adminRouter.HandleFunc("/tenants/{tenantID}/read-only", readOnlyHandler.GetTenant).Methods("GET")
+	adminRouter.HandleFunc("/tenants/{tenantID}/read-only", readOnlyHandler.SetTenant).Methods("PUT")
+	adminRouter.HandleFunc("/maintenance-windows", readOnlyHandler.ListWindows).Methods("GET")
+	adminRouter.HandleFunc("/maintenance-windows", readOnlyHandler.ScheduleWindow).Methods("POST")
+	adminRouter.HandleFunc("/maintenance-windows/{id}", readOnlyHandler.CancelWindow).Methods("DELETE")
+	
+	// Writes queued for retry after QuickBooks failures
+	adminRouter.HandleFunc("/retry-queue", retryQueueHandler.ListWrites).Methods("GET")
+	adminRouter.HandleFunc("/retry-queue/{id}", retryQueueHandler.GetAnyWrite).Methods("GET")
+	adminRouter.HandleFunc("/retry-queue/{id}/retry", retryQueueHandler.RetryWrite).Methods("POST")
+	adminRouter.HandleFunc("/retry-queue/{id}", retryQueueHandler.DiscardWrite).Methods("DELETE")
+	
+	// QuickBooks API call statistics, deprecation notices and per-realm diagnostics
+	adminRouter.HandleFunc("/qb-api-health", qbHealthHandler.GetHealth).Methods("GET")
+	adminRouter.HandleFunc("/qb-api-health", qbHealthHandler.ResetHealth).Methods("DELETE")
+	adminRouter.HandleFunc("/realms/{realmID}/diagnostics", qbHealthHandler.RealmDiagnostics).Methods("GET")
+	
+	// QuickBooks connection and token analytics
+	adminRouter.HandleFunc("/analytics/connections", connStatsHandler.GetAnalytics).Methods("GET")
+	
+	// Intuit App Store checklist
+	adminRouter.HandleFunc("/compliance/check", complianceHandler.SelfCheck).Methods("GET")
+	
+	// Size, hits and evictions of in-memory caches
+	adminRouter.HandleFunc("/caches", lru.Handler).Methods("GET")
+	
+	// Declared API routes and their scopes, roles and rate-limit classes
+	adminRouter.HandleFunc("/routes", apiRoutes.Handler).Methods("GET")
+	
+	// Clients still calling deprecated routes and parameters
+	adminRouter.HandleFunc("/deprecations", deprecationHandler.GetReport).Methods("GET")
+	adminRouter.HandleFunc("/deprecations", deprecationHandler.ResetReport).Methods("DELETE")
+	
+	// Response profiles clients select with X-Response-Profile
+	adminRouter.HandleFunc("/response-profiles", shapingHandler.ListProfiles).Methods("GET")
+	adminRouter.HandleFunc("/response-profiles/{name}", shapingHandler.SaveProfile).Methods("PUT")
+	adminRouter.HandleFunc("/response-profiles/{name}", shapingHandler.DeleteProfile).Methods("DELETE")
+	
+	// Fault injection (development only)
+	adminRouter.HandleFunc("/chaos/rules", chaosHandler.GetRules).Methods("GET")
+	adminRouter.HandleFunc("/chaos/rules", chaosHandler.SetRules).Methods("PUT")
+}
@@ -11,10 +11,14 @@ func RegisterAuthRoutes(router *mux.Router, authHandler *auth.Handler) {
 	// Public auth routes
 	router.HandleFunc("/auth/connect", authHandler.ConnectHandler).Methods("GET")
 	router.HandleFunc("/auth/callback", authHandler.CallbackHandler).Methods("GET")
+	router.HandleFunc("/auth/disconnected", authHandler.DisconnectedHandler).Methods("GET")
 	
 	// Protected auth routes - require user authentication
 	protectedRouter := router.PathPrefix("/auth").Subrouter()
 	protectedRouter.Use(auth.UserMiddleware)
 	protectedRouter.HandleFunc("/disconnect", authHandler.DisconnectHandler).Methods("POST")
 	protectedRouter.HandleFunc("/status", authHandler.StatusHandler).Methods("GET")
+	protectedRouter.HandleFunc("/token/refresh", authHandler.RefreshHandler).Methods("POST")
+	protectedRouter.HandleFunc("/companies", authHandler.CompaniesHandler).Methods("GET")
+	protectedRouter.HandleFunc("/companies/{realmId}/activate", authHandler.ActivateCompanyHandler).Methods("POST")
 }
@@ -0,0 +1,14 @@
+// routes/bankexport.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/bankexport"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterBankExportRoutes registers OFX/QBO bank file export routes
+func RegisterBankExportRoutes(registry *routing.Registry, bankExportHandler *bankexport.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/exports/ofx", Handler: bankExportHandler.ExportOFX, Summary: "Export bank transactions as an OFX file", Class: routing.ClassReport},
+	)
+}
@@ -0,0 +1,20 @@
+// routes/bill.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/bill"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterBillRoutes registers accounts payable bill and bill payment routes
+func RegisterBillRoutes(registry *routing.Registry, billHandler *bill.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/bills", Handler: billHandler.ListBills, Summary: "List bills by vendor"},
+		routing.Route{Method: "POST", Path: "/bills", Handler: billHandler.CreateBill, Summary: "Record a vendor bill", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/bills/unpaid", Handler: billHandler.ListUnpaid, Summary: "List unpaid bills per vendor"},
+		routing.Route{Method: "GET", Path: "/bills/{id}", Handler: billHandler.GetBill, Summary: "Get a bill"},
+		routing.Route{Method: "GET", Path: "/bill-payments", Handler: billHandler.ListPayments, Summary: "List bill payments by vendor"},
+		routing.Route{Method: "POST", Path: "/bill-payments", Handler: billHandler.PayBills, Summary: "Pay a vendor's bills", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/bill-payments/{id}", Handler: billHandler.GetPayment, Summary: "Get a bill payment"},
+	)
+}
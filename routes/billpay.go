@@ -0,0 +1,29 @@
+// routes/billpay.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/billpay"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterBillPayRoutes registers vendor bill payment queue routes
+func RegisterBillPayRoutes(registry *routing.Registry, billPayHandler *billpay.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/billpay/payments", Handler: billPayHandler.ListPayments, Summary: "List scheduled bill payments"},
+		routing.Route{Method: "POST", Path: "/billpay/payments", Handler: billPayHandler.SchedulePayment, Summary: "Schedule a bill for payment on a date", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/billpay/payments/{id}", Handler: billPayHandler.CancelPayment, Summary: "Cancel a scheduled bill payment", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/billpay/run", Handler: billPayHandler.RunPayments, Summary: "Record the bill payments due today", Roles: routing.Editors, Class: routing.ClassBulk},
+		routing.Route{Method: "GET", Path: "/billpay/batches", Handler: billPayHandler.ListBatches, Summary: "List bill payment runs"},
+		routing.Route{Method: "GET", Path: "/billpay/batches/{id}", Handler: billPayHandler.GetBatch, Summary: "Get a bill payment run"},
+		routing.Route{Method: "GET", Path: "/billpay/batches/{id}/nacha", Handler: billPayHandler.ExportNACHA, Summary: "Export a run's ACH payments as a NACHA file", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/billpay/batches/{id}/positive-pay", Handler: billPayHandler.ExportPositivePay, Summary: "Export a run's checks as a positive pay file", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/billpay/checks/print", Handler: billPayHandler.PrintChecks, Summary: "Print and number the queued check payments", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/billpay/check-numbers/{accountId}", Handler: billPayHandler.GetCheckNumber, Summary: "Get a bank account's next check number"},
+		routing.Route{Method: "PUT", Path: "/billpay/check-numbers/{accountId}", Handler: billPayHandler.SetCheckNumber, Summary: "Set a bank account's next check number", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/billpay/settings", Handler: billPayHandler.GetSettings, Summary: "Get bill pay settings"},
+		routing.Route{Method: "PUT", Path: "/billpay/settings", Handler: billPayHandler.SaveSettings, Summary: "Save bill pay settings and bank details", Roles: []string{"admin"}},
+		routing.Route{Method: "GET", Path: "/billpay/payees/{vendorId}", Handler: billPayHandler.GetPayee, Summary: "Get a vendor's ACH bank details", Roles: routing.Editors},
+		routing.Route{Method: "PUT", Path: "/billpay/payees/{vendorId}", Handler: billPayHandler.SavePayee, Summary: "Save a vendor's ACH bank details", Roles: []string{"admin"}},
+		routing.Route{Method: "DELETE", Path: "/billpay/payees/{vendorId}", Handler: billPayHandler.DeletePayee, Summary: "Delete a vendor's ACH bank details", Roles: []string{"admin"}},
+	)
+}
@@ -0,0 +1,20 @@
+// routes/budget.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/budget"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterBudgetRoutes registers budget and budget vs actuals routes
+func RegisterBudgetRoutes(registry *routing.Registry, budgetHandler *budget.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/budgets", Handler: budgetHandler.ListBudgets, Summary: "List budgets"},
+		routing.Route{Method: "POST", Path: "/budgets", Handler: budgetHandler.CreateBudget, Summary: "Create a budget", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/budgets/import", Handler: budgetHandler.ImportBudget, Summary: "Import a budget from CSV", Class: routing.ClassBulk, Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/budgets/{id}", Handler: budgetHandler.GetBudget, Summary: "Get a budget"},
+		routing.Route{Method: "PUT", Path: "/budgets/{id}", Handler: budgetHandler.UpdateBudget, Summary: "Replace a budget", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/budgets/{id}", Handler: budgetHandler.DeleteBudget, Summary: "Delete a budget", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/budgets/{id}/variance", Handler: budgetHandler.GetVariance, Summary: "Compare a budget with actuals", Class: routing.ClassReport},
+	)
+}
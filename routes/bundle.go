@@ -0,0 +1,15 @@
+// routes/bundle.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/bundle"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterBundleRoutes registers configuration bundle export and import routes
+func RegisterBundleRoutes(registry *routing.Registry, bundleHandler *bundle.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/config/bundle", Handler: bundleHandler.ExportBundle, Summary: "Export the tenant's configuration bundle"},
+		routing.Route{Method: "POST", Path: "/config/bundle", Handler: bundleHandler.ImportBundle, Summary: "Import a configuration bundle", Class: routing.ClassBulk, Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,21 @@
+// routes/cdc.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/cdc"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/syncconflict"
+)
+
+// RegisterSyncRoutes registers incremental sync routes and the routes
+// pushing local drafts and reviewing their conflicts
+func RegisterSyncRoutes(registry *routing.Registry, cdcHandler *cdc.Handler, syncConflictHandler *syncconflict.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/sync/changes", Handler: cdcHandler.GetChanges, Summary: "List entities changed or deleted since a time", Class: routing.ClassReport},
+		routing.Route{Method: "GET", Path: "/sync/conflicts", Handler: syncConflictHandler.ListConflicts, Summary: "List unresolved sync conflicts"},
+		routing.Route{Method: "GET", Path: "/sync/conflicts/{id}", Handler: syncConflictHandler.GetConflict, Summary: "Get a sync conflict"},
+		routing.Route{Method: "POST", Path: "/sync/conflicts/{id}/resolve", Handler: syncConflictHandler.ResolveConflict, Summary: "Resolve a sync conflict", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/sync/conflicts/{id}", Handler: syncConflictHandler.DismissConflict, Summary: "Dismiss a sync conflict", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/{entity}/{id}/sync", Handler: syncConflictHandler.PushDraft, Summary: "Push a local draft of a record", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,15 @@
+// routes/closing.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/closing"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterClosingRoutes registers month-end close routes
+func RegisterClosingRoutes(registry *routing.Registry, closingHandler *closing.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/close/checklist", Handler: closingHandler.GetChecklist, Summary: "Run the month-end close checklist", Class: routing.ClassReport},
+		routing.Route{Method: "POST", Path: "/close", Handler: closingHandler.ClosePeriod, Summary: "Close the period", Roles: routing.Editors},
+	)
+}
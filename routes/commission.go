@@ -0,0 +1,18 @@
+// routes/commission.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/commission"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterCommissionRoutes registers sales rep and commission routes. They
+// are mounted with the invoice lifecycles, ahead of the invoice ID routes.
+func RegisterCommissionRoutes(registry *routing.Registry, commissionHandler *commission.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/invoices/{id}/sales-rep", Handler: commissionHandler.GetRep, Summary: "Get the sales rep an invoice is credited to"},
+		routing.Route{Method: "PUT", Path: "/invoices/{id}/sales-rep", Handler: commissionHandler.AssignRep, Summary: "Credit an invoice to a sales rep", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/invoices/{id}/sales-rep", Handler: commissionHandler.ClearRep, Summary: "Remove an invoice's sales rep tag", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/commissions/report", Handler: commissionHandler.GetReport, Summary: "Report commission earned per sales rep", Class: routing.ClassReport},
+	)
+}
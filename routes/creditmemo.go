@@ -0,0 +1,22 @@
+// routes/creditmemo.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/creditmemo"
+	"github.com/eGGnogSC/qbserver/internal/refundreceipt"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterCreditRoutes registers credit memo and refund receipt routes
+func RegisterCreditRoutes(registry *routing.Registry, creditMemoHandler *creditmemo.Handler, refundReceiptHandler *refundreceipt.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/credit-memos", Handler: creditMemoHandler.ListCreditMemos, Summary: "List credit memos by customer"},
+		routing.Route{Method: "POST", Path: "/credit-memos", Handler: creditMemoHandler.CreateCreditMemo, Summary: "Create a credit memo", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/credit-memos/unapplied", Handler: creditMemoHandler.ListUnapplied, Summary: "List unapplied credit per customer"},
+		routing.Route{Method: "GET", Path: "/credit-memos/{id}", Handler: creditMemoHandler.GetCreditMemo, Summary: "Get a credit memo"},
+		routing.Route{Method: "POST", Path: "/credit-memos/{id}/apply", Handler: creditMemoHandler.ApplyCreditMemo, Summary: "Apply a credit memo to open invoices", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/refund-receipts", Handler: refundReceiptHandler.ListRefundReceipts, Summary: "List refund receipts by customer"},
+		routing.Route{Method: "POST", Path: "/refund-receipts", Handler: refundReceiptHandler.CreateRefundReceipt, Summary: "Record a refund to a customer", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/refund-receipts/{id}", Handler: refundReceiptHandler.GetRefundReceipt, Summary: "Get a refund receipt"},
+	)
+}
@@ -0,0 +1,32 @@
+// routes/debug.go
+package routes
+
+import (
+	"expvar"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/profiling"
+)
+
+// RegisterDebugRoutes registers profiling and runtime debug routes for
+// operators holding the admin API key
+func RegisterDebugRoutes(router *mux.Router, adminAPIKey string) {
+	debugRouter := router.PathPrefix("/admin/debug").Subrouter()
+	debugRouter.Use(auth.AdminMiddleware(adminAPIKey))
+	
+	// Go profiles, e.g. go tool pprof https://host/admin/debug/pprof/heap
+	debugRouter.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	debugRouter.HandleFunc("/pprof/profile", pprof.Profile)
+	debugRouter.HandleFunc("/pprof/symbol", pprof.Symbol)
+	debugRouter.HandleFunc("/pprof/trace", pprof.Trace)
+	debugRouter.HandleFunc("/pprof/{profile}", profiling.ProfileHandler)
+	debugRouter.HandleFunc("/pprof/", pprof.Index)
+	
+	// Published variables, including cache statistics
+	debugRouter.Handle("/vars", expvar.Handler()).Methods("GET")
+	
+	// Goroutine and heap snapshot
+	debugRouter.HandleFunc("/snapshot", profiling.SnapshotHandler).Methods("GET")
+}
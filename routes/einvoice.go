@@ -0,0 +1,18 @@
+// routes/einvoice.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/einvoice"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterEInvoiceRoutes registers UBL export and PEPPOL identity routes
+func RegisterEInvoiceRoutes(registry *routing.Registry, einvoiceHandler *einvoice.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/invoices/{id}/ubl", Handler: einvoiceHandler.ExportUBL, Summary: "Export an invoice as UBL/PEPPOL XML"},
+		routing.Route{Method: "GET", Path: "/einvoice/seller", Handler: einvoiceHandler.GetSeller, Summary: "Get the seller's e-invoicing party"},
+		routing.Route{Method: "PUT", Path: "/einvoice/seller", Handler: einvoiceHandler.SaveSeller, Summary: "Save the seller's e-invoicing party", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/einvoice/buyers/{customerId}", Handler: einvoiceHandler.GetBuyer, Summary: "Get a customer's e-invoicing party"},
+		routing.Route{Method: "PUT", Path: "/einvoice/buyers/{customerId}", Handler: einvoiceHandler.SaveBuyer, Summary: "Save a customer's e-invoicing party", Roles: routing.Editors},
+	)
+}
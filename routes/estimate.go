@@ -0,0 +1,19 @@
+// routes/estimate.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/estimate"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterEstimateRoutes registers estimate (quote) routes
+func RegisterEstimateRoutes(registry *routing.Registry, estimateHandler *estimate.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/estimates", Handler: estimateHandler.ListEstimates, Summary: "List estimates by customer or status"},
+		routing.Route{Method: "POST", Path: "/estimates", Handler: estimateHandler.CreateEstimate, Summary: "Create an estimate", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/estimates/{id}", Handler: estimateHandler.GetEstimate, Summary: "Get an estimate"},
+		routing.Route{Method: "PUT", Path: "/estimates/{id}", Handler: estimateHandler.UpdateEstimate, Summary: "Update an estimate's given fields", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/estimates/{id}/send", Handler: estimateHandler.SendEstimate, Summary: "Email an estimate to the customer", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/estimates/{id}/convert", Handler: estimateHandler.ConvertEstimate, Summary: "Convert an accepted estimate to an invoice", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,20 @@
+// routes/expense.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/expense"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterExpenseRoutes registers mileage and expense claim routes
+func RegisterExpenseRoutes(registry *routing.Registry, expenseHandler *expense.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/expenses", Handler: expenseHandler.ListClaims, Summary: "List expense claims"},
+		routing.Route{Method: "POST", Path: "/expenses", Handler: expenseHandler.SubmitClaim, Summary: "Submit an expense claim", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/expenses/{id}", Handler: expenseHandler.GetClaim, Summary: "Get an expense claim"},
+		routing.Route{Method: "POST", Path: "/expenses/{id}/receipts", Handler: expenseHandler.UploadReceipt, Summary: "Attach a receipt to a claim", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/expenses/{id}/receipts/{receiptId}", Handler: expenseHandler.DownloadReceipt, Summary: "Download a claim's receipt"},
+		routing.Route{Method: "POST", Path: "/expenses/{id}/review", Handler: expenseHandler.ReviewClaim, Summary: "Approve or reject a claim", Roles: expense.ReviewerRoles},
+		routing.Route{Method: "POST", Path: "/expenses/{id}/convert", Handler: expenseHandler.ConvertClaim, Summary: "Post an approved claim to QuickBooks", Roles: expense.ReviewerRoles},
+	)
+}
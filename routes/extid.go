@@ -0,0 +1,17 @@
+// routes/extid.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/extid"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterExternalIDRoutes registers the external ID mapping routes
+func RegisterExternalIDRoutes(registry *routing.Registry, externalIDHandler *extid.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/external-ids/{system}/{entity}", Handler: externalIDHandler.ListMappings, Summary: "List external ID mappings"},
+		routing.Route{Method: "GET", Path: "/external-ids/{system}/{entity}/{externalId}", Handler: externalIDHandler.GetMapping, Summary: "Get an external ID mapping"},
+		routing.Route{Method: "PUT", Path: "/external-ids/{system}/{entity}/{externalId}", Handler: externalIDHandler.SaveMapping, Summary: "Map an external ID to a QuickBooks ID", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/external-ids/{system}/{entity}/{externalId}", Handler: externalIDHandler.DeleteMapping, Summary: "Delete an external ID mapping", Roles: routing.Editors},
+	)
+}
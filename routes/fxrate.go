@@ -0,0 +1,18 @@
+// routes/fxrate.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/fxrate"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterExchangeRateRoutes registers exchange rate and revaluation routes
+func RegisterExchangeRateRoutes(registry *routing.Registry, exchangeRateHandler *fxrate.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/exchange-rates", Handler: exchangeRateHandler.GetRate, Summary: "Get a currency's exchange rate on a date"},
+		routing.Route{Method: "GET", Path: "/exchange-rates/overrides", Handler: exchangeRateHandler.ListOverrides, Summary: "List manual exchange rates"},
+		routing.Route{Method: "PUT", Path: "/exchange-rates/overrides/{currency}/{date}", Handler: exchangeRateHandler.SetOverride, Summary: "Set a currency's exchange rate on a date", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/exchange-rates/overrides/{currency}/{date}", Handler: exchangeRateHandler.DeleteOverride, Summary: "Delete a manual exchange rate", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/exchange-rates/revaluation", Handler: exchangeRateHandler.Revaluation, Summary: "Report unrealized gains and losses on foreign-currency invoices", Class: routing.ClassReport},
+	)
+}
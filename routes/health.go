@@ -0,0 +1,14 @@
+// routes/health.go
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/readiness"
+)
+
+// RegisterHealthRoutes registers the unauthenticated liveness and readiness
+// probes
+func RegisterHealthRoutes(router *mux.Router, readinessHandler *readiness.Handler) {
+	router.HandleFunc("/healthz", readinessHandler.Live).Methods("GET")
+	router.HandleFunc("/readyz", readinessHandler.Ready).Methods("GET")
+}
@@ -0,0 +1,21 @@
+// routes/insights.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/insights"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterInsightsRoutes registers transaction insight routes
+func RegisterInsightsRoutes(registry *routing.Registry, insightsHandler *insights.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/insights", Handler: insightsHandler.ListFindings, Summary: "List transaction insights", Class: routing.ClassReport},
+		routing.Route{Method: "POST", Path: "/insights/scan", Handler: insightsHandler.RunScan, Summary: "Scan transactions for insights", Class: routing.ClassBulk, Roles: routing.Editors},
+		routing.Route{Method: "PUT", Path: "/insights/monitor", Handler: insightsHandler.Monitor, Summary: "Schedule insight scans", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/insights/cashflow-forecast", Handler: insightsHandler.CashflowForecast, Summary: "Forecast cash flow", Class: routing.ClassReport},
+		routing.Route{Method: "GET", Path: "/insights/audit", Handler: insightsHandler.AuditReport, Summary: "Report audit findings", Class: routing.ClassReport},
+		routing.Route{Method: "GET", Path: "/insights/quote-to-cash", Handler: insightsHandler.QuoteToCash, Summary: "Report quote-to-cash conversion", Class: routing.ClassReport},
+		routing.Route{Method: "POST", Path: "/insights/customer-metrics/refresh", Handler: insightsHandler.RefreshCustomerMetrics, Summary: "Recompute customer metrics", Class: routing.ClassBulk, Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/customers/{id}/metrics", Handler: insightsHandler.CustomerMetrics, Summary: "Get a customer's metrics", Class: routing.ClassReport},
+	)
+}
@@ -0,0 +1,25 @@
+// routes/intake.go
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/intake"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterIntakeRoutes registers document intake and draft routes, and the
+// endpoint inbound email providers post forwarded documents to
+func RegisterIntakeRoutes(registry *routing.Registry, webhookRouter *mux.Router, intakeHandler *intake.Handler, emailReceiver *intake.EmailReceiver) {
+	registry.Add(
+		routing.Route{Method: "POST", Path: "/intake/bills", Handler: intakeHandler.Intake, Summary: "Scan a receipt or bill into a bill or expense draft", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/intake/drafts", Handler: intakeHandler.ListDrafts, Summary: "List bill and expense drafts"},
+		routing.Route{Method: "GET", Path: "/intake/drafts/{id}", Handler: intakeHandler.GetDraft, Summary: "Get a draft"},
+		routing.Route{Method: "DELETE", Path: "/intake/drafts/{id}", Handler: intakeHandler.DiscardDraft, Summary: "Discard a draft", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/intake/drafts/{id}/document", Handler: intakeHandler.GetDocument, Summary: "Download a draft's scanned document"},
+		routing.Route{Method: "POST", Path: "/intake/drafts/{id}/confirm", Handler: intakeHandler.ConfirmDraft, Summary: "Create the bill or expense of a draft", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/intake/mailbox", Handler: intakeHandler.GetMailbox, Summary: "Get the address for emailing in receipts"},
+		routing.Route{Method: "POST", Path: "/intake/mailbox", Handler: intakeHandler.OpenMailbox, Summary: "Open a new address for emailing in receipts", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/intake/mailbox", Handler: intakeHandler.CloseMailbox, Summary: "Close the address for emailing in receipts", Roles: routing.Editors},
+	)
+	webhookRouter.HandleFunc("/email/{provider}", emailReceiver.Receive).Methods("POST")
+}
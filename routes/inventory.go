@@ -0,0 +1,20 @@
+// routes/inventory.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/inventory"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterInventoryRoutes registers inventory reorder routes
+func RegisterInventoryRoutes(registry *routing.Registry, inventoryHandler *inventory.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/inventory/reorder-points", Handler: inventoryHandler.ListReorderPoints, Summary: "List reorder points"},
+		routing.Route{Method: "PUT", Path: "/inventory/reorder-points", Handler: inventoryHandler.SaveReorderPoints, Summary: "Save reorder points", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/inventory/reorder-points/{itemId}", Handler: inventoryHandler.DeleteReorderPoint, Summary: "Delete an item's reorder point", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/inventory/low-stock", Handler: inventoryHandler.LowStockReport, Summary: "Report items below their reorder point", Class: routing.ClassReport},
+		routing.Route{Method: "GET", Path: "/inventory/reorder-suggestions", Handler: inventoryHandler.ReorderSuggestions, Summary: "Suggest purchase quantities", Class: routing.ClassReport},
+		routing.Route{Method: "PUT", Path: "/inventory/monitor", Handler: inventoryHandler.MonitorStock, Summary: "Schedule low-stock alerts", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/bills/{id}/landed-cost", Handler: inventoryHandler.AllocateLandedCost, Summary: "Allocate a bill's landed cost to items", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,18 @@
+// routes/invoicepdf.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/invoicepdf"
+	"github.com/eGGnogSC/qbserver/internal/payqr"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterInvoicePDFRoutes registers invoice PDF and payment QR code routes
+func RegisterInvoicePDFRoutes(registry *routing.Registry, invoicePDFHandler *invoicepdf.Handler, paymentQRHandler *payqr.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/invoices/{id}/pdf", Handler: invoicePDFHandler.GetPDF, Summary: "Render an invoice as PDF, branded with ?template=custom"},
+		routing.Route{Method: "GET", Path: "/invoices/{id}/qrcode.png", Handler: paymentQRHandler.GetQRCode, Summary: "Render an invoice's payment QR code as PNG"},
+		routing.Route{Method: "GET", Path: "/payment-qr", Handler: paymentQRHandler.GetSettings, Summary: "Get the tenant's payment QR code settings"},
+		routing.Route{Method: "PUT", Path: "/payment-qr", Handler: paymentQRHandler.SaveSettings, Summary: "Save the tenant's payment QR code settings", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,19 @@
+// routes/invoicestate.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/invoicestate"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterInvoiceStateRoutes registers the invoice lifecycle routes. They are
+// mounted with the upserts, ahead of the invoice ID routes that would
+// otherwise take /invoices/lifecycle.
+func RegisterInvoiceStateRoutes(registry *routing.Registry, invoiceStateHandler *invoicestate.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/invoices/lifecycle", Handler: invoiceStateHandler.ListInvoices, Summary: "List invoices by lifecycle status"},
+		routing.Route{Method: "GET", Path: "/invoices/lifecycle/machine", Handler: invoiceStateHandler.GetMachine, Summary: "Get the invoice statuses and transitions"},
+		routing.Route{Method: "GET", Path: "/invoices/{id}/lifecycle", Handler: invoiceStateHandler.GetLifecycle, Summary: "Get an invoice's lifecycle status and history"},
+		routing.Route{Method: "POST", Path: "/invoices/{id}/lifecycle", Handler: invoiceStateHandler.Transition, Summary: "Move an invoice to a lifecycle status", Roles: routing.Editors},
+	)
+}
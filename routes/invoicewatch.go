@@ -0,0 +1,14 @@
+// routes/invoicewatch.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/invoicewatch"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterInvoiceWatchRoutes registers the live invoice payment status stream
+func RegisterInvoiceWatchRoutes(registry *routing.Registry, invoiceWatchHandler *invoicewatch.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/invoices/{id}/watch", Handler: invoiceWatchHandler.Watch, Summary: "Stream an invoice's payment status"},
+	)
+}
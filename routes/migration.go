@@ -0,0 +1,14 @@
+// routes/migration.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/migration"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterMigrationRoutes registers QuickBooks Desktop import routes
+func RegisterMigrationRoutes(registry *routing.Registry, migrationHandler *migration.Handler) {
+	registry.Add(
+		routing.Route{Method: "POST", Path: "/migrations/lists", Handler: migrationHandler.ImportLists, Summary: "Import QuickBooks Desktop lists", Class: routing.ClassBulk, Roles: routing.Editors},
+	)
+}
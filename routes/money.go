@@ -0,0 +1,15 @@
+// routes/money.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterRoundingRoutes registers the realm rounding policy routes
+func RegisterRoundingRoutes(registry *routing.Registry, roundingHandler *money.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/rounding-policy", Handler: roundingHandler.GetPolicy, Summary: "Get the rounding policy"},
+		routing.Route{Method: "PUT", Path: "/rounding-policy", Handler: roundingHandler.SavePolicy, Summary: "Save the rounding policy", Roles: routing.Editors},
+	)
+}
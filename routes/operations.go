@@ -0,0 +1,16 @@
+// routes/operations.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterOperationRoutes registers the routes reporting on background operations
+func RegisterOperationRoutes(registry *routing.Registry, operationsHandler *operations.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/operations/{id}", Handler: operationsHandler.GetOperation, Summary: "Get a background operation"},
+		routing.Route{Method: "GET", Path: "/operations/{id}/result", Handler: operationsHandler.GetResult, Summary: "Download an operation's result"},
+		routing.Route{Method: "DELETE", Path: "/operations/{id}", Handler: operationsHandler.CancelOperation, Summary: "Cancel a background operation", Roles: routing.Editors},
+	)
+}
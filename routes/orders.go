@@ -0,0 +1,19 @@
+// routes/orders.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/orders"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterOrderRoutes registers order source and SKU mapping routes
+func RegisterOrderRoutes(registry *routing.Registry, ordersHandler *orders.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/orders/source", Handler: ordersHandler.GetConfig, Summary: "Get the storefront order source"},
+		routing.Route{Method: "PUT", Path: "/orders/source", Handler: ordersHandler.SaveConfig, Summary: "Save the storefront order source", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/orders/sync", Handler: ordersHandler.SyncOrders, Summary: "Import storefront orders", Class: routing.ClassBulk, Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/orders/sku-mappings", Handler: ordersHandler.ListMappings, Summary: "List SKU to item mappings"},
+		routing.Route{Method: "PUT", Path: "/orders/sku-mappings", Handler: ordersHandler.SaveMappings, Summary: "Save SKU to item mappings", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/orders/sku-mappings/{sku}", Handler: ordersHandler.DeleteMapping, Summary: "Delete a SKU mapping", Roles: routing.Editors},
+	)
+}
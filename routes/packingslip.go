@@ -0,0 +1,18 @@
+// routes/packingslip.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/packingslip"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterPackingSlipRoutes registers packing slip and branding routes
+func RegisterPackingSlipRoutes(registry *routing.Registry, packingSlipHandler *packingslip.Handler, brandingHandler *branding.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/invoices/{id}/packing-slip", Handler: packingSlipHandler.GetSlip, Summary: "Render an invoice's packing slip or delivery note as PDF"},
+		routing.Route{Method: "POST", Path: "/invoices/{id}/packing-slip", Handler: packingSlipHandler.AttachSlip, Summary: "Attach a packing slip or delivery note to an invoice", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/branding", Handler: brandingHandler.GetBranding, Summary: "Get the tenant's document branding"},
+		routing.Route{Method: "PUT", Path: "/branding", Handler: brandingHandler.SaveBranding, Summary: "Save the tenant's document branding", Roles: routing.Editors},
+	)
+}
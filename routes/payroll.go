@@ -0,0 +1,18 @@
+// routes/payroll.go
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/payroll"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterPayrollRoutes registers payroll import routes and provider webhooks
+func RegisterPayrollRoutes(registry *routing.Registry, webhookRouter *mux.Router, payrollHandler *payroll.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/payroll/config", Handler: payrollHandler.GetConfig, Summary: "Get the payroll import configuration"},
+		routing.Route{Method: "PUT", Path: "/payroll/config", Handler: payrollHandler.SaveConfig, Summary: "Save the payroll import configuration", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/payroll/import", Handler: payrollHandler.ImportCSV, Summary: "Import a payroll journal CSV", Class: routing.ClassBulk, Roles: routing.Editors},
+	)
+	webhookRouter.HandleFunc("/payroll/{provider}/{tenantID}", payrollHandler.Webhook).Methods("POST")
+}
@@ -0,0 +1,15 @@
+// routes/periodlock.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/periodlock"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterPeriodLockRoutes registers the accounting period lock routes
+func RegisterPeriodLockRoutes(registry *routing.Registry, periodLockHandler *periodlock.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/period-lock", Handler: periodLockHandler.GetStatus, Summary: "Get the period lock status"},
+		routing.Route{Method: "PUT", Path: "/period-lock/settings", Handler: periodLockHandler.SaveSettings, Summary: "Save period lock settings", Roles: []string{"admin"}},
+	)
+}
@@ -0,0 +1,14 @@
+// routes/project.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/project"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterProjectRoutes registers project routes
+func RegisterProjectRoutes(registry *routing.Registry, projectHandler *project.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/projects/{id}/profitability", Handler: projectHandler.GetProfitability, Summary: "Report a project's profitability", Class: routing.ClassReport},
+	)
+}
@@ -0,0 +1,13 @@
+// routes/qbwebhook.go
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+)
+
+// RegisterQuickBooksWebhookRoutes registers the endpoint QuickBooks Online
+// posts change notifications to
+func RegisterQuickBooksWebhookRoutes(webhookRouter *mux.Router, webhookReceiver *qbwebhook.Receiver) {
+	webhookRouter.HandleFunc("/quickbooks", webhookReceiver.Receive).Methods("POST")
+}
@@ -0,0 +1,16 @@
+// routes/readonly.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/readonly"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterReadOnlyRoutes registers the read-only mode status and queued
+// write routes
+func RegisterReadOnlyRoutes(registry *routing.Registry, readOnlyHandler *readonly.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/read-only", Handler: readOnlyHandler.GetStatus, Summary: "Get the read-only status"},
+		routing.Route{Method: "GET", Path: "/read-only/queue/{id}", Handler: readOnlyHandler.GetQueuedWrite, Summary: "Get a write queued during read-only mode"},
+	)
+}
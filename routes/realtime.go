@@ -0,0 +1,12 @@
+// routes/realtime.go
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/realtime"
+)
+
+// RegisterRealtimeRoutes registers the realtime WebSocket endpoint
+func RegisterRealtimeRoutes(router *mux.Router, realtimeHandler *realtime.Handler) {
+	router.HandleFunc("", realtimeHandler.Connect).Methods("GET")
+}
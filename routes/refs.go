@@ -0,0 +1,14 @@
+// routes/refs.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/refs"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterRefRoutes registers the reference resolution route
+func RegisterRefRoutes(registry *routing.Registry, refHandler *refs.Handler) {
+	registry.Add(
+		routing.Route{Method: "POST", Path: "/refs/resolve", Handler: refHandler.Resolve, Summary: "Resolve names and external IDs to references", Class: routing.ClassRead},
+	)
+}
@@ -0,0 +1,15 @@
+// routes/retryqueue.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/retryqueue"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterRetryQueueRoutes registers the route clients follow up on writes
+// queued for retry at
+func RegisterRetryQueueRoutes(registry *routing.Registry, retryQueueHandler *retryqueue.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/retry-queue/{id}", Handler: retryQueueHandler.GetWrite, Summary: "Get a write queued for retry"},
+	)
+}
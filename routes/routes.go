@@ -2,42 +2,302 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/account"
 	"github.com/eGGnogSC/qbserver/internal/auth"
+	"github.com/eGGnogSC/qbserver/internal/bankexport"
+	"github.com/eGGnogSC/qbserver/internal/bill"
+	"github.com/eGGnogSC/qbserver/internal/billpay"
+	"github.com/eGGnogSC/qbserver/internal/branding"
+	"github.com/eGGnogSC/qbserver/internal/budget"
+	"github.com/eGGnogSC/qbserver/internal/bundle"
+	"github.com/eGGnogSC/qbserver/internal/cdc"
+	"github.com/eGGnogSC/qbserver/internal/chaos"
+	"github.com/eGGnogSC/qbserver/internal/closing"
+	"github.com/eGGnogSC/qbserver/internal/coalesce"
+	"github.com/eGGnogSC/qbserver/internal/commission"
+	"github.com/eGGnogSC/qbserver/internal/compliance"
+	"github.com/eGGnogSC/qbserver/internal/connstats"
+	"github.com/eGGnogSC/qbserver/internal/creditmemo"
+	"github.com/eGGnogSC/qbserver/internal/einvoice"
+	"github.com/eGGnogSC/qbserver/internal/estimate"
 	"github.com/eGGnogSC/qbserver/internal/invoice"
+	"github.com/eGGnogSC/qbserver/internal/invoicepdf"
+	"github.com/eGGnogSC/qbserver/internal/invoicestate"
+	"github.com/eGGnogSC/qbserver/internal/invoicewatch"
+	"github.com/eGGnogSC/qbserver/internal/i18n"
 	"github.com/eGGnogSC/qbserver/internal/customer"
+	"github.com/eGGnogSC/qbserver/internal/deprecation"
+	"github.com/eGGnogSC/qbserver/internal/dryrun"
+	"github.com/eGGnogSC/qbserver/internal/expense"
+	"github.com/eGGnogSC/qbserver/internal/extid"
+	"github.com/eGGnogSC/qbserver/internal/fxrate"
+	"github.com/eGGnogSC/qbserver/internal/insights"
+	"github.com/eGGnogSC/qbserver/internal/intake"
+	"github.com/eGGnogSC/qbserver/internal/inventory"
 	"github.com/eGGnogSC/qbserver/internal/item"
+	"github.com/eGGnogSC/qbserver/internal/knowledge"
+	"github.com/eGGnogSC/qbserver/internal/metering"
+	"github.com/eGGnogSC/qbserver/internal/migration"
+	"github.com/eGGnogSC/qbserver/internal/money"
+	"github.com/eGGnogSC/qbserver/internal/operations"
+	"github.com/eGGnogSC/qbserver/internal/orders"
+	"github.com/eGGnogSC/qbserver/internal/packingslip"
 	"github.com/eGGnogSC/qbserver/internal/payment"
+	"github.com/eGGnogSC/qbserver/internal/payqr"
+	"github.com/eGGnogSC/qbserver/internal/payroll"
+	"github.com/eGGnogSC/qbserver/internal/periodlock"
+	"github.com/eGGnogSC/qbserver/internal/project"
+	"github.com/eGGnogSC/qbserver/internal/qbhealth"
+	"github.com/eGGnogSC/qbserver/internal/qbwebhook"
+	"github.com/eGGnogSC/qbserver/internal/readonly"
+	"github.com/eGGnogSC/qbserver/internal/realmcopy"
+	"github.com/eGGnogSC/qbserver/internal/realtime"
+	"github.com/eGGnogSC/qbserver/internal/refs"
+	"github.com/eGGnogSC/qbserver/internal/refundreceipt"
+	"github.com/eGGnogSC/qbserver/internal/retryqueue"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/sandbox"
+	"github.com/eGGnogSC/qbserver/internal/scripting"
+	"github.com/eGGnogSC/qbserver/internal/search"
+	"github.com/eGGnogSC/qbserver/internal/shaping"
+	"github.com/eGGnogSC/qbserver/internal/stripe"
+	"github.com/eGGnogSC/qbserver/internal/syncconflict"
+	"github.com/eGGnogSC/qbserver/internal/tenantconfig"
+	"github.com/eGGnogSC/qbserver/internal/tenants"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+	"github.com/eGGnogSC/qbserver/internal/totals"
+	"github.com/eGGnogSC/qbserver/internal/transform"
+	"github.com/eGGnogSC/qbserver/internal/trash"
+	"github.com/eGGnogSC/qbserver/internal/upsert"
+	"github.com/eGGnogSC/qbserver/internal/vendor"
+	"github.com/eGGnogSC/qbserver/internal/versions"
+	"github.com/eGGnogSC/qbserver/internal/warehouse"
 	"github.com/eGGnogSC/qbserver/nlp"
 )
 
+// Deps are the handlers and services the routes are served by. Fields are
+// named after the container's, so wiring them up cannot mix up two of the
+// same type.
+type Deps struct {
+	AuthHandler            *auth.Handler
+	AuthService            *auth.Service
+	InvoiceHandler         *invoice.Handler
+	CustomerHandler        *customer.Handler
+	ItemHandler            *item.Handler
+	PaymentHandler         *payment.Handler
+	VendorHandler          *vendor.Handler
+	AccountHandler         *account.Handler
+	BillHandler            *bill.Handler
+	EstimateHandler        *estimate.Handler
+	CreditMemoHandler      *creditmemo.Handler
+	RefundReceiptHandler   *refundreceipt.Handler
+	CDCHandler             *cdc.Handler
+	SyncConflictHandler    *syncconflict.Handler
+	InvoiceStateHandler    *invoicestate.Handler
+	CommissionHandler      *commission.Handler
+	AgentHandler           *nlp.AgentHandler
+	UsageTracker           *nlp.UsageTracker
+	ToolRegistry           *nlp.ToolRegistry
+	UsageHandler           *nlp.UsageHandler
+	ToolPolicyHandler      *nlp.ToolPolicyHandler
+	ScheduledTaskHandler   *nlp.ScheduledTaskHandler
+	TranscriptHandler      *nlp.TranscriptHandler
+	SearchHandler          *search.Handler
+	KnowledgeHandler       *knowledge.Handler
+	InsightsHandler        *insights.Handler
+	ClosingHandler         *closing.Handler
+	BudgetHandler          *budget.Handler
+	ProjectHandler         *project.Handler
+	InventoryHandler       *inventory.Handler
+	ExpenseHandler         *expense.Handler
+	IntakeHandler          *intake.Handler
+	EmailReceiver          *intake.EmailReceiver
+	BillPayHandler         *billpay.Handler
+	ExchangeRateHandler    *fxrate.Handler
+	PayrollHandler         *payroll.Handler
+	StripeHandler          *stripe.Handler
+	OrdersHandler          *orders.Handler
+	EInvoiceHandler        *einvoice.Handler
+	PackingSlipHandler     *packingslip.Handler
+	BrandingHandler        *branding.Handler
+	InvoicePDFHandler      *invoicepdf.Handler
+	PaymentQRHandler       *payqr.Handler
+	BankExportHandler      *bankexport.Handler
+	MigrationHandler       *migration.Handler
+	WarehouseHandler       *warehouse.Handler
+	TenantConfigHandler    *tenantconfig.Handler
+	BundleHandler          *bundle.Handler
+	SandboxHandler         *sandbox.Handler
+	RealmCopyHandler       *realmcopy.Handler
+	ChaosHandler           *chaos.Handler
+	TransformHandler       *transform.Handler
+	ScriptHandler          *scripting.Handler
+	ReplayHandler          *qbwebhook.ReplayHandler
+	WebhookReceiver        *qbwebhook.Receiver
+	InvoiceWatchHandler    *invoicewatch.Handler
+	RealtimeHandler        *realtime.Handler
+	Display                *i18n.Display
+	Shaper                 *shaping.Shaper
+	ShapingHandler         *shaping.Handler
+	TimeZoneService        timezone.Servicer
+	TimeZoneHandler        *timezone.Handler
+	PeriodLockHandler      *periodlock.Handler
+	RoundingService        money.Servicer
+	RoundingHandler        *money.Handler
+	UpsertHandler          *upsert.Handler
+	RefHandler             *refs.Handler
+	ExternalIDs            *extid.Store
+	ExternalIDHandler      *extid.Handler
+	OperationsHandler      *operations.Handler
+	AdminOperationsHandler *operations.Handler
+	Meter                  *metering.Meter
+	MeteringHandler        *metering.Handler
+	Tenants                *tenants.Service
+	TenantHandler          *tenants.Handler
+	ReadOnly               *readonly.Switch
+	ReadOnlyHandler        *readonly.Handler
+	RetryQueue             *retryqueue.Queue
+	RetryQueueHandler      *retryqueue.Handler
+	VersionHandler         *versions.Handler
+	TrashHandler           *trash.Handler
+	QBHealthHandler        *qbhealth.Handler
+	ConnStatsHandler       *connstats.Handler
+	ComplianceHandler      *compliance.Handler
+	Coalescer              *coalesce.Coalescer
+	APIRoutes              *routing.Registry
+	Deprecations           *deprecation.Tracker
+	DeprecationHandler     *deprecation.Handler
+}
+
 // SetupRoutes configures all API routes
-func SetupRoutes(
-	router *mux.Router,
-	authHandler *auth.Handler,
-	authService *auth.Service,
-	invoiceHandler *invoice.Handler,
-	customerHandler *customer.Handler,
-	itemHandler *item.Handler,
-	paymentHandler *payment.Handler,
-	agentHandler *nlp.AgentHandler,
-) {
+func SetupRoutes(router *mux.Router, deps Deps, adminAPIKey string) {
 	// Register auth routes
-	RegisterAuthRoutes(router, authHandler)
+	RegisterAuthRoutes(router, deps.AuthHandler)
+	RegisterOnboardingRoutes(router, deps.TenantHandler)
 	
-	// API routes - protected with QuickBooks auth
+	// API routes - protected with QuickBooks auth; onboarded tenants may
+	// authenticate with an API key instead of a user session
 	apiRouter := router.PathPrefix("/api").Subrouter()
+	apiRouter.Use(deps.Tenants.APIKeyMiddleware)
 	apiRouter.Use(auth.UserMiddleware)
-	apiRouter.Use(auth.QBAuthMiddleware(authService))
+	apiRouter.Use(deps.Tenants.MembershipMiddleware)
+	apiRouter.Use(auth.QBAuthMiddleware(deps.AuthService))
+	apiRouter.Use(deps.AuthService.RequireScope(auth.ScopeAccounting))
+	apiRouter.Use(deps.ReadOnly.Middleware)
+	apiRouter.Use(deps.RetryQueue.Middleware)
+	apiRouter.Use(deps.Meter.Middleware)
+	apiRouter.Use(deps.ExternalIDs.Middleware)
+	apiRouter.Use(deps.TimeZoneService.Middleware)
+	apiRouter.Use(periodlock.Middleware)
+	apiRouter.Use(deps.RoundingService.Middleware)
+	apiRouter.Use(dryrun.Middleware)
+	apiRouter.Use(totals.Middleware)
+	apiRouter.Use(deps.Display.Middleware)
+	apiRouter.Use(deps.Shaper.Middleware)
+	apiRouter.Use(deps.Coalescer.Middleware)
+	
+	// Inbound webhooks - authenticated by signature rather than user session
+	webhookRouter := router.PathPrefix("/webhooks").Subrouter()
 	
-	// Register domain-specific routes
-	RegisterInvoiceRoutes(apiRouter, invoiceHandler)
-	RegisterCustomerRoutes(apiRouter, customerHandler)
-	RegisterItemRoutes(apiRouter, itemHandler)
-	RegisterPaymentRoutes(apiRouter, paymentHandler)
+	// Register domain-specific routes, declared with their metadata and
+	// wrapped in the middlewares it drives. Every route is served under
+	// /api/v1 and /api/v2 as well as unprefixed, as v1. Upserts, invoice
+	// lifecycles and sales reps are mounted first so their fixed paths win
+	// over the entity ID routes.
+	routeMiddleware := []routing.Middleware{deps.Deprecations.Middleware, routing.RequireRoles, requireScopes(deps.AuthService), routing.Prioritize}
+	RegisterUpsertRoutes(deps.APIRoutes, deps.UpsertHandler)
+	RegisterInvoiceStateRoutes(deps.APIRoutes, deps.InvoiceStateHandler)
+	RegisterCommissionRoutes(deps.APIRoutes, deps.CommissionHandler)
+	deps.APIRoutes.Mount(apiRouter, routeMiddleware...)
+	for _, versionRouter := range deps.APIRoutes.VersionRouters(apiRouter) {
+		RegisterInvoiceRoutes(versionRouter, deps.InvoiceHandler)
+		RegisterCustomerRoutes(versionRouter, deps.CustomerHandler)
+		RegisterItemRoutes(versionRouter, deps.ItemHandler)
+		RegisterPaymentRoutes(versionRouter, deps.PaymentHandler)
+	}
+	RegisterVendorRoutes(deps.APIRoutes, deps.VendorHandler)
+	RegisterAccountRoutes(deps.APIRoutes, deps.AccountHandler)
+	RegisterBillRoutes(deps.APIRoutes, deps.BillHandler)
+	RegisterEstimateRoutes(deps.APIRoutes, deps.EstimateHandler)
+	RegisterCreditRoutes(deps.APIRoutes, deps.CreditMemoHandler, deps.RefundReceiptHandler)
+	RegisterSyncRoutes(deps.APIRoutes, deps.CDCHandler, deps.SyncConflictHandler)
+	RegisterSearchRoutes(deps.APIRoutes, deps.SearchHandler)
+	RegisterInsightsRoutes(deps.APIRoutes, deps.InsightsHandler)
+	RegisterClosingRoutes(deps.APIRoutes, deps.ClosingHandler)
+	RegisterBudgetRoutes(deps.APIRoutes, deps.BudgetHandler)
+	RegisterProjectRoutes(deps.APIRoutes, deps.ProjectHandler)
+	RegisterInventoryRoutes(deps.APIRoutes, deps.InventoryHandler)
+	RegisterExpenseRoutes(deps.APIRoutes, deps.ExpenseHandler)
+	RegisterIntakeRoutes(deps.APIRoutes, webhookRouter, deps.IntakeHandler, deps.EmailReceiver)
+	RegisterBillPayRoutes(deps.APIRoutes, deps.BillPayHandler)
+	RegisterExchangeRateRoutes(deps.APIRoutes, deps.ExchangeRateHandler)
+	RegisterStripeRoutes(deps.APIRoutes, deps.StripeHandler)
+	RegisterOrderRoutes(deps.APIRoutes, deps.OrdersHandler)
+	RegisterEInvoiceRoutes(deps.APIRoutes, deps.EInvoiceHandler)
+	RegisterPackingSlipRoutes(deps.APIRoutes, deps.PackingSlipHandler, deps.BrandingHandler)
+	RegisterInvoicePDFRoutes(deps.APIRoutes, deps.InvoicePDFHandler, deps.PaymentQRHandler)
+	RegisterBankExportRoutes(deps.APIRoutes, deps.BankExportHandler)
+	RegisterMigrationRoutes(deps.APIRoutes, deps.MigrationHandler)
+	RegisterWarehouseRoutes(deps.APIRoutes, deps.WarehouseHandler)
+	RegisterBundleRoutes(deps.APIRoutes, deps.BundleHandler)
+	RegisterTransformRoutes(deps.APIRoutes, deps.TransformHandler)
+	RegisterScriptRoutes(deps.APIRoutes, deps.ScriptHandler)
+	RegisterInvoiceWatchRoutes(deps.APIRoutes, deps.InvoiceWatchHandler)
+	RegisterTimeZoneRoutes(deps.APIRoutes, deps.TimeZoneHandler)
+	RegisterPeriodLockRoutes(deps.APIRoutes, deps.PeriodLockHandler)
+	RegisterRoundingRoutes(deps.APIRoutes, deps.RoundingHandler)
+	RegisterRefRoutes(deps.APIRoutes, deps.RefHandler)
+	RegisterExternalIDRoutes(deps.APIRoutes, deps.ExternalIDHandler)
+	RegisterOperationRoutes(deps.APIRoutes, deps.OperationsHandler)
+	RegisterUsageRoutes(deps.APIRoutes, deps.MeteringHandler)
+	RegisterReadOnlyRoutes(deps.APIRoutes, deps.ReadOnlyHandler)
+	RegisterRetryQueueRoutes(deps.APIRoutes, deps.RetryQueueHandler)
+	RegisterVersionRoutes(deps.APIRoutes, deps.VersionHandler)
+	RegisterTrashRoutes(deps.APIRoutes, deps.TrashHandler)
+	RegisterPayrollRoutes(deps.APIRoutes, webhookRouter, deps.PayrollHandler)
+	RegisterQuickBooksWebhookRoutes(webhookRouter, deps.WebhookReceiver)
+	deps.APIRoutes.Mount(apiRouter, routeMiddleware...)
+	
+	// Realtime entity updates over WebSocket
+	wsRouter := router.PathPrefix("/ws").Subrouter()
+	wsRouter.Use(deps.Tenants.APIKeyMiddleware)
+	wsRouter.Use(auth.UserMiddleware)
+	wsRouter.Use(deps.Tenants.MembershipMiddleware)
+	wsRouter.Use(auth.QBAuthMiddleware(deps.AuthService))
+	wsRouter.Use(deps.AuthService.RequireScope(auth.ScopeAccounting))
+	RegisterRealtimeRoutes(wsRouter, deps.RealtimeHandler)
 	
 	// Register NLP agent routes
 	agentRouter := router.PathPrefix("/agent").Subrouter()
+	agentRouter.Use(deps.Tenants.APIKeyMiddleware)
 	agentRouter.Use(auth.UserMiddleware)
-	agentRouter.HandleFunc("/query", agentHandler.ProcessCommand).Methods("POST")
+	agentRouter.Use(deps.Tenants.MembershipMiddleware)
+	agentRouter.Use(deps.UsageTracker.BudgetMiddleware)
+	agentRouter.Use(deps.ToolRegistry.PolicyMiddleware)
+	agentRouter.Handle("/query", deps.TranscriptHandler.RecordTranscript(deps.AgentHandler.ProcessCommand)).Methods("POST")
+	agentRouter.HandleFunc("/tasks", deps.ScheduledTaskHandler.ListTasks).Methods("GET")
+	agentRouter.HandleFunc("/tasks", deps.ScheduledTaskHandler.CreateTask).Methods("POST")
+	agentRouter.HandleFunc("/tasks/{id}", deps.ScheduledTaskHandler.CancelTask).Methods("DELETE")
+	agentRouter.HandleFunc("/transcript", deps.TranscriptHandler.ExportTranscript).Methods("GET")
+	agentRouter.HandleFunc("/transcript", deps.TranscriptHandler.DeleteTranscript).Methods("DELETE")
+	agentRouter.HandleFunc("/documents", deps.KnowledgeHandler.ListDocuments).Methods("GET")
+	agentRouter.HandleFunc("/documents", deps.KnowledgeHandler.UploadDocument).Methods("POST")
+	agentRouter.HandleFunc("/documents/{id}", deps.KnowledgeHandler.DeleteDocument).Methods("DELETE")
+	
+	// Register operator routes
+	RegisterAdminRoutes(router, adminAPIKey, deps.UsageHandler, deps.ToolPolicyHandler, deps.TranscriptHandler, deps.TenantConfigHandler, deps.SandboxHandler, deps.RealmCopyHandler, deps.ChaosHandler, deps.ReplayHandler, deps.AdminOperationsHandler, deps.MeteringHandler, deps.TenantHandler, deps.ReadOnlyHandler, deps.RetryQueueHandler, deps.QBHealthHandler, deps.ConnStatsHandler, deps.ComplianceHandler, deps.APIRoutes, deps.DeprecationHandler, deps.ShapingHandler)
+}
+
+// requireScopes enforces the QuickBooks scopes a route requires beyond the
+// API router's
+func requireScopes(authService *auth.Service) routing.Middleware {
+	return func(route routing.Route, next http.Handler) http.Handler {
+		for i := len(route.Scopes) - 1; i >= 0; i-- {
+			next = authService.RequireScope(route.Scopes[i])(next)
+		}
+		return next
+	}
 }
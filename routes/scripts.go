@@ -0,0 +1,17 @@
+// routes/scripts.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/scripting"
+)
+
+// RegisterScriptRoutes registers tenant script routes
+func RegisterScriptRoutes(registry *routing.Registry, scriptHandler *scripting.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/scripts", Handler: scriptHandler.ListScripts, Summary: "List tenant scripts"},
+		routing.Route{Method: "POST", Path: "/scripts/test", Handler: scriptHandler.TestScript, Summary: "Run a script against a sample payload"},
+		routing.Route{Method: "PUT", Path: "/scripts/{name}", Handler: scriptHandler.SaveScript, Summary: "Save a tenant script", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/scripts/{name}", Handler: scriptHandler.DeleteScript, Summary: "Delete a tenant script", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,15 @@
+// routes/search.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/search"
+)
+
+// RegisterSearchRoutes registers semantic search routes
+func RegisterSearchRoutes(registry *routing.Registry, searchHandler *search.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/search", Handler: searchHandler.Search, Summary: "Search QuickBooks records"},
+		routing.Route{Method: "POST", Path: "/search/reindex", Handler: searchHandler.Reindex, Summary: "Rebuild the search index", Class: routing.ClassBulk, Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,17 @@
+// routes/stripe.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/stripe"
+)
+
+// RegisterStripeRoutes registers Stripe payout reconciliation routes
+func RegisterStripeRoutes(registry *routing.Registry, stripeHandler *stripe.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/stripe/config", Handler: stripeHandler.GetConfig, Summary: "Get the Stripe configuration"},
+		routing.Route{Method: "PUT", Path: "/stripe/config", Handler: stripeHandler.SaveConfig, Summary: "Save the Stripe configuration", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/stripe/sync", Handler: stripeHandler.SyncPayouts, Summary: "Reconcile Stripe payouts", Class: routing.ClassBulk, Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/stripe/payouts", Handler: stripeHandler.ListPayouts, Summary: "List reconciled payouts"},
+	)
+}
@@ -0,0 +1,13 @@
+// routes/tenants.go
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/eGGnogSC/qbserver/internal/tenants"
+)
+
+// RegisterOnboardingRoutes registers the public connect link route handed
+// to onboarded tenants
+func RegisterOnboardingRoutes(router *mux.Router, tenantHandler *tenants.Handler) {
+	router.HandleFunc(tenants.ConnectPath+"{token}", tenantHandler.Connect).Methods("GET")
+}
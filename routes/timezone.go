@@ -0,0 +1,15 @@
+// routes/timezone.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/timezone"
+)
+
+// RegisterTimeZoneRoutes registers the realm time zone routes
+func RegisterTimeZoneRoutes(registry *routing.Registry, timeZoneHandler *timezone.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/company/timezone", Handler: timeZoneHandler.GetTimeZone, Summary: "Get the company's time zone"},
+		routing.Route{Method: "PUT", Path: "/company/timezone", Handler: timeZoneHandler.SetTimeZone, Summary: "Set the company's time zone", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,16 @@
+// routes/transform.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/transform"
+)
+
+// RegisterTransformRoutes registers payload transformation rule routes
+func RegisterTransformRoutes(registry *routing.Registry, transformHandler *transform.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/transform/rules", Handler: transformHandler.GetRules, Summary: "Get payload transformation rules"},
+		routing.Route{Method: "PUT", Path: "/transform/rules", Handler: transformHandler.SaveRules, Summary: "Save payload transformation rules", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/transform/preview", Handler: transformHandler.Preview, Summary: "Preview the rules on a payload"},
+	)
+}
@@ -0,0 +1,20 @@
+// routes/trash.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/trash"
+)
+
+// RegisterTrashRoutes registers the trash and the delete and void routes
+// that fill it
+func RegisterTrashRoutes(registry *routing.Registry, trashHandler *trash.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/trash", Handler: trashHandler.List, Summary: "List trashed records"},
+		routing.Route{Method: "GET", Path: "/trash/{id}", Handler: trashHandler.Get, Summary: "Get a trashed record"},
+		routing.Route{Method: "DELETE", Path: "/trash/{id}", Handler: trashHandler.Purge, Summary: "Purge a trashed record", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/trash/{id}/restore", Handler: trashHandler.Restore, Summary: "Restore a trashed record", Roles: routing.Editors},
+		routing.Route{Method: "DELETE", Path: "/{entity}/{id}", Handler: trashHandler.Delete, Summary: "Delete a record into the trash", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/{entity}/{id}/void", Handler: trashHandler.Void, Summary: "Void a record into the trash", Roles: routing.Editors},
+	)
+}
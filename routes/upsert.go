@@ -0,0 +1,15 @@
+// routes/upsert.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/upsert"
+)
+
+// RegisterUpsertRoutes registers the create-or-get routes for customers and items
+func RegisterUpsertRoutes(registry *routing.Registry, upsertHandler *upsert.Handler) {
+	registry.Add(
+		routing.Route{Method: "POST", Path: "/customers/upsert", Handler: upsertHandler.UpsertCustomer, Summary: "Create or get a customer", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/items/upsert", Handler: upsertHandler.UpsertItem, Summary: "Create or get an item", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,14 @@
+// routes/usage.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/metering"
+	"github.com/eGGnogSC/qbserver/internal/routing"
+)
+
+// RegisterUsageRoutes registers the tenant usage route
+func RegisterUsageRoutes(registry *routing.Registry, meteringHandler *metering.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/usage", Handler: meteringHandler.GetUsage, Summary: "Get the tenant's usage and quotas"},
+	)
+}
@@ -0,0 +1,22 @@
+// routes/vendor.go
+package routes
+
+import (
+	"net/http"
+
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/vendor"
+)
+
+// RegisterVendorRoutes registers accounts payable vendor routes
+func RegisterVendorRoutes(registry *routing.Registry, vendorHandler *vendor.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/vendors", Handler: vendorHandler.ListVendors, Summary: "List or search vendors by display name",
+			Versions: map[string]http.HandlerFunc{routing.V2: vendorHandler.ListVendorPage}},
+		routing.Route{Method: "POST", Path: "/vendors", Handler: vendorHandler.CreateVendor, Summary: "Create a vendor", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/vendors/{id}", Handler: vendorHandler.GetVendor, Summary: "Get a vendor"},
+		routing.Route{Method: "PUT", Path: "/vendors/{id}", Handler: vendorHandler.UpdateVendor, Summary: "Update a vendor's given fields", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/vendors/{id}/deactivate", Handler: vendorHandler.DeactivateVendor, Summary: "Deactivate a vendor", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/vendors/{id}/reactivate", Handler: vendorHandler.ReactivateVendor, Summary: "Reactivate a vendor", Roles: routing.Editors},
+	)
+}
@@ -0,0 +1,18 @@
+// routes/versions.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/versions"
+)
+
+// RegisterVersionRoutes registers the entity history, change feed and
+// restore routes
+func RegisterVersionRoutes(registry *routing.Registry, versionHandler *versions.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/invoices/{id}/versions", Handler: versionHandler.ListInvoiceVersions, Summary: "List an invoice's versions"},
+		routing.Route{Method: "GET", Path: "/invoices/{id}/versions/{version}", Handler: versionHandler.GetInvoiceVersion, Summary: "Get an invoice version"},
+		routing.Route{Method: "POST", Path: "/invoices/{id}/versions/{version}/restore", Handler: versionHandler.RestoreInvoiceVersion, Summary: "Restore an invoice version", Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/{entity}/{id}/changes", Handler: versionHandler.Changes, Summary: "Get a record's change history"},
+	)
+}
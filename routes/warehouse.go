@@ -0,0 +1,17 @@
+// routes/warehouse.go
+package routes
+
+import (
+	"github.com/eGGnogSC/qbserver/internal/routing"
+	"github.com/eGGnogSC/qbserver/internal/warehouse"
+)
+
+// RegisterWarehouseRoutes registers data warehouse export routes
+func RegisterWarehouseRoutes(registry *routing.Registry, warehouseHandler *warehouse.Handler) {
+	registry.Add(
+		routing.Route{Method: "GET", Path: "/warehouse/config", Handler: warehouseHandler.GetConfig, Summary: "Get the warehouse export configuration"},
+		routing.Route{Method: "PUT", Path: "/warehouse/config", Handler: warehouseHandler.SaveConfig, Summary: "Save the warehouse export configuration", Roles: routing.Editors},
+		routing.Route{Method: "POST", Path: "/warehouse/export", Handler: warehouseHandler.RunExport, Summary: "Export to the data warehouse", Class: routing.ClassBulk, Roles: routing.Editors},
+		routing.Route{Method: "GET", Path: "/warehouse/status", Handler: warehouseHandler.GetStatus, Summary: "Get the warehouse export status"},
+	)
+}